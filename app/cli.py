@@ -0,0 +1,123 @@
+"""
+Command Line Interface
+
+Runs the detection of the service locally, without its database nor its HTTP server, on a directory whose
+subdirectories are the submissions to compare:
+
+    python -m app.cli detect submissions/ --output results/ --language python --threshold 0.8 --html
+
+The exit code is 1 when a pair scores at or above the threshold, 0 otherwise (2 for invalid arguments or input),
+so that the check can be scripted.
+"""
+
+import argparse
+import logging
+import sys
+from pathlib import Path
+from typing import List, Optional
+
+from app.config.config import get_settings
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto, SimilarityMetric
+
+# Exit codes of the detect command
+EXIT_CLEAN = 0
+EXIT_FLAGGED = 1
+EXIT_INVALID = 2
+
+
+def build_parser() -> argparse.ArgumentParser:
+    parser = argparse.ArgumentParser(prog="python -m app.cli", description="Plagiarism detection, run locally")
+    commands = parser.add_subparsers(dest="command", required=True)
+
+    detect = commands.add_parser("detect", help="Compare every pair of the submissions of a directory")
+    detect.add_argument("submissions", type=Path, help="Directory whose subdirectories are the submissions")
+    detect.add_argument("--output", "-o", type=Path, default=Path("detection-results"), help="Output directory")
+    detect.add_argument("--language", help="Only compare the files of this language (e.g. python, go, java)")
+    detect.add_argument(
+        "--metric",
+        choices=[metric.value for metric in SimilarityMetric],
+        default=SimilarityMetric.WEIGHTED.value,
+        help="Metric producing the similarity scores",
+    )
+    detect.add_argument(
+        "--threshold",
+        type=float,
+        default=get_settings().similarity_flag_threshold,
+        help="Similarity from which a pair is flagged",
+    )
+    detect.add_argument("--ignore-comments", action="store_true", help="Remove the comments before comparing")
+    detect.add_argument("--ignore-imports", action="store_true", help="Remove the import statements before comparing")
+    detect.add_argument("--normalize-identifiers", action="store_true", help="Replace the identifiers by placeholders")
+    detect.add_argument("--normalize-literals", action="store_true", help="Replace the literals by STR and NUM")
+    detect.add_argument(
+        "--format", dest="formats", choices=["csv", "json"], action="append", help="Result formats (both by default)"
+    )
+    detect.add_argument("--html", action="store_true", help="Write an HTML report of each flagged pair")
+    detect.add_argument("--verbose", "-v", action="store_true", help="Log the progress of the run")
+    return parser
+
+
+def detect(args: argparse.Namespace) -> int:
+    """
+    Run the detection on the submissions of a directory, writing its results, with the configured comparison and
+    tokenization settings of the service but the options given
+    """
+    from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+    from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessor
+    from app.domains.submissions.local_detection_run import LocalDetectionRun, LocalRunWriter
+    from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+    from app.shared.services import get_tokenization_service
+
+    if not 0.0 <= args.threshold <= 1.0:
+        print("The threshold must be between 0 and 1", file=sys.stderr)
+        return EXIT_INVALID
+
+    settings = get_settings()
+    detection_options = DetectionOptionsDto(
+        metric=SimilarityMetric(args.metric),
+        ignore_comments=args.ignore_comments,
+        ignore_imports=args.ignore_imports,
+        normalize_identifiers=args.normalize_identifiers,
+        normalize_literals=args.normalize_literals,
+        min_fragment_tokens=settings.similarity_min_fragment_tokens,
+        min_function_tokens=settings.similarity_min_function_tokens,
+        file_aggregation=settings.similarity_file_aggregation,
+        aggregate_file_scores=settings.similarity_aggregate_file_scores,
+    )
+    local_run = LocalDetectionRun(
+        get_tokenization_service(),
+        SimilarityDetectionService(),
+        detection_options,
+        args.threshold,
+        args.language,
+        TokenizationOptionsDto(strip_headers=settings.strip_file_headers),
+        GoPackagePreprocessor(
+            goos=settings.go_build_goos, goarch=settings.go_build_goarch, skip_test_files=settings.go_skip_test_files
+        ),
+    )
+    try:
+        run = local_run.run(args.submissions)
+    except ValueError as e:
+        print(str(e), file=sys.stderr)
+        return EXIT_INVALID
+
+    written = LocalRunWriter(args.output).write(run, args.formats or ["csv", "json"], args.html)
+    flagged = [pair for pair in run["pairs"] if pair["flagged"]]
+    print(f"Compared {len(run['pairs'])} pairs of {len(run['submissions'])} submissions, {len(flagged)} flagged")
+    for pair in flagged:
+        print(f"  {pair['overall_similarity']:.2%}  {pair['submission']}  {pair['compared_submission']}")
+    for path in written:
+        print(f"Wrote {path}")
+    return EXIT_FLAGGED if flagged else EXIT_CLEAN
+
+
+def main(argv: Optional[List[str]] = None) -> int:
+    args = build_parser().parse_args(argv)
+    logging.basicConfig(level=logging.INFO if args.verbose else logging.WARNING, format="%(levelname)s %(message)s")
+    if args.command == "detect":
+        return detect(args)
+    return EXIT_INVALID
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -0,0 +1,118 @@
+from dataclasses import dataclass, field
+from typing import Any, Collection, Dict, List, Tuple
+
+from app.domains.detection.baseline_filter import BaselineFilter
+
+
+@dataclass
+class SanctionedOverlap:
+    """Fragments of a pair lying within allowed snippets in both files, and the tokens of the pair without them"""
+
+    tokens1: List[Dict[str, Any]]
+    tokens2: List[Dict[str, Any]]
+    fragments: List[Dict[str, Any]] = field(default_factory=list)
+    excluded_token_count1: int = 0
+    excluded_token_count2: int = 0
+
+
+class AllowedSnippetFilter(BaselineFilter):
+    """
+    Exclude from the comparisons the code every student is allowed to copy (allowed snippets published by the
+    lecturers: an input parsing function, a timing harness...).
+
+    The snippets are fingerprinted as the baselines are, as the hashes of their runs of `kgram_size` consecutive
+    tokens. Unlike the starter code, the code matching a snippet is not removed from each file on its own: only the
+    matched fragments of a pair lying within a region matching a snippet in both files are, so that a helper copied
+    by one of the files only, or the code around it, is still compared.
+    """
+
+    def regions(self, tokens: List[Dict[str, Any]], fingerprints: Collection[str]) -> List[Dict[str, Any]]:
+        """Locations (MatchPositionDto shape) of the runs of consecutive tokens of a file matching the snippets"""
+        if not fingerprints or len(tokens) < self.kgram_size:
+            return []
+
+        regions = []
+        region = None
+        for token, is_matched in zip(tokens, self.matched(tokens, fingerprints)):
+            if not is_matched or (region is not None and token.get("file") != region["file"]):
+                region = None
+            if not is_matched:
+                continue
+            if region is None:
+                region = {
+                    "file": token.get("file"),
+                    "start_line": token.get("start") or 0,
+                    "start_column": token.get("start_column"),
+                    "end_line": token.get("end") or 0,
+                    "end_column": token.get("end_column"),
+                }
+                regions.append(region)
+            elif self._end(token) > (region["end_line"], region["end_column"] or 0):
+                region.update(end_line=token.get("end") or 0, end_column=token.get("end_column"))
+        return regions
+
+    def sanction(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        fragments: List[Dict[str, Any]],
+        fingerprints: Collection[str],
+    ) -> SanctionedOverlap:
+        """
+        Find the fragments (FragmentDto shape) of a pair lying within a region matching the snippets in both files,
+        and remove the tokens lying within them from both token streams
+        """
+        regions1 = self.regions(tokens1, fingerprints)
+        regions2 = self.regions(tokens2, fingerprints)
+        sanctioned = [
+            fragment
+            for fragment in fragments
+            if self._within_any(fragment["left"], regions1) and self._within_any(fragment["right"], regions2)
+        ]
+        kept1 = self._exclude(tokens1, [fragment["left"] for fragment in sanctioned])
+        kept2 = self._exclude(tokens2, [fragment["right"] for fragment in sanctioned])
+        return SanctionedOverlap(
+            tokens1=kept1,
+            tokens2=kept2,
+            fragments=sanctioned,
+            excluded_token_count1=len(tokens1) - len(kept1),
+            excluded_token_count2=len(tokens2) - len(kept2),
+        )
+
+    def _exclude(self, tokens: List[Dict[str, Any]], positions: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Tokens of a stream not lying within any of the positions"""
+        if not positions:
+            return list(tokens)
+        return [token for token in tokens if not self._within_any(self._position(token), positions)]
+
+    @classmethod
+    def _within_any(cls, position: Dict[str, Any], regions: List[Dict[str, Any]]) -> bool:
+        file, start, end = cls._bounds(position)
+        for region in regions:
+            region_file, region_start, region_end = cls._bounds(region)
+            if file == region_file and region_start <= start and end <= region_end:
+                return True
+        return False
+
+    @staticmethod
+    def _position(token: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            "file": token.get("file"),
+            "start_line": token.get("start") or 0,
+            "start_column": token.get("start_column"),
+            "end_line": token.get("end") or 0,
+            "end_column": token.get("end_column"),
+        }
+
+    @staticmethod
+    def _end(token: Dict[str, Any]) -> Tuple[int, int]:
+        return token.get("end") or 0, token.get("end_column") or 0
+
+    @staticmethod
+    def _bounds(position: Dict[str, Any]) -> Tuple[str, Tuple[int, int], Tuple[int, int]]:
+        """Comparable start and end of a position, in the order of the lines then of the columns"""
+        return (
+            position.get("file") or "",
+            (position["start_line"], position.get("start_column") or 0),
+            (position["end_line"], position.get("end_column") or 0),
+        )
@@ -0,0 +1,84 @@
+import hashlib
+from dataclasses import dataclass, field
+from typing import Any, Collection, Dict, List
+
+# Number of consecutive tokens of a fingerprint: shorter runs shared with the starter code are still compared
+BASELINE_KGRAM_SIZE = 12
+# Length of the hexadecimal fingerprints stored with the baselines
+FINGERPRINT_LENGTH = 16
+
+
+@dataclass
+class BaselineSubtraction:
+    """Tokens of a file once the regions matching the baseline are removed"""
+
+    tokens: List[Dict[str, Any]]
+    regions: List[Dict[str, int]] = field(default_factory=list)
+    excluded_token_count: int = 0
+
+
+class BaselineFilter:
+    """
+    Subtract the instructor provided starter code (the baseline) from the compared token streams, so that the
+    code every submission legitimately shares does not count as similarity.
+
+    The baseline is fingerprinted once as the hashes of its runs of `kgram_size` consecutive tokens. A token of a
+    submission is removed when it belongs to a run whose fingerprint is in the baseline: a starter function kept
+    as is is removed entirely, while only the still matching fragments of a modified one are.
+    """
+
+    def __init__(self, kgram_size: int = BASELINE_KGRAM_SIZE):
+        self.kgram_size = kgram_size
+
+    def fingerprint(self, tokens: List[Dict[str, Any]]) -> List[str]:
+        """Get the sorted fingerprints of the baseline tokens"""
+        return sorted(set(self._kgram_hashes(tokens)))
+
+    def subtract(self, tokens: List[Dict[str, Any]], fingerprints: Collection[str]) -> BaselineSubtraction:
+        """Remove the tokens matching the baseline fingerprints, reporting the removed regions"""
+        if not fingerprints or len(tokens) < self.kgram_size:
+            return BaselineSubtraction(tokens=list(tokens))
+
+        matched = self.matched(tokens, fingerprints)
+        kept_tokens = [token for token, is_matched in zip(tokens, matched) if not is_matched]
+        subtraction = BaselineSubtraction(tokens=kept_tokens)
+        subtraction.excluded_token_count = len(tokens) - len(subtraction.tokens)
+
+        # Consecutive removed tokens form a region, reported with its lines
+        region = None
+        for token, is_matched in zip(tokens, matched):
+            if not is_matched:
+                region = None
+                continue
+            if region is None:
+                region = {"start": token.get("start", 0), "end": token.get("end", 0), "tokens": 0}
+                subtraction.regions.append(region)
+            region["end"] = max(region["end"], token.get("end", 0))
+            region["tokens"] += 1
+        return subtraction
+
+    def matched(self, tokens: List[Dict[str, Any]], fingerprints: Collection[str]) -> List[bool]:
+        """Whether each token belongs to a run of tokens whose fingerprint is among the given ones"""
+        fingerprints = set(fingerprints)
+        matched = [False] * len(tokens)
+        for index, kgram_hash in enumerate(self._kgram_hashes(tokens)):
+            if kgram_hash in fingerprints:
+                matched[index : index + self.kgram_size] = [True] * self.kgram_size
+        return matched
+
+    @staticmethod
+    def excluded_files(tokens: List[Dict[str, Any]], subtraction: BaselineSubtraction) -> List[str]:
+        """Get the files of the tokens (by their `file` tag) removed entirely as starter code"""
+        kept = {token.get("file") for token in subtraction.tokens}
+        return [file for file in dict.fromkeys(token.get("file") for token in tokens) if file and file not in kept]
+
+    def _kgram_hashes(self, tokens: List[Dict[str, Any]]) -> List[str]:
+        """Hash the runs of consecutive tokens, the text of each token being compared whitespace insensitively"""
+        token_hashes = [
+            hashlib.sha1(f"{token.get('type', '')}:{' '.join(token.get('text', '').split())}".encode("utf8")).digest()
+            for token in tokens
+        ]
+        return [
+            hashlib.sha1(b"".join(token_hashes[index : index + self.kgram_size])).hexdigest()[:FINGERPRINT_LENGTH]
+            for index in range(len(token_hashes) - self.kgram_size + 1)
+        ]
@@ -0,0 +1,95 @@
+from pathlib import Path
+from typing import Optional
+
+from app.config.config import get_settings
+from app.domains.detection.dto.dry_run_analysis_dto import DryRunAnalysisDto, DryRunAnalysisRequestDto
+from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.tokenization.tokenization_service import TokenizationService
+from app.shared.exceptions import ValidationException
+
+# Name of the analyzed snippet without file name, given the extension of its language when known
+SNIPPET_FILE_NAME = "snippet"
+
+
+class DryRunAnalyzer:
+    """
+    Analyze a snippet as the detection analyzes the files of the submissions, returning its tokens, the stream
+    compared once the detection options are applied and its winnowed fingerprints, without storing anything. The
+    snippet is capped in size, and tokenized within the configured timeout of the tokenization of a file.
+    """
+
+    def __init__(self, tokenization_service: TokenizationService, similarity_service: SimilarityDetectionService):
+        self.tokenization_service = tokenization_service
+        self.similarity_service = similarity_service
+
+    def analyze(self, request: DryRunAnalysisRequestDto) -> DryRunAnalysisDto:
+        """
+        Analyze a snippet with the options of the request
+
+        Raises:
+            ValidationException: If the snippet is over the size limit, or its language is not supported
+        """
+        settings = get_settings()
+        size = len(request.content.encode("utf-8"))
+        if size > settings.dry_run_max_bytes:
+            raise ValidationException(
+                "Analyzed content too large",
+                details={
+                    "error_type": "content_too_large",
+                    "message": f"The analyzed content is {size} bytes, over the limit of {settings.dry_run_max_bytes}",
+                    "max_bytes": settings.dry_run_max_bytes,
+                },
+            )
+        if request.language and request.language not in self.tokenization_service.get_supported_languages():
+            raise ValidationException(
+                "Unsupported language",
+                details={
+                    "error_type": "unsupported_language",
+                    "message": f"Language {request.language} is not supported",
+                    "language": request.language,
+                },
+            )
+
+        file_path = self._file_path(request)
+        language_detection = None
+        if not request.language:
+            language_detection = self.tokenization_service.detect_language_with_confidence(file_path, request.content)
+        tokenization_options = request.tokenization_options.model_copy(
+            update={"timeout_seconds": self._timeout_seconds(request.tokenization_options.timeout_seconds)}
+        )
+        result = self.tokenization_service.tokenize_with_details(
+            request.content, file_path, tokenization_options, language=request.language
+        )
+
+        options = request.detection_options
+        compared_tokens = self.similarity_service.prepare_for_similarity(result.tokens, options, result.language)
+        fingerprints = self.similarity_service.fingerprint(result.tokens, options, result.language)
+        return DryRunAnalysisDto(
+            language=result.language,
+            language_detection=language_detection,
+            language_fallback=result.language_fallback,
+            stripped_header=result.stripped_header,
+            unknown_token_percentage=result.unknown_token_percentage,
+            timed_out=result.timed_out,
+            token_count=len(result.tokens),
+            tokens=result.tokens,
+            compared_tokens=compared_tokens,
+            fingerprints=[fingerprint.to_dict() for fingerprint in fingerprints],
+            kgram_size=options.fingerprint_kgram_size,
+            window_size=options.fingerprint_window_size,
+        )
+
+    def _file_path(self, request: DryRunAnalysisRequestDto) -> Optional[Path]:
+        """Path of the snippet, its file name or one with the extension of its language, None if neither is known"""
+        if request.file_name:
+            return Path(Path(request.file_name).name)
+        extension = request.language and self.tokenization_service.get_language_extension(request.language)
+        return Path(f"{SNIPPET_FILE_NAME}{extension}") if extension else None
+
+    @staticmethod
+    def _timeout_seconds(requested: Optional[float]) -> Optional[float]:
+        """Timeout of the tokenization, the requested one capped by the configured one (0 meaning no timeout)"""
+        configured = get_settings().tokenization_file_timeout_seconds or None
+        if requested is None or configured is None:
+            return requested or configured
+        return min(requested, configured)
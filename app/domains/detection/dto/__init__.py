@@ -0,0 +1,21 @@
+from .detection_options_dto import DetectionOptionsDto
+from .file_similarity_dto import (
+    ExcludedFileDto,
+    FileAggregationDto,
+    FilePairScoreDto,
+    FileScoreDto,
+    FileSimilarityBreakdownDto,
+)
+from .token_match_dto import FragmentDto, MatchPositionDto, TokenMatchDto
+
+__all__ = [
+    "DetectionOptionsDto",
+    "ExcludedFileDto",
+    "FileAggregationDto",
+    "FilePairScoreDto",
+    "FileScoreDto",
+    "FileSimilarityBreakdownDto",
+    "FragmentDto",
+    "MatchPositionDto",
+    "TokenMatchDto",
+]
@@ -0,0 +1,156 @@
+from enum import Enum
+from typing import List
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+from app.domains.detection.file_similarity_breakdown import FileAggregation
+from app.domains.detection.function_similarity import DEFAULT_MIN_FUNCTION_TOKENS
+from app.domains.detection.greedy_string_tiling import DEFAULT_MIN_TILE_LENGTH
+from app.domains.detection.tfidf_model import DEFAULT_TFIDF_NGRAM_SIZE
+from app.domains.detection.token_match_finder import DEFAULT_MIN_FRAGMENT_TOKENS, DEFAULT_MIN_MATCH_TOKENS
+from app.domains.detection.winnowing import DEFAULT_KGRAM_SIZE, DEFAULT_WINDOW_SIZE
+
+# Size of the token k-grams compared by the k-gram Jaccard metric
+DEFAULT_JACCARD_KGRAM_SIZE = 3
+
+
+class SimilarityMetric(str, Enum):
+    """Metric producing the similarity score of a comparison"""
+
+    WEIGHTED = "weighted"  # Weighted combination of the signature, sequence, flow and operation similarities
+    KGRAM_JACCARD = "kgram_jaccard"  # Jaccard coefficient of the token k-gram sets, cheap and order insensitive
+    TFIDF_COSINE = "tfidf_cosine"  # Cosine of the token n-gram TF-IDF vectors, IDF computed across the run
+    STRUCTURAL = "structural"  # Go syntax tree shapes of the functions, matched regardless of their order
+    GREEDY_STRING_TILING = "greedy_string_tiling"  # Token coverage of the tiles, long reordered blocks found whole
+
+
+class DetectionOptionsDto(BaseModel):
+    """DTO for the options applied when comparing token streams"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ignore_struct_tags": False,
+                "ignore_comments": True,
+                "ignore_imports": True,
+                "docstrings_as_comments": True,
+                "normalize_identifiers": True,
+                "normalize_literals": True,
+                "literal_length_buckets": False,
+                "preserve_format_verbs": True,
+                "plain_text_lowercase": False,
+                "exclude_unreachable_functions": False,
+                "fingerprint_kgram_size": 5,
+                "fingerprint_window_size": 4,
+                "metric": "weighted",
+                "jaccard_kgram_size": 3,
+                "tfidf_ngram_size": 3,
+                "min_match_tokens": 12,
+                "min_fragment_tokens": 12,
+                "min_function_tokens": 30,
+                "cross_language": False,
+                "min_tile_length": 9,
+                "file_aggregation": "size_weighted",
+                "aggregate_file_scores": False,
+                "exclude_paths": ["tests/", "data/**"],
+            }
+        }
+    )
+
+    ignore_struct_tags: bool = Field(
+        default=False, description="If True, Go struct tags are ignored instead of being compared literally"
+    )
+    ignore_comments: bool = Field(
+        default=False,
+        description="If True, line and block comments of every language are removed before the comparison",
+    )
+    ignore_imports: bool = Field(
+        default=False,
+        description="If True, import, #include, using and require statements are removed before the comparison",
+    )
+    docstrings_as_comments: bool = Field(
+        default=False,
+        description="If True (with ignore_comments), Python module, class and function docstrings are removed too",
+    )
+    normalize_identifiers: bool = Field(
+        default=False,
+        description="If True, identifiers are replaced with positional placeholders (ID1, ID2...) to detect renames,"
+        " keywords and standard library names excluded",
+    )
+    normalize_literals: bool = Field(
+        default=False, description="If True, string literals are replaced with STR and numeric literals with NUM"
+    )
+    literal_length_buckets: bool = Field(
+        default=False, description="If True (with normalize_literals), the placeholders keep the literal length bucket"
+    )
+    preserve_format_verbs: bool = Field(
+        default=False,
+        description="If True (with normalize_literals), the verbs of format strings (%d, {}) are kept in STR",
+    )
+    plain_text_lowercase: bool = Field(
+        default=False, description="If True, files compared without tokenizer are lowercased before the comparison"
+    )
+    exclude_unreachable_functions: bool = Field(
+        default=False,
+        description="If True, Go and Python functions never called from an entry point are excluded as padding",
+    )
+    fingerprint_kgram_size: int = Field(
+        default=DEFAULT_KGRAM_SIZE, ge=1, description="Number of consecutive tokens hashed into a fingerprint"
+    )
+    fingerprint_window_size: int = Field(
+        default=DEFAULT_WINDOW_SIZE,
+        ge=1,
+        description="Number of consecutive k-gram hashes the winnowing keeps the minimum of, 1 keeping them all",
+    )
+    metric: SimilarityMetric = Field(
+        default=SimilarityMetric.WEIGHTED,
+        description="Metric of the score: weighted combination, k-gram Jaccard to pre-filter the pairs, TF-IDF"
+        " cosine down-weighting the code shared by the whole run, Go structural, or greedy string tiling coverage",
+    )
+    jaccard_kgram_size: int = Field(
+        default=DEFAULT_JACCARD_KGRAM_SIZE, ge=1, description="Number of tokens of the k-grams of the Jaccard metric"
+    )
+    tfidf_ngram_size: int = Field(
+        default=DEFAULT_TFIDF_NGRAM_SIZE, ge=1, description="Number of tokens of the n-grams of the TF-IDF metric"
+    )
+    min_match_tokens: int = Field(
+        default=DEFAULT_MIN_MATCH_TOKENS, ge=2, description="Minimum number of matching tokens of a reported match"
+    )
+    min_fragment_tokens: int = Field(
+        default=DEFAULT_MIN_FRAGMENT_TOKENS,
+        ge=1,
+        description="Minimum number of matching tokens (words for plain text) of a reported fragment",
+    )
+    min_function_tokens: int = Field(
+        default=DEFAULT_MIN_FUNCTION_TOKENS,
+        ge=1,
+        description="Minimum number of compared tokens of the functions matched across the submissions, shorter ones"
+        " (getters, setters...) being ignored",
+    )
+    cross_language: bool = Field(
+        default=False,
+        description="If True, files of different languages are compared through the abstract token categories",
+    )
+    min_tile_length: int = Field(
+        default=DEFAULT_MIN_TILE_LENGTH, ge=2, description="Minimum number of tokens of a greedy string tiling tile"
+    )
+    file_aggregation: FileAggregation = Field(
+        default=FileAggregation.SIZE_WEIGHTED,
+        description="Aggregation of the file pair scores into a score of the submissions: mean of the best score of"
+        " each file weighted by its tokens, or score of the most similar file pair",
+    )
+    aggregate_file_scores: bool = Field(
+        default=False,
+        description="If True, the overall similarity is the aggregation of the file pair scores instead of the score"
+        " of the metric",
+    )
+    exclude_paths: List[str] = Field(
+        default_factory=list,
+        description="Paths excluded from the comparison in gitignore syntax, relative to the root of the submissions,"
+        " the stored submissions being untouched",
+    )
+
+    @field_validator("exclude_paths")
+    def validate_exclude_paths(cls, v):
+        """Drop the blank patterns, the others being kept in order"""
+        return [pattern.strip() for pattern in v if pattern.strip()]
@@ -0,0 +1,158 @@
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.tokenization.dto.language_detection_dto import LanguageDetectionDto
+from app.domains.tokenization.dto.stripped_header_dto import StrippedHeaderDto
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.dto.tokenization_result_dto import LanguageFallbackDto
+
+
+class DryRunAnalysisRequestDto(BaseModel):
+    """DTO for a snippet analyzed as the detection would, nothing being stored"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "content": "def add(a, b):\n    return a + b\n",
+                "file_name": "solution.py",
+                "language": None,
+                "detection_options": {"normalize_identifiers": True, "ignore_comments": True},
+                "tokenization_options": {"strip_headers": True},
+            }
+        }
+    )
+
+    content: str = Field(..., description="Code analyzed, a snippet or the content of a small file")
+    file_name: Optional[str] = Field(
+        default=None, description="Name of the file of the code, its extension guiding the language detection"
+    )
+    language: Optional[str] = Field(
+        default=None, description="Language to tokenize the code with, detected from the file name and content if None"
+    )
+    detection_options: DetectionOptionsDto = Field(
+        default_factory=DetectionOptionsDto, description="Options preparing the tokens and their fingerprints"
+    )
+    tokenization_options: TokenizationOptionsDto = Field(
+        default_factory=TokenizationOptionsDto,
+        description="Options of the tokenizer, its timeout capped by the configured one",
+    )
+
+
+class AnalyzedTokenDto(BaseModel):
+    """DTO for a token of the analyzed code, as produced by the tokenizer"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"type": "identifier", "text": "add", "start": 0, "end": 0, "start_column": 4, "end_column": 7}
+        }
+    )
+
+    type: str = Field(..., description="Kind of the token (grammar node type)")
+    text: str = Field(..., description="Text of the token")
+    start: Optional[int] = Field(default=None, description="Line (0-based) the token starts on")
+    end: Optional[int] = Field(default=None, description="Line (0-based) the token ends on")
+    start_column: Optional[int] = Field(default=None, description="Byte column (0-based) of the start of the token")
+    end_column: Optional[int] = Field(default=None, description="Byte column following the token")
+
+
+class ComparedTokenDto(AnalyzedTokenDto):
+    """DTO for a token of the stream compared by the detection, once the detection options are applied"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "type": "identifier",
+                "text": "<VAR>",
+                "normalized": True,
+                "original_text": None,
+                "start": 0,
+                "end": 0,
+                "start_column": 4,
+                "end_column": 7,
+            }
+        }
+    )
+
+    normalized: bool = Field(..., description="Whether the text was replaced with a placeholder")
+    original_text: Optional[str] = Field(default=None, description="Text of a literal replaced with STR or NUM")
+
+
+class AnalyzedFingerprintDto(BaseModel):
+    """DTO for a winnowed fingerprint of the compared stream"""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"hash": "3f2a9c41d07b6e18", "position": 0, "start": 0, "end": 1}}
+    )
+
+    hash: str = Field(..., description="Hash of the k-gram, 16 hexadecimal digits")
+    position: int = Field(..., description="Position of the first token of the k-gram in the compared stream")
+    start: int = Field(..., description="Line (0-based) the k-gram starts on")
+    end: int = Field(..., description="Line (0-based) the k-gram ends on")
+
+
+class DryRunAnalysisDto(BaseModel):
+    """DTO for how the detection sees a snippet: its language, tokens, compared stream and fingerprints"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "language": "python",
+                "language_detection": {
+                    "language": "python",
+                    "confidence": 1.0,
+                    "method": "extension",
+                    "alternatives": [],
+                    "threshold": 0.5,
+                    "low_confidence": False,
+                },
+                "language_fallback": None,
+                "stripped_header": None,
+                "unknown_token_percentage": 0.0,
+                "timed_out": False,
+                "token_count": 12,
+                "tokens": [
+                    {"type": "identifier", "text": "add", "start": 0, "end": 0, "start_column": 4, "end_column": 7}
+                ],
+                "compared_tokens": [
+                    {
+                        "type": "identifier",
+                        "text": "<VAR>",
+                        "normalized": True,
+                        "original_text": None,
+                        "start": 0,
+                        "end": 0,
+                        "start_column": 4,
+                        "end_column": 7,
+                    }
+                ],
+                "fingerprints": [{"hash": "3f2a9c41d07b6e18", "position": 0, "start": 0, "end": 1}],
+                "kgram_size": 5,
+                "window_size": 4,
+            }
+        }
+    )
+
+    language: Optional[str] = Field(default=None, description="Language the code was tokenized with")
+    language_detection: Optional[LanguageDetectionDto] = Field(
+        default=None, description="Detection of the language, None when the language was given"
+    )
+    language_fallback: Optional[LanguageFallbackDto] = Field(
+        default=None, description="Content based fallback applied when the detected language tokenizer failed"
+    )
+    stripped_header: Optional[StrippedHeaderDto] = Field(
+        default=None, description="License or file header removed before tokenization"
+    )
+    unknown_token_percentage: float = Field(..., description="Percentage of tokens the tokenizer could not parse")
+    timed_out: bool = Field(..., description="Whether the tokenization stopped at its timeout, no tokens")
+    token_count: int = Field(..., description="Number of tokens of the code")
+    tokens: List[AnalyzedTokenDto] = Field(default_factory=list, description="Tokens of the code, in order")
+    compared_tokens: List[ComparedTokenDto] = Field(
+        default_factory=list, description="Tokens compared by the detection, after the detection options"
+    )
+    fingerprints: List[AnalyzedFingerprintDto] = Field(
+        default_factory=list, description="Winnowed fingerprints of the compared tokens"
+    )
+    kgram_size: int = Field(..., description="Number of consecutive compared tokens hashed together")
+    window_size: int = Field(..., description="Number of consecutive k-gram hashes a fingerprint is selected from")
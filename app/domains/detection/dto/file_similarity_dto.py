@@ -0,0 +1,82 @@
+from typing import Dict, List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class FileScoreDto(BaseModel):
+    """DTO for a compared file of a submission and its best-matching counterpart in the other one"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "file": "jobs/worker.go",
+                "tokens": 420,
+                "matched_tokens": 388,
+                "best_match": "worker.go",
+                "similarity": 0.92,
+            }
+        }
+    )
+
+    file: str = Field(..., description="Path of the file, relative to the submission")
+    tokens: int = Field(..., description="Number of compared tokens of the file")
+    matched_tokens: int = Field(..., description="Number of its tokens matching any file of the other submission")
+    best_match: Optional[str] = Field(default=None, description="File of the other submission it matches best")
+    similarity: float = Field(..., description="Similarity with its best match, 0 when nothing matches")
+
+
+class FilePairScoreDto(BaseModel):
+    """DTO for the similarity of a file of each submission sharing tokens"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"file1": "jobs/worker.go", "file2": "worker.go", "matched_tokens": 388, "similarity": 0.92}
+        }
+    )
+
+    file1: str = Field(..., description="File of the first submission")
+    file2: str = Field(..., description="File of the second submission")
+    matched_tokens: int = Field(..., description="Number of matching tokens of both files")
+    similarity: float = Field(..., description="Share of the tokens of both files that match")
+
+
+class ExcludedFileDto(BaseModel):
+    """DTO for a file left out of the comparison"""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"file": "api/api.pb.go", "reason": "generated code"}})
+
+    file: str = Field(..., description="Path of the file, relative to the submission")
+    reason: str = Field(..., description="Reason the file is not compared")
+
+
+class FileAggregationDto(BaseModel):
+    """DTO for the aggregation of the file pair scores into a score of the submissions"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "method": "size_weighted",
+                "formula": "sum(tokens(f) * best_similarity(f)) / sum(tokens(f)), over the compared files of both"
+                " submissions, best_similarity(f) being the score of f with its best-matching counterpart",
+                "pair_formula": "similarity(f1, f2) = 2 * matched_tokens(f1, f2) / (tokens(f1) + tokens(f2))",
+                "similarity": 0.64,
+            }
+        }
+    )
+
+    method: str = Field(..., description="Aggregation method: size_weighted or max")
+    formula: str = Field(..., description="Formula of the aggregated score")
+    pair_formula: str = Field(..., description="Formula of the score of a file pair")
+    similarity: float = Field(..., description="Aggregated score of the submissions")
+
+
+class FileSimilarityBreakdownDto(BaseModel):
+    """DTO for the file-to-file breakdown of the comparison of two submissions"""
+
+    files1: List[FileScoreDto] = Field(default=[], description="Compared files of the first submission")
+    files2: List[FileScoreDto] = Field(default=[], description="Compared files of the second submission")
+    pairs: List[FilePairScoreDto] = Field(default=[], description="File pairs sharing tokens, most similar first")
+    excluded_files: Dict[str, List[ExcludedFileDto]] = Field(
+        default={}, description="Files of each submission (file1, file2) left out of the comparison"
+    )
+    aggregation: FileAggregationDto
@@ -0,0 +1,52 @@
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class FunctionPositionDto(BaseModel):
+    """DTO for a function of a submission and its position"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"name": "processJobs", "file": "worker.go", "start": 12, "end": 48, "tokens": 214}
+        }
+    )
+
+    name: Optional[str] = Field(default=None, description="Name of the function, None for an anonymous one")
+    file: str = Field(..., description="Path of the file, relative to the submission")
+    start: int = Field(..., description="First line of the function (0-based)")
+    end: int = Field(..., description="Last line of the function (0-based)")
+    tokens: int = Field(..., description="Number of compared tokens of the function")
+
+
+class FunctionScoreDto(FunctionPositionDto):
+    """DTO for a function of a submission and its best-matching counterpart in the other one"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "name": "processJobs",
+                "file": "worker.go",
+                "start": 12,
+                "end": 48,
+                "tokens": 214,
+                "best_match": {"name": "runQueue", "file": "jobs/queue.go", "start": 30, "end": 66, "tokens": 220},
+                "similarity": 0.97,
+            }
+        }
+    )
+
+    best_match: Optional[FunctionPositionDto] = Field(
+        default=None, description="Function of the other submission it matches best"
+    )
+    similarity: float = Field(..., description="Similarity with its best match, 0 when nothing matches")
+
+
+class FunctionSimilaritiesDto(BaseModel):
+    """DTO for the function-level matches of the comparison of two submissions"""
+
+    functions1: List[FunctionScoreDto] = Field(default=[], description="Functions of the first submission")
+    functions2: List[FunctionScoreDto] = Field(default=[], description="Functions of the second submission")
+    max_similarity: float = Field(..., description="Similarity of the most similar functions, 0 without functions")
+    min_function_tokens: int = Field(..., description="Number of tokens under which functions are ignored")
+    formula: str = Field(..., description="Formula of the score of a function pair")
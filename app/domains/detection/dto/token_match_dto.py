@@ -0,0 +1,57 @@
+from typing import Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class MatchPositionDto(BaseModel):
+    """DTO for the location of a matched token run in one of the compared files"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"file": "src/main.go", "start_line": 12, "start_column": 0, "end_line": 27, "end_column": 1}
+        }
+    )
+
+    file: Optional[str] = Field(default=None, description="Path of the file, relative to the submission")
+    start_line: int = Field(..., description="Line (0-based) of the first token of the run")
+    start_column: Optional[int] = Field(default=None, description="Byte column (0-based) of the first token")
+    end_line: int = Field(..., description="Line (0-based) the last token of the run ends on")
+    end_column: Optional[int] = Field(default=None, description="Byte column following the last token")
+
+
+class TokenMatchDto(BaseModel):
+    """DTO for a maximal run of matching tokens shared by two files, the copied block shown to graders"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "file1": {"file": "src/main.go", "start_line": 12, "start_column": 0, "end_line": 27, "end_column": 1},
+                "file2": {"file": "main.go", "start_line": 40, "start_column": 0, "end_line": 55, "end_column": 1},
+                "tokens": 86,
+            }
+        }
+    )
+
+    file1: MatchPositionDto = Field(..., description="Location of the run in the first file")
+    file2: MatchPositionDto = Field(..., description="Location of the run in the second file")
+    tokens: int = Field(..., description="Number of matching tokens, overlapping runs being merged")
+
+
+class FragmentDto(BaseModel):
+    """DTO for a matched fragment of two files highlighted to graders, located by lines only in plain text"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "left": {"file": "src/main.go", "start_line": 12, "start_column": 0, "end_line": 27, "end_column": 1},
+                "right": {"file": "main.go", "start_line": 40, "start_column": 0, "end_line": 55, "end_column": 1},
+                "tokens": 86,
+                "similarity": 0.93,
+            }
+        }
+    )
+
+    left: MatchPositionDto = Field(..., description="Location of the fragment in the first file")
+    right: MatchPositionDto = Field(..., description="Location of the fragment in the second file")
+    tokens: int = Field(..., description="Number of matching tokens (words for plain text) of the fragment")
+    similarity: float = Field(..., description="Share of the tokens of both locations that match")
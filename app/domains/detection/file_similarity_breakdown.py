@@ -0,0 +1,126 @@
+from enum import Enum
+from typing import Any, Dict, List, Optional, Tuple
+
+
+class FileAggregation(str, Enum):
+    """Aggregation of the scores of the file pairs of two submissions into the score of the submissions"""
+
+    SIZE_WEIGHTED = "size_weighted"  # Mean of the best score of each file, weighted by its number of tokens
+    MAX = "max"  # Score of the most similar file pair
+
+
+AGGREGATION_FORMULAS = {
+    FileAggregation.SIZE_WEIGHTED: "sum(tokens(f) * best_similarity(f)) / sum(tokens(f)), over the compared files"
+    " of both submissions, best_similarity(f) being the score of f with its best-matching counterpart",
+    FileAggregation.MAX: "max(similarity(f1, f2)), over the pairs of a file of each submission",
+}
+
+PAIR_FORMULA = "similarity(f1, f2) = 2 * matched_tokens(f1, f2) / (tokens(f1) + tokens(f2))"
+
+
+class FileSimilarityBreakdown:
+    """
+    Break the comparison of two multi-file submissions down to their files: the matched tokens of the located
+    matches (each match lying in a single file of each submission) are summed by file pair, a pair scoring the
+    share of the tokens of both files that match. Each file is reported with its best-matching counterpart, and the
+    scores of the file pairs are aggregated into a score of the submissions (see FileAggregation).
+
+    The files of the original token streams left without compared tokens (only imports, only comments...) are
+    listed as excluded rather than missing.
+    """
+
+    def __init__(self, aggregation: FileAggregation = FileAggregation.SIZE_WEIGHTED):
+        self.aggregation = FileAggregation(aggregation)
+
+    def breakdown(
+        self,
+        matches: List[Dict[str, Any]],
+        compared_tokens1: List[Dict[str, Any]],
+        compared_tokens2: List[Dict[str, Any]],
+        tokens1: Optional[List[Dict[str, Any]]] = None,
+        tokens2: Optional[List[Dict[str, Any]]] = None,
+    ) -> Dict[str, Any]:
+        """
+        Get the scores of the file pairs sharing tokens (a sparse list, most similar first), the best counterpart
+        of each file and the aggregated score, from the located matches and the compared tokens of both submissions
+        """
+        sizes1 = self._sizes(compared_tokens1)
+        sizes2 = self._sizes(compared_tokens2)
+        shared: Dict[Tuple[str, str], int] = {}
+        for match in matches:
+            key = (match["file1"].get("file") or "", match["file2"].get("file") or "")
+            shared[key] = shared.get(key, 0) + match["tokens"]
+
+        pairs = []
+        for (file1, file2), tokens in shared.items():
+            total = sizes1.get(file1, 0) + sizes2.get(file2, 0)
+            pairs.append(
+                {
+                    "file1": file1,
+                    "file2": file2,
+                    "matched_tokens": tokens,
+                    "similarity": round(min(1.0, 2 * tokens / total), 3) if total else 0.0,
+                }
+            )
+        pairs.sort(key=lambda pair: (-pair["similarity"], pair["file1"], pair["file2"]))
+
+        files1 = self._files(sizes1, pairs, "file1", "file2")
+        files2 = self._files(sizes2, pairs, "file2", "file1")
+        return {
+            "files1": files1,
+            "files2": files2,
+            "pairs": pairs,
+            "excluded_files": {
+                "file1": self._excluded(tokens1, sizes1),
+                "file2": self._excluded(tokens2, sizes2),
+            },
+            "aggregation": {
+                "method": self.aggregation.value,
+                "formula": AGGREGATION_FORMULAS[self.aggregation],
+                "pair_formula": PAIR_FORMULA,
+                "similarity": self.aggregate(files1 + files2, pairs),
+            },
+        }
+
+    def aggregate(self, files: List[Dict[str, Any]], pairs: List[Dict[str, Any]]) -> float:
+        """Score of the submissions from the scores of their files and file pairs"""
+        if self.aggregation == FileAggregation.MAX:
+            return max((pair["similarity"] for pair in pairs), default=0.0)
+        total = sum(file["tokens"] for file in files)
+        weighted = sum(file["tokens"] * file["similarity"] for file in files)
+        return round(weighted / total, 3) if total else 0.0
+
+    @staticmethod
+    def _sizes(tokens: List[Dict[str, Any]]) -> Dict[str, int]:
+        """Number of compared tokens of each file, in the order of the token stream"""
+        sizes: Dict[str, int] = {}
+        for token in tokens:
+            file = token.get("file") or ""
+            sizes[file] = sizes.get(file, 0) + 1
+        return sizes
+
+    @staticmethod
+    def _files(sizes: Dict[str, int], pairs: List[Dict[str, Any]], side: str, other: str) -> List[Dict[str, Any]]:
+        """Files of a submission with their matched tokens and best-matching counterpart, pairs being sorted"""
+        files = {
+            file: {"file": file, "tokens": tokens, "matched_tokens": 0, "best_match": None, "similarity": 0.0}
+            for file, tokens in sizes.items()
+        }
+        for pair in pairs:
+            file = files.get(pair[side])
+            if file is None:
+                continue
+            file["matched_tokens"] = min(file["tokens"], file["matched_tokens"] + pair["matched_tokens"])
+            if file["best_match"] is None:
+                file["best_match"] = pair[other]
+                file["similarity"] = pair["similarity"]
+        return sorted(files.values(), key=lambda file: (-file["similarity"], file["file"]))
+
+    @staticmethod
+    def _excluded(tokens: Optional[List[Dict[str, Any]]], sizes: Dict[str, int]) -> List[Dict[str, str]]:
+        """Files of the original token stream left without any compared token"""
+        excluded = []
+        for file in dict.fromkeys(token.get("file") or "" for token in tokens or []):
+            if file not in sizes:
+                excluded.append({"file": file, "reason": "no compared tokens left after preprocessing"})
+        return excluded
@@ -0,0 +1,146 @@
+import re
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional, Sequence, Set
+
+from app.domains.detection.winnowing import DEFAULT_KGRAM_SIZE, Winnower
+
+# Functions shorter than this number of compared tokens (getters, setters...) are not matched
+DEFAULT_MIN_FUNCTION_TOKENS = 30
+
+# Function and method definition kinds of the languages whose token streams are segmented into functions
+FUNCTION_TYPES = {
+    "function_declaration",  # Go, JavaScript, TypeScript
+    "method_declaration",  # Go, Java
+    "function_definition",  # Python, C, C++
+    "async_function_definition",  # Python
+    "constructor_declaration",  # Java
+    "generator_function_declaration",  # JavaScript, TypeScript
+    "method_definition",  # JavaScript, TypeScript
+}
+# Name of a definition: the identifier before its parameters (`int add(int a, int b) {`), after the receiver of a
+# Go method
+GO_NAME_PATTERN = re.compile(r"^func\b\s*(?:\([^)]*\)\s*)?(\w+)")
+NAME_PATTERN = re.compile(r"(\w+)\s*\(")
+
+SCORE_FORMULA = "similarity(g1, g2) = |kgrams(g1) & kgrams(g2)| / |kgrams(g1) | kgrams(g2)|"
+
+
+@dataclass
+class FunctionSegment:
+    """A function of a token stream, with the k-gram hashes of its compared tokens"""
+
+    name: Optional[str]
+    file: str
+    start: int
+    end: int
+    tokens: int
+    hashes: Set[int]
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Position of the function in its file"""
+        return {"name": self.name, "file": self.file, "start": self.start, "end": self.end, "tokens": self.tokens}
+
+
+class FunctionSimilarity:
+    """
+    Match the functions of two submissions, so that a single copied function stands out of a pair diluted by the
+    rest of the code. Each file of the prepared token streams is segmented into its functions (Go, Python, Java,
+    C, C++, JavaScript and TypeScript definitions), a function being its definition token and the tokens following
+    it within its lines, nested functions included. The functions of fewer than `min_tokens` tokens are ignored.
+
+    Two functions score the Jaccard coefficient of the sets of k-gram hashes of their tokens, renamed functions
+    matching with normalized identifiers. Each function is reported with its best-matching counterpart, and the
+    pair with the score of its most similar functions.
+    """
+
+    def __init__(self, min_tokens: int = DEFAULT_MIN_FUNCTION_TOKENS, kgram_size: int = DEFAULT_KGRAM_SIZE):
+        self.min_tokens = min_tokens
+        self.winnower = Winnower(kgram_size=kgram_size)
+
+    def compare(
+        self,
+        tokens1: List[Dict[str, Any]],
+        parts1: Sequence[str],
+        tokens2: List[Dict[str, Any]],
+        parts2: Sequence[str],
+    ) -> Dict[str, Any]:
+        """
+        Get the functions of both submissions with their best match (most similar first) and the best score, from
+        the prepared tokens of both submissions and their signature parts
+        """
+        functions1 = self.segment(tokens1, parts1)
+        functions2 = self.segment(tokens2, parts2)
+        matched1 = self._best_matches(functions1, functions2)
+        matched2 = self._best_matches(functions2, functions1)
+        return {
+            "functions1": matched1,
+            "functions2": matched2,
+            "max_similarity": max((function["similarity"] for function in matched1 + matched2), default=0.0),
+            "min_function_tokens": self.min_tokens,
+            "formula": SCORE_FORMULA,
+        }
+
+    def segment(self, tokens: List[Dict[str, Any]], parts: Sequence[str]) -> List[FunctionSegment]:
+        """Find the functions of a token stream of at least `min_tokens` tokens"""
+        functions = []
+        for index, token in enumerate(tokens):
+            if token.get("type") not in FUNCTION_TYPES:
+                continue
+            file = token.get("file") or ""
+            start, end = token.get("start") or 0, token.get("end") or 0
+            last_index = index
+            while last_index + 1 < len(tokens) and self._within(tokens[last_index + 1], file, start, end):
+                last_index += 1
+            size = last_index - index + 1
+            if size < self.min_tokens:
+                continue
+            functions.append(
+                FunctionSegment(
+                    name=self._name(token.get("text", "")),
+                    file=file,
+                    start=start,
+                    end=end,
+                    tokens=size,
+                    hashes=set(self.winnower.kgram_hashes(parts[index : last_index + 1])),
+                )
+            )
+        return functions
+
+    @staticmethod
+    def _within(token: Dict[str, Any], file: str, start: int, end: int) -> bool:
+        """Whether a token lies on the lines of a function of a file"""
+        token_start, token_end = token.get("start") or 0, token.get("end") or 0
+        return (token.get("file") or "") == file and start <= token_start <= token_end <= end
+
+    @staticmethod
+    def _name(text: str) -> Optional[str]:
+        """Name of a function from the text of its definition, None for an anonymous one"""
+        text = text.strip()
+        match = GO_NAME_PATTERN.match(text) or NAME_PATTERN.search(text)
+        return match.group(1) if match else None
+
+    @staticmethod
+    def _best_matches(functions: List[FunctionSegment], others: List[FunctionSegment]) -> List[Dict[str, Any]]:
+        """Functions with their best-matching counterpart among the others, found through their shared k-grams"""
+        index: Dict[int, List[int]] = {}
+        for position, other in enumerate(others):
+            for kgram_hash in other.hashes:
+                index.setdefault(kgram_hash, []).append(position)
+
+        matched = []
+        for function in functions:
+            candidates = {position for kgram_hash in function.hashes for position in index.get(kgram_hash, [])}
+            best, best_score = None, 0.0
+            for position in sorted(candidates):
+                other = others[position]
+                score = len(function.hashes & other.hashes) / len(function.hashes | other.hashes)
+                if score > best_score:
+                    best, best_score = other, score
+            matched.append(
+                {
+                    **function.to_dict(),
+                    "best_match": best.to_dict() if best else None,
+                    "similarity": round(best_score, 3),
+                }
+            )
+        return sorted(matched, key=lambda function: (-function["similarity"], function["file"], function["start"]))
@@ -0,0 +1,121 @@
+import hashlib
+from collections import Counter
+from dataclasses import dataclass, field
+from typing import Any, Dict, List
+
+# Declarations compared as units: functions, methods and type specifications
+GO_UNIT_TYPES = {"function_declaration", "method_declaration", "type_spec"}
+# Node kinds abstracted in the shapes, their text being ignored anyway
+GO_IDENTIFIER_KINDS = {"identifier", "field_identifier", "type_identifier", "package_identifier", "label_name"}
+GO_LITERAL_KINDS = {
+    "interpreted_string_literal",
+    "raw_string_literal",
+    "int_literal",
+    "float_literal",
+    "imaginary_literal",
+    "rune_literal",
+    "true",
+    "false",
+    "nil",
+    "iota",
+}
+# Node kinds whose children are compared regardless of their order (struct fields, interface methods)
+GO_UNORDERED_KINDS = {"field_declaration_list", "interface_type"}
+IDENTIFIER_SHAPE = "ID"
+LITERAL_SHAPE = "LIT"
+
+
+@dataclass
+class StructuralUnit:
+    """A function, method or type of a Go file, reduced to the bag of the shapes of its subtrees"""
+
+    name: str
+    kind: str
+    start: int
+    end: int
+    subtrees: Counter = field(default_factory=Counter)
+
+    @property
+    def size(self) -> int:
+        return sum(self.subtrees.values())
+
+
+class GoStructuralAnalyzer:
+    """
+    Compare Go files by the structure of their syntax trees rather than by their token streams, so that reordered
+    declarations, reordered struct fields and code extracted into helpers are still matched.
+
+    Each function, method and type is reduced to the shapes of its subtrees: the node kinds only, identifiers and
+    literals abstracted, the children of unordered kinds (struct fields, interface methods) sorted. Units are
+    matched across files by the Dice coefficient of their subtree bags, and the file level score is the share of
+    the subtrees of each file found in the other one, so that a function split into helpers keeps its score.
+    """
+
+    def extract_units(self, root_node, source: bytes) -> List[StructuralUnit]:
+        """Get the units of a parsed Go file, in the order of the file"""
+        units = []
+        nodes_to_process = [root_node]
+        while nodes_to_process:
+            node = nodes_to_process.pop()
+            if node.type in GO_UNIT_TYPES:
+                name_node = node.child_by_field_name("name")
+                name = source[name_node.start_byte : name_node.end_byte].decode("utf8") if name_node else node.type
+                unit = StructuralUnit(name=name, kind=node.type, start=node.start_point[0], end=node.end_point[0])
+                self._shape(node, unit.subtrees)
+                units.append(unit)
+                continue
+            nodes_to_process.extend(reversed(node.named_children))
+        return units
+
+    def _shape(self, node, subtrees: Counter) -> str:
+        """Get the shape of a subtree, counting the shapes of its inner subtrees"""
+        if node.type in GO_IDENTIFIER_KINDS:
+            return IDENTIFIER_SHAPE
+        if node.type in GO_LITERAL_KINDS:
+            return LITERAL_SHAPE
+
+        children = [self._shape(child, subtrees) for child in node.named_children if child.type != "comment"]
+        if not children:
+            return node.type
+        if node.type in GO_UNORDERED_KINDS:
+            children.sort()
+
+        shape = hashlib.sha1(f"{node.type}({','.join(children)})".encode("utf8")).hexdigest()[:16]
+        subtrees[shape] += 1
+        return shape
+
+    def compare(self, units1: List[StructuralUnit], units2: List[StructuralUnit]) -> Dict[str, Any]:
+        """Match the units of two files and aggregate them into the structural similarity of the files"""
+        bag1 = sum((unit.subtrees for unit in units1), Counter())
+        bag2 = sum((unit.subtrees for unit in units2), Counter())
+        total = sum(bag1.values()) + sum(bag2.values())
+        if not total:
+            similarity = 1.0 if not units1 and not units2 else 0.0
+        else:
+            similarity = 2 * sum((bag1 & bag2).values()) / total
+
+        return {
+            "structural_similarity": round(similarity, 4),
+            "function_matches": [self._best_match(unit, units2) for unit in units1 if unit.size],
+            "units": {"file1": len(units1), "file2": len(units2)},
+        }
+
+    @staticmethod
+    def _best_match(unit: StructuralUnit, candidates: List[StructuralUnit]) -> Dict[str, Any]:
+        """Get the unit of the other file whose subtrees are the most similar (Dice coefficient) to those of a unit"""
+        match = {"unit1": unit.name, "lines1": [unit.start, unit.end], "unit2": None, "lines2": None, "similarity": 0.0}
+        for candidate in candidates:
+            # Types are matched with types, functions with functions and methods
+            if (candidate.kind == "type_spec") != (unit.kind == "type_spec"):
+                continue
+            common = sum((unit.subtrees & candidate.subtrees).values())
+            similarity = 2 * common / (unit.size + candidate.size) if candidate.size else 0.0
+            if similarity > match["similarity"]:
+                match.update(
+                    {
+                        "unit2": candidate.name,
+                        "lines2": [candidate.start, candidate.end],
+                        "similarity": round(similarity, 4),
+                    }
+                )
+        return match
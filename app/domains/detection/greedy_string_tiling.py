@@ -0,0 +1,145 @@
+from typing import Dict, Hashable, List, Sequence, Tuple
+
+from app.shared.deadlines import check_deadline
+
+# Minimum number of tokens of a tile of the greedy string tiling metric (JPlag uses 9 for most languages)
+DEFAULT_MIN_TILE_LENGTH = 9
+# Length of the first matches searched, halved down to the minimum tile length
+DEFAULT_INITIAL_SEARCH_LENGTH = 64
+# Karp-Rabin hashing of the token windows
+HASH_BASE = 1_000_003
+HASH_MODULUS = (1 << 61) - 1
+
+
+class GreedyStringTiler:
+    """
+    Running-Karp-Rabin Greedy String Tiling (Wise, as in JPlag) of two token streams: the longest common runs of
+    unmarked tokens are found, marked as tiles, then the longest ones among the remaining tokens, down to the
+    minimum tile length. Unlike fingerprint overlap, a block moved elsewhere in the other file is a single tile.
+
+    Runs are searched with a decreasing search length: the unmarked windows of that length of the second stream
+    are hashed (Karp-Rabin), those of the first stream looked up and the hits extended as far as they match. A hit
+    much longer than the search length restarts the search at its length, so that the longest tiles come first.
+    The worst case stays quadratic in the token count (many equal windows), see the benchmark of the tests; the
+    scan checks the deadline of the comparison, if any, so that a pathological pair times out.
+    """
+
+    def __init__(
+        self, min_length: int = DEFAULT_MIN_TILE_LENGTH, initial_search_length: int = DEFAULT_INITIAL_SEARCH_LENGTH
+    ):
+        self.min_length = min_length
+        self.initial_search_length = max(min_length, initial_search_length)
+
+    def tile(self, parts1: Sequence[Hashable], parts2: Sequence[Hashable]) -> List[Tuple[int, int, int]]:
+        """Get the tiles as (first index in parts1, first index in parts2, length), in the order of parts1"""
+        if len(parts1) < self.min_length or len(parts2) < self.min_length:
+            return []
+
+        symbols: Dict[Hashable, int] = {}
+        sequence1 = [symbols.setdefault(part, len(symbols) + 1) for part in parts1]
+        sequence2 = [symbols.setdefault(part, len(symbols) + 1) for part in parts2]
+        marked1 = [False] * len(sequence1)
+        marked2 = [False] * len(sequence2)
+        tiles: List[Tuple[int, int, int]] = []
+
+        search_length = self.initial_search_length
+        while True:
+            longest, matches = self._scan(sequence1, sequence2, marked1, marked2, search_length)
+            if longest > 2 * search_length:
+                search_length = longest
+                continue
+            added = self._mark(matches, marked1, marked2, tiles)
+            if search_length > 2 * self.min_length:
+                search_length //= 2
+            elif search_length > self.min_length:
+                search_length = self.min_length
+            elif not added:
+                break
+        return sorted(tiles)
+
+    @staticmethod
+    def coverage(tiles: List[Tuple[int, int, int]], length1: int, length2: int) -> Dict[str, float]:
+        """Share of the tokens of each stream covered by the tiles, and the similarity of both (JPlag)"""
+        tiled = sum(length for _, _, length in tiles)
+        return {
+            "file1": tiled / length1 if length1 else 0.0,
+            "file2": tiled / length2 if length2 else 0.0,
+            "similarity": 2 * tiled / (length1 + length2) if length1 + length2 else 0.0,
+        }
+
+    def _scan(
+        self,
+        sequence1: List[int],
+        sequence2: List[int],
+        marked1: List[bool],
+        marked2: List[bool],
+        search_length: int,
+    ) -> Tuple[int, List[Tuple[int, int, int]]]:
+        """
+        Find the maximal matches of at least the search length among the unmarked tokens, as (length, index1,
+        index2). The scan stops at the first match longer than twice the search length, only its length returned.
+        """
+        free1 = self._unmarked_runs(marked1)
+        free2 = self._unmarked_runs(marked2)
+        hashes1, power = self._prefix_hashes(sequence1, search_length)
+        hashes2, _ = self._prefix_hashes(sequence2, search_length)
+
+        windows2: Dict[int, List[int]] = {}
+        for index2 in range(len(sequence2) - search_length + 1):
+            if free2[index2] >= search_length:
+                window_hash = (hashes2[index2 + search_length] - hashes2[index2] * power) % HASH_MODULUS
+                windows2.setdefault(window_hash, []).append(index2)
+
+        longest = 0
+        matches = []
+        for index1 in range(len(sequence1) - search_length + 1):
+            check_deadline()
+            if free1[index1] < search_length:
+                continue
+            window_hash = (hashes1[index1 + search_length] - hashes1[index1] * power) % HASH_MODULUS
+            for index2 in windows2.get(window_hash, ()):
+                if sequence1[index1 : index1 + search_length] != sequence2[index2 : index2 + search_length]:
+                    continue  # Hash collision
+                length = search_length
+                limit = min(free1[index1], free2[index2])
+                while length < limit and sequence1[index1 + length] == sequence2[index2 + length]:
+                    length += 1
+                if length > 2 * search_length:
+                    return length, []
+                matches.append((length, index1, index2))
+                longest = max(longest, length)
+        return longest, matches
+
+    @staticmethod
+    def _mark(
+        matches: List[Tuple[int, int, int]],
+        marked1: List[bool],
+        marked2: List[bool],
+        tiles: List[Tuple[int, int, int]],
+    ) -> bool:
+        """Mark the matches as tiles, longest first, skipping those overlapping a tile. Whether a tile was added"""
+        added = False
+        for length, index1, index2 in sorted(matches, key=lambda match: -match[0]):
+            if any(marked1[index1 : index1 + length]) or any(marked2[index2 : index2 + length]):
+                continue
+            marked1[index1 : index1 + length] = [True] * length
+            marked2[index2 : index2 + length] = [True] * length
+            tiles.append((index1, index2, length))
+            added = True
+        return added
+
+    @staticmethod
+    def _unmarked_runs(marked: List[bool]) -> List[int]:
+        """Number of consecutive unmarked tokens from each index"""
+        runs = [0] * (len(marked) + 1)
+        for index in range(len(marked) - 1, -1, -1):
+            runs[index] = 0 if marked[index] else runs[index + 1] + 1
+        return runs
+
+    @staticmethod
+    def _prefix_hashes(sequence: List[int], window: int) -> Tuple[List[int], int]:
+        """Karp-Rabin hashes of the prefixes of a sequence, and the power of the base removing a window from them"""
+        hashes = [0] * (len(sequence) + 1)
+        for index, symbol in enumerate(sequence):
+            hashes[index + 1] = (hashes[index] * HASH_BASE + symbol) % HASH_MODULUS
+        return hashes, pow(HASH_BASE, window, HASH_MODULUS)
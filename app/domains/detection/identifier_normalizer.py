@@ -0,0 +1,228 @@
+import re
+from typing import Any, Dict, List, Optional, Set
+
+# Token kinds holding a name chosen by the author of the code
+IDENTIFIER_TYPES = {
+    "identifier",
+    "field_identifier",
+    "type_identifier",
+    "package_identifier",
+    "property_identifier",
+    "shorthand_property_identifier",
+    "private_property_identifier",
+    "simple_identifier",
+    "type_parameter",
+    "constant",
+}
+
+# Keywords that some grammars emit as identifiers, builtin functions and types, per language
+LANGUAGE_BUILTINS: Dict[str, Set[str]] = {
+    "go": set(
+        """
+        bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8
+        uint16 uint32 uint64 uintptr any comparable true false iota nil append cap clear close complex copy delete
+        imag len make max min new panic print println real recover main init _
+        """.split()
+    ),
+    "python": set(
+        """
+        self cls True False None print len range int str float bool list dict set tuple bytes object type open
+        input sum min max abs round sorted reversed enumerate zip map filter any all isinstance issubclass hasattr
+        getattr setattr super iter next repr format id hash divmod Exception ValueError TypeError KeyError
+        IndexError RuntimeError StopIteration NotImplementedError __init__ __name__ __main__ _
+        """.split()
+    ),
+    "java": set(
+        """
+        this super String Object Integer Long Double Float Boolean Character System Math List ArrayList LinkedList
+        Map HashMap TreeMap Set HashSet Arrays Collections Optional Stream Collectors StringBuilder Scanner Thread
+        Exception RuntimeException IllegalArgumentException Override main args length out err println print printf
+        size get put add equals hashCode toString
+        """.split()
+    ),
+    "javascript": set(
+        """
+        this undefined NaN Infinity console Math JSON Object Array String Number Boolean Date Map Set Promise Error
+        Symbol RegExp document window process require module exports parseInt parseFloat isNaN setTimeout
+        setInterval clearTimeout fetch length push pop map filter reduce forEach then catch
+        """.split()
+    ),
+    "c": set(
+        """
+        main printf scanf puts putchar getchar fprintf sprintf snprintf fopen fclose fgets malloc calloc realloc
+        free strlen strcpy strncpy strcmp strcat memcpy memset exit NULL size_t FILE stdin stdout stderr EOF bool
+        true false uint8_t int32_t int64_t uint32_t uint64_t
+        """.split()
+    ),
+    "cpp": set(
+        """
+        main this std cout cin cerr endl string vector map set unordered_map pair make_pair unique_ptr shared_ptr
+        make_unique make_shared size_t nullptr printf size push_back begin end sort move swap min max true false
+        """.split()
+    ),
+    "rust": set(
+        """
+        self Self main std println print format vec panic assert assert_eq String Vec Option Some None Result Ok
+        Err Box Rc Arc HashMap HashSet str i32 i64 u8 u32 u64 usize f64 bool len push iter collect unwrap clone
+        into to_string new
+        """.split()
+    ),
+    "csharp": set(
+        """
+        this base Main args System Console WriteLine Write ReadLine Math String Int32 List Dictionary HashSet Task
+        Exception Linq Count Length Add var ToString Equals
+        """.split()
+    ),
+    "kotlin": set(
+        """
+        this super it main args println print listOf mutableListOf mapOf mutableMapOf setOf arrayOf String Int Long
+        Double Boolean Unit Any List Map Set size forEach map filter let apply also run lazy
+        """.split()
+    ),
+    "php": set(
+        """
+        this echo print count strlen str_replace explode implode array_map array_filter array_keys array_values
+        in_array isset empty unset json_encode json_decode sprintf printf PHP_EOL Exception
+        """.split()
+    ),
+    "ruby": set(
+        """
+        self nil true false puts print p require require_relative attr_accessor attr_reader attr_writer initialize
+        new each map select reject length size to_s to_i raise Integer String Array Hash StandardError
+        """.split()
+    ),
+    "swift": set(
+        """
+        self Self super print Int Double Float String Bool Array Dictionary Set Optional Character count append map
+        filter reduce isEmpty Error
+        """.split()
+    ),
+}
+LANGUAGE_BUILTINS["typescript"] = LANGUAGE_BUILTINS["javascript"] | set(
+    "string number boolean any unknown never void Record Partial Readonly".split()
+)
+
+# Standard library packages and modules: the members accessed through them (`fmt.Println`) keep their names
+LANGUAGE_MODULES: Dict[str, Set[str]] = {
+    "go": set(
+        """
+        fmt os io bufio bytes strings strconv sort sync atomic time context errors math rand json http log regexp
+        filepath path unicode reflect testing
+        """.split()
+    ),
+    "python": set(
+        """
+        os sys math re json time datetime random collections itertools functools typing pathlib logging string
+        subprocess asyncio dataclasses unittest path
+        """.split()
+    ),
+    "java": {"System", "Math", "Arrays", "Collections", "String", "Integer", "Collectors", "out", "err"},
+    "javascript": {"console", "Math", "JSON", "Object", "Array", "Promise", "Number", "document", "window", "process"},
+    "c": set(),
+    "cpp": {"std"},
+    "rust": {"std", "String", "Vec", "HashMap", "io", "fmt", "collections"},
+    "csharp": {"System", "Console", "Math", "String", "Linq"},
+    "kotlin": {"Math", "System"},
+    "php": set(),
+    "ruby": {"Math", "File", "Time"},
+    "swift": {"Foundation"},
+}
+LANGUAGE_MODULES["typescript"] = LANGUAGE_MODULES["javascript"]
+
+# Number of tokens looked back for the `module.member` expression enclosing a member name
+MEMBER_LOOKBACK = 4
+# Literals and comments inside the text of enclosing tokens are left untouched
+PROTECTED_TEXT_PATTERN = r'"(?:\\.|[^"\\\n])*"|\'(?:\\.|[^\'\\\n])*\'|`[^`]*`|//[^\n]*|/\*.*?\*/'
+HASH_COMMENT_LANGUAGES = {"python", "ruby", "php", "bash"}
+
+
+class IdentifierNormalizer:
+    """
+    Replace the identifiers of a token stream with positional placeholders: the first distinct identifier of
+    the file becomes ID1, the second ID2, and so on, so that a global rename of variables, functions and types
+    does not change the stream. Keywords, builtins and standard library names of the language (`len`,
+    `fmt.Println`) keep their names, so that the structure of the code against the standard library is still
+    compared. Literals and operators are left untouched.
+    """
+
+    def __init__(self, language: Optional[str] = None):
+        # Without a language, the names of every language are kept
+        if language in LANGUAGE_BUILTINS:
+            self.builtins = LANGUAGE_BUILTINS[language]
+            self.modules = LANGUAGE_MODULES.get(language, set())
+        else:
+            self.builtins = set().union(*LANGUAGE_BUILTINS.values())
+            self.modules = set().union(*LANGUAGE_MODULES.values())
+        protected = PROTECTED_TEXT_PATTERN
+        if language in HASH_COMMENT_LANGUAGES:
+            protected += r"|#[^\n]*"
+        self.protected_pattern = protected
+
+    def normalize(self, tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Get a copy of the tokens with the identifiers renamed, in the identifiers and in enclosing tokens"""
+        kept_indexes = self._kept_member_indexes(tokens)
+        placeholders = self._placeholders(tokens, kept_indexes)
+        if not placeholders:
+            return [dict(token) for token in tokens]
+
+        names = sorted(placeholders, key=len, reverse=True)
+        # Runs of spaces are collapsed too, as aligned code (gofmt) is realigned when names change length
+        pattern = re.compile(
+            rf"({self.protected_pattern})|(?<![\w$])({'|'.join(re.escape(name) for name in names)})(?![\w$])"
+            r"|([ \t]+)",
+            re.DOTALL,
+        )
+
+        def replace(match: re.Match) -> str:
+            if match.group(1) is not None:
+                return match.group(1)
+            if match.group(2) is not None:
+                return placeholders[match.group(2)]
+            return " "
+
+        normalized_tokens = []
+        for index, token in enumerate(tokens):
+            normalized_token = dict(token)
+            text = token.get("text", "")
+            if token.get("type") in IDENTIFIER_TYPES:
+                if index not in kept_indexes and text in placeholders:
+                    normalized_token["text"] = placeholders[text]
+            elif text:
+                normalized_token["text"] = pattern.sub(replace, text)
+            normalized_tokens.append(normalized_token)
+        return normalized_tokens
+
+    def _placeholders(self, tokens: List[Dict[str, Any]], kept_indexes: Set[int]) -> Dict[str, str]:
+        """Get the placeholder of each renamed identifier, numbered in order of first appearance"""
+        placeholders: Dict[str, str] = {}
+        for index, token in enumerate(tokens):
+            text = token.get("text", "")
+            if token.get("type") not in IDENTIFIER_TYPES or index in kept_indexes or text in placeholders:
+                continue
+            if self.is_builtin(text):
+                continue
+            placeholders[text] = f"ID{len(placeholders) + 1}"
+        return placeholders
+
+    def is_builtin(self, name: str) -> bool:
+        """Check whether a name is a keyword, builtin or standard library module of the language"""
+        return name in self.builtins or name in self.modules
+
+    def _kept_member_indexes(self, tokens: List[Dict[str, Any]]) -> Set[int]:
+        """
+        Get the indexes of the identifiers accessed through a standard library module (`Println` of
+        `fmt.Println`, `out` and `println` of `System.out.println`)
+        """
+        kept: Set[int] = set()
+        previous_index = None
+        for index, token in enumerate(tokens):
+            if token.get("type") not in IDENTIFIER_TYPES:
+                continue
+            previous_text = tokens[previous_index].get("text", "") if previous_index is not None else None
+            if previous_index is not None and (previous_index in kept or previous_text in self.modules):
+                member = f"{previous_text}.{token.get('text', '')}"
+                lookback = tokens[max(0, index - MEMBER_LOOKBACK) : index]
+                if any(member in enclosing.get("text", "").replace(" ", "") for enclosing in lookback):
+                    kept.add(index)
+            previous_index = index
+        return kept
@@ -0,0 +1,180 @@
+import re
+from typing import Any, Dict, List
+
+# String and character literal kinds of the supported grammars
+STRING_LITERAL_TYPES = {
+    "string",
+    "f_string",
+    "template_literal",
+    "template_string",
+    "text_block",
+    "string_template",
+    "interpolated_string",
+    "interpolated_string_expression",
+    "heredoc",
+    "interpreted_string_literal",
+    "raw_string_literal",
+    "string_literal",
+    "raw_string",
+    "verbatim_string_literal",
+    "encapsed_string",
+    "line_string_literal",
+    "multi_line_string_literal",
+    "char_literal",
+    "character_literal",
+    "rune_literal",
+}
+# Numeric literal kinds of the supported grammars
+NUMERIC_LITERAL_TYPES = {
+    "integer",
+    "float",
+    "number",
+    "number_literal",
+    "int_literal",
+    "float_literal",
+    "imaginary_literal",
+    "integer_literal",
+    "real_literal",
+    "long_literal",
+    "hex_literal",
+    "bin_literal",
+    "oct_literal",
+    "decimal_integer_literal",
+    "hex_integer_literal",
+    "octal_integer_literal",
+    "binary_integer_literal",
+    "decimal_floating_point_literal",
+    "hex_floating_point_literal",
+}
+# Pieces of a string literal (quotes, content, escapes) emitted after the literal itself, dropped with it.
+# Interpolated expressions are not pieces, they are still compared.
+STRING_PART_TYPES = {
+    "string_start",
+    "string_content",
+    "string_end",
+    "string_fragment",
+    "escape_sequence",
+    "interpreted_string_literal_content",
+    "raw_string_literal_content",
+    "heredoc_content",
+    "line_str_text",
+    "multi_line_str_text",
+}
+STRING_PLACEHOLDER = "STR"
+NUMBER_PLACEHOLDER = "NUM"
+
+# Literals inside the text of enclosing tokens (calls, statements, definitions)
+STRING_PATTERN = re.compile(
+    r"""[rRbBuUfFL@$]{0,2}("{3}[\s\S]*?"{3}|'{3}[\s\S]*?'{3}|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|`[^`]*`)"""
+)
+NUMBER_PATTERN = re.compile(r"(?<![\w$.])(0[xX][0-9a-fA-F_]+|0[bB][01_]+|\d[\d_]*(\.\d+)?([eE][+-]?\d+)?)[a-zA-Z]*")
+QUOTES_PATTERN = re.compile(r"""^[A-Za-z@$]*("{3}|'{3}|"|'|`)|("{3}|'{3}|"|'|`)$""")
+# printf verbs (`%d`, `%-8.2f`, `%+v`) and format fields (`{}`, `{0}`, `{name:>10}`), `%%` and `{{` excluded
+FORMAT_VERB_PATTERN = re.compile(r"%%|%[-+# 0]*(\d+|\*)?(\.(\d+|\*))?[a-zA-Z]|\{\{|\{[^{}]*\}")
+# Upper bounds (exclusive) of the literal length buckets, the last bucket is unbounded
+LENGTH_BUCKET_BOUNDS = (1, 4, 16, 64)
+
+
+class LiteralNormalizer:
+    """
+    Replace string literals with a single STR placeholder and numeric literals with NUM, so that changed
+    messages and magic numbers do not change the token stream. Optionally:
+
+    - length_buckets: the placeholder records the length bucket of the literal (`STR#4-15`, `NUM#1-3`)
+    - preserve_format_verbs: string placeholders keep the verbs of printf-style format strings and the fields
+      of format strings (`STR(%d %s)`, `STR({} {:>10})`), so that Printf-heavy code still differentiates
+
+    The original text of a replaced literal is kept in `original_text`, for reports showing matched fragments.
+    """
+
+    def __init__(self, length_buckets: bool = False, preserve_format_verbs: bool = False):
+        self.length_buckets = length_buckets
+        self.preserve_format_verbs = preserve_format_verbs
+
+    def normalize(self, tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Get a copy of the tokens with the literals replaced, in the literals and in enclosing tokens"""
+        normalized_tokens = []
+        literal_end = None
+
+        for token in tokens:
+            token_type = token.get("type", "")
+            text = token.get("text", "")
+
+            # Pieces of the last replaced string are dropped
+            if literal_end is not None:
+                if token_type in STRING_PART_TYPES and token.get("start", 0) <= literal_end:
+                    continue
+                literal_end = None
+
+            if token_type in STRING_LITERAL_TYPES:
+                normalized_tokens.append(
+                    {**token, "type": STRING_PLACEHOLDER, "text": self.string_placeholder(text), "original_text": text}
+                )
+                literal_end = token.get("end", 0)
+                continue
+
+            if token_type in NUMERIC_LITERAL_TYPES:
+                normalized_tokens.append(
+                    {**token, "type": NUMBER_PLACEHOLDER, "text": self.number_placeholder(text), "original_text": text}
+                )
+                continue
+
+            normalized_token = dict(token)
+            if text:
+                normalized_token["text"] = self._replace_literals(text)
+            normalized_tokens.append(normalized_token)
+
+        return normalized_tokens
+
+    def string_placeholder(self, text: str) -> str:
+        """Get the placeholder of a string literal"""
+        placeholder = STRING_PLACEHOLDER
+        content = QUOTES_PATTERN.sub("", text)
+        if self.length_buckets:
+            placeholder += f"#{self._length_bucket(len(content))}"
+        if self.preserve_format_verbs:
+            verbs = [self._format_verb(match.group(0)) for match in FORMAT_VERB_PATTERN.finditer(content)]
+            verbs = [verb for verb in verbs if verb]
+            if verbs:
+                placeholder += f"({' '.join(verbs)})"
+        return placeholder
+
+    def number_placeholder(self, text: str) -> str:
+        """Get the placeholder of a numeric literal"""
+        if self.length_buckets:
+            return f"{NUMBER_PLACEHOLDER}#{self._length_bucket(len(text))}"
+        return NUMBER_PLACEHOLDER
+
+    def _replace_literals(self, text: str) -> str:
+        """Replace the literals written in the text of an enclosing token"""
+        parts = []
+        position = 0
+        for match in STRING_PATTERN.finditer(text):
+            parts.append(self._replace_numbers(text[position : match.start()]))
+            parts.append(self.string_placeholder(match.group(0)))
+            position = match.end()
+        parts.append(self._replace_numbers(text[position:]))
+        return "".join(parts)
+
+    def _replace_numbers(self, text: str) -> str:
+        return NUMBER_PATTERN.sub(lambda match: self.number_placeholder(match.group(0)), text)
+
+    @staticmethod
+    def _format_verb(verb: str) -> str:
+        """Normalize a format verb, the names of format fields being identifiers (`{name:>10}` -> `{:>10}`)"""
+        if verb in ("%%", "{{"):
+            return ""
+        if verb.startswith("{"):
+            _, colon, spec = verb[1:-1].partition(":")
+            return "{" + (colon + spec) + "}"
+        return verb
+
+    @staticmethod
+    def _length_bucket(length: int) -> str:
+        """Get the label of the length bucket of a literal (0, 1-3, 4-15, 16-63, 64+)"""
+        lower = 0
+        for bound in LENGTH_BUCKET_BOUNDS:
+            if length < bound:
+                return str(lower) if bound - lower == 1 else f"{lower}-{bound - 1}"
+            lower = bound
+        return f"{lower}+"
@@ -0,0 +1,136 @@
+import math
+import re
+from collections import Counter, defaultdict
+from itertools import combinations
+from typing import Any, Dict, Hashable, List, Mapping, Optional, Set, Tuple
+
+from app.domains.detection.identifier_normalizer import IDENTIFIER_TYPES, IdentifierNormalizer
+
+# Kinds of the rare tokens: a name chosen by the author of the code, or the content of a string literal
+IDENTIFIER_KIND = "identifier"
+STRING_KIND = "string"
+
+# Token kinds of the string literals, their content compared without the quotes
+STRING_TYPES = {
+    "string",
+    "string_literal",
+    "interpreted_string_literal",
+    "raw_string_literal",
+    "template_string",
+    "encapsed_string",
+}
+# Prefix (f, r, b, @...) and quotes around the content of a string literal
+STRING_QUOTES_PATTERN = re.compile(r'^[A-Za-z@$]*("""|\'\'\'|"|\'|`)(.*)\1$', re.DOTALL)
+
+# Conventional names of every language, as common as the builtins: loop counters, errors, results...
+COMMON_NAMES = set(
+    """
+    i j k n m x y z e ex err error ok res result results ret value values val key keys item items data tmp temp
+    count total sum idx index size str num arr list obj args argv argc main test ctx self this
+    """.split()
+)
+
+DEFAULT_MAX_DOCUMENT_FREQUENCY = 0.1
+DEFAULT_MIN_TOKEN_LENGTH = 3
+
+RareToken = Tuple[str, str]
+
+
+class RareTokenAnalyzer:
+    """
+    Find the identifiers and string literals shared by few submissions of a corpus (a detection run): the same
+    bizarre variable name or the same typo'd error message in two submissions is evidence structural similarity
+    misses.
+
+    A token is rare when it appears in at most `max_document_frequency` of the submissions, a token of only two
+    submissions always being rare however small the corpus. The builtins, keywords and standard library names of
+    the language of each submission, the conventional names (`i`, `err`, `result`...) and the tokens shorter than
+    `min_length` are ignored. Each pair sharing rare tokens gets an evidence score, the share of the rarity (inverse
+    document frequency, smoothed) of the rare tokens of both submissions carried by the shared ones.
+    """
+
+    def __init__(
+        self,
+        max_document_frequency: float = DEFAULT_MAX_DOCUMENT_FREQUENCY,
+        min_length: int = DEFAULT_MIN_TOKEN_LENGTH,
+    ):
+        self.max_document_frequency = max_document_frequency
+        self.min_length = min_length
+
+    def extract(self, tokens: List[Dict[str, Any]], language: Optional[str] = None) -> Set[RareToken]:
+        """Get the candidate tokens of a submission, as (kind, text): its own names and its string contents"""
+        normalizer = IdentifierNormalizer(language)
+        candidates = set()
+        for token in tokens:
+            token_type = token.get("type")
+            text = token.get("text") or ""
+            if token_type in IDENTIFIER_TYPES:
+                if len(text) >= self.min_length and not self._is_common(text, normalizer):
+                    candidates.add((IDENTIFIER_KIND, text))
+            elif token_type in STRING_TYPES:
+                content = self.string_content(text)
+                if len(content.strip()) >= self.min_length:
+                    candidates.add((STRING_KIND, content))
+        return candidates
+
+    def max_submissions(self, submission_count: int) -> int:
+        """Number of submissions a rare token appears in at most"""
+        return max(2, math.floor(self.max_document_frequency * submission_count))
+
+    def shared_pairs(self, documents: Mapping[Hashable, Set[RareToken]]) -> List[Dict[str, Any]]:
+        """
+        Get the pairs of submissions sharing rare tokens, by decreasing evidence score, with their shared tokens
+        from the rarest
+        """
+        frequencies = Counter(token for candidates in documents.values() for token in candidates)
+        limit = self.max_submissions(len(documents))
+        # Smoothed inverse document frequency, a token of all the submissions of a small run still weighing
+        rarity = {
+            token: math.log(1 + len(documents) / frequency)
+            for token, frequency in frequencies.items()
+            if frequency <= limit
+        }
+
+        holders = defaultdict(list)
+        for document_id, candidates in documents.items():
+            for token in candidates:
+                if token in rarity and frequencies[token] > 1:
+                    holders[token].append(document_id)
+        shared = defaultdict(list)
+        for token, document_ids in holders.items():
+            for pair in combinations(document_ids, 2):
+                shared[pair].append(token)
+
+        pairs = []
+        for (first, second), tokens in shared.items():
+            pair_rarity = sum(rarity[token] for token in documents[first] | documents[second] if token in rarity)
+            shared_rarity = sum(rarity[token] for token in tokens)
+            pairs.append(
+                {
+                    "submission_id": first,
+                    "compared_submission_id": second,
+                    "evidence_score": round(shared_rarity / pair_rarity, 4) if pair_rarity else 0.0,
+                    "shared_tokens": [
+                        {"kind": kind, "text": text, "document_frequency": frequencies[(kind, text)]}
+                        for kind, text in sorted(tokens, key=lambda token: (frequencies[token], token))
+                    ],
+                }
+            )
+        pairs.sort(key=lambda pair: (-pair["evidence_score"], -len(pair["shared_tokens"])))
+        return pairs
+
+    @staticmethod
+    def blend(similarity: float, evidence_score: float, weight: float) -> float:
+        """Blend the evidence score of a pair into its similarity, with the given weight of the evidence"""
+        return round((1 - weight) * similarity + weight * evidence_score, 4)
+
+    @staticmethod
+    def _is_common(name: str, normalizer: IdentifierNormalizer) -> bool:
+        """Check whether a name is a builtin of the language or a conventional name"""
+        return normalizer.is_builtin(name) or name.lower() in COMMON_NAMES
+
+    @staticmethod
+    def string_content(text: str) -> str:
+        """Content of a string literal, without its prefix and quotes"""
+        match = STRING_QUOTES_PATTERN.match(text)
+        return match.group(2) if match else text
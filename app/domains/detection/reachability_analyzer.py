@@ -0,0 +1,215 @@
+import logging
+import re
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional, Set
+
+from app.domains.detection.identifier_normalizer import IDENTIFIER_TYPES
+
+logger = logging.getLogger(__name__)
+
+# Function definition kinds and the pattern of their name, per language
+FUNCTION_TYPES = {
+    "go": {"function_declaration", "method_declaration"},
+    "python": {"function_definition", "async_function_definition"},
+}
+FUNCTION_NAME_PATTERNS = {
+    "go": re.compile(r"^func\s*(?:\([^)]*\)\s*)?(\w+)"),
+    "python": re.compile(r"^(?:async\s+)?def\s+(\w+)"),
+}
+# Root token of a file, used to infer the language of a token stream
+ROOT_TYPES = {"source_file": "go", "module": "python"}
+
+GO_ENTRY_POINTS = {"main", "init"}
+GO_TEST_PREFIXES = ("Test", "Benchmark", "Example", "Fuzz")
+PYTHON_ENTRY_POINTS = {"main"}
+PYTHON_TEST_PREFIX = "test_"
+PYTHON_ALL_PATTERN = re.compile(r"^__all__\s*[+]?=\s*[\[(](.*)[\])]$", re.DOTALL)
+PACKAGE_PATTERN = re.compile(r"^package\s+(\w+)")
+
+
+@dataclass
+class FunctionInfo:
+    """A function definition of a token stream, with the names it references"""
+
+    name: str
+    start: int
+    end: int
+    first_index: int
+    last_index: int
+    references: Set[str] = field(default_factory=set)
+    entry_point: bool = False
+
+    @property
+    def token_count(self) -> int:
+        return self.last_index - self.first_index + 1
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Entry of the padding report"""
+        return {"name": self.name, "start": self.start, "end": self.end, "tokens": self.token_count}
+
+
+@dataclass
+class ReachabilityResult:
+    """Functions of a token stream, and those never referenced from an entry point"""
+
+    language: Optional[str]
+    functions: List[FunctionInfo] = field(default_factory=list)
+    unreachable: List[FunctionInfo] = field(default_factory=list)
+
+    @property
+    def excluded_token_count(self) -> int:
+        return sum(function.token_count for function in self.unreachable)
+
+    def to_dict(self) -> List[Dict[str, Any]]:
+        """Report of the unreachable functions"""
+        return [function.to_dict() for function in self.unreachable]
+
+
+class ReachabilityAnalyzer:
+    """
+    Find the functions of Go and Python code that are never called, to spot files padded with unused code copied
+    from elsewhere. A name based call graph is built from the tokens: each function references the identifiers
+    used in its body, and the functions reachable from the entry points are marked. Reflection and dynamic
+    dispatch are ignored. Entry points are:
+
+    - Go: main, init, tests and benchmarks, exported methods (they may implement an interface), and exported
+      functions of packages other than main
+    - Python: functions referenced from module level code (`if __name__ == "__main__": main()`), main, decorated
+      functions, dunder methods, tests, and the names listed in `__all__`
+    """
+
+    def __init__(self, language: Optional[str] = None):
+        self.language = language
+
+    def analyze(self, tokens: List[Dict[str, Any]]) -> ReachabilityResult:
+        """Find the unreachable functions of a token stream (of a file, or of the files of a package)"""
+        language = self.language or self._infer_language(tokens)
+        result = ReachabilityResult(language=language)
+        if language not in FUNCTION_TYPES:
+            return result
+
+        result.functions = self._find_functions(tokens, language)
+        covered = set()
+        for function in result.functions:
+            covered.update(range(function.first_index, function.last_index + 1))
+        module_references = {
+            token.get("text", "")
+            for index, token in enumerate(tokens)
+            if index not in covered and token.get("type") in IDENTIFIER_TYPES
+        }
+        exported = self._exported_names(tokens, language)
+
+        by_name: Dict[str, List[FunctionInfo]] = {}
+        for function in result.functions:
+            by_name.setdefault(function.name, []).append(function)
+            if self._is_entry_point(function, language, tokens, module_references, exported):
+                function.entry_point = True
+
+        reached = set()
+        pending = [function for function in result.functions if function.entry_point]
+        while pending:
+            function = pending.pop()
+            if id(function) in reached:
+                continue
+            reached.add(id(function))
+            for name in function.references:
+                pending.extend(callee for callee in by_name.get(name, []) if id(callee) not in reached)
+
+        result.unreachable = [function for function in result.functions if id(function) not in reached]
+        if result.unreachable:
+            logger.debug(f"Unreachable functions: {[function.name for function in result.unreachable]}")
+        return result
+
+    @staticmethod
+    def exclude(tokens: List[Dict[str, Any]], result: ReachabilityResult) -> List[Dict[str, Any]]:
+        """Remove the tokens of the unreachable functions from a token stream"""
+        excluded = set()
+        for function in result.unreachable:
+            excluded.update(range(function.first_index, function.last_index + 1))
+        return [token for index, token in enumerate(tokens) if index not in excluded]
+
+    def _find_functions(self, tokens: List[Dict[str, Any]], language: str) -> List[FunctionInfo]:
+        """Find the function definitions, their body being the following tokens on their lines"""
+        functions = []
+        decorated_until = None
+        for index, token in enumerate(tokens):
+            token_type = token.get("type", "")
+            if token_type == "decorated_definition":
+                decorated_until = token.get("end", 0)
+                continue
+            if token_type not in FUNCTION_TYPES[language]:
+                continue
+
+            match = FUNCTION_NAME_PATTERNS[language].match(token.get("text", ""))
+            if not match:
+                continue
+            start, end = token.get("start", 0), token.get("end", 0)
+            last_index = index
+            while (
+                last_index + 1 < len(tokens)
+                and tokens[last_index + 1].get("start", 0) >= start
+                and tokens[last_index + 1].get("end", 0) <= end
+            ):
+                last_index += 1
+
+            function = FunctionInfo(name=match.group(1), start=start, end=end, first_index=index, last_index=last_index)
+            # The name of the function itself is not a reference
+            name_seen = False
+            for body_token in tokens[index + 1 : last_index + 1]:
+                if body_token.get("type") not in IDENTIFIER_TYPES:
+                    continue
+                text = body_token.get("text", "")
+                if text == function.name and not name_seen:
+                    name_seen = True
+                    continue
+                function.references.add(text)
+            if decorated_until is not None and end <= decorated_until:
+                function.entry_point = True
+                decorated_until = None
+            functions.append(function)
+        return functions
+
+    @staticmethod
+    def _is_entry_point(
+        function: FunctionInfo,
+        language: str,
+        tokens: List[Dict[str, Any]],
+        module_references: Set[str],
+        exported: Set[str],
+    ) -> bool:
+        name = function.name
+        if function.entry_point:
+            return True
+        if language == "go":
+            if name in GO_ENTRY_POINTS or name.startswith(GO_TEST_PREFIXES):
+                return True
+            is_exported = name[:1].isupper()
+            is_method = tokens[function.first_index].get("type") == "method_declaration"
+            return is_exported and (is_method or "main" not in exported)
+        return (
+            name in PYTHON_ENTRY_POINTS
+            or name in module_references
+            or name in exported
+            or name.startswith(PYTHON_TEST_PREFIX)
+            or (name.startswith("__") and name.endswith("__"))
+        )
+
+    @staticmethod
+    def _exported_names(tokens: List[Dict[str, Any]], language: str) -> Set[str]:
+        """Get the Go package names of the stream, or the names listed in the Python `__all__`"""
+        names = set()
+        for token in tokens:
+            text = token.get("text", "")
+            if language == "go" and token.get("type") == "package_clause":
+                match = PACKAGE_PATTERN.match(text)
+                if match:
+                    names.add(match.group(1))
+            elif language == "python" and token.get("type") in ("assignment", "augmented_assignment"):
+                match = PYTHON_ALL_PATTERN.match(text.strip())
+                if match:
+                    names.update(re.findall(r"['\"](\w+)['\"]", match.group(1)))
+        return names
+
+    @staticmethod
+    def _infer_language(tokens: List[Dict[str, Any]]) -> Optional[str]:
+        return ROOT_TYPES.get(tokens[0].get("type", "")) if tokens else None
@@ -2,11 +2,26 @@ from datetime import datetime
 from pathlib import Path
 from typing import Any, Dict
 
-from fastapi import APIRouter, Depends, HTTPException
-
+from fastapi import APIRouter, Depends, HTTPException, Query
+
+from app.domains.detection.dry_run_analyzer import DryRunAnalyzer
+from app.domains.detection.dto.detection_options_dto import (
+    DEFAULT_JACCARD_KGRAM_SIZE,
+    DetectionOptionsDto,
+    SimilarityMetric,
+)
+from app.domains.detection.dto.dry_run_analysis_dto import DryRunAnalysisDto, DryRunAnalysisRequestDto
+from app.domains.detection.function_similarity import DEFAULT_MIN_FUNCTION_TOKENS
+from app.domains.detection.greedy_string_tiling import DEFAULT_MIN_TILE_LENGTH
 from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.detection.tfidf_model import DEFAULT_TFIDF_NGRAM_SIZE
+from app.domains.detection.token_match_finder import DEFAULT_MIN_FRAGMENT_TOKENS, DEFAULT_MIN_MATCH_TOKENS
 from app.domains.detection.visualization import VisualizationService
+from app.domains.detection.winnowing import DEFAULT_KGRAM_SIZE, DEFAULT_WINDOW_SIZE
+from app.domains.tokenization.dto.custom_language_definition_dto import CustomLanguageDefinitionDto
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
 from app.domains.tokenization.tokenization_service import TokenizationService
+from app.shared.services import get_similarity_service
 from app.shared.services import get_tokenization_service as get_singleton_tokenization_service
 
 router = APIRouter(prefix="/detection", tags=["detection"])
@@ -248,9 +263,78 @@ async def test_project_similarity_simple(tokenization_service: TokenizationServi
         raise HTTPException(status_code=500, detail=f"Analysis failed: {str(e)}")
 
 
+@router.get("/similarity-test/run", response_model=Dict[str, Any])
+async def compare_test_project_files(
+    metric: SimilarityMetric = Query(SimilarityMetric.TFIDF_COSINE, description="Metric producing the scores"),
+    tfidf_ngram_size: int = Query(DEFAULT_TFIDF_NGRAM_SIZE, ge=1, description="Tokens of the TF-IDF n-grams"),
+    jaccard_kgram_size: int = Query(DEFAULT_JACCARD_KGRAM_SIZE, ge=1, description="Tokens of the Jaccard k-grams"),
+    min_tile_length: int = Query(DEFAULT_MIN_TILE_LENGTH, ge=2, description="Minimum tokens of a tiling tile"),
+    tokenization_service: TokenizationService = Depends(get_tokenization_service),
+):
+    """
+    Compare every pair of files of the test projects as one detection run, each file being tokenized once.
+
+    Args:
+        metric: Metric producing the scores, `tfidf_cosine` weighting the n-grams by their IDF across all the files
+        tfidf_ngram_size: Number of tokens of the n-grams weighted by the `tfidf_cosine` metric
+        jaccard_kgram_size: Number of tokens of the k-grams compared by the `kgram_jaccard` metric
+        min_tile_length: Minimum number of tokens of the tiles of the `greedy_string_tiling` metric
+    """
+    try:
+        similarity_service = SimilarityDetectionService()
+
+        documents = {}
+        for project in ("project_calculator", "project_game"):
+            for file_path in sorted(Path("resources/test", project).glob("*.py")):
+                content = file_path.read_text(encoding="utf-8")
+                documents[f"{project}/{file_path.name}"] = tokenization_service.tokenize(content, file_path)
+
+        if len(documents) < 2:
+            raise HTTPException(status_code=404, detail="Not enough test project files to compare")
+
+        detection_options = DetectionOptionsDto(
+            metric=metric,
+            tfidf_ngram_size=tfidf_ngram_size,
+            jaccard_kgram_size=jaccard_kgram_size,
+            min_tile_length=min_tile_length,
+        )
+        return {
+            "timestamp": datetime.utcnow().isoformat(),
+            **similarity_service.compare_run(documents, detection_options, "python"),
+        }
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Run comparison failed: {str(e)}")
+
+
 @router.get("/similarity-test/files/{file1}/{file2}")
 async def compare_specific_files(
-    file1: str, file2: str, tokenization_service: TokenizationService = Depends(get_tokenization_service)
+    file1: str,
+    file2: str,
+    max_unknown_token_percentage: float = Query(
+        20.0, ge=0.0, le=100.0, description="Unknown tokens percentage above which the language is detected again"
+    ),
+    ignore_comments: bool = Query(False, description="Remove the comments before comparing the files"),
+    ignore_imports: bool = Query(False, description="Remove the import and include statements before comparing"),
+    docstrings_as_comments: bool = Query(False, description="Remove the Python docstrings too (with ignore_comments)"),
+    normalize_identifiers: bool = Query(False, description="Replace identifiers with positional placeholders"),
+    normalize_literals: bool = Query(False, description="Replace string literals with STR and numbers with NUM"),
+    literal_length_buckets: bool = Query(False, description="Keep the length bucket of the normalized literals"),
+    preserve_format_verbs: bool = Query(False, description="Keep the verbs of the normalized format strings"),
+    exclude_unreachable_functions: bool = Query(False, description="Exclude the Go and Python functions never called"),
+    fingerprint_kgram_size: int = Query(DEFAULT_KGRAM_SIZE, ge=1, description="Number of tokens of a fingerprint"),
+    fingerprint_window_size: int = Query(DEFAULT_WINDOW_SIZE, ge=1, description="Winnowing window, 1 for all k-grams"),
+    metric: SimilarityMetric = Query(SimilarityMetric.WEIGHTED, description="Metric producing the similarity score"),
+    jaccard_kgram_size: int = Query(DEFAULT_JACCARD_KGRAM_SIZE, ge=1, description="Tokens of the Jaccard k-grams"),
+    tfidf_ngram_size: int = Query(DEFAULT_TFIDF_NGRAM_SIZE, ge=1, description="Tokens of the TF-IDF n-grams"),
+    min_match_tokens: int = Query(DEFAULT_MIN_MATCH_TOKENS, ge=2, description="Minimum tokens of a reported match"),
+    min_fragment_tokens: int = Query(DEFAULT_MIN_FRAGMENT_TOKENS, ge=1, description="Minimum tokens of a fragment"),
+    min_function_tokens: int = Query(DEFAULT_MIN_FUNCTION_TOKENS, ge=1, description="Minimum tokens of a function"),
+    cross_language: bool = Query(False, description="Compare files of different languages by abstract categories"),
+    min_tile_length: int = Query(DEFAULT_MIN_TILE_LENGTH, ge=2, description="Minimum tokens of a tiling tile"),
+    tokenization_service: TokenizationService = Depends(get_tokenization_service),
 ):
     """
     Compare two specific files from the test projects.
@@ -258,6 +342,36 @@ async def compare_specific_files(
     Args:
         file1: Filename from calculator project (e.g., "main.py")
         file2: Filename from game project (e.g., "game_engine.py")
+        max_unknown_token_percentage: Above this percentage of unknown tokens, the language of a file is detected
+            from its content instead of its extension
+        ignore_comments: Compare the files without their comments
+        ignore_imports: Compare the files without their import, include, using and require statements
+        docstrings_as_comments: Treat Python module, class and function docstrings as comments
+        normalize_identifiers: Compare the files with their identifiers renamed ID1, ID2... to detect renames
+        normalize_literals: Compare the files with their string and numeric literals abstracted
+        literal_length_buckets: Distinguish the normalized literals by length bucket (with normalize_literals)
+        preserve_format_verbs: Distinguish the format strings by their verbs, `%d` or `{}` (with normalize_literals)
+        exclude_unreachable_functions: Compare the Go and Python files without the functions never called from an
+            entry point, listed as padding
+        fingerprint_kgram_size: Number of consecutive tokens hashed into a fingerprint
+        fingerprint_window_size: Number of consecutive k-gram hashes the winnowing keeps the minimum of
+        metric: `weighted` combination of the similarities, the cheap `kgram_jaccard` coefficient of the token
+            k-gram sets used to pre-filter pairs, the `tfidf_cosine` of the token n-grams, the `structural`
+            comparison of the Go syntax trees (token mode when a file does not parse), or the `greedy_string_tiling`
+            coverage of the tokens by the longest common runs
+        jaccard_kgram_size: Number of tokens of the k-grams compared by the `kgram_jaccard` metric
+        tfidf_ngram_size: Number of tokens of the n-grams weighted by the `tfidf_cosine` metric (with the two
+            files as the run)
+        min_match_tokens: Minimum number of consecutive matching tokens of the matches reported with their lines
+            and columns in both files
+        min_fragment_tokens: Minimum number of matching tokens of the fragments highlighted in both files, with
+            their similarity
+        min_function_tokens: Minimum number of tokens of the functions matched across both files, with their best
+            match in `function_similarities`
+        cross_language: Compare files of two different languages through abstract token categories (loops,
+            conditions, calls, assignments...), the result being labeled as a lower confidence one
+        min_tile_length: Minimum number of tokens of the tiles of the `greedy_string_tiling` metric, the tiles
+            being returned with their positions in both files
     """
     try:
         # Initialize services
@@ -279,11 +393,44 @@ async def compare_specific_files(
         with open(game_file_path, "r", encoding="utf-8") as f:
             game_content = f.read()
 
-        calc_tokens = tokenization_service.tokenize(calc_content, calc_file_path)
-        game_tokens = tokenization_service.tokenize(game_content, game_file_path)
+        options = TokenizationOptionsDto(max_unknown_token_percentage=max_unknown_token_percentage)
+        calc_result = tokenization_service.tokenize_with_details(calc_content, calc_file_path, options)
+        game_result = tokenization_service.tokenize_with_details(game_content, game_file_path, options)
+        calc_tokens = calc_result.tokens
+        game_tokens = game_result.tokens
 
         # Analyze similarity
-        similarity = similarity_service.compare_similarity(calc_tokens, game_tokens)
+        detection_options = DetectionOptionsDto(
+            ignore_comments=ignore_comments,
+            ignore_imports=ignore_imports,
+            docstrings_as_comments=docstrings_as_comments,
+            normalize_identifiers=normalize_identifiers,
+            normalize_literals=normalize_literals,
+            literal_length_buckets=literal_length_buckets,
+            preserve_format_verbs=preserve_format_verbs,
+            exclude_unreachable_functions=exclude_unreachable_functions,
+            fingerprint_kgram_size=fingerprint_kgram_size,
+            fingerprint_window_size=fingerprint_window_size,
+            metric=metric,
+            jaccard_kgram_size=jaccard_kgram_size,
+            tfidf_ngram_size=tfidf_ngram_size,
+            min_match_tokens=min_match_tokens,
+            min_fragment_tokens=min_fragment_tokens,
+            min_function_tokens=min_function_tokens,
+            cross_language=cross_language,
+            min_tile_length=min_tile_length,
+        )
+        language = calc_result.language if calc_result.language == game_result.language else None
+        if cross_language and language is None:
+            similarity = similarity_service.compare_cross_language(
+                calc_tokens, game_tokens, calc_result.language, game_result.language, detection_options
+            )
+        elif metric == SimilarityMetric.STRUCTURAL:
+            similarity = similarity_service.compare_go_structure(
+                calc_content, game_content, tokenization_service, detection_options, calc_file_path, game_file_path
+            )
+        else:
+            similarity = similarity_service.compare_similarity(calc_tokens, game_tokens, detection_options, language)
         shared_blocks = similarity_service.detect_shared_code_blocks(
             source1=calc_content,
             source2=game_content,
@@ -298,11 +445,32 @@ async def compare_specific_files(
             "timestamp": datetime.utcnow().isoformat(),
             "files": {"calculator": file1, "game": file2},
             "tokens": {"calculator": len(calc_tokens), "game": len(game_tokens)},
+            "languages": {
+                "calculator": calc_result.model_dump(exclude={"tokens"}),
+                "game": game_result.model_dump(exclude={"tokens"}),
+            },
             "similarity": {
-                "jaccard": similarity["jaccard_similarity"],
-                "type": similarity["type_similarity"],
-                "common_elements": similarity["common_elements"],
-                "total_unique_elements": similarity["total_unique_elements"],
+                "metric": similarity["metric"],
+                "overall": similarity["overall_similarity"],
+                "jaccard": similarity.get("jaccard_similarity"),
+                "type": similarity.get("type_similarity"),
+                "common_elements": similarity.get("common_elements"),
+                "total_unique_elements": similarity.get("total_unique_elements"),
+                "too_short": similarity.get("too_short"),
+                "excluded": f"{similarity.get('excluded_import_tokens', 0)} tokens excluded as imports",
+                "padding": similarity.get("unreachable_functions"),
+                "function_matches": similarity.get("function_matches"),
+                "warnings": similarity.get("warnings", []),
+                "fingerprints": {
+                    "similarity": similarity.get("fingerprint_similarity"),
+                    "calculator": len(similarity.get("fingerprints", {}).get("file1", [])),
+                    "game": len(similarity.get("fingerprints", {}).get("file2", [])),
+                },
+                "matches": similarity.get("matches", []),
+                "fragments": similarity.get("fragments", []),
+                "cross_language": similarity.get("cross_language"),
+                "coverage": similarity.get("coverage"),
+                "tiles": similarity.get("tiles"),
             },
             "shared_code": {
                 "blocks_detected": shared_blocks["total_shared_blocks"],
@@ -621,3 +789,50 @@ async def get_animated_react_flow_ast_for_files(file1: str, file2: str, layout:
 
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Animated React Flow AST generation failed: {str(e)}")
+
+
+@router.get("/languages", response_model=Dict[str, Any])
+async def get_supported_languages(tokenization_service: TokenizationService = Depends(get_tokenization_service)):
+    """
+    List the languages supported by the detection, custom languages registered at runtime included.
+    """
+    return {
+        "languages": sorted(tokenization_service.get_supported_languages()),
+        "extensions": sorted(tokenization_service.get_supported_extensions()),
+        "custom_languages": [definition.model_dump() for definition in tokenization_service.get_custom_languages()],
+    }
+
+
+@router.post("/languages", response_model=CustomLanguageDefinitionDto, status_code=201)
+async def register_custom_language(
+    definition: CustomLanguageDefinitionDto,
+    tokenization_service: TokenizationService = Depends(get_tokenization_service),
+):
+    """
+    Register a custom language (e.g. a teaching language) analyzed with a generic tokenizer built from its
+    keywords, comment syntaxes, string delimiters and operators. Its files are then analyzed in detection
+    runs like those of built-in languages.
+
+    Returns 422 if the name or one of the extensions is already used by a supported language.
+    """
+    return tokenization_service.register_custom_language(definition)
+
+
+@router.post("/analyze", response_model=DryRunAnalysisDto)
+async def analyze_snippet(
+    request: DryRunAnalysisRequestDto,
+    tokenization_service: TokenizationService = Depends(get_tokenization_service),
+):
+    """
+    Dry-run analysis of a snippet or small file, nothing being stored: the detected language, the tokens
+    (kinds, texts and positions), the stream compared once the detection options are applied, and the winnowed
+    fingerprints a detection run would compute from it. Meant to tune the detection options, debug a tokenizer,
+    and preview how the service sees a piece of code.
+
+    The content is capped at `DRY_RUN_MAX_BYTES`, and tokenized within the configured timeout of the
+    tokenization of a file (`timed_out` then being true, without tokens).
+
+    Returns 422 if the content is too large (`content_too_large`) or the language is not supported
+    (`unsupported_language`).
+    """
+    return DryRunAnalyzer(tokenization_service, get_similarity_service()).analyze(request)
@@ -3,21 +3,101 @@ Similarity Detection Service
 Handles code similarity analysis, comparison, and shared code block detection.
 """
 
+import itertools
 import logging
 import re
+from bisect import bisect_left
 from difflib import SequenceMatcher
 from pathlib import Path
-from typing import Any, Dict, List
+from typing import Any, Collection, Dict, Iterable, Iterator, List, Optional, Set, Tuple
+
+from app.domains.detection.allowed_snippet_filter import AllowedSnippetFilter
+from app.domains.detection.baseline_filter import BaselineFilter
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto, SimilarityMetric
+from app.domains.detection.file_similarity_breakdown import FileAggregation, FileSimilarityBreakdown
+from app.domains.detection.function_similarity import DEFAULT_MIN_FUNCTION_TOKENS, FunctionSimilarity
+from app.domains.detection.go_structural_analyzer import GoStructuralAnalyzer
+from app.domains.detection.greedy_string_tiling import GreedyStringTiler
+from app.domains.detection.identifier_normalizer import IDENTIFIER_TYPES, IdentifierNormalizer
+from app.domains.detection.literal_normalizer import NUMBER_PLACEHOLDER, STRING_PLACEHOLDER, LiteralNormalizer
+from app.domains.detection.reachability_analyzer import ReachabilityAnalyzer
+from app.domains.detection.text_normalizer import NormalizedText, TextNormalizer
+from app.domains.detection.tfidf_model import DEFAULT_TFIDF_NGRAM_SIZE, TfidfModel
+from app.domains.detection.token_abstractor import TokenAbstractor
+from app.domains.detection.token_match_finder import (
+    DEFAULT_MIN_FRAGMENT_TOKENS,
+    DEFAULT_MIN_MATCH_TOKENS,
+    TokenMatchFinder,
+)
+from app.domains.detection.winnowing import DEFAULT_KGRAM_SIZE, DEFAULT_WINDOW_SIZE, Fingerprint, Winnower
+from app.shared.deadlines import check_deadline
 
 logger = logging.getLogger(__name__)
 
+# Scores reported before the starter code (baseline) is subtracted
+BASELINE_RAW_SCORES = ("jaccard_similarity", "type_similarity", "overall_similarity", "structural_similarity")
+
+# Number of tokens of a stream prepared together when it is fingerprinted as it comes
+FINGERPRINT_STREAM_BATCH_SIZE = 10_000
+
+# Comment kinds of the supported grammars (line and block comments, Rust doc comment parts)
+COMMENT_TYPES = {
+    "comment",
+    "line_comment",
+    "block_comment",
+    "multiline_comment",
+    "doc_comment",
+    "outer_doc_comment_marker",
+    "inner_doc_comment_marker",
+}
+# Tokens of a Python string literal, emitted after the string token itself
+STRING_PART_TYPES = {"string", "string_start", "string_content", "string_end", "escape_sequence"}
+# Python definitions whose first statement, when it is a string, is their docstring
+DOCSTRING_OWNER_TYPES = {"function_definition", "async_function_definition", "class_definition"}
+# Import, include, using and require statements of the supported grammars
+IMPORT_TYPES = {
+    "import_declaration",
+    "import_statement",
+    "import_from_statement",
+    "future_import_statement",
+    "preproc_include",
+    "using_directive",
+    "using_declaration",
+    "use_declaration",
+    "import_header",
+    "import_list",
+    "namespace_use_declaration",
+    "require_expression",
+    "require_once_expression",
+    "include_expression",
+    "include_once_expression",
+}
+# Statements importing a module by a call to require (`const fs = require("fs");`, `require 'json'`)
+REQUIRE_STATEMENT_TYPES = {"lexical_declaration", "variable_declaration", "expression_statement", "call"}
+REQUIRE_PATTERN = re.compile(
+    r"""^(?:(?:const|let|var)\s+[^=]+=\s*)?require(?:_relative)?[\s(]+['"`][^'"`]+['"`]\s*\)?(?:\.\w+)*;?$"""
+)
+# Note of the pairs compared cross-language: the abstract streams match more easily than tokens of one language
+CROSS_LANGUAGE_NOTE = (
+    "Compared across languages through abstract token categories: expect a lower confidence than same-language"
+    " scores, common shapes (loops over collections, accumulators) matching more easily"
+)
+# Number of consecutive words of a shingle, in the plain-text comparison
+PLAIN_TEXT_SHINGLE_SIZE = 5
+WORD_PATTERN = re.compile(r"\S+")
+
 
 class SimilarityDetectionService:
     def __init__(self):
         """Initialize the similarity detection service."""
         pass
 
-    def prepare_for_similarity(self, tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    def prepare_for_similarity(
+        self,
+        tokens: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> List[Dict[str, Any]]:
         """
         Prepare tokens for similarity comparison by filtering and normalizing elements.
 
@@ -34,8 +114,29 @@ class SimilarityDetectionService:
         - String literals (normalize to generic placeholder)
         - Numeric literals (normalize to generic placeholder)
         - Variable names (normalize to generic placeholder)
+
+        With `ignore_imports`, import, include, using and require statements are removed with their content.
+
+        With `ignore_comments`, comments of every kind (and Python docstrings with `docstrings_as_comments`)
+        are removed from the stream and from the text of the tokens enclosing them, the remaining tokens
+        keeping their original positions.
+
+        With `normalize_identifiers`, identifiers are replaced with positional placeholders (ID1, ID2...) except
+        the keywords, builtins and standard library names of the language (of every language if not given).
+
+        With `normalize_literals`, string and numeric literals are replaced with STR and NUM placeholders, their
+        original text being kept in `original_text`.
         """
         similarity_tokens = []
+        if options and options.ignore_imports:
+            tokens = self.remove_imports(tokens)
+        if options and options.ignore_comments:
+            tokens = self._remove_comments(tokens, options.docstrings_as_comments)
+        normalize_identifiers = bool(options and options.normalize_identifiers)
+        if normalize_identifiers:
+            tokens = IdentifierNormalizer(language).normalize(tokens)
+        if options and options.normalize_literals:
+            tokens = LiteralNormalizer(options.literal_length_buckets, options.preserve_format_verbs).normalize(tokens)
 
         # Types to keep as-is (structural/logical elements)
         keep_types = {
@@ -43,10 +144,12 @@ class SimilarityDetectionService:
             "else_clause",
             "elif_clause",
             "for_statement",
+            "async_for_statement",
             "while_statement",
             "break_statement",
             "continue_statement",
             "function_definition",
+            "async_function_definition",
             "class_definition",
             "method_definition",
             "call",
@@ -60,6 +163,7 @@ class SimilarityDetectionService:
             "except_clause",
             "finally_clause",
             "with_statement",
+            "async_with_statement",
             "assert_statement",
             "list",
             "dictionary",
@@ -82,6 +186,13 @@ class SimilarityDetectionService:
         # Types normalized to generic placeholders
         normalize_types = {
             "string": "<STRING>",
+            "f_string": "<STRING>",
+            "template_literal": "<STRING>",
+            "text_block": "<STRING>",
+            "string_template": "<STRING>",
+            "interpolated_string": "<STRING>",
+            "heredoc": "<STRING>",
+            "heredoc_content": "<STRING>",
             "integer": "<NUMBER>",
             "float": "<NUMBER>",
             "identifier": "<VAR>",
@@ -90,6 +201,8 @@ class SimilarityDetectionService:
 
         # Types to completely filter out
         skip_types = {"comment", "ERROR"}  # Parsing errors
+        if options and options.ignore_struct_tags:
+            skip_types.add("struct_tag")
 
         for token in tokens:
             token_type = token.get("type", "")
@@ -98,26 +211,203 @@ class SimilarityDetectionService:
             if token_type in skip_types:
                 continue
 
+            # Positions are kept so that reports can show the context of the compared tokens
+            position = {
+                "start": token.get("start"),
+                "end": token.get("end"),
+                "start_column": token.get("start_column"),
+                "end_column": token.get("end_column"),
+            }
+            if "file" in token:
+                position["file"] = token["file"]
+            token_text = token.get("text", "")
+
+            # Literals replaced with their placeholder
+            if token_type in (STRING_PLACEHOLDER, NUMBER_PLACEHOLDER) and "original_text" in token:
+                similarity_tokens.append(
+                    {
+                        "type": token_type,
+                        "text": token_text,
+                        "normalized": True,
+                        "original_text": token["original_text"],
+                        **position,
+                    }
+                )
+                continue
+
             if token_type in keep_types:
-                similarity_tokens.append({"type": token_type, "text": token.get("text", ""), "normalized": False})
+                similarity_tokens.append({"type": token_type, "text": token_text, "normalized": False, **position})
                 continue
 
-            # Normalize certain types
-            if token_type in normalize_types:
-                similarity_tokens.append({"type": token_type, "text": normalize_types[token_type], "normalized": True})
+            # Normalize certain types (identifiers keep their positional placeholder when normalized)
+            if token_type in normalize_types and not (normalize_identifiers and token_type in IDENTIFIER_TYPES):
+                similarity_tokens.append(
+                    {"type": token_type, "text": normalize_types[token_type], "normalized": True, **position}
+                )
                 continue
 
-            similarity_tokens.append({"type": token_type, "text": token.get("text", ""), "normalized": False})
+            similarity_tokens.append({"type": token_type, "text": token_text, "normalized": False, **position})
 
         return similarity_tokens
 
-    def get_similarity_signature(self, tokens: List[Dict[str, Any]]) -> str:
+    def remove_imports(self, tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """
+        Remove the import statements (Go import blocks, Python `import` / `from ... import`, Java `import`,
+        C `#include`, JavaScript `import` / `require`...) and the tokens they enclose from a token stream.
+        The enclosed tokens follow the statement, on its lines, and their text is found in order in its text.
+        """
+        kept: List[Dict[str, Any]] = []
+        statement = None
+        cursor = 0
+
+        for token in tokens:
+            token_type = token.get("type", "")
+            text = token.get("text", "")
+
+            if statement is not None:
+                position = statement.get("text", "").find(text, cursor) if text else -1
+                on_statement_lines = statement.get("start", 0) <= token.get("start", 0) <= statement.get("end", 0)
+                if on_statement_lines and position >= 0:
+                    cursor = position
+                    continue
+                statement = None
+
+            if token_type in IMPORT_TYPES or (
+                token_type in REQUIRE_STATEMENT_TYPES and REQUIRE_PATTERN.match(text.strip())
+            ):
+                statement = token
+                cursor = 0
+                continue
+            kept.append(token)
+
+        return kept
+
+    def _remove_comments(self, tokens: List[Dict[str, Any]], docstrings_as_comments: bool) -> List[Dict[str, Any]]:
+        """
+        Remove the comments (and docstrings) from a token stream and from the text of the tokens enclosing them.
+        A Python docstring is an expression statement made of a single string, first statement of the module or
+        of the body of a function or class.
+        """
+        kept: List[Dict[str, Any]] = []
+        removed: List[Dict[str, Any]] = []
+        # Whether the next block is the body of a function or class, whether the next statement is the first one
+        pending_body = False
+        first_statement = False
+        docstring_end = None
+
+        for index, token in enumerate(tokens):
+            token_type = token.get("type", "")
+
+            if docstring_end is not None:
+                if token_type in STRING_PART_TYPES and token.get("start", 0) <= docstring_end:
+                    continue
+                docstring_end = None
+
+            if token_type in COMMENT_TYPES:
+                removed.append(token)
+                continue
+
+            if docstrings_as_comments and first_statement and self._is_docstring(tokens, index):
+                removed.append(token)
+                docstring_end = token.get("end", 0)
+                first_statement = False
+                continue
+
+            if token_type in DOCSTRING_OWNER_TYPES:
+                pending_body = True
+                first_statement = False
+            elif token_type == "module" or (token_type == "block" and pending_body):
+                pending_body = False
+                first_statement = True
+            else:
+                first_statement = False
+            kept.append(token)
+
+        removed.sort(key=lambda removed_token: removed_token.get("start", 0))
+        removed_starts = [removed_token.get("start", 0) for removed_token in removed]
+        return [
+            {**token, "text": self._strip_removed_text(token, token.get("text", ""), removed, removed_starts)}
+            for token in kept
+        ]
+
+    @staticmethod
+    def _is_docstring(tokens: List[Dict[str, Any]], index: int) -> bool:
+        """Check whether a token is a statement holding only a string (the tokens of the string follow it)"""
+        token = tokens[index]
+        if token.get("type") != "expression_statement" or index + 1 >= len(tokens):
+            return False
+        string_token = tokens[index + 1]
+        return string_token.get("type") == "string" and string_token.get("text", "").strip() == token.get(
+            "text", ""
+        ).strip()
+
+    @staticmethod
+    def _strip_removed_text(
+        token: Dict[str, Any], text: str, removed_tokens: List[Dict[str, Any]], removed_starts: List[int]
+    ) -> str:
+        """
+        Remove the text of the removed comments enclosed by a token, then the whitespace at the end of the lines
+        and the blank lines, so that the token reads the same as in a file without the comments (a token never
+        starts with whitespace, the indentation left before a leading comment is removed too).
+        """
+        start, end = token.get("start", 0), token.get("end", 0)
+        index = bisect_left(removed_starts, start)
+        while index < len(removed_tokens) and removed_starts[index] <= end:
+            removed_text = removed_tokens[index].get("text", "")
+            if removed_text and removed_tokens[index].get("end", 0) <= end:
+                text = text.replace(removed_text, "", 1)
+            index += 1
+        return "\n".join(line.rstrip() for line in text.split("\n") if line.strip()).lstrip()
+
+    def get_similarity_signature(
+        self,
+        tokens: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> str:
         """
         Generate a compact signature for similarity comparison.
         This creates a normalized string representation focusing on structure.
         """
-        similarity_tokens = self.prepare_for_similarity(tokens)
+        similarity_tokens = self.prepare_for_similarity(tokens, options, language)
+        return " | ".join(self._signature_parts(similarity_tokens))
+
+    def fingerprint(
+        self,
+        tokens: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> List[Fingerprint]:
+        """
+        Get the winnowed fingerprints of a token stream, prepared and hashed as the compared streams are, so that
+        a fragment copied from one file to another gets the same fingerprints in both
+        """
+        similarity_tokens = self.prepare_for_similarity(tokens, options, language)
+        winnower = Winnower(
+            options.fingerprint_kgram_size if options else DEFAULT_KGRAM_SIZE,
+            options.fingerprint_window_size if options else DEFAULT_WINDOW_SIZE,
+        )
+        return winnower.fingerprint(self._signature_parts(similarity_tokens), similarity_tokens)
+
+    def fingerprint_stream(
+        self, tokens: Iterable[Dict[str, Any]], language: Optional[str] = None
+    ) -> Iterator[Fingerprint]:
+        """
+        Get the fingerprints of a token stream like `fingerprint` with the default options, the tokens being
+        prepared a batch at a time as they come, so that a large file is not held in memory
+        """
+        tokens = iter(tokens)
+        winnower = Winnower(DEFAULT_KGRAM_SIZE, DEFAULT_WINDOW_SIZE)
 
+        def signatures() -> Iterator[Tuple[str, Dict[str, Any]]]:
+            while batch := list(itertools.islice(tokens, FINGERPRINT_STREAM_BATCH_SIZE)):
+                similarity_tokens = self.prepare_for_similarity(batch, language=language)
+                yield from zip(self._signature_parts(similarity_tokens), similarity_tokens)
+
+        return winnower.fingerprint_stream(signatures())
+
+    def _signature_parts(self, similarity_tokens: List[Dict[str, Any]]) -> List[str]:
+        """Get the signature part of each prepared token"""
         signature_parts = []
         for token in similarity_tokens:
             if token["normalized"]:
@@ -129,8 +419,7 @@ class SimilarityDetectionService:
                 if len(token_text) > 20:
                     token_text = token_text[:20] + "..."
                 signature_parts.append(f"{token['type']}:{token_text}")
-
-        return " | ".join(signature_parts)
+        return signature_parts
 
     def _normalize_structural_token(self, text: str, token_type: str) -> str:
         """
@@ -263,18 +552,115 @@ class SimilarityDetectionService:
 
         return exact_jaccard
 
-    def compare_similarity(self, tokens1: List[Dict[str, Any]], tokens2: List[Dict[str, Any]]) -> Dict[str, Any]:
+    def compare_similarity(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+        tfidf_model: Optional[TfidfModel] = None,
+    ) -> Dict[str, Any]:
         """
         Compare similarity between two sets of tokens.
         Returns similarity metrics and analysis with overall similarity score.
+        The language (of both token sets) selects the names kept when identifiers are normalized.
+        With `exclude_unreachable_functions`, the Go and Python functions never called are reported as padding
+        and left out of the comparison.
+        The winnowed fingerprints (`fingerprint_kgram_size`, `fingerprint_window_size`) are reported with their
+        token positions, along with their Jaccard similarity.
+        The `metric` of the result produced its score: with `kgram_jaccard`, only the Jaccard coefficient of the
+        token k-grams is computed, to cheaply pre-filter the pairs. With `tfidf_cosine`, the n-grams are weighted
+        by the IDF model of the run (see `compare_run`), computed from the two token sets when none is given.
+        Whatever the metric, the maximal runs of at least `min_match_tokens` matching tokens are reported in
+        `matches` (TokenMatchDto shape) with their lines and columns in both files, and those of at least
+        `min_fragment_tokens` tokens in `fragments` (FragmentDto shape) with their similarity. The
+        `greedy_string_tiling` metric scores the share of the tokens covered by the tiles of at least
+        `min_tile_length` tokens.
+        The matches are broken down to the files of both token streams in `file_similarities` (see
+        FileSimilarityBreakdown), the scores of the file pairs being aggregated with `file_aggregation`. With
+        `aggregate_file_scores`, the aggregated score is the overall similarity, the score of the metric being kept
+        in `metric_similarity`. The functions of both streams of at least `min_function_tokens` tokens are reported
+        with their best-matching counterpart in `function_similarities` (see FunctionSimilarity).
         """
+        result = self._compare_streams(tokens1, tokens2, options, language, tfidf_model)
+        if options and options.aggregate_file_scores:
+            result["metric_similarity"] = result["overall_similarity"]
+            result["overall_similarity"] = result["file_similarities"]["aggregation"]["similarity"]
+        return result
+
+    def _compare_streams(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+        tfidf_model: Optional[TfidfModel] = None,
+    ) -> Dict[str, Any]:
+        """Compare two sets of tokens with the metric of the options (see compare_similarity)"""
+        original_tokens1, original_tokens2 = tokens1, tokens2
+
+        # Imports are removed once here to report the number of excluded tokens
+        excluded_import_tokens = 0
+        if options and options.ignore_imports:
+            kept1 = self.remove_imports(tokens1)
+            kept2 = self.remove_imports(tokens2)
+            excluded_import_tokens = len(tokens1) - len(kept1) + len(tokens2) - len(kept2)
+            tokens1, tokens2 = kept1, kept2
+
+        unreachable_functions = {"file1": [], "file2": []}
+        if options and options.exclude_unreachable_functions:
+            analyzer = ReachabilityAnalyzer(language)
+            reachability1 = analyzer.analyze(tokens1)
+            reachability2 = analyzer.analyze(tokens2)
+            unreachable_functions = {"file1": reachability1.to_dict(), "file2": reachability2.to_dict()}
+            tokens1 = analyzer.exclude(tokens1, reachability1)
+            tokens2 = analyzer.exclude(tokens2, reachability2)
+
         # Prepare both token sets for similarity comparison
-        sim_tokens1 = self.prepare_for_similarity(tokens1)
-        sim_tokens2 = self.prepare_for_similarity(tokens2)
+        sim_tokens1 = self.prepare_for_similarity(tokens1, options, language)
+        sim_tokens2 = self.prepare_for_similarity(tokens2, options, language)
+        parts1 = self._signature_parts(sim_tokens1)
+        parts2 = self._signature_parts(sim_tokens2)
+
+        # Copied blocks, found among the prepared tokens so that the excluded regions never match
+        match_finder = TokenMatchFinder(options.min_match_tokens if options else DEFAULT_MIN_MATCH_TOKENS)
+        matches = match_finder.locate(match_finder.find(parts1, parts2), sim_tokens1, sim_tokens2)
+        min_fragment_tokens = options.min_fragment_tokens if options else DEFAULT_MIN_FRAGMENT_TOKENS
+        breakdown = FileSimilarityBreakdown(options.file_aggregation if options else FileAggregation.SIZE_WEIGHTED)
+        function_similarity = FunctionSimilarity(
+            options.min_function_tokens if options else DEFAULT_MIN_FUNCTION_TOKENS,
+            options.fingerprint_kgram_size if options else DEFAULT_KGRAM_SIZE,
+        )
+        preprocessing = {
+            "excluded_import_tokens": excluded_import_tokens,
+            "unreachable_functions": unreachable_functions,
+            "matches": matches,
+            "fragments": match_finder.fragments(matches, sim_tokens1, sim_tokens2, min_fragment_tokens),
+            "file_similarities": breakdown.breakdown(
+                matches, sim_tokens1, sim_tokens2, original_tokens1, original_tokens2
+            ),
+            "function_similarities": function_similarity.compare(sim_tokens1, parts1, sim_tokens2, parts2),
+        }
+
+        if options and options.metric == SimilarityMetric.TFIDF_COSINE:
+            model = tfidf_model or TfidfModel.fit([parts1, parts2], options.tfidf_ngram_size)
+            return {**self._tfidf_cosine_similarity(parts1, parts2, model), **preprocessing}
+
+        if options and options.metric == SimilarityMetric.GREEDY_STRING_TILING:
+            return {
+                **self._greedy_tiling_similarity(parts1, parts2, sim_tokens1, sim_tokens2, options.min_tile_length),
+                **preprocessing,
+            }
+
+        if options and options.metric == SimilarityMetric.KGRAM_JACCARD:
+            return {
+                **self._kgram_jaccard_similarity(sim_tokens1, sim_tokens2, options.jaccard_kgram_size),
+                **preprocessing,
+            }
 
         # Generate signatures
-        signature1 = self.get_similarity_signature(tokens1)
-        signature2 = self.get_similarity_signature(tokens2)
+        signature1 = self.get_similarity_signature(tokens1, options, language)
+        signature2 = self.get_similarity_signature(tokens2, options, language)
 
         sig1_parts = signature1.split(" | ")
         sig2_parts = signature2.split(" | ")
@@ -282,6 +668,15 @@ class SimilarityDetectionService:
         # Calculate enhanced Jaccard similarity with fuzzy matching
         jaccard_similarity = self._calculate_enhanced_jaccard_similarity(sig1_parts, sig2_parts)
 
+        # Document fingerprints selected by winnowing, compared with the tokens they are located with
+        winnower = Winnower(
+            options.fingerprint_kgram_size if options else DEFAULT_KGRAM_SIZE,
+            options.fingerprint_window_size if options else DEFAULT_WINDOW_SIZE,
+        )
+        fingerprints1 = winnower.fingerprint(parts1, sim_tokens1)
+        fingerprints2 = winnower.fingerprint(parts2, sim_tokens2)
+        fingerprint_similarity = winnower.similarity(fingerprints1, fingerprints2)
+
         # Calculate traditional metrics for backward compatibility
         common_parts = set(sig1_parts) & set(sig2_parts)
         total_unique_parts = set(sig1_parts) | set(sig2_parts)
@@ -366,6 +761,7 @@ class SimilarityDetectionService:
         ) * length_penalty  # Apply length penalty
 
         return {
+            "metric": SimilarityMetric.WEIGHTED.value,
             "jaccard_similarity": jaccard_similarity,
             "type_similarity": type_similarity,
             "overall_similarity": round(overall_similarity, 4),
@@ -373,21 +769,477 @@ class SimilarityDetectionService:
             "type_sequence_similarity": round(type_sequence_similarity, 4),
             "flow_similarity": round(flow_similarity, 4),
             "operation_similarity": round(operation_similarity, 4),
+            "fingerprint_similarity": round(fingerprint_similarity, 4),
             "length_penalty": round(length_penalty, 4),
             "common_elements": len(common_parts),
             "total_unique_elements": len(total_unique_parts),
             "signature1_length": len(sig1_parts),
             "signature2_length": len(sig2_parts),
+            **preprocessing,
             "tokens1_length": len1,
             "tokens2_length": len2,
             "length_ratio": round(length_ratio, 4),
             "common_types": list(common_types),
+            "fingerprints": {
+                "kgram_size": winnower.kgram_size,
+                "window_size": winnower.window_size,
+                "file1": [fingerprint.to_dict() for fingerprint in fingerprints1],
+                "file2": [fingerprint.to_dict() for fingerprint in fingerprints2],
+            },
             "signatures": {
                 "file1": signature1[:100] + "..." if len(signature1) > 100 else signature1,
                 "file2": signature2[:100] + "..." if len(signature2) > 100 else signature2,
             },
         }
 
+    def _kgram_jaccard_similarity(
+        self, sim_tokens1: List[Dict[str, Any]], sim_tokens2: List[Dict[str, Any]], kgram_size: int
+    ) -> Dict[str, Any]:
+        """
+        Jaccard coefficient of the sets of k-grams of the prepared tokens. A file shorter than a k-gram (an empty
+        one included) is flagged as too short and scores 0.
+        """
+        kgrams1 = self._token_kgrams(sim_tokens1, kgram_size)
+        kgrams2 = self._token_kgrams(sim_tokens2, kgram_size)
+        too_short = {"file1": len(sim_tokens1) < kgram_size, "file2": len(sim_tokens2) < kgram_size}
+
+        common_kgrams = kgrams1 & kgrams2
+        total_kgrams = kgrams1 | kgrams2
+        if too_short["file1"] or too_short["file2"]:
+            similarity = 0.0
+        else:
+            similarity = len(common_kgrams) / len(total_kgrams)
+
+        return {
+            "metric": SimilarityMetric.KGRAM_JACCARD.value,
+            "kgram_size": kgram_size,
+            "jaccard_similarity": round(similarity, 4),
+            "overall_similarity": round(similarity, 4),
+            "too_short": too_short,
+            "common_elements": len(common_kgrams),
+            "total_unique_elements": len(total_kgrams),
+            "tokens1_length": len(sim_tokens1),
+            "tokens2_length": len(sim_tokens2),
+        }
+
+    @staticmethod
+    def _tfidf_cosine_similarity(parts1: List[str], parts2: List[str], model: TfidfModel) -> Dict[str, Any]:
+        """
+        Cosine of the TF-IDF vectors of the signature parts of two files. A file shorter than an n-gram (an empty
+        one included) is flagged as too short and scores 0.
+        """
+        vector1 = model.vector(parts1)
+        vector2 = model.vector(parts2)
+        similarity = TfidfModel.cosine(vector1, vector2)
+        common_ngrams = vector1.keys() & vector2.keys()
+
+        return {
+            "metric": SimilarityMetric.TFIDF_COSINE.value,
+            "ngram_size": model.ngram_size,
+            "jaccard_similarity": round(similarity, 4),
+            "overall_similarity": round(similarity, 4),
+            "too_short": {"file1": len(parts1) < model.ngram_size, "file2": len(parts2) < model.ngram_size},
+            "common_elements": len(common_ngrams),
+            "total_unique_elements": len(vector1.keys() | vector2.keys()),
+            "tokens1_length": len(parts1),
+            "tokens2_length": len(parts2),
+        }
+
+    def build_tfidf_model(
+        self,
+        token_sets: Iterable[List[Dict[str, Any]]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> TfidfModel:
+        """Compute the IDF model of the token sets of a run, prepared as they are for the comparison"""
+        documents = []
+        for tokens in token_sets:
+            if options and options.ignore_imports:
+                tokens = self.remove_imports(tokens)
+            if options and options.exclude_unreachable_functions:
+                analyzer = ReachabilityAnalyzer(language)
+                tokens = analyzer.exclude(tokens, analyzer.analyze(tokens))
+            documents.append(self._signature_parts(self.prepare_for_similarity(tokens, options, language)))
+        return TfidfModel.fit(documents, options.tfidf_ngram_size if options else DEFAULT_TFIDF_NGRAM_SIZE)
+
+    def compared_stream(
+        self,
+        tokens: List[Dict[str, Any]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> Tuple[List[Dict[str, Any]], List[str]]:
+        """
+        Get the tokens of a file as the comparison sees them, prepared with the detection options (the functions
+        never called excluded if asked), and the signature part each is compared by
+        """
+        if options and options.ignore_imports:
+            tokens = self.remove_imports(tokens)
+        if options and options.exclude_unreachable_functions:
+            analyzer = ReachabilityAnalyzer(language)
+            tokens = analyzer.exclude(tokens, analyzer.analyze(tokens))
+        similarity_tokens = self.prepare_for_similarity(tokens, options, language)
+        return similarity_tokens, self._signature_parts(similarity_tokens)
+
+    def compare_run(
+        self,
+        documents: Dict[str, List[Dict[str, Any]]],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare every pair of the submissions of a detection run (token sets by name), tokenized once. With the
+        `tfidf_cosine` metric, the IDF model is computed across the submissions of the run and returned in the run
+        metadata, so that the scores can be reproduced.
+        """
+        tfidf_model = None
+        if options and options.metric == SimilarityMetric.TFIDF_COSINE:
+            tfidf_model = self.build_tfidf_model(documents.values(), options, language)
+
+        pairs = []
+        names = sorted(documents)
+        for index, name1 in enumerate(names):
+            for name2 in names[index + 1 :]:
+                result = self.compare_similarity(documents[name1], documents[name2], options, language, tfidf_model)
+                pairs.append(
+                    {
+                        "document1": name1,
+                        "document2": name2,
+                        "overall_similarity": result["overall_similarity"],
+                        "jaccard_similarity": result["jaccard_similarity"],
+                        "too_short": result.get("too_short"),
+                    }
+                )
+        pairs.sort(key=lambda pair: pair["overall_similarity"], reverse=True)
+
+        return {
+            "metric": (options.metric if options else SimilarityMetric.WEIGHTED).value,
+            "run_metadata": {
+                "documents": len(documents),
+                "tfidf_model": tfidf_model.to_dict() if tfidf_model else None,
+            },
+            "pairs": pairs,
+        }
+
+    def _greedy_tiling_similarity(
+        self,
+        parts1: List[str],
+        parts2: List[str],
+        sim_tokens1: List[Dict[str, Any]],
+        sim_tokens2: List[Dict[str, Any]],
+        min_tile_length: int,
+    ) -> Dict[str, Any]:
+        """
+        Greedy string tiling similarity of two prepared token sets: the share of the tokens of both files covered
+        by the tiles (see GreedyStringTiler), the tiles being located in both files (TokenMatchDto shape).
+        """
+        tiles = GreedyStringTiler(min_tile_length).tile(parts1, parts2)
+        coverage = GreedyStringTiler.coverage(tiles, len(parts1), len(parts2))
+        return {
+            "metric": SimilarityMetric.GREEDY_STRING_TILING.value,
+            "min_tile_length": min_tile_length,
+            "overall_similarity": round(coverage["similarity"], 4),
+            "coverage": {"file1": round(coverage["file1"], 4), "file2": round(coverage["file2"], 4)},
+            "tiles": TokenMatchFinder(min_tile_length).locate(tiles, sim_tokens1, sim_tokens2),
+            "tokens1_length": len(parts1),
+            "tokens2_length": len(parts2),
+        }
+
+    def _token_kgrams(self, sim_tokens: List[Dict[str, Any]], kgram_size: int) -> Set[Tuple[str, ...]]:
+        """Get the set of runs of `kgram_size` consecutive signature parts of the prepared tokens"""
+        parts = self._signature_parts(sim_tokens)
+        return {tuple(parts[index : index + kgram_size]) for index in range(len(parts) - kgram_size + 1)}
+
+    def compare_similarity_with_baseline(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        baseline_fingerprints: Collection[str],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare two sets of tokens without the regions matching the starter code (baseline) fingerprints.
+        The scores are the baseline-adjusted ones, the raw scores being kept in `raw_similarity`.
+        """
+        raw_result = self.compare_similarity(tokens1, tokens2, options, language)
+        if not baseline_fingerprints:
+            return {**raw_result, "raw_similarity": self._raw_scores(raw_result)}
+
+        baseline_filter = BaselineFilter()
+        subtraction1 = baseline_filter.subtract(tokens1, baseline_fingerprints)
+        subtraction2 = baseline_filter.subtract(tokens2, baseline_fingerprints)
+        logger.debug(
+            f"Baseline subtracted {subtraction1.excluded_token_count} and {subtraction2.excluded_token_count} tokens"
+        )
+
+        result = self.compare_similarity(subtraction1.tokens, subtraction2.tokens, options, language)
+        if not subtraction1.tokens or not subtraction2.tokens:
+            # Nothing but starter code left: nothing is shared (empty signatures would otherwise be identical)
+            result.update({key: 0.0 for key in BASELINE_RAW_SCORES if key in result})
+        result["raw_similarity"] = self._raw_scores(raw_result)
+        for side, tokens, subtraction in (("file1", tokens1, subtraction1), ("file2", tokens2, subtraction2)):
+            result["file_similarities"]["excluded_files"][side].extend(
+                {"file": file, "reason": "baseline"} for file in baseline_filter.excluded_files(tokens, subtraction)
+            )
+        result["baseline"] = {
+            "excluded_tokens": {
+                "file1": subtraction1.excluded_token_count,
+                "file2": subtraction2.excluded_token_count,
+            },
+            "regions": {"file1": subtraction1.regions, "file2": subtraction2.regions},
+        }
+        return result
+
+    def compare_similarity_with_allowed_snippets(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        baseline_fingerprints: Collection[str],
+        snippet_fingerprints: Collection[str],
+        options: Optional[DetectionOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare two sets of tokens like compare_similarity_with_baseline, the matched fragments lying within an
+        allowed snippet in both files being excluded from the scores. They are reported apart in
+        `sanctioned_overlap`, with the scores of the pair before their exclusion.
+        """
+        result = self.compare_similarity_with_baseline(tokens1, tokens2, baseline_fingerprints, options, language)
+        if not snippet_fingerprints:
+            return result
+
+        overlap = AllowedSnippetFilter().sanction(tokens1, tokens2, result["fragments"], snippet_fingerprints)
+        unsanctioned_scores = self._raw_scores(result)
+        if overlap.fragments:
+            logger.debug(
+                f"Allowed snippets excluded {len(overlap.fragments)} fragments, "
+                f"{overlap.excluded_token_count1} and {overlap.excluded_token_count2} tokens"
+            )
+            raw_similarity = result["raw_similarity"]
+            result = self.compare_similarity_with_baseline(
+                overlap.tokens1, overlap.tokens2, baseline_fingerprints, options, language
+            )
+            if not overlap.tokens1 or not overlap.tokens2:
+                result.update({key: 0.0 for key in BASELINE_RAW_SCORES if key in result})
+            result["raw_similarity"] = raw_similarity
+        result["sanctioned_overlap"] = {
+            "fragments": overlap.fragments,
+            "excluded_tokens": {"file1": overlap.excluded_token_count1, "file2": overlap.excluded_token_count2},
+            "unsanctioned_similarity": unsanctioned_scores,
+        }
+        return result
+
+    @staticmethod
+    def _raw_scores(result: Dict[str, Any]) -> Dict[str, Any]:
+        """Get the scores of a result reported as raw ones, those the metric of the result computes"""
+        return {key: result[key] for key in BASELINE_RAW_SCORES if key in result}
+
+    def compare_go_structure(
+        self,
+        source1: str,
+        source2: str,
+        tokenization_service,
+        options: Optional[DetectionOptionsDto] = None,
+        file1_path: Optional[Path] = None,
+        file2_path: Optional[Path] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare two Go files in structural mode: the shapes of the syntax trees of their functions, methods and
+        types are matched regardless of their order (see GoStructuralAnalyzer). A file that does not parse is not
+        an error: both files are then compared in token mode, with a warning in the result.
+        """
+        file1_path = file1_path or Path("file1.go")
+        file2_path = file2_path or Path("file2.go")
+        trees = {
+            "file1": tokenization_service.parse_tree(source1, "go", file1_path),
+            "file2": tokenization_service.parse_tree(source2, "go", file2_path),
+        }
+        unparsed = [name for name, tree in trees.items() if tree is None or tree.root_node.has_error]
+
+        if unparsed:
+            warning = f"Go parse error in {' and '.join(unparsed)}, compared in token mode"
+            logger.warning(warning)
+            tokens1 = tokenization_service.tokenize(source1, file1_path)
+            tokens2 = tokenization_service.tokenize(source2, file2_path)
+            result = self.compare_similarity(tokens1, tokens2, options, "go")
+            result["warnings"] = [warning]
+            return result
+
+        analyzer = GoStructuralAnalyzer()
+        units1 = analyzer.extract_units(trees["file1"].root_node, source1.encode("utf8"))
+        units2 = analyzer.extract_units(trees["file2"].root_node, source2.encode("utf8"))
+        result = analyzer.compare(units1, units2)
+        return {
+            "metric": SimilarityMetric.STRUCTURAL.value,
+            "overall_similarity": result["structural_similarity"],
+            **result,
+            "warnings": [],
+        }
+
+    def compare_cross_language(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        language1: Optional[str],
+        language2: Optional[str],
+        options: Optional[DetectionOptionsDto] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare files of two different languages: both token streams are mapped to the shared alphabet of the
+        abstract token categories (see TokenAbstractor), then compared as usual, fingerprints included. The
+        result is labeled in `cross_language` with the languages and the lower confidence to expect. The starter
+        code is not subtracted, its fingerprints being those of the tokens of one language.
+        """
+        abstractor = TokenAbstractor()
+        result = self.compare_similarity_with_baseline(
+            abstractor.abstract(tokens1), abstractor.abstract(tokens2), (), options
+        )
+        result["cross_language"] = {
+            "languages": {"file1": language1, "file2": language2},
+            "confidence": "lower",
+            "note": CROSS_LANGUAGE_NOTE,
+        }
+        return result
+
+    def compare_plain_text(
+        self, source1: str, source2: str, options: Optional[DetectionOptionsDto] = None
+    ) -> Dict[str, Any]:
+        """
+        Compare two files without tokenizer: both texts are normalized (line endings, indentation and runs of
+        whitespace, optionally case), then compared by shingles of consecutive words so that re-wrapped lines
+        still match. The matched fragments are reported with the byte offsets and lines of the original files, and
+        paired across both files in `fragments` (lines only) from `min_fragment_tokens` words.
+        """
+        normalizer = TextNormalizer(lowercase=bool(options and options.plain_text_lowercase))
+        text1 = normalizer.normalize(source1)
+        text2 = normalizer.normalize(source2)
+        words1 = [match.span() for match in WORD_PATTERN.finditer(text1.text)]
+        words2 = [match.span() for match in WORD_PATTERN.finditer(text2.text)]
+        shingles1 = self._plain_text_shingles(text1.text, words1)
+        shingles2 = self._plain_text_shingles(text2.text, words2)
+
+        common = set(shingles1) & set(shingles2)
+        total = set(shingles1) | set(shingles2)
+        if not total:
+            similarity = 1.0 if not source1.strip() and not source2.strip() else 0.0
+        else:
+            similarity = len(common) / len(total)
+
+        return {
+            "similarity": round(similarity, 4),
+            "shingle_size": PLAIN_TEXT_SHINGLE_SIZE,
+            "common_shingles": len(common),
+            "total_unique_shingles": len(total),
+            "matches_file1": self._plain_text_matches(source1, text1, words1, shingles1, common),
+            "matches_file2": self._plain_text_matches(source2, text2, words2, shingles2, common),
+            "fragments": self._plain_text_fragments(
+                (text1, words1, shingles1),
+                (text2, words2, shingles2),
+                common,
+                options.min_fragment_tokens if options else DEFAULT_MIN_FRAGMENT_TOKENS,
+            ),
+        }
+
+    @staticmethod
+    def _plain_text_shingles(text: str, words: List[tuple]) -> Dict[tuple, List[int]]:
+        """Get the shingles of a normalized text, with the index of the first word of each of their occurrences"""
+        size = min(PLAIN_TEXT_SHINGLE_SIZE, len(words))
+        shingles: Dict[tuple, List[int]] = {}
+        for index in range(len(words) - size + 1 if size else 0):
+            shingle = tuple(text[start:end] for start, end in words[index : index + size])
+            shingles.setdefault(shingle, []).append(index)
+        return shingles
+
+    @staticmethod
+    def _plain_text_matches(
+        source: str,
+        text: NormalizedText,
+        words: List[tuple],
+        shingles: Dict[tuple, List[int]],
+        common: set,
+    ) -> List[Dict[str, Any]]:
+        """Merge the words covered by common shingles into fragments, located in the original file"""
+        size = min(PLAIN_TEXT_SHINGLE_SIZE, len(words))
+        covered = [False] * len(words)
+        for shingle in common:
+            for index in shingles[shingle]:
+                for word_index in range(index, index + size):
+                    covered[word_index] = True
+
+        source_bytes = source.encode("utf8")
+        matches = []
+        word_index = 0
+        while word_index < len(words):
+            if not covered[word_index]:
+                word_index += 1
+                continue
+            first = word_index
+            while word_index + 1 < len(words) and covered[word_index + 1]:
+                word_index += 1
+            start_byte, end_byte = text.original_span(words[first][0], words[word_index][1])
+            matches.append(
+                {
+                    "start_byte": start_byte,
+                    "end_byte": end_byte,
+                    "start_line": text.line_of(start_byte),
+                    "end_line": text.line_of(max(start_byte, end_byte - 1)),
+                    "text": source_bytes[start_byte:end_byte].decode("utf8", errors="replace"),
+                }
+            )
+            word_index += 1
+        return matches
+
+    @classmethod
+    def _plain_text_fragments(
+        cls,
+        side1: Tuple[NormalizedText, List[tuple], Dict[tuple, List[int]]],
+        side2: Tuple[NormalizedText, List[tuple], Dict[tuple, List[int]]],
+        common: set,
+        min_words: int,
+    ) -> List[Dict[str, Any]]:
+        """
+        Pair the runs of consecutive common shingles of both files into fragments (FragmentDto shape) of at least
+        `min_words` words, located by their lines in the original files, their words being the same once normalized
+        """
+        (text1, words1, shingles1), (text2, words2, shingles2) = side1, side2
+        size = min(PLAIN_TEXT_SHINGLE_SIZE, len(words1), len(words2))
+        pairs = {
+            (index1, index2) for shingle in common for index1 in shingles1[shingle] for index2 in shingles2[shingle]
+        }
+
+        fragments = []
+        for index1, index2 in sorted(pairs):
+            # Runs are followed from their first pair of shingles
+            if (index1 - 1, index2 - 1) in pairs:
+                continue
+            length = 1
+            while (index1 + length, index2 + length) in pairs:
+                length += 1
+            word_count = length + size - 1
+            if word_count < min_words:
+                continue
+            fragments.append(
+                {
+                    "left": cls._plain_text_lines(text1, words1, index1, word_count),
+                    "right": cls._plain_text_lines(text2, words2, index2, word_count),
+                    "tokens": word_count,
+                    "similarity": 1.0,
+                }
+            )
+        return fragments
+
+    @staticmethod
+    def _plain_text_lines(text: NormalizedText, words: List[tuple], first: int, count: int) -> Dict[str, Any]:
+        """Location by lines (MatchPositionDto shape) of consecutive words of a normalized text in the original file"""
+        start_byte, end_byte = text.original_span(words[first][0], words[first + count - 1][1])
+        return {
+            "file": None,
+            "start_line": text.line_of(start_byte),
+            "start_column": None,
+            "end_line": text.line_of(max(start_byte, end_byte - 1)),
+            "end_column": None,
+        }
+
     def detect_shared_code_blocks(
         self,
         source1: str,
@@ -773,11 +1625,13 @@ class SimilarityDetectionService:
             # Map similar concepts to same structural element
             if token_type in ["function_definition", "method_definition"]:
                 sequence.append("FUNC_DEF")
+            elif token_type in ["async_function_definition", "async_method_declaration"]:
+                sequence.append("ASYNC_FUNC_DEF")
             elif token_type in ["if_statement", "elif_clause"]:
                 sequence.append("CONDITIONAL")
             elif token_type == "else_clause":
                 sequence.append("ELSE")
-            elif token_type in ["for_statement", "while_statement"]:
+            elif token_type in ["for_statement", "while_statement", "async_for_statement"]:
                 sequence.append("LOOP")
             elif token_type == "return_statement":
                 sequence.append("RETURN")
@@ -789,10 +1643,28 @@ class SimilarityDetectionService:
                 sequence.append("CALL")
             elif token_type in ["list", "tuple", "dictionary", "set"]:
                 sequence.append("COLLECTION")
-            elif token_type in ["string", "integer", "float"]:
+            elif token_type in [
+                "string",
+                "f_string",
+                "template_literal",
+                "text_block",
+                "string_template",
+                "interpolated_string",
+                "heredoc",
+                "integer",
+                "float",
+            ]:
                 sequence.append("LITERAL")
             elif token_type == "identifier":
                 sequence.append("VAR")
+            elif token_type in ["channel_send"]:
+                sequence.append("CHAN_SEND")
+            elif token_type in ["channel_receive", "channel_receive_statement"]:
+                sequence.append("CHAN_RECV")
+            elif token_type in ["select_statement"]:
+                sequence.append("SELECT")
+            elif token_type in ["select_case", "select_default"]:
+                sequence.append("SELECT_CASE")
             else:
                 sequence.append(token_type.upper())
 
@@ -810,6 +1682,7 @@ class SimilarityDetectionService:
                 "elif_clause",
                 "else_clause",
                 "for_statement",
+                "async_for_statement",
                 "while_statement",
                 "break_statement",
                 "continue_statement",
@@ -826,6 +1699,9 @@ class SimilarityDetectionService:
                 "while_statement",
                 "do_statement",
                 "switch_statement",
+                "switch_expression",
+                "switch_rule",
+                "yield_statement",
                 "case_statement",
                 "break_statement",
                 "continue_statement",
@@ -855,6 +1731,16 @@ class SimilarityDetectionService:
                 "throw_statement",
             ]:
                 flow.append(token_type)
+            # Go concurrency patterns
+            elif token_type in [
+                "go_statement",
+                "select_statement",
+                "select_case",
+                "select_default",
+                "channel_send",
+                "channel_receive",
+            ]:
+                flow.append(token_type)
         return flow
 
     def _extract_operations(self, tokens: List[Dict[str, Any]]) -> List[str]:
@@ -915,6 +1801,7 @@ class SimilarityDetectionService:
         lcs_matrix = [[0] * (n + 1) for _ in range(m + 1)]
 
         for i in range(1, m + 1):
+            check_deadline()  # Quadratic, a pathological pair times out within it
             for j in range(1, n + 1):
                 if seq1[i - 1] == seq2[j - 1]:
                     lcs_matrix[i][j] = lcs_matrix[i - 1][j - 1] + 1
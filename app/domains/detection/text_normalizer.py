@@ -0,0 +1,89 @@
+from bisect import bisect_right
+from dataclasses import dataclass, field
+from typing import List, Tuple
+
+HORIZONTAL_WHITESPACE = " \t\f\v"
+
+
+@dataclass
+class NormalizedText:
+    """Normalized text, with the span of the original bytes each of its characters comes from"""
+
+    text: str
+    starts: List[int] = field(default_factory=list)
+    ends: List[int] = field(default_factory=list)
+    line_starts: List[int] = field(default_factory=lambda: [0])
+
+    def original_span(self, start: int, end: int) -> Tuple[int, int]:
+        """Get the original byte offsets [start, end) of the normalized characters [start, end)"""
+        if start >= end:
+            offset = self.starts[start] if start < len(self.starts) else (self.ends[-1] if self.ends else 0)
+            return offset, offset
+        return self.starts[start], self.ends[end - 1]
+
+    def line_of(self, byte_offset: int) -> int:
+        """Get the (0 based) line of the original text holding a byte offset"""
+        return bisect_right(self.line_starts, byte_offset) - 1
+
+
+class TextNormalizer:
+    """
+    Normalize the text of a file compared without tokenizer, so that re-indentation and reformatting do not
+    change it:
+
+    - CRLF and CR line endings become LF
+    - indentation and trailing whitespace are removed, other runs of spaces and tabs become a single space
+    - optionally, the text is lowercased
+
+    Each character of the normalized text records the original byte offsets it comes from, so that matches
+    found on the normalized text are reported on the lines of the original file.
+    """
+
+    def __init__(self, lowercase: bool = False):
+        self.lowercase = lowercase
+
+    def normalize(self, content: str) -> NormalizedText:
+        """Normalize a text"""
+        normalized = NormalizedText(text="")
+        chars: List[str] = []
+        # Start byte of the pending run of spaces, emitted only if followed by text on the same line
+        pending_space = None
+        line_has_text = False
+        byte_offset = 0
+        index = 0
+
+        while index < len(content):
+            char = content[index]
+            char_length = len(char.encode("utf8"))
+
+            if char in "\r\n":
+                # CRLF is a single line ending
+                if char == "\r" and index + 1 < len(content) and content[index + 1] == "\n":
+                    index += 1
+                    char_length += 1
+                self._append(normalized, chars, "\n", byte_offset, byte_offset + char_length)
+                normalized.line_starts.append(byte_offset + char_length)
+                pending_space = None
+                line_has_text = False
+            elif char in HORIZONTAL_WHITESPACE:
+                if line_has_text and pending_space is None:
+                    pending_space = byte_offset
+            else:
+                if pending_space is not None:
+                    self._append(normalized, chars, " ", pending_space, byte_offset)
+                    pending_space = None
+                for normalized_char in char.lower() if self.lowercase else char:
+                    self._append(normalized, chars, normalized_char, byte_offset, byte_offset + char_length)
+                line_has_text = True
+
+            byte_offset += char_length
+            index += 1
+
+        normalized.text = "".join(chars)
+        return normalized
+
+    @staticmethod
+    def _append(normalized: NormalizedText, chars: List[str], char: str, start: int, end: int) -> None:
+        chars.append(char)
+        normalized.starts.append(start)
+        normalized.ends.append(end)
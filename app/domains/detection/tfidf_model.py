@@ -0,0 +1,81 @@
+import hashlib
+import math
+from collections import Counter
+from typing import Any, Dict, Iterable, Optional, Sequence
+
+# Number of consecutive tokens of the n-grams weighted by the TF-IDF cosine metric
+DEFAULT_TFIDF_NGRAM_SIZE = 3
+# Length of the hexadecimal n-gram keys of the IDF model
+NGRAM_KEY_LENGTH = 16
+
+
+class TfidfModel:
+    """
+    Inverse document frequencies of the token n-grams of the submissions of a detection run, so that the idioms
+    every submission shares (loop headers, `if err != nil` checks) weigh less than the code few of them share.
+
+    A submission is the term frequency vector of its n-grams, each weighted by its smoothed IDF,
+    `ln((1 + documents) / (1 + document frequency)) + 1`, and pairs are scored by the cosine of their vectors.
+    The model is computed per run and serialized with its results, so that the scores can be reproduced.
+    """
+
+    def __init__(
+        self,
+        ngram_size: int = DEFAULT_TFIDF_NGRAM_SIZE,
+        document_count: int = 0,
+        idf: Optional[Dict[str, float]] = None,
+    ):
+        self.ngram_size = ngram_size
+        self.document_count = document_count
+        self.idf = idf or {}
+
+    @classmethod
+    def fit(cls, documents: Iterable[Sequence[str]], ngram_size: int = DEFAULT_TFIDF_NGRAM_SIZE) -> "TfidfModel":
+        """Compute the IDF of the n-grams of the documents, each given as its normalized token parts"""
+        model = cls(ngram_size)
+        document_frequencies: Counter = Counter()
+        for parts in documents:
+            model.document_count += 1
+            document_frequencies.update(model.ngrams(parts).keys())
+
+        model.idf = {
+            key: math.log((1 + model.document_count) / (1 + frequency)) + 1
+            for key, frequency in document_frequencies.items()
+        }
+        return model
+
+    def ngrams(self, parts: Sequence[str]) -> Counter:
+        """Count the n-grams of the token parts, by key"""
+        return Counter(
+            self._key(parts[index : index + self.ngram_size]) for index in range(len(parts) - self.ngram_size + 1)
+        )
+
+    @staticmethod
+    def _key(ngram: Sequence[str]) -> str:
+        return hashlib.sha1(" | ".join(ngram).encode("utf8")).hexdigest()[:NGRAM_KEY_LENGTH]
+
+    def vector(self, parts: Sequence[str]) -> Dict[str, float]:
+        """Get the TF-IDF vector of the token parts, the n-grams unknown to the model weighing as the rarest ones"""
+        unknown_idf = math.log(1 + self.document_count) + 1
+        return {key: count * self.idf.get(key, unknown_idf) for key, count in self.ngrams(parts).items()}
+
+    @staticmethod
+    def cosine(vector1: Dict[str, float], vector2: Dict[str, float]) -> float:
+        """Cosine similarity of two TF-IDF vectors, 0 when one of them is empty"""
+        norm1 = math.sqrt(sum(weight * weight for weight in vector1.values()))
+        norm2 = math.sqrt(sum(weight * weight for weight in vector2.values()))
+        if not norm1 or not norm2:
+            return 0.0
+        dot_product = sum(weight * vector2.get(key, 0.0) for key, weight in vector1.items())
+        return min(1.0, dot_product / (norm1 * norm2))
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            "ngram_size": self.ngram_size,
+            "document_count": self.document_count,
+            "idf": {key: round(value, 6) for key, value in sorted(self.idf.items())},
+        }
+
+    @classmethod
+    def from_dict(cls, data: Dict[str, Any]) -> "TfidfModel":
+        return cls(data["ngram_size"], data["document_count"], dict(data["idf"]))
@@ -0,0 +1,218 @@
+from typing import Any, Dict, List, Tuple
+
+# Shared alphabet of the cross-language comparison: the token kinds of every grammar mapped to their category
+ABSTRACT_CATEGORIES: Dict[str, Tuple[str, ...]] = {
+    "KEYWORD_LOOP": (
+        "for_statement",
+        "async_for_statement",
+        "while_statement",
+        "do_statement",
+        "for_in_statement",
+        "enhanced_for_statement",
+        "for_range_loop",
+        "for_expression",
+        "while_expression",
+        "loop_expression",
+        "for",
+        "while",
+        "until",
+    ),
+    "KEYWORD_COND": (
+        "if_statement",
+        "elif_clause",
+        "if_expression",
+        "conditional_expression",
+        "ternary_expression",
+        "switch_statement",
+        "expression_switch_statement",
+        "type_switch_statement",
+        "select_statement",
+        "match_statement",
+        "match_expression",
+        "if",
+        "unless",
+        "case",
+    ),
+    "KEYWORD_CASE": (
+        "case_clause",
+        "expression_case",
+        "type_case",
+        "communication_case",
+        "default_case",
+        "switch_case",
+        "switch_block_statement_group",
+        "match_arm",
+        "when",
+    ),
+    "KEYWORD_RETURN": ("return_statement", "return_expression", "yield_statement", "return"),
+    "KEYWORD_JUMP": ("break_statement", "continue_statement", "break_expression", "continue_expression"),
+    "KEYWORD_TRY": ("try_statement", "except_clause", "catch_clause", "finally_clause", "rescue", "ensure"),
+    "FUNC_DEF": (
+        "function_definition",
+        "async_function_definition",
+        "function_declaration",
+        "method_declaration",
+        "method_definition",
+        "constructor_declaration",
+        "function_item",
+        "method",
+    ),
+    "LAMBDA": ("lambda", "func_literal", "arrow_function", "function_expression", "closure_expression"),
+    "TYPE_DEF": (
+        "class_definition",
+        "class_declaration",
+        "interface_declaration",
+        "enum_declaration",
+        "type_spec",
+        "struct_item",
+        "trait_item",
+        "struct_specifier",
+        "class_specifier",
+        "class",
+    ),
+    "CALL": ("call", "call_expression", "method_invocation", "object_creation_expression", "send_statement"),
+    "ATTRIBUTE": ("attribute", "selector_expression", "member_expression", "field_access", "field_expression"),
+    "INDEX": (
+        "subscript",
+        "index_expression",
+        "slice_expression",
+        "subscript_expression",
+        "array_access",
+        "element_reference",
+    ),
+    "OP_ASSIGN": (
+        "assignment",
+        "augmented_assignment",
+        "assignment_statement",
+        "short_var_declaration",
+        "var_declaration",
+        "const_declaration",
+        "assignment_expression",
+        "augmented_assignment_expression",
+        "local_variable_declaration",
+        "lexical_declaration",
+        "let_declaration",
+        "inc_statement",
+        "dec_statement",
+        "update_expression",
+        "operator_assignment",
+    ),
+    "OP_BINARY": ("binary_operator", "binary_expression", "comparison_operator", "boolean_operator", "binary"),
+    "OP_UNARY": ("unary_operator", "unary_expression", "not_operator", "unary"),
+    "IDENT": ("identifier", "field_identifier", "property_identifier", "shorthand_property_identifier"),
+    "LITERAL": (
+        "string",
+        "f_string",
+        "concatenated_string",
+        "interpreted_string_literal",
+        "raw_string_literal",
+        "string_literal",
+        "template_string",
+        "char_literal",
+        "rune_literal",
+        "integer",
+        "float",
+        "number",
+        "int_literal",
+        "float_literal",
+        "integer_literal",
+        "decimal_integer_literal",
+        "decimal_floating_point_literal",
+        "boolean",
+        "boolean_literal",
+        "true",
+        "false",
+        "none",
+        "nil",
+        "null",
+        "null_literal",
+    ),
+    "COLLECTION": (
+        "list",
+        "dictionary",
+        "set",
+        "tuple",
+        "list_comprehension",
+        "dictionary_comprehension",
+        "set_comprehension",
+        "composite_literal",
+        "array",
+        "object",
+        "hash",
+        "array_expression",
+    ),
+    "BLOCK_OPEN": (
+        "block",
+        "statement_block",
+        "compound_statement",
+        "class_body",
+        "field_declaration_list",
+        "declaration_list",
+        "body_statement",
+        "do_block",
+    ),
+}
+CATEGORY_BY_TYPE = {token_type: category for category, types in ABSTRACT_CATEGORIES.items() for token_type in types}
+BLOCK_OPEN = "BLOCK_OPEN"
+BLOCK_CLOSE = "BLOCK_CLOSE"
+
+
+class TokenAbstractor:
+    """
+    Map the token stream of any language to the shared alphabet of ABSTRACT_CATEGORIES, so that a solution
+    transliterated to another language yields the same stream: loops, conditions, calls and assignments keep
+    their category, identifiers and literals collapse to IDENT and LITERAL.
+
+    Tokens of no category (expression wrappers, argument lists, type annotations Go declares and Python omits,
+    comments, imports) are dropped. Blocks become BLOCK_OPEN, and BLOCK_CLOSE is emitted before the first token
+    following the end of the block, the tree walk having no token for the end of a node.
+    """
+
+    def abstract(self, tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Get the abstract token stream, the tokens keeping their positions (and file)"""
+        abstract_tokens: List[Dict[str, Any]] = []
+        open_blocks: List[Dict[str, Any]] = []
+        for token in tokens:
+            while open_blocks and self._closed_by(open_blocks[-1], token):
+                abstract_tokens.append(self._close(open_blocks.pop()))
+
+            category = CATEGORY_BY_TYPE.get(token.get("type", ""))
+            if not category:
+                continue
+            abstract_token = {
+                "type": category,
+                "text": category,
+                "start": token.get("start"),
+                "end": token.get("end"),
+                "start_column": token.get("start_column"),
+                "end_column": token.get("end_column"),
+            }
+            if "file" in token:
+                abstract_token["file"] = token["file"]
+            abstract_tokens.append(abstract_token)
+            if category == BLOCK_OPEN:
+                open_blocks.append(abstract_token)
+
+        while open_blocks:
+            abstract_tokens.append(self._close(open_blocks.pop()))
+        return abstract_tokens
+
+    @staticmethod
+    def _closed_by(block: Dict[str, Any], token: Dict[str, Any]) -> bool:
+        """Whether a token follows the end of a block (or belongs to another file of the submission)"""
+        if block.get("file") != token.get("file"):
+            return True
+        if block.get("end_column") is None or token.get("start_column") is None:
+            # Tokens without columns: only those of the following lines are known to be out of the block
+            return (token.get("start") or 0) > (block.get("end") or 0)
+        return (token.get("start") or 0, token["start_column"]) >= (block.get("end") or 0, block["end_column"])
+
+    @staticmethod
+    def _close(block: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            **block,
+            "type": BLOCK_CLOSE,
+            "text": BLOCK_CLOSE,
+            "start": block.get("end"),
+            "start_column": block.get("end_column"),
+        }
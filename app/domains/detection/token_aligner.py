@@ -0,0 +1,145 @@
+import base64
+import hashlib
+import json
+from difflib import SequenceMatcher
+from typing import Any, Dict, List, Optional, Sequence
+
+# Operations of an alignment, from the first file to the second
+MATCH = "match"
+DELETION = "deletion"
+INSERTION = "insertion"
+
+# Operations of a page of an alignment, unless requested otherwise
+DEFAULT_OPERATION_LIMIT = 200
+
+
+class TokenAligner:
+    """
+    Align two files token by token, as a diff from the first file to the second: the runs of matched tokens, and
+    the runs of tokens only in the first file (deletions) or only in the second (insertions). The tokens are those
+    the detection compares, prepared with its options, and are aligned by the signature part the detection compares
+    them by, so that the alignment shows what was scored. Each operation is located in both files by the indexes of
+    its tokens in the compared streams (an empty range where the other file has nothing) and, for the file holding
+    its tokens, by the lines and columns of the original file.
+
+    An alignment of large files is served by pages of operations, each with the cursor of the next one: the cursor
+    holds the digest of both streams, so that a cursor of another alignment (or of files changed since) is refused.
+    """
+
+    def align(
+        self,
+        parts1: Sequence[str],
+        parts2: Sequence[str],
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+    ) -> List[Dict[str, Any]]:
+        """Get the operations aligning the compared tokens of the first file with those of the second, in order"""
+        operations = []
+        matcher = SequenceMatcher(None, list(parts1), list(parts2), autojunk=False)
+        for tag, start1, end1, start2, end2 in matcher.get_opcodes():
+            if tag == "equal":
+                operations.append(self._operation(MATCH, tokens1, start1, end1, tokens2, start2, end2, parts1))
+                continue
+            # A replacement is the deletion of the tokens of the first file, then the insertion of the others
+            if tag in ("delete", "replace"):
+                operations.append(self._operation(DELETION, tokens1, start1, end1, tokens2, start2, start2, parts1))
+            if tag in ("insert", "replace"):
+                operations.append(self._operation(INSERTION, tokens1, end1, end1, tokens2, start2, end2, parts2))
+        return operations
+
+    @staticmethod
+    def summary(operations: List[Dict[str, Any]], token_count1: int, token_count2: int) -> Dict[str, Any]:
+        """Counts of the matched, deleted and inserted tokens, and the share of the tokens of both files matched"""
+        counts = {operation: 0 for operation in (MATCH, DELETION, INSERTION)}
+        for operation in operations:
+            counts[operation["operation"]] += operation["token_count"]
+        total = token_count1 + token_count2
+        return {
+            "matched_token_count": counts[MATCH],
+            "deleted_token_count": counts[DELETION],
+            "inserted_token_count": counts[INSERTION],
+            "matched_share": round(2 * counts[MATCH] / total, 4) if total else 0.0,
+        }
+
+    @staticmethod
+    def digest(parts1: Sequence[str], parts2: Sequence[str]) -> str:
+        """Digest of the compared streams of both files, which the cursors of their alignment hold"""
+        content = json.dumps([list(parts1), list(parts2)], ensure_ascii=False).encode("utf-8")
+        return hashlib.sha256(content).hexdigest()[:16]
+
+    def page(
+        self,
+        operations: List[Dict[str, Any]],
+        digest: str,
+        cursor: Optional[str] = None,
+        limit: int = DEFAULT_OPERATION_LIMIT,
+    ) -> Dict[str, Any]:
+        """
+        Get the page of the operations starting at a cursor (the first page without), with the cursor of the next
+        page if the alignment goes on
+
+        Raises:
+            ValueError: If the cursor is malformed or comes from another alignment
+        """
+        offset = self._decode_cursor(cursor, digest) if cursor else 0
+        end = offset + limit
+        return {
+            "operations": operations[offset:end],
+            "operation_offset": offset,
+            "total_operations": len(operations),
+            "truncated": end < len(operations),
+            "next_cursor": self._encode_cursor(end, digest) if end < len(operations) else None,
+        }
+
+    @staticmethod
+    def _operation(
+        operation: str,
+        tokens1: List[Dict[str, Any]],
+        start1: int,
+        end1: int,
+        tokens2: List[Dict[str, Any]],
+        start2: int,
+        end2: int,
+        parts: Sequence[str],
+    ) -> Dict[str, Any]:
+        """Operation over the given ranges of tokens of both files, with the compared parts of its tokens"""
+        start, end = (start2, end2) if operation == INSERTION else (start1, end1)
+        return {
+            "operation": operation,
+            "token_count": end - start,
+            "file1": TokenAligner._range(tokens1, start1, end1),
+            "file2": TokenAligner._range(tokens2, start2, end2),
+            "tokens": list(parts[start:end]),
+        }
+
+    @staticmethod
+    def _range(tokens: List[Dict[str, Any]], start: int, end: int) -> Dict[str, Any]:
+        """Indexes of a range of compared tokens, and its location in the original file unless empty"""
+        if start == end:
+            return {"start_index": start, "end_index": end}
+        last = max(tokens[start:end], key=lambda token: (token.get("end") or 0, token.get("end_column") or 0))
+        return {
+            "start_index": start,
+            "end_index": end,
+            "start_line": tokens[start].get("start"),
+            "start_column": tokens[start].get("start_column"),
+            "end_line": last.get("end"),
+            "end_column": last.get("end_column"),
+        }
+
+    @staticmethod
+    def _encode_cursor(offset: int, digest: str) -> str:
+        payload = json.dumps({"offset": offset, "digest": digest}).encode("utf-8")
+        return base64.urlsafe_b64encode(payload).decode("ascii").rstrip("=")
+
+    @staticmethod
+    def _decode_cursor(cursor: str, digest: str) -> int:
+        """Offset of the first operation of the page of a cursor"""
+        try:
+            payload = json.loads(base64.urlsafe_b64decode(cursor + "=" * (-len(cursor) % 4)))
+            offset = int(payload["offset"])
+        except (ValueError, KeyError, TypeError) as e:
+            raise ValueError(f"Invalid cursor: {str(e)}")
+        if payload.get("digest") != digest or offset < 0:
+            raise ValueError("The cursor comes from the alignment of other files or options, restart without cursor")
+        return offset
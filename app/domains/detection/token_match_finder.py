@@ -0,0 +1,167 @@
+from bisect import bisect_left
+from typing import Any, Dict, List, Sequence, Tuple
+
+from app.domains.detection.greedy_string_tiling import GreedyStringTiler
+
+# Minimum number of consecutive matching tokens reported as a copied block
+DEFAULT_MIN_MATCH_TOKENS = 12
+
+# Minimum number of matching tokens of a fragment highlighted to the graders
+DEFAULT_MIN_FRAGMENT_TOKENS = 12
+
+
+class TokenMatchFinder:
+    """
+    Find the maximal runs of matching tokens shared by two token streams (see GreedyStringTiler): the longest
+    common runs are marked first, then the longest ones among the remaining tokens, until no run of `min_length`
+    tokens is left. Runs are found wherever they are in both files, reordered blocks included.
+
+    The runs are located from the start of their first token to the furthest end of their tokens, split where the
+    token stream of a submission goes from one of its files to the next, and runs overlapping in both files (a
+    parent token spanning the run of its children) are merged. The positions are those of the original files, the
+    prepared tokens keeping the positions of the tokens they were normalized from.
+    """
+
+    def __init__(self, min_length: int = DEFAULT_MIN_MATCH_TOKENS):
+        self.min_length = min_length
+
+    def find(self, parts1: Sequence[str], parts2: Sequence[str]) -> List[Tuple[int, int, int]]:
+        """Get the matching runs as (first index in parts1, first index in parts2, length), in the order of parts1"""
+        return GreedyStringTiler(self.min_length).tile(parts1, parts2)
+
+    def locate(
+        self, tiles: List[Tuple[int, int, int]], tokens1: List[Dict[str, Any]], tokens2: List[Dict[str, Any]]
+    ) -> List[Dict[str, Any]]:
+        """Get the positions of the runs in both files (TokenMatchDto shape), overlapping runs merged"""
+        matches = []
+        for tile in tiles:
+            for index1, index2, length in self._split_by_file(tile, tokens1, tokens2):
+                matches.append(
+                    {
+                        "file1": self._position(tokens1[index1 : index1 + length]),
+                        "file2": self._position(tokens2[index2 : index2 + length]),
+                        "tokens": length,
+                    }
+                )
+        matches.sort(key=lambda match: self._bounds(match["file1"]))
+
+        merged: List[Dict[str, Any]] = []
+        for match in matches:
+            previous = merged[-1] if merged else None
+            overlapping = previous and self._overlap(previous["file1"], match["file1"])
+            if overlapping and self._overlap(previous["file2"], match["file2"]):
+                previous["file1"] = self._union(previous["file1"], match["file1"])
+                previous["file2"] = self._union(previous["file2"], match["file2"])
+                previous["tokens"] += match["tokens"]
+            else:
+                merged.append(match)
+        return merged
+
+    def fragments(
+        self,
+        matches: List[Dict[str, Any]],
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        min_tokens: int = DEFAULT_MIN_FRAGMENT_TOKENS,
+    ) -> List[Dict[str, Any]]:
+        """
+        Get the located matches of at least `min_tokens` tokens as fragments (FragmentDto shape), scored by the
+        share of the tokens of both regions that match: runs merged with the unmatched tokens in between, or split
+        at the end of a file, score lower than a single run covering its regions whole.
+        """
+        index1 = self._index_by_file(tokens1)
+        index2 = self._index_by_file(tokens2)
+        fragments = []
+        for match in matches:
+            if match["tokens"] < min_tokens:
+                continue
+            region_tokens = self._count_within(index1, match["file1"]) + self._count_within(index2, match["file2"])
+            fragments.append(
+                {
+                    "left": match["file1"],
+                    "right": match["file2"],
+                    "tokens": match["tokens"],
+                    "similarity": round(min(1.0, 2 * match["tokens"] / region_tokens), 3) if region_tokens else 1.0,
+                }
+            )
+        return fragments
+
+    @classmethod
+    def _index_by_file(cls, tokens: List[Dict[str, Any]]) -> Dict[str, List[Tuple[Tuple[int, int], Tuple[int, int]]]]:
+        """Start and end of the tokens of each file, sorted by start"""
+        index: Dict[str, List[Tuple[Tuple[int, int], Tuple[int, int]]]] = {}
+        for token in tokens:
+            _, start, end = cls._bounds(cls._position([token]))
+            index.setdefault(token.get("file") or "", []).append((start, end))
+        for bounds in index.values():
+            bounds.sort()
+        return index
+
+    @classmethod
+    def _count_within(cls, index: Dict[str, List[Tuple[Tuple[int, int], Tuple[int, int]]]], position: Dict) -> int:
+        """Number of tokens lying within a position"""
+        file, start, end = cls._bounds(position)
+        bounds = index.get(file, [])
+        count = 0
+        for token_start, token_end in bounds[bisect_left(bounds, (start,)) :]:
+            if token_start > end:
+                break
+            if token_end <= end:
+                count += 1
+        return count
+
+    @staticmethod
+    def _split_by_file(
+        tile: Tuple[int, int, int], tokens1: List[Dict[str, Any]], tokens2: List[Dict[str, Any]]
+    ) -> List[Tuple[int, int, int]]:
+        """Split a run spanning the end of a file and the start of the next one of a submission"""
+        index1, index2, length = tile
+        segments = []
+        start = 0
+        for offset in range(1, length + 1):
+            if offset == length or (
+                tokens1[index1 + offset].get("file") != tokens1[index1 + start].get("file")
+                or tokens2[index2 + offset].get("file") != tokens2[index2 + start].get("file")
+            ):
+                segments.append((index1 + start, index2 + start, offset - start))
+                start = offset
+        return segments
+
+    @staticmethod
+    def _position(run: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """Location of a run, from the start of its first token to the furthest end of its tokens"""
+        last = max(run, key=lambda token: (token.get("end") or 0, token.get("end_column") or 0))
+        return {
+            "file": run[0].get("file"),
+            "start_line": run[0].get("start") or 0,
+            "start_column": run[0].get("start_column"),
+            "end_line": last.get("end") or 0,
+            "end_column": last.get("end_column"),
+        }
+
+    @staticmethod
+    def _bounds(position: Dict[str, Any]) -> Tuple[str, Tuple[int, int], Tuple[int, int]]:
+        """Comparable start and end of a position, in the order of the lines then of the columns"""
+        return (
+            position["file"] or "",
+            (position["start_line"], position["start_column"] or 0),
+            (position["end_line"], position["end_column"] or 0),
+        )
+
+    @classmethod
+    def _overlap(cls, position1: Dict[str, Any], position2: Dict[str, Any]) -> bool:
+        file1, start1, end1 = cls._bounds(position1)
+        file2, start2, end2 = cls._bounds(position2)
+        return file1 == file2 and start1 < end2 and start2 < end1
+
+    @classmethod
+    def _union(cls, position1: Dict[str, Any], position2: Dict[str, Any]) -> Dict[str, Any]:
+        start = min(position1, position2, key=lambda position: cls._bounds(position)[1])
+        end = max(position1, position2, key=lambda position: cls._bounds(position)[2])
+        return {
+            "file": position1["file"],
+            "start_line": start["start_line"],
+            "start_column": start["start_column"],
+            "end_line": end["end_line"],
+            "end_column": end["end_column"],
+        }
@@ -0,0 +1,127 @@
+import hashlib
+from collections import deque
+from dataclasses import dataclass
+from typing import Any, Deque, Dict, Iterable, Iterator, List, Optional, Sequence, Tuple
+
+# Number of consecutive tokens hashed together (noise threshold: shorter shared runs are ignored)
+DEFAULT_KGRAM_SIZE = 5
+# Number of consecutive k-gram hashes a fingerprint is selected from: any shared run of at least
+# DEFAULT_KGRAM_SIZE + DEFAULT_WINDOW_SIZE - 1 tokens is guaranteed to be detected
+DEFAULT_WINDOW_SIZE = 4
+
+
+@dataclass
+class Fingerprint:
+    """Selected k-gram hash, with the position of its first token in the compared stream and its lines"""
+
+    hash: int
+    position: int
+    start: int = 0
+    end: int = 0
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {"hash": f"{self.hash:016x}", "position": self.position, "start": self.start, "end": self.end}
+
+
+class Winnower:
+    """
+    Select the document fingerprints of a token stream with the winnowing algorithm (Schleimer, Wilkerson and
+    Aiken, 2003): the stream is hashed as its runs of `kgram_size` consecutive tokens, and only the minimum hash of
+    each window of `window_size` consecutive hashes is kept (the rightmost one on ties), each selected hash being
+    recorded once.
+
+    A window of size 1 selects every k-gram hash, the exhaustive method.
+    """
+
+    def __init__(self, kgram_size: int = DEFAULT_KGRAM_SIZE, window_size: int = DEFAULT_WINDOW_SIZE):
+        self.kgram_size = kgram_size
+        self.window_size = window_size
+
+    def fingerprint(self, parts: Sequence[str], tokens: Optional[Sequence[Dict[str, Any]]] = None) -> List[Fingerprint]:
+        """Get the fingerprints of the normalized token parts, located with the tokens they were computed from"""
+        hashes = self.kgram_hashes(parts)
+        fingerprints = []
+        for position in self.select(hashes):
+            fingerprint = Fingerprint(hash=hashes[position], position=position)
+            if tokens:
+                kgram = tokens[position : position + self.kgram_size]
+                fingerprint.start = kgram[0].get("start") or 0
+                fingerprint.end = max(token.get("end") or 0 for token in kgram)
+            fingerprints.append(fingerprint)
+        return fingerprints
+
+    def fingerprint_stream(self, parts_and_tokens: Iterable[Tuple[str, Dict[str, Any]]]) -> Iterator[Fingerprint]:
+        """
+        Get the fingerprints of a stream of normalized token parts with their token, as `fingerprint` does, while
+        holding only the last k-gram and window in memory, so that a large file is fingerprinted as it is tokenized
+        """
+        kgram: Deque[Tuple[bytes, Dict[str, Any]]] = deque(maxlen=self.kgram_size)
+        window: Deque[Fingerprint] = deque(maxlen=self.window_size)
+        last_selected = None
+        position = -1
+        evaluated = False
+        for position, (part, token) in enumerate(parts_and_tokens):
+            kgram.append((hashlib.sha1(part.encode("utf8")).digest(), token))
+            if len(kgram) < self.kgram_size:
+                continue
+            window.append(self._kgram_fingerprint(kgram, position - self.kgram_size + 1))
+            if len(window) == self.window_size:
+                evaluated = True
+                selected = self._window_minimum(window)
+                if selected is not last_selected:
+                    last_selected = selected
+                    yield selected
+
+        # A stream shorter than a k-gram is a single run, and a run of hashes shorter than a window a single window
+        if 0 <= position < self.kgram_size - 1:
+            window.append(self._kgram_fingerprint(kgram, 0))
+        if window and not evaluated:
+            yield self._window_minimum(window)
+
+    @staticmethod
+    def _kgram_fingerprint(kgram: Iterable[Tuple[bytes, Dict[str, Any]]], position: int) -> Fingerprint:
+        """Hash a k-gram of part hashes, located with its tokens"""
+        part_hashes, tokens = zip(*kgram)
+        return Fingerprint(
+            hash=int.from_bytes(hashlib.sha1(b"".join(part_hashes)).digest()[:8], "big"),
+            position=position,
+            start=tokens[0].get("start") or 0,
+            end=max(token.get("end") or 0 for token in tokens),
+        )
+
+    @staticmethod
+    def _window_minimum(window: Iterable[Fingerprint]) -> Fingerprint:
+        """Fingerprint of the minimum hash of a window, the rightmost one on ties"""
+        selected = None
+        for fingerprint in window:
+            if selected is None or fingerprint.hash <= selected.hash:
+                selected = fingerprint
+        return selected
+
+    def kgram_hashes(self, parts: Sequence[str]) -> List[int]:
+        """Hash every run of consecutive parts (a single run when the stream is shorter than a k-gram)"""
+        part_hashes = [hashlib.sha1(part.encode("utf8")).digest() for part in parts]
+        return [
+            int.from_bytes(hashlib.sha1(b"".join(part_hashes[index : index + self.kgram_size])).digest()[:8], "big")
+            for index in range(max(len(part_hashes) - self.kgram_size + 1, 1 if part_hashes else 0))
+        ]
+
+    def select(self, hashes: List[int]) -> List[int]:
+        """Get the positions of the minimum hash of each window, the rightmost one on ties"""
+        selected: List[int] = []
+        for window_start in range(max(len(hashes) - self.window_size + 1, 1 if hashes else 0)):
+            window = hashes[window_start : window_start + self.window_size]
+            minimum = min(window)
+            position = window_start + max(index for index, value in enumerate(window) if value == minimum)
+            if not selected or selected[-1] != position:
+                selected.append(position)
+        return selected
+
+    @staticmethod
+    def similarity(fingerprints1: List[Fingerprint], fingerprints2: List[Fingerprint]) -> float:
+        """Jaccard similarity of the selected hashes of two documents"""
+        hashes1 = {fingerprint.hash for fingerprint in fingerprints1}
+        hashes2 = {fingerprint.hash for fingerprint in fingerprints2}
+        if not hashes1 and not hashes2:
+            return 1.0
+        return len(hashes1 & hashes2) / len(hashes1 | hashes2)
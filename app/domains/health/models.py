@@ -1,4 +1,5 @@
 from datetime import datetime
+from typing import List, Optional
 
 from sqlmodel import SQLModel
 
@@ -20,6 +21,29 @@ class DatabaseHealth(SQLModel):
     query_test_passed: bool
 
 
+class AnalysisQueueHealth(SQLModel):
+    """Load of the workers analyzing and comparing the submissions"""
+
+    worker_count: int
+    busy_workers: int
+    queue_depth: int
+    queue_capacity: int
+    backpressure: str
+    rejected_count: int
+    draining: bool = False
+
+
+class StorageHealth(SQLModel):
+    """Integrity of the stored files, from the last scan against their checksums"""
+
+    backend: str
+    scanned_at: Optional[datetime] = None
+    checked_count: int = 0
+    missing: List[str] = []
+    corrupted: List[str] = []
+    unverified_count: int = 0
+
+
 class ServiceHealth(SQLModel):
     """Overall service health information"""
 
@@ -27,3 +51,4 @@ class ServiceHealth(SQLModel):
     database: DatabaseHealth
     uptime_seconds: float
     memory_usage_mb: float
+    analysis_queue: AnalysisQueueHealth
@@ -0,0 +1,113 @@
+import logging
+import threading
+import time
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+# Check of a dependency, raising if it is down
+DependencyCheck = Callable[[], None]
+
+
+def check_database() -> None:
+    """The database answers a query, on a session of its own"""
+    from sqlmodel import text
+
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        session.exec(text("SELECT 1")).first()
+    finally:
+        session.close()
+
+
+def check_storage() -> None:
+    """The backend of the object storage can be read and written"""
+    from app.shared.services import get_object_storage
+
+    get_object_storage().check_reachable()
+
+
+def check_analysis_workers() -> None:
+    """The analysis workers accept jobs: they are not draining, nor is their queue full when it rejects the jobs"""
+    from app.domains.submissions.analysis_worker_pool import BackpressurePolicy
+    from app.shared.services import get_analysis_worker_pool
+
+    stats = get_analysis_worker_pool().stats()
+    if stats["draining"]:
+        raise RuntimeError("the analysis workers are draining")
+    if stats["backpressure"] == BackpressurePolicy.REJECT.value and stats["queue_depth"] >= stats["queue_capacity"]:
+        raise RuntimeError(f"the analysis queue is full ({stats['queue_depth']} jobs)")
+
+
+DEPENDENCY_CHECKS: Dict[str, DependencyCheck] = {
+    "database": check_database,
+    "storage": check_storage,
+    "analysis_workers": check_analysis_workers,
+}
+
+
+class ReadinessProbe:
+    """
+    Readiness of the service to take traffic: each of its dependencies is checked, with its latency, the result
+    being cached for a few seconds so that the probes of the load balancer do not hammer the database. Once the
+    shutdown begins the service is reported not ready at once, without any check, so that the load balancer stops
+    sending it traffic while the workers drain.
+    """
+
+    def __init__(
+        self,
+        checks: Dict[str, DependencyCheck],
+        cache_seconds: float = 2.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.checks = checks
+        self.cache_seconds = cache_seconds
+        self.clock = clock
+        self._lock = threading.Lock()
+        self._result: Optional[Dict[str, Any]] = None
+        self._checked_at = 0.0
+        self._shutting_down = False
+
+    def mark_shutting_down(self) -> None:
+        self._shutting_down = True
+        logger.info("Reporting the service not ready, the shutdown having begun")
+
+    def check(self) -> Dict[str, Any]:
+        """Status of the service (ready or not_ready) and of each dependency (up or down), with its latency"""
+        if self._shutting_down:
+            return {"status": "not_ready", "shutting_down": True, "checked_at": _now(), "dependencies": {}}
+        # Concurrent probes wait for the checks under way rather than running their own
+        with self._lock:
+            if self._result is None or self.clock() - self._checked_at >= self.cache_seconds:
+                self._result = self._run_checks()
+                self._checked_at = self.clock()
+            return self._result
+
+    def _run_checks(self) -> Dict[str, Any]:
+        dependencies = {}
+        for name, check in self.checks.items():
+            started = self.clock()
+            try:
+                check()
+                dependencies[name] = {"status": "up", "latency_ms": _elapsed_ms(self.clock, started)}
+            except Exception as e:
+                dependencies[name] = {"status": "down", "latency_ms": _elapsed_ms(self.clock, started), "error": str(e)}
+                logger.warning(f"Readiness check of {name} failed: {e}")
+        ready = all(dependency["status"] == "up" for dependency in dependencies.values())
+        return {
+            "status": "ready" if ready else "not_ready",
+            "shutting_down": False,
+            "checked_at": _now(),
+            "dependencies": dependencies,
+        }
+
+
+def _elapsed_ms(clock: Callable[[], float], started: float) -> float:
+    return round((clock() - started) * 1000, 2)
+
+
+def _now() -> str:
+    return datetime.now(timezone.utc).isoformat()
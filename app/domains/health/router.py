@@ -4,11 +4,14 @@ from datetime import datetime
 import psutil
 import pytz
 from fastapi import APIRouter, Depends, HTTPException
+from fastapi.responses import JSONResponse
 from sqlmodel import Session, text
 
 from app.config.config import get_settings
-from app.domains.health.models import DatabaseHealth, HealthCheck, ServiceHealth
+from app.domains.health.models import AnalysisQueueHealth, DatabaseHealth, HealthCheck, ServiceHealth, StorageHealth
+from app.domains.repositories.storage_integrity_scan import get_last_scan
 from app.shared.database import get_session
+from app.shared.services import get_analysis_worker_pool, get_readiness_probe
 
 # Paris timezone
 PARIS_TZ = pytz.timezone("Europe/Paris")
@@ -20,6 +23,8 @@ def get_paris_time() -> datetime:
 
 
 router = APIRouter(prefix="/health", tags=["health"])
+# Probes of the orchestrator, at the root
+probes_router = APIRouter(tags=["health"])
 settings = get_settings()
 
 # Store application start time for uptime calculation
@@ -49,7 +54,7 @@ async def health_check(session: Session = Depends(get_session)):
 @router.get("/detailed", response_model=ServiceHealth)
 async def detailed_health_check(session: Session = Depends(get_session)):
     """
-    Detailed health check with system metrics
+    Detailed health check with system metrics and the load of the analysis workers
     """
     # Database health check with timing
     start_time = time.time()
@@ -74,7 +79,38 @@ async def detailed_health_check(session: Session = Depends(get_session)):
         memory_usage_mb = 0.0
 
     return ServiceHealth(
-        api_status="healthy", database=db_health, uptime_seconds=uptime_seconds, memory_usage_mb=memory_usage_mb
+        api_status="healthy",
+        database=db_health,
+        uptime_seconds=uptime_seconds,
+        memory_usage_mb=memory_usage_mb,
+        analysis_queue=AnalysisQueueHealth(**get_analysis_worker_pool().stats()),
+    )
+
+
+@router.get("/analysis-queue", response_model=AnalysisQueueHealth)
+async def analysis_queue_health():
+    """
+    Load of the analysis workers: queued jobs and busy workers, to tune their number and the queue capacity
+    """
+    return AnalysisQueueHealth(**get_analysis_worker_pool().stats())
+
+
+@router.get("/storage", response_model=StorageHealth)
+async def storage_health():
+    """
+    Integrity of the stored files from the last scan: the missing and corrupted ones, none before the first scan
+    """
+    last_scan = get_last_scan()
+    if last_scan is None:
+        return StorageHealth(backend=settings.object_storage_backend)
+    report, scanned_at = last_scan
+    return StorageHealth(
+        backend=settings.object_storage_backend,
+        scanned_at=scanned_at,
+        checked_count=report.checked_count,
+        missing=report.missing,
+        corrupted=report.corrupted,
+        unverified_count=len(report.unverified),
     )
 
 
@@ -131,3 +167,22 @@ async def database_health(session: Session = Depends(get_session)):
         }
     except Exception as e:
         return {"status": "unhealthy", "error": str(e), "timestamp": get_paris_time()}
+
+
+@probes_router.get("/healthz")
+async def healthz():
+    """
+    Liveness probe: the process answers, no dependency being checked, so that it is not restarted for an outage
+    of the database
+    """
+    return {"status": "alive"}
+
+
+@probes_router.get("/readyz")
+def readyz():
+    """
+    Readiness probe: the database, the storage backend and the analysis workers, each with its status and latency,
+    cached for a few seconds; 503 if any is down, or once the shutdown has begun
+    """
+    result = get_readiness_probe().check()
+    return JSONResponse(status_code=200 if result["status"] == "ready" else 503, content=result)
@@ -0,0 +1,507 @@
+import io
+import logging
+import stat
+import tarfile
+import zipfile
+import zlib
+from dataclasses import dataclass, field
+from enum import Enum
+from pathlib import Path, PurePosixPath
+from typing import Any, BinaryIO, Callable, Dict, Iterable, Iterator, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Signatures of the ZIP archives: local file header, empty archive, spanned archive
+ZIP_SIGNATURES = (b"PK\x03\x04", b"PK\x05\x06", b"PK\x07\x08")
+
+# Signature of the gzip streams, and magic of the POSIX and GNU tar headers (at offset 257 of the first header)
+GZIP_SIGNATURE = b"\x1f\x8b"
+TAR_MAGIC = b"ustar"
+TAR_MAGIC_OFFSET = 257
+
+# Extensions of the archives nested in an archive, stripped from the directory they are extracted to
+NESTED_ARCHIVE_EXTENSIONS = (".tar.gz", ".tgz", ".tar", ".zip")
+
+# Errors of the archive libraries on corrupted or truncated archives
+ARCHIVE_ERRORS = (zipfile.BadZipFile, tarfile.TarError, zlib.error, EOFError, OSError, NotImplementedError)
+
+# Metadata added by the archivers of the operating systems, never part of a submission
+JUNK_DIRECTORIES = {"__macosx"}
+JUNK_FILES = {".ds_store", "thumbs.db"}
+METADATA_REASON = "operating system metadata"
+# Reason an entry is skipped for a later entry at the same path, the last one winning
+DUPLICATE_REASON = "duplicate path, replaced by a later entry"
+
+# Cap of the extracted size of an archive, so that an archive bomb is not inflated
+DEFAULT_MAX_EXTRACTED_BYTES = 500_000_000
+
+# Caps of the number of entries of an archive, skipped ones and those of its nested archives included, and of the
+# ratio of the decompressed size of an entry to its compressed size, checked once an entry is large enough for a
+# high ratio to be suspicious rather than an empty or repetitive file
+DEFAULT_MAX_ENTRIES = 20_000
+DEFAULT_MAX_COMPRESSION_RATIO = 100.0
+COMPRESSION_RATIO_MIN_BYTES = 1_000_000
+
+# Bytes decompressed at a time, the limits being checked between the blocks
+READ_BLOCK_BYTES = 64 * 1024
+
+
+# Codes of the limits of the uploads, reported with the structured error of the limit exceeded
+UPLOAD_TOO_LARGE = "upload_too_large"
+EXTRACTED_SIZE_EXCEEDED = "extracted_size_exceeded"
+TOO_MANY_FILES = "too_many_files"
+FILE_TOO_LARGE = "file_too_large"
+TOO_MANY_ENTRIES = "too_many_entries"
+COMPRESSION_RATIO_EXCEEDED = "compression_ratio_exceeded"
+UNSAFE_ENTRY_PATH = "unsafe_entry_path"
+DUPLICATE_ENTRY_PATH = "duplicate_entry_path"
+CASE_COLLIDING_PATHS = "case_colliding_paths"
+
+
+class ArchiveLimitExceeded(ValueError):
+    """Raised when an upload exceeds one of its limits, the extraction being abandoned without keeping any file"""
+
+    def __init__(self, code: str, message: str, limit: Optional[int], entry: Optional[str] = None):
+        super().__init__(message)
+        self.code = code
+        self.limit = limit
+        self.entry = entry
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Structured error of the limit: its code, a message, the limit and the offending entry if any"""
+        return {"error_type": self.code, "message": str(self), "limit": self.limit, "entry": self.entry}
+
+
+class ArchiveFormat(str, Enum):
+    """Formats of the archives extracted into multi-file submissions"""
+
+    ZIP = "zip"
+    TAR = "tar"
+    TAR_GZ = "tar.gz"
+
+
+@dataclass
+class ArchiveEntry:
+    """Entry of an archive, whatever its format: the extraction only reads the entries it does not skip"""
+
+    name: str
+    size: int  # Declared by the archive, checked again as the entry is decompressed
+    open: Callable[[], BinaryIO]
+    compressed_size: Optional[int] = None  # None if the entries are compressed together, as in a gzipped tar
+    unsupported: Optional[str] = None  # Reason an entry of a kind that is never extracted is skipped
+
+
+@dataclass
+class ArchiveFile:
+    """File extracted from an archive, with its path relative to the root of the archive"""
+
+    path: str
+    content: bytes
+    archive: Optional[str] = None  # Path of the nested archive the file was extracted from
+
+
+@dataclass
+class ArchiveExtractionResult:
+    """
+    Files extracted from an archive, the entries skipped with the reason they were skipped, and the paths left out
+    by the ignore rules
+    """
+
+    files: List[ArchiveFile] = field(default_factory=list)
+    skipped_entries: List[Dict[str, str]] = field(default_factory=list)
+    ignored_paths: List[str] = field(default_factory=list)
+    extracted_bytes: int = 0
+    entry_count: int = 0
+
+    def write(self, target: Path) -> None:
+        """
+        Write the extracted files under a directory, at their relative path
+
+        Raises:
+            ArchiveLimitExceeded: If the path of a file resolves outside of the directory, nothing being written
+        """
+        root = target.resolve()
+        paths = []
+        for file in self.files:
+            path = (root / file.path).resolve()
+            if not path.is_relative_to(root):
+                raise ArchiveLimitExceeded(
+                    UNSAFE_ENTRY_PATH, f"The entry {file.path} escapes the extraction directory", None, file.path
+                )
+            paths.append(path)
+        for file, path in zip(self.files, paths):
+            path.parent.mkdir(parents=True, exist_ok=True)
+            path.write_bytes(file.content)
+
+    def to_tar_gz(self) -> bytes:
+        """Archive the files as a gzipped tar, so that a fetched tree is stored like an uploaded archive"""
+        buffer = io.BytesIO()
+        with tarfile.open(fileobj=buffer, mode="w:gz") as tar_file:
+            for file in self.files:
+                info = tarfile.TarInfo(file.path)
+                info.size = len(file.content)
+                tar_file.addfile(info, io.BytesIO(file.content))
+        return buffer.getvalue()
+
+
+class ArchiveExtractor:
+    """
+    Extract the files of a ZIP, tar or gzipped tar archive in memory, the format being recognized by its signature
+    rather than by the name or content type of the archive. Every format is read as a stream of ArchiveEntry, so
+    that they produce the same files:
+    - the relative paths of the files are preserved;
+    - the directories and the metadata of the operating systems (__MACOSX, .DS_Store, Thumbs.db) are skipped;
+    - the entries with an absolute path or a path escaping the archive, the links (symbolic links of the ZIP
+      archives included), the device nodes, the encrypted and the unreadable entries are skipped with a warning;
+    - of the entries at the same path, the last one wins, the others being skipped with a warning.
+
+    In strict mode, the archives with several entries at the same path or with paths differing only by case (merged
+    by the case-insensitive file systems, so that the analysis would depend on the host) are rejected instead.
+
+    The entries matching the ignore rules (dependencies, build outputs) are left out before they are read, neither
+    extracted nor counted in the limits but in the number of entries, their paths being listed apart.
+
+    The archives in the archive are extracted one level deep, at their path without the extension: the archives
+    nested in them are reported as skipped.
+
+    The entries over the extracted size cap are skipped, unless the limits are enforced: the extraction then stops
+    at the first entry exceeding the extracted size, the number of files or the size of a single file, or at the
+    first entry with an unsafe path, with an ArchiveLimitExceeded naming the entry.
+
+    Whatever the limits, the entries are decompressed block by block, and the extraction stops as soon as an
+    archive holds too many entries, an entry is decompressed past its declared size or the extracted size cap, or
+    its compression ratio is that of an archive bomb.
+    """
+
+    def __init__(
+        self,
+        max_extracted_bytes: int = DEFAULT_MAX_EXTRACTED_BYTES,
+        max_file_count: Optional[int] = None,
+        max_file_bytes: Optional[int] = None,
+        enforce_limits: bool = False,
+        max_entries: int = DEFAULT_MAX_ENTRIES,
+        max_compression_ratio: float = DEFAULT_MAX_COMPRESSION_RATIO,
+        strict_paths: bool = False,
+        ignored: Optional[Callable[[str, bool], bool]] = None,
+    ):
+        self.max_extracted_bytes = max_extracted_bytes
+        self.max_file_count = max_file_count
+        self.max_file_bytes = max_file_bytes
+        self.enforce_limits = enforce_limits
+        self.max_entries = max_entries
+        self.max_compression_ratio = max_compression_ratio
+        self.strict_paths = strict_paths
+        self.ignored = ignored
+
+    @staticmethod
+    def detect_format(content: bytes) -> Optional[ArchiveFormat]:
+        """Format of an archive from its signature, None if content is not an archive"""
+        if content[:4] in ZIP_SIGNATURES:
+            return ArchiveFormat.ZIP
+        if content[:2] == GZIP_SIGNATURE:
+            return ArchiveFormat.TAR_GZ
+        if content[TAR_MAGIC_OFFSET : TAR_MAGIC_OFFSET + len(TAR_MAGIC)] == TAR_MAGIC:
+            return ArchiveFormat.TAR
+        return None
+
+    @classmethod
+    def is_archive(cls, content: bytes) -> bool:
+        """Whether content is an archive of a supported format, from its signature"""
+        return cls.detect_format(content) is not None
+
+    @classmethod
+    def is_zip(cls, content: bytes) -> bool:
+        """Whether content is a ZIP archive, from its signature"""
+        return cls.detect_format(content) == ArchiveFormat.ZIP
+
+    def extract(self, content: bytes) -> ArchiveExtractionResult:
+        """
+        Extract the files of an archive, whatever its format
+
+        Raises:
+            ArchiveLimitExceeded: If the limits are enforced and the archive exceeds one of them
+            ValueError: If the content is not a valid archive of a supported format
+        """
+        archive_format = self.detect_format(content)
+        if archive_format is None:
+            raise ValueError("Unsupported archive: expected a ZIP, tar or gzipped tar archive")
+
+        result = ArchiveExtractionResult()
+        try:
+            self._extract(content, archive_format, "", None, result)
+        except ARCHIVE_ERRORS as e:
+            raise ValueError(f"Invalid {archive_format.value} archive: {str(e)}")
+        self._resolve_duplicates(result)
+        self._check_case_collisions(result.files)
+
+        for entry in result.skipped_entries:
+            level = logging.DEBUG if entry["reason"] == METADATA_REASON else logging.WARNING
+            logger.log(level, f"Skipped archive entry {entry['path']}: {entry['reason']}")
+        logger.debug(f"Extracted {len(result.files)} files, skipped {len(result.skipped_entries)} entries")
+        return result
+
+    def _extract(
+        self,
+        content: bytes,
+        archive_format: ArchiveFormat,
+        prefix: str,
+        archive: Optional[str],
+        result: ArchiveExtractionResult,
+    ) -> None:
+        """Extract the entries of an archive under a prefix, the nested archives of the top level archive too"""
+        archive_extracted_bytes = 0
+        for entry in self._entries(content, archive_format):
+            result.entry_count += 1
+            self._check_entry_count(result.entry_count, prefix + "/" + entry.name if prefix else entry.name)
+            relative_path = self._normalize(entry.name)
+            path = str(PurePosixPath(prefix, relative_path)) if prefix and relative_path else relative_path
+            reason = self._skip_reason(entry, relative_path, result)
+            if reason:
+                if self.enforce_limits and reason in ("absolute path", "path outside of the archive"):
+                    raise ArchiveLimitExceeded(
+                        UNSAFE_ENTRY_PATH, f"The entry {entry.name} has an unsafe path: {reason}", None, entry.name
+                    )
+                result.skipped_entries.append({"path": path or entry.name, "reason": reason})
+                continue
+            if self.ignored is not None and self.ignored(path, False):
+                result.ignored_paths.append(path)
+                continue
+
+            self._check_entry_size(path, entry.size, result)
+            try:
+                data = self._read_entry(path, entry, result, len(content), archive_extracted_bytes)
+            except ARCHIVE_ERRORS as e:
+                result.skipped_entries.append({"path": path, "reason": f"unreadable entry: {str(e)}"})
+                continue
+            # The sizes declared by the archive are checked again against the read data
+            self._check_entry_size(path, len(data), result)
+            result.extracted_bytes += len(data)
+            archive_extracted_bytes += len(data)
+
+            nested_format = self.detect_format(data) if path.lower().endswith(NESTED_ARCHIVE_EXTENSIONS) else None
+            if nested_format is None:
+                self.check_file_count(len(result.files) + 1, path)
+                result.files.append(ArchiveFile(path=path, content=data, archive=archive))
+            elif archive is not None:
+                result.skipped_entries.append({"path": path, "reason": "archive nested more than one level"})
+            else:
+                extension = next(ext for ext in NESTED_ARCHIVE_EXTENSIONS if path.lower().endswith(ext))
+                try:
+                    self._extract(data, nested_format, path[: -len(extension)], path, result)
+                except ARCHIVE_ERRORS as e:
+                    result.skipped_entries.append({"path": path, "reason": f"invalid nested archive: {str(e)}"})
+
+    def _read_entry(
+        self,
+        path: str,
+        entry: ArchiveEntry,
+        result: ArchiveExtractionResult,
+        archive_bytes: int,
+        archive_extracted_bytes: int,
+    ) -> bytes:
+        """
+        Decompress an entry block by block, stopping as soon as it exceeds its declared size, the extracted size cap
+        or the compression ratio: of the entry if compressed alone, of the archive so far otherwise
+        """
+        blocks = []
+        read_bytes = 0
+        with entry.open() as stream:
+            while block := stream.read(READ_BLOCK_BYTES):
+                read_bytes += len(block)
+                if read_bytes > entry.size or result.extracted_bytes + read_bytes > self.max_extracted_bytes:
+                    raise ArchiveLimitExceeded(
+                        EXTRACTED_SIZE_EXCEEDED,
+                        f"The entry {path} decompresses past its declared size or the extracted size cap",
+                        self.max_extracted_bytes,
+                        path,
+                    )
+                if entry.compressed_size is not None:
+                    compressed, decompressed = entry.compressed_size, read_bytes
+                else:
+                    compressed, decompressed = archive_bytes, archive_extracted_bytes + read_bytes
+                if self._is_bomb_ratio(compressed, decompressed):
+                    raise ArchiveLimitExceeded(
+                        COMPRESSION_RATIO_EXCEEDED,
+                        f"The entry {path} decompresses over {self.max_compression_ratio:g} times its compressed size",
+                        int(self.max_compression_ratio),
+                        path,
+                    )
+                blocks.append(block)
+        return b"".join(blocks)
+
+    def _is_bomb_ratio(self, compressed: int, decompressed: int) -> bool:
+        """Whether bytes decompressed from compressed ones have the ratio of an archive bomb"""
+        return decompressed >= COMPRESSION_RATIO_MIN_BYTES and decompressed > self.max_compression_ratio * compressed
+
+    def _check_entry_count(self, count: int, name: str) -> None:
+        """Enforce the limit of the number of entries, the entry of the name being the count-th one"""
+        if count > self.max_entries:
+            raise ArchiveLimitExceeded(
+                TOO_MANY_ENTRIES, f"The archive holds more than {self.max_entries} entries", self.max_entries, name
+            )
+
+    def _resolve_duplicates(self, result: ArchiveExtractionResult) -> None:
+        """
+        Keep the last of the files extracted at the same path, the others being skipped
+
+        Raises:
+            ArchiveLimitExceeded: In strict mode, at the first path of several files
+        """
+        last_indexes = {file.path: index for index, file in enumerate(result.files)}
+        if len(last_indexes) == len(result.files):
+            return
+        files = []
+        for index, file in enumerate(result.files):
+            if last_indexes[file.path] == index:
+                files.append(file)
+                continue
+            if self.strict_paths:
+                raise ArchiveLimitExceeded(
+                    DUPLICATE_ENTRY_PATH, f"The archive holds several entries at {file.path}", None, file.path
+                )
+            result.skipped_entries.append({"path": file.path, "reason": DUPLICATE_REASON})
+        result.files = files
+
+    def _check_case_collisions(self, files: List[ArchiveFile]) -> None:
+        """
+        Enforce the uniqueness of the paths regardless of case in strict mode
+
+        Raises:
+            ArchiveLimitExceeded: At the first paths differing only by case
+        """
+        if not self.strict_paths:
+            return
+        collisions = case_colliding_paths(file.path for file in files)
+        if collisions:
+            paths = collisions[0]
+            raise ArchiveLimitExceeded(
+                CASE_COLLIDING_PATHS, f"The paths {', '.join(paths)} differ only by case", None, paths[-1]
+            )
+
+    def check_files(self, files: List[ArchiveFile]) -> None:
+        """
+        Enforce the limits on files already extracted
+
+        Raises:
+            ArchiveLimitExceeded: If the files exceed one of the limits
+        """
+        extracted = ArchiveExtractionResult()
+        for count, file in enumerate(files, start=1):
+            self._check_entry_size(file.path, len(file.content), extracted)
+            self.check_file_count(count, file.path)
+            extracted.extracted_bytes += len(file.content)
+        self._check_case_collisions(files)
+
+    def check_file_count(self, count: int, path: str) -> None:
+        """Enforce the limit of the number of files, the file at the path being the count-th one"""
+        if self.enforce_limits and self.max_file_count is not None and count > self.max_file_count:
+            raise ArchiveLimitExceeded(
+                TOO_MANY_FILES, f"The upload holds more than {self.max_file_count} files", self.max_file_count, path
+            )
+
+    def _check_entry_size(self, path: str, size: int, result: ArchiveExtractionResult) -> None:
+        """Enforce the limits of the size of a file and of the extracted size"""
+        if not self.enforce_limits:
+            return
+        if self.max_file_bytes is not None and size > self.max_file_bytes:
+            raise ArchiveLimitExceeded(
+                FILE_TOO_LARGE, f"The file {path} exceeds {self.max_file_bytes} bytes", self.max_file_bytes, path
+            )
+        if result.extracted_bytes + size > self.max_extracted_bytes:
+            raise ArchiveLimitExceeded(
+                EXTRACTED_SIZE_EXCEEDED,
+                f"The extracted upload exceeds {self.max_extracted_bytes} bytes at {path}",
+                self.max_extracted_bytes,
+                path,
+            )
+
+    def _entries(self, content: bytes, archive_format: ArchiveFormat) -> Iterator[ArchiveEntry]:
+        """Entries of an archive other than its directories, in the order of the archive"""
+        if archive_format == ArchiveFormat.ZIP:
+            return self._zip_entries(content)
+        return self._tar_entries(content)
+
+    def _zip_entries(self, content: bytes) -> Iterator[ArchiveEntry]:
+        """Entries of a ZIP archive, read from its central directory"""
+        with zipfile.ZipFile(io.BytesIO(content)) as zip_file:
+            infos = zip_file.infolist()
+            # The central directory tells the number of entries before any is decompressed
+            files = [info for info in infos if not info.is_dir()]
+            if len(files) > self.max_entries:
+                self._check_entry_count(len(files), files[self.max_entries].filename)
+            for info in infos:
+                if info.is_dir():
+                    continue
+                if info.flag_bits & 0x1:
+                    unsupported = "encrypted entry"
+                # The Unix mode of the entries archived on Unix is kept in the high bytes of their external attributes
+                elif stat.S_ISLNK(info.external_attr >> 16):
+                    unsupported = "symbolic link"
+                else:
+                    unsupported = None
+                yield ArchiveEntry(
+                    name=info.filename,
+                    size=info.file_size,
+                    open=lambda info=info: zip_file.open(info),
+                    compressed_size=info.compress_size,
+                    unsupported=unsupported,
+                )
+
+    @staticmethod
+    def _tar_entries(content: bytes) -> Iterator[ArchiveEntry]:
+        """Entries of a tar archive, decompressed as a stream: an entry is read before the next one is yielded"""
+        with tarfile.open(fileobj=io.BytesIO(content), mode="r|*") as tar_file:
+            for member in tar_file:
+                if member.isdir():
+                    continue
+                if member.islnk():
+                    unsupported = "hard link"
+                elif member.issym():
+                    unsupported = "symbolic link"
+                elif member.ischr() or member.isblk() or member.isfifo():
+                    unsupported = "device node"
+                elif not member.isfile():
+                    unsupported = "unsupported entry type"
+                else:
+                    unsupported = None
+                yield ArchiveEntry(
+                    name=member.name,
+                    size=member.size,
+                    open=lambda member=member: tar_file.extractfile(member),
+                    unsupported=unsupported,
+                )
+
+    def _skip_reason(
+        self, entry: ArchiveEntry, relative_path: Optional[str], result: ArchiveExtractionResult
+    ) -> Optional[str]:
+        """Reason an entry is not extracted, None if it is"""
+        name = entry.name.replace("\\", "/")
+        if name.startswith("/") or ":" in name.split("/")[0]:
+            return "absolute path"
+        if relative_path is None:
+            return "path outside of the archive"
+        parts = relative_path.lower().split("/")
+        if JUNK_DIRECTORIES.intersection(parts[:-1]) or parts[-1] in JUNK_FILES:
+            return METADATA_REASON
+        if entry.unsupported:
+            return entry.unsupported
+        if not self.enforce_limits and result.extracted_bytes + entry.size > self.max_extracted_bytes:
+            return f"extracted size over {self.max_extracted_bytes} bytes"
+        return None
+
+    @staticmethod
+    def _normalize(name: str) -> Optional[str]:
+        """Relative path of an entry, None if it is absolute or escapes the archive"""
+        name = name.replace("\\", "/")
+        parts = [part for part in name.split("/") if part not in ("", ".")]
+        if name.startswith("/") or not parts or ".." in parts or ":" in parts[0]:
+            return None
+        return "/".join(parts)
+
+
+def case_colliding_paths(paths: Iterable[str]) -> List[List[str]]:
+    """Groups of paths differing only by case, sorted, merged into one file by the case-insensitive file systems"""
+    groups: Dict[str, List[str]] = {}
+    for path in paths:
+        groups.setdefault(path.casefold(), []).append(path)
+    return sorted(sorted(set(group)) for group in groups.values() if len(set(group)) > 1)
@@ -74,6 +74,60 @@ class GitTimeoutException(GitRepositoryException):
         )
 
 
+class GitAuthenticationException(GitRepositoryException):
+    """Raised when the credentials of a repository are missing or rejected"""
+
+    def __init__(self, url: str, source_type: str, git_error: str = None):
+        message = f"Authentication failed for {source_type} repository: {url}"
+        super().__init__(
+            message,
+            source_type,
+            url,
+            git_error,
+            details={
+                "error_type": "git_auth_failed",
+                "source_type": source_type,
+                "url": url,
+                "git_error": git_error,
+                "message": "The repository does not exist or requires a token granting read access",
+            },
+        )
+
+
+class GitRefNotFoundException(GitRepositoryException):
+    """Raised when a branch, tag or commit does not exist in a repository"""
+
+    def __init__(self, url: str, source_type: str, ref: str, git_error: str = None):
+        message = f"Ref '{ref}' not found in {source_type} repository: {url}"
+        super().__init__(
+            message,
+            source_type,
+            url,
+            git_error,
+            details={
+                "error_type": "git_ref_not_found",
+                "source_type": source_type,
+                "url": url,
+                "ref": ref,
+                "git_error": git_error,
+            },
+        )
+
+
+class GitNetworkException(GitRepositoryException):
+    """Raised when a repository cannot be reached: DNS resolution, connection or transfer failure"""
+
+    def __init__(self, url: str, source_type: str, git_error: str = None):
+        message = f"Network error reaching {source_type} repository: {url}"
+        super().__init__(
+            message,
+            source_type,
+            url,
+            git_error,
+            details={"error_type": "git_network_error", "source_type": source_type, "url": url, "git_error": git_error},
+        )
+
+
 class S3FetchException(RepositoryFetchException):
     """Base exception for S3 operations"""
 
@@ -173,6 +227,37 @@ class S3ExtractionException(S3FetchException):
         )
 
 
+class ObjectStorageException(RepositoryFetchException):
+    """Raised when an object of the storage of the service cannot be read, written or deleted"""
+
+    def __init__(self, key: str, reason: str, error_type: str = "object_storage_error"):
+        message = f"Object storage failed for '{key}': {reason}"
+        super().__init__(message, "storage", key, details={"error_type": error_type, "key": key, "reason": reason})
+        self.key = key
+
+
+class ObjectNotFoundException(ObjectStorageException):
+    """Raised when an object is not in the storage of the service"""
+
+    def __init__(self, key: str):
+        super().__init__(key, "no such object", error_type="object_not_found")
+
+
+class ObjectCorruptedException(ObjectStorageException):
+    """Raised when an object of the storage does not match the checksum recorded when it was written"""
+
+    def __init__(self, key: str):
+        super().__init__(key, "the content does not match its recorded checksum", error_type="object_corrupted")
+
+
+class ExternalSourceException(RepositoryFetchException):
+    """Raised when an external source cannot be fetched: invalid URL, denied host, too large, timed out"""
+
+    def __init__(self, url: str, reason: str, error_type: str = "external_source_error"):
+        message = f"Failed to fetch external source {url}: {reason}"
+        super().__init__(message, "url", url, details={"error_type": error_type, "url": url, "reason": reason})
+
+
 class SubmissionValidationException(ValidationException):
     """Raised when submission validation fails"""
 
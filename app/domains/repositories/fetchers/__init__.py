@@ -1,5 +1,6 @@
 from .github_fetcher import GithubFetcher
 from .gitlab_fetcher import GitlabFetcher
 from .s3_fetcher import S3Fetcher
+from .url_source_fetcher import UrlSourceFetcher
 
-__all__ = ["GithubFetcher", "GitlabFetcher", "S3Fetcher"]
+__all__ = ["GithubFetcher", "GitlabFetcher", "S3Fetcher", "UrlSourceFetcher"]
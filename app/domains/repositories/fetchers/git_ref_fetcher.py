@@ -0,0 +1,270 @@
+import base64
+import fnmatch
+import logging
+import os
+import re
+import shutil
+import subprocess
+from dataclasses import dataclass
+from pathlib import Path
+from typing import Callable, Dict, List, Optional, Set
+
+from app.domains.repositories.archive_extractor import DEFAULT_MAX_EXTRACTED_BYTES, ArchiveExtractionResult, ArchiveFile
+from app.domains.repositories.exceptions import (
+    GitAuthenticationException,
+    GitCloneException,
+    GitNetworkException,
+    GitRefNotFoundException,
+    GitTimeoutException,
+    UnsupportedRepositoryException,
+)
+
+logger = logging.getLogger(__name__)
+
+# Directories of dependencies and build outputs excluded from the ingested tree by default
+DEFAULT_IGNORE_PATTERNS = ["node_modules", "vendor", "target"]
+
+# Messages of git (and of the forges) telling why a fetch failed
+AUTH_ERRORS = (
+    "authentication failed",
+    "could not read username",
+    "terminal prompts disabled",
+    "repository not found",
+    "access denied",
+    "permission denied",
+    "403",
+)
+REF_ERRORS = ("couldn't find remote ref", "not our ref", "invalid refspec", "no such ref", "bad object")
+NETWORK_ERRORS = (
+    "could not resolve host",
+    "failed to connect",
+    "connection timed out",
+    "connection refused",
+    "operation timed out",
+    "early eof",
+    "the remote end hung up",
+)
+
+# Mode of the gitlinks, the entries of a tree pointing to the commit of a submodule
+GITLINK_MODE = "160000"
+
+# Names of the fetched refs: branches, tags and commit SHAs, never an option of git
+REF_PATTERN = re.compile(r"^[A-Za-z0-9_][A-Za-z0-9._/-]*$")
+
+
+@dataclass
+class GitRefFetchResult:
+    """Tree of a repository at a commit, with the files as an archive would produce them"""
+
+    commit_sha: str
+    extraction: ArchiveExtractionResult
+
+
+class GitRefFetcher:
+    """
+    Fetch the tree of a Git repository at a branch, tag or commit, shallowly (the only fetched commit is the one of
+    the ref), optionally limited to a subdirectory. The commit the ref resolves to is reported, so that the
+    submission can be reproduced.
+
+    A token grants access to private repositories: it is handed to git through the environment of the commands, as
+    an HTTP header, and never written to the repository configuration, the command line or the logs.
+
+    The .git internals, the submodules (never fetched, their paths excluded), the symbolic links and the paths
+    matching the ignore patterns (any path component matching one, e.g. node_modules) are excluded from the tree.
+    The paths matching the ignore rules of the project step are left out too, an ignored directory being neither
+    walked nor read, their paths being listed apart.
+    """
+
+    def __init__(
+        self,
+        timeout_seconds: int = 300,
+        ignore_patterns: Optional[List[str]] = None,
+        max_extracted_bytes: int = DEFAULT_MAX_EXTRACTED_BYTES,
+    ):
+        self.timeout_seconds = timeout_seconds
+        self.ignore_patterns = DEFAULT_IGNORE_PATTERNS if ignore_patterns is None else ignore_patterns
+        self.max_extracted_bytes = max_extracted_bytes
+
+    def fetch(
+        self,
+        repo_url: str,
+        temp_dir: str,
+        ref: Optional[str] = None,
+        subdirectory: Optional[str] = None,
+        token: Optional[str] = None,
+        ignore_patterns: Optional[List[str]] = None,
+        ignored: Optional[Callable[[str, bool], bool]] = None,
+    ) -> GitRefFetchResult:
+        """
+        Fetch the tree of a repository at a ref (the default branch if none), optionally limited to a subdirectory
+
+        Raises:
+            UnsupportedRepositoryException: If the URL is not an HTTPS URL
+            GitAuthenticationException: If the repository is private and the token is missing or rejected
+            GitRefNotFoundException: If the ref or the subdirectory does not exist
+            GitNetworkException: If the repository cannot be reached
+            GitTimeoutException: If the fetch times out
+            GitCloneException: If the fetch fails for another reason
+        """
+        clone_url = self._validate_url(repo_url)
+        if ref is not None and (not REF_PATTERN.match(ref) or ".." in ref):
+            raise GitRefNotFoundException(repo_url, "git", ref, "Invalid ref name")
+        subdirectory_path = self._validate_subdirectory(repo_url, subdirectory)
+        repo_path = Path(temp_dir) / "repository"
+        env = self._environment(token)
+
+        if repo_path.exists():
+            logger.warning(f"Destination path already exists, removing: {repo_path}")
+            shutil.rmtree(repo_path)
+
+        logger.info(f"Fetching Git repository {clone_url} at {ref or 'default branch'} -> {repo_path}")
+        self._run(["git", "init", "--quiet", str(repo_path)], repo_url, ref, env, cwd=temp_dir)
+        self._run(["git", "remote", "add", "origin", clone_url], repo_url, ref, env, cwd=repo_path)
+        self._run(
+            ["git", "fetch", "--quiet", "--depth", "1", "--no-tags", "origin", ref or "HEAD"],
+            repo_url,
+            ref,
+            env,
+            cwd=repo_path,
+        )
+        self._run(["git", "checkout", "--quiet", "--detach", "FETCH_HEAD"], repo_url, ref, env, cwd=repo_path)
+        commit_sha = self._run(["git", "rev-parse", "HEAD"], repo_url, ref, env, cwd=repo_path).strip()
+
+        root = repo_path / subdirectory_path if subdirectory_path else repo_path
+        if not root.is_dir() or root.is_symlink():
+            raise GitRefNotFoundException(
+                repo_url, "git", f"{commit_sha}:{subdirectory}", f"Subdirectory '{subdirectory}' not found"
+            )
+
+        extraction = self._collect(
+            repo_path,
+            root,
+            self._submodule_paths(repo_path, repo_url, env),
+            self.ignore_patterns if ignore_patterns is None else ignore_patterns,
+            ignored,
+        )
+        logger.info(
+            f"Fetched {len(extraction.files)} files of {clone_url} at commit {commit_sha}, "
+            f"skipped {len(extraction.skipped_entries)} entries"
+        )
+        return GitRefFetchResult(commit_sha=commit_sha, extraction=extraction)
+
+    @staticmethod
+    def _validate_url(repo_url: str) -> str:
+        """Clone URL of a repository: only HTTPS URLs are fetched, without credentials in them"""
+        url = (repo_url or "").strip()
+        if not url.lower().startswith("https://") or "@" in url.split("/")[2]:
+            raise UnsupportedRepositoryException(repo_url, ["https://host/user/repo(.git)"])
+        return url
+
+    @staticmethod
+    def _validate_subdirectory(repo_url: str, subdirectory: Optional[str]) -> Optional[str]:
+        """Relative path of the subdirectory, None for the whole repository"""
+        if not subdirectory or not subdirectory.strip("/"):
+            return None
+        parts = [part for part in subdirectory.replace("\\", "/").split("/") if part not in ("", ".")]
+        if subdirectory.startswith("/") or ".." in parts or ".git" in parts:
+            raise GitCloneException(repo_url, "git", f"Invalid subdirectory: {subdirectory}")
+        return "/".join(parts)
+
+    @staticmethod
+    def _environment(token: Optional[str]) -> Dict[str, str]:
+        """Environment of the git commands: no prompt, and the token as an HTTP header of this process only"""
+        env = {**os.environ, "GIT_TERMINAL_PROMPT": "0"}
+        if token:
+            credentials = base64.b64encode(f"x-access-token:{token}".encode("utf-8")).decode("ascii")
+            env.update(
+                {
+                    "GIT_CONFIG_COUNT": "1",
+                    "GIT_CONFIG_KEY_0": "http.extraHeader",
+                    "GIT_CONFIG_VALUE_0": f"Authorization: Basic {credentials}",
+                }
+            )
+        return env
+
+    def _run(self, cmd: List[str], repo_url: str, ref: Optional[str], env: Dict[str, str], cwd) -> str:
+        """Run a git command, raising the exception matching its failure"""
+        logger.debug(f"Executing command: {' '.join(cmd)}")
+        try:
+            result = subprocess.run(
+                cmd, capture_output=True, text=True, timeout=self.timeout_seconds, cwd=str(cwd), env=env
+            )
+        except subprocess.TimeoutExpired:
+            logger.error(f"Git command timed out after {self.timeout_seconds} seconds: {repo_url}")
+            raise GitTimeoutException(repo_url, "git", self.timeout_seconds)
+
+        if result.returncode != 0:
+            error_output = result.stderr.strip() if result.stderr else "Unknown error"
+            logger.error(f"Git command {cmd[1]} failed for {repo_url}: {error_output}")
+            error_lower = error_output.lower()
+            if any(error in error_lower for error in AUTH_ERRORS):
+                raise GitAuthenticationException(repo_url, "git", error_output)
+            if any(error in error_lower for error in NETWORK_ERRORS):
+                raise GitNetworkException(repo_url, "git", error_output)
+            if ref and (any(error in error_lower for error in REF_ERRORS) or cmd[1] == "checkout"):
+                raise GitRefNotFoundException(repo_url, "git", ref, error_output)
+            raise GitCloneException(repo_url, "git", error_output, result.returncode)
+        return result.stdout
+
+    def _submodule_paths(self, repo_path: Path, repo_url: str, env: Dict[str, str]) -> Set[str]:
+        """Paths of the submodules of the fetched commit: the gitlinks of its tree, declared or not"""
+        output = self._run(["git", "ls-files", "--stage"], repo_url, None, env, cwd=repo_path)
+        paths = set()
+        for line in output.splitlines():
+            metadata, _, path = line.partition("\t")
+            if metadata.split(" ")[0] == GITLINK_MODE:
+                paths.add(path)
+        return paths
+
+    def _collect(
+        self,
+        repo_path: Path,
+        root: Path,
+        submodules: Set[str],
+        ignore_patterns: List[str],
+        ignored: Optional[Callable[[str, bool], bool]] = None,
+    ) -> ArchiveExtractionResult:
+        """Files of the tree under root, with their path relative to it"""
+        result = ArchiveExtractionResult()
+        for current, directories, files in os.walk(root):
+            current_path = Path(current)
+            for directory in list(directories):
+                repo_relative = (current_path / directory).relative_to(repo_path).as_posix()
+                path = (current_path / directory).relative_to(root).as_posix()
+                reason = None
+                if directory == ".git":
+                    reason = "git internals"
+                elif repo_relative in submodules:
+                    reason = "submodule"
+                elif (current_path / directory).is_symlink():
+                    reason = "symbolic link"
+                elif any(fnmatch.fnmatch(directory, pattern) for pattern in ignore_patterns):
+                    reason = "ignored path"
+                elif ignored is not None and ignored(path, True):
+                    directories.remove(directory)
+                    result.ignored_paths.append(f"{path}/")
+                    continue
+                if reason:
+                    directories.remove(directory)
+                    if reason != "git internals":
+                        result.skipped_entries.append({"path": path, "reason": reason})
+            directories.sort()
+
+            for name in sorted(files):
+                file_path = current_path / name
+                path = file_path.relative_to(root).as_posix()
+                if file_path.is_symlink():
+                    result.skipped_entries.append({"path": path, "reason": "symbolic link"})
+                elif any(fnmatch.fnmatch(name, pattern) for pattern in ignore_patterns):
+                    result.skipped_entries.append({"path": path, "reason": "ignored path"})
+                elif ignored is not None and ignored(path, False):
+                    result.ignored_paths.append(path)
+                elif result.extracted_bytes + file_path.stat().st_size > self.max_extracted_bytes:
+                    result.skipped_entries.append(
+                        {"path": path, "reason": f"extracted size over {self.max_extracted_bytes} bytes"}
+                    )
+                else:
+                    content = file_path.read_bytes()
+                    result.extracted_bytes += len(content)
+                    result.files.append(ArchiveFile(path=path, content=content))
+        return result
@@ -1,7 +1,7 @@
 import logging
 import tempfile
-import zipfile
 from pathlib import Path
+from typing import Iterator
 from urllib.parse import urlparse
 
 try:
@@ -11,6 +11,7 @@ except ImportError:
     boto3 = None
 
 from app.config.config import get_settings
+from app.domains.repositories.archive_extractor import ArchiveExtractor
 from app.domains.repositories.exceptions import (
     S3BucketException,
     S3ConfigurationException,
@@ -39,6 +40,10 @@ class S3Fetcher:
             self.aws_access_key_id = settings.aws_access_key_id
             self.aws_secret_access_key = settings.aws_secret_access_key
             self.aws_default_region = settings.aws_default_region
+            self.archive_extractor = ArchiveExtractor(
+                max_entries=settings.archive_max_entries,
+                max_compression_ratio=settings.archive_max_compression_ratio,
+            )
 
             logger.debug(f"S3Fetcher initialized successfully with region: {self.aws_default_region}")
         except Exception as e:
@@ -86,34 +91,10 @@ class S3Fetcher:
                 if not Path(temp_file_path).exists() or Path(temp_file_path).stat().st_size == 0:
                     raise S3ObjectException(bucket_name, object_key, s3_url, "Downloaded file is empty or missing")
 
-                # Extract the content
-                extract_path = Path(temp_dir) / self._get_extract_folder_name(object_key)
-                try:
-                    extract_path.mkdir(parents=True, exist_ok=True)
-                    logger.debug(f"Created extraction directory: {extract_path}")
-                except OSError as e:
-                    raise S3ExtractionException(
-                        s3_url, f"Failed to create extraction directory: {str(e)}", bucket_name, object_key
-                    )
-
-                # Check if it's a zip file and extract
-                try:
-                    if self._is_zip_file(temp_file_path):
-                        self._extract_zip(temp_file_path, extract_path)
-                        logger.debug(f"Extracted ZIP file to: {extract_path}")
-
-                        # Check if we need to use a nested directory as the project root
-                        project_root = self._get_project_root_path(extract_path)
-                        if project_root != extract_path:
-                            logger.info(f"Using nested directory as project root: {project_root}")
-                            extract_path = project_root
-                    else:
-                        # If not a zip, just copy the file
-                        target_file = extract_path / Path(object_key).name
-                        target_file.write_bytes(Path(temp_file_path).read_bytes())
-                        logger.debug(f"Copied file to: {target_file}")
-                except Exception as e:
-                    raise S3ExtractionException(s3_url, str(e), bucket_name, object_key)
+                # Extract the content, an archive or a single file
+                extract_path = self.extract_content(
+                    Path(temp_file_path).read_bytes(), object_key, temp_dir, s3_url, bucket_name
+                )
 
                 logger.info(f"Successfully downloaded and extracted S3 content to: {extract_path}")
                 return extract_path
@@ -145,6 +126,111 @@ class S3Fetcher:
                 except Exception as e:
                     logger.warning(f"Failed to clean up temporary file {temp_file_path}: {str(e)}")
 
+    def extract_content(
+        self, content: bytes, object_key: str, temp_dir: str, s3_url: str, bucket_name: str = None
+    ) -> Path:
+        """
+        Extract downloaded content into the temporary directory: an archive (ZIP, tar or tar.gz, from its signature)
+        into its files, its single nested directory being the project root, any other file being copied as is
+
+        Raises:
+            S3ExtractionException: If the extraction fails
+        """
+        extract_path = Path(temp_dir) / self._get_extract_folder_name(object_key)
+        try:
+            extract_path.mkdir(parents=True, exist_ok=True)
+            logger.debug(f"Created extraction directory: {extract_path}")
+        except OSError as e:
+            raise S3ExtractionException(
+                s3_url, f"Failed to create extraction directory: {str(e)}", bucket_name, object_key
+            )
+
+        try:
+            if ArchiveExtractor.is_archive(content):
+                self._extract_archive(content, extract_path)
+                logger.debug(f"Extracted archive to: {extract_path}")
+
+                # Check if we need to use a nested directory as the project root
+                project_root = self._get_project_root_path(extract_path)
+                if project_root != extract_path:
+                    logger.info(f"Using nested directory as project root: {project_root}")
+                    extract_path = project_root
+            else:
+                # If not a zip, just copy the file
+                target_file = extract_path / Path(object_key).name
+                target_file.write_bytes(content)
+                logger.debug(f"Copied file to: {target_file}")
+        except Exception as e:
+            raise S3ExtractionException(s3_url, str(e), bucket_name, object_key)
+        return extract_path
+
+    def upload_content(self, bucket_name: str, object_key: str, content: bytes) -> str:
+        """
+        Store content in S3
+
+        Returns:
+            S3 URL of the stored object
+
+        Raises:
+            S3FetchException: If the upload fails
+        """
+        s3_url = f"s3://{bucket_name}/{object_key}"
+        try:
+            self._get_s3_client(s3_url).put_object(Bucket=bucket_name, Key=object_key, Body=content)
+            logger.info(f"Uploaded {len(content)} bytes to S3: {s3_url}")
+            return s3_url
+        except NoCredentialsError:
+            raise S3CredentialsException(s3_url)
+        except ClientError as e:
+            self._handle_s3_client_error(e, bucket_name, object_key, s3_url)
+
+    def download_content(self, s3_url: str) -> bytes:
+        """
+        Download the content of an S3 object as is, without extracting it
+
+        Raises:
+            S3FetchException: If the download fails
+        """
+        bucket_name, object_key = self._parse_s3_url(s3_url)
+        try:
+            return self._get_s3_client(s3_url).get_object(Bucket=bucket_name, Key=object_key)["Body"].read()
+        except NoCredentialsError:
+            raise S3CredentialsException(s3_url)
+        except ClientError as e:
+            self._handle_s3_client_error(e, bucket_name, object_key, s3_url)
+
+    def stream_content(self, s3_url: str, chunk_bytes: int = 64 * 1024) -> Iterator[bytes]:
+        """
+        Stream the content of an S3 object as is, chunk by chunk, the object being requested at once
+
+        Raises:
+            S3FetchException: If the object cannot be requested
+        """
+        bucket_name, object_key = self._parse_s3_url(s3_url)
+        try:
+            body = self._get_s3_client(s3_url).get_object(Bucket=bucket_name, Key=object_key)["Body"]
+        except NoCredentialsError:
+            raise S3CredentialsException(s3_url)
+        except ClientError as e:
+            self._handle_s3_client_error(e, bucket_name, object_key, s3_url)
+        return body.iter_chunks(chunk_bytes)
+
+    def delete_content(self, s3_url: str) -> None:
+        """
+        Delete an S3 object
+
+        Raises:
+            S3FetchException: If the deletion fails
+        """
+        bucket_name, object_key = self._parse_s3_url(s3_url)
+        try:
+            self._get_s3_client(s3_url).delete_object(Bucket=bucket_name, Key=object_key)
+            logger.info(f"Deleted S3 object: {s3_url}")
+        except NoCredentialsError:
+            raise S3CredentialsException(s3_url)
+        except ClientError as e:
+            self._handle_s3_client_error(e, bucket_name, object_key, s3_url)
+
     def _parse_s3_url(self, s3_url: str) -> tuple[str, str]:
         """Parse S3 URL into bucket and object key"""
         try:
@@ -218,46 +304,24 @@ class S3Fetcher:
         filename = Path(object_key).stem
         return filename if filename else "extracted_content"
 
-    def _is_zip_file(self, file_path: str) -> bool:
-        """Check if file is a ZIP file"""
+    def _extract_archive(self, content: bytes, extract_path: Path):
+        """Extract an archive to specified path, without the metadata of the operating systems"""
         try:
-            with zipfile.ZipFile(file_path, "r") as zip_ref:
-                return True
-        except zipfile.BadZipFile:
-            return False
-
-    def _extract_zip(self, zip_path: str, extract_path: Path):
-        """Extract ZIP file to specified path"""
-        try:
-            with zipfile.ZipFile(zip_path, "r") as zip_ref:
-                # Validate ZIP file structure
-                zip_ref.testzip()
-
-                # Extract all contents
-                zip_ref.extractall(extract_path)
-
-                # Verify extraction
-                extracted_files = list(extract_path.rglob("*"))
-                if not extracted_files:
-                    raise Exception("No files were extracted from ZIP")
-
-                logger.debug(f"Extracted {len(extracted_files)} items from ZIP to: {extract_path}")
-
-        except zipfile.BadZipFile as e:
-            logger.error(f"Invalid ZIP file {zip_path}: {str(e)}")
-            raise Exception(f"Invalid ZIP file: {str(e)}")
-        except zipfile.LargeZipFile as e:
-            logger.error(f"ZIP file too large {zip_path}: {str(e)}")
-            raise Exception(f"ZIP file too large: {str(e)}")
+            extraction = self.archive_extractor.extract(content)
+            if not extraction.files:
+                raise Exception("No files were extracted from the archive")
+            extraction.write(extract_path)
+            logger.debug(f"Extracted {len(extraction.files)} files from the archive to: {extract_path}")
+
+        except ValueError as e:
+            logger.error(f"Invalid archive: {str(e)}")
+            raise Exception(str(e))
         except PermissionError as e:
-            logger.error(f"Permission denied extracting {zip_path}: {str(e)}")
+            logger.error(f"Permission denied extracting to {extract_path}: {str(e)}")
             raise Exception(f"Permission denied during extraction: {str(e)}")
         except OSError as e:
-            logger.error(f"OS error extracting {zip_path}: {str(e)}")
+            logger.error(f"OS error extracting to {extract_path}: {str(e)}")
             raise Exception(f"File system error during extraction: {str(e)}")
-        except Exception as e:
-            logger.error(f"Failed to extract ZIP file {zip_path}: {str(e)}")
-            raise Exception(f"Failed to extract ZIP file: {str(e)}")
 
     def _get_project_root_path(self, extract_path: Path) -> Path:
         """
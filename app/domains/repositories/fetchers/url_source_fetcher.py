@@ -0,0 +1,122 @@
+import ipaddress
+import logging
+import socket
+from pathlib import PurePosixPath
+from typing import Callable, Optional, Tuple
+from urllib.error import URLError
+from urllib.parse import urlparse
+from urllib.request import HTTPRedirectHandler, Request, build_opener
+
+from app.config.config import get_settings
+from app.domains.repositories.exceptions import ExternalSourceException
+
+logger = logging.getLogger(__name__)
+
+# Whether a host may be fetched
+HostPolicy = Callable[[str], bool]
+
+
+class UrlSourceFetcher:
+    """
+    Fetcher for a single source file at a URL (a raw gist, a paste), read in memory without being stored.
+
+    The response is capped in size and time, and the host of the URL and of each redirection is checked by a
+    policy so that the service is not an open proxy. The default policy allows the hosts of the settings (any
+    host if none is listed) except the denied ones, and never an address outside of the public internet.
+    """
+
+    def __init__(
+        self,
+        max_bytes: Optional[int] = None,
+        timeout_seconds: Optional[float] = None,
+        host_policy: Optional[HostPolicy] = None,
+    ):
+        settings = get_settings()
+        self.max_bytes = max_bytes or settings.external_source_max_bytes
+        self.timeout_seconds = timeout_seconds or settings.external_source_timeout_seconds
+        self.allowed_hosts = {host.lower() for host in settings.external_source_allowed_hosts}
+        self.denied_hosts = {host.lower() for host in settings.external_source_denied_hosts}
+        self.host_policy = host_policy or self.default_host_policy
+
+    def fetch(self, url: str) -> Tuple[str, str]:
+        """
+        Fetch the source at a URL
+
+        Returns:
+            The decoded source, and the file name of the URL path ("external" if it has none)
+
+        Raises:
+            ExternalSourceException: If the URL is invalid or denied, or its response too large or too slow
+        """
+        content, charset = self._read(url)
+        return content.decode(charset or "utf-8", errors="replace"), self._file_name(url)
+
+    def fetch_bytes(self, url: str) -> Tuple[bytes, str]:
+        """
+        Fetch the file at a URL as is, an archive say
+
+        Returns:
+            The bytes of the file, and the file name of the URL path ("external" if it has none)
+
+        Raises:
+            ExternalSourceException: If the URL is invalid or denied, or its response too large or too slow
+        """
+        content, _ = self._read(url)
+        return content, self._file_name(url)
+
+    def _read(self, url: str) -> Tuple[bytes, Optional[str]]:
+        """Body of the response at a URL, within the size and time limits, with its charset if any"""
+        self.check_host(url, urlparse(url))
+
+        opener = build_opener(_PolicyRedirectHandler(self))
+        try:
+            request = Request(url, headers={"User-Agent": "PAMP-submissions-service"})
+            with opener.open(request, timeout=self.timeout_seconds) as response:
+                content = response.read(self.max_bytes + 1)
+                charset = response.headers.get_content_charset()
+        except ExternalSourceException:
+            raise
+        except (socket.timeout, TimeoutError):
+            raise ExternalSourceException(
+                url, f"no response within {self.timeout_seconds} seconds", "external_source_timeout"
+            )
+        except (URLError, OSError, ValueError) as e:
+            raise ExternalSourceException(url, str(getattr(e, "reason", e)), "external_source_unreachable")
+
+        if len(content) > self.max_bytes:
+            raise ExternalSourceException(url, f"larger than {self.max_bytes} bytes", "external_source_too_large")
+
+        logger.info(f"Fetched {len(content)} bytes of external source {url}")
+        return content, charset
+
+    @staticmethod
+    def _file_name(url: str) -> str:
+        return PurePosixPath(urlparse(url).path).name or "external"
+
+    def default_host_policy(self, host: str) -> bool:
+        """Allow the listed hosts (any if none is listed) but the denied ones, resolving to public addresses"""
+        if host in self.denied_hosts or (self.allowed_hosts and host not in self.allowed_hosts):
+            return False
+        try:
+            addresses = {info[4][0] for info in socket.getaddrinfo(host, None)}
+        except socket.gaierror:
+            return False
+        return all(ipaddress.ip_address(address.split("%")[0]).is_global for address in addresses)
+
+    def check_host(self, url: str, parsed) -> None:
+        """Raise if the URL is not an HTTP one or its host is denied by the policy"""
+        if parsed.scheme not in ("http", "https") or not parsed.hostname:
+            raise ExternalSourceException(url, "only http and https URLs are fetched", "external_source_invalid_url")
+        if not self.host_policy(parsed.hostname.lower()):
+            raise ExternalSourceException(url, f"host {parsed.hostname} is not allowed", "external_source_denied")
+
+
+class _PolicyRedirectHandler(HTTPRedirectHandler):
+    """Redirection handler checking the host of each redirection with the policy of the fetcher"""
+
+    def __init__(self, fetcher: UrlSourceFetcher):
+        self.fetcher = fetcher
+
+    def redirect_request(self, req, fp, code, msg, headers, newurl):
+        self.fetcher.check_host(newurl, urlparse(newurl))
+        return super().redirect_request(req, fp, code, msg, headers, newurl)
@@ -0,0 +1,181 @@
+import re
+from dataclasses import dataclass
+from pathlib import PurePosixPath
+from typing import Iterable, List, Optional, Pattern, Tuple
+
+from app.domains.repositories.archive_extractor import ArchiveFile
+
+# Dependencies, build outputs and IDE folders ignored in the submissions unless the project step overrides them
+DEFAULT_IGNORE_RULES = ["node_modules/", "vendor/", "target/", "dist/", ".idea/", "*.o", "*.class"]
+
+# Escape of the gitignore syntax, doubled in the character classes of the regular expressions
+BACKSLASH = "\\"
+
+# Name of the ignore files of the submissions, honored when the project step says so
+GITIGNORE_NAME = ".gitignore"
+
+# Ignored paths listed in the processing log of a submission, the others being only counted
+IGNORED_PATH_SAMPLE_SIZE = 20
+
+
+@dataclass(frozen=True)
+class IgnoreRule:
+    """Pattern of a line of a gitignore file, compiled against the paths relative to the directory of the file"""
+
+    pattern: str
+    regex: Pattern
+    negated: bool
+    directory_only: bool
+
+    @classmethod
+    def parse(cls, line: str) -> Optional["IgnoreRule"]:
+        """Rule of a line in gitignore syntax, None for the blank lines and the comments"""
+        pattern = line.rstrip("\r\n")
+        # Trailing spaces are dropped, unless escaped
+        stripped = pattern.rstrip(" ")
+        if stripped.endswith("\\") and len(stripped) < len(pattern):
+            stripped += " "
+        pattern = stripped
+        if not pattern or pattern.startswith("#"):
+            return None
+
+        glob = pattern
+        negated = glob.startswith("!")
+        if negated:
+            glob = glob[1:]
+        elif glob.startswith(("\\!", "\\#")):
+            glob = glob[1:]
+        directory_only = glob.endswith("/")
+        glob = glob.rstrip("/")
+        if not glob:
+            return None
+        # A pattern with a slash (but at its end) is matched from the directory of the file, the others at any depth
+        anchored = "/" in glob
+        regex = cls._translate(glob.lstrip("/"))
+        return cls(pattern, re.compile(regex if anchored else f"(?:.*/)?{regex}"), negated, directory_only)
+
+    @classmethod
+    def _translate(cls, glob: str) -> str:
+        """Regular expression of a glob of gitignore syntax: ** matching any number of directories"""
+        segments = glob.split("/")
+        parts = []
+        for index, segment in enumerate(segments):
+            last = index == len(segments) - 1
+            if segment == "**":
+                # Everything inside at the end, zero or more directories elsewhere
+                parts.append(".*" if last else "(?:.*/)?")
+            else:
+                parts.append(cls._translate_segment(segment) + ("" if last else "/"))
+        return "".join(parts)
+
+    @staticmethod
+    def _translate_segment(segment: str) -> str:
+        """Regular expression of a glob of a single path segment"""
+        parts, index = [], 0
+        while index < len(segment):
+            char = segment[index]
+            if char == "\\" and index + 1 < len(segment):
+                index += 1
+                parts.append(re.escape(segment[index]))
+            elif char == "*":
+                parts.append("[^/]*")
+            elif char == "?":
+                parts.append("[^/]")
+            elif char == "[" and "]" in segment[index + 2 :]:
+                end = segment.index("]", index + 2)
+                characters = segment[index + 1 : end]
+                if characters.startswith("!"):
+                    characters = "^" + characters[1:]
+                parts.append(f"[{characters.replace(BACKSLASH, BACKSLASH * 2)}]")
+                index = end
+            else:
+                parts.append(re.escape(char))
+            index += 1
+        return "".join(parts)
+
+
+class IgnoreFile:
+    """Rules of a gitignore file (or of a project step, at the root), applying to the paths under its directory"""
+
+    def __init__(self, lines: Iterable[str], directory: str = ""):
+        self.rules = [rule for rule in map(IgnoreRule.parse, lines) if rule is not None]
+        self.directory = directory.strip("/")
+
+    @property
+    def depth(self) -> int:
+        """Number of directories the file is nested in"""
+        return len(PurePosixPath(self.directory).parts) if self.directory else 0
+
+    def match(self, path: str, is_directory: bool) -> Optional[bool]:
+        """Whether the last rule matching a path ignores it (True) or includes it again (False), None if none does"""
+        if self.directory:
+            if not path.startswith(self.directory + "/"):
+                return None
+            path = path[len(self.directory) + 1 :]
+        for rule in reversed(self.rules):
+            if rule.directory_only and not is_directory:
+                continue
+            if rule.regex.fullmatch(path):
+                return not rule.negated
+        return None
+
+
+class IgnoreRules:
+    """
+    Paths left out of the submissions of a project step, in gitignore syntax (negations included): a path is
+    ignored when the last rule matching it or one of its directories ignores it, a path in an ignored directory
+    staying ignored whatever the negations. The gitignore files of a submission may be honored too, each applying
+    under its directory and overriding those above it as git does; a path ignored by either the rules of the step or
+    the gitignore files is ignored, so that a submission cannot include again what the step ignores.
+    """
+
+    def __init__(self, lines: Iterable[str] = DEFAULT_IGNORE_RULES, gitignores: Iterable[IgnoreFile] = ()):
+        self.lines = list(lines)
+        self.step_rules = IgnoreFile(self.lines)
+        self.gitignores = sorted(gitignores, key=lambda gitignore: gitignore.depth)
+
+    @property
+    def enabled(self) -> bool:
+        """Whether any path can be ignored"""
+        return bool(self.step_rules.rules or self.gitignores)
+
+    def with_gitignores(self, files: List[ArchiveFile]) -> "IgnoreRules":
+        """Rules honoring the gitignore files among the files of a submission, besides those of the step"""
+        gitignores = []
+        for file in files:
+            path = PurePosixPath(file.path)
+            if path.name == GITIGNORE_NAME:
+                directory = "" if str(path.parent) == "." else path.parent.as_posix()
+                gitignores.append(IgnoreFile(file.content.decode("utf-8", errors="replace").splitlines(), directory))
+        return IgnoreRules(self.lines, [*self.gitignores, *gitignores])
+
+    def ignores(self, path: str, is_directory: bool = False) -> bool:
+        """Whether a path is ignored, itself or one of its directories"""
+        return self._ignored([self.step_rules], path, is_directory) or (
+            bool(self.gitignores) and self._ignored(self.gitignores, path, is_directory)
+        )
+
+    def filter(self, files: List[ArchiveFile]) -> Tuple[List[ArchiveFile], List[str]]:
+        """Files not ignored, and the paths of the ignored ones"""
+        kept, ignored = [], []
+        for file in files:
+            if self.ignores(file.path):
+                ignored.append(file.path)
+            else:
+                kept.append(file)
+        return kept, ignored
+
+    @staticmethod
+    def _ignored(layers: List[IgnoreFile], path: str, is_directory: bool) -> bool:
+        """Whether layers of rules, the deeper ones overriding the others, ignore a path or one of its directories"""
+        parts = path.strip("/").split("/")
+        for depth in range(1, len(parts) + 1):
+            candidate = "/".join(parts[:depth])
+            verdict = None
+            for layer in layers:
+                matched = layer.match(candidate, is_directory or depth < len(parts))
+                if matched is not None:
+                    verdict = matched
+            if verdict:
+                return True
+        return False
@@ -0,0 +1,567 @@
+import functools
+import hashlib
+import io
+import logging
+import os
+import tempfile
+import threading
+import time
+from abc import ABC, abstractmethod
+from contextlib import closing
+from dataclasses import dataclass, field
+from pathlib import Path, PurePosixPath
+from typing import Any, BinaryIO, Callable, Iterator, List, Optional
+from uuid import UUID
+
+try:
+    import boto3
+    from botocore.exceptions import ClientError
+except ImportError:
+    boto3 = None
+
+from app.domains.repositories.exceptions import (
+    ObjectCorruptedException,
+    ObjectNotFoundException,
+    ObjectStorageException,
+)
+from app.shared.metrics import pipeline_metrics
+from app.shared.tenancy import tenant_prefix
+
+logger = logging.getLogger(__name__)
+
+# Bytes read at a time from the streams written to and read from the storage
+STREAM_BLOCK_BYTES = 1024 * 1024
+
+# Error codes of the S3 services for a missing object, by request
+S3_NOT_FOUND_CODES = {"404", "NoSuchKey", "NotFound"}
+
+# Key looked up by the default check of the reachability of a backend, no object having it
+REACHABILITY_KEY = "reachability-check"
+
+# Operations of the storages timed in the metrics, once per call whatever the overrides it goes through (the
+# opening of the stream for a get)
+TIMED_OPERATIONS = ("put", "get", "delete", "exists", "list")
+
+# Whether the current thread is within a timed operation
+_timing = threading.local()
+
+
+def safe_relative_path(path: str) -> str:
+    """
+    Relative POSIX path of a file within a submission, from a path given by a client or read from an archive:
+    backslashes are separators, empty and . parts are dropped, and an absolute path, a drive or a .. part is
+    rejected, so that the key it is part of cannot escape its directory
+
+    Raises:
+        ObjectStorageException: If the path is absolute, has a drive or a .. part, or names no file
+    """
+    parts = [part for part in path.replace("\\", "/").split("/") if part not in ("", ".")]
+    if not parts or path.startswith(("/", "\\")) or ":" in parts[0] or ".." in parts or "\x00" in path:
+        raise ObjectStorageException(path, "the path is not relative to the submission", error_type="unsafe_path")
+    return "/".join(parts)
+
+
+def submission_file_key(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    group_uuid: UUID,
+    version: int,
+    relative_path: str,
+    tenant_id: Optional[str] = None,
+) -> str:
+    """
+    Key of a file of a version of a submission, in the layout of the storage: the assignment (project and step),
+    the submission (its group), the version, then the path of the file within it, under the directory of its
+    tenant unless it is the default one
+    """
+    if version < 1:
+        raise ObjectStorageException(relative_path, f"invalid version {version}", error_type="unsafe_path")
+    return (
+        f"{tenant_prefix(tenant_id)}{UUID(str(project_uuid))}/{UUID(str(project_step_uuid))}/{UUID(str(group_uuid))}/"
+        f"v{int(version)}/{safe_relative_path(relative_path)}"
+    )
+
+
+@dataclass
+class StorageIntegrityReport:
+    """Result of the scan of the stored files against their checksums"""
+
+    checked_count: int = 0
+    missing: List[str] = field(default_factory=list)  # Checksum recorded, file gone
+    corrupted: List[str] = field(default_factory=list)  # Content not matching its checksum
+    unverified: List[str] = field(default_factory=list)  # No recorded checksum
+
+
+def _timed(operation: str, method: Callable[..., Any]) -> Callable[..., Any]:
+    """Operation of a storage recording its duration and outcome (ok, not_found or error) in the metrics"""
+
+    @functools.wraps(method)
+    def timed(self: "ObjectStorage", *args: Any, **kwargs: Any) -> Any:
+        if getattr(_timing, "active", False):
+            return method(self, *args, **kwargs)
+        _timing.active = True
+        started = time.monotonic()
+        outcome = "error"
+        try:
+            result = method(self, *args, **kwargs)
+            outcome = "ok"
+            return result
+        except ObjectNotFoundException:
+            outcome = "not_found"
+            raise
+        finally:
+            _timing.active = False
+            pipeline_metrics.observe_storage_operation(self.backend, operation, outcome, time.monotonic() - started)
+
+    return timed
+
+
+class ObjectStorage(ABC):
+    """
+    Storage of the files of the service by key, a relative POSIX path: the chunks of the resumable uploads and the
+    original files of the uploaded submissions. The objects are written and read as streams, never whole. The
+    operations of each backend are timed in the metrics.
+    """
+
+    # Name of the backend, labelling its metrics
+    backend = "unknown"
+
+    # Whether the objects have S3 links, fetched like any linked submission
+    has_links = False
+
+    def __init_subclass__(cls, **kwargs: Any):
+        super().__init_subclass__(**kwargs)
+        for operation in TIMED_OPERATIONS:
+            if operation in cls.__dict__:
+                setattr(cls, operation, _timed(operation, cls.__dict__[operation]))
+
+    @abstractmethod
+    def put(self, key: str, stream: BinaryIO) -> None:
+        """Write an object from a stream read to its end, replacing the object of the key if any"""
+        pass
+
+    @abstractmethod
+    def get(self, key: str) -> BinaryIO:
+        """
+        Open an object as a stream, to be closed by the caller
+
+        Raises:
+            ObjectNotFoundException: If there is no object of the key
+        """
+        pass
+
+    @abstractmethod
+    def delete(self, key: str) -> None:
+        """Delete an object, nothing being done if there is none"""
+        pass
+
+    @abstractmethod
+    def exists(self, key: str) -> bool:
+        """Whether there is an object of the key"""
+        pass
+
+    @abstractmethod
+    def list(self, prefix: str = "") -> List[str]:
+        """Keys of the objects starting with the prefix, in order"""
+        pass
+
+    def link(self, key: str) -> Optional[str]:
+        """S3 link of an object, None if the storage has no links"""
+        return None
+
+    def key_of(self, link: str) -> Optional[str]:
+        """Key of the object of a link, None if the link is not one of the storage"""
+        return None
+
+    def read_bytes(self, key: str) -> bytes:
+        """Content of an object, for the small ones"""
+        with closing(self.get(key)) as stream:
+            return stream.read()
+
+    def iter_chunks(self, key: str, chunk_bytes: int = STREAM_BLOCK_BYTES) -> Iterator[bytes]:
+        """Content of an object block by block, the object being opened at once"""
+        stream = self.get(key)
+
+        def chunks() -> Iterator[bytes]:
+            with closing(stream):
+                while block := stream.read(chunk_bytes):
+                    yield block
+
+        return chunks()
+
+    def delete_prefix(self, prefix: str) -> int:
+        """Delete the objects starting with the prefix, returning their number"""
+        keys = self.list(prefix)
+        for key in keys:
+            self.delete(key)
+        return len(keys)
+
+    def check_reachable(self) -> None:
+        """
+        Raise if the backend cannot be reached, for the readiness probe of the service
+
+        Raises:
+            ObjectStorageException: If the backend cannot be read or written
+        """
+        self.exists(REACHABILITY_KEY)
+
+    @staticmethod
+    def check_key(key: str) -> str:
+        """A key is a relative path without empty, . or .. parts"""
+        parts = key.split("/")
+        if not key or key.startswith("/") or any(part in ("", ".", "..") for part in parts):
+            raise ObjectStorageException(key, "invalid key", error_type="invalid_object_key")
+        return key
+
+
+class LocalObjectStorage(ObjectStorage):
+    """
+    Objects kept as files under a directory, one per key. An object is written to a temporary file then renamed,
+    so that it is either whole or missing. The files do not survive the rescheduling of the service to another
+    node: the S3 storage is used then.
+    """
+
+    backend = "local"
+    TEMPORARY_SUFFIX = ".part"
+    # Top-level directories of the root not holding objects
+    RESERVED_DIRECTORIES: tuple = ()
+
+    def __init__(self, root: Path):
+        self.root = Path(root)
+
+    @classmethod
+    def from_settings(cls, settings) -> "LocalObjectStorage":
+        return cls(Path(settings.object_storage_local_dir or Path(tempfile.gettempdir()) / "submission-storage"))
+
+    def check_reachable(self) -> None:
+        try:
+            self.root.mkdir(parents=True, exist_ok=True)
+        except OSError as e:
+            raise ObjectStorageException(str(self.root), str(e), error_type="object_storage_unreachable")
+        if not os.access(self.root, os.R_OK | os.W_OK | os.X_OK):
+            raise ObjectStorageException(str(self.root), "the directory is not writable", "object_storage_unreachable")
+
+    def put(self, key: str, stream: BinaryIO) -> None:
+        path = self._path(key)
+        path.parent.mkdir(parents=True, exist_ok=True)
+        self._write_atomically(path, stream)
+
+    def _write_atomically(self, path: Path, stream: BinaryIO) -> str:
+        """
+        Write a file from a stream: to a temporary file flushed to the disk, then renamed, the rename being flushed
+        too, so that the file is either whole or missing even on a crash. Returns the SHA-256 checksum written.
+        """
+        digest = hashlib.sha256()
+        with tempfile.NamedTemporaryFile(
+            dir=path.parent, delete=False, prefix=".", suffix=self.TEMPORARY_SUFFIX
+        ) as temp_file:
+            try:
+                while block := stream.read(STREAM_BLOCK_BYTES):
+                    digest.update(block)
+                    temp_file.write(block)
+                temp_file.flush()
+                os.fsync(temp_file.fileno())
+            except BaseException:
+                temp_file.close()
+                os.unlink(temp_file.name)
+                raise
+        os.replace(temp_file.name, path)
+        self._fsync_directory(path.parent)
+        return digest.hexdigest()
+
+    @staticmethod
+    def _fsync_directory(directory: Path) -> None:
+        try:
+            descriptor = os.open(directory, os.O_RDONLY)
+        except OSError:
+            return
+        try:
+            os.fsync(descriptor)
+        except OSError:
+            pass
+        finally:
+            os.close(descriptor)
+
+    def get(self, key: str) -> BinaryIO:
+        try:
+            return open(self._path(key), "rb")
+        except (FileNotFoundError, IsADirectoryError):
+            raise ObjectNotFoundException(key)
+
+    def delete(self, key: str) -> None:
+        path = self._path(key)
+        path.unlink(missing_ok=True)
+        self._remove_empty_directories(path, self.root)
+
+    @staticmethod
+    def _remove_empty_directories(path: Path, top: Path) -> None:
+        """Remove the directories of a path left empty, up to the top one"""
+        for directory in path.parents:
+            if directory == top or top not in directory.parents:
+                break
+            try:
+                directory.rmdir()
+            except OSError:
+                break
+
+    def exists(self, key: str) -> bool:
+        return self._path(key).is_file()
+
+    def list(self, prefix: str = "") -> List[str]:
+        # Walk the deepest directory holding all the keys of the prefix only
+        base = self.root / PurePosixPath(prefix).parent if "/" in prefix else self.root
+        if not base.is_dir():
+            return []
+        keys = []
+        for directory, directories, filenames in os.walk(base):
+            if Path(directory) == self.root:
+                directories[:] = [name for name in directories if name not in self.RESERVED_DIRECTORIES]
+            for filename in filenames:
+                if filename.startswith(".") and filename.endswith(self.TEMPORARY_SUFFIX):
+                    continue
+                key = (Path(directory) / filename).relative_to(self.root).as_posix()
+                if key.startswith(prefix):
+                    keys.append(key)
+        return sorted(keys)
+
+    def _path(self, key: str) -> Path:
+        if self.check_key(key).split("/", 1)[0] in self.RESERVED_DIRECTORIES:
+            raise ObjectStorageException(key, "reserved key", error_type="invalid_object_key")
+        path = self.root / key
+        # Nothing out of the root, even through a link
+        if not path.resolve().is_relative_to(self.root.resolve()):
+            raise ObjectStorageException(key, "the key leads out of the storage", error_type="invalid_object_key")
+        return path
+
+
+class VerifiedStream:
+    """Stream of a stored file, checked against its recorded checksum once read to its end"""
+
+    def __init__(self, file: BinaryIO, key: str, checksum: str):
+        self._file = file
+        self._key = key
+        self._checksum = checksum
+        self._digest = hashlib.sha256()
+        self._verified = False
+
+    def read(self, size: int = -1) -> bytes:
+        block = self._file.read(size)
+        self._digest.update(block)
+        if (not block or size is None or size < 0) and not self._verified:
+            self._verified = True
+            if self._digest.hexdigest() != self._checksum:
+                logger.error(f"Stored file {self._key} does not match its recorded checksum")
+                raise ObjectCorruptedException(self._key)
+        return block
+
+    def close(self) -> None:
+        self._file.close()
+
+    def __enter__(self) -> "VerifiedStream":
+        return self
+
+    def __exit__(self, *exc_info) -> None:
+        self.close()
+
+
+class FilesystemObjectStorage(LocalObjectStorage):
+    """
+    Objects kept as files on a mounted volume, for the installations without an S3 service: the files of a
+    submission are laid out by assignment, submission and version (see submission_file_key). The SHA-256 checksum
+    of a file is recorded when it is written, in a tree of its own under the root, and checked when the file is
+    read to its end; the files are written atomically, flushed to the disk. The objects have S3-style links under
+    the pseudo bucket of the volume, fetched from it like those of the S3 storage.
+    """
+
+    CHECKSUMS_DIRECTORY = ".checksums"
+    CHECKSUM_SUFFIX = ".sha256"
+    RESERVED_DIRECTORIES = (CHECKSUMS_DIRECTORY,)
+    backend = "filesystem"
+    has_links = True
+
+    def __init__(self, root: Path, link_bucket: str = "filesystem"):
+        super().__init__(root)
+        self.link_bucket = link_bucket
+
+    @classmethod
+    def from_settings(cls, settings) -> "FilesystemObjectStorage":
+        if not settings.object_storage_local_dir:
+            raise ObjectStorageException("", "no root directory is configured", error_type="object_storage_config")
+        return cls(Path(settings.object_storage_local_dir))
+
+    def put(self, key: str, stream: BinaryIO) -> None:
+        path = self._path(key)
+        checksum_path = self._checksum_path(key)
+        path.parent.mkdir(parents=True, exist_ok=True)
+        checksum_path.parent.mkdir(parents=True, exist_ok=True)
+        checksum = self._write_atomically(path, stream)
+        self._write_atomically(checksum_path, io.BytesIO(checksum.encode("ascii")))
+
+    def get(self, key: str) -> BinaryIO:
+        file = super().get(key)
+        checksum = self._recorded_checksum(key)
+        if checksum is None:
+            logger.warning(f"Stored file {key} has no recorded checksum, read unverified")
+            return file
+        return VerifiedStream(file, key, checksum)
+
+    def delete(self, key: str) -> None:
+        super().delete(key)
+        checksum_path = self._checksum_path(key)
+        checksum_path.unlink(missing_ok=True)
+        self._remove_empty_directories(checksum_path, self.root / self.CHECKSUMS_DIRECTORY)
+
+    def link(self, key: str) -> Optional[str]:
+        return f"s3://{self.link_bucket}/{self.check_key(key)}"
+
+    def key_of(self, link: str) -> Optional[str]:
+        base = f"s3://{self.link_bucket}/"
+        return link[len(base) :] if link.startswith(base) and len(link) > len(base) else None
+
+    def scan(self) -> StorageIntegrityReport:
+        """Check every stored file against its recorded checksum, listing the missing and corrupted ones"""
+        report = StorageIntegrityReport()
+        keys = set(self.list())
+        for key in sorted(keys):
+            report.checked_count += 1
+            checksum = self._recorded_checksum(key)
+            if checksum is None:
+                report.unverified.append(key)
+                continue
+            digest = hashlib.sha256()
+            with open(self._path(key), "rb") as file:
+                while block := file.read(STREAM_BLOCK_BYTES):
+                    digest.update(block)
+            if digest.hexdigest() != checksum:
+                report.corrupted.append(key)
+
+        checksums_root = self.root / self.CHECKSUMS_DIRECTORY
+        if checksums_root.is_dir():
+            for directory, _, filenames in os.walk(checksums_root):
+                for filename in filenames:
+                    if filename.endswith(self.CHECKSUM_SUFFIX):
+                        key = (Path(directory) / filename).relative_to(checksums_root).as_posix()
+                        key = key[: -len(self.CHECKSUM_SUFFIX)]
+                        if key not in keys:
+                            report.missing.append(key)
+        report.missing.sort()
+        return report
+
+    def _checksum_path(self, key: str) -> Path:
+        return self.root / self.CHECKSUMS_DIRECTORY / f"{self.check_key(key)}{self.CHECKSUM_SUFFIX}"
+
+    def _recorded_checksum(self, key: str) -> Optional[str]:
+        try:
+            return self._checksum_path(key).read_text(encoding="ascii").strip()
+        except FileNotFoundError:
+            return None
+
+
+class S3ObjectStorage(ObjectStorage):
+    """
+    Objects kept in a bucket of an S3-compatible service (AWS, MinIO) under a key prefix, shared by the instances
+    of the service. The objects are uploaded in parts as their stream is read, and downloaded as a stream. The
+    objects of the bucket have S3 links, so that the submissions made of them are fetched like linked ones.
+    """
+
+    backend = "s3"
+    has_links = True
+
+    def __init__(
+        self,
+        bucket: str,
+        key_prefix: str = "",
+        endpoint_url: Optional[str] = None,
+        access_key_id: Optional[str] = None,
+        secret_access_key: Optional[str] = None,
+        region: Optional[str] = None,
+        client=None,
+    ):
+        if not bucket:
+            raise ObjectStorageException(key_prefix, "no bucket is configured", error_type="object_storage_config")
+        if client is None:
+            if boto3 is None:
+                raise ObjectStorageException(
+                    key_prefix, "boto3 is required for the S3 storage", error_type="object_storage_config"
+                )
+            client = boto3.client(
+                "s3",
+                endpoint_url=endpoint_url or None,
+                aws_access_key_id=access_key_id or None,
+                aws_secret_access_key=secret_access_key or None,
+                region_name=region,
+            )
+        self.client = client
+        self.bucket = bucket
+        self.key_prefix = key_prefix.strip("/") + "/" if key_prefix.strip("/") else ""
+
+    @classmethod
+    def from_settings(cls, settings) -> "S3ObjectStorage":
+        secret = settings.object_storage_s3_secret_access_key
+        return cls(
+            settings.object_storage_s3_bucket,
+            key_prefix=settings.object_storage_key_prefix,
+            endpoint_url=settings.object_storage_s3_endpoint_url,
+            access_key_id=settings.object_storage_s3_access_key_id,
+            secret_access_key=secret.get_secret_value() if secret else None,
+            region=settings.object_storage_s3_region,
+        )
+
+    def check_reachable(self) -> None:
+        try:
+            self.client.head_bucket(Bucket=self.bucket)
+        except ClientError as e:
+            raise ObjectStorageException(self.bucket, self._error_code(e), error_type="object_storage_unreachable")
+
+    def put(self, key: str, stream: BinaryIO) -> None:
+        try:
+            self.client.upload_fileobj(stream, self.bucket, self._object_key(key))
+        except ClientError as e:
+            raise ObjectStorageException(key, self._error_code(e))
+
+    def get(self, key: str) -> BinaryIO:
+        try:
+            return self.client.get_object(Bucket=self.bucket, Key=self._object_key(key))["Body"]
+        except ClientError as e:
+            if self._error_code(e) in S3_NOT_FOUND_CODES:
+                raise ObjectNotFoundException(key)
+            raise ObjectStorageException(key, self._error_code(e))
+
+    def delete(self, key: str) -> None:
+        try:
+            self.client.delete_object(Bucket=self.bucket, Key=self._object_key(key))
+        except ClientError as e:
+            raise ObjectStorageException(key, self._error_code(e))
+
+    def exists(self, key: str) -> bool:
+        try:
+            self.client.head_object(Bucket=self.bucket, Key=self._object_key(key))
+            return True
+        except ClientError as e:
+            if self._error_code(e) in S3_NOT_FOUND_CODES:
+                return False
+            raise ObjectStorageException(key, self._error_code(e))
+
+    def list(self, prefix: str = "") -> List[str]:
+        keys = []
+        try:
+            paginator = self.client.get_paginator("list_objects_v2")
+            for page in paginator.paginate(Bucket=self.bucket, Prefix=self.key_prefix + prefix):
+                keys.extend(entry["Key"][len(self.key_prefix) :] for entry in page.get("Contents", []))
+        except ClientError as e:
+            raise ObjectStorageException(prefix, self._error_code(e))
+        return sorted(keys)
+
+    def link(self, key: str) -> Optional[str]:
+        return f"s3://{self.bucket}/{self._object_key(key)}"
+
+    def key_of(self, link: str) -> Optional[str]:
+        base = f"s3://{self.bucket}/{self.key_prefix}"
+        return link[len(base) :] if link.startswith(base) and len(link) > len(base) else None
+
+    def _object_key(self, key: str) -> str:
+        return self.key_prefix + self.check_key(key)
+
+    @staticmethod
+    def _error_code(error: "ClientError") -> str:
+        return str(error.response.get("Error", {}).get("Code", "Unknown"))
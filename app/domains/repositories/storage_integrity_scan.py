@@ -0,0 +1,45 @@
+import logging
+from datetime import datetime, timezone
+from typing import Optional, Tuple
+
+from app.domains.repositories.object_storage import StorageIntegrityReport
+
+logger = logging.getLogger(__name__)
+
+# Last report of the scan, with the time it ended, None before the first one
+_last_scan: Optional[Tuple[StorageIntegrityReport, datetime]] = None
+
+
+def scan_storage_integrity() -> Optional[StorageIntegrityReport]:
+    """
+    Check the files of the filesystem storage against their checksums, logging the missing and corrupted ones
+    without failing the service; nothing is scanned for the other backends
+    """
+    global _last_scan
+    from app.domains.repositories.object_storage import FilesystemObjectStorage
+    from app.shared.services import get_object_storage
+
+    try:
+        storage = get_object_storage()
+        if not isinstance(storage, FilesystemObjectStorage):
+            return None
+        report = storage.scan()
+    except Exception as e:
+        logger.error(f"Failed to scan the integrity of the storage: {e}")
+        return None
+
+    _last_scan = (report, datetime.now(timezone.utc))
+    for key in report.missing:
+        logger.error(f"Stored file missing: {key}")
+    for key in report.corrupted:
+        logger.error(f"Stored file corrupted: {key}")
+    logger.info(
+        f"Scanned {report.checked_count} stored files: {len(report.missing)} missing, {len(report.corrupted)} "
+        f"corrupted, {len(report.unverified)} without checksum"
+    )
+    return report
+
+
+def get_last_scan() -> Optional[Tuple[StorageIntegrityReport, datetime]]:
+    """Last report of the scan and the time it ended, None if none was made"""
+    return _last_scan
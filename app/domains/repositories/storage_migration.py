@@ -0,0 +1,99 @@
+"""
+Migration of the files of the service from the local object storage to the S3 one, before switching the backend
+
+Usage: python -m app.domains.repositories.storage_migration [--source-dir DIR] [--prefix PREFIX]
+"""
+
+import argparse
+import hashlib
+import logging
+import sys
+from contextlib import closing
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import List, Optional
+
+from app.domains.repositories.object_storage import (
+    STREAM_BLOCK_BYTES,
+    LocalObjectStorage,
+    ObjectStorage,
+    S3ObjectStorage,
+)
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class StorageMigrationReport:
+    """Objects copied, skipped for being already in the target with the same checksum, and failed"""
+
+    copied: int = 0
+    skipped: int = 0
+    failed: List[str] = field(default_factory=list)
+
+
+class StorageMigration:
+    """
+    Copy of the objects of a storage into another, each checked against its SHA-256 checksum read back from the
+    target. An object already in the target with the same checksum is skipped, so that an interrupted migration is
+    run again from the start; the source is left as is.
+    """
+
+    def __init__(self, source: ObjectStorage, target: ObjectStorage):
+        self.source = source
+        self.target = target
+
+    def run(self, prefix: str = "") -> StorageMigrationReport:
+        report = StorageMigrationReport()
+        for key in self.source.list(prefix):
+            try:
+                checksum = self.checksum(self.source, key)
+                if self.target.exists(key) and self.checksum(self.target, key) == checksum:
+                    report.skipped += 1
+                    continue
+                with closing(self.source.get(key)) as stream:
+                    self.target.put(key, stream)
+                if self.checksum(self.target, key) != checksum:
+                    raise ValueError("the copy does not match the checksum of the source")
+                report.copied += 1
+            except Exception as e:
+                logger.error(f"Failed to migrate {key}: {str(e)}")
+                report.failed.append(key)
+        logger.info(f"Migrated {report.copied} objects, skipped {report.skipped}, {len(report.failed)} failed")
+        return report
+
+    @staticmethod
+    def checksum(storage: ObjectStorage, key: str) -> str:
+        """SHA-256 checksum of an object, read as a stream"""
+        digest = hashlib.sha256()
+        with closing(storage.get(key)) as stream:
+            while block := stream.read(STREAM_BLOCK_BYTES):
+                digest.update(block)
+        return digest.hexdigest()
+
+
+def main(argv: Optional[List[str]] = None) -> int:
+    """Copy the local files into the configured S3 bucket, returning 1 if any failed"""
+    from app.config.config import get_settings
+
+    parser = argparse.ArgumentParser(description="Copy the local files of the service into the S3 object storage")
+    parser.add_argument("--source-dir", help="Directory of the local storage, the configured one by default")
+    parser.add_argument("--prefix", default="", help="Copy only the keys starting with the prefix")
+    arguments = parser.parse_args(argv)
+
+    logging.basicConfig(level=logging.INFO, format="%(asctime)s - %(name)s - %(levelname)s - %(message)s")
+    settings = get_settings()
+    source = (
+        LocalObjectStorage(Path(arguments.source_dir))
+        if arguments.source_dir
+        else LocalObjectStorage.from_settings(settings)
+    )
+    report = StorageMigration(source, S3ObjectStorage.from_settings(settings)).run(arguments.prefix)
+    print(f"Copied {report.copied} objects, skipped {report.skipped} already migrated, {len(report.failed)} failed")
+    for key in report.failed:
+        print(f"Failed: {key}")
+    return 1 if report.failed else 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
@@ -1,6 +1,7 @@
 import logging
 import tempfile
 from pathlib import Path
+from typing import BinaryIO, Iterator, Optional
 
 from app.domains.repositories.exceptions import (
     RepositoryFetchException,
@@ -11,6 +12,7 @@ from app.domains.repositories.exceptions import (
 from app.domains.repositories.fetchers.github_fetcher import GithubFetcher
 from app.domains.repositories.fetchers.gitlab_fetcher import GitlabFetcher
 from app.domains.repositories.fetchers.s3_fetcher import S3Fetcher
+from app.domains.repositories.object_storage import ObjectStorage
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
 
 logger = logging.getLogger(__name__)
@@ -41,13 +43,25 @@ def cleanup_temp_directory(repo_path: Path) -> None:
 class SubmissionFetcher:
     """Unified fetcher for submissions from different sources"""
 
-    def __init__(self):
+    # Keys of the original files of the uploaded submissions in the object storage
+    UPLOADS_PREFIX = "uploads/"
+
+    def __init__(self, object_storage: Optional[ObjectStorage] = None):
         """
-        Initialize submission fetcher
+        Initialize submission fetcher, the S3 links of the object storage of the service being fetched from it
         """
         self.github_fetcher = GithubFetcher()
         self.gitlab_fetcher = GitlabFetcher()
         self.s3_fetcher = S3Fetcher()
+        self._object_storage = object_storage
+
+    @property
+    def object_storage(self) -> ObjectStorage:
+        if self._object_storage is None:
+            from app.shared.services import get_object_storage
+
+            self._object_storage = get_object_storage()
+        return self._object_storage
 
     def fetch_submission(self, submission: CreateSubmissionDto) -> Path | None:
         """
@@ -109,6 +123,8 @@ class SubmissionFetcher:
                     result_path = self.github_fetcher.clone_github_repo(project_url, temp_dir)
                 elif project_type == "gitlab":
                     result_path = self.gitlab_fetcher.clone_gitlab_repo(project_url, temp_dir)
+                elif project_type == "s3" and self.object_storage.key_of(project_url):
+                    result_path = self._fetch_stored_content(project_url, temp_dir)
                 elif project_type == "s3":
                     result_path = self.s3_fetcher.fetch_s3_content(project_url, temp_dir)
                 else:
@@ -152,6 +168,13 @@ class SubmissionFetcher:
                 source_url=project_url if "project_url" in locals() else submission.link if submission else None,
             )
 
+    def _fetch_stored_content(self, link: str, temp_dir: str) -> Path:
+        """Fetch and extract an object of the storage of the service from its S3 link"""
+        key = self.object_storage.key_of(link)
+        content = self.object_storage.read_bytes(key)
+        logger.info(f"Fetched {len(content)} bytes from the object storage: {key}")
+        return self.s3_fetcher.extract_content(content, key, temp_dir, link)
+
     def _create_temp_directory(self, project_url: str) -> str:
         """Create a temporary directory for submission fetching"""
         # Create a safe prefix from the URL
@@ -229,3 +252,53 @@ class SubmissionFetcher:
             List of supported project types
         """
         return ["github", "gitlab", "s3"]
+
+    @property
+    def stores_uploads(self) -> bool:
+        """Whether the original files of the uploaded submissions are kept in the object storage, having S3 links"""
+        return self.object_storage.has_links
+
+    def is_stored_upload(self, s3_url: str) -> bool:
+        """Whether a link is the one of an original file kept in the object storage"""
+        key = self.object_storage.key_of(s3_url)
+        return bool(key and key.startswith(self.UPLOADS_PREFIX))
+
+    def store_upload(self, bucket_name: Optional[str], object_key: str, content: BinaryIO) -> str:
+        """
+        Keep the original file of an uploaded submission, streamed to the object storage if it has S3 links, to the
+        upload bucket otherwise, so that it can be fetched and downloaded as any S3 link
+
+        Returns:
+            S3 URL of the stored file
+        """
+        if self.stores_uploads:
+            key = self.UPLOADS_PREFIX + object_key
+            self.object_storage.put(key, content)
+            logger.info(f"Stored the upload in the object storage: {key}")
+            return self.object_storage.link(key)
+        return self.s3_fetcher.upload_content(bucket_name, self.UPLOADS_PREFIX + object_key, content.read())
+
+    def download_upload(self, s3_url: str) -> bytes:
+        """
+        Get the original file of an uploaded submission, without extracting it
+        """
+        if self.is_stored_upload(s3_url):
+            return self.object_storage.read_bytes(self.object_storage.key_of(s3_url))
+        return self.s3_fetcher.download_content(s3_url)
+
+    def stream_upload(self, s3_url: str) -> Iterator[bytes]:
+        """
+        Stream the original file of an uploaded submission, without extracting it nor holding it in memory
+        """
+        if self.is_stored_upload(s3_url):
+            return self.object_storage.iter_chunks(self.object_storage.key_of(s3_url))
+        return self.s3_fetcher.stream_content(s3_url)
+
+    def delete_upload(self, s3_url: str) -> None:
+        """
+        Delete the original file of an uploaded submission from the object storage or the upload bucket
+        """
+        if self.is_stored_upload(s3_url):
+            self.object_storage.delete(self.object_storage.key_of(s3_url))
+        else:
+            self.s3_fetcher.delete_content(s3_url)
@@ -0,0 +1,288 @@
+import logging
+from typing import Any, Callable, Dict, Iterable, List, Optional
+from uuid import UUID
+
+from app.shared.authorization import Principal, Role
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+logger = logging.getLogger(__name__)
+
+# Roles seeing every submission and result, the service role being that of the trusted integrations (LMS, grading)
+UNRESTRICTED_ROLES = (Role.ADMIN, Role.SERVICE)
+
+
+class AccessDenied(Exception):
+    """Raised when the caller is not allowed an action on a resource, the denial being audited"""
+
+    code = "access_denied"
+
+    def __init__(
+        self,
+        message: str,
+        principal: Principal,
+        action: str,
+        resource_type: str,
+        resource_id: Optional[Any] = None,
+    ):
+        super().__init__(message)
+        self.principal = principal
+        self.action = action
+        self.resource_type = resource_type
+        self.resource_id = str(resource_id) if resource_id is not None else None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Structured detail of the denial, without the scoping claims of the caller"""
+        return {
+            "error_type": self.code,
+            "message": str(self),
+            "action": self.action,
+            "resource_type": self.resource_type,
+            "resource_id": self.resource_id,
+        }
+
+
+class OutOfTenant(AccessDenied):
+    """
+    Raised when a resource belongs to another tenant than the caller's, answered as if it did not exist (a 404 of
+    the same message) so that its existence does not leak across the tenants, the denial being audited all the same
+    """
+
+    code = "not_found"
+
+    def __init__(self, principal: Principal, action: str, resource_type: str, resource_id: Optional[Any] = None):
+        message = f"{resource_type.replace('_', ' ').capitalize()} not found"
+        if resource_id is not None:
+            message += f" with identifier: {resource_id}"
+        super().__init__(message, principal, action, resource_type, resource_id)
+
+
+class AccessPolicy:
+    """
+    Scope of the access of a caller to the submissions and their results, enforced by the service layer so that
+    the HTTP and gRPC APIs are covered alike: admins (and trusted services) see everything, graders the
+    submissions and results of their assignments (project steps), and students their own submissions (of their
+    assignments if the token names some), never a pairwise result. A submission is a student's own when they
+    submitted it or it belongs to them or to one of their groups. Without a caller (authentication explicitly
+    disabled, or an internal job), everything is allowed. Each denial is passed to the callback, to be audited,
+    before it is raised.
+
+    Whatever their role, the callers only see the resources of their tenant: a project step belongs to the tenant
+    the step_tenant callback gives (None for a step no tenant acted on yet), a submission to the tenant recorded on
+    it, and a resource of another tenant is reported as not found (OutOfTenant) rather than denied.
+    """
+
+    def __init__(
+        self,
+        principal: Optional[Principal],
+        on_denied: Optional[Callable[[AccessDenied], None]] = None,
+        step_tenant: Optional[Callable[[UUID], Optional[str]]] = None,
+    ):
+        self.principal = principal
+        self.on_denied = on_denied
+        self.step_tenant = step_tenant
+
+    @property
+    def unrestricted(self) -> bool:
+        """Whether the caller sees everything of its tenant"""
+        return self.principal is None or self.principal.role in UNRESTRICTED_ROLES
+
+    @property
+    def tenant_id(self) -> Optional[str]:
+        """Tenant of the caller, None without a caller (every tenant)"""
+        return self.principal.tenant_id if self.principal else None
+
+    def in_tenant(self, tenant_id: Optional[str]) -> bool:
+        """Whether a resource of a tenant is visible to the caller, one without tenant being the default tenant's"""
+        return self.principal is None or (tenant_id or DEFAULT_TENANT_ID) == self.principal.tenant_id
+
+    def check_tenant(
+        self, tenant_id: Optional[str], action: str, resource_type: str, resource_id: Optional[Any] = None
+    ) -> None:
+        """
+        Check that a resource belongs to the tenant of the caller
+
+        Raises:
+            OutOfTenant: If the resource belongs to another tenant
+        """
+        if not self.in_tenant(tenant_id):
+            self._deny_out_of_tenant(action, resource_type, resource_id)
+
+    def owns(self, submission: Any) -> bool:
+        """Whether a submission is one of the caller's own, as a student"""
+        owner_ids = self.principal.owner_ids if self.principal else frozenset()
+        return submission.group_uuid in owner_ids or submission.submitted_by_uuid in owner_ids
+
+    def grades(self, project_step_uuid: Optional[UUID]) -> bool:
+        """Whether a project step is one of the assignments of the caller, as a grader"""
+        return (
+            self.principal is not None
+            and self.principal.role == Role.GRADER
+            and project_step_uuid in self.principal.assignment_ids
+        )
+
+    def can_read_submission(self, submission: Any) -> bool:
+        """Whether the caller may read a submission, its files and its status"""
+        return self.in_tenant(submission.tenant_id) and (
+            self.unrestricted
+            or self.grades(submission.project_step_uuid)
+            or (self._is_student and self.owns(submission) and self._student_step(submission.project_step_uuid))
+        )
+
+    def check_submission(self, submission: Any, action: str, manage: bool = False) -> None:
+        """
+        Check that the caller may read a submission, or manage it (change, delete, retry... as a grader)
+
+        Raises:
+            OutOfTenant: If the submission belongs to another tenant
+            AccessDenied: If the submission is out of the scope of the caller
+        """
+        self.check_tenant(submission.tenant_id, action, "submission", submission.id)
+        allowed = self.can_read_submission(submission)
+        if manage and allowed and self._is_student:
+            self._deny(action, "submission", submission.id, "Students cannot manage submissions")
+        if not allowed:
+            self._deny(action, "submission", submission.id, "The submission is out of the scope of the caller")
+
+    def check_submission_creation(
+        self, project_step_uuid: UUID, group_uuid: UUID, submitted_by_uuid: Optional[UUID], action: str
+    ) -> None:
+        """
+        Check that the caller may submit for a group to a project step: a student only for themselves or one of
+        their groups, a grader only to one of their assignments
+
+        Raises:
+            OutOfTenant: If the project step belongs to another tenant
+            AccessDenied: If the submission would be out of the scope of the caller
+        """
+        self._check_step_tenant(project_step_uuid, action, "project_step", project_step_uuid)
+        if self.unrestricted or self.grades(project_step_uuid):
+            return
+        if self._is_student:
+            owner_ids = self.principal.owner_ids
+            if (
+                group_uuid in owner_ids
+                and submitted_by_uuid in (None, self.principal.student_id)
+                and self._student_step(project_step_uuid)
+            ):
+                return
+            self._deny(action, "project_step", project_step_uuid, "Students can only submit their own work")
+        self._deny(action, "project_step", project_step_uuid, "The project step is not one of the caller's assignments")
+
+    def check_step(
+        self,
+        project_step_uuid: Optional[UUID],
+        action: str,
+        resource_type: str = "project_step",
+        resource_id: Optional[Any] = None,
+        students: bool = False,
+    ) -> None:
+        """
+        Check that the caller may act on a project step or a resource of it: a grader of the step, or a student of
+        it when students are allowed (the limits of their uploads...)
+
+        Raises:
+            OutOfTenant: If the project step belongs to another tenant
+            AccessDenied: If the project step is out of the scope of the caller
+        """
+        self._check_step_tenant(
+            project_step_uuid, action, resource_type, project_step_uuid if resource_id is None else resource_id
+        )
+        if self.unrestricted or self.grades(project_step_uuid):
+            return
+        if students and self._is_student and self._student_step(project_step_uuid):
+            return
+        self._deny(
+            action,
+            resource_type,
+            project_step_uuid if resource_id is None else resource_id,
+            "The project step is not one of the caller's assignments",
+        )
+
+    def check_results(
+        self, project_step_uuid: Optional[UUID], action: str, resource_type: str, resource_id: Optional[Any] = None
+    ) -> None:
+        """
+        Check that the caller may read the pairwise results (similarities, detection runs, reports) of a project
+        step, students never being allowed. A result of no project step does not exist, left to fail as not found.
+
+        Raises:
+            AccessDenied: If the caller is a student, or the project step is out of their scope
+        """
+        if self._is_student:
+            self._deny(action, resource_type, resource_id, "Students cannot access the pairwise results")
+        if project_step_uuid is not None:
+            self.check_step(project_step_uuid, action, resource_type, resource_id)
+
+    def check_global(self, action: str, resource_type: str, resource_id: Optional[Any] = None) -> None:
+        """
+        Check that the caller may act on a resource of no project step (webhooks, corpora): admins and services only
+
+        Raises:
+            AccessDenied: If the caller is a grader or a student
+        """
+        if not self.unrestricted:
+            self._deny(action, resource_type, resource_id, "Only the administrators can access this resource")
+
+    def check_admin(self, action: str, resource_type: str, resource_id: Optional[Any] = None) -> None:
+        """
+        Check that the caller is an administrator, for the irreversible operations (purges)
+
+        Raises:
+            AccessDenied: If the caller is not an administrator
+        """
+        if self.principal is not None and self.principal.role != Role.ADMIN:
+            self._deny(action, resource_type, resource_id, "Only the administrators can do this")
+
+    def submission_filters(self) -> Dict[str, Any]:
+        """Filters narrowing a listing of the submissions to those the caller may read"""
+        if self.unrestricted:
+            return {}
+        if self._is_student:
+            filters: Dict[str, Any] = {"owner_uuids": sorted(self.principal.owner_ids, key=str)}
+            if self.principal.assignment_ids:
+                filters["project_step_uuids"] = sorted(self.principal.assignment_ids, key=str)
+            return filters
+        return {"project_step_uuids": sorted(self.principal.assignment_ids, key=str)}
+
+    def tenant_filters(self) -> Dict[str, Any]:
+        """Filters narrowing a listing to the resources of the tenant of the caller"""
+        return {} if self.principal is None else {"tenant_id": self.principal.tenant_id}
+
+    def readable(self, submissions: Iterable[Any]) -> List[Any]:
+        """Submissions the caller may read, the others being left out"""
+        return [submission for submission in submissions if self.can_read_submission(submission)]
+
+    @property
+    def _is_student(self) -> bool:
+        return self.principal is not None and self.principal.role == Role.STUDENT
+
+    def _student_step(self, project_step_uuid: Optional[UUID]) -> bool:
+        """Whether a student may act on a project step: any without assignments in their token"""
+        return not self.principal.assignment_ids or project_step_uuid in self.principal.assignment_ids
+
+    def _check_step_tenant(
+        self, project_step_uuid: Optional[UUID], action: str, resource_type: str, resource_id: Optional[Any]
+    ) -> None:
+        """Check that a project step is not one of another tenant, a step no tenant acted on yet being anyone's"""
+        if self.principal is None or self.step_tenant is None or project_step_uuid is None:
+            return
+        tenant_id = self.step_tenant(project_step_uuid)
+        if tenant_id is not None and tenant_id != self.principal.tenant_id:
+            self._deny_out_of_tenant(action, resource_type, resource_id)
+
+    def _deny_out_of_tenant(self, action: str, resource_type: str, resource_id: Optional[Any]) -> None:
+        self._report(OutOfTenant(self.principal, action, resource_type, resource_id))
+
+    def _deny(self, action: str, resource_type: str, resource_id: Optional[Any], reason: str) -> None:
+        """Deny an action on a resource for the given reason"""
+        self._report(AccessDenied(reason, self.principal, action, resource_type, resource_id))
+
+    def _report(self, denial: AccessDenied) -> None:
+        """Report a denial to the callback, then raise it"""
+        if self.on_denied:
+            try:
+                self.on_denied(denial)
+            except Exception as e:
+                # The denial stands even when it could not be audited
+                logger.error(f"Failed to audit the denied {denial.action} of {self.principal.subject}: {str(e)}")
+        raise denial
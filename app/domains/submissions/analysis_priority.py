@@ -0,0 +1,57 @@
+from datetime import datetime, timedelta
+from typing import Optional
+
+from app.domains.submissions.submissions_models import AnalysisPriority
+
+# Levels of the priorities in the analysis queue, the higher served first
+PRIORITY_LEVELS = {
+    AnalysisPriority.LOW: 0,
+    AnalysisPriority.NORMAL: 1,
+    AnalysisPriority.HIGH: 2,
+    AnalysisPriority.URGENT: 3,
+}
+
+# Priority of a project step from the time left before its deadline, the first threshold reached applying; a step
+# further from its deadline is low, and one without deadline normal
+DEADLINE_PRIORITIES = [
+    (timedelta(days=1), AnalysisPriority.URGENT),
+    (timedelta(days=3), AnalysisPriority.HIGH),
+    (timedelta(days=14), AnalysisPriority.NORMAL),
+]
+
+
+class AnalysisPriorityPolicy:
+    """
+    Priority of the analysis of a submission: the one given on its request, else that of its project step, set
+    explicitly or derived from the grading deadline of the step, so that the step graded tomorrow is not delayed
+    by the bulk import of next month's
+    """
+
+    @staticmethod
+    def for_deadline(deadline: Optional[datetime], now: datetime) -> AnalysisPriority:
+        """Priority of a step from the time left before its deadline, urgent once it is passed"""
+        if deadline is None:
+            return AnalysisPriority.NORMAL
+        if (deadline.tzinfo is None) != (now.tzinfo is None):
+            deadline, now = deadline.replace(tzinfo=None), now.replace(tzinfo=None)
+        left = deadline - now
+        for threshold, priority in DEADLINE_PRIORITIES:
+            if left <= threshold:
+                return priority
+        return AnalysisPriority.LOW
+
+    @classmethod
+    def resolve(
+        cls,
+        explicit: Optional[AnalysisPriority],
+        step_priority: Optional[AnalysisPriority],
+        deadline: Optional[datetime],
+        now: datetime,
+    ) -> AnalysisPriority:
+        """Effective priority, the first given of the request's, the step's and that of the deadline"""
+        return AnalysisPriority(explicit or step_priority or cls.for_deadline(deadline, now))
+
+    @staticmethod
+    def level(priority: AnalysisPriority) -> int:
+        """Level of a priority in the analysis queue"""
+        return PRIORITY_LEVELS[AnalysisPriority(priority)]
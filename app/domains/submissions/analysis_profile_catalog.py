@@ -0,0 +1,159 @@
+import logging
+from typing import Any, Callable, Dict, List, Optional
+
+from app.domains.submissions.analysis_profile_resolver import DEFAULT_PROFILE_NAME, AnalysisProfileResolver
+from app.domains.submissions.submissions_models import SubmissionAnalysisProfile
+from app.shared.exceptions import ConflictException, NotFoundException, ValidationException
+
+logger = logging.getLogger(__name__)
+
+
+class AnalysisProfileCatalog:
+    """
+    Analysis profiles of a tenant, named bundles of detection options the detection runs are started with, after
+    the built-in default one, which is never changed. The options of the profiles are checked with the given
+    function (full options, returning them normalized, raising ValueError if invalid), and resolved with the
+    resolver of the profiles (see AnalysisProfileResolver).
+    """
+
+    def __init__(
+        self,
+        profile_repository: Any,
+        resolver: AnalysisProfileResolver,
+        validate_options: Callable[[Dict[str, Any]], Dict[str, Any]],
+        tenant_id: str,
+    ):
+        self.profile_repository = profile_repository
+        self.resolver = resolver
+        self.validate_options = validate_options
+        self.tenant_id = tenant_id
+
+    def get_all(self) -> List[Dict[str, Any]]:
+        """Get the analysis profiles of the tenant, the built-in default profile first"""
+        return [self.resolver.built_in()] + [
+            self._profile_dict(profile) for profile in self.profile_repository.get_all(self.tenant_id)
+        ]
+
+    def get(self, name: str, tenant_id: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Get an analysis profile of a tenant (this one if None) by name, with its full detection options
+
+        Raises:
+            NotFoundException: If the profile does not exist
+        """
+        if name == DEFAULT_PROFILE_NAME:
+            return self.resolver.built_in()
+        profile = self.profile_repository.get_by_name(name, tenant_id or self.tenant_id)
+        if not profile:
+            raise NotFoundException("Analysis profile", name)
+        return self._profile_dict(profile)
+
+    def create(self, profile_data: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Store a named bundle of detection options, the options not given being those of the default profile
+
+        Raises:
+            ConflictException: If a profile of that name exists in the tenant, the built-in default one included
+            ValidationException: If a detection option is unknown or invalid
+        """
+        name = profile_data["name"]
+        if name == DEFAULT_PROFILE_NAME or self.profile_repository.get_by_name(name, self.tenant_id):
+            message = f"Analysis profile {name} already exists"
+            raise ConflictException(message, details={"error_type": "profile_exists", "message": message})
+        profile = self.profile_repository.create(
+            {
+                **profile_data,
+                "tenant_id": self.tenant_id,
+                "detection_options": self._check_detection_options(profile_data["detection_options"]),
+            }
+        )
+        logger.info(f"Created analysis profile {profile.name}")
+        return self._profile_dict(profile)
+
+    def update(self, name: str, profile_data: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Replace the options of an analysis profile, the runs already started keeping the options they recorded
+
+        Raises:
+            ConflictException: If the profile is the built-in default one
+            ValidationException: If a detection option is unknown or invalid
+        """
+        self._check_not_built_in(name)
+        profile = self.profile_repository.update(
+            name,
+            {**profile_data, "detection_options": self._check_detection_options(profile_data["detection_options"])},
+            self.tenant_id,
+        )
+        logger.info(f"Updated analysis profile {name}")
+        return self._profile_dict(profile)
+
+    def delete(self, name: str) -> bool:
+        """
+        Delete an analysis profile, the runs of the profile keeping the options they recorded
+
+        Raises:
+            ConflictException: If the profile is the built-in default one
+        """
+        self._check_not_built_in(name)
+        return self.profile_repository.delete(name, self.tenant_id)
+
+    def resolve(
+        self, name: Optional[str] = None, overrides: Optional[Dict[str, Any]] = None, tenant_id: Optional[str] = None
+    ) -> Dict[str, Any]:
+        """
+        Get the effective options of a run of an analysis profile (the built-in default one if None) of a tenant
+        (this one if None), overridden by the options given with the run
+
+        Raises:
+            ValidationException: If the profile does not exist, or an override is unknown or invalid
+        """
+        name = name or DEFAULT_PROFILE_NAME
+        try:
+            profile = self.get(name, tenant_id)
+        except NotFoundException:
+            message = f"Analysis profile {name} does not exist"
+            raise ValidationException(
+                message, details={"error_type": "unknown_analysis_profile", "message": message, "profile": name}
+            )
+        try:
+            effective_options = self.resolver.resolve(profile, overrides)
+        except ValueError as e:
+            raise ValidationException(
+                str(e), details={"error_type": "invalid_detection_options", "message": str(e), "profile": name}
+            )
+        effective_options["detection_options"] = self._check_detection_options(effective_options["detection_options"])
+        return effective_options
+
+    def _check_detection_options(self, options: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Get the full detection options of a profile, the given ones over those of the default profile
+
+        Raises:
+            ValidationException: If an option is unknown, or its value invalid
+        """
+        try:
+            return self.validate_options(self.resolver.detection_options(options))
+        except ValueError as e:
+            raise ValidationException(
+                "Invalid detection options", details={"error_type": "invalid_detection_options", "message": str(e)}
+            )
+
+    @staticmethod
+    def _check_not_built_in(name: str) -> None:
+        """
+        Check that an analysis profile may be changed
+
+        Raises:
+            ConflictException: If the profile is the built-in default one
+        """
+        if name == DEFAULT_PROFILE_NAME:
+            message = f"The built-in analysis profile {name} cannot be changed"
+            raise ConflictException(message, details={"error_type": "built_in_profile", "message": message})
+
+    def _profile_dict(self, profile: SubmissionAnalysisProfile) -> Dict[str, Any]:
+        """Stored analysis profile with its full detection options, those added since it was saved included"""
+        return {
+            **profile.model_dump(),
+            "detection_options": self.resolver.detection_options(profile.detection_options or {}),
+            "built_in": False,
+        }
@@ -0,0 +1,79 @@
+from numbers import Number
+from typing import Any, Dict, Optional
+
+# Name of the built-in profile, the options of the service configuration, used by the runs naming no profile
+DEFAULT_PROFILE_NAME = "default"
+
+# Options of a profile flagging the pairs of its runs, the configuration of their project step when None
+FLAGGING_OPTIONS = ("flag_threshold", "min_token_count")
+
+
+class AnalysisProfileResolver:
+    """
+    Resolve the effective options of a detection run: the detection options and flagging of its analysis profile,
+    overridden option by option by those given with the run. The options a stored profile lacks (added to the
+    detection since it was saved) are those of the built-in default profile, so that a profile keeps resolving to a
+    full option set. The effective options are recorded on the run, a later change of its profile leaving them as
+    they were.
+    """
+
+    def __init__(self, default_detection_options: Dict[str, Any]):
+        self.default_detection_options = default_detection_options
+
+    def built_in(self) -> Dict[str, Any]:
+        """The built-in default profile, comparing and flagging as the runs without profile always did"""
+        return {
+            "name": DEFAULT_PROFILE_NAME,
+            "description": "Options of the service configuration",
+            "detection_options": dict(self.default_detection_options),
+            "flag_threshold": None,
+            "min_token_count": None,
+            "built_in": True,
+        }
+
+    def detection_options(self, options: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Full detection options of a profile, the given options over those of the default profile
+
+        Raises:
+            ValueError: If an option is not an option of the detection
+        """
+        unknown = sorted(set(options) - set(self.default_detection_options))
+        if unknown:
+            raise ValueError(f"Unknown detection options: {', '.join(unknown)}")
+        return {**self.default_detection_options, **options}
+
+    def resolve(self, profile: Dict[str, Any], overrides: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        """
+        Effective options of a run of a profile, with the options overridden by the run
+
+        Raises:
+            ValueError: If an override is not an option of the detection nor of the flagging, or a flagging option
+                is out of its range
+        """
+        overrides = overrides or {}
+        flagging = {option: overrides.get(option, profile.get(option)) for option in FLAGGING_OPTIONS}
+        self._check_flagging(flagging)
+        detection_options = {
+            **profile.get("detection_options", {}),
+            **{option: value for option, value in overrides.items() if option not in FLAGGING_OPTIONS},
+        }
+        return {
+            "detection_options": self.detection_options(detection_options),
+            **flagging,
+            "overrides": dict(overrides),
+        }
+
+    @staticmethod
+    def _check_flagging(flagging: Dict[str, Any]) -> None:
+        """Check the range of the flagging options, None leaving them to the project step"""
+        flag_threshold = flagging["flag_threshold"]
+        min_token_count = flagging["min_token_count"]
+        if flag_threshold is not None and not (
+            isinstance(flag_threshold, Number) and not isinstance(flag_threshold, bool) and 0 <= flag_threshold <= 1
+        ):
+            raise ValueError("The flag threshold must be a number between 0 and 1")
+        if min_token_count is not None and not (
+            isinstance(min_token_count, int) and not isinstance(min_token_count, bool) and min_token_count >= 0
+        ):
+            raise ValueError("The minimum token count must be an integer of at least 0")
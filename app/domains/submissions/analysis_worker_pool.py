@@ -0,0 +1,330 @@
+import contextvars
+import itertools
+import logging
+import threading
+import time
+from dataclasses import dataclass
+from enum import Enum
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from app.shared.log_context import log_context
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class QueuedJob:
+    """Job waiting in the queue of the analysis"""
+
+    function: Callable[..., Any]
+    args: Tuple[Any, ...]
+    on_drop: Optional[Callable[[], Any]]  # Run instead of the job if it is dropped
+    priority: int  # Higher first
+    group: Any  # Jobs reprioritized together, such as those of a project step
+    job_id: Any  # Job looked up for its queue position, such as a submission
+    sequence: int  # Order of submission, the older first among the jobs of the same priority
+    queued_at: float
+    context: contextvars.Context  # Context it was queued in, its logs carrying the correlation ID of the request
+
+
+class BackpressurePolicy(str, Enum):
+    """What becomes of an analysis request when the job queue is full"""
+
+    BLOCK = "block"  # The request waits for a free slot, up to the block time
+    REJECT = "reject"  # The request is refused at once
+
+
+class AnalysisQueueFull(Exception):
+    """Raised when the job queue of the analysis stays full, the request being retriable after some time"""
+
+    def __init__(self, message: str, retry_after_seconds: int):
+        super().__init__(message)
+        self.retry_after_seconds = retry_after_seconds
+
+
+class AnalysisPoolDraining(AnalysisQueueFull):
+    """Raised when the analysis is draining before a shutdown, the request being retriable once restarted"""
+
+
+class AnalysisCancelled(Exception):
+    """Raised within a job once the pool is stopped, the job having to record its interruption and return"""
+
+
+class AnalysisWorkerPool:
+    """
+    Fixed number of workers taking the analysis jobs (submission analyses and comparisons) from a bounded queue,
+    so that a burst of submissions waits in the queue rather than in memory without limit. When the queue is full,
+    a job waits for a free slot up to `block_seconds` with the block policy, or is refused at once with the reject
+    policy, raising AnalysisQueueFull.
+
+    Each worker waits for the next job or for the cancellation of the pool, whichever comes first; once the pool is
+    stopped the workers return after their current job, the long jobs checking `cancelled` between their steps.
+    Before a shutdown the pool is drained: the new jobs are refused, and the running ones are given some time to
+    finish before being cancelled. A job dropped from the queue has its `on_drop` callback run instead, for it
+    to be retried at the next start.
+
+    The jobs of higher priority are served first. Not to starve the others, the priority of a queued job rises by
+    one every `aging_seconds` it waits, so that a job waiting long enough is served before newer urgent ones.
+    """
+
+    def __init__(
+        self,
+        worker_count: int = 1,
+        queue_capacity: int = 1_000,
+        policy: BackpressurePolicy = BackpressurePolicy.BLOCK,
+        block_seconds: float = 5.0,
+        retry_after_seconds: int = 30,
+        aging_seconds: float = 300.0,
+        name: str = "analysis",
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        if worker_count < 1 or queue_capacity < 1:
+            raise ValueError("The analysis needs at least one worker and one queue slot")
+        self.worker_count = worker_count
+        self.queue_capacity = queue_capacity
+        self.policy = BackpressurePolicy(policy)
+        self.block_seconds = block_seconds
+        self.retry_after_seconds = retry_after_seconds
+        self.aging_seconds = aging_seconds
+        self.name = name
+        self._clock = clock
+
+        self._jobs: List[QueuedJob] = []
+        self._sequence = itertools.count()
+        self._condition = threading.Condition()
+        self._cancelled = False
+        self._draining = False
+        self._busy_workers = 0
+        self._rejected_count = 0
+        self._workers: List[threading.Thread] = [
+            threading.Thread(target=self._work, args=(index,), name=f"{name}-{index}", daemon=True)
+            for index in range(worker_count)
+        ]
+        for worker in self._workers:
+            worker.start()
+
+    @property
+    def cancelled(self) -> bool:
+        """Whether the pool is stopped, the jobs in progress having to return"""
+        return self._cancelled
+
+    @property
+    def draining(self) -> bool:
+        """Whether the pool is draining before a shutdown, refusing the new jobs"""
+        return self._draining
+
+    def check_cancelled(self) -> None:
+        """
+        Check between the steps of a long job whether the pool is stopped
+
+        Raises:
+            AnalysisCancelled: If the pool is stopped
+        """
+        if self._cancelled:
+            raise AnalysisCancelled("The analysis worker pool is stopped")
+
+    def submit(
+        self,
+        function: Callable[..., Any],
+        *args: Any,
+        on_drop: Optional[Callable[[], Any]] = None,
+        priority: int = 0,
+        group: Any = None,
+        job_id: Any = None,
+    ) -> None:
+        """
+        Queue a job at a priority, applying the backpressure policy when the queue is full, `on_drop` being run
+        instead of the job if it is dropped from the queue at shutdown. The jobs of a group are reprioritized
+        together, and a job with an id can be looked up for its queue position.
+
+        Raises:
+            AnalysisQueueFull: If the queue stays full
+            AnalysisPoolDraining: If the pool is draining
+        """
+        with self._condition:
+            self._wait_for_slot(self._policy_timeout())
+            self._jobs.append(
+                QueuedJob(
+                    function,
+                    args,
+                    on_drop,
+                    priority,
+                    group,
+                    job_id,
+                    next(self._sequence),
+                    self._clock(),
+                    contextvars.copy_context(),
+                )
+            )
+            self._condition.notify_all()
+
+    def reprioritize(self, group: Any, priority: int) -> int:
+        """Set the priority of the queued jobs of a group, re-ordering the queue, returning their number"""
+        with self._condition:
+            jobs = [job for job in self._jobs if group is not None and job.group == group]
+            for job in jobs:
+                job.priority = priority
+            return len(jobs)
+
+    def queue_position(self, job_id: Any) -> Optional[Dict[str, Any]]:
+        """
+        Position (from 1, the next one served) and effective priority, aging included, of a queued job, None if it
+        is not queued
+        """
+        with self._condition:
+            now = self._clock()
+            for position, job in enumerate(sorted(self._jobs, key=lambda job: self._order(job, now)), start=1):
+                if job.job_id == job_id:
+                    return {"position": position, "effective_priority": round(self._effective_priority(job, now), 2)}
+            return None
+
+    def ensure_capacity(self) -> None:
+        """
+        Make sure a job can be queued before taking a request whose analysis will be queued, applying the
+        backpressure policy
+
+        Raises:
+            AnalysisQueueFull: If the queue stays full
+            AnalysisPoolDraining: If the pool is draining
+        """
+        with self._condition:
+            self._wait_for_slot(self._policy_timeout())
+
+    def wait_for_capacity(self, timeout: Optional[float] = None) -> bool:
+        """Wait for a free slot in the queue, without limit if no timeout is given, returning whether there is one"""
+        with self._condition:
+            return self._condition.wait_for(self._has_slot, timeout) and not (self._cancelled or self._draining)
+
+    def stats(self) -> Dict[str, Any]:
+        """Current load of the pool, to tune its size"""
+        with self._condition:
+            return {
+                "worker_count": self.worker_count,
+                "busy_workers": self._busy_workers,
+                "queue_depth": len(self._jobs),
+                "queue_capacity": self.queue_capacity,
+                "backpressure": self.policy.value,
+                "rejected_count": self._rejected_count,
+                "draining": self._draining,
+            }
+
+    def drain(self, timeout: float, cancel_grace_seconds: float = 2.0) -> bool:
+        """
+        Drain the pool before a shutdown: the new jobs are refused and the queued ones dropped, then the running
+        jobs are given up to `timeout` seconds to finish before the pool is stopped, those still running being
+        cancelled and given `cancel_grace_seconds` to record their interruption. Returns whether every running job
+        finished in time.
+        """
+        with self._condition:
+            self._draining = True
+            dropped = list(self._jobs)
+            self._jobs.clear()
+            self._condition.notify_all()
+        self._drop(dropped)
+
+        logger.info(f"Draining the analysis workers, for up to {timeout} seconds")
+        with self._condition:
+            finished = self._condition.wait_for(lambda: self._busy_workers == 0, timeout)
+        if not finished:
+            logger.warning("Cancelling the analysis jobs still running after the drain timeout")
+        self.shutdown(wait=True, timeout=cancel_grace_seconds)
+        return finished
+
+    def shutdown(self, wait: bool = False, timeout: Optional[float] = None) -> None:
+        """Stop the pool: the queued jobs are dropped, and the workers return once their current job is done"""
+        with self._condition:
+            self._cancelled = True
+            dropped = list(self._jobs)
+            self._jobs.clear()
+            self._condition.notify_all()
+        self._drop(dropped)
+        if wait:
+            for worker in self._workers:
+                worker.join(timeout)
+
+    def _work(self, index: int) -> None:
+        """Loop of a worker: run the next job, until the pool is cancelled"""
+        while True:
+            with self._condition:
+                self._condition.wait_for(lambda: self._jobs or self._cancelled)
+                if self._cancelled:
+                    logger.info(f"Analysis worker {index} cancelled")
+                    return
+                now = self._clock()
+                job = min(self._jobs, key=lambda job: self._order(job, now))
+                self._jobs.remove(job)
+                self._busy_workers += 1
+                self._condition.notify_all()
+            try:
+                job.context.run(self._run, job)
+            finally:
+                with self._condition:
+                    self._busy_workers -= 1
+                    self._condition.notify_all()
+
+    def _run(self, job: QueuedJob) -> None:
+        """Run a job in the context it was queued in, logging its start, its end and its duration"""
+        name = getattr(job.function, "__name__", job.function)
+        with log_context(job_id=f"{self.name}-{job.sequence}"):
+            logger.info(f"Analysis job {name} started")
+            started = time.monotonic()
+            try:
+                job.function(*job.args)
+            except Exception as e:
+                logger.error(f"Analysis job {name} failed after {self._elapsed_ms(started)}ms: {str(e)}")
+                return
+            logger.info(f"Analysis job {name} finished in {self._elapsed_ms(started)}ms")
+
+    @staticmethod
+    def _elapsed_ms(started: float) -> float:
+        return round((time.monotonic() - started) * 1000, 2)
+
+    def _effective_priority(self, job: QueuedJob, now: float) -> float:
+        """Priority of a queued job, raised by one every aging period it has waited"""
+        if self.aging_seconds <= 0:
+            return job.priority
+        return job.priority + (now - job.queued_at) / self.aging_seconds
+
+    def _order(self, job: QueuedJob, now: float) -> Tuple[float, int]:
+        """Order the queued jobs are served in, the highest effective priority then the oldest first"""
+        return -self._effective_priority(job, now), job.sequence
+
+    @staticmethod
+    def _drop(jobs: List[QueuedJob]) -> None:
+        """Run the drop callbacks of the jobs dropped from the queue, never raising"""
+        if not jobs:
+            return
+        logger.info(f"Dropped {len(jobs)} queued analysis jobs at shutdown")
+        for job in jobs:
+            if job.on_drop is None:
+                continue
+            try:
+                job.on_drop()
+            except Exception as e:
+                logger.error(f"Failed to record a dropped analysis job: {str(e)}")
+
+    def _policy_timeout(self) -> float:
+        """Time a request waits for a free slot under the backpressure policy"""
+        return self.block_seconds if self.policy == BackpressurePolicy.BLOCK else 0
+
+    def _wait_for_slot(self, timeout: float) -> None:
+        """Wait for a free slot, the condition being held, raising if there is none in time"""
+        self._check_accepting()
+        if not self._condition.wait_for(self._has_slot, timeout):
+            self._rejected_count += 1
+            raise AnalysisQueueFull(
+                f"The analysis queue is full ({self.queue_capacity} jobs)", retry_after_seconds=self.retry_after_seconds
+            )
+        self._check_accepting()
+
+    def _check_accepting(self) -> None:
+        """Raise if the pool takes no more jobs, the condition being held"""
+        if self._draining:
+            raise AnalysisPoolDraining(
+                "The analysis is draining before a shutdown", retry_after_seconds=self.retry_after_seconds
+            )
+        if self._cancelled:
+            raise RuntimeError("The analysis worker pool is stopped")
+
+    def _has_slot(self) -> bool:
+        return len(self._jobs) < self.queue_capacity or self._cancelled or self._draining
@@ -0,0 +1,33 @@
+from datetime import datetime
+from typing import Optional
+
+from app.domains.submissions.submissions_models import AutoRunStatus, SubmissionStepSchedule
+
+
+class AutoRunPolicy:
+    """
+    When the detection run scheduled for a project step is scheduled again as its schedule is saved: saved with
+    another time or profile, even once started, or after its start failed. A started run saved unchanged, e.g. with
+    a new deadline only, is not started again.
+    """
+
+    @classmethod
+    def is_rescheduled(
+        cls, current: Optional[SubmissionStepSchedule], auto_run_at: datetime, auto_run_profile: Optional[str]
+    ) -> bool:
+        """Whether the run of a schedule saved with the given time and profile is scheduled again"""
+        return (
+            current is None
+            or current.auto_run_status in (None, AutoRunStatus.FAILED)
+            or not cls.same_time(current.auto_run_at, auto_run_at)
+            or current.auto_run_profile != auto_run_profile
+        )
+
+    @staticmethod
+    def same_time(stored: Optional[datetime], given: datetime) -> bool:
+        """Whether a time read back from the database is the one given, either without time zone"""
+        if stored is None:
+            return False
+        if (stored.tzinfo is None) != (given.tzinfo is None):
+            stored, given = stored.replace(tzinfo=None), given.replace(tzinfo=None)
+        return stored == given
@@ -0,0 +1,117 @@
+import logging
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional, Tuple
+from uuid import UUID
+
+from fastapi import HTTPException
+
+from app.domains.submissions.auto_run_policy import AutoRunPolicy
+from app.domains.submissions.submissions_models import (
+    AutoRunStatus,
+    ProcessingStatus,
+    SubmissionDetectionRun,
+    SubmissionStepSchedule,
+)
+from app.shared.exceptions import NotFoundException
+
+logger = logging.getLogger(__name__)
+
+# Fields of the detection run scheduled for a project step, reset when it is scheduled again or cancelled
+AUTO_RUN_FIELDS = (
+    "auto_run_at",
+    "auto_run_profile",
+    "auto_run_status",
+    "auto_run_started_at",
+    "auto_run_id",
+    "auto_run_error",
+)
+
+
+class AutoRunScheduler:
+    """
+    Detection runs scheduled for the project steps: the time and analysis profile of the run of a step are saved
+    with its schedule, and the due runs are started over the latest versions of the analyzed submissions of their
+    step, each by a single instance, those missed while the service was down included. The runs are started with
+    the given function (project, step, submissions, profile), as scheduled runs.
+    """
+
+    def __init__(
+        self,
+        schedule_repository: Any,
+        submission_repository: Any,
+        start_run: Callable[[UUID, UUID, List[UUID], Optional[str]], SubmissionDetectionRun],
+    ):
+        self.schedule_repository = schedule_repository
+        self.submission_repository = submission_repository
+        self.start_run = start_run
+
+    @staticmethod
+    def schedule_fields(
+        current: Optional[SubmissionStepSchedule], schedule_data: Dict[str, Any]
+    ) -> Tuple[Dict[str, Any], bool]:
+        """
+        Fields of a schedule to save, and whether its run is scheduled again: its time or profile changed (see
+        AutoRunPolicy), the run being reset, or cancelled without time
+        """
+        auto_run_at, auto_run_profile = schedule_data.get("auto_run_at"), schedule_data.get("auto_run_profile")
+        if auto_run_at is None:
+            return {**schedule_data, **dict.fromkeys(AUTO_RUN_FIELDS)}, False
+        if not AutoRunPolicy.is_rescheduled(current, auto_run_at, auto_run_profile):
+            return schedule_data, False
+        rescheduled = {
+            **schedule_data,
+            **dict.fromkeys(AUTO_RUN_FIELDS),
+            "auto_run_at": auto_run_at,
+            "auto_run_profile": auto_run_profile,
+            "auto_run_status": AutoRunStatus.SCHEDULED,
+        }
+        return rescheduled, True
+
+    def cancel(self, project_uuid: UUID, project_step_uuid: UUID) -> None:
+        """
+        Cancel the detection run scheduled for a project step, a run already started being left running
+
+        Raises:
+            NotFoundException: If no detection run is scheduled for the step
+        """
+        schedule = self.schedule_repository.get_by_project_step(project_uuid, project_step_uuid)
+        if not schedule or schedule.auto_run_at is None:
+            raise NotFoundException("Scheduled detection run of project step", str(project_step_uuid))
+        self.schedule_repository.update(schedule.id, dict.fromkeys(AUTO_RUN_FIELDS))
+        logger.info(f"Cancelled the scheduled detection run of step {project_step_uuid}")
+
+    def start_due(self, now: datetime) -> List[Dict[str, Any]]:
+        """Start the detection runs whose scheduled time has passed, returning the outcome of each run started"""
+        outcomes = []
+        for schedule in self.schedule_repository.get_due(now):
+            # Started by another instance, or rescheduled, meanwhile
+            if self.schedule_repository.claim_auto_run(schedule.id, schedule.auto_run_at):
+                outcomes.append(self._start(schedule))
+        return outcomes
+
+    def _start(self, schedule: SubmissionStepSchedule) -> Dict[str, Any]:
+        """
+        Start the scheduled detection run of a project step over the latest versions of its analyzed submissions,
+        attached to the run of the step and profile already running if any, its failure being recorded on the schedule
+        """
+        project_uuid, project_step_uuid = schedule.project_uuid, schedule.project_step_uuid
+        submissions = self.submission_repository.get_by_project_step(
+            project_uuid, project_step_uuid, latest_versions_only=True, with_files=True
+        )
+        submission_ids = [s.id for s in submissions if s.processing_status == ProcessingStatus.ANALYZED]
+        try:
+            run = self.start_run(project_uuid, project_step_uuid, submission_ids, schedule.auto_run_profile)
+        except Exception as e:
+            error = str(e.detail) if isinstance(e, HTTPException) else str(e)
+            logger.error(f"Failed to start the scheduled detection run of step {project_step_uuid}: {error}")
+            self.schedule_repository.update(
+                schedule.id, {"auto_run_status": AutoRunStatus.FAILED, "auto_run_error": error}
+            )
+            return {"project_step_uuid": project_step_uuid, "status": AutoRunStatus.FAILED.value, "error": error}
+
+        self.schedule_repository.update(schedule.id, {"auto_run_id": run.id})
+        logger.info(
+            f"Started scheduled detection run {run.id} of step {project_step_uuid} "
+            f"over {len(submission_ids)} analyzed submissions"
+        )
+        return {"project_step_uuid": project_step_uuid, "status": AutoRunStatus.STARTED.value, "run_id": run.id}
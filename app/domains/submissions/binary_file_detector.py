@@ -0,0 +1,93 @@
+from pathlib import Path
+from typing import Optional, Tuple
+
+from app.domains.submissions.encoding_detector import utf16_byte_order
+
+# Signatures of the common binary formats found in the submissions, by the bytes their content starts with. PDFs
+# are binary whatever their mostly textual header, their content being compressed streams.
+MAGIC_SIGNATURES: Tuple[Tuple[bytes, str], ...] = (
+    (b"\x7fELF", "elf"),
+    (b"\xfe\xed\xfa\xce", "mach-o"),
+    (b"\xfe\xed\xfa\xcf", "mach-o"),
+    (b"\xce\xfa\xed\xfe", "mach-o"),
+    (b"\xcf\xfa\xed\xfe", "mach-o"),
+    (b"\xca\xfe\xba\xbe", "java-class"),  # Also the universal Mach-O binaries
+    (b"\x00asm", "wasm"),
+    (b"%PDF-", "pdf"),
+    (b"\x89PNG\r\n\x1a\n", "png"),
+    (b"\xff\xd8\xff", "jpeg"),
+    (b"GIF87a", "gif"),
+    (b"GIF89a", "gif"),
+    (b"II*\x00", "tiff"),
+    (b"MM\x00*", "tiff"),
+    (b"\x00\x00\x01\x00", "ico"),
+    (b"PK\x03\x04", "zip"),  # JAR, docx, xlsx and the other ZIP-based formats
+    (b"PK\x05\x06", "zip"),
+    (b"\x1f\x8b", "gzip"),
+    (b"\xfd7zXZ\x00", "xz"),
+    (b"7z\xbc\xaf\x27\x1c", "7z"),
+    (b"Rar!\x1a\x07", "rar"),
+    (b"SQLite format 3\x00", "sqlite"),
+    (b"\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "ole"),  # Legacy Office documents
+    (b"OggS", "ogg"),
+    (b"fLaC", "flac"),
+    (b"wOFF", "woff"),
+    (b"wOF2", "woff2"),
+)
+# Signatures short enough to begin a text, recognized only when the content is binary by the heuristics too
+WEAK_SIGNATURES: Tuple[Tuple[bytes, str], ...] = ((b"MZ", "pe"), (b"BM", "bmp"), (b"BZh", "bzip2"), (b"ID3", "mp3"))
+# RIFF containers, by the four bytes of their form type
+RIFF_FORMATS = {b"WEBP": "webp", b"WAVE": "wav", b"AVI ": "avi"}
+# Byte order marks of the Unicode texts, the NUL bytes of the UTF-16 and UTF-32 ones being no sign of a binary
+TEXT_BOMS = (b"\xff\xfe", b"\xfe\xff", b"\xef\xbb\xbf")
+
+# Bytes of the start of a file its content is classified by
+SNIFF_BYTES = 8192
+# Share of control characters (other than tabs, line breaks, form feeds and escapes) over which a content is binary
+MAX_CONTROL_RATIO = 0.3
+TEXT_CONTROL_BYTES = {0x08, 0x09, 0x0A, 0x0C, 0x0D, 0x1B}
+
+
+class BinaryFileDetector:
+    """
+    Classify the files of a submission as binary (compiled code, images, documents, archives...): by the magic
+    bytes of the common formats, else by the NUL bytes or the share of control characters of their start. The
+    binary files are recorded as such and left out of the tokenization and the comparisons, where they would
+    only be tokenized as garbage.
+    """
+
+    def detect(self, content: bytes) -> Optional[str]:
+        """Format of a binary content ("binary" if no signature is recognized), None for a text"""
+        head = content[:SNIFF_BYTES]
+        for signature, binary_format in MAGIC_SIGNATURES:
+            if head.startswith(signature):
+                return binary_format
+        if head.startswith(b"RIFF") and head[8:12] in RIFF_FORMATS:
+            return RIFF_FORMATS[head[8:12]]
+        if not head or head.startswith(TEXT_BOMS):
+            return None
+        if not self._looks_binary(head):
+            return None
+        for signature, binary_format in WEAK_SIGNATURES:
+            if head.startswith(signature):
+                return binary_format
+        return "binary"
+
+    @staticmethod
+    def _looks_binary(head: bytes) -> bool:
+        """
+        Whether the start of a content holds NUL bytes, or too many control characters for a text, the NUL bytes of
+        a UTF-16 text without byte order mark excepted
+        """
+        if b"\x00" in head:
+            return utf16_byte_order(head) is None
+        control_count = sum(1 for byte in head if byte < 0x20 and byte not in TEXT_CONTROL_BYTES)
+        return control_count / len(head) > MAX_CONTROL_RATIO
+
+    def detect_file(self, file_path: Path) -> Optional[str]:
+        """Format of a binary file, reading only its start, None for a text file or a file that cannot be read"""
+        try:
+            with open(file_path, "rb") as file:
+                return self.detect(file.read(SNIFF_BYTES))
+        except OSError:
+            return None
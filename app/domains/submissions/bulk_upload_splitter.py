@@ -0,0 +1,119 @@
+import re
+import uuid
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Tuple
+from uuid import UUID
+
+from app.domains.repositories.archive_extractor import ArchiveExtractionResult, ArchiveFile
+
+# Naming pattern of the directories of the students, `{placeholders}` matching a part of the name
+DEFAULT_DIRECTORY_PATTERN = "{id}"
+DIRECTORY_PLACEHOLDER_PATTERN = re.compile(r"\{(\w+)\}")
+STUDENT_ID_PLACEHOLDER = "id"
+
+
+@dataclass
+class BulkUploadEntry:
+    """Top-level directory of a bulk upload, the submission of one student"""
+
+    directory: str
+    fields: Dict[str, str] = field(default_factory=dict)  # Value of each placeholder of the naming pattern
+    files: List[ArchiveFile] = field(default_factory=list)  # Files at their path relative to the directory
+    skipped_entries: List[Dict[str, str]] = field(default_factory=list)
+    error: Optional[str] = None  # Reason no submission is created for the directory
+
+    @property
+    def student_id(self) -> Optional[str]:
+        return self.fields.get(STUDENT_ID_PLACEHOLDER)
+
+
+class BulkUploadSplitter:
+    """
+    Split the archive of a bulk upload, as exported by a learning management system, into one entry per top-level
+    directory: each directory holds the submission of a student, and is named after a pattern such as
+    `{lastname}_{firstname}_{id}`, whose `{id}` placeholder is the student identifier. A single directory wrapping
+    all the others is looked into. The directories not matching the pattern, or holding no file, are reported with
+    their error instead of failing the whole upload.
+    """
+
+    def __init__(self, directory_pattern: str = DEFAULT_DIRECTORY_PATTERN):
+        placeholders = DIRECTORY_PLACEHOLDER_PATTERN.findall(directory_pattern)
+        if STUDENT_ID_PLACEHOLDER not in placeholders:
+            raise ValueError(f"The directory pattern {directory_pattern!r} has no {{id}} placeholder")
+        if len(set(placeholders)) != len(placeholders):
+            raise ValueError(f"The directory pattern {directory_pattern!r} repeats a placeholder")
+
+        self.directory_pattern = directory_pattern
+        literals = DIRECTORY_PLACEHOLDER_PATTERN.split(directory_pattern)[::2]
+        regex = "".join(
+            re.escape(literal) + (f"(?P<{placeholders[i]}>.+)" if i < len(placeholders) else "")
+            for i, literal in enumerate(literals)
+        )
+        self.directory_regex = re.compile(regex)
+
+    def match(self, directory: str) -> Optional[Dict[str, str]]:
+        """Value of each placeholder of the pattern in the name of a directory, None if it does not match"""
+        match = self.directory_regex.fullmatch(directory)
+        if not match or not match.group(STUDENT_ID_PLACEHOLDER).strip():
+            return None
+        return {name: value.strip() for name, value in match.groupdict().items()}
+
+    def split(self, extraction: ArchiveExtractionResult) -> Tuple[List[BulkUploadEntry], List[str]]:
+        """Entries of the top-level directories, by name, and the paths of the files outside of any directory"""
+        files, skipped_entries = extraction.files, extraction.skipped_entries
+        prefix = self._wrapping_directory(files)
+        if prefix:
+            files = [self._relative(file, prefix) for file in files]
+            skipped_entries = [
+                {**entry, "path": entry["path"][len(prefix) + 1 :]}
+                for entry in skipped_entries
+                if entry["path"].startswith(f"{prefix}/")
+            ]
+
+        entries: Dict[str, BulkUploadEntry] = {}
+        root_files = []
+        for file in files:
+            directory, _, path = file.path.partition("/")
+            if not path:
+                root_files.append(file.path)
+                continue
+            entry = entries.setdefault(directory, BulkUploadEntry(directory=directory))
+            entry.files.append(self._relative(file, directory))
+        for skipped in skipped_entries:
+            directory, _, path = skipped["path"].partition("/")
+            if path:
+                entry = entries.setdefault(directory, BulkUploadEntry(directory=directory))
+                entry.skipped_entries.append({**skipped, "path": path})
+
+        for entry in entries.values():
+            fields = self.match(entry.directory)
+            if fields is None:
+                entry.error = f"The directory name does not match the pattern {self.directory_pattern}"
+            elif not entry.files:
+                entry.error = "The directory holds no file to submit"
+            entry.fields = fields or {}
+        return [entries[directory] for directory in sorted(entries)], root_files
+
+    @staticmethod
+    def student_uuid(student_id: str, namespace: UUID) -> UUID:
+        """UUID of a student: the identifier itself when it is a UUID, else derived from it within the namespace"""
+        try:
+            return UUID(student_id)
+        except ValueError:
+            return uuid.uuid5(namespace, student_id)
+
+    def _wrapping_directory(self, files: List[ArchiveFile]) -> Optional[str]:
+        """Directory all the directories of the students are in, if any: one not matching the pattern itself"""
+        if not files or any(file.path.count("/") < 2 for file in files):
+            return None
+        directories = {file.path.split("/")[0] for file in files}
+        if len(directories) != 1:
+            return None
+        directory = directories.pop()
+        return None if self.match(directory) is not None else directory
+
+    @staticmethod
+    def _relative(file: ArchiveFile, directory: str) -> ArchiveFile:
+        """File at its path relative to a directory of the archive"""
+        archive = file.archive[len(directory) + 1 :] if file.archive else None
+        return ArchiveFile(path=file.path[len(directory) + 1 :], content=file.content, archive=archive)
@@ -0,0 +1,94 @@
+import hashlib
+import io
+import logging
+from contextlib import closing
+from typing import Iterable, List, Tuple
+from uuid import UUID
+
+from app.domains.repositories.object_storage import STREAM_BLOCK_BYTES, ObjectStorage
+
+logger = logging.getLogger(__name__)
+
+
+class ChunkConflictError(ValueError):
+    """Raised when a chunk already received is uploaded again with another content"""
+
+
+class ChunkedUploadStore:
+    """
+    Chunks of the resumable uploads, kept in the object storage under the prefix, one object per chunk keyed by
+    its upload session, until the upload is finalized. A chunk is either whole or missing, and uploading a received
+    chunk again with the same content changes nothing.
+    """
+
+    def __init__(self, storage: ObjectStorage, prefix: str = "chunked-uploads/"):
+        self.storage = storage
+        self.prefix = prefix
+
+    def write_chunk(self, upload_id: UUID, index: int, content: bytes) -> bool:
+        """
+        Keep a chunk of an upload, returning whether it was new
+
+        Raises:
+            ChunkConflictError: If the chunk was received with another content
+        """
+        key = self._chunk_key(upload_id, index)
+        if self.storage.exists(key):
+            if self.checksum(self.storage.read_bytes(key)) != self.checksum(content):
+                raise ChunkConflictError(f"Chunk {index} was already received with another content")
+            return False
+
+        self.storage.put(key, io.BytesIO(content))
+        return True
+
+    def received_chunks(self, upload_id: UUID) -> List[int]:
+        """Indexes of the chunks received for an upload, in order"""
+        upload_prefix = self._upload_prefix(upload_id)
+        names = (key[len(upload_prefix) :] for key in self.storage.list(upload_prefix))
+        return sorted(int(name) for name in names if name.isdigit())
+
+    def missing_chunks(self, upload_id: UUID, chunk_count: int) -> List[int]:
+        """Indexes of the chunks of an upload not received yet"""
+        received = set(self.received_chunks(upload_id))
+        return [index for index in range(chunk_count) if index not in received]
+
+    def assemble(self, upload_id: UUID, chunk_count: int) -> Tuple[bytes, str]:
+        """Content of a whole upload from its chunks, each read as a stream, and its SHA-256 checksum"""
+        digest = hashlib.sha256()
+        content = io.BytesIO()
+        for index in range(chunk_count):
+            with closing(self.storage.get(self._chunk_key(upload_id, index))) as stream:
+                while block := stream.read(STREAM_BLOCK_BYTES):
+                    digest.update(block)
+                    content.write(block)
+        return content.getvalue(), digest.hexdigest()
+
+    def delete(self, upload_id: UUID) -> None:
+        """Delete the chunks of an upload"""
+        self.storage.delete_prefix(self._upload_prefix(upload_id))
+
+    def orphaned_uploads(self, known_upload_ids: Iterable[UUID]) -> List[str]:
+        """Uploads without a session having chunks"""
+        known = {str(upload_id) for upload_id in known_upload_ids}
+        uploads = {key[len(self.prefix) :].split("/", 1)[0] for key in self.storage.list(self.prefix)}
+        return sorted(uploads - known)
+
+    def delete_orphaned(self, known_upload_ids: Iterable[UUID]) -> int:
+        """Delete the chunks of the uploads without a session, returning the number of uploads"""
+        orphaned = self.orphaned_uploads(known_upload_ids)
+        for name in orphaned:
+            self.storage.delete_prefix(f"{self.prefix}{name}/")
+        if orphaned:
+            logger.info(f"Deleted the chunks of {len(orphaned)} orphaned uploads")
+        return len(orphaned)
+
+    @staticmethod
+    def checksum(content: bytes) -> str:
+        """SHA-256 checksum of a content, in hexadecimal"""
+        return hashlib.sha256(content).hexdigest()
+
+    def _upload_prefix(self, upload_id: UUID) -> str:
+        return f"{self.prefix}{upload_id}/"
+
+    def _chunk_key(self, upload_id: UUID, index: int) -> str:
+        return f"{self._upload_prefix(upload_id)}{index}"
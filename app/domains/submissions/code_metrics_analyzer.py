@@ -0,0 +1,255 @@
+from typing import Any, Dict, List, Optional, Set, Tuple
+
+from app.domains.detection.reachability_analyzer import FUNCTION_NAME_PATTERNS
+
+# Token types of the comments, whatever the language
+COMMENT_TYPES = {"comment", "line_comment", "block_comment", "multiline_comment", "doc_comment"}
+
+# Function definition kinds, per language
+FUNCTION_TYPES = {
+    "go": {"function_declaration", "method_declaration"},
+    "python": {"function_definition", "async_function_definition"},
+    "javascript": {"function_declaration", "generator_function_declaration", "method_definition"},
+    "typescript": {"function_declaration", "generator_function_declaration", "method_definition"},
+    "java": {"method_declaration", "constructor_declaration"},
+    "c": {"function_definition"},
+    "cpp": {"function_definition"},
+}
+
+# Decision points adding a path to the cyclomatic complexity of a function, per language
+DECISION_TYPES = {
+    "go": {"if_statement", "for_statement", "expression_case", "type_case", "communication_case", "select_case"},
+    "python": {
+        "if_statement",
+        "elif_clause",
+        "for_statement",
+        "async_for_statement",
+        "while_statement",
+        "except_clause",
+        "conditional_expression",
+        "boolean_operator",
+        "case_clause",
+    },
+    "javascript": {
+        "if_statement",
+        "for_statement",
+        "for_in_statement",
+        "while_statement",
+        "do_statement",
+        "switch_case",
+        "catch_clause",
+        "ternary_expression",
+    },
+    "java": {
+        "if_statement",
+        "for_statement",
+        "enhanced_for_statement",
+        "while_statement",
+        "do_statement",
+        "switch_label",
+        "catch_clause",
+        "ternary_expression",
+    },
+    "c": {
+        "if_statement",
+        "for_statement",
+        "while_statement",
+        "do_statement",
+        "case_statement",
+        "conditional_expression",
+    },
+    "cpp": {
+        "if_statement",
+        "for_statement",
+        "for_range_loop",
+        "while_statement",
+        "do_statement",
+        "case_statement",
+        "conditional_expression",
+        "catch_clause",
+    },
+}
+DECISION_TYPES["typescript"] = DECISION_TYPES["javascript"]
+
+
+class CodeMetricsAnalyzer:
+    """
+    Compute quick quality signals of the files of a submission from their content and tokens:
+    - physical lines, blank lines, comment lines (only comments on them) and code lines (the logical lines of
+      code: neither blank nor comment only), the comment ratio being the share of the non-blank lines that are
+      comment lines;
+    - the functions of the languages whose function kinds are known, with their length in lexical tokens (the
+      tokens of the stream nesting no other token, comments excluded);
+    - their cyclomatic complexity for the languages whose decision points are known: 1 plus the number of
+      decision tokens (if, loops, cases, catches, conditional expressions) within the function, those of the
+      anonymous functions counting for the function they are defined in.
+    """
+
+    def analyze_file(
+        self, file: str, content: str, tokens: List[Dict[str, Any]], language: Optional[str]
+    ) -> Dict[str, Any]:
+        """Get the metrics of a file, from its content and its tokens"""
+        lines = content.splitlines()
+        blank = {number for number, line in enumerate(lines) if not line.strip()}
+        comment_only = self._comment_lines(lines, tokens) - blank
+        code_lines = len(lines) - len(blank) - len(comment_only)
+        functions = self._functions(tokens, language)
+        complexities = [function["complexity"] for function in functions if function["complexity"] is not None]
+        return {
+            "file": file,
+            "language": language,
+            "physical_lines": len(lines),
+            "blank_lines": len(blank),
+            "comment_lines": len(comment_only),
+            "code_lines": code_lines,
+            "comment_ratio": self._ratio(len(comment_only), code_lines + len(comment_only)),
+            "function_count": len(functions),
+            "average_function_tokens": self._average([function["tokens"] for function in functions]),
+            "cyclomatic_complexity": sum(complexities) if language in DECISION_TYPES else None,
+            "max_complexity": max(complexities, default=None),
+            "functions": functions,
+        }
+
+    def merge_chunks(
+        self, file: str, language: Optional[str], chunks: List[Tuple[int, Dict[str, Any]]]
+    ) -> Dict[str, Any]:
+        """
+        Get the metrics of a file analyzed in chunks of whole lines, from those of each chunk with the line it
+        begins at, the lines of its functions being shifted to those of the file
+        """
+        functions = [
+            {**function, "start_line": function["start_line"] + offset, "end_line": function["end_line"] + offset}
+            for offset, metrics in chunks
+            for function in metrics["functions"]
+        ]
+        comment_lines = sum(metrics["comment_lines"] for _, metrics in chunks)
+        code_lines = sum(metrics["code_lines"] for _, metrics in chunks)
+        complexities = [function["complexity"] for function in functions if function["complexity"] is not None]
+        return {
+            "file": file,
+            "language": language,
+            "physical_lines": sum(metrics["physical_lines"] for _, metrics in chunks),
+            "blank_lines": sum(metrics["blank_lines"] for _, metrics in chunks),
+            "comment_lines": comment_lines,
+            "code_lines": code_lines,
+            "comment_ratio": self._ratio(comment_lines, code_lines + comment_lines),
+            "function_count": len(functions),
+            "average_function_tokens": self._average([function["tokens"] for function in functions]),
+            "cyclomatic_complexity": sum(complexities) if language in DECISION_TYPES else None,
+            "max_complexity": max(complexities, default=None),
+            "functions": functions,
+        }
+
+    def aggregate(self, files: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """Get the metrics of a submission from those of its files"""
+        comment_lines = sum(file["comment_lines"] for file in files)
+        code_lines = sum(file["code_lines"] for file in files)
+        functions = [function for file in files for function in file["functions"]]
+        complexities = [file["cyclomatic_complexity"] for file in files if file["cyclomatic_complexity"] is not None]
+        languages: Dict[str, int] = {}
+        for file in files:
+            if file["language"]:
+                languages[file["language"]] = languages.get(file["language"], 0) + 1
+        return {
+            "file_count": len(files),
+            "languages": languages,
+            "physical_lines": sum(file["physical_lines"] for file in files),
+            "blank_lines": sum(file["blank_lines"] for file in files),
+            "comment_lines": comment_lines,
+            "code_lines": code_lines,
+            "comment_ratio": self._ratio(comment_lines, code_lines + comment_lines),
+            "function_count": len(functions),
+            "average_function_tokens": self._average([function["tokens"] for function in functions]),
+            "cyclomatic_complexity": sum(complexities) if complexities else None,
+            "max_complexity": max(
+                (file["max_complexity"] for file in files if file["max_complexity"] is not None), default=None
+            ),
+        }
+
+    @staticmethod
+    def _comment_lines(lines: List[str], tokens: List[Dict[str, Any]]) -> Set[int]:
+        """Lines holding nothing but comments (and whitespace)"""
+        covered: Dict[int, List[Tuple[int, Optional[int]]]] = {}
+        for token in tokens:
+            if token.get("type") not in COMMENT_TYPES:
+                continue
+            for line in range(token["start"], token["end"] + 1):
+                start = token.get("start_column", 0) if line == token["start"] else 0
+                end = token.get("end_column") if line == token["end"] else None
+                covered.setdefault(line, []).append((start or 0, end))
+
+        comment_lines = set()
+        for line, ranges in covered.items():
+            if line >= len(lines):
+                continue
+            remaining = bytearray(lines[line].encode("utf8"))
+            for start, end in ranges:
+                end = len(remaining) if end is None else min(end, len(remaining))
+                remaining[start:end] = b" " * max(end - start, 0)
+            if not remaining.strip():
+                comment_lines.add(line)
+        return comment_lines
+
+    def _functions(self, tokens: List[Dict[str, Any]], language: Optional[str]) -> List[Dict[str, Any]]:
+        """Functions of a token stream, with their length in lexical tokens and their complexity"""
+        function_types = FUNCTION_TYPES.get(language or "")
+        if not function_types:
+            return []
+        decision_types = DECISION_TYPES.get(language or "")
+        name_pattern = FUNCTION_NAME_PATTERNS.get(language or "")
+        lexical = self._lexical_indexes(tokens)
+
+        functions = []
+        for index, token in enumerate(tokens):
+            if token.get("type") not in function_types:
+                continue
+            # The tokens of a function follow it in the depth first stream
+            inner = []
+            for other in range(index + 1, len(tokens)):
+                if not self._within(tokens[other], token):
+                    break
+                inner.append(other)
+            match = name_pattern.match(token.get("text", "")) if name_pattern else None
+            decisions = sum(1 for other in inner if tokens[other].get("type") in (decision_types or ()))
+            functions.append(
+                {
+                    "name": match.group(1) if match else None,
+                    "start_line": token["start"],
+                    "end_line": token["end"],
+                    "tokens": len([other for other in inner if other in lexical]),
+                    "complexity": 1 + decisions if decision_types else None,
+                }
+            )
+        return functions
+
+    @classmethod
+    def _lexical_indexes(cls, tokens: List[Dict[str, Any]]) -> Set[int]:
+        """
+        Indexes of the tokens nesting no other token, comments excluded: the stream lists the tokens depth first,
+        a token followed by a token it does not contain is a leaf of the syntax tree
+        """
+        indexes = set()
+        for index, token in enumerate(tokens):
+            if token.get("type") in COMMENT_TYPES:
+                continue
+            following = tokens[index + 1] if index + 1 < len(tokens) else None
+            if following is None or not cls._within(following, token):
+                indexes.add(index)
+        return indexes
+
+    @staticmethod
+    def _within(token: Dict[str, Any], container: Dict[str, Any]) -> bool:
+        """Whether a token lies within the span of another one"""
+        start = (token["start"], token.get("start_column") or 0)
+        end = (token["end"], token.get("end_column") or 0)
+        container_start = (container["start"], container.get("start_column") or 0)
+        container_end = (container["end"], container.get("end_column") or 0)
+        return container_start <= start and end <= container_end
+
+    @staticmethod
+    def _ratio(part: int, total: int) -> float:
+        return round(part / total, 3) if total else 0.0
+
+    @staticmethod
+    def _average(values: List[int]) -> Optional[float]:
+        return round(sum(values) / len(values), 1) if values else None
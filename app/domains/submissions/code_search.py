@@ -0,0 +1,131 @@
+from collections import defaultdict
+from typing import Any, Dict, Iterable, List, Sequence
+
+from app.domains.detection.winnowing import DEFAULT_KGRAM_SIZE, DEFAULT_WINDOW_SIZE, Fingerprint
+
+# Minimum number of consecutive matching tokens of a fragment reported by a search
+DEFAULT_SEARCH_MIN_TOKENS = 12
+
+
+class CodeSearch:
+    """
+    Search the submissions for the fragments of a code snippet through an inverted index of their fingerprints,
+    built when they are analyzed: each winnowed fingerprint of a file is stored with its position in the token
+    stream of the file and its lines, so that a query only reads the entries of the fingerprints of the snippet.
+
+    A fragment is a run of fingerprints shared at the same offset between the snippet and a file: winnowing selects
+    at least one fingerprint of every `window_size` consecutive k-grams, so the fingerprints of a shared run are at
+    most `window_size` tokens apart. A fragment spans its first to its last shared fingerprint, so its bounds may
+    fall a few tokens within the copied run. Only the fragments of at least `min_tokens` tokens are reported.
+    """
+
+    def __init__(
+        self,
+        min_tokens: int = DEFAULT_SEARCH_MIN_TOKENS,
+        kgram_size: int = DEFAULT_KGRAM_SIZE,
+        window_size: int = DEFAULT_WINDOW_SIZE,
+    ):
+        self.min_tokens = min_tokens
+        self.kgram_size = kgram_size
+        self.window_size = window_size
+
+    @staticmethod
+    def index_entries(file_path: str, fingerprints: Sequence[Fingerprint]) -> List[Dict[str, Any]]:
+        """Entries of the inverted index for the fingerprints of a file (SubmissionFingerprint shape)"""
+        return [
+            {
+                "file_path": file_path,
+                "hash": f"{fingerprint.hash:016x}",
+                "position": fingerprint.position,
+                "start_line": fingerprint.start,
+                "end_line": fingerprint.end,
+            }
+            for fingerprint in fingerprints
+        ]
+
+    @staticmethod
+    def hashes(fingerprints: Sequence[Fingerprint]) -> List[str]:
+        """Distinct hashes of fingerprints, as stored in the index"""
+        return sorted({f"{fingerprint.hash:016x}" for fingerprint in fingerprints})
+
+    def find(self, query: Sequence[Fingerprint], entries: Iterable[Any]) -> List[Dict[str, Any]]:
+        """
+        Get the fragments of the snippet found in the index entries read for its hashes (with `submission_id`,
+        `file_path`, `hash`, `position`, `start_line` and `end_line`), longest first
+        """
+        query_by_hash = defaultdict(list)
+        for fingerprint in query:
+            query_by_hash[f"{fingerprint.hash:016x}"].append(fingerprint)
+
+        # Hits of the same file at the same offset from the snippet belong to the same copied run
+        diagonals = defaultdict(list)
+        for entry in entries:
+            for fingerprint in query_by_hash.get(entry.hash, []):
+                offset = entry.position - fingerprint.position
+                diagonals[(entry.submission_id, entry.file_path, offset)].append((fingerprint, entry))
+
+        fragments = []
+        for (submission_id, file_path, _), hits in diagonals.items():
+            hits.sort(key=lambda hit: hit[1].position)
+            run = [hits[0]]
+            for hit in hits[1:]:
+                if hit[1].position - run[-1][1].position > self.window_size:
+                    self._add_fragment(fragments, submission_id, file_path, run)
+                    run = []
+                run.append(hit)
+            self._add_fragment(fragments, submission_id, file_path, run)
+        return sorted(fragments, key=lambda fragment: (-fragment["tokens"], fragment["start_line"]))
+
+    @staticmethod
+    def find_substring(snippet: str, submission_id: Any, file_path: str, content: str) -> List[Dict[str, Any]]:
+        """Get the occurrences of a snippet in the raw content of a file, its surrounding blank lines ignored"""
+        snippet = snippet.strip("\n")
+        fragments = []
+        if not snippet.strip():
+            return fragments
+        index = content.find(snippet)
+        while index != -1:
+            start_line = content.count("\n", 0, index)
+            fragments.append(
+                {
+                    "submission_id": submission_id,
+                    "file_path": file_path,
+                    "start_line": start_line,
+                    "end_line": start_line + snippet.count("\n"),
+                    "tokens": None,
+                    "snippet_start_line": 0,
+                    "snippet_end_line": snippet.count("\n"),
+                }
+            )
+            index = content.find(snippet, index + len(snippet))
+        return fragments
+
+    @staticmethod
+    def by_submission(fragments: Iterable[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Group fragments per submission, the submissions sharing the most tokens (else fragments) first"""
+        matches: Dict[Any, Dict[str, Any]] = {}
+        for fragment in fragments:
+            match = matches.setdefault(
+                fragment["submission_id"],
+                {"submission_id": fragment["submission_id"], "matched_tokens": 0, "fragments": []},
+            )
+            match["matched_tokens"] += fragment["tokens"] or 0
+            match["fragments"].append({key: value for key, value in fragment.items() if key != "submission_id"})
+        return sorted(matches.values(), key=lambda match: (-match["matched_tokens"], -len(match["fragments"])))
+
+    def _add_fragment(self, fragments: List[Dict[str, Any]], submission_id: Any, file_path: str, run: List) -> None:
+        """Report a run of hits as a fragment, if it spans enough tokens"""
+        tokens = run[-1][1].position - run[0][1].position + self.kgram_size
+        if tokens < self.min_tokens:
+            return
+        fragments.append(
+            {
+                "submission_id": submission_id,
+                "file_path": file_path,
+                "start_line": min(entry.start_line for _, entry in run),
+                "end_line": max(entry.end_line for _, entry in run),
+                "tokens": tokens,
+                "snippet_start_line": min(fingerprint.start for fingerprint, _ in run),
+                "snippet_end_line": max(fingerprint.end for fingerprint, _ in run),
+            }
+        )
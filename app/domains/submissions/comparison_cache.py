@@ -0,0 +1,74 @@
+import hashlib
+import json
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+from uuid import UUID
+
+from app.domains.submissions.submissions_models import SimilarityStatus, Submission, SubmissionSimilarity
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+
+class ComparisonCache:
+    """
+    Key the comparisons of the pairs of submissions, so that a detection run reuses those of the earlier runs rather
+    than comparing all of its pairs again. The key of a comparison is the SHA-256 of the content hashes of both
+    submissions (their analyzed files by relative path, with the tokenizer version they were analyzed with), in
+    the order of the comparison, and of its options: the tokenizer version and the detection and tokenization
+    options, with the fingerprints of the baselines and allowed snippets of the step. A change of any of them
+    changes the keys, the cached comparisons being stale. The comparisons of a tenant other than the default one
+    (whose keys predate the tenants) are keyed apart, never shared with another.
+    """
+
+    def __init__(self, options: Dict[str, Any], references: Iterable[Any], tenant_id: Optional[str] = None):
+        if tenant_id is not None and tenant_id != DEFAULT_TENANT_ID:
+            options = {**options, "tenant_id": tenant_id}
+        # The references are sorted, their order of creation making no difference
+        canonical = json.dumps(
+            {
+                "options": options,
+                "references": sorted(json.dumps(reference, sort_keys=True, default=str) for reference in references),
+            },
+            sort_keys=True,
+            default=str,
+        )
+        self.options_hash = hashlib.sha256(canonical.encode("utf8")).hexdigest()
+
+    @staticmethod
+    def content_hash(analyzed_files: Optional[Dict[str, Any]]) -> Optional[str]:
+        """Hash of the analyzed files of a submission, None if it was analyzed before they were recorded"""
+        if not analyzed_files:
+            return None
+        return hashlib.sha256(json.dumps(analyzed_files, sort_keys=True).encode("utf8")).hexdigest()
+
+    def key(self, first: Submission, second: Submission) -> Optional[str]:
+        """Key of the comparison of a submission with another one, None if either has no content hash"""
+        return self._key(self.content_hash(first.analyzed_files), self.content_hash(second.analyzed_files))
+
+    def keys(self, pairs: List[Tuple[Submission, Submission]]) -> Dict[Tuple[UUID, UUID], str]:
+        """Keys of the comparisons of the pairs in both orders, by pair of submission IDs, those without key left out"""
+        content_hashes = {}
+        for pair in pairs:
+            for submission in pair:
+                if submission.id not in content_hashes:
+                    content_hashes[submission.id] = self.content_hash(submission.analyzed_files)
+        keys = {}
+        for first, second in pairs:
+            for submission_id, compared_submission_id in ((first.id, second.id), (second.id, first.id)):
+                key = self._key(content_hashes[submission_id], content_hashes[compared_submission_id])
+                if key is not None:
+                    keys[(submission_id, compared_submission_id)] = key
+        return keys
+
+    def _key(self, first_hash: Optional[str], second_hash: Optional[str]) -> Optional[str]:
+        """Key of the comparison of two content hashes with the options"""
+        if first_hash is None or second_hash is None:
+            return None
+        return hashlib.sha256(f"{first_hash}\n{second_hash}\n{self.options_hash}".encode("ascii")).hexdigest()
+
+    @staticmethod
+    def is_current(similarity: SubmissionSimilarity, keys: Dict[Tuple[UUID, UUID], str]) -> bool:
+        """Whether a similarity record holds a completed comparison with the current key of its pair"""
+        return (
+            similarity.status == SimilarityStatus.COMPLETED
+            and similarity.cache_key is not None
+            and similarity.cache_key == keys.get((similarity.submission_id, similarity.compared_submission_id))
+        )
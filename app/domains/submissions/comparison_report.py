@@ -0,0 +1,287 @@
+import json
+from html import escape
+from typing import Any, Dict, List, Optional
+
+# Files longer than this number of lines are rendered with their unmatched regions collapsed
+LARGE_FILE_LINES = 200
+
+# Unmatched lines kept visible around the fragments of a large file
+CONTEXT_LINES = 3
+
+REPORT_STYLE = """
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1, h2, h3 { margin-bottom: 0.3em; }
+table.summary, table.fragments, table.files { border-collapse: collapse; margin: 1em 0; }
+table.summary td, table.summary th, table.fragments td, table.fragments th, table.files td, table.files th {
+    border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left;
+}
+dl.configuration { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; }
+dl.configuration dt { font-weight: bold; }
+dl.configuration dd { margin: 0; font-family: monospace; white-space: pre-wrap; }
+.sides { display: grid; grid-template-columns: 1fr 1fr; gap: 1em; }
+.source { border: 1px solid #ccc; margin-bottom: 1em; overflow-x: auto; }
+.source h4 { margin: 0; padding: 0.4em; background: #f3f3f3; font-family: monospace; }
+.line { font-family: monospace; white-space: pre; }
+.line .number { display: inline-block; width: 4em; color: #888; text-align: right; padding-right: 0.8em; }
+details.unmatched summary { color: #888; font-style: italic; cursor: pointer; padding: 0.2em 0.4em; }
+.swatch { display: inline-block; width: 1em; height: 1em; vertical-align: middle; }
+.missing { color: #a00; font-style: italic; }
+section.pair { border-top: 2px solid #888; margin-top: 2em; padding-top: 1em; }
+"""
+
+# Paged media rules of the printable reports, converted to PDF: both submissions side by side in a table whose
+# cells break across pages, the locations of the fragments giving the page they are on
+PRINT_STYLE = """
+@page { size: A4 landscape; margin: 1.2cm; @bottom-right { content: "Page " counter(page) " of " counter(pages); } }
+body { margin: 0; font-size: 9pt; }
+table.sides { width: 100%; table-layout: fixed; border-collapse: collapse; }
+table.sides > tbody > tr > td { width: 50%; vertical-align: top; padding: 0 0.5em 0 0; }
+.source { overflow: visible; }
+.source h4, h2, h3 { break-after: avoid; }
+.line { white-space: pre-wrap; word-break: break-all; break-inside: avoid; }
+table.summary tr, table.fragments tr, table.files tr { break-inside: avoid; }
+table.fragments a::after { content: " (p. " target-counter(attr(href), page) ")"; color: #888; }
+section.pair { break-before: page; border-top: none; margin-top: 0; }
+.unmatched { color: #888; font-style: italic; padding: 0.2em 0.4em; }
+"""
+
+
+class ComparisonReportRenderer:
+    """
+    Render the comparisons of pairs of submissions as self-contained HTML documents for the case files: both
+    submissions side by side with the matched fragments highlighted in the same color in both of them, the scores,
+    the configuration of the comparison, and the list of the fragments linking to their locations. The report is
+    rendered from the stored fragments and sources of the comparison, without comparing the submissions again.
+
+    The files longer than `large_file_lines` lines are rendered with their unmatched regions collapsed, but the
+    `context_lines` lines around the fragments. The printable reports, converted to PDF, have no interactive
+    element: the collapsed regions are only mentioned, the pages being laid out by PRINT_STYLE.
+    """
+
+    def __init__(
+        self, large_file_lines: int = LARGE_FILE_LINES, context_lines: int = CONTEXT_LINES, printable: bool = False
+    ):
+        self.large_file_lines = large_file_lines
+        self.context_lines = context_lines
+        self.printable = printable
+
+    def render_pair(self, pair: Dict[str, Any]) -> str:
+        """Render the report of the comparison of a pair of submissions"""
+        title = f"Comparison {pair['similarity_id']}"
+        return self._document(title, self._pair_section(pair, "pair"))
+
+    def render_run(self, run: Dict[str, Any], pairs: List[Dict[str, Any]]) -> str:
+        """
+        Render the report of a detection run: its summary, its matches with the reference sets apart if it included
+        them, then the comparison of each reported pair
+        """
+        rows = "".join(
+            f"<tr><td><a href='#pair-{index}'>{escape(str(pair['submission1']['id']))}</a></td>"
+            f"<td>{escape(str(pair['submission2']['id']))}</td>"
+            f"<td>{self._percentage(pair['overall_similarity'])}</td><td>{len(pair['fragments'])}</td></tr>"
+            for index, pair in enumerate(pairs, start=1)
+        )
+        body = (
+            f"<h1>Detection run {escape(str(run['run_id']))}</h1>"
+            f"{self._configuration(run['configuration'])}"
+            "<table class='summary'><tr><th>Submission</th><th>Compared submission</th><th>Similarity</th>"
+            f"<th>Fragments</th></tr>{rows}</table>"
+        )
+        if not pairs:
+            body += "<p>No pair of the run is reported.</p>"
+        if run.get("reference_matches") is not None:
+            body += self._reference_matches(run["reference_matches"])
+        for index, pair in enumerate(pairs, start=1):
+            body += f"<section class='pair' id='pair-{index}'>{self._pair_section(pair, f'pair-{index}')}</section>"
+        return self._document(f"Detection run {run['run_id']}", body)
+
+    def _reference_matches(self, matches: List[Dict[str, Any]]) -> str:
+        """Matches of the submissions with the files of the reference sets, never mixed with the pairs"""
+        if not matches:
+            return "<h2>Reference matches</h2><p>No match with the reference sets is reported.</p>"
+        rows = "".join(
+            f"<tr><td>{escape(str(match['submission_id']))}</td><td>{escape(match['reference_label'])}</td>"
+            f"<td>{escape(match['path'])}</td><td>{escape(match.get('license_note') or '')}</td>"
+            f"<td>{self._percentage(match['overall_similarity'])}</td><td>{match['shared_fingerprint_count']}</td></tr>"
+            for match in matches
+        )
+        return (
+            "<h2>Reference matches</h2><table class='summary'><tr><th>Submission</th><th>Reference set</th>"
+            f"<th>File</th><th>License</th><th>Share of the file</th><th>Shared fingerprints</th></tr>{rows}</table>"
+        )
+
+    def _pair_section(self, pair: Dict[str, Any], prefix: str) -> str:
+        """Scores, configuration, fragments list and side by side sources of a pair"""
+        submission1, submission2 = pair["submission1"], pair["submission2"]
+        summary = (
+            "<table class='summary'>"
+            f"<tr><th>Submission</th><td>{escape(str(submission1['id']))}</td>"
+            f"<td>{escape(str(submission1.get('link') or ''))}</td></tr>"
+            f"<tr><th>Compared submission</th><td>{escape(str(submission2['id']))}</td>"
+            f"<td>{escape(str(submission2.get('link') or ''))}</td></tr>"
+            f"<tr><th>Overall similarity</th><td colspan='2'>{self._percentage(pair['overall_similarity'])}</td></tr>"
+            f"<tr><th>Status</th><td colspan='2'>{escape(str(pair.get('status') or ''))}"
+            f"{' (suspicious)' if pair.get('suspicious') else ''}</td></tr>"
+            "</table>"
+        )
+        cell = "td" if self.printable else "div"
+        sides = "".join(
+            f"<{cell}><h3>{escape(title)}</h3>{self._side(pair, side, key, prefix)}</{cell}>"
+            for side, key, title in (
+                ("left", "submission1", "Submission"),
+                ("right", "submission2", "Compared submission"),
+            )
+        )
+        if self.printable:
+            sides = f"<table class='sides'><tr>{sides}</tr></table>"
+        else:
+            sides = f"<div class='sides'>{sides}</div>"
+        return (
+            f"<h2>Comparison {escape(str(pair['similarity_id']))}</h2>{summary}"
+            f"{self._configuration(pair['configuration'])}{self._fragments_table(pair['fragments'], prefix)}"
+            f"{self._files(pair.get('file_similarities'))}{sides}"
+        )
+
+    def _fragments_table(self, fragments: List[Dict[str, Any]], prefix: str) -> str:
+        """List of the fragments, linking to their location in both submissions"""
+        if not fragments:
+            return "<p>No matched fragment was stored with the comparison.</p>"
+        rows = ""
+        for index, fragment in enumerate(fragments, start=1):
+            links = "".join(
+                f"<td><a href='#{prefix}-{side}-fragment-{index}'>{escape(self._location(fragment[side]))}</a></td>"
+                for side in ("left", "right")
+            )
+            rows += (
+                f"<tr id='{prefix}-fragment-{index}'><td><span class='swatch' style='background:{self._color(index)}'>"
+                f"</span> {index}</td>{links}<td>{fragment['tokens']}</td>"
+                f"<td>{self._percentage(fragment['similarity'])}</td></tr>"
+            )
+        return (
+            "<table class='fragments'><tr><th>Fragment</th><th>Submission</th><th>Compared submission</th>"
+            f"<th>Tokens</th><th>Similarity</th></tr>{rows}</table>"
+        )
+
+    def _files(self, breakdown: Optional[Dict[str, Any]]) -> str:
+        """Best-matching counterpart of each file of both submissions, aggregation and excluded files"""
+        if not breakdown:
+            return ""
+        rows = ""
+        for side, other, files in (
+            ("Submission", "compared submission", breakdown["files1"]),
+            ("Compared submission", "submission", breakdown["files2"]),
+        ):
+            for file in files:
+                match = "matches no file"
+                if file["best_match"] is not None:
+                    similarity = self._percentage(file["similarity"])
+                    match = f"matches {escape(file['best_match'])} in the {other} at {similarity}"
+                rows += (
+                    f"<tr><td>{side}</td><td>{escape(file['file'])}</td><td>{match}</td><td>{file['tokens']}</td></tr>"
+                )
+        aggregation = breakdown["aggregation"]
+        excluded = "".join(
+            f"<li>{escape(title)}: {escape(file['file'])} ({escape(file['reason'])})</li>"
+            for key, title in (("file1", "Submission"), ("file2", "Compared submission"))
+            for file in breakdown["excluded_files"].get(key, [])
+        )
+        return (
+            "<h3>Files</h3><table class='files'><tr><th>Side</th><th>File</th><th>Best match</th><th>Tokens</th></tr>"
+            f"{rows}</table><p>Aggregated similarity ({escape(aggregation['method'])}): "
+            f"{self._percentage(aggregation['similarity'])} = {escape(aggregation['formula'])}; "
+            f"{escape(aggregation['pair_formula'])}</p>"
+            + (f"<h4>Excluded files</h4><ul class='excluded'>{excluded}</ul>" if excluded else "")
+        )
+
+    def _side(self, pair: Dict[str, Any], side: str, key: str, prefix: str) -> str:
+        """Files of a submission holding fragments, in the order of their first fragment"""
+        sources = pair["sources"].get(key) or {}
+        files: Dict[str, Dict[int, Dict[str, Any]]] = {}
+        for index, fragment in enumerate(pair["fragments"], start=1):
+            files.setdefault(fragment[side].get("file") or "", {})[index] = fragment[side]
+        return "".join(
+            self._file(file, sources.get(file), fragments, f"{prefix}-{side}") for file, fragments in files.items()
+        )
+
+    def _file(self, file: str, source: Optional[str], fragments: Dict[int, Dict[str, Any]], prefix: str) -> str:
+        """A file with the lines of its fragments highlighted, its unmatched regions collapsed if it is large"""
+        header = f"<h4>{escape(file or 'source')}</h4>"
+        if source is None:
+            return f"<div class='source'>{header}<p class='missing'>Source not stored with the comparison</p></div>"
+
+        lines = source.replace("\r\n", "\n").replace("\r", "\n").split("\n")
+        highlights: Dict[int, int] = {}
+        anchors: Dict[int, List[int]] = {}
+        for index, position in fragments.items():
+            anchors.setdefault(position["start_line"], []).append(index)
+            for line in range(position["start_line"], position["end_line"] + 1):
+                highlights.setdefault(line, index)
+
+        visible = set(range(len(lines)))
+        if len(lines) > self.large_file_lines:
+            visible = {
+                line
+                for highlighted in highlights
+                for line in range(highlighted - self.context_lines, highlighted + self.context_lines + 1)
+            }
+
+        rendered, hidden = [], []
+        for number, text in enumerate(lines):
+            line = self._line(number, text, highlights.get(number), anchors.get(number, []), prefix)
+            if number in visible:
+                rendered.append(self._collapsed(hidden))
+                hidden = []
+                rendered.append(line)
+            else:
+                hidden.append((number, line))
+        rendered.append(self._collapsed(hidden))
+        return f"<div class='source'>{header}{''.join(rendered)}</div>"
+
+    def _line(self, number: int, text: str, fragment: Optional[int], anchors: List[int], prefix: str) -> str:
+        """A line of a file, in the color of the fragment it belongs to"""
+        ids = "".join(f"<span id='{prefix}-fragment-{index}'></span>" for index in anchors)
+        style = f" style='background:{self._color(fragment)}' title='Fragment {fragment}'" if fragment else ""
+        return f"<div class='line'{style}>{ids}<span class='number'>{number + 1}</span>{escape(text)}</div>"
+
+    def _collapsed(self, hidden: List[tuple]) -> str:
+        """Collapsed region of consecutive unmatched lines, only mentioned in the printable reports"""
+        if not hidden:
+            return ""
+        first, last = hidden[0][0] + 1, hidden[-1][0] + 1
+        if self.printable:
+            return f"<div class='unmatched'>Lines {first} to {last} not matched</div>"
+        lines = "".join(line for _, line in hidden)
+        return f"<details class='unmatched'><summary>Lines {first} to {last} not matched</summary>{lines}</details>"
+
+    @staticmethod
+    def _configuration(configuration: Dict[str, Any]) -> str:
+        """Configuration the comparison was made and flagged with"""
+        entries = "".join(
+            f"<dt>{escape(str(key))}</dt>"
+            f"<dd>{escape(value if isinstance(value, str) else json.dumps(value, default=str))}</dd>"
+            for key, value in configuration.items()
+        )
+        return f"<h3>Configuration</h3><dl class='configuration'>{entries}</dl>"
+
+    @staticmethod
+    def _location(position: Dict[str, Any]) -> str:
+        """File and (1-based) lines of a fragment"""
+        lines = f"{position['start_line'] + 1}-{position['end_line'] + 1}"
+        return f"{position['file']}:{lines}" if position.get("file") else f"lines {lines}"
+
+    @staticmethod
+    def _color(index: int) -> str:
+        """Color of a fragment, distinct from those of the fragments next to it"""
+        return f"hsl({(index * 137) % 360}, 70%, 85%)"
+
+    @staticmethod
+    def _percentage(value: Optional[float]) -> str:
+        return f"{value * 100:.1f}%" if value is not None else ""
+
+    def _document(self, title: str, body: str) -> str:
+        """Self-contained HTML document"""
+        style = REPORT_STYLE + PRINT_STYLE if self.printable else REPORT_STYLE
+        return (
+            "<!DOCTYPE html><html lang='en'><head><meta charset='utf-8'>"
+            f"<title>{escape(title)}</title><style>{style}</style></head><body>{body}</body></html>"
+        )
@@ -0,0 +1,175 @@
+import logging
+from typing import Any, Callable, Dict, List, Optional, Set
+from uuid import UUID
+
+from app.domains.submissions.corpus_matcher import CorpusMatcher
+from app.domains.submissions.submissions_models import (
+    CorpusKind,
+    LinkType,
+    SimilarityStatus,
+    Submission,
+    SubmissionCorpus,
+    SubmissionCorpusItem,
+)
+from app.shared.exceptions import NotFoundException, ValidationException
+
+logger = logging.getLogger(__name__)
+
+
+class Corpora:
+    """
+    Reference corpora of a tenant, archiving past submissions matched with those of the project steps referencing
+    them, and with the files of the reference sets for the runs including the references. An archived submission
+    is fingerprinted once with the given function (corpus ID, link and its type), a matched submission with the
+    other (submission); the corpora of the other tenants are reported as not found.
+    """
+
+    def __init__(
+        self,
+        corpus_repository: Any,
+        match_repository: Any,
+        submission_repository: Any,
+        tenant_id: str,
+        fingerprint_link: Callable[[UUID, str, Optional[LinkType]], Dict[str, Any]],
+        fingerprint_submission: Callable[[Submission], Set[str]],
+    ):
+        self.corpus_repository = corpus_repository
+        self.match_repository = match_repository
+        self.submission_repository = submission_repository
+        self.tenant_id = tenant_id
+        self.fingerprint_link = fingerprint_link
+        self.fingerprint_submission = fingerprint_submission
+
+    def create(self, corpus_data: Dict[str, Any]) -> SubmissionCorpus:
+        """Create a reference corpus, archiving past submissions matched with those of the steps referencing it"""
+        return self.corpus_repository.create({**corpus_data, "tenant_id": self.tenant_id})
+
+    def get_items(self, corpus_id: UUID) -> List[SubmissionCorpusItem]:
+        """
+        Get the archived submissions of a corpus
+
+        Raises:
+            NotFoundException: If the corpus doesn't exist, or belongs to another tenant
+        """
+        self._get(corpus_id)
+        return self.corpus_repository.get_items([corpus_id])
+
+    def create_item(self, corpus_id: UUID, item_data: Dict[str, Any]) -> SubmissionCorpusItem:
+        """
+        Fetch a past submission and store its fingerprints in a corpus, so that the detection runs match the
+        submissions with it without fetching and tokenizing it again
+
+        Raises:
+            NotFoundException: If the corpus doesn't exist, or belongs to another tenant
+            ValidationException: If the corpus is a reference set, or the submission has no content
+        """
+        corpus = self._get(corpus_id)
+        if corpus.kind == CorpusKind.REFERENCE:
+            raise ValidationException("The files of a reference set are replaced by uploading its archive again")
+
+        fingerprinted = self.fingerprint_link(corpus_id, item_data["link"], item_data.get("link_type"))
+        logger.info(
+            f"Fingerprinted corpus item {item_data['label']}: {fingerprinted['file_count']} files, "
+            f"{len(fingerprinted['fingerprints'])} fingerprints"
+        )
+        return self.corpus_repository.create_item(
+            {"corpus_id": corpus_id, "label": item_data["label"], "link": item_data["link"], **fingerprinted}
+        )
+
+    def get_step_corpora(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionCorpus]:
+        """Get the corpora referenced by a project step"""
+        return self.corpus_repository.get_by_project_step(project_uuid, project_step_uuid)
+
+    def save_step_corpora(self, project_uuid: UUID, project_step_uuid: UUID, corpus_ids: List[UUID]) -> None:
+        """
+        Replace the corpora referenced by a project step, matched by the detection runs including the corpus
+
+        Raises:
+            ValidationException: If a corpus doesn't exist, belongs to another tenant, or is a reference set
+        """
+        corpora = {corpus_id: self.corpus_repository.get_by_id(corpus_id, self.tenant_id) for corpus_id in corpus_ids}
+        unknown = [str(corpus_id) for corpus_id, corpus in corpora.items() if not corpus]
+        if unknown:
+            raise ValidationException(f"Corpora not found: {unknown}")
+        references = [str(corpus_id) for corpus_id, corpus in corpora.items() if corpus.kind == CorpusKind.REFERENCE]
+        if references:
+            raise ValidationException(
+                f"Reference sets are matched by the detection runs including the references, not by steps: {references}"
+            )
+        self.corpus_repository.save_project_step(project_uuid, project_step_uuid, corpus_ids)
+
+    def get_step_items(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionCorpusItem]:
+        """Get the archived submissions of all the corpora referenced by a project step"""
+        corpora = self.corpus_repository.get_by_project_step(project_uuid, project_step_uuid)
+        return self.corpus_repository.get_items([corpus.id for corpus in corpora]) if corpora else []
+
+    def get_reference_items(self, tenant_id: str) -> List[SubmissionCorpusItem]:
+        """Get the files of all the external reference sets of a tenant"""
+        reference_sets = self.corpus_repository.get_by_kind(CorpusKind.REFERENCE, tenant_id)
+        return self.corpus_repository.get_items([corpus.id for corpus in reference_sets]) if reference_sets else []
+
+    def match(self, submission_id: UUID, include_corpus: bool = True, include_references: bool = False) -> None:
+        """
+        Match a submission with the archived submissions of the corpora of its step, and with the files of the
+        reference sets if included, the items it was already matched with being skipped. A failed matching is
+        recorded on each pending item.
+        """
+        pending_items = []
+        pending_references = []
+        try:
+            submission = self.submission_repository.get_by_id(submission_id)
+            if not submission:
+                logger.error(f"Submission not found: {submission_id}")
+                return
+
+            items = self.get_step_items(submission.project_uuid, submission.project_step_uuid) if include_corpus else []
+            references = self.get_reference_items(submission.tenant_id) if include_references else []
+            matched = {
+                match.corpus_item_id
+                for match in self.match_repository.get_by_submission_ids([submission_id])
+                if match.status == SimilarityStatus.COMPLETED
+            }
+            pending_items = [item for item in items if item.id not in matched]
+            pending_references = [item for item in references if item.id not in matched]
+            if not pending_items and not pending_references:
+                return
+
+            fingerprints = self.fingerprint_submission(submission)
+            matcher = CorpusMatcher()
+            matches = matcher.match(fingerprints, pending_items) + matcher.match_references(
+                fingerprints, pending_references
+            )
+            # A reference set has many files, their matches are written at once
+            self.match_repository.create_many(
+                [{"submission_id": submission_id, "status": SimilarityStatus.COMPLETED, **match} for match in matches]
+            )
+            logger.info(
+                f"Matched submission {submission_id} with {len(pending_items)} corpus items and "
+                f"{len(pending_references)} reference files"
+            )
+
+        except Exception as e:
+            logger.error(f"Failed corpus matching of submission {submission_id}: {str(e)}")
+            self.match_repository.create_many(
+                [
+                    {
+                        "submission_id": submission_id,
+                        "corpus_item_id": item.id,
+                        "status": SimilarityStatus.FAILED,
+                        "error_message": str(e),
+                    }
+                    for item in pending_items + pending_references
+                ]
+            )
+
+    def _get(self, corpus_id: UUID) -> SubmissionCorpus:
+        """
+        Get a corpus of the tenant
+
+        Raises:
+            NotFoundException: If the corpus doesn't exist, or belongs to another tenant
+        """
+        corpus = self.corpus_repository.get_by_id(corpus_id, self.tenant_id)
+        if not corpus:
+            raise NotFoundException("Corpus", str(corpus_id))
+        return corpus
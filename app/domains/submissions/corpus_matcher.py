@@ -0,0 +1,53 @@
+from typing import Any, Collection, Dict, List
+
+from app.domains.submissions.submissions_models import SubmissionCorpusItem
+
+
+class CorpusMatcher:
+    """
+    Match the fingerprints of a submission with those of the archived submissions of the reference corpora,
+    precomputed when they were archived. The similarity of a match is the share of the fingerprints of the
+    submission found in the item (reused code stays visible when the submission adds code of its own), the
+    Jaccard index of both fingerprint sets being reported along.
+
+    The files of the reference sets are matched the other way round: a reference file is small next to a
+    submission, so the similarity of a match is the share of the fingerprints of the file found in the submission.
+    """
+
+    def match(self, fingerprints: Collection[str], items: List[SubmissionCorpusItem]) -> List[Dict[str, Any]]:
+        """Get the match of the fingerprints with each item, most similar first"""
+        fingerprints = set(fingerprints)
+        matches = []
+        for item in items:
+            item_fingerprints = set(item.fingerprints or [])
+            shared = len(fingerprints & item_fingerprints)
+            union = len(fingerprints | item_fingerprints)
+            matches.append(
+                {
+                    "corpus_item_id": item.id,
+                    "overall_similarity": round(shared / len(fingerprints), 3) if fingerprints else 0.0,
+                    "jaccard_similarity": round(shared / union, 3) if union else 0.0,
+                    "shared_fingerprint_count": shared,
+                }
+            )
+        return sorted(matches, key=lambda match: -match["overall_similarity"])
+
+    def match_references(
+        self, fingerprints: Collection[str], items: List[SubmissionCorpusItem]
+    ) -> List[Dict[str, Any]]:
+        """Get the match of the fingerprints with each file of the reference sets, most similar first"""
+        fingerprints = set(fingerprints)
+        matches = []
+        for item in items:
+            item_fingerprints = set(item.fingerprints or [])
+            shared = len(fingerprints & item_fingerprints)
+            union = len(fingerprints | item_fingerprints)
+            matches.append(
+                {
+                    "corpus_item_id": item.id,
+                    "overall_similarity": round(shared / len(item_fingerprints), 3) if item_fingerprints else 0.0,
+                    "jaccard_similarity": round(shared / union, 3) if union else 0.0,
+                    "shared_fingerprint_count": shared,
+                }
+            )
+        return sorted(matches, key=lambda match: -match["overall_similarity"])
@@ -0,0 +1,142 @@
+import logging
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional
+from uuid import UUID
+
+from app.domains.submissions.retention_planner import RetentionPlanner
+from app.domains.submissions.submissions_models import Submission, SubmissionRetentionPolicy, SubmissionRetentionPurge
+from app.shared.exceptions import NotFoundException
+
+logger = logging.getLogger(__name__)
+
+
+class DataRetention:
+    """
+    Retention policies of the project steps and the purges of their expired files and results, planned by the
+    RetentionPlanner. A submission deleted for good is deleted with the given function (submission ID), and the
+    stored file of a submission kept with the other (submission); every purge deleting anything is logged.
+    """
+
+    def __init__(
+        self,
+        policy_repository: Any,
+        purge_repository: Any,
+        schedule_repository: Any,
+        submission_repository: Any,
+        similarity_repository: Any,
+        run_repository: Any,
+        report_job_repository: Any,
+        fingerprint_repository: Any,
+        purge_submission: Callable[[UUID], None],
+        delete_stored_upload: Callable[[Submission], None],
+    ):
+        self.policy_repository = policy_repository
+        self.purge_repository = purge_repository
+        self.schedule_repository = schedule_repository
+        self.submission_repository = submission_repository
+        self.similarity_repository = similarity_repository
+        self.run_repository = run_repository
+        self.report_job_repository = report_job_repository
+        self.fingerprint_repository = fingerprint_repository
+        self.purge_submission = purge_submission
+        self.delete_stored_upload = delete_stored_upload
+
+    def get_policy(self, project_uuid: UUID, project_step_uuid: UUID) -> SubmissionRetentionPolicy:
+        """
+        Get the retention policy of a project step
+
+        Raises:
+            NotFoundException: If the step has no retention policy, its files and results being kept forever
+        """
+        policy = self.policy_repository.get_by_project_step(project_uuid, project_step_uuid)
+        if not policy:
+            raise NotFoundException("Retention policy", f"{project_uuid}/{project_step_uuid}")
+        return policy
+
+    def save_policy(
+        self, project_uuid: UUID, project_step_uuid: UUID, policy_data: Dict[str, Any]
+    ) -> SubmissionRetentionPolicy:
+        """Set the retention policy of a project step, applied by the next purge"""
+        return self.policy_repository.save(project_uuid, project_step_uuid, policy_data)
+
+    def delete_policy(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the retention policy of a project step, its files and results being kept forever"""
+        return self.policy_repository.delete(project_uuid, project_step_uuid)
+
+    def purge_expired(self, now: datetime, dry_run: bool = False) -> List[Dict[str, Any]]:
+        """
+        Purge the expired files and detection results of every project step with a retention policy, returning
+        what was purged per step; in dry run, what would be purged, nothing being deleted
+        """
+        return [self._purge_expired_step(policy, now, dry_run) for policy in self.policy_repository.get_all()]
+
+    def get_purges(self, project_uuid: Optional[UUID] = None, limit: int = 100) -> List[SubmissionRetentionPurge]:
+        """Get the log of the latest purges of the expired files and results, latest first"""
+        return self.purge_repository.list_recent(project_uuid, limit)
+
+    def _purge_expired_step(self, policy: SubmissionRetentionPolicy, now: datetime, dry_run: bool) -> Dict[str, Any]:
+        """
+        Purge the expired files and results of a project step under its retention policy. Without full deletion,
+        an expired submission loses its stored file and the index of its code, keeping its record (hashes, scores),
+        and an expired comparison its details (and reports), keeping its scores; with full deletion, they are
+        deleted for good, as are the expired runs. A purge deleting anything is logged with its counts.
+        """
+        project_uuid, project_step_uuid = policy.project_uuid, policy.project_step_uuid
+        schedule = self.schedule_repository.get_by_project_step(project_uuid, project_step_uuid)
+        submissions = self.submission_repository.get_by_project_step(
+            project_uuid, project_step_uuid, include_deleted=True, include_quarantined=True
+        )
+        plan = RetentionPlanner(policy, schedule.deadline if schedule else None, now).plan(
+            submissions,
+            self.similarity_repository.get_by_project_step(project_uuid, project_step_uuid),
+            self.run_repository.get_by_project_step(project_uuid, project_step_uuid),
+        )
+
+        expired_files = plan["expired_file_submission_ids"]
+        counts = {
+            "purged_file_count": 0 if policy.full_deletion else len(expired_files),
+            "deleted_submission_count": len(expired_files) if policy.full_deletion else 0,
+            "purged_result_count": len(plan["expired_similarity_ids"]),
+            "deleted_run_count": len(plan["expired_run_ids"]),
+            "exempt_submission_count": len(plan["exempt_submission_ids"]),
+        }
+        report = {
+            "project_uuid": project_uuid,
+            "project_step_uuid": project_step_uuid,
+            "dry_run": dry_run,
+            "full_deletion": policy.full_deletion,
+            **counts,
+            **plan,
+        }
+        if dry_run:
+            logger.info(f"Retention dry run of project step {project_step_uuid} would purge: {counts}")
+            return report
+
+        by_id = {submission.id: submission for submission in submissions}
+        for submission_id in expired_files:
+            if policy.full_deletion:
+                self.purge_submission(submission_id)
+            else:
+                self.delete_stored_upload(by_id[submission_id])
+                self.fingerprint_repository.delete_by_submission_id(submission_id)
+                self.submission_repository.patch(submission_id, {"files_purged_at": now})
+
+        similarity_ids = plan["expired_similarity_ids"]
+        self.report_job_repository.delete_by_similarity_ids(similarity_ids)
+        if policy.full_deletion:
+            self.similarity_repository.delete_by_ids(similarity_ids)
+        else:
+            self.similarity_repository.purge_details(similarity_ids)
+        self.run_repository.delete_by_ids(plan["expired_run_ids"])
+
+        if any(count for field, count in counts.items() if field != "exempt_submission_count"):
+            self.purge_repository.create(
+                {
+                    "project_uuid": project_uuid,
+                    "project_step_uuid": project_step_uuid,
+                    "full_deletion": policy.full_deletion,
+                    **counts,
+                }
+            )
+            logger.warning(f"Purged the expired data of project step {project_step_uuid}: {counts}")
+        return report
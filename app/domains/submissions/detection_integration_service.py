@@ -1,26 +1,207 @@
+import io
+import itertools
 import logging
+import re
+import secrets
 import threading
+import tempfile
 import time
 from concurrent.futures import ThreadPoolExecutor
-from pathlib import Path
-from typing import Any, Dict, List, Optional
+from concurrent.futures import TimeoutError as FutureTimeoutError
+from datetime import datetime, timedelta
+from pathlib import Path, PurePosixPath
+from typing import Any, Callable, Collection, Dict, FrozenSet, Iterable, Iterator, List, Optional, Set, Tuple
 from uuid import UUID
 
 from fastapi import HTTPException
+from fastapi.encoders import jsonable_encoder
 from sqlmodel import Session
 
+from app.config.config import get_settings
+from app.domains.detection.allowed_snippet_filter import AllowedSnippetFilter
+from app.domains.detection.baseline_filter import BaselineFilter
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.detection.rare_token_analyzer import RareTokenAnalyzer
 from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.detection.token_abstractor import TokenAbstractor
+from app.domains.detection.token_aligner import TokenAligner
 from app.domains.detection.visualization import VisualizationService
+from app.domains.repositories.archive_extractor import (
+    UPLOAD_TOO_LARGE,
+    ArchiveExtractionResult,
+    ArchiveExtractor,
+    ArchiveFile,
+    ArchiveLimitExceeded,
+    case_colliding_paths,
+)
+from app.domains.repositories.fetchers.git_ref_fetcher import GitRefFetcher, GitRefFetchResult
+from app.domains.repositories.fetchers.url_source_fetcher import UrlSourceFetcher
+from app.domains.repositories.ignore_rules import DEFAULT_IGNORE_RULES, IGNORED_PATH_SAMPLE_SIZE, IgnoreRules
+from app.domains.repositories.object_storage import submission_file_key
 from app.domains.repositories.submission_fetcher import SubmissionFetcher, cleanup_temp_directory
+from app.domains.submissions.access_policy import AccessDenied
+from app.domains.submissions.analysis_priority import AnalysisPriorityPolicy
+from app.domains.submissions.analysis_profile_catalog import AnalysisProfileCatalog
+from app.domains.submissions.analysis_profile_resolver import DEFAULT_PROFILE_NAME, AnalysisProfileResolver
+from app.domains.submissions.analysis_worker_pool import AnalysisCancelled, AnalysisWorkerPool
+from app.domains.submissions.auto_run_scheduler import AUTO_RUN_FIELDS, AutoRunScheduler
+from app.domains.submissions.binary_file_detector import BinaryFileDetector
+from app.domains.submissions.chunked_upload_store import ChunkedUploadStore
+from app.domains.submissions.code_metrics_analyzer import CodeMetricsAnalyzer
+from app.domains.submissions.code_search import CodeSearch
+from app.domains.submissions.comparison_cache import ComparisonCache
+from app.domains.submissions.comparison_report import ComparisonReportRenderer
+from app.domains.submissions.corpora import Corpora
+from app.domains.submissions.data_retention import DataRetention
+from app.domains.submissions.detection_run_lock import INSTANCE_ID, DetectionRunLock
+from app.domains.submissions.detection_run_locks import DetectionRunLocks
+from app.domains.submissions.dto.allowed_snippet_dto import CreateAllowedSnippetDto, UpdateAllowedSnippetDto
+from app.domains.submissions.dto.code_search_dto import CodeSearchDto, CodeSearchMode
+from app.domains.submissions.dto.create_baseline_dto import CreateBaselineDto
+from app.domains.submissions.dto.create_git_submission_dto import CreateGitSubmissionDto
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
-from app.domains.submissions.submissions_models import SimilarityStatus, Submission
+from app.domains.submissions.dto.external_comparison_dto import ExternalComparisonDto
+from app.domains.submissions.dto.submission_update_dto import SubmissionUpdateDto
+from app.domains.submissions.dto.token_diff_dto import FileReferenceDto, TokenDiffRequestDto
+from app.domains.submissions.duplicate_upload_detector import DuplicateUploadDetector
+from app.domains.submissions.file_filter import FileFilter, FileFilterMode
+from app.domains.submissions.encoding_detector import DecodedText, EncodingDetector
+from app.domains.submissions.evidence_packages import EvidencePackages
+from app.domains.submissions.generated_code_classifier import GeneratedCodeClassifier
+from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessingResult, GoPackagePreprocessor
+from app.domains.submissions.grading_callbacks import GradingCallbacks
+from app.domains.submissions.idempotency_keys import IdempotencyKeys
+from app.domains.submissions.incremental_reanalysis import IncrementalReanalysis
+from app.domains.submissions.language_statistics import LanguageStatistics
+from app.domains.submissions.malware_scanner import ScannerUnavailable
+from app.domains.submissions.originality_feedback import OriginalityFeedback
+from app.domains.submissions.pair_comparison_pool import PairComparisonPool, PairOutcome
+from app.domains.submissions.pdf_report_renderer import PdfReportRenderer
+from app.domains.submissions.processing_lifecycle import FileProcessingError, ProcessingLifecycle
+from app.domains.submissions.processing_retry import ProcessingRetryPolicy
+from app.domains.submissions.processing_timeline import ProcessingTimeline
+from app.domains.submissions.reference_sets import ReferenceSets
+from app.domains.submissions.resumable_uploads import ResumableUploads
+from app.domains.submissions.run_exporter import DetectionRunExporter
+from app.domains.submissions.run_fingerprint import DEFAULT_VERIFICATION_SAMPLE_SIZE, RunFingerprint
+from app.domains.submissions.run_path_exclusion import RunPathExclusion
+from app.domains.submissions.run_progress import RunProgressTracker
+from app.domains.submissions.run_results_feed import DEFAULT_POLL_SECONDS
+from app.domains.submissions.run_summary import (
+    DEFAULT_CENTRAL_SUBMISSIONS,
+    DEFAULT_SUMMARY_BUCKETS,
+    HISTOGRAM_RESOLUTION,
+    DetectionRunSummarizer,
+)
+from app.domains.submissions.run_verifier import DetectionRunVerifier
+from app.domains.submissions.similarity_clusterer import DEFAULT_MERGE_THRESHOLD
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from app.domains.submissions.similarity_matrix import PairSortField, SimilarityMatrix
+from app.domains.submissions.submissions_access_denial_repository import SubmissionAccessDenialRepository
+from app.domains.submissions.submissions_allowed_snippet_repository import SubmissionAllowedSnippetRepository
+from app.domains.submissions.submissions_analysis_profile_repository import SubmissionAnalysisProfileRepository
+from app.domains.submissions.submissions_assignment_repository import SubmissionAssignmentRepository
+from app.domains.submissions.submissions_audit_repository import SubmissionAuditRepository
+from app.domains.submissions.submissions_baseline_repository import SubmissionBaselineRepository
+from app.domains.submissions.submissions_bulk_upload_job_repository import SubmissionBulkUploadJobRepository
+from app.domains.submissions.submissions_corpus_match_repository import SubmissionCorpusMatchRepository
+from app.domains.submissions.submissions_corpus_repository import SubmissionCorpusRepository
+from app.domains.submissions.submissions_detection_config_repository import SubmissionDetectionConfigRepository
+from app.domains.submissions.submissions_detection_run_repository import SubmissionDetectionRunRepository
+from app.domains.submissions.submissions_evidence_repository import SubmissionEvidenceRepository
+from app.domains.submissions.submissions_file_filter_config_repository import SubmissionFileFilterConfigRepository
+from app.domains.submissions.submissions_ignore_rules_config_repository import SubmissionIgnoreRulesConfigRepository
+from app.domains.submissions.submissions_file_repository import SubmissionFileRepository
+from app.domains.submissions.submissions_fingerprint_repository import SubmissionFingerprintRepository
+from app.domains.submissions.submissions_grading_callback_config_repository import (
+    SubmissionGradingCallbackConfigRepository,
+)
+from app.domains.submissions.submissions_header_config_repository import SubmissionHeaderConfigRepository
+from app.domains.submissions.submissions_idempotency_key_repository import SubmissionIdempotencyKeyRepository
+from app.domains.submissions.submissions_models import (
+    AnalysisPriority,
+    CorpusKind,
+    DetectionRunStatus,
+    LinkType,
+    ProcessingStage,
+    ProcessingStatus,
+    ProcessingWarningCode,
+    SimilarityStatus,
+    Submission,
+    SubmissionAccessDenial,
+    SubmissionAllowedSnippet,
+    SubmissionAuditEntry,
+    SubmissionBaseline,
+    SubmissionBulkUploadJob,
+    SubmissionDetectionRun,
+    SubmissionEvidence,
+    SubmissionFile,
+    SubmissionProcessingEvent,
+    SubmissionReportJob,
+    SubmissionSimilarity,
+    SubmissionStatus,
+    SubmissionUploadSession,
+    WebhookEvent,
+    get_paris_time,
+)
+from app.domains.submissions.submissions_processing_event_repository import SubmissionProcessingEventRepository
+from app.domains.submissions.submissions_report_job_repository import SubmissionReportJobRepository
 from app.domains.submissions.submissions_repository import SubmissionRepository
+from app.domains.submissions.submissions_retention_policy_repository import SubmissionRetentionPolicyRepository
+from app.domains.submissions.submissions_retention_purge_repository import SubmissionRetentionPurgeRepository
 from app.domains.submissions.submissions_similarity_repository import SubmissionSimilarityRepository
-from app.domains.tokenization.tokenization_service import TokenizationService
-from app.shared.exceptions import DatabaseException, ValidationException
+from app.domains.submissions.submissions_step_schedule_repository import SubmissionStepScheduleRepository
+from app.domains.submissions.submissions_token_cache_repository import SubmissionTokenCacheRepository
+from app.domains.submissions.submissions_upload_limits_config_repository import SubmissionUploadLimitsConfigRepository
+from app.domains.submissions.submissions_upload_session_repository import SubmissionUploadSessionRepository
+from app.domains.submissions.submissions_webhook_repository import SubmissionWebhookRepository
+from app.domains.submissions.tenant_scope import TenantScope
+from app.domains.submissions.token_stream_cache import TokenStreamCache
+from app.domains.submissions.token_stream_exporter import EXPORTED_NORMALIZATION_OPTIONS, TokenStreamExporter
+from app.domains.submissions.version_differ import SubmissionVersionDiffer
+from app.domains.submissions.webhook_registry import WebhookRegistry
+from app.domains.submissions.zip_streamer import ZipStreamer
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.dto.tokenization_result_dto import TokenizationResultDto
+from app.domains.tokenization.exceptions import NotebookException
+from app.domains.tokenization.source_chunker import SourceChunk
+from app.domains.tokenization.tokenization_service import TOKENIZER_VERSION, TokenizationService
+from app.shared.deadlines import StageTimeout, check_deadline, stage_deadline
+from app.shared.exceptions import ConflictException, DatabaseException, NotFoundException, ValidationException
+from app.shared.log_context import log_context, log_stage
+from app.shared.metrics import pipeline_metrics
 
 logger = logging.getLogger(__name__)
 
+# Scores of a comparison with an external source reported along its overall similarity
+EXTERNAL_SIMILARITY_METRICS = (
+    "jaccard_similarity",
+    "type_similarity",
+    "structural_similarity",
+    "type_sequence_similarity",
+    "flow_similarity",
+    "operation_similarity",
+)
+
+# Pairs of a detection run rendered at most in its report
+MAX_REPORT_PAIRS = 500
+
+# Files of a submission analyzed between two records of its progress
+PROCESSING_PROGRESS_INTERVAL = 25
+
+# Seconds the streams of the results of a run read back from their last poll, the records of a batch being created
+# just before it is written
+RUN_RESULTS_LOOKBACK_SECONDS = 60
+
+# Bytes of the start of a file streamed in chunks its encoding is detected from
+ENCODING_SAMPLE_BYTES = 65_536
+
+# Limits of the uploads, each overridable per project step
+UPLOAD_LIMIT_FIELDS = ("max_upload_bytes", "max_extracted_bytes", "max_file_count", "max_file_bytes")
+
+# Options of the uploads of a project step, whose configured default applies when left None
+UPLOAD_OPTION_FIELDS = ("reject_binary_files", "strict_paths")
+
 
 class DetectionIntegrationService:
     """Service for integrating similarity detection with submissions"""
@@ -31,10 +212,18 @@ class DetectionIntegrationService:
         tokenization_service: Optional[TokenizationService] = None,
         similarity_service: Optional[SimilarityDetectionService] = None,
         submission_fetcher: Optional[SubmissionFetcher] = None,
+        analysis_pool: Optional[AnalysisWorkerPool] = None,
+        pair_comparison_pool: Optional[PairComparisonPool] = None,
+        tenant_id: Optional[str] = None,
     ):
         self.session = session
+        # Tenant of the caller, None for the background jobs acting on the data of all the tenants
+        self.tenant_scope = TenantScope(SubmissionAssignmentRepository(session), tenant_id)
         self.submission_repository = SubmissionRepository(session)
         self.similarity_repository = SubmissionSimilarityRepository(session)
+        self.baseline_repository = SubmissionBaselineRepository(session)
+        self.allowed_snippet_repository = SubmissionAllowedSnippetRepository(session)
+        self.analysis_profile_repository = SubmissionAnalysisProfileRepository(session)
 
         # Use injected services or get singletons
         if tokenization_service is None:
@@ -62,8 +251,66 @@ class DetectionIntegrationService:
 
         self.visualization_service = get_visualization_service(self.tokenization_service)
 
-        # Create thread pool with limited workers to prevent server overload
-        self.similarity_executor = ThreadPoolExecutor(max_workers=1, thread_name_prefix="similarity")
+        from app.shared.services import get_run_progress_tracker
+
+        self.run_progress = get_run_progress_tracker()
+
+        from app.shared.services import get_run_results_feed
+
+        self.run_results_feed = get_run_results_feed()
+
+        # Events of the analyses and detection runs posted to the webhooks, from threads of their own
+        from app.shared.services import get_webhook_notifier
+
+        self.webhook_notifier = get_webhook_notifier()
+
+        settings = get_settings()
+        self.go_package_preprocessor = GoPackagePreprocessor(
+            goos=settings.go_build_goos, goarch=settings.go_build_goarch, skip_test_files=settings.go_skip_test_files
+        )
+        self.language_confidence_threshold = settings.language_confidence_threshold
+        self.cross_language_detection = settings.cross_language_detection
+        self.min_fragment_tokens = settings.similarity_min_fragment_tokens
+        self.min_function_tokens = settings.similarity_min_function_tokens
+        self.file_aggregation = settings.similarity_file_aggregation
+        self.aggregate_file_scores = settings.similarity_aggregate_file_scores
+        self.stream_buffer_size = settings.tokenization_stream_buffer_size
+        self.token_cache_enabled = settings.token_cache_enabled
+        self.generated_code_classifier = GeneratedCodeClassifier()
+        self.binary_file_detector = BinaryFileDetector()
+        self.encoding_detector = EncodingDetector()
+        self.url_source_fetcher = UrlSourceFetcher()
+        self.version_differ = SubmissionVersionDiffer()
+        self.retry_policy = ProcessingRetryPolicy(
+            max_attempts=settings.processing_retry_max_attempts,
+            base_delay_seconds=settings.processing_retry_base_delay_seconds,
+            max_delay_seconds=settings.processing_retry_max_delay_seconds,
+        )
+        self.run_lease_seconds = settings.detection_run_lease_seconds
+        self.orphaned_run_action = settings.detection_run_orphan_action
+
+        # Workers shared by all the requests, their bounded queue preventing server overload
+        if analysis_pool is None:
+            from app.shared.services import get_analysis_worker_pool
+
+            self.analysis_pool = get_analysis_worker_pool()
+        else:
+            self.analysis_pool = analysis_pool
+
+        # Processes comparing the pairs of the detection runs on the CPU cores
+        if pair_comparison_pool is None:
+            from app.shared.services import get_pair_comparison_pool
+
+            self.pair_comparison_pool = get_pair_comparison_pool()
+        else:
+            self.pair_comparison_pool = pair_comparison_pool
+
+        # The PDF reports are rendered apart, not to wait for the pending comparisons
+        self.report_executor = ThreadPoolExecutor(max_workers=1, thread_name_prefix="report")
+        self.pdf_report_wait_seconds = get_settings().pdf_report_wait_seconds
+
+        # The bulk uploads are processed apart too, one at a time
+        self.bulk_upload_executor = ThreadPoolExecutor(max_workers=1, thread_name_prefix="bulk-upload")
 
         # Thread-local storage for database sessions
         self._local = threading.local()
@@ -78,12 +325,18 @@ class DetectionIntegrationService:
 
     def process_submission_similarities_async(self, submission: Submission) -> None:
         """
-        Process similarity detection asynchronously - doesn't block submission creation
+        Process similarity detection asynchronously - doesn't block submission creation. A quarantined submission
+        is not processed until released.
         """
+        if submission.status == SubmissionStatus.QUARANTINED:
+            logger.info(f"Submission {submission.id} is quarantined, its similarity detection is not started")
+            return
         try:
+            self._submit_processing(submission)
+
             # Get all other submissions in the same project step
             other_submissions = self.submission_repository.get_by_project_step(
-                submission.project_uuid, submission.project_step_uuid
+                submission.project_uuid, submission.project_step_uuid, latest_versions_only=True, with_files=True
             )
 
             # Filter out the current submission and get only completed ones
@@ -95,14 +348,18 @@ class DetectionIntegrationService:
                 logger.info(f"No other submissions found for comparison with submission {submission.id}")
                 return
 
-            # Submit all comparisons to thread pool (fire and forget)
-            for other_submission in other_submissions:
-                self.similarity_executor.submit(
+            # Submit all comparisons to the workers as one job (fire and forget)
+            with log_context(submission_id=submission.id):
+                self.analysis_pool.submit(
+                    self._run_batch_threaded,
                     self._process_single_comparison_threaded,
-                    submission.id,
-                    other_submission.id,
-                    submission.project_uuid,
-                    submission.project_step_uuid,
+                    [
+                        (submission.id, other.id, submission.project_uuid, submission.project_step_uuid)
+                        for other in other_submissions
+                    ],
+                    **self._queueing(
+                        submission.project_uuid, submission.project_step_uuid, submission.analysis_priority
+                    ),
                 )
 
             logger.info(
@@ -113,10 +370,1236 @@ class DetectionIntegrationService:
         except Exception as e:
             logger.error(f"Failed to start async similarity processing: {str(e)}")
 
+    def ensure_analysis_capacity(self) -> None:
+        """
+        Make sure the analysis of a new request can be queued, waiting for a free slot or refusing the request at
+        once depending on the backpressure policy
+
+        Raises:
+            AnalysisQueueFull: If the analysis queue stays full
+        """
+        self.analysis_pool.ensure_capacity()
+
+    def wait_for_analysis_capacity(self) -> bool:
+        """Wait for a free slot in the analysis queue, as long as it takes, returning False if the workers stopped"""
+        return self.analysis_pool.wait_for_capacity()
+
+    def get_analysis_queue_stats(self) -> Dict[str, Any]:
+        """Get the number of queued analysis jobs and of busy workers"""
+        return self.analysis_pool.stats()
+
+    def resume_interrupted_processing(self) -> int:
+        """
+        Queue again at startup the processing of the submissions interrupted by the last shutdown: those left
+        pending retry, and those left extracting or tokenizing by a stop without drain (a single instance processing
+        the submissions). Queues as many as the workers take, returning their number, the others staying pending
+        retry until compared.
+        """
+        interrupted = self.submission_repository.get_by_processing_statuses(
+            [ProcessingStatus.PENDING_RETRY, ProcessingStatus.EXTRACTING, ProcessingStatus.TOKENIZING]
+        )
+        for index, submission in enumerate(interrupted):
+            if not self.analysis_pool.wait_for_capacity():
+                logger.info(f"Resumed {index} of {len(interrupted)} interrupted submissions before a shutdown")
+                return index
+            self._submit_processing(submission)
+        if interrupted:
+            logger.info(f"Resumed the processing of {len(interrupted)} interrupted submissions")
+        return len(interrupted)
+
+    def get_analysis_queue_position(self, submission_id: UUID) -> Optional[Dict[str, Any]]:
+        """Get the position and the effective priority of the queued processing of a submission, None if not queued"""
+        return self.analysis_pool.queue_position(submission_id)
+
+    def get_analysis_priority(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        explicit: Optional[AnalysisPriority] = None,
+        session: Optional[Session] = None,
+    ) -> AnalysisPriority:
+        """Get the priority of an analysis of a project step: the explicit one if given, else that of the step"""
+        if explicit:
+            return AnalysisPriority(explicit)
+        schedule = SubmissionStepScheduleRepository(session or self.session).get_by_project_step(
+            project_uuid, project_step_uuid
+        )
+        if not schedule:
+            return AnalysisPriorityPolicy.for_deadline(None, get_paris_time())
+        return AnalysisPriorityPolicy.resolve(None, schedule.priority, schedule.deadline, get_paris_time())
+
+    def _queueing(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        explicit: Optional[AnalysisPriority] = None,
+        session: Optional[Session] = None,
+    ) -> Dict[str, Any]:
+        """
+        Priority and group an analysis job of a project step is queued with, the job being reprioritized along with
+        the step unless its priority is explicit
+        """
+        priority = self.get_analysis_priority(project_uuid, project_step_uuid, explicit, session)
+        return {
+            "priority": AnalysisPriorityPolicy.level(priority),
+            "group": None if explicit else (project_uuid, project_step_uuid),
+        }
+
+    def _submit_processing(self, submission: Submission, session: Optional[Session] = None) -> None:
+        """Queue the processing of a submission at its priority, left pending retry if dropped at shutdown"""
+        submission_id = submission.id
+        # The job runs in the context it is queued in, its logs carrying the submission
+        with log_context(submission_id=submission_id):
+            self.analysis_pool.submit(
+                self._process_code_metrics_threaded,
+                submission_id,
+                on_drop=lambda: self._interrupt_processing(None, submission_id),
+                job_id=submission_id,
+                **self._queueing(
+                    submission.project_uuid, submission.project_step_uuid, submission.analysis_priority, session
+                ),
+            )
+
+    def _run_batch_threaded(
+        self, function: Callable[..., Any], argument_lists: List[Tuple[Any, ...]], run_id: Optional[UUID] = None
+    ) -> None:
+        """
+        Run a function once per argument list in a worker, returning early once the workers are stopped or the
+        detection run of the batch is cancelled
+        """
+        for index, arguments in enumerate(argument_lists):
+            if self.analysis_pool.cancelled or (run_id is not None and self._is_run_cancelled(run_id)):
+                logger.info(f"Analysis batch cancelled after {index} of {len(argument_lists)} jobs")
+                return
+            function(*arguments)
+
+    def _compare_run_pairs_parallel(
+        self,
+        run_id: UUID,
+        argument_lists: List[Tuple[Any, ...]],
+        timeouts: Optional[Dict[str, Optional[float]]],
+        rescore: bool = False,
+        detection_options: Optional[Dict[str, Any]] = None,
+        cache_keys: Optional[Dict[Tuple[UUID, UUID], str]] = None,
+    ) -> None:
+        """
+        Compare the pairs of a detection run in the comparison processes with the stage timeouts and the detection
+        options of the run, from a worker writing their similarity records by batches as they complete, and counting
+        them in the progress of the run with the hits and misses of the token cache. The completed comparisons are
+        recorded with the key of their pair in the comparison cache, if any. When rescoring, the existing records of
+        the pairs are updated rather than kept. No more pairs are queued once the workers are stopped or the run is
+        cancelled.
+        """
+        cache_keys = cache_keys or {}
+        session = self._get_thread_session()
+        similarity_repo = SubmissionSimilarityRepository(session)
+        run_repo = SubmissionDetectionRunRepository(session)
+
+        def write(outcomes: List[PairOutcome]) -> None:
+            records = []
+            for outcome in outcomes:
+                # Recorded in the comparison process, out of reach of the scrapes
+                pipeline_metrics.replay((outcome.result or {}).get("metrics"))
+                submission1_id, submission2_id, project_uuid, project_step_uuid, *_ = outcome.pair
+                comparison = outcome.result or {"status": SimilarityStatus.FAILED.value, "error": outcome.error}
+                if comparison.get("results") is not None:
+                    comparison["results"]["cache_key"] = cache_keys.get((submission1_id, submission2_id))
+                # A pair compared meanwhile (with a new submission, say) keeps its comparison, unless rescored
+                if not rescore and similarity_repo.check_existing_comparison(submission1_id, submission2_id):
+                    continue
+                if rescore:
+                    # A rescored pair has its comparison replaced, in whichever order it was recorded
+                    existing = similarity_repo.get_comparison_pair(submission1_id, submission2_id)
+                    existing = existing or similarity_repo.get_comparison_pair(submission2_id, submission1_id)
+                    if existing and comparison.get("results") is not None:
+                        similarity_repo.update_results(existing.id, comparison["results"])
+                        continue
+                    if existing:
+                        similarity_repo.update_status(
+                            existing.id,
+                            SimilarityStatus(comparison["status"]),
+                            comparison.get("error"),
+                            comparison.get("elapsed_seconds"),
+                        )
+                        continue
+                record = {
+                    "submission_id": submission1_id,
+                    "compared_submission_id": submission2_id,
+                    "project_uuid": project_uuid,
+                    "project_step_uuid": project_step_uuid,
+                    "status": SimilarityStatus(comparison["status"]),
+                    "error_message": comparison.get("error"),
+                    "processing_time_seconds": comparison.get("elapsed_seconds"),
+                }
+                if comparison.get("results") is not None:
+                    record.update(SubmissionSimilarityRepository.results_fields(comparison["results"]))
+                records.append(record)
+            similarity_repo.create_batch(records)
+
+            if run_repo.increment_completed_pairs(
+                run_id,
+                count=len(outcomes),
+                token_cache_hits=sum((outcome.result or {}).get("token_cache_hits", 0) for outcome in outcomes),
+                token_cache_misses=sum((outcome.result or {}).get("token_cache_misses", 0) for outcome in outcomes),
+            ):
+                logger.info(f"Detection run {run_id} completed")
+                self.run_progress.forget(run_id)
+                self.run_results_feed.forget(run_id)
+                self._notify_run_completed(run_repo.get_by_id(run_id))
+            else:
+                self.run_progress.record(run_id, len(outcomes))
+                self.run_results_feed.publish(run_id)
+
+        try:
+            compared = self.pair_comparison_pool.run(
+                [(*arguments, timeouts, detection_options) for arguments in argument_lists],
+                compare_run_pair_in_process,
+                write,
+                stopped=lambda: self.analysis_pool.cancelled or self._is_run_cancelled(run_id),
+            )
+            if compared < len(argument_lists):
+                logger.info(f"Detection run {run_id} stopped after {compared} of {len(argument_lists)} pairs")
+        except Exception as e:
+            logger.error(f"Failed to compare the pairs of detection run {run_id}: {str(e)}")
+            run_repo.fail(run_id, f"Failed to compare the pairs: {str(e)}")
+
+    def compare_run_pair(
+        self,
+        submission1_id: UUID,
+        submission2_id: UUID,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        timeouts: Optional[Dict[str, Optional[float]]] = None,
+        detection_options: Optional[Dict[str, Any]] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare a pair of a detection run in a comparison process, within the comparison timeout (the configured one
+        by default) and with the detection options of the run, returning the status of the comparison with its
+        results, or its error, and the hits and misses of the token cache: the similarity record is written by the
+        worker feeding the processes.
+        """
+        timeouts = timeouts or self.get_stage_timeouts()
+        submission1 = self.submission_repository.get_by_id(submission1_id)
+        submission2 = self.submission_repository.get_by_id(submission2_id)
+        if not submission1 or not submission2:
+            logger.error(f"Submissions not found: {submission1_id}, {submission2_id}")
+            return {"status": SimilarityStatus.FAILED.value, "error": "Submissions not found"}
+
+        token_cache = self._get_token_cache(self.session, submission1.tenant_id)
+        with stage_deadline("comparison", timeouts["comparison_pair_seconds"]) as deadline:
+            try:
+                with pipeline_metrics.time_comparison():
+                    results = self._compute_comparison(
+                        submission1,
+                        submission2,
+                        self.submission_repository,
+                        self.similarity_repository,
+                        timeouts["tokenization_file_seconds"],
+                        token_cache,
+                        DetectionOptionsDto(**detection_options) if detection_options else None,
+                    )
+                comparison = {"status": SimilarityStatus.COMPLETED.value, "results": jsonable_encoder(results)}
+            except StageTimeout as e:
+                if e.deadline is not deadline:
+                    raise
+                message = f"Comparison timed out after {e.elapsed_seconds:.1f} seconds"
+                logger.warning(f"{message}: {submission1_id} and {submission2_id}")
+                comparison = {
+                    "status": SimilarityStatus.TIMED_OUT.value,
+                    "error": message,
+                    "elapsed_seconds": round(e.elapsed_seconds, 3),
+                }
+            except Exception as e:
+                logger.error(f"Failed comparison between {submission1_id} and {submission2_id}: {str(e)}")
+                comparison = {"status": SimilarityStatus.FAILED.value, "error": str(e)}
+        if token_cache is not None:
+            comparison.update(token_cache_hits=token_cache.hits, token_cache_misses=token_cache.misses)
+        return comparison
+
+    def _is_run_cancelled(self, run_id: UUID) -> bool:
+        """Whether a detection run was cancelled, or failed by another instance, read by the workers between pairs"""
+        status = SubmissionDetectionRunRepository(self._get_thread_session()).get_status(run_id)
+        return status in (DetectionRunStatus.CANCELLED, DetectionRunStatus.FAILED)
+
+    def create_detection_run(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        submission_ids: Optional[List[UUID]] = None,
+        include_corpus: bool = False,
+        teams: Optional[Dict[UUID, str]] = None,
+        include_same_team: bool = False,
+        include_all_versions: bool = False,
+        tokenization_timeout_seconds: Optional[float] = None,
+        comparison_timeout_seconds: Optional[float] = None,
+        profile: Optional[str] = None,
+        overrides: Optional[Dict[str, Any]] = None,
+        force_recompute: bool = False,
+        attach: bool = True,
+        include_references: bool = False,
+        scheduled: bool = False,
+        exclude_paths: Optional[List[str]] = None,
+    ) -> SubmissionDetectionRun:
+        """
+        Compare pairwise the given submissions of a project step, all of them if none is given (only the latest
+        version of the submission of each group, unless include_all_versions). The comparisons
+        are processed in the background, each distinct pair once: the pairs already compared are not scheduled.
+        With include_corpus, each submission is also matched with the archived submissions of the corpora of the
+        step, never with each other, and with include_references with the files of the reference sets, shared by
+        every step of the tenant of the step. The pairs of teammates are compared too, only marked in the matrix. The
+        timeouts of the tokenization of a file and of the comparison of a pair default to the configured ones, and
+        are recorded on the run. The run belongs to the tenant of the step, whose analysis profiles it resolves.
+
+        The pairs are compared with the options of the analysis profile (the built-in default one if None) overridden
+        by those given, the effective options being recorded on the run.
+
+        The comparisons are served from the comparison cache: a pair whose comparison has the key of the same
+        contents and options is not compared again, its comparison being copied from another pair of the same key if
+        needed, and a pair whose comparison is stale (its contents or options changed since) is compared again, in
+        place. The comparisons recorded before the cache, without key, are reused under the default profile without
+        overrides, as they were. With force_recompute, every pair is compared again.
+
+        At most one run of a project step and profile runs at a time, across the instances of the service: while one
+        runs, the run is returned instead of starting another (attach), or refused (ConflictException). A run
+        abandoned by its instance is taken over first, resumed or failed.
+
+        A run started by the schedule of the step is marked scheduled.
+
+        The files of the submissions matching the given exclude_paths (gitignore syntax) are left out of the
+        comparisons of the run, the stored submissions being untouched: the exclusions are an override of the detection
+        options, the comparisons of the pairs being keyed with them.
+
+        The run records the fingerprint of its environment (the versions of the tokenizers and algorithms, the hash
+        of its effective options) and a seed, for its verifications to execute again the same sample of its pairs.
+        """
+        if exclude_paths:
+            overrides = {**(overrides or {}), "exclude_paths": exclude_paths}
+        tenant_id = self.tenant_scope.step_tenant(project_step_uuid)
+        effective_options = self.get_analysis_profile_catalog().resolve(profile, overrides, tenant_id)
+        step_submissions = self.submission_repository.get_by_project_step(
+            project_uuid, project_step_uuid, include_quarantined=True
+        )
+        quarantined = [s.id for s in step_submissions if s.status == SubmissionStatus.QUARANTINED]
+        purged = [s.id for s in step_submissions if s.files_purged_at is not None]
+        step_submissions = [s for s in step_submissions if s.id not in quarantined and s.id not in purged]
+        if submission_ids is None:
+            submissions = (
+                step_submissions if include_all_versions else SubmissionRepository.latest_versions(step_submissions)
+            )
+        else:
+            excluded = [str(submission_id) for submission_id in submission_ids if submission_id in quarantined]
+            if excluded:
+                raise ValidationException(
+                    f"Quarantined submissions cannot be compared: {excluded}",
+                    details={"error_type": "submission_quarantined", "submission_ids": excluded},
+                )
+            excluded = [str(submission_id) for submission_id in submission_ids if submission_id in purged]
+            if excluded:
+                raise ValidationException(
+                    f"Submissions whose files were purged cannot be compared: {excluded}",
+                    details={"error_type": "submission_files_purged", "submission_ids": excluded},
+                )
+            by_id = {submission.id: submission for submission in step_submissions}
+            unknown = [str(submission_id) for submission_id in submission_ids if submission_id not in by_id]
+            if unknown:
+                raise ValidationException(f"Submissions not found in project step {project_step_uuid}: {unknown}")
+            submissions = [by_id[submission_id] for submission_id in dict.fromkeys(submission_ids)]
+
+        if len(submissions) < 2:
+            raise ValidationException("A detection run compares at least two submissions")
+
+        run_submission_ids = {submission.id for submission in submissions}
+        teams = teams or {}
+        unknown = [str(submission_id) for submission_id in teams if submission_id not in run_submission_ids]
+        if unknown:
+            raise ValidationException(f"Teams given for submissions outside of the run: {unknown}")
+
+        # Only analyzed submissions are compared, rather than producing partial results: the others are reported,
+        # those never processed being analyzed at once
+        not_analyzed = [s for s in submissions if s.processing_status != ProcessingStatus.ANALYZED]
+        if not_analyzed:
+            for submission in not_analyzed:
+                if submission.processing_status in (ProcessingStatus.RECEIVED, ProcessingStatus.PENDING_RETRY):
+                    self._submit_processing(submission)
+            message = f"{len(not_analyzed)} submissions of the run are not analyzed yet"
+            raise ValidationException(
+                message,
+                details={
+                    "error_type": "submissions_not_analyzed",
+                    "message": message,
+                    "pending_submissions": [
+                        {
+                            "submission_id": str(submission.id),
+                            "processing_status": ProcessingStatus(submission.processing_status).value,
+                            "processing_error": submission.processing_error,
+                        }
+                        for submission in not_analyzed
+                    ],
+                },
+            )
+
+        # One run of the step and profile at a time, across the instances
+        lock_key = DetectionRunLock.key(project_uuid, project_step_uuid, profile or DEFAULT_PROFILE_NAME)
+        run_locks = self.get_detection_run_locks()
+        running = run_locks.get_running(lock_key, get_paris_time())
+        if running is not None:
+            return run_locks.attach(running, attach)
+
+        pairs = SimilarityMatrix.pairs(submissions)
+        similarities = self.similarity_repository.get_between_submissions([s.id for s in submissions])
+        existing = {
+            SimilarityMatrix.pair_key(similarity.submission_id, similarity.compared_submission_id): similarity
+            for similarity in similarities
+        }
+        cache = self._get_comparison_cache(
+            project_uuid, project_step_uuid, effective_options["detection_options"], tenant_id
+        )
+        cache_keys = cache.keys(pairs)
+        legacy = (profile or DEFAULT_PROFILE_NAME) == DEFAULT_PROFILE_NAME and not overrides
+        compared = set()
+        if not force_recompute:
+            compared = {
+                key
+                for key, similarity in existing.items()
+                if cache.is_current(similarity, cache_keys) or (legacy and similarity.cache_key is None)
+            }
+        remaining = [pair for pair in pairs if SimilarityMatrix.pair_key(pair[0].id, pair[1].id) not in compared]
+        self.analysis_pool.ensure_capacity()
+        corpora = self.get_corpora()
+        corpus_items = corpora.get_step_items(project_uuid, project_step_uuid) if include_corpus else []
+        reference_items = corpora.get_reference_items(tenant_id) if include_references else []
+
+        # The run is recorded with the lock of its step and profile before its comparisons are copied from the cache,
+        # a run started meanwhile by another request or instance holding it already
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.create(
+            {
+                "tenant_id": tenant_id,
+                "project_uuid": project_uuid,
+                "project_step_uuid": project_step_uuid,
+                "submission_ids": [str(submission.id) for submission in submissions],
+                "pair_count": len(pairs),
+                "status": DetectionRunStatus.RUNNING,
+                "lock_key": lock_key,
+                "claimed_by": INSTANCE_ID,
+                "heartbeat_at": get_paris_time(),
+                "include_corpus": include_corpus,
+                "corpus_item_count": len(corpus_items),
+                "include_references": include_references,
+                "reference_item_count": len(reference_items),
+                "scheduled": scheduled,
+                "teams": {str(submission_id): team for submission_id, team in teams.items()},
+                "include_same_team": include_same_team,
+                "timeouts": self.get_stage_timeouts(tokenization_timeout_seconds, comparison_timeout_seconds),
+                "analysis_profile": profile or DEFAULT_PROFILE_NAME,
+                "effective_options": effective_options,
+                "exclude_paths": effective_options["detection_options"]["exclude_paths"],
+                "fingerprint": RunFingerprint.build(
+                    self.tokenization_service.get_language_versions(), effective_options
+                ),
+                "seed": secrets.randbelow(2**31),
+            }
+        )
+        if run is None:
+            return run_locks.attach(run_repo.get_by_lock_key(lock_key), attach)
+
+        if not force_recompute:
+            try:
+                compared |= self._copy_cached_comparisons(
+                    remaining, existing, cache_keys, project_uuid, project_step_uuid
+                )
+            except Exception as e:
+                run_repo.fail(run.id, f"Failed to copy the cached comparisons: {str(e)}")
+                raise
+        scheduled = [pair for pair in remaining if SimilarityMatrix.pair_key(pair[0].id, pair[1].id) not in compared]
+        # The stale comparisons are replaced
+        recompared = [pair for pair in scheduled if SimilarityMatrix.pair_key(pair[0].id, pair[1].id) in existing]
+
+        # The pairs are counted before they are scheduled, for the workers to count them as they compare them
+        run = run_repo.update(
+            run.id,
+            {
+                "scheduled_pair_count": len(scheduled),
+                "cached_pair_count": len(pairs) - len(scheduled),
+                "completed_pair_count": len(pairs) - len(scheduled),
+                "status": DetectionRunStatus.RUNNING if scheduled else DetectionRunStatus.COMPLETED,
+                "completed_at": None if scheduled else get_paris_time(),
+                "lock_key": lock_key if scheduled else None,
+            },
+        )
+        if scheduled:
+            self.run_progress.start(run.id)
+        else:
+            self._notify_run_completed(run)
+
+        # The pairs are compared in parallel by the comparison processes, fed by one job at the step's priority
+        queueing = self._queueing(project_uuid, project_step_uuid)
+        with log_context(run_id=run.id):
+            if scheduled:
+                try:
+                    self.analysis_pool.submit(
+                        self._compare_run_pairs_parallel,
+                        run.id,
+                        self._pair_arguments(scheduled, existing, project_uuid, project_step_uuid),
+                        run.timeouts,
+                        bool(recompared),
+                        effective_options["detection_options"],
+                        cache_keys,
+                        **queueing,
+                    )
+                except Exception as e:
+                    # Not to hold the lock of the step without comparing anything
+                    run_repo.fail(run.id, f"Failed to schedule the pairs: {str(e)}")
+                    raise
+
+            if corpus_items or reference_items:
+                self.analysis_pool.submit(
+                    self._run_batch_threaded,
+                    self._process_corpus_matches_threaded,
+                    [(submission.id, bool(corpus_items), bool(reference_items)) for submission in submissions],
+                    run.id,
+                    **queueing,
+                )
+
+        logger.info(
+            f"Started detection run {run.id} of step {project_step_uuid} with profile {run.analysis_profile}: "
+            f"{len(submissions)} submissions, {len(pairs)} pairs, {len(scheduled)} scheduled "
+            f"({len(recompared)} compared again), {run.cached_pair_count} cached, {len(corpus_items)} corpus items, "
+            f"{len(reference_items)} reference files"
+        )
+        return run
+
+    def get_detection_run_locks(self) -> DetectionRunLocks:
+        """Get the locks of the running detection runs, the stale runs taken over being resumed as configured"""
+        return DetectionRunLocks(
+            SubmissionDetectionRunRepository(self.session),
+            self.run_lease_seconds,
+            self.orphaned_run_action,
+            self._resume_detection_run,
+        )
+
+    def _resume_detection_run(self, run: SubmissionDetectionRun) -> None:
+        """
+        Schedule again the pairs of a detection run taken over whose comparison is not current, with the options and
+        timeouts of the run, those compared before its instance stopped being counted as compared (its corpus
+        matches are not resumed)
+        """
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        submissions = [s for s in map(self.submission_repository.get_by_id, submission_ids) if s is not None]
+        pairs = SimilarityMatrix.pairs(submissions)
+        similarities = self.similarity_repository.get_between_submissions([s.id for s in submissions])
+        existing = {
+            SimilarityMatrix.pair_key(similarity.submission_id, similarity.compared_submission_id): similarity
+            for similarity in similarities
+        }
+        effective_options = run.effective_options or {}
+        detection_options = effective_options.get("detection_options")
+        cache = self._get_comparison_cache(run.project_uuid, run.project_step_uuid, detection_options, run.tenant_id)
+        cache_keys = cache.keys(pairs)
+        legacy = run.analysis_profile == DEFAULT_PROFILE_NAME and not effective_options.get("overrides")
+        compared = {
+            key
+            for key, similarity in existing.items()
+            if cache.is_current(similarity, cache_keys) or (legacy and similarity.cache_key is None)
+        }
+        remaining = [pair for pair in pairs if SimilarityMatrix.pair_key(pair[0].id, pair[1].id) not in compared]
+        recompared = [pair for pair in remaining if SimilarityMatrix.pair_key(pair[0].id, pair[1].id) in existing]
+
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.update(
+            run.id,
+            {
+                "completed_pair_count": max(run.pair_count - len(remaining), 0),
+                "status": DetectionRunStatus.RUNNING if remaining else DetectionRunStatus.COMPLETED,
+                "completed_at": None if remaining else get_paris_time(),
+                "lock_key": run.lock_key if remaining else None,
+            },
+        )
+        logger.info(f"Resuming detection run {run.id}: {len(remaining)} of {run.pair_count} pairs left")
+        if not remaining:
+            self._notify_run_completed(run)
+            return
+
+        self.run_progress.start(run.id)
+        self.analysis_pool.submit(
+            self._compare_run_pairs_parallel,
+            run.id,
+            self._pair_arguments(remaining, existing, run.project_uuid, run.project_step_uuid),
+            run.timeouts,
+            bool(recompared),
+            detection_options,
+            cache_keys,
+            **self._queueing(run.project_uuid, run.project_step_uuid),
+        )
+
+    def _get_comparison_cache(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        detection_options: Optional[Dict[str, Any]],
+        tenant_id: Optional[str] = None,
+    ) -> ComparisonCache:
+        """
+        Get the comparison cache of the pairs of a project step compared with the given detection options (the
+        configured ones if None): its keys change with the tokenizer version, the options, and the baselines and
+        allowed snippets of the step, and with its tenant
+        """
+        tokenization_options = self._get_tokenization_options(project_uuid, project_step_uuid, self.session)
+        references = [
+            {"baseline": baseline.fingerprints}
+            for baseline in self.baseline_repository.get_by_project_step(project_uuid, project_step_uuid)
+        ] + [
+            {"allowed_snippet": snippet.fingerprints, "language": snippet.language}
+            for snippet in self.allowed_snippet_repository.get_by_project_step(project_uuid, project_step_uuid)
+        ]
+        return ComparisonCache(
+            {
+                "tokenizer_version": TOKENIZER_VERSION,
+                "detection_options": detection_options or self._get_detection_options().model_dump(mode="json"),
+                "tokenization_options": tokenization_options.model_dump(mode="json"),
+                "cross_language_detection": self.cross_language_detection,
+            },
+            references,
+            tenant_id,
+        )
+
+    def _copy_cached_comparisons(
+        self,
+        pairs: List[Tuple[Submission, Submission]],
+        existing: Dict[FrozenSet[UUID], SubmissionSimilarity],
+        cache_keys: Dict[Tuple[UUID, UUID], str],
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+    ) -> Set[FrozenSet[UUID]]:
+        """
+        Copy to the given pairs the completed comparisons of other pairs of the step with the same key, such as a
+        new version of a submission with the same files, and get the pairs served so. A stale record of a pair is
+        updated in its own order, a new record being created in the order of the copied comparison.
+        """
+        if not pairs:
+            return set()
+        wanted = {
+            cache_keys[order]
+            for first, second in pairs
+            for order in ((first.id, second.id), (second.id, first.id))
+            if order in cache_keys
+        }
+        sources = {
+            similarity.cache_key: similarity
+            for similarity in self.similarity_repository.get_completed_by_cache_keys(
+                project_uuid, project_step_uuid, sorted(wanted)
+            )
+        }
+        copied = set()
+        records = []
+        for first, second in pairs:
+            key = SimilarityMatrix.pair_key(first.id, second.id)
+            similarity = existing.get(key)
+            if similarity:
+                orders = [(similarity.submission_id, similarity.compared_submission_id)]
+            else:
+                orders = [(first.id, second.id), (second.id, first.id)]
+            for submission_id, compared_submission_id in orders:
+                source = sources.get(cache_keys.get((submission_id, compared_submission_id)))
+                if source is None:
+                    continue
+                results = SubmissionSimilarityRepository.cached_results(source)
+                if similarity:
+                    self.similarity_repository.update_results(similarity.id, results)
+                else:
+                    records.append(
+                        {
+                            "submission_id": submission_id,
+                            "compared_submission_id": compared_submission_id,
+                            "project_uuid": project_uuid,
+                            "project_step_uuid": project_step_uuid,
+                            **SubmissionSimilarityRepository.results_fields(results),
+                        }
+                    )
+                copied.add(key)
+                break
+        if records:
+            self.similarity_repository.create_batch(records)
+        return copied
+
+    @staticmethod
+    def _pair_arguments(
+        pairs: List[Tuple[Submission, Submission]],
+        existing: Dict[FrozenSet[UUID], SubmissionSimilarity],
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+    ) -> List[Tuple[UUID, UUID, UUID, UUID]]:
+        """Arguments of the comparisons of the pairs of a run, a pair already recorded being compared in its order"""
+        arguments = []
+        for first, second in pairs:
+            similarity = existing.get(SimilarityMatrix.pair_key(first.id, second.id))
+            if similarity:
+                arguments.append(
+                    (similarity.submission_id, similarity.compared_submission_id, project_uuid, project_step_uuid)
+                )
+            else:
+                arguments.append((first.id, second.id, project_uuid, project_step_uuid))
+        return arguments
+
+    def get_detection_run(self, run_id: UUID) -> Tuple[SubmissionDetectionRun, Dict[str, Any]]:
+        """
+        Get a detection run with its progress: the percentage of its pairs compared, and while it is running the time
+        left at its recent throughput (unknown if its pairs are compared by another instance, or since a restart)
+        """
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        throughput = self.run_progress.throughput(run_id) if run.status == DetectionRunStatus.RUNNING else None
+        progress = RunProgressTracker.estimate(run.pair_count, run.completed_pair_count, throughput)
+        if run.status in (DetectionRunStatus.CANCELLED, DetectionRunStatus.FAILED):
+            progress["eta_seconds"] = None
+        return run, progress
+
+    def cancel_detection_run(self, run_id: UUID) -> Tuple[SubmissionDetectionRun, Dict[str, Any]]:
+        """
+        Cancel a running detection run: the workers schedule none of its remaining pairs, the pairs already compared
+        being kept and still listed by its matrix, as partial
+        """
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        if not run_repo.cancel(run_id):
+            status = DetectionRunStatus(run_repo.get_status(run_id)).value
+            message = f"Detection run {run_id} is already {status}"
+            raise ConflictException(
+                message, details={"error_type": "detection_run_finished", "message": message, "status": status}
+            )
+        self.run_progress.forget(run_id)
+        self.run_results_feed.forget(run_id)
+        self.session.refresh(run)
+        logger.info(f"Cancelled detection run {run_id} after {run.completed_pair_count} of {run.pair_count} pairs")
+        return self.get_detection_run(run_id)
+
+    def rescore_detection_run(self, run_id: UUID) -> Tuple[SubmissionDetectionRun, Dict[str, Any]]:
+        """
+        Compare again every pair of the submissions of a finished detection run, with the starter code and the
+        allowed snippets the project step has now, their similarity records being updated in place. The tokens of
+        the files come from the token cache when enabled, the files not being tokenized again; the pairs matched
+        with the corpora are not rescored. The fingerprint of the run is recorded again, for the current tokenizers
+        and algorithms.
+        """
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        if run.status == DetectionRunStatus.RUNNING:
+            message = f"Detection run {run_id} is still running"
+            raise ConflictException(message, details={"error_type": "run_in_progress", "message": message})
+        # Refused while another run of the step and profile runs, the rescore holding their lock
+        profile = run.analysis_profile or DEFAULT_PROFILE_NAME
+        lock_key = DetectionRunLock.key(run.project_uuid, run.project_step_uuid, profile)
+        run_locks = self.get_detection_run_locks()
+        running = run_locks.get_running(lock_key, get_paris_time())
+        if running is not None:
+            run_locks.attach(running, attach=False)
+
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        submissions = [s for s in map(self.submission_repository.get_by_id, submission_ids) if s is not None]
+        pairs = SimilarityMatrix.pairs(submissions)
+        similarities = self.similarity_repository.get_between_submissions([s.id for s in submissions])
+        existing = {
+            SimilarityMatrix.pair_key(similarity.submission_id, similarity.compared_submission_id): similarity
+            for similarity in similarities
+        }
+        detection_options = (run.effective_options or {}).get("detection_options")
+        cache = self._get_comparison_cache(run.project_uuid, run.project_step_uuid, detection_options, run.tenant_id)
+        # The reports rendered from the previous scores are stale
+        SubmissionReportJobRepository(self.session).delete_by_similarity_ids([s.id for s in similarities])
+        self.analysis_pool.ensure_capacity()
+
+        run = run_repo.update(
+            run_id,
+            {
+                "status": DetectionRunStatus.RUNNING if pairs else DetectionRunStatus.COMPLETED,
+                "lock_key": lock_key if pairs else None,
+                "claimed_by": INSTANCE_ID,
+                "heartbeat_at": get_paris_time(),
+                "failed_at": None,
+                "error_message": None,
+                "pair_count": len(pairs),
+                "scheduled_pair_count": len(pairs),
+                "cached_pair_count": 0,
+                "completed_pair_count": 0,
+                "token_cache_hits": 0,
+                "token_cache_misses": 0,
+                "summary": None,
+                "completed_at": None if pairs else get_paris_time(),
+                "rescored_at": get_paris_time(),
+                # The pairs are compared again with the tokenizers and algorithms of the service now
+                "fingerprint": RunFingerprint.build(
+                    self.tokenization_service.get_language_versions(), run.effective_options
+                ),
+            },
+        )
+        logger.info(f"Rescoring the {len(pairs)} pairs of detection run {run_id}")
+        if not pairs:
+            return self.get_detection_run(run_id)
+
+        self.run_progress.start(run.id)
+        queueing = self._queueing(run.project_uuid, run.project_step_uuid)
+        with log_context(run_id=run.id):
+            self.analysis_pool.submit(
+                self._compare_run_pairs_parallel,
+                run.id,
+                self._pair_arguments(pairs, existing, run.project_uuid, run.project_step_uuid),
+                run.timeouts,
+                True,
+                detection_options,
+                cache.keys(pairs),
+                **queueing,
+            )
+        return self.get_detection_run(run_id)
+
+    def verify_detection_run(
+        self, run_id: UUID, sample_size: int = DEFAULT_VERIFICATION_SAMPLE_SIZE, seed: Optional[int] = None
+    ) -> Dict[str, Any]:
+        """
+        Verify that a finished detection run is reproducible (see DetectionRunVerifier): a sample of its completed
+        pairs, drawn from the seed of the run (or the given one), is compared again in this instance with the options
+        and timeouts of the run, without the token cache. The pairs whose comparison was replaced since the run (by a
+        run of other options, or after a change of their contents or references) are only counted as superseded. No
+        similarity record is written.
+        """
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        if run.status == DetectionRunStatus.RUNNING:
+            message = f"Detection run {run_id} is still running"
+            raise ConflictException(message, details={"error_type": "run_in_progress", "message": message})
+        seed = seed if seed is not None else run.seed or 0
+        detection_options = (run.effective_options or {}).get("detection_options")
+
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        submissions = {s.id: s for s in map(self.submission_repository.get_by_id, submission_ids) if s is not None}
+        pairs = SimilarityMatrix.pairs(list(submissions.values()))
+        cache_keys = self._get_comparison_cache(
+            run.project_uuid, run.project_step_uuid, detection_options, run.tenant_id
+        ).keys(pairs)
+        similarities = self.similarity_repository.get_between_submissions(list(submissions))
+        return self._get_run_verifier(run).verify(run, submissions, similarities, cache_keys, sample_size, seed)
+
+    def _get_run_verifier(self, run: SubmissionDetectionRun) -> DetectionRunVerifier:
+        """
+        Get the verifier of a detection run, comparing its pairs again in this instance with the options and timeouts
+        of the run, without the token cache, no similarity record being written
+        """
+        detection_options = (run.effective_options or {}).get("detection_options")
+        options = DetectionOptionsDto(**detection_options) if detection_options else None
+        timeouts = run.timeouts or self.get_stage_timeouts()
+
+        def compare(submission: Submission, compared_submission: Submission) -> Dict[str, Any]:
+            results = self._compute_comparison(
+                submission,
+                compared_submission,
+                self.submission_repository,
+                self.similarity_repository,
+                timeouts["tokenization_file_seconds"],
+                None,
+                options,
+            )
+            return SubmissionSimilarityRepository.results_fields(jsonable_encoder(results))
+
+        return DetectionRunVerifier(
+            compare,
+            SubmissionSimilarityRepository.cached_results,
+            timeouts["comparison_pair_seconds"],
+            self.tokenization_service.get_language_versions(),
+        )
+
+    def get_similarity_matrix(
+        self,
+        run_id: UUID,
+        min_similarity: float = 0.0,
+        skip: int = 0,
+        limit: int = 100,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+        merge_threshold: float = DEFAULT_MERGE_THRESHOLD,
+        include_same_team: Optional[bool] = None,
+        flagged_only: bool = False,
+        sort_by: PairSortField = PairSortField.OVERALL_SIMILARITY,
+    ) -> Dict[str, Any]:
+        """
+        Get the similarity matrix of a detection run, the pairs under the minimum similarity (or not flagged, if
+        only the flagged ones are requested) not listed, the listed ones sorted by the given score. Whether the
+        pairs of teammates are flagged defaults to the choice of the run.
+        """
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        similarities = self.similarity_repository.get_between_submissions(submission_ids)
+        flagger = self._get_run_flagger(run, flag_threshold, min_token_count)
+        matrix = self._get_matrix(run, flagger, merge_threshold, include_same_team)
+
+        corpus_matches, reference_matches = self._get_corpus_matches(run, matrix, submission_ids, min_similarity)
+
+        return {
+            "run_id": run.id,
+            "project_uuid": run.project_uuid,
+            "project_step_uuid": run.project_step_uuid,
+            "created_at": run.created_at,
+            "pair_count": run.pair_count,
+            "status": run.status,
+            "partial": run.status != DetectionRunStatus.COMPLETED,
+            **matrix.build(submission_ids, similarities, min_similarity, skip, limit, flagged_only, sort_by),
+            "corpus_matches": corpus_matches,
+            "reference_matches": reference_matches,
+        }
+
+    def _get_corpus_matches(
+        self, run: SubmissionDetectionRun, matrix: SimilarityMatrix, submission_ids: List[UUID], min_similarity: float
+    ) -> Tuple[List[Dict[str, Any]], List[Dict[str, Any]]]:
+        """
+        Get the matches of the submissions of a run with the archived submissions, and apart with the files of the
+        reference sets, as included by the run
+        """
+        if not run.include_corpus and not run.include_references:
+            return [], []
+        matches = SubmissionCorpusMatchRepository(self.session).get_by_submission_ids(submission_ids)
+        corpus_repository = SubmissionCorpusRepository(self.session)
+        items = {
+            item.id: item
+            for item in corpus_repository.get_items_by_ids(list({match.corpus_item_id for match in matches}))
+        }
+        reference_sets = {
+            corpus.id: corpus for corpus in corpus_repository.get_by_kind(CorpusKind.REFERENCE, run.tenant_id)
+        }
+        reference_item_ids = {item.id for item in items.values() if item.corpus_id in reference_sets}
+
+        corpus_matches, reference_matches = [], []
+        if run.include_corpus:
+            labels = {item.id: item.label for item in items.values() if item.id not in reference_item_ids}
+            corpus_matches = matrix.build_corpus_matches(
+                [match for match in matches if match.corpus_item_id not in reference_item_ids], labels, min_similarity
+            )
+        if run.include_references:
+            reference_matches = matrix.build_reference_matches(
+                [match for match in matches if match.corpus_item_id in reference_item_ids],
+                items,
+                reference_sets,
+                min_similarity,
+            )
+        return corpus_matches, reference_matches
+
+    def iter_run_results(
+        self,
+        run_id: UUID,
+        active: Callable[[], bool] = lambda: True,
+        poll_seconds: float = DEFAULT_POLL_SECONDS,
+    ) -> Iterator[Dict[str, Any]]:
+        """
+        Iterate over the compared pairs of a detection run as they are written, as the entries of its matrix: the
+        pairs compared before the run first, then each batch as soon as it is written, until the run is no longer
+        running or the follower is no longer active. A submission found too short by a later pair is flagged in the
+        entries after it only.
+        """
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        flagger = self._get_run_flagger(run)
+        matrix = self._get_matrix(run, flagger)
+        seen: Set[FrozenSet[UUID]] = set()
+        too_short: Set[UUID] = set()
+        since = None
+        while active():
+            # Read before the pairs, so that a batch written meanwhile is not waited for
+            version = self.run_results_feed.version(run_id)
+            polled_at = get_paris_time()
+            status = run_repo.get_status(run_id)
+
+            similarities = []
+            for similarity in self.similarity_repository.get_between_submissions_since(submission_ids, since):
+                key = SimilarityMatrix.pair_key(similarity.submission_id, similarity.compared_submission_id)
+                if len(key) == 2 and key not in seen:
+                    seen.add(key)
+                    similarities.append(similarity)
+            too_short |= flagger.too_short_submissions(similarities)
+            for similarity in similarities:
+                yield matrix.entry(similarity, too_short)[0]
+
+            if status != DetectionRunStatus.RUNNING:
+                return
+            since = polled_at - timedelta(seconds=RUN_RESULTS_LOOKBACK_SECONDS)
+            self.run_results_feed.wait(run_id, version, poll_seconds)
+
+    def get_detection_run_summary(
+        self, run_id: UUID, buckets: int = DEFAULT_SUMMARY_BUCKETS, top: int = DEFAULT_CENTRAL_SUBMISSIONS
+    ) -> Dict[str, Any]:
+        """
+        Get the summary of the score distribution of a detection run, its histogram in the given number of buckets
+        and its top central submissions. The summary is computed once, the first time it is requested after all the
+        pairs of the run are compared, with the flagging configuration of the step at that time; the summary of a
+        run in progress is computed on each request, as incomplete.
+        """
+        if buckets < 1 or HISTOGRAM_RESOLUTION % buckets:
+            raise ValidationException(f"The number of buckets must divide {HISTOGRAM_RESOLUTION}")
+        run_repo = SubmissionDetectionRunRepository(self.session)
+        run = run_repo.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+
+        summarizer = DetectionRunSummarizer()
+        summary = run.summary
+        if summary is None:
+            submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+            similarities = self.similarity_repository.get_between_submissions(submission_ids)
+            matrix = self._get_matrix(run, self._get_run_flagger(run))
+            summary = summarizer.summarize(
+                matrix.build(submission_ids, similarities, limit=len(similarities)), run.pair_count
+            )
+            if summary["complete"]:
+                run_repo.update(run_id, {"summary": summary})
+        return {
+            "run_id": run.id,
+            "status": run.status,
+            "partial": run.status != DetectionRunStatus.COMPLETED,
+            **summarizer.view(summary, buckets, top),
+            "cached_pairs": run.cached_pair_count,
+        }
+
+    def export_detection_run(
+        self,
+        run_id: UUID,
+        export_format: str,
+        min_similarity: float = 0.0,
+        flagged_only: bool = False,
+        include_metrics: bool = False,
+        scope: str = "pairs",
+    ) -> Tuple[Iterator[str], str]:
+        """
+        Export the pairs of a detection run from the minimum similarity (the flagged ones only if requested) as
+        CSV or as a JSON document, streamed, along with the name of the exported file. With include_metrics, the
+        code metrics of both submissions are exported with each pair. The matches of a run including the reference
+        sets are listed after the pairs in JSON, and exported instead of them as CSV with the references scope.
+        """
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        flagger = self._get_run_flagger(run)
+        metrics = None
+        if include_metrics:
+            submissions = self.submission_repository.get_by_project_step(
+                run.project_uuid, run.project_step_uuid, include_deleted=True
+            )
+            metrics = {submission.id: (submission.code_metrics or {}).get("submission") for submission in submissions}
+        matrix = self._get_matrix(run, flagger)
+        exporter = DetectionRunExporter(
+            matrix,
+            submission_ids,
+            lambda: self.similarity_repository.iter_between_submissions(submission_ids),
+            metrics,
+        )
+        reference_matches = None
+        if run.include_references:
+            reference_matches = [
+                match
+                for match in self._get_corpus_matches(run, matrix, submission_ids, min_similarity)[1]
+                if match["suspicious"] or not flagged_only
+            ]
+        filename = f"detection-run-{run.project_step_uuid}-{run.id}.{export_format}"
+        if export_format == "csv" and scope == "references":
+            return exporter.references_csv(reference_matches or []), filename.replace(".csv", "-references.csv")
+        if export_format == "csv":
+            return exporter.csv(min_similarity, flagged_only), filename
+        header = {
+            "run_id": run.id,
+            "project_uuid": run.project_uuid,
+            "project_step_uuid": run.project_step_uuid,
+            "created_at": run.created_at,
+            "submission_ids": submission_ids,
+            "flag_threshold": flagger.flag_threshold,
+            "min_token_count": flagger.min_token_count,
+            "include_same_team": run.include_same_team,
+            "min_similarity": min_similarity,
+            "flagged_only": flagged_only,
+            "include_metrics": include_metrics,
+            "include_references": run.include_references,
+            "exclude_paths": run.exclude_paths or [],
+            "fingerprint": run.fingerprint,
+            "seed": run.seed,
+        }
+        return exporter.json(header, min_similarity, flagged_only, reference_matches), filename
+
+    def get_comparison_report(self, similarity_id: UUID) -> str:
+        """Render the HTML report of the comparison of a pair of submissions, from its stored fragments"""
+        similarity = self.similarity_repository.get_by_id(similarity_id)
+        if not similarity:
+            raise NotFoundException("Similarity", str(similarity_id))
+        submission = self.submission_repository.get_by_id(similarity.submission_id)
+        flagger = self._get_flagger(submission.project_uuid, submission.project_step_uuid)
+        too_short = flagger.too_short_submissions([similarity])
+        return ComparisonReportRenderer().render_pair(self._report_pair(similarity, flagger, too_short))
+
+    def request_comparison_report_pdf(self, similarity_id: UUID) -> SubmissionReportJob:
+        """
+        Render the PDF report of the comparison of a pair of submissions in the background, waiting for it a few
+        seconds. The returned job is completed with the report if it was rendered in time, else it is to be polled.
+        """
+        similarity = self.similarity_repository.get_by_id(similarity_id)
+        if not similarity:
+            raise NotFoundException("Similarity", str(similarity_id))
+        submission = self.submission_repository.get_by_id(similarity.submission_id)
+        flagger = self._get_flagger(submission.project_uuid, submission.project_step_uuid)
+        pair = self._report_pair(similarity, flagger, flagger.too_short_submissions([similarity]))
+
+        report_jobs = SubmissionReportJobRepository(self.session)
+        job = report_jobs.create({"similarity_id": similarity_id, "report_format": "pdf"})
+        future = self.report_executor.submit(self._render_report_pdf_threaded, job.id, pair)
+        try:
+            future.result(timeout=self.pdf_report_wait_seconds)
+        except FutureTimeoutError:
+            logger.info(f"Rendering the PDF report of comparison {similarity_id} in the background: job {job.id}")
+        return report_jobs.get_by_id(job.id)
+
+    def _render_report_pdf_threaded(self, job_id: UUID, pair: Dict[str, Any]) -> None:
+        """Render the PDF report of a comparison in a thread, and store it with the job"""
+        report_jobs = SubmissionReportJobRepository(self._get_thread_session())
+        start_time = time.time()
+        try:
+            report_jobs.update_status(job_id, SimilarityStatus.PROCESSING)
+            content = PdfReportRenderer().render_pair(pair)
+            report_jobs.update_status(
+                job_id, SimilarityStatus.COMPLETED, content=content, processing_time_seconds=time.time() - start_time
+            )
+        except Exception as e:
+            logger.error(f"Failed to render the PDF report of job {job_id}: {str(e)}")
+            report_jobs.update_status(job_id, SimilarityStatus.FAILED, error_message=str(e))
+
+    def get_report_job(self, job_id: UUID) -> SubmissionReportJob:
+        """Get a report job, to poll the rendering of its report"""
+        job = SubmissionReportJobRepository(self.session).get_by_id(job_id)
+        if not job:
+            raise NotFoundException("Report job", str(job_id))
+        return job
+
+    def get_report_job_content(self, job_id: UUID) -> bytes:
+        """Get the rendered report of a completed report job"""
+        job = self.get_report_job(job_id)
+        if job.status != SimilarityStatus.COMPLETED:
+            raise ValidationException(f"The report of job {job_id} is not rendered: {job.status.value}")
+        return job.content
+
+    def get_evidence_packages(self, session: Optional[Session] = None) -> EvidencePackages:
+        """
+        Get the evidence packages of the pairs of the detection runs, on a session (this one if None), built by the
+        report executor on the sessions of its threads
+        """
+        session = session or self.session
+        return EvidencePackages(
+            SubmissionDetectionRunRepository(session),
+            SubmissionSimilarityRepository(session),
+            SubmissionRepository(session),
+            SubmissionReportJobRepository(session),
+            self._run_report_pair,
+            self._check_not_quarantined,
+            self._read_submission_files,
+            lambda pair: ComparisonReportRenderer().render_pair(pair),
+            lambda pair: PdfReportRenderer().render_pair(pair),
+            lambda *arguments: self.report_executor.submit(self._build_evidence_package_threaded, *arguments),
+        )
+
+    def _run_report_pair(self, run: SubmissionDetectionRun, similarity: SubmissionSimilarity) -> Dict[str, Any]:
+        """Report pair of a comparison of a run, flagged as the run flags its pairs"""
+        flagger = self._get_run_flagger(run)
+        return self._report_pair(similarity, flagger, flagger.too_short_submissions([similarity]))
+
+    def _build_evidence_package_threaded(self, *arguments: Any) -> None:
+        """Build the evidence package of a comparison in a thread, with a session of its own"""
+        self.get_evidence_packages(self._get_thread_session()).build(*arguments)
+
+    def get_detection_run_report(self, run_id: UUID, min_similarity: Optional[float] = None) -> str:
+        """
+        Render the HTML report of a detection run, with the comparison of each of its flagged pairs, or of each of
+        its completed pairs from the minimum similarity when one is given, and apart its matches with the reference
+        sets, flagged or from the minimum similarity likewise
+        """
+        matrix = self.get_similarity_matrix(run_id, min_similarity or 0.0, limit=MAX_REPORT_PAIRS)
+        if min_similarity is None:
+            entries = matrix["flagged_pairs"]
+            reference_matches = [match for match in matrix["reference_matches"] if match["suspicious"]]
+        else:
+            entries = [entry for entry in matrix["pairs"] if entry["status"] == SimilarityStatus.COMPLETED]
+            reference_matches = [
+                match for match in matrix["reference_matches"] if match["status"] == SimilarityStatus.COMPLETED
+            ]
+
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        flagger = self._get_run_flagger(run)
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        similarities = {s.id: s for s in self.similarity_repository.get_between_submissions(submission_ids)}
+        too_short = set(matrix["too_short_submissions"])
+        pairs = [self._report_pair(similarities[entry["similarity_id"]], flagger, too_short) for entry in entries]
+        configuration = {
+            "flag_threshold": matrix["flag_threshold"],
+            "min_token_count": matrix["min_token_count"],
+            "min_similarity": min_similarity,
+            "include_same_team": matrix["include_same_team"],
+            "compared_pairs": matrix["compared_pairs"],
+            "flagged_pairs": len(matrix["flagged_pairs"]),
+            "exclude_paths": run.exclude_paths or [],
+        }
+        run_data = {"run_id": run_id, "configuration": configuration}
+        if run.include_references:
+            run_data["reference_matches"] = reference_matches
+        return ComparisonReportRenderer().render_run(run_data, pairs)
+
+    def _report_pair(
+        self, similarity: SubmissionSimilarity, flagger: SimilarityFlagger, too_short: Set[UUID]
+    ) -> Dict[str, Any]:
+        """Stored fragments and sources of a comparison, and the configuration it was made and flagged with"""
+        details = similarity.similarity_details or {}
+        submission1 = self.submission_repository.get_by_id(similarity.submission_id)
+        submission2 = self.submission_repository.get_by_id(similarity.compared_submission_id)
+        return {
+            "similarity_id": similarity.id,
+            "submission1": {"id": similarity.submission_id, "link": submission1.link if submission1 else None},
+            "submission2": {"id": similarity.compared_submission_id, "link": submission2.link if submission2 else None},
+            "overall_similarity": similarity.overall_similarity,
+            "status": similarity.status,
+            "suspicious": flagger.flag(similarity, too_short)["suspicious"],
+            "configuration": {
+                "flag_threshold": flagger.flag_threshold,
+                "min_token_count": flagger.min_token_count,
+                "detection_algorithm": similarity.detection_algorithm,
+                "detection_options": details.get("detection_options"),
+                "tokenization_options": details.get("tokenization_options"),
+                "baseline_excluded_tokens": (details.get("baseline") or {}).get("excluded_tokens"),
+                "sanctioned_overlap_tokens": (details.get("sanctioned_overlap") or {}).get("excluded_tokens"),
+                "cross_language": details.get("cross_language"),
+                "run_excluded_files": details.get("run_excluded_files"),
+            },
+            "fragments": details.get("fragments", []),
+            "sources": details.get("fragment_sources") or {},
+            "file_similarities": details.get("file_similarities"),
+        }
+
     def _process_single_comparison_threaded(
-        self, submission1_id: UUID, submission2_id: UUID, project_uuid: UUID, project_step_uuid: UUID
+        self,
+        submission1_id: UUID,
+        submission2_id: UUID,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        timeouts: Optional[Dict[str, Optional[float]]] = None,
+        token_cache: Optional[TokenStreamCache] = None,
     ) -> None:
-        """Process a single comparison in a thread with its own database session"""
+        """
+        Process a single comparison in a thread with its own database session, within the comparison timeout (the
+        configured one by default): a comparison past it is stopped and marked timed out, with its elapsed time.
+        The files are tokenized through the given token cache, which counts its hits and misses.
+        """
         try:
             # Get thread-local session and repositories
             thread_session = self._get_thread_session()
@@ -148,398 +1631,3185 @@ class DetectionIntegrationService:
             )
 
             # Process the comparison using existing logic
-            self._process_single_comparison_with_repos(
-                similarity_record, submission1, submission2, thread_submission_repo, thread_similarity_repo
-            )
+            timeouts = timeouts or self.get_stage_timeouts()
+            with stage_deadline("comparison", timeouts["comparison_pair_seconds"]) as deadline:
+                try:
+                    self._process_single_comparison_with_repos(
+                        similarity_record,
+                        submission1,
+                        submission2,
+                        thread_submission_repo,
+                        thread_similarity_repo,
+                        timeouts["tokenization_file_seconds"],
+                        token_cache,
+                    )
+                except StageTimeout as e:
+                    if e.deadline is not deadline:
+                        raise
+                    message = f"Comparison timed out after {e.elapsed_seconds:.1f} seconds"
+                    logger.warning(f"{message}: {submission1_id} and {submission2_id}")
+                    thread_similarity_repo.update_status(
+                        similarity_record.id, SimilarityStatus.TIMED_OUT, message, round(e.elapsed_seconds, 3)
+                    )
+                    return
 
             logger.info(f"Completed async comparison between {submission1_id} and {submission2_id}")
 
         except Exception as e:
             logger.error(f"Failed async comparison between {submission1_id} and {submission2_id}: {str(e)}")
 
-    def _process_single_comparison_with_repos(
-        self,
-        similarity_record,
-        submission1: Submission,
-        submission2: Submission,
-        submission_repo: SubmissionRepository,
-        similarity_repo: SubmissionSimilarityRepository,
-    ) -> None:
-        """Process comparison with provided repositories (for thread safety)"""
-        start_time = time.time()
+    def _process_single_comparison_with_repos(
+        self,
+        similarity_record,
+        submission1: Submission,
+        submission2: Submission,
+        submission_repo: SubmissionRepository,
+        similarity_repo: SubmissionSimilarityRepository,
+        tokenization_timeout_seconds: Optional[float] = None,
+        token_cache: Optional[TokenStreamCache] = None,
+    ) -> None:
+        """
+        Process comparison with provided repositories (for thread safety), the files whose tokenization times out
+        being skipped and listed in the results. The files are tokenized through the token cache, unless disabled.
+        """
+        token_cache = token_cache or self._get_token_cache(similarity_repo.session, submission1.tenant_id)
+
+        try:
+            # Update status to processing
+            similarity_repo.update_status(similarity_record.id, SimilarityStatus.PROCESSING)
+
+            with pipeline_metrics.time_comparison():
+                results = self._compute_comparison(
+                    submission1,
+                    submission2,
+                    submission_repo,
+                    similarity_repo,
+                    tokenization_timeout_seconds,
+                    token_cache,
+                )
+
+            # Update the similarity record with results
+            similarity_repo.update_results(similarity_record.id, results)
+
+        except Exception as e:
+            # Update status to failed
+            similarity_repo.update_status(similarity_record.id, SimilarityStatus.FAILED, str(e))
+            logger.error(f"Failed to process comparison: {str(e)}")
+            raise
+
+    def _compute_comparison(
+        self,
+        submission1: Submission,
+        submission2: Submission,
+        submission_repo: SubmissionRepository,
+        similarity_repo: SubmissionSimilarityRepository,
+        tokenization_timeout_seconds: Optional[float] = None,
+        token_cache: Optional[TokenStreamCache] = None,
+        detection_options: Optional[DetectionOptionsDto] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare two submissions, returning the results of the comparison for its similarity record: the language
+        detection of the submissions is recorded on them, the results themselves being left to the caller to store.
+        The submissions are compared with the given detection options, those of the service configuration by default.
+        """
+        detection_options = detection_options or self._get_detection_options()
+        start_time = time.time()
+
+        # Fetch both submissions
+        submission1_data = CreateSubmissionDto(
+            link=submission1.link,
+            project_uuid=submission1.project_uuid,
+            group_uuid=submission1.group_uuid,
+            project_step_uuid=submission1.project_step_uuid,
+            link_type=submission1.link_type,
+        )
+
+        submission2_data = CreateSubmissionDto(
+            link=submission2.link,
+            project_uuid=submission2.project_uuid,
+            group_uuid=submission2.group_uuid,
+            project_step_uuid=submission2.project_step_uuid,
+            link_type=submission2.link_type,
+        )
+
+        # Fetch repositories
+        repo1_path = None
+        repo2_path = None
+
+        try:
+            repo1_path = self.submission_fetcher.fetch_submission(submission1_data)
+            repo2_path = self.submission_fetcher.fetch_submission(submission2_data)
+
+            if not repo1_path.exists() or not repo2_path.exists():
+                raise HTTPException(status_code=404, detail="Test projects not found")
+
+            # Tokenize all files
+            tokens1 = []
+            tokens2 = []
+
+            # Supported files, Go files being grouped by package
+            repo1_selection = self._collect_submission_files(repo1_path)
+            repo2_selection = self._collect_submission_files(repo2_path)
+            repo1_unsupported = self._unsupported_files(repo1_selection, repo1_path)
+            repo2_unsupported = self._unsupported_files(repo2_selection, repo2_path)
+
+            # Files whose language is uncertain are flagged on the submission instead of being analyzed
+            repo1_languages = self._check_language_confidence(repo1_selection, repo1_path)
+            repo2_languages = self._check_language_confidence(repo2_selection, repo2_path)
+            self._record_language_detection(submission1, repo1_languages, submission_repo)
+            self._record_language_detection(submission2, repo2_languages, submission_repo)
+
+            # Generated and minified files are excluded from the comparison, unless forced on the submission
+            repo1_generated = self._exclude_generated_files(repo1_selection, repo1_path, submission1)
+            repo2_generated = self._exclude_generated_files(repo2_selection, repo2_path, submission2)
+
+            # The paths excluded by the detection run are left out of the comparison, not of the submissions
+            run_exclusion = RunPathExclusion(detection_options.exclude_paths)
+            repo1_run_excluded = run_exclusion.apply(repo1_selection, repo1_path)
+            repo2_run_excluded = run_exclusion.apply(repo2_selection, repo2_path)
+
+            repo1_compatible_files = repo1_selection.files
+            repo2_compatible_files = repo2_selection.files
+            language_fallbacks1 = []
+            language_fallbacks2 = []
+            stripped_headers1 = []
+            stripped_headers2 = []
+            timed_out_files1 = []
+            timed_out_files2 = []
+            tokenization_options = self._get_tokenization_options(
+                submission1.project_uuid,
+                submission1.project_step_uuid,
+                similarity_repo.session,
+                tokenization_timeout_seconds,
+            )
+
+            for file_path in repo1_compatible_files:
+                if not file_path.is_file():
+                    continue
+                content = self._read_file_with_encoding_detection(file_path)
+                if content is not None:
+                    tokens = self._tokenize_file(
+                        content,
+                        file_path,
+                        repo1_path,
+                        language_fallbacks1,
+                        tokenization_options,
+                        stripped_headers1,
+                        timed_out_files1,
+                        token_cache,
+                    )
+                    tokens1.extend(tokens)
+
+            for file_path in repo2_compatible_files:
+                if not file_path.is_file():
+                    continue
+                content = self._read_file_with_encoding_detection(file_path)
+                if content is not None:
+                    tokens = self._tokenize_file(
+                        content,
+                        file_path,
+                        repo2_path,
+                        language_fallbacks2,
+                        tokenization_options,
+                        stripped_headers2,
+                        timed_out_files2,
+                        token_cache,
+                    )
+                    tokens2.extend(tokens)
+
+            # Perform similarity analysis, without the starter code nor the allowed snippets of the project step
+            baseline_fingerprints = self._get_baseline_fingerprints(submission1, similarity_repo.session)
+            snippet_fingerprints = self._get_allowed_snippet_fingerprints(submission1, similarity_repo.session)
+            similarity_result = self._compare_tokens(
+                tokens1,
+                tokens2,
+                repo1_languages,
+                repo2_languages,
+                baseline_fingerprints,
+                snippet_fingerprints,
+                detection_options,
+            )
+
+            # The file pairs unchanged since a previous comparison of the two groups keep their visualization
+            file_hashes = {
+                "submission1": self._file_hashes(repo1_compatible_files, repo1_path),
+                "submission2": self._file_hashes(repo2_compatible_files, repo2_path),
+            }
+            files_with_similarities_visualization = self._visualize_file_pairs(
+                repo1_compatible_files,
+                repo2_compatible_files,
+                repo1_path,
+                repo2_path,
+                file_hashes,
+                self._get_previous_comparison(submission1, submission2, submission_repo, similarity_repo),
+            )
+
+            # Prepare results
+            processing_time = time.time() - start_time
+            function_similarities = similarity_result.get("function_similarities")
+
+            results = {
+                "jaccard_similarity": similarity_result["jaccard_similarity"],
+                "type_similarity": similarity_result["type_similarity"],
+                "overall_similarity": similarity_result["overall_similarity"],
+                "structural_similarity": similarity_result["structural_similarity"],
+                "type_sequence_similarity": similarity_result["type_sequence_similarity"],
+                "flow_similarity": similarity_result["flow_similarity"],
+                "operation_similarity": similarity_result["operation_similarity"],
+                "max_function_similarity": function_similarities and function_similarities["max_similarity"],
+                "processing_time_seconds": processing_time,
+                "similarity_details": {
+                    "algorithm": "ast_similarity",
+                    "common_elements": similarity_result["common_elements"],
+                    "total_unique_elements": similarity_result["total_unique_elements"],
+                    "length_ratio": similarity_result["length_ratio"],
+                    "length_penalty": similarity_result["length_penalty"],
+                    "tokens_count": {"submission1": len(tokens1), "submission2": len(tokens2)},
+                    "processed_tokens_count": {
+                        "submission1": similarity_result["tokens1_length"],
+                        "submission2": similarity_result["tokens2_length"],
+                    },
+                    "files_count": {
+                        "submission1": len(repo1_compatible_files),
+                        "submission2": len(repo2_compatible_files),
+                    },
+                    "go_packages": {
+                        "submission1": repo1_selection.to_dict(),
+                        "submission2": repo2_selection.to_dict(),
+                    },
+                    "language_detection": {"submission1": repo1_languages, "submission2": repo2_languages},
+                    "language_fallbacks": {"submission1": language_fallbacks1, "submission2": language_fallbacks2},
+                    "stripped_headers": {"submission1": stripped_headers1, "submission2": stripped_headers2},
+                    "timed_out_files": {"submission1": timed_out_files1, "submission2": timed_out_files2},
+                    "file_hashes": file_hashes,
+                    "generated_files": {"submission1": repo1_generated, "submission2": repo2_generated},
+                    "run_excluded_files": {"submission1": repo1_run_excluded, "submission2": repo2_run_excluded},
+                    "baseline": similarity_result.get("baseline"),
+                    "sanctioned_overlap": similarity_result.get("sanctioned_overlap"),
+                    "matches": similarity_result.get("matches", []),
+                    "fragments": similarity_result.get("fragments", []),
+                    "fragment_sources": {
+                        "submission1": self._fragment_sources(similarity_result, "left", repo1_path),
+                        "submission2": self._fragment_sources(similarity_result, "right", repo2_path),
+                    },
+                    "file_similarities": self._file_similarities(
+                        similarity_result,
+                        self._excluded_files(
+                            repo1_selection,
+                            repo1_path,
+                            repo1_unsupported,
+                            repo1_languages,
+                            repo1_generated,
+                            repo1_run_excluded,
+                        ),
+                        self._excluded_files(
+                            repo2_selection,
+                            repo2_path,
+                            repo2_unsupported,
+                            repo2_languages,
+                            repo2_generated,
+                            repo2_run_excluded,
+                        ),
+                    ),
+                    "function_similarities": function_similarities,
+                    "detection_options": detection_options.model_dump(mode="json"),
+                    "tokenization_options": tokenization_options.model_dump(mode="json"),
+                    "cross_language": similarity_result.get("cross_language"),
+                    "raw_similarity": similarity_result["raw_similarity"],
+                },
+                "visualization_data": files_with_similarities_visualization,
+            }
+
+            return results
+
+        finally:
+            # Clean up temporary directories
+            if repo1_path and repo1_path.exists():
+                cleanup_temp_directory(repo1_path)
+            if repo2_path and repo2_path.exists():
+                cleanup_temp_directory(repo2_path)
+
+    def create_baseline(
+        self, project_uuid: UUID, project_step_uuid: UUID, baseline_data: CreateBaselineDto
+    ) -> SubmissionBaseline:
+        """
+        Fetch the starter code of a project step and store its fingerprints, subtracted from the similarity of all
+        the submissions of the step compared afterwards.
+        """
+        link_type = baseline_data.link_type or self._detect_link_type(baseline_data.link)
+
+        # A baseline belongs to no group, the project step stands for it when fetching
+        fetch_data = CreateSubmissionDto(
+            link=baseline_data.link,
+            project_uuid=project_uuid,
+            group_uuid=project_step_uuid,
+            project_step_uuid=project_step_uuid,
+            link_type=link_type,
+        )
+
+        baseline_path = None
+        try:
+            baseline_path = self.submission_fetcher.fetch_submission(fetch_data)
+            if not baseline_path or not baseline_path.exists():
+                raise ValidationException(f"Baseline content not found at {baseline_data.link}")
+
+            selection = self._collect_submission_files(baseline_path)
+            tokenization_options = self._get_tokenization_options(project_uuid, project_step_uuid, self.session)
+            tokens = self._tokenize_selection(selection, baseline_path, tokenization_options)
+
+            fingerprints = BaselineFilter().fingerprint(tokens)
+            logger.info(
+                f"Fingerprinted baseline {baseline_data.link} of step {project_step_uuid}: "
+                f"{len(selection.files)} files, {len(fingerprints)} fingerprints"
+            )
+            return self.baseline_repository.create(
+                {
+                    "project_uuid": project_uuid,
+                    "project_step_uuid": project_step_uuid,
+                    "link": baseline_data.link,
+                    "link_type": link_type,
+                    "description": baseline_data.description,
+                    "file_count": len(selection.files),
+                    "token_count": len(tokens),
+                    "fingerprints": fingerprints,
+                }
+            )
+        finally:
+            if baseline_path and baseline_path.exists():
+                cleanup_temp_directory(baseline_path)
+
+    def get_baselines(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionBaseline]:
+        """Get the starter code baselines of a project step"""
+        return self.baseline_repository.get_by_project_step(project_uuid, project_step_uuid)
+
+    def delete_baseline(self, baseline_id: UUID) -> bool:
+        """Delete a starter code baseline, the submissions compared afterwards not being adjusted for it"""
+        return self.baseline_repository.delete(baseline_id)
+
+    def create_allowed_snippet(
+        self, project_uuid: UUID, project_step_uuid: UUID, snippet_data: CreateAllowedSnippetDto
+    ) -> SubmissionAllowedSnippet:
+        """
+        Fingerprint code every student of a project step may copy: the fragments of the pairs of its submissions
+        lying within it in both files are excluded from the scores of the comparisons run afterwards.
+        """
+        fingerprints, token_count = self._fingerprint_snippet(
+            snippet_data.content, snippet_data.language, project_uuid, project_step_uuid
+        )
+        logger.info(
+            f"Fingerprinted {snippet_data.language} allowed snippet of step {project_step_uuid}: "
+            f"{token_count} tokens, {len(fingerprints)} fingerprints"
+        )
+        return self.allowed_snippet_repository.create(
+            {
+                "project_uuid": project_uuid,
+                "project_step_uuid": project_step_uuid,
+                "language": snippet_data.language,
+                "content": snippet_data.content,
+                "description": snippet_data.description,
+                "token_count": token_count,
+                "fingerprints": fingerprints,
+            }
+        )
+
+    def get_allowed_snippets(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionAllowedSnippet]:
+        """Get the allowed snippets of a project step"""
+        return self.allowed_snippet_repository.get_by_project_step(project_uuid, project_step_uuid)
+
+    def get_allowed_snippet(self, snippet_id: UUID) -> SubmissionAllowedSnippet:
+        """Get an allowed snippet"""
+        snippet = self.allowed_snippet_repository.get_by_id(snippet_id)
+        if not snippet:
+            raise NotFoundException("Allowed snippet", str(snippet_id))
+        return snippet
+
+    def update_allowed_snippet(
+        self, snippet_id: UUID, snippet_data: UpdateAllowedSnippetDto
+    ) -> SubmissionAllowedSnippet:
+        """
+        Update an allowed snippet, fingerprinted again when its code or language changes: the comparisons run
+        afterwards use it, the existing ones keeping their scores until their detection run is rescored
+        """
+        snippet = self.get_allowed_snippet(snippet_id)
+        changes = {
+            field: value
+            for field, value in snippet_data.model_dump(exclude_unset=True).items()
+            if value is not None or field == "description"
+        }
+        if "content" in changes or "language" in changes:
+            fingerprints, token_count = self._fingerprint_snippet(
+                changes.get("content", snippet.content),
+                changes.get("language", snippet.language),
+                snippet.project_uuid,
+                snippet.project_step_uuid,
+            )
+            changes.update(fingerprints=fingerprints, token_count=token_count)
+        return self.allowed_snippet_repository.update(snippet_id, changes)
+
+    def delete_allowed_snippet(self, snippet_id: UUID) -> bool:
+        """Delete an allowed snippet, the comparisons already run keeping their scores until they are rescored"""
+        return self.allowed_snippet_repository.delete(snippet_id)
+
+    def _fingerprint_snippet(
+        self, content: str, language: str, project_uuid: UUID, project_step_uuid: UUID
+    ) -> Tuple[List[str], int]:
+        """
+        Tokenize an allowed snippet with its language and the tokenization options of its project step, as the files
+        of the submissions are, returning its fingerprints and its number of tokens
+
+        Raises:
+            ValidationException: If the language is not supported, or the snippet is shorter than a fingerprint
+        """
+        if language not in self.tokenization_service.get_supported_languages():
+            message = f"Language {language} is not supported"
+            raise ValidationException(
+                message, details={"error_type": "unsupported_language", "message": message, "language": language}
+            )
+        extension = self.tokenization_service.get_language_extension(language)
+        result = self.tokenization_service.tokenize_with_details(
+            content,
+            Path(f"snippet{extension}") if extension else None,
+            self._get_tokenization_options(project_uuid, project_step_uuid, self.session),
+            language=language,
+        )
+        snippet_filter = AllowedSnippetFilter()
+        fingerprints = snippet_filter.fingerprint(result.tokens)
+        if not fingerprints:
+            message = (
+                f"The snippet has {len(result.tokens)} tokens, fewer than the {snippet_filter.kgram_size} of a "
+                "fingerprint"
+            )
+            raise ValidationException(
+                message,
+                details={
+                    "error_type": "snippet_too_short",
+                    "message": message,
+                    "min_tokens": snippet_filter.kgram_size,
+                },
+            )
+        return fingerprints, len(result.tokens)
+
+    def get_analysis_profile_catalog(self) -> AnalysisProfileCatalog:
+        """Get the analysis profiles of the tenant of the caller, the default one comparing as configured"""
+        return AnalysisProfileCatalog(
+            self.analysis_profile_repository,
+            AnalysisProfileResolver(self._get_detection_options().model_dump(mode="json")),
+            lambda options: DetectionOptionsDto(**options).model_dump(mode="json"),
+            self.tenant_scope.tenant_id,
+        )
+
+    def get_corpora(self, session: Optional[Session] = None) -> Corpora:
+        """
+        Get the reference corpora of the tenant of the caller on a session (this one if None), the archived and
+        matched submissions being fingerprinted as the detection runs fingerprint them
+        """
+        session = session or self.session
+        return Corpora(
+            SubmissionCorpusRepository(session),
+            SubmissionCorpusMatchRepository(session),
+            SubmissionRepository(session),
+            self.tenant_scope.tenant_id,
+            self._fingerprint_corpus_link,
+            lambda submission: self._fingerprint_matched_submission(submission, session),
+        )
+
+    def _fingerprint_corpus_link(self, corpus_id: UUID, link: str, link_type: Optional[LinkType]) -> Dict[str, Any]:
+        """
+        Fetch a past submission archived in a corpus and fingerprint its files with the default options, getting
+        the type of its link, its file and token counts and its fingerprints
+
+        Raises:
+            ValidationException: If the submission has no content
+        """
+        link_type = link_type or self._detect_link_type(link)
+
+        # An archived submission belongs to no project, the corpus stands for it when fetching
+        fetch_data = CreateSubmissionDto(
+            link=link,
+            project_uuid=corpus_id,
+            group_uuid=corpus_id,
+            project_step_uuid=corpus_id,
+            link_type=link_type,
+        )
+
+        item_path = None
+        try:
+            item_path = self.submission_fetcher.fetch_submission(fetch_data)
+            if not item_path or not item_path.exists():
+                raise ValidationException(f"Corpus item content not found at {link}")
+
+            # Archived submissions belong to no step, they are tokenized with the default options
+            selection = self._collect_submission_files(item_path)
+            tokenization_options = TokenizationOptionsDto(strip_headers=get_settings().strip_file_headers)
+            tokens = self._tokenize_selection(selection, item_path, tokenization_options)
+            return {
+                "link_type": link_type,
+                "file_count": len(selection.files),
+                "token_count": len(tokens),
+                "fingerprints": BaselineFilter().fingerprint(tokens),
+            }
+        finally:
+            if item_path and item_path.exists():
+                cleanup_temp_directory(item_path)
+
+    def _fingerprint_matched_submission(self, submission: Submission, session: Session) -> Set[str]:
+        """
+        Fetch a submission matched with the corpora and fingerprint its files as its step tokenizes them, the
+        starter code of the step being left out
+        """
+        submission_path = None
+        try:
+            submission_path = self.submission_fetcher.fetch_submission(
+                CreateSubmissionDto(
+                    link=submission.link,
+                    project_uuid=submission.project_uuid,
+                    group_uuid=submission.group_uuid,
+                    project_step_uuid=submission.project_step_uuid,
+                    link_type=submission.link_type,
+                )
+            )
+            selection = self._collect_submission_files(submission_path)
+            self._exclude_generated_files(selection, submission_path, submission)
+            tokenization_options = self._get_tokenization_options(
+                submission.project_uuid, submission.project_step_uuid, session
+            )
+            tokens = self._tokenize_selection(selection, submission_path, tokenization_options)
+
+            # The starter code of the step is shared with the archived submissions of the previous years too
+            fingerprints = set(BaselineFilter().fingerprint(tokens))
+            return fingerprints - self._get_baseline_fingerprints(submission, session)
+        finally:
+            if submission_path and submission_path.exists():
+                cleanup_temp_directory(submission_path)
+
+    def get_reference_sets(self) -> ReferenceSets:
+        """Get the external reference sets of the tenant of the caller, their files fingerprinted with the defaults"""
+        return ReferenceSets(
+            SubmissionCorpusRepository(self.session),
+            SubmissionCorpusMatchRepository(self.session),
+            self.tenant_scope.tenant_id,
+            self._fingerprint_reference_files,
+        )
+
+    def _fingerprint_reference_files(self, corpus_id: UUID, content: bytes, filename: str) -> List[Dict[str, Any]]:
+        """
+        Extract the uploaded archive of a reference set within the default upload limits, and fingerprint each of
+        its supported files apart with the default tokenization options, so that a match names the file. The files
+        too short to be fingerprinted are left out.
+        """
+        # A reference set belongs to no project step, it stands for one for the upload limits
+        limits = self.get_upload_limits(corpus_id, corpus_id)
+        if not content:
+            raise ValidationException("The uploaded file is empty")
+        if len(content) > limits["max_upload_bytes"]:
+            error = self.upload_too_large(limits["max_upload_bytes"])
+            raise ValidationException(str(error), details=error.to_dict())
+        if not ArchiveExtractor.is_archive(content):
+            raise ValidationException("A reference set is a ZIP, tar or tar.gz archive of source files")
+        try:
+            extraction = self._limited_extractor(limits).extract(content)
+        except ArchiveLimitExceeded as e:
+            raise ValidationException(str(e), details=e.to_dict())
+        except ValueError as e:
+            raise ValidationException(str(e))
+
+        set_path = Path(tempfile.mkdtemp(prefix="reference_set_"))
+        items = []
+        try:
+            extraction.write(set_path)
+            tokenization_options = TokenizationOptionsDto(strip_headers=get_settings().strip_file_headers)
+            fingerprinter = BaselineFilter()
+            for file_path in self._collect_submission_files(set_path).files:
+                file_content = self._read_file_with_encoding_detection(file_path)
+                if file_content is None:
+                    continue
+                tokens = self._tokenize_file(file_content, file_path, set_path, [], tokenization_options)
+                fingerprints = fingerprinter.fingerprint(tokens)
+                if not fingerprints:
+                    continue
+                path = file_path.relative_to(set_path).as_posix()
+                items.append(
+                    {
+                        "label": path,
+                        "link": filename,
+                        "path": path,
+                        "file_count": 1,
+                        "token_count": len(tokens),
+                        "fingerprints": fingerprints,
+                    }
+                )
+        except ArchiveLimitExceeded as e:
+            raise ValidationException(str(e), details=e.to_dict())
+        finally:
+            cleanup_temp_directory(set_path)
+        if not items:
+            raise ValidationException("No source file of the reference set could be fingerprinted")
+        return items
+
+    def _process_corpus_matches_threaded(
+        self, submission_id: UUID, include_corpus: bool = True, include_references: bool = False
+    ) -> None:
+        """Match a submission with the corpora of its step and the reference sets in a thread, with its own session"""
+        self.get_corpora(self._get_thread_session()).match(submission_id, include_corpus, include_references)
+
+    def _process_code_metrics_threaded(self, submission_id: UUID) -> None:
+        """
+        Analyze a submission in a thread: fetch its files, then tokenize them to compute the code metrics of the
+        compared files, stored on it, and index their fingerprints for the code search. Each stage is recorded on
+        the submission, a failure with its reason and the file it happened on, and in its processing log with the
+        stages of each file and their warnings.
+        """
+        submission_path = None
+        submission_repo = None
+        timeline = ProcessingTimeline()
+        try:
+            thread_session = self._get_thread_session()
+            submission_repo = SubmissionRepository(thread_session)
+            submission = submission_repo.get_by_id(submission_id)
+            if not submission:
+                logger.error(f"Submission not found: {submission_id}")
+                return
+
+            submission = self._transition_processing(submission_repo, submission, ProcessingStatus.EXTRACTING)
+            with log_stage(logger, "extracting"), timeline.stage(ProcessingStage.EXTRACTION) as extraction:
+                submission_path = self.submission_fetcher.fetch_submission(
+                    CreateSubmissionDto(
+                        link=submission.link,
+                        project_uuid=submission.project_uuid,
+                        group_uuid=submission.group_uuid,
+                        project_step_uuid=submission.project_step_uuid,
+                        link_type=submission.link_type,
+                    )
+                )
+                selection = self._collect_submission_files(submission_path)
+                exclusion = self._exclude_generated_files(selection, submission_path, submission)
+                tree_log_entries = self._tree_log_entries(submission_path, timeline)
+                for excluded in exclusion["excluded_files"]:
+                    timeline.record(
+                        ProcessingStage.EXTRACTION,
+                        excluded["file"],
+                        ProcessingWarningCode.GENERATED_FILE_EXCLUDED,
+                        f"Excluded {excluded['kind']} file: {', '.join(excluded['reasons'])}",
+                        skipped=True,
+                    )
+                extraction["details"]["file_count"] = len(selection.files)
+            self.analysis_pool.check_cancelled()
+
+            submission = self._transition_processing(
+                submission_repo, submission, ProcessingStatus.TOKENIZING, total_file_count=len(selection.files)
+            )
+            # The files unchanged since the previous version keep their metrics and fingerprints
+            analyzed_files = self._analyzed_files(selection, submission_path)
+            previous = self._get_previous_analysis(submission, submission_repo, analyzed_files)
+            reanalysis = IncrementalReanalysis(
+                previous.analyzed_files["files"] if previous else None, analyzed_files["files"]
+            )
+            index_entries = []
+
+            def index_file(path: str, tokens: Iterable[Dict[str, Any]], language: str) -> None:
+                # The tokens of a large file come as a stream, fingerprinted as they are tokenized
+                fingerprints = list(self.similarity_service.fingerprint_stream(tokens, language=language))
+                index_entries.extend(CodeSearch.index_entries(path, fingerprints))
+                # Stop between the files once the workers are stopped, the submission being processed again later
+                self.analysis_pool.check_cancelled()
+
+            fingerprint_repo = SubmissionFingerprintRepository(thread_session)
+            previous_files = {file["file"]: file for file in previous.code_metrics["files"]} if previous else None
+            with log_stage(logger, "tokenizing", file_count=len(selection.files)), timeline.stage(
+                ProcessingStage.TOKENIZATION, file_count=len(selection.files)
+            ) as tokenization:
+                code_metrics = self._compute_code_metrics(
+                    selection,
+                    submission_path,
+                    on_progress=lambda count: submission_repo.patch(submission_id, {"processed_file_count": count}),
+                    on_file=index_file,
+                    reanalysis=reanalysis,
+                    previous_files=previous_files,
+                    timeline=timeline,
+                )
+                tokenization["details"]["reused_file_count"] = len(reanalysis.reused)
+            processing_log = submission.processing_log
+            if tree_log_entries:
+                processing_log = list(processing_log or [])
+                processing_log.extend(entry for entry in tree_log_entries if entry not in processing_log)
+            if previous:
+                index_entries.extend(
+                    {
+                        "file_path": record.file_path,
+                        "hash": record.hash,
+                        "position": record.position,
+                        "start_line": record.start_line,
+                        "end_line": record.end_line,
+                    }
+                    for record in fingerprint_repo.get_by_submission_id(previous.id)
+                    if reanalysis.is_unchanged(record.file_path)
+                )
+                processing_log = list(processing_log or [])
+                processing_log.extend(
+                    entry for entry in reanalysis.log_entries(previous.version) if entry not in processing_log
+                )
+                logger.info(
+                    f"Re-analyzed submission {submission_id} from version {previous.version}: "
+                    f"{len(reanalysis.reused)} files reused, {len(reanalysis.reprocessed)} reprocessed"
+                )
+            with log_stage(logger, "indexing", fingerprint_count=len(index_entries)), timeline.stage(
+                ProcessingStage.FINGERPRINTING, fingerprint_count=len(index_entries)
+            ):
+                fingerprint_repo.replace_for_submission(submission_id, index_entries)
+            submission = self._transition_processing(
+                submission_repo,
+                submission,
+                ProcessingStatus.ANALYZED,
+                code_metrics=code_metrics,
+                analyzed_files=analyzed_files,
+                processing_log=processing_log,
+                processed_file_count=len(selection.files),
+                processing_attempt_count=0,
+            )
+            logger.info(f"Computed the code metrics of submission {submission_id}")
+            self._notify_submission(WebhookEvent.SUBMISSION_ANALYZED, submission, **code_metrics["submission"])
+
+        except AnalysisCancelled:
+            logger.info(f"Processing of submission {submission_id} interrupted by a shutdown, retried at restart")
+            self._interrupt_processing(submission_repo, submission_id)
+        except Exception as e:
+            logger.error(f"Failed to compute the code metrics of submission {submission_id}: {str(e)}")
+            self._fail_processing(submission_repo, submission_id, e)
+        finally:
+            if timeline.events:
+                self.record_processing_timeline(submission_id, timeline, self._get_thread_session())
+            if submission_path and submission_path.exists():
+                cleanup_temp_directory(submission_path)
+
+    def record_processing_timeline(
+        self, submission_id: UUID, timeline: ProcessingTimeline, session: Optional[Session] = None
+    ) -> None:
+        """
+        Store the stages of a processing of a submission in its processing log, with the session of the service
+        by default, never raising: the processing does not depend on its log
+        """
+        if not timeline.events:
+            return
+        try:
+            SubmissionProcessingEventRepository(session or self.session).create_many(submission_id, timeline.events)
+        except Exception as e:
+            logger.error(f"Failed to record the processing log of submission {submission_id}: {str(e)}")
+
+    def _analyzed_files(self, selection: GoPackagePreprocessingResult, repo_path: Path) -> Dict[str, Any]:
+        """
+        Content hashes of the selected files by relative path, with the tokenizer version and stream buffer size
+        their metrics and fingerprints depend on
+        """
+        return {
+            "tokenizer_version": TOKENIZER_VERSION,
+            "stream_buffer_size": self.stream_buffer_size,
+            "files": self._file_hashes(selection.files, repo_path),
+        }
+
+    @staticmethod
+    def _get_previous_analysis(
+        submission: Submission, repository: SubmissionRepository, analyzed_files: Dict[str, Any]
+    ) -> Optional[Submission]:
+        """
+        Get the latest earlier version of a submission analyzed with the same tokenizer version and stream buffer
+        size, whose results of the unchanged files are reused, None if there is none
+        """
+        versions = repository.get_versions(submission.project_uuid, submission.group_uuid, submission.project_step_uuid)
+        for version in reversed(versions):
+            previous_files = version.analyzed_files or {}
+            if (
+                version.version < submission.version
+                and version.code_metrics
+                and previous_files.get("tokenizer_version") == analyzed_files["tokenizer_version"]
+                and previous_files.get("stream_buffer_size") == analyzed_files["stream_buffer_size"]
+            ):
+                return version
+        return None
+
+    @staticmethod
+    def _transition_processing(
+        repository: SubmissionRepository, submission: Submission, status: ProcessingStatus, **fields: Any
+    ) -> Submission:
+        """Move a submission to a stage of its processing, recording the transition with the other given fields"""
+        changes = ProcessingLifecycle.transition(
+            submission.processing_status, submission.processing_transitions, status, get_paris_time(), **fields
+        )
+        return repository.patch(submission.id, changes)
+
+    def _fail_processing(
+        self, repository: Optional[SubmissionRepository], submission_id: UUID, error: Exception
+    ) -> None:
+        """
+        Record the failure of the processing of a submission with its attempt, never raising: a transient failure is
+        retried after the backoff delay while attempts remain, the submission being pending retry until then, else
+        the submission fails with the reason and the file it failed on
+        """
+        try:
+            repository = repository or SubmissionRepository(self._get_thread_session())
+            submission = repository.get_by_id(submission_id)
+            if not submission:
+                return
+
+            failed_file = error.path if isinstance(error, FileProcessingError) else None
+            message = str(error.cause) if isinstance(error, FileProcessingError) else str(error)
+            attempt = submission.processing_attempt_count + 1
+            retry_in = self.retry_policy.delay(attempt) if self.retry_policy.should_retry(error, attempt) else None
+            at = get_paris_time()
+            record = ProcessingRetryPolicy.attempt_record(
+                attempt, at, message, failed_file, ProcessingRetryPolicy.is_transient(error), retry_in
+            )
+            submission = self._transition_processing(
+                repository,
+                submission,
+                ProcessingStatus.FAILED if retry_in is None else ProcessingStatus.PENDING_RETRY,
+                processing_error=message,
+                processing_failed_file=failed_file,
+                processing_attempt_count=attempt,
+                processing_attempts=list(submission.processing_attempts or []) + [record],
+                processing_retry_at=None if retry_in is None else at + timedelta(seconds=retry_in),
+            )
+            if retry_in is not None:
+                logger.info(
+                    f"Retrying the processing of submission {submission_id} in {retry_in:.1f} seconds "
+                    f"(attempt {attempt + 1} of {self.retry_policy.max_attempts})"
+                )
+                timer = threading.Timer(retry_in, self._requeue_processing, args=(submission_id,))
+                timer.daemon = True
+                timer.start()
+            else:
+                self._notify_submission(
+                    WebhookEvent.SUBMISSION_FAILED,
+                    submission,
+                    error=message,
+                    failed_file=failed_file,
+                    attempt_count=attempt,
+                )
+        except Exception as e:
+            logger.error(f"Failed to record the processing failure of submission {submission_id}: {str(e)}")
+
+    def _notify_submission(self, event: WebhookEvent, submission: Submission, **summary: Any) -> None:
+        """Notify the webhooks of the step of a submission of its analysis or failure, with a summary of it"""
+        self.webhook_notifier.notify(
+            event,
+            submission.project_uuid,
+            submission.project_step_uuid,
+            {
+                "submission_id": str(submission.id),
+                "group_uuid": str(submission.group_uuid),
+                "version": submission.version,
+                "processing_status": ProcessingStatus(submission.processing_status).value,
+                "summary": summary,
+            },
+        )
+
+    def _notify_run_completed(self, run: Optional[SubmissionDetectionRun]) -> None:
+        """Notify the webhooks of the step of a detection run of its completion, with the counts of its pairs"""
+        if run is None:
+            return
+        self.webhook_notifier.notify(
+            WebhookEvent.DETECTION_RUN_COMPLETED,
+            run.project_uuid,
+            run.project_step_uuid,
+            {
+                "run_id": str(run.id),
+                "status": DetectionRunStatus(run.status).value,
+                "summary": {
+                    "submission_count": len(run.submission_ids),
+                    "pair_count": run.pair_count,
+                    "completed_pair_count": run.completed_pair_count,
+                    "include_corpus": run.include_corpus,
+                    "include_references": run.include_references,
+                    "scheduled": run.scheduled,
+                    "completed_at": run.completed_at.isoformat() if run.completed_at else None,
+                },
+            },
+        )
+        self.get_grading_callbacks().send(run)
+
+    def _deliver_grading_callback(self, run_id: UUID) -> None:
+        """Post the results of a run to the grading callback of its step, on a session of its own"""
+        from app.shared.database import get_session
+
+        session = next(get_session())
+        try:
+            DetectionIntegrationService(session).get_grading_callbacks().deliver(run_id, get_paris_time())
+        except Exception as e:
+            logger.error(f"Failed to deliver the results of detection run {run_id}: {str(e)}")
+        finally:
+            session.close()
+
+    def _requeue_processing(self, submission_id: UUID) -> None:
+        """
+        Queue again a processing pending retry once its delay is over, unless the workers are stopping, the
+        submission being resumed at the next start then
+        """
+        from app.shared.database import get_session
+
+        session = next(get_session())
+        try:
+            submission = SubmissionRepository(session).get_by_id(submission_id)
+            if submission and self.analysis_pool.wait_for_capacity():
+                self._submit_processing(submission, session)
+        except Exception as e:
+            logger.error(f"Failed to queue the retry of the processing of submission {submission_id}: {str(e)}")
+        finally:
+            session.close()
+
+    def retry_processing(self, submission_id: UUID) -> Submission:
+        """
+        Retry by hand the processing of a failed submission, once the cause of its failure is fixed, with all its
+        attempts again
+
+        Raises:
+            NotFoundException: If the submission doesn't exist
+            ConflictException: If the submission has not failed
+            AnalysisQueueFull: If the analysis queue stays full
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        status = ProcessingStatus(submission.processing_status)
+        if status != ProcessingStatus.FAILED:
+            message = f"Only a failed submission is retried, submission {submission_id} is {status.value}"
+            raise ConflictException(
+                message,
+                details={"error_type": "submission_not_failed", "message": message, "processing_status": status.value},
+            )
+
+        self.analysis_pool.ensure_capacity()
+        submission = self._transition_processing(
+            self.submission_repository, submission, ProcessingStatus.PENDING_RETRY, processing_attempt_count=0
+        )
+        self._submit_processing(submission)
+        logger.info(f"Retrying by hand the processing of submission {submission_id}")
+        return submission
+
+    def _interrupt_processing(self, repository: Optional[SubmissionRepository], submission_id: UUID) -> None:
+        """Leave the interrupted processing of a submission pending retry at the next start, never raising"""
+        try:
+            repository = repository or SubmissionRepository(self._get_thread_session())
+            submission = repository.get_by_id(submission_id)
+            if submission and submission.processing_status in (
+                ProcessingStatus.RECEIVED, ProcessingStatus.EXTRACTING, ProcessingStatus.TOKENIZING
+            ):
+                self._transition_processing(repository, submission, ProcessingStatus.PENDING_RETRY)
+        except Exception as e:
+            logger.error(f"Failed to record the interrupted processing of submission {submission_id}: {str(e)}")
+
+    def _compute_code_metrics(
+        self,
+        selection: GoPackagePreprocessingResult,
+        repo_path: Path,
+        on_progress: Optional[Callable[[int], Any]] = None,
+        on_file: Optional[Callable[[str, Iterable[Dict[str, Any]], str], Any]] = None,
+        reanalysis: Optional[IncrementalReanalysis] = None,
+        previous_files: Optional[Dict[str, Dict[str, Any]]] = None,
+        timeline: Optional[ProcessingTimeline] = None,
+    ) -> Dict[str, Any]:
+        """
+        Get the metrics of each selected file and of the whole submission. The comments being counted, the file
+        headers are kept; the metrics of a notebook are those of its code cells. The number of files processed so
+        far is reported every few files, and the tokens of each file are given to `on_file` with its language.
+        With a re-analysis, the metrics of the unchanged files are those of the previous version (`previous_files`,
+        by relative path), only the others being tokenized. The language detection and tokenization of each
+        tokenized file are recorded in the timeline, if given.
+
+        The files larger than the stream buffer are tokenized as a stream, chunk by chunk: their tokens are given
+        to `on_file` as an iterator, the metrics of each chunk being computed as it is consumed, then merged.
+
+        Raises:
+            FileProcessingError: If a file cannot be tokenized, with its path
+        """
+        analyzer = CodeMetricsAnalyzer()
+        options = TokenizationOptionsDto(strip_headers=False)
+        timeline = timeline or ProcessingTimeline()
+        paths = {
+            str(file_path.relative_to(repo_path)): (index, file_path) for index, file_path in enumerate(selection.files)
+        }
+
+        def compute(relative_path: str) -> Optional[Dict[str, Any]]:
+            index, file_path = paths[relative_path]
+            if on_progress and index and index % PROCESSING_PROGRESS_INTERVAL == 0:
+                on_progress(index)
+            return self._compute_file_metrics(analyzer, file_path, relative_path, options, on_file, timeline)
+
+        files = IncrementalReanalysis.merge(
+            paths,
+            previous_files or {},
+            compute,
+            reanalysis.is_unchanged if reanalysis else lambda relative_path: False,
+        )
+        return {"submission": analyzer.aggregate(files), "files": files}
+
+    def _compute_file_metrics(
+        self,
+        analyzer: CodeMetricsAnalyzer,
+        file_path: Path,
+        relative_path: str,
+        options: TokenizationOptionsDto,
+        on_file: Optional[Callable[[str, Iterable[Dict[str, Any]], str], Any]],
+        timeline: ProcessingTimeline,
+    ) -> Optional[Dict[str, Any]]:
+        """
+        Get the metrics of a selected file, giving its tokens to `on_file`, None if it cannot be read (or is an
+        invalid notebook), its tokenization being recorded in the timeline
+
+        Raises:
+            FileProcessingError: If the file cannot be tokenized, with its path
+        """
+        with timeline.stage(ProcessingStage.TOKENIZATION, relative_path) as event:
+            if not file_path.is_file():
+                timeline.warn(event, ProcessingWarningCode.UNREADABLE_FILE, "Not a regular file", skipped=True)
+                return None
+            if file_path.stat().st_size > self.stream_buffer_size and not self.tokenization_service.is_notebook(
+                file_path
+            ):
+                return self._compute_streamed_file_metrics(
+                    analyzer, file_path, relative_path, options, on_file, timeline, event
+                )
+
+            content = self._read_file_with_encoding_detection(file_path)
+            if content is None:
+                timeline.warn(event, ProcessingWarningCode.UNREADABLE_FILE, "Could not be decoded", skipped=True)
+                return None
+            try:
+                if self.tokenization_service.is_notebook(file_path):
+                    content = self.tokenization_service.extract_notebook(content).source
+                result = self.tokenization_service.tokenize_with_details(content, file_path, options)
+            except NotebookException as e:
+                logger.warning(f"No code metrics for {relative_path}: {e}")
+                timeline.warn(event, ProcessingWarningCode.INVALID_NOTEBOOK, str(e), skipped=True)
+                return None
+            except Exception as e:
+                raise FileProcessingError(relative_path, e) from e
+            self._record_file_language_detection(timeline, relative_path, result)
+            if result.timed_out:
+                timeline.warn(
+                    event,
+                    ProcessingWarningCode.TOKENIZATION_TIMEOUT,
+                    f"Tokenization stopped after {result.elapsed_seconds} seconds, no tokens kept",
+                )
+            event["details"].update(language=result.language, token_count=len(result.tokens))
+            if on_file:
+                on_file(relative_path, result.tokens, result.language)
+            return analyzer.analyze_file(relative_path, content, result.tokens, result.language)
+
+    @staticmethod
+    def _record_file_language_detection(
+        timeline: ProcessingTimeline, relative_path: str, result: TokenizationResultDto
+    ) -> None:
+        """
+        Record the language a file was tokenized with (its first chunk for a stream), with a warning if the content
+        based fallback was used
+        """
+        fallback = result.language_fallback
+        if fallback:
+            timeline.record(
+                ProcessingStage.LANGUAGE_DETECTION,
+                relative_path,
+                ProcessingWarningCode.LANGUAGE_FALLBACK,
+                f"Tokenized as {fallback.language}, {fallback.unknown_token_percentage:.1f}% of the tokens unknown "
+                f"as {fallback.detected_language}",
+                language=result.language,
+                detected_language=fallback.detected_language,
+            )
+        else:
+            timeline.record(ProcessingStage.LANGUAGE_DETECTION, relative_path, language=result.language)
+
+    def _compute_streamed_file_metrics(
+        self,
+        analyzer: CodeMetricsAnalyzer,
+        file_path: Path,
+        relative_path: str,
+        options: TokenizationOptionsDto,
+        on_file: Optional[Callable[[str, Iterable[Dict[str, Any]], str], Any]],
+        timeline: ProcessingTimeline,
+        event: Dict[str, Any],
+    ) -> Dict[str, Any]:
+        """
+        Get the metrics of a large file tokenized as a stream, giving its tokens to `on_file` as an iterator, their
+        lines being those of the file. A chunk is held in memory at a time, with its tokens. The tokenization is
+        recorded in the given event of the timeline.
+
+        Raises:
+            FileProcessingError: If the file cannot be tokenized, with its path
+        """
+        chunk_metrics: List[Tuple[int, Dict[str, Any]]] = []
+
+        def file_tokens(chunks: Iterable[Tuple[SourceChunk, TokenizationResultDto]]) -> Iterator[Dict[str, Any]]:
+            for chunk, result in chunks:
+                if result.timed_out:
+                    timeline.warn(
+                        event,
+                        ProcessingWarningCode.TOKENIZATION_TIMEOUT,
+                        f"Tokenization of the chunk at line {chunk.line_offset + 1} stopped after "
+                        f"{result.elapsed_seconds} seconds, no tokens kept",
+                    )
+                metrics = analyzer.analyze_file(relative_path, chunk.text, result.tokens, result.language)
+                chunk_metrics.append((chunk.line_offset, metrics))
+                for token in result.tokens:
+                    token["start"] += chunk.line_offset
+                    token["end"] += chunk.line_offset
+                    yield token
+
+        try:
+            with open(file_path, "r", encoding=self._detect_stream_encoding(file_path), errors="replace") as stream:
+                chunks = self.tokenization_service.tokenize_stream(stream, file_path, options, self.stream_buffer_size)
+                # The language of the file is that of its first chunk
+                first = next(chunks, None)
+                language = first[1].language if first else None
+                if first:
+                    self._record_file_language_detection(timeline, relative_path, first[1])
+                tokens = file_tokens(itertools.chain([first] if first else [], chunks))
+                del first
+                if on_file:
+                    on_file(relative_path, tokens, language)
+                # The chunks left unconsumed by the callback still count in the metrics
+                for _ in tokens:
+                    pass
+        except AnalysisCancelled:
+            raise
+        except Exception as e:
+            raise FileProcessingError(relative_path, e) from e
+        logger.debug(f"Tokenized {relative_path} as a stream of {len(chunk_metrics)} chunks")
+        event["details"].update(language=language, chunk_count=len(chunk_metrics))
+        return analyzer.merge_chunks(relative_path, language, chunk_metrics)
+
+    def _detect_stream_encoding(self, file_path: Path) -> str:
+        """
+        Get the encoding a file streamed in chunks is read with, detected from its first bytes as for the files read
+        whole (the invalid byte sequences further in the file being replaced)
+        """
+        with open(file_path, "rb") as f:
+            sample = f.read(ENCODING_SAMPLE_BYTES)
+        return self.encoding_detector.stream_codec(sample)
+
+    def search_code(self, search_data: CodeSearchDto) -> Dict[str, Any]:
+        """
+        Search the submissions of a project step for the fragments of a code snippet. In fingerprint mode, the
+        snippet is tokenized and fingerprinted like the analyzed files and looked up in the index of their
+        fingerprints, built when the submissions are analyzed. In substring mode, the default for a language without
+        tokenizer, the snippet is searched as is in the raw content of the files, each submission being fetched.
+
+        Raises:
+            ValidationException: If the language is not supported in fingerprint mode, or the snippet is too short
+        """
+        submissions = self.submission_repository.get_by_project_step(
+            search_data.project_uuid, search_data.project_step_uuid, with_files=True
+        )
+        extension = None
+        if search_data.language:
+            extension = self.tokenization_service.get_language_extension(search_data.language)
+        mode = search_data.mode or (CodeSearchMode.FINGERPRINT if extension else CodeSearchMode.SUBSTRING)
+        result = {
+            "mode": mode,
+            "language": search_data.language,
+            "searched_submission_count": len(submissions),
+            "unindexed_submission_count": 0,
+        }
+
+        if mode == CodeSearchMode.FINGERPRINT:
+            if not extension:
+                message = f"Unsupported language for a fingerprint search: {search_data.language}"
+                raise ValidationException(
+                    message, details={"error_type": "unsupported_search_language", "message": message}
+                )
+            # The snippet is tokenized like the files were when indexed, their headers kept
+            source_path = Path(f"snippet{extension}")
+            result["language"] = self.tokenization_service.detect_file_language(source_path, search_data.code)
+            tokens = self.tokenization_service.tokenize_with_details(
+                search_data.code, source_path, TokenizationOptionsDto(strip_headers=False)
+            ).tokens
+            query = self.similarity_service.fingerprint(tokens, language=result["language"])
+            search = CodeSearch(search_data.min_tokens)
+            if not query or query[-1].position + search.kgram_size < search.min_tokens:
+                message = f"The snippet holds fewer than {search.min_tokens} compared tokens"
+                raise ValidationException(message, details={"error_type": "snippet_too_short", "message": message})
+
+            repository = SubmissionFingerprintRepository(self.session)
+            indexed_ids = repository.get_indexed_submission_ids([submission.id for submission in submissions])
+            result["unindexed_submission_count"] = len(submissions) - len(indexed_ids)
+            fragments = search.find(query, repository.get_by_hashes(CodeSearch.hashes(query), indexed_ids))
+        else:
+            fragments = []
+            for submission in submissions:
+                fragments.extend(self._search_submission_content(submission, search_data.code))
+
+        submissions_by_id = {submission.id: submission for submission in submissions}
+        result["matches"] = [
+            {
+                "group_uuid": submissions_by_id[match["submission_id"]].group_uuid,
+                "version": submissions_by_id[match["submission_id"]].version,
+                **match,
+            }
+            for match in CodeSearch.by_submission(fragments)
+        ]
+        logger.info(
+            f"Searched {len(submissions)} submissions of step {search_data.project_step_uuid} by {mode}: "
+            f"{len(result['matches'])} matches"
+        )
+        return result
+
+    def _search_submission_content(self, submission: Submission, snippet: str) -> List[Dict[str, Any]]:
+        """Get the occurrences of a snippet in the text files of a submission, none if it cannot be fetched"""
+        try:
+            files = self._read_submission_files(submission)
+        except Exception as e:
+            logger.warning(f"Could not search submission {submission.id}: {str(e)}")
+            return []
+        fragments = []
+        for path, content in files.items():
+            if not self.binary_file_detector.detect(content):
+                fragments.extend(
+                    CodeSearch.find_substring(snippet, submission.id, path, self.encoding_detector.decode(content).text)
+                )
+        return fragments
+
+    def get_submission_metrics(self, submission_id: UUID) -> Dict[str, Any]:
+        """Get the code metrics of a submission, computed when it is analyzed"""
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        if submission.code_metrics is None:
+            raise NotFoundException("Code metrics of submission", str(submission_id))
+        return {"submission_id": submission.id, **submission.code_metrics}
+
+    def compare_external_source(self, submission_id: UUID, comparison_data: ExternalComparisonDto) -> Dict[str, Any]:
+        """
+        Compare a submission with an external source (pasted code or a fetched URL) through the tokenization and
+        comparison of the pairs of submissions, the source being compared as a single file without being stored as
+        a submission. The result is attached to the submission as evidence if requested.
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        self._check_not_quarantined(submission)
+        if (comparison_data.code is None) == (comparison_data.url is None):
+            raise ValidationException("Exactly one of code or url must be given")
+        if comparison_data.code is not None and not comparison_data.language:
+            raise ValidationException("The language of pasted code must be given")
+
+        if comparison_data.url is not None:
+            content, file_name = self.url_source_fetcher.fetch(comparison_data.url)
+        else:
+            content, file_name = comparison_data.code, "external"
+
+        # The source is analyzed under a path of its language when it is given, else under its own file name
+        source_path = Path(file_name)
+        if comparison_data.language:
+            extension = self.tokenization_service.get_language_extension(comparison_data.language)
+            if not extension:
+                message = f"Unsupported language: {comparison_data.language}"
+                raise ValidationException(message, details={"error_type": "unsupported_language", "message": message})
+            source_path = Path(f"{source_path.stem}{extension}")
+        language = self.tokenization_service.detect_file_language(source_path, content)
+
+        tokenization_options = self._get_tokenization_options(
+            submission.project_uuid, submission.project_step_uuid, self.session
+        )
+        source_tokens = self.tokenization_service.tokenize_with_details(
+            content, source_path, tokenization_options
+        ).tokens
+        for token in source_tokens:
+            token["file"] = source_path.name
+
+        submission_tokens, submission_languages = self._tokenize_submission(submission, tokenization_options)
+        similarity_result = self._compare_tokens(
+            submission_tokens,
+            source_tokens,
+            submission_languages,
+            {"languages": {language: 1}},
+            self._get_baseline_fingerprints(submission, self.session),
+            self._get_allowed_snippet_fingerprints(submission, self.session),
+        )
+        result = {
+            "submission_id": submission_id,
+            "source_type": "url" if comparison_data.url is not None else "text",
+            "source_url": comparison_data.url,
+            "label": comparison_data.label,
+            "language": language,
+            "overall_similarity": similarity_result["overall_similarity"],
+            "similarity_metrics": {
+                key: similarity_result[key] for key in EXTERNAL_SIMILARITY_METRICS if key in similarity_result
+            },
+            "matches": similarity_result.get("matches", []),
+            "fragments": similarity_result.get("fragments", []),
+            "evidence_id": None,
+        }
+        logger.info(
+            f"Compared submission {submission_id} with {result['source_type']} source "
+            f"{comparison_data.url or comparison_data.label or ''}: {result['overall_similarity']:.3f}"
+        )
+
+        if comparison_data.store_as_evidence:
+            evidence = SubmissionEvidenceRepository(self.session).create(
+                {
+                    "submission_id": submission_id,
+                    "source_type": result["source_type"],
+                    "source_url": comparison_data.url,
+                    "label": comparison_data.label,
+                    "language": language,
+                    "content": content,
+                    "overall_similarity": result["overall_similarity"],
+                    "similarity_details": {
+                        "similarity_metrics": result["similarity_metrics"],
+                        "raw_similarity": similarity_result.get("raw_similarity"),
+                        "matches": result["matches"],
+                        "fragments": result["fragments"],
+                    },
+                }
+            )
+            result["evidence_id"] = evidence.id
+        return result
+
+    def get_rare_token_evidence(
+        self,
+        run_id: UUID,
+        max_document_frequency: Optional[float] = None,
+        blend_weight: float = 0.0,
+        skip: int = 0,
+        limit: int = 100,
+    ) -> Dict[str, Any]:
+        """
+        Get the pairs of submissions of a detection run sharing rare identifiers or string literals, evidence next to
+        their similarity that structural similarity misses. Each submission of the run is fetched and tokenized, its
+        tokens coming from the token cache when enabled; a submission that cannot be fetched is listed apart. The
+        scores of the pairs are left untouched, a blend weight mixing the evidence score into a blended similarity.
+        """
+        run = SubmissionDetectionRunRepository(self.session).get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+
+        settings = get_settings()
+        if max_document_frequency is None:
+            max_document_frequency = settings.rare_token_max_document_frequency
+        analyzer = RareTokenAnalyzer(max_document_frequency, settings.rare_token_min_length)
+        tokenization_options = self._get_tokenization_options(run.project_uuid, run.project_step_uuid, self.session)
+        token_cache = self._get_token_cache(self.session, run.tenant_id)
+        documents = {}
+        unfetched_submission_ids = []
+        submission_ids = [UUID(submission_id) for submission_id in run.submission_ids]
+        submissions = [s for s in map(self.submission_repository.get_by_id, submission_ids) if s is not None]
+        for submission in submissions:
+            try:
+                tokens, languages = self._tokenize_submission(submission, tokenization_options, token_cache)
+            except Exception as e:
+                logger.warning(f"Could not extract the rare tokens of submission {submission.id}: {str(e)}")
+                unfetched_submission_ids.append(submission.id)
+                continue
+            documents[submission.id] = analyzer.extract(tokens, self._main_language(languages))
+
+        similarities = {
+            SimilarityMatrix.pair_key(similarity.submission_id, similarity.compared_submission_id): similarity
+            for similarity in self.similarity_repository.get_between_submissions(list(documents))
+        }
+        pairs = analyzer.shared_pairs(documents)
+        for pair in pairs:
+            key = SimilarityMatrix.pair_key(pair["submission_id"], pair["compared_submission_id"])
+            similarity = similarities.get(key)
+            pair["similarity_id"] = similarity.id if similarity else None
+            pair["overall_similarity"] = similarity.overall_similarity if similarity else None
+            pair["blended_similarity"] = None
+            if blend_weight and similarity:
+                pair["blended_similarity"] = analyzer.blend(
+                    similarity.overall_similarity, pair["evidence_score"], blend_weight
+                )
+        logger.info(f"Found {len(pairs)} pairs sharing rare tokens among {len(documents)} submissions of run {run_id}")
+        return {
+            "run_id": run.id,
+            "submission_count": len(documents),
+            "max_document_frequency": max_document_frequency,
+            "max_submissions": analyzer.max_submissions(len(documents)),
+            "blend_weight": blend_weight,
+            "total_pairs": len(pairs),
+            "pairs": pairs[skip : skip + limit],
+            "unfetched_submission_ids": unfetched_submission_ids,
+        }
+
+    def get_submission_evidence(self, submission_id: UUID) -> List[SubmissionEvidence]:
+        """Get the external source comparisons attached to a submission"""
+        if not self.submission_repository.get_by_id(submission_id):
+            raise NotFoundException("Submission", str(submission_id))
+        return SubmissionEvidenceRepository(self.session).get_by_submission_id(submission_id)
+
+    def extract_upload(
+        self, content: bytes, filename: str, limits: Dict[str, Any], ignore_rules: Optional[IgnoreRules] = None
+    ) -> ArchiveExtractionResult:
+        """
+        Get the files of an uploaded submission: those of a ZIP, tar or gzipped tar archive (recognized by its
+        signature, whatever its name), the uploaded file itself otherwise. The extraction stops at the first entry
+        exceeding a limit of the upload, none of the extracted files being kept, and the upload is rejected if it
+        holds a binary file while the step rejects them. The entries matching the ignore rules are left out of the
+        archive before the limits are checked.
+
+        Raises:
+            ValidationException: If the upload is invalid or exceeds a limit, with the structured error of the limit
+        """
+        self._require_upload_storage()
+        if not content:
+            raise ValidationException("The uploaded file is empty")
+        if len(content) > limits["max_upload_bytes"]:
+            error = self.upload_too_large(limits["max_upload_bytes"])
+            raise ValidationException(str(error), details=error.to_dict())
+
+        extractor = self._limited_extractor(limits, ignore_rules)
+        try:
+            if not ArchiveExtractor.is_archive(content):
+                file = ArchiveFile(path=filename, content=content)
+                extractor.check_files([file])
+                self._check_binary_files([file], limits)
+                return ArchiveExtractionResult(files=[file], extracted_bytes=len(content))
+            extraction = extractor.extract(content)
+        except ArchiveLimitExceeded as e:
+            logger.warning(f"Rejected the upload {filename}: {str(e)}")
+            raise ValidationException(str(e), details=e.to_dict())
+        except ValueError as e:
+            raise ValidationException(str(e))
+        if not extraction.files:
+            raise ValidationException(
+                "No files were extracted from the uploaded archive",
+                details={
+                    "skipped_entries": extraction.skipped_entries,
+                    "ignored_paths": extraction.ignored_paths[:IGNORED_PATH_SAMPLE_SIZE],
+                },
+            )
+        self._check_binary_files(extraction.files, limits)
+        logger.info(
+            f"Extracted {len(extraction.files)} files from the uploaded archive {filename}, "
+            f"skipped {len(extraction.skipped_entries)} entries, ignored {len(extraction.ignored_paths)} paths"
+        )
+        return extraction
+
+    @staticmethod
+    def upload_too_large(max_upload_bytes: int) -> ArchiveLimitExceeded:
+        """Error of an uploaded file over the size limit"""
+        return ArchiveLimitExceeded(
+            UPLOAD_TOO_LARGE, f"The uploaded file exceeds {max_upload_bytes} bytes", max_upload_bytes
+        )
+
+    def create_bulk_upload_job(
+        self, content: bytes, filename: str, project_uuid: UUID, project_step_uuid: UUID, directory_pattern: str
+    ) -> SubmissionBulkUploadJob:
+        """Check the archive of a bulk upload, and record the job its submissions are created by"""
+        settings = get_settings()
+        self._require_upload_storage()
+        if not content:
+            raise ValidationException("The uploaded file is empty")
+        if len(content) > settings.bulk_upload_max_bytes:
+            error = self.upload_too_large(settings.bulk_upload_max_bytes)
+            raise ValidationException(str(error), details=error.to_dict())
+        if not ArchiveExtractor.is_archive(content):
+            raise ValidationException("A bulk upload is a ZIP, tar or tar.gz archive of one directory per student")
+        return SubmissionBulkUploadJobRepository(self.session).create(
+            {
+                "project_uuid": project_uuid,
+                "project_step_uuid": project_step_uuid,
+                "filename": filename,
+                "directory_pattern": directory_pattern,
+            }
+        )
+
+    def extract_bulk_upload(self, content: bytes) -> ArchiveExtractionResult:
+        """Get the files of the archive of a bulk upload, the directories of all the students"""
+        try:
+            settings = get_settings()
+            extractor = ArchiveExtractor(
+                settings.bulk_upload_max_extracted_bytes,
+                enforce_limits=True,
+                max_entries=settings.archive_max_entries,
+                max_compression_ratio=settings.archive_max_compression_ratio,
+            )
+            return extractor.extract(content)
+        except ArchiveLimitExceeded as e:
+            raise ValidationException(str(e), details=e.to_dict())
+        except ValueError as e:
+            raise ValidationException(str(e))
+
+    def get_bulk_upload_job(self, job_id: UUID) -> SubmissionBulkUploadJob:
+        """Get a bulk upload job, to poll the creation of its submissions"""
+        job = SubmissionBulkUploadJobRepository(self.session).get_by_id(job_id)
+        if not job:
+            raise NotFoundException("Bulk upload job", str(job_id))
+        return job
+
+    def update_bulk_upload_job(self, job_id: UUID, job_data: dict) -> SubmissionBulkUploadJob:
+        """Record the progress of a bulk upload"""
+        return SubmissionBulkUploadJobRepository(self.session).update(job_id, job_data)
+
+    def create_upload_session(self, upload_data: Dict[str, Any]) -> SubmissionUploadSession:
+        """
+        Initiate a resumable upload, the file being sent in chunks of the configured size until it is finalized
+
+        Raises:
+            ValidationException: If the announced file exceeds the upload limit of its project step
+        """
+        self._require_upload_storage()
+        limits = self.get_upload_limits(upload_data["project_uuid"], upload_data["project_step_uuid"])
+        if upload_data["total_bytes"] > limits["max_upload_bytes"]:
+            error = self.upload_too_large(limits["max_upload_bytes"])
+            raise ValidationException(str(error), details=error.to_dict())
+        return self.get_resumable_uploads().create(upload_data, get_paris_time())
+
+    def get_resumable_uploads(self) -> ResumableUploads:
+        """Get the resumable uploads, their chunks being kept in the object storage of the service"""
+        settings = get_settings()
+        return ResumableUploads(
+            SubmissionUploadSessionRepository(self.session),
+            ChunkedUploadStore(self.submission_fetcher.object_storage),
+            settings.chunked_upload_chunk_bytes,
+            settings.chunked_upload_ttl_seconds,
+        )
+
+    def get_idempotency_keys(self) -> IdempotencyKeys:
+        """Get the idempotency keys of the creation requests, expiring after the configured time"""
+        return IdempotencyKeys(
+            SubmissionIdempotencyKeyRepository(self.session), get_settings().idempotency_key_ttl_seconds
+        )
+
+    def fetch_git_submission(self, git_data: CreateGitSubmissionDto) -> GitRefFetchResult:
+        """
+        Fetch the tree of a Git repository at a ref for a new submission, the token being only handed to git
+
+        Raises:
+            ValidationException: If the repository cannot be fetched (its details tell whether the authentication
+                failed, the ref is missing or the network timed out) or its tree has no files
+        """
+        self._require_upload_storage()
+        settings = get_settings()
+        fetcher = GitRefFetcher(
+            timeout_seconds=settings.git_fetch_timeout_seconds,
+            ignore_patterns=settings.git_submission_ignore_patterns,
+            max_extracted_bytes=settings.submission_upload_max_extracted_bytes,
+        )
+        temp_dir = Path(tempfile.mkdtemp(prefix="git_submission_"))
+        try:
+            result = fetcher.fetch(
+                git_data.repository_url,
+                str(temp_dir),
+                ref=git_data.ref,
+                subdirectory=git_data.subdirectory,
+                token=git_data.token.get_secret_value() if git_data.token else None,
+                ignore_patterns=git_data.ignore_patterns,
+                ignored=self.get_ignore_rules(git_data.project_uuid, git_data.project_step_uuid).ignores,
+            )
+        finally:
+            cleanup_temp_directory(temp_dir)
+        if not result.extraction.files:
+            raise ValidationException(
+                f"No files were found in {git_data.repository_url} at commit {result.commit_sha}",
+                details={"error_type": "git_empty_tree", "skipped_entries": result.extraction.skipped_entries},
+            )
+        return result
+
+    def store_upload(
+        self,
+        content: bytes,
+        filename: str,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        group_uuid: UUID,
+        version: int,
+    ) -> str:
+        """
+        Keep the original file of an uploaded submission in the object storage or upload bucket, under the tenant,
+        assignment, submission and version it is uploaded as, and get its S3 link
+        """
+        tenant_id = self.tenant_scope.step_tenant(project_step_uuid)
+        object_key = submission_file_key(project_uuid, project_step_uuid, group_uuid, version, filename, tenant_id)
+        return self.submission_fetcher.store_upload(
+            get_settings().submission_upload_bucket, object_key, io.BytesIO(content)
+        )
+
+    def create_submission_files(self, submission_id: UUID, files: List[ArchiveFile]) -> List[SubmissionFile]:
+        """
+        Record the files extracted from the upload of a submission, with their detected language and content hash,
+        and whether they are binary or the encoding of their text, the files being kept in storage as uploaded
+        """
+        records = []
+        for file in files:
+            binary = self.binary_file_detector.detect(file.content) is not None
+            decoded = None if binary else self.encoding_detector.decode(file.content)
+            records.append(
+                {
+                    "submission_id": submission_id,
+                    "path": file.path,
+                    "size_bytes": len(file.content),
+                    "language": self._detect_uploaded_file_language(file.path, decoded),
+                    "archive": file.archive,
+                    "content_hash": TokenStreamCache.content_hash(file.content),
+                    "binary": binary,
+                    "encoding": decoded.encoding if decoded else None,
+                    "encoding_error_count": decoded.replaced_count if decoded else 0,
+                }
+            )
+        return SubmissionFileRepository(self.session).create_many(records)
+
+    def detect_duplicate_upload(self, submission_id: UUID, records: List[SubmissionFile]) -> Optional[Submission]:
+        """
+        Record the aggregate content hash of an uploaded submission from the content hashes of its files, and flag
+        it if the submission of another group of its step has the same files: the submission references the earliest
+        one, which references it in turn unless already flagged, and the webhooks of the step are notified. Returns
+        the submission duplicated, None if the upload is not a duplicate.
+        """
+        content_hash = DuplicateUploadDetector.aggregate_hash(record.content_hash for record in records)
+        if content_hash is None:
+            return None
+        submission = self.submission_repository.patch(submission_id, {"content_hash": content_hash})
+        candidates = self.submission_repository.get_by_content_hash(
+            submission.project_uuid, submission.project_step_uuid, content_hash
+        )
+        originals = DuplicateUploadDetector.originals(submission, candidates)
+        if not originals:
+            return None
+
+        original = originals[0]
+        submission = self.submission_repository.patch(submission_id, {"duplicate_of": original.id})
+        if original.duplicate_of is None:
+            self.submission_repository.patch(original.id, {"duplicate_of": submission_id})
+        logger.warning(
+            f"Submission {submission_id} of group {submission.group_uuid} has the same files as submission "
+            f"{original.id} of group {original.group_uuid}"
+        )
+        self._notify_submission(
+            WebhookEvent.SUBMISSION_DUPLICATE,
+            submission,
+            duplicate_of=str(original.id),
+            duplicate_of_group_uuid=str(original.group_uuid),
+            duplicate_submission_ids=[str(duplicate.id) for duplicate in originals],
+            content_hash=content_hash,
+        )
+        return original
+
+    def get_submission_files(self, submission_id: UUID) -> List[SubmissionFile]:
+        """Get the files extracted from the upload of a submission, none for a linked submission"""
+        if not self.submission_repository.get_by_id(submission_id):
+            raise NotFoundException("Submission", str(submission_id))
+        return SubmissionFileRepository(self.session).get_by_submission_id(submission_id)
+
+    def get_submission_archive(self, submission_id: UUID) -> Tuple[str, Iterator[bytes]]:
+        """Get the name and the streamed content of the original file of an uploaded submission"""
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        self._check_not_quarantined(submission)
+        if not SubmissionFileRepository(self.session).get_by_submission_id(submission_id):
+            raise NotFoundException("Uploaded archive of submission", str(submission_id))
+        return PurePosixPath(submission.link).name, self.submission_fetcher.stream_upload(submission.link)
+
+    def get_submission_download(
+        self, submission_id: UUID, version: Optional[int] = None, original: bool = False
+    ) -> Tuple[str, Iterator[bytes]]:
+        """
+        Get the filename and the streamed content of the download of a submission, or of another of its versions:
+        a ZIP archive of its files built on the fly, or the original file of an uploaded submission as is. The
+        submission is fetched beforehand, so that a failure is reported before anything is streamed.
+        """
+        submission = self._get_submission_version(submission_id, version)
+        self._check_not_quarantined(submission)
+        if original:
+            if not SubmissionFileRepository(self.session).get_by_submission_id(submission.id):
+                raise NotFoundException("Uploaded archive of submission", str(submission.id))
+            filename = self._download_filename(PurePosixPath(submission.link).name)
+            return filename, self.submission_fetcher.stream_upload(submission.link)
+
+        submission_path = self.submission_fetcher.fetch_submission(
+            CreateSubmissionDto(
+                link=submission.link,
+                project_uuid=submission.project_uuid,
+                group_uuid=submission.group_uuid,
+                project_step_uuid=submission.project_step_uuid,
+                link_type=submission.link_type,
+            )
+        )
+        name = submission.display_name or f"submission-{submission.group_uuid}"
+        filename = self._download_filename(f"{name}-v{submission.version}.zip")
+        return filename, self._stream_submission_zip(submission_path, submission.created_at)
+
+    def export_submission_tokens(
+        self, submission_id: UUID, export_format: str, detection_options: DetectionOptionsDto
+    ) -> Tuple[Iterator[str], str]:
+        """
+        Export the token streams of the analyzed files of a submission as a JSON document or as newline-delimited
+        JSON, streamed, along with the name of the exported file. The tokens of each file come from the token cache
+        when enabled, and are normalized with the normalization options of the detection. The submission is fetched
+        beforehand, so that a failure is reported before anything is streamed.
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        self._check_not_quarantined(submission)
+
+        tokenization_options = self._get_tokenization_options(
+            submission.project_uuid, submission.project_step_uuid, self.session
+        )
+        submission_path = self.submission_fetcher.fetch_submission(
+            CreateSubmissionDto(
+                link=submission.link,
+                project_uuid=submission.project_uuid,
+                group_uuid=submission.group_uuid,
+                project_step_uuid=submission.project_step_uuid,
+                link_type=submission.link_type,
+            )
+        )
+        try:
+            selection = self._collect_submission_files(submission_path)
+            languages = self._check_language_confidence(selection, submission_path)
+            self._exclude_generated_files(selection, submission_path, submission)
+        except Exception:
+            cleanup_temp_directory(submission_path)
+            raise
+
+        normalization = detection_options.model_dump(include=set(EXPORTED_NORMALIZATION_OPTIONS))
+        header = {
+            "submission_id": submission.id,
+            "project_uuid": submission.project_uuid,
+            "project_step_uuid": submission.project_step_uuid,
+            "version": submission.version,
+            "tokenizer_version": TOKENIZER_VERSION,
+            "language": self._main_language(languages),
+            "normalization": normalization,
+        }
+        files = self._iter_file_token_streams(
+            selection, submission_path, tokenization_options, DetectionOptionsDto(**normalization), submission.tenant_id
+        )
+        exporter = TokenStreamExporter(header, files)
+        filename = f"submission-{submission.id}-tokens.{export_format}"
+        return (exporter.ndjson() if export_format == "ndjson" else exporter.json()), filename
+
+    def _iter_file_token_streams(
+        self,
+        selection: GoPackagePreprocessingResult,
+        repo_path: Path,
+        tokenization_options: TokenizationOptionsDto,
+        detection_options: DetectionOptionsDto,
+        tenant_id: Optional[str] = None,
+    ) -> Iterator[Dict[str, Any]]:
+        """
+        Token streams of the selected files of a fetched submission of a tenant, one file at a time, prepared for
+        the comparison with the detection options, the directory being deleted once they are all streamed
+        """
+        try:
+            token_cache = self._get_token_cache(self.session, tenant_id)
+            for file_path in selection.files:
+                if not file_path.is_file():
+                    continue
+                content = self._read_file_with_encoding_detection(file_path)
+                if content is None:
+                    continue
+                language = self.tokenization_service.detect_file_language(file_path, content)
+                tokens = self._tokenize_file(
+                    content, file_path, repo_path, [], tokenization_options, token_cache=token_cache
+                )
+                yield {
+                    "file": str(file_path.relative_to(repo_path)),
+                    "language": language,
+                    "tokens": self.similarity_service.prepare_for_similarity(tokens, detection_options, language),
+                }
+        finally:
+            cleanup_temp_directory(repo_path)
+
+    @staticmethod
+    def _check_not_quarantined(submission: Submission) -> None:
+        """
+        Check that the files of a submission may be downloaded or compared
+
+        Raises:
+            ConflictException: If the submission is quarantined, until released, or its files were purged
+        """
+        if submission.status == SubmissionStatus.QUARANTINED:
+            message = f"Submission {submission.id} is quarantined for malware, its files are not available"
+            raise ConflictException(
+                message,
+                details={
+                    "error_type": "submission_quarantined",
+                    "message": message,
+                    "submission_id": str(submission.id),
+                },
+            )
+        if submission.files_purged_at is not None:
+            message = f"The files of submission {submission.id} were purged past their retention period"
+            raise ConflictException(
+                message,
+                details={
+                    "error_type": "submission_files_purged",
+                    "message": message,
+                    "submission_id": str(submission.id),
+                },
+            )
+
+    def _get_submission_version(self, submission_id: UUID, version: Optional[int] = None) -> Submission:
+        """Get a submission, or another of its versions by number"""
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        if version is None or version == submission.version:
+            return submission
+        versions = self.submission_repository.get_versions(
+            submission.project_uuid, submission.group_uuid, submission.project_step_uuid
+        )
+        other = next((other for other in versions if other.version == version), None)
+        if not other:
+            raise NotFoundException(f"Version {version} of submission", str(submission_id))
+        return other
+
+    @staticmethod
+    def _stream_submission_zip(submission_path: Path, modified_at: datetime) -> Iterator[bytes]:
+        """ZIP archive of the files of a fetched submission, its directory being deleted once streamed"""
+        try:
+            files = SubmissionVersionDiffer.file_paths(submission_path)
+            yield from ZipStreamer().stream(files.items(), modified_at)
+        finally:
+            cleanup_temp_directory(submission_path)
+
+    @staticmethod
+    def _download_filename(filename: str) -> str:
+        """Name of a downloaded file, made of the characters safe in a Content-Disposition header"""
+        return re.sub(r"[^A-Za-z0-9._-]+", "_", filename).strip("._") or "submission"
+
+    def get_submission_versions(self, submission_id: UUID) -> List[Submission]:
+        """Get the versions of a submission (of its group for its project step) but the deleted ones, oldest first"""
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        return self.submission_repository.get_versions(
+            submission.project_uuid, submission.group_uuid, submission.project_step_uuid
+        )
+
+    def diff_submission_versions(self, submission_id: UUID, other_submission_id: UUID) -> Dict[str, Any]:
+        """
+        Compare file by file two versions of a submission, from the older version to the newer one whatever their
+        order, the files of both versions being fetched like for a comparison
+
+        Raises:
+            ValidationException: If the submissions are not versions of the same group for the same project step
+        """
+        versions = []
+        for version_id in (submission_id, other_submission_id):
+            submission = self.submission_repository.get_by_id(version_id)
+            if not submission:
+                raise NotFoundException("Submission", str(version_id))
+            versions.append(submission)
+        if len({(s.project_uuid, s.group_uuid, s.project_step_uuid) for s in versions}) > 1:
+            raise ValidationException("Only the versions of the same group for the same project step are compared")
+
+        old, new = sorted(versions, key=lambda submission: submission.version)
+        diff = self.version_differ.diff(self._read_submission_files(old), self._read_submission_files(new))
+        logger.info(
+            f"Compared versions {old.version} and {new.version} of submission {new.id}: "
+            f"{diff['files_added']} added, {diff['files_removed']} removed, {diff['files_modified']} modified"
+        )
+        return {
+            "from_submission_id": old.id,
+            "from_version": old.version,
+            "to_submission_id": new.id,
+            "to_version": new.version,
+            **diff,
+        }
+
+    def diff_file_tokens(self, diff_data: TokenDiffRequestDto) -> Dict[str, Any]:
+        """
+        Align token by token two files of submissions, tokenized and normalized as the detection does with the
+        options of an analysis profile, through the abstract token categories when cross-language detection is
+        enabled and the languages of the files differ. The operations are served by pages, with the cursor of the
+        next one for large files.
+
+        Raises:
+            NotFoundException: If a submission, or a file in it, does not exist
+            ValidationException: If a file is binary, the profile or its overrides invalid, or the cursor invalid
+        """
+        effective_options = self.get_analysis_profile_catalog().resolve(diff_data.profile, diff_data.overrides)
+        options = DetectionOptionsDto(**effective_options["detection_options"])
+        files = [self._read_diffed_file(diff_data.file1), self._read_diffed_file(diff_data.file2)]
+
+        language1, language2 = (file["language"] for file in files)
+        cross_language = bool(
+            (self.cross_language_detection or options.cross_language)
+            and language1
+            and language2
+            and language1 != language2
+        )
+        streams = []
+        for file in files:
+            tokens = TokenAbstractor().abstract(file["tokens"]) if cross_language else file["tokens"]
+            streams.append(self.similarity_service.compared_stream(tokens, options))
+        (tokens1, parts1), (tokens2, parts2) = streams
+
+        aligner = TokenAligner()
+        operations = aligner.align(parts1, parts2, tokens1, tokens2)
+        try:
+            page = aligner.page(operations, aligner.digest(parts1, parts2), diff_data.cursor, diff_data.limit)
+        except ValueError as e:
+            raise ValidationException(str(e))
+        logger.info(
+            f"Aligned {files[0]['path']} of submission {diff_data.file1.submission_id} with {files[1]['path']} "
+            f"of submission {diff_data.file2.submission_id}: {len(operations)} operations"
+        )
+        described = [{key: file[key] for key in ("submission_id", "path", "language")} for file in files]
+        return {
+            "file1": {**described[0], "token_count": len(parts1)},
+            "file2": {**described[1], "token_count": len(parts2)},
+            "cross_language": cross_language,
+            "analysis_profile": diff_data.profile or DEFAULT_PROFILE_NAME,
+            "detection_options": effective_options["detection_options"],
+            "summary": aligner.summary(operations, len(parts1), len(parts2)),
+            **page,
+        }
+
+    def _read_diffed_file(self, reference: FileReferenceDto) -> Dict[str, Any]:
+        """Fetch a submission and get a file of it, with its language and its tokens"""
+        submission = self.submission_repository.get_by_id(reference.submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(reference.submission_id))
+        self._check_not_quarantined(submission)
+
+        tokenization_options = self._get_tokenization_options(
+            submission.project_uuid, submission.project_step_uuid, self.session
+        )
+        submission_path = self.submission_fetcher.fetch_submission(
+            CreateSubmissionDto(
+                link=submission.link,
+                project_uuid=submission.project_uuid,
+                group_uuid=submission.group_uuid,
+                project_step_uuid=submission.project_step_uuid,
+                link_type=submission.link_type,
+            )
+        )
+        try:
+            file_path = submission_path / reference.path
+            if not file_path.is_file() or file_path.is_symlink():
+                raise NotFoundException(f"File {reference.path} of submission", str(submission.id))
+            content = self._read_file_with_encoding_detection(file_path)
+            if content is None:
+                raise ValidationException(f"File {reference.path} of submission {submission.id} is not a text file")
+            tokens = self._tokenize_file(
+                content,
+                file_path,
+                submission_path,
+                [],
+                tokenization_options,
+                token_cache=self._get_token_cache(self.session, submission.tenant_id),
+            )
+            return {
+                "submission_id": submission.id,
+                "path": reference.path,
+                "language": self.tokenization_service.detect_file_language(file_path, content),
+                "tokens": tokens,
+            }
+        finally:
+            cleanup_temp_directory(submission_path)
+
+    def build_submission_audit_entry(
+        self, submission_id: UUID, changes: Dict[str, Dict[str, Any]], actor: Optional[str], ip_address: Optional[str]
+    ) -> SubmissionAuditEntry:
+        """
+        Audit entry of the old and new values of the changed metadata of a submission, not saved: it is committed
+        along with the changes
+        """
+        return SubmissionAuditEntry(
+            submission_id=submission_id, changes=jsonable_encoder(changes), actor=actor, ip_address=ip_address
+        )
+
+    def get_submission_audit_trail(self, submission_id: UUID) -> List[SubmissionAuditEntry]:
+        """Get the changes of the metadata of a submission, oldest first"""
+        if not self.submission_repository.get_by_id(submission_id):
+            raise NotFoundException("Submission", str(submission_id))
+        return SubmissionAuditRepository(self.session).get_by_submission_id(submission_id)
+
+    def get_submission_processing_log(
+        self,
+        submission_id: UUID,
+        skip: int,
+        limit: int,
+        stage: Optional[ProcessingStage] = None,
+        warnings_only: bool = False,
+    ) -> Tuple[List[SubmissionProcessingEvent], int]:
+        """Get a page of the processing log of a submission in the order the stages started, with its total"""
+        if not self.submission_repository.get_by_id(submission_id):
+            raise NotFoundException("Submission", str(submission_id))
+        return SubmissionProcessingEventRepository(self.session).get_page(
+            submission_id, skip, limit, stage, warnings_only
+        )
+
+    def prune_processing_log(self, retention_days: int) -> int:
+        """Delete the events of the processing logs older than the retention period, returning their number"""
+        pruned = SubmissionProcessingEventRepository(self.session).delete_created_before(
+            get_paris_time() - timedelta(days=retention_days)
+        )
+        if pruned:
+            logger.info(f"Pruned {pruned} processing log events older than {retention_days} days")
+        return pruned
+
+    def get_resource_project_step(self, resource_type: str, resource_id: UUID) -> Optional[UUID]:
+        """
+        Project step of a comparison, report job, detection run, baseline, allowed snippet or bulk upload, for the
+        checks of the access policy; None if it does not exist, the operation then failing as not found
+        """
+        if resource_type == "report_job":
+            job = SubmissionReportJobRepository(self.session).get_by_id(resource_id)
+            resource_type, resource_id = "similarity", job.similarity_id if job else None
+        repositories = {
+            "similarity": self.similarity_repository,
+            "detection_run": SubmissionDetectionRunRepository(self.session),
+            "baseline": self.baseline_repository,
+            "allowed_snippet": self.allowed_snippet_repository,
+            "bulk_upload_job": SubmissionBulkUploadJobRepository(self.session),
+        }
+        resource = repositories[resource_type].get_by_id(resource_id) if resource_id else None
+        return resource.project_step_uuid if resource else None
+
+    def record_access_denial(self, denial: AccessDenied) -> SubmissionAccessDenial:
+        """Log an access denied to its caller in the audit log, with the identity of the caller and the resource"""
+        logger.warning(
+            f"Denied {denial.action} of {denial.resource_type} {denial.resource_id} to {denial.principal.subject} "
+            f"({denial.principal.role.value}): {str(denial)}"
+        )
+        return SubmissionAccessDenialRepository(self.session).create(
+            {
+                "tenant_id": denial.principal.tenant_id,
+                "subject": denial.principal.subject,
+                "role": denial.principal.role.value,
+                "action": denial.action,
+                "resource_type": denial.resource_type,
+                "resource_id": denial.resource_id,
+                "reason": str(denial),
+            }
+        )
+
+    def get_access_denials(self, subject: Optional[str] = None, limit: int = 100) -> List[SubmissionAccessDenial]:
+        """Get the latest accesses denied to the callers of the tenant of the caller, latest first"""
+        return SubmissionAccessDenialRepository(self.session).list_recent(subject, limit, self.tenant_scope.tenant_id)
+
+    def invalidate_detection_results(self, submission: Submission, project_uuid: UUID, project_step_uuid: UUID) -> int:
+        """
+        Drop the detection results of a submission moved to another group or out of a project step: its
+        comparisons (and their reports) and its corpus matches, the step's runs that compared it losing their
+        stored summary. The submission is then compared again as moved, returning the number of dropped
+        comparisons.
+        """
+        similarities = self.similarity_repository.get_by_submission_id(submission.id)
+        similarity_ids = [similarity.id for similarity in similarities]
+        SubmissionReportJobRepository(self.session).delete_by_similarity_ids(similarity_ids)
+        self.similarity_repository.delete_by_submission_id(submission.id)
+        SubmissionCorpusMatchRepository(self.session).delete_by_submission_id(submission.id)
+
+        run_repository = SubmissionDetectionRunRepository(self.session)
+        for run in run_repository.get_by_project_step(project_uuid, project_step_uuid):
+            if str(submission.id) in run.submission_ids and run.summary is not None:
+                run_repository.update(run.id, {"summary": None})
+
+        logger.info(
+            f"Invalidated {len(similarity_ids)} comparisons of submission {submission.id}, "
+            f"moved from project step {project_step_uuid}"
+        )
+        self.process_submission_similarities_async(submission)
+        return len(similarity_ids)
+
+    def purge_submission(self, submission_id: UUID) -> None:
+        """
+        Delete a submission for good: the original file of an uploaded submission is deleted from the upload
+        bucket first, then its comparisons (and their reports), corpus matches, evidence, file records, indexed
+        fingerprints, audit trail and processing log. The repositories and buckets a submission links to are not
+        the service's, they are left as they are.
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+
+        self._delete_stored_upload(submission)
+
+        similarities = self.similarity_repository.get_by_submission_id(submission_id)
+        similarity_ids = [similarity.id for similarity in similarities]
+        SubmissionReportJobRepository(self.session).delete_by_similarity_ids(similarity_ids)
+        self.similarity_repository.delete_by_submission_id(submission_id)
+        SubmissionCorpusMatchRepository(self.session).delete_by_submission_id(submission_id)
+        SubmissionEvidenceRepository(self.session).delete_by_submission_id(submission_id)
+        SubmissionFileRepository(self.session).delete_by_submission_id(submission_id)
+        SubmissionFingerprintRepository(self.session).delete_by_submission_id(submission_id)
+        SubmissionAuditRepository(self.session).delete_by_submission_id(submission_id)
+        SubmissionProcessingEventRepository(self.session).delete_by_submission_id(submission_id)
+        self.submission_repository.delete(submission_id)
+        logger.info(f"Purged submission {submission_id} and {len(similarity_ids)} comparisons")
+
+    def _delete_stored_upload(self, submission: Submission) -> None:
+        """Delete the original file of an uploaded submission, kept by the service, from the upload bucket"""
+        bucket = get_settings().submission_upload_bucket
+        if self.submission_fetcher.is_stored_upload(submission.link) or (
+            bucket and submission.link.startswith(f"s3://{bucket}/uploads/")
+        ):
+            self.submission_fetcher.delete_upload(submission.link)
+
+    def get_data_retention(self) -> DataRetention:
+        """Get the retention policies of the project steps, and the purges of their expired files and results"""
+        return DataRetention(
+            SubmissionRetentionPolicyRepository(self.session),
+            SubmissionRetentionPurgeRepository(self.session),
+            SubmissionStepScheduleRepository(self.session),
+            self.submission_repository,
+            self.similarity_repository,
+            SubmissionDetectionRunRepository(self.session),
+            SubmissionReportJobRepository(self.session),
+            SubmissionFingerprintRepository(self.session),
+            self.purge_submission,
+            self._delete_stored_upload,
+        )
+
+    def purge_token_cache(self, language: str) -> Dict[str, Any]:
+        """
+        Delete the cached token streams of a language, of every tokenizer version, after a fix of its tokenizer:
+        its files are tokenized again by the next comparisons, and cached anew
+        """
+        purged_count = SubmissionTokenCacheRepository(self.session).delete_by_language(language)
+        logger.info(f"Purged {purged_count} cached token streams of {language}")
+        return {"language": language, "purged_entry_count": purged_count, "tokenizer_version": TOKENIZER_VERSION}
+
+    def _read_submission_files(self, submission: Submission) -> Dict[str, bytes]:
+        """Fetch a submission and get the content of its files by relative path"""
+        submission_path = None
+        try:
+            submission_path = self.submission_fetcher.fetch_submission(
+                CreateSubmissionDto(
+                    link=submission.link,
+                    project_uuid=submission.project_uuid,
+                    group_uuid=submission.group_uuid,
+                    project_step_uuid=submission.project_step_uuid,
+                    link_type=submission.link_type,
+                )
+            )
+            return self.version_differ.read_files(submission_path)
+        finally:
+            if submission_path and submission_path.exists():
+                cleanup_temp_directory(submission_path)
+
+    def _require_upload_storage(self) -> None:
+        """
+        Reject the uploaded submissions when there is nowhere to keep their original file: neither an object storage
+        with S3 links nor an upload bucket
+        """
+        if not self.submission_fetcher.stores_uploads and not get_settings().submission_upload_bucket:
+            raise ValidationException(
+                "Uploaded submissions are disabled: no S3 object storage nor upload bucket is configured"
+            )
+
+    def _detect_uploaded_file_language(self, path: str, decoded: Optional[DecodedText]) -> Optional[str]:
+        """Language of an uploaded file from its decoded text, None for a binary file or a file of no known language"""
+        if decoded is None:
+            return None
+        detection = self.tokenization_service.detect_language_with_confidence(Path(path), decoded.text)
+        return None if detection.method == "default" else detection.language
+
+    @staticmethod
+    def _detect_link_type(link: str) -> Optional[LinkType]:
+        """Get the type of a link from its URL, None if it is not recognized"""
+        link_lower = link.lower()
+        if link_lower.startswith("s3://"):
+            return LinkType.S3
+        elif "github.com" in link_lower:
+            return LinkType.GITHUB
+        elif "gitlab.com" in link_lower:
+            return LinkType.GITLAB
+        return None
+
+    def _tokenize_submission(
+        self,
+        submission: Submission,
+        tokenization_options: TokenizationOptionsDto,
+        token_cache: Optional[TokenStreamCache] = None,
+    ) -> Tuple[List[Dict[str, Any]], Dict[str, Any]]:
+        """
+        Fetch a submission and tokenize its analyzed files as one stream, returning the tokens with the detection of
+        its languages
+        """
+        submission_path = None
+        try:
+            submission_path = self.submission_fetcher.fetch_submission(
+                CreateSubmissionDto(
+                    link=submission.link,
+                    project_uuid=submission.project_uuid,
+                    group_uuid=submission.group_uuid,
+                    project_step_uuid=submission.project_step_uuid,
+                    link_type=submission.link_type,
+                )
+            )
+            selection = self._collect_submission_files(submission_path)
+            languages = self._check_language_confidence(selection, submission_path)
+            self._exclude_generated_files(selection, submission_path, submission)
+            return self._tokenize_selection(selection, submission_path, tokenization_options, token_cache), languages
+        finally:
+            if submission_path and submission_path.exists():
+                cleanup_temp_directory(submission_path)
+
+    def _tokenize_selection(
+        self,
+        selection: GoPackagePreprocessingResult,
+        repo_path: Path,
+        tokenization_options: TokenizationOptionsDto,
+        token_cache: Optional[TokenStreamCache] = None,
+    ) -> List[Dict[str, Any]]:
+        """Tokenize the selected files of a repository, one stream for all of them"""
+        tokens = []
+        for file_path in selection.files:
+            if not file_path.is_file():
+                continue
+            content = self._read_file_with_encoding_detection(file_path)
+            if content is not None:
+                tokens.extend(
+                    self._tokenize_file(
+                        content, file_path, repo_path, [], tokenization_options, token_cache=token_cache
+                    )
+                )
+        return tokens
+
+    def _get_baseline_fingerprints(self, submission: Submission, session: Session) -> Set[str]:
+        """Get the fingerprints of all the baselines of the project step of a submission"""
+        fingerprints = set()
+        baselines = SubmissionBaselineRepository(session).get_by_project_step(
+            submission.project_uuid, submission.project_step_uuid
+        )
+        for baseline in baselines:
+            fingerprints.update(baseline.fingerprints or [])
+        return fingerprints
+
+    def _get_allowed_snippet_fingerprints(self, submission: Submission, session: Session) -> Set[str]:
+        """Get the fingerprints of all the allowed snippets of the project step of a submission"""
+        fingerprints = set()
+        snippets = SubmissionAllowedSnippetRepository(session).get_by_project_step(
+            submission.project_uuid, submission.project_step_uuid
+        )
+        for snippet in snippets:
+            fingerprints.update(snippet.fingerprints or [])
+        return fingerprints
+
+    def _collect_submission_files(self, repo_path: Path) -> GoPackagePreprocessingResult:
+        """
+        Get the supported files of a submission, the Go files being grouped by package for the build platform. The
+        binary files are left out whatever their extension, such as a compiled program named main.c.
+        """
+        files = [
+            file_path
+            for file_path in self.tokenization_service.extract_supported_files_from_directory(repo_path)
+            if not self.binary_file_detector.detect_file(file_path)
+        ]
+        return self.go_package_preprocessor.prepare(files, repo_path)
+
+    def _tree_log_entries(self, repo_path: Path, timeline: ProcessingTimeline) -> List[Dict[str, str]]:
+        """
+        Entries of the processing log of a fetched submission for the files left out of its analysis (binary files,
+        symbolic links, never followed) and for its paths differing only by case, recorded in its timeline too as
+        warnings of the extraction
+        """
+        entries = []
+        paths = []
+        for file_path in sorted(repo_path.rglob("*")):
+            relative_path = file_path.relative_to(repo_path)
+            if ".git" in relative_path.parts:
+                continue
+            if file_path.is_symlink():
+                entries.append({"level": "warning", "message": f"Skipped symbolic link {relative_path}"})
+                timeline.record(
+                    ProcessingStage.EXTRACTION,
+                    str(relative_path),
+                    ProcessingWarningCode.SYMBOLIC_LINK_SKIPPED,
+                    entries[-1]["message"],
+                    skipped=True,
+                )
+            elif file_path.is_file():
+                paths.append(str(relative_path))
+                binary_format = self.binary_file_detector.detect_file(file_path)
+                if binary_format:
+                    entries.append(
+                        self._binary_file_log_entry(
+                            str(relative_path), binary_format, ProcessingStage.EXTRACTION, timeline
+                        )
+                    )
+        entries.extend(
+            self._case_collision_log_entry(collision, ProcessingStage.EXTRACTION, timeline)
+            for collision in case_colliding_paths(paths)
+        )
+        return entries
 
-        try:
-            # Update status to processing
-            similarity_repo.update_status(similarity_record.id, SimilarityStatus.PROCESSING)
+    def upload_log_entries(self, files: List[ArchiveFile], timeline: ProcessingTimeline) -> List[Dict[str, str]]:
+        """
+        Entries of the processing log of an uploaded submission for its binary files, for its files holding invalid
+        byte sequences in their encoding and for its paths differing only by case, recorded in its timeline too as
+        warnings of the upload
+        """
+        entries = []
+        for file in files:
+            binary_format = self.binary_file_detector.detect(file.content)
+            if binary_format:
+                entries.append(self._binary_file_log_entry(file.path, binary_format, ProcessingStage.UPLOAD, timeline))
+                continue
+            decoded = self.encoding_detector.decode(file.content)
+            if decoded.replaced_count:
+                message = (
+                    f"Replaced {decoded.replaced_count} invalid byte sequences of {file.path} ({decoded.encoding}) "
+                    f"for the analysis"
+                )
+                entries.append({"level": "warning", "message": message})
+                timeline.record(
+                    ProcessingStage.UPLOAD,
+                    file.path,
+                    ProcessingWarningCode.ENCODING_REPLACED,
+                    message,
+                    encoding=decoded.encoding,
+                    replaced_count=decoded.replaced_count,
+                )
+        entries.extend(
+            self._case_collision_log_entry(collision, ProcessingStage.UPLOAD, timeline)
+            for collision in case_colliding_paths(file.path for file in files)
+        )
+        return entries
+
+    @staticmethod
+    def _binary_file_log_entry(
+        path: str, binary_format: str, stage: ProcessingStage, timeline: ProcessingTimeline
+    ) -> Dict[str, str]:
+        """Entry of the processing log of a submission for one of its binary files, recorded as skipped at the stage"""
+        message = f"Binary file {path} ({binary_format}) left out of the analysis"
+        timeline.record(
+            stage, path, ProcessingWarningCode.BINARY_FILE_SKIPPED, message, skipped=True, binary_format=binary_format
+        )
+        return {"level": "info", "message": message}
 
-            # Fetch both submissions
-            submission1_data = CreateSubmissionDto(
-                link=submission1.link,
-                project_uuid=submission1.project_uuid,
-                group_uuid=submission1.group_uuid,
-                project_step_uuid=submission1.project_step_uuid,
-                link_type=submission1.link_type,
-            )
+    @staticmethod
+    def _case_collision_log_entry(
+        paths: List[str], stage: ProcessingStage, timeline: ProcessingTimeline
+    ) -> Dict[str, str]:
+        """
+        Entry of the processing log of a submission for paths merged into one file on case-insensitive systems,
+        recorded as a warning of the stage
+        """
+        message = f"The paths {', '.join(paths)} differ only by case, one file on a case-insensitive file system"
+        timeline.record(stage, None, ProcessingWarningCode.CASE_COLLISION, message, paths=paths)
+        return {"level": "warning", "message": message}
 
-            submission2_data = CreateSubmissionDto(
-                link=submission2.link,
-                project_uuid=submission2.project_uuid,
-                group_uuid=submission2.group_uuid,
-                project_step_uuid=submission2.project_step_uuid,
-                link_type=submission2.link_type,
-            )
+    def _check_language_confidence(self, selection: GoPackagePreprocessingResult, repo_path: Path) -> Dict[str, Any]:
+        """
+        Detect the language of the selected files, and remove from the selection the files whose language is
+        detected with a confidence under the configured threshold.
+
+        Returns:
+            Summary of the detection: languages count, lowest confidence and flagged files
+        """
+        analyzed_files = []
+        languages: Dict[str, int] = {}
+        flagged_files = []
+        lowest_confidence = None
 
-            # Fetch repositories
-            repo1_path = None
-            repo2_path = None
+        for file_path in selection.files:
+            content = self._read_file_with_encoding_detection(file_path) if file_path.is_file() else None
+            if content is None:
+                analyzed_files.append(file_path)
+                continue
 
             try:
-                repo1_path = self.submission_fetcher.fetch_submission(submission1_data)
-                repo2_path = self.submission_fetcher.fetch_submission(submission2_data)
+                detection = self.tokenization_service.detect_language_with_confidence(
+                    file_path, content, self.language_confidence_threshold
+                )
+            except NotebookException:
+                # Invalid notebooks are reported when they are analyzed
+                analyzed_files.append(file_path)
+                continue
 
-                if not repo1_path.exists() or not repo2_path.exists():
-                    raise HTTPException(status_code=404, detail="Test projects not found")
+            if lowest_confidence is None or detection.confidence < lowest_confidence:
+                lowest_confidence = detection.confidence
+
+            if detection.low_confidence:
+                relative_path = str(file_path.relative_to(repo_path))
+                logger.warning(
+                    f"Not analyzing {relative_path}: language {detection.language} detected with confidence "
+                    f"{detection.confidence:.2f}, under {detection.threshold:.2f}"
+                )
+                flagged_files.append(
+                    {"file": relative_path, **detection.model_dump(exclude={"threshold", "low_confidence"})}
+                )
+                continue
 
-                # Tokenize all files
-                tokens1 = []
-                tokens2 = []
+            languages[detection.language] = languages.get(detection.language, 0) + 1
+            analyzed_files.append(file_path)
 
-                repo1_compatible_files = self.tokenization_service.extract_supported_files_from_directory(repo1_path)
-                repo2_compatible_files = self.tokenization_service.extract_supported_files_from_directory(repo2_path)
+        selection.files = analyzed_files
+        return {
+            "threshold": self.language_confidence_threshold,
+            "lowest_confidence": lowest_confidence,
+            "languages": languages,
+            "flagged_files": flagged_files,
+        }
 
-                for file_path in repo1_compatible_files:
-                    if not file_path.is_file():
-                        continue
-                    content = self._read_file_with_encoding_detection(file_path)
-                    if content is not None:
-                        tokens = self.tokenization_service.tokenize(content, file_path)
-                        tokens1.extend(tokens)
+    def _exclude_generated_files(
+        self, selection: GoPackagePreprocessingResult, repo_path: Path, submission: Submission
+    ) -> Dict[str, Any]:
+        """
+        Remove from the selection the files classified as generated or minified code, except those listed in the
+        force_include_files of the submission.
 
-                for file_path in repo2_compatible_files:
-                    if not file_path.is_file():
-                        continue
-                    content = self._read_file_with_encoding_detection(file_path)
-                    if content is not None:
-                        tokens = self.tokenization_service.tokenize(content, file_path)
-                        tokens2.extend(tokens)
+        Returns:
+            Report of the excluded and force-included files, with the heuristics that classified them
+        """
+        force_include_files = {path.strip("/") for path in submission.force_include_files or []}
+        compared_files = []
+        excluded_files = []
+        force_included_files = []
 
-                # Perform similarity analysis
-                similarity_result = self.similarity_service.compare_similarity(tokens1, tokens2)
+        for file_path in selection.files:
+            content = self._read_file_with_encoding_detection(file_path) if file_path.is_file() else None
+            if content is None:
+                compared_files.append(file_path)
+                continue
 
-                files_with_similarities_visualization = []
+            relative_path = str(file_path.relative_to(repo_path))
+            classification = self.generated_code_classifier.classify(file_path, content, relative_path)
+            if not classification.is_generated:
+                compared_files.append(file_path)
+            elif relative_path in force_include_files:
+                force_included_files.append(classification.to_dict())
+                compared_files.append(file_path)
+            else:
+                reasons = ", ".join(classification.reasons)
+                logger.info(f"Excluding {classification.kind} file {relative_path}: {reasons}")
+                excluded_files.append(classification.to_dict())
+
+        selection.files = compared_files
+        return {"excluded_files": excluded_files, "force_included_files": force_included_files}
+
+    def _compare_tokens(
+        self,
+        tokens1: List[Dict[str, Any]],
+        tokens2: List[Dict[str, Any]],
+        languages1: Dict[str, Any],
+        languages2: Dict[str, Any],
+        baseline_fingerprints: Collection[str],
+        snippet_fingerprints: Collection[str] = (),
+        options: Optional[DetectionOptionsDto] = None,
+    ) -> Dict[str, Any]:
+        """
+        Compare the token streams of two submissions with the given detection options (those of the service
+        configuration by default) without the starter code nor the fragments within an allowed snippet in both, or
+        through the abstract token categories when cross-language detection is enabled (by the configuration or the
+        options) and the main languages of the submissions differ
+        """
+        language1 = self._main_language(languages1)
+        language2 = self._main_language(languages2)
+        options = options or self._get_detection_options()
+        cross_language = self.cross_language_detection or options.cross_language
+        if cross_language and language1 and language2 and language1 != language2:
+            logger.info(f"Comparing {language1} and {language2} submissions across languages")
+            return self.similarity_service.compare_cross_language(tokens1, tokens2, language1, language2, options)
+        return self.similarity_service.compare_similarity_with_allowed_snippets(
+            tokens1, tokens2, baseline_fingerprints, snippet_fingerprints, options
+        )
 
-                for file_path in repo1_compatible_files:
-                    content1 = self._read_file_with_encoding_detection(file_path)
+    def _get_detection_options(self) -> DetectionOptionsDto:
+        """Get the options the token streams of the submissions are compared with"""
+        return DetectionOptionsDto(
+            min_fragment_tokens=self.min_fragment_tokens,
+            min_function_tokens=self.min_function_tokens,
+            file_aggregation=self.file_aggregation,
+            aggregate_file_scores=self.aggregate_file_scores,
+        )
 
-                    for file_path2 in repo2_compatible_files:
-                        content2 = self._read_file_with_encoding_detection(file_path2)
+    def _fragment_sources(self, similarity_result: Dict[str, Any], side: str, repo_path: Path) -> Dict[str, str]:
+        """
+        Get the content of the files of a submission holding matched fragments, by relative path, stored with the
+        comparison so that its report is rendered without fetching the submission again
+        """
+        sources = {}
+        for fragment in similarity_result.get("fragments", []):
+            relative_path = fragment[side].get("file")
+            if relative_path and relative_path not in sources:
+                content = self._read_file_with_encoding_detection(repo_path / relative_path)
+                if content is not None:
+                    sources[relative_path] = content
+        return sources
+
+    @staticmethod
+    def _unsupported_files(selection: GoPackagePreprocessingResult, repo_path: Path) -> List[str]:
+        """
+        Get the files of a submission no supported language was found for (binary files, images, data...), from
+        its selection before any file is removed from it
+        """
+        selected = {str(file_path.relative_to(repo_path)) for file_path in selection.files}
+        selected.update(skipped["file"] for skipped in selection.skipped_files)
+        unsupported = []
+        for file_path in sorted(repo_path.rglob("*")):
+            relative_path = file_path.relative_to(repo_path)
+            if (
+                file_path.is_file()
+                and not file_path.is_symlink()
+                and ".git" not in relative_path.parts
+                and str(relative_path) not in selected
+            ):
+                unsupported.append(str(relative_path))
+        return unsupported
+
+    @staticmethod
+    def _excluded_files(
+        selection: GoPackagePreprocessingResult,
+        repo_path: Path,
+        unsupported: List[str],
+        languages: Dict[str, Any],
+        generated: Dict[str, Any],
+        run_excluded: Collection[str] = (),
+    ) -> Dict[str, Any]:
+        """
+        Files of a submission left out of the comparison by its preprocessing or the exclusions of the detection run,
+        with the reason, and those kept
+        """
+        excluded = [{"file": file, "reason": "binary file or unsupported language"} for file in unsupported]
+        excluded.extend(
+            {"file": skipped["file"], "reason": f"not part of the Go build: {skipped['reason']}"}
+            for skipped in selection.skipped_files
+        )
+        excluded.extend(
+            {"file": flagged["file"], "reason": "language detected with a low confidence"}
+            for flagged in languages["flagged_files"]
+        )
+        excluded.extend(
+            {"file": file["file"], "reason": f"{file['kind']} code: {', '.join(file['reasons'])}"}
+            for file in generated["excluded_files"]
+        )
+        excluded.extend({"file": file, "reason": "excluded by the detection run"} for file in run_excluded)
+        return {
+            "excluded": excluded,
+            "compared": [str(file_path.relative_to(repo_path)) for file_path in selection.files],
+        }
 
-                        if content1 is None or content2 is None:
-                            continue
+    @staticmethod
+    def _file_similarities(
+        similarity_result: Dict[str, Any], files1: Dict[str, Any], files2: Dict[str, Any]
+    ) -> Optional[Dict[str, Any]]:
+        """
+        Get the file breakdown of a comparison, listing the files excluded by the preprocessing of each submission
+        along with those the comparison left without tokens: no file goes silently missing
+        """
+        breakdown = similarity_result.get("file_similarities")
+        if breakdown is None:
+            return None
+        for side, scored, files in (("file1", "files1", files1), ("file2", "files2", files2)):
+            excluded = files["excluded"] + breakdown["excluded_files"][side]
+            listed = {file["file"] for file in excluded} | {file["file"] for file in breakdown[scored]}
+            excluded.extend(
+                {"file": file, "reason": "unreadable or without tokens"}
+                for file in files["compared"]
+                if file not in listed
+            )
+            breakdown["excluded_files"][side] = excluded
+        return breakdown
+
+    @staticmethod
+    def _file_hashes(files: List[Path], repo_path: Path) -> Dict[str, str]:
+        """Content hashes of the files of a submission by relative path"""
+        return {
+            str(file_path.relative_to(repo_path)): IncrementalReanalysis.file_hash(file_path)
+            for file_path in files
+            if file_path.is_file()
+        }
 
-                        react_flow_data = self.visualization_service.generate_react_flow_ast(
-                            content1, content2, file_path.name, file_path2.name, "elk"
-                        )
+    @staticmethod
+    def _get_previous_comparison(
+        submission1: Submission,
+        submission2: Submission,
+        submission_repo: SubmissionRepository,
+        similarity_repo: SubmissionSimilarityRepository,
+    ) -> Optional[SubmissionSimilarity]:
+        """
+        Get the latest completed comparison of a version of the group of the first submission with a version of the
+        group of the second one, in this order, recording the content hashes of its files; None if there is none
+        """
+        versions1 = submission_repo.get_versions(
+            submission1.project_uuid, submission1.group_uuid, submission1.project_step_uuid
+        )
+        versions2 = submission_repo.get_versions(
+            submission2.project_uuid, submission2.group_uuid, submission2.project_step_uuid
+        )
+        ids1 = {version.id for version in versions1}
+        ids2 = {version.id for version in versions2}
+        comparisons = [
+            similarity
+            for similarity in similarity_repo.get_between_submissions(list(ids1 | ids2))
+            if similarity.submission_id in ids1
+            and similarity.compared_submission_id in ids2
+            and similarity.status == SimilarityStatus.COMPLETED
+            and (similarity.similarity_details or {}).get("file_hashes")
+        ]
+        return max(comparisons, key=lambda similarity: similarity.updated_at or similarity.created_at, default=None)
 
-                        if react_flow_data.get("has_similarity", False):
-                            files_with_similarities_visualization.append(
-                                {
-                                    "file_pair": {
-                                        "file_from_submission1": f"{file_path.name}",
-                                        "file_from_submission2": f"{file_path2.name}",
-                                    },
-                                    "react_flow": react_flow_data,
-                                }
-                            )
-
-                # Sort by similarity
-                files_with_similarities_visualization.sort(
-                    key=lambda x: x["react_flow"].get("analysis_metadata", {}).get("average_similarity", 0.0),
-                    reverse=True,
-                )
-
-                # Prepare results
-                processing_time = time.time() - start_time
-
-                results = {
-                    "jaccard_similarity": similarity_result["jaccard_similarity"],
-                    "type_similarity": similarity_result["type_similarity"],
-                    "overall_similarity": similarity_result["overall_similarity"],
-                    "structural_similarity": similarity_result["structural_similarity"],
-                    "type_sequence_similarity": similarity_result["type_sequence_similarity"],
-                    "flow_similarity": similarity_result["flow_similarity"],
-                    "operation_similarity": similarity_result["operation_similarity"],
-                    "processing_time_seconds": processing_time,
-                    "similarity_details": {
-                        "algorithm": "ast_similarity",
-                        "common_elements": similarity_result["common_elements"],
-                        "total_unique_elements": similarity_result["total_unique_elements"],
-                        "length_ratio": similarity_result["length_ratio"],
-                        "length_penalty": similarity_result["length_penalty"],
-                        "tokens_count": {"submission1": len(tokens1), "submission2": len(tokens2)},
-                        "processed_tokens_count": {
-                            "submission1": similarity_result["tokens1_length"],
-                            "submission2": similarity_result["tokens2_length"],
-                        },
-                        "files_count": {
-                            "submission1": len(repo1_compatible_files),
-                            "submission2": len(repo2_compatible_files),
-                        },
-                    },
-                    "visualization_data": files_with_similarities_visualization,
-                }
+    def _visualize_file_pairs(
+        self,
+        repo1_files: List[Path],
+        repo2_files: List[Path],
+        repo1_path: Path,
+        repo2_path: Path,
+        file_hashes: Dict[str, Dict[str, str]],
+        previous: Optional[SubmissionSimilarity] = None,
+    ) -> List[Dict[str, Any]]:
+        """
+        Get the visualization of each pair of files of two submissions having similarities, most similar first.
+        With a previous comparison of the groups, a pair of files both unchanged since (by content hash) keeps its
+        visualization (or its lack of similarity), only the pairs with a changed or added file being compared.
+        """
+        previous_hashes = (previous.similarity_details or {}).get("file_hashes", {}) if previous else {}
+        reanalysis1 = IncrementalReanalysis(previous_hashes.get("submission1"), file_hashes["submission1"])
+        reanalysis2 = IncrementalReanalysis(previous_hashes.get("submission2"), file_hashes["submission2"])
+        previous_pairs = {
+            (pair["file_pair"].get("path_from_submission1"), pair["file_pair"].get("path_from_submission2")): pair
+            for pair in ((previous.visualization_data if previous else None) or [])
+        }
+        paths1 = {str(file_path.relative_to(repo1_path)): file_path for file_path in repo1_files}
+        paths2 = {str(file_path.relative_to(repo2_path)): file_path for file_path in repo2_files}
+        contents: Dict[Path, Optional[str]] = {}
+        compared_pairs = 0
+
+        def content(file_path: Path) -> Optional[str]:
+            if file_path not in contents:
+                contents[file_path] = self._read_file_with_encoding_detection(file_path)
+            return contents[file_path]
+
+        def visualize(pair: Tuple[str, str]) -> Optional[Dict[str, Any]]:
+            nonlocal compared_pairs
+            check_deadline()
+            file_path, file_path2 = paths1[pair[0]], paths2[pair[1]]
+            content1, content2 = content(file_path), content(file_path2)
+            if content1 is None or content2 is None:
+                return None
+            compared_pairs += 1
+            react_flow_data = self.visualization_service.generate_react_flow_ast(
+                content1, content2, file_path.name, file_path2.name, "elk"  # Always use ELK
+            )
+            if not react_flow_data.get("has_similarity", False):
+                return None
+            return {
+                "file_pair": {
+                    "file_from_submission1": f"{file_path.name}",
+                    "file_from_submission2": f"{file_path2.name}",
+                    "path_from_submission1": pair[0],
+                    "path_from_submission2": pair[1],
+                },
+                "react_flow": react_flow_data,
+            }
 
-                # Update the similarity record with results
-                similarity_repo.update_results(similarity_record.id, results)
+        visualizations = IncrementalReanalysis.merge(
+            [(path1, path2) for path1 in paths1 for path2 in paths2],
+            previous_pairs,
+            visualize,
+            lambda pair: reanalysis1.is_unchanged(pair[0]) and reanalysis2.is_unchanged(pair[1]),
+        )
+        if previous:
+            logger.info(
+                f"Reused the file pairs of comparison {previous.id} unchanged since: {compared_pairs} of "
+                f"{len(paths1) * len(paths2)} file pairs compared again"
+            )
 
-            finally:
-                # Clean up temporary directories
-                if repo1_path and repo1_path.exists():
-                    cleanup_temp_directory(repo1_path)
-                if repo2_path and repo2_path.exists():
-                    cleanup_temp_directory(repo2_path)
+        # Order the file pairs by the average similarity of their visualization, most similar first
+        visualizations.sort(
+            key=lambda x: x["react_flow"].get("analysis_metadata", {}).get("average_similarity", 0.0),
+            reverse=True,
+        )
+        return visualizations
 
-        except Exception as e:
-            # Update status to failed
-            similarity_repo.update_status(similarity_record.id, SimilarityStatus.FAILED, str(e))
-            logger.error(f"Failed to process comparison: {str(e)}")
-            raise
+    @staticmethod
+    def _main_language(language_detection: Dict[str, Any]) -> Optional[str]:
+        """Get the language of most of the analyzed files of a submission"""
+        languages = language_detection.get("languages") or {}
+        return max(languages, key=languages.get) if languages else None
 
-    def process_submission_similarities(self, submission: Submission) -> List[str]:
+    def _tokenize_file(
+        self,
+        content: str,
+        file_path: Path,
+        repo_path: Path,
+        language_fallbacks: List[Dict[str, Any]],
+        options: Optional[TokenizationOptionsDto] = None,
+        stripped_headers: Optional[List[Dict[str, Any]]] = None,
+        timed_out_files: Optional[List[Dict[str, Any]]] = None,
+        token_cache: Optional[TokenStreamCache] = None,
+    ) -> List[Dict[str, Any]]:
         """
-        Process similarity detection for a new submission against all other submissions
-        in the same project step.
+        Tokenize a file, recording the content based language fallback applied when its extension lies, the
+        license or file header stripped from it, and its elapsed time if it timed out (without tokens). Tokens are
+        tagged with the relative path of the file, so that the matches found in the token stream of a submission are
+        located in its files. With a token cache, the tokens of a content already tokenized with the same options
+        are reused, and those of a new content cached.
+        """
+        relative_path = str(file_path.relative_to(repo_path))
+        options = options or TokenizationOptionsDto()
+        result = None
+        if token_cache is not None:
+            content_hash = TokenStreamCache.content_hash(file_path.read_bytes())
+            language = self.tokenization_service.detect_file_language(file_path, content)
+            result = token_cache.get(content_hash, options, file_path, language)
+        if result is None:
+            result = self.tokenization_service.tokenize_with_details(content, file_path, options)
+            if token_cache is not None:
+                token_cache.put(content_hash, options, file_path, language, result)
+        if result.timed_out and timed_out_files is not None:
+            timed_out_files.append({"file": relative_path, "elapsed_seconds": result.elapsed_seconds})
+        if result.language_fallback:
+            language_fallbacks.append({"file": relative_path, **result.language_fallback.model_dump()})
+        if result.stripped_header and stripped_headers is not None:
+            stripped_headers.append({"file": relative_path, **result.stripped_header.model_dump()})
+        for token in result.tokens:
+            token["file"] = relative_path
+        return result.tokens
+
+    def _get_token_cache(self, session: Session, tenant_id: Optional[str] = None) -> Optional[TokenStreamCache]:
+        """
+        Get a token cache counting the tokenizations of a comparison, keyed apart for the tenant of the tokenized
+        submissions, None if the cache is disabled
+        """
+        if not self.token_cache_enabled:
+            return None
+        return TokenStreamCache(SubmissionTokenCacheRepository(session), tenant_id=tenant_id)
 
-        Returns:
-            List of similarity record IDs that were created
+    def _get_tokenization_options(
+        self, project_uuid: UUID, project_step_uuid: UUID, session: Session, timeout_seconds: Optional[float] = None
+    ) -> TokenizationOptionsDto:
         """
-        try:
-            # Get all other submissions in the same project step
-            other_submissions = self.submission_repository.get_by_project_step(
-                submission.project_uuid, submission.project_step_uuid
+        Get the tokenization options of a project step: its header configuration, or the configured default, with
+        the timeout of the tokenization of a file, if any
+        """
+        config = SubmissionHeaderConfigRepository(session).get_by_project_step(project_uuid, project_step_uuid)
+        if not config:
+            return TokenizationOptionsDto(
+                strip_headers=get_settings().strip_file_headers, timeout_seconds=timeout_seconds
             )
+        return TokenizationOptionsDto(
+            strip_headers=config.enabled,
+            header_patterns=config.patterns,
+            header_templates=config.templates or [],
+            timeout_seconds=timeout_seconds,
+        )
 
-            # Filter out the current submission and get only completed ones
-            other_submissions = [
-                s for s in other_submissions if s.id != submission.id and s.status.value == "completed"
-            ]
-
-            if not other_submissions:
-                logger.info(f"No other submissions found for comparison with submission {submission.id}")
-                return []
+    @staticmethod
+    def get_stage_timeouts(
+        tokenization_timeout_seconds: Optional[float] = None, comparison_timeout_seconds: Optional[float] = None
+    ) -> Dict[str, Optional[float]]:
+        """
+        Get the timeouts of the stages of a comparison, the given ones (those of a run) or the configured ones, 0
+        meaning no timeout (None)
+        """
+        settings = get_settings()
+        if tokenization_timeout_seconds is None:
+            tokenization_timeout_seconds = settings.tokenization_file_timeout_seconds
+        if comparison_timeout_seconds is None:
+            comparison_timeout_seconds = settings.comparison_pair_timeout_seconds
+        return {
+            "tokenization_file_seconds": tokenization_timeout_seconds or None,
+            "comparison_pair_seconds": comparison_timeout_seconds or None,
+        }
 
-            similarity_ids = []
+    def get_header_config(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """Get the header stripping configuration of a project step, the default one if it was never configured"""
+        config = SubmissionHeaderConfigRepository(self.session).get_by_project_step(project_uuid, project_step_uuid)
+        if not config:
+            return {"enabled": get_settings().strip_file_headers, "patterns": None, "templates": []}
+        return {"enabled": config.enabled, "patterns": config.patterns, "templates": config.templates or []}
+
+    def save_header_config(self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]) -> None:
+        """Save the header stripping configuration of a project step, applied to the comparisons run afterwards"""
+        SubmissionHeaderConfigRepository(self.session).save(project_uuid, project_step_uuid, config_data)
+
+    def get_file_filter_config(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """Get the files allowed in the uploads of a project step, every file if it was never configured"""
+        config = SubmissionFileFilterConfigRepository(self.session).get_by_project_step(project_uuid, project_step_uuid)
+        if not config:
+            return {"mode": FileFilterMode.LENIENT}
+        return {
+            "mode": config.mode,
+            "allowed_extensions": config.allowed_extensions or [],
+            "denied_extensions": config.denied_extensions or [],
+            "allowed_patterns": config.allowed_patterns or [],
+            "denied_patterns": config.denied_patterns or [],
+        }
 
-            # Compare against each other submission
-            for other_submission in other_submissions:
-                try:
-                    # Check if comparison already exists
-                    if self.similarity_repository.check_existing_comparison(submission.id, other_submission.id):
-                        logger.info(f"Comparison already exists between {submission.id} and {other_submission.id}")
-                        continue
+    def save_file_filter_config(self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]) -> None:
+        """Save the files allowed in the uploads of a project step, enforced on the uploads made afterwards"""
+        SubmissionFileFilterConfigRepository(self.session).save(project_uuid, project_step_uuid, config_data)
 
-                    # Create similarity record
-                    similarity_record = self.similarity_repository.create(
-                        {
-                            "submission_id": submission.id,
-                            "compared_submission_id": other_submission.id,
-                            "project_uuid": submission.project_uuid,
-                            "project_step_uuid": submission.project_step_uuid,
-                            "status": SimilarityStatus.PENDING,
-                        }
-                    )
+    def filter_upload_files(
+        self, files: List[ArchiveFile], project_uuid: UUID, project_step_uuid: UUID
+    ) -> Tuple[List[ArchiveFile], List[Dict[str, str]]]:
+        """
+        Enforce the file filter of a project step on the files of an upload: the allowed files, and the skipped
+        disallowed ones in lenient mode
 
-                    similarity_ids.append(str(similarity_record.id))
+        Raises:
+            ValidationException: If the upload holds disallowed files in strict mode, listing them, or holds no
+                allowed file
+        """
+        file_filter = FileFilter(**self.get_file_filter_config(project_uuid, project_step_uuid))
+        if not file_filter.enabled:
+            return files, []
+
+        allowed, disallowed = file_filter.filter(files)
+        if disallowed and file_filter.mode == FileFilterMode.STRICT:
+            raise ValidationException(
+                f"The upload holds {len(disallowed)} files not allowed for the project step",
+                details={"error_type": "disallowed_files", "disallowed_files": disallowed},
+            )
+        if not allowed:
+            raise ValidationException(
+                "The upload holds no file allowed for the project step",
+                details={"error_type": "no_allowed_files", "disallowed_files": disallowed},
+            )
+        if disallowed:
+            logger.info(f"Skipped {len(disallowed)} disallowed files of an upload to project step {project_step_uuid}")
+        return allowed, disallowed
+
+    def get_ignore_rules_config(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """Get the paths left out of the submissions of a project step, the built-in rules if it never set its own"""
+        repository = SubmissionIgnoreRulesConfigRepository(self.session)
+        config = repository.get_by_project_step(project_uuid, project_step_uuid)
+        patterns = config.patterns if config else None
+        return {
+            "patterns": list(DEFAULT_IGNORE_RULES) if patterns is None else patterns,
+            "honor_gitignore": config.honor_gitignore if config else False,
+            "default_patterns": patterns is None,
+        }
 
-                    # Process the comparison
-                    self._process_single_comparison(similarity_record, submission, other_submission)
+    def save_ignore_rules_config(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]
+    ) -> None:
+        """Save the ignore rules of a project step, applied to the submissions created afterwards"""
+        SubmissionIgnoreRulesConfigRepository(self.session).save(project_uuid, project_step_uuid, config_data)
 
-                except Exception as e:
-                    logger.error(
-                        f"Failed to process comparison between {submission.id} and {other_submission.id}: {str(e)}"
-                    )
-                    continue
+    def get_ignore_rules(self, project_uuid: UUID, project_step_uuid: UUID) -> IgnoreRules:
+        """Ignore rules of a project step, applied while its uploads and Git trees are extracted"""
+        return IgnoreRules(self.get_ignore_rules_config(project_uuid, project_step_uuid)["patterns"])
 
-            return similarity_ids
+    def ignore_upload_files(
+        self, files: List[ArchiveFile], project_uuid: UUID, project_step_uuid: UUID
+    ) -> Tuple[List[ArchiveFile], List[str]]:
+        """
+        Leave out of the files of an upload those matching the ignore rules of its project step, and those matching
+        its .gitignore files when the step honors them: the kept files, and the paths of the ignored ones
 
-        except Exception as e:
-            logger.error(f"Failed to process submission similarities: {str(e)}")
-            raise DatabaseException(f"Failed to process submission similarities: {str(e)}")
+        Raises:
+            ValidationException: If every file of the upload is ignored
+        """
+        config = self.get_ignore_rules_config(project_uuid, project_step_uuid)
+        ignore_rules = IgnoreRules(config["patterns"])
+        if config["honor_gitignore"]:
+            ignore_rules = ignore_rules.with_gitignores(files)
+        if not ignore_rules.enabled:
+            return files, []
+
+        kept, ignored = ignore_rules.filter(files)
+        if not kept:
+            raise ValidationException(
+                "Every file of the upload is ignored by the ignore rules of the project step",
+                details={"error_type": "all_files_ignored", "ignored_paths": ignored[:IGNORED_PATH_SAMPLE_SIZE]},
+            )
+        if ignored:
+            logger.info(f"Ignored {len(ignored)} files of an upload to project step {project_step_uuid}")
+        return kept, ignored
 
-    def _process_single_comparison(self, similarity_record, submission1: Submission, submission2: Submission) -> None:
-        """Process a single comparison between two submissions"""
-        start_time = time.time()
+    def scan_upload_files(self, files: List[ArchiveFile]) -> Dict[str, Any]:
+        """
+        Scan the files of an upload for malware with the configured scanner: the scan to record on the submission,
+        its verdict being "infected" (with the infected files and the signature each matched), "clean", or
+        "unscanned" (with the error) when the scanner is unavailable. The submission is to be quarantined when
+        infected, and when unscanned if the scan fails closed.
+        """
+        from app.shared.services import get_malware_scanner
 
+        scanner = get_malware_scanner()
         try:
-            # Update status to processing
-            self.similarity_repository.update_status(similarity_record.id, SimilarityStatus.PROCESSING)
+            scan = scanner.scan_files((file.path, file.content) for file in files).to_dict()
+        except ScannerUnavailable as e:
+            logger.warning(f"Failed to scan an upload for malware with {scanner.name}: {str(e)}")
+            scan = {"scanner": scanner.name, "verdict": "unscanned", "infected_files": [], "error": str(e)}
+        if scan["verdict"] == "infected":
+            logger.warning(f"Found {len(scan['infected_files'])} infected files in an upload: {scan['infected_files']}")
+        fails_closed = get_settings().malware_scan_failure_mode == "closed"
+        scan["quarantined"] = scan["verdict"] == "infected" or (scan["verdict"] == "unscanned" and fails_closed)
+        scan["scanned_at"] = get_paris_time().isoformat()
+        return scan
+
+    def release_quarantined_submission(self, submission_id: UUID) -> Submission:
+        """
+        Release a quarantined submission, wrongly flagged or cleaned, recording when on its scan: its files may be
+        downloaded again, and it is compared like the others
 
-            # Fetch both submissions
-            submission1_data = CreateSubmissionDto(
-                link=submission1.link,
-                project_uuid=submission1.project_uuid,
-                group_uuid=submission1.group_uuid,
-                project_step_uuid=submission1.project_step_uuid,
-                link_type=submission1.link_type,
+        Raises:
+            NotFoundException: If the submission doesn't exist
+            ConflictException: If the submission is not quarantined
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        status = SubmissionStatus(submission.status)
+        if status != SubmissionStatus.QUARANTINED:
+            message = f"Only a quarantined submission is released, submission {submission_id} is {status.value}"
+            raise ConflictException(
+                message,
+                details={"error_type": "submission_not_quarantined", "message": message, "status": status.value},
             )
 
-            submission2_data = CreateSubmissionDto(
-                link=submission2.link,
-                project_uuid=submission2.project_uuid,
-                group_uuid=submission2.group_uuid,
-                project_step_uuid=submission2.project_step_uuid,
-                link_type=submission2.link_type,
-            )
+        submission = self.submission_repository.patch(
+            submission_id,
+            {
+                "status": SubmissionStatus.COMPLETED,
+                "quarantined_at": None,
+                "malware_scan": {**(submission.malware_scan or {}), "released_at": get_paris_time().isoformat()},
+            },
+        )
+        logger.warning(f"Released quarantined submission {submission_id}")
+        self.process_submission_similarities_async(submission)
+        return submission
+
+    def get_upload_limits(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """Get the limits enforced on the uploads of a project step: its own, else the configured defaults"""
+        settings = get_settings()
+        config = SubmissionUploadLimitsConfigRepository(self.session).get_by_project_step(
+            project_uuid, project_step_uuid
+        )
+        overrides = {field: getattr(config, field) if config else None for field in UPLOAD_LIMIT_FIELDS}
+        defaults = {
+            "max_upload_bytes": settings.submission_upload_max_bytes,
+            "max_extracted_bytes": settings.submission_upload_max_extracted_bytes,
+            "max_file_count": settings.submission_upload_max_file_count,
+            "max_file_bytes": settings.submission_upload_max_file_bytes,
+        }
+        limits = {field: overrides[field] or defaults[field] for field in UPLOAD_LIMIT_FIELDS}
+        options = {field: getattr(config, field) if config else None for field in UPLOAD_OPTION_FIELDS}
+        option_defaults = {
+            "reject_binary_files": settings.submission_upload_reject_binary_files,
+            "strict_paths": settings.submission_upload_strict_paths,
+        }
+        limits.update(
+            {field: option_defaults[field] if options[field] is None else options[field] for field in options}
+        )
+        return {**limits, "overrides": {**overrides, **options}}
 
-            # Fetch repositories
-            repo1_path = None
-            repo2_path = None
+    def save_upload_limits(self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]) -> None:
+        """Save the limits of the uploads of a project step, enforced on the uploads made afterwards"""
+        SubmissionUploadLimitsConfigRepository(self.session).save(project_uuid, project_step_uuid, config_data)
 
-            try:
-                repo1_path = self.submission_fetcher.fetch_submission(submission1_data)
-                repo2_path = self.submission_fetcher.fetch_submission(submission2_data)
+    def get_step_schedule(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """
+        Get the grading deadline of a project step, with the priority the analyses of its submissions get, and its
+        scheduled detection run
+        """
+        schedule = SubmissionStepScheduleRepository(self.session).get_by_project_step(project_uuid, project_step_uuid)
+        deadline = schedule.deadline if schedule else None
+        priority = schedule.priority if schedule else None
+        return {
+            "project_uuid": project_uuid,
+            "project_step_uuid": project_step_uuid,
+            "deadline": deadline,
+            "priority": priority,
+            "effective_priority": AnalysisPriorityPolicy.resolve(None, priority, deadline, get_paris_time()),
+            **{field: getattr(schedule, field) if schedule else None for field in AUTO_RUN_FIELDS},
+        }
 
-                if not repo1_path.exists() or not repo2_path.exists():
-                    raise HTTPException(status_code=404, detail="Test projects not found")
+    def save_step_schedule(
+        self, project_uuid: UUID, project_step_uuid: UUID, schedule_data: Dict[str, Any]
+    ) -> Dict[str, Any]:
+        """
+        Save the grading deadline of a project step, its queued analysis jobs being re-ordered by its priority, and
+        the time its detection run is started at: the run is scheduled again once its time or profile changes (see
+        AutoRunPolicy), and cancelled without time
 
-                # Tokenize all files
-                tokens1 = []
-                tokens2 = []
-                source1 = ""
-                source2 = ""
+        Raises:
+            ValidationException: If the analysis profile of the scheduled run does not exist
+        """
+        repository = SubmissionStepScheduleRepository(self.session)
+        current = repository.get_by_project_step(project_uuid, project_step_uuid)
+        schedule_data, rescheduled = AutoRunScheduler.schedule_fields(current, schedule_data)
+        if rescheduled:
+            self.get_analysis_profile_catalog().resolve(
+                schedule_data["auto_run_profile"], tenant_id=self.tenant_scope.step_tenant(project_step_uuid)
+            )
+        repository.save(project_uuid, project_step_uuid, schedule_data)
+        schedule = self.get_step_schedule(project_uuid, project_step_uuid)
+        count = self.analysis_pool.reprioritize(
+            (project_uuid, project_step_uuid), AnalysisPriorityPolicy.level(schedule["effective_priority"])
+        )
+        logger.info(f"Scheduled project step {project_step_uuid}: {schedule['effective_priority']}, {count} jobs")
+        return {**schedule, "reprioritized_job_count": count}
 
-                repo1_compatible_files = self.tokenization_service.extract_supported_files_from_directory(repo1_path)
-                repo2_compatible_files = self.tokenization_service.extract_supported_files_from_directory(repo2_path)
+    def cancel_scheduled_detection_run(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """
+        Cancel the detection run scheduled for a project step, a run already started being left running
 
-                for file_path in repo1_compatible_files:
-                    if not file_path.is_file():
-                        continue
+        Raises:
+            NotFoundException: If no detection run is scheduled for the step
+        """
+        self._get_auto_run_scheduler().cancel(project_uuid, project_step_uuid)
+        return self.get_step_schedule(project_uuid, project_step_uuid)
 
-                    # Read and tokenize the file with encoding detection
-                    content = self._read_file_with_encoding_detection(file_path)
-                    if content is not None:
-                        tokens = self.tokenization_service.tokenize(content, file_path)
-                        tokens1.extend(tokens)
-                        source1 += f"\n# === {file_path.name} ===\n" + content + "\n"
+    def start_scheduled_detection_runs(self) -> List[Dict[str, Any]]:
+        """
+        Start the detection runs of the project steps whose scheduled time has passed, those missed while the
+        service was down included, each by a single instance. Returns the outcome of each run started.
+        """
+        return self._get_auto_run_scheduler().start_due(get_paris_time())
+
+    def _get_auto_run_scheduler(self) -> AutoRunScheduler:
+        """Get the scheduler of the detection runs of the project steps, starting them as scheduled runs"""
+        return AutoRunScheduler(
+            SubmissionStepScheduleRepository(self.session),
+            self.submission_repository,
+            lambda project_uuid, project_step_uuid, submission_ids, profile: self.create_detection_run(
+                project_uuid, project_step_uuid, submission_ids, profile=profile, scheduled=True
+            ),
+        )
 
-                for file_path in repo2_compatible_files:
-                    if not file_path.is_file():
-                        continue
+    def get_webhook_registry(self) -> WebhookRegistry:
+        """Get the webhooks registered by the tenant of the caller, posted to by the sender of the notifier"""
+        return WebhookRegistry(
+            SubmissionWebhookRepository(self.session), self.webhook_notifier.sender, self.tenant_scope.tenant_id
+        )
 
-                    # Read and tokenize the file with encoding detection
-                    content = self._read_file_with_encoding_detection(file_path)
-                    if content is not None:
-                        tokens = self.tokenization_service.tokenize(content, file_path)
-                        tokens2.extend(tokens)
-                        source2 += f"\n# === {file_path.name} ===\n" + content + "\n"
+    def get_grading_callbacks(self) -> GradingCallbacks:
+        """
+        Get the grading callbacks of the project steps, posted to by the sender of the notifier from its threads,
+        with the similarity matrix of each run
+        """
+        return GradingCallbacks(
+            SubmissionGradingCallbackConfigRepository(self.session),
+            SubmissionDetectionRunRepository(self.session),
+            self.webhook_notifier.sender,
+            lambda run_id: self.get_similarity_matrix(run_id, limit=0),
+            lambda run_id: self.webhook_notifier.dispatcher.submit(self._deliver_grading_callback, run_id),
+        )
 
-                # Perform similarity analysis
-                similarity_result = self.similarity_service.compare_similarity(tokens1, tokens2)
+    def check_upload_limits(self, files: List[ArchiveFile], limits: Dict[str, Any]) -> None:
+        """
+        Enforce the limits of an upload on files extracted beforehand, such as a directory of a bulk upload
 
-                files_with_similarities_visualization = []
+        Raises:
+            ValidationException: If the files exceed a limit, with its structured error
+        """
+        try:
+            self._limited_extractor(limits).check_files(files)
+        except ArchiveLimitExceeded as e:
+            raise ValidationException(str(e), details=e.to_dict())
+        self._check_binary_files(files, limits)
 
-                for file_path in repo1_compatible_files:
-                    content1 = self._read_file_with_encoding_detection(file_path)
+    def _check_binary_files(self, files: List[ArchiveFile], limits: Dict[str, Any]) -> None:
+        """
+        Reject an upload holding a binary file when the binary files are rejected for its project step
 
-                    for file_path2 in repo2_compatible_files:
-                        content2 = self._read_file_with_encoding_detection(file_path2)
+        Raises:
+            ValidationException: With the first binary file and its format
+        """
+        if not limits["reject_binary_files"]:
+            return
+        for file in files:
+            binary_format = self.binary_file_detector.detect(file.content)
+            if binary_format:
+                raise ValidationException(
+                    f"The file {file.path} is binary ({binary_format}), binary files are rejected",
+                    details={
+                        "error_type": "binary_file_rejected",
+                        "message": f"The file {file.path} is binary ({binary_format})",
+                        "entry": file.path,
+                        "format": binary_format,
+                    },
+                )
 
-                        if content1 is None or content2 is None:
-                            continue
+    @staticmethod
+    def _limited_extractor(limits: Dict[str, Any], ignore_rules: Optional[IgnoreRules] = None) -> ArchiveExtractor:
+        """Extractor enforcing the limits of an upload, leaving out the entries matching the ignore rules if given"""
+        settings = get_settings()
+        return ArchiveExtractor(
+            limits["max_extracted_bytes"],
+            max_file_count=limits["max_file_count"],
+            max_file_bytes=limits["max_file_bytes"],
+            enforce_limits=True,
+            max_entries=settings.archive_max_entries,
+            max_compression_ratio=settings.archive_max_compression_ratio,
+            strict_paths=limits["strict_paths"],
+            ignored=ignore_rules.ignores if ignore_rules is not None and ignore_rules.enabled else None,
+        )
 
-                        react_flow_data = self.visualization_service.generate_react_flow_ast(
-                            content1, content2, file_path.name, file_path2.name, "elk"  # Always use ELK
-                        )
+    def get_detection_config(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """Get the flagging configuration of a project step, the configured defaults if it was never configured"""
+        config = SubmissionDetectionConfigRepository(self.session).get_by_project_step(project_uuid, project_step_uuid)
+        if not config:
+            settings = get_settings()
+            return {
+                "flag_threshold": settings.similarity_flag_threshold,
+                "min_token_count": settings.similarity_min_token_count,
+                "originality_feedback_enabled": False,
+            }
+        return {
+            "flag_threshold": config.flag_threshold,
+            "min_token_count": config.min_token_count,
+            "originality_feedback_enabled": config.originality_feedback_enabled,
+        }
 
-                        if react_flow_data.get("has_similarity", False):
-                            files_with_similarities_visualization.append(
-                                {
-                                    "file_pair": {
-                                        "file_from_submission1": f"{file_path.name}",
-                                        "file_from_submission2": f"{file_path2.name}",
-                                    },
-                                    "react_flow": react_flow_data,
-                                }
-                            )
-
-                # order the files pairs by the "analysis_metadata" -> "average_similarity" from the react_flow_data, most similar first
-                files_with_similarities_visualization.sort(
-                    key=lambda x: x["react_flow"].get("analysis_metadata", {}).get("average_similarity", 0.0),
-                    reverse=True,
-                )
-
-                # Calculate overall similarity score
-                # overall_similarity = self._calculate_overall_similarity(
-                #     similarity_result, shared_blocks_result
-                # )
-
-                # Prepare results
-                processing_time = time.time() - start_time
-
-                results = {
-                    "jaccard_similarity": similarity_result["jaccard_similarity"],
-                    "type_similarity": similarity_result["type_similarity"],
-                    "overall_similarity": similarity_result["overall_similarity"],
-                    "structural_similarity": similarity_result["structural_similarity"],
-                    "type_sequence_similarity": similarity_result["type_sequence_similarity"],
-                    "flow_similarity": similarity_result["flow_similarity"],
-                    "operation_similarity": similarity_result["operation_similarity"],
-                    # "shared_blocks_count": shared_blocks_result['total_shared_blocks'],
-                    # "average_shared_similarity": shared_blocks_result['average_similarity'],
-                    "processing_time_seconds": processing_time,
-                    "similarity_details": {
-                        "algorithm": "ast_similarity",
-                        "common_elements": similarity_result["common_elements"],
-                        "total_unique_elements": similarity_result["total_unique_elements"],
-                        "length_ratio": similarity_result["length_ratio"],
-                        "length_penalty": similarity_result["length_penalty"],
-                        "tokens_count": {"submission1": len(tokens1), "submission2": len(tokens2)},
-                        "processed_tokens_count": {
-                            "submission1": similarity_result["tokens1_length"],
-                            "submission2": similarity_result["tokens2_length"],
-                        },
-                        "files_count": {
-                            "submission1": len(repo1_compatible_files),
-                            "submission2": len(repo2_compatible_files),
-                        },
-                        "similarity_breakdown": {
-                            "jaccard_similarity": similarity_result["jaccard_similarity"],
-                            "structural_similarity": similarity_result["structural_similarity"],
-                            "type_sequence_similarity": similarity_result["type_sequence_similarity"],
-                            "flow_similarity": similarity_result["flow_similarity"],
-                            "operation_similarity": similarity_result["operation_similarity"],
-                            "type_similarity": similarity_result["type_similarity"],
-                        },
-                    },
-                    # "shared_blocks": {
-                    #     "blocks": shared_blocks_result['shared_blocks'],
-                    #     "functions_file1": shared_blocks_result['functions_file1'],
-                    #     "functions_file2": shared_blocks_result['functions_file2'],
-                    #     "shared_percentage": shared_blocks_result['shared_percentage']
-                    # },
-                    "visualization_data": files_with_similarities_visualization,
-                }
+    def save_detection_config(self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]) -> None:
+        """Save the flagging configuration of a project step, the defaults of the runs reading its results"""
+        SubmissionDetectionConfigRepository(self.session).save(project_uuid, project_step_uuid, config_data)
 
-                # Update the similarity record with results
-                self.similarity_repository.update_results(similarity_record.id, results)
+    def _get_matrix(
+        self,
+        run: SubmissionDetectionRun,
+        flagger: SimilarityFlagger,
+        merge_threshold: float = DEFAULT_MERGE_THRESHOLD,
+        include_same_team: Optional[bool] = None,
+    ) -> SimilarityMatrix:
+        """
+        Get the matrix of a run, with its teams and, unless overridden, its choice for the pairs of teammates, its
+        submissions deleted since the run marking their pairs
+        """
+        return SimilarityMatrix(
+            flagger,
+            merge_threshold,
+            teams={UUID(submission_id): team for submission_id, team in (run.teams or {}).items()},
+            include_same_team=run.include_same_team if include_same_team is None else include_same_team,
+            deleted_submission_ids=self.submission_repository.get_deleted_ids(
+                [UUID(submission_id) for submission_id in run.submission_ids]
+            ),
+        )
 
-                logger.info(
-                    f"Successfully processed comparison between {submission1.id} and {submission2.id} "
-                    f"with overall similarity: {similarity_result['overall_similarity']:.3f}"
-                )
+    def _get_flagger(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+    ) -> SimilarityFlagger:
+        """Get the flagger of a run: the configuration of its project step, overridden by the values of the run"""
+        config = self.get_detection_config(project_uuid, project_step_uuid)
+        return SimilarityFlagger(
+            flag_threshold=config["flag_threshold"] if flag_threshold is None else flag_threshold,
+            min_token_count=config["min_token_count"] if min_token_count is None else min_token_count,
+        )
 
-            finally:
-                # Clean up temporary directories
-                if repo1_path and repo1_path.exists():
-                    cleanup_temp_directory(repo1_path)
-                if repo2_path and repo2_path.exists():
-                    cleanup_temp_directory(repo2_path)
+    def _get_run_flagger(
+        self, run: SubmissionDetectionRun, flag_threshold: Optional[float] = None, min_token_count: Optional[int] = None
+    ) -> SimilarityFlagger:
+        """Get the flagger of a run, the flagging of its analysis profile overriding that of its project step"""
+        effective_options = run.effective_options or {}
+        return self._get_flagger(
+            run.project_uuid,
+            run.project_step_uuid,
+            effective_options.get("flag_threshold") if flag_threshold is None else flag_threshold,
+            effective_options.get("min_token_count") if min_token_count is None else min_token_count,
+        )
 
-        except Exception as e:
-            # Update status to failed
-            self.similarity_repository.update_status(similarity_record.id, SimilarityStatus.FAILED, str(e))
-            logger.error(f"Failed to process comparison: {str(e)}")
-            raise
+    def _record_language_detection(
+        self, submission: Submission, language_detection: Dict[str, Any], submission_repo: SubmissionRepository
+    ) -> None:
+        """
+        Persist the language detection summary on the submission, so that low confidence ones can be filtered, and
+        its main language so that the submissions can be filtered and sorted by language
+        """
+        try:
+            submission_repo.update(
+                submission.id,
+                SubmissionUpdateDto(
+                    language_confidence=language_detection["lowest_confidence"],
+                    language_detection=language_detection,
+                    language=self._main_language(language_detection),
+                ),
+            )
+        except (DatabaseException, NotFoundException) as e:
+            logger.warning(f"Failed to record the language detection of submission {submission.id}: {e}")
 
     def _detect_shared_blocks_multifile(
         self,
@@ -624,20 +4894,33 @@ class DetectionIntegrationService:
             if content is None:
                 continue
 
+            # Notebooks are analyzed through their code cells, in the language of their kernel
+            notebook = None
+            analysis_path = file_path
+            if self.tokenization_service.is_notebook(file_path):
+                try:
+                    notebook = self.tokenization_service.extract_notebook(content)
+                except NotebookException as e:
+                    logger.warning(f"Skipping notebook {file_path.relative_to(repo_path)}: {e.message}")
+                    continue
+                content = notebook.source
+                analysis_path = self.tokenization_service.get_notebook_analysis_path(file_path, notebook.language)
+
             # Detect language once
-            language = self.tokenization_service._detect_language(file_path)
+            language = self.tokenization_service.detect_file_language(analysis_path, content)
 
             # Extract functions once
-            functions = self.tokenization_service.extract_functions_with_positions(content, file_path)
+            functions = self.tokenization_service.extract_functions_with_positions(content, analysis_path)
 
             file_data = {
-                "path": file_path,
+                "path": analysis_path,
                 "name": file_path.name,
                 "relative_path": str(file_path.relative_to(repo_path)),
                 "content": content,
                 "language": language,
                 "functions": functions,
                 "function_count": len(functions),
+                "notebook": notebook,
             }
 
             # Group by language for smart pairing
@@ -699,23 +4982,19 @@ class DetectionIntegrationService:
                 block["file1_path"] = file1_data["relative_path"]
                 block["file2_path"] = file2_data["relative_path"]
                 block["language"] = file1_data["language"]
+                # Point back to the notebook cells holding the matched code
+                for prefix, file_data in (("file1", file1_data), ("file2", file2_data)):
+                    notebook = file_data.get("notebook")
+                    if notebook:
+                        cell = notebook.cell_for_line(block[f"{prefix}_start_line"])
+                        block[f"{prefix}_notebook_cell"] = cell.cell_index if cell else None
 
         return comparison_result
 
-    def _calculate_overall_similarity(self, similarity_result: dict, shared_blocks_result: dict) -> float:
-        """Calculate overall similarity score combining multiple metrics"""
-        jaccard_similarity = similarity_result.get("jaccard_similarity", 0.0)
-        type_similarity = similarity_result.get("type_similarity", 0.0)
-        shared_blocks_similarity = shared_blocks_result.get("average_similarity", 0.0)
-
-        # Weighted combination of similarity metrics
-        overall_similarity = jaccard_similarity * 0.4 + type_similarity * 0.3 + shared_blocks_similarity * 0.3
-
-        return round(overall_similarity, 4)
-
     def _read_file_with_encoding_detection(self, file_path) -> Optional[str]:
         """
-        Read a file with multiple encoding attempts to handle various file encodings.
+        Read a file as text in its detected encoding (byte order mark, UTF-16, UTF-8, Windows-1252 or ISO-8859-1),
+        its invalid byte sequences being replaced rather than failing the file.
 
         Args:
             file_path: Path to the file to read
@@ -723,42 +5002,42 @@ class DetectionIntegrationService:
         Returns:
             File content as string, or None if reading fails
         """
-        encodings_to_try = [
-            "utf-8",
-            "utf-8-sig",  # UTF-8 with BOM
-            "latin-1",
-            "cp1252",  # Windows-1252
-            "iso-8859-1",
-            "ascii",
-        ]
-
-        for encoding in encodings_to_try:
-            try:
-                with open(file_path, "r", encoding=encoding) as f:
-                    content = f.read()
-                logger.debug(f"Successfully read {file_path} with encoding: {encoding}")
-                return content
-            except UnicodeDecodeError:
-                continue
-            except Exception as e:
-                logger.warning(f"Failed to read {file_path} with encoding {encoding}: {e}")
-                continue
-
-        # If all encodings fail, try with error handling
         try:
-            with open(file_path, "r", encoding="utf-8", errors="replace") as f:
-                content = f.read()
-            logger.warning(f"Read {file_path} with UTF-8 and replaced invalid characters")
-            return content
+            with open(file_path, "rb") as f:
+                decoded = self.encoding_detector.decode(f.read())
         except Exception as e:
-            logger.error(f"Failed to read {file_path} with all encoding attempts: {e}")
+            logger.error(f"Failed to read {file_path}: {e}")
             return None
+        if decoded.replaced_count:
+            logger.warning(
+                f"Read {file_path} as {decoded.encoding}, replacing {decoded.replaced_count} invalid byte sequences"
+            )
+        else:
+            logger.debug(f"Read {file_path} as {decoded.encoding}")
+        return decoded.text
 
-    def get_submission_similarities(self, submission_id: UUID) -> List[dict]:
-        """Get all similarity results for a submission (bidirectional)"""
+    def get_submission_similarities(
+        self, submission_id: UUID, flag_threshold: Optional[float] = None, min_token_count: Optional[int] = None
+    ) -> Dict[str, Any]:
+        """
+        Get all similarity results for a submission (bidirectional), each one flagged with the configuration of
+        its project step, overridden by the given threshold and minimum token count
+        """
         try:
             similarities = self.similarity_repository.get_by_submission_id(submission_id)
 
+            if similarities:
+                flagger = self._get_flagger(
+                    similarities[0].project_uuid, similarities[0].project_step_uuid, flag_threshold, min_token_count
+                )
+            else:
+                settings = get_settings()
+                flagger = SimilarityFlagger(
+                    flag_threshold=settings.similarity_flag_threshold if flag_threshold is None else flag_threshold,
+                    min_token_count=settings.similarity_min_token_count if min_token_count is None else min_token_count,
+                )
+            too_short = flagger.too_short_submissions(similarities)
+
             results = []
             for similarity in similarities:
                 # Check if we need to swap the IDs (when the requested submission is in compared_submission_id)
@@ -785,21 +5064,52 @@ class DetectionIntegrationService:
                     "flow_similarity": similarity.flow_similarity,
                     "operation_similarity": similarity.operation_similarity,
                     "type_similarity": similarity.type_similarity,
+                    "max_function_similarity": similarity.max_function_similarity,
                     "status": similarity.status,
                     "created_at": similarity.created_at,
                     "processing_time_seconds": similarity.processing_time_seconds,
                     "error_message": similarity.error_message,
                     # Add a flag to indicate if this was a swapped result for debugging
                     "is_swapped": similarity.compared_submission_id == submission_id,
+                    **flagger.flag(similarity, too_short),
                 }
 
                 results.append(result)
 
-            return results
+            return {
+                "similarities": results,
+                "flag_threshold": flagger.flag_threshold,
+                "min_token_count": flagger.min_token_count,
+                "flagged_count": len([result for result in results if result["suspicious"]]),
+                "too_short_submissions": sorted(too_short, key=str),
+            }
 
         except Exception as e:
             raise DatabaseException(f"Failed to get submission similarities: {str(e)}")
 
+    def get_originality_feedback(self, submission_id: UUID) -> Dict[str, Any]:
+        """
+        Get the anonymized originality feedback of a submission (see OriginalityFeedback), if its project step
+        shows it to the students
+
+        Raises:
+            NotFoundException: If the submission does not exist
+            ConflictException: If the originality feedback is disabled for the project step
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        config = self.get_detection_config(submission.project_uuid, submission.project_step_uuid)
+        if not config["originality_feedback_enabled"]:
+            message = f"The originality feedback is disabled for project step {submission.project_step_uuid}"
+            raise ConflictException(
+                message, details={"error_type": "originality_feedback_disabled", "message": message}
+            )
+        similarities = self.similarity_repository.get_by_project_step(
+            submission.project_uuid, submission.project_step_uuid
+        )
+        return OriginalityFeedback().build(submission_id, similarities)
+
     def get_detailed_comparison(self, similarity_id: UUID) -> dict:
         """Get detailed comparison results including visualization data"""
         try:
@@ -837,6 +5147,7 @@ class DetectionIntegrationService:
                     "type_sequence_similarity": similarity.type_sequence_similarity,
                     "flow_similarity": similarity.flow_similarity,
                     "operation_similarity": similarity.operation_similarity,
+                    "max_function_similarity": similarity.max_function_similarity,
                 },
                 "analysis_metadata": {
                     "detection_algorithm": similarity.detection_algorithm,
@@ -847,6 +5158,10 @@ class DetectionIntegrationService:
                     "processing_time_seconds": similarity.processing_time_seconds,
                     "error_message": similarity.error_message,
                 },
+                "matches": (similarity.similarity_details or {}).get("matches", []),
+                "fragments": (similarity.similarity_details or {}).get("fragments", []),
+                "file_similarities": (similarity.similarity_details or {}).get("file_similarities"),
+                "function_similarities": (similarity.similarity_details or {}).get("function_similarities"),
                 "detailed_results": {
                     "similarity_details": similarity.similarity_details,
                     "shared_blocks": similarity.shared_blocks,
@@ -857,13 +5172,38 @@ class DetectionIntegrationService:
         except Exception as e:
             raise DatabaseException(f"Failed to get detailed comparison: {str(e)}")
 
-    def get_project_step_statistics(self, project_uuid: UUID, project_step_uuid: UUID) -> dict:
-        """Get similarity statistics for a project step"""
+    def get_project_step_statistics(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+    ) -> dict:
+        """Get similarity statistics for a project step, with the suspicious pairs flagged for the given run"""
         try:
-            return self.similarity_repository.get_statistics(project_uuid, project_step_uuid)
+            flagger = self._get_flagger(project_uuid, project_step_uuid, flag_threshold, min_token_count)
+            similarities = self.similarity_repository.get_by_project_step(project_uuid, project_step_uuid)
+            return {
+                **self.similarity_repository.get_statistics(project_uuid, project_step_uuid),
+                **flagger.summarize(similarities),
+            }
         except Exception as e:
             raise DatabaseException(f"Failed to get project step statistics: {str(e)}")
 
+    def get_language_statistics(self, project_uuid: UUID, project_step_uuid: UUID) -> Dict[str, Any]:
+        """
+        Get the languages the latest submissions of a project step are written in, from their stored analysis: it
+        follows the submissions as they are analyzed
+        """
+        submissions = self.submission_repository.get_by_project_step(
+            project_uuid, project_step_uuid, latest_versions_only=True
+        )
+        return {
+            "project_uuid": project_uuid,
+            "project_step_uuid": project_step_uuid,
+            **LanguageStatistics().aggregate(submissions),
+        }
+
     def get_high_similarity_alerts(
         self, project_uuid: UUID, project_step_uuid: UUID, threshold: float = 0.7
     ) -> List[dict]:
@@ -903,3 +5243,23 @@ class DetectionIntegrationService:
 
         except Exception as e:
             raise DatabaseException(f"Failed to get high similarity alerts: {str(e)}")
+
+
+# Service of a comparison process, created for its first pair with its own database session
+_process_service: Optional[DetectionIntegrationService] = None
+
+
+def compare_run_pair_in_process(arguments: Tuple[Any, ...]) -> Dict[str, Any]:
+    """Compare a pair of a detection run in a comparison process, see DetectionIntegrationService.compare_run_pair"""
+    global _process_service
+
+    if _process_service is None:
+        from app.shared.database import get_session
+
+        _process_service = DetectionIntegrationService(
+            next(get_session()), analysis_pool=AnalysisWorkerPool(worker_count=1, name="comparison-process")
+        )
+    with pipeline_metrics.collect() as observations:
+        comparison = _process_service.compare_run_pair(*arguments)
+    comparison["metrics"] = observations
+    return comparison
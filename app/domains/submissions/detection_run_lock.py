@@ -0,0 +1,42 @@
+import os
+import socket
+from datetime import datetime, timedelta
+from uuid import UUID, uuid4
+
+from app.domains.submissions.submissions_models import DetectionRunStatus, SubmissionDetectionRun
+
+# Identity of this instance of the service in the locks of the detection runs it runs, new at every start
+INSTANCE_ID = f"{socket.gethostname()}-{os.getpid()}-{uuid4().hex[:8]}"
+
+
+class DetectionRunLock:
+    """
+    Lock of the detection runs, at most one running per project step and analysis profile across the instances of
+    the service. A running run holds the lock key of its step and profile (unique in the database), claimed by the
+    instance comparing its pairs, which renews its heartbeat while it lives: once its heartbeat is older than the
+    lease, the instance is deemed dead and the run stale, another instance taking it over to resume or fail it.
+    """
+
+    def __init__(self, lease_seconds: float, now: datetime):
+        self.lease_seconds = lease_seconds
+        self.now = now
+
+    @staticmethod
+    def key(project_uuid: UUID, project_step_uuid: UUID, profile: str) -> str:
+        """Lock key of the runs of a project step with an analysis profile"""
+        return f"{project_uuid}/{project_step_uuid}/{profile}"
+
+    def stale_before(self) -> datetime:
+        """Time before which the heartbeat of a run is too old for its instance to be alive"""
+        return self.now - timedelta(seconds=self.lease_seconds)
+
+    def is_stale(self, run: SubmissionDetectionRun) -> bool:
+        """Whether a running run was abandoned by its instance, never renewing a heartbeat or not within the lease"""
+        if run.status != DetectionRunStatus.RUNNING:
+            return False
+        if run.heartbeat_at is None:
+            return True
+        heartbeat_at, stale_before = run.heartbeat_at, self.stale_before()
+        if (heartbeat_at.tzinfo is None) != (stale_before.tzinfo is None):
+            heartbeat_at, stale_before = heartbeat_at.replace(tzinfo=None), stale_before.replace(tzinfo=None)
+        return heartbeat_at < stale_before
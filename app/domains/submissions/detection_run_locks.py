@@ -0,0 +1,96 @@
+import logging
+from datetime import datetime
+from typing import Any, Callable, Dict, Optional
+
+from app.domains.submissions.detection_run_lock import INSTANCE_ID, DetectionRunLock
+from app.domains.submissions.submissions_models import SubmissionDetectionRun
+from app.shared.exceptions import ConflictException
+from app.shared.log_context import log_context
+
+logger = logging.getLogger(__name__)
+
+
+class DetectionRunLocks:
+    """
+    Locks of the running detection runs held by the instances of the service (see DetectionRunLock): this instance
+    renews the heartbeat of its runs, and takes over the stale runs of the others to resume them with the given
+    function (run) or fail them, as configured (orphaned run action "resume" or "fail").
+    """
+
+    def __init__(
+        self,
+        run_repository: Any,
+        lease_seconds: float,
+        orphaned_run_action: str,
+        resume: Callable[[SubmissionDetectionRun], None],
+        instance_id: str = INSTANCE_ID,
+    ):
+        self.run_repository = run_repository
+        self.lease_seconds = lease_seconds
+        self.orphaned_run_action = orphaned_run_action
+        self.resume = resume
+        self.instance_id = instance_id
+
+    def get_running(self, lock_key: str, now: datetime) -> Optional[SubmissionDetectionRun]:
+        """Get the run holding the lock of a step and profile, a stale one being taken over first, resumed or failed"""
+        run = self.run_repository.get_by_lock_key(lock_key)
+        if run is not None and DetectionRunLock(self.lease_seconds, now).is_stale(run):
+            self.recover(run)
+            run = self.run_repository.get_by_lock_key(lock_key)
+        return run
+
+    @staticmethod
+    def attach(running: Optional[SubmissionDetectionRun], attach: bool) -> SubmissionDetectionRun:
+        """
+        Return the run of a step and profile already running to a new run of them (attach), refuse it else
+
+        Raises:
+            ConflictException: If not attaching, or the running run finished meanwhile
+        """
+        if running is not None and attach:
+            logger.info(f"Attached to detection run {running.id} of step {running.project_step_uuid}, still running")
+            return running
+        if running is None:
+            # Finished between the failed creation of the new run and the read of the running one
+            message = "A detection run of the project step with the profile was started meanwhile"
+        else:
+            message = f"Detection run {running.id} of the project step with the profile is still running"
+        raise ConflictException(
+            message,
+            details={
+                "error_type": "detection_run_in_progress",
+                "message": message,
+                "run_id": str(running.id) if running else None,
+            },
+        )
+
+    def maintain(self, now: datetime) -> Dict[str, int]:
+        """
+        Renew the heartbeat of the running runs of this instance, then take over the stale runs, whose instance
+        stopped renewing theirs (stopped or dead), to resume or fail them. Returns the number of runs renewed and
+        taken over.
+        """
+        renewed = self.run_repository.renew_heartbeats(self.instance_id)
+        stale_before = DetectionRunLock(self.lease_seconds, now).stale_before()
+        recovered = sum(self.recover(run) for run in self.run_repository.get_stale(stale_before))
+        return {"renewed": renewed, "recovered": recovered}
+
+    def recover(self, run: SubmissionDetectionRun) -> bool:
+        """
+        Take over a stale detection run and resume it or fail it, as configured: a rescored run is failed, the pairs
+        rescored before its instance stopped being unknown. Returns whether this instance took it over, rather than
+        another one meanwhile.
+        """
+        abandoned_by = run.claimed_by
+        if not self.run_repository.take_over(run.id, run.heartbeat_at, self.instance_id):
+            return False
+        with log_context(run_id=run.id):
+            if self.orphaned_run_action == "resume" and run.rescored_at is None:
+                try:
+                    self.resume(self.run_repository.get_by_id(run.id))
+                    return True
+                except Exception as e:
+                    logger.error(f"Failed to resume detection run {run.id}: {str(e)}")
+            self.run_repository.fail(run.id, f"Abandoned by instance {abandoned_by} and not resumed")
+            logger.warning(f"Failed detection run {run.id}, abandoned by instance {abandoned_by}")
+        return True
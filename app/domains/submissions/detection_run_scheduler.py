@@ -0,0 +1,30 @@
+import asyncio
+import logging
+from typing import Any, Dict, List
+
+logger = logging.getLogger(__name__)
+
+
+def start_scheduled_detection_runs() -> List[Dict[str, Any]]:
+    """Start the detection runs of the project steps whose scheduled time has passed, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).start_scheduled_detection_runs()
+    finally:
+        session.close()
+
+
+async def start_scheduled_detection_runs_periodically(interval_seconds: float) -> None:
+    """
+    Start the due scheduled detection runs in a thread at every interval, the first time at startup so that the runs
+    due while the service was down are started then, until cancelled at shutdown
+    """
+    while True:
+        try:
+            await asyncio.to_thread(start_scheduled_detection_runs)
+        except Exception as e:
+            logger.error(f"Failed to start the scheduled detection runs: {e}")
+        await asyncio.sleep(interval_seconds)
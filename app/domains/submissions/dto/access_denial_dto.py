@@ -0,0 +1,33 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict
+
+
+class AccessDenialDto(BaseModel):
+    """DTO for an access denied to a caller, from the audit log of the denials"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440070",
+                "subject": "student-42",
+                "role": "student",
+                "action": "get_submission_similarities",
+                "resource_type": "submission",
+                "resource_id": "550e8400-e29b-41d4-a716-446655440000",
+                "reason": "Students cannot access the pairwise results",
+                "created_at": "2024-01-16T09:00:00Z",
+            }
+        }
+    )
+
+    id: UUID
+    subject: str
+    role: str
+    action: str
+    resource_type: str
+    resource_id: Optional[str] = None
+    reason: str
+    created_at: datetime
@@ -0,0 +1,79 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+
+class CreateAllowedSnippetDto(BaseModel):
+    """DTO for code every student of a project step may copy, such as a helper published by the lecturers"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "language": "python",
+                "content": "def read_ints():\n    return [int(value) for value in input().split()]\n",
+                "description": "Input parsing helper given with the assignment",
+            }
+        }
+    )
+
+    language: str = Field(..., description="Language the snippet is tokenized with")
+    content: str = Field(..., description="Code of the snippet")
+    description: Optional[str] = Field(default=None, max_length=1000)
+
+    @field_validator("content")
+    @classmethod
+    def validate_content(cls, v: str) -> str:
+        if not v.strip():
+            raise ValueError("The snippet cannot be empty")
+        return v
+
+
+class UpdateAllowedSnippetDto(BaseModel):
+    """DTO for changing an allowed snippet, the snippet being fingerprinted again when its code or language changes"""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"description": "Input parsing and timing helpers"}})
+
+    language: Optional[str] = Field(default=None, description="Language the snippet is tokenized with")
+    content: Optional[str] = Field(default=None, description="Code of the snippet")
+    description: Optional[str] = Field(default=None, max_length=1000)
+
+    @field_validator("content")
+    @classmethod
+    def validate_content(cls, v: Optional[str]) -> Optional[str]:
+        if v is not None and not v.strip():
+            raise ValueError("The snippet cannot be empty")
+        return v
+
+
+class AllowedSnippetResponseDto(BaseModel):
+    """DTO for reading an allowed snippet of a project step, without its fingerprints"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440012",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "language": "python",
+                "content": "def read_ints():\n    return [int(value) for value in input().split()]\n",
+                "description": "Input parsing helper given with the assignment",
+                "token_count": 24,
+                "fingerprint_count": 13,
+                "created_at": "2024-01-10T09:00:00Z",
+                "updated_at": "2024-01-10T09:00:00Z",
+            }
+        }
+    )
+
+    id: UUID
+    project_uuid: UUID
+    project_step_uuid: UUID
+    language: str
+    content: str
+    description: Optional[str]
+    token_count: int
+    fingerprint_count: int
+    created_at: datetime
+    updated_at: datetime
@@ -0,0 +1,100 @@
+from datetime import datetime
+from typing import Any, Dict, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+
+
+class CreateAnalysisProfileDto(BaseModel):
+    """DTO for a named bundle of detection options, referenced by name by the detection runs"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "name": "renames-and-reorders",
+                "description": "Identifiers and literals normalized, greedy string tiling",
+                "detection_options": {
+                    "normalize_identifiers": True,
+                    "normalize_literals": True,
+                    "ignore_comments": True,
+                    "metric": "greedy_string_tiling",
+                    "fingerprint_kgram_size": 7,
+                    "fingerprint_window_size": 4,
+                },
+                "flag_threshold": 0.6,
+                "min_token_count": 100,
+            }
+        }
+    )
+
+    name: str = Field(
+        ..., min_length=1, max_length=100, pattern=r"^[A-Za-z0-9][A-Za-z0-9._-]*$", description="Name of the profile"
+    )
+    description: Optional[str] = Field(default=None, max_length=1000)
+    detection_options: Dict[str, Any] = Field(
+        default_factory=dict, description="Detection options, those not given being those of the default profile"
+    )
+    flag_threshold: Optional[float] = Field(
+        default=None, ge=0.0, le=1.0, description="Similarity flagging a pair, that of the project step if None"
+    )
+    min_token_count: Optional[int] = Field(
+        default=None, ge=0, description="Compared tokens under which a submission is too short, the step's if None"
+    )
+
+
+class UpdateAnalysisProfileDto(BaseModel):
+    """DTO for replacing the options of an analysis profile, the runs already started keeping theirs"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "description": "Identifiers normalized, k-gram size 9",
+                "detection_options": {"normalize_identifiers": True, "fingerprint_kgram_size": 9},
+                "flag_threshold": 0.65,
+                "min_token_count": None,
+            }
+        }
+    )
+
+    description: Optional[str] = Field(default=None, max_length=1000)
+    detection_options: Dict[str, Any] = Field(
+        default_factory=dict, description="Detection options, those not given being those of the default profile"
+    )
+    flag_threshold: Optional[float] = Field(
+        default=None, ge=0.0, le=1.0, description="Similarity flagging a pair, that of the project step if None"
+    )
+    min_token_count: Optional[int] = Field(
+        default=None, ge=0, description="Compared tokens under which a submission is too short, the step's if None"
+    )
+
+
+class AnalysisProfileResponseDto(BaseModel):
+    """DTO for reading an analysis profile with its full detection options"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440030",
+                "name": "renames-and-reorders",
+                "description": "Identifiers and literals normalized, greedy string tiling",
+                "detection_options": {"normalize_identifiers": True, "metric": "greedy_string_tiling"},
+                "flag_threshold": 0.6,
+                "min_token_count": 100,
+                "built_in": False,
+                "created_at": "2024-01-10T09:00:00Z",
+                "updated_at": "2024-01-12T14:30:00Z",
+            }
+        }
+    )
+
+    id: Optional[UUID] = Field(default=None, description="ID of the profile, None for the built-in default profile")
+    name: str
+    description: Optional[str] = None
+    detection_options: DetectionOptionsDto
+    flag_threshold: Optional[float] = None
+    min_token_count: Optional[int] = None
+    built_in: bool = Field(default=False, description="Whether the profile is the built-in default, not editable")
+    created_at: Optional[datetime] = None
+    updated_at: Optional[datetime] = None
@@ -0,0 +1,40 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict
+
+from app.domains.submissions.submissions_models import LinkType
+
+
+class BaselineResponseDto(BaseModel):
+    """DTO for reading the starter code (baseline) of a project step, without its fingerprints"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440010",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "link": "s3://assignments/project-step-1/starter.zip",
+                "link_type": "s3",
+                "description": "Starter template provided with the assignment",
+                "file_count": 4,
+                "token_count": 2350,
+                "fingerprint_count": 2180,
+                "created_at": "2024-01-10T09:00:00Z",
+            }
+        },
+    )
+
+    id: UUID
+    project_uuid: UUID
+    project_step_uuid: UUID
+    link: str
+    link_type: Optional[LinkType]
+    description: Optional[str]
+    file_count: int
+    token_count: int
+    fingerprint_count: int
+    created_at: datetime
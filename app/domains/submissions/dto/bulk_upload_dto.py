@@ -0,0 +1,74 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+
+class BulkUploadEntryResultDto(BaseModel):
+    """DTO for the result of a directory of a bulk upload: the created submission, or the error"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "directory": "doe_jane_21400123",
+                "student_id": "21400123",
+                "group_uuid": "6f1c29a4-1a7e-5b7a-9d36-4c1f2e8b9a10",
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "file_count": 12,
+                "error": None,
+            }
+        }
+    )
+
+    directory: str = Field(..., description="Top-level directory of the archive")
+    student_id: Optional[str] = Field(default=None, description="Student identifier read from the directory name")
+    group_uuid: Optional[UUID] = Field(default=None, description="Group of the submission, from the student identifier")
+    submission_id: Optional[UUID] = Field(default=None, description="Created submission, None on error")
+    file_count: int = Field(default=0, description="Number of files of the directory")
+    error: Optional[str] = Field(default=None, description="Reason no submission was created for the directory")
+
+
+class BulkUploadJobResponseDto(BaseModel):
+    """DTO for reading a bulk upload, polled until its submissions are all created"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440070",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "filename": "assignment-1-export.zip",
+                "directory_pattern": "{lastname}_{firstname}_{id}",
+                "status": "processing",
+                "entry_count": 200,
+                "created_count": 120,
+                "failed_count": 1,
+                "results": [],
+                "created_at": "2024-01-16T09:00:00Z",
+                "updated_at": "2024-01-16T09:01:30Z",
+                "processing_time_seconds": None,
+                "error_message": None,
+                "status_url": "/submissions/bulk-upload-jobs/550e8400-e29b-41d4-a716-446655440070",
+            }
+        },
+    )
+
+    id: UUID
+    project_uuid: UUID
+    project_step_uuid: UUID
+    filename: str
+    directory_pattern: str
+    status: SimilarityStatus
+    entry_count: int = Field(..., description="Number of directories of the archive, 0 until it is extracted")
+    created_count: int = Field(..., description="Number of submissions created so far")
+    failed_count: int = Field(..., description="Number of directories no submission was created for so far")
+    results: List[BulkUploadEntryResultDto] = Field(default=[], description="Result of each processed directory")
+    created_at: datetime
+    updated_at: Optional[datetime]
+    processing_time_seconds: Optional[float]
+    error_message: Optional[str]
+    status_url: str
@@ -0,0 +1,96 @@
+from typing import Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class FunctionMetricsDto(BaseModel):
+    """DTO for the metrics of a function of a file"""
+
+    name: Optional[str] = Field(default=None, description="Name of the function, None for unnamed ones")
+    start_line: int = Field(..., description="First line of the function (0-based)")
+    end_line: int = Field(..., description="Last line of the function (0-based)")
+    tokens: int = Field(..., description="Length of the function in lexical tokens")
+    complexity: Optional[int] = Field(
+        default=None, description="Cyclomatic complexity, None for the languages whose decision points are unknown"
+    )
+
+
+class SummaryCodeMetricsDto(BaseModel):
+    """DTO for the metrics shared by a file and a whole submission"""
+
+    physical_lines: int = Field(..., description="Number of lines")
+    blank_lines: int = Field(..., description="Number of blank lines")
+    comment_lines: int = Field(..., description="Number of lines holding nothing but comments")
+    code_lines: int = Field(..., description="Number of logical lines of code: neither blank nor comment only")
+    comment_ratio: float = Field(..., description="Share of the non-blank lines that are comment lines")
+    function_count: int = Field(..., description="Number of functions")
+    average_function_tokens: Optional[float] = Field(
+        default=None, description="Average length of the functions in lexical tokens"
+    )
+    cyclomatic_complexity: Optional[int] = Field(
+        default=None, description="Sum of the cyclomatic complexities of the functions"
+    )
+    max_complexity: Optional[int] = Field(default=None, description="Highest cyclomatic complexity of a function")
+
+
+class FileCodeMetricsDto(SummaryCodeMetricsDto):
+    """DTO for the metrics of a file of a submission"""
+
+    file: str = Field(..., description="Path of the file, relative to the submission")
+    language: Optional[str] = Field(default=None, description="Language the file was analyzed as")
+    functions: List[FunctionMetricsDto] = Field(default=[], description="Functions of the file")
+
+
+class SubmissionCodeMetricsDto(SummaryCodeMetricsDto):
+    """DTO for the metrics of a whole submission"""
+
+    file_count: int = Field(..., description="Number of analyzed files")
+    languages: Dict[str, int] = Field(default={}, description="Number of analyzed files per language")
+
+
+class CodeMetricsDto(BaseModel):
+    """DTO for the code metrics of a submission, per file and aggregated"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "submission_id": "123e4567-e89b-12d3-a456-426614174000",
+                "submission": {
+                    "file_count": 1,
+                    "languages": {"go": 1},
+                    "physical_lines": 115,
+                    "blank_lines": 18,
+                    "comment_lines": 13,
+                    "code_lines": 84,
+                    "comment_ratio": 0.134,
+                    "function_count": 4,
+                    "average_function_tokens": 88.5,
+                    "cyclomatic_complexity": 14,
+                    "max_complexity": 6,
+                },
+                "files": [
+                    {
+                        "file": "main.go",
+                        "language": "go",
+                        "physical_lines": 115,
+                        "blank_lines": 18,
+                        "comment_lines": 13,
+                        "code_lines": 84,
+                        "comment_ratio": 0.134,
+                        "function_count": 4,
+                        "average_function_tokens": 88.5,
+                        "cyclomatic_complexity": 14,
+                        "max_complexity": 6,
+                        "functions": [
+                            {"name": "main", "start_line": 55, "end_line": 114, "tokens": 152, "complexity": 6}
+                        ],
+                    }
+                ],
+            }
+        }
+    )
+
+    submission_id: UUID
+    submission: SubmissionCodeMetricsDto = Field(..., description="Metrics of the whole submission")
+    files: List[FileCodeMetricsDto] = Field(default=[], description="Metrics of each analyzed file")
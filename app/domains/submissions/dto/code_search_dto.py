@@ -0,0 +1,105 @@
+from enum import Enum
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.code_search import DEFAULT_SEARCH_MIN_TOKENS
+
+
+class CodeSearchMode(str, Enum):
+    """How the submissions are searched for a snippet"""
+
+    FINGERPRINT = "fingerprint"  # Tokenized and fingerprinted like the compared files, through the index
+    SUBSTRING = "substring"  # Plain substring of the raw content of the files, for the unsupported languages
+
+
+class CodeSearchDto(BaseModel):
+    """DTO for searching the submissions of a project step for a code snippet"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "project_uuid": "123e4567-e89b-12d3-a456-426614174000",
+                "project_step_uuid": "222e2222-2222-2222-2222-222222222222",
+                "code": "def retry(fn, attempts=3):\n    for attempt in range(attempts):\n        try:\n"
+                "            return fn()\n        except Exception:\n            time.sleep(2 ** attempt)\n",
+                "language": "python",
+            }
+        },
+    )
+
+    project_uuid: UUID = Field(..., description="UUID of the project of the searched submissions")
+    project_step_uuid: UUID = Field(..., description="UUID of the project step of the searched submissions")
+    code: str = Field(..., min_length=1, max_length=100_000, description="Code snippet to search for")
+    language: Optional[str] = Field(default=None, description="Language of the snippet, e.g. python or go")
+    mode: Optional[CodeSearchMode] = Field(
+        default=None, description="Search mode, by default fingerprint for the supported languages, else substring"
+    )
+    min_tokens: int = Field(
+        default=DEFAULT_SEARCH_MIN_TOKENS, ge=1, description="Minimum number of tokens of a fingerprint fragment"
+    )
+
+
+class CodeSearchFragmentDto(BaseModel):
+    """DTO for a fragment of the snippet found in a file of a submission"""
+
+    file_path: str = Field(..., description="Path of the file, relative to the submission")
+    start_line: int = Field(..., description="Line (0-based) of the fragment in the file")
+    end_line: int = Field(..., description="Line (0-based) the fragment ends on in the file")
+    tokens: Optional[int] = Field(default=None, description="Number of matching tokens, None for a substring")
+    snippet_start_line: int = Field(..., description="Line (0-based) of the fragment in the snippet")
+    snippet_end_line: int = Field(..., description="Line (0-based) the fragment ends on in the snippet")
+
+
+class CodeSearchMatchDto(BaseModel):
+    """DTO for a submission holding fragments of the snippet"""
+
+    submission_id: UUID
+    group_uuid: UUID
+    version: int
+    matched_tokens: int = Field(..., description="Number of tokens of its fragments, 0 for substrings")
+    fragments: List[CodeSearchFragmentDto] = Field(default_factory=list, description="Fragments, longest first")
+
+
+class CodeSearchResponseDto(BaseModel):
+    """DTO for the submissions found holding a code snippet"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "mode": "fingerprint",
+                "language": "python",
+                "searched_submission_count": 42,
+                "unindexed_submission_count": 0,
+                "matches": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "group_uuid": "987fcdeb-51a2-43d1-9f12-345678901234",
+                        "version": 2,
+                        "matched_tokens": 31,
+                        "fragments": [
+                            {
+                                "file_path": "utils/network.py",
+                                "start_line": 14,
+                                "end_line": 19,
+                                "tokens": 31,
+                                "snippet_start_line": 0,
+                                "snippet_end_line": 5,
+                            }
+                        ],
+                    }
+                ],
+            }
+        },
+    )
+
+    mode: CodeSearchMode = Field(..., description="Mode the submissions were searched with")
+    language: Optional[str] = Field(default=None, description="Language the snippet was tokenized as")
+    searched_submission_count: int = Field(..., description="Number of submissions of the step searched")
+    unindexed_submission_count: int = Field(
+        default=0, description="Number of submissions not searched by fingerprint, not analyzed since indexing began"
+    )
+    matches: List[CodeSearchMatchDto] = Field(default_factory=list, description="Submissions found, most matched first")
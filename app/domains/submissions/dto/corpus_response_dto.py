@@ -0,0 +1,71 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict
+
+from app.domains.submissions.submissions_models import CorpusKind, LinkType
+
+
+class CorpusResponseDto(BaseModel):
+    """DTO for reading a reference corpus, of archived submissions or an external reference set"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440030",
+                "label": "2023",
+                "description": "Submissions of the 2023 cohort",
+                "kind": "archive",
+                "license_note": None,
+                "item_count": 118,
+                "created_at": "2024-01-05T09:00:00Z",
+                "updated_at": None,
+            }
+        },
+    )
+
+    id: UUID
+    label: str
+    description: Optional[str]
+    kind: CorpusKind = CorpusKind.ARCHIVE
+    license_note: Optional[str] = None
+    item_count: int
+    created_at: datetime
+    updated_at: Optional[datetime] = None
+
+
+class CorpusItemResponseDto(BaseModel):
+    """DTO for reading an archived submission of a corpus, without its fingerprints"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440031",
+                "corpus_id": "550e8400-e29b-41d4-a716-446655440030",
+                "label": "2023/alice",
+                "link": "https://github.com/user/repo-2023",
+                "link_type": "github",
+                "path": None,
+                "gradable": False,
+                "file_count": 12,
+                "token_count": 8400,
+                "fingerprint_count": 7900,
+                "created_at": "2024-01-05T09:10:00Z",
+            }
+        },
+    )
+
+    id: UUID
+    corpus_id: UUID
+    label: str
+    link: str
+    link_type: Optional[LinkType]
+    path: Optional[str] = None
+    gradable: bool
+    file_count: int
+    token_count: int
+    fingerprint_count: int
+    created_at: datetime
@@ -0,0 +1,47 @@
+from typing import Optional
+
+from pydantic import BaseModel, ConfigDict, field_validator
+
+from app.domains.submissions.submissions_models import LinkType
+
+
+class CreateBaselineDto(BaseModel):
+    """DTO for attaching starter code (a baseline) to a project step"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "link": "s3://assignments/project-step-1/starter.zip",
+                "link_type": "s3",
+                "description": "Starter template provided with the assignment",
+            }
+        },
+    )
+
+    link: str
+    link_type: Optional[LinkType] = None
+    description: Optional[str] = None
+
+    @field_validator("link")
+    def validate_link(cls, v):
+        """Validate that the link is a proper URL or S3 path"""
+        if not v:
+            raise ValueError("Link cannot be empty")
+
+        v_lower = v.lower()
+        if v_lower.startswith("s3://"):
+            return v
+        elif "github.com" in v_lower or "gitlab.com" in v_lower:
+            if not v_lower.startswith("https://"):
+                raise ValueError("GitHub/GitLab links must start with https://")
+            return v
+        else:
+            raise ValueError("Link must be an S3 path (s3://) or a GitHub/GitLab URL")
+
+    @field_validator("description")
+    def validate_description(cls, v):
+        """Validate description length"""
+        if v is not None and len(v) > 1000:
+            raise ValueError("Description cannot exceed 1000 characters")
+        return v
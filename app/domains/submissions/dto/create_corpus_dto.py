@@ -0,0 +1,42 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.dto.create_baseline_dto import CreateBaselineDto
+
+
+class CreateCorpusDto(BaseModel):
+    """DTO for creating a reference corpus, an archived collection of past submissions"""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"label": "2023", "description": "Submissions of the 2023 cohort"}}
+    )
+
+    label: str = Field(min_length=1, max_length=255)
+    description: Optional[str] = Field(default=None, max_length=1000)
+
+
+class CreateCorpusItemDto(CreateBaselineDto):
+    """DTO for archiving a past submission in a corpus, the link being validated as for a baseline"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "label": "2023/alice",
+                "link": "https://github.com/user/repo-2023",
+                "link_type": "github",
+            }
+        },
+    )
+
+    label: str = Field(min_length=1, max_length=255)
+
+
+class StepCorporaDto(BaseModel):
+    """DTO for the corpora referenced by a project step, matched with its submissions by the detection runs"""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"corpus_ids": ["550e8400-e29b-41d4-a716-446655440030"]}})
+
+    corpus_ids: List[UUID] = Field(default_factory=list)
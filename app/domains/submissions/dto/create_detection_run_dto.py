@@ -0,0 +1,81 @@
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class CreateDetectionRunDto(BaseModel):
+    """DTO for starting a detection run comparing pairwise the submissions of a project step"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "submission_ids": [
+                    "550e8400-e29b-41d4-a716-446655440000",
+                    "550e8400-e29b-41d4-a716-446655440004",
+                    "550e8400-e29b-41d4-a716-446655440005",
+                ],
+                "include_corpus": True,
+                "include_references": False,
+                "teams": {
+                    "550e8400-e29b-41d4-a716-446655440000": "team-a",
+                    "550e8400-e29b-41d4-a716-446655440004": "team-a",
+                },
+                "include_same_team": False,
+                "include_all_versions": False,
+                "tokenization_timeout_seconds": 30.0,
+                "comparison_timeout_seconds": None,
+                "profile": "renames-and-reorders",
+                "overrides": {"fingerprint_kgram_size": 9, "flag_threshold": 0.5},
+                "exclude_paths": ["tests/", "data/**"],
+                "force_recompute": False,
+                "attach": True,
+            }
+        }
+    )
+
+    submission_ids: Optional[List[UUID]] = Field(
+        default=None, description="Submissions of the step to compare, all the submissions of the step if omitted"
+    )
+    include_corpus: bool = Field(
+        default=False, description="Whether each submission is also matched with the corpora referenced by the step"
+    )
+    include_references: bool = Field(
+        default=False, description="Whether each submission is also matched with the files of the reference sets"
+    )
+    teams: Dict[UUID, str] = Field(
+        default_factory=dict, description="Team of the submissions by ID, the others being their own team"
+    )
+    include_same_team: bool = Field(
+        default=False, description="Whether the pairs of teammates are flagged and clustered like the others"
+    )
+    include_all_versions: bool = Field(
+        default=False,
+        description="Whether every version of the submissions is compared when no submission is given, not the latest",
+    )
+    tokenization_timeout_seconds: Optional[float] = Field(
+        default=None,
+        ge=0,
+        description="Timeout of the tokenization of a file, the configured one if omitted, 0 for none",
+    )
+    comparison_timeout_seconds: Optional[float] = Field(
+        default=None, ge=0, description="Timeout of the comparison of a pair, the configured one if omitted, 0 for none"
+    )
+    profile: Optional[str] = Field(
+        default=None, description="Name of the analysis profile of the run, the built-in default profile if omitted"
+    )
+    overrides: Dict[str, Any] = Field(
+        default_factory=dict,
+        description="Detection options (and flag_threshold or min_token_count) of the profile overridden for the run",
+    )
+    exclude_paths: List[str] = Field(
+        default_factory=list,
+        description="Paths left out of the comparisons of the run in gitignore syntax, the submissions being untouched",
+    )
+    force_recompute: bool = Field(
+        default=False, description="Whether every pair is compared again, rather than served from the comparison cache"
+    )
+    attach: bool = Field(
+        default=True,
+        description="Whether the run of the step and profile already running is returned (attach), rather than a 409",
+    )
@@ -0,0 +1,41 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, SecretStr
+
+
+class CreateGitSubmissionDto(BaseModel):
+    """DTO for creating a submission from the tree of a Git repository at a ref"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "repository_url": "https://github.com/user/repository.git",
+                "ref": "v1.0.0",
+                "subdirectory": "backend",
+                "token": "ghp_xxxxxxxxxxxxxxxxxxxx",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440000",
+                "group_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440002",
+                "description": "Final submission for project step 1",
+                "ignore_patterns": ["node_modules", "vendor", "target", "*.min.js"],
+            }
+        }
+    )
+
+    repository_url: str = Field(..., description="HTTPS URL of the repository")
+    ref: Optional[str] = Field(default=None, description="Branch, tag or full commit SHA, the default branch if none")
+    subdirectory: Optional[str] = Field(default=None, description="Subdirectory ingested instead of the whole tree")
+    token: Optional[SecretStr] = Field(
+        default=None, description="Token granting read access to a private repository, used once and never stored"
+    )
+
+    project_uuid: UUID
+    group_uuid: UUID
+    project_step_uuid: UUID
+    description: Optional[str] = None
+    submitted_by_uuid: Optional[UUID] = None
+
+    ignore_patterns: Optional[List[str]] = Field(
+        default=None, description="File and directory names excluded from the tree, the configured ones if none"
+    )
@@ -5,7 +5,7 @@ from uuid import UUID
 from pydantic import BaseModel, ConfigDict, Field, field_validator
 
 from app.domains.submissions.dto.rule_dto import RuleDto
-from app.domains.submissions.submissions_models import LinkType
+from app.domains.submissions.submissions_models import AnalysisPriority, LinkType
 
 
 class CreateSubmissionDto(BaseModel):
@@ -37,6 +37,7 @@ class CreateSubmissionDto(BaseModel):
                     },
                 ],
                 "force_rules": False,
+                "force_include_files": ["static/js/app.min.js"],
             }
         },
     )
@@ -63,6 +64,16 @@ class CreateSubmissionDto(BaseModel):
         default=False, description="If True, submission is created even if validation rules fail"
     )
 
+    # Files compared even if they are classified as generated or minified code
+    force_include_files: Optional[List[str]] = Field(
+        default=None, description="Paths (relative to the submission root) of generated files to compare anyway"
+    )
+
+    # Priority of the analysis, derived from the deadline of the project step if None
+    analysis_priority: Optional[AnalysisPriority] = Field(
+        default=None, description="Priority of the analysis, by default that of the deadline of the project step"
+    )
+
     @field_validator("link")
     def validate_link(cls, v):
         """Validate that the link is a proper URL or S3 path"""
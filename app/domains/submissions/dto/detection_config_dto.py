@@ -0,0 +1,22 @@
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class DetectionConfigDto(BaseModel):
+    """DTO for the similarity flagging configuration of a project step, the defaults of its detection runs"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"flag_threshold": 0.4, "min_token_count": 200, "originality_feedback_enabled": True}
+        }
+    )
+
+    flag_threshold: float = Field(
+        default=0.7, ge=0.0, le=1.0, description="Overall similarity at or above which a pair is suspicious"
+    )
+    min_token_count: int = Field(
+        default=50, ge=0, description="Compared tokens under which a submission is listed as too short instead"
+    )
+    originality_feedback_enabled: bool = Field(
+        default=False,
+        description="Whether the students may read the anonymized originality feedback of their own submissions",
+    )
@@ -0,0 +1,448 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.similarity_matrix import PairSortField
+from app.domains.submissions.submissions_models import DetectionRunStatus, GradingCallbackStatus, SimilarityStatus
+
+
+class DetectionRunResponseDto(BaseModel):
+    """DTO for a detection run and its progress, its comparisons processed in the background"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440020",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "submission_count": 120,
+                "pair_count": 7140,
+                "scheduled_pair_count": 6900,
+                "cached_pair_count": 240,
+                "include_corpus": True,
+                "corpus_item_count": 118,
+                "include_references": True,
+                "reference_item_count": 240,
+                "scheduled": False,
+                "team_count": 40,
+                "include_same_team": False,
+                "timeouts": {"tokenization_file_seconds": 60.0, "comparison_pair_seconds": 600.0},
+                "analysis_profile": "renames-and-reorders",
+                "effective_options": {
+                    "detection_options": {"normalize_identifiers": True, "fingerprint_kgram_size": 9},
+                    "flag_threshold": 0.5,
+                    "min_token_count": 100,
+                    "overrides": {"fingerprint_kgram_size": 9, "flag_threshold": 0.5},
+                },
+                "exclude_paths": ["tests/"],
+                "fingerprint": {
+                    "tokenizers": {"go": "1/0.9.0/abi-14", "python": "1/0.9.0/abi-14"},
+                    "algorithms": {"ast_similarity": 1, "winnowing": 1, "greedy_string_tiling": 1},
+                    "options_hash": "5d41402abc4b2a76b9719d911017c592aa4e2c37f1a2d5d2b1f0c3e7a9b8c6d4",
+                    "python_version": "3.11.9",
+                },
+                "seed": 1804289383,
+                "status": "running",
+                "completed_pair_count": 3480,
+                "progress_percentage": 48.7,
+                "eta_seconds": 612.5,
+                "pairs_per_second": 5.9,
+                "token_cache_hits": 6120,
+                "token_cache_misses": 840,
+                "grading_callback_status": None,
+                "grading_callback_delivered_at": None,
+                "created_at": "2024-01-20T10:00:00Z",
+                "completed_at": None,
+                "cancelled_at": None,
+                "failed_at": None,
+                "error_message": None,
+                "claimed_by": "submissions-7f9c-12-3e4a5b6c",
+                "heartbeat_at": "2024-01-20T10:20:30Z",
+                "rescored_at": None,
+            }
+        },
+    )
+
+    id: UUID
+    project_uuid: UUID
+    project_step_uuid: UUID
+    submission_count: int
+    pair_count: int
+    scheduled_pair_count: int
+    cached_pair_count: int = Field(default=0, description="Pairs whose comparison was served from the comparison cache")
+    include_corpus: bool
+    corpus_item_count: int
+    include_references: bool = False
+    reference_item_count: int = Field(default=0, description="Files of the reference sets matched with")
+    scheduled: bool = Field(default=False, description="Whether the run was started by the schedule of its step")
+    team_count: int
+    include_same_team: bool
+    timeouts: Optional[Dict[str, Optional[float]]] = Field(
+        default=None, description="Stage timeouts in seconds the pairs of the run are compared with, None for none"
+    )
+    analysis_profile: Optional[str] = Field(default=None, description="Profile of the run, None for older runs")
+    effective_options: Optional[Dict[str, Any]] = Field(
+        default=None,
+        description="Detection options and flagging the run was started with: its profile, overridden by the run",
+    )
+    exclude_paths: Optional[List[str]] = Field(
+        default=None, description="Paths left out of the comparisons of the run (gitignore syntax), None for older runs"
+    )
+    fingerprint: Optional[Dict[str, Any]] = Field(
+        default=None,
+        description="Versions of the tokenizers and algorithms and options hash of the run, None for older runs",
+    )
+    seed: Optional[int] = Field(default=None, description="Seed of the pairs sampled by the verifications of the run")
+    status: DetectionRunStatus
+    completed_pair_count: int = Field(..., description="Pairs compared so far, those compared before the run included")
+    progress_percentage: float = Field(..., description="Percentage of the pairs of the run compared")
+    eta_seconds: Optional[float] = Field(
+        default=None, description="Time left at the recent throughput, None if unknown or cancelled"
+    )
+    pairs_per_second: Optional[float] = Field(default=None, description="Recent throughput of the run")
+    token_cache_hits: int = Field(default=0, description="Files of the compared pairs whose tokens were cached")
+    token_cache_misses: int = Field(default=0, description="Files of the compared pairs tokenized, then cached")
+    grading_callback_status: Optional[GradingCallbackStatus] = Field(
+        default=None, description="Delivery of the results to the grading callback of the step, None without one"
+    )
+    grading_callback_delivered_at: Optional[datetime] = None
+    created_at: datetime
+    completed_at: Optional[datetime] = None
+    cancelled_at: Optional[datetime] = None
+    failed_at: Optional[datetime] = None
+    error_message: Optional[str] = Field(default=None, description="Why the run failed, abandoned by its instance")
+    claimed_by: Optional[str] = Field(default=None, description="Instance of the service comparing the pairs")
+    heartbeat_at: Optional[datetime] = Field(default=None, description="When the instance last renewed its lock")
+    rescored_at: Optional[datetime] = Field(
+        default=None, description="When the pairs of the run were last compared again with the updated snippets"
+    )
+
+
+class MatrixPairDto(BaseModel):
+    """DTO for a pair of the similarity matrix of a detection run"""
+
+    similarity_id: UUID
+    submission_id: UUID
+    compared_submission_id: UUID
+    overall_similarity: float
+    max_function_similarity: Optional[float] = None
+    status: SimilarityStatus
+    suspicious: bool
+    too_short: bool
+    same_team: bool = False
+    deleted_submission: bool = False
+
+
+class CorpusMatchDto(BaseModel):
+    """DTO for the match of a submission of a detection run with an archived submission of a corpus"""
+
+    submission_id: UUID
+    corpus_item_id: UUID
+    corpus_label: str
+    overall_similarity: float
+    jaccard_similarity: float
+    shared_fingerprint_count: int
+    status: SimilarityStatus
+    suspicious: bool
+
+
+class ReferenceMatchDto(BaseModel):
+    """DTO for the match of a submission of a detection run with a file of an external reference set"""
+
+    submission_id: UUID
+    corpus_item_id: UUID
+    reference_set_id: UUID
+    reference_label: str
+    path: str
+    license_note: Optional[str]
+    overall_similarity: float = Field(..., description="Share of the fingerprints of the file found in the submission")
+    shared_fingerprint_count: int
+    status: SimilarityStatus
+    suspicious: bool
+
+
+class SubmissionMaxSimilarityDto(BaseModel):
+    """DTO for the highest similarity of a submission of a detection run, None until one of its pairs completes"""
+
+    submission_id: UUID
+    max_similarity: Optional[float]
+    most_similar_submission_id: Optional[UUID]
+
+
+class SimilarityClusterDto(BaseModel):
+    """DTO for a cluster of submissions sharing code, linked by the flagged pairs of a detection run"""
+
+    submission_ids: List[UUID]
+    size: int
+    min_similarity: float
+    avg_similarity: float
+    max_similarity: float
+    medoid_submission_id: UUID
+
+
+class SimilarityClustersDto(BaseModel):
+    """DTO for the clusters of a detection run, partial until the run is completed"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "run_id": "550e8400-e29b-41d4-a716-446655440020",
+                "status": "completed",
+                "partial": False,
+                "flag_threshold": 0.7,
+                "merge_threshold": 0.85,
+                "include_same_team": False,
+                "suppressed_pairs": 2,
+                "clusters": [
+                    {
+                        "submission_ids": [
+                            "550e8400-e29b-41d4-a716-446655440000",
+                            "550e8400-e29b-41d4-a716-446655440004",
+                            "550e8400-e29b-41d4-a716-446655440005",
+                        ],
+                        "size": 3,
+                        "min_similarity": 0.52,
+                        "avg_similarity": 0.74,
+                        "max_similarity": 0.91,
+                        "medoid_submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                    }
+                ],
+            }
+        }
+    )
+
+    run_id: UUID
+    status: DetectionRunStatus
+    partial: bool
+    flag_threshold: float
+    merge_threshold: float
+    include_same_team: bool
+    suppressed_pairs: int
+    clusters: List[SimilarityClusterDto]
+
+
+class ScoreBucketDto(BaseModel):
+    """DTO for a bucket of the score histogram of a detection run, from its minimum (included) to its maximum"""
+
+    min: float
+    max: float
+    count: int
+
+
+class CentralSubmissionDto(BaseModel):
+    """DTO for a submission of the flagged pairs of a detection run"""
+
+    submission_id: UUID
+    flagged_pair_count: int
+    max_similarity: float
+
+
+class DetectionRunSummaryDto(BaseModel):
+    """DTO for the score distribution of a detection run, partial while some of its pairs are not compared"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "run_id": "550e8400-e29b-41d4-a716-446655440020",
+                "status": "completed",
+                "partial": False,
+                "complete": True,
+                "pair_count": 190,
+                "completed_pairs": 188,
+                "failed_pairs": 2,
+                "timed_out_pairs": 0,
+                "pending_pairs": 0,
+                "cached_pairs": 171,
+                "flag_threshold": 0.7,
+                "min_token_count": 50,
+                "merge_threshold": 0.85,
+                "histogram": [
+                    {"min": 0.0, "max": 0.5, "count": 171},
+                    {"min": 0.5, "max": 1.0, "count": 17},
+                ],
+                "mean": 0.23,
+                "median": 0.19,
+                "p95": 0.61,
+                "flagged_pairs": 6,
+                "cluster_count": 2,
+                "central_submissions": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "flagged_pair_count": 3,
+                        "max_similarity": 0.91,
+                    }
+                ],
+                "computed_at": "2024-01-15T12:00:00+01:00",
+            }
+        }
+    )
+
+    run_id: UUID
+    status: DetectionRunStatus
+    partial: bool
+    complete: bool
+    pair_count: int
+    completed_pairs: int
+    failed_pairs: int
+    timed_out_pairs: int = 0
+    pending_pairs: int
+    cached_pairs: int = Field(default=0, description="Pairs whose comparison was served from the comparison cache")
+    flag_threshold: float
+    min_token_count: int
+    merge_threshold: float
+    histogram: List[ScoreBucketDto]
+    mean: Optional[float]
+    median: Optional[float]
+    p95: Optional[float]
+    flagged_pairs: int
+    cluster_count: int
+    central_submissions: List[CentralSubmissionDto]
+    computed_at: datetime
+
+
+class SimilarityMatrixDto(BaseModel):
+    """DTO for the sparse pairwise similarity matrix of a detection run, partial until the run is completed"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "run_id": "550e8400-e29b-41d4-a716-446655440020",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "created_at": "2024-01-20T10:00:00Z",
+                "status": "completed",
+                "partial": False,
+                "submission_ids": ["550e8400-e29b-41d4-a716-446655440000", "550e8400-e29b-41d4-a716-446655440004"],
+                "pair_count": 1,
+                "compared_pairs": 1,
+                "status_breakdown": {"completed": 1},
+                "flag_threshold": 0.7,
+                "min_token_count": 50,
+                "min_similarity": 0.3,
+                "flagged_only": False,
+                "sort_by": "overall_similarity",
+                "total_pairs": 1,
+                "skip": 0,
+                "limit": 100,
+                "pairs": [
+                    {
+                        "similarity_id": "550e8400-e29b-41d4-a716-446655440002",
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "compared_submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "overall_similarity": 0.85,
+                        "max_function_similarity": 0.97,
+                        "status": "completed",
+                        "suspicious": True,
+                        "too_short": False,
+                        "same_team": False,
+                        "deleted_submission": False,
+                    }
+                ],
+                "flagged_pairs": [
+                    {
+                        "similarity_id": "550e8400-e29b-41d4-a716-446655440002",
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "compared_submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "overall_similarity": 0.85,
+                        "max_function_similarity": 0.97,
+                        "status": "completed",
+                        "suspicious": True,
+                        "too_short": False,
+                        "same_team": False,
+                        "deleted_submission": False,
+                    }
+                ],
+                "include_same_team": False,
+                "same_team_pairs": 0,
+                "deleted_submission_ids": [],
+                "suppressed_pairs": 0,
+                "merge_threshold": 0.85,
+                "clusters": [
+                    {
+                        "submission_ids": [
+                            "550e8400-e29b-41d4-a716-446655440000",
+                            "550e8400-e29b-41d4-a716-446655440004",
+                        ],
+                        "size": 2,
+                        "min_similarity": 0.85,
+                        "avg_similarity": 0.85,
+                        "max_similarity": 0.85,
+                        "medoid_submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                    }
+                ],
+                "max_similarities": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "max_similarity": 0.85,
+                        "most_similar_submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                    },
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "max_similarity": 0.85,
+                        "most_similar_submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                    },
+                ],
+                "too_short_submissions": [],
+                "corpus_matches": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "corpus_item_id": "550e8400-e29b-41d4-a716-446655440031",
+                        "corpus_label": "2023/alice",
+                        "overall_similarity": 0.78,
+                        "jaccard_similarity": 0.64,
+                        "shared_fingerprint_count": 412,
+                        "status": "completed",
+                        "suspicious": True,
+                    }
+                ],
+                "reference_matches": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "corpus_item_id": "550e8400-e29b-41d4-a716-446655440041",
+                        "reference_set_id": "550e8400-e29b-41d4-a716-446655440040",
+                        "reference_label": "left-pad 1.3.0",
+                        "path": "left-pad/index.js",
+                        "license_note": "WTFPL",
+                        "overall_similarity": 0.92,
+                        "shared_fingerprint_count": 38,
+                        "status": "completed",
+                        "suspicious": True,
+                    }
+                ],
+            }
+        }
+    )
+
+    run_id: UUID
+    project_uuid: UUID
+    project_step_uuid: UUID
+    created_at: datetime
+    status: DetectionRunStatus
+    partial: bool
+    submission_ids: List[UUID]
+    pair_count: int
+    compared_pairs: int
+    status_breakdown: Dict[str, int]
+    flag_threshold: float
+    min_token_count: int
+    min_similarity: float
+    flagged_only: bool = False
+    sort_by: PairSortField = PairSortField.OVERALL_SIMILARITY
+    total_pairs: int
+    skip: int
+    limit: int
+    pairs: List[MatrixPairDto]
+    flagged_pairs: List[MatrixPairDto]
+    include_same_team: bool
+    same_team_pairs: int
+    deleted_submission_ids: List[UUID] = []
+    suppressed_pairs: int
+    merge_threshold: float
+    clusters: List[SimilarityClusterDto]
+    max_similarities: List[SubmissionMaxSimilarityDto]
+    too_short_submissions: List[UUID]
+    corpus_matches: List[CorpusMatchDto] = []
+    reference_matches: List[ReferenceMatchDto] = []
@@ -0,0 +1,121 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.run_fingerprint import DEFAULT_VERIFICATION_SAMPLE_SIZE
+
+# Pairs of a run a verification compares again, at most, each of them being compared in the request
+MAX_VERIFICATION_SAMPLE_SIZE = 100
+
+
+class VerifyDetectionRunDto(BaseModel):
+    """DTO for the verification of a finished detection run, a sample of its pairs being compared again"""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"sample_size": 10, "seed": None}})
+
+    sample_size: int = Field(
+        default=DEFAULT_VERIFICATION_SAMPLE_SIZE,
+        ge=1,
+        le=MAX_VERIFICATION_SAMPLE_SIZE,
+        description="Completed pairs of the run compared again, at most",
+    )
+    seed: Optional[int] = Field(
+        default=None, ge=0, description="Seed the pairs are sampled from, the one recorded on the run if omitted"
+    )
+
+
+class EnvironmentDifferenceDto(BaseModel):
+    """DTO for an entry of the fingerprint of a run changed since the run"""
+
+    field: str = Field(..., description="Entry of the fingerprint, e.g. tokenizers.go or options_hash")
+    recorded: Optional[Any] = None
+    current: Optional[Any] = None
+
+
+class PairVerificationDto(BaseModel):
+    """DTO for a pair of a detection run compared again by a verification"""
+
+    similarity_id: UUID
+    submission_id: UUID
+    compared_submission_id: UUID
+    status: str = Field(..., description="identical, diverged, or failed when the comparison itself failed")
+    stage: Optional[str] = Field(
+        default=None, description="First stage of the pipeline the results diverge in, None if identical"
+    )
+    fields: List[str] = Field(default_factory=list, description="Fields of the results that differ")
+    recorded_digest: Optional[str] = Field(default=None, description="SHA-256 of the recorded results")
+    current_digest: Optional[str] = Field(default=None, description="SHA-256 of the results compared again")
+    error: Optional[str] = None
+
+
+class DetectionRunVerificationDto(BaseModel):
+    """DTO for the verification of a detection run: whether a sample of its pairs yields the recorded results"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "run_id": "550e8400-e29b-41d4-a716-446655440020",
+                "seed": 1804289383,
+                "reproducible": False,
+                "stage": "tokenization",
+                "fingerprint": {
+                    "tokenizers": {"go": "1/0.9.0/abi-14"},
+                    "algorithms": {"ast_similarity": 1, "winnowing": 1},
+                    "options_hash": "5d41402abc4b2a76b9719d911017c592aa4e2c37f1a2d5d2b1f0c3e7a9b8c6d4",
+                    "python_version": "3.11.9",
+                },
+                "current_fingerprint": {
+                    "tokenizers": {"go": "1/0.9.0/abi-14"},
+                    "algorithms": {"ast_similarity": 1, "winnowing": 1},
+                    "options_hash": "5d41402abc4b2a76b9719d911017c592aa4e2c37f1a2d5d2b1f0c3e7a9b8c6d4",
+                    "python_version": "3.11.9",
+                },
+                "environment_differences": [],
+                "completed_pair_count": 7140,
+                "superseded_pair_count": 12,
+                "verified_pair_count": 10,
+                "identical_pair_count": 9,
+                "diverged_pair_count": 1,
+                "failed_pair_count": 0,
+                "pairs": [
+                    {
+                        "similarity_id": "550e8400-e29b-41d4-a716-446655440030",
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "compared_submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                        "status": "diverged",
+                        "stage": "tokenization",
+                        "fields": ["overall_similarity", "similarity_details.timed_out_files"],
+                        "recorded_digest": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+                        "current_digest": "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+                        "error": None,
+                    }
+                ],
+                "verified_at": "2024-01-21T09:00:00Z",
+            }
+        }
+    )
+
+    run_id: UUID
+    seed: int = Field(..., description="Seed the pairs were sampled from")
+    reproducible: bool = Field(..., description="Whether every pair compared again yields the recorded results")
+    stage: Optional[str] = Field(
+        default=None,
+        description="First stage the run diverges in: environment when its fingerprint changed, None if reproducible",
+    )
+    fingerprint: Optional[Dict[str, Any]] = Field(
+        default=None, description="Fingerprint recorded on the run, None for older runs"
+    )
+    current_fingerprint: Dict[str, Any] = Field(..., description="Fingerprint of the service now, with the run options")
+    environment_differences: List[EnvironmentDifferenceDto] = Field(default_factory=list)
+    completed_pair_count: int
+    superseded_pair_count: int = Field(
+        ..., description="Completed pairs whose comparison was replaced since the run, not sampled"
+    )
+    verified_pair_count: int
+    identical_pair_count: int
+    diverged_pair_count: int
+    failed_pair_count: int
+    pairs: List[PairVerificationDto]
+    verified_at: datetime
@@ -0,0 +1,117 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+from app.domains.detection.dto.token_match_dto import FragmentDto, TokenMatchDto
+
+
+class ExternalComparisonDto(BaseModel):
+    """DTO for comparing a submission with an external source: pasted code or a URL to fetch, exactly one of them"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "url": "https://gist.githubusercontent.com/user/0123abcd/raw/worker.go",
+                "label": "Gist shared on the course forum",
+                "store_as_evidence": True,
+            }
+        }
+    )
+
+    code: Optional[str] = Field(default=None, description="Pasted source to compare with the submission")
+    language: Optional[str] = Field(
+        default=None, description="Language of the source, required for pasted code (detected from the URL otherwise)"
+    )
+    url: Optional[str] = Field(default=None, description="HTTP(S) URL of a single source file to fetch")
+    label: Optional[str] = Field(default=None, description="Label of the source reported with the evidence")
+    store_as_evidence: bool = Field(default=False, description="Whether the result is attached to the submission")
+
+    @field_validator("label")
+    def validate_label(cls, v):
+        """Validate label length"""
+        if v is not None and len(v) > 255:
+            raise ValueError("Label cannot exceed 255 characters")
+        return v
+
+
+class ExternalComparisonResponseDto(BaseModel):
+    """DTO for the comparison of a submission with an external source"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "source_type": "url",
+                "source_url": "https://gist.githubusercontent.com/user/0123abcd/raw/worker.go",
+                "label": "Gist shared on the course forum",
+                "language": "go",
+                "overall_similarity": 0.82,
+                "similarity_metrics": {"jaccard_similarity": 0.74, "structural_similarity": 0.88},
+                "matches": [
+                    {
+                        "file1": {"file": "jobs/worker.go", "start_line": 40, "end_line": 55},
+                        "file2": {"file": "worker.go", "start_line": 3, "end_line": 18},
+                        "tokens": 96,
+                    }
+                ],
+                "fragments": [
+                    {
+                        "left": {"file": "jobs/worker.go", "start_line": 40, "end_line": 55},
+                        "right": {"file": "worker.go", "start_line": 3, "end_line": 18},
+                        "tokens": 96,
+                        "similarity": 1.0,
+                    }
+                ],
+                "evidence_id": "550e8400-e29b-41d4-a716-446655440030",
+            }
+        }
+    )
+
+    submission_id: UUID
+    source_type: str = Field(..., description="Type of the external source: url or text")
+    source_url: Optional[str] = None
+    label: Optional[str] = None
+    language: Optional[str] = Field(default=None, description="Language the source was tokenized as")
+    overall_similarity: float
+    similarity_metrics: Dict[str, Any] = Field(default_factory=dict, description="Scores of the comparison")
+    matches: List[TokenMatchDto] = Field(
+        default_factory=list, description="Fragments shared by the submission (file1) and the source (file2)"
+    )
+    fragments: List[FragmentDto] = Field(
+        default_factory=list, description="Shared fragments long enough to be highlighted, with their similarity"
+    )
+    evidence_id: Optional[UUID] = Field(default=None, description="ID of the stored evidence, if it was stored")
+
+
+class EvidenceResponseDto(BaseModel):
+    """DTO for reading an external source comparison attached to a submission"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440030",
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "source_type": "url",
+                "source_url": "https://gist.githubusercontent.com/user/0123abcd/raw/worker.go",
+                "label": "Gist shared on the course forum",
+                "language": "go",
+                "content": "package main\n\nfunc worker(jobs <-chan int) {\n...",
+                "overall_similarity": 0.82,
+                "similarity_details": {"similarity_metrics": {"jaccard_similarity": 0.74}, "matches": []},
+                "created_at": "2024-01-16T14:00:00Z",
+            }
+        }
+    )
+
+    id: UUID
+    submission_id: UUID
+    source_type: str
+    source_url: Optional[str]
+    label: Optional[str]
+    language: Optional[str]
+    content: str
+    overall_similarity: float
+    similarity_details: Optional[Dict[str, Any]]
+    created_at: datetime
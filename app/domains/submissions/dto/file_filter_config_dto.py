@@ -0,0 +1,46 @@
+from typing import List
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+from app.domains.submissions.file_filter import FileFilter, FileFilterMode
+
+
+class FileFilterConfigDto(BaseModel):
+    """DTO for the files allowed in the uploaded submissions of a project step"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "mode": "strict",
+                "allowed_extensions": [".c", ".h"],
+                "denied_extensions": [],
+                "allowed_patterns": ["Makefile"],
+                "denied_patterns": ["node_modules", "*.o"],
+            }
+        },
+    )
+
+    mode: FileFilterMode = Field(
+        default=FileFilterMode.LENIENT,
+        description="strict rejects the uploads holding disallowed files, lenient skips these files",
+    )
+    allowed_extensions: List[str] = Field(default_factory=list, description="Allowed extensions, all if none")
+    denied_extensions: List[str] = Field(default_factory=list, description="Extensions never allowed")
+    allowed_patterns: List[str] = Field(
+        default_factory=list, description="Glob patterns of the allowed files, matched like the denied ones"
+    )
+    denied_patterns: List[str] = Field(
+        default_factory=list, description="Glob patterns never allowed, one without a slash matching any directory too"
+    )
+
+    @field_validator("allowed_extensions", "denied_extensions")
+    def validate_extensions(cls, v):
+        """Normalize the extensions to lower case with their leading dot, without duplicates"""
+        extensions = [FileFilter.normalize_extension(extension) for extension in v if extension.strip(" .")]
+        return list(dict.fromkeys(extensions))
+
+    @field_validator("allowed_patterns", "denied_patterns")
+    def validate_patterns(cls, v):
+        """Drop the empty patterns and the duplicates"""
+        return list(dict.fromkeys(pattern.strip() for pattern in v if pattern.strip()))
@@ -0,0 +1,62 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from urllib.parse import urlparse
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+from app.domains.submissions.submissions_models import GradingCallbackStatus
+
+
+class GradingCallbackDto(BaseModel):
+    """DTO for the grading callback of a project step, the results of its completed runs being posted to it"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "url": "https://grading.example.com/assignments/42/plagiarism-results",
+                "secret": None,
+            }
+        }
+    )
+
+    url: str = Field(max_length=2048, description="URL the results of the completed runs are posted to")
+    secret: Optional[str] = Field(
+        default=None, min_length=16, max_length=255, description="Secret shared with the grading service, or generated"
+    )
+
+    @field_validator("url")
+    @classmethod
+    def validate_url(cls, v: str) -> str:
+        parsed = urlparse(v)
+        if parsed.scheme not in ("http", "https") or not parsed.hostname:
+            raise ValueError("The grading callback URL must be an http or https URL")
+        return v
+
+
+class GradingCallbackResponseDto(BaseModel):
+    """DTO for reading the grading callback of a project step, without its secret"""
+
+    project_uuid: UUID
+    project_step_uuid: UUID
+    url: str
+    created_at: datetime
+    updated_at: Optional[datetime]
+
+
+class SavedGradingCallbackResponseDto(GradingCallbackResponseDto):
+    """DTO for a saved grading callback, with the secret its deliveries are signed with, returned only when saved"""
+
+    secret: str = Field(..., description="Secret of the HMAC-SHA256 signatures of the deliveries")
+
+
+class GradingCallbackDeliveryDto(BaseModel):
+    """DTO for the delivery of the results of a detection run to the grading service, with each of its attempts"""
+
+    model_config = ConfigDict(use_enum_values=True)
+
+    run_id: UUID
+    status: Optional[GradingCallbackStatus] = Field(..., description="None if the step had no grading callback")
+    schema_version: int = Field(..., description="Version of the schema of the posted payload")
+    attempts: List[Dict[str, Any]] = Field(..., description="Status code or error of each attempt, oldest first")
+    delivered_at: Optional[datetime] = Field(default=None, description="When the results were answered with a 2xx")
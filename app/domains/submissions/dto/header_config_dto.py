@@ -0,0 +1,36 @@
+import re
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+
+class HeaderConfigDto(BaseModel):
+    """DTO for the license and file header stripping configuration of a project step"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "enabled": True,
+                "patterns": ["Permission is hereby granted", "^\\W*Course:\\s*Algorithms"],
+                "templates": ["Author: {name}\nDate: {date}\nCourse: Algorithms 101"],
+            }
+        }
+    )
+
+    enabled: bool = Field(default=True, description="If True, matching headers are stripped before tokenization")
+    patterns: Optional[List[str]] = Field(
+        default=None, description="Regexes searched in the initial comment of the files, known licenses if None"
+    )
+    templates: List[str] = Field(
+        default_factory=list, description="Header templates mandated for the step, {placeholders} match anything"
+    )
+
+    @field_validator("patterns")
+    def validate_patterns(cls, v):
+        """Validate that the patterns are valid regexes"""
+        for pattern in v or []:
+            try:
+                re.compile(pattern)
+            except re.error as e:
+                raise ValueError(f"Invalid header pattern {pattern!r}: {e}")
+        return v
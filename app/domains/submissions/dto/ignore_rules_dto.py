@@ -0,0 +1,38 @@
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+
+class IgnoreRulesConfigDto(BaseModel):
+    """DTO for the paths left out of the submissions of a project step, in gitignore syntax"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "patterns": ["node_modules/", "build/", "*.o", "!build/README.md"],
+                "honor_gitignore": True,
+            }
+        }
+    )
+
+    patterns: Optional[List[str]] = Field(
+        default=None, description="Ignore rules in gitignore syntax, negations included, the built-in ones if None"
+    )
+    honor_gitignore: bool = Field(
+        default=False, description="Whether the .gitignore files of a submission are honored besides the rules"
+    )
+
+    @field_validator("patterns")
+    def validate_patterns(cls, v):
+        """Drop the blank patterns and the duplicates, keeping their order, which decides the negations"""
+        if v is None:
+            return None
+        return list(dict.fromkeys(pattern.rstrip("\r\n") for pattern in v if pattern.strip()))
+
+
+class EffectiveIgnoreRulesDto(BaseModel):
+    """DTO for the ignore rules applied to the submissions of a project step, its own or the built-in ones"""
+
+    patterns: List[str] = Field(..., description="Ignore rules applied, in order")
+    honor_gitignore: bool
+    default_patterns: bool = Field(..., description="Whether the rules are the built-in ones, not set for the step")
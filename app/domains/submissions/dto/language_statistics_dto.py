@@ -0,0 +1,61 @@
+from typing import List
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class LanguageUsageDto(BaseModel):
+    """DTO for the use of a language in the submissions of a project step"""
+
+    language: str
+    submission_count: int = Field(..., description="Submissions it is the primary language of, by lines of code")
+    file_count: int = Field(..., description="Files of the submissions in the language")
+    code_lines: int = Field(..., description="Lines of code of these files")
+    code_line_share: float = Field(..., description="Share of the lines of code of every language")
+
+
+class LanguageStatisticsDto(BaseModel):
+    """DTO for the languages the latest submissions of a project step are written in"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "project_uuid": "123e4567-e89b-12d3-a456-426614174000",
+                "project_step_uuid": "222e2222-2222-2222-2222-222222222222",
+                "submission_count": 40,
+                "analyzed_submission_count": 38,
+                "plain_text_file_count": 12,
+                "plain_text_submission_count": 1,
+                "low_confidence_file_count": 3,
+                "low_confidence_submission_count": 2,
+                "languages": [
+                    {
+                        "language": "python",
+                        "submission_count": 19,
+                        "file_count": 114,
+                        "code_lines": 9120,
+                        "code_line_share": 0.5312,
+                    },
+                    {
+                        "language": "rust",
+                        "submission_count": 18,
+                        "file_count": 90,
+                        "code_lines": 8050,
+                        "code_line_share": 0.4688,
+                    },
+                ],
+            }
+        }
+    )
+
+    project_uuid: UUID
+    project_step_uuid: UUID
+    submission_count: int = Field(..., description="Latest versions of the submissions of the step")
+    analyzed_submission_count: int = Field(..., description="Those analyzed, the others having no statistics yet")
+    plain_text_file_count: int = Field(..., description="Files of no known language, compared as plain text")
+    plain_text_submission_count: int = Field(..., description="Submissions of no known primary language")
+    low_confidence_file_count: int = Field(
+        ..., description="Files left out of the analysis for a language detected with a low confidence"
+    )
+    low_confidence_submission_count: int = Field(..., description="Submissions with such files")
+    languages: List[LanguageUsageDto] = Field(..., description="Languages used, the most primary first")
@@ -0,0 +1,59 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class MatchedRegionDto(BaseModel):
+    """DTO for a region of a file of the submission matching another submission, the counterpart withheld"""
+
+    file: Optional[str] = Field(default=None, description="Path of the file, relative to the submission")
+    start_line: Optional[int] = None
+    start_column: Optional[int] = None
+    end_line: Optional[int] = None
+    end_column: Optional[int] = None
+    tokens: int = Field(..., description="Number of matching tokens of the region")
+    similarity: float = Field(..., description="Similarity of the region with the code it matches")
+
+
+class OriginalityFeedbackDto(BaseModel):
+    """
+    DTO for the anonymized originality feedback of a submission, shown to its student: nothing of the submissions
+    it matches (identity, files, positions or code) is part of it
+    """
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "max_similarity": 0.42,
+                "percentile": 87.5,
+                "compared_submissions": 48,
+                "matched_regions": [
+                    {
+                        "file": "worker.go",
+                        "start_line": 12,
+                        "start_column": 0,
+                        "end_line": 27,
+                        "end_column": 1,
+                        "tokens": 96,
+                        "similarity": 1.0,
+                    }
+                ],
+            }
+        }
+    )
+
+    submission_id: UUID
+    max_similarity: Optional[float] = Field(
+        default=None, description="Highest similarity of the submission, None before its first completed comparison"
+    )
+    percentile: Optional[float] = Field(
+        default=None,
+        description="Share in percent of the compared submissions of the project step whose highest similarity is"
+        " at or below that of the submission",
+    )
+    compared_submissions: int = Field(..., description="Number of compared submissions of the project step")
+    matched_regions: List[MatchedRegionDto] = Field(
+        default=[], description="Regions of the files of the submission matching another submission"
+    )
@@ -0,0 +1,65 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+# Tags of a submission: how many, and how long each
+MAX_TAGS = 20
+MAX_TAG_LENGTH = 50
+
+
+class PatchSubmissionDto(BaseModel):
+    """
+    DTO for a partial update of the mutable metadata of a submission, only the given fields being changed. The
+    other fields are kept as extra fields, so that the immutable ones can be listed in the rejection.
+    """
+
+    model_config = ConfigDict(
+        extra="allow",
+        json_schema_extra={
+            "example": {
+                "submitted_by_uuid": "550e8400-e29b-41d4-a716-446655440005",
+                "group_uuid": "550e8400-e29b-41d4-a716-446655440002",
+                "display_name": "Team Rocket - final",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "tags": ["late", "reviewed"],
+                "integrity_case_open": True,
+            }
+        },
+    )
+
+    submitted_by_uuid: Optional[UUID] = Field(default=None, description="Student who made the submission")
+    group_uuid: Optional[UUID] = Field(default=None, description="Team the submission belongs to")
+    display_name: Optional[str] = Field(default=None, max_length=200, description="Name the submission is shown with")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Description of the submission")
+    project_uuid: Optional[UUID] = Field(default=None, description="Project of the assignment")
+    project_step_uuid: Optional[UUID] = Field(default=None, description="Assignment (project step) submitted to")
+    tags: Optional[List[str]] = Field(default=None, description=f"Free-form tags, at most {MAX_TAGS}")
+    integrity_case_open: Optional[bool] = Field(
+        default=None, description="Whether an integrity case is open on the submission, exempting it from purging"
+    )
+
+    @field_validator("display_name")
+    def validate_display_name(cls, v):
+        """Strip the display name, an empty one being none"""
+        if v is None:
+            return v
+        return v.strip() or None
+
+    @field_validator("tags")
+    def validate_tags(cls, v):
+        """Strip the tags and drop the duplicates, in their order"""
+        if v is None:
+            return v
+        tags = list(dict.fromkeys(tag.strip() for tag in v if tag.strip()))
+        if len(tags) > MAX_TAGS:
+            raise ValueError(f"A submission has at most {MAX_TAGS} tags")
+        too_long = [tag for tag in tags if len(tag) > MAX_TAG_LENGTH]
+        if too_long:
+            raise ValueError(f"Tags are at most {MAX_TAG_LENGTH} characters long: {too_long}")
+        return tags
+
+    @field_validator("integrity_case_open")
+    def validate_integrity_case_open(cls, v):
+        """Close the integrity case of a submission set to None"""
+        return bool(v)
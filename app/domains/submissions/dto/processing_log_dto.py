@@ -0,0 +1,64 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import ProcessingOutcome, ProcessingStage, ProcessingWarningCode
+
+
+class ProcessingWarningDto(BaseModel):
+    """DTO for a warning of a stage of the processing log, its code being stable"""
+
+    code: ProcessingWarningCode = Field(..., description="Stable code of the warning, rendered by the frontend")
+    message: str = Field(..., description="Human-readable description of the warning")
+
+
+class ProcessingEventDto(BaseModel):
+    """DTO for a stage of the processing of a submission, or of one of its files"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440070",
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "stage": "language_detection",
+                "file_path": "src/Main.txt",
+                "started_at": "2024-01-15T10:30:02Z",
+                "ended_at": "2024-01-15T10:30:02Z",
+                "duration_ms": 0,
+                "outcome": "warning",
+                "warnings": [
+                    {"code": "language_fallback", "message": "Tokenized as java, 42.5% of the tokens unknown as python"}
+                ],
+                "details": {"language": "java", "detected_language": "python"},
+                "error_message": None,
+            }
+        }
+    )
+
+    id: UUID
+    submission_id: UUID
+    stage: ProcessingStage = Field(..., description="Stage of the processing")
+    file_path: Optional[str] = Field(default=None, description="Relative path of the file, None for the whole stage")
+    started_at: datetime
+    ended_at: datetime
+    duration_ms: int = Field(..., description="Duration of the stage in milliseconds")
+    outcome: ProcessingOutcome = Field(..., description="ok, warning, skipped (the file left out) or failed")
+    warnings: List[ProcessingWarningDto] = Field(default=[], description="Warnings of the stage")
+    details: Dict[str, Any] = Field(default={}, description="Facts of the stage (language, counts...)")
+    error_message: Optional[str] = Field(default=None, description="Why the stage failed, if it did")
+
+
+class ProcessingLogPageDto(BaseModel):
+    """DTO for a page of the processing log of a submission, in the order the stages started"""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"items": [], "total": 42, "skip": 0, "limit": 100, "has_more": False}}
+    )
+
+    items: List[ProcessingEventDto] = Field(default=[], description="Events of the page")
+    total: int = Field(..., description="Number of events matching the filters, over all the pages")
+    skip: int = Field(..., description="Number of events skipped before the page")
+    limit: int = Field(..., description="Maximum number of events of the page")
+    has_more: bool = Field(..., description="Whether events follow the page")
@@ -0,0 +1,73 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class SharedRareTokenDto(BaseModel):
+    """DTO for a rare identifier or string literal shared by a pair of submissions"""
+
+    kind: str = Field(..., description="Kind of the token: identifier or string (content of a string literal)")
+    text: str = Field(..., description="Text of the identifier or content of the string literal")
+    document_frequency: int = Field(..., description="Number of submissions of the run the token appears in")
+
+
+class RareTokenPairDto(BaseModel):
+    """DTO for a pair of submissions of a detection run sharing rare tokens"""
+
+    submission_id: UUID
+    compared_submission_id: UUID
+    similarity_id: Optional[UUID] = Field(default=None, description="Comparison of the pair, None if not compared")
+    overall_similarity: Optional[float] = Field(
+        default=None, description="Similarity of the pair, untouched by the evidence"
+    )
+    evidence_score: float = Field(
+        ..., description="Share (0-1) of the rarity of the rare tokens of both submissions carried by the shared ones"
+    )
+    blended_similarity: Optional[float] = Field(
+        default=None, description="Similarity blended with the evidence score, only with a blend weight"
+    )
+    shared_tokens: List[SharedRareTokenDto] = Field(default_factory=list, description="Shared tokens, rarest first")
+
+
+class RareTokenEvidenceDto(BaseModel):
+    """DTO for the pairs of submissions of a detection run sharing rare identifiers or string literals"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "run_id": "770e8400-e29b-41d4-a716-446655440000",
+                "submission_count": 30,
+                "max_document_frequency": 0.1,
+                "max_submissions": 3,
+                "blend_weight": 0.2,
+                "total_pairs": 1,
+                "pairs": [
+                    {
+                        "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                        "compared_submission_id": "660e8400-e29b-41d4-a716-446655440001",
+                        "similarity_id": "880e8400-e29b-41d4-a716-446655440002",
+                        "overall_similarity": 0.42,
+                        "evidence_score": 0.85,
+                        "blended_similarity": 0.506,
+                        "shared_tokens": [
+                            {"kind": "identifier", "text": "tmpFooBar2", "document_frequency": 2},
+                            {"kind": "string", "text": "conection refused", "document_frequency": 2},
+                        ],
+                    }
+                ],
+                "unfetched_submission_ids": [],
+            }
+        }
+    )
+
+    run_id: UUID
+    submission_count: int = Field(..., description="Number of submissions of the run whose tokens were extracted")
+    max_document_frequency: float = Field(..., description="Share of the submissions a rare token appears in at most")
+    max_submissions: int = Field(..., description="Number of submissions a rare token appears in at most")
+    blend_weight: float = Field(..., description="Weight of the evidence score in the blended similarity")
+    total_pairs: int = Field(..., description="Number of pairs sharing rare tokens")
+    pairs: List[RareTokenPairDto] = Field(default_factory=list, description="Pairs, by decreasing evidence score")
+    unfetched_submission_ids: List[UUID] = Field(
+        default_factory=list, description="Submissions that could not be fetched or tokenized"
+    )
@@ -0,0 +1,44 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict
+
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+
+class ReportJobResponseDto(BaseModel):
+    """DTO for reading the rendering of a PDF report or of an evidence package, without the report itself"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440040",
+                "similarity_id": "550e8400-e29b-41d4-a716-446655440002",
+                "run_id": None,
+                "report_format": "pdf",
+                "status": "processing",
+                "created_at": "2024-01-15T10:30:00Z",
+                "updated_at": "2024-01-15T10:30:01Z",
+                "processing_time_seconds": None,
+                "error_message": None,
+                "content_sha256": None,
+                "status_url": "/submissions/report-jobs/550e8400-e29b-41d4-a716-446655440040",
+                "download_url": None,
+            }
+        },
+    )
+
+    id: UUID
+    similarity_id: UUID
+    run_id: Optional[UUID] = None
+    report_format: str
+    status: SimilarityStatus
+    created_at: datetime
+    updated_at: Optional[datetime]
+    processing_time_seconds: Optional[float]
+    error_message: Optional[str]
+    content_sha256: Optional[str] = None
+    status_url: str
+    download_url: Optional[str] = None
@@ -0,0 +1,107 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, model_validator
+
+from app.domains.submissions.submissions_models import RetentionAnchor
+
+
+class RetentionPolicyDto(BaseModel):
+    """DTO for the retention policy of a project step, its files and detection results being purged once expired"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "anchor": "date",
+                "anchor_date": "2024-06-30T00:00:00+02:00",
+                "file_retention_days": 180,
+                "result_retention_days": 365,
+                "full_deletion": False,
+            }
+        },
+    )
+
+    anchor: RetentionAnchor = Field(
+        default=RetentionAnchor.DEADLINE,
+        description="Date the retention runs from: the grading deadline of the step, a fixed date or each upload",
+    )
+    anchor_date: Optional[datetime] = Field(default=None, description="Fixed date, such as the end of the course")
+    file_retention_days: Optional[int] = Field(
+        default=None, ge=0, description="Days the files are kept, forever if None"
+    )
+    result_retention_days: Optional[int] = Field(
+        default=None, ge=0, description="Days the detection results are kept, forever if None"
+    )
+    full_deletion: bool = Field(
+        default=False, description="Whether the expired records are deleted, rather than reduced to their metadata"
+    )
+
+    @model_validator(mode="after")
+    def anchor_date_given(self) -> "RetentionPolicyDto":
+        if self.anchor == RetentionAnchor.DATE and self.anchor_date is None:
+            raise ValueError("A retention policy anchored on a fixed date needs its anchor_date")
+        return self
+
+
+class RetentionPolicyResponseDto(RetentionPolicyDto):
+    """DTO for reading the retention policy of a project step"""
+
+    project_uuid: UUID
+    project_step_uuid: UUID
+    created_at: datetime
+    updated_at: Optional[datetime]
+
+
+class RetentionPurgeCountsDto(BaseModel):
+    """DTO for the counts of a purge of the expired files and results of a project step"""
+
+    project_uuid: UUID
+    project_step_uuid: UUID
+    full_deletion: bool = Field(..., description="Whether the expired records are deleted for good")
+    purged_file_count: int = Field(..., description="Submissions whose stored files are purged, their record kept")
+    deleted_submission_count: int = Field(..., description="Submissions deleted for good")
+    purged_result_count: int = Field(..., description="Comparisons purged of their details, or deleted")
+    deleted_run_count: int = Field(..., description="Detection runs deleted")
+    exempt_submission_count: int = Field(..., description="Submissions kept for their open integrity case")
+
+
+class RetentionPurgeReportDto(RetentionPurgeCountsDto):
+    """DTO for what a purge deleted in a project step, or would delete in dry run"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "dry_run": True,
+                "full_deletion": False,
+                "purged_file_count": 2,
+                "deleted_submission_count": 0,
+                "purged_result_count": 1,
+                "deleted_run_count": 0,
+                "exempt_submission_count": 1,
+                "expired_file_submission_ids": [
+                    "550e8400-e29b-41d4-a716-446655440000",
+                    "550e8400-e29b-41d4-a716-446655440004",
+                ],
+                "expired_similarity_ids": ["123e4567-e89b-12d3-a456-426614174000"],
+                "expired_run_ids": [],
+                "exempt_submission_ids": ["550e8400-e29b-41d4-a716-446655440006"],
+            }
+        }
+    )
+
+    dry_run: bool = Field(..., description="Whether nothing was deleted, the purge only reporting what expired")
+    expired_file_submission_ids: List[UUID] = Field(..., description="Submissions whose files expired")
+    expired_similarity_ids: List[UUID] = Field(..., description="Comparisons whose results expired")
+    expired_run_ids: List[UUID] = Field(..., description="Detection runs whose results expired")
+    exempt_submission_ids: List[UUID] = Field(..., description="Submissions with an open integrity case")
+
+
+class RetentionPurgeDto(RetentionPurgeCountsDto):
+    """DTO for a purge in the log of the purges, with when it was made"""
+
+    id: UUID
+    created_at: datetime
@@ -4,6 +4,9 @@ from uuid import UUID
 
 from pydantic import BaseModel, ConfigDict
 
+from app.domains.detection.dto.file_similarity_dto import FileSimilarityBreakdownDto
+from app.domains.detection.dto.function_similarity_dto import FunctionSimilaritiesDto
+from app.domains.detection.dto.token_match_dto import FragmentDto, TokenMatchDto
 from app.domains.submissions.submissions_models import SimilarityStatus
 
 
@@ -29,6 +32,7 @@ class SimilarityMetricsDto(BaseModel):
     type_sequence_similarity: Optional[float] = None
     flow_similarity: Optional[float] = None
     operation_similarity: Optional[float] = None
+    max_function_similarity: Optional[float] = None
 
 
 class SubmissionSummaryDto(BaseModel):
@@ -72,6 +76,8 @@ class SimilarityResponseDto(BaseModel):
                 "created_at": "2024-01-15T10:30:00Z",
                 "processing_time_seconds": 12.5,
                 "error_message": None,
+                "suspicious": True,
+                "too_short": False,
             }
         },
     )
@@ -86,10 +92,13 @@ class SimilarityResponseDto(BaseModel):
     type_sequence_similarity: Optional[float] = None
     flow_similarity: Optional[float] = None
     operation_similarity: Optional[float] = None
+    max_function_similarity: Optional[float] = None
     status: SimilarityStatus
     created_at: datetime
     processing_time_seconds: Optional[float]
     error_message: Optional[str]
+    suspicious: bool = False
+    too_short: bool = False
 
 
 class DetailedComparisonDto(BaseModel):
@@ -132,6 +141,45 @@ class DetailedComparisonDto(BaseModel):
                     "processing_time_seconds": 12.5,
                     "error_message": None,
                 },
+                "matches": [
+                    {
+                        "file1": {
+                            "file": "worker.go",
+                            "start_line": 12,
+                            "start_column": 0,
+                            "end_line": 27,
+                            "end_column": 1,
+                        },
+                        "file2": {
+                            "file": "jobs/worker.go",
+                            "start_line": 40,
+                            "start_column": 0,
+                            "end_line": 55,
+                            "end_column": 1,
+                        },
+                        "tokens": 96,
+                    }
+                ],
+                "fragments": [
+                    {
+                        "left": {
+                            "file": "worker.go",
+                            "start_line": 12,
+                            "start_column": 0,
+                            "end_line": 27,
+                            "end_column": 1,
+                        },
+                        "right": {
+                            "file": "jobs/worker.go",
+                            "start_line": 40,
+                            "start_column": 0,
+                            "end_line": 55,
+                            "end_column": 1,
+                        },
+                        "tokens": 96,
+                        "similarity": 1.0,
+                    }
+                ],
             }
         },
     )
@@ -140,6 +188,10 @@ class DetailedComparisonDto(BaseModel):
     submissions: Dict[str, SubmissionSummaryDto]
     similarity_metrics: SimilarityMetricsDto
     analysis_metadata: Dict[str, Any]
+    matches: List[TokenMatchDto] = []
+    fragments: List[FragmentDto] = []
+    file_similarities: Optional[FileSimilarityBreakdownDto] = None
+    function_similarities: Optional[FunctionSimilaritiesDto] = None
     detailed_results: Optional[Dict[str, Any]] = None
 
 
@@ -188,6 +240,11 @@ class SimilarityStatisticsDto(BaseModel):
                 "average_similarity": 0.245,
                 "max_similarity": 0.87,
                 "status_breakdown": {"completed": 20, "failed": 3, "processing": 2},
+                "flag_threshold": 0.4,
+                "min_token_count": 200,
+                "compared_pairs": 21,
+                "flagged_pairs": 4,
+                "too_short_submissions": ["550e8400-e29b-41d4-a716-446655440003"],
             }
         }
     )
@@ -197,6 +254,11 @@ class SimilarityStatisticsDto(BaseModel):
     average_similarity: float
     max_similarity: float
     status_breakdown: Dict[str, int]
+    flag_threshold: float = 0.7
+    min_token_count: int = 0
+    compared_pairs: int = 0
+    flagged_pairs: int = 0
+    too_short_submissions: List[UUID] = []
 
 
 class SimilarityListResponseDto(BaseModel):
@@ -222,8 +284,14 @@ class SimilarityListResponseDto(BaseModel):
                         "created_at": "2024-01-15T10:30:00Z",
                         "processing_time_seconds": 12.5,
                         "error_message": None,
+                        "suspicious": True,
+                        "too_short": False,
                     }
                 ],
+                "flag_threshold": 0.4,
+                "min_token_count": 200,
+                "flagged_count": 3,
+                "too_short_submissions": [],
             }
         }
     )
@@ -232,6 +300,10 @@ class SimilarityListResponseDto(BaseModel):
     total_comparisons: int
     high_similarity_count: int
     similarities: List[SimilarityResponseDto]
+    flag_threshold: float = 0.7
+    min_token_count: int = 0
+    flagged_count: int = 0
+    too_short_submissions: List[UUID] = []
 
 
 class SimilarityAlertsResponseDto(BaseModel):
@@ -0,0 +1,58 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import AnalysisPriority, AutoRunStatus
+
+
+class StepScheduleDto(BaseModel):
+    """
+    DTO for the grading deadline of a project step, the analyses of its submissions being prioritized by it, and
+    the detection run started over its analyzed submissions at a given time
+    """
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "deadline": "2024-01-16T18:00:00+01:00",
+                "priority": None,
+                "auto_run_at": "2024-01-16T20:00:00+01:00",
+                "auto_run_profile": "renames-and-reorders",
+            }
+        },
+    )
+
+    deadline: Optional[datetime] = Field(default=None, description="Grading deadline of the step")
+    priority: Optional[AnalysisPriority] = Field(
+        default=None, description="Priority of the analyses of the step, overriding that of the deadline"
+    )
+    auto_run_at: Optional[datetime] = Field(
+        default=None, description="When a detection run of the analyzed submissions is started, e.g. after the deadline"
+    )
+    auto_run_profile: Optional[str] = Field(
+        default=None, description="Analysis profile of the scheduled run, the built-in default profile if omitted"
+    )
+
+
+class StepScheduleResponseDto(BaseModel):
+    """DTO for the schedule of a project step, with the priority its analyses are queued at"""
+
+    model_config = ConfigDict(use_enum_values=True)
+
+    project_uuid: UUID
+    project_step_uuid: UUID
+    deadline: Optional[datetime] = Field(default=None, description="Grading deadline of the step")
+    priority: Optional[AnalysisPriority] = Field(default=None, description="Priority set for the step")
+    effective_priority: AnalysisPriority = Field(..., description="Priority the analyses of the step are queued at")
+    reprioritized_job_count: int = Field(default=0, description="Queued analysis jobs re-ordered by the change")
+    auto_run_at: Optional[datetime] = Field(default=None, description="When the scheduled detection run is started")
+    auto_run_profile: Optional[str] = Field(default=None, description="Analysis profile of the scheduled run")
+    auto_run_status: Optional[AutoRunStatus] = Field(
+        default=None, description="Status of the scheduled run: scheduled, started or failed, None without one"
+    )
+    auto_run_started_at: Optional[datetime] = Field(default=None, description="When the scheduled run was started")
+    auto_run_id: Optional[UUID] = Field(default=None, description="ID of the detection run started by the schedule")
+    auto_run_error: Optional[str] = Field(default=None, description="Why the scheduled run could not be started")
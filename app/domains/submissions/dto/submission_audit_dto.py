@@ -0,0 +1,36 @@
+from datetime import datetime
+from typing import Any, Dict, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class SubmissionAuditEntryDto(BaseModel):
+    """DTO for a change of the audit trail of a submission"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440060",
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "action": "metadata_update",
+                "changes": {
+                    "group_uuid": {
+                        "old": "550e8400-e29b-41d4-a716-446655440002",
+                        "new": "550e8400-e29b-41d4-a716-446655440012",
+                    }
+                },
+                "actor": "grader@school.edu",
+                "ip_address": "192.168.1.100",
+                "created_at": "2024-01-16T09:00:00Z",
+            }
+        }
+    )
+
+    id: UUID
+    submission_id: UUID
+    action: str
+    changes: Dict[str, Dict[str, Any]] = Field(..., description="Old and new value of each changed field")
+    actor: Optional[str] = None
+    ip_address: Optional[str] = None
+    created_at: datetime
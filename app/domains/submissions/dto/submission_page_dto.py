@@ -0,0 +1,61 @@
+from enum import Enum
+from typing import Dict, List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.dto.submission_response_dto import SubmissionResponseDto
+
+# Response headers of a listing, its body staying the array of the submissions of the page
+TOTAL_COUNT_HEADER = "X-Total-Count"
+HAS_MORE_HEADER = "X-Has-More"
+NEXT_CURSOR_HEADER = "X-Next-Cursor"
+PAGE_HEADERS = [TOTAL_COUNT_HEADER, HAS_MORE_HEADER, NEXT_CURSOR_HEADER]
+
+
+class SubmissionSortField(str, Enum):
+    """Fields the listed submissions are sorted by, their ID breaking the ties"""
+
+    CREATED_AT = "created_at"
+    GROUP = "group"
+    STATUS = "status"
+    LANGUAGE = "language"
+
+
+class SortOrder(str, Enum):
+    """Order of a sort"""
+
+    ASC = "asc"
+    DESC = "desc"
+
+
+class SubmissionPageDto(BaseModel):
+    """DTO for a page of the listed submissions, returned as its items with the rest in the response headers"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "items": [],
+                "total": 240,
+                "skip": 0,
+                "limit": 100,
+                "has_more": True,
+                "next_cursor": "eyJzb3J0IjogImNyZWF0ZWRfYXQiLCAib3JkZXIiOiAiZGVzYyIsIC4uLn0",
+            }
+        }
+    )
+
+    items: List[SubmissionResponseDto] = Field(default=[], description="Submissions of the page")
+    total: int = Field(..., description="Number of submissions matching the filters, over all the pages")
+    skip: int = Field(..., description="Number of submissions skipped before the page (after the cursor if given)")
+    limit: int = Field(..., description="Maximum number of submissions of the page")
+    has_more: bool = Field(..., description="Whether submissions follow the page")
+    next_cursor: Optional[str] = Field(
+        default=None, description="Cursor of the next page, stable under concurrent inserts, None on the last page"
+    )
+
+    def headers(self) -> Dict[str, str]:
+        """Response headers of the page: the total, whether more follow and the cursor of the next page if any"""
+        headers = {TOTAL_COUNT_HEADER: str(self.total), HAS_MORE_HEADER: "true" if self.has_more else "false"}
+        if self.next_cursor:
+            headers[NEXT_CURSOR_HEADER] = self.next_cursor
+        return headers
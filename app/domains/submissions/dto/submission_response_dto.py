@@ -1,10 +1,10 @@
 from datetime import datetime
-from typing import Optional
+from typing import Any, Dict, List, Optional
 from uuid import UUID
 
 from pydantic import BaseModel, ConfigDict
 
-from app.domains.submissions.submissions_models import LinkType, SubmissionStatus
+from app.domains.submissions.submissions_models import LinkType, ProcessingStatus, SubmissionStatus
 
 
 class SubmissionResponseDto(BaseModel):
@@ -26,10 +26,36 @@ class SubmissionResponseDto(BaseModel):
                 "file_count": 25,
                 "upload_date_time": "2024-01-15T10:30:00Z",
                 "status": "completed",
+                "processing_status": "analyzed",
+                "version": 2,
+                "display_name": "Team Rocket - final",
+                "tags": ["late", "reviewed"],
+                "deleted_at": None,
+                "malware_scan": {"scanner": "clamav", "verdict": "clean", "infected_files": []},
+                "quarantined_at": None,
+                "content_hash": "5f2b7c1e9d0a4b3c8e6f1a2d7b9c0e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d",
+                "duplicate_of": None,
+                "integrity_case_open": False,
+                "files_purged_at": None,
                 "created_at": "2024-01-15T10:30:00Z",
                 "updated_at": "2024-01-15T11:00:00Z",
                 "ip_address": "192.168.1.100",
                 "user_agent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36",
+                "language_confidence": 0.7,
+                "language": "c",
+                "language_detection": {
+                    "threshold": 0.75,
+                    "languages": {"python": 12, "c": 1},
+                    "flagged_files": [
+                        {
+                            "file": "include/utils.h",
+                            "language": "c",
+                            "confidence": 0.7,
+                            "method": "content",
+                            "alternatives": [{"language": "cpp", "score": 0.3}],
+                        }
+                    ],
+                },
             }
         },
     )
@@ -46,7 +72,27 @@ class SubmissionResponseDto(BaseModel):
     file_count: Optional[int]
     upload_date_time: datetime
     status: SubmissionStatus
+    processing_status: ProcessingStatus = ProcessingStatus.RECEIVED
+    version: int = 1
+    display_name: Optional[str] = None
+    tags: Optional[List[str]] = None
+    deleted_at: Optional[datetime] = None
+    malware_scan: Optional[Dict[str, Any]] = None
+    quarantined_at: Optional[datetime] = None
     created_at: datetime
     updated_at: Optional[datetime]
     ip_address: Optional[str]
     user_agent: Optional[str]
+    language_confidence: Optional[float] = None
+    language_detection: Optional[Dict[str, Any]] = None
+    language: Optional[str] = None
+    processing_log: Optional[List[Dict[str, Any]]] = None
+    git_repository_url: Optional[str] = None
+    git_ref: Optional[str] = None
+    git_commit_sha: Optional[str] = None
+    git_subdirectory: Optional[str] = None
+    force_include_files: Optional[List[str]] = None
+    content_hash: Optional[str] = None
+    duplicate_of: Optional[UUID] = None
+    integrity_case_open: bool = False
+    files_purged_at: Optional[datetime] = None
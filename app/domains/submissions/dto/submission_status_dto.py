@@ -0,0 +1,83 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import AnalysisPriority, ProcessingStatus
+
+
+class ProcessingTransitionDto(BaseModel):
+    """DTO for a stage a submission went through"""
+
+    model_config = ConfigDict(use_enum_values=True)
+
+    status: ProcessingStatus
+    at: datetime = Field(..., description="When the submission reached the stage")
+
+
+class ProcessingAttemptDto(BaseModel):
+    """DTO for a failed attempt of the processing of a submission"""
+
+    attempt: int = Field(..., description="Number of the attempt, from 1, counted again when retried by hand")
+    at: datetime = Field(..., description="When the attempt failed")
+    error: str = Field(..., description="Reason the attempt failed")
+    failed_file: Optional[str] = Field(default=None, description="File the attempt failed on, if any")
+    transient: bool = Field(..., description="Whether the cause is transient, the processing being retried")
+    retry_at: Optional[datetime] = Field(default=None, description="When the processing is retried, None if not")
+
+
+class SubmissionStatusDto(BaseModel):
+    """DTO for the processing status of a submission, polled until it is analyzed"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "status": "tokenizing",
+                "transitions": [
+                    {"status": "received", "at": "2024-01-15T10:30:00+01:00"},
+                    {"status": "extracting", "at": "2024-01-15T10:30:01+01:00"},
+                    {"status": "pending_retry", "at": "2024-01-15T10:30:31+01:00"},
+                    {"status": "extracting", "at": "2024-01-15T10:30:35+01:00"},
+                    {"status": "tokenizing", "at": "2024-01-15T10:30:38+01:00"},
+                ],
+                "processed_file_count": 50,
+                "total_file_count": 120,
+                "error": None,
+                "failed_file": None,
+                "attempt_count": 1,
+                "attempts": [
+                    {
+                        "attempt": 1,
+                        "at": "2024-01-15T10:30:31+01:00",
+                        "error": "Read timeout on endpoint URL",
+                        "failed_file": None,
+                        "transient": True,
+                        "retry_at": "2024-01-15T10:30:35+01:00",
+                    }
+                ],
+                "retry_at": None,
+                "priority": "urgent",
+                "effective_priority": None,
+                "queue_position": None,
+            }
+        },
+    )
+
+    submission_id: UUID
+    status: ProcessingStatus = Field(..., description="Current stage of the processing of the submission")
+    transitions: List[ProcessingTransitionDto] = Field(..., description="Stages the submission went through")
+    processed_file_count: int = Field(..., description="Number of files processed so far")
+    total_file_count: Optional[int] = Field(..., description="Number of files to process, None until extracted")
+    error: Optional[str] = Field(default=None, description="Reason the processing failed")
+    failed_file: Optional[str] = Field(default=None, description="File the processing failed on, if any")
+    attempt_count: int = Field(default=0, description="Failed attempts of the current processing")
+    attempts: List[ProcessingAttemptDto] = Field(default_factory=list, description="Failed attempts, oldest first")
+    retry_at: Optional[datetime] = Field(default=None, description="When the processing pending retry is retried")
+    priority: Optional[AnalysisPriority] = Field(default=None, description="Priority of the analysis")
+    effective_priority: Optional[float] = Field(
+        default=None, description="Priority in the analysis queue, raised while waiting, None if not queued"
+    )
+    queue_position: Optional[int] = Field(default=None, description="Position in the analysis queue (1 is next)")
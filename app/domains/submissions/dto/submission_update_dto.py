@@ -1,5 +1,5 @@
 from datetime import datetime
-from typing import Optional
+from typing import Any, Dict, List, Optional
 from uuid import UUID
 
 import pytz
@@ -28,6 +28,7 @@ class SubmissionUpdateDto(BaseModel):
                 "submitted_by_uuid": "550e8400-e29b-41d4-a716-446655440005",
                 "file_size_bytes": 2048000,
                 "file_count": 30,
+                "force_include_files": ["static/js/app.min.js"],
                 "updated_at": "2024-01-15T12:00:00Z",
             }
         },
@@ -38,6 +39,16 @@ class SubmissionUpdateDto(BaseModel):
     submitted_by_uuid: Optional[UUID] = None
     file_size_bytes: Optional[int] = None
     file_count: Optional[int] = None
+    language_confidence: Optional[float] = Field(default=None, ge=0.0, le=1.0)
+    language_detection: Optional[Dict[str, Any]] = None
+    language: Optional[str] = None
+    code_metrics: Optional[Dict[str, Any]] = None
+    processing_log: Optional[List[Dict[str, Any]]] = None
+    git_repository_url: Optional[str] = None
+    git_ref: Optional[str] = None
+    git_commit_sha: Optional[str] = None
+    git_subdirectory: Optional[str] = None
+    force_include_files: Optional[List[str]] = None
     updated_at: datetime = Field(default_factory=get_paris_time)
 
     @field_validator("description")
@@ -0,0 +1,103 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import SubmissionStatus
+
+
+class SubmissionVersionDto(BaseModel):
+    """DTO for a version of the submission of a group for a project step"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440000",
+                "version": 2,
+                "latest": True,
+                "link": "s3://submissions/uploads/project/step/group/archive.zip",
+                "status": "completed",
+                "upload_date_time": "2024-01-15T23:58:00Z",
+                "file_count": 25,
+                "file_size_bytes": 1024000,
+                "git_commit_sha": None,
+            }
+        }
+    )
+
+    id: UUID
+    version: int = Field(..., description="Version of the submission, the versions being never renumbered")
+    latest: bool = Field(..., description="Whether the version is the latest one, compared by the detection runs")
+    link: str
+    status: SubmissionStatus
+    upload_date_time: datetime
+    file_count: Optional[int] = None
+    file_size_bytes: Optional[int] = None
+    git_commit_sha: Optional[str] = None
+
+
+class FileDiffDto(BaseModel):
+    """DTO for the changes of a file between two versions of a submission"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "path": "src/main.py",
+                "status": "modified",
+                "binary": False,
+                "lines_added": 1,
+                "lines_removed": 1,
+                "diff": [
+                    "--- a/src/main.py",
+                    "+++ b/src/main.py",
+                    "@@ -1 +1 @@",
+                    '-print("v1")',
+                    '+print("v2")',
+                ],
+                "truncated": False,
+            }
+        }
+    )
+
+    path: str = Field(..., description="Path of the file, relative to the root of the submission")
+    status: str = Field(..., description="added, removed or modified")
+    binary: bool = Field(..., description="Whether the file is binary, its lines being then not compared")
+    lines_added: int = Field(..., description="Lines added to the file")
+    lines_removed: int = Field(..., description="Lines removed from the file")
+    diff: Optional[List[str]] = Field(default=None, description="Unified diff of the file, None for binary files")
+    truncated: bool = Field(default=False, description="Whether the unified diff was cut to its first lines")
+
+
+class SubmissionVersionDiffDto(BaseModel):
+    """DTO for the per-file changes between two versions of a submission"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "from_submission_id": "550e8400-e29b-41d4-a716-446655440010",
+                "from_version": 1,
+                "to_submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                "to_version": 2,
+                "files_added": 1,
+                "files_removed": 0,
+                "files_modified": 1,
+                "files_unchanged": 23,
+                "lines_added": 12,
+                "lines_removed": 1,
+                "files": [],
+            }
+        }
+    )
+
+    from_submission_id: UUID = Field(..., description="Version the changes are computed from")
+    from_version: int
+    to_submission_id: UUID = Field(..., description="Version the changes are computed to")
+    to_version: int
+    files_added: int
+    files_removed: int
+    files_modified: int
+    files_unchanged: int
+    lines_added: int
+    lines_removed: int
+    files: List[FileDiffDto] = Field(default=[], description="Changed files, by path")
@@ -0,0 +1,13 @@
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class TokenCachePurgeResponseDto(BaseModel):
+    """DTO for the purge of the cached token streams of a language"""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"language": "python", "purged_entry_count": 1250, "tokenizer_version": 1}}
+    )
+
+    language: str = Field(..., description="Language whose cached token streams were deleted")
+    purged_entry_count: int = Field(..., description="Number of cached token streams deleted")
+    tokenizer_version: int = Field(..., description="Current tokenizer version, the one the files are cached with")
@@ -0,0 +1,166 @@
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+from app.domains.detection.token_aligner import DEFAULT_OPERATION_LIMIT
+
+# Operations of a page of a token diff, at most
+MAX_OPERATION_LIMIT = 1000
+
+
+class FileReferenceDto(BaseModel):
+    """DTO for a file of a submission, by its path relative to the root of the submission"""
+
+    submission_id: UUID
+    path: str = Field(..., description="Path of the file relative to the root of the submission")
+
+    @field_validator("path")
+    def validate_path(cls, v):
+        """Validate that the path stays within the submission"""
+        path = v.strip().strip("/")
+        if not path or ".." in path.split("/"):
+            raise ValueError("The path must be relative to the root of the submission, without '..'")
+        return path
+
+
+class TokenDiffRequestDto(BaseModel):
+    """DTO for the token-level diff of two files of submissions, through the normalization of the detection"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "file1": {"submission_id": "550e8400-e29b-41d4-a716-446655440000", "path": "jobs/worker.go"},
+                "file2": {"submission_id": "550e8400-e29b-41d4-a716-446655440004", "path": "worker/pool.go"},
+                "profile": "renames-and-reorders",
+                "overrides": {"normalize_literals": True},
+                "cursor": None,
+                "limit": 200,
+            }
+        }
+    )
+
+    file1: FileReferenceDto
+    file2: FileReferenceDto
+    profile: Optional[str] = Field(
+        default=None, description="Name of the analysis profile the files are normalized with, the default if omitted"
+    )
+    overrides: Dict[str, Any] = Field(
+        default_factory=dict, description="Detection options of the profile overridden for the diff"
+    )
+    cursor: Optional[str] = Field(default=None, description="Cursor of the next page of a previous diff")
+    limit: int = Field(
+        default=DEFAULT_OPERATION_LIMIT, ge=1, le=MAX_OPERATION_LIMIT, description="Operations of the page, at most"
+    )
+
+
+class TokenDiffFileDto(BaseModel):
+    """DTO for a file of a token diff"""
+
+    submission_id: UUID
+    path: str
+    language: Optional[str] = Field(default=None, description="Language the file was tokenized as")
+    token_count: int = Field(..., description="Tokens of the file compared, once normalized")
+
+
+class TokenRangeDto(BaseModel):
+    """DTO for the tokens of an operation in a file, by index in its compared tokens and by position in the file"""
+
+    start_index: int
+    end_index: int = Field(..., description="Index past the last token, equal to start_index if the range is empty")
+    start_line: Optional[int] = None
+    start_column: Optional[int] = None
+    end_line: Optional[int] = None
+    end_column: Optional[int] = None
+
+
+class TokenDiffOperationDto(BaseModel):
+    """DTO for a run of matched, deleted (only in the first file) or inserted (only in the second) tokens"""
+
+    operation: str = Field(..., description="Operation: match, deletion or insertion")
+    token_count: int
+    file1: TokenRangeDto
+    file2: TokenRangeDto
+    tokens: List[str] = Field(default_factory=list, description="Normalized tokens of the operation, as compared")
+
+
+class TokenDiffSummaryDto(BaseModel):
+    """DTO for the counts of a token diff, over all its operations"""
+
+    matched_token_count: int
+    deleted_token_count: int
+    inserted_token_count: int
+    matched_share: float = Field(..., description="Share of the tokens of both files matched")
+
+
+class TokenDiffDto(BaseModel):
+    """DTO for a page of the token-level diff of two files of submissions"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "file1": {
+                    "submission_id": "550e8400-e29b-41d4-a716-446655440000",
+                    "path": "jobs/worker.go",
+                    "language": "go",
+                    "token_count": 412,
+                },
+                "file2": {
+                    "submission_id": "550e8400-e29b-41d4-a716-446655440004",
+                    "path": "worker/pool.go",
+                    "language": "go",
+                    "token_count": 398,
+                },
+                "cross_language": False,
+                "analysis_profile": "renames-and-reorders",
+                "detection_options": {"normalize_identifiers": True, "normalize_literals": True},
+                "summary": {
+                    "matched_token_count": 371,
+                    "deleted_token_count": 41,
+                    "inserted_token_count": 27,
+                    "matched_share": 0.9118,
+                },
+                "operations": [
+                    {
+                        "operation": "match",
+                        "token_count": 96,
+                        "file1": {
+                            "start_index": 0,
+                            "end_index": 96,
+                            "start_line": 1,
+                            "start_column": 0,
+                            "end_line": 24,
+                            "end_column": 1,
+                        },
+                        "file2": {
+                            "start_index": 0,
+                            "end_index": 96,
+                            "start_line": 1,
+                            "start_column": 0,
+                            "end_line": 22,
+                            "end_column": 1,
+                        },
+                        "tokens": ["package_clause:package ID1", "function_declaration:func ID2(ID3 <-chan..."],
+                    }
+                ],
+                "operation_offset": 0,
+                "total_operations": 14,
+                "truncated": False,
+                "next_cursor": None,
+            }
+        }
+    )
+
+    file1: TokenDiffFileDto
+    file2: TokenDiffFileDto
+    cross_language: bool = Field(..., description="Whether the files were compared through abstract token categories")
+    analysis_profile: str
+    detection_options: Dict[str, Any] = Field(
+        default_factory=dict, description="Detection options the files were normalized with"
+    )
+    summary: TokenDiffSummaryDto
+    operations: List[TokenDiffOperationDto]
+    operation_offset: int = Field(..., description="Index of the first operation of the page")
+    total_operations: int
+    truncated: bool = Field(..., description="Whether operations follow, served with the next cursor")
+    next_cursor: Optional[str] = None
@@ -0,0 +1,41 @@
+from typing import Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class UploadLimitsDto(BaseModel):
+    """DTO for the limits of the uploads of a project step, None meaning the configured default"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "max_upload_bytes": 500000000,
+                "max_extracted_bytes": 2000000000,
+                "max_file_count": None,
+                "max_file_bytes": None,
+                "reject_binary_files": True,
+                "strict_paths": None,
+            }
+        }
+    )
+
+    max_upload_bytes: Optional[int] = Field(default=None, gt=0, description="Size of an uploaded file, in bytes")
+    max_extracted_bytes: Optional[int] = Field(default=None, gt=0, description="Extracted size of an upload, in bytes")
+    max_file_count: Optional[int] = Field(default=None, gt=0, description="Number of files of an upload")
+    max_file_bytes: Optional[int] = Field(default=None, gt=0, description="Size of a single file, in bytes")
+    reject_binary_files: Optional[bool] = Field(default=None, description="Whether the binary files are rejected")
+    strict_paths: Optional[bool] = Field(
+        default=None, description="Whether the duplicate paths and the paths differing only by case are rejected"
+    )
+
+
+class EffectiveUploadLimitsDto(BaseModel):
+    """DTO for the limits enforced on the uploads of a project step, its own or the configured defaults"""
+
+    max_upload_bytes: int
+    max_extracted_bytes: int
+    max_file_count: int
+    max_file_bytes: int
+    reject_binary_files: bool
+    strict_paths: bool
+    overrides: UploadLimitsDto = Field(..., description="Limits set for the project step")
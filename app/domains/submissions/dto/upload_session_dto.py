@@ -0,0 +1,90 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.submissions_models import UploadSessionStatus
+
+
+class CreateUploadSessionDto(BaseModel):
+    """DTO for initiating a resumable upload, the file being then sent in chunks"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "group_uuid": "550e8400-e29b-41d4-a716-446655440002",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "filename": "final-project.zip",
+                "total_bytes": 734003200,
+                "checksum": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+                "description": "Final project, with its datasets",
+                "submitted_by_uuid": "550e8400-e29b-41d4-a716-446655440004",
+                "allow_duplicates": False,
+            }
+        }
+    )
+
+    project_uuid: UUID = Field(..., description="UUID of the associated project")
+    group_uuid: UUID = Field(..., description="UUID of the associated group")
+    project_step_uuid: UUID = Field(..., description="UUID of the project step")
+    filename: str = Field(..., min_length=1, max_length=255, description="Name of the uploaded file")
+    total_bytes: int = Field(..., gt=0, description="Size of the whole file in bytes")
+    checksum: str = Field(
+        ..., pattern=r"^[0-9a-fA-F]{64}$", description="SHA-256 checksum of the whole file, in hexadecimal"
+    )
+    description: Optional[str] = Field(default=None, max_length=1000, description="Description of the submission")
+    submitted_by_uuid: Optional[UUID] = Field(default=None, description="UUID of the submitter")
+    allow_duplicates: bool = Field(default=False, description="Allow duplicate submissions")
+
+
+class UploadSessionResponseDto(BaseModel):
+    """DTO for reading a resumable upload, with the chunks received and those still missing"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440080",
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "group_uuid": "550e8400-e29b-41d4-a716-446655440002",
+                "project_step_uuid": "550e8400-e29b-41d4-a716-446655440003",
+                "filename": "final-project.zip",
+                "total_bytes": 734003200,
+                "checksum": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+                "chunk_bytes": 8000000,
+                "chunk_count": 92,
+                "received_chunks": [0, 1, 2],
+                "missing_chunks": [3, 4, 5],
+                "status": "pending",
+                "submission_id": None,
+                "created_at": "2024-01-16T09:00:00Z",
+                "updated_at": None,
+                "expires_at": "2024-01-17T09:00:00Z",
+                "error_message": None,
+                "chunks_url": "/submissions/uploads/550e8400-e29b-41d4-a716-446655440080/chunks",
+                "finalize_url": "/submissions/uploads/550e8400-e29b-41d4-a716-446655440080/finalize",
+            }
+        },
+    )
+
+    id: UUID
+    project_uuid: UUID
+    group_uuid: UUID
+    project_step_uuid: UUID
+    filename: str
+    total_bytes: int
+    checksum: str
+    chunk_bytes: int = Field(..., description="Size of each chunk in bytes, the last one being smaller")
+    chunk_count: int = Field(..., description="Number of chunks, indexed from 0")
+    received_chunks: List[int] = Field(default=[], description="Indexes of the chunks received")
+    missing_chunks: List[int] = Field(default=[], description="Indexes of the chunks still to send")
+    status: UploadSessionStatus
+    submission_id: Optional[UUID] = Field(default=None, description="Submission created once the upload is finalized")
+    created_at: datetime
+    updated_at: Optional[datetime]
+    expires_at: datetime = Field(..., description="When the upload expires if not finalized")
+    error_message: Optional[str]
+    chunks_url: str = Field(..., description="URL each chunk is sent to, followed by its index")
+    finalize_url: str
@@ -0,0 +1,55 @@
+from typing import List, Optional
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.submissions.dto.create_submission_response_dto import CreateSubmissionResponseDto
+
+
+class SubmissionFileResponseDto(BaseModel):
+    """DTO for reading a file extracted from the uploaded archive of a submission"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440050",
+                "path": "cmd/server/main.go",
+                "size_bytes": 2048,
+                "language": "go",
+                "archive": None,
+                "content_hash": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+                "binary": False,
+                "encoding": "utf-8",
+                "encoding_error_count": 0,
+            }
+        }
+    )
+
+    id: UUID
+    path: str = Field(..., description="Path of the file, relative to the root of the archive")
+    size_bytes: int = Field(..., description="Size of the extracted file in bytes")
+    language: Optional[str] = Field(default=None, description="Detected language, None for binary or unknown files")
+    archive: Optional[str] = Field(default=None, description="Path of the nested archive the file was extracted from")
+    content_hash: Optional[str] = Field(default=None, description="SHA-256 of the content of the file")
+    binary: bool = Field(default=False, description="Whether the file is binary, left out of the analysis")
+    encoding: Optional[str] = Field(default=None, description="Detected encoding of a text file, None for a binary")
+    encoding_error_count: int = Field(default=0, description="Invalid byte sequences replaced in its text")
+
+
+class SkippedEntryDto(BaseModel):
+    """DTO for an entry of an uploaded archive that was not extracted"""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"path": "__MACOSX/._main.go", "reason": "operating system metadata"}}
+    )
+
+    path: str = Field(..., description="Path of the entry in the archive")
+    reason: str = Field(..., description="Reason the entry was not extracted")
+
+
+class UploadSubmissionResponseDto(CreateSubmissionResponseDto):
+    """DTO for the response of an uploaded submission, with the files extracted from it"""
+
+    files: List[SubmissionFileResponseDto] = Field(default=[], description="Files extracted from the upload")
+    skipped_entries: List[SkippedEntryDto] = Field(default=[], description="Entries of the archive not extracted")
+    ignored_path_count: int = Field(default=0, description="Paths left out by the ignore rules of the project step")
@@ -0,0 +1,117 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+from urllib.parse import urlparse
+from uuid import UUID
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator, model_validator
+
+from app.domains.submissions.submissions_models import WebhookDeliveryStatus, WebhookEvent
+
+
+class CreateWebhookDto(BaseModel):
+    """DTO for registering a webhook, the events of a project step, a project or all of them being posted to it"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "url": "https://grading.example.com/hooks/submissions",
+                "events": ["submission.analyzed", "submission.failed", "detection_run.completed"],
+                "project_uuid": "550e8400-e29b-41d4-a716-446655440001",
+                "project_step_uuid": None,
+                "secret": None,
+                "description": "Grading frontend",
+            }
+        },
+    )
+
+    url: str = Field(max_length=2048, description="URL the events are posted to")
+    events: List[WebhookEvent] = Field(min_length=1, description="Events notified to the webhook")
+    project_uuid: Optional[UUID] = Field(default=None, description="Project of the events, all projects if None")
+    project_step_uuid: Optional[UUID] = Field(default=None, description="Step of the events, all steps if None")
+    secret: Optional[str] = Field(
+        default=None, min_length=16, max_length=255, description="Secret of the signatures, generated if None"
+    )
+    description: Optional[str] = Field(default=None, max_length=1000)
+
+    @field_validator("url")
+    @classmethod
+    def validate_url(cls, v: str) -> str:
+        parsed = urlparse(v)
+        if parsed.scheme not in ("http", "https") or not parsed.hostname:
+            raise ValueError("The webhook URL must be an http or https URL")
+        return v
+
+    @field_validator("events")
+    @classmethod
+    def unique_events(cls, v: List[WebhookEvent]) -> List[WebhookEvent]:
+        return list(dict.fromkeys(v))
+
+    @model_validator(mode="after")
+    def step_within_project(self) -> "CreateWebhookDto":
+        if self.project_step_uuid is not None and self.project_uuid is None:
+            raise ValueError("A webhook scoped to a project step must be scoped to its project too")
+        return self
+
+
+class WebhookResponseDto(BaseModel):
+    """DTO for reading a webhook, without its secret"""
+
+    model_config = ConfigDict(use_enum_values=True)
+
+    id: UUID
+    url: str
+    events: List[WebhookEvent]
+    project_uuid: Optional[UUID]
+    project_step_uuid: Optional[UUID]
+    description: Optional[str]
+    created_at: datetime
+
+
+class CreateWebhookResponseDto(WebhookResponseDto):
+    """DTO for a registered webhook, with the secret its deliveries are signed with, returned only once"""
+
+    secret: str = Field(..., description="Secret of the HMAC-SHA256 signatures of the deliveries")
+
+
+class WebhookDeliveryResponseDto(BaseModel):
+    """DTO for reading the delivery of an event to a webhook, with each of its attempts"""
+
+    model_config = ConfigDict(
+        use_enum_values=True,
+        json_schema_extra={
+            "example": {
+                "id": "550e8400-e29b-41d4-a716-446655440040",
+                "webhook_id": "550e8400-e29b-41d4-a716-446655440041",
+                "event": "submission.analyzed",
+                "status": "pending",
+                "attempt_count": 1,
+                "attempts": [
+                    {
+                        "attempt": 1,
+                        "at": "2024-01-15T10:30:00+01:00",
+                        "status_code": 503,
+                        "error": "HTTP 503",
+                        "duration_ms": 42.0,
+                        "response_excerpt": "Service Unavailable",
+                        "retry_at": "2024-01-15T10:30:10+01:00",
+                    }
+                ],
+                "next_attempt_at": "2024-01-15T10:30:10+01:00",
+                "payload": {"event": "submission.analyzed", "data": {"submission_id": "..."}},
+                "created_at": "2024-01-15T10:30:00+01:00",
+                "delivered_at": None,
+            }
+        },
+    )
+
+    id: UUID
+    webhook_id: UUID
+    event: WebhookEvent
+    status: WebhookDeliveryStatus
+    attempt_count: int
+    attempts: List[Dict[str, Any]]
+    next_attempt_at: Optional[datetime]
+    payload: Dict[str, Any]
+    created_at: datetime
+    delivered_at: Optional[datetime]
@@ -0,0 +1,38 @@
+import hashlib
+from typing import Iterable, List, Optional, Sequence
+
+from app.domains.submissions.submissions_models import Submission
+
+
+class DuplicateUploadDetector:
+    """
+    Detect at its upload a submission whose files are byte-identical to those of the submission of another group of
+    the same project step, without waiting for a detection run. The aggregate content hash of a submission is the
+    SHA-256 of the sorted content hashes of its files, so that the order of the files in the archive and their names
+    make no difference. The submissions of the same group are its versions, never duplicates of each other.
+    """
+
+    @staticmethod
+    def aggregate_hash(content_hashes: Iterable[Optional[str]]) -> Optional[str]:
+        """Content hash of a submission from those of its files, whatever their order and name, None without files"""
+        hashes = sorted(content_hash for content_hash in content_hashes if content_hash)
+        if not hashes:
+            return None
+        return hashlib.sha256("\n".join(hashes).encode("ascii")).hexdigest()
+
+    @staticmethod
+    def originals(submission: Submission, candidates: Sequence[Submission]) -> List[Submission]:
+        """
+        Submissions of the other groups of the step the submission duplicates, earliest first, among those of the
+        same aggregate content hash
+        """
+        originals = [
+            candidate
+            for candidate in candidates
+            if candidate.id != submission.id
+            and candidate.group_uuid != submission.group_uuid
+            and candidate.project_uuid == submission.project_uuid
+            and candidate.project_step_uuid == submission.project_step_uuid
+            and candidate.content_hash == submission.content_hash
+        ]
+        return sorted(originals, key=lambda candidate: candidate.upload_date_time)
@@ -0,0 +1,122 @@
+import codecs
+from dataclasses import dataclass
+from typing import Optional, Tuple
+
+# Byte order marks, by the encoding they begin (the UTF-32 ones before the UTF-16 one they start like)
+BOMS: Tuple[Tuple[bytes, str], ...] = (
+    (codecs.BOM_UTF8, "utf-8"),
+    (codecs.BOM_UTF32_LE, "utf-32-le"),
+    (codecs.BOM_UTF32_BE, "utf-32-be"),
+    (codecs.BOM_UTF16_LE, "utf-16-le"),
+    (codecs.BOM_UTF16_BE, "utf-16-be"),
+)
+# Codecs a file beginning with a byte order mark is streamed with, skipping it
+BOM_STREAM_CODECS = {
+    "utf-8": "utf-8-sig",
+    "utf-16-le": "utf-16",
+    "utf-16-be": "utf-16",
+    "utf-32-le": "utf-32",
+    "utf-32-be": "utf-32",
+}
+
+# Bytes of the start of a content its encoding is guessed from, without a byte order mark
+SAMPLE_BYTES = 65_536
+# Share of the odd (or even) bytes that are NUL for a content without byte order mark to be UTF-16LE (or BE), the
+# other bytes being mostly not NUL: the high bytes of the ASCII characters
+MIN_UTF16_NUL_RATIO = 0.6
+MAX_UTF16_OTHER_NUL_RATIO = 0.1
+# Character the invalid byte sequences are replaced with
+REPLACEMENT_CHARACTER = "\ufffd"
+# Bytes decoded differently by Windows-1252 than by ISO-8859-1 (C1 control characters in ISO-8859-1)
+CP1252_BYTES = range(0x80, 0xA0)
+
+
+@dataclass
+class DecodedText:
+    """Text of a decoded content, with the detected encoding and the invalid byte sequences that were replaced"""
+
+    text: str
+    encoding: str
+    bom: bool = False
+    replaced_count: int = 0
+
+
+class EncodingDetector:
+    """
+    Detect the encoding of the files of a submission so that they are analyzed as the same text whatever the editor
+    saved them with: by their byte order mark, else UTF-16 by the NUL bytes of its ASCII characters, UTF-8 if they
+    decode as such (with a few invalid sequences at most), else Windows-1252 or ISO-8859-1. The invalid byte
+    sequences are replaced with U+FFFD and counted, the same bytes always giving the same text.
+    """
+
+    def detect(self, content: bytes) -> Tuple[str, bool]:
+        """Encoding of a content, and whether it begins with a byte order mark"""
+        bom = self._bom(content)
+        if bom:
+            return bom[1], True
+        sample = content[:SAMPLE_BYTES]
+        utf16 = utf16_byte_order(sample)
+        if utf16:
+            return utf16, False
+        try:
+            codecs.getincrementaldecoder("utf-8")().decode(sample, final=False)
+            return "utf-8", False
+        except UnicodeDecodeError:
+            pass
+        if self._mostly_utf8(sample):
+            return "utf-8", False
+        return ("cp1252" if self._is_cp1252(sample) else "iso-8859-1"), False
+
+    def decode(self, content: bytes) -> DecodedText:
+        """Text of a content in its detected encoding, its byte order mark left out"""
+        encoding, bom = self.detect(content)
+        body = content[len(self._bom(content)[0]) :] if bom else content
+        text = body.decode(encoding, errors="replace")
+        return DecodedText(text=text, encoding=encoding, bom=bom, replaced_count=_replaced_count(body, text, encoding))
+
+    def stream_codec(self, sample: bytes) -> str:
+        """Codec a file beginning with the sample is streamed with, skipping its byte order mark"""
+        encoding, bom = self.detect(sample)
+        return BOM_STREAM_CODECS[encoding] if bom else encoding
+
+    @staticmethod
+    def _bom(content: bytes) -> Optional[Tuple[bytes, str]]:
+        """Byte order mark a content begins with, and its encoding"""
+        return next(((bom, encoding) for bom, encoding in BOMS if content.startswith(bom)), None)
+
+    @staticmethod
+    def _mostly_utf8(sample: bytes) -> bool:
+        """Whether a content not decoding as UTF-8 holds more valid multi-byte sequences than invalid ones"""
+        text = sample.decode("utf-8", errors="replace")
+        multibyte_count = sum(1 for char in text if ord(char) > 0x7F and char != REPLACEMENT_CHARACTER)
+        return multibyte_count > _replaced_count(sample, text, "utf-8")
+
+    @staticmethod
+    def _is_cp1252(sample: bytes) -> bool:
+        """Whether a single-byte content uses the characters of Windows-1252 (curly quotes, euro sign...)"""
+        if not any(byte in CP1252_BYTES for byte in sample):
+            return False
+        try:
+            sample.decode("cp1252")
+            return True
+        except UnicodeDecodeError:
+            return False
+
+
+def utf16_byte_order(sample: bytes) -> Optional[str]:
+    """UTF-16 encoding of a content without byte order mark, by the NUL bytes of its ASCII characters, if any"""
+    if len(sample) < 4:
+        return None
+    even, odd = sample[0::2], sample[1::2]
+    even_ratio = even.count(0) / len(even)
+    odd_ratio = odd.count(0) / len(odd)
+    if odd_ratio >= MIN_UTF16_NUL_RATIO and even_ratio <= MAX_UTF16_OTHER_NUL_RATIO:
+        return "utf-16-le"
+    if even_ratio >= MIN_UTF16_NUL_RATIO and odd_ratio <= MAX_UTF16_OTHER_NUL_RATIO:
+        return "utf-16-be"
+    return None
+
+
+def _replaced_count(content: bytes, text: str, encoding: str) -> int:
+    """Number of invalid byte sequences of a content replaced in its decoded text, not counting the U+FFFD it holds"""
+    return text.count(REPLACEMENT_CHARACTER) - content.decode(encoding, errors="ignore").count(REPLACEMENT_CHARACTER)
@@ -0,0 +1,71 @@
+import hashlib
+import io
+import json
+import stat
+import zipfile
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from app.domains.submissions.zip_streamer import ZipStreamer
+
+# Manifest of an evidence package, listing the size and checksum of each of its other files
+MANIFEST_NAME = "manifest.json"
+
+# Permissions of the files of an evidence package, whoever builds it
+EVIDENCE_FILE_MODE = 0o644
+
+# Operating system recorded with the ZIP entries (Unix), whatever the one building the package
+ZIP_UNIX_SYSTEM = 3
+
+
+class EvidencePackageBuilder:
+    """
+    Case file of a pair of submissions for an academic integrity case, as a ZIP archive closed by its manifest. The
+    archive is reproducible: its entries are sorted by path and share the time the package was generated at (that
+    of the comparison, not of the export) and the same permissions, and its JSON documents are serialized with sorted
+    keys, so that two packages of the same comparison are identical, down to their checksum.
+    """
+
+    def __init__(self, generated_at: datetime):
+        self.generated_at = generated_at
+        self.files: Dict[str, bytes] = {}
+
+    def add(self, name: str, content: bytes) -> None:
+        """Add a file to the package, at its path within the archive"""
+        self.files[name] = content
+
+    def add_json(self, name: str, document: Any) -> None:
+        """Add a JSON document to the package"""
+        self.add(name, self.dumps(document))
+
+    @staticmethod
+    def dumps(document: Any) -> bytes:
+        """Stable serialization of a JSON document, identifiers and dates being written as strings"""
+        return json.dumps(document, indent=2, sort_keys=True, ensure_ascii=False, default=str).encode("utf-8") + b"\n"
+
+    @staticmethod
+    def checksum(content: bytes) -> str:
+        """SHA-256 of a file, or of a whole package"""
+        return hashlib.sha256(content).hexdigest()
+
+    def manifest(self, details: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        """Manifest of the package: what it is the case file of, when it was generated and its files"""
+        files: List[Dict[str, Any]] = [
+            {"path": name, "size_bytes": len(self.files[name]), "sha256": self.checksum(self.files[name])}
+            for name in sorted(self.files)
+        ]
+        return {**(details or {}), "generated_at": self.generated_at.isoformat(), "files": files}
+
+    def build(self, details: Optional[Dict[str, Any]] = None) -> bytes:
+        """Content of the ZIP archive of the package, with its manifest"""
+        files = {**self.files, MANIFEST_NAME: self.dumps(self.manifest(details))}
+        date_time = ZipStreamer.zip_date_time(self.generated_at)
+        buffer = io.BytesIO()
+        with zipfile.ZipFile(buffer, "w", compression=zipfile.ZIP_DEFLATED) as archive:
+            for name in sorted(files):
+                info = zipfile.ZipInfo(name, date_time=date_time)
+                info.compress_type = zipfile.ZIP_DEFLATED
+                info.create_system = ZIP_UNIX_SYSTEM
+                info.external_attr = (stat.S_IFREG | EVIDENCE_FILE_MODE) << 16
+                archive.writestr(info, files[name])
+        return buffer.getvalue()
@@ -0,0 +1,187 @@
+import logging
+import time
+from datetime import datetime
+from typing import Any, Callable, Dict, List
+from uuid import UUID
+
+from app.domains.submissions.evidence_package import EvidencePackageBuilder
+from app.domains.submissions.submissions_models import (
+    SimilarityStatus,
+    Submission,
+    SubmissionDetectionRun,
+    SubmissionReportJob,
+    SubmissionSimilarity,
+)
+from app.shared.exceptions import NotFoundException, ValidationException
+
+logger = logging.getLogger(__name__)
+
+# Scores of a comparison recorded in the JSON document of its evidence package
+EVIDENCE_SCORE_FIELDS = (
+    "overall_similarity",
+    "jaccard_similarity",
+    "type_similarity",
+    "structural_similarity",
+    "type_sequence_similarity",
+    "flow_similarity",
+    "operation_similarity",
+    "average_shared_similarity",
+    "shared_blocks_count",
+    "max_function_similarity",
+)
+
+
+class EvidencePackages:
+    """
+    Evidence packages of the pairs of the detection runs, built in the background as report jobs: the source files
+    of both submissions (read with the given function), the HTML and PDF reports of their comparison (rendered from
+    the report pair of the run with the given functions), a JSON document of its scores, fragments and options, and
+    a manifest of the checksums of the files. A job is built with the given submit function, in a thread with
+    repositories of its own.
+    """
+
+    def __init__(
+        self,
+        run_repository: Any,
+        similarity_repository: Any,
+        submission_repository: Any,
+        report_job_repository: Any,
+        report_pair: Callable[[SubmissionDetectionRun, SubmissionSimilarity], Dict[str, Any]],
+        check_not_quarantined: Callable[[Submission], None],
+        read_files: Callable[[Submission], Dict[str, bytes]],
+        render_html: Callable[[Dict[str, Any]], str],
+        render_pdf: Callable[[Dict[str, Any]], bytes],
+        submit: Callable[..., Any],
+    ):
+        self.run_repository = run_repository
+        self.similarity_repository = similarity_repository
+        self.submission_repository = submission_repository
+        self.report_job_repository = report_job_repository
+        self.report_pair = report_pair
+        self.check_not_quarantined = check_not_quarantined
+        self.read_files = read_files
+        self.render_html = render_html
+        self.render_pdf = render_pdf
+        self.submit = submit
+
+    def request(self, run_id: UUID, similarity_id: UUID) -> SubmissionReportJob:
+        """
+        Build the evidence package of a pair of a detection run in the background, the returned job being polled
+        until it is completed
+
+        Raises:
+            NotFoundException: If the run does not exist, or the comparison is not one of its pairs
+            ValidationException: If the comparison is not completed
+            ConflictException: If the files of a submission are quarantined or purged
+        """
+        run = self.run_repository.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        similarity = self.similarity_repository.get_by_id(similarity_id)
+        pair_ids = {str(similarity.submission_id), str(similarity.compared_submission_id)} if similarity else set()
+        if not pair_ids or not pair_ids <= set(run.submission_ids):
+            raise NotFoundException(f"Pair of detection run {run_id}", str(similarity_id))
+        if similarity.status != SimilarityStatus.COMPLETED:
+            raise ValidationException(f"The comparison {similarity_id} is not completed: {similarity.status.value}")
+
+        submission_ids = [similarity.submission_id, similarity.compared_submission_id]
+        for submission_id in submission_ids:
+            self.check_not_quarantined(self._get_submission(submission_id))
+        pair = self.report_pair(run, similarity)
+        document = self.document(run, similarity, pair)
+        # The time of the comparison, so that every export of it is the same
+        generated_at = similarity.updated_at or similarity.created_at
+
+        job = self.report_job_repository.create(
+            {"similarity_id": similarity_id, "run_id": run_id, "report_format": "evidence"}
+        )
+        self.submit(job.id, pair, document, submission_ids, generated_at)
+        return job
+
+    @staticmethod
+    def document(run: SubmissionDetectionRun, similarity: SubmissionSimilarity, pair: Dict[str, Any]) -> Dict[str, Any]:
+        """Machine-readable record of a comparison of a run: its scores, fragments and options"""
+        details = similarity.similarity_details or {}
+        return {
+            "run": {
+                "id": run.id,
+                "project_uuid": run.project_uuid,
+                "project_step_uuid": run.project_step_uuid,
+                "created_at": run.created_at,
+                "analysis_profile": run.analysis_profile,
+                "effective_options": run.effective_options,
+                "include_same_team": run.include_same_team,
+            },
+            "comparison": {
+                "id": similarity.id,
+                "submission_id": similarity.submission_id,
+                "compared_submission_id": similarity.compared_submission_id,
+                "status": similarity.status,
+                "suspicious": pair["suspicious"],
+                "detection_version": similarity.detection_version,
+                "processing_time_seconds": similarity.processing_time_seconds,
+            },
+            "scores": {field: getattr(similarity, field) for field in EVIDENCE_SCORE_FIELDS},
+            "configuration": pair["configuration"],
+            "fragments": pair["fragments"],
+            "file_similarities": pair["file_similarities"],
+            "function_similarities": details.get("function_similarities"),
+        }
+
+    def build(
+        self,
+        job_id: UUID,
+        pair: Dict[str, Any],
+        document: Dict[str, Any],
+        submission_ids: List[UUID],
+        generated_at: datetime,
+    ) -> None:
+        """
+        Build the evidence package of a comparison and store it with the job: a PDF report failing to render is
+        left out, the manifest telling why
+        """
+        start_time = time.time()
+        try:
+            self.report_job_repository.update_status(job_id, SimilarityStatus.PROCESSING)
+            builder = EvidencePackageBuilder(generated_at)
+            for submission_id in submission_ids:
+                for name, content in self.read_files(self._get_submission(submission_id)).items():
+                    builder.add(f"submissions/{submission_id}/{name}", content)
+            builder.add("report.html", self.render_html(pair).encode("utf-8"))
+            pdf_report_error = None
+            try:
+                builder.add("report.pdf", self.render_pdf(pair))
+            except Exception as e:
+                logger.warning(f"Left the PDF report out of the evidence package of job {job_id}: {str(e)}")
+                pdf_report_error = str(e)
+            builder.add_json("comparison.json", document)
+            content = builder.build(
+                {
+                    "run_id": document["run"]["id"],
+                    "similarity_id": pair["similarity_id"],
+                    "submission_ids": submission_ids,
+                    "pdf_report_error": pdf_report_error,
+                }
+            )
+            self.report_job_repository.update_status(
+                job_id,
+                SimilarityStatus.COMPLETED,
+                content=content,
+                processing_time_seconds=time.time() - start_time,
+                content_sha256=EvidencePackageBuilder.checksum(content),
+            )
+        except Exception as e:
+            logger.error(f"Failed to build the evidence package of job {job_id}: {str(e)}")
+            self.report_job_repository.update_status(job_id, SimilarityStatus.FAILED, error_message=str(e))
+
+    def _get_submission(self, submission_id: UUID) -> Submission:
+        """
+        Get a submission of a pair
+
+        Raises:
+            NotFoundException: If the submission doesn't exist
+        """
+        submission = self.submission_repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        return submission
@@ -0,0 +1,85 @@
+import fnmatch
+from enum import Enum
+from pathlib import PurePosixPath
+from typing import Dict, Iterable, List, Optional, Tuple
+
+from app.domains.repositories.archive_extractor import ArchiveFile
+
+
+class FileFilterMode(str, Enum):
+    """What becomes of an upload holding disallowed files"""
+
+    STRICT = "strict"  # The upload is rejected, listing the disallowed files
+    LENIENT = "lenient"  # The disallowed files are skipped, and logged in the processing log
+
+
+class FileFilter:
+    """
+    Allowlist and denylist of the files of the submissions of a project step, by extension (`.c`, `.tar.gz`) and
+    by glob pattern (`Makefile`, `test_*.py`, `docs/*.pdf`). A pattern without a slash is matched against the file
+    name and against each directory of its path (so `node_modules` denies the whole directory), one with a slash
+    against the whole path. A denied file is never allowed; when an allowlist is given, a file is allowed only if
+    it matches it. Without any list every file is allowed.
+    """
+
+    def __init__(
+        self,
+        mode: FileFilterMode = FileFilterMode.LENIENT,
+        allowed_extensions: Optional[Iterable[str]] = None,
+        denied_extensions: Optional[Iterable[str]] = None,
+        allowed_patterns: Optional[Iterable[str]] = None,
+        denied_patterns: Optional[Iterable[str]] = None,
+    ):
+        self.mode = FileFilterMode(mode)
+        self.allowed_extensions = [self.normalize_extension(ext) for ext in allowed_extensions or []]
+        self.denied_extensions = [self.normalize_extension(ext) for ext in denied_extensions or []]
+        self.allowed_patterns = list(allowed_patterns or [])
+        self.denied_patterns = list(denied_patterns or [])
+
+    @property
+    def enabled(self) -> bool:
+        """Whether any file can be disallowed"""
+        return bool(self.allowed_extensions or self.denied_extensions or self.allowed_patterns or self.denied_patterns)
+
+    @staticmethod
+    def normalize_extension(extension: str) -> str:
+        """Extension in lower case, with its leading dot"""
+        extension = extension.strip().lower()
+        return extension if extension.startswith(".") else f".{extension}"
+
+    def reason(self, path: str) -> Optional[str]:
+        """Reason a file is disallowed, None if it is allowed"""
+        name = PurePosixPath(path).name.lower()
+        denied_extension = next((ext for ext in self.denied_extensions if name.endswith(ext)), None)
+        if denied_extension:
+            return f"denied extension {denied_extension}"
+        denied_pattern = next((pattern for pattern in self.denied_patterns if self._matches(path, pattern)), None)
+        if denied_pattern:
+            return f"denied pattern {denied_pattern}"
+
+        if not self.allowed_extensions and not self.allowed_patterns:
+            return None
+        if any(name.endswith(ext) for ext in self.allowed_extensions):
+            return None
+        if any(self._matches(path, pattern) for pattern in self.allowed_patterns):
+            return None
+        return "not in the allowed extensions nor patterns"
+
+    def filter(self, files: List[ArchiveFile]) -> Tuple[List[ArchiveFile], List[Dict[str, str]]]:
+        """Allowed files, and the disallowed ones as skipped entries with their reason"""
+        allowed, disallowed = [], []
+        for file in files:
+            reason = self.reason(file.path)
+            if reason:
+                disallowed.append({"path": file.path, "reason": f"disallowed file: {reason}"})
+            else:
+                allowed.append(file)
+        return allowed, disallowed
+
+    @staticmethod
+    def _matches(path: str, pattern: str) -> bool:
+        """Whether a path matches a glob pattern, case insensitively"""
+        path, pattern = path.lower(), pattern.lower().strip("/")
+        if "/" in pattern:
+            return fnmatch.fnmatchcase(path, pattern)
+        return any(fnmatch.fnmatchcase(part, pattern) for part in PurePosixPath(path).parts)
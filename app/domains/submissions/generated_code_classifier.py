@@ -0,0 +1,101 @@
+import logging
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, List
+
+logger = logging.getLogger(__name__)
+
+# Markers written by code generators in the header of the files they produce
+GENERATED_MARKERS = re.compile(
+    r"DO NOT EDIT|@generated|Code generated .* DO NOT EDIT|Generated by the protocol buffer compiler"
+    r"|auto-?generated|This file was generated by",
+    re.IGNORECASE,
+)
+# File names of minified bundles and generated sources (bundle.min.js, user.pb.go, user_pb2.py...)
+GENERATED_FILE_NAMES = re.compile(
+    r"(\.min\.(js|css|mjs)|\.bundle\.js|\.pb\.(go|cc|h)|_pb2(_grpc)?\.pyi?|\.pb\.swift|\.g\.dart|\.designer\.cs)$",
+    re.IGNORECASE,
+)
+IDENTIFIER_PATTERN = re.compile(r"\b[A-Za-z_$][A-Za-z0-9_$]*\b")
+COMMENT_PATTERN = re.compile(r"//|/\*|^\s*#|<!--", re.MULTILINE)
+
+# Number of characters of the header searched for generator markers
+HEADER_LENGTH = 2000
+# Thresholds of the minified code heuristics
+MAX_AVERAGE_LINE_LENGTH = 200
+MAX_SINGLE_CHAR_IDENTIFIER_RATIO = 0.4
+MIN_IDENTIFIERS = 50
+MIN_SIZE_WITHOUT_COMMENTS = 2000
+
+
+@dataclass
+class GeneratedCodeClassification:
+    """Whether a file is generated or minified code, and the heuristics that classified it so"""
+
+    file: str
+    kind: str = ""
+    reasons: List[str] = field(default_factory=list)
+    metrics: Dict[str, Any] = field(default_factory=dict)
+
+    @property
+    def is_generated(self) -> bool:
+        return bool(self.kind)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Entry of the analysis report"""
+        return {"file": self.file, "kind": self.kind, "reasons": self.reasons, "metrics": self.metrics}
+
+
+class GeneratedCodeClassifier:
+    """
+    Classify the files of a submission as generated (protobuf, "DO NOT EDIT" / "@generated" headers) or
+    minified code (long lines, mostly single character identifiers and no comments despite the size). These
+    files are shared by everyone using the same tools, so they are excluded from the similarity scoring.
+    """
+
+    def classify(self, file_path: Path, content: str, relative_path: str = "") -> GeneratedCodeClassification:
+        """Classify a file from its name and content"""
+        classification = GeneratedCodeClassification(file=relative_path or file_path.name)
+
+        if GENERATED_FILE_NAMES.search(file_path.name):
+            classification.reasons.append("generated file name")
+        marker = GENERATED_MARKERS.search(content[:HEADER_LENGTH])
+        if marker:
+            classification.reasons.append(f"generator marker '{marker.group(0)}'")
+        if classification.reasons:
+            classification.kind = "generated"
+
+        lines = [line for line in content.splitlines() if line.strip()]
+        identifiers = IDENTIFIER_PATTERN.findall(content)
+        average_line_length = sum(len(line) for line in lines) / len(lines) if lines else 0.0
+        single_char_ratio = (
+            sum(1 for identifier in identifiers if len(identifier) == 1) / len(identifiers) if identifiers else 0.0
+        )
+        has_comments = bool(COMMENT_PATTERN.search(content))
+        classification.metrics = {
+            "average_line_length": round(average_line_length, 1),
+            "single_char_identifier_ratio": round(single_char_ratio, 2),
+            "has_comments": has_comments,
+            "size": len(content),
+        }
+
+        minified_reasons = []
+        if average_line_length > MAX_AVERAGE_LINE_LENGTH:
+            minified_reasons.append(f"average line length {average_line_length:.0f} > {MAX_AVERAGE_LINE_LENGTH}")
+        if (
+            len(identifiers) >= MIN_IDENTIFIERS
+            and single_char_ratio > MAX_SINGLE_CHAR_IDENTIFIER_RATIO
+            and not has_comments
+            and len(content) >= MIN_SIZE_WITHOUT_COMMENTS
+        ):
+            minified_reasons.append(
+                f"{single_char_ratio:.0%} single character identifiers and no comments in {len(content)} characters"
+            )
+        if minified_reasons:
+            classification.reasons.extend(minified_reasons)
+            classification.kind = classification.kind or "minified"
+
+        if classification.is_generated:
+            logger.debug(f"Classified {classification.file} as {classification.kind}: {classification.reasons}")
+        return classification
@@ -0,0 +1,279 @@
+import logging
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, List, Optional, Set
+
+logger = logging.getLogger(__name__)
+
+# Operating systems and architectures known by the Go toolchain, used for file name constraints (x_linux_amd64.go)
+KNOWN_GOOS = {
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "js",
+    "linux",
+    "nacl",
+    "netbsd",
+    "openbsd",
+    "plan9",
+    "solaris",
+    "wasip1",
+    "windows",
+    "zos",
+}
+KNOWN_GOARCH = {
+    "386",
+    "amd64",
+    "arm",
+    "arm64",
+    "loong64",
+    "mips",
+    "mips64",
+    "mips64le",
+    "mipsle",
+    "ppc64",
+    "ppc64le",
+    "riscv64",
+    "s390x",
+    "wasm",
+}
+UNIX_GOOS = {
+    "aix",
+    "android",
+    "darwin",
+    "dragonfly",
+    "freebsd",
+    "hurd",
+    "illumos",
+    "ios",
+    "linux",
+    "netbsd",
+    "openbsd",
+    "solaris",
+}
+
+PACKAGE_CLAUSE_PATTERN = re.compile(r"^\s*package\s+(\w+)", re.MULTILINE)
+BUILD_CONSTRAINT_TOKEN_PATTERN = re.compile(r"\s*(&&|\|\||!|\(|\)|[\w.]+)")
+
+
+@dataclass
+class GoPackagePreprocessingResult:
+    """Files to analyze once Go files are grouped by package, and the report of the grouping decisions"""
+
+    files: List[Path]
+    packages: List[Dict[str, Any]] = field(default_factory=list)
+    skipped_files: List[Dict[str, str]] = field(default_factory=list)
+    platform: str = ""
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Report stored in the analysis result"""
+        return {"platform": self.platform, "packages": self.packages, "skipped_files": self.skipped_files}
+
+
+class GoPackagePreprocessor:
+    """
+    Group the Go files of a submission by package so that a package split across several files is analyzed as
+    a unit: files of a same package (directory and package clause) are made contiguous and sorted by name,
+    packages themselves being sorted by directory. Files excluded from the build for the configured platform
+    (//go:build and legacy // +build lines, _GOOS/_GOARCH file name suffixes) and, optionally, _test.go files
+    are skipped. Other files keep their (sorted) position after the Go packages.
+    """
+
+    def __init__(self, goos: str = "linux", goarch: str = "amd64", skip_test_files: bool = True):
+        self.goos = goos
+        self.goarch = goarch
+        self.skip_test_files = skip_test_files
+
+    def prepare(
+        self, files: List[Path], repo_path: Path, contents: Optional[Dict[Path, str]] = None
+    ) -> GoPackagePreprocessingResult:
+        """
+        Order the files of a submission and skip the Go files not part of the build.
+
+        Args:
+            files: Supported files of the submission
+            repo_path: Root of the submission, used for the relative paths of the report
+            contents: Already read file contents (optional, files are read otherwise)
+        """
+        result = GoPackagePreprocessingResult(files=[], platform=f"{self.goos}/{self.goarch}")
+        packages: Dict[tuple, List[Path]] = {}
+        other_files = []
+
+        for file_path in sorted(files):
+            if file_path.suffix != ".go":
+                other_files.append(file_path)
+                continue
+
+            relative_path = self._relative(file_path, repo_path)
+            content = contents.get(file_path) if contents else None
+            if content is None:
+                content = self._read(file_path)
+
+            reason = self._skip_reason(file_path, content)
+            if reason:
+                result.skipped_files.append({"file": relative_path, "reason": reason})
+                logger.debug(f"Skipping Go file {relative_path}: {reason}")
+                continue
+
+            package_match = PACKAGE_CLAUSE_PATTERN.search(self._strip_comments(content))
+            package_name = package_match.group(1) if package_match else ""
+            directory = self._relative(file_path.parent, repo_path)
+            packages.setdefault((directory, package_name), []).append(file_path)
+
+        for (directory, package_name), package_files in sorted(packages.items()):
+            result.files.extend(package_files)
+            result.packages.append(
+                {
+                    "directory": directory,
+                    "package": package_name,
+                    "files": [self._relative(file_path, repo_path) for file_path in package_files],
+                }
+            )
+
+        result.files.extend(other_files)
+        logger.info(
+            f"Grouped Go files in {len(result.packages)} packages for {result.platform}, "
+            f"skipped {len(result.skipped_files)} files"
+        )
+        return result
+
+    def _skip_reason(self, file_path: Path, content: str) -> Optional[str]:
+        """Get the reason why a Go file is not part of the build for the configured platform, if any"""
+        name = file_path.name
+        if name.startswith(("_", ".")):
+            return "ignored file name"
+        if self.skip_test_files and name.endswith("_test.go"):
+            return "test file"
+        if not self._matches_file_name(name):
+            return f"file name constraint excludes {self.goos}/{self.goarch}"
+
+        constraint = self._build_constraint(content)
+        if constraint is not None and not self.matches_constraint(constraint):
+            return f"build constraint '{constraint}' excludes {self.goos}/{self.goarch}"
+        return None
+
+    def _matches_file_name(self, name: str) -> bool:
+        """Check the implicit _GOOS, _GOARCH and _GOOS_GOARCH constraints of a file name"""
+        parts = name[: -len(".go")].split("_")
+        if name.endswith("_test.go"):
+            parts = parts[:-1]
+        if len(parts) < 2:
+            return True
+
+        tags = self._satisfied_tags()
+        last = parts[-1]
+        if len(parts) >= 3 and parts[-2] in KNOWN_GOOS and last in KNOWN_GOARCH:
+            return parts[-2] in tags and last == self.goarch
+        if last in KNOWN_GOOS:
+            return last in tags
+        if last in KNOWN_GOARCH:
+            return last == self.goarch
+        return True
+
+    @staticmethod
+    def _build_constraint(content: str) -> Optional[str]:
+        """Get the build constraint expression of a file (lines before the package clause)"""
+        legacy_lines = []
+        for line in content.splitlines():
+            stripped = line.strip()
+            if stripped.startswith("//go:build"):
+                return stripped[len("//go:build") :].strip()
+            if stripped.startswith("// +build"):
+                # Legacy syntax: space separated options are OR-ed, comma separated terms AND-ed, lines AND-ed
+                options = stripped[len("// +build") :].split()
+                legacy_lines.append(
+                    "(" + " || ".join("(" + " && ".join(option.split(",")) + ")" for option in options) + ")"
+                )
+                continue
+            if stripped.startswith("package ") or (stripped and not stripped.startswith("//")):
+                break
+        if legacy_lines:
+            return " && ".join(legacy_lines)
+        return None
+
+    def matches_constraint(self, constraint: str) -> bool:
+        """Evaluate a build constraint expression (`linux && (amd64 || arm64)`, `!windows`) for the platform"""
+        tokens = [token for token in BUILD_CONSTRAINT_TOKEN_PATTERN.findall(constraint) if token]
+        position = 0
+
+        def parse_or() -> bool:
+            nonlocal position
+            value = parse_and()
+            while position < len(tokens) and tokens[position] == "||":
+                position += 1
+                value = parse_and() or value
+            return value
+
+        def parse_and() -> bool:
+            nonlocal position
+            value = parse_not()
+            while position < len(tokens) and tokens[position] == "&&":
+                position += 1
+                value = parse_not() and value
+            return value
+
+        def parse_not() -> bool:
+            nonlocal position
+            if position < len(tokens) and tokens[position] == "!":
+                position += 1
+                return not parse_not()
+            if position < len(tokens) and tokens[position] == "(":
+                position += 1
+                value = parse_or()
+                position += 1  # closing parenthesis
+                return value
+            if position >= len(tokens):
+                return False
+            tag = tokens[position]
+            position += 1
+            return tag in self._satisfied_tags()
+
+        try:
+            return parse_or()
+        except Exception as e:
+            logger.warning(f"Failed to evaluate Go build constraint '{constraint}': {e}")
+            return True
+
+    def _satisfied_tags(self) -> Set[str]:
+        """Build tags satisfied by the configured platform"""
+        tags = {self.goos, self.goarch, "gc"}
+        if self.goos in UNIX_GOOS:
+            tags.add("unix")
+        if self.goos == "android":
+            tags.add("linux")
+        if self.goos == "ios":
+            tags.add("darwin")
+        if self.goos == "illumos":
+            tags.add("solaris")
+        # Release tags: any go1.N version is considered satisfied
+        tags.update(f"go1.{minor}" for minor in range(1, 40))
+        return tags
+
+    @staticmethod
+    def _strip_comments(content: str) -> str:
+        """Remove line and block comments, so that a commented package clause is not matched"""
+        content = re.sub(r"/\*.*?\*/", "", content, flags=re.DOTALL)
+        return re.sub(r"//[^\n]*", "", content)
+
+    @staticmethod
+    def _relative(path: Path, repo_path: Path) -> str:
+        """Get a path relative to the submission root"""
+        try:
+            return str(path.relative_to(repo_path)) or "."
+        except ValueError:
+            return str(path)
+
+    @staticmethod
+    def _read(file_path: Path) -> str:
+        """Read a Go source file (Go sources are UTF-8)"""
+        try:
+            return file_path.read_text(encoding="utf-8", errors="replace")
+        except OSError as e:
+            logger.warning(f"Failed to read {file_path}: {e}")
+            return ""
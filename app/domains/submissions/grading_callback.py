@@ -0,0 +1,63 @@
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from app.domains.submissions.submissions_models import GradingCallbackStatus, SubmissionDetectionRun
+from app.domains.submissions.webhook_delivery import WebhookAttempt
+
+# Version of the schema of the payload, raised on any change the grading service has to adapt to, and sent in the
+# SCHEMA_VERSION_HEADER header too, so that it can tell the versions apart before parsing the body
+GRADING_SCHEMA_VERSION = 1
+SCHEMA_VERSION_HEADER = "X-Callback-Schema-Version"
+
+# Event of the deliveries, in the X-Webhook-Event header, their X-Webhook-Delivery being the ID of the run
+GRADING_EVENT = "detection_run.results"
+
+
+def grading_payload(run: SubmissionDetectionRun, matrix: Dict[str, Any]) -> Dict[str, Any]:
+    """
+    Results of a completed run for the grading service, from its similarity matrix: the flagged pairs, and the
+    highest similarity of each submission (None for a submission none of whose comparisons completed)
+    """
+    return {
+        "schema_version": GRADING_SCHEMA_VERSION,
+        "event": GRADING_EVENT,
+        "run_id": str(run.id),
+        "project_uuid": str(run.project_uuid),
+        "project_step_uuid": str(run.project_step_uuid),
+        "completed_at": run.completed_at.isoformat() if run.completed_at else None,
+        "flag_threshold": matrix["flag_threshold"],
+        "flagged_pairs": [
+            {
+                "submission_id": str(entry["submission_id"]),
+                "compared_submission_id": str(entry["compared_submission_id"]),
+                "overall_similarity": entry["overall_similarity"],
+                "same_team": entry["same_team"],
+            }
+            for entry in matrix["flagged_pairs"]
+        ],
+        "submissions": [
+            {
+                "submission_id": str(maximum["submission_id"]),
+                "max_similarity": maximum["max_similarity"],
+                "most_similar_submission_id": (
+                    str(maximum["most_similar_submission_id"]) if maximum["most_similar_submission_id"] else None
+                ),
+            }
+            for maximum in matrix["max_similarities"]
+        ],
+    }
+
+
+def attempt_changes(run: SubmissionDetectionRun, result: WebhookAttempt, at: datetime) -> Dict[str, Any]:
+    """
+    Changes of a run recording an attempt to deliver its results, delivered only once answered with a 2xx, failed
+    otherwise until re-delivered: the grading service acknowledges the results, they are not retried on their own
+    """
+    attempts = list(run.grading_callback_attempts or [])
+    status = GradingCallbackStatus.DELIVERED if result.succeeded else GradingCallbackStatus.FAILED
+    delivered_at: Optional[datetime] = at if result.succeeded else None
+    return {
+        "grading_callback_status": status,
+        "grading_callback_attempts": attempts + [result.record(len(attempts) + 1, at, None)],
+        "grading_callback_delivered_at": delivered_at,
+    }
@@ -0,0 +1,142 @@
+import logging
+import secrets
+from datetime import datetime
+from typing import Any, Callable, Dict
+from uuid import UUID
+
+from app.domains.submissions.grading_callback import (
+    GRADING_EVENT,
+    GRADING_SCHEMA_VERSION,
+    SCHEMA_VERSION_HEADER,
+    attempt_changes,
+    grading_payload,
+)
+from app.domains.submissions.submissions_models import (
+    DetectionRunStatus,
+    GradingCallbackStatus,
+    SubmissionDetectionRun,
+    SubmissionGradingCallbackConfig,
+)
+from app.domains.submissions.webhook_delivery import WebhookSender, encode_payload
+from app.shared.exceptions import ConflictException, NotFoundException, ValidationException
+
+logger = logging.getLogger(__name__)
+
+
+class GradingCallbacks:
+    """
+    Grading callbacks of the project steps, the results of their completed detection runs being posted to them:
+    a run is marked pending then dispatched with the given function (run ID), to be delivered in the background
+    from the similarity matrix of the run (read with the given function), signed with the secret of the callback.
+    A callback is only saved with a URL the sender would post to, its host being checked again before each delivery.
+    """
+
+    def __init__(
+        self,
+        config_repository: Any,
+        run_repository: Any,
+        sender: WebhookSender,
+        run_matrix: Callable[[UUID], Dict[str, Any]],
+        dispatch: Callable[[UUID], Any],
+    ):
+        self.config_repository = config_repository
+        self.run_repository = run_repository
+        self.sender = sender
+        self.run_matrix = run_matrix
+        self.dispatch = dispatch
+
+    def save(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: Dict[str, Any]
+    ) -> SubmissionGradingCallbackConfig:
+        """
+        Set the grading callback of a project step, its secret being generated if none is given
+
+        Raises:
+            ValidationException: If the host of its URL is not allowed (see WebhookSender)
+        """
+        denied_reason = self.sender.denied_reason(config_data["url"])
+        if denied_reason:
+            raise ValidationException(f"The grading callback URL is not allowed: {denied_reason}")
+        return self.config_repository.save(
+            project_uuid,
+            project_step_uuid,
+            {**config_data, "secret": config_data.get("secret") or secrets.token_urlsafe(32)},
+        )
+
+    def get(self, project_uuid: UUID, project_step_uuid: UUID) -> SubmissionGradingCallbackConfig:
+        """
+        Get the grading callback of a project step
+
+        Raises:
+            NotFoundException: If the step has no grading callback
+        """
+        config = self.config_repository.get_by_project_step(project_uuid, project_step_uuid)
+        if not config:
+            raise NotFoundException("Grading callback", f"{project_uuid}/{project_step_uuid}")
+        return config
+
+    def delete(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the grading callback of a project step, the results of its next runs being posted nowhere"""
+        return self.config_repository.delete(project_uuid, project_step_uuid)
+
+    def send(self, run: SubmissionDetectionRun) -> None:
+        """Post the results of a completed run to the grading callback of its step if any, in the background"""
+        try:
+            if not self.config_repository.get_by_project_step(run.project_uuid, run.project_step_uuid):
+                return
+            self.run_repository.update(run.id, {"grading_callback_status": GradingCallbackStatus.PENDING})
+            self.dispatch(run.id)
+        except Exception as e:
+            logger.error(f"Failed to send the results of detection run {run.id} to the grading service: {str(e)}")
+
+    def redeliver(self, run_id: UUID) -> SubmissionDetectionRun:
+        """
+        Post again the results of a completed run to the grading callback of its step, in the background, whether
+        its last delivery failed or not
+
+        Raises:
+            NotFoundException: If the run or the grading callback of its step doesn't exist
+            ConflictException: If the run is not completed
+        """
+        run = self.run_repository.get_by_id(run_id)
+        if not run:
+            raise NotFoundException("Detection run", str(run_id))
+        status = DetectionRunStatus(run.status)
+        if status != DetectionRunStatus.COMPLETED:
+            message = f"Only the results of a completed run are delivered, detection run {run_id} is {status.value}"
+            error_type = {
+                DetectionRunStatus.RUNNING: "run_in_progress",
+                DetectionRunStatus.FAILED: "detection_run_failed",
+            }.get(status, "detection_run_cancelled")
+            raise ConflictException(message, details={"error_type": error_type, "message": message})
+        self.get(run.project_uuid, run.project_step_uuid)
+        run = self.run_repository.update(run_id, {"grading_callback_status": GradingCallbackStatus.PENDING})
+        self.dispatch(run_id)
+        return run
+
+    def deliver(self, run_id: UUID, now: datetime) -> None:
+        """
+        Post the results of a pending run to the grading callback of its step, signed with its secret, the run
+        being delivered once answered with a 2xx and failed otherwise
+        """
+        run = self.run_repository.get_by_id(run_id)
+        if not run or run.grading_callback_status != GradingCallbackStatus.PENDING:
+            return
+        config = self.config_repository.get_by_project_step(run.project_uuid, run.project_step_uuid)
+        if not config:
+            self.run_repository.update(run_id, {"grading_callback_status": GradingCallbackStatus.FAILED})
+            return
+
+        result = self.sender.send(
+            config.url,
+            config.secret,
+            GRADING_EVENT,
+            str(run_id),
+            encode_payload(grading_payload(run, self.run_matrix(run_id))),
+            headers={SCHEMA_VERSION_HEADER: str(GRADING_SCHEMA_VERSION)},
+        )
+        self.run_repository.update(run_id, attempt_changes(run, result, now))
+        if result.succeeded:
+            logger.info(f"Delivered the results of detection run {run_id} to the grading service")
+        else:
+            logger.error(f"Grading service failed to receive detection run {run_id}: {result.error}")
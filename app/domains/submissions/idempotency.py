@@ -0,0 +1,46 @@
+import hashlib
+import json
+from enum import Enum
+from typing import Any, Optional
+
+# Scope of the requests made without any client credential
+ANONYMOUS_CLIENT_SCOPE = "anonymous"
+
+
+class IdempotencyDecision(str, Enum):
+    """What becomes of a request carrying an idempotency key"""
+
+    EXECUTE = "execute"  # First request with the key
+    REPLAY = "replay"  # Retry of a completed request, its stored response being returned
+    CONFLICT = "conflict"  # Same key, another payload
+    IN_PROGRESS = "in_progress"  # Retry of a request still being executed
+
+
+class Idempotency:
+    """
+    Idempotency keys of the creation requests: the first request with a key is executed and its response stored,
+    the retries with the same key and payload get the stored response, and the same key with another payload is a
+    conflict. The keys are scoped per client credential, so that two integrations never share a key.
+    """
+
+    @staticmethod
+    def client_scope(credential: Optional[str]) -> str:
+        """Scope of the keys of a client, derived from its credential without keeping it"""
+        if not credential:
+            return ANONYMOUS_CLIENT_SCOPE
+        return hashlib.sha256(credential.encode("utf-8")).hexdigest()
+
+    @staticmethod
+    def request_hash(endpoint: str, payload: Any) -> str:
+        """Hash of the payload of a request to an endpoint, the same whatever the order of its fields"""
+        canonical = json.dumps({"endpoint": endpoint, "payload": payload}, sort_keys=True, separators=(",", ":"))
+        return hashlib.sha256(canonical.encode("utf-8")).hexdigest()
+
+    @staticmethod
+    def decide(stored_request_hash: Optional[str], completed: bool, request_hash: str) -> IdempotencyDecision:
+        """What becomes of a request, given the request stored for its key if any"""
+        if stored_request_hash is None:
+            return IdempotencyDecision.EXECUTE
+        if stored_request_hash != request_hash:
+            return IdempotencyDecision.CONFLICT
+        return IdempotencyDecision.REPLAY if completed else IdempotencyDecision.IN_PROGRESS
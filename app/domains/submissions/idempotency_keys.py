@@ -0,0 +1,71 @@
+import logging
+from datetime import datetime, timedelta
+from typing import Any
+from uuid import UUID
+
+from app.domains.submissions.idempotency import Idempotency, IdempotencyDecision
+from app.domains.submissions.submissions_models import SubmissionIdempotencyKey
+from app.shared.exceptions import ConflictException
+
+logger = logging.getLogger(__name__)
+
+
+class IdempotencyKeys:
+    """
+    Stored idempotency keys of the creation requests (see Idempotency), each reserved by the first request with it
+    then holding its response, until it expires after the configured time
+    """
+
+    def __init__(self, idempotency_key_repository: Any, ttl_seconds: float):
+        self.idempotency_key_repository = idempotency_key_repository
+        self.ttl_seconds = ttl_seconds
+
+    def begin(
+        self, client_scope: str, key: str, endpoint: str, request_hash: str, now: datetime
+    ) -> SubmissionIdempotencyKey:
+        """
+        Check the idempotency key of a creation request: the returned record holds the stored response to replay
+        once its request completed, else the key is reserved for the request to be executed. The expired keys are
+        deleted beforehand, a new request with an expired key being executed again.
+
+        Raises:
+            ConflictException: If the key was sent with another payload, or its request is still being executed
+        """
+        self.idempotency_key_repository.delete_expired(now)
+        record = self.idempotency_key_repository.get_active(client_scope, key, now)
+        if record is None:
+            record = self.idempotency_key_repository.reserve(
+                {
+                    "client_scope": client_scope,
+                    "key": key,
+                    "endpoint": endpoint,
+                    "request_hash": request_hash,
+                    "expires_at": now + timedelta(seconds=self.ttl_seconds),
+                }
+            )
+            if record is not None:
+                return record
+            # Reserved meanwhile by a concurrent request with the same key
+            record = self.idempotency_key_repository.get_active(client_scope, key, now)
+
+        decision = Idempotency.decide(
+            record.request_hash if record else None, bool(record and record.status_code is not None), request_hash
+        )
+        if decision == IdempotencyDecision.CONFLICT:
+            message = "The idempotency key was already used with another payload"
+            raise ConflictException(message, details={"error_type": "idempotency_key_reused", "message": message})
+        if decision != IdempotencyDecision.REPLAY:
+            message = "The request of the idempotency key is still being executed"
+            raise ConflictException(
+                message, details={"error_type": "idempotency_request_in_progress", "message": message}
+            )
+        logger.info(f"Replaying the response of idempotency key {key} to {endpoint}")
+        return record
+
+    def complete(self, record_id: UUID, status_code: int, response_body: dict) -> None:
+        """Store the response of the request of an idempotency key, replayed to its retries"""
+        self.idempotency_key_repository.complete(record_id, status_code, response_body)
+
+    def release(self, record_id: UUID) -> None:
+        """Release the idempotency key of a failed request, so that it can be retried"""
+        self.idempotency_key_repository.delete(record_id)
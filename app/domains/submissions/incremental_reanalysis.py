@@ -0,0 +1,93 @@
+import hashlib
+from pathlib import Path
+from typing import Callable, Dict, Hashable, Iterable, List, Optional, TypeVar
+
+# Bytes of a file hashed at a time, a large file not being read whole
+HASH_BLOCK_SIZE = 1_048_576
+
+Key = TypeVar("Key", bound=Hashable)
+Result = TypeVar("Result")
+
+
+class IncrementalReanalysis:
+    """
+    Changes of the files of a submission since the analysis of a previous version (or of a previous comparison),
+    from the SHA-256 of their content by relative path: a file is unchanged if the previous analysis had it with the
+    same content, its results being reused, and reprocessed otherwise (changed or added). Without previous hashes,
+    every file is reprocessed.
+
+    The reused results are merged with the recomputed ones in the order of a full recompute, so that a re-analysis
+    is identical to it.
+    """
+
+    def __init__(self, previous_hashes: Optional[Dict[str, str]], current_hashes: Dict[str, str]):
+        self.previous_hashes = previous_hashes or {}
+        self.current_hashes = current_hashes
+
+    @staticmethod
+    def file_hash(path: Path) -> str:
+        """SHA-256 of the content of a file, hexadecimal, like the content hash of its record"""
+        digest = hashlib.sha256()
+        with open(path, "rb") as f:
+            while block := f.read(HASH_BLOCK_SIZE):
+                digest.update(block)
+        return digest.hexdigest()
+
+    def is_unchanged(self, path: str) -> bool:
+        """Whether a file was analyzed previously with the same content"""
+        previous_hash = self.previous_hashes.get(path)
+        return previous_hash is not None and previous_hash == self.current_hashes.get(path)
+
+    @property
+    def reused(self) -> List[str]:
+        """Unchanged files, whose previous results are reused"""
+        return [path for path in self.current_hashes if self.is_unchanged(path)]
+
+    @property
+    def reprocessed(self) -> List[str]:
+        """Changed and added files, processed again"""
+        return [path for path in self.current_hashes if not self.is_unchanged(path)]
+
+    @property
+    def removed(self) -> List[str]:
+        """Files of the previous analysis no longer in the submission"""
+        return sorted(set(self.previous_hashes) - set(self.current_hashes))
+
+    @staticmethod
+    def merge(
+        keys: Iterable[Key],
+        previous: Dict[Key, Result],
+        compute: Callable[[Key], Optional[Result]],
+        reusable: Callable[[Key], bool],
+    ) -> List[Result]:
+        """
+        Results of the keys (files or pairs of files) in order: the previous one of a reusable key, else computed.
+        A reusable key without previous result had none (None from `compute`, left out), and still has none.
+        """
+        results = []
+        for key in keys:
+            result = previous.get(key) if reusable(key) else compute(key)
+            if result is not None:
+                results.append(result)
+        return results
+
+    def log_entries(self, previous_version: int) -> List[Dict[str, str]]:
+        """Entries of the processing log stating the files reused from a previous version and those reprocessed"""
+        entries = []
+        if self.reused:
+            entries.append(
+                {
+                    "level": "info",
+                    "message": f"Reused the analysis of {len(self.reused)} files unchanged since version "
+                    f"{previous_version}: {', '.join(self.reused)}",
+                }
+            )
+        if self.reprocessed:
+            entries.append(
+                {
+                    "level": "info",
+                    "message": f"Reprocessed {len(self.reprocessed)} files changed or added since version "
+                    f"{previous_version}: {', '.join(self.reprocessed)}",
+                }
+            )
+        return entries
@@ -0,0 +1,329 @@
+import hashlib
+import json
+import logging
+import re
+import threading
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, Optional
+from urllib.parse import urlparse
+from uuid import UUID, uuid4
+
+try:
+    import pika
+except ImportError:
+    pika = None
+
+from app.domains.submissions.analysis_worker_pool import AnalysisQueueFull
+from app.shared.log_context import current_log_fields, log_context
+
+logger = logging.getLogger(__name__)
+
+# Header of the number of failed attempts of a message, republished with it incremented until dead-lettered
+ATTEMPTS_HEADER = "x-pamp-attempts"
+
+# Scope and endpoint of the idempotency keys of the ingested messages, apart from those of the HTTP clients
+IDEMPOTENCY_SCOPE = "ingestion"
+IDEMPOTENCY_ENDPOINT = "ingest_submission"
+
+USER_AGENT = "PAMP ingestion consumer"
+
+CHECKSUM_PATTERN = re.compile(r"^(sha256:)?([0-9a-fA-F]{64})$")
+
+# Outcomes of a message
+INGESTED = "ingested"
+DUPLICATE = "duplicate"
+RETRIED = "retried"
+DEAD_LETTERED = "dead_lettered"
+
+
+class InvalidIngestionMessage(ValueError):
+    """Raised for a message not matching the schema, dead-lettered at once since no attempt could succeed"""
+
+
+class ChecksumMismatch(ValueError):
+    """Raised when the downloaded file does not match the checksum of its message"""
+
+
+@dataclass(frozen=True)
+class IngestionMessage:
+    """
+    "Submission uploaded" event of the LMS, version 1 of its schema:
+    {"project_id", "assignment_id" (the project step), "student_id", "group_id" (the student if none), "file_url",
+    "checksum" ("sha256:" and the hexadecimal SHA-256 of the file), "filename" (the name of the URL path if none)}
+    """
+
+    project_uuid: UUID
+    assignment_id: UUID
+    student_id: UUID
+    group_uuid: UUID
+    file_url: str
+    checksum: str
+    filename: Optional[str] = None
+
+    @classmethod
+    def parse(cls, body: bytes) -> "IngestionMessage":
+        """
+        Raises:
+            InvalidIngestionMessage: If the body is not a JSON object of the schema
+        """
+        try:
+            data = json.loads(body)
+        except ValueError as e:
+            raise InvalidIngestionMessage(f"The message is not JSON: {str(e)}")
+        if not isinstance(data, dict):
+            raise InvalidIngestionMessage("The message is not a JSON object")
+
+        required = ("project_id", "assignment_id", "student_id", "file_url", "checksum")
+        missing = [field for field in required if not data.get(field)]
+        if missing:
+            raise InvalidIngestionMessage(f"Missing fields: {', '.join(missing)}")
+        parsed_url = urlparse(str(data["file_url"]))
+        if parsed_url.scheme not in ("http", "https") or not parsed_url.hostname:
+            raise InvalidIngestionMessage("The file URL must be an http or https URL")
+        checksum = CHECKSUM_PATTERN.match(str(data["checksum"]))
+        if not checksum:
+            raise InvalidIngestionMessage("The checksum must be the hexadecimal SHA-256 of the file")
+        try:
+            student_id = UUID(str(data["student_id"]))
+            return cls(
+                project_uuid=UUID(str(data["project_id"])),
+                assignment_id=UUID(str(data["assignment_id"])),
+                student_id=student_id,
+                group_uuid=UUID(str(data["group_id"])) if data.get("group_id") else student_id,
+                file_url=str(data["file_url"]),
+                checksum=checksum.group(2).lower(),
+                filename=data.get("filename") or None,
+            )
+        except ValueError as e:
+            raise InvalidIngestionMessage(f"Invalid UUID: {str(e)}")
+
+    @property
+    def idempotency_key(self) -> str:
+        """Key of the message, the same file of a student for an assignment being ingested once"""
+        return f"{self.assignment_id}:{self.student_id}:{self.checksum}"
+
+    def idempotency_payload(self) -> Dict[str, Any]:
+        """Payload the key is checked against, without the URL, a new one being signed for each publication"""
+        return {
+            "project_uuid": str(self.project_uuid),
+            "project_step_uuid": str(self.assignment_id),
+            "group_uuid": str(self.group_uuid),
+            "submitted_by_uuid": str(self.student_id),
+            "checksum": self.checksum,
+        }
+
+    def verify(self, content: bytes) -> None:
+        """
+        Raises:
+            ChecksumMismatch: If the content is not the file of the message
+        """
+        actual = hashlib.sha256(content).hexdigest()
+        if actual != self.checksum:
+            raise ChecksumMismatch(f"The downloaded file has the SHA-256 {actual}, not {self.checksum}")
+
+
+def ingest_submission(message: IngestionMessage) -> str:
+    """
+    Create the submission of a message through the service layer, as the uploads of the HTTP API: the file is
+    downloaded within the upload limits of its step and checked against its checksum. A message already ingested
+    is recognized by its idempotency key, and not ingested again.
+
+    Raises:
+        ConflictException: If the same message is being ingested meanwhile (idempotency_request_in_progress)
+    """
+    from fastapi.encoders import jsonable_encoder
+
+    from app.config.config import get_settings
+    from app.domains.repositories.fetchers.url_source_fetcher import UrlSourceFetcher
+    from app.domains.submissions.submissions_service import SubmissionService
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        service = SubmissionService(session)
+        record = service.begin_idempotent_request(
+            IDEMPOTENCY_SCOPE, message.idempotency_key, IDEMPOTENCY_ENDPOINT, message.idempotency_payload()
+        )
+        if record.status_code is not None:
+            return DUPLICATE
+        try:
+            limits = service.get_upload_limits(message.project_uuid, message.assignment_id)
+            allowed_hosts = {host.lower() for host in get_settings().ingestion_allowed_hosts}
+            fetcher = UrlSourceFetcher(
+                max_bytes=limits.max_upload_bytes,
+                host_policy=(lambda host: host in allowed_hosts) if allowed_hosts else None,
+            )
+            content, url_filename = fetcher.fetch_bytes(message.file_url)
+            message.verify(content)
+            response = service.upload_submission(
+                content=content,
+                filename=message.filename or url_filename,
+                submission_data={
+                    "project_uuid": message.project_uuid,
+                    "group_uuid": message.group_uuid,
+                    "project_step_uuid": message.assignment_id,
+                    "submitted_by_uuid": message.student_id,
+                    "description": None,
+                },
+                user_agent=USER_AGENT,
+            )
+        except Exception:
+            service.release_idempotency_key(record.id)
+            raise
+        service.complete_idempotent_request(record.id, 201, jsonable_encoder(response))
+        logger.info(f"Ingested submission {response.submission_id} of student {message.student_id}")
+        return INGESTED
+    finally:
+        session.close()
+
+
+class IngestionConsumer:
+    """
+    Consumer of the "submission uploaded" events of the LMS from a RabbitMQ queue, in a thread of its own, one
+    message at a time. A message is acked only once ingested (or recognized as a duplicate); a failed one is
+    republished to "<queue>.retry", whose messages go back to the queue after the retry delay, until its attempts
+    are exhausted and it is dead-lettered to "<queue>.dead", as a message not matching the schema at once. The
+    connection is opened again after a delay when lost.
+
+    Stopping lets the message being ingested finish; the messages not acked are redelivered by the broker.
+    """
+
+    def __init__(
+        self,
+        amqp_url: str,
+        queue: str,
+        max_attempts: int = 5,
+        retry_delay_seconds: float = 30.0,
+        ingest: Callable[[IngestionMessage], str] = ingest_submission,
+        reconnect_delay_seconds: float = 5.0,
+    ):
+        self.amqp_url = amqp_url
+        self.queue = queue
+        self.retry_queue = f"{queue}.retry"
+        self.dead_letter_queue = f"{queue}.dead"
+        self.max_attempts = max_attempts
+        self.retry_delay_seconds = retry_delay_seconds
+        self.ingest = ingest
+        self.reconnect_delay_seconds = reconnect_delay_seconds
+        self._stopping = threading.Event()
+        self._thread: Optional[threading.Thread] = None
+
+    def start(self) -> None:
+        if pika is None:
+            logger.error("The ingestion consumer is enabled but pika is not installed")
+            return
+        self._thread = threading.Thread(target=self._run, name="ingestion-consumer", daemon=True)
+        self._thread.start()
+        logger.info(f"Consuming the submissions uploaded to the LMS from queue {self.queue}")
+
+    def stop(self, timeout_seconds: float = 30.0) -> None:
+        """Stop consuming, the message being ingested being given the timeout to finish"""
+        self._stopping.set()
+        if self._thread is not None:
+            self._thread.join(timeout_seconds)
+            if self._thread.is_alive():
+                logger.warning(f"The ingestion consumer did not stop within {timeout_seconds} seconds")
+
+    def handle(self, channel: Any, method: Any, properties: Any, body: bytes) -> str:
+        """Ingest a message, then ack, retry or dead-letter it, returning its outcome"""
+        # The logs of a message carry its correlation ID, sent by the LMS or generated
+        with log_context(correlation_id=getattr(properties, "correlation_id", None) or uuid4().hex):
+            return self._handle(channel, method, properties, body)
+
+    def _handle(self, channel: Any, method: Any, properties: Any, body: bytes) -> str:
+        headers = dict(getattr(properties, "headers", None) or {})
+        attempts = int(headers.get(ATTEMPTS_HEADER, 0))
+        try:
+            message = IngestionMessage.parse(body)
+        except InvalidIngestionMessage as e:
+            logger.error(f"Dead-lettered an invalid ingestion message: {str(e)}")
+            channel.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
+            return DEAD_LETTERED
+
+        try:
+            outcome = self.ingest(message)
+        except AnalysisQueueFull:
+            # Not a failure of the message: the analysis is saturated
+            return self._retry(channel, method, properties, body, attempts)
+        except Exception as e:
+            detail = getattr(e, "detail", None)
+            if isinstance(detail, dict) and detail.get("error_type") == "idempotency_request_in_progress":
+                # Nor is the same message being ingested meanwhile, acked once it is found a duplicate
+                return self._retry(channel, method, properties, body, attempts)
+            return self._fail(channel, method, properties, body, attempts + 1, str(detail or e))
+
+        channel.basic_ack(delivery_tag=method.delivery_tag)
+        return outcome
+
+    def _fail(self, channel: Any, method: Any, properties: Any, body: bytes, attempts: int, error: str) -> str:
+        if attempts >= self.max_attempts:
+            logger.error(f"Dead-lettered an ingestion message after {attempts} attempts: {error}")
+            channel.basic_nack(delivery_tag=method.delivery_tag, requeue=False)
+            return DEAD_LETTERED
+        logger.warning(f"Ingestion attempt {attempts} failed, retried in {self.retry_delay_seconds}s: {error}")
+        return self._retry(channel, method, properties, body, attempts)
+
+    def _retry(self, channel: Any, method: Any, properties: Any, body: bytes, attempts: int) -> str:
+        """Republish a message to the retry queue with its attempts, then ack it"""
+        headers = {**dict(getattr(properties, "headers", None) or {}), ATTEMPTS_HEADER: attempts}
+        channel.basic_publish(
+            exchange="", routing_key=self.retry_queue, body=body, properties=self._properties(properties, headers)
+        )
+        channel.basic_ack(delivery_tag=method.delivery_tag)
+        return RETRIED
+
+    @staticmethod
+    def _properties(properties: Any, headers: Dict[str, Any]) -> Any:
+        return pika.BasicProperties(
+            content_type=getattr(properties, "content_type", None) or "application/json",
+            correlation_id=current_log_fields().get("correlation_id"),
+            delivery_mode=2,
+            headers=headers,
+        )
+
+    def _run(self) -> None:
+        while not self._stopping.is_set():
+            try:
+                self._consume()
+            except Exception as e:
+                if self._stopping.is_set():
+                    break
+                logger.error(f"Ingestion consumer disconnected, reconnecting in {self.reconnect_delay_seconds}s: {e}")
+                self._stopping.wait(self.reconnect_delay_seconds)
+
+    def _consume(self) -> None:
+        connection = pika.BlockingConnection(pika.URLParameters(self.amqp_url))
+        try:
+            channel = connection.channel()
+            self._declare(channel)
+            channel.basic_qos(prefetch_count=1)
+            # Woken up every second without a message, to notice a stop
+            for method, properties, body in channel.consume(self.queue, inactivity_timeout=1.0):
+                if self._stopping.is_set():
+                    break
+                if method is not None:
+                    self.handle(channel, method, properties, body)
+            # The messages delivered but not acked are requeued by the broker
+            channel.cancel()
+        finally:
+            if connection.is_open:
+                connection.close()
+
+    def _declare(self, channel: Any) -> None:
+        """Declare the queue, its retry queue and its dead-letter queue, durable"""
+        channel.queue_declare(queue=self.dead_letter_queue, durable=True)
+        channel.queue_declare(
+            queue=self.queue,
+            durable=True,
+            arguments={"x-dead-letter-exchange": "", "x-dead-letter-routing-key": self.dead_letter_queue},
+        )
+        channel.queue_declare(
+            queue=self.retry_queue,
+            durable=True,
+            arguments={
+                "x-message-ttl": int(self.retry_delay_seconds * 1000),
+                "x-dead-letter-exchange": "",
+                "x-dead-letter-routing-key": self.queue,
+            },
+        )
+
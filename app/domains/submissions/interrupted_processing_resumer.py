@@ -0,0 +1,18 @@
+import logging
+
+logger = logging.getLogger(__name__)
+
+
+def resume_interrupted_processing() -> int:
+    """Queue again the processing of the submissions interrupted by the last shutdown, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).resume_interrupted_processing()
+    except Exception as e:
+        logger.error(f"Failed to resume the interrupted submissions: {e}")
+        return 0
+    finally:
+        session.close()
@@ -0,0 +1,72 @@
+from typing import Any, Dict, List, Optional
+
+from app.domains.submissions.submissions_models import Submission
+
+# Counts of the statistics besides the languages, over the submissions of the step
+COUNTS = (
+    "analyzed_submission_count",
+    "plain_text_file_count",
+    "plain_text_submission_count",
+    "low_confidence_file_count",
+    "low_confidence_submission_count",
+)
+
+
+class LanguageStatistics:
+    """
+    Languages the submissions of a project step are written in, from their stored code metrics and language
+    detection, never reading their files again. The primary language of a submission is that of most of its lines of
+    code, the tie going to the first language by name; the files of no known language, compared as plain text, and
+    those left out for a language detected with a low confidence are counted apart. A submission not analyzed yet
+    has no metrics, and only counts as such.
+    """
+
+    @staticmethod
+    def primary_language(files: List[Dict[str, Any]]) -> Optional[str]:
+        """Language of most of the lines of code of the files of a submission, None if none is known"""
+        code_lines: Dict[str, int] = {}
+        for file in files:
+            if file.get("language"):
+                code_lines[file["language"]] = code_lines.get(file["language"], 0) + file.get("code_lines", 0)
+        if not code_lines:
+            return None
+        return min(code_lines, key=lambda language: (-code_lines[language], language))
+
+    def aggregate(self, submissions: List[Submission]) -> Dict[str, Any]:
+        """Statistics of the languages of submissions, each language with its submissions, files and lines of code"""
+        languages: Dict[str, Dict[str, Any]] = {}
+        counts = dict.fromkeys(COUNTS, 0)
+        for submission in submissions:
+            if not submission.code_metrics:
+                continue
+            counts["analyzed_submission_count"] += 1
+            files = submission.code_metrics.get("files") or []
+            for file in files:
+                if not file.get("language"):
+                    counts["plain_text_file_count"] += 1
+                    continue
+                usage = languages.setdefault(file["language"], self._usage(file["language"]))
+                usage["file_count"] += 1
+                usage["code_lines"] += file.get("code_lines", 0)
+
+            primary = self.primary_language(files)
+            if primary is None:
+                counts["plain_text_submission_count"] += 1
+            else:
+                languages[primary]["submission_count"] += 1
+            flagged = len((submission.language_detection or {}).get("flagged_files") or [])
+            counts["low_confidence_file_count"] += flagged
+            counts["low_confidence_submission_count"] += 1 if flagged else 0
+
+        total_code_lines = sum(usage["code_lines"] for usage in languages.values())
+        for usage in languages.values():
+            usage["code_line_share"] = round(usage["code_lines"] / total_code_lines, 4) if total_code_lines else 0.0
+        return {
+            "submission_count": len(submissions),
+            **counts,
+            "languages": sorted(languages.values(), key=lambda usage: (-usage["submission_count"], usage["language"])),
+        }
+
+    @staticmethod
+    def _usage(language: str) -> Dict[str, Any]:
+        return {"language": language, "submission_count": 0, "file_count": 0, "code_lines": 0}
@@ -0,0 +1,244 @@
+import csv
+import json
+import logging
+import re
+from dataclasses import dataclass, field
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto, SimilarityMetric
+from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.submissions.binary_file_detector import BinaryFileDetector
+from app.domains.submissions.comparison_report import ComparisonReportRenderer
+from app.domains.submissions.encoding_detector import EncodingDetector
+from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessor
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.tokenization_service import TokenizationService
+
+logger = logging.getLogger(__name__)
+
+# Columns of the CSV results of a local run, one row per pair
+LOCAL_RUN_CSV_COLUMNS = (
+    "submission",
+    "compared_submission",
+    "overall_similarity",
+    "jaccard_similarity",
+    "flagged",
+    "submission_token_count",
+    "compared_submission_token_count",
+    "fragment_count",
+)
+
+# Characters of the names of the submissions kept in the file names of their reports
+REPORT_NAME_PATTERN = re.compile(r"[^A-Za-z0-9._-]+")
+
+
+@dataclass
+class LocalSubmission:
+    """Submission of a local run: its tokens, tagged with their file, and the sources of its analyzed files"""
+
+    name: str
+    path: Path
+    tokens: List[Dict[str, Any]] = field(default_factory=list)
+    sources: Dict[str, str] = field(default_factory=dict)
+
+
+class LocalDetectionRun:
+    """
+    Detection run over a directory of submissions, one per subdirectory, in-process: no database nor HTTP service,
+    the token streams of the submissions being kept in memory. The files of each submission are selected, read and
+    tokenized once as by the service (binary files left out, Go files grouped by package, encodings detected), only
+    those of the language if given, then every pair is compared with the detection options of the run, the pairs
+    scoring at or above the threshold being flagged.
+    """
+
+    def __init__(
+        self,
+        tokenization_service: TokenizationService,
+        similarity_service: SimilarityDetectionService,
+        detection_options: DetectionOptionsDto,
+        threshold: float,
+        language: Optional[str] = None,
+        tokenization_options: Optional[TokenizationOptionsDto] = None,
+        go_package_preprocessor: Optional[GoPackagePreprocessor] = None,
+    ):
+        self.tokenization_service = tokenization_service
+        self.similarity_service = similarity_service
+        self.detection_options = detection_options
+        self.threshold = threshold
+        self.language = language
+        self.tokenization_options = tokenization_options or TokenizationOptionsDto()
+        self.go_package_preprocessor = go_package_preprocessor or GoPackagePreprocessor()
+        self.binary_file_detector = BinaryFileDetector()
+        self.encoding_detector = EncodingDetector()
+
+    @staticmethod
+    def discover(root: Path) -> List[Path]:
+        """
+        Directories of the submissions of a run, by name, the hidden ones left out
+
+        Raises:
+            ValueError: If the root is not a directory
+        """
+        if not root.is_dir():
+            raise ValueError(f"Not a directory: {root}")
+        return sorted(path for path in root.iterdir() if path.is_dir() and not path.name.startswith("."))
+
+    def load(self, path: Path) -> LocalSubmission:
+        """Tokenize the supported files of a submission, only those of the language of the run if given"""
+        submission = LocalSubmission(name=path.name, path=path)
+        files = [
+            file_path
+            for file_path in self.tokenization_service.extract_supported_files_from_directory(path)
+            if not self.binary_file_detector.detect_file(file_path)
+        ]
+        for file_path in self.go_package_preprocessor.prepare(files, path).files:
+            text = self.encoding_detector.decode(file_path.read_bytes()).text
+            if self.language and self.tokenization_service.detect_file_language(file_path, text) != self.language:
+                continue
+            relative_path = str(file_path.relative_to(path))
+            result = self.tokenization_service.tokenize_with_details(text, file_path, self.tokenization_options)
+            for token in result.tokens:
+                token["file"] = relative_path
+            submission.tokens.extend(result.tokens)
+            submission.sources[relative_path] = text
+        logger.info(f"Tokenized {len(submission.sources)} files of submission {submission.name}")
+        return submission
+
+    def run(self, root: Path) -> Dict[str, Any]:
+        """
+        Compare every pair of the submissions of a directory: the configuration of the run, and its pairs by
+        decreasing similarity with their full results
+
+        Raises:
+            ValueError: If the root is not a directory, or holds fewer than two submissions
+        """
+        paths = self.discover(root)
+        if len(paths) < 2:
+            raise ValueError(f"A detection run compares at least two submissions, {root} holds {len(paths)}")
+        submissions = [self.load(path) for path in paths]
+
+        tfidf_model = None
+        if self.detection_options.metric == SimilarityMetric.TFIDF_COSINE:
+            tfidf_model = self.similarity_service.build_tfidf_model(
+                (submission.tokens for submission in submissions), self.detection_options, self.language
+            )
+
+        pairs = []
+        for index, submission in enumerate(submissions):
+            for other in submissions[index + 1 :]:
+                result = self.similarity_service.compare_similarity(
+                    submission.tokens, other.tokens, self.detection_options, self.language, tfidf_model
+                )
+                pairs.append(self._pair(submission, other, result))
+        pairs.sort(key=lambda pair: pair["overall_similarity"], reverse=True)
+        logger.info(f"Compared {len(pairs)} pairs of {len(submissions)} submissions in {root}")
+
+        return {
+            "root": str(root),
+            "configuration": {
+                "threshold": self.threshold,
+                "language": self.language,
+                "detection_options": self.detection_options.model_dump(mode="json"),
+                "tokenization_options": self.tokenization_options.model_dump(mode="json"),
+            },
+            "submissions": {submission.name: len(submission.sources) for submission in submissions},
+            "pairs": pairs,
+        }
+
+    def _pair(self, submission: LocalSubmission, other: LocalSubmission, result: Dict[str, Any]) -> Dict[str, Any]:
+        """Result of the comparison of a pair, with the sources of the files holding its fragments"""
+        fragments = result.get("fragments", [])
+        return {
+            "submission": submission.name,
+            "compared_submission": other.name,
+            "overall_similarity": result["overall_similarity"],
+            "jaccard_similarity": result.get("jaccard_similarity"),
+            "flagged": result["overall_similarity"] >= self.threshold,
+            "submission_token_count": result.get("tokens1_length"),
+            "compared_submission_token_count": result.get("tokens2_length"),
+            "fragments": fragments,
+            "file_similarities": result.get("file_similarities"),
+            "sources": {
+                "submission1": self._fragment_sources(submission, fragments, "left"),
+                "submission2": self._fragment_sources(other, fragments, "right"),
+            },
+        }
+
+    @staticmethod
+    def _fragment_sources(submission: LocalSubmission, fragments: List[Dict[str, Any]], side: str) -> Dict[str, str]:
+        """Sources of the files of a submission holding fragments of a pair, its side of the fragments given"""
+        files = {fragment[side].get("file") for fragment in fragments}
+        return {file: source for file, source in submission.sources.items() if file in files}
+
+
+class LocalRunWriter:
+    """
+    Write the results of a local run to a directory: results.csv (one row per pair), results.json (the run and its
+    pairs with their fragments) and, if requested, an HTML report per flagged pair under reports/
+    """
+
+    def __init__(self, output_dir: Path):
+        self.output_dir = output_dir
+
+    def write(self, run: Dict[str, Any], formats: List[str], html: bool = False) -> List[Path]:
+        """Write the results in the given formats (csv, json), returning the written files"""
+        self.output_dir.mkdir(parents=True, exist_ok=True)
+        written = []
+        if "csv" in formats:
+            written.append(self._write_csv(run))
+        if "json" in formats:
+            path = self.output_dir / "results.json"
+            pairs = [{key: value for key, value in pair.items() if key != "sources"} for pair in run["pairs"]]
+            path.write_text(json.dumps({**run, "pairs": pairs}, indent=2, default=str), encoding="utf-8")
+            written.append(path)
+        if html:
+            written.extend(self._write_reports(run))
+        return written
+
+    def _write_csv(self, run: Dict[str, Any]) -> Path:
+        path = self.output_dir / "results.csv"
+        with open(path, "w", newline="", encoding="utf-8") as file:
+            writer = csv.writer(file)
+            writer.writerow(LOCAL_RUN_CSV_COLUMNS)
+            for pair in run["pairs"]:
+                row = {**pair, "fragment_count": len(pair["fragments"])}
+                writer.writerow(["" if row[column] is None else row[column] for column in LOCAL_RUN_CSV_COLUMNS])
+        return path
+
+    def _write_reports(self, run: Dict[str, Any]) -> List[Path]:
+        """HTML report of each flagged pair, rendered as those of the service"""
+        reports_dir = self.output_dir / "reports"
+        reports_dir.mkdir(exist_ok=True)
+        renderer = ComparisonReportRenderer()
+        written = []
+        for pair in run["pairs"]:
+            if not pair["flagged"]:
+                continue
+            name = f"{pair['submission']}__{pair['compared_submission']}"
+            path = reports_dir / f"{REPORT_NAME_PATTERN.sub('_', name)}.html"
+            path.write_text(renderer.render_pair(self._report_pair(run, pair, name)), encoding="utf-8")
+            written.append(path)
+        return written
+
+    @staticmethod
+    def _report_pair(run: Dict[str, Any], pair: Dict[str, Any], name: str) -> Dict[str, Any]:
+        """Pair of a local run in the shape of a stored comparison of the service"""
+        root, configuration = Path(run["root"]), run["configuration"]
+        return {
+            "similarity_id": name,
+            "submission1": {"id": pair["submission"], "link": str(root / pair["submission"])},
+            "submission2": {"id": pair["compared_submission"], "link": str(root / pair["compared_submission"])},
+            "overall_similarity": pair["overall_similarity"],
+            "status": "completed",
+            "suspicious": pair["flagged"],
+            "configuration": {
+                "flag_threshold": configuration["threshold"],
+                "language": configuration["language"],
+                "detection_options": configuration["detection_options"],
+                "tokenization_options": configuration["tokenization_options"],
+            },
+            "fragments": pair["fragments"],
+            "sources": pair["sources"],
+            "file_similarities": pair["file_similarities"],
+        }
@@ -0,0 +1,133 @@
+import socket
+import struct
+from abc import ABC, abstractmethod
+from dataclasses import dataclass, field
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+# Bytes of a file sent to clamd at once, staying under its StreamMaxLength with the default settings
+CLAMAV_CHUNK_BYTES = 1_048_576
+
+
+class ScannerUnavailable(Exception):
+    """Raised when the malware scanner cannot be reached or cannot scan a file"""
+
+
+@dataclass
+class ScanVerdict:
+    """Verdict of the scan of the files of an upload: its infected files, with the signature each matched"""
+
+    scanner: str
+    infected_files: List[Dict[str, str]] = field(default_factory=list)
+
+    @property
+    def infected(self) -> bool:
+        return bool(self.infected_files)
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            "scanner": self.scanner,
+            "verdict": "infected" if self.infected else "clean",
+            "infected_files": self.infected_files,
+        }
+
+
+class MalwareScanner(ABC):
+    """Scanner of the files of the uploads, run after their extraction and before their analysis"""
+
+    name: str
+
+    @abstractmethod
+    def scan(self, content: bytes) -> Optional[str]:
+        """
+        Signature the content of a file matched, None when it is clean
+
+        Raises:
+            ScannerUnavailable: If the file could not be scanned
+        """
+
+    def scan_files(self, files: Iterable[Tuple[str, bytes]]) -> ScanVerdict:
+        """
+        Verdict of the scan of extracted files, by their path and content
+
+        Raises:
+            ScannerUnavailable: If any of the files could not be scanned
+        """
+        verdict = ScanVerdict(scanner=self.name)
+        for path, content in files:
+            signature = self.scan(content)
+            if signature:
+                verdict.infected_files.append({"path": path, "signature": signature})
+        return verdict
+
+
+class NoOpMalwareScanner(MalwareScanner):
+    """Scanner of the deployments without one, every file being clean"""
+
+    name = "none"
+
+    def scan(self, content: bytes) -> Optional[str]:
+        return None
+
+    def scan_files(self, files: Iterable[Tuple[str, bytes]]) -> ScanVerdict:
+        return ScanVerdict(scanner=self.name)
+
+
+class ClamAVScanner(MalwareScanner):
+    """
+    Scanner sending the files to a clamd daemon over TCP, by its INSTREAM command: the content in chunks prefixed
+    by their length (4 bytes, big-endian) closed by an empty chunk, clamd replying "stream: OK" for a clean file or
+    "stream: <signature> FOUND" for an infected one
+    """
+
+    name = "clamav"
+
+    def __init__(
+        self, host: str, port: int = 3310, timeout_seconds: float = 30.0, chunk_bytes: int = CLAMAV_CHUNK_BYTES
+    ):
+        self.host = host
+        self.port = port
+        self.timeout_seconds = timeout_seconds
+        self.chunk_bytes = chunk_bytes
+
+    @classmethod
+    def from_settings(cls, settings) -> "ClamAVScanner":
+        return cls(settings.clamav_host, settings.clamav_port, settings.clamav_timeout_seconds)
+
+    def scan(self, content: bytes) -> Optional[str]:
+        try:
+            with socket.create_connection((self.host, self.port), timeout=self.timeout_seconds) as connection:
+                connection.sendall(b"zINSTREAM\0")
+                for start in range(0, len(content), self.chunk_bytes):
+                    chunk = content[start : start + self.chunk_bytes]
+                    connection.sendall(struct.pack("!L", len(chunk)) + chunk)
+                connection.sendall(struct.pack("!L", 0))
+                reply = self._read_reply(connection)
+        except OSError as e:
+            raise ScannerUnavailable(f"clamd at {self.host}:{self.port} is unreachable: {str(e)}")
+        return self.parse_reply(reply)
+
+    @staticmethod
+    def parse_reply(reply: str) -> Optional[str]:
+        """
+        Signature of a reply of clamd to a scan, None for a clean file
+
+        Raises:
+            ScannerUnavailable: If clamd reported an error (file over its size limit...)
+        """
+        _, _, result = reply.strip().partition(": ")
+        if result == "OK":
+            return None
+        if result.endswith(" FOUND"):
+            return result[: -len(" FOUND")]
+        raise ScannerUnavailable(f"clamd could not scan the file: {reply.strip() or 'no reply'}")
+
+    @staticmethod
+    def _read_reply(connection: socket.socket) -> str:
+        """Reply of clamd, up to its terminating NUL byte or the end of the connection"""
+        reply = b""
+        while not reply.endswith(b"\0"):
+            data = connection.recv(4096)
+            if not data:
+                break
+            reply += data
+        return reply.rstrip(b"\0").decode("utf-8", errors="replace")
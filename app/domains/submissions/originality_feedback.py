@@ -0,0 +1,65 @@
+from typing import Any, Dict, List, Optional, Tuple
+from uuid import UUID
+
+from app.domains.submissions.submissions_models import SimilarityStatus, SubmissionSimilarity
+
+# Position fields of the side of a fragment kept in the feedback, those of the counterpart being withheld
+REGION_FIELDS = ("file", "start_line", "start_column", "end_line", "end_column")
+
+
+class OriginalityFeedback:
+    """
+    Anonymized view of the results of a submission, shown to its student before the deadline: its highest
+    similarity, its percentile among the highest similarities of the submissions of the project step, and the
+    regions of its own files matching another submission. Nothing of the counterparts is kept: neither their IDs
+    nor the similarity records, their files and positions, nor their code. A region matched in several pairs is
+    listed once, with its highest similarity.
+    """
+
+    def build(self, submission_id: UUID, step_similarities: List[SubmissionSimilarity]) -> Dict[str, Any]:
+        """Get the feedback of a submission from the similarity records of its project step"""
+        completed = [similarity for similarity in step_similarities if similarity.status == SimilarityStatus.COMPLETED]
+        maximums: Dict[UUID, float] = {}
+        for similarity in completed:
+            for compared_id in (similarity.submission_id, similarity.compared_submission_id):
+                maximums[compared_id] = max(maximums.get(compared_id, 0.0), similarity.overall_similarity)
+
+        own = [
+            similarity
+            for similarity in completed
+            if submission_id in (similarity.submission_id, similarity.compared_submission_id)
+        ]
+        max_similarity = maximums.get(submission_id)
+        return {
+            "submission_id": submission_id,
+            "max_similarity": max_similarity,
+            "percentile": self.percentile(max_similarity, list(maximums.values())),
+            "compared_submissions": len(maximums),
+            "matched_regions": self.regions(submission_id, own),
+        }
+
+    @staticmethod
+    def percentile(value: Optional[float], values: List[float]) -> Optional[float]:
+        """Share in percent of the values at or below a value, None without value"""
+        if value is None or not values:
+            return None
+        return round(100 * len([other for other in values if other <= value]) / len(values), 1)
+
+    @staticmethod
+    def regions(submission_id: UUID, similarities: List[SubmissionSimilarity]) -> List[Dict[str, Any]]:
+        """Own side of the fragments of the comparisons of a submission, each region once, in the order of its files"""
+        regions: Dict[Tuple[Any, ...], Dict[str, Any]] = {}
+        for similarity in similarities:
+            # The submission of the record is the left side of its fragments
+            side = "left" if similarity.submission_id == submission_id else "right"
+            for fragment in (similarity.similarity_details or {}).get("fragments", []):
+                position = fragment.get(side) or {}
+                key = tuple(position.get(field) for field in REGION_FIELDS)
+                region = regions.get(key)
+                if region is None or fragment.get("similarity", 0.0) > region["similarity"]:
+                    regions[key] = {
+                        **{field: position.get(field) for field in REGION_FIELDS},
+                        "tokens": fragment.get("tokens", 0),
+                        "similarity": fragment.get("similarity", 0.0),
+                    }
+        return sorted(regions.values(), key=lambda region: (region["file"] or "", region["start_line"] or 0))
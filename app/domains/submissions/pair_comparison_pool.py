@@ -0,0 +1,139 @@
+import logging
+import multiprocessing
+import os
+import threading
+from concurrent.futures import FIRST_COMPLETED, Executor, Future, ProcessPoolExecutor, wait
+from concurrent.futures.process import BrokenProcessPool
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, List, Optional, Sequence
+
+logger = logging.getLogger(__name__)
+
+# Comparisons written to the storage at once, as they complete
+DEFAULT_WRITE_BATCH_SIZE = 50
+# Pairs queued per process, so that a process done with its pair takes the next one without waiting
+QUEUED_PAIRS_PER_PROCESS = 2
+
+
+def available_cpu_count() -> int:
+    """Cores the process may run on, at least one"""
+    try:
+        return len(os.sched_getaffinity(0)) or 1
+    except AttributeError:
+        return os.cpu_count() or 1
+
+
+@dataclass
+class PairOutcome:
+    """Outcome of the comparison of a pair, its result or the error raised by the comparison"""
+
+    index: int  # Position of the pair in the compared pairs
+    pair: Any
+    result: Any = None
+    error: Optional[str] = None
+
+
+class PairComparisonPool:
+    """
+    Processes comparing the pairs of the detection runs on the CPU cores, `process_count` of them (all the cores
+    if 0): the comparisons are CPU bound, and the threads of a process run one at a time. The pairs are not split
+    between the processes beforehand but queued one at a time on a queue they share, since they cost very
+    differently (large files against small ones): a process done with its pair takes the next one.
+
+    The outcomes come back to the calling thread as the comparisons complete, and are written from it by batches
+    of `write_batch_size`, each in the order of the pairs: the processes do not write to the storage, and what is
+    written does not depend on the order the comparisons complete in. The processes are started by the first run
+    and shared by the runs; they are spawned, not forked from a process holding database connections and threads.
+    """
+
+    def __init__(
+        self,
+        process_count: int = 0,
+        write_batch_size: int = DEFAULT_WRITE_BATCH_SIZE,
+        executor_factory: Optional[Callable[[int], Executor]] = None,
+    ):
+        if process_count < 0 or write_batch_size < 1:
+            raise ValueError("The comparisons need a non-negative process count and a positive write batch size")
+        self.process_count = process_count or available_cpu_count()
+        self.write_batch_size = write_batch_size
+        self._executor_factory = executor_factory or self._process_executor
+        self._executor: Optional[Executor] = None
+        self._lock = threading.Lock()
+
+    @staticmethod
+    def _process_executor(process_count: int) -> Executor:
+        return ProcessPoolExecutor(max_workers=process_count, mp_context=multiprocessing.get_context("spawn"))
+
+    def _get_executor(self) -> Executor:
+        with self._lock:
+            if self._executor is None:
+                self._executor = self._executor_factory(self.process_count)
+            return self._executor
+
+    def _discard_executor(self, executor: Executor) -> None:
+        """Drop a broken executor (a process died), the next run starting new processes"""
+        with self._lock:
+            if self._executor is executor:
+                self._executor = None
+        executor.shutdown(wait=False, cancel_futures=True)
+
+    def run(
+        self,
+        pairs: Sequence[Any],
+        compare: Callable[[Any], Any],
+        write: Callable[[List[PairOutcome]], Any],
+        stopped: Optional[Callable[[], bool]] = None,
+    ) -> int:
+        """
+        Compare pairs with `compare` (a module-level function, run in the processes), giving their outcomes to
+        `write` by batches, and return the number of pairs compared. Once `stopped`, no more pairs are queued, the
+        comparisons in progress being still written. If a process dies (out of memory on a huge pair, say), the
+        comparisons in progress fail, and the next pairs are compared by new processes.
+        """
+        executor = self._get_executor()
+        queued_limit = self.process_count * QUEUED_PAIRS_PER_PROCESS
+        in_progress: Dict[Future, int] = {}
+        completed: List[PairOutcome] = []
+        next_index = 0
+        written = 0
+
+        def flush() -> None:
+            nonlocal written
+            completed.sort(key=lambda outcome: outcome.index)
+            write(list(completed))
+            written += len(completed)
+            completed.clear()
+
+        while True:
+            while next_index < len(pairs) and len(in_progress) < queued_limit and not (stopped and stopped()):
+                in_progress[executor.submit(compare, pairs[next_index])] = next_index
+                next_index += 1
+            if not in_progress:
+                break
+
+            done, _ = wait(in_progress, return_when=FIRST_COMPLETED)
+            broken = any(isinstance(future.exception(), BrokenProcessPool) for future in done)
+            for future in list(in_progress) if broken else done:
+                index = in_progress.pop(future)
+                outcome = PairOutcome(index, pairs[index])
+                if not future.done() or isinstance(future.exception(), BrokenProcessPool):
+                    outcome.error = "The comparison process died"
+                elif future.exception() is not None:
+                    outcome.error = str(future.exception())
+                else:
+                    outcome.result = future.result()
+                completed.append(outcome)
+            if broken:
+                logger.error("A comparison process died, its comparisons in progress failing")
+                self._discard_executor(executor)
+                executor = self._get_executor()
+            if completed and (len(completed) >= self.write_batch_size or not in_progress):
+                flush()
+        return written
+
+    def shutdown(self) -> None:
+        """Stop the processes, the comparisons left in the queue being dropped"""
+        with self._lock:
+            executor, self._executor = self._executor, None
+        if executor is not None:
+            executor.shutdown(wait=True, cancel_futures=True)
@@ -0,0 +1,35 @@
+from typing import Any, Dict, Optional
+
+from app.domains.submissions.comparison_report import ComparisonReportRenderer
+
+
+class PdfRendererUnavailable(Exception):
+    """Raised when WeasyPrint or the system libraries it needs (Pango, cairo, gdk-pixbuf) cannot be loaded"""
+
+
+class PdfReportRenderer:
+    """
+    Render the report of the comparison of a pair of submissions as a PDF document for the case files, with the
+    content of the HTML report: the printable HTML report is laid out on pages (see PRINT_STYLE), keeping the
+    highlighting of the fragments, the long files breaking across pages and the fragment references being links
+    to their locations, along with the page they are on.
+
+    WeasyPrint is only loaded when a report is rendered, so that the service starts without its system libraries,
+    the PDF reports failing alone.
+    """
+
+    def __init__(self, renderer: Optional[ComparisonReportRenderer] = None):
+        self.renderer = renderer or ComparisonReportRenderer(printable=True)
+
+    def render_pair(self, pair: Dict[str, Any]) -> bytes:
+        """
+        Render the PDF report of the comparison of a pair of submissions
+
+        Raises:
+            PdfRendererUnavailable: If WeasyPrint cannot be loaded
+        """
+        try:
+            from weasyprint import HTML
+        except (ImportError, OSError) as e:
+            raise PdfRendererUnavailable(f"PDF rendering is unavailable: {str(e)}")
+        return HTML(string=self.renderer.render_pair(pair)).write_pdf()
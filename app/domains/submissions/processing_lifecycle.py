@@ -0,0 +1,87 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from app.domains.submissions.submissions_models import ProcessingStatus
+
+# Stages a submission may move to from each stage: its processing may start again from the extraction at any stage,
+# such as after an interruption or to analyze it again, and an interrupted one or one failed for a transient cause (or
+# retried by hand) waits pending retry
+ALLOWED_TRANSITIONS = {
+    ProcessingStatus.RECEIVED: {ProcessingStatus.EXTRACTING, ProcessingStatus.FAILED, ProcessingStatus.PENDING_RETRY},
+    ProcessingStatus.EXTRACTING: {
+        ProcessingStatus.EXTRACTING,
+        ProcessingStatus.TOKENIZING,
+        ProcessingStatus.FAILED,
+        ProcessingStatus.PENDING_RETRY,
+    },
+    ProcessingStatus.TOKENIZING: {
+        ProcessingStatus.EXTRACTING,
+        ProcessingStatus.ANALYZED,
+        ProcessingStatus.FAILED,
+        ProcessingStatus.PENDING_RETRY,
+    },
+    ProcessingStatus.ANALYZED: {ProcessingStatus.EXTRACTING},
+    ProcessingStatus.FAILED: {ProcessingStatus.EXTRACTING, ProcessingStatus.PENDING_RETRY},
+    ProcessingStatus.PENDING_RETRY: {ProcessingStatus.EXTRACTING, ProcessingStatus.FAILED},
+}
+
+
+class InvalidProcessingTransition(ValueError):
+    """Raised when a submission is moved to a stage it cannot reach from its current one"""
+
+
+class FileProcessingError(Exception):
+    """Raised when a file of a submission cannot be processed, failing the processing of the whole submission"""
+
+    def __init__(self, path: str, cause: Exception):
+        super().__init__(f"Failed to process {path}: {cause}")
+        self.path = path
+        self.cause = cause
+
+
+class ProcessingLifecycle:
+    """
+    Stages a submission is processed through: received, its files extracted (fetched), then tokenized and analyzed,
+    after which it can be compared. Each transition is recorded with its time; a failure records its reason and the
+    file it happened on, if any. A processing interrupted by a shutdown, or failed for a transient cause, is left
+    pending retry rather than failed.
+    """
+
+    @staticmethod
+    def transition(
+        current: ProcessingStatus,
+        transitions: Optional[List[Dict[str, Any]]],
+        status: ProcessingStatus,
+        at: datetime,
+        **fields: Any,
+    ) -> Dict[str, Any]:
+        """
+        Fields of a submission moved to a new stage, with the other given fields
+
+        Raises:
+            InvalidProcessingTransition: If the stage cannot be reached from the current one
+        """
+        current, status = ProcessingStatus(current), ProcessingStatus(status)
+        if status not in ALLOWED_TRANSITIONS[current]:
+            raise InvalidProcessingTransition(f"A submission cannot go from {current.value} to {status.value}")
+
+        changes = {
+            "processing_status": status,
+            "processing_transitions": list(transitions or []) + [{"status": status.value, "at": at.isoformat()}],
+            **fields,
+        }
+        if status == ProcessingStatus.EXTRACTING:
+            changes = {
+                "processed_file_count": 0,
+                "total_file_count": None,
+                "processing_error": None,
+                "processing_failed_file": None,
+                "processing_retry_at": None,
+                **changes,
+            }
+        return changes
+
+    @staticmethod
+    def timeline(received_at: datetime, transitions: Optional[List[Dict[str, Any]]]) -> List[Dict[str, Any]]:
+        """Stages a submission went through, oldest first, from its reception"""
+        return [{"status": ProcessingStatus.RECEIVED.value, "at": received_at.isoformat()}] + list(transitions or [])
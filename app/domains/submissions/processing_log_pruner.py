@@ -0,0 +1,26 @@
+import asyncio
+import logging
+
+logger = logging.getLogger(__name__)
+
+
+def prune_processing_log(retention_days: int) -> int:
+    """Delete the events of the processing logs older than the retention period, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).prune_processing_log(retention_days)
+    finally:
+        session.close()
+
+
+async def prune_processing_log_periodically(retention_days: int, interval_seconds: float) -> None:
+    """Prune the processing logs in a thread at every interval, until cancelled at shutdown"""
+    while True:
+        try:
+            await asyncio.to_thread(prune_processing_log, retention_days)
+        except Exception as e:
+            logger.error(f"Failed to prune the processing logs: {e}")
+        await asyncio.sleep(interval_seconds)
@@ -0,0 +1,92 @@
+import random
+from datetime import datetime, timedelta
+from typing import Any, Dict, Optional
+
+# Errors of the database, the storage backends and the network worth retrying, by class name so that the
+# classification does not depend on the clients installed (SQLAlchemy, psycopg2, botocore)
+TRANSIENT_ERROR_NAMES = {
+    "OperationalError",
+    "DisconnectionError",
+    "TimeoutError",
+    "EndpointConnectionError",
+    "ConnectTimeoutError",
+    "ReadTimeoutError",
+    "ConnectionClosedError",
+}
+
+# Error types of the structured fetch errors worth retrying; the others (unsupported language, corrupt archive,
+# missing object, denied access) are permanent
+TRANSIENT_ERROR_TYPES = {"git_timeout", "git_network_error", "external_source_timeout", "external_source_unreachable"}
+
+# Error codes of S3 telling that the request may succeed later
+TRANSIENT_S3_ERROR_CODES = {"RequestTimeout", "SlowDown", "InternalError", "ServiceUnavailable", "Throttling"}
+
+
+class ProcessingRetryPolicy:
+    """
+    Retry of the processing of a submission failing for a transient cause, such as a timeout of the storage backend
+    or the database being unavailable, up to `max_attempts` attempts in all. The delay before each retry doubles
+    from `base_delay_seconds` up to `max_delay_seconds`, half of it being random so that the submissions failing
+    together are not retried together. The permanent failures are never retried.
+    """
+
+    def __init__(
+        self,
+        max_attempts: int = 3,
+        base_delay_seconds: float = 5.0,
+        max_delay_seconds: float = 300.0,
+        random_source: Optional[random.Random] = None,
+    ):
+        self.max_attempts = max_attempts
+        self.base_delay_seconds = base_delay_seconds
+        self.max_delay_seconds = max_delay_seconds
+        self.random = random_source or random.Random()
+
+    @staticmethod
+    def is_transient(error: BaseException) -> bool:
+        """
+        Whether an error is transient, from the error and the errors it was raised from: the first one with a
+        structured error type decides, else any transient one among them
+        """
+        seen = set()
+        while error is not None and id(error) not in seen:
+            seen.add(id(error))
+            detail = getattr(error, "detail", None)
+            if isinstance(detail, dict) and detail.get("error_type"):
+                return detail["error_type"] in TRANSIENT_ERROR_TYPES
+            if isinstance(error, (TimeoutError, ConnectionError)) or type(error).__name__ in TRANSIENT_ERROR_NAMES:
+                return True
+            response = getattr(error, "response", None)
+            if isinstance(response, dict) and response.get("Error", {}).get("Code") in TRANSIENT_S3_ERROR_CODES:
+                return True
+            error = getattr(error, "cause", None) or error.__cause__ or error.__context__
+        return False
+
+    def should_retry(self, error: BaseException, attempt: int) -> bool:
+        """Whether a processing failed at the given attempt (1 for the first) is retried"""
+        return attempt < self.max_attempts and self.is_transient(error)
+
+    def delay(self, attempt: int) -> float:
+        """Seconds to wait before retrying a processing failed at the given attempt"""
+        ceiling = min(self.max_delay_seconds, self.base_delay_seconds * 2 ** (attempt - 1))
+        return ceiling / 2 + self.random.uniform(0, ceiling / 2)
+
+    @staticmethod
+    def attempt_record(
+        attempt: int,
+        at: datetime,
+        error: str,
+        failed_file: Optional[str],
+        transient: bool,
+        retry_in_seconds: Optional[float] = None,
+    ) -> Dict[str, Any]:
+        """Record of a failed attempt, kept on the submission to tell a flapping processing"""
+        retry_at = at + timedelta(seconds=retry_in_seconds) if retry_in_seconds is not None else None
+        return {
+            "attempt": attempt,
+            "at": at.isoformat(),
+            "error": error,
+            "failed_file": failed_file,
+            "transient": transient,
+            "retry_at": retry_at.isoformat() if retry_at else None,
+        }
@@ -0,0 +1,83 @@
+from contextlib import contextmanager
+from datetime import datetime
+from typing import Any, Callable, Dict, Iterator, List, Optional
+
+from app.domains.submissions.submissions_models import (
+    ProcessingOutcome,
+    ProcessingStage,
+    ProcessingWarningCode,
+    get_paris_time,
+)
+
+
+class ProcessingTimeline:
+    """
+    Record the stages a submission is processed through, each with its start and end, its outcome and its
+    warnings, the per-file stages with the file. The events are kept in memory in the order the stages started,
+    to be stored at once in the processing log of the submission. A stage raising is recorded as failed with the
+    error, which is raised again.
+    """
+
+    def __init__(self, clock: Callable[[], datetime] = get_paris_time):
+        self.clock = clock
+        self.events: List[Dict[str, Any]] = []
+
+    @contextmanager
+    def stage(
+        self,
+        stage: ProcessingStage,
+        file_path: Optional[str] = None,
+        started_at: Optional[datetime] = None,
+        **details,
+    ) -> Iterator[Dict[str, Any]]:
+        """
+        Record a stage over the block (started earlier if a start is given), the event being given to the block for
+        its warnings and details
+        """
+        event = {
+            "stage": stage,
+            "file_path": file_path,
+            "started_at": started_at or self.clock(),
+            "outcome": ProcessingOutcome.OK,
+            "warnings": [],
+            "details": dict(details),
+            "error_message": None,
+        }
+        self.events.append(event)
+        try:
+            yield event
+        except Exception as e:
+            event["outcome"] = ProcessingOutcome.FAILED
+            event["error_message"] = str(e)
+            raise
+        finally:
+            event["ended_at"] = self.clock()
+            event["duration_ms"] = max(0, round((event["ended_at"] - event["started_at"]).total_seconds() * 1000))
+
+    def record(
+        self,
+        stage: ProcessingStage,
+        file_path: Optional[str] = None,
+        code: Optional[ProcessingWarningCode] = None,
+        message: Optional[str] = None,
+        skipped: bool = False,
+        started_at: Optional[datetime] = None,
+        **details,
+    ) -> Dict[str, Any]:
+        """
+        Record a stage ending now, instant unless a start is given, with a warning if a code is given (the file
+        being skipped if set)
+        """
+        with self.stage(stage, file_path, started_at, **details) as event:
+            if code is not None:
+                self.warn(event, code, message, skipped)
+        return event
+
+    @staticmethod
+    def warn(event: Dict[str, Any], code: ProcessingWarningCode, message: str, skipped: bool = False) -> None:
+        """Add a warning to a stage, its outcome becoming a warning (skipped if set) unless it failed or was skipped"""
+        event["warnings"].append({"code": code, "message": message})
+        if skipped and event["outcome"] != ProcessingOutcome.FAILED:
+            event["outcome"] = ProcessingOutcome.SKIPPED
+        elif event["outcome"] == ProcessingOutcome.OK:
+            event["outcome"] = ProcessingOutcome.WARNING
@@ -0,0 +1,90 @@
+import logging
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional
+from uuid import UUID, uuid4
+
+from app.domains.submissions.submissions_models import CorpusKind, SubmissionCorpus
+from app.shared.exceptions import NotFoundException
+
+logger = logging.getLogger(__name__)
+
+
+class ReferenceSets:
+    """
+    External reference sets of a tenant, corpora of source files imported from an uploaded archive and shared by
+    the detection runs of every project step of the tenant including the references. The files of an archive are
+    fingerprinted once with the given function (reference set ID, archive content and filename, one item per file);
+    the reference sets of the other tenants are reported as not found.
+    """
+
+    def __init__(
+        self,
+        corpus_repository: Any,
+        match_repository: Any,
+        tenant_id: str,
+        fingerprint_archive: Callable[[UUID, bytes, str], List[Dict[str, Any]]],
+    ):
+        self.corpus_repository = corpus_repository
+        self.match_repository = match_repository
+        self.tenant_id = tenant_id
+        self.fingerprint_archive = fingerprint_archive
+
+    def create(
+        self,
+        content: bytes,
+        filename: str,
+        label: str,
+        license_note: Optional[str] = None,
+        description: Optional[str] = None,
+    ) -> SubmissionCorpus:
+        """
+        Import an external reference set from an uploaded archive of source files, each file being fingerprinted
+
+        Raises:
+            ValidationException: If the archive is invalid, or none of its files could be fingerprinted
+        """
+        corpus_id = uuid4()
+        items = self.fingerprint_archive(corpus_id, content, filename)
+        reference_set = self.corpus_repository.create(
+            {
+                "id": corpus_id,
+                "tenant_id": self.tenant_id,
+                "label": label,
+                "description": description,
+                "kind": CorpusKind.REFERENCE,
+                "license_note": license_note,
+            }
+        )
+        self.corpus_repository.replace_items(reference_set.id, items)
+        logger.info(f"Imported reference set {label} from {filename}: {len(items)} files")
+        return reference_set
+
+    def get_all(self) -> List[SubmissionCorpus]:
+        """Get the external reference sets of the tenant, by label"""
+        return self.corpus_repository.get_by_kind(CorpusKind.REFERENCE, self.tenant_id)
+
+    def refresh(
+        self, corpus_id: UUID, content: bytes, filename: str, now: datetime, license_note: Optional[str] = None
+    ) -> SubmissionCorpus:
+        """
+        Replace the files of a reference set by those of a new upload of its archive, fingerprinted again: the
+        matches with its former files are deleted, the next runs including the references matching the new ones
+
+        Raises:
+            NotFoundException: If the reference set doesn't exist, or belongs to another tenant
+            ValidationException: If the archive is invalid, or none of its files could be fingerprinted
+        """
+        reference_set = self.corpus_repository.get_by_id(corpus_id, self.tenant_id)
+        if not reference_set or reference_set.kind != CorpusKind.REFERENCE:
+            raise NotFoundException("Reference set", str(corpus_id))
+
+        items = self.fingerprint_archive(corpus_id, content, filename)
+        former_items = self.corpus_repository.get_items([corpus_id])
+        if former_items:
+            self.match_repository.delete_by_corpus_item_ids([item.id for item in former_items])
+        self.corpus_repository.replace_items(corpus_id, items)
+        update_data = {"updated_at": now}
+        if license_note is not None:
+            update_data["license_note"] = license_note
+        logger.info(f"Refreshed reference set {reference_set.label} from {filename}: {len(items)} files")
+        return self.corpus_repository.update(corpus_id, update_data)
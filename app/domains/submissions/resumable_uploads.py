@@ -0,0 +1,163 @@
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from app.domains.submissions.chunked_upload_store import ChunkConflictError, ChunkedUploadStore
+from app.domains.submissions.submissions_models import SubmissionUploadSession, UploadSessionStatus
+from app.shared.exceptions import NotFoundException, ValidationException
+
+logger = logging.getLogger(__name__)
+
+
+class ResumableUploads:
+    """
+    Resumable uploads of large files: an upload is initiated with the size and checksum of its file, then receives
+    its chunks of the configured size in any order, kept in the chunk store, a chunk being sent again when unsure it
+    was received, until the whole file is assembled and checked against its checksum. The uploads not finalized
+    within the configured time expire, their chunks being deleted.
+    """
+
+    def __init__(self, upload_session_repository: Any, store: ChunkedUploadStore, chunk_bytes: int, ttl_seconds: float):
+        self.upload_session_repository = upload_session_repository
+        self.store = store
+        self.chunk_bytes = chunk_bytes
+        self.ttl_seconds = ttl_seconds
+
+    def create(self, upload_data: Dict[str, Any], now: datetime) -> SubmissionUploadSession:
+        """Initiate a resumable upload, split into chunks of the configured size and expiring after its time"""
+        return self.upload_session_repository.create(
+            {
+                **upload_data,
+                "checksum": upload_data["checksum"].lower(),
+                "chunk_bytes": self.chunk_bytes,
+                "chunk_count": -(-upload_data["total_bytes"] // self.chunk_bytes),
+                "expires_at": now + timedelta(seconds=self.ttl_seconds),
+            }
+        )
+
+    def get(self, upload_id: UUID) -> SubmissionUploadSession:
+        """
+        Get a resumable upload
+
+        Raises:
+            NotFoundException: If the upload does not exist
+        """
+        upload = self.upload_session_repository.get_by_id(upload_id)
+        if not upload:
+            raise NotFoundException("Upload session", str(upload_id))
+        return upload
+
+    def get_pending(self, upload_id: UUID) -> SubmissionUploadSession:
+        """
+        Get a resumable upload still receiving its chunks
+
+        Raises:
+            ValidationException: If the upload is being finalized, was finalized, failed or expired
+        """
+        upload = self.get(upload_id)
+        if upload.status != UploadSessionStatus.PENDING:
+            message = f"The upload is {upload.status.value}, it no longer receives chunks"
+            raise ValidationException(
+                message,
+                details={
+                    "error_type": "upload_not_pending",
+                    "message": message,
+                    "status": upload.status.value,
+                    "submission_id": str(upload.submission_id) if upload.submission_id else None,
+                },
+            )
+        return upload
+
+    def received_chunks(self, upload: SubmissionUploadSession) -> List[int]:
+        """Indexes of the chunks received for a resumable upload"""
+        return self.store.received_chunks(upload.id)
+
+    def store_chunk(
+        self, upload_id: UUID, index: int, content: bytes, checksum: Optional[str] = None
+    ) -> SubmissionUploadSession:
+        """
+        Keep a chunk of a resumable upload. Sending a received chunk again with the same content is a no-op, so that
+        an interrupted upload is resumed by sending again the chunks it is unsure of.
+
+        Raises:
+            ValidationException: If the upload no longer receives chunks, or the chunk is not the expected one
+        """
+        upload = self.get_pending(upload_id)
+        if not 0 <= index < upload.chunk_count:
+            message = f"Chunk index {index} is out of range, the upload having {upload.chunk_count} chunks"
+            raise ValidationException(
+                message,
+                details={"error_type": "invalid_chunk_index", "message": message, "chunk_count": upload.chunk_count},
+            )
+        expected_bytes = min(upload.chunk_bytes, upload.total_bytes - index * upload.chunk_bytes)
+        if len(content) != expected_bytes:
+            message = f"Chunk {index} has {len(content)} bytes instead of {expected_bytes}"
+            raise ValidationException(
+                message,
+                details={"error_type": "invalid_chunk_size", "message": message, "expected_bytes": expected_bytes},
+            )
+        if checksum and ChunkedUploadStore.checksum(content) != checksum.lower():
+            message = f"Chunk {index} does not match its checksum"
+            raise ValidationException(message, details={"error_type": "chunk_checksum_mismatch", "message": message})
+
+        try:
+            if not self.store.write_chunk(upload.id, index, content):
+                logger.debug(f"Chunk {index} of upload {upload.id} was already received")
+        except ChunkConflictError as e:
+            raise ValidationException(str(e), details={"error_type": "chunk_conflict", "message": str(e)})
+        return upload
+
+    def assemble(self, upload: SubmissionUploadSession) -> bytes:
+        """
+        Get the whole file of a resumable upload from its chunks. The upload goes on while chunks are missing, but
+        fails when the file does not match its checksum, its chunks being deleted.
+
+        Raises:
+            ValidationException: If chunks are missing, listing them, or the file does not match its checksum
+        """
+        missing_chunks = self.store.missing_chunks(upload.id, upload.chunk_count)
+        if missing_chunks:
+            message = f"{len(missing_chunks)} chunks of the upload were not received"
+            raise ValidationException(
+                message, details={"error_type": "missing_chunks", "message": message, "missing_chunks": missing_chunks}
+            )
+        content, checksum = self.store.assemble(upload.id, upload.chunk_count)
+        if checksum != upload.checksum:
+            message = "The uploaded file does not match its checksum"
+            self.update(upload.id, {"status": UploadSessionStatus.FAILED, "error_message": message}, delete_chunks=True)
+            raise ValidationException(
+                message,
+                details={
+                    "error_type": "checksum_mismatch",
+                    "message": message,
+                    "expected": upload.checksum,
+                    "actual": checksum,
+                },
+            )
+        return content
+
+    def update(self, upload_id: UUID, upload_data: dict, delete_chunks: bool = False) -> SubmissionUploadSession:
+        """Record the progress of a resumable upload, deleting its chunks once they are no longer needed"""
+        upload = self.upload_session_repository.update(upload_id, upload_data)
+        if delete_chunks:
+            self.store.delete(upload_id)
+        return upload
+
+    def clean_expired(self, now: datetime) -> int:
+        """
+        Expire the resumable uploads not finalized in time, deleting their chunks and those of any upload without a
+        session still receiving chunks or being finalized, returning the number of expired uploads
+        """
+        expired = self.upload_session_repository.get_expired(now)
+        for upload in expired:
+            self.upload_session_repository.update(upload.id, {"status": UploadSessionStatus.EXPIRED})
+            self.store.delete(upload.id)
+
+        active = self.upload_session_repository.get_by_status(
+            [UploadSessionStatus.PENDING, UploadSessionStatus.PROCESSING]
+        )
+        self.store.delete_orphaned(upload.id for upload in active)
+        if expired:
+            logger.info(f"Expired {len(expired)} resumable uploads")
+        return len(expired)
@@ -0,0 +1,93 @@
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+
+from app.domains.submissions.submissions_models import (
+    DetectionRunStatus,
+    RetentionAnchor,
+    Submission,
+    SubmissionDetectionRun,
+    SubmissionRetentionPolicy,
+    SubmissionSimilarity,
+)
+
+# Fields of a comparison holding code of the compared submissions, emptied when its details are purged
+RESULT_DETAIL_FIELDS = ("similarity_details", "shared_blocks", "visualization_data")
+
+
+class RetentionPlanner:
+    """
+    What the retention policy of a project step purges: the submissions whose files expired, the comparisons and the
+    detection runs whose results expired, a number of days after the anchor of the policy (the grading deadline of
+    the step, a fixed date such as the end of the course, or the upload of each submission and the creation of each
+    result). Nothing expires while its anchor is unknown. A submission with an open integrity case is exempt, with
+    its comparisons and the runs that compared it. Without full deletion, the comparisons keep their scores and the
+    runs their summary, only their details being purged; the runs are then kept as they are.
+    """
+
+    def __init__(self, policy: SubmissionRetentionPolicy, deadline: Optional[datetime], now: datetime):
+        self.policy = policy
+        self.deadline = deadline
+        self.now = now
+
+    def anchor(self, created_at: datetime) -> Optional[datetime]:
+        """Date the retention of a record created at a time runs from, None if unknown"""
+        anchor = RetentionAnchor(self.policy.anchor)
+        if anchor == RetentionAnchor.DEADLINE:
+            return self.deadline
+        if anchor == RetentionAnchor.DATE:
+            return self.policy.anchor_date
+        return created_at
+
+    def expired(self, retention_days: Optional[int], created_at: datetime) -> bool:
+        """Whether a record created at a time is kept no longer, never without retention period or anchor"""
+        anchor = self.anchor(created_at)
+        if retention_days is None or anchor is None:
+            return False
+        now = self.now
+        if (anchor.tzinfo is None) != (now.tzinfo is None):
+            anchor, now = anchor.replace(tzinfo=None), now.replace(tzinfo=None)
+        return anchor + timedelta(days=retention_days) <= now
+
+    def plan(
+        self,
+        submissions: List[Submission],
+        similarities: List[SubmissionSimilarity],
+        runs: List[SubmissionDetectionRun],
+    ) -> Dict[str, Any]:
+        """Get the submissions, comparisons and runs of the step to purge, and the submissions exempt from it"""
+        full_deletion = self.policy.full_deletion
+        exempt = [submission.id for submission in submissions if submission.integrity_case_open]
+        expired_files = [
+            submission.id
+            for submission in submissions
+            if submission.id not in exempt
+            and (full_deletion or submission.files_purged_at is None)
+            and self.expired(self.policy.file_retention_days, submission.upload_date_time)
+        ]
+
+        # The comparisons of the deleted submissions are deleted with them
+        skipped = set(exempt) | (set(expired_files) if full_deletion else set())
+        expired_results = [
+            similarity.id
+            for similarity in similarities
+            if similarity.submission_id not in skipped
+            and similarity.compared_submission_id not in skipped
+            and (full_deletion or any(getattr(similarity, field) is not None for field in RESULT_DETAIL_FIELDS))
+            and self.expired(self.policy.result_retention_days, similarity.created_at)
+        ]
+
+        exempt_ids = {str(submission_id) for submission_id in exempt}
+        expired_runs = [
+            run.id
+            for run in runs
+            if full_deletion
+            and run.status != DetectionRunStatus.RUNNING
+            and not exempt_ids & set(run.submission_ids)
+            and self.expired(self.policy.result_retention_days, run.created_at)
+        ]
+        return {
+            "expired_file_submission_ids": expired_files,
+            "expired_similarity_ids": expired_results,
+            "expired_run_ids": expired_runs,
+            "exempt_submission_ids": exempt,
+        }
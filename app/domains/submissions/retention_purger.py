@@ -0,0 +1,28 @@
+import asyncio
+import logging
+from typing import Any, Dict, List
+
+logger = logging.getLogger(__name__)
+
+
+def purge_expired_data(dry_run: bool) -> List[Dict[str, Any]]:
+    """Purge the expired files and results of the project steps with a retention policy, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.domains.submissions.submissions_models import get_paris_time
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).get_data_retention().purge_expired(get_paris_time(), dry_run)
+    finally:
+        session.close()
+
+
+async def purge_expired_data_periodically(interval_seconds: float, dry_run: bool) -> None:
+    """Purge the expired files and results in a thread at every interval, until cancelled at shutdown"""
+    while True:
+        try:
+            await asyncio.to_thread(purge_expired_data, dry_run)
+        except Exception as e:
+            logger.error(f"Failed to purge the expired files and results: {e}")
+        await asyncio.sleep(interval_seconds)
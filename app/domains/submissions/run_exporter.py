@@ -0,0 +1,162 @@
+import csv
+import io
+import json
+from typing import Any, Callable, Dict, FrozenSet, Iterable, Iterator, List, Optional, Set, Tuple
+from uuid import UUID
+
+from app.domains.submissions.similarity_matrix import SimilarityMatrix
+from app.domains.submissions.submissions_models import SubmissionSimilarity
+
+CSV_COLUMNS = (
+    "similarity_id",
+    "submission_id",
+    "compared_submission_id",
+    "overall_similarity",
+    "status",
+    "suspicious",
+    "too_short",
+    "same_team",
+    "deleted_submission",
+    "submission_token_count",
+    "compared_submission_token_count",
+    "fragment_count",
+)
+
+# Code metrics of each submission of a pair exported along with it, if requested
+METRIC_COLUMNS = ("code_lines", "comment_ratio", "function_count", "cyclomatic_complexity")
+
+# Columns of the matches with the files of the reference sets, exported apart from the pairs
+REFERENCE_CSV_COLUMNS = (
+    "submission_id",
+    "reference_set_id",
+    "reference_label",
+    "path",
+    "license_note",
+    "overall_similarity",
+    "shared_fingerprint_count",
+    "status",
+    "suspicious",
+)
+
+
+class DetectionRunExporter:
+    """
+    Export the pairs of a detection run, flagged as in its similarity matrix, as CSV (one row per pair) or as a
+    JSON document including their fragments. The export is streamed: the similarity records are read twice (once
+    to find the too short submissions of the run, then to export its pairs) without ever being held all at once,
+    a run of 200 submissions having some 20,000 pairs.
+
+    The records are read by decreasing similarity, the first record of a pair being the exported one. Given the
+    code metrics of the submissions, those of both submissions are exported with each pair. The matches with the
+    files of the reference sets are never mixed with the pairs: listed after them in JSON, exported on their own
+    as CSV.
+    """
+
+    def __init__(
+        self,
+        matrix: SimilarityMatrix,
+        submission_ids: List[UUID],
+        similarities: Callable[[], Iterable[SubmissionSimilarity]],
+        metrics: Optional[Dict[UUID, Optional[Dict[str, Any]]]] = None,
+    ):
+        self.matrix = matrix
+        self.submission_ids = set(submission_ids)
+        self.similarities = similarities
+        self.metrics = metrics
+
+    def csv(self, min_similarity: float = 0.0, flagged_only: bool = False) -> Iterator[str]:
+        """Stream the pairs as CSV, a header row first"""
+        columns = CSV_COLUMNS
+        if self.metrics is not None:
+            columns += tuple(f"submission_{column}" for column in METRIC_COLUMNS)
+            columns += tuple(f"compared_submission_{column}" for column in METRIC_COLUMNS)
+        yield self._csv_row(columns)
+        for entry, similarity in self.entries(min_similarity, flagged_only):
+            fragments = (similarity.similarity_details or {}).get("fragments") or []
+            row = (
+                entry["similarity_id"],
+                entry["submission_id"],
+                entry["compared_submission_id"],
+                entry["overall_similarity"],
+                getattr(entry["status"], "value", entry["status"]),
+                entry["suspicious"],
+                entry["too_short"],
+                entry["same_team"],
+                entry["deleted_submission"],
+                self._token_count(similarity, entry["submission_id"]),
+                self._token_count(similarity, entry["compared_submission_id"]),
+                len(fragments),
+            )
+            if self.metrics is not None:
+                for submission_id in (entry["submission_id"], entry["compared_submission_id"]):
+                    metrics = self.metrics.get(submission_id) or {}
+                    row += tuple(metrics.get(column) for column in METRIC_COLUMNS)
+            yield self._csv_row(row)
+
+    def json(
+        self,
+        run: Dict[str, Any],
+        min_similarity: float = 0.0,
+        flagged_only: bool = False,
+        reference_matches: Optional[List[Dict[str, Any]]] = None,
+    ) -> Iterator[str]:
+        """
+        Stream the pairs as a JSON document: the description of the run, then its pairs with their fragments, and
+        its matches with the reference sets if given
+        """
+        yield '{"run": ' + json.dumps(run, default=str) + ', "pairs": ['
+        separator = ""
+        for entry, similarity in self.entries(min_similarity, flagged_only):
+            pair = {
+                **entry,
+                "submission_token_count": self._token_count(similarity, entry["submission_id"]),
+                "compared_submission_token_count": self._token_count(similarity, entry["compared_submission_id"]),
+                "fragments": (similarity.similarity_details or {}).get("fragments") or [],
+            }
+            if self.metrics is not None:
+                pair["submission_metrics"] = self.metrics.get(entry["submission_id"])
+                pair["compared_submission_metrics"] = self.metrics.get(entry["compared_submission_id"])
+            yield separator + json.dumps(pair, default=str)
+            separator = ", "
+        if reference_matches is None:
+            yield "]}"
+        else:
+            yield '], "reference_matches": ' + json.dumps(self._reference_matches(reference_matches), default=str) + "}"
+
+    def references_csv(self, reference_matches: List[Dict[str, Any]]) -> Iterator[str]:
+        """Stream the matches with the files of the reference sets as CSV, a header row first"""
+        yield self._csv_row(REFERENCE_CSV_COLUMNS)
+        for match in self._reference_matches(reference_matches):
+            yield self._csv_row(match[column] for column in REFERENCE_CSV_COLUMNS)
+
+    def entries(
+        self, min_similarity: float = 0.0, flagged_only: bool = False
+    ) -> Iterator[Tuple[Dict[str, Any], SubmissionSimilarity]]:
+        """Entries of the exported pairs, along with their similarity records"""
+        too_short = self.matrix.flagger.too_short_submissions(self._records())
+        for similarity in self._records():
+            entry, _ = self.matrix.entry(similarity, too_short)
+            if entry["overall_similarity"] >= min_similarity and (entry["suspicious"] or not flagged_only):
+                yield entry, similarity
+
+    def _records(self) -> Iterator[SubmissionSimilarity]:
+        """First record of each pair of submissions of the run"""
+        seen: Set[FrozenSet[UUID]] = set()
+        for similarity in self.similarities():
+            key = self.matrix.pair_key(similarity.submission_id, similarity.compared_submission_id)
+            if len(key) == 2 and key <= self.submission_ids and key not in seen:
+                seen.add(key)
+                yield similarity
+
+    @staticmethod
+    def _reference_matches(reference_matches: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        return [{**match, "status": getattr(match["status"], "value", match["status"])} for match in reference_matches]
+
+    def _token_count(self, similarity: SubmissionSimilarity, submission_id: UUID) -> Optional[int]:
+        return self.matrix.flagger.token_count(similarity, submission_id)
+
+    @staticmethod
+    def _csv_row(values: Iterable[Any]) -> str:
+        buffer = io.StringIO()
+        csv.writer(buffer).writerow(["" if value is None else value for value in values])
+        return buffer.getvalue()
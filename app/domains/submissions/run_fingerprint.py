@@ -0,0 +1,160 @@
+import hashlib
+import json
+import platform
+import random
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+# Version of the results of each algorithm of the comparisons: to bump with any change of the results a run yields
+# for the same tokens and options, the verifications of the runs of the previous versions reporting the change
+ALGORITHM_VERSIONS = {
+    "ast_similarity": 1,
+    "winnowing": 1,
+    "greedy_string_tiling": 1,
+    "function_similarity": 1,
+    "file_similarity_breakdown": 1,
+    "similarity_flagger": 1,
+    "similarity_clusterer": 1,
+}
+
+# Pairs of a run a verification compares again, by default
+DEFAULT_VERIFICATION_SAMPLE_SIZE = 10
+
+# Stages of a comparison, in the order of the pipeline, a divergence being reported with the first one it occurs in
+STAGES = ("tokenization", "fingerprinting", "scoring", "visualization")
+
+# Stage of the results of a comparison, those of the details of its similarity record by key, the others scores
+DETAIL_STAGES = {
+    "tokens_count": "tokenization",
+    "processed_tokens_count": "tokenization",
+    "files_count": "tokenization",
+    "go_packages": "tokenization",
+    "language_detection": "tokenization",
+    "language_fallbacks": "tokenization",
+    "stripped_headers": "tokenization",
+    "timed_out_files": "tokenization",
+    "file_hashes": "tokenization",
+    "generated_files": "tokenization",
+    "run_excluded_files": "tokenization",
+    "tokenization_options": "tokenization",
+    "common_elements": "fingerprinting",
+    "total_unique_elements": "fingerprinting",
+    "baseline": "fingerprinting",
+    "sanctioned_overlap": "fingerprinting",
+    "matches": "fingerprinting",
+    "fragments": "fingerprinting",
+    "fragment_sources": "fingerprinting",
+}
+RESULT_STAGES = {
+    "shared_blocks": "fingerprinting",
+    "shared_blocks_count": "fingerprinting",
+    "average_shared_similarity": "fingerprinting",
+    "visualization_data": "visualization",
+}
+
+# Fields of the results of a comparison not reproduced by a second execution: its timing and bookkeeping
+VOLATILE_FIELDS = ("processing_time_seconds", "status", "updated_at", "cache_key")
+
+
+class RunFingerprint:
+    """
+    Fingerprint of the environment of a detection run, recorded on the run: the version of the tokens of each
+    language, the versions of the comparison algorithms, the hash of the effective options and the Python version.
+    Two executions of the same pairs with the same fingerprint yield byte-identical results: the comparisons are
+    deterministic (stable sorts, explicit tie-breaks), the only randomness, the sample of pairs a verification
+    executes again, being drawn from the seed recorded on the run.
+    """
+
+    @staticmethod
+    def canonical(value: Any) -> str:
+        """Canonical JSON of a value, its keys sorted, whatever their order of insertion"""
+        return json.dumps(value, sort_keys=True, separators=(",", ":"), ensure_ascii=False, default=str)
+
+    @classmethod
+    def digest(cls, value: Any) -> str:
+        """SHA-256 of the canonical JSON of a value"""
+        return hashlib.sha256(cls.canonical(value).encode("utf8")).hexdigest()
+
+    @classmethod
+    def build(cls, language_versions: Dict[str, str], effective_options: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+        """Fingerprint of a run with the tokenizers of the service and the effective options of the run"""
+        return {
+            "tokenizers": dict(sorted(language_versions.items())),
+            "algorithms": dict(ALGORITHM_VERSIONS),
+            "options_hash": cls.digest(effective_options or {}),
+            "python_version": platform.python_version(),
+        }
+
+    @staticmethod
+    def differences(recorded: Optional[Dict[str, Any]], current: Dict[str, Any]) -> List[Dict[str, Any]]:
+        """
+        Entries of a fingerprint changed since it was recorded (a tokenizer or an algorithm by name), with their
+        recorded and current values, None where missing
+        """
+        recorded = recorded or {}
+        differences = []
+        for field in sorted(set(recorded) | set(current)):
+            recorded_value, current_value = recorded.get(field), current.get(field)
+            if isinstance(recorded_value, dict) or isinstance(current_value, dict):
+                recorded_value, current_value = recorded_value or {}, current_value or {}
+                for name in sorted(set(recorded_value) | set(current_value)):
+                    if recorded_value.get(name) != current_value.get(name):
+                        differences.append(
+                            {
+                                "field": f"{field}.{name}",
+                                "recorded": recorded_value.get(name),
+                                "current": current_value.get(name),
+                            }
+                        )
+            elif recorded_value != current_value:
+                differences.append({"field": field, "recorded": recorded_value, "current": current_value})
+        return differences
+
+    @staticmethod
+    def comparable(results: Dict[str, Any]) -> Dict[str, Any]:
+        """Fields of the results of a comparison reproduced by a second execution, without its timing"""
+        return {field: value for field, value in results.items() if field not in VOLATILE_FIELDS}
+
+    @classmethod
+    def divergence(cls, recorded: Dict[str, Any], current: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Divergence of the results of a comparison executed again from the recorded ones: the digests of both, the
+        fields whose values differ (those of the details by key) and the first stage of the pipeline among theirs,
+        None if the results are identical
+        """
+        recorded, current = cls.comparable(recorded), cls.comparable(current)
+        fields = []
+        for field in sorted(set(recorded) | set(current)):
+            recorded_value, current_value = recorded.get(field), current.get(field)
+            if field == "similarity_details" and isinstance(recorded_value, dict) and isinstance(current_value, dict):
+                fields.extend(
+                    f"{field}.{key}"
+                    for key in sorted(set(recorded_value) | set(current_value))
+                    if cls.canonical(recorded_value.get(key)) != cls.canonical(current_value.get(key))
+                )
+            elif cls.canonical(recorded_value) != cls.canonical(current_value):
+                fields.append(field)
+        stages = {cls.stage(field) for field in fields}
+        return {
+            "recorded_digest": cls.digest(recorded),
+            "current_digest": cls.digest(current),
+            "fields": fields,
+            "stage": next((stage for stage in STAGES if stage in stages), None),
+        }
+
+    @staticmethod
+    def stage(field: str) -> str:
+        """Stage of the pipeline a field of the results of a comparison comes from"""
+        if field.startswith("similarity_details."):
+            return DETAIL_STAGES.get(field.split(".", 1)[1], "scoring")
+        return RESULT_STAGES.get(field, "scoring")
+
+    @staticmethod
+    def sample(pairs: Sequence[Tuple[str, str]], seed: int, size: int) -> List[Tuple[str, str]]:
+        """
+        Pairs drawn from the seed, the same ones whatever the order of the given pairs, all of them if there are no
+        more than the size
+        """
+        ordered = sorted(pairs)
+        if len(ordered) <= size:
+            return ordered
+        return sorted(random.Random(seed).sample(ordered, size))
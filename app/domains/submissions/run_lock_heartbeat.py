@@ -0,0 +1,28 @@
+import asyncio
+import logging
+from typing import Dict
+
+logger = logging.getLogger(__name__)
+
+
+def maintain_detection_run_locks() -> Dict[str, int]:
+    """Renew the locks of the runs of this instance and take over the stale ones, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.domains.submissions.submissions_models import get_paris_time
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).get_detection_run_locks().maintain(get_paris_time())
+    finally:
+        session.close()
+
+
+async def maintain_detection_run_locks_periodically(interval_seconds: float) -> None:
+    """Renew and take over the locks of the detection runs in a thread at every interval, until cancelled at shutdown"""
+    while True:
+        try:
+            await asyncio.to_thread(maintain_detection_run_locks)
+        except Exception as e:
+            logger.error(f"Failed to maintain the detection run locks: {e}")
+        await asyncio.sleep(interval_seconds)
@@ -0,0 +1,46 @@
+import logging
+from pathlib import Path
+from typing import Iterable, List
+
+from app.domains.repositories.ignore_rules import IgnoreRules
+from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessingResult
+
+logger = logging.getLogger(__name__)
+
+
+class RunPathExclusion:
+    """
+    Paths excluded by a detection run from its comparisons (gitignore syntax, relative to the root of each
+    submission): the matching files are left out of the files compared, the stored submissions being untouched
+    """
+
+    def __init__(self, patterns: Iterable[str] = ()):
+        self.patterns = list(patterns or [])
+        self.rules = IgnoreRules(self.patterns)
+
+    @property
+    def enabled(self) -> bool:
+        """Whether any file can be excluded"""
+        return bool(self.patterns)
+
+    def apply(self, selection: GoPackagePreprocessingResult, repo_path: Path) -> List[str]:
+        """
+        Remove from the selection of a submission the files matching the excluded paths
+
+        Returns:
+            Relative paths of the excluded files
+        """
+        if not self.enabled:
+            return []
+        compared_files = []
+        excluded_files = []
+        for file_path in selection.files:
+            relative_path = file_path.relative_to(repo_path).as_posix()
+            if self.rules.ignores(relative_path):
+                excluded_files.append(relative_path)
+            else:
+                compared_files.append(file_path)
+        if excluded_files:
+            logger.info(f"Excluding {len(excluded_files)} files matching the paths excluded by the detection run")
+        selection.files = compared_files
+        return excluded_files
@@ -0,0 +1,74 @@
+import threading
+import time
+from collections import deque
+from typing import Any, Callable, Deque, Dict, Optional
+
+# Period the throughput of a detection run is measured over, its ETA following the recent pace of the workers
+DEFAULT_THROUGHPUT_WINDOW_SECONDS = 300.0
+
+
+class RunProgressTracker:
+    """
+    Recent completions of the pairs of the detection runs in progress, kept in memory by the process comparing them,
+    for their throughput over the last `window_seconds`. The count of the completed pairs itself is stored on the
+    run; a run followed by no tracker (started by another process, or before a restart) has no throughput.
+    """
+
+    def __init__(
+        self, window_seconds: float = DEFAULT_THROUGHPUT_WINDOW_SECONDS, clock: Callable[[], float] = time.monotonic
+    ):
+        self.window_seconds = window_seconds
+        self._clock = clock
+        self._lock = threading.Lock()
+        self._started: Dict[Any, float] = {}
+        self._completions: Dict[Any, Deque[float]] = {}
+
+    def start(self, run_id: Any) -> None:
+        """Start following a run, its throughput measured from now"""
+        with self._lock:
+            self._started[run_id] = self._clock()
+            self._completions[run_id] = deque()
+
+    def record(self, run_id: Any, count: int = 1) -> None:
+        """Record the completion of pairs of a run"""
+        with self._lock:
+            now = self._clock()
+            self._started.setdefault(run_id, now)
+            completions = self._completions.setdefault(run_id, deque())
+            completions.extend([now] * count)
+            self._expire(completions, now)
+
+    def throughput(self, run_id: Any) -> Optional[float]:
+        """Pairs completed per second over the window (or since the run started, if more recently), None if unknown"""
+        with self._lock:
+            if run_id not in self._started:
+                return None
+            now = self._clock()
+            completions = self._completions[run_id]
+            self._expire(completions, now)
+            elapsed = min(self.window_seconds, now - self._started[run_id])
+            return len(completions) / elapsed if elapsed > 0 else None
+
+    def forget(self, run_id: Any) -> None:
+        """Stop following a finished run"""
+        with self._lock:
+            self._started.pop(run_id, None)
+            self._completions.pop(run_id, None)
+
+    @staticmethod
+    def estimate(pair_count: int, completed_pair_count: int, throughput: Optional[float]) -> Dict[str, Any]:
+        """Percentage of the pairs of a run completed, and the seconds left at the given throughput (None if unknown)"""
+        remaining = max(pair_count - completed_pair_count, 0)
+        percentage = 100.0 if not pair_count else round(100.0 * min(completed_pair_count, pair_count) / pair_count, 1)
+        if not remaining:
+            eta_seconds = 0.0
+        elif throughput:
+            eta_seconds = round(remaining / throughput, 1)
+        else:
+            eta_seconds = None
+        return {"progress_percentage": percentage, "eta_seconds": eta_seconds, "pairs_per_second": throughput}
+
+    def _expire(self, completions: Deque[float], now: float) -> None:
+        """Drop the completions older than the window"""
+        while completions and completions[0] <= now - self.window_seconds:
+            completions.popleft()
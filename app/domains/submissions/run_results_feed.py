@@ -0,0 +1,41 @@
+import threading
+from typing import Any, Dict
+
+# Seconds a follower of a run waits for a notification before reading the database again, the pairs of the runs
+# compared by another process being only seen by reading it
+DEFAULT_POLL_SECONDS = 2.0
+
+
+class RunResultsFeed:
+    """
+    Notifications of the pairs of the detection runs written to the database, kept in memory by the process comparing
+    them, so that the streams following a run read its new pairs as soon as they are written instead of polling. A
+    follower reads the version of a run before reading its pairs, then waits for a later version, so that no write
+    between the two is missed.
+    """
+
+    def __init__(self):
+        self._condition = threading.Condition()
+        self._versions: Dict[Any, int] = {}
+
+    def version(self, run_id: Any) -> int:
+        """Current version of a run, raised by each notification"""
+        with self._condition:
+            return self._versions.get(run_id, 0)
+
+    def publish(self, run_id: Any) -> None:
+        """Notify that pairs of a run were written, or that it finished, waking its followers"""
+        with self._condition:
+            self._versions[run_id] = self._versions.get(run_id, 0) + 1
+            self._condition.notify_all()
+
+    def wait(self, run_id: Any, version: int, timeout_seconds: float = DEFAULT_POLL_SECONDS) -> bool:
+        """Wait for a version of a run later than the given one, up to the timeout. Returns whether it came."""
+        with self._condition:
+            return self._condition.wait_for(lambda: self._versions.get(run_id, 0) != version, timeout_seconds)
+
+    def forget(self, run_id: Any) -> None:
+        """Stop following a finished run, waking its followers one last time"""
+        with self._condition:
+            self._versions.pop(run_id, None)
+            self._condition.notify_all()
@@ -0,0 +1,101 @@
+import math
+from typing import Any, Dict, List, Optional
+
+from app.domains.submissions.submissions_models import SimilarityStatus, get_paris_time
+
+# Number of buckets of the stored score histogram, rebucketed into any number of buckets dividing it
+HISTOGRAM_RESOLUTION = 100
+
+DEFAULT_SUMMARY_BUCKETS = 10
+DEFAULT_CENTRAL_SUBMISSIONS = 10
+
+
+class DetectionRunSummarizer:
+    """
+    Summarize the score distribution of a detection run from its similarity matrix: the histogram of the scores of
+    its completed pairs, their mean, median and 95th percentile, the number of flagged pairs and of clusters, and
+    the submissions ranked by their number of flagged pairs, the most "central" ones first.
+
+    The summary is computed once, the scores being kept as a histogram of HISTOGRAM_RESOLUTION buckets: a view of
+    the summary merges its buckets into the requested number of buckets, and lists the requested number of central
+    submissions, without reading the pairs again. A run some of whose pairs are not compared yet has a partial
+    summary, marked as incomplete.
+    """
+
+    def summarize(self, matrix: Dict[str, Any], pair_count: int) -> Dict[str, Any]:
+        """Summary of a run from its matrix, all of its pairs being listed, and its number of distinct pairs"""
+        entries = matrix["pairs"]
+        completed = [entry for entry in entries if entry["status"] == SimilarityStatus.COMPLETED]
+        scores = sorted(entry["overall_similarity"] for entry in completed)
+        failed = len([entry for entry in entries if entry["status"] == SimilarityStatus.FAILED])
+        timed_out = len([entry for entry in entries if entry["status"] == SimilarityStatus.TIMED_OUT])
+
+        score_counts = [0] * HISTOGRAM_RESOLUTION
+        for score in scores:
+            score_counts[min(math.floor(round(score * HISTOGRAM_RESOLUTION, 6)), HISTOGRAM_RESOLUTION - 1)] += 1
+
+        return {
+            "complete": len(scores) + failed + timed_out >= pair_count,
+            "pair_count": pair_count,
+            "completed_pairs": len(scores),
+            "failed_pairs": failed,
+            "timed_out_pairs": timed_out,
+            "pending_pairs": max(pair_count - len(scores) - failed - timed_out, 0),
+            "flag_threshold": matrix["flag_threshold"],
+            "min_token_count": matrix["min_token_count"],
+            "merge_threshold": matrix["merge_threshold"],
+            "score_counts": score_counts,
+            "mean": round(sum(scores) / len(scores), 4) if scores else None,
+            "median": self._median(scores),
+            "p95": scores[math.ceil(0.95 * len(scores)) - 1] if scores else None,
+            "flagged_pairs": len(matrix["flagged_pairs"]),
+            "cluster_count": len(matrix["clusters"]),
+            "central_submissions": self._central_submissions(matrix["flagged_pairs"]),
+            "computed_at": get_paris_time().isoformat(),
+        }
+
+    @staticmethod
+    def view(
+        summary: Dict[str, Any], buckets: int = DEFAULT_SUMMARY_BUCKETS, top: int = DEFAULT_CENTRAL_SUBMISSIONS
+    ) -> Dict[str, Any]:
+        """A summary with its histogram in a number of buckets dividing HISTOGRAM_RESOLUTION, and its top submissions"""
+        width = HISTOGRAM_RESOLUTION // buckets
+        counts = summary["score_counts"]
+        histogram = [
+            {
+                "min": round(index / buckets, 4),
+                "max": round((index + 1) / buckets, 4),
+                "count": sum(counts[index * width : (index + 1) * width]),
+            }
+            for index in range(buckets)
+        ]
+        view = {key: value for key, value in summary.items() if key != "score_counts"}
+        return {**view, "histogram": histogram, "central_submissions": summary["central_submissions"][:top]}
+
+    @staticmethod
+    def _median(scores: List[float]) -> Optional[float]:
+        if not scores:
+            return None
+        middle = len(scores) // 2
+        return scores[middle] if len(scores) % 2 else round((scores[middle - 1] + scores[middle]) / 2, 4)
+
+    @staticmethod
+    def _central_submissions(flagged_pairs: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Submissions of the flagged pairs, by decreasing number of flagged pairs then highest similarity"""
+        submissions: Dict[str, Dict[str, Any]] = {}
+        for entry in flagged_pairs:
+            for submission_id in (entry["submission_id"], entry["compared_submission_id"]):
+                submission = submissions.setdefault(
+                    str(submission_id),
+                    {"submission_id": str(submission_id), "flagged_pair_count": 0, "max_similarity": 0.0},
+                )
+                submission["flagged_pair_count"] += 1
+                submission["max_similarity"] = max(submission["max_similarity"], entry["overall_similarity"])
+        return sorted(
+            submissions.values(),
+            key=lambda submission: (
+                -submission["flagged_pair_count"],
+                -submission["max_similarity"],
+                submission["submission_id"],
+            ),
+        )
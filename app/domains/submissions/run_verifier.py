@@ -0,0 +1,125 @@
+import logging
+from typing import Any, Callable, Dict, Iterable, Optional, Tuple
+from uuid import UUID
+
+from app.domains.submissions.run_fingerprint import STAGES, RunFingerprint
+from app.domains.submissions.submissions_models import (
+    SimilarityStatus,
+    Submission,
+    SubmissionDetectionRun,
+    SubmissionSimilarity,
+    get_paris_time,
+)
+from app.shared.deadlines import StageTimeout, stage_deadline
+
+logger = logging.getLogger(__name__)
+
+
+class DetectionRunVerifier:
+    """
+    Verification that a finished detection run is reproducible: a sample of its completed pairs, drawn from a seed,
+    is compared again with the given function (submission, compared submission, returning the fields of the results
+    of its similarity record), each comparison within the given timeout, and the results are asserted
+    byte-identical to the recorded ones (read with the given function), their timing aside. A divergence is reported
+    per pair with the first stage of the pipeline it occurs in, and the changes of the fingerprint of the run since
+    it was recorded with the environment stage. The pairs whose comparison was replaced since the run are not
+    sampled, only counted as superseded.
+    """
+
+    def __init__(
+        self,
+        compare: Callable[[Submission, Submission], Dict[str, Any]],
+        recorded_results: Callable[[SubmissionSimilarity], Dict[str, Any]],
+        comparison_timeout_seconds: Optional[float],
+        language_versions: Dict[str, str],
+    ):
+        self.compare = compare
+        self.recorded_results = recorded_results
+        self.comparison_timeout_seconds = comparison_timeout_seconds
+        self.language_versions = language_versions
+
+    def verify(
+        self,
+        run: SubmissionDetectionRun,
+        submissions: Dict[UUID, Submission],
+        similarities: Iterable[SubmissionSimilarity],
+        cache_keys: Dict[Tuple[UUID, UUID], str],
+        sample_size: int,
+        seed: int,
+    ) -> Dict[str, Any]:
+        """
+        Verify a sample of the completed pairs of a run whose comparison is current (with the current key of its
+        pair, or recorded before the cache, without key), returning the report of the verification
+        """
+        completed = self.completed_pairs(similarities)
+        # A comparison recorded before the cache, without key, is verified as it is
+        current_pairs = [
+            pair
+            for pair, similarity in completed.items()
+            if similarity.cache_key is None
+            or similarity.cache_key == cache_keys.get((similarity.submission_id, similarity.compared_submission_id))
+        ]
+        reports = [
+            self._verify_pair(completed[pair], submissions)
+            for pair in RunFingerprint.sample(current_pairs, seed, sample_size)
+        ]
+
+        fingerprint = RunFingerprint.build(self.language_versions, run.effective_options)
+        environment_differences = RunFingerprint.differences(run.fingerprint, fingerprint) if run.fingerprint else []
+        diverged = [report for report in reports if report["status"] == "diverged"]
+        failed = [report for report in reports if report["status"] == "failed"]
+        stages = {report["stage"] for report in diverged}
+        if environment_differences and (diverged or failed):
+            stages.add("environment")
+        stage = next((stage for stage in ("environment", *STAGES) if stage in stages), None)
+        logger.info(
+            f"Verified {len(reports)} pairs of detection run {run.id} (seed {seed}): {len(diverged)} diverged, "
+            f"{len(failed)} failed, {len(environment_differences)} changes of the environment"
+        )
+        return {
+            "run_id": run.id,
+            "seed": seed,
+            "reproducible": not diverged and not failed,
+            "stage": stage,
+            "fingerprint": run.fingerprint,
+            "current_fingerprint": fingerprint,
+            "environment_differences": environment_differences,
+            "completed_pair_count": len(completed),
+            "superseded_pair_count": len(completed) - len(current_pairs),
+            "verified_pair_count": len(reports),
+            "identical_pair_count": len(reports) - len(diverged) - len(failed),
+            "diverged_pair_count": len(diverged),
+            "failed_pair_count": len(failed),
+            "pairs": reports,
+            "verified_at": get_paris_time(),
+        }
+
+    @staticmethod
+    def completed_pairs(similarities: Iterable[SubmissionSimilarity]) -> Dict[Tuple[str, str], SubmissionSimilarity]:
+        """Completed similarity records by pair of submission IDs, sorted, the first one of each pair kept"""
+        completed = {}
+        for similarity in similarities:
+            if similarity.status == SimilarityStatus.COMPLETED:
+                pair = tuple(sorted((str(similarity.submission_id), str(similarity.compared_submission_id))))
+                completed.setdefault(pair, similarity)
+        return completed
+
+    def _verify_pair(self, similarity: SubmissionSimilarity, submissions: Dict[UUID, Submission]) -> Dict[str, Any]:
+        """Compare a pair again, reporting whether its results are identical, diverged or failed to be computed"""
+        report = {
+            "similarity_id": similarity.id,
+            "submission_id": similarity.submission_id,
+            "compared_submission_id": similarity.compared_submission_id,
+        }
+        try:
+            with stage_deadline("comparison", self.comparison_timeout_seconds):
+                results = self.compare(
+                    submissions[similarity.submission_id], submissions[similarity.compared_submission_id]
+                )
+        except StageTimeout as e:
+            message = f"Comparison timed out after {e.elapsed_seconds:.1f} seconds"
+            return {**report, "status": "failed", "error": message}
+        except Exception as e:
+            return {**report, "status": "failed", "error": str(e)}
+        divergence = RunFingerprint.divergence(self.recorded_results(similarity), results)
+        return {**report, **divergence, "status": "diverged" if divergence["fields"] else "identical"}
@@ -0,0 +1,85 @@
+from typing import Any, Dict, List
+from uuid import UUID
+
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+# Similarity of a pair bridging two clusters of several submissions required to merge them
+DEFAULT_MERGE_THRESHOLD = 0.85
+
+
+class SimilarityClusterer:
+    """
+    Group the submissions sharing code from the flagged pairs of a detection run, by single linkage: the flagged
+    pairs are linked by decreasing similarity, a submission joining the cluster of any submission it is flagged
+    with. A pair bridging two clusters of several submissions merges them only at or above the merge threshold,
+    so that a chain of moderately similar pairs does not end up in a single giant cluster.
+    """
+
+    def __init__(self, merge_threshold: float = DEFAULT_MERGE_THRESHOLD):
+        self.merge_threshold = merge_threshold
+
+    def cluster(self, entries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """
+        Get the clusters of the pairs of a run (the entries of its similarity matrix), largest first, with the
+        similarities of their internal pairs and their medoid
+        """
+        clusters: Dict[UUID, List[UUID]] = {}
+        flagged = [entry for entry in entries if entry["suspicious"] and entry["status"] == SimilarityStatus.COMPLETED]
+        # The pairs of the same similarity are linked by their submission IDs, for the same clusters on every run
+        flagged.sort(
+            key=lambda entry: (
+                -entry["overall_similarity"],
+                str(entry["submission_id"]),
+                str(entry["compared_submission_id"]),
+            )
+        )
+        for entry in flagged:
+            first = clusters.setdefault(entry["submission_id"], [entry["submission_id"]])
+            second = clusters.setdefault(entry["compared_submission_id"], [entry["compared_submission_id"]])
+            if first is second:
+                continue
+            if len(first) > 1 and len(second) > 1 and entry["overall_similarity"] < self.merge_threshold:
+                continue
+            first.extend(second)
+            for submission_id in second:
+                clusters[submission_id] = first
+
+        unique = {id(members): members for members in clusters.values()}.values()
+        results = [self._describe(members, entries) for members in unique]
+        return sorted(
+            results,
+            key=lambda cluster: (
+                -cluster["size"],
+                -cluster["max_similarity"],
+                [str(submission_id) for submission_id in cluster["submission_ids"]],
+            ),
+        )
+
+    @staticmethod
+    def _describe(members: List[UUID], entries: List[Dict[str, Any]]) -> Dict[str, Any]:
+        """Similarities of the completed pairs within a cluster, and the member most similar to the others"""
+        member_set = set(members)
+        internal = [
+            entry
+            for entry in entries
+            if entry["status"] == SimilarityStatus.COMPLETED
+            and entry["submission_id"] in member_set
+            and entry["compared_submission_id"] in member_set
+        ]
+        similarities = [entry["overall_similarity"] for entry in internal]
+
+        # Pairs of the cluster not compared count as dissimilar
+        totals = {submission_id: 0.0 for submission_id in members}
+        for entry in internal:
+            totals[entry["submission_id"]] += entry["overall_similarity"]
+            totals[entry["compared_submission_id"]] += entry["overall_similarity"]
+        medoid = max(sorted(members, key=str), key=lambda submission_id: totals[submission_id])
+
+        return {
+            "submission_ids": sorted(members, key=str),
+            "size": len(members),
+            "min_similarity": min(similarities),
+            "avg_similarity": round(sum(similarities) / len(similarities), 3),
+            "max_similarity": max(similarities),
+            "medoid_submission_id": medoid,
+        }
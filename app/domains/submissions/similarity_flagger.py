@@ -0,0 +1,54 @@
+from typing import Any, Dict, Iterable, List, Optional, Set
+from uuid import UUID
+
+from app.domains.submissions.submissions_models import SubmissionSimilarity
+
+
+class SimilarityFlagger:
+    """
+    Flag the suspicious pairs of a detection run with the sensitivity of its assignment: pairs scoring at or above
+    `flag_threshold` are suspicious, while the submissions with fewer than `min_token_count` compared tokens (a
+    30-line exercise shares most of its tokens with any other solution) are listed as too short, their pairs being
+    neither flagged nor counted in the comparisons of the run.
+    """
+
+    def __init__(self, flag_threshold: float, min_token_count: int):
+        self.flag_threshold = flag_threshold
+        self.min_token_count = min_token_count
+
+    @staticmethod
+    def token_count(similarity: SubmissionSimilarity, submission_id: UUID) -> Optional[int]:
+        """Number of compared tokens of one of the submissions of a pair, None if the pair was not compared"""
+        counts = (similarity.similarity_details or {}).get("processed_tokens_count") or {}
+        side = "submission1" if similarity.submission_id == submission_id else "submission2"
+        return counts.get(side)
+
+    def too_short_submissions(self, similarities: Iterable[SubmissionSimilarity]) -> Set[UUID]:
+        """Get the submissions of the pairs having fewer compared tokens than the minimum"""
+        too_short = set()
+        for similarity in similarities:
+            for submission_id in (similarity.submission_id, similarity.compared_submission_id):
+                count = self.token_count(similarity, submission_id)
+                if count is not None and count < self.min_token_count:
+                    too_short.add(submission_id)
+        return too_short
+
+    def flag(self, similarity: SubmissionSimilarity, too_short: Set[UUID]) -> Dict[str, bool]:
+        """Get the flags of a pair, given the too short submissions of the run"""
+        is_too_short = similarity.submission_id in too_short or similarity.compared_submission_id in too_short
+        return {
+            "suspicious": not is_too_short and similarity.overall_similarity >= self.flag_threshold,
+            "too_short": is_too_short,
+        }
+
+    def summarize(self, similarities: List[SubmissionSimilarity]) -> Dict[str, Any]:
+        """Get the counts of a run, the pairs of too short submissions excluded from the comparisons"""
+        too_short = self.too_short_submissions(similarities)
+        counted = [similarity for similarity in similarities if not self.flag(similarity, too_short)["too_short"]]
+        return {
+            "flag_threshold": self.flag_threshold,
+            "min_token_count": self.min_token_count,
+            "compared_pairs": len(counted),
+            "flagged_pairs": len([s for s in counted if s.overall_similarity >= self.flag_threshold]),
+            "too_short_submissions": sorted(too_short, key=str),
+        }
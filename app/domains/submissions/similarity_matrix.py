@@ -0,0 +1,261 @@
+from enum import Enum
+from typing import Any, Dict, FrozenSet, List, Optional, Set, Tuple
+from uuid import UUID
+
+from app.domains.submissions.similarity_clusterer import DEFAULT_MERGE_THRESHOLD, SimilarityClusterer
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from app.domains.submissions.submissions_models import (
+    SimilarityStatus,
+    Submission,
+    SubmissionCorpus,
+    SubmissionCorpusItem,
+    SubmissionCorpusMatch,
+    SubmissionSimilarity,
+)
+
+
+class PairSortField(str, Enum):
+    """Scores the listed pairs of a matrix are sorted by, decreasing"""
+
+    OVERALL_SIMILARITY = "overall_similarity"  # Score of the whole submissions
+    MAX_FUNCTION_SIMILARITY = "max_function_similarity"  # Score of their most similar functions, pairs without last
+
+
+class SimilarityMatrix:
+    """
+    Pairwise similarity matrix of the submissions of a detection run, represented sparsely: the pairs are listed
+    by decreasing similarity from a floor, along with the flagged pairs, their clusters and the maximum similarity
+    of each submission. Each unordered pair is compared once, (A, B) and (B, A) being the same similarity record.
+
+    The pairs of members of the same team (the submissions without a team being their own team) legitimately
+    share code: they are marked as such and, unless included, neither flagged nor clustered.
+
+    The pairs involving a submission deleted since the run keep their results, only marked as such.
+    """
+
+    def __init__(
+        self,
+        flagger: SimilarityFlagger,
+        merge_threshold: float = DEFAULT_MERGE_THRESHOLD,
+        teams: Optional[Dict[UUID, str]] = None,
+        include_same_team: bool = False,
+        deleted_submission_ids: Optional[Set[UUID]] = None,
+    ):
+        self.flagger = flagger
+        self.clusterer = SimilarityClusterer(merge_threshold)
+        self.teams = teams or {}
+        self.include_same_team = include_same_team
+        self.deleted_submission_ids = deleted_submission_ids or set()
+
+    def same_team(self, submission_id: UUID, compared_submission_id: UUID) -> bool:
+        """Whether two submissions belong to the same team"""
+        team = self.teams.get(submission_id)
+        return team is not None and team == self.teams.get(compared_submission_id)
+
+    @staticmethod
+    def pair_key(submission_id: UUID, compared_submission_id: UUID) -> FrozenSet[UUID]:
+        """Key of a pair of submissions, whatever their order"""
+        return frozenset((submission_id, compared_submission_id))
+
+    @staticmethod
+    def pairs(submissions: List[Submission]) -> List[Tuple[Submission, Submission]]:
+        """
+        Distinct unordered pairs of the submissions, without self pairs. As when a submission is created, the
+        submissions of the same group are not compared with each other.
+        """
+        unique = list({submission.id: submission for submission in submissions}.values())
+        return [
+            (submission, other)
+            for index, submission in enumerate(unique)
+            for other in unique[index + 1 :]
+            if submission.group_uuid != other.group_uuid
+        ]
+
+    def build(
+        self,
+        submission_ids: List[UUID],
+        similarities: List[SubmissionSimilarity],
+        min_similarity: float = 0.0,
+        skip: int = 0,
+        limit: int = 100,
+        flagged_only: bool = False,
+        sort_by: PairSortField = PairSortField.OVERALL_SIMILARITY,
+    ) -> Dict[str, Any]:
+        """
+        Get the matrix of the submissions from their similarity records, the listed pairs (from the minimum
+        similarity, the flagged ones only if requested) sorted by the given score and paginated
+        """
+        records: Dict[FrozenSet[UUID], SubmissionSimilarity] = {}
+        for similarity in similarities:
+            key = self.pair_key(similarity.submission_id, similarity.compared_submission_id)
+            if len(key) == 2 and key <= set(submission_ids):
+                records.setdefault(key, similarity)
+        # The pairs of the same similarity are ordered by their submission IDs, whatever the order of the records
+        ordered = sorted(
+            records.values(),
+            key=lambda similarity: (
+                -similarity.overall_similarity,
+                str(similarity.submission_id),
+                str(similarity.compared_submission_id),
+            ),
+        )
+
+        too_short = self.flagger.too_short_submissions(ordered)
+        entries = []
+        suppressed_pairs = 0
+        for similarity in ordered:
+            entry, suppressed = self.entry(similarity, too_short)
+            suppressed_pairs += suppressed
+            entries.append(entry)
+        listed = [
+            entry
+            for entry in entries
+            if entry["overall_similarity"] >= min_similarity and (entry["suspicious"] or not flagged_only)
+        ]
+        if sort_by == PairSortField.MAX_FUNCTION_SIMILARITY:
+            # The sort is stable, the pairs of the same function score staying by decreasing similarity
+            listed.sort(
+                key=lambda entry: (entry["max_function_similarity"] is None, -(entry["max_function_similarity"] or 0.0))
+            )
+
+        status_breakdown: Dict[str, int] = {}
+        for entry in entries:
+            status_breakdown[entry["status"]] = status_breakdown.get(entry["status"], 0) + 1
+
+        return {
+            "submission_ids": submission_ids,
+            "compared_pairs": len(entries),
+            "status_breakdown": status_breakdown,
+            "flag_threshold": self.flagger.flag_threshold,
+            "min_token_count": self.flagger.min_token_count,
+            "min_similarity": min_similarity,
+            "flagged_only": flagged_only,
+            "sort_by": PairSortField(sort_by),
+            "total_pairs": len(listed),
+            "skip": skip,
+            "limit": limit,
+            "pairs": listed[skip : skip + limit],
+            "flagged_pairs": [entry for entry in entries if entry["suspicious"]],
+            "include_same_team": self.include_same_team,
+            "same_team_pairs": len([entry for entry in entries if entry["same_team"]]),
+            "deleted_submission_ids": sorted(self.deleted_submission_ids.intersection(submission_ids), key=str),
+            "suppressed_pairs": suppressed_pairs,
+            "merge_threshold": self.clusterer.merge_threshold,
+            "clusters": self.clusterer.cluster(entries),
+            "max_similarities": self._max_similarities(submission_ids, entries),
+            "too_short_submissions": sorted(too_short, key=str),
+        }
+
+    def entry(self, similarity: SubmissionSimilarity, too_short: Set[UUID]) -> Tuple[Dict[str, Any], bool]:
+        """
+        Get the entry of a pair given the too short submissions of the run, and whether its flag is suppressed
+        for being a pair of teammates
+        """
+        entry = {
+            "similarity_id": similarity.id,
+            "submission_id": similarity.submission_id,
+            "compared_submission_id": similarity.compared_submission_id,
+            "overall_similarity": similarity.overall_similarity,
+            "max_function_similarity": similarity.max_function_similarity,
+            "status": similarity.status,
+            **self.flagger.flag(similarity, too_short),
+            "same_team": self.same_team(similarity.submission_id, similarity.compared_submission_id),
+            "deleted_submission": bool(
+                self.deleted_submission_ids.intersection((similarity.submission_id, similarity.compared_submission_id))
+            ),
+        }
+        suppressed = entry["same_team"] and entry["suspicious"] and not self.include_same_team
+        if suppressed:
+            entry["suspicious"] = False
+        return entry, suppressed
+
+    def build_corpus_matches(
+        self, matches: List[SubmissionCorpusMatch], labels: Dict[UUID, str], min_similarity: float = 0.0
+    ) -> List[Dict[str, Any]]:
+        """
+        Get the matches of the submissions with the archived ones from their records, one per submission and
+        archived submission (a completed record over a failed one), from the minimum similarity
+        """
+        return [
+            {
+                "submission_id": match.submission_id,
+                "corpus_item_id": match.corpus_item_id,
+                "corpus_label": labels.get(match.corpus_item_id, str(match.corpus_item_id)),
+                "overall_similarity": match.overall_similarity,
+                "jaccard_similarity": match.jaccard_similarity,
+                "shared_fingerprint_count": match.shared_fingerprint_count,
+                "status": match.status,
+                "suspicious": self._corpus_match_suspicious(match),
+            }
+            for match in self._corpus_records(matches)
+            if match.overall_similarity >= min_similarity
+        ]
+
+    def build_reference_matches(
+        self,
+        matches: List[SubmissionCorpusMatch],
+        items: Dict[UUID, SubmissionCorpusItem],
+        reference_sets: Dict[UUID, SubmissionCorpus],
+        min_similarity: float = 0.0,
+    ) -> List[Dict[str, Any]]:
+        """
+        Get the matches of the submissions with the files of the reference sets from their records, as those with
+        the archived submissions, only the files sharing fingerprints with the submission being listed
+        """
+        entries = []
+        for match in self._corpus_records(matches):
+            item = items.get(match.corpus_item_id)
+            reference_set = reference_sets.get(item.corpus_id) if item else None
+            if reference_set is None or match.overall_similarity < min_similarity:
+                continue
+            if match.status == SimilarityStatus.COMPLETED and not match.shared_fingerprint_count:
+                continue
+            entries.append(
+                {
+                    "submission_id": match.submission_id,
+                    "corpus_item_id": match.corpus_item_id,
+                    "reference_set_id": reference_set.id,
+                    "reference_label": reference_set.label,
+                    "path": item.path or item.label,
+                    "license_note": reference_set.license_note,
+                    "overall_similarity": match.overall_similarity,
+                    "shared_fingerprint_count": match.shared_fingerprint_count,
+                    "status": match.status,
+                    "suspicious": self._corpus_match_suspicious(match),
+                }
+            )
+        return entries
+
+    @staticmethod
+    def _corpus_records(matches: List[SubmissionCorpusMatch]) -> List[SubmissionCorpusMatch]:
+        """One match per submission and corpus item (a completed record over a failed one), most similar first"""
+        records: Dict[Tuple[UUID, UUID], SubmissionCorpusMatch] = {}
+        for match in sorted(matches, key=lambda m: (m.status != SimilarityStatus.COMPLETED, -m.overall_similarity)):
+            records.setdefault((match.submission_id, match.corpus_item_id), match)
+        return sorted(
+            records.values(),
+            key=lambda match: (-match.overall_similarity, str(match.submission_id), str(match.corpus_item_id)),
+        )
+
+    def _corpus_match_suspicious(self, match: SubmissionCorpusMatch) -> bool:
+        return match.status == SimilarityStatus.COMPLETED and match.overall_similarity >= self.flagger.flag_threshold
+
+    @staticmethod
+    def _max_similarities(submission_ids: List[UUID], entries: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Highest similarity of each submission among its completed comparisons, and the submission reaching it"""
+        maximums = {
+            submission_id: {"submission_id": submission_id, "max_similarity": None, "most_similar_submission_id": None}
+            for submission_id in submission_ids
+        }
+        # The entries are ordered by decreasing similarity, the first completed one of a submission is its maximum
+        for entry in entries:
+            if entry["status"] != SimilarityStatus.COMPLETED:
+                continue
+            for submission_id, other_id in (
+                (entry["submission_id"], entry["compared_submission_id"]),
+                (entry["compared_submission_id"], entry["submission_id"]),
+            ):
+                if maximums[submission_id]["max_similarity"] is None:
+                    maximums[submission_id]["max_similarity"] = entry["overall_similarity"]
+                    maximums[submission_id]["most_similar_submission_id"] = other_id
+        return list(maximums.values())
@@ -0,0 +1,40 @@
+from typing import List, Optional
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionAccessDenial
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionAccessDenialRepository:
+    """Repository for the audit log of the accesses denied to the callers of the service"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, denial_data: dict) -> SubmissionAccessDenial:
+        """Create a new access denial"""
+        try:
+            denial = SubmissionAccessDenial(**denial_data)
+            self.session.add(denial)
+            self.session.commit()
+            self.session.refresh(denial)
+            return denial
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create access denial: {str(e)}")
+
+    def list_recent(
+        self, subject: Optional[str] = None, limit: int = 100, tenant_id: Optional[str] = None
+    ) -> List[SubmissionAccessDenial]:
+        """List the latest access denials, latest first, only those of a caller and of a tenant if given"""
+        try:
+            statement = select(SubmissionAccessDenial)
+            if tenant_id is not None:
+                statement = statement.where(SubmissionAccessDenial.tenant_id == tenant_id)
+            if subject is not None:
+                statement = statement.where(SubmissionAccessDenial.subject == subject)
+            statement = statement.order_by(SubmissionAccessDenial.created_at.desc()).limit(limit)
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to list access denials: {str(e)}")
@@ -0,0 +1,84 @@
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionAllowedSnippet, get_paris_time
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionAllowedSnippetRepository:
+    """Repository for the allowed snippets of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, snippet_data: dict) -> SubmissionAllowedSnippet:
+        """Create a new allowed snippet record"""
+        try:
+            snippet = SubmissionAllowedSnippet(**snippet_data)
+            self.session.add(snippet)
+            self.session.commit()
+            self.session.refresh(snippet)
+            return snippet
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create allowed snippet: {str(e)}")
+
+    def get_by_id(self, snippet_id: UUID) -> Optional[SubmissionAllowedSnippet]:
+        """Get allowed snippet record by ID"""
+        try:
+            statement = select(SubmissionAllowedSnippet).where(SubmissionAllowedSnippet.id == snippet_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get allowed snippet: {str(e)}")
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionAllowedSnippet]:
+        """Get all allowed snippets of a project step"""
+        try:
+            statement = (
+                select(SubmissionAllowedSnippet)
+                .where(
+                    SubmissionAllowedSnippet.project_uuid == project_uuid,
+                    SubmissionAllowedSnippet.project_step_uuid == project_step_uuid,
+                )
+                .order_by(SubmissionAllowedSnippet.created_at)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get allowed snippets for project step: {str(e)}")
+
+    def update(self, snippet_id: UUID, snippet_data: dict) -> SubmissionAllowedSnippet:
+        """Update the given fields of an allowed snippet record"""
+        try:
+            snippet = self.get_by_id(snippet_id)
+            if not snippet:
+                raise NotFoundException("Allowed snippet", str(snippet_id))
+            for field, value in snippet_data.items():
+                setattr(snippet, field, value)
+            snippet.updated_at = get_paris_time()
+            self.session.add(snippet)
+            self.session.commit()
+            self.session.refresh(snippet)
+            return snippet
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update allowed snippet: {str(e)}")
+
+    def delete(self, snippet_id: UUID) -> bool:
+        """Delete an allowed snippet record"""
+        try:
+            snippet = self.get_by_id(snippet_id)
+            if not snippet:
+                raise NotFoundException("Allowed snippet", str(snippet_id))
+
+            self.session.delete(snippet)
+            self.session.commit()
+            return True
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete allowed snippet: {str(e)}")
@@ -0,0 +1,83 @@
+from typing import List, Optional
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionAnalysisProfile, get_paris_time
+from app.shared.exceptions import DatabaseException, NotFoundException
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+
+class SubmissionAnalysisProfileRepository:
+    """Repository for the analysis profiles, named bundles of detection options"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, profile_data: dict) -> SubmissionAnalysisProfile:
+        """Create a new analysis profile record"""
+        try:
+            profile = SubmissionAnalysisProfile(**profile_data)
+            self.session.add(profile)
+            self.session.commit()
+            self.session.refresh(profile)
+            return profile
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create analysis profile: {str(e)}")
+
+    def get_by_name(self, name: str, tenant_id: str = DEFAULT_TENANT_ID) -> Optional[SubmissionAnalysisProfile]:
+        """Get analysis profile record of a tenant by name"""
+        try:
+            statement = select(SubmissionAnalysisProfile).where(
+                SubmissionAnalysisProfile.tenant_id == tenant_id, SubmissionAnalysisProfile.name == name
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get analysis profile: {str(e)}")
+
+    def get_all(self, tenant_id: str = DEFAULT_TENANT_ID) -> List[SubmissionAnalysisProfile]:
+        """Get all the analysis profiles of a tenant, by name"""
+        try:
+            statement = (
+                select(SubmissionAnalysisProfile)
+                .where(SubmissionAnalysisProfile.tenant_id == tenant_id)
+                .order_by(SubmissionAnalysisProfile.name)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get analysis profiles: {str(e)}")
+
+    def update(self, name: str, profile_data: dict, tenant_id: str = DEFAULT_TENANT_ID) -> SubmissionAnalysisProfile:
+        """Update the given fields of an analysis profile record of a tenant"""
+        try:
+            profile = self.get_by_name(name, tenant_id)
+            if not profile:
+                raise NotFoundException("Analysis profile", name)
+            for field, value in profile_data.items():
+                setattr(profile, field, value)
+            profile.updated_at = get_paris_time()
+            self.session.add(profile)
+            self.session.commit()
+            self.session.refresh(profile)
+            return profile
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update analysis profile: {str(e)}")
+
+    def delete(self, name: str, tenant_id: str = DEFAULT_TENANT_ID) -> bool:
+        """Delete an analysis profile record of a tenant"""
+        try:
+            profile = self.get_by_name(name, tenant_id)
+            if not profile:
+                raise NotFoundException("Analysis profile", name)
+
+            self.session.delete(profile)
+            self.session.commit()
+            return True
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete analysis profile: {str(e)}")
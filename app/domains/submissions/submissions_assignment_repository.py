@@ -0,0 +1,45 @@
+from typing import Optional
+from uuid import UUID
+
+from sqlalchemy.exc import IntegrityError
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionAssignment
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionAssignmentRepository:
+    """Repository for the tenants of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_tenant(self, project_step_uuid: UUID) -> Optional[str]:
+        """Get the tenant a project step belongs to, None if no tenant acted on it yet"""
+        try:
+            statement = select(SubmissionAssignment).where(SubmissionAssignment.project_step_uuid == project_step_uuid)
+            assignment = self.session.exec(statement).first()
+            return assignment.tenant_id if assignment else None
+        except Exception as e:
+            raise DatabaseException(f"Failed to get assignment: {str(e)}")
+
+    def claim(self, project_step_uuid: UUID, tenant_id: str) -> str:
+        """
+        Register a project step to a tenant unless registered already, returning the tenant the step belongs to:
+        of two tenants acting on a new step at once, the first registered owns it
+        """
+        try:
+            tenant = self.get_tenant(project_step_uuid)
+            if tenant is not None:
+                return tenant
+            self.session.add(SubmissionAssignment(project_step_uuid=project_step_uuid, tenant_id=tenant_id))
+            self.session.commit()
+            return tenant_id
+        except IntegrityError:
+            self.session.rollback()
+            return self.get_tenant(project_step_uuid)
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to register assignment: {str(e)}")
@@ -0,0 +1,51 @@
+from typing import List
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionAuditEntry
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionAuditRepository:
+    """Repository for the audit trail of the changes of the metadata of the submissions"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, entry_data: dict) -> SubmissionAuditEntry:
+        """Create a new audit entry"""
+        try:
+            entry = SubmissionAuditEntry(**entry_data)
+            self.session.add(entry)
+            self.session.commit()
+            self.session.refresh(entry)
+            return entry
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create audit entry: {str(e)}")
+
+    def get_by_submission_id(self, submission_id: UUID) -> List[SubmissionAuditEntry]:
+        """Get the audit trail of a submission, oldest change first"""
+        try:
+            statement = (
+                select(SubmissionAuditEntry)
+                .where(SubmissionAuditEntry.submission_id == submission_id)
+                .order_by(SubmissionAuditEntry.created_at)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get audit entries: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the audit trail of a submission, returning the number of its entries"""
+        try:
+            statement = select(SubmissionAuditEntry).where(SubmissionAuditEntry.submission_id == submission_id)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete audit entries: {str(e)}")
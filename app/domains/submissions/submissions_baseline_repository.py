@@ -0,0 +1,65 @@
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionBaseline
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionBaselineRepository:
+    """Repository for the starter code (baseline) of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, baseline_data: dict) -> SubmissionBaseline:
+        """Create a new baseline record"""
+        try:
+            baseline = SubmissionBaseline(**baseline_data)
+            self.session.add(baseline)
+            self.session.commit()
+            self.session.refresh(baseline)
+            return baseline
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create baseline: {str(e)}")
+
+    def get_by_id(self, baseline_id: UUID) -> Optional[SubmissionBaseline]:
+        """Get baseline record by ID"""
+        try:
+            statement = select(SubmissionBaseline).where(SubmissionBaseline.id == baseline_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get baseline: {str(e)}")
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionBaseline]:
+        """Get all baselines of a project step"""
+        try:
+            statement = (
+                select(SubmissionBaseline)
+                .where(
+                    SubmissionBaseline.project_uuid == project_uuid,
+                    SubmissionBaseline.project_step_uuid == project_step_uuid,
+                )
+                .order_by(SubmissionBaseline.created_at)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get baselines for project step: {str(e)}")
+
+    def delete(self, baseline_id: UUID) -> bool:
+        """Delete a baseline record"""
+        try:
+            baseline = self.get_by_id(baseline_id)
+            if not baseline:
+                raise NotFoundException(f"Baseline with ID {baseline_id} not found")
+
+            self.session.delete(baseline)
+            self.session.commit()
+            return True
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete baseline: {str(e)}")
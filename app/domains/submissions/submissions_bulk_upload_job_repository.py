@@ -0,0 +1,54 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionBulkUploadJob
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionBulkUploadJobRepository:
+    """Repository for the bulk uploads of submissions, processed in the background"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, job_data: dict) -> SubmissionBulkUploadJob:
+        """Create a new bulk upload job record"""
+        try:
+            job = SubmissionBulkUploadJob(**job_data)
+            self.session.add(job)
+            self.session.commit()
+            self.session.refresh(job)
+            return job
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create bulk upload job: {str(e)}")
+
+    def get_by_id(self, job_id: UUID) -> Optional[SubmissionBulkUploadJob]:
+        """Get bulk upload job record by ID, as last committed"""
+        try:
+            statement = select(SubmissionBulkUploadJob).where(SubmissionBulkUploadJob.id == job_id)
+            return self.session.exec(statement.execution_options(populate_existing=True)).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get bulk upload job: {str(e)}")
+
+    def update(self, job_id: UUID, job_data: dict) -> SubmissionBulkUploadJob:
+        """Update the given fields of a bulk upload job record"""
+        try:
+            job = self.get_by_id(job_id)
+            if not job:
+                raise NotFoundException(f"Bulk upload job with ID {job_id} not found")
+            for field, value in job_data.items():
+                setattr(job, field, value)
+            job.updated_at = datetime.utcnow()
+            self.session.add(job)
+            self.session.commit()
+            self.session.refresh(job)
+            return job
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update bulk upload job: {str(e)}")
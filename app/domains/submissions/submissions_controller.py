@@ -1,30 +1,132 @@
-from typing import List, Optional
+import hashlib
+from datetime import datetime
+from typing import Any, Callable, List, Optional
 from uuid import UUID
 
-from fastapi import APIRouter, Depends, HTTPException, Query, Request
+from fastapi import APIRouter, Depends, File, Form, Header, HTTPException, Query, Request, UploadFile
+from fastapi.encoders import jsonable_encoder
+from fastapi.responses import HTMLResponse, JSONResponse, Response, StreamingResponse
 from sqlmodel import Session
 
+from app.config.config import get_settings
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.repositories.archive_extractor import UPLOAD_TOO_LARGE, ArchiveLimitExceeded
+from app.domains.submissions.access_policy import AccessDenied
+from app.domains.submissions.analysis_worker_pool import AnalysisPoolDraining, AnalysisQueueFull
+from app.domains.submissions.bulk_upload_splitter import DEFAULT_DIRECTORY_PATTERN
+from app.domains.submissions.dto.access_denial_dto import AccessDenialDto
+from app.domains.submissions.dto.analysis_profile_dto import (
+    AnalysisProfileResponseDto,
+    CreateAnalysisProfileDto,
+    UpdateAnalysisProfileDto,
+)
+from app.domains.submissions.dto.allowed_snippet_dto import (
+    AllowedSnippetResponseDto,
+    CreateAllowedSnippetDto,
+    UpdateAllowedSnippetDto,
+)
+from app.domains.submissions.dto.baseline_response_dto import BaselineResponseDto
+from app.domains.submissions.dto.bulk_upload_dto import BulkUploadJobResponseDto
+from app.domains.submissions.dto.code_metrics_dto import CodeMetricsDto
+from app.domains.submissions.dto.code_search_dto import CodeSearchDto, CodeSearchResponseDto
+from app.domains.submissions.dto.corpus_response_dto import CorpusItemResponseDto, CorpusResponseDto
+from app.domains.submissions.dto.create_baseline_dto import CreateBaselineDto
+from app.domains.submissions.dto.create_corpus_dto import CreateCorpusDto, CreateCorpusItemDto, StepCorporaDto
+from app.domains.submissions.dto.create_git_submission_dto import CreateGitSubmissionDto
+from app.domains.submissions.dto.create_detection_run_dto import CreateDetectionRunDto
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
 from app.domains.submissions.dto.create_submission_response_dto import CreateSubmissionResponseDto
+from app.domains.submissions.dto.detection_config_dto import DetectionConfigDto
+from app.domains.submissions.dto.detection_run_response_dto import (
+    DetectionRunResponseDto,
+    DetectionRunSummaryDto,
+    SimilarityClustersDto,
+    SimilarityMatrixDto,
+)
+from app.domains.submissions.dto.detection_run_verification_dto import (
+    DetectionRunVerificationDto,
+    VerifyDetectionRunDto,
+)
+from app.domains.submissions.dto.external_comparison_dto import (
+    EvidenceResponseDto,
+    ExternalComparisonDto,
+    ExternalComparisonResponseDto,
+)
+from app.domains.submissions.dto.file_filter_config_dto import FileFilterConfigDto
+from app.domains.submissions.dto.grading_callback_dto import (
+    GradingCallbackDeliveryDto,
+    GradingCallbackDto,
+    GradingCallbackResponseDto,
+    SavedGradingCallbackResponseDto,
+)
+from app.domains.submissions.dto.header_config_dto import HeaderConfigDto
+from app.domains.submissions.dto.ignore_rules_dto import EffectiveIgnoreRulesDto, IgnoreRulesConfigDto
+from app.domains.submissions.dto.language_statistics_dto import LanguageStatisticsDto
 from app.domains.submissions.dto.similarity_response_dto import (
     DetailedComparisonDto,
     SimilarityAlertsResponseDto,
     SimilarityListResponseDto,
     SimilarityStatisticsDto,
 )
+from app.domains.submissions.dto.originality_feedback_dto import OriginalityFeedbackDto
+from app.domains.submissions.dto.patch_submission_dto import PatchSubmissionDto
+from app.domains.submissions.dto.processing_log_dto import ProcessingLogPageDto
+from app.domains.submissions.dto.rare_token_evidence_dto import RareTokenEvidenceDto
+from app.domains.submissions.dto.report_job_response_dto import ReportJobResponseDto
+from app.domains.submissions.dto.retention_policy_dto import (
+    RetentionPolicyDto,
+    RetentionPolicyResponseDto,
+    RetentionPurgeDto,
+    RetentionPurgeReportDto,
+)
+from app.domains.submissions.dto.submission_audit_dto import SubmissionAuditEntryDto
+from app.domains.submissions.dto.submission_page_dto import SortOrder, SubmissionSortField
 from app.domains.submissions.dto.submission_response_dto import SubmissionResponseDto
+from app.domains.submissions.dto.submission_status_dto import SubmissionStatusDto
 from app.domains.submissions.dto.submission_update_dto import SubmissionUpdateDto
+from app.domains.submissions.dto.submission_version_dto import SubmissionVersionDiffDto, SubmissionVersionDto
+from app.domains.submissions.dto.step_schedule_dto import StepScheduleDto, StepScheduleResponseDto
+from app.domains.submissions.dto.token_cache_dto import TokenCachePurgeResponseDto
+from app.domains.submissions.dto.token_diff_dto import TokenDiffDto, TokenDiffRequestDto
+from app.domains.submissions.dto.upload_limits_dto import EffectiveUploadLimitsDto, UploadLimitsDto
+from app.domains.submissions.dto.upload_session_dto import CreateUploadSessionDto, UploadSessionResponseDto
+from app.domains.submissions.dto.upload_submission_dto import SubmissionFileResponseDto, UploadSubmissionResponseDto
+from app.domains.submissions.dto.webhook_dto import (
+    CreateWebhookDto,
+    CreateWebhookResponseDto,
+    WebhookDeliveryResponseDto,
+    WebhookResponseDto,
+)
+from app.domains.submissions.idempotency import Idempotency
+from app.domains.submissions.run_summary import DEFAULT_CENTRAL_SUBMISSIONS, DEFAULT_SUMMARY_BUCKETS
+from app.domains.submissions.similarity_clusterer import DEFAULT_MERGE_THRESHOLD
+from app.domains.submissions.similarity_matrix import PairSortField
+from app.domains.submissions.submissions_models import ProcessingStage, SubmissionStatus, WebhookDeliveryStatus
 from app.domains.submissions.submissions_service import SubmissionService
-from app.domains.submissions.submissions_similarity_repository import SubmissionSimilarityRepository
 from app.shared.database import get_session
-from app.shared.exceptions import DatabaseException, NotFoundException, ValidationException
+from app.shared.exceptions import (
+    BadRequestException,
+    ConflictException,
+    DatabaseException,
+    NotFoundException,
+    ValidationException,
+)
+from app.shared.authorization import Principal
+from app.shared.permissions import get_principal, require_admin, verify_admin_key
+
+# Size of the chunks the uploaded files are read by
+UPLOAD_CHUNK_BYTES = 1024 * 1024
+
+IDEMPOTENCY_KEY_DESCRIPTION = "Key of the request, its retries with the same key getting the response of the first one"
 
 router = APIRouter(prefix="/submissions", tags=["submissions"])
 
 
-def get_submission_service(session: Session = Depends(get_session)) -> SubmissionService:
-    """Dependency to get submission service"""
-    return SubmissionService(session)
+def get_submission_service(
+    session: Session = Depends(get_session), principal: Optional[Principal] = Depends(get_principal)
+) -> SubmissionService:
+    """Dependency to get submission service, scoped to the caller of the request"""
+    return SubmissionService(session, principal)
 
 
 def get_client_info(request: Request) -> tuple[Optional[str], Optional[str]]:
@@ -40,11 +142,98 @@ def get_client_info(request: Request) -> tuple[Optional[str], Optional[str]]:
     return ip_address, user_agent
 
 
+async def read_upload(file: UploadFile, max_bytes: int) -> bytes:
+    """
+    Read an uploaded file chunk by chunk, giving up as soon as it exceeds the size limit rather than once it is
+    read whole, the spooled file being released
+    """
+    chunks, size = [], 0
+    while chunk := await file.read(UPLOAD_CHUNK_BYTES):
+        size += len(chunk)
+        if size > max_bytes:
+            await file.close()
+            error = ArchiveLimitExceeded(UPLOAD_TOO_LARGE, f"The uploaded file exceeds {max_bytes} bytes", max_bytes)
+            raise ValidationException(str(error), details=error.to_dict())
+        chunks.append(chunk)
+    return b"".join(chunks)
+
+
+async def read_chunk(request: Request, max_bytes: int) -> bytes:
+    """Read the raw body of a request holding a chunk of a resumable upload, giving up once it exceeds the chunk size"""
+    chunks, size = [], 0
+    async for chunk in request.stream():
+        size += len(chunk)
+        if size > max_bytes:
+            message = f"The chunk exceeds {max_bytes} bytes"
+            raise ValidationException(message, details={"error_type": "invalid_chunk_size", "message": message})
+        chunks.append(chunk)
+    return b"".join(chunks)
+
+
+def get_client_scope(request: Request) -> str:
+    """Scope of the idempotency keys of the client of a request, after its credential"""
+    return Idempotency.client_scope(request.headers.get("Authorization") or request.headers.get("X-API-Key"))
+
+
+def run_idempotently(
+    service: SubmissionService,
+    request: Request,
+    idempotency_key: Optional[str],
+    endpoint: str,
+    payload: Any,
+    status_code: int,
+    operation: Callable[[], Any],
+) -> Any:
+    """
+    Execute a creation request once per idempotency key: a retry of a completed request gets its stored response,
+    flagged by the Idempotent-Replayed header. The key of a failed request is released, so that it can be retried.
+
+    Raises:
+        ConflictException: If the key was sent with another payload, or its request is still being executed
+    """
+    if not idempotency_key:
+        return operation()
+
+    record = service.begin_idempotent_request(get_client_scope(request), idempotency_key, endpoint, payload)
+    if record.status_code is not None:
+        return JSONResponse(
+            record.response_body, status_code=record.status_code, headers={"Idempotent-Replayed": "true"}
+        )
+    try:
+        result = operation()
+    except Exception:
+        service.release_idempotency_key(record.id)
+        raise
+    service.complete_idempotent_request(record.id, status_code, jsonable_encoder(result))
+    return result
+
+
+def analysis_queue_full(e: AnalysisQueueFull) -> HTTPException:
+    """
+    Retriable 503 of a request refused because the analysis queue is full, or draining before a shutdown, with when
+    to retry
+    """
+    error_type = "analysis_draining" if isinstance(e, AnalysisPoolDraining) else "analysis_queue_full"
+    return HTTPException(
+        status_code=503,
+        detail={"error_type": error_type, "message": str(e), "retry_after_seconds": e.retry_after_seconds},
+        headers={"Retry-After": str(e.retry_after_seconds)},
+    )
+
+
+def _pdf_response(content: bytes, filename: str) -> Response:
+    """PDF report, as a downloaded file"""
+    return Response(
+        content, media_type="application/pdf", headers={"Content-Disposition": f"attachment; filename={filename}"}
+    )
+
+
 @router.post("", response_model=CreateSubmissionResponseDto, status_code=201)
 async def create_submission(
     submission_data: CreateSubmissionDto,
     request: Request,
     allow_duplicates: bool = Query(False, description="Allow duplicate submissions"),
+    idempotency_key: Optional[str] = Header(None, max_length=255, description=IDEMPOTENCY_KEY_DESCRIPTION),
     service: SubmissionService = Depends(get_submission_service),
 ):
     """
@@ -61,15 +250,30 @@ async def create_submission(
     - **file_count**: Number of files in the submission
     - **rules**: List of validation rules to execute (optional)
     - **force_rules**: If True, submission is created even if validation rules fail (optional, defaults to False)
+
+    With an Idempotency-Key header, a retry with the same key and payload gets the response of the first request
+    instead of creating another submission, and the same key with another payload is a conflict (409).
+
+    When the analysis queue stays full, the submission is refused with a retriable 503 (analysis_queue_full) and a
+    Retry-After header, nothing being created, as it is while the analysis drains before a shutdown
+    (analysis_draining).
     """
     try:
         ip_address, user_agent = get_client_info(request)
 
-        return service.create_submission(
-            submission_data=submission_data,
-            ip_address=ip_address,
-            user_agent=user_agent,
-            allow_duplicates=allow_duplicates,
+        return run_idempotently(
+            service,
+            request,
+            idempotency_key,
+            endpoint="create_submission",
+            payload={**jsonable_encoder(submission_data, exclude_unset=True), "allow_duplicates": allow_duplicates},
+            status_code=201,
+            operation=lambda: service.create_submission(
+                submission_data=submission_data,
+                ip_address=ip_address,
+                user_agent=user_agent,
+                allow_duplicates=allow_duplicates,
+            ),
         )
     except ValidationException as e:
         # Return structured error details if available
@@ -77,143 +281,951 @@ async def create_submission(
             raise HTTPException(status_code=422, detail=e.detail)
         else:
             raise HTTPException(status_code=422, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
     except Exception as e:
         raise HTTPException(status_code=500, detail=f"Internal server error: {str(e)}")
 
 
-@router.get("/{submission_id}", response_model=CreateSubmissionResponseDto)
-async def get_submission(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
-    """Get a submission by ID"""
+@router.post("/upload", response_model=UploadSubmissionResponseDto, status_code=201)
+async def upload_submission(
+    request: Request,
+    file: UploadFile = File(..., description="ZIP, tar or tar.gz archive of the submission, or a single file"),
+    project_uuid: UUID = Form(...),
+    group_uuid: UUID = Form(...),
+    project_step_uuid: UUID = Form(...),
+    description: Optional[str] = Form(None),
+    submitted_by_uuid: Optional[UUID] = Form(None),
+    allow_duplicates: bool = Query(False, description="Allow duplicate submissions"),
+    idempotency_key: Optional[str] = Header(None, max_length=255, description=IDEMPOTENCY_KEY_DESCRIPTION),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Create a submission from an uploaded file (multipart form)
+
+    A ZIP, tar or gzipped tar archive, recognized by its signature, is extracted into a multi-file submission: the
+    relative paths are preserved, the directories and the metadata of the operating systems (__MACOSX, .DS_Store,
+    Thumbs.db) are skipped, and the archives it contains are extracted one level deep. The links, device nodes and
+    absolute paths are skipped too, with a warning in the processing log of the submission. The skipped entries are
+    reported with the extracted files, and the original file is kept for download.
+
+    The upload is rejected as soon as it exceeds a limit of its project step, with the error_type of the limit:
+    upload_too_large, extracted_size_exceeded, too_many_files or file_too_large, and the offending entry.
+
+    With an Idempotency-Key header, a retry with the same key, fields and file gets the response of the first
+    request, and the same key with another payload is a conflict (409).
+    """
     try:
-        return service.get_submission(submission_id)
-    except NotFoundException as e:
-        raise HTTPException(status_code=404, detail=str(e))
+        ip_address, user_agent = get_client_info(request)
+        limits = service.get_upload_limits(project_uuid, project_step_uuid)
+        content = await read_upload(file, limits.max_upload_bytes)
+        submission_data = {
+            "project_uuid": project_uuid,
+            "group_uuid": group_uuid,
+            "project_step_uuid": project_step_uuid,
+            "description": description,
+            "submitted_by_uuid": submitted_by_uuid,
+        }
+
+        return run_idempotently(
+            service,
+            request,
+            idempotency_key,
+            endpoint="upload_submission",
+            payload={
+                **jsonable_encoder(submission_data),
+                "filename": file.filename,
+                "content_sha256": hashlib.sha256(content).hexdigest(),
+                "allow_duplicates": allow_duplicates,
+            },
+            status_code=201,
+            operation=lambda: service.upload_submission(
+                content=content,
+                filename=file.filename,
+                submission_data=submission_data,
+                ip_address=ip_address,
+                user_agent=user_agent,
+                allow_duplicates=allow_duplicates,
+            ),
+        )
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Internal server error: {str(e)}")
 
 
-@router.get(
-    "/project/{project_uuid}/group/{group_uuid}/step/{project_step_uuid}", response_model=CreateSubmissionResponseDto
-)
-async def get_submission_by_project_group_step(
-    project_uuid: UUID,
-    group_uuid: UUID,
-    project_step_uuid: UUID,
+@router.post("/bulk-upload", response_model=BulkUploadJobResponseDto, status_code=202)
+async def bulk_upload_submissions(
+    request: Request,
+    file: UploadFile = File(..., description="ZIP, tar or tar.gz archive of one top-level directory per student"),
+    project_uuid: UUID = Form(...),
+    project_step_uuid: UUID = Form(...),
+    directory_pattern: str = Form(
+        DEFAULT_DIRECTORY_PATTERN, description="Naming pattern of the directories, e.g. {lastname}_{firstname}_{id}"
+    ),
+    allow_duplicates: bool = Query(False, description="Allow duplicate submissions"),
+    idempotency_key: Optional[str] = Header(None, max_length=255, description=IDEMPOTENCY_KEY_DESCRIPTION),
     service: SubmissionService = Depends(get_submission_service),
 ):
-    """Get a submission by project, group and step"""
+    """
+    Create the submissions of a whole class from one archive (multipart form), in the background
+
+    Each top-level directory of the archive, as exported by a learning management system, holds the submission of
+    a student and is named after the pattern: its `{id}` placeholder is the student identifier, the group and the
+    submitter of the submission (a UUID, or derived from the identifier within the project), the other placeholders
+    being free. A single directory wrapping all the others is looked into. Each directory is processed like an
+    uploaded archive.
+
+    The returned job is polled at its `status_url` for the result of each directory: the ID of the created
+    submission, or the error of a directory not matching the pattern, empty or rejected, the other directories
+    being processed anyway.
+
+    With an Idempotency-Key header, a retry with the same key, fields and archive gets the job of the first request
+    rather than starting another one, and the same key with another payload is a conflict (409).
+    """
     try:
-        return service.get_submission_by_project_group_step(project_uuid, group_uuid, project_step_uuid)
+        ip_address, user_agent = get_client_info(request)
+        content = await read_upload(file, get_settings().bulk_upload_max_bytes)
+
+        return run_idempotently(
+            service,
+            request,
+            idempotency_key,
+            endpoint="bulk_upload_submissions",
+            payload={
+                "project_uuid": str(project_uuid),
+                "project_step_uuid": str(project_step_uuid),
+                "directory_pattern": directory_pattern,
+                "filename": file.filename,
+                "content_sha256": hashlib.sha256(content).hexdigest(),
+                "allow_duplicates": allow_duplicates,
+            },
+            status_code=202,
+            operation=lambda: service.bulk_upload_submissions(
+                content=content,
+                filename=file.filename,
+                project_uuid=project_uuid,
+                project_step_uuid=project_step_uuid,
+                directory_pattern=directory_pattern,
+                ip_address=ip_address,
+                user_agent=user_agent,
+                allow_duplicates=allow_duplicates,
+            ),
+        )
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/bulk-upload-jobs/{job_id}", response_model=BulkUploadJobResponseDto)
+async def get_bulk_upload_job(job_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the progress of a bulk upload: pending, processing (with the directories processed so far), completed (with
+    the result of each directory) or failed (with the error message when the archive could not be extracted)
+    """
+    try:
+        return service.get_bulk_upload_job(job_id)
     except NotFoundException as e:
-        raise HTTPException(status_code=404, detail=str(e))
+        raise HTTPException(status_code=404, detail=str(e.detail))
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.get("", response_model=List[SubmissionResponseDto])
-async def list_submissions(
-    skip: int = Query(0, ge=0, description="Number of submissions to skip"),
-    limit: int = Query(100, ge=1, le=1000, description="Maximum number of submissions to return"),
+@router.post("/uploads", response_model=UploadSessionResponseDto, status_code=201)
+async def create_upload_session(
+    upload_data: CreateUploadSessionDto, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Initiate a resumable upload of a large archive, with the metadata of its submission, its size and the SHA-256
+    checksum of the whole file
+
+    The returned upload tells the size of the chunks and their number: each chunk is sent to its `chunks_url`, then
+    the upload is finalized at its `finalize_url`. An upload not finalized within the configured time expires, its
+    chunks being deleted. An upload over the size limit of its project step is rejected at once (upload_too_large).
+    """
+    try:
+        return service.create_upload_session(upload_data)
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/uploads/{upload_id}", response_model=UploadSessionResponseDto)
+async def get_upload_session(upload_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the progress of a resumable upload, with the chunks received and those still missing, to resume an
+    interrupted upload
+    """
+    try:
+        return service.get_upload_session(upload_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/uploads/{upload_id}/chunks/{index}", response_model=UploadSessionResponseDto)
+async def upload_chunk(
+    upload_id: UUID,
+    index: int,
+    request: Request,
+    x_chunk_checksum: Optional[str] = Header(None, description="SHA-256 checksum of the chunk, in hexadecimal"),
     service: SubmissionService = Depends(get_submission_service),
 ):
-    """List all submissions with pagination"""
+    """
+    Send a chunk of a resumable upload as the raw request body, its index counting from 0
+
+    Every chunk but the last one has the chunk size of the upload. Sending a chunk already received with the same
+    content changes nothing, so a chunk is sent again when unsure it was received; with another content it is
+    rejected (chunk_conflict). A chunk not matching its X-Chunk-Checksum header is rejected (chunk_checksum_mismatch).
+    """
     try:
-        return service.list_submissions(skip=skip, limit=limit)
+        chunk_bytes = service.get_upload_session(upload_id).chunk_bytes
+        return service.upload_chunk(upload_id, index, await read_chunk(request, chunk_bytes), x_chunk_checksum)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.get("/project/{project_uuid}/group/{group_uuid}", response_model=List[SubmissionResponseDto])
-async def get_submissions_by_project_and_group(
-    project_uuid: UUID, group_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+@router.post("/uploads/{upload_id}/finalize", response_model=UploadSubmissionResponseDto, status_code=201)
+async def finalize_upload(
+    upload_id: UUID, request: Request, service: SubmissionService = Depends(get_submission_service)
 ):
-    """Get all submissions for a specific project and group"""
+    """
+    Finalize a resumable upload into a submission, processed like an uploaded archive
+
+    When chunks are missing they are listed (missing_chunks) and the upload goes on. The whole file is checked
+    against the checksum it was initiated with: on a mismatch (checksum_mismatch), or when the submission is
+    rejected, the upload fails and is to be made again.
+    """
     try:
-        return service.get_submissions_by_project_and_group(project_uuid, group_uuid)
+        ip_address, user_agent = get_client_info(request)
+        return service.finalize_upload(upload_id, ip_address, user_agent)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Internal server error: {str(e)}")
 
 
-@router.get("/project/{project_uuid}/step/{project_step_uuid}", response_model=List[SubmissionResponseDto])
-async def get_submissions_by_project_step(
-    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+@router.post("/git", response_model=UploadSubmissionResponseDto, status_code=201)
+async def create_git_submission(
+    git_data: CreateGitSubmissionDto,
+    request: Request,
+    allow_duplicates: bool = Query(False, description="Allow duplicate submissions"),
+    idempotency_key: Optional[str] = Header(None, max_length=255, description=IDEMPOTENCY_KEY_DESCRIPTION),
+    service: SubmissionService = Depends(get_submission_service),
 ):
-    """Get all submissions for a specific project step"""
+    """
+    Create a submission from the tree of a Git repository at a branch, tag or commit
+
+    The repository is fetched shallowly, optionally limited to a subdirectory, and its tree is ingested as a
+    multi-file submission recording the commit the ref resolved to. The .git internals, the submodules and the
+    ignored paths (node_modules, vendor, target by default) are excluded. The token of a private repository is only
+    used for the fetch. Fetch failures are reported with an error_type: git_auth_failed, git_ref_not_found,
+    git_network_error or git_timeout.
+
+    With an Idempotency-Key header, a retry with the same key and payload gets the response of the first request,
+    and the same key with another payload is a conflict (409).
+    """
+    try:
+        ip_address, user_agent = get_client_info(request)
+
+        return run_idempotently(
+            service,
+            request,
+            idempotency_key,
+            endpoint="create_git_submission",
+            payload={**jsonable_encoder(git_data, exclude_unset=True), "allow_duplicates": allow_duplicates},
+            status_code=201,
+            operation=lambda: service.create_git_submission(
+                git_data=git_data,
+                ip_address=ip_address,
+                user_agent=user_agent,
+                allow_duplicates=allow_duplicates,
+            ),
+        )
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Internal server error: {str(e)}")
+
+
+@router.post("/webhooks", response_model=CreateWebhookResponseDto, status_code=201)
+async def create_webhook(webhook_data: CreateWebhookDto, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Register a webhook, the events of the analyses and detection runs being posted to it
+
+    Each delivery is a JSON POST with the `X-Webhook-Event`, `X-Webhook-Delivery` (the same for its retries),
+    `X-Webhook-Timestamp` and `X-Webhook-Signature` headers, the signature being `sha256=` followed by the
+    hexadecimal HMAC-SHA256 of `{timestamp}.{body}` with the secret of the webhook. A delivery not answered with a
+    2xx is retried with a doubling delay, up to the configured number of attempts. The secret is returned once.
+
+    - **url**: http or https URL the events are posted to, whose host resolves to public addresses only and is
+      allowed by the external source hosts (required; checked again before each delivery)
+    - **events**: `submission.analyzed`, `submission.failed` (no retry left), `submission.duplicate` (same files as
+      the submission of another group of the step, at its upload) and/or `detection_run.completed`
+    - **project_uuid**: Project of the events (optional, every project by default)
+    - **project_step_uuid**: Step of the project of the events (optional, every step by default)
+    - **secret**: Secret of the signatures, at least 16 characters (optional, generated by default)
+    """
     try:
-        return service.get_submissions_by_project_step(project_uuid, project_step_uuid)
+        return service.create_webhook(webhook_data)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.get("/project/{project_uuid}/group/{group_uuid}/statistics")
-async def get_submission_statistics(
-    project_uuid: UUID, group_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+@router.get("/webhooks", response_model=List[WebhookResponseDto])
+async def get_webhooks(
+    project_uuid: Optional[UUID] = Query(None, description="Only the webhooks scoped to this project"),
+    service: SubmissionService = Depends(get_submission_service),
 ):
-    """Get submission statistics for a project and group"""
+    """Get the registered webhooks, without their secrets"""
     try:
-        return service.get_submission_statistics(project_uuid, group_uuid)
+        return service.get_webhooks(project_uuid)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.put("/{submission_id}", response_model=CreateSubmissionResponseDto)
-async def update_submission(
-    submission_id: UUID,
-    update_data: SubmissionUpdateDto,
+@router.get("/webhooks/{webhook_id}", response_model=WebhookResponseDto)
+async def get_webhook(webhook_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get a registered webhook, without its secret"""
+    try:
+        return service.get_webhook(webhook_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/webhooks/{webhook_id}")
+async def delete_webhook(webhook_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Delete a webhook with the log of its deliveries, its pending retries being dropped"""
+    try:
+        return {"success": service.delete_webhook(webhook_id), "webhook_id": webhook_id}
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/webhooks/{webhook_id}/deliveries", response_model=List[WebhookDeliveryResponseDto])
+async def get_webhook_deliveries(
+    webhook_id: UUID,
+    status: Optional[WebhookDeliveryStatus] = Query(None, description="Only the deliveries of this status"),
+    limit: int = Query(50, ge=1, le=500, description="Number of deliveries, newest first"),
     service: SubmissionService = Depends(get_submission_service),
 ):
-    """Update a submission"""
+    """
+    Get the log of the latest deliveries of a webhook, to debug missed events: the posted payload, and the status
+    code, error, duration and response excerpt of each attempt, with the time of the next one if it is pending
+    """
     try:
-        return service.update_submission(submission_id, update_data)
+        return service.get_webhook_deliveries(webhook_id, status, limit)
     except NotFoundException as e:
-        raise HTTPException(status_code=404, detail=str(e))
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/analysis-profiles", response_model=AnalysisProfileResponseDto, status_code=201)
+async def create_analysis_profile(
+    profile_data: CreateAnalysisProfileDto, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Store an analysis profile, a named bundle of detection options the detection runs reference by name
+
+    - **name**: Name of the profile, letters, digits, dots, dashes and underscores (required, `default` being the
+      built-in profile)
+    - **description**: What the profile is for (optional)
+    - **detection_options**: Detection options (metric, k-gram and window sizes, normalizations...), those not given
+      being those of the built-in default profile
+    - **flag_threshold**: Similarity flagging a pair of its runs (optional, that of the project step)
+    - **min_token_count**: Compared tokens under which a submission is too short (optional, that of the project step)
+
+    A profile of an existing name is refused (profile_exists), as are unknown or invalid options
+    (invalid_detection_options). Administrators and services only.
+    """
+    try:
+        return service.create_analysis_profile(profile_data)
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
     except ValidationException as e:
-        raise HTTPException(status_code=422, detail=str(e))
+        raise HTTPException(status_code=422, detail=str(e.detail))
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.delete("/{submission_id}", response_model=CreateSubmissionResponseDto)
-async def delete_submission(
-    submission_id: UUID,
-    service: SubmissionService = Depends(get_submission_service),
-    session: Session = Depends(get_session),
+@router.get("/analysis-profiles", response_model=List[AnalysisProfileResponseDto])
+async def get_analysis_profiles(service: SubmissionService = Depends(get_submission_service)):
+    """Get the analysis profiles with their full detection options, the built-in default profile first"""
+    try:
+        return service.get_analysis_profiles()
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/analysis-profiles/{name}", response_model=AnalysisProfileResponseDto)
+async def get_analysis_profile(name: str, service: SubmissionService = Depends(get_submission_service)):
+    """Get an analysis profile with its full detection options, those added since it was stored included"""
+    try:
+        return service.get_analysis_profile(name)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/analysis-profiles/{name}", response_model=AnalysisProfileResponseDto)
+async def update_analysis_profile(
+    name: str, profile_data: UpdateAnalysisProfileDto, service: SubmissionService = Depends(get_submission_service)
 ):
-    """Delete a submission"""
-    # first check if a similarity exists for this submission if so, delete it first
+    """
+    Replace the options of an analysis profile
+
+    The runs already started keep the effective options they recorded, only the runs started afterwards comparing
+    with the new ones. The built-in default profile cannot be changed (built_in_profile). Administrators and
+    services only.
+    """
     try:
-        similarity_repository = SubmissionSimilarityRepository(session)
-        similarity_repository.delete_by_submission_id(submission_id)
+        return service.update_analysis_profile(name, profile_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
+
+@router.delete("/analysis-profiles/{name}")
+async def delete_analysis_profile(name: str, service: SubmissionService = Depends(get_submission_service)):
+    """Delete an analysis profile, its runs keeping the effective options they recorded (administrators only)"""
     try:
-        return service.delete_submission(submission_id)
+        return {"success": service.delete_analysis_profile(name), "name": name}
     except NotFoundException as e:
-        raise HTTPException(status_code=404, detail=str(e))
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
-@router.get("/rules/documentation")
-async def get_rules_documentation():
-    """Get detailed documentation for all available validation rules"""
+@router.get("/access-denials", response_model=List[AccessDenialDto], dependencies=[Depends(require_admin)])
+async def get_access_denials(
+    subject: Optional[str] = Query(None, description="Only the denials of this caller (subject of their token)"),
+    limit: int = Query(100, ge=1, le=1000, description="Number of denials to return"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the latest accesses denied to the callers, latest first (administrators only, with the `X-Admin-Key` header)
+
+    Each denial records the subject and role of the caller, the denied operation, the kind and ID of its target
+    and why it was denied: a student reading another student's submission or any pairwise result, a grader out of
+    their assignments, or a grader or student reaching the webhooks, the corpora or the purges.
+    """
     try:
-        documentation = {
-            "available_rules": [
-                {
-                    "name": "file_presence",
-                    "description": "Validates presence of required files and absence of forbidden files",
-                    "parameters": {
-                        "must_exist": {
-                            "type": "array of strings",
-                            "description": "List of file patterns that must be present (glob patterns supported)",
-                            "required": False,
-                            "examples": ["README*", "*.md", "package.json", "src/**/*.py"],
-                        },
-                        "forbidden": {
+        return service.get_access_denials(subject, limit)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/retention/purge", response_model=List[RetentionPurgeReportDto], dependencies=[Depends(require_admin)])
+async def purge_expired_data(
+    dry_run: bool = Query(False, description="Only report what would be purged, nothing being deleted"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Purge the expired files and results of the project steps now (administrators only, with the `X-Admin-Key`
+    header)
+
+    The purge also runs in the background at the configured interval. For each project step with a retention
+    policy, the counts of what was purged (would be, in dry run) are returned with the IDs of the expired
+    submissions, comparisons and runs, and of the submissions exempt for their open integrity case. The purges
+    deleting anything are logged, read with `/retention/purges`.
+    """
+    try:
+        return service.purge_expired_data(dry_run)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/retention/purges", response_model=List[RetentionPurgeDto], dependencies=[Depends(require_admin)])
+async def get_retention_purges(
+    project_uuid: Optional[UUID] = Query(None, description="Only the purges of this project"),
+    limit: int = Query(100, ge=1, le=1000, description="Number of purges to return"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the log of the latest purges of the expired files and results, latest first, with their counts per project
+    step (administrators only, with the `X-Admin-Key` header)
+    """
+    try:
+        return service.get_retention_purges(project_uuid, limit)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post(
+    "/reference-sets", response_model=CorpusResponseDto, status_code=201, dependencies=[Depends(require_admin)]
+)
+async def create_reference_set(
+    file: UploadFile = File(..., description="ZIP, tar or tar.gz archive of the reference source files"),
+    label: str = Form(..., min_length=1, max_length=255),
+    license_note: Optional[str] = Form(None, max_length=1000),
+    description: Optional[str] = Form(None, max_length=1000),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Import an external reference set, such as an excerpt of a public package registry (administrators only, with
+    the `X-Admin-Key` header)
+
+    Each supported file of the archive is fingerprinted once, its fingerprints being shared by the detection runs
+    of every project step including the references (`include_references`), which report their matches with the
+    label of the set and the path of the file, apart from the pairs.
+
+    - **file**: Archive of the reference source files (required)
+    - **label**: Label of the set reported with its matches, e.g. left-pad 1.3.0 (required)
+    - **license_note**: License of the files, reported with their matches
+    - **description**: Optional description of the set
+    """
+    try:
+        content = await read_upload(file, get_settings().submission_upload_max_bytes)
+        return service.create_reference_set(content, file.filename, label, license_note, description)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/reference-sets", response_model=List[CorpusResponseDto], dependencies=[Depends(require_admin)])
+async def get_reference_sets(service: SubmissionService = Depends(get_submission_service)):
+    """Get the external reference sets (administrators only, with the `X-Admin-Key` header)"""
+    try:
+        return service.get_reference_sets()
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put(
+    "/reference-sets/{reference_set_id}/archive",
+    response_model=CorpusResponseDto,
+    dependencies=[Depends(require_admin)],
+)
+async def refresh_reference_set(
+    reference_set_id: UUID,
+    file: UploadFile = File(..., description="ZIP, tar or tar.gz archive of the reference source files"),
+    license_note: Optional[str] = Form(None, max_length=1000),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Refresh a reference set by uploading its archive again (administrators only, with the `X-Admin-Key` header)
+
+    Its files are replaced by those of the new archive, fingerprinted again, and the matches with its former files
+    deleted: the next detection runs including the references match the submissions with the new files.
+
+    - **file**: New archive of the reference source files (required)
+    - **license_note**: New license of the files, the former one being kept if omitted
+    """
+    try:
+        content = await read_upload(file, get_settings().submission_upload_max_bytes)
+        return service.refresh_reference_set(reference_set_id, content, file.filename, license_note)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}", response_model=CreateSubmissionResponseDto)
+async def get_submission(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get a submission by ID"""
+    try:
+        return service.get_submission(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get(
+    "/project/{project_uuid}/group/{group_uuid}/step/{project_step_uuid}", response_model=CreateSubmissionResponseDto
+)
+async def get_submission_by_project_group_step(
+    project_uuid: UUID,
+    group_uuid: UUID,
+    project_step_uuid: UUID,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """Get a submission by project, group and step"""
+    try:
+        return service.get_submission_by_project_group_step(project_uuid, group_uuid, project_step_uuid)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("", response_model=List[SubmissionResponseDto])
+async def list_submissions(
+    response: Response,
+    skip: int = Query(0, ge=0, description="Number of submissions to skip"),
+    limit: int = Query(100, ge=1, le=1000, description="Maximum number of submissions to return"),
+    cursor: Optional[str] = Query(None, description="Cursor of the page, the X-Next-Cursor of the previous page"),
+    sort_by: SubmissionSortField = Query(SubmissionSortField.CREATED_AT, description="Field sorting the submissions"),
+    order: SortOrder = Query(SortOrder.DESC, description="Order of the sort"),
+    project_uuid: Optional[UUID] = Query(None, description="Only the submissions of a project"),
+    project_step_uuid: Optional[UUID] = Query(None, description="Only the submissions of an assignment"),
+    group_uuid: Optional[UUID] = Query(None, description="Only the submissions of a student or group"),
+    status: Optional[SubmissionStatus] = Query(None, description="Only the submissions with a status"),
+    language: Optional[str] = Query(None, description="Only the submissions of a detected language"),
+    created_after: Optional[datetime] = Query(None, description="Only the submissions created at or after"),
+    created_before: Optional[datetime] = Query(None, description="Only the submissions created before"),
+    deleted: bool = Query(False, description="Only the deleted submissions (administrators only)"),
+    x_admin_key: Optional[str] = Header(None, description="Key of an administrator, to list the deleted submissions"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    List the submissions a page at a time, sorted and filtered
+
+    The submissions are sorted by the sort field then by ID, so that the order is total. A page is either read by
+    offset (`skip`), or after the `X-Next-Cursor` of the previous page, which keeps the pages stable when
+    submissions are inserted meanwhile. Without parameters, the first 100 submissions are listed, the latest first.
+    The body is the array of the submissions of the page, the rest of the page being in the response headers.
+
+    - **sort_by**: `created_at` (default), `group` (student or group), `status` or `language`
+    - **order**: `desc` (default) or `asc`
+    - **X-Total-Count**: Number of submissions matching the filters, **X-Has-More** whether another page follows
+    - **deleted**: List the soft deleted submissions instead, given the `X-Admin-Key` header of an administrator
+
+    A malformed cursor, one from another sort or order, or inverted creation dates are a 400.
+    """
+    if deleted:
+        verify_admin_key(x_admin_key)
+    try:
+        page = service.list_submissions(
+            skip=skip,
+            limit=limit,
+            cursor=cursor,
+            sort_by=sort_by,
+            order=order,
+            filters={
+                "project_uuid": project_uuid,
+                "project_step_uuid": project_step_uuid,
+                "group_uuid": group_uuid,
+                "status": status,
+                "language": language,
+                "created_after": created_after,
+                "created_before": created_before,
+                "deleted": deleted,
+            },
+        )
+    except BadRequestException as e:
+        raise HTTPException(status_code=400, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    response.headers.update(page.headers())
+    return page.items
+
+
+@router.get("/project/{project_uuid}/group/{group_uuid}", response_model=List[SubmissionResponseDto])
+async def get_submissions_by_project_and_group(
+    project_uuid: UUID, group_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get all submissions for a specific project and group"""
+    try:
+        return service.get_submissions_by_project_and_group(project_uuid, group_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}", response_model=List[SubmissionResponseDto])
+async def get_submissions_by_project_step(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    max_language_confidence: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Only return submissions whose language confidence is under this value"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """Get all submissions for a specific project step, optionally only the low language confidence ones"""
+    try:
+        return service.get_submissions_by_project_step(project_uuid, project_step_uuid, max_language_confidence)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/group/{group_uuid}/statistics")
+async def get_submission_statistics(
+    project_uuid: UUID, group_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get submission statistics for a project and group"""
+    try:
+        return service.get_submission_statistics(project_uuid, group_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/{submission_id}", response_model=CreateSubmissionResponseDto)
+async def update_submission(
+    submission_id: UUID,
+    update_data: SubmissionUpdateDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """Update a submission"""
+    try:
+        return service.update_submission(submission_id, update_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.patch("/{submission_id}", response_model=CreateSubmissionResponseDto)
+async def patch_submission(
+    submission_id: UUID,
+    patch_data: PatchSubmissionDto,
+    request: Request,
+    x_actor: Optional[str] = Header(None, description="Who makes the change, recorded in the audit trail"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Change the metadata of a submission
+
+    Only the given fields are changed, among the mutable ones:
+
+    - **submitted_by_uuid**: Student who made the submission
+    - **group_uuid**: Team the submission belongs to
+    - **display_name**: Name the submission is shown with (at most 200 characters)
+    - **description**: Description of the submission
+    - **project_uuid** / **project_step_uuid**: Assignment the submission belongs to
+    - **tags**: Free-form tags (at most 20, of at most 50 characters, duplicates dropped)
+    - **integrity_case_open**: Whether an integrity case is open on the submission, exempting it from the
+      retention purges
+
+    The other fields (files, content, upload time, processing results...) are rejected with a 400 listing the
+    `immutable_fields` and `unknown_fields`. The changes are recorded in the audit trail of the submission, read
+    with `/{submission_id}/audit`, in the same transaction. A submission moved to another team or assignment
+    becomes its latest version there and loses its detection results, compared again where it was moved.
+    """
+    try:
+        ip_address, _ = get_client_info(request)
+        return service.patch_submission(submission_id, patch_data, x_actor, ip_address)
+    except BadRequestException as e:
+        raise HTTPException(status_code=400, detail=e.detail)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e.detail))
+
+
+@router.get("/{submission_id}/audit", response_model=List[SubmissionAuditEntryDto])
+async def get_submission_audit_trail(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get the changes of the metadata of a submission, oldest first, with who made them and from where"""
+    try:
+        return service.get_submission_audit_trail(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e.detail))
+
+
+@router.get("/{submission_id}/log", response_model=ProcessingLogPageDto)
+async def get_submission_processing_log(
+    submission_id: UUID,
+    skip: int = Query(0, ge=0, description="Number of events to skip"),
+    limit: int = Query(100, ge=1, le=1000, description="Maximum number of events to return"),
+    stage: Optional[ProcessingStage] = Query(None, description="Stage of the listed events, all if unset"),
+    warnings_only: bool = Query(False, description="Whether only the events with warnings or failed are listed"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the processing log of a submission: the timeline of the stages it was processed through, in the order they
+    started, each with its start, end, duration and outcome (`ok`, `warning`, `skipped` or `failed`)
+
+    The upload, extraction and fingerprinting stages cover the whole submission, the language detection and
+    tokenization stages each of its files, and the files left out at the upload or extraction are recorded with
+    their path. A submission processed again (a retry, or a re-analysis) has the stages of each processing. The
+    warnings have stable codes the frontend renders, e.g. `binary_file_skipped`, `language_fallback` or
+    `tokenization_timeout`. The events older than the retention period of the configuration are pruned.
+    """
+    try:
+        return service.get_submission_processing_log(submission_id, skip, limit, stage, warnings_only)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e.detail))
+
+
+@router.delete("/token-cache", response_model=TokenCachePurgeResponseDto, dependencies=[Depends(require_admin)])
+async def purge_token_cache(
+    language: str = Query(..., min_length=1, description="Language whose cached token streams are deleted"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Delete the cached token streams of a language (administrators only, with the `X-Admin-Key` header)
+
+    The comparisons reuse the tokens of the file contents they already tokenized with the same tokenizer version
+    and options. After a fix of the tokenizer of a language, its cached token streams are purged so that its files
+    are tokenized again by the next detection runs.
+    """
+    try:
+        return service.purge_token_cache(language)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/{submission_id}", response_model=CreateSubmissionResponseDto)
+async def delete_submission(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Soft delete a submission
+
+    The submission is no longer listed nor compared by the detection runs, its files and its comparisons being
+    kept: the runs that compared it keep their results, their pairs marked `deleted_submission`. It can be
+    restored with `/{submission_id}/restore`, or purged for good by an administrator.
+    """
+    try:
+        return service.delete_submission(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/{submission_id}/restore", response_model=CreateSubmissionResponseDto)
+async def restore_submission(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Restore a soft deleted submission, listed and compared again"""
+    try:
+        return service.restore_submission(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete(
+    "/{submission_id}/purge", response_model=CreateSubmissionResponseDto, dependencies=[Depends(require_admin)]
+)
+async def purge_submission(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Delete a submission for good (administrators only, with the `X-Admin-Key` header)
+
+    The original file of an uploaded submission is deleted from the upload bucket, along with the comparisons,
+    corpus matches, evidence and file records of the submission. Deleted or not, the submission cannot be restored.
+    """
+    try:
+        return service.purge_submission(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post(
+    "/{submission_id}/quarantine/release",
+    response_model=CreateSubmissionResponseDto,
+    dependencies=[Depends(require_admin)],
+)
+async def release_quarantined_submission(
+    submission_id: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Release a submission quarantined for malware (administrators only, with the `X-Admin-Key` header)
+
+    An upload found infected by the malware scan (or left unscanned when the scan fails closed) is kept
+    quarantined, its files being neither downloadable nor compared (409 submission_quarantined). Once released, it
+    is compared with the other submissions of its project step; only a quarantined submission is released (409
+    submission_not_quarantined otherwise). The quarantined submissions are listed with `status=quarantined`, and
+    deleted for good with their purge.
+    """
+    try:
+        return service.release_quarantined_submission(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/rules/documentation")
+async def get_rules_documentation():
+    """Get detailed documentation for all available validation rules"""
+    try:
+        documentation = {
+            "available_rules": [
+                {
+                    "name": "file_presence",
+                    "description": "Validates presence of required files and absence of forbidden files",
+                    "parameters": {
+                        "must_exist": {
+                            "type": "array of strings",
+                            "description": "List of file patterns that must be present (glob patterns supported)",
+                            "required": False,
+                            "examples": ["README*", "*.md", "package.json", "src/**/*.py"],
+                        },
+                        "forbidden": {
                             "type": "array of strings",
                             "description": "List of file patterns that must NOT be present (glob patterns supported)",
                             "required": False,
@@ -381,11 +1393,24 @@ async def get_available_rules(service: SubmissionService = Depends(get_submissio
 
 @router.get("/{submission_id}/similarities", response_model=SimilarityListResponseDto)
 async def get_submission_similarities(
-    submission_id: UUID, service: SubmissionService = Depends(get_submission_service)
+    submission_id: UUID,
+    flag_threshold: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Similarity flagging a pair as suspicious (project step configuration)"
+    ),
+    min_token_count: Optional[int] = Query(
+        None, ge=0, description="Compared tokens under which a submission is too short (project step configuration)"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
 ):
-    """Get all similarity results for a specific submission"""
-    try:
-        similarities = service.get_submission_similarities(submission_id)
+    """
+    Get all similarity results for a specific submission
+
+    The pairs at or above the flag threshold are suspicious, except those of a submission with fewer compared
+    tokens than the minimum, listed as too short instead. Both values default to the configuration of the step.
+    """
+    try:
+        result = service.get_submission_similarities(submission_id, flag_threshold, min_token_count)
+        similarities = result["similarities"]
 
         # Count high similarity results (>= 0.7)
         high_similarity_count = len([s for s in similarities if s.get("overall_similarity", 0) >= 0.7])
@@ -395,6 +1420,10 @@ async def get_submission_similarities(
             total_comparisons=len(similarities),
             high_similarity_count=high_similarity_count,
             similarities=similarities,
+            flag_threshold=result["flag_threshold"],
+            min_token_count=result["min_token_count"],
+            flagged_count=result["flagged_count"],
+            too_short_submissions=result["too_short_submissions"],
         )
     except NotFoundException as e:
         raise HTTPException(status_code=404, detail=str(e))
@@ -402,6 +1431,340 @@ async def get_submission_similarities(
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@router.get("/{submission_id}/originality", response_model=OriginalityFeedbackDto)
+async def get_originality_feedback(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the anonymized originality feedback of a submission, for its student before the deadline
+
+    The feedback holds the highest similarity of the submission, its percentile among the highest similarities of
+    the submissions of the project step and the regions of its own files matching another submission. The identity,
+    files, positions and code of the matched submissions are withheld by the service, so that students (who never
+    read the pairwise results) may read the feedback of their own submissions. The feedback is available only if
+    `originality_feedback_enabled` is set in the detection configuration of the project step, a 409 otherwise.
+    """
+    try:
+        return service.get_originality_feedback(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/search", response_model=CodeSearchResponseDto)
+async def search_code(search_data: CodeSearchDto, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Search the submissions of a project step for a code snippet, e.g. a suspicious helper function
+
+    In fingerprint mode, the snippet is tokenized and fingerprinted like the compared files, and looked up in the
+    index of the fingerprints of the submissions built when they are analyzed: the files holding fragments of at
+    least min_tokens tokens are reported with their lines. The submissions analyzed before the index existed are
+    counted in unindexed_submission_count. In substring mode, the default for a language without tokenizer, the
+    snippet is searched as is in the content of the files.
+
+    - **project_uuid**, **project_step_uuid**: Step of the searched submissions
+    - **code**: Snippet to search for
+    - **language**: Language of the snippet (required in fingerprint mode)
+    - **mode**: fingerprint or substring (optional)
+    - **min_tokens**: Minimum number of tokens of a fragment (optional)
+    """
+    try:
+        return service.search_code(search_data)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/{submission_id}/external-comparison", response_model=ExternalComparisonResponseDto)
+async def compare_external_source(
+    submission_id: UUID,
+    comparison_data: ExternalComparisonDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Compare a submission with an external source, e.g. a gist or a forum post it may have been copied from
+
+    The source is tokenized and compared with the submission like another submission, without being stored as
+    one. URLs are fetched within the configured size and time limits, from the allowed public hosts only.
+
+    - **code**: Pasted source (either code or url is required)
+    - **language**: Language of the source (required with code, detected from the URL otherwise)
+    - **url**: HTTP(S) URL of a single source file
+    - **label**: Label of the source reported with the evidence (optional)
+    - **store_as_evidence**: Whether the result is attached to the submission (default false)
+    """
+    try:
+        return service.compare_external_source(submission_id, comparison_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Failed to compare external source: {str(e)}")
+
+
+@router.get("/{submission_id}/evidence", response_model=List[EvidenceResponseDto])
+async def get_submission_evidence(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get the external source comparisons attached to a submission as evidence"""
+    try:
+        return service.get_submission_evidence(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/files", response_model=List[SubmissionFileResponseDto])
+async def get_submission_files(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get the files extracted from the uploaded archive of a submission, with their detected language"""
+    try:
+        return service.get_submission_files(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/archive", response_class=StreamingResponse)
+async def get_submission_archive(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Download the original file (archive) of an uploaded submission, streamed from its storage"""
+    try:
+        filename, content = service.get_submission_archive(submission_id)
+        return StreamingResponse(
+            content,
+            media_type="application/zip" if filename.lower().endswith(".zip") else "application/octet-stream",
+            headers={"Content-Disposition": f"attachment; filename={filename}"},
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/status", response_model=SubmissionStatusDto)
+async def get_submission_status(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the processing status of a submission, polled after its creation until it is analyzed
+
+    A submission is received, its files are extracted then tokenized, after which it is analyzed and can be
+    compared by a detection run; a failure tells its reason and the file it happened on. A processing interrupted by
+    a shutdown is pending retry until processed again at the next start. The time of each transition is listed, with
+    the number of files processed out of the total.
+
+    A processing failing for a transient cause (storage or database unavailable, network timeout) is retried with
+    an exponential backoff up to the configured number of attempts, each failed attempt being listed with its time
+    and error.
+    """
+    try:
+        return service.get_submission_status(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/{submission_id}/retry", response_model=SubmissionStatusDto, status_code=202)
+async def retry_submission_processing(
+    submission_id: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Retry by hand the processing of a failed submission, once the cause of its failure is fixed
+
+    The submission is pending retry until processed again, with all its attempts. Only a failed submission is
+    retried (409 submission_not_failed otherwise); the retry is refused with a retriable 503 (analysis_queue_full)
+    when the analysis queue stays full.
+    """
+    try:
+        return service.retry_submission_processing(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/download", response_class=StreamingResponse)
+async def download_submission(
+    submission_id: UUID,
+    version: Optional[int] = Query(None, ge=1, description="Version to download, the given submission if unset"),
+    original: bool = Query(False, description="Return the original file of an uploaded submission as is"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Download the files of a submission, or of another of its versions, as a ZIP archive streamed as it is built
+
+    The files keep their relative paths, and are dated when the submission was made. With `original`, the file an
+    uploaded submission was made from is returned byte for byte instead (404 for a linked submission).
+    """
+    try:
+        filename, content = service.download_submission(submission_id, version, original)
+        return StreamingResponse(
+            content,
+            media_type="application/zip" if filename.lower().endswith(".zip") else "application/octet-stream",
+            headers={"Content-Disposition": f"attachment; filename={filename}"},
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/tokens")
+async def export_submission_tokens(
+    submission_id: UUID,
+    request: Request,
+    export_format: Optional[str] = Query(
+        None, alias="format", pattern="^(json|ndjson)$", description="Format of the export (from the Accept header)"
+    ),
+    ignore_struct_tags: bool = Query(False, description="Remove the Go struct tags"),
+    ignore_comments: bool = Query(False, description="Remove the comments"),
+    ignore_imports: bool = Query(False, description="Remove the import and include statements"),
+    docstrings_as_comments: bool = Query(False, description="Remove the Python docstrings too (with ignore_comments)"),
+    normalize_identifiers: bool = Query(False, description="Replace identifiers with positional placeholders"),
+    normalize_literals: bool = Query(False, description="Replace string literals with STR and numbers with NUM"),
+    literal_length_buckets: bool = Query(False, description="Keep the length bucket of the normalized literals"),
+    preserve_format_verbs: bool = Query(False, description="Keep the verbs of the normalized format strings"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Download the token streams of the analyzed files of a submission, streamed, for external tooling
+
+    Each token is exported with its file, its kind, its value and its line and column (0-based), once the
+    normalization options are applied to the stream of its file (the value of a normalized token being its
+    placeholder). The format is the `format` query parameter, or newline-delimited JSON when the Accept header asks
+    for `application/x-ndjson` (a header line then one compact line per token) and a JSON document otherwise. The
+    tokenizer version the streams were produced with is part of the header, changing whenever a new version of the
+    tokenizer changes them. Graders of the project step and administrators only.
+    """
+    if export_format is None:
+        export_format = "ndjson" if "application/x-ndjson" in request.headers.get("accept", "") else "json"
+    detection_options = DetectionOptionsDto(
+        ignore_struct_tags=ignore_struct_tags,
+        ignore_comments=ignore_comments,
+        ignore_imports=ignore_imports,
+        docstrings_as_comments=docstrings_as_comments,
+        normalize_identifiers=normalize_identifiers,
+        normalize_literals=normalize_literals,
+        literal_length_buckets=literal_length_buckets,
+        preserve_format_verbs=preserve_format_verbs,
+    )
+    try:
+        content, filename = service.export_submission_tokens(submission_id, export_format, detection_options)
+        return StreamingResponse(
+            content,
+            media_type="application/x-ndjson" if export_format == "ndjson" else "application/json",
+            headers={"Content-Disposition": f"attachment; filename={filename}"},
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/versions", response_model=List[SubmissionVersionDto])
+async def get_submission_versions(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get all the versions of a submission, oldest first
+
+    A resubmission of a group for a project step is the next version of its submission. Only the latest version
+    is compared by the detection runs, unless requested otherwise. The versions are never renumbered, a deleted
+    version leaving a gap.
+    """
+    try:
+        return service.get_submission_versions(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/diff/{other_submission_id}", response_model=SubmissionVersionDiffDto)
+async def diff_submission_versions(
+    submission_id: UUID, other_submission_id: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Get the per-file changes between two versions of a submission, from the older version to the newer one
+
+    The files added, removed and modified are listed with their added and removed lines, and with a unified diff
+    for the text files. Both submissions must be versions of the same group for the same project step.
+    """
+    try:
+        return service.diff_submission_versions(submission_id, other_submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/token-diff", response_model=TokenDiffDto)
+async def diff_file_tokens(
+    diff_data: TokenDiffRequestDto, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Align token by token two files of submissions, e.g. to review what a pair of a detection run was scored on
+
+    Both files are tokenized and normalized as the detection does with the options of the analysis profile, across
+    languages through the abstract token categories when cross-language detection applies. The runs of matched
+    tokens, of deletions (only in file1) and of insertions (only in file2) are located in both files; the
+    operations of large files are served by pages, each with the cursor of the next one.
+
+    - **file1**, **file2**: Submission ID and path of each file
+    - **profile**: Analysis profile (optional, the default one)
+    - **overrides**: Detection options overridden (optional)
+    - **cursor**: Cursor of the next page of a previous diff (optional)
+    - **limit**: Operations of the page, at most (default 200, at most 1000)
+    """
+    try:
+        return service.diff_file_tokens(diff_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/{submission_id}/metrics", response_model=CodeMetricsDto)
+async def get_submission_metrics(submission_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the code metrics of a submission, computed when it is analyzed: lines of code, comment ratio, functions
+    and their cyclomatic complexity, per file and for the whole submission
+    """
+    try:
+        return service.get_submission_metrics(submission_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @router.get("/similarities/{similarity_id}/detailed", response_model=DetailedComparisonDto)
 async def get_detailed_comparison(similarity_id: UUID, service: SubmissionService = Depends(get_submission_service)):
     """Get detailed comparison results including visualization data"""
@@ -416,20 +1779,122 @@ async def get_detailed_comparison(similarity_id: UUID, service: SubmissionServic
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@router.get("/similarities/{similarity_id}/report", response_class=HTMLResponse)
+async def get_comparison_report(
+    similarity_id: UUID,
+    report_format: str = Query("html", alias="format", pattern="^(html|pdf)$", description="Format of the report"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Download the HTML or PDF report of the comparison of a pair of submissions, for an academic integrity case file
+
+    Both submissions are shown side by side with their matched fragments highlighted in the same color, along with
+    the scores, the configuration of the comparison and the list of the fragments. The report is rendered from the
+    stored comparison, the unmatched regions of the large files being collapsed.
+
+    The PDF report is rendered in the background: it is returned at once when it is rendered within a few seconds,
+    else a 202 response gives the report job to poll on `/submissions/report-jobs/{job_id}` until it is completed,
+    its report being then downloaded from `/submissions/report-jobs/{job_id}/download`. Without the system libraries
+    of WeasyPrint, the job is failed with its error; the HTML report is always available.
+    """
+    try:
+        if report_format == "pdf":
+            job = service.request_comparison_report_pdf(similarity_id)
+            if job.download_url is None:
+                return JSONResponse(status_code=202, content=jsonable_encoder(job))
+            return _pdf_response(service.get_report_job_content(job.id), f"comparison-{similarity_id}.pdf")
+        report = service.get_comparison_report(similarity_id)
+        return HTMLResponse(
+            report, headers={"Content-Disposition": f"attachment; filename=comparison-{similarity_id}.html"}
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/report-jobs/{job_id}", response_model=ReportJobResponseDto)
+async def get_report_job(job_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the status of the rendering of a PDF report or of an evidence package: pending, processing, completed (with
+    the URL to download it from, and the checksum of an evidence package) or failed (with the error message)
+    """
+    try:
+        return service.get_report_job(job_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/report-jobs/{job_id}/download", response_class=Response)
+async def download_report_job(job_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Download the PDF report or the evidence package (a ZIP archive) of a completed report job"""
+    try:
+        job = service.get_report_job(job_id)
+        if job.report_format == "evidence":
+            return Response(
+                service.get_report_job_content(job_id),
+                media_type="application/zip",
+                headers={"Content-Disposition": f"attachment; filename=evidence-{job.similarity_id}.zip"},
+            )
+        return _pdf_response(service.get_report_job_content(job_id), f"comparison-{job.similarity_id}.pdf")
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @router.get(
     "/project/{project_uuid}/step/{project_step_uuid}/similarity-statistics", response_model=SimilarityStatisticsDto
 )
 async def get_project_step_similarity_statistics(
-    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    flag_threshold: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Similarity flagging a pair as suspicious (project step configuration)"
+    ),
+    min_token_count: Optional[int] = Query(
+        None, ge=0, description="Compared tokens under which a submission is too short (project step configuration)"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
 ):
-    """Get similarity statistics for a project step"""
+    """
+    Get similarity statistics for a project step
+
+    The pairs of the submissions with fewer compared tokens than the minimum are not counted in the compared
+    pairs, these submissions being listed separately.
+    """
     try:
-        statistics = service.get_project_step_statistics(project_uuid, project_step_uuid)
+        statistics = service.get_project_step_statistics(
+            project_uuid, project_step_uuid, flag_threshold, min_token_count
+        )
         return SimilarityStatisticsDto(**statistics)
     except DatabaseException as e:
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/language-stats", response_model=LanguageStatisticsDto)
+async def get_language_statistics(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Get the languages the latest submissions of a project step are written in
+
+    Each language lists the submissions it is the primary language of (that of most of their lines of code, the tie
+    going to the first by name), and its files and lines of code across the submissions. The files of no known
+    language, compared as plain text, and those left out for a language detected with a low confidence are counted
+    apart. The statistics are aggregated from the stored analysis of the submissions, those not analyzed yet being
+    only counted.
+    """
+    try:
+        return service.get_language_statistics(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @router.get(
     "/project/{project_uuid}/step/{project_step_uuid}/similarity-alerts", response_model=SimilarityAlertsResponseDto
 )
@@ -482,17 +1947,1050 @@ async def get_project_step_similarity_overview(
         raise HTTPException(status_code=500, detail=str(e))
 
 
+@router.post(
+    "/project/{project_uuid}/step/{project_step_uuid}/baselines", response_model=BaselineResponseDto, status_code=201
+)
+async def create_baseline(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    baseline_data: CreateBaselineDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Attach starter code to a project step
+
+    The starter code (an S3 archive, a GitHub or GitLab repository) is fingerprinted once and stored with the step.
+    The regions of the submissions matching it are subtracted from all the comparisons run afterwards, which
+    report both the raw and the baseline-adjusted scores.
+
+    - **link**: URL to the S3 archive, GitHub, or GitLab repository of the starter code (required)
+    - **link_type**: Type of the link (optional, detected from the link)
+    - **description**: Optional description of the starter code
+    """
+    try:
+        return service.create_baseline(project_uuid, project_step_uuid, baseline_data)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Failed to create baseline: {str(e)}")
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/baselines", response_model=List[BaselineResponseDto])
+async def get_baselines(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the starter code baselines of a project step"""
+    try:
+        return service.get_baselines(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/baselines/{baseline_id}")
+async def delete_baseline(baseline_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Delete a starter code baseline, the comparisons already run keeping their scores"""
+    try:
+        return {"success": service.delete_baseline(baseline_id), "baseline_id": baseline_id}
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post(
+    "/project/{project_uuid}/step/{project_step_uuid}/allowed-snippets",
+    response_model=AllowedSnippetResponseDto,
+    status_code=201,
+)
+async def create_allowed_snippet(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    snippet_data: CreateAllowedSnippetDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Allow the students of a project step to copy a snippet
+
+    The snippet (an input parsing function, a timing harness...) is tokenized with its language and fingerprinted
+    once. In the comparisons run afterwards, the matched fragments lying within the snippet in both files of a pair
+    are excluded from its scores and reported apart as `sanctioned_overlap`, with the scores before their
+    exclusion. A fragment matching the snippet in one of the files only is still scored.
+
+    - **language**: Language of the snippet (required, 422 unsupported_language otherwise)
+    - **content**: Code of the snippet (required, 422 snippet_too_short when shorter than a fingerprint)
+    - **description**: Optional description of the snippet
+    """
+    try:
+        return service.create_allowed_snippet(project_uuid, project_step_uuid, snippet_data)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Failed to create allowed snippet: {str(e)}")
+
+
+@router.get(
+    "/project/{project_uuid}/step/{project_step_uuid}/allowed-snippets", response_model=List[AllowedSnippetResponseDto]
+)
+async def get_allowed_snippets(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the allowed snippets of a project step"""
+    try:
+        return service.get_allowed_snippets(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/allowed-snippets/{snippet_id}", response_model=AllowedSnippetResponseDto)
+async def get_allowed_snippet(snippet_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get an allowed snippet"""
+    try:
+        return service.get_allowed_snippet(snippet_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.patch("/allowed-snippets/{snippet_id}", response_model=AllowedSnippetResponseDto)
+async def update_allowed_snippet(
+    snippet_id: UUID,
+    snippet_data: UpdateAllowedSnippetDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Update an allowed snippet
+
+    Only the given fields are changed, the snippet being fingerprinted again when its code or language changes. The
+    comparisons run afterwards use the new snippet, those already run keeping their scores until their detection
+    run is rescored.
+    """
+    try:
+        return service.update_allowed_snippet(snippet_id, snippet_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/allowed-snippets/{snippet_id}")
+async def delete_allowed_snippet(snippet_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Delete an allowed snippet, the comparisons already run keeping their scores until they are rescored"""
+    try:
+        return {"success": service.delete_allowed_snippet(snippet_id), "snippet_id": snippet_id}
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post(
+    "/project/{project_uuid}/step/{project_step_uuid}/detection-runs",
+    response_model=DetectionRunResponseDto,
+    status_code=202,
+)
+async def create_detection_run(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    run_data: CreateDetectionRunDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Compare pairwise the submissions of a project step
+
+    Each distinct pair is compared once and in the background, the submissions of the same group not being compared
+    with each other. The pairs already compared (in either order) with the same contents and options are served from
+    the comparison cache, as are the pairs of the same contents as other compared pairs of the step: only the new or
+    stale comparisons are computed, the number of cached pairs being the `cached_pair_count` of the run. The progress
+    of the run is read from `/detection-runs/{run_id}`, and its matrix from `/detection-runs/{run_id}/matrix` as the
+    comparisons complete.
+
+    - **submission_ids**: Submissions of the step to compare (optional, all the submissions of the step)
+    - **include_corpus**: Whether each submission is also matched with the archived submissions of the corpora
+      referenced by the step (defaults to False)
+    - **include_references**: Whether each submission is also matched with the files of the reference sets, its
+      matches being listed apart from the pairs (defaults to False)
+    - **teams**: Team of the submissions by ID (optional, the others being their own team). The pairs of
+      teammates are compared but marked `same_team`, neither flagged nor clustered
+    - **include_same_team**: Whether the pairs of teammates are flagged and clustered anyway (defaults to False)
+    - **include_all_versions**: Whether every version of the submissions is compared when no submission is given,
+      not only the latest version of each group (defaults to False)
+    - **tokenization_timeout_seconds**: Time after which the tokenization of a file stops, the file being skipped
+      and listed in the `timed_out_files` of the comparison (optional, the configured one; 0 for no timeout)
+    - **comparison_timeout_seconds**: Time after which the comparison of a pair stops, the pair being marked
+      `timed_out` with its elapsed time (optional, the configured one; 0 for no timeout). The timeouts used are
+      recorded in the `timeouts` of the run
+    - **profile**: Analysis profile of the detection options and flagging of the run (optional, the built-in
+      `default` profile comparing as the service is configured)
+    - **overrides**: Options of the profile overridden for this run only, detection options or `flag_threshold`
+      and `min_token_count` (optional). The resolved options are recorded in the `effective_options` of the run
+    - **exclude_paths**: Paths left out of the comparisons of the run, in gitignore syntax (`tests/`, `data/**`),
+      the stored submissions being untouched (optional). The excluded files of each pair are listed in its
+      `file_similarities` and report, the comparisons being keyed with the exclusions
+    - **force_recompute**: Whether every pair is compared again, bypassing the comparison cache (defaults to False)
+    - **attach**: Whether a run of the step and profile already running is returned instead (defaults to True),
+      rather than refused with a 409 (detection_run_in_progress, with its `run_id`)
+
+    One run of a project step and profile runs at a time, across the instances of the service. The instance running
+    it renews its heartbeat (`heartbeat_at`, `claimed_by`): once stale, the run is taken over by another instance
+    and resumed, its pairs not compared yet being scheduled again, or `failed` (`DETECTION_RUN_ORPHAN_ACTION`).
+
+    A run is refused until all its submissions are analyzed (see `/{submission_id}/status`), listing the pending
+    ones (submissions_not_analyzed) rather than comparing them partially. It is refused with a retriable 503
+    (analysis_queue_full, with a Retry-After header) when the analysis queue stays full, or (analysis_draining) while
+    the analysis drains before a shutdown, and with a 422 when its profile does not exist (unknown_analysis_profile)
+    or its overrides are invalid (invalid_detection_options).
+    """
+    try:
+        return service.create_detection_run(project_uuid, project_step_uuid, run_data)
+    except ValidationException as e:
+        if hasattr(e, "detail") and isinstance(e.detail, dict):
+            raise HTTPException(status_code=422, detail=e.detail)
+        else:
+            raise HTTPException(status_code=422, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}", response_model=DetectionRunResponseDto)
+async def get_detection_run(run_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get a detection run and its progress
+
+    The run is running until all of its pairs are compared, then completed, or cancelled. Its progress is the
+    number and percentage of its pairs compared (those compared before the run included), with the time left at
+    the throughput of the recent period while it is running, unknown until the first pairs are compared.
+    """
+    try:
+        return service.get_detection_run(run_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/detection-runs/{run_id}", response_model=DetectionRunResponseDto)
+async def cancel_detection_run(run_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Cancel a running detection run
+
+    The workers compare none of its remaining pairs, the pairs in progress being finished; the pairs already
+    compared are kept, the matrix, clusters and summary of the run staying available as partial. A run already
+    completed or cancelled is refused with a 409 (detection_run_finished).
+    """
+    try:
+        return service.cancel_detection_run(run_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/detection-runs/{run_id}/rescore", response_model=DetectionRunResponseDto, status_code=202)
+async def rescore_detection_run(run_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Score again the pairs of a finished detection run with the current baselines and allowed snippets of its step
+
+    The pairs of its submissions are compared again in the background, their comparisons being updated in place
+    and their rendered reports discarded; the run is running again until all of them are, `rescored_at` recording
+    when it was rescored. With the token cache enabled, the files are not tokenized again. The pairs matched with
+    the corpora keep their scores. A run still running is refused with a 409 (run_in_progress), as is a run whose
+    step and profile have another run running (detection_run_in_progress), and the rescore with a retriable 503
+    (analysis_queue_full) when the analysis queue stays full.
+    """
+    try:
+        return service.rescore_detection_run(run_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except AnalysisQueueFull as e:
+        raise analysis_queue_full(e)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/detection-runs/{run_id}/verify", response_model=DetectionRunVerificationDto)
+async def verify_detection_run(
+    run_id: UUID,
+    verification: VerifyDetectionRunDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Verify that a finished detection run is reproducible
+
+    A sample of its completed pairs, drawn from the seed recorded on the run (or the given one, the same seed
+    drawing the same pairs), is compared again without the token cache, with the options and timeouts of the run,
+    and the results are asserted byte-identical to the recorded ones, their timing aside. Each pair is reported
+    identical, diverged (with the fields that differ and the first stage of the pipeline they come from:
+    tokenization, fingerprinting, scoring or visualization) or failed, with the digests of both results. The
+    changes of the fingerprint of the run (the versions of the tokenizers and algorithms, the options hash) are
+    listed as the environment stage. The pairs whose comparison was replaced since the run are not sampled, only
+    counted as superseded, and nothing is written. A run still running is refused with a 409 (run_in_progress).
+    """
+    try:
+        return service.verify_detection_run(run_id, verification)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/grading-callback", response_model=GradingCallbackDeliveryDto)
+async def get_grading_callback_delivery(run_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Get the delivery of the results of a detection run to the grading callback of its step: pending, delivered
+    once answered with a 2xx or failed, with the status code or error of each attempt
+    """
+    try:
+        return service.get_grading_callback_delivery(run_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/detection-runs/{run_id}/grading-callback", response_model=GradingCallbackDeliveryDto, status_code=202)
+async def redeliver_grading_callback(run_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Post again the results of a completed detection run to the grading callback of its step, in the background
+
+    A failed delivery is not retried on its own, the grading service being re-delivered explicitly. A run still
+    running is refused with a 409 (run_in_progress), as a cancelled one (detection_run_cancelled), and a run whose
+    step has no grading callback with a 404.
+    """
+    try:
+        return service.redeliver_grading_callback(run_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/matrix", response_model=SimilarityMatrixDto)
+async def get_similarity_matrix(
+    run_id: UUID,
+    min_similarity: float = Query(0.0, ge=0.0, le=1.0, description="Similarity under which pairs are not listed"),
+    skip: int = Query(0, ge=0, description="Number of listed pairs to skip"),
+    limit: int = Query(100, ge=1, le=1000, description="Maximum number of listed pairs to return"),
+    flag_threshold: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Similarity flagging a pair as suspicious (project step configuration)"
+    ),
+    min_token_count: Optional[int] = Query(
+        None, ge=0, description="Compared tokens under which a submission is too short (project step configuration)"
+    ),
+    merge_threshold: float = Query(
+        DEFAULT_MERGE_THRESHOLD, ge=0.0, le=1.0, description="Similarity of a pair bridging two clusters to merge them"
+    ),
+    include_same_team: Optional[bool] = Query(
+        None, description="Whether the pairs of teammates are flagged and clustered (choice of the run by default)"
+    ),
+    flagged_only: bool = Query(False, description="Whether only the flagged pairs are listed"),
+    sort_by: PairSortField = Query(PairSortField.OVERALL_SIMILARITY, description="Score sorting the listed pairs"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the pairwise similarity matrix of a detection run
+
+    The matrix is sparse: the pairs at or above the minimum similarity (the flagged ones only if requested) are
+    listed by decreasing similarity and paginated, while the flagged pairs, their clusters and the maximum
+    similarity of each submission cover the whole run. With `sort_by=max_function_similarity`, the pairs are listed
+    by decreasing similarity of their most similar functions instead, the pairs without functions last. The runs
+    including the corpus list the matches with archived submissions from the minimum similarity too, with the
+    labels of the archived submissions, and the runs including the references their matches with the files of the
+    reference sets apart (`reference_matches`), with the label of the set, the path of the file and its license.
+
+    The matrix of a run not completed (running or cancelled) lists the pairs compared so far, marked `partial`.
+    """
+    try:
+        return service.get_similarity_matrix(
+            run_id,
+            min_similarity,
+            skip,
+            limit,
+            flag_threshold,
+            min_token_count,
+            merge_threshold,
+            include_same_team,
+            flagged_only,
+            sort_by,
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/clusters", response_model=SimilarityClustersDto)
+async def get_similarity_clusters(
+    run_id: UUID,
+    flag_threshold: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Similarity flagging a pair as suspicious (project step configuration)"
+    ),
+    min_token_count: Optional[int] = Query(
+        None, ge=0, description="Compared tokens under which a submission is too short (project step configuration)"
+    ),
+    merge_threshold: float = Query(
+        DEFAULT_MERGE_THRESHOLD, ge=0.0, le=1.0, description="Similarity of a pair bridging two clusters to merge them"
+    ),
+    include_same_team: Optional[bool] = Query(
+        None, description="Whether the pairs of teammates are flagged and clustered (choice of the run by default)"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the clusters of submissions sharing code of a detection run
+
+    The submissions are linked by their flagged pairs (single linkage), most similar first. A pair bridging two
+    clusters of several submissions merges them only at or above the merge threshold, and the pairs of teammates
+    link nothing unless included. Each cluster comes with
+    the minimum, average and maximum similarity of its pairs and its medoid, the submission most similar to the
+    others.
+    """
+    try:
+        return service.get_similarity_clusters(
+            run_id, flag_threshold, min_token_count, merge_threshold, include_same_team
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/summary", response_model=DetectionRunSummaryDto)
+async def get_detection_run_summary(
+    run_id: UUID,
+    buckets: int = Query(
+        DEFAULT_SUMMARY_BUCKETS, ge=1, le=100, description="Number of buckets of the score histogram, dividing 100"
+    ),
+    top: int = Query(DEFAULT_CENTRAL_SUBMISSIONS, ge=0, le=100, description="Number of central submissions listed"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the score distribution of a detection run
+
+    The summary holds the histogram of the scores of the completed pairs, their mean, median and 95th percentile,
+    the number of flagged pairs and of clusters, and the submissions appearing in the most flagged pairs. It is
+    computed once all the pairs of the run are compared, and served as stored; a run in progress gets a partial
+    summary, marked as incomplete.
+    """
+    try:
+        return service.get_detection_run_summary(run_id, buckets, top)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/rare-tokens", response_model=RareTokenEvidenceDto)
+async def get_rare_token_evidence(
+    run_id: UUID,
+    max_document_frequency: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Share of the submissions a rare token appears in at most (configured)"
+    ),
+    blend_weight: float = Query(
+        0.0, ge=0.0, le=1.0, description="Weight of the evidence score blended into the similarity, none by default"
+    ),
+    skip: int = Query(0, ge=0, description="Number of pairs to skip"),
+    limit: int = Query(100, ge=1, le=1000, description="Maximum number of pairs returned"),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Get the pairs of submissions of a detection run sharing rare identifiers or string literals
+
+    A token is rare when it appears in few submissions of the run, the same bizarre variable name or typo'd error
+    message in two submissions being evidence structural similarity misses. The builtins, keywords and standard
+    library names of the language and the conventional names (`i`, `err`, `result`...) are ignored. Each pair lists
+    its shared tokens, rarest first, with an evidence score; its similarity is left untouched unless a blend weight
+    is given, mixing the evidence score into a blended similarity. The submissions are fetched and tokenized on
+    request.
+    """
+    try:
+        return service.get_rare_token_evidence(run_id, max_document_frequency, blend_weight, skip, limit)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/report", response_class=HTMLResponse)
+async def get_detection_run_report(
+    run_id: UUID,
+    min_similarity: Optional[float] = Query(
+        None, ge=0.0, le=1.0, description="Similarity from which the completed pairs are reported (flagged pairs)"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Download the HTML report of a detection run: its summary, then the report of each of its flagged pairs, or of
+    each of its completed pairs from the minimum similarity when one is given
+    """
+    try:
+        report = service.get_detection_run_report(run_id, min_similarity)
+        return HTMLResponse(
+            report, headers={"Content-Disposition": f"attachment; filename=detection-run-{run_id}.html"}
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post(
+    "/detection-runs/{run_id}/pairs/{similarity_id}/evidence", response_model=ReportJobResponseDto, status_code=202
+)
+async def request_evidence_package(
+    run_id: UUID, similarity_id: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Build the evidence package of a pair of a detection run for an academic integrity case, in the background
+
+    The package is a ZIP archive of the source files of both submissions (under `submissions/{submission_id}/`),
+    the HTML report of their comparison and its PDF report (left out if it fails to render), `comparison.json`
+    with the scores, fragments and options of the comparison and the configuration of the run, and
+    `manifest.json` with the size and SHA-256 of each file and the time the package was generated at. The archive
+    is reproducible: its files are ordered by path and dated with the comparison, so that two exports of the same
+    pair have the same checksum (`content_sha256` of the job).
+
+    The job is polled on `/submissions/report-jobs/{job_id}` until it is completed, its package being then
+    downloaded from `/submissions/report-jobs/{job_id}/download`. A comparison not completed is a 422, and a pair
+    whose files are quarantined or purged a 409.
+    """
+    try:
+        return service.request_evidence_package(run_id, similarity_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ConflictException as e:
+        raise HTTPException(status_code=409, detail=e.detail)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/detection-runs/{run_id}/export")
+async def export_detection_run(
+    run_id: UUID,
+    request: Request,
+    export_format: Optional[str] = Query(
+        None, alias="format", pattern="^(csv|json)$", description="Format of the export (from the Accept header)"
+    ),
+    min_similarity: float = Query(0.0, ge=0.0, le=1.0, description="Similarity under which pairs are not exported"),
+    flagged_only: bool = Query(False, description="Whether only the flagged pairs are exported"),
+    include_metrics: bool = Query(False, description="Whether the code metrics of both submissions are exported"),
+    scope: str = Query(
+        "pairs", pattern="^(pairs|references)$", description="Whether a CSV export holds the pairs or reference matches"
+    ),
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Download the pairs of a detection run, streamed, one row per pair as CSV or as a JSON document including
+    their fragments
+
+    The format is the `format` query parameter, or CSV when the Accept header asks for `text/csv` and JSON
+    otherwise. The pairs are filtered as in the similarity matrix of the run, and exported with the code metrics
+    of their submissions if requested. The matches of a run including the reference sets are listed after the
+    pairs in JSON (`reference_matches`), and exported on their own as CSV with `scope=references`.
+    """
+    if export_format is None:
+        export_format = "csv" if "text/csv" in request.headers.get("accept", "") else "json"
+    try:
+        content, filename = service.export_detection_run(
+            run_id, export_format, min_similarity, flagged_only, include_metrics, scope
+        )
+        return StreamingResponse(
+            content,
+            media_type="text/csv" if export_format == "csv" else "application/json",
+            headers={"Content-Disposition": f"attachment; filename={filename}"},
+        )
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/corpora", response_model=CorpusResponseDto, status_code=201)
+async def create_corpus(corpus_data: CreateCorpusDto, service: SubmissionService = Depends(get_submission_service)):
+    """
+    Create a reference corpus, an archived collection of past submissions
+
+    - **label**: Label of the collection, e.g. the year of its cohort (required)
+    - **description**: Optional description of the collection
+    """
+    try:
+        return service.create_corpus(corpus_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/corpora/{corpus_id}/items", response_model=List[CorpusItemResponseDto])
+async def get_corpus_items(corpus_id: UUID, service: SubmissionService = Depends(get_submission_service)):
+    """Get the archived submissions of a corpus"""
+    try:
+        return service.get_corpus_items(corpus_id)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.post("/corpora/{corpus_id}/items", response_model=CorpusItemResponseDto, status_code=201)
+async def create_corpus_item(
+    corpus_id: UUID, item_data: CreateCorpusItemDto, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Archive a past submission in a corpus
+
+    The submission is fetched and fingerprinted once, its fingerprints being stored so that the detection runs
+    do not tokenize it again. Archived submissions are never graded nor compared with each other.
+
+    - **label**: Label reported with its matches, e.g. 2023/alice (required)
+    - **link**: URL to the S3 archive, GitHub, or GitLab repository of the submission (required)
+    - **link_type**: Type of the link (optional, detected from the link)
+    """
+    try:
+        return service.create_corpus_item(corpus_id, item_data)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+    except AccessDenied:
+        raise
+    except Exception as e:
+        raise HTTPException(status_code=500, detail=f"Failed to create corpus item: {str(e)}")
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/corpora", response_model=List[CorpusResponseDto])
+async def get_step_corpora(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the reference corpora of a project step"""
+    try:
+        return service.get_step_corpora(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/corpora", response_model=List[CorpusResponseDto])
+async def save_step_corpora(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    corpora_data: StepCorporaDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Reference the corpora whose archived submissions the detection runs of a project step match with
+
+    - **corpus_ids**: Corpora of the step, replacing those it referenced
+    """
+    try:
+        return service.save_step_corpora(project_uuid, project_step_uuid, corpora_data)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/file-filter", response_model=FileFilterConfigDto)
+async def get_file_filter_config(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the files allowed in the uploaded submissions of a project step, every file unless configured"""
+    try:
+        return service.get_file_filter_config(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/file-filter", response_model=FileFilterConfigDto)
+async def save_file_filter_config(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: FileFilterConfigDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Configure the files allowed in the uploaded submissions of a project step
+
+    Enforced when the files of an upload, a Git repository or a bulk upload are extracted: a denied file is never
+    allowed, and when an allowlist is given only the files it matches are. The patterns are globs: one without a
+    slash matches the file name or any directory name (`Makefile`, `node_modules`), one with a slash the whole path.
+
+    - **mode**: `strict` rejects an upload holding disallowed files, listing them; `lenient` (default) skips them,
+      logging them in the processing log of the submission
+    - **allowed_extensions** / **denied_extensions**: Extensions such as `.c` or `.min.js` (optional)
+    - **allowed_patterns** / **denied_patterns**: Glob patterns of the file paths (optional)
+    """
+    try:
+        return service.save_file_filter_config(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/upload-limits", response_model=EffectiveUploadLimitsDto)
+async def get_upload_limits(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the limits enforced on the uploads of a project step, with those it overrides"""
+    try:
+        return service.get_upload_limits(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/upload-limits", response_model=EffectiveUploadLimitsDto)
+async def save_upload_limits(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: UploadLimitsDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Override the limits of the uploads of a project step, for legitimately large projects
+
+    Each limit left out keeps the configured default. An upload exceeding a limit is rejected with its error_type.
+
+    - **max_upload_bytes**: Size of the uploaded file (`upload_too_large`)
+    - **max_extracted_bytes**: Total size of the extracted files (`extracted_size_exceeded`)
+    - **max_file_count**: Number of files (`too_many_files`)
+    - **max_file_bytes**: Size of a single file (`file_too_large`)
+    - **reject_binary_files**: Reject an upload holding a binary file, rather than analyzing it without the file
+      (`binary_file_rejected`)
+    - **strict_paths**: Reject an archive with several entries at a path (`duplicate_entry_path`) or with paths
+      differing only by case (`case_colliding_paths`), rather than keeping the last entry and flagging the paths
+    """
+    try:
+        return service.save_upload_limits(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/ignore-rules", response_model=EffectiveIgnoreRulesDto)
+async def get_ignore_rules_config(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the paths left out of the submissions of a project step, the built-in rules if it never set its own"""
+    try:
+        return service.get_ignore_rules_config(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/ignore-rules", response_model=EffectiveIgnoreRulesDto)
+async def save_ignore_rules_config(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: IgnoreRulesConfigDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Set the paths left out of the submissions of a project step, during the extraction of the uploads and the
+    fetch of the Git trees
+
+    - **patterns**: Rules in gitignore syntax, in order: `build/` ignores a directory, `*.o` a name at any depth,
+      `/docs` a path from the root, `**` any number of directories, and `!build/README.md` includes a path again
+      (unless one of its directories is ignored). None restores the built-in rules (node_modules/, vendor/,
+      target/, dist/, .idea/, *.o, *.class), an empty list ignores nothing
+    - **honor_gitignore**: Honor the .gitignore files of a submission too, a path being ignored when either the
+      rules of the step or the .gitignore files ignore it
+
+    The ignored paths are counted, with a sample, in the processing log of each submission.
+    """
+    try:
+        return service.save_ignore_rules_config(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get(
+    "/project/{project_uuid}/step/{project_step_uuid}/grading-callback", response_model=GradingCallbackResponseDto
+)
+async def get_grading_callback(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the grading callback of a project step, without its secret"""
+    try:
+        return service.get_grading_callback(project_uuid, project_step_uuid)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put(
+    "/project/{project_uuid}/step/{project_step_uuid}/grading-callback",
+    response_model=SavedGradingCallbackResponseDto,
+)
+async def save_grading_callback(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: GradingCallbackDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Set the callback of the grading service the results of the completed detection runs of a project step are
+    posted to
+
+    Each delivery is a JSON POST of the flagged pairs of the run and the highest similarity of each submission,
+    signed as the webhook deliveries: `X-Webhook-Signature` is `sha256=` followed by the hexadecimal HMAC-SHA256 of
+    `{timestamp}.{body}` with the secret, `timestamp` being the `X-Webhook-Timestamp` header. The version of the
+    payload is in its `schema_version` and in the `X-Callback-Schema-Version` header, and `X-Webhook-Delivery` is
+    the ID of the run. The secret is returned each time the callback is saved.
+
+    - **url**: http or https URL of the assignment in the grading service, whose host must resolve to public addresses
+      only and be allowed by the external source hosts, checked again before each delivery (required)
+    - **secret**: Secret shared with the grading service, at least 16 characters (optional, generated by default)
+    """
+    try:
+        return service.save_grading_callback(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete("/project/{project_uuid}/step/{project_step_uuid}/grading-callback")
+async def delete_grading_callback(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Delete the grading callback of a project step, the results of its next runs being posted nowhere"""
+    try:
+        return {"success": service.delete_grading_callback(project_uuid, project_step_uuid)}
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get(
+    "/project/{project_uuid}/step/{project_step_uuid}/retention-policy", response_model=RetentionPolicyResponseDto
+)
+async def get_retention_policy(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the retention policy of a project step, 404 when its files and results are kept forever"""
+    try:
+        return service.get_retention_policy(project_uuid, project_step_uuid)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put(
+    "/project/{project_uuid}/step/{project_step_uuid}/retention-policy",
+    response_model=RetentionPolicyResponseDto,
+    dependencies=[Depends(require_admin)],
+)
+async def save_retention_policy(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    policy_data: RetentionPolicyDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Set how long the files and detection results of a project step are kept (administrators only, with the
+    `X-Admin-Key` header)
+
+    Once expired, a submission loses its stored file and the index of its code, keeping its record (hashes,
+    scores), and a comparison its details and reports, keeping its scores: they are deleted for good, with the
+    expired detection runs, under full deletion. A submission whose files were purged is no longer compared nor
+    downloaded (409 `submission_files_purged`). A submission with an open integrity case (`integrity_case_open`,
+    set with a patch) is never purged, nor its comparisons and the runs that compared it. The expired data is
+    purged in the background, or at once with `/retention/purge`.
+
+    - **anchor**: Date the retention runs from: `deadline` (the grading deadline of the step, nothing expiring
+      without one), `date` (the anchor_date) or `upload` (the upload of each submission, the creation of each
+      result). Defaults to `deadline`
+    - **anchor_date**: Fixed date, such as the end of the course (required with the `date` anchor)
+    - **file_retention_days**: Days the files are kept (optional, forever by default)
+    - **result_retention_days**: Days the detection results are kept (optional, forever by default)
+    - **full_deletion**: Delete the expired records rather than keeping their metadata (defaults to False)
+    """
+    try:
+        return service.save_retention_policy(project_uuid, project_step_uuid, policy_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete(
+    "/project/{project_uuid}/step/{project_step_uuid}/retention-policy", dependencies=[Depends(require_admin)]
+)
+async def delete_retention_policy(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Delete the retention policy of a project step, its files and results being kept forever (administrators only,
+    with the `X-Admin-Key` header)
+    """
+    try:
+        return {"success": service.delete_retention_policy(project_uuid, project_step_uuid)}
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/schedule", response_model=StepScheduleResponseDto)
+async def get_step_schedule(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """
+    Get the grading deadline of a project step, with the priority the analyses of its submissions are queued at, and
+    its scheduled detection run
+    """
+    try:
+        return service.get_step_schedule(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/schedule", response_model=StepScheduleResponseDto)
+async def save_step_schedule(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    schedule_data: StepScheduleDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Set the grading deadline of a project step, the analyses of its submissions being prioritized by it
+
+    The analyses of a step are urgent within a day of its deadline or once it is passed, high within three days,
+    normal within two weeks or without deadline, and low beyond. The jobs of higher priority are served first, a
+    job waiting long enough being served before the newer urgent ones. The queued jobs of the step are re-ordered
+    at its new priority, except those of submissions created with an explicit `analysis_priority`.
+
+    At `auto_run_at`, typically the deadline plus a grace period, a detection run of the latest versions of the
+    analyzed submissions of the step is started with the analysis profile `auto_run_profile`, its completion
+    notified to the webhooks as any run's. A run due while the service was down is started at its startup. Saved
+    with another time or profile, the run is scheduled again; saved without `auto_run_at`, it is cancelled.
+
+    - **deadline**: Grading deadline of the step (optional)
+    - **priority**: Priority of the analyses of the step, overriding that of the deadline (optional)
+    - **auto_run_at**: When the detection run of the step is started (optional)
+    - **auto_run_profile**: Analysis profile of the scheduled run (optional, the default profile)
+    """
+    try:
+        return service.save_step_schedule(project_uuid, project_step_uuid, schedule_data)
+    except ValidationException as e:
+        raise HTTPException(status_code=422, detail=e.detail if isinstance(e.detail, dict) else str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.delete(
+    "/project/{project_uuid}/step/{project_step_uuid}/schedule/auto-run", response_model=StepScheduleResponseDto
+)
+async def cancel_scheduled_detection_run(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Cancel the detection run scheduled for a project step, keeping its deadline and priority"""
+    try:
+        return service.cancel_scheduled_detection_run(project_uuid, project_step_uuid)
+    except NotFoundException as e:
+        raise HTTPException(status_code=404, detail=str(e.detail))
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/header-config", response_model=HeaderConfigDto)
+async def get_header_config(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the license and file header stripping configuration of a project step"""
+    try:
+        return service.get_header_config(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/header-config", response_model=HeaderConfigDto)
+async def save_header_config(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: HeaderConfigDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Configure the headers stripped from the files of a project step before tokenization
+
+    The initial comment block of a file is stripped when it matches one of the patterns or templates, the
+    stripped lines being listed in the similarity details of the comparisons.
+
+    - **enabled**: Whether the headers are stripped (defaults to True)
+    - **patterns**: Regexes searched in the initial comment block (optional, known license headers by default)
+    - **templates**: Header templates mandated for the step, `{placeholders}` matching anything (optional)
+    """
+    try:
+        return service.save_header_config(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.get("/project/{project_uuid}/step/{project_step_uuid}/detection-config", response_model=DetectionConfigDto)
+async def get_detection_config(
+    project_uuid: UUID, project_step_uuid: UUID, service: SubmissionService = Depends(get_submission_service)
+):
+    """Get the similarity flagging configuration of a project step"""
+    try:
+        return service.get_detection_config(project_uuid, project_step_uuid)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
+@router.put("/project/{project_uuid}/step/{project_step_uuid}/detection-config", response_model=DetectionConfigDto)
+async def save_detection_config(
+    project_uuid: UUID,
+    project_step_uuid: UUID,
+    config_data: DetectionConfigDto,
+    service: SubmissionService = Depends(get_submission_service),
+):
+    """
+    Configure the flagging of the similarity results of a project step
+
+    These values are the defaults of the similarity listings and statistics of the step, each request being able
+    to override them.
+
+    - **flag_threshold**: Overall similarity at or above which a pair is suspicious (defaults to 0.7)
+    - **min_token_count**: Compared tokens under which a submission is listed as too short (defaults to 50)
+    - **originality_feedback_enabled**: Whether the students may read the anonymized originality feedback of their
+      own submissions (defaults to false)
+    """
+    try:
+        return service.save_detection_config(project_uuid, project_step_uuid, config_data)
+    except DatabaseException as e:
+        raise HTTPException(status_code=500, detail=str(e))
+
+
 @router.get("/health/check")
 async def submissions_health_check(service: SubmissionService = Depends(get_submission_service)):
     """Health check for submissions domain"""
     try:
         # Try to perform a simple database operation
-        submissions = service.list_submissions(skip=0, limit=1)
+        page = service.list_submissions(skip=0, limit=1)
         return {
             "status": "healthy",
             "domain": "submissions",
             "database_accessible": True,
-            "sample_count": len(submissions),
+            "sample_count": len(page.items),
         }
     except Exception as e:
         return {"status": "unhealthy", "domain": "submissions", "database_accessible": False, "error": str(e)}
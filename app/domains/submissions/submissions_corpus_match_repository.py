@@ -0,0 +1,75 @@
+from typing import List
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionCorpusMatch
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionCorpusMatchRepository:
+    """Repository for the matches of the submissions with the archived submissions of the corpora"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, match_data: dict) -> SubmissionCorpusMatch:
+        """Create a new corpus match record"""
+        try:
+            match = SubmissionCorpusMatch(**match_data)
+            self.session.add(match)
+            self.session.commit()
+            self.session.refresh(match)
+            return match
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create corpus match: {str(e)}")
+
+    def create_many(self, matches_data: List[dict]) -> int:
+        """Create the given corpus match records in one transaction, returning their number"""
+        try:
+            for match_data in matches_data:
+                self.session.add(SubmissionCorpusMatch(**match_data))
+            self.session.commit()
+            return len(matches_data)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create corpus matches: {str(e)}")
+
+    def get_by_submission_ids(self, submission_ids: List[UUID]) -> List[SubmissionCorpusMatch]:
+        """Get the corpus matches of the given submissions, most similar first"""
+        try:
+            statement = (
+                select(SubmissionCorpusMatch)
+                .where(SubmissionCorpusMatch.submission_id.in_(submission_ids))
+                .order_by(SubmissionCorpusMatch.overall_similarity.desc(), SubmissionCorpusMatch.id)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpus matches: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the corpus matches of a submission, returning their number"""
+        try:
+            statement = select(SubmissionCorpusMatch).where(SubmissionCorpusMatch.submission_id == submission_id)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete corpus matches: {str(e)}")
+
+    def delete_by_corpus_item_ids(self, corpus_item_ids: List[UUID]) -> int:
+        """Delete the matches of the given corpus items, returning their number"""
+        try:
+            statement = select(SubmissionCorpusMatch).where(SubmissionCorpusMatch.corpus_item_id.in_(corpus_item_ids))
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete corpus matches: {str(e)}")
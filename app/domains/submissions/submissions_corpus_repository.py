@@ -0,0 +1,153 @@
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import (
+    CorpusKind,
+    SubmissionCorpus,
+    SubmissionCorpusItem,
+    SubmissionStepCorpus,
+)
+from app.shared.exceptions import DatabaseException
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+
+class SubmissionCorpusRepository:
+    """Repository for the corpora of archived submissions and the project steps referencing them"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, corpus_data: dict) -> SubmissionCorpus:
+        """Create a new corpus record"""
+        try:
+            corpus = SubmissionCorpus(**corpus_data)
+            self.session.add(corpus)
+            self.session.commit()
+            self.session.refresh(corpus)
+            return corpus
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create corpus: {str(e)}")
+
+    def get_by_id(self, corpus_id: UUID, tenant_id: Optional[str] = None) -> Optional[SubmissionCorpus]:
+        """Get corpus record by ID, None if it belongs to another tenant than the given one"""
+        try:
+            statement = select(SubmissionCorpus).where(SubmissionCorpus.id == corpus_id)
+            if tenant_id is not None:
+                statement = statement.where(SubmissionCorpus.tenant_id == tenant_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpus: {str(e)}")
+
+    def get_by_kind(self, kind: CorpusKind, tenant_id: str = DEFAULT_TENANT_ID) -> List[SubmissionCorpus]:
+        """Get the corpora of a kind of a tenant, by label"""
+        try:
+            statement = (
+                select(SubmissionCorpus)
+                .where(SubmissionCorpus.kind == kind, SubmissionCorpus.tenant_id == tenant_id)
+                .order_by(SubmissionCorpus.label)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpora: {str(e)}")
+
+    def update(self, corpus_id: UUID, update_data: dict) -> Optional[SubmissionCorpus]:
+        """Update corpus record"""
+        try:
+            corpus = self.get_by_id(corpus_id)
+            if not corpus:
+                return None
+            for field, value in update_data.items():
+                setattr(corpus, field, value)
+            self.session.add(corpus)
+            self.session.commit()
+            self.session.refresh(corpus)
+            return corpus
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update corpus: {str(e)}")
+
+    def create_item(self, item_data: dict) -> SubmissionCorpusItem:
+        """Create a new corpus item record"""
+        try:
+            item = SubmissionCorpusItem(**item_data)
+            self.session.add(item)
+            self.session.commit()
+            self.session.refresh(item)
+            return item
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create corpus item: {str(e)}")
+
+    def replace_items(self, corpus_id: UUID, items_data: List[dict]) -> int:
+        """Replace the items of a corpus in one transaction, their matches deleted beforehand, returning their number"""
+        try:
+            statement = select(SubmissionCorpusItem).where(SubmissionCorpusItem.corpus_id == corpus_id)
+            for item in self.session.exec(statement).all():
+                self.session.delete(item)
+            self.session.flush()
+            for item_data in items_data:
+                self.session.add(SubmissionCorpusItem(corpus_id=corpus_id, **item_data))
+            self.session.commit()
+            return len(items_data)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to replace corpus items: {str(e)}")
+
+    def get_items(self, corpus_ids: List[UUID]) -> List[SubmissionCorpusItem]:
+        """Get all the items of the given corpora"""
+        try:
+            statement = (
+                select(SubmissionCorpusItem)
+                .where(SubmissionCorpusItem.corpus_id.in_(corpus_ids))
+                .order_by(SubmissionCorpusItem.label)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpus items: {str(e)}")
+
+    def get_items_by_ids(self, item_ids: List[UUID]) -> List[SubmissionCorpusItem]:
+        """Get the corpus items of the given IDs"""
+        try:
+            statement = select(SubmissionCorpusItem).where(SubmissionCorpusItem.id.in_(item_ids))
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpus items: {str(e)}")
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionCorpus]:
+        """Get the corpora referenced by a project step"""
+        try:
+            statement = (
+                select(SubmissionCorpus)
+                .join(SubmissionStepCorpus, SubmissionStepCorpus.corpus_id == SubmissionCorpus.id)
+                .where(
+                    SubmissionStepCorpus.project_uuid == project_uuid,
+                    SubmissionStepCorpus.project_step_uuid == project_step_uuid,
+                )
+                .order_by(SubmissionCorpus.label)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get corpora for project step: {str(e)}")
+
+    def save_project_step(self, project_uuid: UUID, project_step_uuid: UUID, corpus_ids: List[UUID]) -> None:
+        """Replace the corpora referenced by a project step"""
+        try:
+            statement = select(SubmissionStepCorpus).where(
+                SubmissionStepCorpus.project_uuid == project_uuid,
+                SubmissionStepCorpus.project_step_uuid == project_step_uuid,
+            )
+            for reference in self.session.exec(statement).all():
+                self.session.delete(reference)
+            for corpus_id in dict.fromkeys(corpus_ids):
+                self.session.add(
+                    SubmissionStepCorpus(
+                        project_uuid=project_uuid, project_step_uuid=project_step_uuid, corpus_id=corpus_id
+                    )
+                )
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save corpora for project step: {str(e)}")
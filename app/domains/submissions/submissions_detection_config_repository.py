@@ -0,0 +1,49 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionDetectionConfig
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionDetectionConfigRepository:
+    """Repository for the similarity flagging configuration of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> Optional[SubmissionDetectionConfig]:
+        """Get the detection configuration of a project step"""
+        try:
+            statement = select(SubmissionDetectionConfig).where(
+                SubmissionDetectionConfig.project_uuid == project_uuid,
+                SubmissionDetectionConfig.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get detection configuration: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, config_data: dict) -> SubmissionDetectionConfig:
+        """Create or replace the detection configuration of a project step"""
+        try:
+            config = self.get_by_project_step(project_uuid, project_step_uuid)
+            if config:
+                for field, value in config_data.items():
+                    setattr(config, field, value)
+                config.updated_at = datetime.utcnow()
+            else:
+                config = SubmissionDetectionConfig(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **config_data
+                )
+
+            self.session.add(config)
+            self.session.commit()
+            self.session.refresh(config)
+            return config
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save detection configuration: {str(e)}")
@@ -0,0 +1,228 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from sqlalchemy import delete, or_, update
+from sqlalchemy.exc import IntegrityError
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import DetectionRunStatus, SubmissionDetectionRun, get_paris_time
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionDetectionRunRepository:
+    """Repository for the detection runs comparing the submissions of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, run_data: dict) -> Optional[SubmissionDetectionRun]:
+        """Create a new detection run record, None if its lock key is held by another running run meanwhile"""
+        try:
+            run = SubmissionDetectionRun(**run_data)
+            self.session.add(run)
+            self.session.commit()
+            self.session.refresh(run)
+            return run
+        except IntegrityError:
+            self.session.rollback()
+            return None
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create detection run: {str(e)}")
+
+    def get_by_id(self, run_id: UUID) -> Optional[SubmissionDetectionRun]:
+        """Get detection run record by ID"""
+        try:
+            statement = select(SubmissionDetectionRun).where(SubmissionDetectionRun.id == run_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get detection run: {str(e)}")
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> List[SubmissionDetectionRun]:
+        """Get the detection runs of a project step, most recent first"""
+        try:
+            statement = (
+                select(SubmissionDetectionRun)
+                .where(
+                    SubmissionDetectionRun.project_uuid == project_uuid,
+                    SubmissionDetectionRun.project_step_uuid == project_step_uuid,
+                )
+                .order_by(SubmissionDetectionRun.created_at.desc())
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get detection runs: {str(e)}")
+
+    def get_by_lock_key(self, lock_key: str) -> Optional[SubmissionDetectionRun]:
+        """Get the running run holding a lock key, as last committed"""
+        try:
+            statement = select(SubmissionDetectionRun).where(SubmissionDetectionRun.lock_key == lock_key)
+            return self.session.exec(statement.execution_options(populate_existing=True)).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get detection run: {str(e)}")
+
+    def get_stale(self, stale_before: datetime) -> List[SubmissionDetectionRun]:
+        """Get the running runs whose heartbeat was never renewed or not since the given time, oldest first"""
+        try:
+            statement = (
+                select(SubmissionDetectionRun)
+                .where(
+                    SubmissionDetectionRun.status == DetectionRunStatus.RUNNING,
+                    or_(
+                        SubmissionDetectionRun.heartbeat_at.is_(None),
+                        SubmissionDetectionRun.heartbeat_at < stale_before,
+                    ),
+                )
+                .order_by(SubmissionDetectionRun.created_at)
+            )
+            return list(self.session.exec(statement.execution_options(populate_existing=True)).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get stale detection runs: {str(e)}")
+
+    def renew_heartbeats(self, instance_id: str) -> int:
+        """Renew the heartbeat of the running runs claimed by an instance, returning their number"""
+        try:
+            result = self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(
+                    SubmissionDetectionRun.claimed_by == instance_id,
+                    SubmissionDetectionRun.status == DetectionRunStatus.RUNNING,
+                )
+                .values(heartbeat_at=get_paris_time())
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to renew detection run heartbeats: {str(e)}")
+
+    def take_over(self, run_id: UUID, heartbeat_at: Optional[datetime], instance_id: str) -> bool:
+        """
+        Claim a stale run for an instance if its heartbeat is still the one it was found stale with, in a single
+        statement so that a single instance takes it over. Returns whether it did.
+        """
+        try:
+            renewed = (
+                SubmissionDetectionRun.heartbeat_at.is_(None)
+                if heartbeat_at is None
+                else SubmissionDetectionRun.heartbeat_at == heartbeat_at
+            )
+            result = self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(
+                    SubmissionDetectionRun.id == run_id,
+                    SubmissionDetectionRun.status == DetectionRunStatus.RUNNING,
+                    renewed,
+                )
+                .values(claimed_by=instance_id, heartbeat_at=get_paris_time())
+            )
+            self.session.commit()
+            return result.rowcount > 0
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to take over detection run: {str(e)}")
+
+    def fail(self, run_id: UUID, error_message: str) -> bool:
+        """Fail a detection run if it is still running, releasing its lock, returning whether it was"""
+        try:
+            result = self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(SubmissionDetectionRun.id == run_id, SubmissionDetectionRun.status == DetectionRunStatus.RUNNING)
+                .values(
+                    status=DetectionRunStatus.FAILED,
+                    failed_at=get_paris_time(),
+                    error_message=error_message,
+                    lock_key=None,
+                )
+            )
+            self.session.commit()
+            return result.rowcount > 0
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to fail detection run: {str(e)}")
+
+    def update(self, run_id: UUID, run_data: dict) -> SubmissionDetectionRun:
+        """Update the given fields of a detection run record"""
+        try:
+            run = self.get_by_id(run_id)
+            if not run:
+                raise NotFoundException(f"Detection run with ID {run_id} not found")
+            for field, value in run_data.items():
+                setattr(run, field, value)
+            self.session.add(run)
+            self.session.commit()
+            self.session.refresh(run)
+            return run
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update detection run: {str(e)}")
+
+    def get_status(self, run_id: UUID) -> Optional[DetectionRunStatus]:
+        """Get the current status of a detection run, read from the database rather than the session"""
+        try:
+            statement = select(SubmissionDetectionRun.status).where(SubmissionDetectionRun.id == run_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get detection run status: {str(e)}")
+
+    def increment_completed_pairs(
+        self, run_id: UUID, count: int = 1, token_cache_hits: int = 0, token_cache_misses: int = 0
+    ) -> bool:
+        """
+        Count pairs of a detection run as compared, with the hits and misses of the token cache of their files, in
+        a single statement so that the workers do not overwrite each other, completing the run still running once
+        all of its pairs are counted and releasing its lock. Returns whether it completed it.
+        """
+        try:
+            self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(SubmissionDetectionRun.id == run_id)
+                .values(
+                    completed_pair_count=SubmissionDetectionRun.completed_pair_count + count,
+                    token_cache_hits=SubmissionDetectionRun.token_cache_hits + token_cache_hits,
+                    token_cache_misses=SubmissionDetectionRun.token_cache_misses + token_cache_misses,
+                )
+            )
+            result = self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(
+                    SubmissionDetectionRun.id == run_id,
+                    SubmissionDetectionRun.status == DetectionRunStatus.RUNNING,
+                    SubmissionDetectionRun.completed_pair_count >= SubmissionDetectionRun.pair_count,
+                )
+                .values(status=DetectionRunStatus.COMPLETED, completed_at=get_paris_time(), lock_key=None)
+            )
+            self.session.commit()
+            return result.rowcount > 0
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to count detection run pairs: {str(e)}")
+
+    def cancel(self, run_id: UUID) -> bool:
+        """Cancel a detection run if it is still running, releasing its lock, returning whether it was"""
+        try:
+            result = self.session.execute(
+                update(SubmissionDetectionRun)
+                .where(SubmissionDetectionRun.id == run_id, SubmissionDetectionRun.status == DetectionRunStatus.RUNNING)
+                .values(status=DetectionRunStatus.CANCELLED, cancelled_at=get_paris_time(), lock_key=None)
+            )
+            self.session.commit()
+            return result.rowcount > 0
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to cancel detection run: {str(e)}")
+
+    def delete_by_ids(self, run_ids: List[UUID]) -> int:
+        """Delete the given detection runs, returning their number"""
+        if not run_ids:
+            return 0
+        try:
+            result = self.session.execute(delete(SubmissionDetectionRun).where(SubmissionDetectionRun.id.in_(run_ids)))
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete detection runs: {str(e)}")
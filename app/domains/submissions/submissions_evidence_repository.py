@@ -0,0 +1,51 @@
+from typing import List
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionEvidence
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionEvidenceRepository:
+    """Repository for the comparisons with external sources attached to the submissions as evidence"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, evidence_data: dict) -> SubmissionEvidence:
+        """Create a new evidence record"""
+        try:
+            evidence = SubmissionEvidence(**evidence_data)
+            self.session.add(evidence)
+            self.session.commit()
+            self.session.refresh(evidence)
+            return evidence
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create evidence: {str(e)}")
+
+    def get_by_submission_id(self, submission_id: UUID) -> List[SubmissionEvidence]:
+        """Get the evidence attached to a submission, most recent first"""
+        try:
+            statement = (
+                select(SubmissionEvidence)
+                .where(SubmissionEvidence.submission_id == submission_id)
+                .order_by(SubmissionEvidence.created_at.desc())
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get evidence: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the evidence attached to a submission, returning its number"""
+        try:
+            statement = select(SubmissionEvidence).where(SubmissionEvidence.submission_id == submission_id)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete evidence: {str(e)}")
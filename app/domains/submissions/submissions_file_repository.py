@@ -0,0 +1,52 @@
+from typing import List
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionFile
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionFileRepository:
+    """Repository for the files extracted from the uploaded archives of the submissions"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create_many(self, files_data: List[dict]) -> List[SubmissionFile]:
+        """Create the file records of a submission at once"""
+        try:
+            files = [SubmissionFile(**file_data) for file_data in files_data]
+            self.session.add_all(files)
+            self.session.commit()
+            for file in files:
+                self.session.refresh(file)
+            return files
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create submission files: {str(e)}")
+
+    def get_by_submission_id(self, submission_id: UUID) -> List[SubmissionFile]:
+        """Get the files of a submission, by path"""
+        try:
+            statement = (
+                select(SubmissionFile)
+                .where(SubmissionFile.submission_id == submission_id)
+                .order_by(SubmissionFile.path)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submission files: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the file records of a submission, returning their number"""
+        try:
+            statement = select(SubmissionFile).where(SubmissionFile.submission_id == submission_id)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete submission files: {str(e)}")
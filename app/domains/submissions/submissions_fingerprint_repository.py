@@ -0,0 +1,79 @@
+from typing import Collection, List
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionFingerprint
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionFingerprintRepository:
+    """Repository for the fingerprints of the files of the submissions, the inverted index of the code search"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def replace_for_submission(self, submission_id: UUID, entries: List[dict]) -> int:
+        """Replace the fingerprints of a submission, analyzed again, returning their number"""
+        try:
+            statement = select(SubmissionFingerprint).where(SubmissionFingerprint.submission_id == submission_id)
+            for record in self.session.exec(statement).all():
+                self.session.delete(record)
+            self.session.add_all(SubmissionFingerprint(submission_id=submission_id, **entry) for entry in entries)
+            self.session.commit()
+            return len(entries)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to index submission fingerprints: {str(e)}")
+
+    def get_by_submission_id(self, submission_id: UUID) -> List[SubmissionFingerprint]:
+        """Get the fingerprints of a submission, file by file in the order of their tokens"""
+        try:
+            statement = (
+                select(SubmissionFingerprint)
+                .where(SubmissionFingerprint.submission_id == submission_id)
+                .order_by(SubmissionFingerprint.file_path, SubmissionFingerprint.position)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submission fingerprints: {str(e)}")
+
+    def get_by_hashes(self, hashes: Collection[str], submission_ids: Collection[UUID]) -> List[SubmissionFingerprint]:
+        """Get the fingerprints of the given submissions having one of the hashes"""
+        if not hashes or not submission_ids:
+            return []
+        try:
+            statement = select(SubmissionFingerprint).where(
+                SubmissionFingerprint.hash.in_(list(hashes)),
+                SubmissionFingerprint.submission_id.in_(list(submission_ids)),
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submission fingerprints: {str(e)}")
+
+    def get_indexed_submission_ids(self, submission_ids: Collection[UUID]) -> List[UUID]:
+        """Get those of the given submissions having fingerprints in the index"""
+        if not submission_ids:
+            return []
+        try:
+            statement = (
+                select(SubmissionFingerprint.submission_id)
+                .where(SubmissionFingerprint.submission_id.in_(list(submission_ids)))
+                .distinct()
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get indexed submissions: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the fingerprints of a submission, returning their number"""
+        try:
+            statement = select(SubmissionFingerprint).where(SubmissionFingerprint.submission_id == submission_id)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete submission fingerprints: {str(e)}")
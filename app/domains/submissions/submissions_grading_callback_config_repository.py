@@ -0,0 +1,66 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionGradingCallbackConfig
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionGradingCallbackConfigRepository:
+    """Repository for the grading callbacks of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(
+        self, project_uuid: UUID, project_step_uuid: UUID
+    ) -> Optional[SubmissionGradingCallbackConfig]:
+        """Get the grading callback of a project step"""
+        try:
+            statement = select(SubmissionGradingCallbackConfig).where(
+                SubmissionGradingCallbackConfig.project_uuid == project_uuid,
+                SubmissionGradingCallbackConfig.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get grading callback: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, config_data: dict) -> SubmissionGradingCallbackConfig:
+        """Create or replace the grading callback of a project step"""
+        try:
+            config = self.get_by_project_step(project_uuid, project_step_uuid)
+            if config:
+                for field, value in config_data.items():
+                    setattr(config, field, value)
+                config.updated_at = datetime.utcnow()
+            else:
+                config = SubmissionGradingCallbackConfig(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **config_data
+                )
+
+            self.session.add(config)
+            self.session.commit()
+            self.session.refresh(config)
+            return config
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save grading callback: {str(e)}")
+
+    def delete(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the grading callback of a project step, returning whether it existed"""
+        try:
+            config = self.get_by_project_step(project_uuid, project_step_uuid)
+            if not config:
+                return False
+            self.session.delete(config)
+            self.session.commit()
+            return True
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete grading callback: {str(e)}")
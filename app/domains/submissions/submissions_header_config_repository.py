@@ -0,0 +1,49 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionHeaderConfig
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionHeaderConfigRepository:
+    """Repository for the header stripping configuration of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> Optional[SubmissionHeaderConfig]:
+        """Get the header configuration of a project step"""
+        try:
+            statement = select(SubmissionHeaderConfig).where(
+                SubmissionHeaderConfig.project_uuid == project_uuid,
+                SubmissionHeaderConfig.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get header configuration: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, config_data: dict) -> SubmissionHeaderConfig:
+        """Create or replace the header configuration of a project step"""
+        try:
+            config = self.get_by_project_step(project_uuid, project_step_uuid)
+            if config:
+                for field, value in config_data.items():
+                    setattr(config, field, value)
+                config.updated_at = datetime.utcnow()
+            else:
+                config = SubmissionHeaderConfig(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **config_data
+                )
+
+            self.session.add(config)
+            self.session.commit()
+            self.session.refresh(config)
+            return config
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save header configuration: {str(e)}")
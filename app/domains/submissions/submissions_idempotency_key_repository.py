@@ -0,0 +1,85 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlalchemy.exc import IntegrityError
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionIdempotencyKey
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionIdempotencyKeyRepository:
+    """Repository for the idempotency keys of the creation requests"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_active(self, client_scope: str, key: str, now: datetime) -> Optional[SubmissionIdempotencyKey]:
+        """Get the unexpired idempotency key record of a client, as last committed"""
+        try:
+            statement = select(SubmissionIdempotencyKey).where(
+                SubmissionIdempotencyKey.client_scope == client_scope,
+                SubmissionIdempotencyKey.key == key,
+                SubmissionIdempotencyKey.expires_at >= now,
+            )
+            return self.session.exec(statement.execution_options(populate_existing=True)).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get idempotency key: {str(e)}")
+
+    def reserve(self, key_data: dict) -> Optional[SubmissionIdempotencyKey]:
+        """Create an idempotency key record, None if the client already sent the key meanwhile"""
+        try:
+            record = SubmissionIdempotencyKey(**key_data)
+            self.session.add(record)
+            self.session.commit()
+            self.session.refresh(record)
+            return record
+        except IntegrityError:
+            self.session.rollback()
+            return None
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create idempotency key: {str(e)}")
+
+    def complete(self, record_id: UUID, status_code: int, response_body: dict) -> SubmissionIdempotencyKey:
+        """Store the response of the request of an idempotency key"""
+        try:
+            record = self.session.get(SubmissionIdempotencyKey, record_id)
+            if not record:
+                raise NotFoundException(f"Idempotency key with ID {record_id} not found")
+            record.status_code = status_code
+            record.response_body = response_body
+            self.session.add(record)
+            self.session.commit()
+            self.session.refresh(record)
+            return record
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to store idempotent response: {str(e)}")
+
+    def delete(self, record_id: UUID) -> None:
+        """Delete an idempotency key record, so that the key can be sent again"""
+        try:
+            record = self.session.get(SubmissionIdempotencyKey, record_id)
+            if record:
+                self.session.delete(record)
+                self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete idempotency key: {str(e)}")
+
+    def delete_expired(self, now: datetime) -> int:
+        """Delete the expired idempotency key records, returning their number"""
+        try:
+            statement = select(SubmissionIdempotencyKey).where(SubmissionIdempotencyKey.expires_at < now)
+            records = list(self.session.exec(statement).all())
+            for record in records:
+                self.session.delete(record)
+            self.session.commit()
+            return len(records)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete expired idempotency keys: {str(e)}")
@@ -0,0 +1,49 @@
+from datetime import datetime
+from typing import Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionIgnoreRulesConfig
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionIgnoreRulesConfigRepository:
+    """Repository for the ignore rules configuration of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> Optional[SubmissionIgnoreRulesConfig]:
+        """Get the ignore rules configuration of a project step"""
+        try:
+            statement = select(SubmissionIgnoreRulesConfig).where(
+                SubmissionIgnoreRulesConfig.project_uuid == project_uuid,
+                SubmissionIgnoreRulesConfig.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get ignore rules configuration: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, config_data: dict) -> SubmissionIgnoreRulesConfig:
+        """Create or replace the ignore rules configuration of a project step"""
+        try:
+            config = self.get_by_project_step(project_uuid, project_step_uuid)
+            if config:
+                for field, value in config_data.items():
+                    setattr(config, field, value)
+                config.updated_at = datetime.utcnow()
+            else:
+                config = SubmissionIgnoreRulesConfig(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **config_data
+                )
+
+            self.session.add(config)
+            self.session.commit()
+            self.session.refresh(config)
+            return config
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save ignore rules configuration: {str(e)}")
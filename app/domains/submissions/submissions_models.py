@@ -5,7 +5,10 @@ from uuid import UUID, uuid4
 
 import pytz
 from pydantic import field_validator
-from sqlmodel import JSON, Column, Field, SQLModel
+from sqlalchemy import UniqueConstraint
+from sqlmodel import JSON, Column, Field, LargeBinary, SQLModel
+
+from app.shared.tenancy import DEFAULT_TENANT_ID
 
 # Paris timezone
 PARIS_TZ = pytz.timezone("Europe/Paris")
@@ -24,6 +27,7 @@ class SubmissionStatus(str, Enum):
     COMPLETED = "completed"
     FAILED = "failed"
     REJECTED = "rejected"
+    QUARANTINED = "quarantined"
 
 
 class LinkType(str, Enum):
@@ -41,6 +45,130 @@ class SimilarityStatus(str, Enum):
     PROCESSING = "processing"
     COMPLETED = "completed"
     FAILED = "failed"
+    TIMED_OUT = "timed_out"  # Stopped at the comparison timeout, its elapsed time recorded
+
+
+class ProcessingStatus(str, Enum):
+    """Enumeration for the stages a submission is processed through once received"""
+
+    RECEIVED = "received"
+    EXTRACTING = "extracting"  # Its files being fetched
+    TOKENIZING = "tokenizing"  # Its files being tokenized and analyzed
+    ANALYZED = "analyzed"  # Ready to be compared
+    FAILED = "failed"
+    PENDING_RETRY = "pending_retry"  # Interrupted by a shutdown or failed for a transient cause, processed again
+
+
+class AnalysisPriority(str, Enum):
+    """Enumeration for the priority of the analysis of submissions, the higher analyzed first"""
+
+    LOW = "low"
+    NORMAL = "normal"
+    HIGH = "high"
+    URGENT = "urgent"
+
+
+class DetectionRunStatus(str, Enum):
+    """Enumeration for the status of a detection run"""
+
+    RUNNING = "running"  # Pairs being compared
+    COMPLETED = "completed"  # Every pair compared
+    CANCELLED = "cancelled"  # Stopped before the end, the pairs compared being kept
+    FAILED = "failed"  # Abandoned by the instance running it, and not resumed by another
+
+
+class UploadSessionStatus(str, Enum):
+    """Enumeration for resumable upload status"""
+
+    PENDING = "pending"  # Receiving the chunks
+    PROCESSING = "processing"  # Being finalized into a submission
+    COMPLETED = "completed"
+    FAILED = "failed"
+    EXPIRED = "expired"
+
+
+class WebhookEvent(str, Enum):
+    """Enumeration for the events notified to the webhooks"""
+
+    SUBMISSION_ANALYZED = "submission.analyzed"
+    SUBMISSION_FAILED = "submission.failed"  # Failed with no retry left
+    DETECTION_RUN_COMPLETED = "detection_run.completed"
+    SUBMISSION_DUPLICATE = "submission.duplicate"  # Same files as the submission of another group
+
+
+class WebhookDeliveryStatus(str, Enum):
+    """Enumeration for the status of the delivery of an event to a webhook"""
+
+    PENDING = "pending"  # Being sent, or waiting for its next attempt
+    DELIVERED = "delivered"
+    FAILED = "failed"  # Every attempt failed
+
+
+class GradingCallbackStatus(str, Enum):
+    """Enumeration for the status of the delivery of the results of a detection run to the grading service"""
+
+    PENDING = "pending"  # Being sent
+    DELIVERED = "delivered"  # Answered with a 2xx
+    FAILED = "failed"  # To be re-delivered explicitly
+
+
+class AutoRunStatus(str, Enum):
+    """Enumeration for the status of the detection run scheduled for a project step"""
+
+    SCHEDULED = "scheduled"  # Waiting for its time
+    STARTED = "started"  # Its run was started
+    FAILED = "failed"  # Its run could not be started
+
+
+class RetentionAnchor(str, Enum):
+    """Enumeration for the dates the retention periods of a project step run from"""
+
+    DEADLINE = "deadline"  # Grading deadline of the step
+    DATE = "date"  # Fixed date of the policy, such as the end of the course
+    UPLOAD = "upload"  # Upload of each submission, creation of each result
+
+
+class CorpusKind(str, Enum):
+    """Enumeration for the kinds of corpora the submissions are matched with"""
+
+    ARCHIVE = "archive"  # Past submissions, referenced by project steps
+    REFERENCE = "reference"  # External reference set of source files, shared across assignments
+
+
+class ProcessingStage(str, Enum):
+    """Enumeration for the stages of the processing log of a submission"""
+
+    UPLOAD = "upload"  # Its archive received, extracted and scanned
+    EXTRACTION = "extraction"  # Its files fetched and selected for the analysis
+    LANGUAGE_DETECTION = "language_detection"  # Per file
+    TOKENIZATION = "tokenization"  # Per file
+    FINGERPRINTING = "fingerprinting"  # Its fingerprints indexed for the code search
+
+
+class ProcessingOutcome(str, Enum):
+    """Enumeration for the outcome of a stage of the processing log of a submission"""
+
+    OK = "ok"
+    WARNING = "warning"  # Completed with warnings
+    SKIPPED = "skipped"  # The file left out of the analysis
+    FAILED = "failed"
+
+
+class ProcessingWarningCode(str, Enum):
+    """Enumeration for the stable codes of the warnings of the processing log, rendered by the frontend"""
+
+    ARCHIVE_ENTRY_SKIPPED = "archive_entry_skipped"  # For its kind or path, or disallowed for the step
+    BINARY_FILE_SKIPPED = "binary_file_skipped"
+    SYMBOLIC_LINK_SKIPPED = "symbolic_link_skipped"
+    ENCODING_REPLACED = "encoding_replaced"  # Invalid byte sequences replaced
+    CASE_COLLISION = "case_collision"  # Paths differing only by case
+    DUPLICATE_UPLOAD = "duplicate_upload"  # Same files as the submission of another group
+    GENERATED_FILE_EXCLUDED = "generated_file_excluded"
+    UNREADABLE_FILE = "unreadable_file"
+    INVALID_NOTEBOOK = "invalid_notebook"
+    LANGUAGE_FALLBACK = "language_fallback"  # Tokenized with a content based language, the detected one failing
+    TOKENIZATION_TIMEOUT = "tokenization_timeout"
+    PATHS_IGNORED = "paths_ignored"  # Left out by the ignore rules of the step, counted with a sample
 
 
 class SubmissionBase(SQLModel):
@@ -84,11 +212,36 @@ class Submission(SubmissionBase, table=True):
     __tablename__ = "submission"
 
     id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    tenant_id: str = Field(
+        default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant of the project step of the submission"
+    )
     upload_date_time: datetime = Field(default_factory=get_paris_time, description="When the submission was uploaded")
     status: SubmissionStatus = Field(default=SubmissionStatus.PENDING, description="Current status of the submission")
     created_at: datetime = Field(default_factory=get_paris_time, description="When the record was created")
     updated_at: Optional[datetime] = Field(default=None, description="When the record was last updated")
 
+    # Metadata corrected after the upload, the changes being recorded in the audit trail of the submission
+    display_name: Optional[str] = Field(default=None, max_length=200, description="Name the submission is shown with")
+    tags: Optional[list] = Field(default=None, sa_column=Column(JSON), description="Free-form tags of the submission")
+
+    # Soft deletion: the deleted submissions are neither listed nor compared, until restored
+    deleted_at: Optional[datetime] = Field(default=None, index=True, description="When the submission was deleted")
+
+    # Malware scan of the uploaded files: a submission found infected (or left unscanned when the scan fails closed)
+    # is quarantined, neither downloadable nor compared, until an administrator releases or purges it
+    malware_scan: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Scanner, verdict and infected files of the malware scan"
+    )
+    quarantined_at: Optional[datetime] = Field(default=None, description="When the submission was quarantined")
+
+    # Retention: a submission with an open integrity case is never purged, and the stored files of an expired one
+    # are deleted, its metadata (hashes, scores) being kept unless its project step deletes it fully
+    integrity_case_open: bool = Field(default=False, description="Whether an integrity case is open on the submission")
+    files_purged_at: Optional[datetime] = Field(default=None, description="When its stored files were purged")
+
+    # Version among the submissions of the group for the step, never renumbered when a version is deleted
+    version: int = Field(default=1, ge=1, description="Version of the submission for its project, group and step")
+
     # Metadata fields
     ip_address: Optional[str] = Field(default=None, max_length=45, description="IP address of the submitter")
     user_agent: Optional[str] = Field(default=None, max_length=500, description="User agent of the submitter")
@@ -96,6 +249,73 @@ class Submission(SubmissionBase, table=True):
     # Rule validation results
     rule_results: Optional[str] = Field(default=None, description="JSON string containing rule validation results")
 
+    # Language detection results
+    language_confidence: Optional[float] = Field(
+        default=None, ge=0.0, le=1.0, description="Lowest language detection confidence among the analyzed files"
+    )
+    language_detection: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Language detection summary and low confidence files"
+    )
+    language: Optional[str] = Field(
+        default=None, index=True, description="Language of most of the analyzed files, to filter and sort by"
+    )
+
+    # Code metrics per file and for the whole submission
+    code_metrics: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Lines, comment ratio, functions and complexity metrics"
+    )
+
+    # Content hashes of the analyzed files, with the tokenizer version: the next version reuses the unchanged ones
+    analyzed_files: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Content hashes of the analyzed files by relative path"
+    )
+
+    # Aggregate content hash of the uploaded files, and the submission of another group it is byte-identical to
+    content_hash: Optional[str] = Field(
+        default=None, max_length=64, index=True, description="SHA-256 of the sorted content hashes of its files"
+    )
+    duplicate_of: Optional[UUID] = Field(
+        default=None, description="Submission of another group of the step with the same files, found at the upload"
+    )
+
+    # Git source of the submissions created from a repository, the resolved commit making them reproducible
+    git_repository_url: Optional[str] = Field(default=None, description="URL of the repository the tree comes from")
+    git_ref: Optional[str] = Field(default=None, description="Requested branch, tag or commit")
+    git_commit_sha: Optional[str] = Field(default=None, max_length=40, description="Commit the ref resolved to")
+    git_subdirectory: Optional[str] = Field(default=None, description="Subdirectory of the repository ingested")
+
+    # Stage of the processing of the submission, each transition being timestamped, and why it failed
+    processing_status: ProcessingStatus = Field(
+        default=ProcessingStatus.RECEIVED, index=True, description="Stage of the processing of the submission"
+    )
+    processing_transitions: list = Field(
+        default_factory=list, sa_column=Column(JSON), description="Stages the submission went through, with their time"
+    )
+    processed_file_count: int = Field(default=0, ge=0, description="Number of files processed so far")
+    total_file_count: Optional[int] = Field(default=None, ge=0, description="Number of files to process, once known")
+    processing_error: Optional[str] = Field(default=None, description="Reason the processing failed")
+    processing_failed_file: Optional[str] = Field(default=None, description="File the processing failed on, if any")
+
+    # Failed attempts of the processing, those failing for a transient cause being retried later
+    processing_attempt_count: int = Field(default=0, ge=0, description="Failed attempts of the current processing")
+    processing_attempts: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Failed attempts of the processing, with their time and error"
+    )
+    processing_retry_at: Optional[datetime] = Field(default=None, description="When the processing is retried")
+
+    # Warnings raised while processing the submission, such as the skipped entries of its archive
+    processing_log: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Warnings raised while processing the submission"
+    )
+
+    # Generated or minified files compared anyway
+    force_include_files: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Paths of generated files to compare anyway"
+    )
+
+    # Priority of its analysis, that of its project step (from its deadline) applying if None
+    analysis_priority: Optional[AnalysisPriority] = Field(default=None, description="Priority of its analysis")
+
 
 class SubmissionSimilarity(SQLModel, table=True):
     """Database model for storing similarity detection results between submissions"""
@@ -126,6 +346,9 @@ class SubmissionSimilarity(SQLModel, table=True):
     type_sequence_similarity: float = Field(default=0.0, description="Type sequence similarity score (0.0 to 1.0)")
     flow_similarity: float = Field(default=0.0, description="Flow similarity score (0.0 to 1.0)")
     operation_similarity: float = Field(default=0.0, description="Operation similarity score (0.0 to 1.0)")
+    max_function_similarity: Optional[float] = Field(
+        default=None, description="Similarity of the most similar functions of both submissions (0.0 to 1.0)"
+    )
 
     # Detection metadata
     detection_algorithm: str = Field(default="ast_similarity_v2", description="Algorithm used for detection")
@@ -152,3 +375,794 @@ class SubmissionSimilarity(SQLModel, table=True):
 
     # Error handling
     error_message: Optional[str] = Field(default=None, description="Error message if similarity detection failed")
+
+    # Key of the comparison, its results being reused by the runs comparing the same contents with the same options
+    cache_key: Optional[str] = Field(
+        default=None, max_length=64, index=True, description="Key of the comparison cache, None if not cacheable"
+    )
+
+
+class SubmissionBaseline(SQLModel, table=True):
+    """Database model for the starter code of a project step, subtracted from the similarity of its submissions"""
+
+    __tablename__ = "submission_baseline"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    # Source of the starter code
+    link: str = Field(description="Link to the S3 archive, GitHub, or GitLab repository of the starter code")
+    link_type: Optional[LinkType] = Field(default=None, description="Type of the baseline link")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Optional description")
+    file_count: int = Field(default=0, ge=0, description="Number of fingerprinted files")
+    token_count: int = Field(default=0, ge=0, description="Number of fingerprinted tokens")
+
+    # Fingerprints of the starter code, computed once so that the baseline is not fetched again for each run
+    fingerprints: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Hashes of the runs of consecutive tokens of the baseline"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the baseline was created")
+
+
+class SubmissionAllowedSnippet(SQLModel, table=True):
+    """Database model for code every student of a project step may copy, its fragments not counting as similarity"""
+
+    __tablename__ = "submission_allowed_snippet"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    # Code of the snippet, tokenized with its language
+    language: str = Field(description="Language the snippet is tokenized with")
+    content: str = Field(description="Code of the snippet")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Optional description")
+    token_count: int = Field(default=0, ge=0, description="Number of fingerprinted tokens")
+
+    # Fingerprints of the snippet, computed again when its code or language is updated
+    fingerprints: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Hashes of the runs of consecutive tokens of the snippet"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the snippet was created")
+    updated_at: datetime = Field(default_factory=get_paris_time, description="When the snippet was last updated")
+
+
+class SubmissionAssignment(SQLModel, table=True):
+    """Database model for the tenant of a project step, the first tenant acting on the step owning it"""
+
+    __tablename__ = "submission_assignment"
+
+    project_step_uuid: UUID = Field(primary_key=True, description="UUID of the project step")
+    tenant_id: str = Field(max_length=64, index=True, description="Tenant the project step belongs to")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the tenant acted on the step first")
+
+
+class SubmissionHeaderConfig(SQLModel, table=True):
+    """Database model for the license and file header stripping configuration of a project step"""
+
+    __tablename__ = "submission_header_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    enabled: bool = Field(default=True, description="Whether the headers are stripped before tokenization")
+    patterns: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Regexes of the headers, the known license patterns if None"
+    )
+    templates: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Header templates mandated for the step, with placeholders"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionFileFilterConfig(SQLModel, table=True):
+    """Database model for the files allowed in the uploaded submissions of a project step"""
+
+    __tablename__ = "submission_file_filter_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    mode: str = Field(default="lenient", description="Whether disallowed files reject the upload or are skipped")
+    allowed_extensions: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Extensions of the allowed files, all of them if None"
+    )
+    denied_extensions: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Extensions of the files never allowed"
+    )
+    allowed_patterns: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Glob patterns of the allowed files, such as Makefile"
+    )
+    denied_patterns: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Glob patterns never allowed, such as node_modules"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionIgnoreRulesConfig(SQLModel, table=True):
+    """Database model for the paths left out of the submissions of a project step, in gitignore syntax"""
+
+    __tablename__ = "submission_ignore_rules_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    patterns: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Ignore rules of the step, the built-in ones if None"
+    )
+    honor_gitignore: bool = Field(default=False, description="Whether the .gitignore files of a submission apply too")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionUploadLimitsConfig(SQLModel, table=True):
+    """Database model for the limits of the uploads of a project step, overriding the configured defaults"""
+
+    __tablename__ = "submission_upload_limits_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    # Limits of the step, the configured default applying to those left None
+    max_upload_bytes: Optional[int] = Field(default=None, gt=0, description="Size of an uploaded file")
+    max_extracted_bytes: Optional[int] = Field(default=None, gt=0, description="Extracted size of an upload")
+    max_file_count: Optional[int] = Field(default=None, gt=0, description="Number of files of an upload")
+    max_file_bytes: Optional[int] = Field(default=None, gt=0, description="Size of a single file of an upload")
+    reject_binary_files: Optional[bool] = Field(
+        default=None, description="Whether an upload holding a binary file is rejected rather than analyzed without it"
+    )
+    strict_paths: Optional[bool] = Field(
+        default=None, description="Whether an upload with duplicate or case-colliding paths is rejected, not flagged"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionGradingCallbackConfig(SQLModel, table=True):
+    """Database model for the callback of the grading service the results of the runs of a project step are posted to"""
+
+    __tablename__ = "submission_grading_callback_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    url: str = Field(max_length=2048, description="URL the results of the completed runs are posted to")
+    secret: str = Field(max_length=255, description="Secret shared with the grading service, signing the deliveries")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionDetectionConfig(SQLModel, table=True):
+    """Database model for the similarity flagging defaults of a project step, overridable per detection run"""
+
+    __tablename__ = "submission_detection_config"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    flag_threshold: float = Field(default=0.7, ge=0.0, le=1.0, description="Similarity flagging a pair as suspicious")
+    min_token_count: int = Field(default=50, ge=0, description="Compared tokens under which a submission is too short")
+    originality_feedback_enabled: bool = Field(
+        default=False, description="Whether the students see the anonymized originality feedback of their submissions"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the configuration was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the configuration was last updated")
+
+
+class SubmissionAnalysisProfile(SQLModel, table=True):
+    """Database model for a named bundle of detection options, referenced by the detection runs"""
+
+    __tablename__ = "submission_analysis_profile"
+    __table_args__ = (UniqueConstraint("tenant_id", "name", name="uq_submission_analysis_profile_tenant_name"),)
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    tenant_id: str = Field(default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant of the profile")
+    name: str = Field(max_length=100, index=True, description="Name the runs of the tenant reference the profile by")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Optional description")
+
+    # Options of the comparisons, and the flagging of the runs overriding the configuration of their project step
+    detection_options: dict = Field(
+        default_factory=dict, sa_column=Column(JSON), description="Options the token streams are compared with"
+    )
+    flag_threshold: Optional[float] = Field(
+        default=None, ge=0.0, le=1.0, description="Similarity flagging a pair, that of the project step if None"
+    )
+    min_token_count: Optional[int] = Field(
+        default=None, ge=0, description="Compared tokens under which a submission is too short, the step's if None"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the profile was created")
+    updated_at: datetime = Field(default_factory=get_paris_time, description="When the profile was last updated")
+
+
+class SubmissionDetectionRun(SQLModel, table=True):
+    """Database model for a detection run comparing pairwise a set of submissions of a project step"""
+
+    __tablename__ = "submission_detection_run"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    tenant_id: str = Field(
+        default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant of the project step of the run"
+    )
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    # Submissions of the run, the matrix being read from their similarity records
+    submission_ids: list = Field(
+        default_factory=list, sa_column=Column(JSON), description="IDs of the submissions compared by the run"
+    )
+    pair_count: int = Field(default=0, ge=0, description="Number of distinct pairs of the run")
+    scheduled_pair_count: int = Field(default=0, ge=0, description="Number of pairs not compared before the run")
+    cached_pair_count: int = Field(
+        default=0, ge=0, description="Number of pairs whose comparison was served from the comparison cache"
+    )
+    include_corpus: bool = Field(default=False, description="Whether the submissions are matched with the corpora")
+    corpus_item_count: int = Field(default=0, ge=0, description="Number of archived submissions matched with")
+    include_references: bool = Field(default=False, description="Whether the submissions are matched with references")
+    reference_item_count: int = Field(default=0, ge=0, description="Number of files of the reference sets matched with")
+    scheduled: bool = Field(default=False, description="Whether the run was started by the schedule of its step")
+
+    # Teams of the submissions, whose pairs are marked and by default neither flagged nor clustered
+    teams: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Team of each submission of a team, by submission ID"
+    )
+    include_same_team: bool = Field(default=False, description="Whether the pairs of teammates are flagged")
+
+    # Analysis profile of the run, and the options it resolved to with the overrides of the run, kept as they were
+    # when the run started whatever the later changes of the profile
+    analysis_profile: Optional[str] = Field(default=None, max_length=100, description="Name of the analysis profile")
+    effective_options: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Detection options and flagging the run was started with"
+    )
+    exclude_paths: list = Field(
+        default_factory=list, sa_column=Column(JSON), description="Paths left out of the comparisons (gitignore syntax)"
+    )
+
+    # Environment the pairs of the run were compared in (tokenizers, algorithms, options), and the seed of the
+    # samples of pairs its verifications execute again
+    fingerprint: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Versions of the tokenizers and algorithms, options hash"
+    )
+    seed: Optional[int] = Field(default=None, ge=0, description="Seed of the pairs sampled by the verifications")
+
+    # Timeouts of the stages the pairs of the run were compared with, each file tokenized and pair compared
+    timeouts: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Stage timeouts in seconds, None for no timeout"
+    )
+
+    # Progress of the run, its pairs being counted as the workers compare them
+    status: DetectionRunStatus = Field(default=DetectionRunStatus.RUNNING, description="Status of the run")
+    completed_pair_count: int = Field(default=0, ge=0, description="Number of pairs of the run compared so far")
+
+    # Lock of the run while running, one run at a time per project step and analysis profile, held by the instance
+    # comparing its pairs as long as it renews its heartbeat: a run whose heartbeat stops is resumed or failed by
+    # another instance
+    lock_key: Optional[str] = Field(
+        default=None, max_length=200, unique=True, description="Project step and profile of the run while running"
+    )
+    claimed_by: Optional[str] = Field(default=None, max_length=200, description="Instance running the run")
+    heartbeat_at: Optional[datetime] = Field(default=None, description="When the instance last renewed its lock")
+    error_message: Optional[str] = Field(default=None, description="Why the run failed")
+
+    # Tokenizations of the files of the compared pairs served from the token cache, or tokenized and cached
+    token_cache_hits: int = Field(default=0, ge=0, description="Number of files whose tokens were cached")
+    token_cache_misses: int = Field(default=0, ge=0, description="Number of files tokenized for the run")
+
+    # Score distribution of the run, stored once all of its pairs are compared
+    summary: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Histogram and statistics of the scores of the run"
+    )
+
+    # Delivery of the results of the completed run to the grading callback of its step, None without one
+    grading_callback_status: Optional[GradingCallbackStatus] = Field(
+        default=None, description="Status of the delivery of the results to the grading service"
+    )
+    grading_callback_attempts: list = Field(
+        default_factory=list, sa_column=Column(JSON), description="Record of each attempt to deliver the results"
+    )
+    grading_callback_delivered_at: Optional[datetime] = Field(
+        default=None, description="When the grading service answered the results with a 2xx"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the run was started")
+    completed_at: Optional[datetime] = Field(default=None, description="When the last pair of the run was compared")
+    cancelled_at: Optional[datetime] = Field(default=None, description="When the run was cancelled")
+    failed_at: Optional[datetime] = Field(default=None, description="When the run was failed, abandoned")
+    rescored_at: Optional[datetime] = Field(
+        default=None, description="When the pairs of the run were last compared again (updated allowed snippets)"
+    )
+
+
+class SubmissionCorpus(SQLModel, table=True):
+    """Database model for an archived collection of past submissions, the reference corpus of project steps"""
+
+    __tablename__ = "submission_corpus"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    tenant_id: str = Field(default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant of the corpus")
+    label: str = Field(max_length=255, description="Label of the collection, prefixing those of its items")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Optional description")
+    kind: CorpusKind = Field(default=CorpusKind.ARCHIVE, description="Archived submissions, or a reference set")
+    license_note: Optional[str] = Field(
+        default=None, max_length=1000, description="License of the files of a reference set, reported with its matches"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the corpus was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the files of a reference set were refreshed")
+
+
+class SubmissionCorpusItem(SQLModel, table=True):
+    """Database model for an archived submission of a corpus, stored for matching only and never graded"""
+
+    __tablename__ = "submission_corpus_item"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    corpus_id: UUID = Field(foreign_key="submission_corpus.id", description="ID of the corpus of the item")
+
+    # Source of the archived submission
+    label: str = Field(max_length=255, description="Label reported with the matches of the item, e.g. 2023/alice")
+    link: str = Field(description="Link to the S3 archive, GitHub, or GitLab repository of the archived submission")
+    link_type: Optional[LinkType] = Field(default=None, description="Type of the item link")
+    path: Optional[str] = Field(default=None, description="Path of the file of a reference set within its archive")
+    gradable: bool = Field(default=False, description="Archived submissions are never graded")
+    file_count: int = Field(default=0, ge=0, description="Number of fingerprinted files")
+    token_count: int = Field(default=0, ge=0, description="Number of fingerprinted tokens")
+
+    # Fingerprints of the archived submission, computed once so that it is not tokenized again for each run
+    fingerprints: Optional[list] = Field(
+        default=None, sa_column=Column(JSON), description="Hashes of the runs of consecutive tokens of the item"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the item was archived")
+
+
+class SubmissionStepCorpus(SQLModel, table=True):
+    """Database model for the reference of a project step to a corpus its submissions are matched with"""
+
+    __tablename__ = "submission_step_corpus"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    corpus_id: UUID = Field(foreign_key="submission_corpus.id", description="ID of the referenced corpus")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the corpus was referenced")
+
+
+class SubmissionCorpusMatch(SQLModel, table=True):
+    """Database model for the match of a submission with an archived submission of a corpus"""
+
+    __tablename__ = "submission_corpus_match"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", description="ID of the matched submission")
+    corpus_item_id: UUID = Field(foreign_key="submission_corpus_item.id", description="ID of the archived submission")
+
+    # Share of the fingerprints of the submission found in the item, and Jaccard index of both fingerprint sets
+    overall_similarity: float = Field(default=0.0, description="Containment of the submission in the item")
+    jaccard_similarity: float = Field(default=0.0, description="Jaccard similarity of the fingerprints")
+    shared_fingerprint_count: int = Field(default=0, ge=0, description="Number of fingerprints shared with the item")
+
+    status: SimilarityStatus = Field(default=SimilarityStatus.PENDING, description="Status of the matching")
+    error_message: Optional[str] = Field(default=None, description="Error message if the matching failed")
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the match was computed")
+
+
+class SubmissionEvidence(SQLModel, table=True):
+    """Database model for a comparison of a submission with an external source, attached to it as evidence"""
+
+    __tablename__ = "submission_evidence"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", description="ID of the compared submission")
+
+    # Compared source: a fetched URL or pasted code, kept so that the evidence does not depend on the URL
+    source_type: str = Field(description="Type of the external source: url or text")
+    source_url: Optional[str] = Field(default=None, description="URL the source was fetched from")
+    label: Optional[str] = Field(default=None, max_length=255, description="Label of the source, e.g. a forum post")
+    language: Optional[str] = Field(default=None, description="Language the source was tokenized as")
+    content: str = Field(description="Compared source")
+
+    overall_similarity: float = Field(default=0.0, description="Overall similarity with the source")
+    similarity_details: Optional[dict] = Field(
+        default=None, sa_column=Column(JSON), description="Similarity metrics and matched fragments"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the comparison was made")
+
+
+class SubmissionReportJob(SQLModel, table=True):
+    """
+    Database model for the rendering of a PDF report of a comparison, or of its evidence package, polled until it is
+    completed
+    """
+
+    __tablename__ = "submission_report_job"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    similarity_id: UUID = Field(foreign_key="submission_similarity.id", description="ID of the reported comparison")
+    run_id: Optional[UUID] = Field(default=None, description="ID of the detection run of an evidence package")
+    report_format: str = Field(default="pdf", description="Format of the rendered report: pdf or evidence (ZIP)")
+
+    # Rendered report, stored until it is downloaded again
+    content: Optional[bytes] = Field(default=None, sa_column=Column(LargeBinary), description="Rendered report")
+    content_sha256: Optional[str] = Field(
+        default=None, max_length=64, description="SHA-256 of an evidence package, the same for every export of it"
+    )
+
+    # Status and timing
+    status: SimilarityStatus = Field(default=SimilarityStatus.PENDING, description="Status of the rendering")
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the report was requested")
+    updated_at: Optional[datetime] = Field(default=None, description="When the rendering was last updated")
+    processing_time_seconds: Optional[float] = Field(default=None, description="Time taken to render the report")
+
+    # Error handling
+    error_message: Optional[str] = Field(default=None, description="Error message if the rendering failed")
+
+
+class SubmissionFile(SQLModel, table=True):
+    """Database model for a file extracted from the uploaded archive of a submission"""
+
+    __tablename__ = "submission_file"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", description="ID of the submission the file belongs to")
+
+    path: str = Field(description="Path of the file, relative to the root of the archive")
+    size_bytes: int = Field(default=0, ge=0, description="Size of the extracted file in bytes")
+    language: Optional[str] = Field(default=None, description="Detected language, None for binary or unknown files")
+    archive: Optional[str] = Field(default=None, description="Path of the nested archive the file was extracted from")
+    content_hash: Optional[str] = Field(
+        default=None, max_length=64, index=True, description="SHA-256 of the content, the key of its cached tokens"
+    )
+    binary: bool = Field(default=False, description="Whether the file is binary, left out of the analysis")
+    encoding: Optional[str] = Field(default=None, description="Detected encoding of a text file, None for a binary")
+    encoding_error_count: int = Field(
+        default=0, ge=0, description="Invalid byte sequences of the file replaced when it was decoded for the analysis"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the file was extracted")
+
+
+class SubmissionAuditEntry(SQLModel, table=True):
+    """Database model for a change of the metadata of a submission, its audit trail being read per submission"""
+
+    __tablename__ = "submission_audit_entry"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", description="ID of the changed submission")
+
+    action: str = Field(default="metadata_update", description="Kind of change")
+    changes: dict = Field(
+        default_factory=dict, sa_column=Column(JSON), description="Old and new value of each changed field"
+    )
+    actor: Optional[str] = Field(default=None, max_length=200, description="Who made the change, if known")
+    ip_address: Optional[str] = Field(default=None, max_length=45, description="IP address of the change")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the change was made")
+
+
+class SubmissionProcessingEvent(SQLModel, table=True):
+    """
+    Database model for a stage of the processing of a submission (of one of its files), its processing log being read
+    per submission and pruned past the retention period
+    """
+
+    __tablename__ = "submission_processing_event"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", index=True, description="ID of the processed submission")
+
+    stage: ProcessingStage = Field(description="Stage of the processing")
+    file_path: Optional[str] = Field(default=None, description="Relative path of the file, None for the whole stage")
+    started_at: datetime = Field(description="When the stage started")
+    ended_at: datetime = Field(description="When the stage ended")
+    duration_ms: int = Field(default=0, ge=0, description="Duration of the stage in milliseconds")
+    outcome: ProcessingOutcome = Field(default=ProcessingOutcome.OK, description="Outcome of the stage")
+    warnings: list = Field(
+        default_factory=list, sa_column=Column(JSON), description="Warnings of the stage, each with its stable code"
+    )
+    details: dict = Field(default_factory=dict, sa_column=Column(JSON), description="Facts of the stage")
+    error_message: Optional[str] = Field(default=None, description="Why the stage failed, if it did")
+
+    created_at: datetime = Field(default_factory=get_paris_time, index=True, description="When it was recorded")
+
+
+class SubmissionAccessDenial(SQLModel, table=True):
+    """Database model for an access to a submission or a result denied to its caller, read by the administrators"""
+
+    __tablename__ = "submission_access_denial"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Identity of the caller, as in their token
+    tenant_id: str = Field(default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant of the caller")
+    subject: str = Field(index=True, max_length=200, description="Subject of the token of the caller")
+    role: str = Field(max_length=20, description="Role of the caller")
+
+    # Denied action and its target
+    action: str = Field(max_length=100, description="Operation of the service that was denied")
+    resource_type: str = Field(max_length=50, description="Kind of the target resource")
+    resource_id: Optional[str] = Field(default=None, max_length=100, description="ID of the target resource")
+    reason: str = Field(description="Why the access was denied")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the access was denied")
+
+
+class SubmissionBulkUploadJob(SQLModel, table=True):
+    """Database model for a bulk upload, one submission being created per directory of its archive in the background"""
+
+    __tablename__ = "submission_bulk_upload_job"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context, and how the directories of the students are named
+    project_uuid: UUID = Field(description="UUID of the project of the submissions")
+    project_step_uuid: UUID = Field(description="UUID of the project step of the submissions")
+    filename: str = Field(description="Name of the uploaded archive")
+    directory_pattern: str = Field(description="Naming pattern of the directories of the students")
+
+    # Progress, and the result of each directory (created submission or error)
+    entry_count: int = Field(default=0, ge=0, description="Number of directories of the archive")
+    created_count: int = Field(default=0, ge=0, description="Number of submissions created")
+    failed_count: int = Field(default=0, ge=0, description="Number of directories no submission was created for")
+    results: list = Field(default_factory=list, sa_column=Column(JSON), description="Result of each directory")
+
+    # Status and timing
+    status: SimilarityStatus = Field(default=SimilarityStatus.PENDING, description="Status of the bulk upload")
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the bulk upload was requested")
+    updated_at: Optional[datetime] = Field(default=None, description="When the bulk upload was last updated")
+    processing_time_seconds: Optional[float] = Field(default=None, description="Time taken to create the submissions")
+
+    # Error handling
+    error_message: Optional[str] = Field(default=None, description="Error message if the whole bulk upload failed")
+
+
+class SubmissionUploadSession(SQLModel, table=True):
+    """Database model for a resumable upload, received in chunks then finalized into an uploaded submission"""
+
+    __tablename__ = "submission_upload_session"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Metadata of the submission created once the upload is finalized
+    project_uuid: UUID = Field(description="UUID of the project of the submission")
+    group_uuid: UUID = Field(description="UUID of the group of the submission")
+    project_step_uuid: UUID = Field(description="UUID of the project step of the submission")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Description of the submission")
+    submitted_by_uuid: Optional[UUID] = Field(default=None, description="UUID of the submitter")
+    allow_duplicates: bool = Field(default=False, description="Whether the submission may duplicate another one")
+
+    # Uploaded file, and how it is split into chunks
+    filename: str = Field(description="Name of the uploaded file")
+    total_bytes: int = Field(gt=0, description="Size of the whole file in bytes")
+    checksum: str = Field(max_length=64, description="SHA-256 checksum of the whole file, in hexadecimal")
+    chunk_bytes: int = Field(gt=0, description="Size of each chunk in bytes, the last one being smaller")
+    chunk_count: int = Field(gt=0, description="Number of chunks of the file")
+
+    # Status and timing
+    status: UploadSessionStatus = Field(default=UploadSessionStatus.PENDING, description="Status of the upload")
+    submission_id: Optional[UUID] = Field(default=None, description="ID of the submission created once finalized")
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the upload was initiated")
+    updated_at: Optional[datetime] = Field(default=None, description="When the upload was last updated")
+    expires_at: datetime = Field(description="When the upload expires if not finalized, its chunks being deleted")
+
+    # Error handling
+    error_message: Optional[str] = Field(default=None, description="Error message if the upload failed")
+
+
+class SubmissionIdempotencyKey(SQLModel, table=True):
+    """Database model for an idempotency key of a creation request, its response being replayed to the retries"""
+
+    __tablename__ = "submission_idempotency_key"
+    __table_args__ = (UniqueConstraint("client_scope", "key"),)
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Key, scoped per client credential, and the request it was first sent with
+    client_scope: str = Field(max_length=64, description="Hash of the credential of the client")
+    key: str = Field(max_length=255, description="Idempotency key sent by the client")
+    endpoint: str = Field(description="Endpoint the request was sent to")
+    request_hash: str = Field(max_length=64, description="Hash of the payload of the request")
+
+    # Stored response, None while the request is being executed
+    status_code: Optional[int] = Field(default=None, description="Status code of the response")
+    response_body: Optional[dict] = Field(default=None, sa_column=Column(JSON), description="Body of the response")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the key was first sent")
+    expires_at: datetime = Field(index=True, description="When the key expires, a new request being then executed")
+
+
+class SubmissionFingerprint(SQLModel, table=True):
+    """Database model for a fingerprint of a file of a submission, the inverted index of the code search"""
+
+    __tablename__ = "submission_fingerprint"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    submission_id: UUID = Field(foreign_key="submission.id", index=True, description="ID of the indexed submission")
+
+    hash: str = Field(max_length=16, index=True, description="Hexadecimal hash of the winnowed k-gram")
+    file_path: str = Field(description="Path of the file, relative to the submission")
+    position: int = Field(ge=0, description="Position of the first token of the k-gram in the token stream of the file")
+    start_line: int = Field(default=0, description="Line (0-based) of the first token of the k-gram")
+    end_line: int = Field(default=0, description="Line (0-based) the last token of the k-gram ends on")
+
+
+class SubmissionTokenCacheEntry(SQLModel, table=True):
+    """
+    Database model for the cached tokens of a file content, reused by the comparisons tokenizing the same content
+    with the same tokenizer version and options
+    """
+
+    __tablename__ = "submission_token_cache"
+    __table_args__ = (UniqueConstraint("content_hash", "tokenizer_version", "tokenization_key", "language"),)
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Key of the entry: the content, the version of the tokenizers, the options and file extension, the language
+    content_hash: str = Field(max_length=64, index=True, description="SHA-256 of the content of the file")
+    tokenizer_version: int = Field(description="Version of the token output of the tokenizers")
+    tokenization_key: str = Field(
+        max_length=160, description="Hash of the tokenization options and file extension, under the tenant prefix"
+    )
+    language: str = Field(max_length=50, index=True, description="Language the file was detected in")
+
+    result: dict = Field(
+        default_factory=dict, sa_column=Column(JSON), description="Tokenization result: tokens, language, header..."
+    )
+    token_count: int = Field(default=0, ge=0, description="Number of cached tokens")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the tokens were cached")
+
+
+class SubmissionStepSchedule(SQLModel, table=True):
+    """Database model for the grading deadline of a project step and the detection run scheduled after it"""
+
+    __tablename__ = "submission_step_schedule"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    deadline: Optional[datetime] = Field(default=None, description="Grading deadline of the step")
+    priority: Optional[AnalysisPriority] = Field(default=None, description="Priority overriding that of the deadline")
+
+    # Detection run started by the scheduler over the analyzed submissions of the step
+    auto_run_at: Optional[datetime] = Field(default=None, description="When the detection run of the step is started")
+    auto_run_profile: Optional[str] = Field(
+        default=None, max_length=100, description="Analysis profile of the scheduled run, the default one if None"
+    )
+    auto_run_status: Optional[AutoRunStatus] = Field(default=None, description="Status of the scheduled run")
+    auto_run_started_at: Optional[datetime] = Field(default=None, description="When the scheduled run was started")
+    auto_run_id: Optional[UUID] = Field(default=None, description="ID of the detection run started by the scheduler")
+    auto_run_error: Optional[str] = Field(default=None, description="Why the scheduled run could not be started")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the schedule was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the schedule was last updated")
+
+
+class SubmissionRetentionPolicy(SQLModel, table=True):
+    """Database model for the retention of the files and detection results of a project step, purged once expired"""
+
+    __tablename__ = "submission_retention_policy"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    # Date the retention periods run from, and how long the files and the results are kept, forever if None
+    anchor: RetentionAnchor = Field(default=RetentionAnchor.DEADLINE, description="Date the retention runs from")
+    anchor_date: Optional[datetime] = Field(default=None, description="Anchor of the policy when it is a fixed date")
+    file_retention_days: Optional[int] = Field(default=None, ge=0, description="Days the files are kept")
+    result_retention_days: Optional[int] = Field(default=None, ge=0, description="Days the detection results are kept")
+    full_deletion: bool = Field(
+        default=False, description="Whether the expired records are deleted, rather than reduced to their metadata"
+    )
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the policy was created")
+    updated_at: Optional[datetime] = Field(default=None, description="When the policy was last updated")
+
+
+class SubmissionRetentionPurge(SQLModel, table=True):
+    """Database model for a purge of the expired files and results of a project step, the log of the purges"""
+
+    __tablename__ = "submission_retention_purge"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+
+    # Project context
+    project_uuid: UUID = Field(index=True, description="UUID of the associated project")
+    project_step_uuid: UUID = Field(description="UUID of the project step")
+
+    full_deletion: bool = Field(default=False, description="Whether the expired records were deleted")
+    purged_file_count: int = Field(default=0, ge=0, description="Submissions whose stored files were purged")
+    deleted_submission_count: int = Field(default=0, ge=0, description="Submissions deleted for good")
+    purged_result_count: int = Field(default=0, ge=0, description="Comparisons purged of their details or deleted")
+    deleted_run_count: int = Field(default=0, ge=0, description="Detection runs deleted")
+    exempt_submission_count: int = Field(default=0, ge=0, description="Submissions kept for their open integrity case")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the purge was made")
+
+
+class SubmissionWebhook(SQLModel, table=True):
+    """Database model for a webhook, the URL the events of a project step (or of all of them) are posted to"""
+
+    __tablename__ = "submission_webhook"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    tenant_id: str = Field(
+        default=DEFAULT_TENANT_ID, max_length=64, index=True, description="Tenant whose events are posted"
+    )
+    url: str = Field(max_length=2048, description="URL the events are posted to")
+    events: list = Field(default_factory=list, sa_column=Column(JSON), description="Events notified to the webhook")
+    secret: str = Field(max_length=255, description="Secret the HMAC signature of the deliveries is computed with")
+    description: Optional[str] = Field(default=None, max_length=1000, description="Optional description")
+
+    # Scope of the webhook: a project step, all the steps of a project, or every project if none
+    project_uuid: Optional[UUID] = Field(default=None, index=True, description="UUID of the project, None for all")
+    project_step_uuid: Optional[UUID] = Field(default=None, description="UUID of the project step, None for all")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the webhook was registered")
+
+
+class SubmissionWebhookDelivery(SQLModel, table=True):
+    """Database model for the delivery of an event to a webhook, with its attempts, the log of the deliveries"""
+
+    __tablename__ = "submission_webhook_delivery"
+
+    id: Optional[UUID] = Field(default_factory=uuid4, primary_key=True)
+    webhook_id: UUID = Field(foreign_key="submission_webhook.id", index=True, description="ID of the webhook")
+    event: WebhookEvent = Field(description="Notified event")
+    payload: dict = Field(default_factory=dict, sa_column=Column(JSON), description="JSON payload posted")
+
+    # Attempts, each with the status code of the response or the error of the request
+    status: WebhookDeliveryStatus = Field(default=WebhookDeliveryStatus.PENDING, description="Status of the delivery")
+    attempt_count: int = Field(default=0, ge=0, description="Number of attempts made")
+    attempts: list = Field(default_factory=list, sa_column=Column(JSON), description="Record of each attempt")
+    next_attempt_at: Optional[datetime] = Field(default=None, description="When the next attempt is made, if any")
+
+    created_at: datetime = Field(default_factory=get_paris_time, description="When the event fired")
+    delivered_at: Optional[datetime] = Field(default=None, description="When a response of 2xx was received")
@@ -0,0 +1,84 @@
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+from uuid import UUID
+
+from sqlalchemy import delete, func
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import ProcessingOutcome, ProcessingStage, SubmissionProcessingEvent
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionProcessingEventRepository:
+    """Repository for the processing log of the submissions, a timeline of the stages they were processed through"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create_many(self, submission_id: UUID, events: List[Dict[str, Any]]) -> int:
+        """Record the stages of a processing of a submission at once, returning their number"""
+        try:
+            for event in events:
+                self.session.add(SubmissionProcessingEvent(submission_id=submission_id, **event))
+            self.session.commit()
+            return len(events)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create processing events: {str(e)}")
+
+    def get_page(
+        self,
+        submission_id: UUID,
+        skip: int,
+        limit: int,
+        stage: Optional[ProcessingStage] = None,
+        warnings_only: bool = False,
+    ) -> Tuple[List[SubmissionProcessingEvent], int]:
+        """
+        Get a page of the processing log of a submission in the order the stages started, with the number of its
+        events matching the filters: the given stage, and those with warnings or failed only if set
+        """
+        try:
+            conditions = [SubmissionProcessingEvent.submission_id == submission_id]
+            if stage is not None:
+                conditions.append(SubmissionProcessingEvent.stage == stage)
+            if warnings_only:
+                conditions.append(SubmissionProcessingEvent.outcome != ProcessingOutcome.OK)
+
+            total = self.session.exec(
+                select(func.count()).select_from(SubmissionProcessingEvent).where(*conditions)
+            ).one()
+            statement = (
+                select(SubmissionProcessingEvent)
+                .where(*conditions)
+                .order_by(SubmissionProcessingEvent.started_at, SubmissionProcessingEvent.created_at)
+                .offset(skip)
+                .limit(limit)
+            )
+            return list(self.session.exec(statement).all()), total
+        except Exception as e:
+            raise DatabaseException(f"Failed to get processing events: {str(e)}")
+
+    def delete_created_before(self, cutoff: datetime) -> int:
+        """Delete the events recorded before a time, whatever their submission, returning their number"""
+        try:
+            result = self.session.execute(
+                delete(SubmissionProcessingEvent).where(SubmissionProcessingEvent.created_at < cutoff)
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to prune processing events: {str(e)}")
+
+    def delete_by_submission_id(self, submission_id: UUID) -> int:
+        """Delete the processing log of a submission, returning the number of its events"""
+        try:
+            result = self.session.execute(
+                delete(SubmissionProcessingEvent).where(SubmissionProcessingEvent.submission_id == submission_id)
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete processing events: {str(e)}")
@@ -0,0 +1,86 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SimilarityStatus, SubmissionReportJob
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionReportJobRepository:
+    """Repository for the renderings of the PDF reports and evidence packages of the comparisons"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, job_data: dict) -> SubmissionReportJob:
+        """Create a new report job record"""
+        try:
+            job = SubmissionReportJob(**job_data)
+            self.session.add(job)
+            self.session.commit()
+            self.session.refresh(job)
+            return job
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create report job: {str(e)}")
+
+    def get_by_id(self, job_id: UUID) -> Optional[SubmissionReportJob]:
+        """Get report job record by ID, as last committed"""
+        try:
+            statement = select(SubmissionReportJob).where(SubmissionReportJob.id == job_id)
+            return self.session.exec(statement.execution_options(populate_existing=True)).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get report job: {str(e)}")
+
+    def update_status(
+        self,
+        job_id: UUID,
+        status: SimilarityStatus,
+        error_message: Optional[str] = None,
+        content: Optional[bytes] = None,
+        processing_time_seconds: Optional[float] = None,
+        content_sha256: Optional[str] = None,
+    ) -> SubmissionReportJob:
+        """Update the status of a report job, with the rendered report once it is completed"""
+        try:
+            job = self.get_by_id(job_id)
+            if not job:
+                raise NotFoundException(f"Report job with ID {job_id} not found")
+
+            job.status = status
+            job.updated_at = datetime.utcnow()
+            if error_message:
+                job.error_message = error_message
+            if content is not None:
+                job.content = content
+            if processing_time_seconds is not None:
+                job.processing_time_seconds = processing_time_seconds
+            if content_sha256 is not None:
+                job.content_sha256 = content_sha256
+
+            self.session.add(job)
+            self.session.commit()
+            self.session.refresh(job)
+            return job
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update report job status: {str(e)}")
+
+    def delete_by_similarity_ids(self, similarity_ids: List[UUID]) -> int:
+        """Delete the report jobs of the given comparisons, returning their number"""
+        if not similarity_ids:
+            return 0
+        try:
+            statement = select(SubmissionReportJob).where(SubmissionReportJob.similarity_id.in_(similarity_ids))
+            jobs = list(self.session.exec(statement).all())
+            for job in jobs:
+                self.session.delete(job)
+            self.session.commit()
+            return len(jobs)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete report jobs: {str(e)}")
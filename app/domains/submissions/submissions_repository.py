@@ -1,14 +1,22 @@
 from datetime import datetime, timezone
-from typing import List, Optional
+from typing import Any, Dict, List, Optional, Set, Tuple
 from uuid import UUID
 
 import pytz
+from sqlalchemy import and_, func, or_
 from sqlmodel import Session, select
 
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
 from app.domains.submissions.dto.submission_update_dto import SubmissionUpdateDto
-from app.domains.submissions.submissions_models import LinkType, Submission
+from app.domains.submissions.submissions_models import (
+    LinkType,
+    ProcessingStatus,
+    Submission,
+    SubmissionAuditEntry,
+    SubmissionStatus,
+)
 from app.shared.exceptions import DatabaseException, NotFoundException
+from app.shared.tenancy import DEFAULT_TENANT_ID
 
 # Paris timezone
 PARIS_TZ = pytz.timezone("Europe/Paris")
@@ -19,6 +27,15 @@ def get_paris_time() -> datetime:
     return datetime.now(PARIS_TZ)
 
 
+# Columns the listed submissions are sorted by, the submissions without a language sorting first
+SORT_COLUMNS = {
+    "created_at": Submission.created_at,
+    "group": Submission.group_uuid,
+    "status": Submission.status,
+    "language": func.coalesce(Submission.language, ""),
+}
+
+
 class SubmissionRepository:
     """Repository for submission data access operations"""
 
@@ -31,8 +48,10 @@ class SubmissionRepository:
         ip_address: Optional[str] = None,
         user_agent: Optional[str] = None,
         rule_results_json: Optional[str] = None,
+        version: int = 1,
+        tenant_id: str = DEFAULT_TENANT_ID,
     ) -> Submission:
-        """Create a new submission"""
+        """Create a new submission, at the given version for its project, group and step, of the tenant of the step"""
         try:
             # Set upload_date_time to current time in Paris timezone if not provided
             upload_time = submission_data.upload_date_time or get_paris_time()
@@ -58,6 +77,8 @@ class SubmissionRepository:
                     "link_type": link_type,
                     "ip_address": ip_address,
                     "user_agent": user_agent,
+                    "version": version,
+                    "tenant_id": tenant_id,
                 }
             )
 
@@ -84,40 +105,126 @@ class SubmissionRepository:
             raise DatabaseException(f"Failed to get submission: {str(e)}")
 
     def get_by_project_group_step(self, project_uuid, group_uuid, project_step_uuid):
-        """Get submission by project, group, and step"""
+        """Get the latest version of the submission by project, group, and step"""
+        try:
+            statement = (
+                select(Submission)
+                .where(
+                    Submission.project_uuid == project_uuid,
+                    Submission.group_uuid == group_uuid,
+                    Submission.project_step_uuid == project_step_uuid,
+                    Submission.deleted_at.is_(None),
+                )
+                .order_by(Submission.version.desc())
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submission: {str(e)}")
+
+    def get_versions(
+        self, project_uuid: UUID, group_uuid: UUID, project_step_uuid: UUID, include_deleted: bool = False
+    ) -> List[Submission]:
+        """Get all the versions of the submission of a group for a project step, oldest first"""
         try:
             statement = select(Submission).where(
                 Submission.project_uuid == project_uuid,
                 Submission.group_uuid == group_uuid,
                 Submission.project_step_uuid == project_step_uuid,
             )
-            return self.session.exec(statement).first()
+            if not include_deleted:
+                statement = statement.where(Submission.deleted_at.is_(None))
+            return list(self.session.exec(statement.order_by(Submission.version)).all())
         except Exception as e:
-            raise DatabaseException(f"Failed to get submission: {str(e)}")
+            raise DatabaseException(f"Failed to get submission versions: {str(e)}")
 
     def get_by_project_and_group(self, project_uuid: UUID, group_uuid: UUID) -> List[Submission]:
         """Get all submissions for a specific project and group"""
         try:
             statement = (
                 select(Submission)
-                .where(Submission.project_uuid == project_uuid, Submission.group_uuid == group_uuid)
+                .where(
+                    Submission.project_uuid == project_uuid,
+                    Submission.group_uuid == group_uuid,
+                    Submission.deleted_at.is_(None),
+                )
                 .order_by(Submission.upload_date_time.desc())
             )
             return list(self.session.exec(statement).all())
         except Exception as e:
             raise DatabaseException(f"Failed to get submissions: {str(e)}")
 
-    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> List[Submission]:
-        """Get all submissions for a specific project step"""
+    def get_by_project_step(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        max_language_confidence: Optional[float] = None,
+        latest_versions_only: bool = False,
+        include_deleted: bool = False,
+        include_quarantined: bool = False,
+        with_files: bool = False,
+    ) -> List[Submission]:
+        """
+        Get all submissions for a specific project step, only those under a language confidence if given, and only
+        the latest version of the submission of each group with latest_versions_only. The deleted and the
+        quarantined submissions are left out unless included, and those whose files were purged with with_files.
+        """
+        try:
+            statement = select(Submission).where(
+                Submission.project_uuid == project_uuid, Submission.project_step_uuid == project_step_uuid
+            )
+            if not include_deleted:
+                statement = statement.where(Submission.deleted_at.is_(None))
+            if not include_quarantined:
+                statement = statement.where(Submission.status != SubmissionStatus.QUARANTINED)
+            if with_files:
+                statement = statement.where(Submission.files_purged_at.is_(None))
+            if max_language_confidence is not None:
+                statement = statement.where(Submission.language_confidence < max_language_confidence)
+            statement = statement.order_by(Submission.upload_date_time.desc(), Submission.id)
+            submissions = list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submissions by step: {str(e)}")
+        return self.latest_versions(submissions) if latest_versions_only else submissions
+
+    def get_by_content_hash(self, project_uuid: UUID, project_step_uuid: UUID, content_hash: str) -> List[Submission]:
+        """Get the submissions of a project step with the given aggregate content hash, oldest first"""
         try:
             statement = (
                 select(Submission)
-                .where(Submission.project_uuid == project_uuid, Submission.project_step_uuid == project_step_uuid)
-                .order_by(Submission.upload_date_time.desc())
+                .where(
+                    Submission.project_uuid == project_uuid,
+                    Submission.project_step_uuid == project_step_uuid,
+                    Submission.content_hash == content_hash,
+                    Submission.deleted_at.is_(None),
+                )
+                .order_by(Submission.upload_date_time)
             )
             return list(self.session.exec(statement).all())
         except Exception as e:
-            raise DatabaseException(f"Failed to get submissions by step: {str(e)}")
+            raise DatabaseException(f"Failed to get submissions by content hash: {str(e)}")
+
+    def get_by_processing_statuses(self, statuses: List[ProcessingStatus]) -> List[Submission]:
+        """Get the submissions at one of the given processing stages, oldest first, the deleted ones left out"""
+        try:
+            statement = (
+                select(Submission)
+                .where(Submission.processing_status.in_(statuses), Submission.deleted_at.is_(None))
+                .order_by(Submission.upload_date_time)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get submissions by processing status: {str(e)}")
+
+    @staticmethod
+    def latest_versions(submissions: List[Submission]) -> List[Submission]:
+        """Keep the latest version of the submission of each group, in the order of the submissions"""
+        latest = {}
+        for submission in submissions:
+            current = latest.get(submission.group_uuid)
+            if current is None or submission.version > current.version:
+                latest[submission.group_uuid] = submission
+        kept = {submission.id for submission in latest.values()}
+        return [submission for submission in submissions if submission.id in kept]
 
     def update(self, submission_id: UUID, update_data: SubmissionUpdateDto) -> Optional[Submission]:
         """Update a submission"""
@@ -145,8 +252,74 @@ class SubmissionRepository:
             self.session.rollback()
             raise DatabaseException(f"Failed to update submission: {str(e)}")
 
+    def patch(
+        self, submission_id: UUID, changes: Dict[str, Any], audit_entry: Optional[SubmissionAuditEntry] = None
+    ) -> Submission:
+        """Set the given fields of a submission, validated beforehand, with their audit entry in one transaction"""
+        try:
+            submission = self.get_by_id(submission_id)
+            if not submission:
+                raise NotFoundException(f"Submission with ID {submission_id} not found")
+
+            for field, value in changes.items():
+                setattr(submission, field, value)
+            submission.updated_at = get_paris_time()
+
+            self.session.add(submission)
+            if audit_entry is not None:
+                self.session.add(audit_entry)
+            self.session.commit()
+            self.session.refresh(submission)
+            return submission
+
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to patch submission: {str(e)}")
+
+    def soft_delete(self, submission_id: UUID) -> Submission:
+        """Mark a submission as deleted, keeping its record and its files"""
+        return self._set_deleted_at(submission_id, get_paris_time())
+
+    def restore(self, submission_id: UUID) -> Submission:
+        """Restore a deleted submission"""
+        return self._set_deleted_at(submission_id, None)
+
+    def _set_deleted_at(self, submission_id: UUID, deleted_at: Optional[datetime]) -> Submission:
+        """Set when a submission was deleted, None for a submission that is not"""
+        try:
+            submission = self.get_by_id(submission_id)
+            if not submission:
+                raise NotFoundException(f"Submission with ID {submission_id} not found")
+
+            submission.deleted_at = deleted_at
+            submission.updated_at = get_paris_time()
+            self.session.add(submission)
+            self.session.commit()
+            self.session.refresh(submission)
+            return submission
+
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update the deletion of submission: {str(e)}")
+
+    def get_deleted_ids(self, submission_ids: List[UUID]) -> Set[UUID]:
+        """Get the IDs of the deleted submissions among the given ones"""
+        if not submission_ids:
+            return set()
+        try:
+            statement = select(Submission.id).where(
+                Submission.id.in_(submission_ids), Submission.deleted_at.is_not(None)
+            )
+            return set(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get deleted submissions: {str(e)}")
+
     def delete(self, submission_id: UUID) -> bool:
-        """Delete a submission"""
+        """Delete a submission for good"""
         try:
             submission = self.get_by_id(submission_id)
             if not submission:
@@ -163,18 +336,87 @@ class SubmissionRepository:
             raise DatabaseException(f"Failed to delete submission: {str(e)}")
 
     def list_all(self, skip: int = 0, limit: int = 100) -> List[Submission]:
-        """List all submissions with pagination"""
+        """List all submissions with pagination, but the deleted ones"""
         try:
-            statement = select(Submission).order_by(Submission.upload_date_time.desc()).offset(skip).limit(limit)
+            statement = (
+                select(Submission)
+                .where(Submission.deleted_at.is_(None))
+                .order_by(Submission.upload_date_time.desc())
+                .offset(skip)
+                .limit(limit)
+            )
             return list(self.session.exec(statement).all())
         except Exception as e:
             raise DatabaseException(f"Failed to list submissions: {str(e)}")
 
+    def list_page(
+        self,
+        filters: Dict[str, Any],
+        sort_by: str = "created_at",
+        descending: bool = True,
+        skip: int = 0,
+        limit: int = 100,
+        after: Optional[Tuple[Any, UUID]] = None,
+    ) -> Tuple[List[Submission], int, bool]:
+        """
+        List a page of the submissions matching the filters, sorted by a column of SORT_COLUMNS then by ID so that
+        the order is total. The page starts after the (sort value, ID) of the last submission of the previous page
+        if given, so that the submissions inserted meanwhile do not shift it. Only the deleted submissions are
+        listed with the deleted filter, never otherwise.
+
+        Returns:
+            The submissions of the page, the number of submissions matching the filters and whether more follow
+        """
+        try:
+            conditions = [
+                Submission.deleted_at.is_not(None) if filters.get("deleted") else Submission.deleted_at.is_(None)
+            ]
+            for field in ("tenant_id", "project_uuid", "project_step_uuid", "group_uuid", "status", "language"):
+                if filters.get(field) is not None:
+                    conditions.append(getattr(Submission, field) == filters[field])
+            # Scope of the caller: the assignments of a grader, the submissions of a student or of their groups
+            if filters.get("project_step_uuids") is not None:
+                conditions.append(Submission.project_step_uuid.in_(filters["project_step_uuids"]))
+            if filters.get("owner_uuids") is not None:
+                conditions.append(
+                    or_(
+                        Submission.group_uuid.in_(filters["owner_uuids"]),
+                        Submission.submitted_by_uuid.in_(filters["owner_uuids"]),
+                    )
+                )
+            if filters.get("created_after") is not None:
+                conditions.append(Submission.created_at >= filters["created_after"])
+            if filters.get("created_before") is not None:
+                conditions.append(Submission.created_at < filters["created_before"])
+
+            total = self.session.exec(select(func.count()).select_from(Submission).where(*conditions)).one()
+
+            column = SORT_COLUMNS[sort_by]
+            statement = select(Submission).where(*conditions)
+            if after is not None:
+                value, last_id = after
+                if descending:
+                    statement = statement.where(or_(column < value, and_(column == value, Submission.id < last_id)))
+                else:
+                    statement = statement.where(or_(column > value, and_(column == value, Submission.id > last_id)))
+            if descending:
+                statement = statement.order_by(column.desc(), Submission.id.desc())
+            else:
+                statement = statement.order_by(column.asc(), Submission.id.asc())
+
+            # One more submission than the page tells whether another page follows
+            submissions = list(self.session.exec(statement.offset(skip).limit(limit + 1)).all())
+            return submissions[:limit], total, len(submissions) > limit
+        except Exception as e:
+            raise DatabaseException(f"Failed to list submissions: {str(e)}")
+
     def count_by_project_and_group(self, project_uuid: UUID, group_uuid: UUID) -> int:
         """Count submissions for a specific project and group"""
         try:
             statement = select(Submission).where(
-                Submission.project_uuid == project_uuid, Submission.group_uuid == group_uuid
+                Submission.project_uuid == project_uuid,
+                Submission.group_uuid == group_uuid,
+                Submission.deleted_at.is_(None),
             )
             return len(list(self.session.exec(statement).all()))
         except Exception as e:
@@ -187,6 +429,7 @@ class SubmissionRepository:
                 Submission.project_uuid == project_uuid,
                 Submission.group_uuid == group_uuid,
                 Submission.project_step_uuid == project_step_uuid,
+                Submission.deleted_at.is_(None),
             )
             existing = self.session.exec(statement).first()
             return existing is not None
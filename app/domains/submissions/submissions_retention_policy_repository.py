@@ -0,0 +1,72 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionRetentionPolicy
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionRetentionPolicyRepository:
+    """Repository for the retention policies of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> Optional[SubmissionRetentionPolicy]:
+        """Get the retention policy of a project step"""
+        try:
+            statement = select(SubmissionRetentionPolicy).where(
+                SubmissionRetentionPolicy.project_uuid == project_uuid,
+                SubmissionRetentionPolicy.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get retention policy: {str(e)}")
+
+    def get_all(self) -> List[SubmissionRetentionPolicy]:
+        """Get the retention policies of all the project steps, oldest first"""
+        try:
+            statement = select(SubmissionRetentionPolicy).order_by(SubmissionRetentionPolicy.created_at)
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get retention policies: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, policy_data: dict) -> SubmissionRetentionPolicy:
+        """Create or replace the retention policy of a project step"""
+        try:
+            policy = self.get_by_project_step(project_uuid, project_step_uuid)
+            if policy:
+                for field, value in policy_data.items():
+                    setattr(policy, field, value)
+                policy.updated_at = datetime.utcnow()
+            else:
+                policy = SubmissionRetentionPolicy(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **policy_data
+                )
+
+            self.session.add(policy)
+            self.session.commit()
+            self.session.refresh(policy)
+            return policy
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save retention policy: {str(e)}")
+
+    def delete(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the retention policy of a project step, returning whether it existed"""
+        try:
+            policy = self.get_by_project_step(project_uuid, project_step_uuid)
+            if not policy:
+                return False
+            self.session.delete(policy)
+            self.session.commit()
+            return True
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete retention policy: {str(e)}")
@@ -0,0 +1,37 @@
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionRetentionPurge
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionRetentionPurgeRepository:
+    """Repository for the log of the purges of the expired files and results of the project steps"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, purge_data: dict) -> SubmissionRetentionPurge:
+        """Create a new purge record"""
+        try:
+            purge = SubmissionRetentionPurge(**purge_data)
+            self.session.add(purge)
+            self.session.commit()
+            self.session.refresh(purge)
+            return purge
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create retention purge: {str(e)}")
+
+    def list_recent(self, project_uuid: Optional[UUID] = None, limit: int = 100) -> List[SubmissionRetentionPurge]:
+        """List the latest purges, latest first, only those of a project if given"""
+        try:
+            statement = select(SubmissionRetentionPurge)
+            if project_uuid is not None:
+                statement = statement.where(SubmissionRetentionPurge.project_uuid == project_uuid)
+            statement = statement.order_by(SubmissionRetentionPurge.created_at.desc()).limit(limit)
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to list retention purges: {str(e)}")
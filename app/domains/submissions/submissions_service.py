@@ -1,30 +1,163 @@
+import base64
 import json
 import logging
-from typing import List, Optional
+import time
+from collections import Counter
+from datetime import datetime
+from pathlib import PurePosixPath
+from typing import Any, Callable, Dict, Iterator, List, Optional, Tuple
 from uuid import UUID
 
+from fastapi import HTTPException
 from sqlmodel import Session
 
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.repositories.archive_extractor import (
+    METADATA_REASON,
+    NESTED_ARCHIVE_EXTENSIONS,
+    ArchiveExtractionResult,
+    ArchiveFile,
+)
+from app.domains.repositories.ignore_rules import IGNORED_PATH_SAMPLE_SIZE
+from app.domains.submissions.access_policy import AccessPolicy
+from app.domains.submissions.bulk_upload_splitter import DEFAULT_DIRECTORY_PATTERN, BulkUploadEntry, BulkUploadSplitter
 from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+from app.domains.submissions.dto.access_denial_dto import AccessDenialDto
+from app.domains.submissions.dto.analysis_profile_dto import (
+    AnalysisProfileResponseDto,
+    CreateAnalysisProfileDto,
+    UpdateAnalysisProfileDto,
+)
+from app.domains.submissions.dto.allowed_snippet_dto import (
+    AllowedSnippetResponseDto,
+    CreateAllowedSnippetDto,
+    UpdateAllowedSnippetDto,
+)
+from app.domains.submissions.dto.baseline_response_dto import BaselineResponseDto
+from app.domains.submissions.dto.bulk_upload_dto import BulkUploadJobResponseDto
+from app.domains.submissions.dto.code_metrics_dto import CodeMetricsDto
+from app.domains.submissions.dto.code_search_dto import CodeSearchDto, CodeSearchResponseDto
+from app.domains.submissions.dto.corpus_response_dto import CorpusItemResponseDto, CorpusResponseDto
+from app.domains.submissions.dto.create_baseline_dto import CreateBaselineDto
+from app.domains.submissions.dto.create_corpus_dto import CreateCorpusDto, CreateCorpusItemDto, StepCorporaDto
+from app.domains.submissions.dto.create_git_submission_dto import CreateGitSubmissionDto
+from app.domains.submissions.dto.create_detection_run_dto import CreateDetectionRunDto
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
 from app.domains.submissions.dto.create_submission_response_dto import CreateSubmissionResponseDto
+from app.domains.submissions.dto.detection_config_dto import DetectionConfigDto
+from app.domains.submissions.dto.detection_run_response_dto import (
+    DetectionRunResponseDto,
+    DetectionRunSummaryDto,
+    MatrixPairDto,
+    SimilarityClustersDto,
+    SimilarityMatrixDto,
+)
+from app.domains.submissions.dto.detection_run_verification_dto import (
+    DetectionRunVerificationDto,
+    VerifyDetectionRunDto,
+)
+from app.domains.submissions.dto.external_comparison_dto import (
+    EvidenceResponseDto,
+    ExternalComparisonDto,
+    ExternalComparisonResponseDto,
+)
+from app.domains.submissions.dto.file_filter_config_dto import FileFilterConfigDto
+from app.domains.submissions.dto.grading_callback_dto import (
+    GradingCallbackDeliveryDto,
+    GradingCallbackDto,
+    GradingCallbackResponseDto,
+    SavedGradingCallbackResponseDto,
+)
+from app.domains.submissions.dto.header_config_dto import HeaderConfigDto
+from app.domains.submissions.dto.ignore_rules_dto import EffectiveIgnoreRulesDto, IgnoreRulesConfigDto
+from app.domains.submissions.dto.language_statistics_dto import LanguageStatisticsDto
+from app.domains.submissions.dto.originality_feedback_dto import OriginalityFeedbackDto
+from app.domains.submissions.dto.patch_submission_dto import PatchSubmissionDto
+from app.domains.submissions.dto.processing_log_dto import ProcessingEventDto, ProcessingLogPageDto
+from app.domains.submissions.dto.rare_token_evidence_dto import RareTokenEvidenceDto
+from app.domains.submissions.dto.report_job_response_dto import ReportJobResponseDto
+from app.domains.submissions.dto.retention_policy_dto import (
+    RetentionPolicyDto,
+    RetentionPolicyResponseDto,
+    RetentionPurgeDto,
+    RetentionPurgeReportDto,
+)
+from app.domains.submissions.dto.submission_audit_dto import SubmissionAuditEntryDto
+from app.domains.submissions.dto.submission_page_dto import SortOrder, SubmissionPageDto, SubmissionSortField
 from app.domains.submissions.dto.submission_response_dto import SubmissionResponseDto
+from app.domains.submissions.dto.submission_status_dto import SubmissionStatusDto
 from app.domains.submissions.dto.submission_update_dto import SubmissionUpdateDto
+from app.domains.submissions.dto.submission_version_dto import SubmissionVersionDiffDto, SubmissionVersionDto
+from app.domains.submissions.dto.step_schedule_dto import StepScheduleDto, StepScheduleResponseDto
+from app.domains.submissions.dto.token_cache_dto import TokenCachePurgeResponseDto
+from app.domains.submissions.dto.token_diff_dto import TokenDiffDto, TokenDiffRequestDto
+from app.domains.submissions.dto.upload_limits_dto import EffectiveUploadLimitsDto, UploadLimitsDto
+from app.domains.submissions.dto.upload_session_dto import CreateUploadSessionDto, UploadSessionResponseDto
+from app.domains.submissions.dto.upload_submission_dto import SubmissionFileResponseDto, UploadSubmissionResponseDto
+from app.domains.submissions.dto.webhook_dto import (
+    CreateWebhookDto,
+    CreateWebhookResponseDto,
+    WebhookDeliveryResponseDto,
+    WebhookResponseDto,
+)
+from app.domains.submissions.grading_callback import GRADING_SCHEMA_VERSION
+from app.domains.submissions.idempotency import Idempotency
+from app.domains.submissions.processing_lifecycle import ProcessingLifecycle
+from app.domains.submissions.processing_timeline import ProcessingTimeline
 from app.domains.submissions.rules.rule_service import RuleService
-from app.domains.submissions.submissions_models import LinkType, SubmissionStatus
+from app.domains.submissions.run_summary import DEFAULT_CENTRAL_SUBMISSIONS, DEFAULT_SUMMARY_BUCKETS
+from app.domains.submissions.similarity_clusterer import DEFAULT_MERGE_THRESHOLD
+from app.domains.submissions.similarity_matrix import PairSortField
+from app.domains.submissions.submissions_models import (
+    LinkType,
+    ProcessingStage,
+    ProcessingWarningCode,
+    SimilarityStatus,
+    Submission,
+    SubmissionAllowedSnippet,
+    SubmissionBaseline,
+    SubmissionBulkUploadJob,
+    SubmissionCorpus,
+    SubmissionCorpusItem,
+    SubmissionDetectionRun,
+    SubmissionIdempotencyKey,
+    SubmissionReportJob,
+    SubmissionStatus,
+    SubmissionUploadSession,
+    UploadSessionStatus,
+    WebhookDeliveryStatus,
+    get_paris_time,
+)
 from app.domains.submissions.submissions_repository import SubmissionRepository
-from app.shared.exceptions import NotFoundException, ValidationException
+from app.shared.authorization import Principal
+from app.shared.exceptions import BadRequestException, NotFoundException, ValidationException
 
 logger = logging.getLogger(__name__)
 
+# Fields of a submission that are never patched: its files and the fields set at its upload or by its processing
+IMMUTABLE_SUBMISSION_FIELDS = (set(Submission.model_fields) | {"files", "content_hash"}) - set(
+    PatchSubmissionDto.model_fields
+)
+
+# Fields of a submission that a patch changes but cannot remove
+REQUIRED_SUBMISSION_FIELDS = ("project_uuid", "group_uuid", "project_step_uuid")
+
 
 class SubmissionService:
-    """Service for submission business logic"""
+    """
+    Service for submission business logic, each operation checked against the access policy of the caller (none
+    for the internal jobs, and while authentication is explicitly disabled)
+    """
 
-    def __init__(self, session: Session):
+    def __init__(self, session: Session, principal: Optional[Principal] = None):
         self.repository = SubmissionRepository(session)
         self.rule_service = RuleService()
-        self.detection_service = DetectionIntegrationService(session)
+        self.detection_service = DetectionIntegrationService(
+            session, tenant_id=principal.tenant_id if principal else None
+        )
+        self.access = AccessPolicy(
+            principal, self.detection_service.record_access_denial, self.detection_service.tenant_scope.step_tenant
+        )
 
     def create_submission(
         self,
@@ -32,8 +165,24 @@ class SubmissionService:
         ip_address: Optional[str] = None,
         user_agent: Optional[str] = None,
         allow_duplicates: bool = False,
+        quarantined: bool = False,
     ) -> CreateSubmissionResponseDto:
-        """Create a new submission with business logic validation"""
+        """
+        Create a new submission with business logic validation, a quarantined one (its files found infected) being
+        kept without being compared
+
+        Raises:
+            AccessDenied: If the caller may not submit for the group to the project step
+            AnalysisQueueFull: If the analysis queue stays full, before anything is created
+        """
+        self.access.check_submission_creation(
+            submission_data.project_step_uuid,
+            submission_data.group_uuid,
+            submission_data.submitted_by_uuid,
+            "create_submission",
+        )
+        # The analysis of the submission must be able to be queued
+        self.detection_service.ensure_analysis_capacity()
 
         # Check for duplicate submissions if not allowed
         if not allow_duplicates:
@@ -92,6 +241,7 @@ class SubmissionService:
 
                     # Create detailed error response
                     error_response = {
+                        "error_type": "rule_validation_failed",
                         "validation_failed": True,
                         "failed_rule_count": len(failed_rules),
                         "total_rule_count": len(rule_results),
@@ -127,16 +277,24 @@ class SubmissionService:
                     details={"error_type": type(e).__name__, "error_message": str(e)},
                 )
 
+        version = self._next_version(
+            submission_data.project_uuid, submission_data.group_uuid, submission_data.project_step_uuid
+        )
+
         # Create the submission
         submission = self.repository.create(
             submission_data=submission_data,
             ip_address=ip_address,
             user_agent=user_agent,
             rule_results_json=rule_results_json if "rule_results_json" in locals() else None,
+            version=version,
+            tenant_id=self.detection_service.tenant_scope.step_tenant(submission_data.project_step_uuid),
         )
 
         # Update submission status to completed for similarity detection
-        update_data = SubmissionUpdateDto(status=SubmissionStatus.COMPLETED)
+        update_data = SubmissionUpdateDto(
+            status=SubmissionStatus.QUARANTINED if quarantined else SubmissionStatus.COMPLETED
+        )
         submission = self.repository.update(submission.id, update_data)
 
         # Start similarity detection asynchronously (non-blocking)
@@ -161,6 +319,11 @@ class SubmissionService:
             "status": "processing_async",
             "message": "Similarity detection has been started in the background. Results will be available shortly.",
         }
+        if quarantined:
+            response_data["similarity_detection"] = {
+                "status": "quarantined",
+                "message": "The submission is quarantined for malware, it is not compared until released.",
+            }
 
         # Include rule results from execution or from stored submission
         if rule_results:
@@ -179,12 +342,519 @@ class SubmissionService:
 
         return CreateSubmissionResponseDto(**response_data)
 
+    def upload_submission(
+        self,
+        content: bytes,
+        filename: Optional[str],
+        submission_data: dict,
+        ip_address: Optional[str] = None,
+        user_agent: Optional[str] = None,
+        allow_duplicates: bool = False,
+    ) -> UploadSubmissionResponseDto:
+        """
+        Create a submission from an uploaded file: an archive (ZIP, tar or tar.gz) is extracted into a multi-file
+        submission, the original file being kept in the upload bucket as the link of the submission. The entries
+        skipped for their kind or path are logged as warnings in the processing log of the submission.
+        """
+        self.access.check_submission_creation(
+            submission_data["project_step_uuid"],
+            submission_data["group_uuid"],
+            submission_data.get("submitted_by_uuid"),
+            "upload_submission",
+        )
+        filename = PurePosixPath((filename or "").replace("\\", "/")).name or "submission"
+        limits = self.detection_service.get_upload_limits(
+            submission_data["project_uuid"], submission_data["project_step_uuid"]
+        )
+        ignore_rules = self.detection_service.get_ignore_rules(
+            submission_data["project_uuid"], submission_data["project_step_uuid"]
+        )
+        extraction = self.detection_service.extract_upload(content, filename, limits, ignore_rules)
+        return self._create_stored_submission(
+            content,
+            filename,
+            extraction.files,
+            extraction.skipped_entries,
+            submission_data,
+            ip_address,
+            user_agent,
+            allow_duplicates,
+            ignored_paths=extraction.ignored_paths,
+        )
+
+    def bulk_upload_submissions(
+        self,
+        content: bytes,
+        filename: Optional[str],
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        directory_pattern: str = DEFAULT_DIRECTORY_PATTERN,
+        ip_address: Optional[str] = None,
+        user_agent: Optional[str] = None,
+        allow_duplicates: bool = False,
+    ) -> BulkUploadJobResponseDto:
+        """
+        Create the submissions of a whole class from one archive, one per top-level directory named after the
+        pattern, in the background: the returned job is polled for the result of each directory, a bad directory
+        failing alone. The student identifier of a directory is the group (and the submitter) of its submission.
+        """
+        self.access.check_step(project_step_uuid, "bulk_upload_submissions")
+        try:
+            BulkUploadSplitter(directory_pattern)
+        except ValueError as e:
+            raise ValidationException(str(e))
+        filename = PurePosixPath((filename or "").replace("\\", "/")).name or "bulk-upload"
+        job = self.detection_service.create_bulk_upload_job(
+            content, filename, project_uuid, project_step_uuid, directory_pattern
+        )
+        self.detection_service.bulk_upload_executor.submit(
+            self._process_bulk_upload_threaded, job.id, content, ip_address, user_agent, allow_duplicates
+        )
+        logger.info(f"Started bulk upload {job.id} of {filename} ({len(content)} bytes)")
+        return self._to_bulk_upload_job_response(job)
+
+    def get_bulk_upload_job(self, job_id: UUID) -> BulkUploadJobResponseDto:
+        """Get the progress of a bulk upload, with the result of each processed directory"""
+        self._check_step_resource("bulk_upload_job", job_id, "get_bulk_upload_job")
+        return self._to_bulk_upload_job_response(self.detection_service.get_bulk_upload_job(job_id))
+
+    @staticmethod
+    def _process_bulk_upload_threaded(
+        job_id: UUID, content: bytes, ip_address: Optional[str], user_agent: Optional[str], allow_duplicates: bool
+    ) -> None:
+        """Create the submissions of a bulk upload in a thread, with a database session of its own"""
+        from app.shared.database import get_session
+
+        SubmissionService(next(get_session()))._process_bulk_upload(
+            job_id, content, ip_address, user_agent, allow_duplicates
+        )
+
+    def _process_bulk_upload(
+        self,
+        job_id: UUID,
+        content: bytes,
+        ip_address: Optional[str],
+        user_agent: Optional[str],
+        allow_duplicates: bool,
+    ) -> None:
+        """Extract a bulk upload and create the submission of each directory, recording the progress with the job"""
+        start_time = time.time()
+        try:
+            job = self.detection_service.update_bulk_upload_job(job_id, {"status": SimilarityStatus.PROCESSING})
+            entries, root_files = BulkUploadSplitter(job.directory_pattern).split(
+                self.detection_service.extract_bulk_upload(content)
+            )
+        except Exception as e:
+            logger.error(f"Failed to extract bulk upload {job_id}: {str(getattr(e, 'detail', e))}")
+            self.detection_service.update_bulk_upload_job(
+                job_id, {"status": SimilarityStatus.FAILED, "error_message": str(getattr(e, "detail", e))}
+            )
+            return
+        if root_files:
+            logger.info(f"Ignored {len(root_files)} files outside of the directories of bulk upload {job_id}")
+
+        results = []
+        limits = self.detection_service.get_upload_limits(job.project_uuid, job.project_step_uuid)
+        self.detection_service.update_bulk_upload_job(job_id, {"entry_count": len(entries)})
+        for entry in entries:
+            # The directories wait for the analysis queue rather than being refused
+            if not self.detection_service.wait_for_analysis_capacity():
+                logger.info(f"Stopped bulk upload {job_id}: the analysis workers are stopped")
+                self.detection_service.update_bulk_upload_job(
+                    job_id, {"status": SimilarityStatus.FAILED, "error_message": "Interrupted by a shutdown"}
+                )
+                return
+            result = self._create_bulk_upload_entry(job, entry, limits, ip_address, user_agent, allow_duplicates)
+            results = results + [result]
+            created = sum(1 for result in results if result["submission_id"])
+            self.detection_service.update_bulk_upload_job(
+                job_id, {"results": results, "created_count": created, "failed_count": len(results) - created}
+            )
+
+        self.detection_service.update_bulk_upload_job(
+            job_id, {"status": SimilarityStatus.COMPLETED, "processing_time_seconds": time.time() - start_time}
+        )
+        logger.info(f"Completed bulk upload {job_id}: {len(entries)} directories")
+
+    def _create_bulk_upload_entry(
+        self,
+        job: SubmissionBulkUploadJob,
+        entry: BulkUploadEntry,
+        limits: Dict[str, Any],
+        ip_address: Optional[str],
+        user_agent: Optional[str],
+        allow_duplicates: bool,
+    ) -> Dict[str, Any]:
+        """
+        Create the submission of a directory of a bulk upload, within the limits of an upload to its project step,
+        getting its ID or the error
+        """
+        result = {
+            "directory": entry.directory,
+            "student_id": entry.student_id,
+            "group_uuid": None,
+            "submission_id": None,
+            "file_count": len(entry.files),
+            "error": entry.error,
+        }
+        if entry.error:
+            return result
+
+        group_uuid = BulkUploadSplitter.student_uuid(entry.student_id, job.project_uuid)
+        result["group_uuid"] = str(group_uuid)
+        try:
+            self.detection_service.check_upload_limits(entry.files, limits)
+            response = self._create_stored_submission(
+                ArchiveExtractionResult(files=entry.files).to_tar_gz(),
+                f"{entry.directory}.tar.gz",
+                entry.files,
+                entry.skipped_entries,
+                {
+                    "project_uuid": job.project_uuid,
+                    "group_uuid": group_uuid,
+                    "project_step_uuid": job.project_step_uuid,
+                    "description": f"Bulk upload {job.filename}: {entry.directory}",
+                    "submitted_by_uuid": group_uuid,
+                },
+                ip_address,
+                user_agent,
+                allow_duplicates,
+            )
+            self.repository.patch(response.submission_id, {"display_name": entry.directory[:200]})
+            result["submission_id"] = str(response.submission_id)
+        except HTTPException as e:
+            result["error"] = str(e.detail)
+        except Exception as e:
+            logger.error(f"Failed to create the submission of directory {entry.directory} of bulk upload {job.id}: {e}")
+            result["error"] = str(e)
+        return result
+
+    def begin_idempotent_request(
+        self, client_scope: str, key: str, endpoint: str, payload: Any
+    ) -> SubmissionIdempotencyKey:
+        """
+        Check the idempotency key of a creation request against its payload: the returned record holds the stored
+        response when the request is a retry of a completed one, else the request is to be executed
+        """
+        request_hash = Idempotency.request_hash(endpoint, payload)
+        return self.detection_service.get_idempotency_keys().begin(
+            client_scope, key, endpoint, request_hash, get_paris_time()
+        )
+
+    def complete_idempotent_request(self, record_id: UUID, status_code: int, response_body: Any) -> None:
+        """Store the response of an idempotent request, replayed to its retries until the key expires"""
+        self.detection_service.get_idempotency_keys().complete(record_id, status_code, response_body)
+
+    def release_idempotency_key(self, record_id: UUID) -> None:
+        """Release the idempotency key of a failed request"""
+        self.detection_service.get_idempotency_keys().release(record_id)
+
+    def create_upload_session(self, upload_data: CreateUploadSessionDto) -> UploadSessionResponseDto:
+        """
+        Initiate a resumable upload of a large file: its chunks are sent in any order, a chunk being sent again when
+        unsure it was received, then the upload is finalized into a submission. An upload not finalized in time
+        expires, its chunks being deleted.
+        """
+        self.access.check_submission_creation(
+            upload_data.project_step_uuid,
+            upload_data.group_uuid,
+            upload_data.submitted_by_uuid,
+            "create_upload_session",
+        )
+        data = upload_data.model_dump()
+        data["filename"] = PurePosixPath(data["filename"].replace("\\", "/")).name or "submission"
+        upload = self.detection_service.create_upload_session(data)
+        logger.info(f"Initiated upload {upload.id} of {upload.filename} in {upload.chunk_count} chunks")
+        return self._to_upload_session_response(upload)
+
+    def get_upload_session(self, upload_id: UUID) -> UploadSessionResponseDto:
+        """Get the progress of a resumable upload, with the chunks still missing"""
+        self._check_upload_session(upload_id, "get_upload_session")
+        return self._to_upload_session_response(self.detection_service.get_resumable_uploads().get(upload_id))
+
+    def upload_chunk(
+        self, upload_id: UUID, index: int, content: bytes, checksum: Optional[str] = None
+    ) -> UploadSessionResponseDto:
+        """Receive a chunk of a resumable upload, checked against its checksum when given"""
+        self._check_upload_session(upload_id, "upload_chunk")
+        return self._to_upload_session_response(
+            self.detection_service.get_resumable_uploads().store_chunk(upload_id, index, content, checksum)
+        )
+
+    def finalize_upload(
+        self, upload_id: UUID, ip_address: Optional[str] = None, user_agent: Optional[str] = None
+    ) -> UploadSubmissionResponseDto:
+        """
+        Create the submission of a resumable upload: the chunks are assembled, the whole file checked against its
+        checksum then processed like an uploaded file. The upload goes on when chunks are missing, listing them, and
+        fails when the file does not match its checksum or the submission is rejected. It stays pending when the
+        analysis queue is full, to be finalized again later.
+        """
+        self._check_upload_session(upload_id, "finalize_upload")
+        uploads = self.detection_service.get_resumable_uploads()
+        upload = uploads.get_pending(upload_id)
+        self.detection_service.ensure_analysis_capacity()
+        content = uploads.assemble(upload)
+        uploads.update(upload.id, {"status": UploadSessionStatus.PROCESSING})
+        try:
+            response = self.upload_submission(
+                content=content,
+                filename=upload.filename,
+                submission_data={
+                    "project_uuid": upload.project_uuid,
+                    "group_uuid": upload.group_uuid,
+                    "project_step_uuid": upload.project_step_uuid,
+                    "description": upload.description,
+                    "submitted_by_uuid": upload.submitted_by_uuid,
+                },
+                ip_address=ip_address,
+                user_agent=user_agent,
+                allow_duplicates=upload.allow_duplicates,
+            )
+        except Exception as e:
+            error_message = str(e.detail) if isinstance(e, HTTPException) else str(e)
+            uploads.update(
+                upload.id, {"status": UploadSessionStatus.FAILED, "error_message": error_message}, delete_chunks=True
+            )
+            raise
+
+        uploads.update(
+            upload.id,
+            {"status": UploadSessionStatus.COMPLETED, "submission_id": response.submission_id},
+            delete_chunks=True,
+        )
+        logger.info(f"Finalized upload {upload.id} into submission {response.submission_id}")
+        return response
+
+    def create_git_submission(
+        self,
+        git_data: CreateGitSubmissionDto,
+        ip_address: Optional[str] = None,
+        user_agent: Optional[str] = None,
+        allow_duplicates: bool = False,
+    ) -> UploadSubmissionResponseDto:
+        """
+        Create a submission from the tree of a Git repository at a ref: the tree is stored in the upload bucket as
+        a tar.gz archive, so that the submission is processed like an uploaded one and never fetched again, and the
+        commit the ref resolved to is recorded with it
+        """
+        self.access.check_submission_creation(
+            git_data.project_step_uuid, git_data.group_uuid, git_data.submitted_by_uuid, "create_git_submission"
+        )
+        fetched = self.detection_service.fetch_git_submission(git_data)
+        repository_name = PurePosixPath(git_data.repository_url.rstrip("/")).name.removesuffix(".git") or "repository"
+        filename = f"{repository_name}-{fetched.commit_sha[:12]}.tar.gz"
+        content = fetched.extraction.to_tar_gz()
+        limits = self.detection_service.get_upload_limits(git_data.project_uuid, git_data.project_step_uuid)
+        ignore_rules = self.detection_service.get_ignore_rules(git_data.project_uuid, git_data.project_step_uuid)
+        extraction = self.detection_service.extract_upload(content, filename, limits, ignore_rules)
+        submission_data = git_data.model_dump(
+            include={"project_uuid", "group_uuid", "project_step_uuid", "description", "submitted_by_uuid"}
+        )
+        return self._create_stored_submission(
+            content,
+            filename,
+            extraction.files,
+            fetched.extraction.skipped_entries + extraction.skipped_entries,
+            submission_data,
+            ip_address,
+            user_agent,
+            allow_duplicates,
+            source_data={
+                "git_repository_url": git_data.repository_url,
+                "git_ref": git_data.ref,
+                "git_commit_sha": fetched.commit_sha,
+                "git_subdirectory": git_data.subdirectory,
+            },
+            ignored_paths=fetched.extraction.ignored_paths + extraction.ignored_paths,
+        )
+
+    def _next_version(self, project_uuid: UUID, group_uuid: UUID, project_step_uuid: UUID) -> int:
+        """
+        Version of the next submission of a group for a step: a resubmission is its next version, the earlier
+        versions (deleted ones too) being kept with their number
+        """
+        versions = self.repository.get_versions(project_uuid, group_uuid, project_step_uuid, include_deleted=True)
+        return versions[-1].version + 1 if versions else 1
+
+    def _create_stored_submission(
+        self,
+        content: bytes,
+        filename: str,
+        files: List[ArchiveFile],
+        skipped_entries: List[dict],
+        submission_data: dict,
+        ip_address: Optional[str],
+        user_agent: Optional[str],
+        allow_duplicates: bool,
+        source_data: Optional[dict] = None,
+        ignored_paths: Optional[List[str]] = None,
+    ) -> UploadSubmissionResponseDto:
+        """
+        Create a submission from files kept in the upload bucket, recording the files and logging the entries
+        skipped for their kind or path as warnings in the processing log of the submission, with the binary files
+        left out of its analysis and the files whose invalid byte sequences were replaced. The files disallowed for
+        the project step are skipped likewise, the allowed ones being archived again to be kept without them. The
+        paths matching the ignore rules of the step (or the .gitignore files of the upload when the step honors them)
+        are left out the same way, counted with a sample in the processing log rather than logged one by one. The
+        files are scanned for malware before their analysis, an infected upload being kept quarantined. A submission
+        with the same files as that of another group of the step is flagged as its duplicate. The upload starts the
+        processing log of the submission, with the warnings of its files.
+        """
+        started_at = get_paris_time()
+        # Nothing is stored when the analysis cannot be queued
+        self.detection_service.ensure_analysis_capacity()
+        files, ignored = self.detection_service.ignore_upload_files(
+            files, submission_data["project_uuid"], submission_data["project_step_uuid"]
+        )
+        ignored_paths = (ignored_paths or []) + ignored
+        files, disallowed = self.detection_service.filter_upload_files(
+            files, submission_data["project_uuid"], submission_data["project_step_uuid"]
+        )
+        if disallowed or ignored_paths:
+            content = ArchiveExtractionResult(files=files).to_tar_gz()
+            filename = f"{self._archive_stem(filename)}.tar.gz"
+            skipped_entries = skipped_entries + disallowed
+        malware_scan = self.detection_service.scan_upload_files(files)
+        quarantined = malware_scan.pop("quarantined")
+
+        # Kept under the version the submission is about to get
+        link = self.detection_service.store_upload(
+            content,
+            filename,
+            submission_data["project_uuid"],
+            submission_data["project_step_uuid"],
+            submission_data["group_uuid"],
+            self._next_version(
+                submission_data["project_uuid"], submission_data["group_uuid"], submission_data["project_step_uuid"]
+            ),
+        )
+
+        response = self.create_submission(
+            CreateSubmissionDto(
+                **submission_data,
+                link=link,
+                link_type=LinkType.S3,
+                file_size_bytes=len(content),
+                file_count=len(files),
+            ),
+            ip_address=ip_address,
+            user_agent=user_agent,
+            allow_duplicates=allow_duplicates,
+            quarantined=quarantined,
+        )
+        records = self.detection_service.create_submission_files(response.submission_id, files)
+        original = self.detection_service.detect_duplicate_upload(response.submission_id, records)
+        timeline = ProcessingTimeline()
+        upload = timeline.record(
+            ProcessingStage.UPLOAD, started_at=started_at, filename=filename, file_count=len(files)
+        )
+        log_entries = []
+        if ignored_paths:
+            sample = ignored_paths[:IGNORED_PATH_SAMPLE_SIZE]
+            log_entries.append(
+                {"level": "info", "message": f"Ignored {len(ignored_paths)} paths, such as {', '.join(sample[:3])}"}
+            )
+            timeline.record(
+                ProcessingStage.UPLOAD,
+                code=ProcessingWarningCode.PATHS_IGNORED,
+                message=log_entries[-1]["message"],
+                ignored_path_count=len(ignored_paths),
+                ignored_path_sample=sample,
+            )
+        for entry in skipped_entries:
+            if entry["reason"] != METADATA_REASON:
+                log_entries.append({"level": "warning", "message": f"Skipped entry {entry['path']}: {entry['reason']}"})
+                timeline.record(
+                    ProcessingStage.UPLOAD,
+                    entry["path"],
+                    ProcessingWarningCode.ARCHIVE_ENTRY_SKIPPED,
+                    entry["reason"],
+                    skipped=True,
+                )
+        log_entries += self._malware_log_entries(malware_scan) + self.detection_service.upload_log_entries(
+            files, timeline
+        )
+        if original:
+            log_entries.append(
+                {"level": "warning", "message": f"Same files as submission {original.id} of another group"}
+            )
+            timeline.warn(upload, ProcessingWarningCode.DUPLICATE_UPLOAD, log_entries[-1]["message"])
+        languages = Counter(record.language for record in records if record.language).most_common(1)
+        language = languages[0][0] if languages else None
+        if log_entries or source_data or language:
+            self.repository.update(
+                response.submission_id,
+                SubmissionUpdateDto(**(source_data or {}), processing_log=log_entries or None, language=language),
+            )
+        # The scan is recorded once the submission is created, whatever its verdict
+        submission = self.repository.patch(
+            response.submission_id,
+            {"malware_scan": malware_scan, "quarantined_at": get_paris_time() if quarantined else None},
+        )
+        self.detection_service.record_processing_timeline(response.submission_id, timeline)
+        response.data = SubmissionResponseDto.model_validate(submission.model_dump())
+        return UploadSubmissionResponseDto(
+            **response.model_dump(),
+            files=[SubmissionFileResponseDto.model_validate(record.model_dump()) for record in records],
+            skipped_entries=skipped_entries,
+            ignored_path_count=len(ignored_paths),
+        )
+
+    def get_submission_files(self, submission_id: UUID) -> List[SubmissionFileResponseDto]:
+        """Get the files extracted from the upload of a submission"""
+        self._check_submission(submission_id, "get_submission_files")
+        files = self.detection_service.get_submission_files(submission_id)
+        return [SubmissionFileResponseDto.model_validate(file.model_dump()) for file in files]
+
+    def get_submission_archive(self, submission_id: UUID) -> Tuple[str, Iterator[bytes]]:
+        """Get the name and the streamed content of the original file of an uploaded submission"""
+        self._check_submission(submission_id, "get_submission_archive")
+        return self.detection_service.get_submission_archive(submission_id)
+
+    def download_submission(
+        self, submission_id: UUID, version: Optional[int] = None, original: bool = False
+    ) -> Tuple[str, Iterator[bytes]]:
+        """Get the filename and the streamed content of a submission (or of another of its versions) to download"""
+        self._check_submission(submission_id, "download_submission")
+        return self.detection_service.get_submission_download(submission_id, version, original)
+
+    def export_submission_tokens(
+        self, submission_id: UUID, export_format: str, detection_options: DetectionOptionsDto
+    ) -> Tuple[Iterator[str], str]:
+        """Get the streamed export of the token streams of a submission, and the name of the exported file"""
+        self._check_results("submission", submission_id, "export_submission_tokens")
+        return self.detection_service.export_submission_tokens(submission_id, export_format, detection_options)
+
+    def get_submission_versions(self, submission_id: UUID) -> List[SubmissionVersionDto]:
+        """Get all the versions of a submission, oldest first, the latest one being compared by the detection runs"""
+        self._check_submission(submission_id, "get_submission_versions")
+        versions = self.detection_service.get_submission_versions(submission_id)
+        return [
+            SubmissionVersionDto.model_validate({**version.model_dump(), "latest": version is versions[-1]})
+            for version in versions
+        ]
+
+    def diff_submission_versions(self, submission_id: UUID, other_submission_id: UUID) -> SubmissionVersionDiffDto:
+        """Get the per-file changes between two versions of a submission, from the older to the newer one"""
+        self._check_submission(submission_id, "diff_submission_versions")
+        self._check_submission(other_submission_id, "diff_submission_versions")
+        diff = self.detection_service.diff_submission_versions(submission_id, other_submission_id)
+        return SubmissionVersionDiffDto.model_validate(diff)
+
+    def diff_file_tokens(self, diff_data: TokenDiffRequestDto) -> TokenDiffDto:
+        """Align token by token two files of submissions, normalized as the detection does"""
+        self._check_results("submission", diff_data.file1.submission_id, "diff_file_tokens")
+        self._check_results("submission", diff_data.file2.submission_id, "diff_file_tokens")
+        return TokenDiffDto.model_validate(self.detection_service.diff_file_tokens(diff_data))
+
     def get_submission(self, submission_id: UUID) -> CreateSubmissionResponseDto:
         """Get a submission by ID"""
         submission = self.repository.get_by_id(submission_id)
 
         if not submission:
             raise NotFoundException(f"Submission with ID {submission_id} not found")
+        self.access.check_submission(submission, "get_submission")
 
         response_data = {
             "success": True,
@@ -204,6 +874,40 @@ class SubmissionService:
 
         return CreateSubmissionResponseDto(**response_data)
 
+    def get_submission_status(self, submission_id: UUID) -> SubmissionStatusDto:
+        """Get the stage of the processing of a submission, with the time of each transition and its progress"""
+        submission = self.repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException("Submission", str(submission_id))
+        self.access.check_submission(submission, "get_submission_status")
+        return self._status_dto(submission)
+
+    def retry_submission_processing(self, submission_id: UUID) -> SubmissionStatusDto:
+        """Retry by hand the processing of a failed submission, once the cause of its failure is fixed"""
+        self._check_submission(submission_id, "retry_submission_processing", manage=True)
+        return self._status_dto(self.detection_service.retry_processing(submission_id))
+
+    def _status_dto(self, submission: Submission) -> SubmissionStatusDto:
+        """Processing status of a submission, with its position in the analysis queue while it is queued"""
+        queued = self.detection_service.get_analysis_queue_position(submission.id) or {}
+        return SubmissionStatusDto(
+            submission_id=submission.id,
+            status=submission.processing_status,
+            transitions=ProcessingLifecycle.timeline(submission.upload_date_time, submission.processing_transitions),
+            processed_file_count=submission.processed_file_count,
+            total_file_count=submission.total_file_count,
+            error=submission.processing_error,
+            failed_file=submission.processing_failed_file,
+            attempt_count=submission.processing_attempt_count,
+            attempts=submission.processing_attempts or [],
+            retry_at=submission.processing_retry_at,
+            priority=self.detection_service.get_analysis_priority(
+                submission.project_uuid, submission.project_step_uuid, submission.analysis_priority
+            ),
+            effective_priority=queued.get("effective_priority"),
+            queue_position=queued.get("position"),
+        )
+
     def get_submission_by_project_group_step(self, project_uuid, group_uuid, project_step_uuid):
         """Get a submission by project, group and step"""
         submission = self.repository.get_by_project_group_step(
@@ -216,6 +920,7 @@ class SubmissionService:
             raise NotFoundException(
                 f"Submission for project {project_uuid}, group {group_uuid}, and step {project_step_uuid} not found"
             )
+        self.access.check_submission(submission, "get_submission_by_project_group_step")
 
         response_data = {
             "success": True,
@@ -236,19 +941,25 @@ class SubmissionService:
         return CreateSubmissionResponseDto(**response_data)
 
     def get_submissions_by_project_and_group(self, project_uuid: UUID, group_uuid: UUID) -> List[SubmissionResponseDto]:
-        """Get all submissions for a specific project and group"""
-        submissions = self.repository.get_by_project_and_group(project_uuid, group_uuid)
+        """Get all submissions for a specific project and group, those the caller may read"""
+        submissions = self.access.readable(self.repository.get_by_project_and_group(project_uuid, group_uuid))
         return [SubmissionResponseDto.model_validate(sub.model_dump()) for sub in submissions]
 
     def get_submissions_by_project_step(
-        self, project_uuid: UUID, project_step_uuid: UUID
+        self, project_uuid: UUID, project_step_uuid: UUID, max_language_confidence: Optional[float] = None
     ) -> List[SubmissionResponseDto]:
-        """Get all submissions for a specific project step"""
-        submissions = self.repository.get_by_project_step(project_uuid, project_step_uuid)
+        """
+        Get all submissions for a specific project step that the caller may read, only those under a language
+        confidence if given
+        """
+        submissions = self.access.readable(
+            self.repository.get_by_project_step(project_uuid, project_step_uuid, max_language_confidence)
+        )
         return [SubmissionResponseDto.model_validate(sub.model_dump()) for sub in submissions]
 
     def update_submission(self, submission_id: UUID, update_data: SubmissionUpdateDto) -> CreateSubmissionResponseDto:
         """Update a submission"""
+        self._check_submission(submission_id, "update_submission", manage=True)
         submission = self.repository.update(submission_id, update_data)
 
         return CreateSubmissionResponseDto(
@@ -258,25 +969,292 @@ class SubmissionService:
             data=SubmissionResponseDto.model_validate(submission.model_dump()),
         )
 
+    def patch_submission(
+        self,
+        submission_id: UUID,
+        patch_data: PatchSubmissionDto,
+        actor: Optional[str] = None,
+        ip_address: Optional[str] = None,
+    ) -> CreateSubmissionResponseDto:
+        """
+        Change the given metadata of a submission, recording the old and new values in its audit trail in the same
+        transaction. A submission moved to another group or project step becomes the latest version there, and
+        loses its detection results, compared again within its new group and step.
+
+        Raises:
+            BadRequestException: If immutable or unknown fields are given, or a required field is set to None
+        """
+        extra_fields = set(patch_data.model_extra or {})
+        if extra_fields:
+            immutable_fields = sorted(extra_fields & IMMUTABLE_SUBMISSION_FIELDS)
+            raise BadRequestException(
+                "Only the mutable metadata of a submission can be changed",
+                details={
+                    "message": "Only the mutable metadata of a submission can be changed",
+                    "immutable_fields": immutable_fields,
+                    "unknown_fields": sorted(extra_fields - IMMUTABLE_SUBMISSION_FIELDS),
+                },
+            )
+
+        submission = self.repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException(f"Submission with ID {submission_id} not found")
+        self.access.check_submission(submission, "patch_submission", manage=True)
+
+        requested = {field: getattr(patch_data, field) for field in patch_data.model_fields_set}
+        if requested.get("project_step_uuid") is not None:
+            # Moved only to another assignment of the caller
+            self.access.check_step(requested["project_step_uuid"], "patch_submission")
+        missing = [field for field in REQUIRED_SUBMISSION_FIELDS if field in requested and requested[field] is None]
+        if missing:
+            raise BadRequestException(f"Required fields of a submission cannot be removed: {', '.join(missing)}")
+
+        changes = {
+            field: {"old": getattr(submission, field), "new": value}
+            for field, value in requested.items()
+            if getattr(submission, field) != value
+        }
+        if not changes:
+            return CreateSubmissionResponseDto(
+                success=True,
+                message="Submission unchanged",
+                submission_id=submission.id,
+                data=SubmissionResponseDto.model_validate(submission.model_dump()),
+            )
+
+        old_project_uuid, old_project_step_uuid = submission.project_uuid, submission.project_step_uuid
+        reassigned = any(field in changes for field in REQUIRED_SUBMISSION_FIELDS)
+        if reassigned:
+            target = {field: requested.get(field, getattr(submission, field)) for field in REQUIRED_SUBMISSION_FIELDS}
+            versions = self.repository.get_versions(
+                target["project_uuid"], target["group_uuid"], target["project_step_uuid"], include_deleted=True
+            )
+            version = max((other.version for other in versions if other.id != submission.id), default=0) + 1
+            if version != submission.version:
+                changes["version"] = {"old": submission.version, "new": version}
+
+        audit_entry = self.detection_service.build_submission_audit_entry(submission_id, changes, actor, ip_address)
+        submission = self.repository.patch(
+            submission_id, {field: change["new"] for field, change in changes.items()}, audit_entry
+        )
+        if reassigned:
+            self.detection_service.invalidate_detection_results(submission, old_project_uuid, old_project_step_uuid)
+
+        logger.info(f"Patched submission {submission_id}: {', '.join(sorted(changes))}")
+        return CreateSubmissionResponseDto(
+            success=True,
+            message="Submission patched successfully",
+            submission_id=submission.id,
+            data=SubmissionResponseDto.model_validate(submission.model_dump()),
+        )
+
+    def get_submission_audit_trail(self, submission_id: UUID) -> List[SubmissionAuditEntryDto]:
+        """Get the changes of the metadata of a submission, oldest first"""
+        self._check_submission(submission_id, "get_submission_audit_trail", manage=True)
+        entries = self.detection_service.get_submission_audit_trail(submission_id)
+        return [SubmissionAuditEntryDto.model_validate(entry.model_dump()) for entry in entries]
+
+    def get_submission_processing_log(
+        self,
+        submission_id: UUID,
+        skip: int,
+        limit: int,
+        stage: Optional[ProcessingStage] = None,
+        warnings_only: bool = False,
+    ) -> ProcessingLogPageDto:
+        """Get a page of the processing log of a submission, in the order its stages started"""
+        self._check_submission(submission_id, "get_submission_processing_log")
+        events, total = self.detection_service.get_submission_processing_log(
+            submission_id, skip, limit, stage, warnings_only
+        )
+        return ProcessingLogPageDto(
+            items=[ProcessingEventDto.model_validate(event.model_dump()) for event in events],
+            total=total,
+            skip=skip,
+            limit=limit,
+            has_more=skip + len(events) < total,
+        )
+
     def delete_submission(self, submission_id: UUID) -> CreateSubmissionResponseDto:
-        """Delete a submission"""
-        success = self.repository.delete(submission_id)
+        """
+        Soft delete a submission: it is no longer listed nor compared, its files and its results being kept until
+        it is restored or purged
+        """
+        submission = self.repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException(f"Submission with ID {submission_id} not found")
+        self.access.check_submission(submission, "delete_submission", manage=True)
+        if submission.deleted_at is not None:
+            raise ValidationException(f"Submission {submission_id} is already deleted")
+
+        submission = self.repository.soft_delete(submission_id)
+        return CreateSubmissionResponseDto(
+            success=True,
+            message="Submission deleted successfully",
+            submission_id=submission_id,
+            data=SubmissionResponseDto.model_validate(submission.model_dump()),
+        )
+
+    def restore_submission(self, submission_id: UUID) -> CreateSubmissionResponseDto:
+        """Restore a soft deleted submission, listed and compared again"""
+        submission = self.repository.get_by_id(submission_id)
+        if not submission:
+            raise NotFoundException(f"Submission with ID {submission_id} not found")
+        self.access.check_submission(submission, "restore_submission", manage=True)
+        if submission.deleted_at is None:
+            raise ValidationException(f"Submission {submission_id} is not deleted")
+
+        submission = self.repository.restore(submission_id)
+        return CreateSubmissionResponseDto(
+            success=True,
+            message="Submission restored successfully",
+            submission_id=submission_id,
+            data=SubmissionResponseDto.model_validate(submission.model_dump()),
+        )
 
+    def purge_submission(self, submission_id: UUID) -> CreateSubmissionResponseDto:
+        """Delete a submission for good, with its results and the files kept in the storage backend"""
+        self.access.check_admin("purge_submission", "submission", submission_id)
+        self.detection_service.purge_submission(submission_id)
         return CreateSubmissionResponseDto(
-            success=success, message="Submission deleted successfully", submission_id=submission_id
+            success=True, message="Submission purged successfully", submission_id=submission_id
         )
 
-    def list_submissions(self, skip: int = 0, limit: int = 100) -> List[SubmissionResponseDto]:
-        """List all submissions with pagination"""
+    def release_quarantined_submission(self, submission_id: UUID) -> CreateSubmissionResponseDto:
+        """Release a quarantined submission, its files being downloadable and compared again"""
+        self.access.check_admin("release_quarantined_submission", "submission", submission_id)
+        submission = self.detection_service.release_quarantined_submission(submission_id)
+        return CreateSubmissionResponseDto(
+            success=True,
+            message="Submission released from quarantine successfully",
+            submission_id=submission.id,
+            data=SubmissionResponseDto.model_validate(submission.model_dump()),
+        )
+
+    def get_retention_policy(self, project_uuid: UUID, project_step_uuid: UUID) -> RetentionPolicyResponseDto:
+        """Get the retention policy of a project step"""
+        self.access.check_step(project_step_uuid, "get_retention_policy")
+        policy = self.detection_service.get_data_retention().get_policy(project_uuid, project_step_uuid)
+        return RetentionPolicyResponseDto.model_validate(policy.model_dump())
+
+    def save_retention_policy(
+        self, project_uuid: UUID, project_step_uuid: UUID, policy_data: RetentionPolicyDto
+    ) -> RetentionPolicyResponseDto:
+        """Set the retention policy of a project step, its expired files and results being purged"""
+        self.access.check_admin("save_retention_policy", "project_step", project_step_uuid)
+        retention = self.detection_service.get_data_retention()
+        policy = retention.save_policy(project_uuid, project_step_uuid, policy_data.model_dump())
+        return RetentionPolicyResponseDto.model_validate(policy.model_dump())
+
+    def delete_retention_policy(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the retention policy of a project step"""
+        self.access.check_admin("delete_retention_policy", "project_step", project_step_uuid)
+        return self.detection_service.get_data_retention().delete_policy(project_uuid, project_step_uuid)
+
+    def purge_expired_data(self, dry_run: bool = False) -> List[RetentionPurgeReportDto]:
+        """Purge the expired files and results of the project steps, or report what would be purged in dry run"""
+        self.access.check_admin("purge_expired_data", "retention_policy")
+        reports = self.detection_service.get_data_retention().purge_expired(get_paris_time(), dry_run)
+        return [RetentionPurgeReportDto(**report) for report in reports]
+
+    def get_retention_purges(self, project_uuid: Optional[UUID] = None, limit: int = 100) -> List[RetentionPurgeDto]:
+        """Get the log of the latest purges of the expired files and results, latest first"""
+        self.access.check_admin("get_retention_purges", "retention_purge")
+        purges = self.detection_service.get_data_retention().get_purges(project_uuid, limit)
+        return [RetentionPurgeDto.model_validate(purge.model_dump()) for purge in purges]
+
+    def purge_token_cache(self, language: str) -> TokenCachePurgeResponseDto:
+        """Delete the cached token streams of a language, after a tokenizer fix"""
+        self.access.check_admin("purge_token_cache", "token_cache", language)
+        return TokenCachePurgeResponseDto(**self.detection_service.purge_token_cache(language))
+
+    def list_submissions(
+        self,
+        skip: int = 0,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+        sort_by: SubmissionSortField = SubmissionSortField.CREATED_AT,
+        order: SortOrder = SortOrder.DESC,
+        filters: Optional[Dict[str, Any]] = None,
+    ) -> SubmissionPageDto:
+        """
+        List a page of the submissions matching the filters, sorted by a field then by ID, the page starting after
+        the cursor of the previous page if given (skip then counting from the cursor), only those the caller may
+        read being listed
+
+        Raises:
+            BadRequestException: If the cursor is invalid or the creation dates are inverted
+        """
         if limit > 1000:  # Prevent excessive data retrieval
             limit = 1000
 
-        submissions = self.repository.list_all(skip=skip, limit=limit)
-        return [SubmissionResponseDto.model_validate(sub.model_dump()) for sub in submissions]
+        filters = {**(filters or {}), **self.access.submission_filters(), **self.access.tenant_filters()}
+        if filters.get("created_after") and filters.get("created_before"):
+            try:
+                inverted = filters["created_after"] >= filters["created_before"]
+            except TypeError:
+                raise BadRequestException("created_after and created_before must both have a timezone, or neither")
+            if inverted:
+                raise BadRequestException("created_after must be before created_before")
+
+        after = self._decode_cursor(cursor, sort_by, order) if cursor else None
+        submissions, total, has_more = self.repository.list_page(
+            filters, sort_by.value, order == SortOrder.DESC, skip, limit, after
+        )
+        return SubmissionPageDto(
+            items=[SubmissionResponseDto.model_validate(sub.model_dump()) for sub in submissions],
+            total=total,
+            skip=skip,
+            limit=limit,
+            has_more=has_more,
+            next_cursor=self._encode_cursor(submissions[-1], sort_by, order) if has_more else None,
+        )
+
+    @staticmethod
+    def _encode_cursor(submission: Submission, sort_by: SubmissionSortField, order: SortOrder) -> str:
+        """Cursor of the page following a submission: its sort value and ID, with the sort they come from"""
+        if sort_by == SubmissionSortField.CREATED_AT:
+            value = submission.created_at.isoformat()
+        elif sort_by == SubmissionSortField.GROUP:
+            value = str(submission.group_uuid)
+        elif sort_by == SubmissionSortField.STATUS:
+            value = SubmissionStatus(submission.status).value
+        else:
+            value = submission.language or ""
+        payload = {"sort": sort_by.value, "order": order.value, "value": value, "id": str(submission.id)}
+        return base64.urlsafe_b64encode(json.dumps(payload).encode("utf-8")).decode("ascii").rstrip("=")
+
+    @staticmethod
+    def _decode_cursor(cursor: str, sort_by: SubmissionSortField, order: SortOrder) -> Tuple[Any, UUID]:
+        """
+        Sort value and ID of the last submission of the previous page
+
+        Raises:
+            BadRequestException: If the cursor is malformed or comes from another sort
+        """
+        try:
+            payload = json.loads(base64.urlsafe_b64decode(cursor + "=" * (-len(cursor) % 4)))
+            sort, cursor_order, value, last_id = payload["sort"], payload["order"], payload["value"], payload["id"]
+            if not all(isinstance(field, str) for field in (sort, cursor_order, value, last_id)):
+                raise ValueError("the fields of the cursor must be strings")
+        except (ValueError, KeyError, TypeError) as e:
+            raise BadRequestException(f"Invalid cursor: {str(e)}")
+        if sort != sort_by.value or cursor_order != order.value:
+            raise BadRequestException("The cursor comes from a listing with another sort, restart from skip 0")
+        try:
+            if sort_by == SubmissionSortField.CREATED_AT:
+                value = datetime.fromisoformat(value)
+            elif sort_by == SubmissionSortField.GROUP:
+                value = UUID(value)
+            elif sort_by == SubmissionSortField.STATUS:
+                value = SubmissionStatus(value)
+            return value, UUID(last_id)
+        except ValueError as e:
+            raise BadRequestException(f"Invalid cursor: {str(e)}")
 
     def get_submission_statistics(self, project_uuid: UUID, group_uuid: UUID) -> dict:
-        """Get submission statistics for a project and group"""
-        submissions = self.repository.get_by_project_and_group(project_uuid, group_uuid)
+        """Get submission statistics for a project and group, over the submissions the caller may read"""
+        submissions = self.access.readable(self.repository.get_by_project_and_group(project_uuid, group_uuid))
 
         total_count = len(submissions)
         status_counts = {}
@@ -342,20 +1320,673 @@ class SubmissionService:
         if submission_data.description and len(submission_data.description) > 1000:
             raise ValidationException("Description cannot exceed 1000 characters")
 
-    def get_submission_similarities(self, submission_id: UUID) -> List[dict]:
-        """Get all similarity results for a submission"""
-        return self.detection_service.get_submission_similarities(submission_id)
+    def get_submission_similarities(
+        self, submission_id: UUID, flag_threshold: Optional[float] = None, min_token_count: Optional[int] = None
+    ) -> dict:
+        """Get all similarity results for a submission, flagged with the given threshold and minimum token count"""
+        self._check_results("submission", submission_id, "get_submission_similarities")
+        return self.detection_service.get_submission_similarities(submission_id, flag_threshold, min_token_count)
+
+    def get_originality_feedback(self, submission_id: UUID) -> OriginalityFeedbackDto:
+        """
+        Get the anonymized originality feedback of a submission, readable by its student unlike the pairwise
+        results: the submissions it matches are withheld
+        """
+        self._check_submission(submission_id, "get_originality_feedback")
+        return OriginalityFeedbackDto(**self.detection_service.get_originality_feedback(submission_id))
 
     def get_detailed_comparison(self, similarity_id: UUID) -> dict:
         """Get detailed comparison results including visualization data"""
+        self._check_results("similarity", similarity_id, "get_detailed_comparison")
         return self.detection_service.get_detailed_comparison(similarity_id)
 
-    def get_project_step_statistics(self, project_uuid: UUID, project_step_uuid: UUID) -> dict:
+    def get_comparison_report(self, similarity_id: UUID) -> str:
+        """Get the HTML report of the comparison of a pair of submissions"""
+        self._check_results("similarity", similarity_id, "get_comparison_report")
+        return self.detection_service.get_comparison_report(similarity_id)
+
+    def request_comparison_report_pdf(self, similarity_id: UUID) -> ReportJobResponseDto:
+        """Request the PDF report of the comparison of a pair of submissions, rendered in the background"""
+        self._check_results("similarity", similarity_id, "request_comparison_report_pdf")
+        return self._to_report_job_response(self.detection_service.request_comparison_report_pdf(similarity_id))
+
+    def request_evidence_package(self, run_id: UUID, similarity_id: UUID) -> ReportJobResponseDto:
+        """Request the evidence package of a pair of a detection run, built in the background"""
+        self._check_results("detection_run", run_id, "request_evidence_package")
+        job = self.detection_service.get_evidence_packages().request(run_id, similarity_id)
+        return self._to_report_job_response(job)
+
+    def get_report_job(self, job_id: UUID) -> ReportJobResponseDto:
+        """Get the status of the rendering of a PDF report or of an evidence package"""
+        self._check_results("report_job", job_id, "get_report_job")
+        return self._to_report_job_response(self.detection_service.get_report_job(job_id))
+
+    def get_report_job_content(self, job_id: UUID) -> bytes:
+        """Get the rendered PDF report or evidence package of a completed report job"""
+        self._check_results("report_job", job_id, "get_report_job_content")
+        return self.detection_service.get_report_job_content(job_id)
+
+    def get_project_step_statistics(
+        self,
+        project_uuid: UUID,
+        project_step_uuid: UUID,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+    ) -> dict:
         """Get similarity statistics for a project step"""
-        return self.detection_service.get_project_step_statistics(project_uuid, project_step_uuid)
+        self.access.check_results(project_step_uuid, "get_project_step_statistics", "project_step", project_step_uuid)
+        return self.detection_service.get_project_step_statistics(
+            project_uuid, project_step_uuid, flag_threshold, min_token_count
+        )
+
+    def get_language_statistics(self, project_uuid: UUID, project_step_uuid: UUID) -> LanguageStatisticsDto:
+        """Get the languages the latest submissions of a project step are written in"""
+        self.access.check_results(project_step_uuid, "get_language_statistics", "project_step", project_step_uuid)
+        return LanguageStatisticsDto(**self.detection_service.get_language_statistics(project_uuid, project_step_uuid))
 
     def get_high_similarity_alerts(
         self, project_uuid: UUID, project_step_uuid: UUID, threshold: float = 0.7
     ) -> List[dict]:
         """Get high similarity alerts for a project step"""
+        self.access.check_results(project_step_uuid, "get_high_similarity_alerts", "project_step", project_step_uuid)
         return self.detection_service.get_high_similarity_alerts(project_uuid, project_step_uuid, threshold)
+
+    def create_baseline(
+        self, project_uuid: UUID, project_step_uuid: UUID, baseline_data: CreateBaselineDto
+    ) -> BaselineResponseDto:
+        """Attach starter code to a project step, subtracted from the similarity of its submissions"""
+        self.access.check_step(project_step_uuid, "create_baseline")
+        baseline = self.detection_service.create_baseline(project_uuid, project_step_uuid, baseline_data)
+        return self._to_baseline_response(baseline)
+
+    def get_baselines(self, project_uuid: UUID, project_step_uuid: UUID) -> List[BaselineResponseDto]:
+        """Get the starter code baselines of a project step"""
+        self.access.check_step(project_step_uuid, "get_baselines")
+        baselines = self.detection_service.get_baselines(project_uuid, project_step_uuid)
+        return [self._to_baseline_response(baseline) for baseline in baselines]
+
+    def delete_baseline(self, baseline_id: UUID) -> bool:
+        """Delete a starter code baseline"""
+        self._check_step_resource("baseline", baseline_id, "delete_baseline")
+        return self.detection_service.delete_baseline(baseline_id)
+
+    def create_allowed_snippet(
+        self, project_uuid: UUID, project_step_uuid: UUID, snippet_data: CreateAllowedSnippetDto
+    ) -> AllowedSnippetResponseDto:
+        """Allow the students of a project step to copy a snippet, its matches being excluded from their scores"""
+        self.access.check_step(project_step_uuid, "create_allowed_snippet")
+        snippet = self.detection_service.create_allowed_snippet(project_uuid, project_step_uuid, snippet_data)
+        return self._to_allowed_snippet_response(snippet)
+
+    def get_allowed_snippets(self, project_uuid: UUID, project_step_uuid: UUID) -> List[AllowedSnippetResponseDto]:
+        """Get the allowed snippets of a project step"""
+        self.access.check_step(project_step_uuid, "get_allowed_snippets")
+        snippets = self.detection_service.get_allowed_snippets(project_uuid, project_step_uuid)
+        return [self._to_allowed_snippet_response(snippet) for snippet in snippets]
+
+    def get_allowed_snippet(self, snippet_id: UUID) -> AllowedSnippetResponseDto:
+        """Get an allowed snippet"""
+        self._check_step_resource("allowed_snippet", snippet_id, "get_allowed_snippet")
+        return self._to_allowed_snippet_response(self.detection_service.get_allowed_snippet(snippet_id))
+
+    def update_allowed_snippet(
+        self, snippet_id: UUID, snippet_data: UpdateAllowedSnippetDto
+    ) -> AllowedSnippetResponseDto:
+        """Update an allowed snippet"""
+        self._check_step_resource("allowed_snippet", snippet_id, "update_allowed_snippet")
+        snippet = self.detection_service.update_allowed_snippet(snippet_id, snippet_data)
+        return self._to_allowed_snippet_response(snippet)
+
+    def delete_allowed_snippet(self, snippet_id: UUID) -> bool:
+        """Delete an allowed snippet"""
+        self._check_step_resource("allowed_snippet", snippet_id, "delete_allowed_snippet")
+        return self.detection_service.delete_allowed_snippet(snippet_id)
+
+    def get_file_filter_config(self, project_uuid: UUID, project_step_uuid: UUID) -> FileFilterConfigDto:
+        """Get the files allowed in the uploads of a project step"""
+        self.access.check_step(project_step_uuid, "get_file_filter_config", students=True)
+        return FileFilterConfigDto(**self.detection_service.get_file_filter_config(project_uuid, project_step_uuid))
+
+    def save_file_filter_config(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: FileFilterConfigDto
+    ) -> FileFilterConfigDto:
+        """Save the files allowed in the uploads of a project step"""
+        self.access.check_step(project_step_uuid, "save_file_filter_config")
+        self.detection_service.save_file_filter_config(project_uuid, project_step_uuid, config_data.model_dump())
+        return self.get_file_filter_config(project_uuid, project_step_uuid)
+
+    def get_upload_limits(self, project_uuid: UUID, project_step_uuid: UUID) -> EffectiveUploadLimitsDto:
+        """Get the limits enforced on the uploads of a project step"""
+        self.access.check_step(project_step_uuid, "get_upload_limits", students=True)
+        return EffectiveUploadLimitsDto(**self.detection_service.get_upload_limits(project_uuid, project_step_uuid))
+
+    def save_upload_limits(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: UploadLimitsDto
+    ) -> EffectiveUploadLimitsDto:
+        """Override the limits of the uploads of a project step"""
+        self.access.check_step(project_step_uuid, "save_upload_limits")
+        self.detection_service.save_upload_limits(project_uuid, project_step_uuid, config_data.model_dump())
+        return self.get_upload_limits(project_uuid, project_step_uuid)
+
+    def get_ignore_rules_config(self, project_uuid: UUID, project_step_uuid: UUID) -> EffectiveIgnoreRulesDto:
+        """Get the paths left out of the submissions of a project step"""
+        self.access.check_step(project_step_uuid, "get_ignore_rules_config", students=True)
+        return EffectiveIgnoreRulesDto(
+            **self.detection_service.get_ignore_rules_config(project_uuid, project_step_uuid)
+        )
+
+    def save_ignore_rules_config(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: IgnoreRulesConfigDto
+    ) -> EffectiveIgnoreRulesDto:
+        """Save the paths left out of the submissions of a project step, the built-in ones if no patterns are given"""
+        self.access.check_step(project_step_uuid, "save_ignore_rules_config")
+        self.detection_service.save_ignore_rules_config(project_uuid, project_step_uuid, config_data.model_dump())
+        return self.get_ignore_rules_config(project_uuid, project_step_uuid)
+
+    def get_grading_callback(self, project_uuid: UUID, project_step_uuid: UUID) -> GradingCallbackResponseDto:
+        """Get the grading callback of a project step, without its secret"""
+        self.access.check_step(project_step_uuid, "get_grading_callback")
+        config = self.detection_service.get_grading_callbacks().get(project_uuid, project_step_uuid)
+        return GradingCallbackResponseDto.model_validate(config.model_dump())
+
+    def save_grading_callback(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: GradingCallbackDto
+    ) -> SavedGradingCallbackResponseDto:
+        """Set the grading callback of a project step, returning the secret of its signatures"""
+        self.access.check_step(project_step_uuid, "save_grading_callback")
+        config = self.detection_service.get_grading_callbacks().save(
+            project_uuid, project_step_uuid, config_data.model_dump()
+        )
+        return SavedGradingCallbackResponseDto.model_validate(config.model_dump())
+
+    def delete_grading_callback(self, project_uuid: UUID, project_step_uuid: UUID) -> bool:
+        """Delete the grading callback of a project step"""
+        self.access.check_step(project_step_uuid, "delete_grading_callback")
+        return self.detection_service.get_grading_callbacks().delete(project_uuid, project_step_uuid)
+
+    def get_grading_callback_delivery(self, run_id: UUID) -> GradingCallbackDeliveryDto:
+        """Get the delivery of the results of a detection run to the grading service"""
+        self._check_results("detection_run", run_id, "get_grading_callback_delivery")
+        run, _ = self.detection_service.get_detection_run(run_id)
+        return self._grading_callback_delivery_dto(run)
+
+    def redeliver_grading_callback(self, run_id: UUID) -> GradingCallbackDeliveryDto:
+        """Post again the results of a completed detection run to the grading service"""
+        self._check_results("detection_run", run_id, "redeliver_grading_callback")
+        run = self.detection_service.get_grading_callbacks().redeliver(run_id)
+        return self._grading_callback_delivery_dto(run)
+
+    @staticmethod
+    def _grading_callback_delivery_dto(run: SubmissionDetectionRun) -> GradingCallbackDeliveryDto:
+        return GradingCallbackDeliveryDto(
+            run_id=run.id,
+            status=run.grading_callback_status,
+            schema_version=GRADING_SCHEMA_VERSION,
+            attempts=run.grading_callback_attempts or [],
+            delivered_at=run.grading_callback_delivered_at,
+        )
+
+    def get_step_schedule(self, project_uuid: UUID, project_step_uuid: UUID) -> StepScheduleResponseDto:
+        """Get the grading deadline of a project step, with the priority of its analyses"""
+        self.access.check_step(project_step_uuid, "get_step_schedule")
+        return StepScheduleResponseDto(**self.detection_service.get_step_schedule(project_uuid, project_step_uuid))
+
+    def save_step_schedule(
+        self, project_uuid: UUID, project_step_uuid: UUID, schedule_data: StepScheduleDto
+    ) -> StepScheduleResponseDto:
+        """Set the grading deadline of a project step, re-ordering its queued analyses, and its scheduled run"""
+        self.access.check_step(project_step_uuid, "save_step_schedule")
+        return StepScheduleResponseDto(
+            **self.detection_service.save_step_schedule(project_uuid, project_step_uuid, schedule_data.model_dump())
+        )
+
+    def cancel_scheduled_detection_run(self, project_uuid: UUID, project_step_uuid: UUID) -> StepScheduleResponseDto:
+        """Cancel the detection run scheduled for a project step"""
+        self.access.check_step(project_step_uuid, "cancel_scheduled_detection_run")
+        return StepScheduleResponseDto(
+            **self.detection_service.cancel_scheduled_detection_run(project_uuid, project_step_uuid)
+        )
+
+    def get_header_config(self, project_uuid: UUID, project_step_uuid: UUID) -> HeaderConfigDto:
+        """Get the license and file header stripping configuration of a project step"""
+        self.access.check_step(project_step_uuid, "get_header_config")
+        return HeaderConfigDto(**self.detection_service.get_header_config(project_uuid, project_step_uuid))
+
+    def save_header_config(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: HeaderConfigDto
+    ) -> HeaderConfigDto:
+        """Configure the license and file headers stripped from the files of a project step"""
+        self.access.check_step(project_step_uuid, "save_header_config")
+        self.detection_service.save_header_config(project_uuid, project_step_uuid, config_data.model_dump())
+        return self.get_header_config(project_uuid, project_step_uuid)
+
+    def get_detection_config(self, project_uuid: UUID, project_step_uuid: UUID) -> DetectionConfigDto:
+        """Get the similarity flagging configuration of a project step"""
+        self.access.check_step(project_step_uuid, "get_detection_config")
+        return DetectionConfigDto(**self.detection_service.get_detection_config(project_uuid, project_step_uuid))
+
+    def save_detection_config(
+        self, project_uuid: UUID, project_step_uuid: UUID, config_data: DetectionConfigDto
+    ) -> DetectionConfigDto:
+        """Configure the threshold and minimum token count flagging the similarity results of a project step"""
+        self.access.check_step(project_step_uuid, "save_detection_config")
+        self.detection_service.save_detection_config(project_uuid, project_step_uuid, config_data.model_dump())
+        return self.get_detection_config(project_uuid, project_step_uuid)
+
+    def create_webhook(self, webhook_data: CreateWebhookDto) -> CreateWebhookResponseDto:
+        """Register a webhook, returning the secret of its signatures once"""
+        self.access.check_global("create_webhook", "webhook")
+        webhook = self.detection_service.get_webhook_registry().create(webhook_data.model_dump())
+        return CreateWebhookResponseDto.model_validate(webhook.model_dump())
+
+    def get_webhooks(self, project_uuid: Optional[UUID] = None) -> List[WebhookResponseDto]:
+        """Get the registered webhooks"""
+        self.access.check_global("get_webhooks", "webhook")
+        return [
+            WebhookResponseDto.model_validate(webhook.model_dump())
+            for webhook in self.detection_service.get_webhook_registry().get_all(project_uuid)
+        ]
+
+    def get_webhook(self, webhook_id: UUID) -> WebhookResponseDto:
+        """Get a registered webhook, without its secret"""
+        self.access.check_global("get_webhook", "webhook", webhook_id)
+        webhook = self.detection_service.get_webhook_registry().get(webhook_id)
+        return WebhookResponseDto.model_validate(webhook.model_dump())
+
+    def delete_webhook(self, webhook_id: UUID) -> bool:
+        """Delete a webhook with the log of its deliveries"""
+        self.access.check_global("delete_webhook", "webhook", webhook_id)
+        return self.detection_service.get_webhook_registry().delete(webhook_id)
+
+    def get_webhook_deliveries(
+        self, webhook_id: UUID, status: Optional[WebhookDeliveryStatus] = None, limit: int = 50
+    ) -> List[WebhookDeliveryResponseDto]:
+        """Get the log of the latest deliveries of a webhook"""
+        self.access.check_global("get_webhook_deliveries", "webhook", webhook_id)
+        return [
+            WebhookDeliveryResponseDto.model_validate(delivery.model_dump())
+            for delivery in self.detection_service.get_webhook_registry().get_deliveries(webhook_id, status, limit)
+        ]
+
+    def create_analysis_profile(self, profile_data: CreateAnalysisProfileDto) -> AnalysisProfileResponseDto:
+        """Store a named bundle of detection options, referenced by the detection runs"""
+        self.access.check_global("create_analysis_profile", "analysis_profile", profile_data.name)
+        profile = self.detection_service.get_analysis_profile_catalog().create(profile_data.model_dump())
+        return AnalysisProfileResponseDto.model_validate(profile)
+
+    def get_analysis_profiles(self) -> List[AnalysisProfileResponseDto]:
+        """Get the analysis profiles, the built-in default profile first"""
+        return [
+            AnalysisProfileResponseDto.model_validate(profile)
+            for profile in self.detection_service.get_analysis_profile_catalog().get_all()
+        ]
+
+    def get_analysis_profile(self, name: str) -> AnalysisProfileResponseDto:
+        """Get an analysis profile with its full detection options"""
+        profile = self.detection_service.get_analysis_profile_catalog().get(name)
+        return AnalysisProfileResponseDto.model_validate(profile)
+
+    def update_analysis_profile(self, name: str, profile_data: UpdateAnalysisProfileDto) -> AnalysisProfileResponseDto:
+        """Replace the options of an analysis profile, the runs already started keeping theirs"""
+        self.access.check_global("update_analysis_profile", "analysis_profile", name)
+        profile = self.detection_service.get_analysis_profile_catalog().update(name, profile_data.model_dump())
+        return AnalysisProfileResponseDto.model_validate(profile)
+
+    def delete_analysis_profile(self, name: str) -> bool:
+        """Delete an analysis profile"""
+        self.access.check_global("delete_analysis_profile", "analysis_profile", name)
+        return self.detection_service.get_analysis_profile_catalog().delete(name)
+
+    def create_detection_run(
+        self, project_uuid: UUID, project_step_uuid: UUID, run_data: CreateDetectionRunDto
+    ) -> DetectionRunResponseDto:
+        """Compare pairwise the submissions of a project step in the background"""
+        self.access.check_results(project_step_uuid, "create_detection_run", "project_step", project_step_uuid)
+        run = self.detection_service.create_detection_run(
+            project_uuid,
+            project_step_uuid,
+            run_data.submission_ids,
+            run_data.include_corpus,
+            run_data.teams,
+            run_data.include_same_team,
+            run_data.include_all_versions,
+            run_data.tokenization_timeout_seconds,
+            run_data.comparison_timeout_seconds,
+            run_data.profile,
+            run_data.overrides,
+            run_data.force_recompute,
+            run_data.attach,
+            run_data.include_references,
+            exclude_paths=run_data.exclude_paths,
+        )
+        return self._run_dto(*self.detection_service.get_detection_run(run.id))
+
+    def get_detection_run(self, run_id: UUID) -> DetectionRunResponseDto:
+        """Get a detection run with its progress"""
+        self._check_results("detection_run", run_id, "get_detection_run")
+        return self._run_dto(*self.detection_service.get_detection_run(run_id))
+
+    def cancel_detection_run(self, run_id: UUID) -> DetectionRunResponseDto:
+        """Cancel a running detection run, keeping the pairs already compared"""
+        self._check_results("detection_run", run_id, "cancel_detection_run")
+        return self._run_dto(*self.detection_service.cancel_detection_run(run_id))
+
+    def rescore_detection_run(self, run_id: UUID) -> DetectionRunResponseDto:
+        """Compare again the pairs of a finished detection run with the current baselines and allowed snippets"""
+        self._check_results("detection_run", run_id, "rescore_detection_run")
+        return self._run_dto(*self.detection_service.rescore_detection_run(run_id))
+
+    def verify_detection_run(self, run_id: UUID, verification: VerifyDetectionRunDto) -> DetectionRunVerificationDto:
+        """Compare again a sample of the pairs of a finished detection run, asserting the recorded results"""
+        self._check_results("detection_run", run_id, "verify_detection_run")
+        return DetectionRunVerificationDto.model_validate(
+            self.detection_service.verify_detection_run(run_id, verification.sample_size, verification.seed)
+        )
+
+    @staticmethod
+    def _run_dto(run: SubmissionDetectionRun, progress: Dict[str, Any]) -> DetectionRunResponseDto:
+        """Map a detection run and its progress to its DTO"""
+        return DetectionRunResponseDto.model_validate(
+            {
+                **run.model_dump(exclude={"submission_ids", "teams", "summary", "grading_callback_attempts"}),
+                **progress,
+                "submission_count": len(run.submission_ids),
+                "team_count": len(set((run.teams or {}).values())),
+            }
+        )
+
+    def get_similarity_matrix(
+        self,
+        run_id: UUID,
+        min_similarity: float = 0.0,
+        skip: int = 0,
+        limit: int = 100,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+        merge_threshold: float = DEFAULT_MERGE_THRESHOLD,
+        include_same_team: Optional[bool] = None,
+        flagged_only: bool = False,
+        sort_by: PairSortField = PairSortField.OVERALL_SIMILARITY,
+    ) -> SimilarityMatrixDto:
+        """Get the sparse similarity matrix of a detection run"""
+        self._check_results("detection_run", run_id, "get_similarity_matrix")
+        return SimilarityMatrixDto(
+            **self.detection_service.get_similarity_matrix(
+                run_id,
+                min_similarity,
+                skip,
+                limit,
+                flag_threshold,
+                min_token_count,
+                merge_threshold,
+                include_same_team,
+                flagged_only,
+                sort_by,
+            )
+        )
+
+    def iter_run_results(self, run_id: UUID, active: Callable[[], bool] = lambda: True) -> Iterator[MatrixPairDto]:
+        """Iterate over the compared pairs of a detection run as they are written, until the run is finished"""
+        self._check_results("detection_run", run_id, "iter_run_results")
+        for entry in self.detection_service.iter_run_results(run_id, active):
+            yield MatrixPairDto(**entry)
+
+    def get_similarity_clusters(
+        self,
+        run_id: UUID,
+        flag_threshold: Optional[float] = None,
+        min_token_count: Optional[int] = None,
+        merge_threshold: float = DEFAULT_MERGE_THRESHOLD,
+        include_same_team: Optional[bool] = None,
+    ) -> SimilarityClustersDto:
+        """Get the clusters of submissions sharing code of a detection run"""
+        self._check_results("detection_run", run_id, "get_similarity_clusters")
+        matrix = self.detection_service.get_similarity_matrix(
+            run_id,
+            flag_threshold=flag_threshold,
+            min_token_count=min_token_count,
+            merge_threshold=merge_threshold,
+            include_same_team=include_same_team,
+        )
+        return SimilarityClustersDto(**matrix)
+
+    def get_detection_run_report(self, run_id: UUID, min_similarity: Optional[float] = None) -> str:
+        """Get the HTML report of the reported pairs of a detection run"""
+        self._check_results("detection_run", run_id, "get_detection_run_report")
+        return self.detection_service.get_detection_run_report(run_id, min_similarity)
+
+    def get_detection_run_summary(
+        self, run_id: UUID, buckets: int = DEFAULT_SUMMARY_BUCKETS, top: int = DEFAULT_CENTRAL_SUBMISSIONS
+    ) -> DetectionRunSummaryDto:
+        """Get the score distribution of a detection run"""
+        self._check_results("detection_run", run_id, "get_detection_run_summary")
+        return DetectionRunSummaryDto(**self.detection_service.get_detection_run_summary(run_id, buckets, top))
+
+    def get_rare_token_evidence(
+        self,
+        run_id: UUID,
+        max_document_frequency: Optional[float] = None,
+        blend_weight: float = 0.0,
+        skip: int = 0,
+        limit: int = 100,
+    ) -> RareTokenEvidenceDto:
+        """Get the pairs of submissions of a detection run sharing rare identifiers or string literals"""
+        self._check_results("detection_run", run_id, "get_rare_token_evidence")
+        return RareTokenEvidenceDto(
+            **self.detection_service.get_rare_token_evidence(run_id, max_document_frequency, blend_weight, skip, limit)
+        )
+
+    def export_detection_run(
+        self,
+        run_id: UUID,
+        export_format: str,
+        min_similarity: float = 0.0,
+        flagged_only: bool = False,
+        include_metrics: bool = False,
+        scope: str = "pairs",
+    ) -> Tuple[Iterator[str], str]:
+        """Get the streamed export of the pairs (or reference matches) of a detection run, and the exported file name"""
+        self._check_results("detection_run", run_id, "export_detection_run")
+        return self.detection_service.export_detection_run(
+            run_id, export_format, min_similarity, flagged_only, include_metrics, scope
+        )
+
+    def create_corpus(self, corpus_data: CreateCorpusDto) -> CorpusResponseDto:
+        """Create a reference corpus of archived submissions"""
+        self.access.check_global("create_corpus", "corpus")
+        return self._to_corpus_response(self.detection_service.get_corpora().create(corpus_data.model_dump()))
+
+    def get_corpus_items(self, corpus_id: UUID) -> List[CorpusItemResponseDto]:
+        """Get the archived submissions of a corpus"""
+        self.access.check_global("get_corpus_items", "corpus", corpus_id)
+        items = self.detection_service.get_corpora().get_items(corpus_id)
+        return [self._to_corpus_item_response(item) for item in items]
+
+    def create_corpus_item(self, corpus_id: UUID, item_data: CreateCorpusItemDto) -> CorpusItemResponseDto:
+        """Archive a past submission in a corpus, fingerprinted once"""
+        self.access.check_global("create_corpus_item", "corpus", corpus_id)
+        item = self.detection_service.get_corpora().create_item(corpus_id, item_data.model_dump())
+        return self._to_corpus_item_response(item)
+
+    def get_step_corpora(self, project_uuid: UUID, project_step_uuid: UUID) -> List[CorpusResponseDto]:
+        """Get the corpora referenced by a project step"""
+        self.access.check_step(project_step_uuid, "get_step_corpora")
+        corpora = self.detection_service.get_corpora().get_step_corpora(project_uuid, project_step_uuid)
+        return [self._to_corpus_response(corpus) for corpus in corpora]
+
+    def save_step_corpora(
+        self, project_uuid: UUID, project_step_uuid: UUID, corpora_data: StepCorporaDto
+    ) -> List[CorpusResponseDto]:
+        """Reference the corpora matched with the submissions of a project step"""
+        self.access.check_step(project_step_uuid, "save_step_corpora")
+        corpora = self.detection_service.get_corpora()
+        corpora.save_step_corpora(project_uuid, project_step_uuid, corpora_data.corpus_ids)
+        return self.get_step_corpora(project_uuid, project_step_uuid)
+
+    def create_reference_set(
+        self,
+        content: bytes,
+        filename: Optional[str],
+        label: str,
+        license_note: Optional[str] = None,
+        description: Optional[str] = None,
+    ) -> CorpusResponseDto:
+        """Import an external reference set from an archive of source files, fingerprinted once"""
+        self.access.check_admin("create_reference_set", "reference_set")
+        reference_set = self.detection_service.get_reference_sets().create(
+            content, filename or "reference-set", label, license_note, description
+        )
+        return self._to_corpus_response(reference_set)
+
+    def get_reference_sets(self) -> List[CorpusResponseDto]:
+        """Get the external reference sets"""
+        self.access.check_admin("get_reference_sets", "reference_set")
+        return [self._to_corpus_response(corpus) for corpus in self.detection_service.get_reference_sets().get_all()]
+
+    def refresh_reference_set(
+        self, reference_set_id: UUID, content: bytes, filename: Optional[str], license_note: Optional[str] = None
+    ) -> CorpusResponseDto:
+        """Replace the files of a reference set by those of a new upload of its archive"""
+        self.access.check_admin("refresh_reference_set", "reference_set", reference_set_id)
+        reference_set = self.detection_service.get_reference_sets().refresh(
+            reference_set_id, content, filename or "reference-set", get_paris_time(), license_note
+        )
+        return self._to_corpus_response(reference_set)
+
+    def search_code(self, search_data: CodeSearchDto) -> CodeSearchResponseDto:
+        """Search the submissions of a project step for the fragments of a code snippet"""
+        project_step_uuid = search_data.project_step_uuid
+        self.access.check_results(project_step_uuid, "search_code", "project_step", project_step_uuid)
+        return CodeSearchResponseDto(**self.detection_service.search_code(search_data))
+
+    def compare_external_source(
+        self, submission_id: UUID, comparison_data: ExternalComparisonDto
+    ) -> ExternalComparisonResponseDto:
+        """Compare a submission with pasted code or a fetched URL, the result being optionally kept as evidence"""
+        self._check_results("submission", submission_id, "compare_external_source")
+        return ExternalComparisonResponseDto(
+            **self.detection_service.compare_external_source(submission_id, comparison_data)
+        )
+
+    def get_submission_metrics(self, submission_id: UUID) -> CodeMetricsDto:
+        """Get the code metrics of a submission, per file and aggregated"""
+        self._check_submission(submission_id, "get_submission_metrics")
+        return CodeMetricsDto(**self.detection_service.get_submission_metrics(submission_id))
+
+    def get_submission_evidence(self, submission_id: UUID) -> List[EvidenceResponseDto]:
+        """Get the external source comparisons attached to a submission"""
+        self._check_results("submission", submission_id, "get_submission_evidence")
+        evidence = self.detection_service.get_submission_evidence(submission_id)
+        return [EvidenceResponseDto.model_validate(item.model_dump()) for item in evidence]
+
+    def get_access_denials(self, subject: Optional[str] = None, limit: int = 100) -> List[AccessDenialDto]:
+        """Get the latest accesses denied to the callers, latest first, only those of a caller if given"""
+        self.access.check_admin("get_access_denials", "access_denial")
+        denials = self.detection_service.get_access_denials(subject, limit)
+        return [AccessDenialDto.model_validate(denial.model_dump()) for denial in denials]
+
+    def _check_submission(self, submission_id: UUID, action: str, manage: bool = False) -> None:
+        """Check that the caller may read (or manage) a submission, left to fail as not found if it does not exist"""
+        if self.access.principal is None:
+            return
+        submission = self.repository.get_by_id(submission_id)
+        if submission:
+            self.access.check_submission(submission, action, manage)
+
+    def _check_results(self, resource_type: str, resource_id: UUID, action: str) -> None:
+        """Check that the caller may read the results of a submission, comparison, report job or detection run"""
+        if self.access.principal is None:
+            return
+        if resource_type == "submission":
+            submission = self.repository.get_by_id(resource_id)
+            project_step_uuid = submission.project_step_uuid if submission else None
+        else:
+            project_step_uuid = self.detection_service.get_resource_project_step(resource_type, resource_id)
+        self.access.check_results(project_step_uuid, action, resource_type, resource_id)
+
+    def _check_step_resource(self, resource_type: str, resource_id: UUID, action: str) -> None:
+        """Check that the caller may act on a resource of a project step (baseline, bulk upload)"""
+        if self.access.principal is None:
+            return
+        project_step_uuid = self.detection_service.get_resource_project_step(resource_type, resource_id)
+        if project_step_uuid is not None:
+            self.access.check_step(project_step_uuid, action, resource_type, resource_id)
+
+    def _check_upload_session(self, upload_id: UUID, action: str) -> None:
+        """Check that the caller may go on with a resumable upload: one they could have initiated"""
+        if self.access.principal is None:
+            return
+        upload = self.detection_service.get_resumable_uploads().get(upload_id)
+        self.access.check_submission_creation(
+            upload.project_step_uuid, upload.group_uuid, upload.submitted_by_uuid, action
+        )
+
+    def _to_corpus_response(self, corpus: SubmissionCorpus) -> CorpusResponseDto:
+        return CorpusResponseDto.model_validate(
+            {**corpus.model_dump(), "item_count": len(self.detection_service.get_corpora().get_items(corpus.id))}
+        )
+
+    @staticmethod
+    def _to_corpus_item_response(item: SubmissionCorpusItem) -> CorpusItemResponseDto:
+        return CorpusItemResponseDto.model_validate(
+            {**item.model_dump(exclude={"fingerprints"}), "fingerprint_count": len(item.fingerprints or [])}
+        )
+
+    @staticmethod
+    def _malware_log_entries(malware_scan: dict) -> List[dict]:
+        """Entries of the processing log of a submission for its infected files, or for its files left unscanned"""
+        if malware_scan["verdict"] == "unscanned":
+            return [{"level": "warning", "message": f"Files not scanned for malware: {malware_scan['error']}"}]
+        return [
+            {"level": "error", "message": f"Infected file {infected['path']} ({infected['signature']}) quarantined"}
+            for infected in malware_scan["infected_files"]
+        ]
+
+    @staticmethod
+    def _archive_stem(filename: str) -> str:
+        """Name of an uploaded file without its archive extension"""
+        extension = next((ext for ext in NESTED_ARCHIVE_EXTENSIONS if filename.lower().endswith(ext)), "")
+        return filename[: len(filename) - len(extension)] or "submission"
+
+    @staticmethod
+    def _to_bulk_upload_job_response(job: SubmissionBulkUploadJob) -> BulkUploadJobResponseDto:
+        return BulkUploadJobResponseDto.model_validate(
+            {**job.model_dump(), "status_url": f"/submissions/bulk-upload-jobs/{job.id}"}
+        )
+
+    def _to_upload_session_response(self, upload: SubmissionUploadSession) -> UploadSessionResponseDto:
+        received_chunks = self.detection_service.get_resumable_uploads().received_chunks(upload)
+        received = set(received_chunks)
+        upload_url = f"/submissions/uploads/{upload.id}"
+        return UploadSessionResponseDto.model_validate(
+            {
+                **upload.model_dump(exclude={"description", "submitted_by_uuid", "allow_duplicates"}),
+                "received_chunks": received_chunks,
+                "missing_chunks": [index for index in range(upload.chunk_count) if index not in received],
+                "chunks_url": f"{upload_url}/chunks",
+                "finalize_url": f"{upload_url}/finalize",
+            }
+        )
+
+    @staticmethod
+    def _to_report_job_response(job: SubmissionReportJob) -> ReportJobResponseDto:
+        status_url = f"/submissions/report-jobs/{job.id}"
+        return ReportJobResponseDto.model_validate(
+            {
+                **job.model_dump(exclude={"content"}),
+                "status_url": status_url,
+                "download_url": f"{status_url}/download" if job.status == SimilarityStatus.COMPLETED else None,
+            }
+        )
+
+    @staticmethod
+    def _to_baseline_response(baseline: SubmissionBaseline) -> BaselineResponseDto:
+        return BaselineResponseDto.model_validate(
+            {**baseline.model_dump(exclude={"fingerprints"}), "fingerprint_count": len(baseline.fingerprints or [])}
+        )
+
+    @staticmethod
+    def _to_allowed_snippet_response(snippet: SubmissionAllowedSnippet) -> AllowedSnippetResponseDto:
+        return AllowedSnippetResponseDto.model_validate(
+            {**snippet.model_dump(exclude={"fingerprints"}), "fingerprint_count": len(snippet.fingerprints or [])}
+        )
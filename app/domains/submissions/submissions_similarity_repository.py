@@ -1,9 +1,9 @@
 import logging
 from datetime import datetime
-from typing import List, Optional
+from typing import Iterator, List, Optional
 from uuid import UUID
 
-from sqlalchemy import or_
+from sqlalchemy import delete, or_, update
 from sqlmodel import Session, select
 
 from app.domains.submissions.submissions_models import SimilarityStatus, SubmissionSimilarity
@@ -28,6 +28,16 @@ class SubmissionSimilarityRepository:
             self.session.rollback()
             raise DatabaseException(f"Failed to create similarity record: {str(e)}")
 
+    def create_batch(self, similarities_data: List[dict]) -> int:
+        """Create similarity records in one transaction, such as the compared pairs of a detection run"""
+        try:
+            self.session.add_all(SubmissionSimilarity(**similarity_data) for similarity_data in similarities_data)
+            self.session.commit()
+            return len(similarities_data)
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create similarity records: {str(e)}")
+
     def get_by_id(self, similarity_id: UUID) -> Optional[SubmissionSimilarity]:
         """Get similarity record by ID"""
         try:
@@ -68,6 +78,63 @@ class SubmissionSimilarityRepository:
         except Exception as e:
             raise DatabaseException(f"Failed to get similarity records for project step: {str(e)}")
 
+    def get_between_submissions(self, submission_ids: List[UUID]) -> List[SubmissionSimilarity]:
+        """Get the similarity records comparing two of the given submissions, in either order"""
+        try:
+            statement = (
+                select(SubmissionSimilarity)
+                .where(
+                    SubmissionSimilarity.submission_id.in_(submission_ids),
+                    SubmissionSimilarity.compared_submission_id.in_(submission_ids),
+                )
+                .order_by(SubmissionSimilarity.overall_similarity.desc(), SubmissionSimilarity.id)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get similarity records between submissions: {str(e)}")
+
+    def get_between_submissions_since(
+        self, submission_ids: List[UUID], since: Optional[datetime] = None
+    ) -> List[SubmissionSimilarity]:
+        """Get the similarity records comparing two of the given submissions created since a time, oldest first"""
+        try:
+            statement = select(SubmissionSimilarity).where(
+                SubmissionSimilarity.submission_id.in_(submission_ids),
+                SubmissionSimilarity.compared_submission_id.in_(submission_ids),
+            )
+            if since is not None:
+                statement = statement.where(SubmissionSimilarity.created_at >= since)
+            statement = statement.order_by(SubmissionSimilarity.created_at, SubmissionSimilarity.id)
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get similarity records between submissions: {str(e)}")
+
+    def iter_between_submissions(
+        self, submission_ids: List[UUID], batch_size: int = 500
+    ) -> Iterator[SubmissionSimilarity]:
+        """
+        Iterate over the similarity records comparing two of the given submissions, most similar first, loading
+        them by batches so that the records of a large run are not all held at once
+        """
+        statement = (
+            select(SubmissionSimilarity)
+            .where(
+                SubmissionSimilarity.submission_id.in_(submission_ids),
+                SubmissionSimilarity.compared_submission_id.in_(submission_ids),
+            )
+            .order_by(SubmissionSimilarity.overall_similarity.desc(), SubmissionSimilarity.id)
+        )
+        offset = 0
+        while True:
+            try:
+                batch = list(self.session.exec(statement.offset(offset).limit(batch_size)).all())
+            except Exception as e:
+                raise DatabaseException(f"Failed to get similarity records between submissions: {str(e)}")
+            yield from batch
+            if len(batch) < batch_size:
+                return
+            offset += batch_size
+
     def get_high_similarity_pairs(
         self, project_uuid: UUID, project_step_uuid: UUID, similarity_threshold: float = 0.7
     ) -> List[SubmissionSimilarity]:
@@ -86,6 +153,24 @@ class SubmissionSimilarityRepository:
         except Exception as e:
             raise DatabaseException(f"Failed to get high similarity pairs: {str(e)}")
 
+    def get_completed_by_cache_keys(
+        self, project_uuid: UUID, project_step_uuid: UUID, cache_keys: List[str], batch_size: int = 500
+    ) -> List[SubmissionSimilarity]:
+        """Get the completed similarity records of a project step with one of the given cache keys"""
+        try:
+            similarities = []
+            for start in range(0, len(cache_keys), batch_size):
+                statement = select(SubmissionSimilarity).where(
+                    SubmissionSimilarity.project_uuid == project_uuid,
+                    SubmissionSimilarity.project_step_uuid == project_step_uuid,
+                    SubmissionSimilarity.status == SimilarityStatus.COMPLETED,
+                    SubmissionSimilarity.cache_key.in_(cache_keys[start : start + batch_size]),
+                )
+                similarities.extend(self.session.exec(statement).all())
+            return similarities
+        except Exception as e:
+            raise DatabaseException(f"Failed to get similarity records by cache key: {str(e)}")
+
     def get_comparison_pair(self, submission_id: UUID, compared_submission_id: UUID) -> Optional[SubmissionSimilarity]:
         """Get specific comparison between two submissions"""
         try:
@@ -98,9 +183,13 @@ class SubmissionSimilarityRepository:
             raise DatabaseException(f"Failed to get comparison pair: {str(e)}")
 
     def update_status(
-        self, similarity_id: UUID, status: SimilarityStatus, error_message: Optional[str] = None
+        self,
+        similarity_id: UUID,
+        status: SimilarityStatus,
+        error_message: Optional[str] = None,
+        processing_time_seconds: Optional[float] = None,
     ) -> SubmissionSimilarity:
-        """Update the status of a similarity record"""
+        """Update the status of a similarity record, and the time taken by a comparison stopped early"""
         try:
             similarity = self.get_by_id(similarity_id)
             if not similarity:
@@ -111,6 +200,8 @@ class SubmissionSimilarityRepository:
 
             if error_message:
                 similarity.error_message = error_message
+            if processing_time_seconds is not None:
+                similarity.processing_time_seconds = processing_time_seconds
 
             self.session.add(similarity)
             self.session.commit()
@@ -129,26 +220,8 @@ class SubmissionSimilarityRepository:
             if not similarity:
                 raise NotFoundException(f"Similarity record with ID {similarity_id} not found")
 
-            # Update similarity metrics
-            similarity.jaccard_similarity = results.get("jaccard_similarity", 0.0)
-            similarity.type_similarity = results.get("type_similarity", 0.0)
-            similarity.overall_similarity = results.get("overall_similarity", 0.0)
-            similarity.shared_blocks_count = results.get("shared_blocks_count", 0)
-            similarity.average_shared_similarity = results.get("average_shared_similarity", 0.0)
-            similarity.structural_similarity = results.get("structural_similarity", 0.0)
-            similarity.type_sequence_similarity = results.get("type_sequence_similarity", 0.0)
-            similarity.flow_similarity = results.get("flow_similarity", 0.0)
-            similarity.operation_similarity = results.get("operation_similarity", 0.0)
-
-            # Update detailed results
-            similarity.similarity_details = results.get("similarity_details")
-            similarity.shared_blocks = results.get("shared_blocks")
-            similarity.visualization_data = results.get("visualization_data")
-
-            # Update timing and status
-            similarity.processing_time_seconds = results.get("processing_time_seconds")
-            similarity.status = SimilarityStatus.COMPLETED
-            similarity.updated_at = datetime.utcnow()
+            for field, value in self.results_fields(results).items():
+                setattr(similarity, field, value)
 
             self.session.add(similarity)
             self.session.commit()
@@ -160,6 +233,57 @@ class SubmissionSimilarityRepository:
             self.session.rollback()
             raise DatabaseException(f"Failed to update similarity results: {str(e)}")
 
+    @staticmethod
+    def results_fields(results: dict) -> dict:
+        """Fields of a completed similarity record holding the results of its comparison"""
+        return {
+            # Similarity metrics
+            "jaccard_similarity": results.get("jaccard_similarity", 0.0),
+            "type_similarity": results.get("type_similarity", 0.0),
+            "overall_similarity": results.get("overall_similarity", 0.0),
+            "shared_blocks_count": results.get("shared_blocks_count", 0),
+            "average_shared_similarity": results.get("average_shared_similarity", 0.0),
+            "structural_similarity": results.get("structural_similarity", 0.0),
+            "type_sequence_similarity": results.get("type_sequence_similarity", 0.0),
+            "flow_similarity": results.get("flow_similarity", 0.0),
+            "operation_similarity": results.get("operation_similarity", 0.0),
+            "max_function_similarity": results.get("max_function_similarity"),
+            # Detailed results
+            "similarity_details": results.get("similarity_details"),
+            "shared_blocks": results.get("shared_blocks"),
+            "visualization_data": results.get("visualization_data"),
+            # Timing and status
+            "processing_time_seconds": results.get("processing_time_seconds"),
+            "status": SimilarityStatus.COMPLETED,
+            "updated_at": datetime.utcnow(),
+            # Key of the comparison cache, None if it is not reusable
+            "cache_key": results.get("cache_key"),
+        }
+
+    @staticmethod
+    def cached_results(similarity: SubmissionSimilarity) -> dict:
+        """Results of a completed similarity record, copied to the records of the pairs of the same key"""
+        return {
+            field: getattr(similarity, field)
+            for field in (
+                "jaccard_similarity",
+                "type_similarity",
+                "overall_similarity",
+                "shared_blocks_count",
+                "average_shared_similarity",
+                "structural_similarity",
+                "type_sequence_similarity",
+                "flow_similarity",
+                "operation_similarity",
+                "max_function_similarity",
+                "similarity_details",
+                "shared_blocks",
+                "visualization_data",
+                "processing_time_seconds",
+                "cache_key",
+            )
+        }
+
     def delete(self, similarity_id: UUID) -> bool:
         """Delete a similarity record"""
         try:
@@ -199,6 +323,39 @@ class SubmissionSimilarityRepository:
             self.session.rollback()
             raise DatabaseException(f"Failed to delete similarity records for submission: {str(e)}")
 
+    def purge_details(self, similarity_ids: List[UUID]) -> int:
+        """
+        Empty the details of the given similarity records, keeping their scores, and take them out of the comparison
+        cache, returning their number
+        """
+        if not similarity_ids:
+            return 0
+        try:
+            result = self.session.execute(
+                update(SubmissionSimilarity)
+                .where(SubmissionSimilarity.id.in_(similarity_ids))
+                .values(similarity_details=None, shared_blocks=None, visualization_data=None, cache_key=None)
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to purge similarity details: {str(e)}")
+
+    def delete_by_ids(self, similarity_ids: List[UUID]) -> int:
+        """Delete the given similarity records, returning their number"""
+        if not similarity_ids:
+            return 0
+        try:
+            result = self.session.execute(
+                delete(SubmissionSimilarity).where(SubmissionSimilarity.id.in_(similarity_ids))
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete similarity records: {str(e)}")
+
     def get_statistics(self, project_uuid: UUID, project_step_uuid: UUID) -> dict:
         """Get similarity statistics for a project step"""
         try:
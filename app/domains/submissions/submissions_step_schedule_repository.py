@@ -0,0 +1,97 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from sqlalchemy import update
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import AutoRunStatus, SubmissionStepSchedule, get_paris_time
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionStepScheduleRepository:
+    """Repository for the grading deadlines of the project steps and their scheduled detection runs"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get_by_project_step(self, project_uuid: UUID, project_step_uuid: UUID) -> Optional[SubmissionStepSchedule]:
+        """Get the schedule of a project step"""
+        try:
+            statement = select(SubmissionStepSchedule).where(
+                SubmissionStepSchedule.project_uuid == project_uuid,
+                SubmissionStepSchedule.project_step_uuid == project_step_uuid,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get step schedule: {str(e)}")
+
+    def save(self, project_uuid: UUID, project_step_uuid: UUID, schedule_data: dict) -> SubmissionStepSchedule:
+        """Create or replace the schedule of a project step"""
+        try:
+            schedule = self.get_by_project_step(project_uuid, project_step_uuid)
+            if schedule:
+                for field, value in schedule_data.items():
+                    setattr(schedule, field, value)
+                schedule.updated_at = datetime.utcnow()
+            else:
+                schedule = SubmissionStepSchedule(
+                    project_uuid=project_uuid, project_step_uuid=project_step_uuid, **schedule_data
+                )
+
+            self.session.add(schedule)
+            self.session.commit()
+            self.session.refresh(schedule)
+            return schedule
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to save step schedule: {str(e)}")
+
+    def get_due(self, now: datetime) -> List[SubmissionStepSchedule]:
+        """Get the schedules whose detection run is due and not started yet, the oldest first"""
+        try:
+            statement = (
+                select(SubmissionStepSchedule)
+                .where(
+                    SubmissionStepSchedule.auto_run_status == AutoRunStatus.SCHEDULED,
+                    SubmissionStepSchedule.auto_run_at <= now,
+                )
+                .order_by(SubmissionStepSchedule.auto_run_at)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get due step schedules: {str(e)}")
+
+    def claim_auto_run(self, schedule_id: UUID, auto_run_at: datetime) -> bool:
+        """
+        Mark the scheduled run of a step started if it is still scheduled at the time it was found due at, in a
+        single statement so that a single instance starts it, and not once rescheduled. Returns whether it did.
+        """
+        try:
+            result = self.session.execute(
+                update(SubmissionStepSchedule)
+                .where(
+                    SubmissionStepSchedule.id == schedule_id,
+                    SubmissionStepSchedule.auto_run_status == AutoRunStatus.SCHEDULED,
+                    SubmissionStepSchedule.auto_run_at == auto_run_at,
+                )
+                .values(auto_run_status=AutoRunStatus.STARTED, auto_run_started_at=get_paris_time())
+            )
+            self.session.commit()
+            return result.rowcount > 0
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to claim scheduled detection run: {str(e)}")
+
+    def update(self, schedule_id: UUID, schedule_data: dict) -> None:
+        """Update the given fields of a schedule"""
+        try:
+            self.session.execute(
+                update(SubmissionStepSchedule).where(SubmissionStepSchedule.id == schedule_id).values(**schedule_data)
+            )
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update step schedule: {str(e)}")
@@ -0,0 +1,57 @@
+from typing import Optional
+
+from sqlalchemy import delete
+from sqlalchemy.exc import IntegrityError
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionTokenCacheEntry
+from app.shared.exceptions import DatabaseException
+
+
+class SubmissionTokenCacheRepository:
+    """Repository for the cached tokens of the file contents, keyed by content hash, tokenizer version and options"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def get(
+        self, content_hash: str, tokenizer_version: int, tokenization_key: str, language: str
+    ) -> Optional[SubmissionTokenCacheEntry]:
+        """Get the cached tokens of a content, None if it was not tokenized with this version and these options"""
+        try:
+            statement = select(SubmissionTokenCacheEntry).where(
+                SubmissionTokenCacheEntry.content_hash == content_hash,
+                SubmissionTokenCacheEntry.tokenizer_version == tokenizer_version,
+                SubmissionTokenCacheEntry.tokenization_key == tokenization_key,
+                SubmissionTokenCacheEntry.language == language,
+            )
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get cached tokens: {str(e)}")
+
+    def create(self, entry_data: dict) -> Optional[SubmissionTokenCacheEntry]:
+        """Cache the tokens of a content, None if another worker cached them meanwhile"""
+        try:
+            entry = SubmissionTokenCacheEntry(**entry_data)
+            self.session.add(entry)
+            self.session.commit()
+            self.session.refresh(entry)
+            return entry
+        except IntegrityError:
+            self.session.rollback()
+            return None
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to cache tokens: {str(e)}")
+
+    def delete_by_language(self, language: str) -> int:
+        """Delete the cached token streams of a language, whatever their version, returning their number"""
+        try:
+            result = self.session.execute(
+                delete(SubmissionTokenCacheEntry).where(SubmissionTokenCacheEntry.language == language)
+            )
+            self.session.commit()
+            return result.rowcount
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to purge cached tokens: {str(e)}")
@@ -0,0 +1,73 @@
+from datetime import datetime
+from typing import List, Optional
+from uuid import UUID
+
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import SubmissionUploadSession, UploadSessionStatus
+from app.shared.exceptions import DatabaseException, NotFoundException
+
+
+class SubmissionUploadSessionRepository:
+    """Repository for the resumable uploads, received in chunks"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, upload_data: dict) -> SubmissionUploadSession:
+        """Create a new upload session record"""
+        try:
+            upload = SubmissionUploadSession(**upload_data)
+            self.session.add(upload)
+            self.session.commit()
+            self.session.refresh(upload)
+            return upload
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create upload session: {str(e)}")
+
+    def get_by_id(self, upload_id: UUID) -> Optional[SubmissionUploadSession]:
+        """Get upload session record by ID, as last committed"""
+        try:
+            statement = select(SubmissionUploadSession).where(SubmissionUploadSession.id == upload_id)
+            return self.session.exec(statement.execution_options(populate_existing=True)).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get upload session: {str(e)}")
+
+    def get_by_status(self, statuses: List[UploadSessionStatus]) -> List[SubmissionUploadSession]:
+        """Get the upload session records in any of the given statuses"""
+        try:
+            statement = select(SubmissionUploadSession).where(SubmissionUploadSession.status.in_(statuses))
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get upload sessions: {str(e)}")
+
+    def get_expired(self, now: datetime) -> List[SubmissionUploadSession]:
+        """Get the upload session records still receiving chunks past their expiry"""
+        try:
+            statement = select(SubmissionUploadSession).where(
+                SubmissionUploadSession.status == UploadSessionStatus.PENDING,
+                SubmissionUploadSession.expires_at < now,
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get expired upload sessions: {str(e)}")
+
+    def update(self, upload_id: UUID, upload_data: dict) -> SubmissionUploadSession:
+        """Update the given fields of an upload session record"""
+        try:
+            upload = self.get_by_id(upload_id)
+            if not upload:
+                raise NotFoundException(f"Upload session with ID {upload_id} not found")
+            for field, value in upload_data.items():
+                setattr(upload, field, value)
+            upload.updated_at = datetime.utcnow()
+            self.session.add(upload)
+            self.session.commit()
+            self.session.refresh(upload)
+            return upload
+        except NotFoundException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update upload session: {str(e)}")
@@ -0,0 +1,157 @@
+from typing import List, Optional
+from uuid import UUID
+
+from sqlalchemy import delete, or_
+from sqlmodel import Session, select
+
+from app.domains.submissions.submissions_models import (
+    SubmissionWebhook,
+    SubmissionWebhookDelivery,
+    WebhookDeliveryStatus,
+)
+from app.shared.exceptions import DatabaseException
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+
+class SubmissionWebhookRepository:
+    """Repository for the webhooks and the log of their deliveries"""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def create(self, webhook_data: dict) -> SubmissionWebhook:
+        """Create a new webhook record"""
+        try:
+            webhook = SubmissionWebhook(**webhook_data)
+            self.session.add(webhook)
+            self.session.commit()
+            self.session.refresh(webhook)
+            return webhook
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create webhook: {str(e)}")
+
+    def get_by_id(self, webhook_id: UUID, tenant_id: Optional[str] = None) -> Optional[SubmissionWebhook]:
+        """Get webhook record by ID, None if it belongs to another tenant than the given one"""
+        try:
+            statement = select(SubmissionWebhook).where(SubmissionWebhook.id == webhook_id)
+            if tenant_id is not None:
+                statement = statement.where(SubmissionWebhook.tenant_id == tenant_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get webhook: {str(e)}")
+
+    def get_all(
+        self, project_uuid: Optional[UUID] = None, tenant_id: str = DEFAULT_TENANT_ID
+    ) -> List[SubmissionWebhook]:
+        """Get the webhooks of a tenant, oldest first, only those scoped to a project if given"""
+        try:
+            statement = select(SubmissionWebhook).where(SubmissionWebhook.tenant_id == tenant_id)
+            if project_uuid is not None:
+                statement = statement.where(SubmissionWebhook.project_uuid == project_uuid)
+            return list(self.session.exec(statement.order_by(SubmissionWebhook.created_at)).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get webhooks: {str(e)}")
+
+    def get_subscribed(
+        self, event: str, project_uuid: UUID, project_step_uuid: UUID, tenant_id: str = DEFAULT_TENANT_ID
+    ) -> List[SubmissionWebhook]:
+        """
+        Get the webhooks notified of an event of a project step: those of the step, of its project and global, of
+        the tenant of the step only
+        """
+        try:
+            statement = select(SubmissionWebhook).where(
+                SubmissionWebhook.tenant_id == tenant_id,
+                or_(SubmissionWebhook.project_uuid.is_(None), SubmissionWebhook.project_uuid == project_uuid),
+                or_(
+                    SubmissionWebhook.project_step_uuid.is_(None),
+                    SubmissionWebhook.project_step_uuid == project_step_uuid,
+                ),
+            )
+            # The events are a JSON list, filtered here rather than with the operators of a given database
+            return [webhook for webhook in self.session.exec(statement).all() if event in (webhook.events or [])]
+        except Exception as e:
+            raise DatabaseException(f"Failed to get subscribed webhooks: {str(e)}")
+
+    def delete(self, webhook_id: UUID) -> bool:
+        """Delete a webhook with the log of its deliveries, returning whether it existed"""
+        try:
+            webhook = self.get_by_id(webhook_id)
+            if not webhook:
+                return False
+            self.session.execute(
+                delete(SubmissionWebhookDelivery).where(SubmissionWebhookDelivery.webhook_id == webhook_id)
+            )
+            self.session.delete(webhook)
+            self.session.commit()
+            return True
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to delete webhook: {str(e)}")
+
+    def create_deliveries(self, deliveries_data: List[dict]) -> List[SubmissionWebhookDelivery]:
+        """Create the delivery records of an event, one per notified webhook"""
+        try:
+            deliveries = [SubmissionWebhookDelivery(**delivery_data) for delivery_data in deliveries_data]
+            self.session.add_all(deliveries)
+            self.session.commit()
+            for delivery in deliveries:
+                self.session.refresh(delivery)
+            return deliveries
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to create webhook deliveries: {str(e)}")
+
+    def get_delivery(self, delivery_id: UUID) -> Optional[SubmissionWebhookDelivery]:
+        """Get webhook delivery record by ID"""
+        try:
+            statement = select(SubmissionWebhookDelivery).where(SubmissionWebhookDelivery.id == delivery_id)
+            return self.session.exec(statement).first()
+        except Exception as e:
+            raise DatabaseException(f"Failed to get webhook delivery: {str(e)}")
+
+    def get_deliveries(
+        self, webhook_id: UUID, status: Optional[WebhookDeliveryStatus] = None, limit: int = 50
+    ) -> List[SubmissionWebhookDelivery]:
+        """Get the latest deliveries of a webhook, newest first, only those of a status if given"""
+        try:
+            statement = select(SubmissionWebhookDelivery).where(SubmissionWebhookDelivery.webhook_id == webhook_id)
+            if status is not None:
+                statement = statement.where(SubmissionWebhookDelivery.status == status)
+            statement = statement.order_by(SubmissionWebhookDelivery.created_at.desc()).limit(limit)
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get webhook deliveries: {str(e)}")
+
+    def get_pending_deliveries(self) -> List[SubmissionWebhookDelivery]:
+        """Get the deliveries still pending, oldest first, to be resumed at startup"""
+        try:
+            statement = (
+                select(SubmissionWebhookDelivery)
+                .where(SubmissionWebhookDelivery.status == WebhookDeliveryStatus.PENDING)
+                .order_by(SubmissionWebhookDelivery.created_at)
+            )
+            return list(self.session.exec(statement).all())
+        except Exception as e:
+            raise DatabaseException(f"Failed to get pending webhook deliveries: {str(e)}")
+
+    def update_delivery(self, delivery_id: UUID, delivery_data: dict) -> Optional[SubmissionWebhookDelivery]:
+        """Update the fields of a delivery record"""
+        try:
+            delivery = self.get_delivery(delivery_id)
+            if not delivery:
+                return None
+            for field, value in delivery_data.items():
+                setattr(delivery, field, value)
+            self.session.add(delivery)
+            self.session.commit()
+            self.session.refresh(delivery)
+            return delivery
+        except DatabaseException:
+            raise
+        except Exception as e:
+            self.session.rollback()
+            raise DatabaseException(f"Failed to update webhook delivery: {str(e)}")
@@ -0,0 +1,28 @@
+from typing import Any, Optional
+from uuid import UUID
+
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+
+class TenantScope:
+    """
+    Tenant a caller acts for, whose records it creates and reads, and the tenants of the project steps it acts on:
+    a step belongs to the first tenant acting on it. The background jobs act for no tenant, on the data of all the
+    tenants, their new records belonging to the default one.
+    """
+
+    def __init__(self, assignment_repository: Any, caller_tenant_id: Optional[str] = None):
+        self.assignment_repository = assignment_repository
+        self.caller_tenant_id = caller_tenant_id
+
+    @property
+    def tenant_id(self) -> str:
+        """Tenant the records created by the caller belong to, the default one for the background jobs"""
+        return self.caller_tenant_id or DEFAULT_TENANT_ID
+
+    def step_tenant(self, project_step_uuid: UUID) -> str:
+        """
+        Tenant a project step belongs to, the step being registered to the tenant of the caller if no tenant acted
+        on it yet
+        """
+        return self.assignment_repository.claim(project_step_uuid, self.tenant_id)
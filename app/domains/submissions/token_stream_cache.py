@@ -0,0 +1,97 @@
+import hashlib
+import logging
+from pathlib import Path
+from typing import Optional
+
+from app.domains.submissions.submissions_token_cache_repository import SubmissionTokenCacheRepository
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.dto.tokenization_result_dto import TokenizationResultDto
+from app.domains.tokenization.tokenization_service import TOKENIZER_VERSION
+from app.shared.exceptions import DatabaseException
+from app.shared.metrics import pipeline_metrics
+from app.shared.tenancy import tenant_prefix
+
+logger = logging.getLogger(__name__)
+
+# Options with no effect on the tokens of a content, left out of the key of its cached tokens
+UNKEYED_OPTIONS = {"timeout_seconds"}
+
+
+class TokenStreamCache:
+    """
+    Tokenization results of the file contents, persisted by the repository and reused by the comparisons: the
+    files never change once submitted, and each pair of a detection run tokenizes both of its submissions again.
+    A result is keyed by the SHA-256 of the content, the tokenizer version (bumped whenever the tokens change, the
+    older entries being ignored), the options and extension of the file (grammar dialects) and its language. The
+    results of a tenant other than the default one are keyed apart, under its prefix, never shared with another.
+
+    The lookups of a cache are counted as hits and misses; a failing lookup or write is logged and counted as a
+    miss, the file being tokenized as without cache. The timed out tokenizations, and those without tokens (the
+    failed ones), are not cached.
+    """
+
+    def __init__(
+        self,
+        repository: SubmissionTokenCacheRepository,
+        tokenizer_version: int = TOKENIZER_VERSION,
+        tenant_id: Optional[str] = None,
+    ):
+        self.repository = repository
+        self.tokenizer_version = tokenizer_version
+        self.tenant_id = tenant_id
+        self.hits = 0
+        self.misses = 0
+
+    @staticmethod
+    def content_hash(content: bytes) -> str:
+        """SHA-256 of the content of a file, hexadecimal"""
+        return hashlib.sha256(content).hexdigest()
+
+    @staticmethod
+    def tokenization_key(options: TokenizationOptionsDto, file_path: Path, tenant_id: Optional[str] = None) -> str:
+        """Hash of the options a file is tokenized with and of its extension, under the prefix of its tenant"""
+        keyed_options = options.model_dump_json(exclude=UNKEYED_OPTIONS)
+        digest = hashlib.sha256(f"{keyed_options}\n{file_path.suffix.lower()}".encode("utf8")).hexdigest()
+        return f"{tenant_prefix(tenant_id)}{digest}"
+
+    def get(
+        self, content_hash: str, options: TokenizationOptionsDto, file_path: Path, language: str
+    ) -> Optional[TokenizationResultDto]:
+        """Get the cached tokenization result of a file content, None (a miss) if it is not cached"""
+        try:
+            tokenization_key = self.tokenization_key(options, file_path, self.tenant_id)
+            entry = self.repository.get(content_hash, self.tokenizer_version, tokenization_key, language)
+        except DatabaseException as e:
+            logger.warning(f"Token cache lookup of {file_path.name} failed: {str(e)}")
+            entry = None
+        pipeline_metrics.record_token_cache_lookup(entry is not None)
+        if entry is None:
+            self.misses += 1
+            return None
+        self.hits += 1
+        return TokenizationResultDto.model_validate(entry.result)
+
+    def put(
+        self,
+        content_hash: str,
+        options: TokenizationOptionsDto,
+        file_path: Path,
+        language: str,
+        result: TokenizationResultDto,
+    ) -> None:
+        """Cache the tokenization result of a file content, unless it timed out or has no tokens"""
+        if result.timed_out or not result.tokens:
+            return
+        try:
+            self.repository.create(
+                {
+                    "content_hash": content_hash,
+                    "tokenizer_version": self.tokenizer_version,
+                    "tokenization_key": self.tokenization_key(options, file_path, self.tenant_id),
+                    "language": language,
+                    "result": result.model_dump(mode="json"),
+                    "token_count": len(result.tokens),
+                }
+            )
+        except DatabaseException as e:
+            logger.warning(f"Token cache write of {file_path.name} failed: {str(e)}")
@@ -0,0 +1,64 @@
+import json
+from typing import Any, Dict, Iterable, Iterator, List
+
+# Options of the detection applying to the tokens of each file on its own, those of a whole submission (functions
+# unreachable from its entry points) being left out of the exported streams
+EXPORTED_NORMALIZATION_OPTIONS = (
+    "ignore_struct_tags",
+    "ignore_comments",
+    "ignore_imports",
+    "docstrings_as_comments",
+    "normalize_identifiers",
+    "normalize_literals",
+    "literal_length_buckets",
+    "preserve_format_verbs",
+)
+
+# Separators of the compact newline-delimited format, without spaces
+COMPACT_SEPARATORS = (",", ":")
+
+
+class TokenStreamExporter:
+    """
+    Export the token streams of the files of a submission, for external tooling (detectors of their own), as a JSON
+    document or as newline-delimited JSON: a header line then one line per token, with its file. Each token is
+    exported with its kind, its value (the placeholder of a normalized token) and its line and column (0-based). The
+    export is streamed, one file at a time, the header telling the tokenizer version the streams were produced with
+    so that consumers detect when a new version changed them.
+    """
+
+    def __init__(self, header: Dict[str, Any], files: Iterable[Dict[str, Any]]):
+        self.header = header
+        self.files = files
+
+    def json(self) -> Iterator[str]:
+        """Stream the files as a JSON document: the description of the submission, then its files with their tokens"""
+        yield '{"submission": ' + json.dumps(self.header, default=str) + ', "files": ['
+        separator = ""
+        for file in self.files:
+            yield separator + json.dumps({**file, "tokens": self.tokens(file["tokens"])}, default=str)
+            separator = ", "
+        yield "]}"
+
+    def ndjson(self) -> Iterator[str]:
+        """Stream the files as newline-delimited JSON: the header, then one compact line per token"""
+        yield json.dumps(self.header, default=str, separators=COMPACT_SEPARATORS) + "\n"
+        for file in self.files:
+            for token in self.tokens(file["tokens"]):
+                line = {"file": file["file"], **token}
+                yield json.dumps(line, default=str, separators=COMPACT_SEPARATORS) + "\n"
+
+    @staticmethod
+    def tokens(tokens: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+        """Exported fields of the tokens of a file"""
+        return [
+            {
+                "kind": token.get("type"),
+                "value": token.get("text"),
+                "line": token.get("start"),
+                "column": token.get("start_column"),
+                "end_line": token.get("end"),
+                "end_column": token.get("end_column"),
+            }
+            for token in tokens
+        ]
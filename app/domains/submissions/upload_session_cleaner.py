@@ -0,0 +1,27 @@
+import asyncio
+import logging
+
+logger = logging.getLogger(__name__)
+
+
+def clean_expired_upload_sessions() -> int:
+    """Expire the resumable uploads not finalized in time and delete the orphaned chunks, with a session of its own"""
+    from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+    from app.domains.submissions.submissions_models import get_paris_time
+    from app.shared.database import get_session
+
+    session = next(get_session())
+    try:
+        return DetectionIntegrationService(session).get_resumable_uploads().clean_expired(get_paris_time())
+    finally:
+        session.close()
+
+
+async def clean_expired_upload_sessions_periodically(interval_seconds: float) -> None:
+    """Clean up the resumable uploads in a thread at every interval, until cancelled at shutdown"""
+    while True:
+        try:
+            await asyncio.to_thread(clean_expired_upload_sessions)
+        except Exception as e:
+            logger.error(f"Failed to clean up the expired uploads: {e}")
+        await asyncio.sleep(interval_seconds)
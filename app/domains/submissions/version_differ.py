@@ -0,0 +1,108 @@
+import difflib
+from pathlib import Path
+from typing import Any, Dict, List, Optional
+
+# Lines of the unified diff reported per file, the rest being counted but truncated
+DEFAULT_MAX_DIFF_LINES = 500
+
+# Directories never part of the content of a version
+EXCLUDED_DIRECTORIES = {".git"}
+
+
+class SubmissionVersionDiffer:
+    """
+    Summarize the changes between two versions of a submission, file by file: the files added, removed and
+    modified (by content) from the old version to the new one, with the number of added and removed lines and a
+    unified diff of the modified text files. Binary files (holding a NUL byte, or not UTF-8) are only reported as
+    modified.
+    """
+
+    def __init__(self, max_diff_lines: int = DEFAULT_MAX_DIFF_LINES, context_lines: int = 3):
+        self.max_diff_lines = max_diff_lines
+        self.context_lines = context_lines
+
+    @staticmethod
+    def file_paths(root: Path) -> Dict[str, Path]:
+        """Files of a fetched version by path relative to its root, without the .git internals nor the links"""
+        files = {}
+        for path in sorted(root.rglob("*")):
+            relative = path.relative_to(root)
+            if EXCLUDED_DIRECTORIES.intersection(relative.parts) or path.is_symlink() or not path.is_file():
+                continue
+            files[relative.as_posix()] = path
+        return files
+
+    @classmethod
+    def read_files(cls, root: Path) -> Dict[str, bytes]:
+        """Content of the files of a fetched version by path relative to its root, without the .git internals"""
+        return {relative: path.read_bytes() for relative, path in cls.file_paths(root).items()}
+
+    def diff(self, old_files: Dict[str, bytes], new_files: Dict[str, bytes]) -> Dict[str, Any]:
+        """Per-file changes from the old version to the new one, and their counts"""
+        files = []
+        unchanged = 0
+        for path in sorted(set(old_files) | set(new_files)):
+            old, new = old_files.get(path), new_files.get(path)
+            if old == new:
+                unchanged += 1
+                continue
+            status = "added" if old is None else "removed" if new is None else "modified"
+            files.append(self._file_diff(path, status, old, new))
+
+        return {
+            "files_added": sum(1 for file in files if file["status"] == "added"),
+            "files_removed": sum(1 for file in files if file["status"] == "removed"),
+            "files_modified": sum(1 for file in files if file["status"] == "modified"),
+            "files_unchanged": unchanged,
+            "lines_added": sum(file["lines_added"] for file in files),
+            "lines_removed": sum(file["lines_removed"] for file in files),
+            "files": files,
+        }
+
+    def _file_diff(self, path: str, status: str, old: Optional[bytes], new: Optional[bytes]) -> Dict[str, Any]:
+        old_lines = self._lines(old)
+        new_lines = self._lines(new)
+        if old_lines is None or new_lines is None:
+            return {
+                "path": path,
+                "status": status,
+                "binary": True,
+                "lines_added": 0,
+                "lines_removed": 0,
+                "diff": None,
+                "truncated": False,
+            }
+
+        diff = list(
+            difflib.unified_diff(
+                old_lines,
+                new_lines,
+                fromfile=f"a/{path}" if old is not None else "/dev/null",
+                tofile=f"b/{path}" if new is not None else "/dev/null",
+                n=self.context_lines,
+                lineterm="",
+            )
+        )
+        # The two header lines are not changed lines
+        changed = diff[2:]
+        return {
+            "path": path,
+            "status": status,
+            "binary": False,
+            "lines_added": sum(1 for line in changed if line.startswith("+")),
+            "lines_removed": sum(1 for line in changed if line.startswith("-")),
+            "diff": diff[: self.max_diff_lines],
+            "truncated": len(diff) > self.max_diff_lines,
+        }
+
+    @staticmethod
+    def _lines(content: Optional[bytes]) -> Optional[List[str]]:
+        """Lines of a text file, an empty list for a missing file, None for a binary file"""
+        if content is None:
+            return []
+        if b"\x00" in content:
+            return None
+        try:
+            return content.decode("utf-8").splitlines()
+        except UnicodeDecodeError:
+            return None
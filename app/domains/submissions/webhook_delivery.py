@@ -0,0 +1,215 @@
+import hashlib
+import hmac
+import json
+import logging
+import socket
+import threading
+import time
+from concurrent.futures import ThreadPoolExecutor
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Callable, Dict, Optional
+from urllib.error import HTTPError, URLError
+from urllib.parse import urlparse
+from urllib.request import HTTPRedirectHandler, Request, build_opener
+
+logger = logging.getLogger(__name__)
+
+# Headers of a delivery: the event, the ID of the delivery (the same for its retries), the time it was signed at
+# and the signature of that time and the body, "sha256=" followed by the hexadecimal HMAC-SHA256 with the secret
+EVENT_HEADER = "X-Webhook-Event"
+DELIVERY_HEADER = "X-Webhook-Delivery"
+TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+SIGNATURE_HEADER = "X-Webhook-Signature"
+
+# Characters of the response body kept in the record of an attempt
+RESPONSE_EXCERPT_CHARS = 500
+
+# Whether a host may be posted to
+HostPolicy = Callable[[str], bool]
+
+
+def sign(secret: str, timestamp: int, body: bytes) -> str:
+    """
+    Signature of a delivery, verified by the receiver by computing it again from the timestamp and raw body it
+    received; the timestamp being signed too, the receiver can refuse the replays of old deliveries
+    """
+    message = f"{timestamp}.".encode("utf8") + body
+    return "sha256=" + hmac.new(secret.encode("utf8"), message, hashlib.sha256).hexdigest()
+
+
+def encode_payload(payload: Dict[str, Any]) -> bytes:
+    """Body of a delivery, the same bytes being signed and sent"""
+    return json.dumps(payload, separators=(",", ":"), sort_keys=True, default=str).encode("utf8")
+
+
+@dataclass
+class WebhookAttempt:
+    """Result of an attempt to deliver an event: the status code of the response, or the error of the request"""
+
+    status_code: Optional[int]
+    error: Optional[str]
+    duration_ms: float
+    response_excerpt: Optional[str] = None
+
+    @property
+    def succeeded(self) -> bool:
+        return self.status_code is not None and 200 <= self.status_code < 300
+
+    def record(self, attempt: int, at: datetime, retry_at: Optional[datetime]) -> Dict[str, Any]:
+        """Record of the attempt, kept in the log of the delivery"""
+        return {
+            "attempt": attempt,
+            "at": at.isoformat(),
+            "status_code": self.status_code,
+            "error": self.error,
+            "duration_ms": round(self.duration_ms, 1),
+            "response_excerpt": self.response_excerpt,
+            "retry_at": retry_at.isoformat() if retry_at else None,
+        }
+
+
+class WebhookRetryPolicy:
+    """
+    Retry of the deliveries not answered with a 2xx, up to `max_attempts` attempts in all, the delay before each
+    retry doubling from `base_delay_seconds` up to `max_delay_seconds`
+    """
+
+    def __init__(self, max_attempts: int = 6, base_delay_seconds: float = 10.0, max_delay_seconds: float = 3600.0):
+        self.max_attempts = max_attempts
+        self.base_delay_seconds = base_delay_seconds
+        self.max_delay_seconds = max_delay_seconds
+
+    def delay(self, attempt: int) -> Optional[float]:
+        """Seconds to wait before retrying a delivery failed at the given attempt (1 for the first), None if none"""
+        if attempt >= self.max_attempts:
+            return None
+        return min(self.max_delay_seconds, self.base_delay_seconds * 2 ** (attempt - 1))
+
+
+class WebhookSender:
+    """
+    Sender of the deliveries: a signed JSON POST, a response of 2xx within the timeout being a success. The
+    redirections are not followed, a webhook being expected at the URL it was registered with. The host of the URL
+    is checked by the policy (that of the external sources, see UrlSourceFetcher) when the URL is registered and
+    again before each delivery, its addresses possibly having changed meanwhile; without policy any host is posted
+    to.
+    """
+
+    def __init__(self, timeout_seconds: float = 10.0, opener=None, host_policy: Optional[HostPolicy] = None):
+        self.timeout_seconds = timeout_seconds
+        self.opener = opener or build_opener(_NoRedirectHandler())
+        self.host_policy = host_policy
+
+    def denied_reason(self, url: str) -> Optional[str]:
+        """Reason a URL is not posted to, None for an http or https URL whose host the policy allows"""
+        parsed = urlparse(url)
+        if parsed.scheme not in ("http", "https") or not parsed.hostname:
+            return "only http and https URLs are posted to"
+        if self.host_policy and not self.host_policy(parsed.hostname.lower()):
+            return f"host {parsed.hostname} is not allowed"
+        return None
+
+    def send(
+        self,
+        url: str,
+        secret: str,
+        event: str,
+        delivery_id: str,
+        body: bytes,
+        headers: Optional[Dict[str, str]] = None,
+    ) -> WebhookAttempt:
+        """Post a delivery, with the headers of the integration receiving it if any, unless its URL is denied"""
+        denied_reason = self.denied_reason(url)
+        if denied_reason:
+            logger.warning(f"Refused to post to {url}: {denied_reason}")
+            return WebhookAttempt(None, denied_reason, 0.0)
+        timestamp = int(time.time())
+        request = Request(
+            url,
+            data=body,
+            method="POST",
+            headers={
+                "Content-Type": "application/json",
+                "User-Agent": "PAMP-submissions-service",
+                EVENT_HEADER: event,
+                DELIVERY_HEADER: delivery_id,
+                TIMESTAMP_HEADER: str(timestamp),
+                SIGNATURE_HEADER: sign(secret, timestamp, body),
+                **(headers or {}),
+            },
+        )
+        started = time.monotonic()
+        try:
+            with self.opener.open(request, timeout=self.timeout_seconds) as response:
+                excerpt = response.read(RESPONSE_EXCERPT_CHARS).decode("utf8", errors="replace")
+                return WebhookAttempt(response.status, None, self._elapsed_ms(started), excerpt or None)
+        except HTTPError as e:
+            excerpt = e.read(RESPONSE_EXCERPT_CHARS).decode("utf8", errors="replace") if e.fp else ""
+            return WebhookAttempt(e.code, f"HTTP {e.code}", self._elapsed_ms(started), excerpt or None)
+        except (socket.timeout, TimeoutError):
+            return WebhookAttempt(None, f"no response within {self.timeout_seconds} seconds", self._elapsed_ms(started))
+        except (URLError, OSError, ValueError) as e:
+            return WebhookAttempt(None, str(getattr(e, "reason", e)), self._elapsed_ms(started))
+
+    @staticmethod
+    def _elapsed_ms(started: float) -> float:
+        return (time.monotonic() - started) * 1000
+
+
+class WebhookDispatcher:
+    """
+    Threads of their own the webhooks are delivered from, so that a slow or unreachable receiver never holds an
+    analysis worker: the notifying code only submits a job, and the retries wait on timers.
+    """
+
+    def __init__(self, worker_count: int = 2):
+        self.executor = ThreadPoolExecutor(max_workers=worker_count, thread_name_prefix="webhook")
+        self._timers = set()
+        self._lock = threading.Lock()
+        self._stopped = False
+
+    def submit(self, job: Callable[..., Any], *args: Any) -> None:
+        """Run a job in the background, its errors being logged"""
+        if self._stopped:
+            return
+        self.executor.submit(self._run, job, *args)
+
+    def schedule(self, delay_seconds: float, job: Callable[..., Any], *args: Any) -> None:
+        """Run a job in the background once the delay is over, unless stopped meanwhile"""
+        timer = threading.Timer(delay_seconds, self._fire, args=(job, *args))
+        timer.daemon = True
+        with self._lock:
+            if self._stopped:
+                return
+            self._timers.add(timer)
+        timer.start()
+
+    def shutdown(self) -> None:
+        """Cancel the scheduled retries, left pending to be resumed at the next start, and stop the threads"""
+        with self._lock:
+            self._stopped = True
+            timers, self._timers = self._timers, set()
+        for timer in timers:
+            timer.cancel()
+        self.executor.shutdown(wait=False, cancel_futures=True)
+
+    def _fire(self, job: Callable[..., Any], *args: Any) -> None:
+        # Run by the timer thread itself
+        with self._lock:
+            self._timers.discard(threading.current_thread())
+        self.submit(job, *args)
+
+    @staticmethod
+    def _run(job: Callable[..., Any], *args: Any) -> None:
+        try:
+            job(*args)
+        except Exception as e:
+            logger.error(f"Webhook job failed: {str(e)}")
+
+
+class _NoRedirectHandler(HTTPRedirectHandler):
+    """Redirection handler refusing the redirections, answered as failed attempts with their status code"""
+
+    def redirect_request(self, req, fp, code, msg, headers, newurl):
+        return None
@@ -0,0 +1,171 @@
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, Optional
+from uuid import UUID
+
+from app.domains.submissions.submissions_assignment_repository import SubmissionAssignmentRepository
+from app.domains.submissions.submissions_models import (
+    SubmissionWebhookDelivery,
+    WebhookDeliveryStatus,
+    WebhookEvent,
+    get_paris_time,
+)
+from app.domains.submissions.submissions_webhook_repository import SubmissionWebhookRepository
+from app.domains.submissions.webhook_delivery import (
+    WebhookAttempt,
+    WebhookDispatcher,
+    WebhookRetryPolicy,
+    WebhookSender,
+    encode_payload,
+)
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+logger = logging.getLogger(__name__)
+
+
+class WebhookNotifier:
+    """
+    Notification of the events to the webhooks subscribed to them. Notifying only submits a job to the dispatcher,
+    which records a delivery per webhook then sends it, each on a session of its own, so that the analysis workers
+    never wait for the database writes nor the receivers. The deliveries not answered with a 2xx are retried by the
+    retry policy, those pending at a shutdown being resumed at the next start.
+    """
+
+    def __init__(self, dispatcher: WebhookDispatcher, sender: WebhookSender, retry_policy: WebhookRetryPolicy):
+        self.dispatcher = dispatcher
+        self.sender = sender
+        self.retry_policy = retry_policy
+
+    def notify(self, event: WebhookEvent, project_uuid: UUID, project_step_uuid: UUID, data: Dict[str, Any]) -> None:
+        """Notify an event of a project step to its webhooks, in the background, never raising"""
+        try:
+            self.dispatcher.submit(self._record_deliveries, event, project_uuid, project_step_uuid, data)
+        except Exception as e:
+            logger.error(f"Failed to notify the webhooks of {event.value}: {str(e)}")
+
+    def resume_pending(self) -> int:
+        """Send again the deliveries left pending by the last shutdown, at the time of their next attempt"""
+        from app.shared.database import get_session
+
+        session = next(get_session())
+        try:
+            deliveries = SubmissionWebhookRepository(session).get_pending_deliveries()
+            now = get_paris_time()
+            for delivery in deliveries:
+                delay = 0
+                if delivery.next_attempt_at:
+                    # Read back without its time zone by some databases, in Paris time
+                    next_attempt_at, current = delivery.next_attempt_at, now
+                    if next_attempt_at.tzinfo is None:
+                        current = current.replace(tzinfo=None)
+                    delay = (next_attempt_at - current).total_seconds()
+                if delay > 0:
+                    self.dispatcher.schedule(delay, self._deliver, delivery.id)
+                else:
+                    self.dispatcher.submit(self._deliver, delivery.id)
+            if deliveries:
+                logger.info(f"Resumed {len(deliveries)} pending webhook deliveries")
+            return len(deliveries)
+        except Exception as e:
+            logger.error(f"Failed to resume the pending webhook deliveries: {str(e)}")
+            return 0
+        finally:
+            session.close()
+
+    def _record_deliveries(
+        self, event: WebhookEvent, project_uuid: UUID, project_step_uuid: UUID, data: Dict[str, Any]
+    ) -> None:
+        from app.shared.database import get_session
+
+        session = next(get_session())
+        try:
+            repository = SubmissionWebhookRepository(session)
+            # Only the webhooks of the tenant of the step are notified of its events
+            tenant_id = SubmissionAssignmentRepository(session).get_tenant(project_step_uuid) or DEFAULT_TENANT_ID
+            webhooks = repository.get_subscribed(event.value, project_uuid, project_step_uuid, tenant_id)
+            if not webhooks:
+                return
+            payload = {
+                "event": event.value,
+                "occurred_at": get_paris_time().isoformat(),
+                "project_uuid": str(project_uuid),
+                "project_step_uuid": str(project_step_uuid),
+                "data": data,
+            }
+            deliveries = repository.create_deliveries(
+                [{"webhook_id": webhook.id, "event": event, "payload": payload} for webhook in webhooks]
+            )
+        finally:
+            session.close()
+        for delivery in deliveries:
+            self.dispatcher.submit(self._deliver, delivery.id)
+
+    def _deliver(self, delivery_id: UUID) -> None:
+        """Make the next attempt of a delivery, scheduling the one after if it fails and attempts remain"""
+        from app.shared.database import get_session
+
+        session = next(get_session())
+        try:
+            repository = SubmissionWebhookRepository(session)
+            delivery = repository.get_delivery(delivery_id)
+            if not delivery or delivery.status != WebhookDeliveryStatus.PENDING:
+                return
+            webhook = repository.get_by_id(delivery.webhook_id)
+            if not webhook:
+                repository.update_delivery(delivery_id, {"status": WebhookDeliveryStatus.FAILED})
+                return
+
+            event = WebhookEvent(delivery.event).value
+            result = self.sender.send(
+                webhook.url, webhook.secret, event, str(delivery.id), encode_payload(delivery.payload)
+            )
+            attempt = delivery.attempt_count + 1
+            at = get_paris_time()
+            retry_in = None if result.succeeded else self.retry_policy.delay(attempt)
+            retry_at = at + timedelta(seconds=retry_in) if retry_in is not None else None
+            repository.update_delivery(delivery_id, self._attempt_changes(delivery, result, attempt, at, retry_at))
+
+            if result.succeeded:
+                logger.info(f"Delivered {event} to webhook {webhook.id} at attempt {attempt}")
+            elif retry_in is not None:
+                logger.warning(
+                    f"Webhook {webhook.id} failed to receive {event} ({result.error}), "
+                    f"retried in {retry_in:.0f} seconds"
+                )
+                self.dispatcher.schedule(retry_in, self._deliver, delivery_id)
+            else:
+                logger.error(f"Webhook {webhook.id} failed to receive {event} after {attempt} attempts")
+        finally:
+            session.close()
+
+    @staticmethod
+    def _attempt_changes(
+        delivery: SubmissionWebhookDelivery,
+        result: WebhookAttempt,
+        attempt: int,
+        at: datetime,
+        retry_at: Optional[datetime],
+    ) -> Dict[str, Any]:
+        """Changes of a delivery recording an attempt"""
+        if result.succeeded:
+            status = WebhookDeliveryStatus.DELIVERED
+        else:
+            status = WebhookDeliveryStatus.PENDING if retry_at else WebhookDeliveryStatus.FAILED
+        return {
+            "status": status,
+            "attempt_count": attempt,
+            "attempts": list(delivery.attempts or []) + [result.record(attempt, at, retry_at)],
+            "next_attempt_at": retry_at,
+            "delivered_at": at if result.succeeded else None,
+        }
+
+
+def resume_pending_webhook_deliveries() -> int:
+    """Resume the webhook deliveries left pending by the last shutdown"""
+    from app.shared.services import get_webhook_notifier
+
+    try:
+        return get_webhook_notifier().resume_pending()
+    except Exception as e:
+        logger.error(f"Failed to resume the pending webhook deliveries: {e}")
+        return 0
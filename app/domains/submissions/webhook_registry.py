@@ -0,0 +1,81 @@
+import secrets
+from typing import Any, Dict, List, Optional
+from uuid import UUID
+
+from app.domains.submissions.submissions_models import (
+    SubmissionWebhook,
+    SubmissionWebhookDelivery,
+    WebhookDeliveryStatus,
+)
+from app.domains.submissions.webhook_delivery import WebhookSender
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class WebhookRegistry:
+    """
+    Webhooks registered by a tenant, each with the secret its deliveries are signed with, generated if none is
+    given. A webhook is only registered to a URL the sender would post to, its host being checked again before each
+    delivery; the webhooks of the other tenants are reported as not found.
+    """
+
+    def __init__(self, webhook_repository: Any, sender: WebhookSender, tenant_id: str):
+        self.webhook_repository = webhook_repository
+        self.sender = sender
+        self.tenant_id = tenant_id
+
+    def create(self, webhook_data: Dict[str, Any]) -> SubmissionWebhook:
+        """
+        Register a webhook, its secret being generated if none is given
+
+        Raises:
+            ValidationException: If the host of its URL is not allowed (see WebhookSender)
+        """
+        denied_reason = self.sender.denied_reason(webhook_data["url"])
+        if denied_reason:
+            raise ValidationException(f"The webhook URL is not allowed: {denied_reason}")
+        return self.webhook_repository.create(
+            {
+                **webhook_data,
+                "tenant_id": self.tenant_id,
+                "secret": webhook_data.get("secret") or secrets.token_urlsafe(32),
+            }
+        )
+
+    def get_all(self, project_uuid: Optional[UUID] = None) -> List[SubmissionWebhook]:
+        """Get the webhooks of the tenant, those scoped to a project only if given"""
+        return self.webhook_repository.get_all(project_uuid, self.tenant_id)
+
+    def get(self, webhook_id: UUID) -> SubmissionWebhook:
+        """
+        Get a webhook of the tenant
+
+        Raises:
+            NotFoundException: If the webhook doesn't exist, or belongs to another tenant
+        """
+        webhook = self.webhook_repository.get_by_id(webhook_id, self.tenant_id)
+        if not webhook:
+            raise NotFoundException("Webhook", str(webhook_id))
+        return webhook
+
+    def delete(self, webhook_id: UUID) -> bool:
+        """
+        Delete a webhook of the tenant with the log of its deliveries, its pending retries being dropped, returning
+        whether it existed
+        """
+        try:
+            self.get(webhook_id)
+        except NotFoundException:
+            return False
+        return self.webhook_repository.delete(webhook_id)
+
+    def get_deliveries(
+        self, webhook_id: UUID, status: Optional[WebhookDeliveryStatus] = None, limit: int = 50
+    ) -> List[SubmissionWebhookDelivery]:
+        """
+        Get the log of the latest deliveries of a webhook, with the response or error of each attempt
+
+        Raises:
+            NotFoundException: If the webhook doesn't exist, or belongs to another tenant
+        """
+        self.get(webhook_id)
+        return self.webhook_repository.get_deliveries(webhook_id, status, limit)
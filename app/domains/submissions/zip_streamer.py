@@ -0,0 +1,65 @@
+import stat
+import zipfile
+from datetime import datetime
+from pathlib import Path
+from typing import Iterable, Iterator, List, Tuple
+
+# Size of the pieces the files are read and the archives streamed by
+STREAM_CHUNK_BYTES = 64 * 1024
+
+# Earliest modification time a ZIP entry can hold
+ZIP_MIN_DATE_TIME = (1980, 1, 1, 0, 0, 0)
+
+
+class _StreamBuffer:
+    """Unseekable file collecting what the ZIP writer writes, drained as the archive is streamed"""
+
+    def __init__(self):
+        self.chunks: List[bytes] = []
+
+    def write(self, data: bytes) -> int:
+        self.chunks.append(bytes(data))
+        return len(data)
+
+    def flush(self) -> None:
+        pass
+
+    def drain(self) -> bytes:
+        data, self.chunks = b"".join(self.chunks), []
+        return data
+
+
+class ZipStreamer:
+    """
+    Build a ZIP archive on the fly from files on disk, yielding it piece by piece as it is written: neither the
+    archive nor a whole file is ever held in memory. The entries keep their relative path and their permissions, and
+    share one modification time, such as when the submission was made.
+    """
+
+    def __init__(self, chunk_bytes: int = STREAM_CHUNK_BYTES):
+        self.chunk_bytes = chunk_bytes
+
+    def stream(self, files: Iterable[Tuple[str, Path]], modified_at: datetime) -> Iterator[bytes]:
+        """Content of the ZIP archive of files, given as their path within the archive and on disk"""
+        buffer = _StreamBuffer()
+        date_time = self.zip_date_time(modified_at)
+        with zipfile.ZipFile(buffer, "w", compression=zipfile.ZIP_DEFLATED) as archive:
+            for name, path in files:
+                file_stat = path.stat()
+                info = zipfile.ZipInfo(name, date_time=date_time)
+                info.compress_type = zipfile.ZIP_DEFLATED
+                info.external_attr = (stat.S_IFREG | stat.S_IMODE(file_stat.st_mode)) << 16
+                info.file_size = file_stat.st_size  # Tells whether the entry needs the ZIP64 extension
+                with path.open("rb") as source, archive.open(info, "w") as target:
+                    while chunk := source.read(self.chunk_bytes):
+                        target.write(chunk)
+                        if data := buffer.drain():
+                            yield data
+                if data := buffer.drain():
+                    yield data
+        yield buffer.drain()
+
+    @staticmethod
+    def zip_date_time(moment: datetime) -> Tuple[int, int, int, int, int, int]:
+        """Modification time of a ZIP entry, in local time of the moment and not before 1980"""
+        return max(moment.timetuple()[:6], ZIP_MIN_DATE_TIME)
@@ -0,0 +1,131 @@
+import logging
+import re
+from typing import Dict, Iterable, List, Tuple
+
+logger = logging.getLogger(__name__)
+
+# Distinctive syntax of each language, with the weight of a match. Matches are counted up to MAX_MATCHES per
+# pattern so that a long file does not outweigh a distinctive construct with repeated common ones.
+CONTENT_SIGNATURES: Dict[str, List[Tuple[str, float]]] = {
+    "python": [
+        (r"^\s*def \w+\(.*\)\s*(->\s*[^:]+)?:\s*$", 3),
+        (r"def __init__\(self", 5),
+        (r"^\s*(from [\w.]+ )?import [\w., ]+$", 1),
+        (r"^\s*elif .*:\s*$", 3),
+        (r"\bself\.\w+", 1),
+        (r"^if __name__ == ['\"]__main__['\"]:", 5),
+    ],
+    "java": [
+        (r"^\s*package [\w.]+;", 4),
+        (r"^\s*import (static )?[\w.*]+;", 3),
+        (r"\bpublic (static )?(final )?(abstract )?class \w+", 4),
+        (r"public static void main\(String\s*\[\]", 6),
+        (r"\bSystem\.out\.print", 4),
+        (r"^\s*@Override\b", 3),
+    ],
+    "c": [
+        (r"^\s*#include\s*<\w+\.h>", 3),
+        (r"\bint main\s*\(", 2),
+        (r"\bprintf\s*\(", 2),
+        (r"\bmalloc\s*\(", 2),
+        (r"^\s*typedef struct\b", 3),
+    ],
+    "cpp": [
+        (r"^\s*#include\s*<(iostream|vector|string|map|memory|algorithm)>", 5),
+        (r"\bstd::", 4),
+        (r"\bc(out|err)\s*<<", 4),
+        (r"\btemplate\s*<", 3),
+        (r"^\s*using namespace\b", 4),
+    ],
+    "go": [
+        (r"^package \w+\s*$", 4),
+        (r"^func (\(\w+ \*?\w+\) )?\w+\(", 4),
+        (r"\w+ :=", 2),
+        (r"^import \($", 3),
+        (r"\bfmt\.\w+\(", 3),
+    ],
+    "javascript": [
+        (r"\bconst \w+ = require\(", 4),
+        (r"\bfunction \w*\s*\(", 2),
+        (r"\bconsole\.log\(", 3),
+        (r"\bmodule\.exports\b", 4),
+        (r"^\s*export (default )?(function|const|class)\b", 2),
+    ],
+    "typescript": [
+        (r"^\s*(export )?interface \w+\s*\{", 3),
+        (r"\w+\??:\s*(string|number|boolean)\b", 3),
+        (r"^\s*(export )?type \w+ =", 3),
+    ],
+    "rust": [
+        (r"\bfn \w+\s*(<.*>)?\(", 3),
+        (r"\blet mut\b", 4),
+        (r"^\s*impl(<.*>)? \w+", 3),
+        (r"\bprintln!\(", 4),
+        (r"^\s*use [\w:]+(::\{.*\})?;", 2),
+    ],
+    "csharp": [
+        (r"^\s*using System(\.\w+)*;", 5),
+        (r"^\s*namespace [\w.]+", 3),
+        (r"\bConsole\.Write(Line)?\(", 4),
+        (r"\{\s*get;\s*(private |init; )?(set;)?\s*\}", 4),
+    ],
+    "kotlin": [
+        (r"\bfun \w+\(", 4),
+        (r"\bval \w+\s*[:=]", 2),
+        (r"^\s*data class\b", 4),
+    ],
+    "php": [
+        (r"<\?php", 8),
+        (r"\$\w+\s*=", 2),
+        (r"^\s*(public |private )?function \w+\(", 1),
+    ],
+    "ruby": [
+        (r"^\s*def \w+[?!]?(\(.*\))?\s*$", 3),
+        (r"^\s*end\s*$", 1),
+        (r"\bputs\b", 2),
+        (r"^\s*require(_relative)? ['\"]", 3),
+        (r"\bdo \|\w+(, \w+)*\|", 4),
+        (r"\battr_(reader|writer|accessor)\b", 4),
+    ],
+    "swift": [
+        (r"\bfunc \w+\(", 3),
+        (r"\bguard let\b", 5),
+        (r"^\s*import (Foundation|UIKit|SwiftUI)\b", 6),
+    ],
+}
+
+MAX_MATCHES = 5
+
+
+class ContentLanguageDetector:
+    """
+    Score the languages a source code may be written in from its content only: keyword frequency and
+    distinctive syntax (`package main`, `def __init__`, `#include <stdio.h>`). Used when the file extension
+    lies, e.g. a Java file renamed to .txt or a C file saved as .py.
+    """
+
+    def __init__(self, supported_languages: Iterable[str]):
+        supported = set(supported_languages)
+        self.signatures = {
+            language: [(re.compile(pattern, re.MULTILINE), weight) for pattern, weight in patterns]
+            for language, patterns in CONTENT_SIGNATURES.items()
+            if language in supported
+        }
+
+    def rank(self, content: str) -> List[Tuple[str, float]]:
+        """
+        Rank the languages matching the content, best first.
+
+        Returns:
+            (language, score) pairs, scores being in [0, 1] and summing to 1, languages without match excluded
+        """
+        raw_scores = {}
+        for language, patterns in self.signatures.items():
+            score = sum(weight * min(len(pattern.findall(content)), MAX_MATCHES) for pattern, weight in patterns)
+            if score > 0:
+                raw_scores[language] = score
+
+        total = sum(raw_scores.values())
+        ranked = sorted(((language, score / total) for language, score in raw_scores.items()), key=lambda x: -x[1])
+        logger.debug(f"Content language scores: {ranked[:3]}")
+        return ranked
@@ -0,0 +1,126 @@
+import re
+from typing import Any, Dict, List, Optional
+
+from app.domains.tokenization.dto.custom_language_definition_dto import CustomLanguageDefinitionDto
+
+IDENTIFIER_PATTERN = re.compile(r"[^\W\d]\w*")
+NUMBER_PATTERN = re.compile(r"\d+(\.\d+)?([eE][+-]?\d+)?")
+
+
+class CustomLanguageTokenizer:
+    """
+    Generic tokenizer of a language registered at runtime, built from its definition (keywords, comment
+    syntaxes, string delimiters and operators) instead of a tree-sitter grammar. The produced tokens have
+    the shape of the tree-sitter ones, with the types normalized by the similarity detection:
+
+    - comment, string, integer, float and identifier
+    - keywords: their own type (`while`), like the keyword nodes of tree-sitter grammars
+    - operator, and punctuation for any other character
+    """
+
+    def __init__(self, definition: CustomLanguageDefinitionDto):
+        self.definition = definition
+        self.keywords = {keyword if definition.case_sensitive else keyword.lower() for keyword in definition.keywords}
+        # Longest delimiters first, so that `"""` wins over `"` and `<=` over `<`
+        self.line_comments = sorted(definition.line_comments, key=len, reverse=True)
+        self.block_comments = sorted(definition.block_comments, key=lambda delimiters: len(delimiters[0]), reverse=True)
+        self.string_delimiters = sorted(definition.string_delimiters, key=len, reverse=True)
+        self.operators = sorted(definition.operators, key=len, reverse=True)
+
+    @property
+    def name(self) -> str:
+        return self.definition.name
+
+    def tokenize(self, text: str) -> List[Dict[str, Any]]:
+        """Tokenize a source file of the language"""
+        tokens = []
+        position = 0
+        line = 0
+        line_start = 0
+
+        while position < len(text):
+            char = text[position]
+            if char.isspace():
+                if char == "\n":
+                    line += 1
+                    line_start = position + 1
+                position += 1
+                continue
+
+            token_type, end = self._match(text, position)
+            token_text = text[position:end]
+            end_line = line + token_text.count("\n")
+            # Byte columns, as those of the tree-sitter tokens
+            start_column = len(text[line_start:position].encode("utf8"))
+            if end_line != line:
+                line_start = text.rindex("\n", position, end) + 1
+            tokens.append(
+                {
+                    "type": token_type,
+                    "text": token_text,
+                    "start": line,
+                    "end": end_line,
+                    "start_column": start_column,
+                    "end_column": len(text[line_start:end].encode("utf8")),
+                }
+            )
+            position = end
+            line = end_line
+
+        return tokens
+
+    def _match(self, text: str, position: int) -> tuple[str, int]:
+        """Get the type and end position of the token starting at a position"""
+        for start_delimiter, end_delimiter in self.block_comments:
+            if text.startswith(start_delimiter, position):
+                end = text.find(end_delimiter, position + len(start_delimiter))
+                return "comment", len(text) if end == -1 else end + len(end_delimiter)
+
+        for prefix in self.line_comments:
+            if text.startswith(prefix, position):
+                end = text.find("\n", position)
+                return "comment", len(text) if end == -1 else end
+
+        for delimiter in self.string_delimiters:
+            if text.startswith(delimiter, position):
+                return "string", self._string_end(text, position, delimiter)
+
+        number = NUMBER_PATTERN.match(text, position)
+        if number:
+            return ("float" if number.group(1) or number.group(2) else "integer"), number.end()
+
+        identifier = IDENTIFIER_PATTERN.match(text, position)
+        if identifier:
+            word = identifier.group(0)
+            keyword = word if self.definition.case_sensitive else word.lower()
+            if keyword in self.keywords:
+                return keyword, identifier.end()
+            # Operators spelled as words (mod, div)
+            if word in self.operators:
+                return "operator", identifier.end()
+            return "identifier", identifier.end()
+
+        operator = self._match_operator(text, position)
+        if operator:
+            return "operator", position + len(operator)
+
+        return "punctuation", position + 1
+
+    def _string_end(self, text: str, position: int, delimiter: str) -> int:
+        """Get the end of a string literal, an unterminated string ending with its line"""
+        escape = self.definition.escape_character
+        index = position + len(delimiter)
+        while index < len(text):
+            if escape and text[index] == escape:
+                index += 2
+                continue
+            if text.startswith(delimiter, index):
+                return index + len(delimiter)
+            if text[index] == "\n" and len(delimiter) == 1:
+                return index
+            index += 1
+        return len(text)
+
+    def _match_operator(self, text: str, position: int) -> Optional[str]:
+        """Get the longest operator starting at a position"""
+        return next((operator for operator in self.operators if text.startswith(operator, position)), None)
@@ -0,0 +1,20 @@
+from .custom_language_definition_dto import CustomLanguageDefinitionDto
+from .html_region_dto import HtmlRegionDto
+from .language_detection_dto import LanguageAlternativeDto, LanguageDetectionDto
+from .notebook_source_dto import NotebookCellDto, NotebookSourceDto
+from .stripped_header_dto import StrippedHeaderDto
+from .tokenization_options_dto import TokenizationOptionsDto
+from .tokenization_result_dto import LanguageFallbackDto, TokenizationResultDto
+
+__all__ = [
+    "CustomLanguageDefinitionDto",
+    "HtmlRegionDto",
+    "LanguageAlternativeDto",
+    "LanguageDetectionDto",
+    "LanguageFallbackDto",
+    "NotebookCellDto",
+    "NotebookSourceDto",
+    "StrippedHeaderDto",
+    "TokenizationOptionsDto",
+    "TokenizationResultDto",
+]
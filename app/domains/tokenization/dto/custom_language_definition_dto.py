@@ -0,0 +1,59 @@
+from typing import List
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+
+class CustomLanguageDefinitionDto(BaseModel):
+    """DTO for the definition of a language tokenized by the generic tokenizer instead of a tree-sitter grammar"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "name": "algo",
+                "extensions": [".algo"],
+                "keywords": ["algorithme", "debut", "fin", "si", "alors", "sinon", "pour", "tantque", "retourner"],
+                "line_comments": ["//"],
+                "block_comments": [["/*", "*/"]],
+                "string_delimiters": ["\"", "'"],
+                "operators": ["<-", "<=", ">=", "<>", "+", "-", "*", "/", "=", "<", ">"],
+                "case_sensitive": False,
+            }
+        }
+    )
+
+    name: str = Field(..., min_length=1, max_length=50, description="Language name, as used in the language mapping")
+    extensions: List[str] = Field(..., min_length=1, description="File extensions of the language (e.g. .algo)")
+    keywords: List[str] = Field(default_factory=list, description="Reserved words, each one having its own type")
+    line_comments: List[str] = Field(default_factory=list, description="Prefixes of line comments (e.g. //, #)")
+    block_comments: List[List[str]] = Field(
+        default_factory=list, description="Start and end delimiters of block comments (e.g. [\"/*\", \"*/\"])"
+    )
+    string_delimiters: List[str] = Field(default_factory=list, description="Delimiters of string literals")
+    operators: List[str] = Field(default_factory=list, description="Operators, longest ones being matched first")
+    escape_character: str = Field(default="\\", max_length=1, description="Escape character inside strings")
+    case_sensitive: bool = Field(default=True, description="If False, keywords are matched case insensitively")
+
+    @field_validator("name")
+    def validate_name(cls, v):
+        """Normalize the language name"""
+        v = v.strip().lower()
+        if not v.replace("_", "").replace("-", "").isalnum():
+            raise ValueError("Language name can only contain letters, digits, '-' and '_'")
+        return v
+
+    @field_validator("extensions")
+    def validate_extensions(cls, v):
+        """Normalize the extensions, which must start with a dot"""
+        extensions = [extension.strip().lower() for extension in v]
+        for extension in extensions:
+            if not extension.startswith(".") or len(extension) < 2:
+                raise ValueError(f"Extension '{extension}' must start with a dot, e.g. .algo")
+        return extensions
+
+    @field_validator("block_comments")
+    def validate_block_comments(cls, v):
+        """Block comments are pairs of non-empty start and end delimiters"""
+        for delimiters in v:
+            if len(delimiters) != 2 or not all(delimiters):
+                raise ValueError("Block comments must be [start, end] pairs of non-empty delimiters")
+        return v
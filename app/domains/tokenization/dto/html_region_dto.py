@@ -0,0 +1,25 @@
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class HtmlRegionDto(BaseModel):
+    """DTO for a region of an HTML file written in another language (script, style, inline event handler)"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "language": "javascript",
+                "kind": "script",
+                "source": "\nfunction start() {\n  game.run();\n}\n",
+                "start_line": 12,
+            }
+        }
+    )
+
+    language: str = Field(..., description="Language of the region, as used in the language mapping")
+    kind: str = Field(..., description="Origin of the region: script, style or event_handler")
+    source: str = Field(..., description="Code of the region")
+    start_line: int = Field(..., description="Line (0-based) of the HTML file the region starts on")
+
+    def to_file_line(self, line: int) -> int:
+        """Get the line (0-based) of the HTML file of a line of the region source"""
+        return self.start_line + line
@@ -0,0 +1,40 @@
+from typing import List
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class LanguageAlternativeDto(BaseModel):
+    """DTO for a language considered by the language detection, with its score"""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"language": "c", "score": 0.2}})
+
+    language: str = Field(..., description="Language, as used in the language mapping")
+    score: float = Field(..., ge=0.0, le=1.0, description="Score of the language (0.0 to 1.0)")
+
+
+class LanguageDetectionDto(BaseModel):
+    """DTO for the language detected for a file, with the confidence of the detection"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "language": "cpp",
+                "confidence": 0.8,
+                "method": "content",
+                "alternatives": [{"language": "c", "score": 0.2}],
+                "threshold": 0.5,
+                "low_confidence": False,
+            }
+        }
+    )
+
+    language: str = Field(..., description="Detected language, as returned by detect_file_language")
+    confidence: float = Field(..., ge=0.0, le=1.0, description="Confidence of the detection (0.0 to 1.0)")
+    method: str = Field(
+        ..., description="Evidence of the language: filename, extension, shebang, notebook_kernel, content or default"
+    )
+    alternatives: List[LanguageAlternativeDto] = Field(
+        default_factory=list, description="Other languages considered, ordered by decreasing score"
+    )
+    threshold: float = Field(..., ge=0.0, le=1.0, description="Confidence under which the detection is flagged")
+    low_confidence: bool = Field(..., description="Whether the confidence is under the threshold")
@@ -0,0 +1,49 @@
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class NotebookCellDto(BaseModel):
+    """DTO for the position of a notebook code cell in the extracted source"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "cell_index": 2,
+                "start_line": 0,
+                "end_line": 4,
+            }
+        }
+    )
+
+    cell_index: int = Field(..., description="Index of the cell in the notebook, markdown cells included")
+    start_line: int = Field(..., description="First line (0-based) of the cell in the extracted source")
+    end_line: int = Field(..., description="Last line (0-based) of the cell in the extracted source")
+
+
+class NotebookSourceDto(BaseModel):
+    """DTO for the code extracted from a Jupyter notebook"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "language": "python",
+                "source": "import pandas as pd\n\ndf = pd.read_csv('data.csv')\n",
+                "cells": [
+                    {"cell_index": 1, "start_line": 0, "end_line": 0},
+                    {"cell_index": 3, "start_line": 2, "end_line": 2},
+                ],
+            }
+        }
+    )
+
+    language: str = Field(..., description="Language of the notebook kernel, as used in the language mapping")
+    source: str = Field(..., description="Code cells concatenated in order, separated by a blank line")
+    cells: List[NotebookCellDto] = Field(default_factory=list, description="Position of each code cell")
+
+    def cell_for_line(self, line: int) -> Optional[NotebookCellDto]:
+        """Get the code cell containing a line (0-based) of the extracted source"""
+        for cell in self.cells:
+            if cell.start_line <= line <= cell.end_line:
+                return cell
+        return None
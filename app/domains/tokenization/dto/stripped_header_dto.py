@@ -0,0 +1,23 @@
+from pydantic import BaseModel, ConfigDict, Field
+
+
+class StrippedHeaderDto(BaseModel):
+    """DTO for the license or file header comment removed from the beginning of a file before tokenization"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "start_line": 0,
+                "end_line": 20,
+                "start_byte": 0,
+                "end_byte": 1077,
+                "matched": "Permission is hereby granted, free of charge",
+            }
+        }
+    )
+
+    start_line: int = Field(..., description="Line (0-based) of the file the header starts on")
+    end_line: int = Field(..., description="Line (0-based) of the file the header ends on")
+    start_byte: int = Field(..., description="Offset of the first byte of the header")
+    end_byte: int = Field(..., description="Offset of the byte following the header")
+    matched: str = Field(..., description="Pattern or template the header matched")
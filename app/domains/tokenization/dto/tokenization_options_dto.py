@@ -0,0 +1,55 @@
+import re
+from typing import List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field, field_validator
+
+
+class TokenizationOptionsDto(BaseModel):
+    """DTO for the options applied by the tokenizer"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "normalize_struct_tags": False,
+                "max_unknown_token_percentage": 20.0,
+                "strip_headers": True,
+                "header_patterns": ["Permission is hereby granted", "^\\W*Course:"],
+                "header_templates": ["Author: {name}\nDate: {date}\nCourse: Algorithms 101"],
+            }
+        }
+    )
+
+    normalize_struct_tags: bool = Field(
+        default=False, description="If True, Go struct tag values are replaced by a placeholder and only keys are kept"
+    )
+    max_unknown_token_percentage: float = Field(
+        default=20.0,
+        ge=0.0,
+        le=100.0,
+        description="If the tokenizer of the detected language yields more unknown tokens than this percentage, "
+        "the language is detected again from the content",
+    )
+    strip_headers: bool = Field(
+        default=False, description="If True, an initial license or file header comment matching the patterns is removed"
+    )
+    header_patterns: Optional[List[str]] = Field(
+        default=None,
+        description="Regexes searched in the initial comment block to strip it, the known license patterns if None",
+    )
+    header_templates: List[str] = Field(
+        default_factory=list,
+        description="Known header texts whose {placeholders} match anything, the matching initial comment is stripped",
+    )
+    timeout_seconds: Optional[float] = Field(
+        default=None, gt=0, description="Time after which the tokenization of a file stops, None for no timeout"
+    )
+
+    @field_validator("header_patterns")
+    def validate_patterns(cls, v):
+        """Validate that the header patterns are valid regexes"""
+        for pattern in v or []:
+            try:
+                re.compile(pattern)
+            except re.error as e:
+                raise ValueError(f"Invalid header pattern {pattern!r}: {e}")
+        return v
@@ -0,0 +1,61 @@
+from typing import Any, Dict, List, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+
+from app.domains.tokenization.dto.stripped_header_dto import StrippedHeaderDto
+
+
+class LanguageFallbackDto(BaseModel):
+    """DTO for a content based language fallback, when the file extension led to a tokenizer that failed"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "detected_language": "python",
+                "language": "java",
+                "content_score": 0.88,
+                "unknown_token_percentage": 42.5,
+                "fallback_unknown_token_percentage": 0.0,
+            }
+        }
+    )
+
+    detected_language: str = Field(..., description="Language detected from the file path")
+    language: str = Field(..., description="Language the file was tokenized with, detected from its content")
+    content_score: float = Field(..., ge=0.0, le=1.0, description="Score of the language by the content heuristics")
+    unknown_token_percentage: float = Field(
+        ..., description="Percentage of unknown tokens with the tokenizer of the detected language"
+    )
+    fallback_unknown_token_percentage: float = Field(
+        ..., description="Percentage of unknown tokens with the tokenizer of the fallback language"
+    )
+
+
+class TokenizationResultDto(BaseModel):
+    """DTO for the tokens of a file and the language they were produced with"""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "tokens": [{"type": "module", "text": "import os", "start": 0, "end": 0}],
+                "language": "python",
+                "unknown_token_percentage": 0.0,
+                "language_fallback": None,
+                "stripped_header": None,
+            }
+        }
+    )
+
+    tokens: List[Dict[str, Any]] = Field(default_factory=list, description="Tokens of the file")
+    language: Optional[str] = Field(default=None, description="Language the file was tokenized with")
+    unknown_token_percentage: float = Field(
+        default=0.0, description="Percentage of tokens the tokenizer could not parse (ERROR and missing nodes)"
+    )
+    language_fallback: Optional[LanguageFallbackDto] = Field(
+        default=None, description="Content based fallback applied when the detected language tokenizer failed"
+    )
+    stripped_header: Optional[StrippedHeaderDto] = Field(
+        default=None, description="License or file header removed from the beginning of the file before tokenization"
+    )
+    timed_out: bool = Field(default=False, description="Whether the tokenization stopped at its timeout, no tokens")
+    elapsed_seconds: Optional[float] = Field(default=None, description="Time taken until the timeout, if timed out")
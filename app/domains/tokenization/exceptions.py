@@ -0,0 +1,48 @@
+"""Tokenization-specific exceptions for better error handling"""
+
+from app.shared.exceptions import ValidationException
+
+
+class NotebookException(ValidationException):
+    """Base exception for Jupyter notebook preprocessing"""
+
+    def __init__(self, message: str, details: dict = None):
+        super().__init__(message, details)
+        self.message = message
+
+
+class InvalidNotebookException(NotebookException):
+    """Raised when a .ipynb file is not a valid notebook document"""
+
+    def __init__(self, error: str):
+        message = f"Invalid Jupyter notebook: {error}"
+        super().__init__(message, details={"error_type": "invalid_notebook", "message": message, "error": error})
+
+
+class UnsupportedNotebookLanguageException(NotebookException):
+    """Raised when the kernel language of a notebook is not supported"""
+
+    def __init__(self, kernel_language: str, supported_languages: list = None):
+        message = f"Unsupported notebook kernel language: {kernel_language}"
+        super().__init__(
+            message,
+            details={
+                "error_type": "unsupported_notebook_language",
+                "message": message,
+                "kernel_language": kernel_language,
+                "supported_languages": supported_languages or [],
+            },
+        )
+        self.kernel_language = kernel_language
+
+
+class LanguageRegistrationException(ValidationException):
+    """Raised when a custom language definition cannot be registered (name or extensions already in use)"""
+
+    def __init__(self, message: str, conflicts: dict = None):
+        super().__init__(
+            message,
+            details={"error_type": "language_registration_failed", "message": message, "conflicts": conflicts or {}},
+        )
+        self.message = message
+        self.conflicts = conflicts or {}
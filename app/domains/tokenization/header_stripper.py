@@ -0,0 +1,152 @@
+import logging
+import re
+from typing import Optional, Sequence, Tuple
+
+from app.domains.tokenization.dto.stripped_header_dto import StrippedHeaderDto
+
+logger = logging.getLogger(__name__)
+
+# License texts and school-mandated header fields, searched in the initial comment block of the files
+DEFAULT_HEADER_PATTERNS = (
+    r"Permission is hereby granted, free of charge",
+    r"GNU (Lesser |Affero )?General Public License",
+    r"Licensed under the Apache License",
+    r"Redistribution and use in source and binary forms",
+    r"SPDX-License-Identifier",
+    r"Copyright\s*(\(c\)|©)?\s*\d{4}",
+    r"^\W*@?(author|auteur)\b",
+)
+
+# Line comment markers and block comment delimiters of the languages, C-like ones by default
+HASH_COMMENT_LANGUAGES = set("bash cmake dockerfile julia make perl python r ruby toml yaml".split())
+DASH_COMMENT_LANGUAGES = {"ada", "haskell", "lua", "sql"}
+LINE_COMMENT_MARKERS = {
+    **{language: ("#",) for language in HASH_COMMENT_LANGUAGES},
+    **{language: ("--",) for language in DASH_COMMENT_LANGUAGES},
+    "php": ("//", "#"),
+    "matlab": ("%",),
+    "asm": (";",),
+    "css": (),
+    "html": (),
+    "xml": (),
+}
+BLOCK_COMMENT_DELIMITERS = {
+    **{language: () for language in HASH_COMMENT_LANGUAGES | {"ada"}},
+    "python": (('"""', '"""'), ("'''", "'''")),
+    "julia": (("#=", "=#"),),
+    "ruby": (("=begin", "=end"),),
+    "lua": (("--[[", "]]"),),
+    "haskell": (("{-", "-}"),),
+    "ocaml": (("(*", "*)"),),
+    "pascal": (("{", "}"), ("(*", "*)")),
+    "html": (("<!--", "-->"),),
+    "xml": (("<!--", "-->"),),
+    "markdown": (("<!--", "-->"),),
+}
+DEFAULT_LINE_COMMENT_MARKERS = ("//",)
+DEFAULT_BLOCK_COMMENT_DELIMITERS = (("/*", "*/"),)
+
+# First lines kept before the header: shebang and PHP opening tag
+PREAMBLE_PATTERN = re.compile(r"\A(#![^\n]*\n|<\?php[^\n]*\n)")
+TEMPLATE_PLACEHOLDER_PATTERN = re.compile(r"\{[^{}]*\}")
+COMMENT_DECORATION_PATTERN = re.compile(r"^[\s*#/;%!-]+|[\s*#/;%!-]+$", re.MULTILINE)
+
+
+class HeaderStripper:
+    """
+    Remove the initial comment block of a file (license header, author/date/course header mandated by the school)
+    before tokenization, when it matches one of the configured patterns or header templates, so that identical
+    headers do not inflate the similarity of small files.
+
+    - patterns are regexes searched (case insensitively) in the comment block, DEFAULT_HEADER_PATTERNS by default
+    - templates are header texts whose `{placeholders}` match anything (`Author: {name}`), compared with the
+      comment block without its comment markers and whitespace insensitively
+
+    The header is replaced with the line breaks it spans, so that the tokens keep the lines of the original file.
+    """
+
+    def strip(
+        self,
+        text: str,
+        language: Optional[str],
+        patterns: Optional[Sequence[str]] = None,
+        templates: Sequence[str] = (),
+    ) -> Tuple[str, Optional[StrippedHeaderDto]]:
+        """Get the text without its header, and the stripped header (None if the file has no matching header)"""
+        header = self.find_header(text, language)
+        if header is None:
+            return text, None
+
+        start, end = header
+        matched = self._match(text[start:end], DEFAULT_HEADER_PATTERNS if patterns is None else patterns, templates)
+        if matched is None:
+            return text, None
+
+        stripped_header = StrippedHeaderDto(
+            start_line=text.count("\n", 0, start),
+            end_line=text.count("\n", 0, end),
+            start_byte=len(text[:start].encode("utf8")),
+            end_byte=len(text[:end].encode("utf8")),
+            matched=matched,
+        )
+        logger.debug(f"Stripped header of lines {stripped_header.start_line}-{stripped_header.end_line}: {matched}")
+        return text[:start] + "\n" * text.count("\n", start, end) + text[end:], stripped_header
+
+    def find_header(self, text: str, language: Optional[str]) -> Optional[Tuple[int, int]]:
+        """Get the character range of the run of comments beginning the file, after its shebang or PHP tag"""
+        line_markers = LINE_COMMENT_MARKERS.get(language, DEFAULT_LINE_COMMENT_MARKERS)
+        block_delimiters = BLOCK_COMMENT_DELIMITERS.get(language, DEFAULT_BLOCK_COMMENT_DELIMITERS)
+
+        preamble = PREAMBLE_PATTERN.match(text)
+        position = preamble.end() if preamble else 0
+        header_start = None
+        header_end = None
+
+        while True:
+            comment_start = len(text) - len(text[position:].lstrip())
+            comment_end = self._comment_end(text, comment_start, line_markers, block_delimiters)
+            if comment_end is None:
+                break
+            if header_start is None:
+                header_start = comment_start
+            header_end = position = comment_end
+
+        return (header_start, header_end) if header_start is not None else None
+
+    @staticmethod
+    def _comment_end(
+        text: str, position: int, line_markers: Sequence[str], block_delimiters: Sequence[Tuple[str, str]]
+    ) -> Optional[int]:
+        """Get the end of the comment starting at a position, None if no comment starts there"""
+        for opening, closing in block_delimiters:
+            if text.startswith(opening, position):
+                closing_start = text.find(closing, position + len(opening))
+                return None if closing_start == -1 else closing_start + len(closing)
+        for marker in line_markers:
+            if text.startswith(marker, position):
+                line_end = text.find("\n", position)
+                return len(text) if line_end == -1 else line_end
+        return None
+
+    @staticmethod
+    def _match(header: str, patterns: Sequence[str], templates: Sequence[str]) -> Optional[str]:
+        """Get the first pattern or template the header matches"""
+        for pattern in patterns:
+            if re.search(pattern, header, re.IGNORECASE | re.MULTILINE):
+                return pattern
+
+        content = HeaderStripper._normalize(header)
+        for template in templates:
+            parts = TEMPLATE_PLACEHOLDER_PATTERN.split(HeaderStripper._normalize(template))
+            parts = [re.escape(part) for part in parts]
+            if re.search(".*?".join(parts), content, re.IGNORECASE | re.DOTALL):
+                return template
+        return None
+
+    @staticmethod
+    def _normalize(text: str) -> str:
+        """Remove the comment markers and decorations of the lines, and collapse whitespace"""
+        for delimiter in ('"""', "'''", "/*", "*/", "<!--", "-->"):
+            text = text.replace(delimiter, " ")
+        return " ".join(COMMENT_DECORATION_PATTERN.sub(" ", text).split())
+
@@ -0,0 +1,115 @@
+import logging
+from typing import Dict, List
+
+from app.domains.tokenization.dto.html_region_dto import HtmlRegionDto
+from app.domains.tokenization.languages.models.html_processor import HtmlProcessor
+
+logger = logging.getLogger(__name__)
+
+# Values of the type attribute of script elements holding JavaScript (no type attribute is JavaScript too)
+JAVASCRIPT_SCRIPT_TYPES = {
+    "text/javascript",
+    "application/javascript",
+    "application/ecmascript",
+    "text/ecmascript",
+    "module",
+}
+
+
+class HtmlRegionExtractor:
+    """
+    Split an HTML file into the regions written in other languages: the JavaScript of `<script>` elements and
+    inline event handlers (`onclick="..."`), and the CSS of `<style>` elements. Scripts loaded with `src=` and
+    scripts of other types (JSON data, templates) are skipped. Each region records the line it starts on, so
+    that tokens and functions found in it keep the line numbers of the HTML file.
+    """
+
+    def __init__(self, parser):
+        self.parser = parser
+
+    def extract(self, content: str) -> List[HtmlRegionDto]:
+        """Get the embedded regions of an HTML file, in document order"""
+        if not self.parser:
+            return []
+
+        tree = self.parser.parse(bytes(content, "utf8"))
+        regions: List[HtmlRegionDto] = []
+        nodes_to_process = [tree.root_node]
+        while nodes_to_process:
+            node = nodes_to_process.pop()
+            if node.type == "script_element":
+                self._add_element_region(node, "javascript", "script", regions)
+                continue
+            if node.type == "style_element":
+                self._add_element_region(node, "css", "style", regions)
+                continue
+            if HtmlProcessor.is_event_handler(node):
+                self._add_event_handler_region(node, regions)
+                continue
+            nodes_to_process.extend(reversed(node.children))
+
+        logger.debug(f"Extracted {len(regions)} embedded regions from HTML file")
+        return regions
+
+    def _add_element_region(self, element, language: str, kind: str, regions: List[HtmlRegionDto]) -> None:
+        """Add the content of a script or style element as a region"""
+        start_tag = next((child for child in element.children if child.type == "start_tag"), None)
+        attributes = self._attributes(start_tag) if start_tag else {}
+        if kind == "script":
+            if "src" in attributes:
+                logger.debug(f"Skipping external script {attributes['src']}")
+                return
+            script_type = attributes.get("type", "").strip().lower()
+            if script_type and script_type not in JAVASCRIPT_SCRIPT_TYPES:
+                logger.debug(f"Skipping script of type {script_type}")
+                return
+
+        raw_text = next((child for child in element.children if child.type == "raw_text"), None)
+        if raw_text is None or not raw_text.text.strip():
+            return
+        regions.append(
+            HtmlRegionDto(
+                language=language,
+                kind=kind,
+                source=raw_text.text.decode("utf8", errors="replace"),
+                start_line=raw_text.start_point[0],
+            )
+        )
+
+    @staticmethod
+    def _add_event_handler_region(attribute, regions: List[HtmlRegionDto]) -> None:
+        """Add the JavaScript of an inline event handler attribute as a region"""
+        quoted_value = next((child for child in attribute.children if child.type == "quoted_attribute_value"), None)
+        value = None
+        if quoted_value is not None:
+            value = next((child for child in quoted_value.children if child.type == "attribute_value"), None)
+        if value is None or not value.text.strip():
+            return
+        regions.append(
+            HtmlRegionDto(
+                language="javascript",
+                kind="event_handler",
+                source=value.text.decode("utf8", errors="replace"),
+                start_line=value.start_point[0],
+            )
+        )
+
+    @staticmethod
+    def _attributes(start_tag) -> Dict[str, str]:
+        """Get the attributes of a start tag, by lowercase name"""
+        attributes = {}
+        for attribute in start_tag.children:
+            if attribute.type != "attribute":
+                continue
+            name = ""
+            value = ""
+            for child in attribute.children:
+                if child.type == "attribute_name":
+                    name = child.text.decode("utf8", errors="ignore").lower()
+                elif child.type == "attribute_value":
+                    value = child.text.decode("utf8", errors="ignore")
+                elif child.type == "quoted_attribute_value":
+                    value = child.text.decode("utf8", errors="ignore").strip("\"'")
+            if name:
+                attributes[name] = value
+        return attributes
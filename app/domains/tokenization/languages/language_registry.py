@@ -0,0 +1,68 @@
+from typing import Dict, List, Optional, Type
+
+from app.domains.tokenization.languages.models.bash_processor import BashProcessor
+from app.domains.tokenization.languages.models.cpp_processor import CppProcessor
+from app.domains.tokenization.languages.models.csharp_processor import CSharpProcessor
+from app.domains.tokenization.languages.models.go_processor import GoProcessor
+from app.domains.tokenization.languages.models.html_processor import HtmlProcessor
+from app.domains.tokenization.languages.models.java_processor import JavaProcessor
+from app.domains.tokenization.languages.models.javascript_processor import JavaScriptProcessor
+from app.domains.tokenization.languages.models.kotlin_processor import KotlinProcessor
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+from app.domains.tokenization.languages.models.make_processor import MakeProcessor
+from app.domains.tokenization.languages.models.php_processor import PhpProcessor
+from app.domains.tokenization.languages.models.python_processor import PythonProcessor
+from app.domains.tokenization.languages.models.ruby_processor import RubyProcessor
+from app.domains.tokenization.languages.models.rust_processor import RustProcessor
+from app.domains.tokenization.languages.models.sql_processor import SqlProcessor
+from app.domains.tokenization.languages.models.swift_processor import SwiftProcessor
+from app.domains.tokenization.languages.models.typescript_processor import TypeScriptProcessor
+
+
+class LanguageRegistry:
+    """Registry for managing language specific token processors"""
+
+    def __init__(self):
+        self._processors: Dict[str, Type[LanguageProcessor]] = {}
+        self._register_default_processors()
+
+    def _register_default_processors(self):
+        """Register all available language processor classes"""
+        self.register_processor(GoProcessor)
+        self.register_processor(PythonProcessor)
+        self.register_processor(JavaScriptProcessor)
+        self.register_processor(TypeScriptProcessor)
+        self.register_processor(JavaProcessor)
+        self.register_processor(CppProcessor)
+        self.register_processor(RustProcessor)
+        self.register_processor(KotlinProcessor)
+        self.register_processor(CSharpProcessor)
+        self.register_processor(PhpProcessor)
+        self.register_processor(RubyProcessor)
+        self.register_processor(SwiftProcessor)
+        self.register_processor(SqlProcessor)
+        self.register_processor(BashProcessor)
+        self.register_processor(MakeProcessor)
+        self.register_processor(HtmlProcessor)
+
+    def register_processor(self, processor_class: Type[LanguageProcessor]):
+        """Register a processor class with its language name"""
+        self._processors[processor_class.name] = processor_class
+
+    def get_language_names(self) -> List[str]:
+        """Get list of all languages having a dedicated processor"""
+        return list(self._processors.keys())
+
+    def processor_exists(self, language: str) -> bool:
+        """Check if a processor exists for the given language"""
+        return language in self._processors
+
+    def get_processor(self, language: str) -> Optional[LanguageProcessor]:
+        """Create the processor for a language, or None if the language has no dedicated processor"""
+        if not self.processor_exists(language):
+            return None
+        return self._processors[language]()
+
+
+# Global language registry instance
+language_registry = LanguageRegistry()
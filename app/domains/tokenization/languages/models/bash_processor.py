@@ -0,0 +1,61 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class BashProcessor(LanguageProcessor):
+    """
+    Shell script specific token processing.
+
+    Expansions:
+    - variable_expansion: `$VAR` (and special parameters like `$1`, `$@`)
+    - parameter_expansion: `${VAR}`, `${VAR:-default}`
+    - command_substitution: `$(...)` and backquotes, followed by the tokens of the substituted command
+
+    Quoting follows the shell semantics: a single_quoted_string (`'$HOME'`) is atomic as nothing is expanded
+    inside it, a double_quoted_string keeps its expansions as separate tokens.
+
+    Heredocs: the body of a heredoc with an unquoted delimiter (`<<EOF`) is an expanding_heredoc_body followed by
+    the tokens of its expansions, with a quoted delimiter (`<<'EOF'`) it is a literal_heredoc_body, atomic.
+    pipeline is kept as emitted by the grammar.
+    """
+
+    name = "bash"
+
+    LITERAL_STRING_TYPES = {"raw_string", "ansi_c_string"}
+    QUOTES = ("'", '"')
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if node_type == "simple_expansion":
+            return "variable_expansion"
+
+        if node_type == "expansion":
+            return "parameter_expansion"
+
+        if node_type == "raw_string":
+            return "single_quoted_string"
+
+        if node_type == "string":
+            return "double_quoted_string"
+
+        if node_type == "heredoc_body":
+            if self._has_quoted_delimiter(node):
+                return "literal_heredoc_body"
+            return "expanding_heredoc_body"
+
+        return node_type
+
+    def is_atomic(self, node) -> bool:
+        if node.type in self.LITERAL_STRING_TYPES:
+            return True
+        return node.type == "heredoc_body" and self._has_quoted_delimiter(node)
+
+    def _has_quoted_delimiter(self, node) -> bool:
+        """Check whether the heredoc a body belongs to has a quoted delimiter (<<'EOF', <<"EOF")"""
+        redirect = node.parent
+        if redirect is None or redirect.type != "heredoc_redirect":
+            return False
+        for child in redirect.children:
+            if child.type == "heredoc_start":
+                return child.text.decode("utf8").startswith(self.QUOTES)
+        return False
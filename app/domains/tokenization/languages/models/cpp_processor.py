@@ -0,0 +1,27 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class CppProcessor(LanguageProcessor):
+    """
+    C++ specific token processing.
+
+    Template parameter and argument lists use the same kinds as the generics of the other languages
+    (type_parameter_list, type_arguments), nested ones included: `vector<pair<int,int>>` gives two
+    type_arguments tokens, the closing `>>` is not read as a shift operator by the grammar.
+    String, raw string and char literals are atomic. Namespaces, preprocessor directives (preproc_*),
+    lambdas and both comment styles keep their tree-sitter kinds.
+    """
+
+    name = "cpp"
+
+    KIND_OVERRIDES = {
+        "template_parameter_list": "type_parameter_list",
+        "template_argument_list": "type_arguments",
+    }
+    STRING_LITERAL_TYPES = {"string_literal", "raw_string_literal", "char_literal", "concatenated_string"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        return self.KIND_OVERRIDES.get(node.type, node.type)
+
+    def is_atomic(self, node) -> bool:
+        return node.type in self.STRING_LITERAL_TYPES
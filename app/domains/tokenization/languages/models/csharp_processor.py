@@ -0,0 +1,75 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class CSharpProcessor(LanguageProcessor):
+    """
+    C# specific token processing.
+
+    Properties: each accessor of a property gets the kind of its keyword (get_accessor, set_accessor,
+    init_accessor), so auto-properties `{ get; set; }` and read-only ones `{ get; }` differ structurally.
+
+    LINQ query syntax is kept as emitted by the grammar (query_expression, from_clause, where_clause,
+    select_clause, ...), it already differs from the equivalent method chain.
+
+    Coroutines: methods, local functions and lambdas declared `async` get distinct kinds
+    (async_method_declaration, async_local_function_statement, async_lambda_expression), await_expression is kept.
+
+    Strings:
+    - interpolated_string: `$"Hello {name}"`, followed by an interpolation token for each `{...}` part
+    - verbatim_string_literal (`@"C:\\path"`), raw and plain string literals are atomic
+
+    Attributes: `[HttpGet]` is emitted as an attribute token followed by a single attribute_name token holding
+    the (qualified) name, arguments of the attribute are kept.
+    """
+
+    name = "csharp"
+
+    STRING_LITERAL_TYPES = {"string_literal", "verbatim_string_literal", "raw_string_literal", "character_literal"}
+    ACCESSOR_KEYWORDS = {"get", "set", "init"}
+    ASYNC_KINDS = {
+        "method_declaration": "async_method_declaration",
+        "local_function_statement": "async_local_function_statement",
+        "lambda_expression": "async_lambda_expression",
+    }
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if self._is_attribute_name(node):
+            return "attribute_name"
+
+        if node_type == "interpolated_string_expression":
+            return "interpolated_string"
+
+        if node_type == "accessor_declaration":
+            keyword = self._accessor_keyword(node)
+            if keyword:
+                return f"{keyword}_accessor"
+
+        if node_type in self.ASYNC_KINDS and self._has_modifier(node, source_code, b"async"):
+            return self.ASYNC_KINDS[node_type]
+
+        return node_type
+
+    def is_atomic(self, node) -> bool:
+        return node.type in self.STRING_LITERAL_TYPES or self._is_attribute_name(node)
+
+    def _is_attribute_name(self, node) -> bool:
+        """Check whether a node is the name of an attribute (`HttpGet` in `[HttpGet("{id}")]`)"""
+        parent = node.parent
+        return parent is not None and parent.type == "attribute" and self._field_of(node) == "name"
+
+    def _accessor_keyword(self, node) -> str:
+        """Get the keyword (get, set, init) of a property accessor, if any"""
+        for child in node.children:
+            if not child.is_named and child.type in self.ACCESSOR_KEYWORDS:
+                return child.type
+        return ""
+
+    @staticmethod
+    def _has_modifier(node, source_code: bytes, modifier: bytes) -> bool:
+        """Check whether a declaration has the given modifier (async, static...)"""
+        for child in node.children:
+            if child.type == "modifier" and source_code[child.start_byte : child.end_byte].strip() == modifier:
+                return True
+        return False
@@ -0,0 +1,186 @@
+import re
+from typing import Any, Dict, List, Optional
+
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+# key:"value" pairs of a struct tag, following the reflect.StructTag convention
+STRUCT_TAG_PAIR_PATTERN = re.compile(r'([^\s:"]+):"((?:[^"\\]|\\.)*)"')
+
+
+class GoProcessor(LanguageProcessor):
+    """
+    Go specific token processing.
+
+    Generics: tree-sitter-go exposes type parameters and type arguments with node types that changed
+    between grammar versions (constraint_elem, type_elem, ...), so they are mapped to stable token kinds:
+    - type_parameter_list / type_parameter_declaration: `[T, U any]`
+    - type_parameter: each declared parameter name (also the `T` bound in a `Stack[T]` receiver)
+    - type_constraint / type_constraint_term / underlying_type_constraint: `any`, `int | ~float64`
+    - generic_instantiation: call with explicit type arguments, e.g. `Map[int, string](...)`
+    - generic_receiver_type: receiver of a method declared on a generic type
+    - type_argument: each element of a type argument list
+
+    Struct tags (`json:"name"`) are emitted as a single struct_tag token instead of a string literal,
+    so that they can be normalized or ignored independently from the other strings.
+
+    Concurrency: channel direction and channel operations get their own kinds as they are a strong
+    structural signal in Go assignments:
+    - channel_type / receive_channel_type / send_channel_type: `chan int`, `<-chan int`, `chan<- int`
+    - channel_send: `results <- job * 2`
+    - channel_receive: `<-jobs`, channel_receive_statement: `job, ok := <-jobs` (select case communication)
+    - select_statement / select_case / select_default
+
+    String literals (raw and interpreted) are atomic: a multi-line raw string is a single token spanning
+    all its lines, and nothing inside it (even text that looks like Go code) is tokenized.
+
+    Composition: embedding is marked apart from the named fields (still field_declaration), so that
+    composition-heavy designs compare on their structure rather than on a stray type name:
+    - embedded_field / embedded_pointer_field: `http.Handler`, `*Logger` in a struct
+    - embedded_type: the type named by an embedded field
+    - embedded_interface: an interface embedded in another one, `io.Reader` in `interface { io.Reader }`
+    - anonymous_struct_type / anonymous_interface_type: a struct or interface written inline (a field, a
+      parameter, a composite literal...) rather than declared by a type spec, its fields and methods being
+      tokenized as those of a declared type, however deeply nested
+    """
+
+    name = "go"
+
+    CONSTRAINT_TERM_TYPES = {"constraint_elem", "constraint_term"}
+    STRING_LITERAL_TYPES = {"raw_string_literal", "interpreted_string_literal"}
+    EMBEDDABLE_TYPES = {"type_identifier", "qualified_type", "generic_type"}
+    INLINE_TYPES = {"struct_type": "anonymous_struct_type", "interface_type": "anonymous_interface_type"}
+    TYPE_DECLARATION_TYPES = {"type_spec", "type_alias"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+        parent = node.parent
+
+        if parent is not None and parent.type == "field_declaration" and self._field_of(node) == "tag":
+            return "struct_tag"
+
+        if node_type == "channel_type":
+            return self._classify_channel_type(node, source_code)
+
+        if node_type == "send_statement":
+            return "channel_send"
+
+        if node_type == "receive_statement":
+            return "channel_receive_statement"
+
+        if node_type == "unary_expression":
+            operator = node.child_by_field_name("operator")
+            if operator is not None and operator.type == "<-":
+                return "channel_receive"
+
+        if node_type == "communication_case":
+            return "select_case"
+
+        if node_type == "default_case" and parent is not None and parent.type == "select_statement":
+            return "select_default"
+
+        if node_type == "call_expression" and node.child_by_field_name("type_arguments") is not None:
+            return "generic_instantiation"
+
+        if parent is not None and parent.type == "type_parameter_declaration":
+            field = self._field_of(node)
+            if field == "name":
+                return "type_parameter"
+            if field == "type":
+                return "type_constraint"
+
+        if node_type in self.CONSTRAINT_TERM_TYPES:
+            return "type_constraint_term"
+
+        if node_type == "negated_type" and self._has_ancestor(node, {"type_parameter_declaration"}):
+            return "underlying_type_constraint"
+
+        if node_type == "generic_type" and self._in_receiver(node):
+            return "generic_receiver_type"
+
+        if parent is not None and parent.type == "type_arguments":
+            if node_type == "type_identifier" and self._in_receiver(node):
+                return "type_parameter"
+            return "type_argument"
+
+        if node_type == "type_identifier" and parent is not None and parent.type == "type_elem":
+            grandparent = parent.parent
+            if grandparent is not None and grandparent.type == "type_arguments" and self._in_receiver(node):
+                return "type_parameter"
+
+        if node_type == "field_declaration" and self._is_embedded_field(node):
+            if source_code[node.start_byte : node.end_byte].startswith(b"*"):
+                return "embedded_pointer_field"
+            return "embedded_field"
+
+        if node_type in self.EMBEDDABLE_TYPES and parent is not None and parent.type == "field_declaration":
+            if self._is_embedded_field(parent):
+                return "embedded_type"
+
+        if node_type == "interface_type_name" or (node_type == "type_elem" and self._is_embedded_interface(node)):
+            return "embedded_interface"
+
+        if node_type in self.INLINE_TYPES and (parent is None or parent.type not in self.TYPE_DECLARATION_TYPES):
+            return self.INLINE_TYPES[node_type]
+
+        return node_type
+
+    def is_atomic(self, node) -> bool:
+        return node.type in self.STRING_LITERAL_TYPES
+
+    def post_process(
+        self, tokens: List[Dict[str, Any]], options: Optional[TokenizationOptionsDto] = None
+    ) -> List[Dict[str, Any]]:
+        if options and options.normalize_struct_tags:
+            for token in tokens:
+                if token["type"] == "struct_tag":
+                    token["text"] = self.normalize_struct_tag(token["text"])
+        return tokens
+
+    @staticmethod
+    def normalize_struct_tag(tag: str) -> str:
+        """Replace the values of a struct tag by a placeholder: `json:"age" xml:"age"` -> `json:"<TAG>" xml:"<TAG>"`"""
+        if tag.startswith('"'):
+            # Interpreted string tag: "json:\"name\""
+            tag = tag[1:-1].replace('\\"', '"')
+        pairs = STRUCT_TAG_PAIR_PATTERN.findall(tag.strip("`"))
+        if not pairs:
+            return "<TAG>"
+        return " ".join(f'{key}:"<TAG>"' for key, _ in pairs)
+
+    @staticmethod
+    def _classify_channel_type(node, source_code: bytes) -> str:
+        """Distinguish bidirectional, receive-only and send-only channel types"""
+        text = b"".join(source_code[node.start_byte : node.end_byte].split())
+        if text.startswith(b"<-"):
+            return "receive_channel_type"
+        if text.startswith(b"chan<-"):
+            return "send_channel_type"
+        return "channel_type"
+
+    @staticmethod
+    def _is_embedded_field(node) -> bool:
+        """Check whether a field declaration embeds a type: it names no field"""
+        return node.child_by_field_name("name") is None
+
+    def _is_embedded_interface(self, node) -> bool:
+        """Check whether an interface element is a single type name (an embedded interface), not a type union"""
+        if node.parent is None or node.parent.type != "interface_type":
+            return False
+        named_children = node.named_children
+        return len(named_children) == 1 and named_children[0].type in self.EMBEDDABLE_TYPES
+
+    def _in_receiver(self, node) -> bool:
+        """Check whether a node belongs to the receiver of a method declaration"""
+        current = node.parent
+        while current is not None:
+            if current.type == "parameter_list":
+                return (
+                    current.parent is not None
+                    and current.parent.type == "method_declaration"
+                    and self._field_of(current) == "receiver"
+                )
+            if current.type in {"method_declaration", "function_declaration", "block"}:
+                return False
+            current = current.parent
+        return False
@@ -0,0 +1,31 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+# Elements whose content is written in another language, tokenized as a separate region
+EMBEDDED_ELEMENT_TYPES = {"script_element", "style_element"}
+
+
+class HtmlProcessor(LanguageProcessor):
+    """
+    HTML specific token processing.
+
+    Only the markup is tokenized here: the content of `<script>` and `<style>` elements and the values of
+    inline event handlers (`onclick="..."`) are excluded, being tokenized with the JavaScript and CSS
+    tokenizers as regions of the file (see HtmlRegionExtractor).
+    """
+
+    name = "html"
+
+    def is_excluded(self, node) -> bool:
+        if node.type == "raw_text":
+            return node.parent is not None and node.parent.type in EMBEDDED_ELEMENT_TYPES
+        if node.type == "quoted_attribute_value":
+            return self.is_event_handler(node.parent)
+        return False
+
+    @staticmethod
+    def is_event_handler(attribute) -> bool:
+        """Check whether an attribute node is an inline event handler (onclick, onsubmit...)"""
+        if attribute is None or attribute.type != "attribute":
+            return False
+        name = next((child for child in attribute.children if child.type == "attribute_name"), None)
+        return name is not None and name.text.decode("utf8", errors="ignore").lower().startswith("on")
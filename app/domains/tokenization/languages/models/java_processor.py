@@ -0,0 +1,23 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class JavaProcessor(LanguageProcessor):
+    """
+    Java specific token processing.
+
+    String literals are atomic, and triple quoted text blocks get their own text_block kind: the whole block
+    is a single token spanning all its lines, so nothing after it is mistaken for string content.
+    Records (record_declaration, compact_constructor_declaration), arrow-form switch expressions
+    (switch_expression, switch_rule) and yield_statement keep their tree-sitter kinds.
+    """
+
+    name = "java"
+
+    def classify(self, node, source_code: bytes) -> str:
+        if node.type == "string_literal" and source_code[node.start_byte : node.start_byte + 3] == b'"""':
+            return "text_block"
+        return node.type
+
+    def is_atomic(self, node) -> bool:
+        # Older grammars expose text blocks as a text_block node
+        return node.type in {"string_literal", "text_block"}
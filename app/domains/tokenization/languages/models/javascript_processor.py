@@ -0,0 +1,46 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class JavaScriptProcessor(LanguageProcessor):
+    """
+    JavaScript specific token processing.
+
+    Template literals: tree-sitter parses the substitutions of a template string as regular expressions,
+    nesting included, so their kinds are only made explicit:
+    - template_literal: the whole literal, at any nesting depth
+    - template_substitution: each `${...}` part, followed by the tokens of the embedded expression
+    - tagged_template / template_tag: a tagged template (sql`select ${id}`) and its tag, kept apart from
+      the literal itself
+
+    Arrow functions: the `=>` operator is emitted as a single arrow token.
+    """
+
+    name = "javascript"
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if node_type == "template_string":
+            return "template_literal"
+
+        if node_type == "call_expression" and self._is_tagged_template(node):
+            return "tagged_template"
+
+        parent = node.parent
+        if parent is not None and parent.type == "call_expression" and self._is_tagged_template(parent):
+            if self._field_of(node) == "function":
+                return "template_tag"
+
+        if node_type == "=>":
+            return "arrow"
+
+        return node_type
+
+    def include_anonymous(self, node) -> bool:
+        return node.type == "=>"
+
+    @staticmethod
+    def _is_tagged_template(node) -> bool:
+        """Check whether a call expression is a tagged template (its arguments are a template string)"""
+        arguments = node.child_by_field_name("arguments")
+        return arguments is not None and arguments.type == "template_string"
@@ -0,0 +1,74 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class KotlinProcessor(LanguageProcessor):
+    """
+    Kotlin specific token processing.
+
+    String templates: tree-sitter-kotlin already descends into `$name` and `${...}` entries, the kinds are
+    made explicit so that templates are not confused with plain strings:
+    - string_template: a string literal (single or triple quoted) holding at least one template entry
+    - template_identifier: each `$name` entry
+    - template_substitution: each `${...}` entry, followed by the tokens of the embedded expression
+
+    Declarations and calls:
+    - data_class_declaration: a class declared with the `data` modifier
+    - extension_function_declaration: a function declared on a receiver type (`fun String.slug()`)
+    - trailing_lambda: a lambda passed after the call parentheses (`items.map { it * 2 }`)
+    - nullable_type is kept as emitted by the grammar (`String?`)
+    """
+
+    name = "kotlin"
+
+    STRING_LITERAL_TYPES = {"string_literal", "line_string_literal", "multi_line_string_literal"}
+    TEMPLATE_ENTRY_TYPES = {"interpolated_identifier", "interpolated_expression"}
+    RECEIVER_TYPES = {"user_type", "nullable_type", "parenthesized_type", "type_modifiers"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if node_type in self.STRING_LITERAL_TYPES and self._has_template_entry(node):
+            return "string_template"
+
+        if node_type == "interpolated_identifier":
+            return "template_identifier"
+
+        if node_type == "interpolated_expression":
+            return "template_substitution"
+
+        if node_type == "class_declaration" and self._has_class_modifier(node, source_code, b"data"):
+            return "data_class_declaration"
+
+        if node_type == "function_declaration" and self._has_receiver_type(node):
+            return "extension_function_declaration"
+
+        if node_type == "annotated_lambda" and node.parent is not None and node.parent.type == "call_suffix":
+            return "trailing_lambda"
+
+        return node_type
+
+    def _has_template_entry(self, node) -> bool:
+        """Check whether a string literal holds `$name` or `${...}` entries"""
+        return any(child.type in self.TEMPLATE_ENTRY_TYPES for child in node.children)
+
+    @staticmethod
+    def _has_class_modifier(node, source_code: bytes, modifier: bytes) -> bool:
+        """Check whether a class declaration has the given class modifier (data, sealed, value...)"""
+        for child in node.children:
+            if child.type != "modifiers":
+                continue
+            for modifier_node in child.children:
+                if modifier_node.type != "class_modifier":
+                    continue
+                if source_code[modifier_node.start_byte : modifier_node.end_byte].strip() == modifier:
+                    return True
+        return False
+
+    def _has_receiver_type(self, node) -> bool:
+        """Check whether a function declaration has a receiver type before its name"""
+        for child in node.children:
+            if child.type == "simple_identifier":
+                return False
+            if child.type in self.RECEIVER_TYPES:
+                return True
+        return False
@@ -0,0 +1,63 @@
+from abc import ABC
+from typing import Any, Dict, List, Optional
+
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+
+
+class LanguageProcessor(ABC):
+    """
+    Base class for language specific token processing.
+    Each sub class must define the language name it handles (as used in the language mapping)
+    and can override the hooks below to refine the tree-sitter output for that language.
+    """
+
+    name: str
+
+    def classify(self, node, source_code: bytes) -> str:
+        """Return the token type for a named node (defaults to the tree-sitter node type)"""
+        return node.type
+
+    def token_text(self, node, source_code: bytes) -> str:
+        """Return the text of the token emitted for a node (defaults to the node source text)"""
+        return source_code[node.start_byte : node.end_byte].decode("utf8")
+
+    def include_anonymous(self, node) -> bool:
+        """Return True if an anonymous node (operator, keyword...) must be emitted as a token"""
+        return False
+
+    def is_atomic(self, node) -> bool:
+        """Return True if the children of this node must not be emitted as separate tokens"""
+        return False
+
+    def is_excluded(self, node) -> bool:
+        """Return True if this node and its children must not be emitted (text outside of the analyzed code)"""
+        return False
+
+    def post_process(
+        self, tokens: List[Dict[str, Any]], options: Optional[TokenizationOptionsDto] = None
+    ) -> List[Dict[str, Any]]:
+        """Hook applied on the full token stream once the tree walk is done"""
+        return tokens
+
+    @staticmethod
+    def _field_of(node) -> Optional[str]:
+        """Get the field name under which a node is attached to its parent, if any"""
+        parent = node.parent
+        if parent is None:
+            return None
+        for index, child in enumerate(parent.children):
+            if child == node:
+                return parent.field_name_for_child(index)
+        return None
+
+    @staticmethod
+    def _has_ancestor(node, node_types: set, stop_types: Optional[set] = None) -> bool:
+        """Check whether one of the node ancestors has a type in node_types"""
+        current = node.parent
+        while current is not None:
+            if current.type in node_types:
+                return True
+            if stop_types and current.type in stop_types:
+                return False
+            current = current.parent
+        return False
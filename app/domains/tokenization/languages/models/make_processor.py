@@ -0,0 +1,25 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class MakeProcessor(LanguageProcessor):
+    """
+    Makefile specific token processing.
+
+    Rules are split into their parts so that two Makefiles can be compared rule by rule:
+    - target: each name before the colon (`build`, `.PHONY`)
+    - prerequisite: each name after the colon
+    - recipe_line: each command of the recipe, followed by its variable references (`$(CC)`, `$@`)
+    """
+
+    name = "make"
+
+    NAME_TYPES = {"word", "variable_reference", "automatic_variable"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        parent = node.parent
+        if node.type in self.NAME_TYPES and parent is not None:
+            if parent.type == "targets":
+                return "target"
+            if parent.type == "prerequisites":
+                return "prerequisite"
+        return node.type
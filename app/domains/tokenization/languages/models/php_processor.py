@@ -0,0 +1,38 @@
+from typing import Any, Dict, List, Optional
+
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class PhpProcessor(LanguageProcessor):
+    """
+    PHP specific token processing.
+
+    Only the code inside `<?php ... ?>` regions is tokenized: the HTML around them (text and
+    text_interpolation nodes) and the php_tag delimiters are excluded, so a page with several PHP regions
+    produces one continuous token stream keeping the line numbers of the original file. The root program
+    token spans the whole page, HTML included, and is dropped.
+
+    - variable_name: `$name` is a single token
+    - heredoc: `<<<SQL`, followed by the tokens of its interpolated variables and expressions
+    - nowdoc: `<<<'SQL'` is atomic, like single quoted strings
+    - `//`, `#` and `/* */` comments are all emitted as comment tokens
+    """
+
+    name = "php"
+
+    HTML_TYPES = {"text", "text_interpolation", "php_tag"}
+    ATOMIC_TYPES = {"variable_name", "nowdoc", "string"}
+
+    def is_excluded(self, node) -> bool:
+        return node.type in self.HTML_TYPES
+
+    def is_atomic(self, node) -> bool:
+        return node.type in self.ATOMIC_TYPES
+
+    def post_process(
+        self, tokens: List[Dict[str, Any]], options: Optional[TokenizationOptionsDto] = None
+    ) -> List[Dict[str, Any]]:
+        if tokens and tokens[0]["type"] == "program":
+            del tokens[0]
+        return tokens
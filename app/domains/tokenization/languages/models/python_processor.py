@@ -0,0 +1,81 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class PythonProcessor(LanguageProcessor):
+    """
+    Python specific token processing.
+
+    Decorators: each `@name` line is emitted as a decorator token followed by a single decorator_name
+    token holding the dotted name (`dataclass`, `pytest.fixture`), arguments of decorator calls are kept.
+
+    F-strings: tree-sitter already descends into interpolations, the kinds are made explicit so that
+    formatted strings are not confused with plain strings:
+    - f_string: the whole literal (single, double or triple quoted)
+    - f_string_interpolation: each `{...}` part, followed by the tokens of the embedded expression
+    - type_conversion / format_specifier: `!r` and `:>10` (format_expression for nested `{width}`)
+
+    Coroutines: `async def`, `async with` and `async for` get kinds distinct from their synchronous
+    counterparts (async_function_definition, async_with_statement, async_for_statement), and the walrus
+    operator `:=` is emitted as a single walrus_operator token inside its named_expression.
+    """
+
+    name = "python"
+
+    DECORATOR_NAME_TYPES = {"identifier", "attribute"}
+    ASYNC_KINDS = {
+        "function_definition": "async_function_definition",
+        "with_statement": "async_with_statement",
+        "for_statement": "async_for_statement",
+    }
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if self._is_decorator_name(node):
+            return "decorator_name"
+
+        if node_type == "string" and self._is_f_string(node, source_code):
+            return "f_string"
+
+        if node_type == "interpolation":
+            return "f_string_interpolation"
+
+        if node_type in self.ASYNC_KINDS and node.child_count > 0 and node.children[0].type == "async":
+            return self.ASYNC_KINDS[node_type]
+
+        if node_type == ":=":
+            return "walrus_operator"
+
+        return node_type
+
+    def include_anonymous(self, node) -> bool:
+        return node.type == ":="
+
+    def is_atomic(self, node) -> bool:
+        return self._is_decorator_name(node)
+
+    def _is_decorator_name(self, node) -> bool:
+        """Check whether a node is the (dotted) name of a decorator"""
+        if node.type not in self.DECORATOR_NAME_TYPES:
+            return False
+        parent = node.parent
+        if parent is None:
+            return False
+        if parent.type == "decorator":
+            return True
+        # Decorator calls: @pytest.fixture(scope="module")
+        return (
+            parent.type == "call"
+            and parent.parent is not None
+            and parent.parent.type == "decorator"
+            and self._field_of(node) == "function"
+        )
+
+    @staticmethod
+    def _is_f_string(node, source_code: bytes) -> bool:
+        """Check the string prefix (f, rf, fr, ...) of a string node"""
+        for child in node.children:
+            if child.type == "string_start":
+                prefix = source_code[child.start_byte : child.end_byte].lower()
+                return b"f" in prefix
+        return False
@@ -0,0 +1,37 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class RubyProcessor(LanguageProcessor):
+    """
+    Ruby specific token processing.
+
+    - symbol: `:name` and `:"quoted name"` (hash_key_symbol is kept for `name:` keys)
+    - interpolated_string: a string holding `#{...}` parts, followed by an interpolation token for each of them
+    - do_block / block / block_parameters: `do |x| ... end` and `{ |x| ... }` blocks, as emitted by the grammar
+    - string_array / symbol_array: `%w[]` and `%i[]` literals
+
+    Heredocs (`<<SQL`, `<<-SQL`, squiggly `<<~SQL`): tree-sitter-ruby emits the body of a heredoc after the
+    statement opening it, it is made a heredoc token whose text chunks are heredoc_content tokens (normalized
+    by the similarity detection), so a long embedded SQL query does not drive the comparison of two files.
+    """
+
+    name = "ruby"
+
+    SYMBOL_TYPES = {"simple_symbol", "delimited_symbol"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if node_type in self.SYMBOL_TYPES:
+            return "symbol"
+
+        if node_type == "string" and any(child.type == "interpolation" for child in node.children):
+            return "interpolated_string"
+
+        if node_type == "heredoc_body":
+            return "heredoc"
+
+        return node_type
+
+    def is_atomic(self, node) -> bool:
+        return node.type == "simple_symbol"
@@ -0,0 +1,29 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class RustProcessor(LanguageProcessor):
+    """
+    Rust specific token processing.
+
+    Macro invocations (`println!`, `vec![]`) are macro_invocation tokens followed by a macro_name token
+    holding the macro path, which is not normalized like identifiers, so that heavy macro use only
+    matches when the same macros are used. String literals, raw strings `r#"..."#` included, are atomic.
+    Lifetimes, attributes (attribute_item), match arms and closures keep their tree-sitter kinds.
+    """
+
+    name = "rust"
+
+    STRING_LITERAL_TYPES = {"string_literal", "raw_string_literal", "char_literal"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        parent = node.parent
+        if parent is not None and parent.type == "macro_invocation" and self._field_of(node) == "macro":
+            return "macro_name"
+        return node.type
+
+    def is_atomic(self, node) -> bool:
+        if node.type in self.STRING_LITERAL_TYPES:
+            return True
+        # Scoped macro paths (std::println) are a single macro_name token
+        parent = node.parent
+        return parent is not None and parent.type == "macro_invocation" and self._field_of(node) == "macro"
@@ -0,0 +1,80 @@
+import re
+
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+# PostgreSQL dollar-quoted string: $$ ... $$ or $tag$ ... $tag$
+DOLLAR_QUOTED_PATTERN = re.compile(rb"^\$(\w*)\$.*\$\1\$$", re.DOTALL)
+
+
+class SqlProcessor(LanguageProcessor):
+    """
+    SQL specific token processing.
+
+    Formatting insensitivity: keywords are upper-cased (`select` -> `SELECT`) and the text of a token made of
+    several nodes is rebuilt from its leaves separated by a single space, comments excluded. Two queries
+    differing only by keyword case, indentation or line breaks between clauses give the same token stream
+    (line numbers apart).
+
+    - quoted_identifier: `"Order"`, `` `order` ``, kept apart from plain identifiers
+    - string_literal: `'text'`, kept apart from numeric literals
+    - comment: both `--` and `/* */` comments
+    - dollar_quoted_string: a PostgreSQL `$$ ... $$` (or `$body$ ... $body$`) function body, a single token
+      spanning all its lines whose content is not tokenized
+    """
+
+    name = "sql"
+
+    COMMENT_TYPES = {"comment", "marginalia"}
+    IDENTIFIER_QUOTES = ('"', "`", "[")
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+
+        if node_type in self.COMMENT_TYPES:
+            return "comment"
+
+        if self._is_dollar_quoted(source_code[node.start_byte : node.end_byte]):
+            return "dollar_quoted_string"
+
+        text = source_code[node.start_byte : node.end_byte].decode("utf8")
+        if node_type == "identifier" and text.startswith(self.IDENTIFIER_QUOTES):
+            return "quoted_identifier"
+
+        if node_type == "literal" and re.match(r"^[eEnN]?'", text):
+            return "string_literal"
+
+        return node_type
+
+    def token_text(self, node, source_code: bytes) -> str:
+        if node.child_count == 0 or self.is_atomic(node):
+            return self._leaf_text(node, source_code)
+
+        parts = []
+        nodes_to_process = [node]
+        while nodes_to_process:
+            current = nodes_to_process.pop()
+            if current.type in self.COMMENT_TYPES:
+                continue
+            if current.child_count == 0 or self.is_atomic(current):
+                text = self._leaf_text(current, source_code)
+                if text:
+                    parts.append(text)
+                continue
+            nodes_to_process.extend(reversed(current.children))
+        return " ".join(parts)
+
+    def is_atomic(self, node) -> bool:
+        return node.type in self.COMMENT_TYPES or self._is_dollar_quoted(node.text)
+
+    @staticmethod
+    def _leaf_text(node, source_code: bytes) -> str:
+        """Get the text of a leaf node, keywords being upper-cased"""
+        text = source_code[node.start_byte : node.end_byte].decode("utf8")
+        if node.type.startswith("keyword_"):
+            return text.upper()
+        return text.strip()
+
+    @staticmethod
+    def _is_dollar_quoted(text: bytes) -> bool:
+        """Check whether the text of a node is exactly a dollar-quoted string"""
+        return text is not None and text.startswith(b"$") and DOLLAR_QUOTED_PATTERN.match(text) is not None
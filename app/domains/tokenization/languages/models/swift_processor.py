@@ -0,0 +1,49 @@
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+
+
+class SwiftProcessor(LanguageProcessor):
+    """
+    Swift specific token processing.
+
+    Optionals: optional_type is kept for `String?`, the `!` of a forced unwrap (`name!`) is a force_unwrap token.
+
+    Closures: lambda literals are closure tokens, a closure written after the call parentheses
+    (`items.map { $0 * 2 }`, `DispatchQueue.main.async { ... }`) is a trailing_closure.
+
+    Strings:
+    - interpolated_string: a single line string holding `\\(...)` parts
+    - multi_line_string_literal: `\"\"\"` strings, one token spanning all their lines
+    - interpolation: each `\\(...)` part of both, followed by the tokens of the embedded expression
+
+    guard_statement is kept, `defer { ... }` is a defer_statement.
+    """
+
+    name = "swift"
+
+    def classify(self, node, source_code: bytes) -> str:
+        node_type = node.type
+        parent = node.parent
+
+        if node_type == "lambda_literal":
+            if parent is not None and parent.type == "call_suffix":
+                return "trailing_closure"
+            return "closure"
+
+        if node_type == "line_string_literal" and self._has_interpolation(node):
+            return "interpolated_string"
+
+        if node_type == "interpolated_expression":
+            return "interpolation"
+
+        if node_type == "bang" and parent is not None and parent.type == "postfix_expression":
+            return "force_unwrap"
+
+        if node.child_count > 0 and node.children[0].type == "defer":
+            return "defer_statement"
+
+        return node_type
+
+    @staticmethod
+    def _has_interpolation(node) -> bool:
+        """Check whether a string literal holds `\\(...)` parts"""
+        return any(child.type == "interpolated_expression" for child in node.children)
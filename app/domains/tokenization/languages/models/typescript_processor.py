@@ -0,0 +1,49 @@
+from app.domains.tokenization.languages.models.javascript_processor import JavaScriptProcessor
+
+
+class TypeScriptProcessor(JavaScriptProcessor):
+    """
+    TypeScript specific token processing, sharing the JavaScript template literal and arrow handling.
+
+    Type level constructs keep the tree-sitter kinds (type_annotation, interface_declaration,
+    type_alias_declaration, enum_declaration, ...) except for the ones aligned with the other languages:
+    - type_parameter_list: generic parameters `<T extends Entity>` (same kind as Go type parameters)
+    - type_assertion: `value as Type` and `<Type>value` casts
+    - decorator_name: the (dotted) name of a decorator, like in Python
+    """
+
+    name = "typescript"
+
+    KIND_OVERRIDES = {
+        "type_parameters": "type_parameter_list",
+        "as_expression": "type_assertion",
+    }
+    DECORATOR_NAME_TYPES = {"identifier", "member_expression"}
+
+    def classify(self, node, source_code: bytes) -> str:
+        if node.type in self.KIND_OVERRIDES:
+            return self.KIND_OVERRIDES[node.type]
+
+        if self._is_decorator_name(node):
+            return "decorator_name"
+
+        return super().classify(node, source_code)
+
+    def is_atomic(self, node) -> bool:
+        return self._is_decorator_name(node)
+
+    def _is_decorator_name(self, node) -> bool:
+        """Check whether a node is the (dotted) name of a decorator, called or not"""
+        if node.type not in self.DECORATOR_NAME_TYPES:
+            return False
+        parent = node.parent
+        if parent is None:
+            return False
+        if parent.type == "decorator":
+            return True
+        return (
+            parent.type == "call_expression"
+            and parent.parent is not None
+            and parent.parent.type == "decorator"
+            and self._field_of(node) == "function"
+        )
@@ -0,0 +1,116 @@
+import json
+import logging
+from typing import Any, Dict, Iterable, List, Optional
+
+from app.domains.tokenization.dto.notebook_source_dto import NotebookCellDto, NotebookSourceDto
+from app.domains.tokenization.exceptions import InvalidNotebookException, UnsupportedNotebookLanguageException
+
+logger = logging.getLogger(__name__)
+
+# Kernel names and languages found in notebook metadata that differ from the language mapping names
+KERNEL_LANGUAGE_ALIASES = {
+    "python2": "python",
+    "python3": "python",
+    "ipython": "python",
+    "ir": "r",
+    "c++": "cpp",
+    "c++11": "cpp",
+    "c++14": "cpp",
+    "c++17": "cpp",
+    "c++20": "cpp",
+    "xcpp": "cpp",
+    "xcpp17": "cpp",
+    "c#": "csharp",
+    "f#": "fsharp",
+    "node": "javascript",
+    "nodejs": "javascript",
+    "gophernotes": "go",
+    "evcxr": "rust",
+    "sh": "bash",
+}
+
+DEFAULT_KERNEL_LANGUAGE = "python"
+
+
+class NotebookExtractor:
+    """
+    Extract the code cells of a Jupyter notebook (nbformat 4) as a single source file.
+    Markdown and raw cells as well as outputs are skipped, code cells are concatenated in order and separated
+    by a blank line, and the position of each cell is recorded so that matches can point back to it.
+    """
+
+    def __init__(self, supported_languages: Iterable[str]):
+        self.supported_languages = set(supported_languages)
+
+    def extract(self, content: str) -> NotebookSourceDto:
+        """Extract the code cells of a notebook document, raising if it is invalid or its language unsupported"""
+        notebook = self.load(content)
+        language = self.detect_kernel_language(notebook)
+
+        lines: List[str] = []
+        cells: List[NotebookCellDto] = []
+        for cell_index, cell in enumerate(notebook.get("cells", [])):
+            if not isinstance(cell, dict) or cell.get("cell_type") != "code":
+                continue
+            cell_lines = self._cell_source(cell).splitlines()
+            if not any(line.strip() for line in cell_lines):
+                continue
+            if language == "python":
+                cell_lines = [self._comment_ipython_syntax(line) for line in cell_lines]
+
+            if lines:
+                lines.append("")
+            cells.append(
+                NotebookCellDto(cell_index=cell_index, start_line=len(lines), end_line=len(lines) + len(cell_lines) - 1)
+            )
+            lines.extend(cell_lines)
+
+        source = "\n".join(lines) + "\n" if lines else ""
+        logger.debug(f"Extracted {len(cells)} {language} code cells from notebook")
+        return NotebookSourceDto(language=language, source=source, cells=cells)
+
+    def detect_kernel_language(self, notebook: Dict[str, Any]) -> str:
+        """Get the supported language of a notebook from its metadata (python when it has none)"""
+        metadata = notebook.get("metadata") or {}
+        language_info = metadata.get("language_info") or {}
+        kernelspec = metadata.get("kernelspec") or {}
+        candidates = [language_info.get("name"), kernelspec.get("language"), kernelspec.get("name")]
+        candidates = [str(candidate).strip().lower() for candidate in candidates if candidate]
+
+        if not candidates:
+            return DEFAULT_KERNEL_LANGUAGE
+
+        for candidate in candidates:
+            language = KERNEL_LANGUAGE_ALIASES.get(candidate, candidate)
+            if language in self.supported_languages:
+                return language
+
+        raise UnsupportedNotebookLanguageException(candidates[0], sorted(self.supported_languages))
+
+    @staticmethod
+    def load(content: str) -> Dict[str, Any]:
+        """Parse the notebook JSON document"""
+        try:
+            notebook = json.loads(content)
+        except json.JSONDecodeError as e:
+            raise InvalidNotebookException(f"malformed JSON ({e.msg} at line {e.lineno})")
+
+        if not isinstance(notebook, dict) or not isinstance(notebook.get("cells"), list):
+            raise InvalidNotebookException("no cells list found (only nbformat 4 notebooks are supported)")
+        return notebook
+
+    @staticmethod
+    def _cell_source(cell: Dict[str, Any]) -> str:
+        """Get the source of a cell, stored either as a string or as a list of lines"""
+        source: Optional[Any] = cell.get("source", "")
+        if isinstance(source, list):
+            return "".join(source)
+        return source or ""
+
+    @staticmethod
+    def _comment_ipython_syntax(line: str) -> str:
+        """Turn IPython magics (%matplotlib inline) and shell escapes (!pip install) into comments"""
+        stripped = line.lstrip()
+        if stripped.startswith(("%", "!")):
+            return line[: len(line) - len(stripped)] + "# " + stripped
+        return line
@@ -0,0 +1,164 @@
+import re
+from dataclasses import dataclass
+from typing import Dict, Iterable, Iterator, List, Optional, Tuple
+
+# Characters of source a chunk holds before it is cut at the next safe boundary
+DEFAULT_STREAM_BUFFER_SIZE = 1_048_576
+
+
+@dataclass(frozen=True)
+class Delimited:
+    """Region of source between delimiters (a string or a comment), up to the end of the line without closer"""
+
+    opener: str
+    closer: Optional[str]  # None for a line comment
+    multiline: bool = False
+    escapes: bool = True  # Whether a backslash escapes the closer
+
+
+@dataclass(frozen=True)
+class SourceChunk:
+    """Consecutive whole lines of a source file, from its line `line_offset` (from 0)"""
+
+    text: str
+    line_offset: int
+
+
+LINE_COMMENT_SLASH = Delimited("//", None)
+BLOCK_COMMENT = Delimited("/*", "*/", multiline=True, escapes=False)
+DOUBLE_QUOTED = Delimited('"', '"')
+SINGLE_QUOTED = Delimited("'", "'")
+TEXT_BLOCK = Delimited('"""', '"""', multiline=True)
+
+C_LIKE = (LINE_COMMENT_SLASH, BLOCK_COMMENT, DOUBLE_QUOTED, SINGLE_QUOTED)
+
+# Strings and comments of the languages tokenized in chunks, the others being tokenized whole
+LANGUAGE_SYNTAX: Dict[str, Tuple[Delimited, ...]] = {
+    "python": (
+        Delimited("#", None),
+        TEXT_BLOCK,
+        Delimited("'''", "'''", multiline=True),
+        DOUBLE_QUOTED,
+        SINGLE_QUOTED,
+    ),
+    "c": C_LIKE,
+    "cpp": C_LIKE,
+    "csharp": (*C_LIKE, TEXT_BLOCK),
+    "java": (*C_LIKE, TEXT_BLOCK),
+    "kotlin": (*C_LIKE, TEXT_BLOCK),
+    "scala": (*C_LIKE, TEXT_BLOCK),
+    "swift": (*C_LIKE, TEXT_BLOCK),
+    "groovy": (*C_LIKE, TEXT_BLOCK),
+    "dart": (*C_LIKE, TEXT_BLOCK),
+    "solidity": C_LIKE,
+    "go": (*C_LIKE, Delimited("`", "`", multiline=True, escapes=False)),
+    "javascript": (*C_LIKE, Delimited("`", "`", multiline=True)),
+    "typescript": (*C_LIKE, Delimited("`", "`", multiline=True)),
+    "rust": (LINE_COMMENT_SLASH, BLOCK_COMMENT, Delimited('"', '"', multiline=True)),
+}
+
+OPENING_BRACKETS = "([{"
+CLOSING_BRACKETS = ")]}"
+# A line a chunk can begin with starts a statement or a declaration at the top level of the file
+BOUNDARY_START = re.compile(r"[A-Za-z_$#@/\"'`]")
+# Continuations of the previous statement, even at the top level
+CONTINUATION_START = re.compile(r"(else|elif|except|finally|catch)\b")
+# Ends of lines the following line continues (operators, a decorator or a block header)
+CONTINUATION_END = re.compile(r"([\\:,=+\-*&|.?]|(?<!\*)/)\s*$")
+
+
+class SourceChunker:
+    """
+    Cut a source file into chunks of whole lines of about `buffer_size` characters, so that a large file is
+    tokenized chunk by chunk under a bounded memory. A chunk ends before a line beginning a top-level statement:
+    outside of any string, comment or bracket, at the first column, and continuing neither the previous statement
+    (else, except, a decorator, an operator ending the previous line) nor a multi-line string or block comment.
+    A chunk is only cut at such a boundary, growing past the buffer until one is found.
+    """
+
+    def __init__(self, language: str, buffer_size: int = DEFAULT_STREAM_BUFFER_SIZE):
+        if buffer_size < 1:
+            raise ValueError("The buffer of the chunks has to hold at least one character")
+        self.buffer_size = buffer_size
+        syntax = LANGUAGE_SYNTAX.get(language, C_LIKE)
+        self._delimited = {delimited.opener: delimited for delimited in syntax}
+        openers = sorted(self._delimited, key=len, reverse=True)
+        self._opener = re.compile("|".join([re.escape(opener) for opener in openers] + [r"[()\[\]{}]"]))
+
+    @staticmethod
+    def supports(language: Optional[str]) -> bool:
+        """Whether the files of a language can be tokenized in chunks"""
+        return language in LANGUAGE_SYNTAX
+
+    def chunks(self, lines: Iterable[str]) -> Iterator[SourceChunk]:
+        """Cut the lines of a source (a text stream, its lines ending with their line break) into chunks"""
+        buffer: List[str] = []
+        size = 0
+        line_offset = 0
+        region: Optional[Delimited] = None
+        depth = 0
+        previous = None  # Last non-blank line
+
+        for number, line in enumerate(lines):
+            if size >= self.buffer_size and self._is_boundary(region, depth, previous, line):
+                yield SourceChunk("".join(buffer), line_offset)
+                buffer = []
+                size = 0
+                line_offset = number
+            buffer.append(line)
+            size += len(line)
+            region, depth = self._scan(line, region, depth)
+            if line.strip():
+                previous = line
+
+        if buffer:
+            yield SourceChunk("".join(buffer), line_offset)
+
+    @staticmethod
+    def _is_boundary(region: Optional[Delimited], depth: int, previous: Optional[str], line: str) -> bool:
+        """Whether a chunk can begin with a line, given the state of the source before it"""
+        if region is not None or depth > 0 or previous is None:
+            return False
+        if not BOUNDARY_START.match(line) or CONTINUATION_START.match(line):
+            return False
+        return not previous.startswith("@") and not CONTINUATION_END.search(previous)
+
+    def _scan(self, line: str, region: Optional[Delimited], depth: int) -> Tuple[Optional[Delimited], int]:
+        """Region (string or comment) the source is in at the end of a line, and the depth of its brackets"""
+        position = 0
+        while True:
+            if region is not None:
+                end = self._find_closer(line, position, region)
+                if end < 0:
+                    return (region if region.multiline else None), depth
+                position = end
+                region = None
+
+            match = self._opener.search(line, position)
+            if not match:
+                return None, depth
+            position = match.end()
+            token = match.group()
+            if token in OPENING_BRACKETS:
+                depth += 1
+            elif token in CLOSING_BRACKETS:
+                # Unbalanced closers (in a regular expression literal, say) do not hide the next boundaries
+                depth = max(depth - 1, 0)
+            elif self._delimited[token].closer is None:
+                return None, depth
+            else:
+                region = self._delimited[token]
+
+    @staticmethod
+    def _find_closer(line: str, position: int, region: Delimited) -> int:
+        """Position after the closer of a region in a line, -1 if it does not close on the line"""
+        while True:
+            index = line.find(region.closer, position)
+            if index < 0:
+                return -1
+            if region.escapes:
+                backslashes = len(line[:index]) - len(line[:index].rstrip("\\"))
+                if backslashes % 2:
+                    position = index + 1
+                    continue
+            return index + len(region.closer)
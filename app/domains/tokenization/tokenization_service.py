@@ -1,8 +1,16 @@
+import hashlib
+import heapq
+import io
+import itertools
+import json
 import logging
+import re
 import shutil
 import tempfile
+import time
+from importlib import metadata
 from pathlib import Path
-from typing import Any, Dict, List, Optional
+from typing import Any, Dict, Iterator, List, Optional, TextIO, Tuple
 from uuid import UUID, uuid4
 
 from tree_sitter import Language, Parser, Query
@@ -17,11 +25,37 @@ from app.domains.repositories.exceptions import (
 )
 from app.domains.repositories.submission_fetcher import SubmissionFetcher
 from app.domains.submissions.dto.create_submission_dto import CreateSubmissionDto
+from app.domains.tokenization.content_language_detector import ContentLanguageDetector
 from app.domains.tokenization.custom_cache import CustomCache
+from app.domains.tokenization.custom_language_tokenizer import CustomLanguageTokenizer
+from app.domains.tokenization.dto.custom_language_definition_dto import CustomLanguageDefinitionDto
+from app.domains.tokenization.dto.language_detection_dto import LanguageAlternativeDto, LanguageDetectionDto
+from app.domains.tokenization.dto.notebook_source_dto import NotebookSourceDto
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.dto.tokenization_result_dto import LanguageFallbackDto, TokenizationResultDto
+from app.domains.tokenization.exceptions import LanguageRegistrationException, NotebookException
+from app.domains.tokenization.header_stripper import HeaderStripper
+from app.domains.tokenization.html_region_extractor import HtmlRegionExtractor
+from app.domains.tokenization.languages.language_registry import language_registry
+from app.domains.tokenization.languages.models.language_processor import LanguageProcessor
+from app.domains.tokenization.notebook_extractor import DEFAULT_KERNEL_LANGUAGE, NotebookExtractor
+from app.domains.tokenization.source_chunker import DEFAULT_STREAM_BUFFER_SIZE, SourceChunk, SourceChunker
+from app.shared.deadlines import StageTimeout, check_deadline, stage_deadline
 from app.shared.exceptions import ValidationException
+from app.shared.metrics import pipeline_metrics
 
 logger = logging.getLogger(__name__)
 
+# Language of the mapping for Jupyter notebooks, resolved to the language of their kernel
+NOTEBOOK_LANGUAGE = "notebook"
+# Number of characters read to find the shebang line of a file
+SHEBANG_MAX_LENGTH = 256
+# Confidence under which a language detection is flagged as low confidence
+DEFAULT_LANGUAGE_CONFIDENCE_THRESHOLD = 0.5
+# Version of the token output: to bump with any change of the tokens yielded for a content (grammars, language
+# processors, token extraction), the cached token streams of the previous versions being no longer reused
+TOKENIZER_VERSION = 1
+
 
 class TokenizationService:
     def __init__(self):
@@ -29,6 +63,8 @@ class TokenizationService:
         self.parsers = {}
         self.languages = {}
         self.language_mapping = {}
+        # Extensions parsed with a dedicated grammar dialect while keeping the language of the mapping
+        self.grammar_overrides = {".tsx": "tsx"}
         self.similarity_service = SimilarityDetectionService()
         self.submission_fetcher = SubmissionFetcher()
         self.cache = CustomCache(
@@ -36,6 +72,14 @@ class TokenizationService:
         )
         self._setup_language_mapping()
         self._setup_parsers()
+        self.notebook_extractor = NotebookExtractor(
+            language for language in set(self.language_mapping.values()) if language in self.parsers
+        )
+        self.content_language_detector = ContentLanguageDetector(self.parsers)
+        self.html_region_extractor = HtmlRegionExtractor(self.parsers.get("html"))
+        self.header_stripper = HeaderStripper()
+        # Languages registered at runtime, tokenized by a generic tokenizer built from their definition
+        self.custom_languages: Dict[str, CustomLanguageTokenizer] = {}
 
     def _setup_language_mapping(self):
         """Set up file extension to language mapping"""
@@ -120,6 +164,8 @@ class TokenizationService:
             ".json5": "json",
             # Julia
             ".jl": "julia",
+            # Jupyter notebooks (code cells are analyzed in the language of the kernel)
+            ".ipynb": NOTEBOOK_LANGUAGE,
             # Kotlin
             ".kt": "kotlin",
             ".kts": "kotlin",
@@ -195,6 +241,7 @@ class TokenizationService:
             ".svelte": "svelte",
             # Swift
             ".swift": "swift",
+            "Package.swift": "swift",
             # TOML
             ".toml": "toml",
             # TypeScript
@@ -225,13 +272,30 @@ class TokenizationService:
             raise ValidationException(f"Invalid directory path: {directory}")
 
         supported_files = []
-        for file_path in directory.rglob("*"):
-            if file_path.is_file() and file_path.suffix.lower() in self.language_mapping:
+        # Sorted, for the same order of the files, and of their tokens, whatever the file system
+        for file_path in sorted(directory.rglob("*")):
+            # The symbolic links are never followed, their target possibly outside of the submission
+            if file_path.is_symlink() or not file_path.is_file():
+                continue
+            # Mapped names and extensions (Makefile, Dockerfile, .sh...), then scripts with no or an unknown
+            # extension (run, solve.txt) by shebang
+            if self._detect_language_by_path(file_path) or self._detect_shebang_language(
+                self._read_first_line(file_path)
+            ):
                 supported_files.append(file_path)
 
         logger.info(f"Extracted {len(supported_files)} supported files from {directory}")
         return supported_files
 
+    @staticmethod
+    def _read_first_line(file_path: Path) -> str:
+        """Read the first line of a file (bounded, binary files have no line breaks), empty if it cannot be read"""
+        try:
+            with open(file_path, "r", encoding="utf-8", errors="ignore") as f:
+                return f.readline(SHEBANG_MAX_LENGTH)
+        except OSError:
+            return ""
+
     def _setup_parsers(self):
         """Set up tree-sitter parsers for different languages"""
         # List of languages supported by tree-sitter-language-pack
@@ -304,6 +368,14 @@ class TokenizationService:
                 self.parsers[ext] = self.parsers[lang]
                 self.languages[ext] = self.languages[lang]
 
+        # Grammar dialects (e.g. TSX) override the parser of their extension
+        for ext, grammar in self.grammar_overrides.items():
+            try:
+                self.parsers[ext] = get_parser(grammar)
+                self.languages[ext] = get_language(grammar)
+            except Exception as e:
+                logger.warning(f"Failed to initialize {grammar} parser for {ext}, falling back to its language: {e}")
+
         logger.info(
             f"Tree-sitter parsers initialized: {initialized_count}/{len(supported_languages)} languages successful"
         )
@@ -634,7 +706,12 @@ class TokenizationService:
         """
         try:
             # Detect language
-            lang_key = self._detect_language(file_path)
+            lang_key = self.detect_file_language(file_path, text)
+            text = self._resolve_source(text, file_path)
+
+            # Functions of HTML files are those of their scripts
+            if lang_key == "html":
+                return self._extract_html_script_functions(text, file_path)
 
             # Get language-specific function query
             query_string = self._get_function_query(lang_key)
@@ -645,8 +722,9 @@ class TokenizationService:
                 return {}
 
             # Get parser and language
-            parser = self.parsers.get(lang_key)
-            language = self.languages.get(lang_key)
+            parser_key = self._get_parser_key(lang_key, file_path)
+            parser = self.parsers.get(parser_key)
+            language = self.languages.get(parser_key)
 
             if not parser or not language:
                 logger.warning(f"No parser/language available for {lang_key}")
@@ -728,10 +806,34 @@ class TokenizationService:
             )
             return functions
 
+        except NotebookException:
+            raise
         except Exception as e:
             logger.error(f"Function extraction failed for {lang_key}: {e}")
             return {}
 
+    def _extract_html_script_functions(self, text: str, file_path: Optional[Path]) -> Dict[str, Dict]:
+        """Extract the functions of the scripts of an HTML file, with the line numbers of the HTML file"""
+        functions = {}
+        source_lines = text.split("\n")
+        script_path = (file_path or Path("index.html")).with_suffix(".js")
+
+        for region in self.html_region_extractor.extract(text):
+            if region.kind != "script":
+                continue
+            for function in self.extract_functions_with_positions(region.source, script_path).values():
+                start_line = region.to_file_line(function["start_line"])
+                end_line = region.to_file_line(function["end_line"])
+                functions[f"{function['function_name']}_{start_line}"] = {
+                    **function,
+                    "start_line": start_line,
+                    "end_line": end_line,
+                    "code_block": self._extract_code_block_from_lines(source_lines, start_line, end_line + 1),
+                }
+
+        logger.debug(f"Extracted {len(functions)} functions from the scripts of HTML file {file_path}")
+        return functions
+
     def _extract_functions_fallback(self, tree, text: str, language: str) -> Dict[str, Dict]:
         """Fallback function extraction using iterative node traversal to avoid recursion limits"""
         # Skip function extraction for languages that don't have functions
@@ -910,48 +1012,18 @@ class TokenizationService:
     def _detect_language(self, file_path: Optional[Path] = None, content: Optional[str] = None) -> str:
         """Detect the programming language based on file extension or content"""
         if file_path:
-            # Check for exact filename matches first (e.g., Dockerfile, Makefile)
-            filename = file_path.name
-            if filename in self.language_mapping:
-                detected_lang = self.language_mapping[filename]
-                logger.debug(f"Detected language by filename '{filename}': {detected_lang}")
+            detected_lang = self._detect_language_by_path(file_path)
+            if detected_lang:
                 return detected_lang
 
-            # Check file extension
-            suffix = file_path.suffix.lower()
-            if suffix in self.language_mapping:
-                detected_lang = self.language_mapping[suffix]
-                logger.debug(f"Detected language by extension '{suffix}': {detected_lang}")
-                return detected_lang
-
-            # Special cases for files without extensions
-            if not suffix:
-                filename_lower = filename.lower()
-                special_files = {
-                    "dockerfile": "dockerfile",
-                    "makefile": "make",
-                    "rakefile": "ruby",
-                    "gemfile": "ruby",
-                    "cmakelists.txt": "cmake",
-                }
-                if filename_lower in special_files:
-                    detected_lang = special_files[filename_lower]
-                    logger.debug(f"Detected language by special filename '{filename}': {detected_lang}")
-                    return detected_lang
-
         # Content-based detection as fallback (simplified heuristics)
         if content:
             content_lower = content.lower().strip()
 
             # Check for shebang lines
-            if content_lower.startswith("#!"):
-                first_line = content.split("\n")[0].lower()
-                if "python" in first_line:
-                    return "python"
-                elif "bash" in first_line or "sh" in first_line:
-                    return "bash"
-                elif "node" in first_line:
-                    return "javascript"
+            shebang_lang = self._detect_shebang_language(content)
+            if shebang_lang:
+                return shebang_lang
 
             # Check for XML-like content
             if content_lower.startswith("<?xml") or "<" in content_lower and ">" in content_lower:
@@ -971,14 +1043,288 @@ class TokenizationService:
         logger.debug(f"Could not detect language, defaulting to 'python'")
         return "python"  # Default to Python if we can't detect the language
 
+    def _detect_language_by_path(self, file_path: Path) -> Optional[str]:
+        """Detect the programming language based on the file name or extension, None if it is not mapped"""
+        # Check for exact filename matches first (e.g., Dockerfile, Makefile)
+        filename = file_path.name
+        if filename in self.language_mapping:
+            detected_lang = self.language_mapping[filename]
+            logger.debug(f"Detected language by filename '{filename}': {detected_lang}")
+            return detected_lang
+
+        # Check file extension
+        suffix = file_path.suffix.lower()
+        if suffix in self.language_mapping:
+            detected_lang = self.language_mapping[suffix]
+            logger.debug(f"Detected language by extension '{suffix}': {detected_lang}")
+            return detected_lang
+
+        # Special cases for files without extensions
+        if not suffix:
+            filename_lower = filename.lower()
+            special_files = {
+                "dockerfile": "dockerfile",
+                "makefile": "make",
+                "gnumakefile": "make",
+                "rakefile": "ruby",
+                "gemfile": "ruby",
+                "cmakelists.txt": "cmake",
+            }
+            if filename_lower in special_files:
+                detected_lang = special_files[filename_lower]
+                logger.debug(f"Detected language by special filename '{filename}': {detected_lang}")
+                return detected_lang
+
+        return None
+
+    @staticmethod
+    def _detect_shebang_language(content: Optional[str]) -> Optional[str]:
+        """Detect the language of a script from its shebang line (#!/bin/sh, #!/usr/bin/env python3...)"""
+        if not content or not content.lstrip().startswith("#!"):
+            return None
+
+        words = content.lstrip().split("\n")[0][2:].strip().lower().split()
+        if not words:
+            return None
+        interpreter = words[0].rsplit("/", 1)[-1]
+        if interpreter == "env":
+            # env options (e.g. -S) come before the interpreter
+            interpreter = next((word for word in words[1:] if not word.startswith("-")), "")
+        interpreter = interpreter.rstrip("0123456789.")
+
+        shebang_interpreters = {
+            "python": "python",
+            "bash": "bash",
+            "sh": "bash",
+            "zsh": "bash",
+            "dash": "bash",
+            "ksh": "bash",
+            "node": "javascript",
+            "nodejs": "javascript",
+            "ruby": "ruby",
+            "perl": "perl",
+            "php": "php",
+        }
+        return shebang_interpreters.get(interpreter)
+
+    def detect_file_language(self, file_path: Optional[Path], content: str) -> str:
+        """
+        Detect the language of a file being analyzed, refining the extension based detection with its content
+        for ambiguous extensions (a .h header can be C or C++) and notebooks (language of their kernel), and with
+        the shebang line for scripts without extension. The shebang only wins over an extension unknown to the
+        language mapping: `solve.txt` starting with `#!/usr/bin/env python3` is python, `tool.py` starting with
+        `#!/bin/bash` stays python.
+        """
+        return self.detect_language_with_confidence(file_path, content).language
+
+    def detect_language_with_confidence(
+        self, file_path: Optional[Path], content: str, threshold: float = DEFAULT_LANGUAGE_CONFIDENCE_THRESHOLD
+    ) -> LanguageDetectionDto:
+        """
+        Detect the language of a file like detect_file_language, with the confidence of the detection and the
+        other languages considered. A detection whose confidence is under the threshold is flagged, so that the
+        file can be reported instead of being analyzed with the wrong tokenizer.
+        """
+        scores, method = self._score_languages(file_path, content)
+        ranked = sorted(scores.items(), key=lambda item: item[1], reverse=True)
+        language, confidence = ranked[0]
+
+        return LanguageDetectionDto(
+            language=language,
+            confidence=confidence,
+            method=method,
+            alternatives=[LanguageAlternativeDto(language=lang, score=score) for lang, score in ranked[1:]],
+            threshold=threshold,
+            low_confidence=confidence < threshold,
+        )
+
+    def _score_languages(self, file_path: Optional[Path], content: str) -> tuple[Dict[str, float], str]:
+        """Score the candidate languages of a file, and get the evidence the best candidate comes from"""
+        path_lang = self._detect_language_by_path(file_path) if file_path else None
+        shebang_lang = self._detect_shebang_language(content)
+
+        if not path_lang:
+            if shebang_lang:
+                logger.debug(f"Detected language by shebang: {file_path} -> {shebang_lang}")
+                return {shebang_lang: 0.9}, "shebang"
+            return {self._detect_language(): 0.1}, "default"
+
+        if path_lang == NOTEBOOK_LANGUAGE:
+            if self._is_notebook_document(content):
+                notebook = self.notebook_extractor.load(content)
+                kernel_lang = self.notebook_extractor.detect_kernel_language(notebook)
+                metadata = notebook.get("metadata") or {}
+                declared = bool(metadata.get("language_info") or metadata.get("kernelspec"))
+                return {kernel_lang: 0.9 if declared else 0.6}, "notebook_kernel"
+            # Code already extracted from a notebook (e.g. the code block of a function)
+            return {DEFAULT_KERNEL_LANGUAGE: 0.6}, "notebook_kernel"
+
+        if path_lang == "c" and file_path.suffix.lower() == ".h":
+            if self._is_cpp_header(content):
+                logger.debug(f"Detected C++ header by content: {file_path}")
+                return {"cpp": 0.8, "c": 0.2}, "content"
+            return {"c": 0.7, "cpp": 0.3}, "content"
+
+        method = "filename" if file_path.name in self.language_mapping or not file_path.suffix else "extension"
+        if shebang_lang and shebang_lang != path_lang:
+            # A script whose extension and shebang disagree (tool.py starting with #!/bin/bash)
+            return {path_lang: 0.8, shebang_lang: 0.2}, method
+        return {path_lang: 0.98 if method == "filename" else 0.95}, method
+
+    def _is_cpp_header(self, content: str) -> bool:
+        """Check whether a .h header contains C++ only constructs (classes, templates, namespaces...)"""
+        cpp_markers = [
+            r"^\s*(template\s*<|namespace\s+\w+|using\s+namespace\s)",
+            r"^\s*class\s+\w+[^;]*$",
+            r"^\s*(public|private|protected)\s*:",
+            r"\bstd::",
+            r"#include\s*<(iostream|vector|string|map|memory|algorithm)>",
+        ]
+        return any(re.search(marker, content, re.MULTILINE) for marker in cpp_markers)
+
+    def extract_notebook(self, content: str) -> NotebookSourceDto:
+        """Extract the code cells of a Jupyter notebook, raising if it is invalid or its kernel language unsupported"""
+        return self.notebook_extractor.extract(content)
+
+    def is_notebook(self, file_path: Optional[Path]) -> bool:
+        """Check whether a file is a Jupyter notebook"""
+        return file_path is not None and self._detect_language(file_path) == NOTEBOOK_LANGUAGE
+
+    def get_notebook_analysis_path(self, file_path: Path, language: str) -> Path:
+        """
+        Get the path under which the code extracted from a notebook is analyzed: the notebook path with an
+        extension of its kernel language (analysis.ipynb -> analysis.ipynb.py), so that it is detected as such.
+        """
+        return file_path.with_name(f"{file_path.name}{self.get_language_extension(language) or ''}")
+
+    def get_language_extension(self, language: str) -> Optional[str]:
+        """Get an extension a language is detected from, None if the language is not supported"""
+        return next(
+            (ext for ext, lang in self.language_mapping.items() if lang == language and ext.startswith(".")), None
+        )
+
+    def _resolve_source(self, text: str, file_path: Optional[Path]) -> str:
+        """Get the code to analyze: the code cells of a notebook document, else the text itself"""
+        if self.is_notebook(file_path) and self._is_notebook_document(text):
+            return self.extract_notebook(text).source
+        return text
+
+    @staticmethod
+    def _is_notebook_document(content: str) -> bool:
+        """Check whether a notebook file content is the notebook JSON document (and not code extracted from it)"""
+        return content.lstrip().startswith("{")
+
+    def _get_parser_key(self, lang_key: str, file_path: Optional[Path] = None) -> str:
+        """Get the key of the parser to use: the extension when it has a grammar dialect, else the language"""
+        if file_path and file_path.suffix.lower() in self.grammar_overrides:
+            return file_path.suffix.lower()
+        return lang_key
+
+    def parse_tree(self, text: str, lang_key: str, file_path: Optional[Path] = None):
+        """Parse a text with the tree-sitter parser of a language, None if there is no parser for it"""
+        parser = self.parsers.get(self._get_parser_key(lang_key, file_path))
+        if not parser:
+            return None
+        return parser.parse(bytes(text, "utf8"))
+
     def get_supported_languages(self) -> List[str]:
         """Get list of all supported programming languages"""
         return list(set(self.language_mapping.values()))
 
+    def get_language_versions(self) -> Dict[str, str]:
+        """
+        Version of the tokens yielded for each supported language, recorded with the detection runs: the tokenizer
+        version with the release of the grammar package and the ABI of the tree-sitter grammar of the language (of
+        its dialects too, e.g. TSX), or with the digest of the definition of a language registered at runtime
+        """
+        try:
+            package = metadata.version("tree-sitter-language-pack")
+        except metadata.PackageNotFoundError:
+            package = "unknown"
+        grammars = {language: language for language in self.get_supported_languages()}
+        grammars.update({grammar: extension for extension, grammar in self.grammar_overrides.items()})
+        versions = {}
+        for language, key in sorted(grammars.items()):
+            if language in self.custom_languages:
+                definition = self.custom_languages[language].definition.model_dump_json()
+                digest = hashlib.sha256(definition.encode("utf8")).hexdigest()[:16]
+                versions[language] = f"{TOKENIZER_VERSION}/custom-{digest}"
+            elif key in self.languages:
+                grammar = self.languages[key]
+                abi = getattr(grammar, "abi_version", None) or getattr(grammar, "version", None)
+                versions[language] = f"{TOKENIZER_VERSION}/{package}/abi-{abi}"
+        return versions
+
     def get_supported_extensions(self) -> List[str]:
         """Get list of all supported file extensions"""
         return list(self.language_mapping.keys())
 
+    def get_custom_languages(self) -> List[CustomLanguageDefinitionDto]:
+        """Get the definitions of the languages registered at runtime"""
+        return [tokenizer.definition for tokenizer in self.custom_languages.values()]
+
+    def register_custom_language(self, definition: CustomLanguageDefinitionDto) -> CustomLanguageDefinitionDto:
+        """
+        Register a language tokenized by a generic tokenizer built from its definition. Its extensions are added
+        to the language mapping, so that its files are collected, detected and compared like those of built-in
+        languages.
+
+        Raises:
+            LanguageRegistrationException: if the name or one of the extensions is already used
+        """
+        conflicts = {}
+        if definition.name in self.get_supported_languages() or definition.name in self.parsers:
+            conflicts["name"] = definition.name
+        mapped_extensions = {
+            extension: self.language_mapping[extension]
+            for extension in definition.extensions
+            if extension in self.language_mapping
+        }
+        if mapped_extensions:
+            conflicts["extensions"] = mapped_extensions
+
+        if conflicts:
+            reasons = []
+            if "name" in conflicts:
+                reasons.append("the name is already used by a supported language")
+            if mapped_extensions:
+                mapped = ", ".join(f"{extension} ({language})" for extension, language in mapped_extensions.items())
+                reasons.append(f"extensions are already mapped: {mapped}")
+            raise LanguageRegistrationException(
+                f"Cannot register language '{definition.name}': {'; '.join(reasons)}", conflicts
+            )
+
+        self.custom_languages[definition.name] = CustomLanguageTokenizer(definition)
+        for extension in definition.extensions:
+            self.language_mapping[extension] = definition.name
+
+        logger.info(f"Registered custom language {definition.name} for {', '.join(definition.extensions)}")
+        return definition
+
+    def load_custom_languages(self, definitions_path: Path) -> List[CustomLanguageDefinitionDto]:
+        """
+        Register the custom languages defined in a JSON file (a list of language definitions), e.g. at startup.
+
+        Raises:
+            ValidationException: if the file cannot be read or a definition is invalid
+            LanguageRegistrationException: if a language conflicts with a supported one
+        """
+        try:
+            with open(definitions_path, "r", encoding="utf-8") as f:
+                raw_definitions = json.load(f)
+        except (OSError, json.JSONDecodeError) as e:
+            raise ValidationException(f"Failed to load custom languages from {definitions_path}: {e}")
+
+        if not isinstance(raw_definitions, list):
+            raise ValidationException(f"Custom languages file {definitions_path} must contain a list of definitions")
+
+        try:
+            definitions = [CustomLanguageDefinitionDto(**raw_definition) for raw_definition in raw_definitions]
+        except (TypeError, ValueError) as e:
+            raise ValidationException(f"Invalid custom language definition in {definitions_path}: {e}")
+
+        return [self.register_custom_language(definition) for definition in definitions]
+
     def _extract_relative_path(self, file_path: Path, temp_base_path: Optional[Path] = None) -> str:
         """
         Extract relative path from project root for consistent caching.
@@ -1100,6 +1446,7 @@ class TokenizationService:
         file_path: Optional[Path] = None,
         submission_id: Optional[UUID] = None,
         project_root_path: Optional[Path] = None,
+        options: Optional[TokenizationOptionsDto] = None,
     ) -> List[Dict[str, Any]]:
         """
         Tokenizes the input text into a list of tokens using tree-sitter.
@@ -1109,6 +1456,7 @@ class TokenizationService:
             file_path: Full path to the file being tokenized
             submission_id: UUID of the submission for cache key
             project_root_path: Root path of the extracted project (optional, used for relative path calculation)
+            options: Tokenization options (optional, defaults are used when not provided)
         """
         try:
             # CACHE DISABLED FOR PERFORMANCE REASON -> it was ruining everything sadly
@@ -1133,43 +1481,240 @@ class TokenizationService:
             #         logger.debug(f"Cache hit for {cache_key}, returning cached tokens")
             #         return tokens
 
-            # Detect language
-            lang_key = self._detect_language(file_path)
+            result = self.tokenize_with_details(text, file_path, options)
 
-            # Try to get parser by language name first, then by extension
-            parser = self.parsers.get(lang_key)
-            if not parser and file_path:
-                # Try the detected language mapping
-                detected_lang = self.language_mapping.get(lang_key)
-                if detected_lang:
-                    parser = self.parsers.get(detected_lang)
+            # Store in cache if we have a cache key
+            # if cache_key:
+            #     self.cache.set(cache_key, result.tokens)
+            #     logger.debug(f"Stored {len(result.tokens)} tokens in cache for {cache_key}")
 
-            if not parser:
-                logger.warning(f"No parser available for {lang_key}, skipping tokenization")
-                return []
+            return result.tokens
 
-            # Parse the text
-            tree = parser.parse(bytes(text, "utf8"))
-            root_node = tree.root_node
+        except NotebookException:
+            raise
+        except Exception as e:
+            logger.error(f"Tokenization failed for {file_path}: {e}")
+            return []
 
-            # Extract tokens
-            tokens = []
-            self._extract_tokens(root_node, text.encode("utf8"), tokens)
+    def tokenize_with_details(
+        self,
+        text: str,
+        file_path: Optional[Path] = None,
+        options: Optional[TokenizationOptionsDto] = None,
+        language: Optional[str] = None,
+    ) -> TokenizationResultDto:
+        """
+        Tokenize the input text like tokenize, also returning the language used and the percentage of unknown
+        tokens. When the tokenizer of the detected language yields more unknown tokens than the
+        max_unknown_token_percentage option (the extension lies: a Java file renamed to .txt, a C file saved as
+        .py), the file is tokenized with the best scoring language of the content heuristics that parses it
+        better, and the fallback is recorded in the result. With the strip_headers option, a license or file
+        header beginning the file is removed first, and recorded in the result. With the timeout_seconds option,
+        the tokenization stops once it is exceeded, the result holding no tokens and being marked timed out. With
+        a language given, the text is tokenized with it instead of the detected one.
+        """
+        options = options or TokenizationOptionsDto()
+        started = time.monotonic()
+        with stage_deadline("tokenization", options.timeout_seconds) as deadline:
+            try:
+                result = self._tokenize_with_details(text, file_path, options, language)
+            except StageTimeout as e:
+                if e.deadline is not deadline:
+                    raise
+                logger.warning(f"Tokenization of {file_path} timed out after {e.elapsed_seconds:.1f} seconds")
+                result = TokenizationResultDto(timed_out=True, elapsed_seconds=round(e.elapsed_seconds, 3))
+        pipeline_metrics.observe_tokenization(result.language or language, time.monotonic() - started)
+        return result
+
+    def tokenize_stream(
+        self,
+        stream: TextIO,
+        file_path: Optional[Path] = None,
+        options: Optional[TokenizationOptionsDto] = None,
+        buffer_size: int = DEFAULT_STREAM_BUFFER_SIZE,
+    ) -> Iterator[Tuple[SourceChunk, TokenizationResultDto]]:
+        """
+        Tokenize a text stream chunk by chunk, like tokenize_with_details, so that the memory taken by a large file
+        is bounded by the buffer size rather than by its size. The stream is cut into chunks of about `buffer_size`
+        characters at the top-level statements (see SourceChunker), and the tokens of each chunk are yielded with
+        it, their lines being those of the chunk (from its `line_offset` in the file).
+
+        The language is detected from the first chunk, which alone has its header stripped and may fall back to
+        the language of the content, the next chunks being tokenized with the language of the first one. The
+        timeout applies to each chunk. Notebooks, and the languages without chunk syntax (HTML, the custom
+        languages...), are tokenized whole as a single chunk.
+        """
+        options = options or TokenizationOptionsDto()
+        # The head is read up to the end of its last line, for the chunks to hold whole lines
+        head = stream.read(buffer_size) + stream.readline()
+        language = self.detect_file_language(file_path, head)
+        if self.is_notebook(file_path) or language in self.custom_languages or not SourceChunker.supports(language):
+            text = head + stream.read()
+            yield SourceChunk(text, 0), self.tokenize_with_details(text, file_path, options)
+            return
+
+        chunker = SourceChunker(language, buffer_size)
+        next_options = options.model_copy(update={"strip_headers": False, "max_unknown_token_percentage": 100.0})
+        for index, chunk in enumerate(chunker.chunks(itertools.chain(io.StringIO(head), stream))):
+            result = self.tokenize_with_details(
+                chunk.text, file_path, next_options if index else options, language=language
+            )
+            language = result.language or language
+            yield chunk, result
+
+    def _tokenize_with_details(
+        self, text: str, file_path: Optional[Path], options: TokenizationOptionsDto, language: Optional[str] = None
+    ) -> TokenizationResultDto:
+        """Tokenize the input text like tokenize_with_details, within the deadline of the tokenization"""
+        lang_key = None
+        try:
+            # Detect language
+            lang_key = language or self.detect_file_language(file_path, text)
 
-            logger.debug(f"Tokenized {len(tokens)} tokens for language: {lang_key}")
+            # Only the code cells of notebooks are analyzed
+            text = self._resolve_source(text, file_path)
 
-            # Store in cache if we have a cache key
-            # if cache_key:
-            #     self.cache.set(cache_key, tokens)
-            #     logger.debug(f"Stored {len(tokens)} tokens in cache for {cache_key}")
+            # Header comments are replaced with line breaks, the tokens keep the lines of the file
+            stripped_header = None
+            if options.strip_headers:
+                text, stripped_header = self.header_stripper.strip(
+                    text, lang_key, options.header_patterns, options.header_templates
+                )
+                if stripped_header and not text.strip():
+                    logger.debug(f"{file_path} only holds a header, no tokens to analyze")
+                    return TokenizationResultDto(language=lang_key, stripped_header=stripped_header)
+
+            tokenized = self._tokenize_as(text, lang_key, file_path, options)
+            if tokenized is None:
+                logger.warning(f"No parser available for {lang_key}, skipping tokenization")
+                return TokenizationResultDto(language=lang_key, stripped_header=stripped_header)
+            tokens, unknown_percentage = tokenized
+            result = TokenizationResultDto(
+                tokens=tokens, language=lang_key, unknown_token_percentage=unknown_percentage
+            )
+
+            if unknown_percentage > options.max_unknown_token_percentage:
+                result = self._content_language_fallback(text, result, options) or result
+            result.stripped_header = stripped_header
 
-            return tokens
+            logger.debug(f"Tokenized {len(result.tokens)} tokens for language: {result.language}")
+            return result
 
+        except NotebookException:
+            raise
         except Exception as e:
             logger.error(f"Tokenization failed for {lang_key}: {e}")
-            return []
+            return TokenizationResultDto(language=lang_key)
+
+    def _tokenize_as(
+        self, text: str, lang_key: str, file_path: Optional[Path], options: TokenizationOptionsDto
+    ) -> Optional[tuple[List[Dict[str, Any]], float]]:
+        """Tokenize the text with the tokenizer of a language, None if there is no parser for it"""
+        custom_tokenizer = self.custom_languages.get(lang_key)
+        if custom_tokenizer:
+            return custom_tokenizer.tokenize(text), 0.0
+
+        # Try to get parser by language name first (or grammar dialect of the extension), then by extension
+        parser = self.parsers.get(self._get_parser_key(lang_key, file_path))
+        if not parser and file_path:
+            # Try the detected language mapping
+            detected_lang = self.language_mapping.get(lang_key)
+            if detected_lang:
+                parser = self.parsers.get(detected_lang)
+
+        if not parser:
+            return None
+
+        # Parse the text
+        tree = parser.parse(bytes(text, "utf8"))
+        root_node = tree.root_node
+
+        # Extract tokens, refined by the language processor when one is registered
+        processor = language_registry.get_processor(lang_key)
+        tokens = []
+        self._extract_tokens(root_node, text.encode("utf8"), tokens, processor)
+        if processor:
+            tokens = processor.post_process(tokens, options)
+
+        unknown_percentage = self._unknown_node_percentage(root_node)
+        if lang_key == "html":
+            tokens = self._add_html_region_tokens(text, tokens, options)
+        return tokens, unknown_percentage
+
+    def _add_html_region_tokens(
+        self, text: str, markup_tokens: List[Dict[str, Any]], options: TokenizationOptionsDto
+    ) -> List[Dict[str, Any]]:
+        """
+        Merge the markup tokens of an HTML file with the tokens of its scripts, styles and inline event handlers,
+        each tokenized with the tokenizer of its language. The lines of the region tokens are mapped back to the
+        lines of the HTML file, and tokens are kept in line order.
+        """
+        region_tokens = []
+        for region in self.html_region_extractor.extract(text):
+            tokenized = self._tokenize_as(region.source, region.language, None, options)
+            if tokenized is None:
+                logger.debug(f"No parser available for {region.language} {region.kind}, skipping region")
+                continue
+            for token in tokenized[0]:
+                token["start"] = region.to_file_line(token["start"])
+                token["end"] = region.to_file_line(token["end"])
+                region_tokens.append(token)
+
+        return list(heapq.merge(markup_tokens, region_tokens, key=lambda token: token["start"]))
 
-    def _extract_tokens(self, node, source_code: bytes, tokens: List[Dict[str, Any]]):
+    def _content_language_fallback(
+        self, text: str, result: TokenizationResultDto, options: TokenizationOptionsDto
+    ) -> Optional[TokenizationResultDto]:
+        """
+        Tokenize the text with the best scoring language of the content heuristics among those yielding fewer
+        unknown tokens than the detected language, None if no language does better
+        """
+        for language, score in self.content_language_detector.rank(text):
+            if language == result.language:
+                continue
+            tokenized = self._tokenize_as(text, language, None, options)
+            if tokenized is None or tokenized[1] >= result.unknown_token_percentage:
+                continue
+
+            tokens, unknown_percentage = tokenized
+            logger.info(
+                f"Tokenizer of {result.language} yielded {result.unknown_token_percentage:.1f}% unknown tokens, "
+                f"falling back to {language} detected from the content ({unknown_percentage:.1f}% unknown tokens)"
+            )
+            return TokenizationResultDto(
+                tokens=tokens,
+                language=language,
+                unknown_token_percentage=unknown_percentage,
+                language_fallback=LanguageFallbackDto(
+                    detected_language=result.language,
+                    language=language,
+                    content_score=score,
+                    unknown_token_percentage=result.unknown_token_percentage,
+                    fallback_unknown_token_percentage=unknown_percentage,
+                ),
+            )
+        return None
+
+    @staticmethod
+    def _unknown_node_percentage(root_node) -> float:
+        """Percentage of named nodes the parser could not recognize: ERROR nodes, their content and missing nodes"""
+        total = 0
+        unknown = 0
+        nodes_to_process = [(root_node, False)]
+        while nodes_to_process:
+            check_deadline()
+            node, in_error = nodes_to_process.pop()
+            in_error = in_error or node.type == "ERROR"
+            if node.is_named:
+                total += 1
+                if in_error or node.is_missing:
+                    unknown += 1
+            nodes_to_process.extend((child, in_error) for child in node.children)
+        return (unknown / total) * 100 if total else 0.0
+
+    def _extract_tokens(
+        self, node, source_code: bytes, tokens: List[Dict[str, Any]], processor: Optional[LanguageProcessor] = None
+    ):
         """Iteratively extract tokens from the syntax tree to avoid recursion limits"""
         # Use iterative approach with a stack to avoid recursion depth issues
         nodes_to_process = [node]
@@ -1177,21 +1722,37 @@ class TokenizationService:
         max_nodes = 20000  # Higher limit for token extraction as it processes more nodes
 
         while nodes_to_process and processed_count < max_nodes:
+            check_deadline()
             current_node = nodes_to_process.pop()
             processed_count += 1
 
-            # Add current node as token if it has meaningful content and is named
-            if current_node.start_byte < current_node.end_byte and current_node.is_named:
-                token_text = source_code[current_node.start_byte : current_node.end_byte].decode("utf8")
+            # Excluded nodes (e.g. HTML around PHP code) are neither emitted nor walked
+            if processor and processor.is_excluded(current_node):
+                continue
+
+            # Add current node as token if it has meaningful content and is named (or requested by the processor)
+            if current_node.start_byte < current_node.end_byte and (
+                current_node.is_named or (processor and processor.include_anonymous(current_node))
+            ):
+                if processor:
+                    token_text = processor.token_text(current_node, source_code)
+                else:
+                    token_text = source_code[current_node.start_byte : current_node.end_byte].decode("utf8")
 
                 token = {
-                    "type": current_node.type,
+                    "type": processor.classify(current_node, source_code) if processor else current_node.type,
                     "text": token_text,
                     "start": current_node.start_point[0],  # Just row number
                     "end": current_node.end_point[0],  # Just row number
+                    "start_column": current_node.start_point[1],  # Byte column, to locate matches exactly
+                    "end_column": current_node.end_point[1],
                 }
                 tokens.append(token)
 
+            # Atomic nodes are emitted as a single token, their children are not walked
+            if processor and processor.is_atomic(current_node):
+                continue
+
             # Add children to the stack for processing (in reverse order to maintain depth-first traversal)
             for child in reversed(current_node.children):
                 nodes_to_process.append(child)
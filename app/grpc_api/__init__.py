@@ -0,0 +1 @@
+# gRPC API package
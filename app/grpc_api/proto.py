@@ -0,0 +1,13 @@
+import sys
+from pathlib import Path
+
+import grpc
+
+# Root of the proto packages, the imports of the protos being resolved from it
+PROTO_ROOT = Path(__file__).parent / "protos"
+
+if str(PROTO_ROOT) not in sys.path:
+    sys.path.append(str(PROTO_ROOT))
+
+# Compiled from the proto when first imported (grpcio-tools), no generated module being kept in the tree
+submissions_pb2, submissions_pb2_grpc = grpc.protos_and_services("pamp/submissions/v1/submissions.proto")
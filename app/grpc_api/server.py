@@ -0,0 +1,40 @@
+import logging
+from concurrent.futures import ThreadPoolExecutor
+from typing import Optional
+
+import grpc
+
+from app.grpc_api.proto import submissions_pb2_grpc
+from app.grpc_api.submissions_servicer import SubmissionsServicer
+
+logger = logging.getLogger(__name__)
+
+
+class GrpcServer:
+    """
+    gRPC server of the API, run in the process of the HTTP server on a port of its own, with threads of its own. Each
+    call holds one of its threads, the streams of the run results included, for as long as it lasts. Stopping it
+    refuses the new calls and gives the running ones the grace period to finish, the streams still open being then
+    cancelled, independently of the HTTP server.
+    """
+
+    def __init__(self, port: int, max_workers: int = 10, shutdown_grace_seconds: float = 10.0):
+        self.port = port
+        self.max_workers = max_workers
+        self.shutdown_grace_seconds = shutdown_grace_seconds
+        self._server: Optional[grpc.Server] = None
+
+    def start(self) -> None:
+        self._server = grpc.server(ThreadPoolExecutor(max_workers=self.max_workers, thread_name_prefix="grpc"))
+        submissions_pb2_grpc.add_SubmissionsServiceServicer_to_server(SubmissionsServicer(), self._server)
+        self._server.add_insecure_port(f"[::]:{self.port}")
+        self._server.start()
+        logger.info(f"gRPC server listening on port {self.port}")
+
+    def stop(self) -> None:
+        """Stop the server, waiting for the running calls up to the grace period"""
+        if self._server is None:
+            return
+        self._server.stop(self.shutdown_grace_seconds).wait()
+        self._server = None
+        logger.info("gRPC server stopped")
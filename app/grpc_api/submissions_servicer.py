@@ -0,0 +1,227 @@
+import logging
+from contextlib import contextmanager
+from datetime import datetime
+from typing import Any, Iterator, Optional
+from uuid import UUID
+
+import grpc
+from fastapi import HTTPException
+
+from app.domains.repositories.archive_extractor import UPLOAD_TOO_LARGE, ArchiveLimitExceeded
+from app.domains.submissions.access_policy import AccessDenied, OutOfTenant
+from app.domains.submissions.analysis_worker_pool import AnalysisPoolDraining, AnalysisQueueFull
+from app.domains.submissions.dto.create_detection_run_dto import CreateDetectionRunDto
+from app.domains.submissions.submissions_models import SimilarityStatus
+from app.domains.submissions.submissions_service import SubmissionService
+from app.grpc_api.proto import submissions_pb2, submissions_pb2_grpc
+from app.shared.authorization import InvalidToken
+from app.shared.database import get_session
+from app.shared.exceptions import (
+    BadRequestException,
+    ConflictException,
+    DatabaseException,
+    NotFoundException,
+    ValidationException,
+)
+from app.shared.permissions import authenticate
+
+logger = logging.getLogger(__name__)
+
+# Status codes of the errors of the service layer, as the status codes of the HTTP API
+ERROR_CODES = (
+    (NotFoundException, grpc.StatusCode.NOT_FOUND),
+    (ValidationException, grpc.StatusCode.INVALID_ARGUMENT),
+    (BadRequestException, grpc.StatusCode.INVALID_ARGUMENT),
+    (ConflictException, grpc.StatusCode.ALREADY_EXISTS),
+    (DatabaseException, grpc.StatusCode.INTERNAL),
+)
+
+
+class SubmissionsServicer(submissions_pb2_grpc.SubmissionsServiceServicer):
+    """
+    gRPC servicer of the core operations, calling the same service layer as the HTTP handlers, each call on a
+    session of its own
+    """
+
+    def CreateSubmission(self, request_iterator, context):
+        with _submission_service(context) as service:
+            first = next(request_iterator, None)
+            if first is None or first.WhichOneof("payload") != "metadata":
+                raise ValidationException("The first message of the upload must be its metadata")
+            metadata = first.metadata
+            submission_data = {
+                "project_uuid": UUID(metadata.project_uuid),
+                "group_uuid": UUID(metadata.group_uuid),
+                "project_step_uuid": UUID(metadata.project_step_uuid),
+                "description": _optional(metadata, "description"),
+                "submitted_by_uuid": (
+                    UUID(metadata.submitted_by_uuid) if metadata.HasField("submitted_by_uuid") else None
+                ),
+            }
+            limits = service.get_upload_limits(submission_data["project_uuid"], submission_data["project_step_uuid"])
+            content = _read_chunks(request_iterator, limits.max_upload_bytes)
+            response = service.upload_submission(
+                content=content,
+                filename=metadata.filename,
+                submission_data=submission_data,
+                ip_address=_peer_address(context),
+                user_agent=_user_agent(context),
+                allow_duplicates=metadata.allow_duplicates,
+            )
+            return submissions_pb2.CreateSubmissionResponse(
+                submission_id=str(response.submission_id),
+                message=response.message,
+                files=[
+                    submissions_pb2.SubmissionFile(
+                        id=str(file.id),
+                        path=file.path,
+                        size_bytes=file.size_bytes,
+                        language=file.language,
+                        archive=file.archive,
+                    )
+                    for file in response.files
+                ],
+                skipped_entries=[
+                    submissions_pb2.SkippedEntry(path=entry.path, reason=entry.reason)
+                    for entry in response.skipped_entries
+                ],
+            )
+
+    def GetSubmissionStatus(self, request, context):
+        with _submission_service(context) as service:
+            status = service.get_submission_status(UUID(request.submission_id))
+            return submissions_pb2.SubmissionStatus(
+                submission_id=str(status.submission_id),
+                status=status.status,
+                transitions=[
+                    submissions_pb2.ProcessingTransition(status=transition.status, at=_time(transition.at))
+                    for transition in status.transitions
+                ],
+                processed_file_count=status.processed_file_count,
+                total_file_count=status.total_file_count,
+                error=status.error,
+                failed_file=status.failed_file,
+                attempt_count=status.attempt_count,
+                retry_at=_time(status.retry_at),
+                priority=status.priority,
+                queue_position=status.queue_position,
+            )
+
+    def CreateDetectionRun(self, request, context):
+        with _submission_service(context) as service:
+            run_data = CreateDetectionRunDto(
+                submission_ids=[UUID(submission_id) for submission_id in request.submission_ids] or None,
+                include_corpus=request.include_corpus,
+                teams={UUID(submission_id): team for submission_id, team in request.teams.items()},
+                include_same_team=request.include_same_team,
+                include_all_versions=request.include_all_versions,
+                tokenization_timeout_seconds=_optional(request, "tokenization_timeout_seconds"),
+                comparison_timeout_seconds=_optional(request, "comparison_timeout_seconds"),
+            )
+            run = service.create_detection_run(UUID(request.project_uuid), UUID(request.project_step_uuid), run_data)
+            return submissions_pb2.DetectionRun(
+                id=str(run.id),
+                project_uuid=str(run.project_uuid),
+                project_step_uuid=str(run.project_step_uuid),
+                status=run.status,
+                submission_count=run.submission_count,
+                pair_count=run.pair_count,
+                scheduled_pair_count=run.scheduled_pair_count,
+                completed_pair_count=run.completed_pair_count,
+                progress_percentage=run.progress_percentage,
+                created_at=_time(run.created_at),
+            )
+
+    def StreamRunResults(self, request, context):
+        with _submission_service(context) as service:
+            # Given up by the next poll once the client cancels the stream or the server stops
+            for pair in service.iter_run_results(UUID(request.run_id), active=context.is_active):
+                yield submissions_pb2.PairResult(
+                    similarity_id=str(pair.similarity_id),
+                    submission_id=str(pair.submission_id),
+                    compared_submission_id=str(pair.compared_submission_id),
+                    overall_similarity=pair.overall_similarity,
+                    status=SimilarityStatus(pair.status).value,
+                    suspicious=pair.suspicious,
+                    too_short=pair.too_short,
+                    same_team=pair.same_team,
+                    deleted_submission=pair.deleted_submission,
+                )
+
+
+@contextmanager
+def _submission_service(context: grpc.ServicerContext) -> Iterator[SubmissionService]:
+    """
+    Service layer of a call, scoped to the caller of the bearer token of its authorization metadata, on a session
+    closed with the call, its errors aborting the call with the status code of their HTTP counterpart
+    """
+    try:
+        principal = authenticate(dict(context.invocation_metadata()).get("authorization"))
+    except InvalidToken as e:
+        context.abort(grpc.StatusCode.UNAUTHENTICATED, str(e))
+    session = next(get_session())
+    try:
+        yield SubmissionService(session, principal)
+    except OutOfTenant as e:
+        context.abort(grpc.StatusCode.NOT_FOUND, str(e))
+    except AccessDenied as e:
+        context.abort(grpc.StatusCode.PERMISSION_DENIED, str(e))
+    except AnalysisQueueFull as e:
+        # Retriable after the given time, on another instance if this one is draining before a shutdown
+        draining = isinstance(e, AnalysisPoolDraining)
+        code = grpc.StatusCode.UNAVAILABLE if draining else grpc.StatusCode.RESOURCE_EXHAUSTED
+        context.set_trailing_metadata((("retry-after-seconds", str(e.retry_after_seconds)),))
+        context.abort(code, str(e))
+    except HTTPException as e:
+        code = next((code for cls, code in ERROR_CODES if isinstance(e, cls)), grpc.StatusCode.INTERNAL)
+        context.abort(code, _message(e.detail))
+    except ValueError as e:
+        # Malformed UUIDs and invalid DTOs
+        context.abort(grpc.StatusCode.INVALID_ARGUMENT, str(e))
+    except Exception as e:
+        logger.error(f"gRPC call failed: {str(e)}")
+        context.abort(grpc.StatusCode.INTERNAL, f"Internal server error: {str(e)}")
+    finally:
+        session.close()
+
+
+def _read_chunks(request_iterator: Iterator[Any], max_bytes: int) -> bytes:
+    """Read the chunks of an upload, giving up as soon as it exceeds the size limit"""
+    chunks, size = [], 0
+    for request in request_iterator:
+        if request.WhichOneof("payload") != "chunk":
+            raise ValidationException("Only the first message of the upload may be its metadata")
+        size += len(request.chunk)
+        if size > max_bytes:
+            error = ArchiveLimitExceeded(UPLOAD_TOO_LARGE, f"The uploaded file exceeds {max_bytes} bytes", max_bytes)
+            raise ValidationException(str(error), details=error.to_dict())
+        chunks.append(request.chunk)
+    return b"".join(chunks)
+
+
+def _message(detail: Any) -> str:
+    """Message of the detail of an error, structured or not"""
+    if isinstance(detail, dict):
+        return str(detail.get("message", detail))
+    return str(detail)
+
+
+def _optional(message: Any, field: str) -> Optional[Any]:
+    """Value of an optional field of a message, None if not set"""
+    return getattr(message, field) if message.HasField(field) else None
+
+
+def _time(value: Optional[datetime]) -> Optional[str]:
+    return value.isoformat() if value else None
+
+
+def _peer_address(context: grpc.ServicerContext) -> Optional[str]:
+    """IP address of the client, from its peer ("ipv4:127.0.0.1:50000" or "ipv6:[::1]:50000")"""
+    peer = context.peer() or ""
+    if not peer.startswith(("ipv4:", "ipv6:")):
+        return None
+    return peer.split(":", 1)[1].rsplit(":", 1)[0].strip("[]")
+
+
+def _user_agent(context: grpc.ServicerContext) -> Optional[str]:
+    return dict(context.invocation_metadata()).get("user-agent")
@@ -1,17 +1,35 @@
+import asyncio
 import logging
 import sys
 from contextlib import asynccontextmanager
 
-from fastapi import FastAPI
+from fastapi import FastAPI, HTTPException, Response
 from fastapi.middleware.cors import CORSMiddleware
 
 from app.config.config import get_settings
 from app.domains.detection.router import router as detection_router
 
 # Import domain routers
+from app.domains.health.router import probes_router
 from app.domains.health.router import router as health_router
+from app.domains.repositories.storage_integrity_scan import scan_storage_integrity
+from app.domains.submissions.dto.submission_page_dto import PAGE_HEADERS
 from app.domains.submissions.submissions_controller import router as submissions_router
+from app.domains.submissions.detection_run_scheduler import start_scheduled_detection_runs_periodically
+from app.domains.submissions.interrupted_processing_resumer import resume_interrupted_processing
+from app.domains.submissions.processing_log_pruner import prune_processing_log_periodically
+from app.domains.submissions.retention_purger import purge_expired_data_periodically
+from app.domains.submissions.run_lock_heartbeat import maintain_detection_run_locks_periodically
+from app.domains.submissions.upload_session_cleaner import clean_expired_upload_sessions_periodically
+from app.domains.submissions.webhook_notifier import resume_pending_webhook_deliveries
 from app.shared.database import create_db_and_tables
+from app.shared.error_handlers import register_error_handlers
+from app.shared.log_context import JsonLogFormatter, LogContextFilter, TextLogFormatter
+from app.shared.metrics import pipeline_metrics
+from app.shared.metrics_middleware import MetricsMiddleware
+from app.shared.permissions import authentication_warning
+from app.shared.rate_limit_middleware import RateLimitMiddleware
+from app.shared.request_id_middleware import RequestIdMiddleware
 
 settings = get_settings()
 
@@ -19,17 +37,22 @@ settings = get_settings()
 # Configure logging
 def setup_logging():
     """Configure logging for the application"""
-    log_level = logging.DEBUG if settings.debug else logging.INFO
-
-    # Configure the root logger
-    logging.basicConfig(
-        level=log_level,
-        format="%(asctime)s - %(name)s - %(levelname)s - %(message)s",
-        handlers=[
-            logging.StreamHandler(sys.stdout),
-            logging.FileHandler("app.log") if not settings.debug else logging.NullHandler(),
-        ],
+    log_level = (settings.log_level or ("DEBUG" if settings.debug else "INFO")).upper()
+
+    # Configure the root logger, each log carrying the fields of its context (correlation ID, submission, job)
+    handlers = [
+        logging.StreamHandler(sys.stdout),
+        logging.FileHandler("app.log") if not settings.debug else logging.NullHandler(),
+    ]
+    formatter = (
+        JsonLogFormatter()
+        if settings.log_format == "json"
+        else TextLogFormatter("%(asctime)s - %(name)s - %(levelname)s - %(message)s")
     )
+    for handler in handlers:
+        handler.setFormatter(formatter)
+        handler.addFilter(LogContextFilter())
+    logging.basicConfig(level=log_level, handlers=handlers)
 
     # Set specific loggers to appropriate levels
     logging.getLogger("uvicorn").setLevel(logging.INFO)
@@ -57,16 +80,94 @@ async def lifespan(app: FastAPI):
     logger.info("🚀 Database tables created successfully")
 
     # Initialize singleton services
-    from app.shared.services import cleanup_services, init_services
+    from app.shared.services import cleanup_services, get_readiness_probe, init_services
 
     init_services()
     logger.info("🔧 Singleton services initialized")
 
+    # Warn that the callers are not authenticated, every caller being allowed everything or every request refused
+    auth_warning = authentication_warning()
+    if auth_warning:
+        logger.warning(f"⚠️ {auth_warning}")
+
+    # Process again the submissions interrupted by the last shutdown, in the background
+    asyncio.create_task(asyncio.to_thread(resume_interrupted_processing))
+
+    # Send again the webhook deliveries left pending by the last shutdown, in the background
+    asyncio.create_task(asyncio.to_thread(resume_pending_webhook_deliveries))
+
+    # Check the stored files against their checksums, reporting the missing and corrupted ones, in the background
+    if settings.object_storage_integrity_scan:
+        asyncio.create_task(asyncio.to_thread(scan_storage_integrity))
+
+    # Expire the resumable uploads not finalized in time, in the background
+    upload_cleanup = asyncio.create_task(
+        clean_expired_upload_sessions_periodically(settings.chunked_upload_cleanup_interval_seconds)
+    )
+
+    # Prune the processing logs past their retention period, in the background
+    processing_log_pruning = asyncio.create_task(
+        prune_processing_log_periodically(
+            settings.processing_log_retention_days, settings.processing_log_prune_interval_seconds
+        )
+    )
+
+    # Purge the files and results of the project steps past their retention policy, in the background
+    retention_purge = None
+    if settings.retention_purge_enabled:
+        retention_purge = asyncio.create_task(
+            purge_expired_data_periodically(settings.retention_purge_interval_seconds, settings.retention_purge_dry_run)
+        )
+
+    # Renew the locks of the detection runs of this instance, and take over the runs abandoned by the others
+    run_lock_heartbeat = asyncio.create_task(
+        maintain_detection_run_locks_periodically(settings.detection_run_heartbeat_interval_seconds)
+    )
+
+    # Start the detection runs scheduled for the project steps once due, those missed while down at once
+    detection_schedule = asyncio.create_task(
+        start_scheduled_detection_runs_periodically(settings.detection_schedule_interval_seconds)
+    )
+
+    # Serve the gRPC API on a port of its own, in threads of its own
+    grpc_server = None
+    if settings.grpc_enabled:
+        from app.grpc_api.server import GrpcServer
+
+        grpc_server = GrpcServer(settings.grpc_port, settings.grpc_max_workers, settings.grpc_shutdown_grace_seconds)
+        grpc_server.start()
+
+    # Consume the submissions uploaded to the LMS from the ingestion queue, in a thread of its own
+    ingestion_consumer = None
+    if settings.ingestion_enabled:
+        from app.domains.submissions.ingestion_consumer import IngestionConsumer
+
+        ingestion_consumer = IngestionConsumer(
+            settings.ingestion_amqp_url,
+            settings.ingestion_queue,
+            settings.ingestion_max_attempts,
+            settings.ingestion_retry_delay_seconds,
+        )
+        ingestion_consumer.start()
+
     logger.info(f"📊 Starting {settings.app_name} v{settings.app_version}")
     logger.info(f"🔧 Debug mode: {settings.debug}")
     yield
 
-    # Shutdown: Cleanup services
+    # Shutdown: Report the service not ready for the load balancer to stop sending it traffic, stop the cleanup of
+    # the uploads, the gRPC server (its running calls given their grace period) and the ingestion consumer (its
+    # message being ingested given its timeout), drain the analysis and cleanup services
+    get_readiness_probe().mark_shutting_down()
+    upload_cleanup.cancel()
+    processing_log_pruning.cancel()
+    if retention_purge is not None:
+        retention_purge.cancel()
+    run_lock_heartbeat.cancel()
+    detection_schedule.cancel()
+    if grpc_server is not None:
+        await asyncio.to_thread(grpc_server.stop)
+    if ingestion_consumer is not None:
+        await asyncio.to_thread(ingestion_consumer.stop, settings.ingestion_shutdown_timeout_seconds)
     cleanup_services()
     logger.info("🛑 Application shutting down")
 
@@ -89,10 +190,26 @@ app.add_middleware(
     allow_credentials=True,
     allow_methods=["*"],
     allow_headers=["*"],
+    expose_headers=PAGE_HEADERS,  # The pagination of the listings, for the browser clients to read it
 )
 
+# Add rate limiting per API client, the expensive operations being limited apart
+if settings.rate_limit_enabled:
+    app.add_middleware(RateLimitMiddleware, trust_forwarded_for=settings.rate_limit_trust_forwarded_for)
+
+# Add the metrics of the requests, outermost to count the limited ones too
+if settings.metrics_enabled:
+    app.add_middleware(MetricsMiddleware)
+
+# Give each request an ID, outermost for the limited requests and the errors to carry it too
+app.add_middleware(RequestIdMiddleware)
+
+# Return every error in the same envelope: a stable code, a message, the details and the ID of the request
+register_error_handlers(app)
+
 # Include domain routers
 app.include_router(health_router)
+app.include_router(probes_router)
 app.include_router(submissions_router)
 app.include_router(detection_router)
 
@@ -113,10 +230,23 @@ async def root():
         "architecture": "Clean Architecture with Domain-Driven Design",
         "swagger": "/swagger-ui",
         "health": "/health",
+        "metrics": "/metrics",
         "domains": {"health": "/health", "submissions": "/submissions", "detection": "/detection"},
     }
 
 
+@app.get("/metrics", include_in_schema=False)
+async def metrics():
+    """
+    Prometheus metrics of the requests and of the pipeline: the analysis queue, the tokenization by language, the
+    comparisons, the token cache and the object storage
+    """
+    if not settings.metrics_enabled or not pipeline_metrics.enabled:
+        raise HTTPException(status_code=404, detail="Metrics are disabled")
+    content, content_type = pipeline_metrics.render()
+    return Response(content=content, media_type=content_type)
+
+
 if __name__ == "__main__":
     import uvicorn
 
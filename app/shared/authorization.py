@@ -0,0 +1,186 @@
+import base64
+import hashlib
+import hmac
+import json
+import time
+from dataclasses import dataclass, field
+from enum import Enum
+from typing import Any, Dict, FrozenSet, Optional
+from uuid import UUID
+
+from app.shared.tenancy import DEFAULT_TENANT_ID, check_tenant_id
+
+# Signature algorithm of the tokens, the only one accepted (never "none")
+TOKEN_ALGORITHM = "HS256"
+
+
+class Role(str, Enum):
+    """Role of the caller of the service: admins see everything, graders their assignments, students their work"""
+
+    ADMIN = "admin"
+    GRADER = "grader"
+    STUDENT = "student"
+    SERVICE = "service"
+
+
+class InvalidToken(Exception):
+    """Raised when a bearer token is malformed, wrongly signed, expired or not meant for this service"""
+
+
+@dataclass(frozen=True)
+class Principal:
+    """
+    Authenticated caller of the service, with the claims scoping its access: its tenant (the organization it acts
+    for, the default one if its token names none), the assignments (project steps) of a grader, and the student ID
+    of a student with the groups they belong to
+    """
+
+    subject: str
+    role: Role
+    assignment_ids: FrozenSet[UUID] = field(default_factory=frozenset)
+    student_id: Optional[UUID] = None
+    group_ids: FrozenSet[UUID] = field(default_factory=frozenset)
+    tenant_id: str = DEFAULT_TENANT_ID
+
+    @property
+    def owner_ids(self) -> FrozenSet[UUID]:
+        """Groups and submitters a submission of the caller is recorded under"""
+        return self.group_ids | ({self.student_id} if self.student_id else frozenset())
+
+    def to_claims(self) -> Dict[str, Any]:
+        """Claims of a token of the caller"""
+        claims: Dict[str, Any] = {"sub": self.subject, "role": self.role.value}
+        if self.tenant_id != DEFAULT_TENANT_ID:
+            claims["tenant_id"] = self.tenant_id
+        if self.assignment_ids:
+            claims["assignment_ids"] = sorted(str(assignment_id) for assignment_id in self.assignment_ids)
+        if self.student_id:
+            claims["student_id"] = str(self.student_id)
+        if self.group_ids:
+            claims["group_ids"] = sorted(str(group_id) for group_id in self.group_ids)
+        return claims
+
+
+class TokenVerifier:
+    """
+    Verify the bearer tokens of the callers: JWTs signed with HMAC-SHA256 by the identity provider with the shared
+    secret, holding the subject (sub), role and scoping claims of the caller, its tenant (tenant_id) included. Their
+    expiry (exp) and start (nbf) are checked with some clock skew, and their issuer (iss) and audience (aud) when
+    configured.
+    """
+
+    def __init__(
+        self,
+        secret: str,
+        issuer: Optional[str] = None,
+        audience: Optional[str] = None,
+        clock_skew_seconds: float = 30.0,
+    ):
+        if not secret:
+            raise ValueError("The secret of the tokens is required")
+        self.secret = secret.encode("utf-8")
+        self.issuer = issuer
+        self.audience = audience
+        self.clock_skew_seconds = clock_skew_seconds
+
+    def verify(self, token: str, now: Optional[float] = None) -> Principal:
+        """
+        Caller of a token
+
+        Raises:
+            InvalidToken: If the token is malformed, wrongly signed, expired, not yet valid, from another issuer, for
+                another audience, or missing its subject or role
+        """
+        try:
+            header_segment, payload_segment, signature_segment = token.split(".")
+            header = json.loads(_b64decode(header_segment))
+            signature = _b64decode(signature_segment)
+        except (ValueError, TypeError) as e:
+            raise InvalidToken(f"Malformed token: {str(e)}")
+        if not isinstance(header, dict) or header.get("alg") != TOKEN_ALGORITHM:
+            raise InvalidToken(f"Only {TOKEN_ALGORITHM} tokens are accepted")
+        if not hmac.compare_digest(signature, self._sign(f"{header_segment}.{payload_segment}")):
+            raise InvalidToken("Invalid token signature")
+        try:
+            claims = json.loads(_b64decode(payload_segment))
+        except (ValueError, TypeError) as e:
+            raise InvalidToken(f"Malformed token claims: {str(e)}")
+        if not isinstance(claims, dict):
+            raise InvalidToken("Malformed token claims")
+        self._check_registered_claims(claims, time.time() if now is None else now)
+        return _principal(claims)
+
+    def issue(self, principal: Principal, ttl_seconds: float = 3600.0, now: Optional[float] = None) -> str:
+        """Token of a caller, valid for the given time (for the tests and the internal tooling)"""
+        issued_at = int(time.time() if now is None else now)
+        claims = {**principal.to_claims(), "iat": issued_at, "exp": issued_at + int(ttl_seconds)}
+        if self.issuer:
+            claims["iss"] = self.issuer
+        if self.audience:
+            claims["aud"] = self.audience
+        header_segment = _b64encode(json.dumps({"alg": TOKEN_ALGORITHM, "typ": "JWT"}).encode("utf-8"))
+        payload_segment = _b64encode(json.dumps(claims).encode("utf-8"))
+        signature_segment = _b64encode(self._sign(f"{header_segment}.{payload_segment}"))
+        return f"{header_segment}.{payload_segment}.{signature_segment}"
+
+    def _sign(self, signing_input: str) -> bytes:
+        return hmac.new(self.secret, signing_input.encode("ascii"), hashlib.sha256).digest()
+
+    def _check_registered_claims(self, claims: Dict[str, Any], now: float) -> None:
+        """Check the expiry, start, issuer and audience of a token"""
+        expires_at, not_before = claims.get("exp"), claims.get("nbf")
+        if not isinstance(expires_at, (int, float)):
+            raise InvalidToken("The token has no expiry")
+        if now > expires_at + self.clock_skew_seconds:
+            raise InvalidToken("The token has expired")
+        if isinstance(not_before, (int, float)) and now < not_before - self.clock_skew_seconds:
+            raise InvalidToken("The token is not yet valid")
+        if self.issuer and claims.get("iss") != self.issuer:
+            raise InvalidToken("The token comes from another issuer")
+        if self.audience:
+            audience = claims.get("aud")
+            audiences = audience if isinstance(audience, list) else [audience]
+            if self.audience not in audiences:
+                raise InvalidToken("The token is meant for another audience")
+
+
+def bearer_token(authorization: Optional[str]) -> Optional[str]:
+    """Token of an Authorization header of the Bearer scheme, None without one"""
+    if not authorization:
+        return None
+    scheme, _, token = authorization.strip().partition(" ")
+    if scheme.lower() != "bearer" or not token.strip():
+        raise InvalidToken("The Authorization header must be a Bearer token")
+    return token.strip()
+
+
+def _principal(claims: Dict[str, Any]) -> Principal:
+    """Caller of the claims of a verified token"""
+    if not isinstance(claims.get("sub"), str) or not claims["sub"]:
+        raise InvalidToken("The token has no subject")
+    try:
+        role = Role(claims.get("role"))
+    except ValueError:
+        raise InvalidToken(f"Unknown role: {claims.get('role')}")
+    try:
+        principal = Principal(
+            subject=claims["sub"],
+            role=role,
+            assignment_ids=frozenset(UUID(str(value)) for value in claims.get("assignment_ids") or []),
+            student_id=UUID(str(claims["student_id"])) if claims.get("student_id") else None,
+            group_ids=frozenset(UUID(str(value)) for value in claims.get("group_ids") or []),
+            tenant_id=check_tenant_id(claims.get("tenant_id") or DEFAULT_TENANT_ID),
+        )
+    except (ValueError, TypeError) as e:
+        raise InvalidToken(f"Malformed scoping claims: {str(e)}")
+    if principal.role == Role.STUDENT and principal.student_id is None:
+        raise InvalidToken("The token of a student has no student_id")
+    return principal
+
+
+def _b64encode(data: bytes) -> str:
+    return base64.urlsafe_b64encode(data).decode("ascii").rstrip("=")
+
+
+def _b64decode(segment: str) -> bytes:
+    return base64.urlsafe_b64decode(segment + "=" * (-len(segment) % 4))
@@ -4,6 +4,7 @@ from app.config.config import get_settings
 
 # Import all models to ensure they are registered with SQLModel
 from app.domains.submissions.submissions_models import Submission
+from app.shared.tenant_migration import migrate_tenants
 
 settings = get_settings()
 
@@ -29,6 +30,9 @@ def create_db_and_tables():
     # Create all tables from scratch
     SQLModel.metadata.create_all(engine)
 
+    # Assign the data predating the tenants to the default tenant
+    migrate_tenants(engine)
+
 
 def get_session():
     """Get database session"""
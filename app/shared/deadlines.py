@@ -0,0 +1,69 @@
+import time
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Callable, Iterator, Optional, Tuple
+
+
+class StageTimeout(BaseException):
+    """
+    Raised by `check_deadline` once the deadline of a stage has passed. Like the cancellation of asyncio, it is not
+    an Exception, not to be swallowed by the handlers of the failures between the check and the stage, which
+    catches the timeouts of its own deadline.
+    """
+
+    def __init__(self, deadline: "StageDeadline"):
+        super().__init__(f"{deadline.stage} timed out after {deadline.elapsed_seconds:.1f} seconds")
+        self.deadline = deadline
+        self.stage = deadline.stage
+        self.timeout_seconds = deadline.timeout_seconds
+        self.elapsed_seconds = deadline.elapsed_seconds
+
+
+class StageDeadline:
+    """Deadline of a stage of an analysis (the tokenization of a file, the comparison of a pair), without if None"""
+
+    def __init__(self, stage: str, timeout_seconds: Optional[float], clock: Callable[[], float] = time.monotonic):
+        self.stage = stage
+        self.timeout_seconds = timeout_seconds or None
+        self._clock = clock
+        self._started = clock()
+
+    @property
+    def elapsed_seconds(self) -> float:
+        return self._clock() - self._started
+
+    @property
+    def expired(self) -> bool:
+        return self.timeout_seconds is not None and self.elapsed_seconds > self.timeout_seconds
+
+
+# Deadlines of the stages the current thread is in, outermost first, checked by the long loops of the stages
+_deadlines: ContextVar[Tuple[StageDeadline, ...]] = ContextVar("stage_deadlines", default=())
+
+
+@contextmanager
+def stage_deadline(
+    stage: str, timeout_seconds: Optional[float], clock: Callable[[], float] = time.monotonic
+) -> Iterator[StageDeadline]:
+    """
+    Run a stage within a deadline, no deadline applying if the timeout is None or 0. The deadline is cooperative,
+    like a Go context: the long loops of the stage call `check_deadline`, which raises StageTimeout once it passed.
+    """
+    deadline = StageDeadline(stage, timeout_seconds, clock)
+    token = _deadlines.set(_deadlines.get() + (deadline,))
+    try:
+        yield deadline
+    finally:
+        _deadlines.reset(token)
+
+
+def check_deadline() -> None:
+    """
+    Check the deadlines of the stages the current thread is in, from the outermost
+
+    Raises:
+        StageTimeout: If one of them has passed
+    """
+    for deadline in _deadlines.get():
+        if deadline.expired:
+            raise StageTimeout(deadline)
@@ -0,0 +1,108 @@
+import re
+from http import HTTPStatus
+from typing import Any, Dict, Iterable, Mapping, Optional
+
+# Codes of the errors whose detail names no error_type, by HTTP status
+STATUS_CODES = {
+    400: "BAD_REQUEST",
+    401: "UNAUTHORIZED",
+    403: "FORBIDDEN",
+    404: "NOT_FOUND",
+    405: "METHOD_NOT_ALLOWED",
+    409: "CONFLICT",
+    413: "PAYLOAD_TOO_LARGE",
+    415: "UNSUPPORTED_MEDIA_TYPE",
+    422: "VALIDATION_FAILED",
+    429: "RATE_LIMITED",
+    500: "INTERNAL_ERROR",
+    503: "SERVICE_UNAVAILABLE",
+}
+VALIDATION_FAILED = "VALIDATION_FAILED"
+INTERNAL_ERROR = "INTERNAL_ERROR"
+
+# Keys of a structured detail that are the code and the message of the envelope rather than its details
+ENVELOPE_KEYS = ("error_type", "message")
+
+
+def error_code(status_code: int, detail: Any = None, internal_code: Optional[str] = None) -> str:
+    """
+    Stable code of an error: the error_type of its structured detail (upload_too_large as UPLOAD_TOO_LARGE), else
+    the code of its internal type, else that of its status
+    """
+    if isinstance(detail, dict) and isinstance(detail.get("error_type"), str) and detail["error_type"]:
+        return as_code(detail["error_type"])
+    if internal_code:
+        return as_code(internal_code)
+    if status_code in STATUS_CODES:
+        return STATUS_CODES[status_code]
+    return INTERNAL_ERROR if status_code >= 500 else STATUS_CODES[400]
+
+
+def as_code(name: str) -> str:
+    """Name of an error (snake_case, camelCase or a class name) as a code: UPPER_SNAKE_CASE"""
+    return re.sub(r"(?<=[a-z0-9])(?=[A-Z])", "_", name).replace("-", "_").upper()
+
+
+def error_message(status_code: int, detail: Any = None) -> str:
+    """Message of an error for a human, from its detail, else the phrase of its status"""
+    if isinstance(detail, dict):
+        for key in ("message", "summary", "error_message"):
+            if detail.get(key):
+                return str(detail[key])
+    elif isinstance(detail, str) and detail:
+        return detail
+    try:
+        return HTTPStatus(status_code).phrase
+    except ValueError:
+        return "Error"
+
+
+def error_details(detail: Any) -> Dict[str, Any]:
+    """Details of an error: its structured detail but its code and message, the items of a list as errors"""
+    if isinstance(detail, dict):
+        return {key: value for key, value in detail.items() if key not in ENVELOPE_KEYS}
+    if isinstance(detail, list):
+        return {"errors": detail}
+    return {}
+
+
+def error_envelope(
+    status_code: int,
+    detail: Any,
+    request_id: Optional[str],
+    code: Optional[str] = None,
+    message: Optional[str] = None,
+    details: Optional[Dict[str, Any]] = None,
+) -> Dict[str, Any]:
+    """
+    Body of every error response: its stable code, a message for a human, the details and the ID of the request.
+    The detail of the former responses is kept as is for the clients reading it, until the next release.
+    """
+    return {
+        "code": code or error_code(status_code, detail),
+        "message": message or error_message(status_code, detail),
+        "details": details if details is not None else error_details(detail),
+        "request_id": request_id,
+        "detail": detail,
+    }
+
+
+def validation_error_details(errors: Iterable[Mapping[str, Any]]) -> Dict[str, Any]:
+    """Details of a request failing validation: every invalid field (body.project_uuid...), with why"""
+    return {
+        "fields": [
+            {
+                "field": ".".join(str(part) for part in error.get("loc", ())),
+                "message": error.get("msg"),
+                "type": error.get("type"),
+            }
+            for error in errors
+        ]
+    }
+
+
+def validation_error_message(details: Dict[str, Any]) -> str:
+    fields = [field["field"] for field in details["fields"]]
+    if len(fields) == 1:
+        return f"Invalid field: {fields[0]}"
+    return f"{len(fields)} invalid fields: {', '.join(fields)}"
@@ -0,0 +1,115 @@
+import logging
+from typing import Any, Dict, Optional
+
+from fastapi import FastAPI, Request
+from fastapi.exceptions import RequestValidationError
+from fastapi.responses import JSONResponse
+from starlette.exceptions import HTTPException as StarletteHTTPException
+
+from app.domains.repositories.archive_extractor import ArchiveLimitExceeded
+from app.domains.submissions.access_policy import AccessDenied, OutOfTenant
+from app.domains.submissions.analysis_worker_pool import AnalysisPoolDraining, AnalysisQueueFull
+from app.domains.submissions.chunked_upload_store import ChunkConflictError
+from app.domains.submissions.processing_lifecycle import InvalidProcessingTransition
+from app.shared.error_envelope import (
+    INTERNAL_ERROR,
+    VALIDATION_FAILED,
+    error_code,
+    error_envelope,
+    validation_error_details,
+    validation_error_message,
+)
+from app.shared.exceptions import DatabaseException
+from app.shared.request_id_middleware import REQUEST_ID_HEADER, request_id
+
+logger = logging.getLogger(__name__)
+
+# Status codes and names of the internal errors reaching the API, the first matching type of an error applying
+INTERNAL_ERRORS = (
+    (AnalysisPoolDraining, 503, "analysis_draining"),
+    (AnalysisQueueFull, 503, "analysis_queue_full"),
+    (ArchiveLimitExceeded, 422, None),
+    (OutOfTenant, 404, "not_found"),
+    (AccessDenied, 403, "access_denied"),
+    (ChunkConflictError, 409, "chunk_conflict"),
+    (InvalidProcessingTransition, 409, "invalid_processing_transition"),
+    (DatabaseException, 500, "database_error"),
+)
+
+
+def internal_code(exc: BaseException) -> Optional[str]:
+    """
+    Code of the internal error behind an HTTP error: the controllers re-raising the errors of the service layer
+    as HTTPException, the error itself or the one it was raised from
+    """
+    seen = set()
+    while exc is not None and id(exc) not in seen:
+        seen.add(id(exc))
+        for cls, _, name in INTERNAL_ERRORS:
+            if isinstance(exc, cls):
+                return getattr(exc, "code", None) or name
+        exc = exc.__cause__ or exc.__context__
+    return None
+
+
+def error_response(
+    request: Request, status_code: int, detail: Any, headers: Optional[Dict[str, str]] = None, **envelope: Any
+) -> JSONResponse:
+    """Error response in the envelope of the API, with the ID of the request"""
+    rid = request_id(request)
+    return JSONResponse(
+        status_code=status_code,
+        content=error_envelope(status_code, detail, rid, **envelope),
+        headers={**(headers or {}), REQUEST_ID_HEADER: rid},
+    )
+
+
+async def http_exception_handler(request: Request, exc: StarletteHTTPException) -> JSONResponse:
+    code = error_code(exc.status_code, exc.detail, internal_code(exc))
+    return error_response(request, exc.status_code, exc.detail, getattr(exc, "headers", None), code=code)
+
+
+async def validation_exception_handler(request: Request, exc: RequestValidationError) -> JSONResponse:
+    """Every invalid field of the request at once, not only the first"""
+    details = validation_error_details(exc.errors())
+    return error_response(
+        request,
+        422,
+        exc.errors(),
+        code=VALIDATION_FAILED,
+        message=validation_error_message(details),
+        details=details,
+    )
+
+
+async def internal_exception_handler(request: Request, exc: Exception) -> JSONResponse:
+    """Internal errors of a known type escaping the controllers, with the status code of their type"""
+    status_code, name = next((status, name) for cls, status, name in INTERNAL_ERRORS if isinstance(exc, cls))
+    if isinstance(exc, OutOfTenant):
+        # The response of a resource that does not exist, its existence in another tenant not leaking
+        return error_response(request, status_code, str(exc), code=error_code(status_code, str(exc)))
+    structured = isinstance(exc, (ArchiveLimitExceeded, AccessDenied))
+    detail: Dict[str, Any] = exc.to_dict() if structured else {"message": str(exc)}
+    detail["error_type"] = getattr(exc, "code", None) or name
+    headers = None
+    if isinstance(exc, AnalysisQueueFull):
+        detail["retry_after_seconds"] = exc.retry_after_seconds
+        headers = {"Retry-After": str(exc.retry_after_seconds)}
+    return error_response(request, status_code, detail, headers)
+
+
+async def unhandled_exception_handler(request: Request, exc: Exception) -> JSONResponse:
+    """Unexpected errors, as a 500 without their internals, logged with the ID of the request"""
+    rid = request_id(request)
+    logger.exception(f"Unhandled error of request {rid} ({request.method} {request.url.path}): {str(exc)}")
+    return error_response(request, 500, "Internal server error", code=INTERNAL_ERROR)
+
+
+def register_error_handlers(app: FastAPI) -> None:
+    """Return every error of the API in the same envelope, whatever raised it"""
+    app.add_exception_handler(StarletteHTTPException, http_exception_handler)
+    app.add_exception_handler(RequestValidationError, validation_exception_handler)
+    for cls, _, _ in INTERNAL_ERRORS:
+        if not issubclass(cls, StarletteHTTPException):
+            app.add_exception_handler(cls, internal_exception_handler)
+    app.add_exception_handler(Exception, unhandled_exception_handler)
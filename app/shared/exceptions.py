@@ -24,6 +24,20 @@ class ValidationException(HTTPException):
             super().__init__(status_code=status.HTTP_422_UNPROCESSABLE_ENTITY, detail=detail)
 
 
+class BadRequestException(HTTPException):
+    """Raised when a request asks for something that is never allowed, such as changing an immutable field"""
+
+    def __init__(self, detail: str, details: Optional[Dict[str, Any]] = None):
+        super().__init__(status_code=status.HTTP_400_BAD_REQUEST, detail=details or detail)
+
+
+class ConflictException(HTTPException):
+    """Raised when a request conflicts with an earlier one, such as a reused idempotency key"""
+
+    def __init__(self, detail: str, details: Optional[Dict[str, Any]] = None):
+        super().__init__(status_code=status.HTTP_409_CONFLICT, detail=details or detail)
+
+
 class DatabaseException(HTTPException):
     """Raised when database operation fails"""
 
@@ -0,0 +1,83 @@
+import json
+import logging
+import time
+from contextlib import contextmanager
+from contextvars import ContextVar
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterator
+
+# Fields of the logs of the current request or job: the correlation ID of the request, and the submission, job or
+# run being processed. A job of the analysis workers runs in a copy of the context it was queued in, so that its
+# logs carry the correlation ID of the request that queued it.
+_log_fields: ContextVar[Dict[str, Any]] = ContextVar("log_fields", default={})
+
+# Attributes of every log record, the others being the extra fields of a log call
+_RECORD_ATTRIBUTES = set(vars(logging.LogRecord("", 0, "", 0, "", (), None))) | {"message", "asctime", "log_fields"}
+
+
+def current_log_fields() -> Dict[str, Any]:
+    """Fields of the logs of the current context"""
+    return dict(_log_fields.get())
+
+
+@contextmanager
+def log_context(**fields: Any) -> Iterator[None]:
+    """Add fields to the logs within the block, the fields None being left out"""
+    added = {name: value for name, value in fields.items() if value is not None}
+    token = _log_fields.set({**_log_fields.get(), **added})
+    try:
+        yield
+    finally:
+        _log_fields.reset(token)
+
+
+@contextmanager
+def log_stage(logger: logging.Logger, stage: str, **fields: Any) -> Iterator[None]:
+    """Log the start and the end of a stage of the processing with its duration, or its failure"""
+    started = time.monotonic()
+    with log_context(stage=stage, **fields):
+        logger.info(f"Stage {stage} started")
+        try:
+            yield
+        except BaseException as e:
+            duration_ms = round((time.monotonic() - started) * 1000, 2)
+            logger.warning(f"Stage {stage} failed after {duration_ms}ms: {e}", extra={"duration_ms": duration_ms})
+            raise
+        duration_ms = round((time.monotonic() - started) * 1000, 2)
+        logger.info(f"Stage {stage} finished in {duration_ms}ms", extra={"duration_ms": duration_ms})
+
+
+class LogContextFilter(logging.Filter):
+    """Filter of the handlers giving each record the fields of the context it was logged in"""
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        record.log_fields = current_log_fields()
+        return True
+
+
+class JsonLogFormatter(logging.Formatter):
+    """One JSON object per record: its time, level, logger and message, the fields of its context and its extras"""
+
+    def format(self, record: logging.LogRecord) -> str:
+        entry = {
+            "timestamp": datetime.fromtimestamp(record.created, timezone.utc).isoformat(),
+            "level": record.levelname,
+            "logger": record.name,
+            "message": record.getMessage(),
+            **getattr(record, "log_fields", {}),
+            **{name: value for name, value in vars(record).items() if name not in _RECORD_ATTRIBUTES},
+        }
+        if record.exc_info:
+            entry["exception"] = self.formatException(record.exc_info)
+        return json.dumps(entry, default=str)
+
+
+class TextLogFormatter(logging.Formatter):
+    """Human-readable record, followed by the fields of its context as key=value pairs"""
+
+    def formatMessage(self, record: logging.LogRecord) -> str:
+        text = super().formatMessage(record)
+        fields = getattr(record, "log_fields", None)
+        if not fields:
+            return text
+        return f"{text} [{' '.join(f'{name}={value}' for name, value in fields.items())}]"
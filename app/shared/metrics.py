@@ -0,0 +1,184 @@
+import threading
+import time
+from contextlib import contextmanager
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+try:
+    import prometheus_client
+except ImportError:
+    prometheus_client = None
+
+from app.shared.deadlines import StageTimeout
+
+# Buckets of the durations, in seconds: a file tokenized, a pair compared, a storage operation
+TOKENIZATION_BUCKETS = (0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0)
+COMPARISON_BUCKETS = (0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0, 30.0, 60.0, 120.0, 300.0, 600.0)
+STORAGE_BUCKETS = (0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0)
+
+# Label of the requests matching no route, not to label the metrics with the paths of the clients
+UNMATCHED_ROUTE = "unmatched"
+
+# Observation of a metric recorded away from the registry (by a comparison process): kind, labels and value
+Observation = Tuple[str, Tuple[str, ...], float]
+
+
+class PipelineMetrics:
+    """
+    Prometheus metrics of the pipeline, recorded by the service layer so that every surface (HTTP or gRPC) is
+    measured alike: the requests by route template, the load of the analysis queue, the tokenization by language,
+    the comparisons by outcome, the lookups of the token cache and the operations of the object storage. The labels
+    are bounded sets (routes, languages, outcomes...), never the IDs of submissions or runs.
+
+    The comparison processes have no registry read by the scrapes: what they record within `collect()` is kept in
+    a list instead, returned with their result and replayed by the process serving the metrics. Without
+    prometheus_client, nothing is recorded.
+    """
+
+    def __init__(self):
+        self.enabled = prometheus_client is not None
+        self._local = threading.local()
+        self._lock = threading.Lock()
+        self._cache_lookups = {"hit": 0, "miss": 0}
+        self._analysis_pool = None
+        if not self.enabled:
+            return
+
+        self.registry = prometheus_client.CollectorRegistry()
+        self.http_requests = prometheus_client.Counter(
+            "pamp_http_requests_total",
+            "HTTP requests by method, route template and status code",
+            ["method", "route", "status"],
+            registry=self.registry,
+        )
+        self.http_request_duration = prometheus_client.Histogram(
+            "pamp_http_request_duration_seconds",
+            "Duration of the HTTP requests by method and route template",
+            ["method", "route"],
+            registry=self.registry,
+        )
+        self.tokenization_duration = prometheus_client.Histogram(
+            "pamp_tokenization_duration_seconds",
+            "Duration of the tokenization of a file (or chunk of a streamed file) by language",
+            ["language"],
+            buckets=TOKENIZATION_BUCKETS,
+            registry=self.registry,
+        )
+        self.comparison_duration = prometheus_client.Histogram(
+            "pamp_comparison_duration_seconds",
+            "Duration of the comparison of a pair of submissions by outcome",
+            ["outcome"],
+            buckets=COMPARISON_BUCKETS,
+            registry=self.registry,
+        )
+        self.token_cache_lookups = prometheus_client.Counter(
+            "pamp_token_cache_lookups_total",
+            "Lookups of the token cache of the compared files by result (hit or miss)",
+            ["result"],
+            registry=self.registry,
+        )
+        self.storage_operation_duration = prometheus_client.Histogram(
+            "pamp_storage_operation_duration_seconds",
+            "Duration of the operations of the object storage by backend, operation and outcome",
+            ["backend", "operation", "outcome"],
+            buckets=STORAGE_BUCKETS,
+            registry=self.registry,
+        )
+        prometheus_client.Gauge(
+            "pamp_token_cache_hit_ratio",
+            "Share of the lookups of the token cache served from it since the start",
+            registry=self.registry,
+        ).set_function(self.token_cache_hit_ratio)
+        prometheus_client.Gauge(
+            "pamp_analysis_queue_depth", "Analysis jobs waiting in the queue", registry=self.registry
+        ).set_function(lambda: self._analysis_stat("queue_depth"))
+        prometheus_client.Gauge(
+            "pamp_analysis_jobs_in_flight", "Analysis jobs being run by the workers", registry=self.registry
+        ).set_function(lambda: self._analysis_stat("busy_workers"))
+
+    def track_analysis_pool(self, pool: Any) -> None:
+        """Read the load of the analysis queue from the given worker pool at each scrape"""
+        self._analysis_pool = pool
+
+    def observe_http_request(self, method: str, route: str, status_code: int, seconds: float) -> None:
+        self._record("http_request", (method, route, str(status_code)), seconds)
+
+    def observe_tokenization(self, language: Optional[str], seconds: float) -> None:
+        self._record("tokenization", (language or "unknown",), seconds)
+
+    def observe_storage_operation(self, backend: str, operation: str, outcome: str, seconds: float) -> None:
+        self._record("storage_operation", (backend, operation, outcome), seconds)
+
+    def record_token_cache_lookup(self, hit: bool) -> None:
+        self._record("token_cache_lookup", ("hit" if hit else "miss",), 1)
+
+    @contextmanager
+    def time_comparison(self) -> Iterator[None]:
+        """Time the comparison of a pair, completed unless it raises (timed out on a stage timeout, else failed)"""
+        started = time.monotonic()
+        outcome = "failed"
+        try:
+            yield
+            outcome = "completed"
+        except StageTimeout:
+            outcome = "timed_out"
+            raise
+        finally:
+            self._record("comparison", (outcome,), time.monotonic() - started)
+
+    def token_cache_hit_ratio(self) -> float:
+        with self._lock:
+            total = self._cache_lookups["hit"] + self._cache_lookups["miss"]
+            return self._cache_lookups["hit"] / total if total else 0.0
+
+    @contextmanager
+    def collect(self) -> Iterator[List[Observation]]:
+        """Keep what the current thread records in a list, to be replayed by another process"""
+        observations: List[Observation] = []
+        self._local.observations = observations
+        try:
+            yield observations
+        finally:
+            self._local.observations = None
+
+    def replay(self, observations: Optional[List[Observation]]) -> None:
+        """Record the observations collected by another process"""
+        for kind, labels, value in observations or []:
+            self._apply(kind, tuple(labels), value)
+
+    def render(self) -> Tuple[bytes, str]:
+        """Body and content type of a scrape, in the Prometheus text format"""
+        return prometheus_client.generate_latest(self.registry), prometheus_client.CONTENT_TYPE_LATEST
+
+    def _record(self, kind: str, labels: Tuple[str, ...], value: float) -> None:
+        observations = getattr(self._local, "observations", None)
+        if observations is not None:
+            observations.append((kind, labels, value))
+        else:
+            self._apply(kind, labels, value)
+
+    def _apply(self, kind: str, labels: Tuple[str, ...], value: float) -> None:
+        if kind == "token_cache_lookup":
+            with self._lock:
+                self._cache_lookups[labels[0]] += int(value)
+        if not self.enabled:
+            return
+        if kind == "http_request":
+            method, route, status = labels
+            self.http_requests.labels(method, route, status).inc()
+            self.http_request_duration.labels(method, route).observe(value)
+        elif kind == "tokenization":
+            self.tokenization_duration.labels(*labels).observe(value)
+        elif kind == "comparison":
+            self.comparison_duration.labels(*labels).observe(value)
+        elif kind == "token_cache_lookup":
+            self.token_cache_lookups.labels(*labels).inc(value)
+        elif kind == "storage_operation":
+            self.storage_operation_duration.labels(*labels).observe(value)
+
+    def _analysis_stat(self, name: str) -> float:
+        stats: Dict[str, Any] = self._analysis_pool.stats() if self._analysis_pool is not None else {}
+        return stats.get(name, 0)
+
+
+# Metrics of the process, its registry being the one scraped from /metrics
+pipeline_metrics = PipelineMetrics()
@@ -0,0 +1,35 @@
+import time
+
+from fastapi import Request
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.routing import Match
+
+from app.shared.metrics import UNMATCHED_ROUTE, PipelineMetrics, pipeline_metrics
+
+
+def route_template(request: Request) -> str:
+    """Path template of the route of a request (/submissions/{submission_id}), its IDs left out of the labels"""
+    for route in request.app.routes:
+        match, _ = route.matches(request.scope)
+        if match == Match.FULL:
+            return getattr(route, "path", UNMATCHED_ROUTE)
+    return UNMATCHED_ROUTE
+
+
+class MetricsMiddleware(BaseHTTPMiddleware):
+    """Count and time the HTTP requests by method, route template and status code, the failed ones as 500"""
+
+    def __init__(self, app, metrics: PipelineMetrics = pipeline_metrics):
+        super().__init__(app)
+        self.metrics = metrics
+
+    async def dispatch(self, request: Request, call_next):
+        route = route_template(request)
+        started = time.monotonic()
+        status_code = 500
+        try:
+            response = await call_next(request)
+            status_code = response.status_code
+            return response
+        finally:
+            self.metrics.observe_http_request(request.method, route, status_code, time.monotonic() - started)
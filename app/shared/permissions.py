@@ -0,0 +1,81 @@
+import secrets
+from typing import Optional
+
+from fastapi import Header, HTTPException, status
+
+from app.config.config import get_settings
+from app.shared.authorization import InvalidToken, Principal, TokenVerifier, bearer_token
+
+
+def verify_admin_key(admin_key: Optional[str]) -> None:
+    """
+    Check the key of an administrator against the configured one
+
+    Raises:
+        HTTPException: 403 if no key is configured, or if the key is missing or wrong
+    """
+    configured = get_settings().admin_api_key
+    if configured is None or not configured.get_secret_value():
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Administration is disabled")
+    if not admin_key or not secrets.compare_digest(
+        admin_key.encode("utf-8"), configured.get_secret_value().encode("utf-8")
+    ):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Invalid administrator key")
+
+
+def require_admin(x_admin_key: Optional[str] = Header(None, description="Key of an administrator")) -> None:
+    """Dependency of the endpoints reserved to the administrators"""
+    verify_admin_key(x_admin_key)
+
+
+def authenticate(authorization: Optional[str]) -> Optional[Principal]:
+    """
+    Caller of the Authorization header of a request or call, None while authentication is explicitly disabled
+
+    Raises:
+        InvalidToken: If authentication is neither enabled nor disabled, or if the bearer token is missing or invalid
+    """
+    settings = get_settings()
+    if not settings.auth_enabled:
+        if settings.auth_disabled:
+            return None
+        raise InvalidToken("Authentication is not configured: set AUTH_ENABLED, or AUTH_DISABLED for local development")
+    token = bearer_token(authorization)
+    if token is None:
+        raise InvalidToken("A bearer token is required")
+    if settings.auth_jwt_secret is None or not settings.auth_jwt_secret.get_secret_value():
+        raise InvalidToken("Authentication is enabled without a token secret")
+    verifier = TokenVerifier(
+        settings.auth_jwt_secret.get_secret_value(),
+        settings.auth_jwt_issuer,
+        settings.auth_jwt_audience,
+        settings.auth_clock_skew_seconds,
+    )
+    return verifier.verify(token)
+
+
+def authentication_warning() -> Optional[str]:
+    """Warning logged at startup when the callers are not authenticated, None while authentication is enabled"""
+    settings = get_settings()
+    if settings.auth_enabled:
+        return None
+    if settings.auth_disabled:
+        return "Authentication is disabled: every caller is allowed everything, for local development only"
+    return "Authentication is neither enabled nor disabled: every request is refused"
+
+
+def get_principal(
+    authorization: Optional[str] = Header(None, description="Bearer token of the caller"),
+) -> Optional[Principal]:
+    """
+    Dependency of the caller of a request, its access being checked by the service layer
+
+    Raises:
+        HTTPException: 401 if authentication is enabled and the bearer token is missing or invalid
+    """
+    try:
+        return authenticate(authorization)
+    except InvalidToken as e:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED, detail=str(e), headers={"WWW-Authenticate": "Bearer"}
+        )
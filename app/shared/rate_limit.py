@@ -0,0 +1,177 @@
+import hashlib
+import math
+import threading
+import time
+from abc import ABC, abstractmethod
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, Mapping, Optional
+
+# Categories of the requests, limited separately: the reads and cheap writes, and the expensive operations
+STANDARD = "standard"
+EXPENSIVE = "expensive"
+
+# Buckets of the in-memory store between two removals of those idle long enough to be full again
+PRUNE_INTERVAL_TAKES = 10_000
+
+
+@dataclass(frozen=True)
+class RateLimit:
+    """Token bucket: `burst` requests at once, refilled at `per_minute` requests a minute"""
+
+    per_minute: float
+    burst: int
+
+    def __post_init__(self):
+        if self.per_minute <= 0 or self.burst < 1:
+            raise ValueError("A rate limit needs a positive rate and a burst of at least one request")
+
+    @property
+    def refill_per_second(self) -> float:
+        return self.per_minute / 60.0
+
+
+@dataclass(frozen=True)
+class RateLimitDecision:
+    """Whether a request is let through, and the state of its bucket returned in the headers of the response"""
+
+    allowed: bool
+    limit: int
+    remaining: int
+    reset_seconds: int  # Until the bucket is full again
+    retry_after_seconds: int  # Until the next request is let through, 0 if allowed
+
+    @classmethod
+    def of(cls, limit: RateLimit, tokens: float, allowed: bool) -> "RateLimitDecision":
+        """Decision from the tokens left in the bucket once the request is counted"""
+        rate = limit.refill_per_second
+        return cls(
+            allowed=allowed,
+            limit=limit.burst,
+            remaining=max(0, math.floor(tokens)),
+            reset_seconds=math.ceil(max(0.0, limit.burst - tokens) / rate),
+            retry_after_seconds=0 if allowed else max(1, math.ceil((1 - tokens) / rate)),
+        )
+
+    def headers(self) -> Dict[str, str]:
+        headers = {
+            "X-RateLimit-Limit": str(self.limit),
+            "X-RateLimit-Remaining": str(self.remaining),
+            "X-RateLimit-Reset": str(self.reset_seconds),
+        }
+        if not self.allowed:
+            headers["Retry-After"] = str(self.retry_after_seconds)
+        return headers
+
+
+def refill(limit: RateLimit, tokens: float, updated: float, now: float) -> float:
+    """Tokens of a bucket last updated at `updated`, refilled up to its burst"""
+    return min(float(limit.burst), tokens + max(0.0, now - updated) * limit.refill_per_second)
+
+
+class RateLimitStore(ABC):
+    """
+    Storage of the token buckets. Each service instance has its own in-memory buckets by default; behind a load
+    balancer a store shared by the instances (Redis) keeps a single bucket per client.
+    """
+
+    @abstractmethod
+    def take(self, key: str, limit: RateLimit, now: float) -> RateLimitDecision:
+        """Refill the bucket of the key and take a token from it if any, atomically"""
+        pass
+
+
+class InMemoryRateLimitStore(RateLimitStore):
+    """Buckets of the instance, those idle long enough to be full again being dropped from time to time"""
+
+    def __init__(self):
+        self._buckets: Dict[str, tuple] = {}  # Key: (tokens, updated, full at)
+        self._takes = 0
+        self._lock = threading.Lock()
+
+    def take(self, key: str, limit: RateLimit, now: float) -> RateLimitDecision:
+        with self._lock:
+            self._takes += 1
+            if self._takes % PRUNE_INTERVAL_TAKES == 0:
+                self._buckets = {k: bucket for k, bucket in self._buckets.items() if bucket[2] > now}
+
+            tokens, updated, _ = self._buckets.get(key, (float(limit.burst), now, now))
+            tokens = refill(limit, tokens, updated, now)
+            allowed = tokens >= 1
+            if allowed:
+                tokens -= 1
+            self._buckets[key] = (tokens, now, now + (limit.burst - tokens) / limit.refill_per_second)
+            return RateLimitDecision.of(limit, tokens, allowed)
+
+
+class RedisRateLimitStore(RateLimitStore):
+    """
+    Buckets shared by the service instances in Redis, refilled and taken from by a script run atomically by the
+    server, and expiring once full again. The clocks of the instances are expected to be synchronized.
+    """
+
+    SCRIPT = """
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+"""
+
+    def __init__(self, client: Any, prefix: str = "rate-limit:"):
+        self.prefix = prefix
+        self._script = client.register_script(self.SCRIPT)
+
+    @classmethod
+    def from_url(cls, url: str) -> "RedisRateLimitStore":
+        import redis
+
+        return cls(redis.Redis.from_url(url))
+
+    def take(self, key: str, limit: RateLimit, now: float) -> RateLimitDecision:
+        allowed, tokens = self._script(keys=[self.prefix + key], args=[limit.burst, limit.refill_per_second, now])
+        return RateLimitDecision.of(limit, float(tokens), bool(int(allowed)))
+
+
+class RateLimiter:
+    """
+    Rate limits of the requests of the API clients, per category of request. A client sending a configured
+    credential has its own buckets, with the limits configured for it if any; the others are limited by IP address
+    (a credential not configured is not trusted, or a client could change it to get a new bucket).
+    """
+
+    def __init__(
+        self,
+        store: RateLimitStore,
+        limits: Mapping[str, RateLimit],
+        client_limits: Optional[Mapping[str, Mapping[str, RateLimit]]] = None,
+        clock: Callable[[], float] = time.time,
+    ):
+        self.store = store
+        self.limits = dict(limits)
+        self.client_limits = dict(client_limits or {})
+        self._clock = clock
+
+    @staticmethod
+    def credential_id(credential: str) -> str:
+        """Identifier of a credential in the bucket keys, not the credential itself"""
+        return hashlib.sha256(credential.encode("utf-8")).hexdigest()[:16]
+
+    def check(self, category: str, credential: Optional[str], ip_address: Optional[str]) -> RateLimitDecision:
+        """Count a request of the category, from a client credential or else an IP address"""
+        limit = self.limits[category]
+        if credential and credential in self.client_limits:
+            limit = self.client_limits[credential].get(category, limit)
+            identity = f"client:{self.credential_id(credential)}"
+        else:
+            identity = f"ip:{ip_address or 'unknown'}"
+        return self.store.take(f"{category}:{identity}", limit, self._clock())
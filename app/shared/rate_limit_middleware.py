@@ -0,0 +1,106 @@
+import logging
+import re
+from typing import Optional
+
+from fastapi import Request
+from fastapi.responses import JSONResponse
+from starlette.concurrency import run_in_threadpool
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from app.shared.error_envelope import error_envelope
+from app.shared.rate_limit import EXPENSIVE, STANDARD, RateLimiter
+from app.shared.request_id_middleware import REQUEST_ID_HEADER, request_id
+
+logger = logging.getLogger(__name__)
+
+# Header of the credential of an API client, limited on its own instead of by IP address if configured
+CLIENT_KEY_HEADER = "X-Client-Key"
+
+# Requests not limited: the health checks and probes of the load balancer, the scrapes of the metrics and the
+# documentation
+EXEMPT_PATHS = re.compile(r"^/(health(/.*)?|healthz|readyz|metrics|swagger-ui|redoc|openapi\.json)$")
+
+# Expensive operations, by method and path: uploads, detection runs, their rescores and verifications, reports, rare
+# tokens of runs, token stream exports, code searches, dry-run analyses
+EXPENSIVE_OPERATIONS = [
+    ("POST", re.compile(r"^/submissions/?$")),
+    ("POST", re.compile(r"^/submissions/(upload|bulk-upload|git|search)$")),
+    ("POST", re.compile(r"^/submissions/uploads/[^/]+/finalize$")),
+    ("POST", re.compile(r"^/submissions/[^/]+/external-comparison$")),
+    ("POST", re.compile(r"^/submissions/project/[^/]+/step/[^/]+/detection-runs$")),
+    ("POST", re.compile(r"^/submissions/detection-runs/[^/]+/(rescore|verify)$")),
+    ("GET", re.compile(r"^/submissions/similarities/[^/]+/report$")),
+    ("GET", re.compile(r"^/submissions/[^/]+/tokens$")),
+    ("GET", re.compile(r"^/submissions/detection-runs/[^/]+/(report|export|rare-tokens)$")),
+    ("POST", re.compile(r"^/detection/analyze$")),
+]
+
+
+def request_category(method: str, path: str) -> Optional[str]:
+    """Category of the limit of a request, None if not limited"""
+    if EXEMPT_PATHS.match(path):
+        return None
+    if any(method == expensive_method and pattern.match(path) for expensive_method, pattern in EXPENSIVE_OPERATIONS):
+        return EXPENSIVE
+    return STANDARD
+
+
+def client_ip(request: Request, trust_forwarded_for: bool) -> Optional[str]:
+    """IP address of the client, the first of X-Forwarded-For behind a trusted reverse proxy"""
+    forwarded_for = request.headers.get("X-Forwarded-For") if trust_forwarded_for else None
+    if forwarded_for:
+        return forwarded_for.split(",")[0].strip()
+    return request.client.host if request.client else None
+
+
+class RateLimitMiddleware(BaseHTTPMiddleware):
+    """
+    Rate limiting of the requests per API client: 429 with Retry-After past the limit of their category, the
+    X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset headers being returned on every limited request.
+    When the store of the buckets is not reachable (Redis down), the requests are let through rather than refused.
+    """
+
+    def __init__(self, app, limiter: Optional[RateLimiter] = None, trust_forwarded_for: bool = True):
+        super().__init__(app)
+        self._limiter = limiter
+        self.trust_forwarded_for = trust_forwarded_for
+
+    @property
+    def limiter(self) -> RateLimiter:
+        if self._limiter is None:
+            from app.shared.services import get_rate_limiter
+
+            self._limiter = get_rate_limiter()
+        return self._limiter
+
+    async def dispatch(self, request: Request, call_next):
+        category = request_category(request.method, request.url.path)
+        if category is None:
+            return await call_next(request)
+
+        try:
+            decision = await run_in_threadpool(
+                self.limiter.check,
+                category,
+                request.headers.get(CLIENT_KEY_HEADER),
+                client_ip(request, self.trust_forwarded_for),
+            )
+        except Exception as e:
+            logger.error(f"Rate limit not checked, the request being let through: {str(e)}")
+            return await call_next(request)
+
+        if not decision.allowed:
+            detail = {
+                "error_type": "rate_limited",
+                "message": f"Too many {category} requests, retry in {decision.retry_after_seconds} seconds",
+                "retry_after_seconds": decision.retry_after_seconds,
+            }
+            return JSONResponse(
+                status_code=429,
+                content=error_envelope(429, detail, request_id(request)),
+                headers={**decision.headers(), REQUEST_ID_HEADER: request_id(request)},
+            )
+
+        response = await call_next(request)
+        response.headers.update(decision.headers())
+        return response
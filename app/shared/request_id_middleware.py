@@ -0,0 +1,34 @@
+import re
+import uuid
+
+from fastapi import Request
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from app.shared.log_context import log_context
+
+REQUEST_ID_HEADER = "X-Request-ID"
+
+# Request IDs accepted from the clients (or from a gateway), the others being replaced
+REQUEST_ID_PATTERN = re.compile(r"^[A-Za-z0-9._:-]{1,128}$")
+
+
+def request_id(request: Request) -> str:
+    """ID of a request, given by the middleware, else a new one kept for the rest of the request"""
+    if not getattr(request.state, "request_id", None):
+        request.state.request_id = uuid.uuid4().hex
+    return request.state.request_id
+
+
+class RequestIdMiddleware(BaseHTTPMiddleware):
+    """
+    Give each request an ID (the one sent in X-Request-ID if valid), echoed in its response and error body, and
+    carried as the correlation ID by its logs and those of the analysis jobs it queues
+    """
+
+    async def dispatch(self, request: Request, call_next):
+        sent = request.headers.get(REQUEST_ID_HEADER)
+        request.state.request_id = sent if sent and REQUEST_ID_PATTERN.match(sent) else uuid.uuid4().hex
+        with log_context(correlation_id=request.state.request_id):
+            response = await call_next(request)
+        response.headers[REQUEST_ID_HEADER] = request.state.request_id
+        return response
@@ -8,6 +8,7 @@ once and reused across requests.
 
 import logging
 import threading
+from pathlib import Path
 from typing import Optional
 
 logger = logging.getLogger(__name__)
@@ -19,6 +20,15 @@ _services_lock = threading.Lock()
 _tokenization_service: Optional["TokenizationService"] = None
 _similarity_service: Optional["SimilarityDetectionService"] = None
 _submission_fetcher: Optional["SubmissionFetcher"] = None
+_analysis_worker_pool: Optional["AnalysisWorkerPool"] = None
+_run_progress_tracker: Optional["RunProgressTracker"] = None
+_run_results_feed: Optional["RunResultsFeed"] = None
+_pair_comparison_pool: Optional["PairComparisonPool"] = None
+_rate_limiter: Optional["RateLimiter"] = None
+_object_storage: Optional["ObjectStorage"] = None
+_malware_scanner: Optional["MalwareScanner"] = None
+_webhook_notifier: Optional["WebhookNotifier"] = None
+_readiness_probe: Optional["ReadinessProbe"] = None
 
 
 def get_tokenization_service() -> "TokenizationService":
@@ -36,6 +46,14 @@ def get_tokenization_service() -> "TokenizationService":
                 from app.domains.tokenization.tokenization_service import TokenizationService
 
                 _tokenization_service = TokenizationService()
+
+                # Custom languages defined in the configuration
+                from app.config.config import get_settings
+
+                custom_languages_file = get_settings().custom_languages_file
+                if custom_languages_file:
+                    languages = _tokenization_service.load_custom_languages(Path(custom_languages_file))
+                    logger.info(f"Registered {len(languages)} custom languages from {custom_languages_file}")
                 logger.info("TokenizationService singleton initialized successfully")
 
     return _tokenization_service
@@ -81,6 +99,269 @@ def get_submission_fetcher() -> "SubmissionFetcher":
     return _submission_fetcher
 
 
+def get_analysis_worker_pool() -> "AnalysisWorkerPool":
+    """
+    Get singleton instance of AnalysisWorkerPool, sized by the configuration.
+    Thread-safe lazy initialization.
+    """
+    global _analysis_worker_pool
+
+    if _analysis_worker_pool is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _analysis_worker_pool is None:
+                from app.config.config import get_settings
+                from app.domains.submissions.analysis_worker_pool import AnalysisWorkerPool
+
+                settings = get_settings()
+                _analysis_worker_pool = AnalysisWorkerPool(
+                    worker_count=settings.analysis_worker_count,
+                    queue_capacity=settings.analysis_queue_capacity,
+                    policy=settings.analysis_backpressure,
+                    block_seconds=settings.analysis_queue_block_seconds,
+                    retry_after_seconds=settings.analysis_retry_after_seconds,
+                    aging_seconds=settings.analysis_priority_aging_seconds,
+                )
+                from app.shared.metrics import pipeline_metrics
+
+                pipeline_metrics.track_analysis_pool(_analysis_worker_pool)
+                logger.info(
+                    f"AnalysisWorkerPool singleton initialized: {settings.analysis_worker_count} workers, "
+                    f"{settings.analysis_queue_capacity} queued jobs"
+                )
+
+    return _analysis_worker_pool
+
+
+def get_run_progress_tracker() -> "RunProgressTracker":
+    """
+    Get singleton instance of RunProgressTracker, shared by the workers comparing the pairs of the detection runs.
+    Thread-safe lazy initialization.
+    """
+    global _run_progress_tracker
+
+    if _run_progress_tracker is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _run_progress_tracker is None:
+                from app.config.config import get_settings
+                from app.domains.submissions.run_progress import RunProgressTracker
+
+                _run_progress_tracker = RunProgressTracker(
+                    window_seconds=get_settings().detection_run_throughput_window_seconds
+                )
+
+    return _run_progress_tracker
+
+
+def get_run_results_feed() -> "RunResultsFeed":
+    """
+    Get singleton instance of RunResultsFeed, waking the streams of the results of the detection runs as their
+    pairs are written. Thread-safe lazy initialization.
+    """
+    global _run_results_feed
+
+    if _run_results_feed is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _run_results_feed is None:
+                from app.domains.submissions.run_results_feed import RunResultsFeed
+
+                _run_results_feed = RunResultsFeed()
+
+    return _run_results_feed
+
+
+def get_pair_comparison_pool() -> "PairComparisonPool":
+    """
+    Get singleton instance of PairComparisonPool, the processes comparing the pairs of the detection runs, sized by
+    the configuration. Thread-safe lazy initialization, the processes being started by the first run.
+    """
+    global _pair_comparison_pool
+
+    if _pair_comparison_pool is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _pair_comparison_pool is None:
+                from app.config.config import get_settings
+                from app.domains.submissions.pair_comparison_pool import PairComparisonPool
+
+                settings = get_settings()
+                _pair_comparison_pool = PairComparisonPool(
+                    process_count=settings.comparison_process_count,
+                    write_batch_size=settings.comparison_write_batch_size,
+                )
+                logger.info(
+                    f"PairComparisonPool singleton initialized: {_pair_comparison_pool.process_count} processes"
+                )
+
+    return _pair_comparison_pool
+
+
+def get_rate_limiter() -> "RateLimiter":
+    """
+    Get singleton instance of RateLimiter, with the limits of the configuration and its store of the buckets (in
+    memory, or in Redis shared by the instances). Thread-safe lazy initialization.
+    """
+    global _rate_limiter
+
+    if _rate_limiter is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _rate_limiter is None:
+                from app.config.config import get_settings
+                from app.shared.rate_limit import (
+                    EXPENSIVE,
+                    STANDARD,
+                    InMemoryRateLimitStore,
+                    RateLimit,
+                    RateLimiter,
+                    RedisRateLimitStore,
+                )
+
+                settings = get_settings()
+                limits = {
+                    STANDARD: RateLimit(settings.rate_limit_standard_per_minute, settings.rate_limit_standard_burst),
+                    EXPENSIVE: RateLimit(settings.rate_limit_expensive_per_minute, settings.rate_limit_expensive_burst),
+                }
+                client_limits = {
+                    credential: {
+                        category: RateLimit(
+                            overrides.get(f"{category}_per_minute", limit.per_minute),
+                            int(overrides.get(f"{category}_burst", limit.burst)),
+                        )
+                        for category, limit in limits.items()
+                    }
+                    for credential, overrides in settings.rate_limit_clients.items()
+                }
+                if settings.rate_limit_store == "redis":
+                    store = RedisRateLimitStore.from_url(settings.rate_limit_redis_url)
+                else:
+                    store = InMemoryRateLimitStore()
+                _rate_limiter = RateLimiter(store, limits, client_limits)
+                logger.info(
+                    f"RateLimiter singleton initialized: {settings.rate_limit_store} store, "
+                    f"{len(client_limits)} configured clients"
+                )
+
+    return _rate_limiter
+
+
+def get_object_storage() -> "ObjectStorage":
+    """
+    Get singleton instance of the ObjectStorage of the configured backend, local directory, filesystem volume or S3
+    bucket, keeping the files of the service. Thread-safe lazy initialization.
+    """
+    global _object_storage
+
+    if _object_storage is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _object_storage is None:
+                from app.config.config import get_settings
+                from app.domains.repositories.object_storage import (
+                    FilesystemObjectStorage,
+                    LocalObjectStorage,
+                    S3ObjectStorage,
+                )
+
+                settings = get_settings()
+                if settings.object_storage_backend == "s3":
+                    _object_storage = S3ObjectStorage.from_settings(settings)
+                elif settings.object_storage_backend == "filesystem":
+                    _object_storage = FilesystemObjectStorage.from_settings(settings)
+                elif settings.object_storage_backend == "local":
+                    _object_storage = LocalObjectStorage.from_settings(settings)
+                else:
+                    raise ValueError(f"Unknown object storage backend: {settings.object_storage_backend}")
+                logger.info(f"ObjectStorage singleton initialized: {settings.object_storage_backend} backend")
+
+    return _object_storage
+
+
+def get_malware_scanner() -> "MalwareScanner":
+    """
+    Get singleton instance of the MalwareScanner of the configured backend, the clamd daemon or none, scanning the
+    files of the uploads. Thread-safe lazy initialization.
+    """
+    global _malware_scanner
+
+    if _malware_scanner is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _malware_scanner is None:
+                from app.config.config import get_settings
+                from app.domains.submissions.malware_scanner import ClamAVScanner, NoOpMalwareScanner
+
+                settings = get_settings()
+                if settings.malware_scanner == "clamav":
+                    _malware_scanner = ClamAVScanner.from_settings(settings)
+                elif settings.malware_scanner == "none":
+                    _malware_scanner = NoOpMalwareScanner()
+                else:
+                    raise ValueError(f"Unknown malware scanner: {settings.malware_scanner}")
+                logger.info(f"MalwareScanner singleton initialized: {settings.malware_scanner} backend")
+
+    return _malware_scanner
+
+
+def get_webhook_notifier() -> "WebhookNotifier":
+    """
+    Get singleton instance of WebhookNotifier, delivering the events to the webhooks from threads of its own with
+    the timeout and retries of the configuration. Thread-safe lazy initialization.
+    """
+    global _webhook_notifier
+
+    if _webhook_notifier is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _webhook_notifier is None:
+                from app.config.config import get_settings
+                from app.domains.repositories.fetchers.url_source_fetcher import UrlSourceFetcher
+                from app.domains.submissions.webhook_delivery import (
+                    WebhookDispatcher,
+                    WebhookRetryPolicy,
+                    WebhookSender,
+                )
+                from app.domains.submissions.webhook_notifier import WebhookNotifier
+
+                settings = get_settings()
+                _webhook_notifier = WebhookNotifier(
+                    WebhookDispatcher(worker_count=settings.webhook_worker_count),
+                    WebhookSender(
+                        timeout_seconds=settings.webhook_timeout_seconds,
+                        host_policy=UrlSourceFetcher().default_host_policy,
+                    ),
+                    WebhookRetryPolicy(
+                        max_attempts=settings.webhook_max_attempts,
+                        base_delay_seconds=settings.webhook_retry_base_delay_seconds,
+                        max_delay_seconds=settings.webhook_retry_max_delay_seconds,
+                    ),
+                )
+                logger.info("WebhookNotifier singleton initialized")
+
+    return _webhook_notifier
+
+
+def get_readiness_probe() -> "ReadinessProbe":
+    """
+    Get singleton instance of ReadinessProbe, checking the dependencies of the service for the load balancer.
+    Thread-safe lazy initialization.
+    """
+    global _readiness_probe
+
+    if _readiness_probe is None:
+        with _services_lock:
+            # Double-check locking pattern
+            if _readiness_probe is None:
+                from app.config.config import get_settings
+                from app.domains.health.probes import DEPENDENCY_CHECKS, ReadinessProbe
+
+                _readiness_probe = ReadinessProbe(DEPENDENCY_CHECKS, get_settings().readiness_cache_seconds)
+
+    return _readiness_probe
+
+
 def get_visualization_service(tokenization_service: Optional["TokenizationService"] = None) -> "VisualizationService":
     """
     Get instance of VisualizationService.
@@ -103,7 +384,10 @@ def init_services():
     logger.info("Warming up singleton services...")
     get_tokenization_service()
     get_similarity_service()
+    get_object_storage()
     get_submission_fetcher()
+    get_analysis_worker_pool()
+    get_webhook_notifier()
     logger.info("All singleton services warmed up successfully")
 
 
@@ -111,13 +395,36 @@ def cleanup_services():
     """
     Cleanup services during application shutdown.
     """
-    global _tokenization_service, _similarity_service, _submission_fetcher
+    global _tokenization_service, _similarity_service, _submission_fetcher, _analysis_worker_pool, _run_progress_tracker
+    global _pair_comparison_pool, _rate_limiter, _object_storage, _webhook_notifier, _run_results_feed, _malware_scanner
 
     logger.info("Cleaning up singleton services...")
 
+    # Drain the analysis workers, the submissions they could not finish being left pending retry
+    if _analysis_worker_pool is not None:
+        from app.config.config import get_settings
+
+        _analysis_worker_pool.drain(get_settings().analysis_drain_timeout_seconds)
+
+    # Then stop the comparison processes, no run being left to queue pairs on them
+    if _pair_comparison_pool is not None:
+        _pair_comparison_pool.shutdown()
+
+    # Then stop the webhook deliveries, those waiting for a retry being resumed at the next start
+    if _webhook_notifier is not None:
+        _webhook_notifier.dispatcher.shutdown()
+
     # Reset singleton references
     _tokenization_service = None
     _similarity_service = None
     _submission_fetcher = None
+    _analysis_worker_pool = None
+    _run_progress_tracker = None
+    _run_results_feed = None
+    _pair_comparison_pool = None
+    _rate_limiter = None
+    _object_storage = None
+    _malware_scanner = None
+    _webhook_notifier = None
 
     logger.info("Singleton services cleaned up")
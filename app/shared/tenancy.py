@@ -0,0 +1,33 @@
+import re
+from typing import Optional
+
+# Tenant of the data predating the tenants, and of the callers whose token names none
+DEFAULT_TENANT_ID = "default"
+
+# Identifiers of the tenants: lowercase letters, digits and dashes, as in the storage keys they prefix
+TENANT_ID_PATTERN = re.compile(r"^[a-z0-9](?:[a-z0-9-]{0,62}[a-z0-9])?$")
+
+# Directory of the storage keys of the tenants, never the first segment of a key of the default tenant
+TENANTS_DIRECTORY = "tenants"
+
+
+def check_tenant_id(tenant_id: str) -> str:
+    """
+    Identifier of a tenant, safe in the storage and cache keys
+
+    Raises:
+        ValueError: If the identifier is not made of lowercase letters, digits and dashes
+    """
+    if not isinstance(tenant_id, str) or not TENANT_ID_PATTERN.match(tenant_id):
+        raise ValueError(f"Invalid tenant: {tenant_id!r}, lowercase letters, digits and dashes expected")
+    return tenant_id
+
+
+def tenant_prefix(tenant_id: Optional[str]) -> str:
+    """
+    Prefix of the storage and cache keys of a tenant: none for the default tenant, whose keys predate the tenants,
+    and the directory of the tenant for the others, so that the keys of two tenants never collide
+    """
+    if tenant_id is None or tenant_id == DEFAULT_TENANT_ID:
+        return ""
+    return f"{TENANTS_DIRECTORY}/{check_tenant_id(tenant_id)}/"
@@ -0,0 +1,92 @@
+"""
+Migration of the database predating the tenants: the tenant columns are added, the existing rows and project
+steps being assigned to the default tenant. Run at startup after the tables are created, and idempotent.
+
+Usage: python -m app.shared.tenant_migration
+"""
+
+import logging
+import sys
+
+from sqlalchemy.engine import Engine
+from sqlmodel import text
+
+from app.shared.tenancy import DEFAULT_TENANT_ID
+
+logger = logging.getLogger(__name__)
+
+# Tables whose rows belong to a tenant
+TENANT_TABLES = (
+    "submission",
+    "submission_detection_run",
+    "submission_analysis_profile",
+    "submission_corpus",
+    "submission_access_denial",
+    "submission_webhook",
+)
+
+
+def migrate_tenants(engine: Engine) -> int:
+    """
+    Add the tenant column to the tables predating the tenants, the existing rows getting the default tenant, make
+    the names of the analysis profiles unique per tenant, widen the keys of the token cache for the tenant prefix,
+    and register the project steps of the existing rows to the default tenant, returning the number of steps
+    registered
+    """
+    with engine.begin() as connection:
+        for table in TENANT_TABLES:
+            connection.execute(
+                text(
+                    f"ALTER TABLE {table} ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL "
+                    f"DEFAULT '{DEFAULT_TENANT_ID}'"
+                )
+            )
+            connection.execute(text(f"CREATE INDEX IF NOT EXISTS ix_{table}_tenant_id ON {table} (tenant_id)"))
+
+        # The names were unique across the service, they are unique per tenant
+        connection.execute(text("DROP INDEX IF EXISTS ix_submission_analysis_profile_name"))
+        connection.execute(
+            text("CREATE INDEX ix_submission_analysis_profile_name ON submission_analysis_profile (name)")
+        )
+        connection.execute(
+            text(
+                "CREATE UNIQUE INDEX IF NOT EXISTS uq_submission_analysis_profile_tenant_name "
+                "ON submission_analysis_profile (tenant_id, name)"
+            )
+        )
+
+        connection.execute(text("ALTER TABLE submission_token_cache ALTER COLUMN tokenization_key TYPE VARCHAR(160)"))
+
+        # Every project step with data belongs to the default tenant, never claimed by another one
+        step_tables = connection.execute(
+            text(
+                "SELECT table_name FROM information_schema.columns WHERE table_schema = current_schema() "
+                "AND column_name = 'project_step_uuid' AND table_name <> 'submission_assignment'"
+            )
+        ).scalars()
+        registered = 0
+        for table in sorted(step_tables):
+            registered += connection.execute(
+                text(
+                    "INSERT INTO submission_assignment (project_step_uuid, tenant_id, created_at) "
+                    f"SELECT DISTINCT project_step_uuid, :tenant_id, NOW() FROM {table} "
+                    "WHERE project_step_uuid IS NOT NULL ON CONFLICT (project_step_uuid) DO NOTHING"
+                ),
+                {"tenant_id": DEFAULT_TENANT_ID},
+            ).rowcount
+    if registered:
+        logger.info(f"Registered {registered} project steps to the {DEFAULT_TENANT_ID} tenant")
+    return registered
+
+
+def main() -> int:
+    """Migrate the configured database"""
+    from app.shared.database import engine
+
+    logging.basicConfig(level=logging.INFO, format="%(asctime)s - %(name)s - %(levelname)s - %(message)s")
+    print(f"Registered {migrate_tenants(engine)} project steps to the {DEFAULT_TENANT_ID} tenant")
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())
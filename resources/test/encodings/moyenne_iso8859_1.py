@@ -0,0 +1,4 @@
+# Calcule la moyenne des lves (rsultat arrondi)
+def moyenne(notes):
+    """Moyenne des notes, 0 si aucune"""
+    return round(sum(notes) / len(notes), 2) if notes else 0
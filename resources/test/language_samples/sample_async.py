@@ -0,0 +1,55 @@
+"""Async worker pool, the asyncio counterpart of the goroutine example in sample.go."""
+
+import asyncio
+import contextlib
+
+
+async def worker(worker_id: int, jobs: asyncio.Queue, results: asyncio.Queue, stop: asyncio.Event) -> None:
+    while not stop.is_set():
+        try:
+            job = await asyncio.wait_for(jobs.get(), timeout=0.5)
+        except asyncio.TimeoutError:
+            continue
+        if (doubled := job * 2) > 0:
+            print(f"Worker {worker_id} processing job {job}")
+            await asyncio.sleep(0.1)
+            await results.put(doubled)
+        jobs.task_done()
+
+
+def collect(results: asyncio.Queue) -> list:
+    collected = []
+    while (item := results.get_nowait() if not results.empty() else None) is not None:
+        collected.append(item)
+    return collected
+
+
+async def main() -> None:
+    jobs: asyncio.Queue = asyncio.Queue(maxsize=10)
+    results: asyncio.Queue = asyncio.Queue(maxsize=10)
+    stop = asyncio.Event()
+
+    async with asyncio.timeout(2):
+        tasks = [asyncio.create_task(worker(i, jobs, results, stop)) for i in range(1, 4)]
+        for i in range(1, 6):
+            await jobs.put(i)
+        await jobs.join()
+        stop.set()
+        await asyncio.gather(*tasks)
+
+    async for value in numbers(3):
+        print(f"Generated: {value}")
+
+    with contextlib.suppress(asyncio.QueueEmpty):
+        for result in collect(results):
+            print(f"Result: {result}")
+
+
+async def numbers(limit: int):
+    for n in range(limit):
+        yield n
+        await asyncio.sleep(0)
+
+
+if __name__ == "__main__":
+    asyncio.run(main())
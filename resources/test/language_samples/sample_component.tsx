@@ -0,0 +1,17 @@
+import React, { useState } from "react";
+
+interface CounterProps {
+  label: string;
+  initial?: number;
+}
+
+export function Counter<T extends CounterProps>({ label, initial = 0 }: T) {
+  const [count, setCount] = useState<number>(initial);
+
+  return (
+    <div className="counter">
+      <span>{`${label}: ${count}`}</span>
+      <button onClick={() => setCount(count + 1)}>+</button>
+    </div>
+  );
+}
@@ -0,0 +1,68 @@
+use std::collections::HashMap;
+use std::sync::mpsc;
+use std::sync::{Arc, Mutex};
+use std::thread;
+
+// Struct with derived traits
+#[derive(Debug, Clone, PartialEq)]
+struct Person<'a> {
+    name: &'a str,
+    age: u32,
+}
+
+// Trait, the counterpart of the Greeter interface in sample.go
+trait Greeter {
+    fn greet(&self) -> String;
+}
+
+impl<'a> Greeter for Person<'a> {
+    fn greet(&self) -> String {
+        format!("Hello, {}! You are {} years old.", self.name, self.age)
+    }
+}
+
+// Generic function
+fn map<T, U, F: Fn(&T) -> U>(items: &[T], f: F) -> Vec<U> {
+    items.iter().map(f).collect()
+}
+
+fn classify(age: u32) -> &'static str {
+    match age {
+        0..=12 => "child",
+        13..=17 => "teenager",
+        _ => "adult",
+    }
+}
+
+fn main() {
+    let person = Person { name: "Alice", age: 30 };
+    println!("{}", person.greet());
+
+    let doubled = map(&vec![1, 2, 3], |n| n * 2);
+    println!("{:?} {}", doubled, classify(person.age));
+
+    let template = r#"{"name": "Alice", "note": "uses \"quotes\" and # signs"}"#;
+    println!("{}", template);
+
+    // Worker threads sending results through a channel
+    let (tx, rx) = mpsc::channel();
+    let counter = Arc::new(Mutex::new(HashMap::new()));
+    let mut handles = vec![];
+
+    for id in 1..=3 {
+        let tx = tx.clone();
+        let counter = Arc::clone(&counter);
+        handles.push(thread::spawn(move || {
+            let mut map = counter.lock().unwrap();
+            *map.entry(id).or_insert(0) += id * 2;
+            tx.send(id * 2).unwrap();
+        }));
+    }
+    drop(tx);
+
+    for handle in handles {
+        handle.join().unwrap();
+    }
+    let total: u32 = rx.iter().sum();
+    assert_eq!(total, 12, "unexpected total");
+}
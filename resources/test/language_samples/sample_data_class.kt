@@ -0,0 +1,39 @@
+// Kotlin sample: data classes, string templates, nullable types,
+// extension functions and trailing lambdas
+
+package sample.people
+
+data class Person(val name: String, val age: Int, val email: String? = null)
+
+class Registry {
+    private val people = mutableListOf<Person>()
+
+    fun add(person: Person) {
+        people.add(person)
+    }
+
+    fun findByEmail(email: String): Person? {
+        return people.firstOrNull { it.email == email }
+    }
+
+    fun adults(): List<Person> = people.filter { it.age >= 18 }
+}
+
+fun Person.describe(): String {
+    return "$name is ${age} years old"
+}
+
+fun String?.orUnknown(): String = this ?: "unknown"
+
+fun main() {
+    val registry = Registry()
+    registry.add(Person("Alice", 30, "alice@example.com"))
+    registry.add(Person("Bob", 17))
+
+    registry.adults().forEach { person ->
+        println(person.describe())
+    }
+
+    val found: Person? = registry.findByEmail("bob@example.com")
+    println("Found: ${found?.name.orUnknown()}")
+}
@@ -0,0 +1,46 @@
+"""Sample Python module exercising decorators and f-strings."""
+
+import functools
+from dataclasses import dataclass, field
+
+import pytest
+
+
+def log_calls(func):
+    @functools.wraps(func)
+    def wrapper(*args, **kwargs):
+        print(f"calling {func.__name__!r} with {len(args)} args")
+        return func(*args, **kwargs)
+
+    return wrapper
+
+
+@dataclass
+class Grade:
+    student: str
+    score: float
+    tags: list = field(default_factory=list)
+
+    @property
+    def label(self) -> str:
+        return f"{self.student:<12}|{self.score:>6.2f}"
+
+    @staticmethod
+    @log_calls
+    def passing(score: float, threshold: float = 10.0) -> bool:
+        return score >= threshold
+
+
+@pytest.fixture(scope="module")
+def grades():
+    return [Grade("alice", 14.5), Grade("bob", 9.0)]
+
+
+def report(grades, width=10):
+    lines = [f"{g.student!r:>{width}} -> {'pass' if Grade.passing(g.score) else 'fail'}" for g in grades]
+    summary = f"""
+Report for {len(grades)} students
+Best: {max(grades, key=lambda g: g.score).student}
+Nested: {f"{sum(g.score for g in grades) / len(grades):.1f}"}
+"""
+    return "\n".join(lines) + summary
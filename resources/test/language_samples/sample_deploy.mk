@@ -0,0 +1,21 @@
+# Deploy Makefile: targets, prerequisites and recipe lines
+
+APP_NAME = pamp
+BUILD_DIR = build
+
+.PHONY: all test deploy clean
+
+all: test $(BUILD_DIR)/app
+
+$(BUILD_DIR)/app: main.go config.go
+	mkdir -p $(BUILD_DIR)
+	go build -o $@ .
+
+test:
+	go test ./...
+
+deploy: all
+	./sample_deploy.sh $(APP_NAME)
+
+clean:
+	rm -rf $(BUILD_DIR)
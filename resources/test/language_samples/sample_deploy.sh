@@ -0,0 +1,26 @@
+#!/usr/bin/env bash
+# Deploy script: variables, command substitution, pipes, quoting and heredocs
+set -euo pipefail
+
+APP_NAME="pamp"
+RELEASE=$(date +%Y%m%d%H%M%S)
+TARGET_DIR="/srv/${APP_NAME}/releases/${RELEASE}"
+
+echo "Deploying $APP_NAME to $TARGET_DIR"
+echo 'Literal: $APP_NAME is not expanded'
+
+mkdir -p "$TARGET_DIR"
+git ls-files | grep -v '^tests/' | xargs -I{} cp --parents {} "$TARGET_DIR"
+
+cat > "$TARGET_DIR/.env" <<EOF
+APP_NAME=$APP_NAME
+RELEASE=${RELEASE}
+BUILT_BY=$(whoami)
+EOF
+
+cat > "$TARGET_DIR/README" <<'EOF'
+Generated file, $APP_NAME and $(whoami) are kept as is
+EOF
+
+ln -sfn "$TARGET_DIR" "/srv/${APP_NAME}/current"
+echo "Release $RELEASE deployed"
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// Server composed of an embedded handler, a pointer to its logger and a named mutex
+type Server struct {
+	http.Handler
+	*log.Logger
+	mu    sync.Mutex
+	stats struct {
+		hits   int
+		errors struct{ count int }
+	}
+}
+
+// ReadCloser embedding two interfaces next to an own method
+type ReadCloser interface {
+	io.Reader
+	io.Closer
+	Reset() error
+}
+
+// Serve writes to anything with a WriteString method, given as an anonymous interface
+func Serve(w interface{ WriteString(s string) (int, error) }, s *Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.hits++
+	w.WriteString("ok")
+}
+
+func main() {
+	config := struct {
+		Addr string
+		Port int
+	}{Addr: "localhost", Port: 8080}
+	log.Println(config.Addr, config.Port)
+}
@@ -0,0 +1,49 @@
+// TypeScript counterpart of sample.go: interface, generic function, enum and decorators
+
+enum Status {
+  Pending = "pending",
+  Done = "done",
+}
+
+interface Greeter {
+  greet(): string;
+}
+
+type Pair<K, V> = { key: K; value: V };
+
+function logged(target: unknown, propertyKey: string, descriptor: PropertyDescriptor): void {
+  const original = descriptor.value;
+  descriptor.value = function (...args: unknown[]) {
+    console.log(`calling ${propertyKey}`);
+    return original.apply(this, args);
+  };
+}
+
+class Person implements Greeter {
+  constructor(public name: string, public age: number, public status: Status = Status.Pending) {}
+
+  @logged
+  greet(): string {
+    return `Hello, ${this.name}! You are ${this.age} years old.`;
+  }
+}
+
+// Generic function
+function map<T, U>(items: T[], fn: (item: T) => U): U[] {
+  const result: U[] = [];
+  for (const item of items) {
+    result.push(fn(item));
+  }
+  return result;
+}
+
+function firstKey<K extends string, V>(pairs: Pair<K, V>[]): K | undefined {
+  return pairs.length > 0 ? pairs[0].key : undefined;
+}
+
+const raw: unknown = JSON.parse('{"name": "Alice", "age": 30}');
+const data = raw as { name: string; age: number };
+const person = new Person(data.name, data.age);
+const doubled = map<number, number>([1, 2, 3], (n) => n * 2);
+
+console.log(person.greet(), doubled, firstKey([{ key: "a", value: 1 }]));
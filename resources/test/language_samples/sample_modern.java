@@ -0,0 +1,47 @@
+import java.util.List;
+
+// Java 14+ features: records, switch expressions and text blocks
+public class SampleModern {
+
+    record Point(int x, int y) {
+        Point {
+            if (x < 0 || y < 0) {
+                throw new IllegalArgumentException("negative coordinate");
+            }
+        }
+
+        int distance() {
+            return Math.abs(x) + Math.abs(y);
+        }
+    }
+
+    enum Shape { SQUARE, CIRCLE, TRIANGLE }
+
+    static int sides(Shape shape) {
+        return switch (shape) {
+            case SQUARE -> 4;
+            case TRIANGLE -> 3;
+            default -> {
+                int none = 0;
+                yield none;
+            }
+        };
+    }
+
+    static String query() {
+        return """
+            SELECT id, name
+            FROM points
+            WHERE x > 0 -- "quoted" text and { braces } stay in the literal
+            """;
+    }
+
+    public static void main(String[] args) {
+        List<Point> points = List.of(new Point(1, 2), new Point(3, 4));
+        for (Point point : points) {
+            System.out.println(point + " -> " + point.distance());
+        }
+        System.out.println(sides(Shape.CIRCLE));
+        System.out.println(query());
+    }
+}
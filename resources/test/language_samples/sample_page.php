@@ -0,0 +1,34 @@
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Student list</title>
+</head>
+<body>
+<?php
+// Load the students
+$students = [
+    ['name' => 'Alice', 'grade' => 17],
+    ['name' => 'Bob', 'grade' => 12],
+];
+# Passing grade
+$threshold = 10;
+?>
+    <h1>Students</h1>
+    <ul>
+<?php foreach ($students as $student): ?>
+        <li><?= htmlspecialchars($student['name']) ?></li>
+<?php endforeach; ?>
+    </ul>
+<?php
+/* Summary rendered as a heredoc */
+$count = count($students);
+$summary = <<<HTML
+    <p>{$count} students, threshold $threshold</p>
+    HTML;
+$query = <<<'SQL'
+    SELECT name, grade FROM students WHERE grade >= :threshold
+    SQL;
+echo $summary;
+?>
+</body>
+</html>
@@ -0,0 +1,44 @@
+// Swift sample: protocol conformance, optionals, closures,
+// string interpolation, guard/defer and multiline strings
+
+import Foundation
+
+protocol Greeter {
+    func greet() -> String
+}
+
+struct Person: Greeter {
+    let name: String
+    let age: Int
+    var email: String?
+
+    func greet() -> String {
+        return "Hello, \(name)! You are \(age) years old."
+    }
+}
+
+func contact(_ person: Person?) -> String {
+    guard let person = person, let email = person.email else {
+        return "no contact"
+    }
+    defer {
+        print("contact resolved")
+    }
+    return "\(person.name) <\(email)>"
+}
+
+let people = [
+    Person(name: "Alice", age: 30, email: "alice@example.com"),
+    Person(name: "Bob", age: 17, email: nil),
+]
+
+let adults = people.filter { $0.age >= 18 }
+let names = people.map({ person in person.name })
+let first = adults.first!
+
+let report = """
+    Adults: \(adults.count)
+    First: \(first.greet())
+    """
+print(report)
+print(contact(first))
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Multi-line raw string
+const usage = `Usage: grader [options] <submission>
+
+Options:
+  -v    verbose output
+  -o    output file
+`
+
+// Raw string with backslashes, no escape sequences are interpreted
+var pathPattern = regexp.MustCompile(`^C:\\Users\\[a-z]+\\go\\src\\.*\.go$`)
+
+// Raw string that looks like Go code
+const template = `package generated
+
+func Generated(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+`
+
+func main() {
+	fmt.Println(usage)
+	fmt.Println(pathPattern.MatchString(`C:\Users\alice\go\src\main.go`))
+	fmt.Println(len(template))
+}
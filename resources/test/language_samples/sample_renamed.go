@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Struct with JSON tags
+type Human struct {
+	Label string `json:"name"`
+	Years int    `json:"age"`
+}
+
+// Interface
+type Speaker interface {
+	Speak() string
+}
+
+// Method on struct
+func (h Human) Speak() string {
+	return fmt.Sprintf("Hello, %s! You are %d years old.", h.Label, h.Years)
+}
+
+// Generic function (Go 1.18+)
+func Transform[A, B any](items []A, apply func(A) B) []B {
+	out := make([]B, len(items))
+	for k, val := range items {
+		out[k] = apply(val)
+	}
+	return out
+}
+
+// Worker function for goroutines
+func consumer(scope context.Context, num int, tasks <-chan int, outputs chan<- int, group *sync.WaitGroup) {
+	defer group.Done()
+	for {
+		select {
+		case task, alive := <-tasks:
+			if !alive {
+				return
+			}
+			fmt.Printf("Worker %d processing job %d\n", num, task)
+			time.Sleep(100 * time.Millisecond)
+			outputs <- task * 2
+		case <-scope.Done():
+			fmt.Printf("Worker %d cancelled\n", num)
+			return
+		}
+	}
+}
+
+func main() {
+	fmt.Println("Go Programming Example")
+
+	// Create a person
+	human := Human{Label: "Alice", Years: 30}
+	fmt.Println(human.Speak())
+
+	// JSON marshaling/unmarshaling
+	encoded, failure := json.Marshal(human)
+	if failure != nil {
+		log.Fatal(failure)
+	}
+	fmt.Printf("JSON: %s\n", encoded)
+
+	var restored Human
+	if failure := json.Unmarshal(encoded, &restored); failure != nil {
+		log.Fatal(failure)
+	}
+	fmt.Printf("Decoded: %+v\n", restored)
+
+	// Generic function usage
+	values := []int{1, 2, 3, 4, 5}
+	twice := Transform(values, func(x int) int { return x * 2 })
+	fmt.Printf("Original: %v, Doubled: %v\n", values, twice)
+
+	// Goroutines and channels
+	scope, stop := context.WithTimeout(context.Background(), 2*time.Second)
+	defer stop()
+
+	tasks := make(chan int, 10)
+	outputs := make(chan int, 10)
+	var group sync.WaitGroup
+
+	// Start workers
+	for k := 1; k <= 3; k++ {
+		group.Add(1)
+		go consumer(scope, k, tasks, outputs, &group)
+	}
+
+	// Send jobs
+	go func() {
+		for k := 1; k <= 5; k++ {
+			tasks <- k
+		}
+		close(tasks)
+	}()
+
+	// Collect results
+	go func() {
+		group.Wait()
+		close(outputs)
+	}()
+
+	// Print results
+	for out := range outputs {
+		fmt.Printf("Result: %d\n", out)
+	}
+
+	fmt.Println("Program completed")
+} 
\ No newline at end of file
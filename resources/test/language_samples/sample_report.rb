@@ -0,0 +1,38 @@
+# Ruby sample: symbols, interpolation, blocks, heredocs and %w[] literals
+
+class Report
+  COLUMNS = %w[name grade passed]
+
+  def initialize(students, threshold: 10)
+    @students = students
+    @threshold = threshold
+  end
+
+  def query
+    <<~SQL
+      SELECT name, grade
+      FROM students
+      WHERE grade >= #{@threshold}
+      ORDER BY grade DESC
+    SQL
+  end
+
+  def passed
+    @students.select { |student| student[:grade] >= @threshold }
+  end
+
+  def summary
+    passed.map do |student|
+      "#{student[:name]} passed with #{student[:grade]}"
+    end
+  end
+
+  def status(student)
+    student[:grade] >= @threshold ? :passed : :failed
+  end
+end
+
+report = Report.new([{ name: "Alice", grade: 17 }, { name: "Bob", grade: 8 }])
+report.summary.each { |line| puts line }
+puts report.query
+puts 'Columns: ' + Report::COLUMNS.join(", ")
@@ -0,0 +1,26 @@
+-- Library schema and queries (PostgreSQL)
+
+CREATE TABLE authors (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE "Books" (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(200) NOT NULL,
+    author_id INTEGER REFERENCES authors(id),
+    published INTEGER
+);
+
+/* Books published after 2000, with their author */
+SELECT b.title, a.name
+FROM "Books" b
+JOIN authors a ON a.id = b.author_id
+WHERE b.published > 2000 AND a.name <> 'Anonymous'
+ORDER BY b.title;
+
+CREATE FUNCTION book_count(author INTEGER) RETURNS INTEGER AS $$
+    SELECT count(*)
+    FROM "Books"
+    WHERE author_id = author;
+$$ LANGUAGE sql;
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Struct with multi-key tags
+type Student struct {
+	ID        int       `json:"id" xml:"id" db:"student_id"`
+	Name      string    `json:"name" xml:"name"`
+	Age       int       `json:"age" xml:"age"`
+	Email     string    `json:"email,omitempty" xml:"email,attr" validate:"required,email"`
+	CreatedAt time.Time `json:"created_at" xml:"-"`
+	Notes     string    // field without tag
+}
+
+// Embedded struct with an interpreted string tag
+type Enrollment struct {
+	Student
+	Course string "json:\"course\""
+	Grade  float64 `json:"grade" xml:"grade"`
+}
@@ -0,0 +1,24 @@
+// Template literals, tagged templates and arrow functions
+
+const sql = (strings, ...values) => strings.reduce((query, part, i) => `${query}${part}${i < values.length ? "$" + (i + 1) : ""}`, "");
+
+const users = [
+  { id: 1, name: "Alice", roles: ["admin", "dev"] },
+  { id: 2, name: "Bob", roles: [] },
+];
+
+const describe = (user) => `${user.name} has ${user.roles.length ? `roles: ${user.roles.map((r) => `<${r}>`).join(", ")}` : "no roles"}`;
+
+const findById = (id) => sql`select * from users where id = ${id} and active = ${true}`;
+
+const summary = users
+  .filter((u) => u.id > 0)
+  .map((u) => ({ label: describe(u), query: findById(u.id) }));
+
+function render(items) {
+  return `<ul>
+${items.map((item) => `  <li title="${item.query}">${item.label}</li>`).join("\n")}
+</ul>`;
+}
+
+console.log(render(summary));
@@ -0,0 +1,21 @@
+#pragma once
+
+#include <functional>
+#include <utility>
+#include <vector>
+
+namespace grading {
+
+/* Generic container of scored items */
+template <typename T, typename Score = int>
+class Ranking {
+public:
+    void add(const T& item, Score score) { items_.push_back({item, score}); }
+
+    std::vector<std::pair<T, Score>> top(std::size_t count) const;
+
+private:
+    std::vector<std::pair<T, Score>> items_;
+};
+
+}  // namespace grading
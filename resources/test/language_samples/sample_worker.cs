@@ -0,0 +1,75 @@
+using System;
+using System.Collections.Generic;
+using System.Linq;
+using System.Threading;
+using System.Threading.Tasks;
+using System.Threading.Channels;
+
+namespace SampleWorker
+{
+    public class Job
+    {
+        public int Id { get; set; }
+        public int Value { get; init; }
+        public string Label => $"job-{Id}";
+    }
+
+    public class JobsController
+    {
+        private readonly string logPath = @"C:\logs\worker.log";
+
+        [HttpGet("{id}")]
+        [Obsolete]
+        public string Describe(int id)
+        {
+            return $"Job {id} logged to {logPath}";
+        }
+    }
+
+    public static class Program
+    {
+        // Worker reading jobs from a channel, the analogue of the goroutine worker in sample.go
+        static async Task Worker(int id, ChannelReader<Job> jobs, ChannelWriter<int> results, CancellationToken token)
+        {
+            await foreach (var job in jobs.ReadAllAsync(token))
+            {
+                Console.WriteLine($"Worker {id} processing job {job.Id}");
+                await Task.Delay(10, token);
+                await results.WriteAsync(job.Value * 2, token);
+            }
+        }
+
+        public static async Task Main(string[] args)
+        {
+            var jobs = Channel.CreateBounded<Job>(10);
+            var results = Channel.CreateBounded<int>(10);
+            using var cts = new CancellationTokenSource(TimeSpan.FromSeconds(5));
+
+            var workers = Enumerable.Range(1, 3)
+                .Select(id => Worker(id, jobs.Reader, results.Writer, cts.Token))
+                .ToList();
+
+            for (var i = 1; i <= 5; i++)
+            {
+                await jobs.Writer.WriteAsync(new Job { Id = i, Value = i }, cts.Token);
+            }
+            jobs.Writer.Complete();
+
+            await Task.WhenAll(workers);
+            results.Writer.Complete();
+
+            var collected = new List<int>();
+            await foreach (var result in results.Reader.ReadAllAsync())
+            {
+                collected.Add(result);
+            }
+
+            var large = from r in collected
+                        where r > 4
+                        orderby r descending
+                        select r;
+
+            Console.WriteLine($"Results: {string.Join(", ", large)}");
+        }
+    }
+}
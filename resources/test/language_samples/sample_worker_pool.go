@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Job to be processed by the pool
+type Job struct {
+	ID    int
+	Value int
+}
+
+// Worker summing the multiples of its id up to the value of each job
+func worker(id int, jobs chan Job, results chan int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range jobs {
+		if job.Value < 0 {
+			continue
+		}
+		total := 0
+		for i := 0; i < job.Value; i++ {
+			total += i * id
+		}
+		fmt.Println("worker", id, "finished job", job.ID)
+		results <- total
+	}
+}
+
+func main() {
+	jobs := make(chan Job, 10)
+	results := make(chan int, 10)
+	var wg sync.WaitGroup
+
+	for id := 1; id <= 3; id++ {
+		wg.Add(1)
+		go worker(id, jobs, results, &wg)
+	}
+
+	for n := 0; n < 5; n++ {
+		jobs <- Job{ID: n, Value: n * 10}
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	sum := 0
+	for result := range results {
+		sum += result
+	}
+	fmt.Println("total", sum)
+}
@@ -0,0 +1,47 @@
+"""Worker pool transliterated from sample_worker_pool.go, statement for statement."""
+
+import queue
+import threading
+
+
+class Job:
+    def __init__(self, id, value):
+        self.id = id
+        self.value = value
+
+
+def worker(id, jobs, results, wg):
+    for job in iter(jobs.get, None):
+        if job.value < 0:
+            continue
+        total = 0
+        for i in range(job.value):
+            total += i * id
+        print("worker", id, "finished job", job.id)
+        results.put(total)
+    wg.release()
+
+
+def main():
+    jobs = queue.Queue(10)
+    results = queue.Queue(10)
+    wg = threading.Semaphore(0)
+
+    for id in range(1, 4):
+        threading.Thread(target=worker, args=(id, jobs, results, wg)).start()
+
+    for n in range(5):
+        jobs.put(Job(n, n * 10))
+    for _ in range(3):
+        jobs.put(None)
+    for _ in range(3):
+        wg.acquire()
+
+    sum = 0
+    for result in list(results.queue):
+        sum += result
+    print("total", sum)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,81 @@
+"""
+Tests for AllowedSnippetFilter
+"""
+
+import unittest
+
+from app.domains.detection.allowed_snippet_filter import AllowedSnippetFilter
+
+
+class TestAllowedSnippetFilter(unittest.TestCase):
+    """Unit tests for the exclusion of the fragments of a pair lying within allowed snippets in both files."""
+
+    def setUp(self):
+        self.snippet_filter = AllowedSnippetFilter(kgram_size=4)
+        self.fingerprints = self.snippet_filter.fingerprint(self._statements(['read', 'parse']))
+
+    def _statements(self, names, first_row=0, file='main.py'):
+        """One assignment per line, `name = name + <code of its letter>`"""
+        tokens = []
+        for row, name in enumerate(names, start=first_row):
+            code = str(ord(name[0]))
+            tokens += [
+                {'type': 'assignment', 'text': f'{name} = {name} + {code}', 'start': row, 'end': row},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row},
+                {'type': 'binary_operator', 'text': f'{name} + {code}', 'start': row, 'end': row},
+                {'type': 'integer', 'text': code, 'start': row, 'end': row},
+            ]
+        return [{**token, 'file': file} for token in tokens]
+
+    @staticmethod
+    def _position(start_line, end_line):
+        return {'file': 'main.py', 'start_line': start_line, 'start_column': 0, 'end_line': end_line, 'end_column': 0}
+
+    def _fragment(self, left, right):
+        """Fragment of the lines of both files, given as (start, end)"""
+        return {'left': self._position(*left), 'right': self._position(*right), 'tokens': 8, 'similarity': 1.0}
+
+    def test_regions(self):
+        """Test that the runs matching a snippet are located, by file."""
+        tokens = self._statements(['own']) + self._statements(['read', 'parse'], first_row=1)
+        self.assertEqual(
+            self.snippet_filter.regions(tokens, self.fingerprints),
+            [{'file': 'main.py', 'start_line': 1, 'start_column': None, 'end_line': 2, 'end_column': None}],
+        )
+
+    def test_fragment_sanctioned_in_both_files(self):
+        """Test that a fragment within a snippet in both files is excluded, with its tokens."""
+        tokens1 = self._statements(['read', 'parse']) + self._statements(['own1', 'own2'], first_row=2)
+        tokens2 = self._statements(['other']) + self._statements(['read', 'parse'], first_row=1)
+        fragments = [self._fragment((0, 1), (1, 2)), self._fragment((2, 3), (0, 0))]
+
+        overlap = self.snippet_filter.sanction(tokens1, tokens2, fragments, self.fingerprints)
+
+        self.assertEqual(overlap.fragments, fragments[:1])
+        self.assertEqual((overlap.excluded_token_count1, overlap.excluded_token_count2), (8, 8))
+        self.assertEqual(overlap.tokens1, tokens1[8:])
+        self.assertEqual(overlap.tokens2, tokens2[:4])
+
+    def test_fragment_sanctioned_in_one_file_only(self):
+        """Test that a fragment matching a snippet in one of the files only is still compared."""
+        tokens1 = self._statements(['read', 'parse'])
+        # The second file has the code of the snippet changed, the fragment matching the first file only
+        tokens2 = self._statements(['read']) + self._statements(['parse'], first_row=1)
+        tokens2[4]['text'] = 'parse = parse + 1'
+
+        overlap = self.snippet_filter.sanction(tokens1, tokens2, [self._fragment((0, 1), (0, 1))], self.fingerprints)
+
+        self.assertEqual(overlap.fragments, [])
+        self.assertEqual(overlap.tokens1, tokens1)
+        self.assertEqual(overlap.tokens2, tokens2)
+
+    def test_no_snippets(self):
+        """Test that nothing is excluded when the step has no allowed snippet."""
+        tokens = self._statements(['read', 'parse'])
+        overlap = self.snippet_filter.sanction(tokens, tokens, [self._fragment((0, 1), (0, 1))], [])
+        self.assertEqual(overlap.fragments, [])
+        self.assertEqual(overlap.tokens1, tokens)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,86 @@
+"""
+Tests for BaselineFilter
+"""
+
+import unittest
+
+from app.domains.detection.baseline_filter import BaselineFilter
+
+
+class TestBaselineFilter(unittest.TestCase):
+    """Unit tests for the subtraction of starter code from the compared token streams."""
+
+    def _statements(self, names, first_row=0):
+        """One assignment per line, `name = name + <code of its letter>`"""
+        tokens = []
+        for row, name in enumerate(names, start=first_row):
+            code = str(ord(name[0]))
+            tokens += [
+                {'type': 'assignment', 'text': f'{name} = {name} + {code}', 'start': row, 'end': row},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row},
+                {'type': 'binary_operator', 'text': f'{name} + {code}', 'start': row, 'end': row},
+                {'type': 'integer', 'text': code, 'start': row, 'end': row}
+            ]
+        return tokens
+
+    def test_starter_code_subtracted(self):
+        """Test that the starter code kept as is is removed, and the student code kept."""
+        baseline_filter = BaselineFilter(kgram_size=4)
+        starter = self._statements(['a', 'b', 'c', 'd'])
+        fingerprints = baseline_filter.fingerprint(starter)
+        submission = starter + self._statements(['own1', 'own2'], first_row=4)
+
+        subtraction = baseline_filter.subtract(submission, fingerprints)
+
+        self.assertEqual(subtraction.excluded_token_count, len(starter))
+        self.assertEqual(subtraction.tokens, submission[len(starter):])
+        self.assertEqual(subtraction.regions, [{'start': 0, 'end': 3, 'tokens': len(starter)}])
+
+    def test_partial_overlap(self):
+        """Test that only the still matching fragments of modified starter code are removed."""
+        baseline_filter = BaselineFilter(kgram_size=4)
+        fingerprints = baseline_filter.fingerprint(self._statements(['a', 'b', 'c', 'd', 'e', 'f']))
+        # The student replaced the middle statements
+        submission = self._statements(['a', 'b', 'x', 'y', 'e', 'f'])
+
+        subtraction = baseline_filter.subtract(submission, fingerprints)
+
+        self.assertEqual([r['start'] for r in subtraction.regions], [0, 4])
+        self.assertEqual(subtraction.excluded_token_count, 16)
+        self.assertEqual({t['text'] for t in subtraction.tokens if t['type'] == 'identifier'}, {'x', 'y'})
+
+    def test_whitespace_insensitive_and_stable(self):
+        """Test that fingerprints ignore whitespace changes and are stable, as they are stored."""
+        baseline_filter = BaselineFilter(kgram_size=2)
+        tokens = [
+            {'type': 'call', 'text': 'print(a,  b)', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': 'print', 'start': 0, 'end': 0}
+        ]
+        reformatted = [{**tokens[0], 'text': 'print(a,\n    b)'}, tokens[1]]
+
+        self.assertEqual(baseline_filter.fingerprint(tokens), baseline_filter.fingerprint(reformatted))
+        self.assertEqual(baseline_filter.fingerprint(tokens), BaselineFilter(kgram_size=2).fingerprint(tokens))
+        self.assertEqual(len(baseline_filter.fingerprint(tokens)[0]), 16)
+
+    def test_no_baseline(self):
+        """Test that the tokens are kept when the step has no baseline."""
+        tokens = self._statements(['a', 'b', 'c', 'd'])
+
+        subtraction = BaselineFilter().subtract(tokens, [])
+
+        self.assertEqual(subtraction.tokens, tokens)
+        self.assertEqual(subtraction.regions, [])
+
+    def test_excluded_files(self):
+        """Test that the files removed entirely as starter code are reported."""
+        baseline_filter = BaselineFilter(kgram_size=4)
+        starter = [{**token, 'file': 'starter.py'} for token in self._statements(['a', 'b', 'c', 'd'])]
+        own = [{**token, 'file': 'main.py'} for token in self._statements(['own1', 'own2'], first_row=4)]
+
+        subtraction = baseline_filter.subtract(starter + own, baseline_filter.fingerprint(starter))
+
+        self.assertEqual(BaselineFilter.excluded_files(starter + own, subtraction), ['starter.py'])
+
+
+if __name__ == '__main__':
+    unittest.main()
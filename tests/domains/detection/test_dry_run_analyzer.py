@@ -0,0 +1,66 @@
+"""
+Tests for DryRunAnalyzer
+"""
+
+import unittest
+
+from app.domains.detection.dry_run_analyzer import DryRunAnalyzer
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.detection.dto.dry_run_analysis_dto import DryRunAnalysisRequestDto
+from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.tokenization.tokenization_service import TokenizationService
+from app.shared.exceptions import ValidationException
+
+SNIPPET = '''
+def total(values):
+    # Sum of the values
+    result = 0
+    for value in values:
+        result += value
+    return result
+'''
+
+
+class TestDryRunAnalyzer(unittest.TestCase):
+    """Unit tests for the analyses of snippets, returning their tokens and fingerprints."""
+
+    def setUp(self):
+        self.analyzer = DryRunAnalyzer(TokenizationService(), SimilarityDetectionService())
+
+    def test_tokens_and_fingerprints(self):
+        """Test that a snippet is tokenized with the language of its file name, and fingerprinted."""
+        analysis = self.analyzer.analyze(DryRunAnalysisRequestDto(content=SNIPPET, file_name='solution.py'))
+        self.assertEqual(analysis.language, 'python')
+        self.assertEqual(analysis.language_detection.language, 'python')
+        self.assertEqual(analysis.token_count, len(analysis.tokens))
+        self.assertIn('total', [token.text for token in analysis.tokens])
+        self.assertTrue(analysis.fingerprints)
+        self.assertEqual(len(analysis.fingerprints[0].hash), 16)
+        self.assertEqual((analysis.kgram_size, analysis.window_size), (5, 4))
+
+    def test_detection_options_applied(self):
+        """Test that the compared stream is that of the detection options, the tokens staying those of the code."""
+        options = DetectionOptionsDto(ignore_comments=True, normalize_identifiers=True)
+        analysis = self.analyzer.analyze(
+            DryRunAnalysisRequestDto(content=SNIPPET, language='python', detection_options=options)
+        )
+        self.assertIsNone(analysis.language_detection)
+        self.assertIn('comment', [token.type for token in analysis.tokens])
+        self.assertNotIn('comment', [token.type for token in analysis.compared_tokens])
+        self.assertNotIn('total', [token.text for token in analysis.compared_tokens])
+
+    def test_content_too_large(self):
+        """Test that a content over the size limit is refused."""
+        with self.assertRaises(ValidationException) as context:
+            self.analyzer.analyze(DryRunAnalysisRequestDto(content='x' * 100_001, language='python'))
+        self.assertEqual(context.exception.detail['error_type'], 'content_too_large')
+
+    def test_unsupported_language(self):
+        """Test that a language without tokenizer is refused."""
+        with self.assertRaises(ValidationException) as context:
+            self.analyzer.analyze(DryRunAnalysisRequestDto(content=SNIPPET, language='cobol'))
+        self.assertEqual(context.exception.detail['error_type'], 'unsupported_language')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,70 @@
+"""
+Tests for FileSimilarityBreakdown
+"""
+
+import unittest
+
+from app.domains.detection.file_similarity_breakdown import FileAggregation, FileSimilarityBreakdown
+
+
+class TestFileSimilarityBreakdown(unittest.TestCase):
+    """Unit tests for the file-to-file breakdown of the comparison of two submissions."""
+
+    def _tokens(self, sizes):
+        return [{'type': 'identifier', 'text': 'x', 'file': file} for file, size in sizes for _ in range(size)]
+
+    def _match(self, file1, file2, tokens):
+        return {
+            'file1': {'file': file1, 'start_line': 0, 'end_line': 1},
+            'file2': {'file': file2, 'start_line': 0, 'end_line': 1},
+            'tokens': tokens,
+        }
+
+    def setUp(self):
+        self.tokens1 = self._tokens([('worker.go', 100), ('main.go', 50), ('util.go', 50)])
+        self.tokens2 = self._tokens([('jobs/worker.go', 100), ('cmd/main.go', 150)])
+        self.matches = [
+            self._match('worker.go', 'jobs/worker.go', 60),
+            self._match('worker.go', 'jobs/worker.go', 32),
+            self._match('main.go', 'cmd/main.go', 20),
+            self._match('worker.go', 'cmd/main.go', 25),
+        ]
+
+    def test_pairs_and_best_matches(self):
+        """Test that the matched tokens are summed by file pair, each file keeping its best counterpart."""
+        result = FileSimilarityBreakdown().breakdown(self.matches, self.tokens1, self.tokens2)
+
+        self.assertEqual(result['pairs'][0], {
+            'file1': 'worker.go', 'file2': 'jobs/worker.go', 'matched_tokens': 92, 'similarity': 0.92
+        })
+        self.assertEqual(len(result['pairs']), 3)
+        files1 = {file['file']: file for file in result['files1']}
+        self.assertEqual(files1['worker.go']['best_match'], 'jobs/worker.go')
+        self.assertEqual(files1['worker.go']['matched_tokens'], 100)
+        self.assertEqual((files1['main.go']['best_match'], files1['main.go']['similarity']), ('cmd/main.go', 0.2))
+        self.assertEqual((files1['util.go']['best_match'], files1['util.go']['similarity']), (None, 0.0))
+        self.assertEqual(result['files2'][0]['file'], 'jobs/worker.go')
+
+    def test_aggregation(self):
+        """Test that the file scores are aggregated by size or by their maximum, the formula being reported."""
+        size_weighted = FileSimilarityBreakdown().breakdown(self.matches, self.tokens1, self.tokens2)['aggregation']
+        maximum = FileSimilarityBreakdown(FileAggregation.MAX).breakdown(self.matches, self.tokens1, self.tokens2)
+
+        # (100 * 0.92 + 50 * 0.2 + 50 * 0 + 100 * 0.92 + 150 * 0.2) / 450
+        self.assertEqual(size_weighted['similarity'], 0.498)
+        self.assertEqual(size_weighted['method'], 'size_weighted')
+        self.assertIn('sum(tokens(f)', size_weighted['formula'])
+        self.assertEqual(maximum['aggregation']['similarity'], 0.92)
+
+    def test_excluded_files(self):
+        """Test that the files left without compared tokens are listed with a reason instead of missing."""
+        original1 = self.tokens1 + self._tokens([('imports.go', 5)])
+
+        result = FileSimilarityBreakdown().breakdown(self.matches, self.tokens1, self.tokens2, original1, self.tokens2)
+
+        self.assertEqual([file['file'] for file in result['excluded_files']['file1']], ['imports.go'])
+        self.assertEqual(result['excluded_files']['file2'], [])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,87 @@
+"""
+Tests for FunctionSimilarity
+"""
+
+import unittest
+
+from app.domains.detection.function_similarity import FunctionSimilarity
+
+
+class TestFunctionSimilarity(unittest.TestCase):
+    """Unit tests for the function-level matching of two submissions."""
+
+    def _function(self, definition, body, file, start):
+        """Tokens of a function whose body is a line per part, with their signature parts"""
+        end = start + len(body)
+        tokens = [{'type': 'function_definition', 'text': definition, 'file': file, 'start': start, 'end': end}]
+        tokens.extend(
+            {'type': 'expression_statement', 'text': part, 'file': file, 'start': line, 'end': line}
+            for line, part in enumerate(body, start + 1)
+        )
+        return tokens, [definition.split('(')[0].split()[0]] + body
+
+    def _stream(self, *functions):
+        tokens, parts = [], []
+        for function_tokens, function_parts in functions:
+            tokens.extend(function_tokens)
+            parts.extend(function_parts)
+        return tokens, parts
+
+    def setUp(self):
+        self.similarity = FunctionSimilarity(min_tokens=5, kgram_size=3)
+        self.copied = [f'step_{index}' for index in range(12)]
+
+    def test_best_matches(self):
+        """Test that each function is reported with its most similar counterpart and the best score of the pair."""
+        tokens1, parts1 = self._stream(
+            self._function('def process(jobs):', self.copied, 'worker.py', 0),
+            self._function('def report(jobs):', [f'report_{index}' for index in range(8)], 'worker.py', 20),
+        )
+        renamed = self.copied[:10] + ['other_0', 'other_1']
+        tokens2, parts2 = self._stream(
+            self._function('def run_queue(items):', renamed, 'jobs/queue.py', 3),
+        )
+
+        result = self.similarity.compare(tokens1, parts1, tokens2, parts2)
+
+        best = result['functions1'][0]
+        self.assertEqual((best['name'], best['file'], best['start'], best['end']), ('process', 'worker.py', 0, 12))
+        self.assertEqual(best['best_match']['name'], 'run_queue')
+        self.assertEqual(best['best_match']['file'], 'jobs/queue.py')
+        self.assertEqual(best['similarity'], 0.692)
+        self.assertEqual((result['functions1'][1]['best_match'], result['functions1'][1]['similarity']), (None, 0.0))
+        self.assertEqual(result['functions2'][0]['best_match']['name'], 'process')
+        self.assertEqual(result['max_similarity'], 0.692)
+
+    def test_short_functions_ignored(self):
+        """Test that the functions under the minimum number of tokens are ignored, however similar."""
+        getter = ['return self.value']
+        tokens1, parts1 = self._stream(self._function('def get_value(self):', getter, 'a.py', 0))
+        tokens2, parts2 = self._stream(self._function('def get_value(self):', getter, 'b.py', 0))
+
+        result = self.similarity.compare(tokens1, parts1, tokens2, parts2)
+
+        self.assertEqual((result['functions1'], result['functions2']), ([], []))
+        self.assertEqual(result['max_similarity'], 0.0)
+
+    def test_function_names(self):
+        """Test the names of the Go, Java and JavaScript definitions, a Go method named after its receiver."""
+        self.assertEqual(FunctionSimilarity._name('func (w *Worker) Process(jobs []Job) error {'), 'Process')
+        self.assertEqual(FunctionSimilarity._name('func main() {'), 'main')
+        self.assertEqual(FunctionSimilarity._name('public static int add(int a, int b) {'), 'add')
+        self.assertEqual(FunctionSimilarity._name('function render(items) {'), 'render')
+
+    def test_segments_stay_in_their_file(self):
+        """Test that a function ends with its file, the next file starting on the same lines."""
+        tokens, parts = self._stream(
+            self._function('def first():', self.copied[:6], 'a.py', 0),
+            self._function('def second():', self.copied[6:], 'b.py', 0),
+        )
+
+        functions = self.similarity.segment(tokens, parts)
+
+        self.assertEqual([(function.name, function.tokens) for function in functions], [('first', 7), ('second', 7)])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,106 @@
+"""
+Tests for GoStructuralAnalyzer
+"""
+
+import unittest
+
+from app.domains.detection.go_structural_analyzer import GoStructuralAnalyzer
+
+
+class FakeNode:
+    """Minimal tree-sitter node: kind, named children and the name field"""
+
+    def __init__(self, type, children=(), name=None, row=0):
+        self.type = type
+        self.named_children = list(children)
+        self.start_point = (row, 0)
+        self.end_point = (row, 0)
+        self.name = name
+
+    def child_by_field_name(self, field):
+        return self.name if field == 'name' else None
+
+
+class TestGoStructuralAnalyzer(unittest.TestCase):
+    """Unit tests for the structural comparison of Go syntax trees."""
+
+    def setUp(self):
+        self.analyzer = GoStructuralAnalyzer()
+        self.source = b''
+
+    def _name(self, text):
+        node = FakeNode('identifier')
+        node.start_byte = len(self.source)
+        self.source += text.encode('utf8')
+        node.end_byte = len(self.source)
+        return node
+
+    def _call(self, function, literal='int_literal'):
+        return FakeNode('expression_statement', [
+            FakeNode('call_expression', [self._name(function), FakeNode('argument_list', [FakeNode(literal)])])
+        ])
+
+    def _function(self, name, statements, row=0):
+        body = FakeNode('block', statements)
+        return FakeNode('function_declaration', [self._name(name), FakeNode('parameter_list'), body],
+                        name=self._name(name), row=row)
+
+    def _struct(self, name, fields):
+        field_list = FakeNode('field_declaration_list', [
+            FakeNode('field_declaration', [self._name(field), self._name(kind)]) for field, kind in fields
+        ])
+        return FakeNode('type_spec', [self._name(name), FakeNode('struct_type', [field_list])], name=self._name(name))
+
+    def _file(self, *declarations):
+        return FakeNode('source_file', declarations)
+
+    def test_identifiers_and_literals_abstracted(self):
+        """Test that renamed functions with other literals have the same shape."""
+        units1 = self.analyzer.extract_units(self._file(self._function('run', [self._call('log')])), self.source)
+        units2 = self.analyzer.extract_units(
+            self._file(self._function('start', [self._call('trace', 'float_literal')])), self.source
+        )
+
+        self.assertEqual([u.name for u in units1], ['run'])
+        self.assertEqual([u.name for u in units2], ['start'])
+        self.assertEqual(units1[0].subtrees, units2[0].subtrees)
+        self.assertEqual(self.analyzer.compare(units1, units2)['structural_similarity'], 1.0)
+
+    def test_reordered_declarations_and_fields(self):
+        """Test that reordered functions and struct fields keep the structure of the file."""
+        person = self._struct('Person', [('Name', 'string'), ('Age', 'int')])
+        reordered_person = self._struct('Person', [('Age', 'int'), ('Name', 'string')])
+        run = self._function('run', [self._call('log')])
+        stop = self._function('stop', [FakeNode('return_statement', [FakeNode('nil')])])
+
+        units1 = self.analyzer.extract_units(self._file(person, run, stop), self.source)
+        units2 = self.analyzer.extract_units(self._file(stop, run, reordered_person), self.source)
+        result = self.analyzer.compare(units1, units2)
+
+        self.assertEqual(result['structural_similarity'], 1.0)
+        self.assertEqual(result['units'], {'file1': 3, 'file2': 3})
+        self.assertEqual([(m['unit1'], m['unit2'], m['similarity']) for m in result['function_matches']],
+                         [('Person', 'Person', 1.0), ('run', 'run', 1.0), ('stop', 'stop', 1.0)])
+
+    def test_function_split_into_helpers(self):
+        """Test that a function split into two helpers still scores high at file level."""
+        statements = [self._call(f'step{index}') for index in range(6)]
+        original = self._file(self._function('worker', statements))
+        split = self._file(
+            self._function('worker', [self._call('first'), self._call('second')]),
+            self._function('first', statements[:3]),
+            self._function('second', statements[3:])
+        )
+
+        units1 = self.analyzer.extract_units(original, self.source)
+        units2 = self.analyzer.extract_units(split, self.source)
+        result = self.analyzer.compare(units1, units2)
+
+        self.assertGreater(result['structural_similarity'], 0.7)
+        self.assertLess(result['function_matches'][0]['similarity'], result['structural_similarity'])
+        self.assertEqual(self.analyzer.compare([], [])['structural_similarity'], 1.0)
+        self.assertEqual(self.analyzer.compare(units1, [])['structural_similarity'], 0.0)
+
+
+if __name__ == '__main__':
+    unittest.main()
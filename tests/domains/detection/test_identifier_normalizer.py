@@ -0,0 +1,77 @@
+"""
+Tests for IdentifierNormalizer
+"""
+
+import unittest
+
+from app.domains.detection.identifier_normalizer import IdentifierNormalizer
+
+
+class TestIdentifierNormalizer(unittest.TestCase):
+    """Unit tests for the positional renaming of identifiers."""
+
+    def _go_tokens(self, total, values):
+        """Tokens of `total := cap(values)` and `fmt.Println(len(values), "total", total)` with the given names"""
+        return [
+            {'type': 'short_var_declaration', 'text': f'{total} := cap({values})', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': total, 'start': 0, 'end': 0},
+            {'type': 'call_expression', 'text': f'cap({values})', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': 'cap', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': values, 'start': 0, 'end': 0},
+            {'type': 'call_expression', 'text': f'fmt.Println(len({values}), "total", {total})', 'start': 1,
+             'end': 1},
+            {'type': 'selector_expression', 'text': 'fmt.Println', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': 'fmt', 'start': 1, 'end': 1},
+            {'type': 'field_identifier', 'text': 'Println', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': 'len', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': values, 'start': 1, 'end': 1},
+            {'type': 'interpreted_string_literal', 'text': '"total"', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': total, 'start': 1, 'end': 1}
+        ]
+
+    def test_positional_placeholders(self):
+        """Test that identifiers are numbered in order of first appearance, consistently within a file."""
+        normalized = IdentifierNormalizer('go').normalize(self._go_tokens('total', 'values'))
+
+        self.assertEqual([t['text'] for t in normalized if t['type'] == 'identifier'],
+                         ['ID1', 'cap', 'ID2', 'fmt', 'len', 'ID2', 'ID1'])
+        # Enclosing tokens are renamed too, literals are left untouched
+        self.assertEqual(normalized[0]['text'], 'ID1 := cap(ID2)')
+        self.assertEqual(normalized[5]['text'], 'fmt.Println(len(ID2), "total", ID1)')
+        self.assertEqual(normalized[11]['text'], '"total"')
+
+    def test_builtins_and_standard_library_kept(self):
+        """Test that builtins and the members of standard library packages keep their names."""
+        normalized = IdentifierNormalizer('go').normalize(self._go_tokens('total', 'values'))
+
+        self.assertEqual(normalized[6]['text'], 'fmt.Println')
+        self.assertEqual(normalized[8]['text'], 'Println')
+        self.assertEqual(normalized[9]['text'], 'len')
+
+        # A member accessed through a user variable is renamed
+        tokens = [
+            {'type': 'selector_expression', 'text': 'report.Println', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': 'report', 'start': 0, 'end': 0},
+            {'type': 'field_identifier', 'text': 'Println', 'start': 0, 'end': 0}
+        ]
+        self.assertEqual([t['text'] for t in IdentifierNormalizer('go').normalize(tokens)], ['ID1.ID2', 'ID1', 'ID2'])
+
+    def test_renamed_streams_identical(self):
+        """Test that a global rename does not change the normalized stream."""
+        normalizer = IdentifierNormalizer('go')
+        original = normalizer.normalize(self._go_tokens('total', 'values'))
+        renamed = normalizer.normalize(self._go_tokens('acc', 'items'))
+
+        self.assertEqual([t['text'] for t in original], [t['text'] for t in renamed])
+
+    def test_builtins_per_language(self):
+        """Test that the kept names depend on the language, every language being used when it is unknown."""
+        tokens = [{'type': 'identifier', 'text': 'self', 'start': 0, 'end': 0}]
+
+        self.assertEqual(IdentifierNormalizer('python').normalize(tokens)[0]['text'], 'self')
+        self.assertEqual(IdentifierNormalizer('go').normalize(tokens)[0]['text'], 'ID1')
+        self.assertEqual(IdentifierNormalizer().normalize(tokens)[0]['text'], 'self')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,70 @@
+"""
+Tests for LiteralNormalizer
+"""
+
+import unittest
+
+from app.domains.detection.literal_normalizer import LiteralNormalizer
+
+
+class TestLiteralNormalizer(unittest.TestCase):
+    """Unit tests for the abstraction of string and numeric literals."""
+
+    def _printf_tokens(self, message, limit):
+        """Tokens of `fmt.Printf(message, limit)` as emitted for Go"""
+        return [
+            {'type': 'call_expression', 'text': f'fmt.Printf({message}, {limit})', 'start': 0, 'end': 0},
+            {'type': 'selector_expression', 'text': 'fmt.Printf', 'start': 0, 'end': 0},
+            {'type': 'argument_list', 'text': f'({message}, {limit})', 'start': 0, 'end': 0},
+            {'type': 'interpreted_string_literal', 'text': message, 'start': 0, 'end': 0},
+            {'type': 'interpreted_string_literal_content', 'text': message.strip('"'), 'start': 0, 'end': 0},
+            {'type': 'int_literal', 'text': limit, 'start': 0, 'end': 0}
+        ]
+
+    def test_placeholders(self):
+        """Test that literals are replaced with STR and NUM, in the literals and in enclosing tokens."""
+        normalized = LiteralNormalizer().normalize(self._printf_tokens('"Total: %d\\n"', '42'))
+
+        self.assertEqual([t['type'] for t in normalized],
+                         ['call_expression', 'selector_expression', 'argument_list', 'STR', 'NUM'])
+        self.assertEqual(normalized[0]['text'], 'fmt.Printf(STR, NUM)')
+        self.assertEqual(normalized[2]['text'], '(STR, NUM)')
+        # The original text is kept for the reports
+        self.assertEqual(normalized[3]['original_text'], '"Total: %d\\n"')
+        self.assertEqual(normalized[4]['original_text'], '42')
+
+    def test_changed_literals_identical(self):
+        """Test that changed messages and magic numbers do not change the normalized stream."""
+        normalizer = LiteralNormalizer()
+        original = normalizer.normalize(self._printf_tokens('"Total: %d\\n"', '42'))
+        changed = normalizer.normalize(self._printf_tokens('"Sum is %s"', '0x2A'))
+
+        self.assertEqual([t['text'] for t in original], [t['text'] for t in changed])
+
+    def test_length_buckets(self):
+        """Test that the length bucket sub-mode keeps the order of magnitude of the literals."""
+        normalizer = LiteralNormalizer(length_buckets=True)
+
+        self.assertEqual(normalizer.string_placeholder('""'), 'STR#0')
+        self.assertEqual(normalizer.string_placeholder('"abc"'), 'STR#1-3')
+        self.assertEqual(normalizer.string_placeholder("'''Hello, world'''"), 'STR#4-15')
+        self.assertEqual(normalizer.number_placeholder('7'), 'NUM#1-3')
+        self.assertEqual(normalizer.number_placeholder('3.14159'), 'NUM#4-15')
+
+    def test_format_verbs(self):
+        """Test that the verbs of Go and Python format strings are optionally preserved."""
+        normalizer = LiteralNormalizer(preserve_format_verbs=True)
+
+        self.assertEqual(normalizer.string_placeholder('"Worker %d processing job %-5s (100%%)\\n"'), 'STR(%d %-5s)')
+        self.assertEqual(normalizer.string_placeholder('"{} scored {name:>10} {{points}}"'), 'STR({} {:>10})')
+        self.assertEqual(normalizer.string_placeholder('f"Hello {user}"'), 'STR({})')
+        self.assertEqual(normalizer.string_placeholder('"plain"'), 'STR')
+
+        same_verbs = normalizer.normalize(self._printf_tokens('"Total: %d"', '1'))
+        other_verbs = normalizer.normalize(self._printf_tokens('"Total: %s"', '1'))
+        self.assertNotEqual(same_verbs[3]['text'], other_verbs[3]['text'])
+        self.assertEqual(LiteralNormalizer().normalize(self._printf_tokens('"Total: %s"', '1'))[3]['text'], 'STR')
+
+
+if __name__ == '__main__':
+    unittest.main()
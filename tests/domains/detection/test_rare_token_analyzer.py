@@ -0,0 +1,68 @@
+"""
+Tests for RareTokenAnalyzer
+"""
+
+import unittest
+
+from app.domains.detection.rare_token_analyzer import RareTokenAnalyzer
+
+
+class TestRareTokenAnalyzer(unittest.TestCase):
+    """Unit tests for the identifiers and string literals shared by few submissions of a run."""
+
+    def setUp(self):
+        self.analyzer = RareTokenAnalyzer(max_document_frequency=0.1)
+
+    @staticmethod
+    def _tokens(identifiers=(), strings=()):
+        tokens = [{'type': 'identifier', 'text': name, 'start': 0, 'end': 0} for name in identifiers]
+        return tokens + [{'type': 'interpreted_string_literal', 'text': f'"{text}"', 'start': 1, 'end': 1}
+                         for text in strings]
+
+    def test_common_names_ignored(self):
+        """Test that builtins, conventional names and short names are not candidates."""
+        tokens = self._tokens(['err', 'result', 'main', 'len', 'fmt', 'Result', 'ab', 'tmpFooBar2'],
+                              ['ok', 'conection refused'])
+        self.assertEqual(self.analyzer.extract(tokens, 'go'),
+                         {('identifier', 'tmpFooBar2'), ('string', 'conection refused')})
+
+    def test_string_content(self):
+        """Test that the prefix and quotes of a string literal are not part of its content."""
+        self.assertEqual(RareTokenAnalyzer.string_content('"hello"'), 'hello')
+        self.assertEqual(RareTokenAnalyzer.string_content("f'total: {x}'"), 'total: {x}')
+        self.assertEqual(RareTokenAnalyzer.string_content('`raw`'), 'raw')
+        self.assertEqual(RareTokenAnalyzer.string_content('"""doc"""'), 'doc')
+
+    def test_pairs_sharing_rare_tokens(self):
+        """Test that only the tokens of few submissions are reported, from the rarest."""
+        documents = {f's{index}': {('identifier', 'parseInput')} for index in range(30)}
+        documents['s1'] |= {('identifier', 'tmpFooBar2'), ('string', 'conection refused')}
+        documents['s2'] |= {('identifier', 'tmpFooBar2'), ('string', 'conection refused')}
+        documents['s3'] |= {('string', 'conection refused'), ('identifier', 'ownName')}
+
+        pairs = self.analyzer.shared_pairs(documents)
+
+        self.assertEqual([(p['submission_id'], p['compared_submission_id']) for p in pairs],
+                         [('s1', 's2'), ('s1', 's3'), ('s2', 's3')])
+        self.assertEqual(pairs[0]['shared_tokens'], [
+            {'kind': 'identifier', 'text': 'tmpFooBar2', 'document_frequency': 2},
+            {'kind': 'string', 'text': 'conection refused', 'document_frequency': 3},
+        ])
+        # The unshared rare name of s3 lowers the evidence of its pairs
+        self.assertEqual(pairs[0]['evidence_score'], 1.0)
+        self.assertLess(pairs[1]['evidence_score'], pairs[0]['evidence_score'])
+
+    def test_small_run(self):
+        """Test that a token of exactly two submissions is rare however small the run."""
+        documents = {'s1': {('identifier', 'tmpFooBar2')}, 's2': {('identifier', 'tmpFooBar2')}, 's3': set()}
+        self.assertEqual(self.analyzer.max_submissions(len(documents)), 2)
+        self.assertEqual(len(self.analyzer.shared_pairs(documents)), 1)
+
+    def test_blend(self):
+        """Test that the evidence enters the similarity only with a weight."""
+        self.assertEqual(RareTokenAnalyzer.blend(0.4, 1.0, 0.0), 0.4)
+        self.assertEqual(RareTokenAnalyzer.blend(0.4, 1.0, 0.5), 0.7)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,130 @@
+"""
+Tests for ReachabilityAnalyzer
+"""
+
+import unittest
+
+from app.domains.detection.reachability_analyzer import ReachabilityAnalyzer
+
+
+class TestReachabilityAnalyzer(unittest.TestCase):
+    """Unit tests for the detection of functions never called."""
+
+    def _python_tokens(self):
+        """Tokens of a Python script whose `unused` function is never called"""
+        return [
+            {'type': 'module', 'text': 'def helper(x): ...', 'start': 0, 'end': 7},
+            {'type': 'function_definition', 'text': 'def helper(x):\n    return x * 2', 'start': 0, 'end': 1},
+            {'type': 'identifier', 'text': 'helper', 'start': 0, 'end': 0},
+            {'type': 'parameters', 'text': '(x)', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': 'x', 'start': 0, 'end': 0},
+            {'type': 'block', 'text': 'return x * 2', 'start': 1, 'end': 1},
+            {'type': 'return_statement', 'text': 'return x * 2', 'start': 1, 'end': 1},
+            {'type': 'binary_operator', 'text': 'x * 2', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': 'x', 'start': 1, 'end': 1},
+            {'type': 'function_definition', 'text': 'def unused(y):\n    return helper(y)', 'start': 2, 'end': 3},
+            {'type': 'identifier', 'text': 'unused', 'start': 2, 'end': 2},
+            {'type': 'parameters', 'text': '(y)', 'start': 2, 'end': 2},
+            {'type': 'identifier', 'text': 'y', 'start': 2, 'end': 2},
+            {'type': 'block', 'text': 'return helper(y)', 'start': 3, 'end': 3},
+            {'type': 'return_statement', 'text': 'return helper(y)', 'start': 3, 'end': 3},
+            {'type': 'call', 'text': 'helper(y)', 'start': 3, 'end': 3},
+            {'type': 'identifier', 'text': 'helper', 'start': 3, 'end': 3},
+            {'type': 'function_definition', 'text': 'def main():\n    print(helper(3))', 'start': 4, 'end': 5},
+            {'type': 'identifier', 'text': 'main', 'start': 4, 'end': 4},
+            {'type': 'block', 'text': 'print(helper(3))', 'start': 5, 'end': 5},
+            {'type': 'call', 'text': 'print(helper(3))', 'start': 5, 'end': 5},
+            {'type': 'identifier', 'text': 'print', 'start': 5, 'end': 5},
+            {'type': 'call', 'text': 'helper(3)', 'start': 5, 'end': 5},
+            {'type': 'identifier', 'text': 'helper', 'start': 5, 'end': 5},
+            {'type': 'if_statement', 'text': 'if __name__ == "__main__":\n    main()', 'start': 6, 'end': 7},
+            {'type': 'identifier', 'text': '__name__', 'start': 6, 'end': 6},
+            {'type': 'call', 'text': 'main()', 'start': 7, 'end': 7},
+            {'type': 'identifier', 'text': 'main', 'start': 7, 'end': 7}
+        ]
+
+    def _go_tokens(self, package):
+        """Tokens of a Go file with an unused function, an unused method and an exported function"""
+        def function(name, row, token_type='function_declaration', receiver=''):
+            tokens = [{'type': token_type, 'text': f'func {receiver}{name}() {{}}', 'start': row, 'end': row}]
+            if receiver:
+                tokens += [
+                    {'type': 'parameter_list', 'text': receiver.strip(), 'start': row, 'end': row},
+                    {'type': 'identifier', 'text': 's', 'start': row, 'end': row},
+                    {'type': 'type_identifier', 'text': 'Server', 'start': row, 'end': row},
+                    {'type': 'field_identifier', 'text': name, 'start': row, 'end': row}
+                ]
+            else:
+                tokens.append({'type': 'identifier', 'text': name, 'start': row, 'end': row})
+            return tokens + [{'type': 'block', 'text': '{}', 'start': row, 'end': row}]
+
+        return [
+            {'type': 'source_file', 'text': f'package {package}', 'start': 0, 'end': 8},
+            {'type': 'package_clause', 'text': f'package {package}', 'start': 0, 'end': 0},
+            {'type': 'package_identifier', 'text': package, 'start': 0, 'end': 0},
+            {'type': 'function_declaration', 'text': 'func main() {\n\trun()\n}', 'start': 1, 'end': 3},
+            {'type': 'identifier', 'text': 'main', 'start': 1, 'end': 1},
+            {'type': 'block', 'text': '{\n\trun()\n}', 'start': 1, 'end': 3},
+            {'type': 'call_expression', 'text': 'run()', 'start': 2, 'end': 2},
+            {'type': 'identifier', 'text': 'run', 'start': 2, 'end': 2}
+        ] + (
+            function('run', 4)
+            + function('dead', 5)
+            + function('Helper', 6)
+            + function('Serve', 7, 'method_declaration', '(s *Server) ')
+            + function('stop', 8, 'method_declaration', '(s *Server) ')
+        )
+
+    def test_python_unreachable_function(self):
+        """Test that a function only called from an unused function is still reachable from main."""
+        tokens = self._python_tokens()
+        result = ReachabilityAnalyzer('python').analyze(tokens)
+
+        self.assertEqual([f.name for f in result.functions], ['helper', 'unused', 'main'])
+        self.assertEqual(result.to_dict(), [{'name': 'unused', 'start': 2, 'end': 3, 'tokens': 8}])
+        self.assertEqual(result.excluded_token_count, 8)
+
+        kept = ReachabilityAnalyzer.exclude(tokens, result)
+        self.assertEqual(len(kept), len(tokens) - 8)
+        self.assertNotIn('unused', [t['text'] for t in kept])
+
+    def test_python_entry_points(self):
+        """Test that decorated functions, dunder methods, tests and `__all__` names are entry points."""
+        tokens = [
+            {'type': 'module', 'text': '__all__ = ["api"]', 'start': 0, 'end': 6},
+            {'type': 'expression_statement', 'text': '__all__ = ["api"]', 'start': 0, 'end': 0},
+            {'type': 'assignment', 'text': '__all__ = ["api"]', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': '__all__', 'start': 0, 'end': 0},
+            {'type': 'function_definition', 'text': 'def api(): pass', 'start': 1, 'end': 1},
+            {'type': 'decorated_definition', 'text': '@app.get("/")\ndef index(): pass', 'start': 2, 'end': 3},
+            {'type': 'decorator', 'text': '@app.get("/")', 'start': 2, 'end': 2},
+            {'type': 'function_definition', 'text': 'def index(): pass', 'start': 3, 'end': 3},
+            {'type': 'function_definition', 'text': 'def __init__(self): pass', 'start': 4, 'end': 4},
+            {'type': 'function_definition', 'text': 'def test_api(): pass', 'start': 5, 'end': 5},
+            {'type': 'function_definition', 'text': 'def _private(): pass', 'start': 6, 'end': 6}
+        ]
+
+        result = ReachabilityAnalyzer().analyze(tokens)
+
+        self.assertEqual(result.language, 'python')
+        self.assertEqual([f.name for f in result.unreachable], ['_private'])
+
+    def test_go_unreachable_functions(self):
+        """Test that exported functions are only entry points outside the main package."""
+        main_result = ReachabilityAnalyzer('go').analyze(self._go_tokens('main'))
+        library_result = ReachabilityAnalyzer('go').analyze(self._go_tokens('util'))
+
+        # Exported methods may implement an interface, unexported ones never called are padding
+        self.assertEqual([f.name for f in main_result.unreachable], ['dead', 'Helper', 'stop'])
+        self.assertEqual([f.name for f in library_result.unreachable], ['dead', 'stop'])
+
+    def test_unsupported_language(self):
+        """Test that the functions of other languages are never reported."""
+        tokens = [{'type': 'function_definition', 'text': 'def unused(): pass', 'start': 0, 'end': 0}]
+
+        self.assertEqual(ReachabilityAnalyzer('ruby').analyze(tokens).unreachable, [])
+        self.assertEqual(ReachabilityAnalyzer().analyze(tokens).unreachable, [])
+
+
+if __name__ == '__main__':
+    unittest.main()
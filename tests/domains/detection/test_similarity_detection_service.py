@@ -7,7 +7,11 @@ from pathlib import Path
 from unittest.mock import patch, MagicMock
 from typing import List, Dict, Any
 
+from app.domains.detection.allowed_snippet_filter import AllowedSnippetFilter
+from app.domains.detection.baseline_filter import BaselineFilter
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto, SimilarityMetric
 from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.detection.tfidf_model import TfidfModel
 from app.domains.tokenization.tokenization_service import TokenizationService
 from app.domains.detection.visualization.visualization_service import VisualizationService
 
@@ -258,6 +262,28 @@ class TestSimilarityDetectionService(unittest.TestCase):
         expected = ['FUNC_DEF', 'CONDITIONAL', 'LOOP', 'LOOP']
         self.assertEqual(sequence, expected)
 
+    def test_create_structural_sequence_go_concurrency(self):
+        """Test that Go channel and select tokens contribute to the structural sequence."""
+        tokens = [
+            {'type': 'select_statement', 'text': 'select {...}'},
+            {'type': 'select_case', 'text': 'case job := <-jobs:'},
+            {'type': 'channel_receive_statement', 'text': 'job := <-jobs'},
+            {'type': 'channel_receive', 'text': '<-jobs'},
+            {'type': 'channel_send', 'text': 'results <- job'},
+            {'type': 'select_default', 'text': 'default:'},
+            {'type': 'receive_channel_type', 'text': '<-chan int'}
+        ]
+
+        sequence = self.service._create_structural_sequence(tokens)
+        self.assertEqual(sequence, [
+            'SELECT', 'SELECT_CASE', 'CHAN_RECV', 'CHAN_RECV', 'CHAN_SEND', 'SELECT_CASE', 'RECEIVE_CHANNEL_TYPE'
+        ])
+
+        flow = self.service._extract_logical_flow(tokens)
+        self.assertEqual(flow, [
+            'select_statement', 'select_case', 'channel_receive', 'channel_send', 'select_default'
+        ])
+
     def test_extract_logical_flow(self):
         """Test logical flow extraction."""
         tokens = [
@@ -364,6 +390,517 @@ class TestSimilarityDetectionService(unittest.TestCase):
         self.assertEqual(result[0]['type'], 'some_other_type')
         self.assertFalse(result[0]['normalized'])
 
+    def test_compare_similarity_ignore_struct_tags(self):
+        """Test that struct tags are compared literally unless ignored."""
+        tokens1 = [
+            {'type': 'field_identifier', 'text': 'Name', 'start': 1, 'end': 1},
+            {'type': 'type_identifier', 'text': 'string', 'start': 1, 'end': 1},
+            {'type': 'struct_tag', 'text': '`json:"name"`', 'start': 1, 'end': 1}
+        ]
+        tokens2 = [
+            {'type': 'field_identifier', 'text': 'Name', 'start': 1, 'end': 1},
+            {'type': 'type_identifier', 'text': 'string', 'start': 1, 'end': 1},
+            {'type': 'struct_tag', 'text': '`json:"full_name" xml:"fullName"`', 'start': 1, 'end': 1}
+        ]
+
+        literal = self.service.compare_similarity(tokens1, tokens2)
+        ignored = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(ignore_struct_tags=True))
+
+        self.assertLess(literal['jaccard_similarity'], 1.0)
+        self.assertEqual(ignored['jaccard_similarity'], 1.0)
+        self.assertEqual(ignored['overall_similarity'], 1.0)
+
+        prepared = self.service.prepare_for_similarity(tokens1, DetectionOptionsDto(ignore_struct_tags=True))
+        self.assertNotIn('struct_tag', [t['type'] for t in prepared])
+
+    def test_compare_similarity_ignore_comments(self):
+        """Test that files differing only in comments are identical when comments are ignored."""
+        tokens1 = [
+            {'type': 'function_definition',
+             'text': 'void add(int a) {\n    // accumulate\n    total += a; /* sum */\n}', 'start': 0, 'end': 3},
+            {'type': 'line_comment', 'text': '// accumulate', 'start': 1, 'end': 1},
+            {'type': 'expression_statement', 'text': 'total += a;', 'start': 2, 'end': 2},
+            {'type': 'identifier', 'text': 'total', 'start': 2, 'end': 2},
+            {'type': 'block_comment', 'text': '/* sum */', 'start': 2, 'end': 2}
+        ]
+        tokens2 = [
+            {'type': 'function_definition', 'text': 'void add(int a) {\n    total += a;\n}', 'start': 0, 'end': 2},
+            {'type': 'expression_statement', 'text': 'total += a;', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': 'total', 'start': 1, 'end': 1}
+        ]
+
+        literal = self.service.compare_similarity(tokens1, tokens2)
+        ignored = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(ignore_comments=True))
+
+        self.assertLess(literal['overall_similarity'], 1.0)
+        self.assertEqual(ignored['jaccard_similarity'], 1.0)
+        self.assertEqual(ignored['overall_similarity'], 1.0)
+
+        # Comments are removed from the enclosing tokens, the original positions are kept
+        prepared = self.service.prepare_for_similarity(tokens1, DetectionOptionsDto(ignore_comments=True))
+        self.assertEqual([t['type'] for t in prepared], ['function_definition', 'expression_statement', 'identifier'])
+        self.assertEqual(prepared[0]['text'], 'void add(int a) {\n    total += a;\n}')
+        self.assertEqual((prepared[1]['start'], prepared[1]['end']), (2, 2))
+
+    def test_compare_similarity_docstrings_as_comments(self):
+        """Test that Python docstrings are only removed with the docstrings_as_comments sub-option."""
+        tokens1 = [
+            {'type': 'module', 'text': '"""Geometry."""\ndef area(r):\n    """Area."""\n    return r * r\n',
+             'start': 0, 'end': 4},
+            {'type': 'expression_statement', 'text': '"""Geometry."""', 'start': 0, 'end': 0},
+            {'type': 'string', 'text': '"""Geometry."""', 'start': 0, 'end': 0},
+            {'type': 'string_content', 'text': 'Geometry.', 'start': 0, 'end': 0},
+            {'type': 'function_definition', 'text': 'def area(r):\n    """Area."""\n    return r * r',
+             'start': 1, 'end': 3},
+            {'type': 'identifier', 'text': 'area', 'start': 1, 'end': 1},
+            {'type': 'block', 'text': '"""Area."""\n    return r * r', 'start': 2, 'end': 3},
+            {'type': 'expression_statement', 'text': '"""Area."""', 'start': 2, 'end': 2},
+            {'type': 'string', 'text': '"""Area."""', 'start': 2, 'end': 2},
+            {'type': 'string_content', 'text': 'Area.', 'start': 2, 'end': 2},
+            {'type': 'return_statement', 'text': 'return r * r', 'start': 3, 'end': 3},
+            {'type': 'expression_statement', 'text': '"""Not a docstring."""', 'start': 3, 'end': 3},
+            {'type': 'string', 'text': '"""Not a docstring."""', 'start': 3, 'end': 3}
+        ]
+
+        comments_only = self.service.prepare_for_similarity(tokens1, DetectionOptionsDto(ignore_comments=True))
+        self.assertEqual(len(comments_only), len(tokens1))
+
+        prepared = self.service.prepare_for_similarity(
+            tokens1, DetectionOptionsDto(ignore_comments=True, docstrings_as_comments=True)
+        )
+        self.assertEqual(
+            [t['type'] for t in prepared],
+            ['module', 'function_definition', 'identifier', 'block', 'return_statement', 'expression_statement',
+             'string']
+        )
+        self.assertEqual(prepared[1]['text'], 'def area(r):\n    return r * r')
+        self.assertEqual(prepared[3]['text'], 'return r * r')
+
+    def test_compare_similarity_ignore_imports(self):
+        """Test that two files sharing only their imports score 0% when imports are ignored."""
+        imports = [
+            {'type': 'import_declaration', 'text': 'import (\n\t"fmt"\n\t"os"\n)', 'start': 0, 'end': 3},
+            {'type': 'import_spec_list', 'text': '(\n\t"fmt"\n\t"os"\n)', 'start': 0, 'end': 3},
+            {'type': 'import_spec', 'text': '"fmt"', 'start': 1, 'end': 1},
+            {'type': 'interpreted_string_literal', 'text': '"fmt"', 'start': 1, 'end': 1},
+            {'type': 'import_spec', 'text': '"os"', 'start': 2, 'end': 2},
+            {'type': 'interpreted_string_literal', 'text': '"os"', 'start': 2, 'end': 2}
+        ]
+        tokens1 = imports + [
+            {'type': 'if_statement', 'text': 'if len(os.Args) > 1 {}', 'start': 4, 'end': 4},
+            {'type': 'call', 'text': 'len(os.Args)', 'start': 4, 'end': 4}
+        ]
+        tokens2 = imports + [
+            {'type': 'for_statement', 'text': 'for {}', 'start': 4, 'end': 4},
+            {'type': 'integer', 'text': '42', 'start': 5, 'end': 5}
+        ]
+
+        literal = self.service.compare_similarity(tokens1, tokens2)
+        ignored = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(ignore_imports=True))
+
+        self.assertGreater(literal['overall_similarity'], 0.0)
+        self.assertEqual(literal['excluded_import_tokens'], 0)
+        self.assertEqual(ignored['overall_similarity'], 0.0)
+        self.assertEqual(ignored['excluded_import_tokens'], 2 * len(imports))
+
+    def test_remove_imports_same_line_statement(self):
+        """Test that the statements following an import on the same line are kept."""
+        tokens = [
+            {'type': 'import_statement', 'text': 'import os', 'start': 0, 'end': 0},
+            {'type': 'dotted_name', 'text': 'os', 'start': 0, 'end': 0},
+            {'type': 'identifier', 'text': 'os', 'start': 0, 'end': 0},
+            {'type': 'expression_statement', 'text': 'x = 1', 'start': 0, 'end': 0},
+            {'type': 'lexical_declaration', 'text': 'const fs = require("fs");', 'start': 1, 'end': 1},
+            {'type': 'call_expression', 'text': 'require("fs")', 'start': 1, 'end': 1},
+            {'type': 'lexical_declaration', 'text': 'const total = sum(values);', 'start': 2, 'end': 2}
+        ]
+
+        kept = self.service.remove_imports(tokens)
+
+        self.assertEqual([t['text'] for t in kept], ['x = 1', 'const total = sum(values);'])
+
+    def test_compare_plain_text_reformatted(self):
+        """Test that the plain-text comparison ignores re-indentation and wrapping and maps matches back."""
+        source1 = "procedure Main is\n    Count : Integer := 0;\nbegin\n    Count := Count + 1;\nend Main;\n"
+        source2 = (
+            "-- copied\r\nprocedure Main is\r\n  Count :   Integer := 0;\r\nbegin\r\n"
+            "\tCount :=\r\n\t\tCount + 1;\r\nend Main;\r\n"
+        )
+
+        result = self.service.compare_plain_text(source1, source2)
+
+        self.assertGreater(result['similarity'], 0.7)
+        self.assertEqual(len(result['matches_file2']), 1)
+        match = result['matches_file2'][0]
+        # Offsets and lines are those of the original file, after the added comment line
+        self.assertEqual(match['start_line'], 1)
+        self.assertEqual(match['end_line'], 6)
+        self.assertTrue(match['text'].startswith('procedure Main is\r\n  Count :   Integer'))
+        self.assertEqual(source2.encode('utf8')[match['start_byte']:match['end_byte']].decode('utf8'), match['text'])
+
+        lowercase = self.service.compare_plain_text(
+            source1.upper(), source2, DetectionOptionsDto(plain_text_lowercase=True)
+        )
+        self.assertEqual(lowercase['similarity'], result['similarity'])
+        self.assertEqual(self.service.compare_plain_text(source1.upper(), source2)['similarity'], 0.0)
+
+    def test_compare_plain_text_fragments(self):
+        """Test that plain-text fragments pair the shared word runs of both files by their original lines."""
+        shared = "with Ada.Text_IO; use Ada.Text_IO;\nprocedure Hello is\nbegin\n   Put_Line (\"Hello\");\nend Hello;\n"
+        source1 = "-- header\n" + shared
+        source2 = "-- copied\n-- from a forum\n\n" + shared.replace("\n", "\r\n")
+
+        result = self.service.compare_plain_text(source1, source2, DetectionOptionsDto(min_fragment_tokens=10))
+
+        self.assertEqual(len(result['fragments']), 1)
+        fragment = result['fragments'][0]
+        self.assertEqual((fragment['left']['start_line'], fragment['left']['end_line']), (1, 5))
+        self.assertEqual((fragment['right']['start_line'], fragment['right']['end_line']), (3, 7))
+        self.assertIsNone(fragment['left']['start_column'])
+        self.assertEqual(fragment['tokens'], 12)
+        too_short = self.service.compare_plain_text(source1, source2, DetectionOptionsDto(min_fragment_tokens=20))
+        self.assertEqual(too_short['fragments'], [])
+
+    def test_compare_similarity_normalize_literals(self):
+        """Test that files differing only in literals are identical when literals are normalized."""
+        def tokens(message, count):
+            return [
+                {'type': 'call', 'text': f'print({message} % {count})', 'start': 0, 'end': 0},
+                {'type': 'identifier', 'text': 'print', 'start': 0, 'end': 0},
+                {'type': 'binary_operator', 'text': f'{message} % {count}', 'start': 0, 'end': 0},
+                {'type': 'string', 'text': message, 'start': 0, 'end': 0},
+                {'type': 'string_content', 'text': message.strip('"'), 'start': 0, 'end': 0},
+                {'type': 'integer', 'text': count, 'start': 0, 'end': 0}
+            ]
+
+        tokens1 = tokens('"%d items left"', '3')
+        tokens2 = tokens('"remaining: %d"', '10')
+
+        literal = self.service.compare_similarity(tokens1, tokens2)
+        normalized = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(normalize_literals=True))
+        self.assertLess(literal['overall_similarity'], 1.0)
+        self.assertEqual(normalized['overall_similarity'], 1.0)
+
+        # The verbs of format strings optionally still differentiate
+        tokens3 = tokens('"%s items left"', '3')
+        options = DetectionOptionsDto(normalize_literals=True, preserve_format_verbs=True)
+        self.assertEqual(self.service.compare_similarity(tokens1, tokens2, options)['overall_similarity'], 1.0)
+        self.assertLess(self.service.compare_similarity(tokens1, tokens3, options)['jaccard_similarity'], 1.0)
+
+        # Matched fragments can still be shown with the original literals
+        prepared = self.service.prepare_for_similarity(tokens1, DetectionOptionsDto(normalize_literals=True))
+        self.assertEqual([t['text'] for t in prepared if t['normalized']], ['<VAR>', 'STR', 'NUM'])
+        self.assertEqual([t.get('original_text') for t in prepared if t['type'] in ('STR', 'NUM')],
+                         ['"%d items left"', '3'])
+
+    def test_compare_similarity_with_baseline(self):
+        """Test that the starter code shared by two submissions is subtracted, the raw scores being kept."""
+        def statements(names, first_row):
+            return [
+                token
+                for row, name in enumerate(names, start=first_row)
+                for token in (
+                    {'type': 'expression_statement', 'text': f'{name}()', 'start': row, 'end': row},
+                    {'type': 'call', 'text': f'{name}()', 'start': row, 'end': row},
+                    {'type': 'identifier', 'text': name, 'start': row, 'end': row}
+                )
+            ]
+
+        starter = statements(['load', 'parse', 'validate', 'setup', 'connect', 'render'], 0)
+        tokens1 = starter + [
+            {'type': 'for_statement', 'text': 'for item in items: total += item', 'start': 6, 'end': 6},
+            {'type': 'augmented_assignment', 'text': 'total += item', 'start': 6, 'end': 6}
+        ]
+        tokens2 = starter + [
+            {'type': 'while_statement', 'text': 'while queue: queue.pop()', 'start': 6, 'end': 6},
+            {'type': 'return_statement', 'text': 'return None', 'start': 7, 'end': 7}
+        ]
+        fingerprints = BaselineFilter().fingerprint(starter)
+
+        result = self.service.compare_similarity_with_baseline(tokens1, tokens2, fingerprints)
+
+        self.assertEqual(result['raw_similarity']['overall_similarity'],
+                         self.service.compare_similarity(tokens1, tokens2)['overall_similarity'])
+        self.assertLess(result['overall_similarity'], result['raw_similarity']['overall_similarity'])
+        self.assertEqual(result['baseline']['excluded_tokens'], {'file1': len(starter), 'file2': len(starter)})
+        self.assertEqual(result['baseline']['regions']['file1'], [{'start': 0, 'end': 5, 'tokens': len(starter)}])
+
+        # Submissions left with only the starter code share nothing
+        untouched = self.service.compare_similarity_with_baseline(starter, starter, fingerprints)
+        self.assertEqual(untouched['raw_similarity']['overall_similarity'], 1.0)
+        self.assertEqual(untouched['overall_similarity'], 0.0)
+
+        # Without baseline the scores are the raw ones
+        unadjusted = self.service.compare_similarity_with_baseline(tokens1, tokens2, [])
+        self.assertEqual(unadjusted['overall_similarity'], unadjusted['raw_similarity']['overall_similarity'])
+        self.assertNotIn('baseline', unadjusted)
+
+    def test_compare_similarity_with_allowed_snippets(self):
+        """Test that the fragments within an allowed snippet in both files are excluded, and reported apart."""
+        def statements(names, first_row):
+            return [
+                token
+                for row, name in enumerate(names, start=first_row)
+                for token in (
+                    {'type': 'expression_statement', 'text': f'{name}()', 'start': row, 'end': row},
+                    {'type': 'call', 'text': f'{name}()', 'start': row, 'end': row},
+                    {'type': 'identifier', 'text': name, 'start': row, 'end': row}
+                )
+            ]
+
+        snippet = statements(['read', 'split', 'strip', 'convert', 'check', 'collect'], 0)
+        tokens1 = snippet + [
+            {'type': 'for_statement', 'text': 'for item in items: total += item', 'start': 6, 'end': 6},
+            {'type': 'augmented_assignment', 'text': 'total += item', 'start': 6, 'end': 6}
+        ]
+        tokens2 = snippet + [
+            {'type': 'while_statement', 'text': 'while queue: queue.pop()', 'start': 6, 'end': 6},
+            {'type': 'return_statement', 'text': 'return None', 'start': 7, 'end': 7}
+        ]
+        fingerprints = AllowedSnippetFilter().fingerprint(snippet)
+
+        result = self.service.compare_similarity_with_allowed_snippets(tokens1, tokens2, [], fingerprints)
+
+        overlap = result['sanctioned_overlap']
+        self.assertEqual(len(overlap['fragments']), 1)
+        self.assertEqual(overlap['excluded_tokens'], {'file1': len(snippet), 'file2': len(snippet)})
+        self.assertEqual(overlap['unsanctioned_similarity']['overall_similarity'],
+                         self.service.compare_similarity(tokens1, tokens2)['overall_similarity'])
+        self.assertLess(result['overall_similarity'], overlap['unsanctioned_similarity']['overall_similarity'])
+
+        # Without allowed snippets the scores are those of the baseline comparison
+        unsanctioned = self.service.compare_similarity_with_allowed_snippets(tokens1, tokens2, [], [])
+        self.assertNotIn('sanctioned_overlap', unsanctioned)
+
+    def test_compare_similarity_exclude_unreachable_functions(self):
+        """Test that functions never called are reported as padding and left out of the comparison."""
+        tokens1 = [
+            {'type': 'module', 'text': 'def main():', 'start': 0, 'end': 7},
+            {'type': 'function_definition', 'text': 'def main():\n    run()', 'start': 0, 'end': 1},
+            {'type': 'identifier', 'text': 'main', 'start': 0, 'end': 0},
+            {'type': 'call', 'text': 'run()', 'start': 1, 'end': 1},
+            {'type': 'identifier', 'text': 'run', 'start': 1, 'end': 1},
+            {'type': 'function_definition', 'text': 'def run():\n    return 1', 'start': 2, 'end': 3},
+            {'type': 'identifier', 'text': 'run', 'start': 2, 'end': 2},
+            {'type': 'return_statement', 'text': 'return 1', 'start': 3, 'end': 3},
+            {'type': 'if_statement', 'text': 'if __name__ == "__main__":\n    main()', 'start': 4, 'end': 5},
+            {'type': 'call', 'text': 'main()', 'start': 5, 'end': 5},
+            {'type': 'identifier', 'text': 'main', 'start': 5, 'end': 5}
+        ]
+        padding = [
+            {'type': 'function_definition', 'text': 'def sort_items(items):\n    return sorted(items)',
+             'start': 6, 'end': 7},
+            {'type': 'identifier', 'text': 'sort_items', 'start': 6, 'end': 6},
+            {'type': 'identifier', 'text': 'items', 'start': 6, 'end': 6},
+            {'type': 'return_statement', 'text': 'return sorted(items)', 'start': 7, 'end': 7},
+            {'type': 'call', 'text': 'sorted(items)', 'start': 7, 'end': 7}
+        ]
+        tokens2 = tokens1 + padding
+
+        padded = self.service.compare_similarity(tokens1, tokens2)
+        options = DetectionOptionsDto(exclude_unreachable_functions=True)
+        excluded = self.service.compare_similarity(tokens1, tokens2, options, language='python')
+
+        self.assertLess(padded['overall_similarity'], 1.0)
+        self.assertEqual(padded['unreachable_functions'], {'file1': [], 'file2': []})
+        self.assertEqual(excluded['overall_similarity'], 1.0)
+        self.assertEqual(excluded['unreachable_functions']['file1'], [])
+        self.assertEqual(excluded['unreachable_functions']['file2'],
+                         [{'name': 'sort_items', 'start': 6, 'end': 7, 'tokens': len(padding)}])
+
+    def test_compare_similarity_kgram_jaccard(self):
+        """Test the k-gram Jaccard metric, order insensitive and defined for files shorter than k tokens."""
+        def statement(name, row):
+            return [
+                {'type': 'expression_statement', 'text': f'{name}()', 'start': row, 'end': row},
+                {'type': 'call', 'text': f'{name}()', 'start': row, 'end': row},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row}
+            ]
+
+        tokens1 = statement('load', 0) + statement('parse', 1) + statement('render', 2)
+        # Same statements in another order, plus one of its own
+        tokens2 = statement('render', 0) + statement('load', 1) + statement('parse', 2) + statement('save', 3)
+        options = DetectionOptionsDto(metric='kgram_jaccard', jaccard_kgram_size=3)
+
+        result = self.service.compare_similarity(tokens1, tokens2, options)
+
+        self.assertEqual(result['metric'], 'kgram_jaccard')
+        self.assertEqual(result['kgram_size'], 3)
+        self.assertEqual(result['too_short'], {'file1': False, 'file2': False})
+        # The three statements are common k-grams, among the 7 of tokens1 and the 10 of tokens2
+        self.assertEqual(result['common_elements'], 5)
+        self.assertEqual(result['overall_similarity'], round(5 / 12, 4))
+        self.assertEqual(self.service.compare_similarity(tokens1, tokens1, options)['overall_similarity'], 1.0)
+        self.assertEqual(self.service.compare_similarity(tokens1, tokens2)['metric'], 'weighted')
+
+        # Empty files and files shorter than k tokens score 0
+        for short in ([], tokens1[:2]):
+            with self.subTest(tokens=len(short)):
+                too_short = self.service.compare_similarity(short, short, options)
+                self.assertEqual(too_short['overall_similarity'], 0.0)
+                self.assertEqual(too_short['too_short'], {'file1': True, 'file2': True})
+
+    def test_compare_run_tfidf_cosine(self):
+        """Test that the boilerplate shared by the whole run is down-weighted, the IDF model being returned."""
+        def statement(name, row):
+            return [
+                {'type': 'if_statement', 'text': f'if {name} != nil', 'start': row, 'end': row},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row},
+                {'type': 'return_statement', 'text': f'return {name}', 'start': row, 'end': row}
+            ]
+
+        boilerplate = statement('err', 0) + statement('err', 1)
+        documents = {
+            'a.go': boilerplate + statement('total', 2) + statement('count', 3),
+            'b.go': boilerplate + statement('total', 2) + statement('count', 3) + statement('other', 4),
+            'c.go': boilerplate + statement('width', 2),
+            'd.go': boilerplate + statement('height', 2),
+        }
+        options = DetectionOptionsDto(metric=SimilarityMetric.TFIDF_COSINE, tfidf_ngram_size=2)
+
+        run = self.service.compare_run(documents, options)
+        scores = {(p['document1'], p['document2']): p['overall_similarity'] for p in run['pairs']}
+
+        self.assertEqual(run['metric'], 'tfidf_cosine')
+        self.assertEqual(len(run['pairs']), 6)
+        self.assertEqual(run['run_metadata']['tfidf_model']['document_count'], 4)
+        self.assertEqual(run['run_metadata']['tfidf_model']['ngram_size'], 2)
+        # The pair sharing its own code scores first, the boilerplate weighing less across the run than in the pair
+        self.assertEqual((run['pairs'][0]['document1'], run['pairs'][0]['document2']), ('a.go', 'b.go'))
+        pair_only = self.service.compare_similarity(documents['c.go'], documents['d.go'], options)
+        self.assertLess(scores[('c.go', 'd.go')], pair_only['overall_similarity'])
+
+        # The serialized model reproduces the scores
+        model = TfidfModel.from_dict(run['run_metadata']['tfidf_model'])
+        pair = self.service.compare_similarity(documents['c.go'], documents['d.go'], options, tfidf_model=model)
+        self.assertEqual(pair['overall_similarity'], scores[('c.go', 'd.go')])
+        self.assertEqual(pair['metric'], 'tfidf_cosine')
+        self.assertIsNone(self.service.compare_run(documents)['run_metadata']['tfidf_model'])
+
+    def test_compare_similarity_token_matches(self):
+        """Test that copied blocks are located in both files, comments ignored never matching."""
+        def statement(name, row):
+            return [
+                {'type': 'expression_statement', 'text': f'{name}()', 'start': row, 'end': row,
+                 'start_column': 4, 'end_column': 6 + len(name)},
+                {'type': 'call', 'text': f'{name}()', 'start': row, 'end': row,
+                 'start_column': 4, 'end_column': 6 + len(name)},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row,
+                 'start_column': 4, 'end_column': 4 + len(name)}
+            ]
+
+        def comment(row):
+            return {'type': 'line_comment', 'text': '// shared note', 'start': row, 'end': row,
+                    'start_column': 4, 'end_column': 18}
+
+        tokens1 = [comment(0), comment(1), comment(2)] + statement('load', 3) + statement('parse', 4)
+        # The copied statements moved further down, after code of its own
+        tokens2 = ([comment(0), comment(1), comment(2)] + statement('other', 3) + statement('load', 4)
+                   + statement('parse', 5))
+
+        result = self.service.compare_similarity(
+            tokens1, tokens2, DetectionOptionsDto(ignore_comments=True, min_match_tokens=3)
+        )
+
+        self.assertEqual(result['matches'], [{
+            'file1': {'file': None, 'start_line': 3, 'start_column': 4, 'end_line': 4, 'end_column': 11},
+            'file2': {'file': None, 'start_line': 4, 'start_column': 4, 'end_line': 5, 'end_column': 11},
+            'tokens': 6,
+        }])
+        # Without ignore_comments, the comments are part of the matches
+        with_comments = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(min_match_tokens=3))
+        self.assertEqual([m['file1']['start_line'] for m in with_comments['matches']], [0, 3])
+        self.assertEqual(self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto())['matches'], [])
+
+    def test_compare_similarity_fragments(self):
+        """Test that fragments keep the original positions of normalized tokens, short ones being dropped."""
+        def assignment(name, value, row):
+            return [
+                {'type': 'assignment', 'text': f'{name} = {value}', 'start': row, 'end': row,
+                 'start_column': 0, 'end_column': 3 + len(name) + len(value)},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row,
+                 'start_column': 0, 'end_column': len(name)},
+                {'type': 'integer', 'text': value, 'start': row, 'end': row,
+                 'start_column': 3 + len(name), 'end_column': 3 + len(name) + len(value)},
+            ]
+
+        tokens1 = assignment('total', '1', 0) + assignment('amount', '2', 1)
+        # Renamed variables and changed literals, followed by code of its own
+        tokens2 = assignment('other', '9', 0) + assignment('price', '10', 1) + assignment('n', '20', 2)
+        options = DetectionOptionsDto(
+            normalize_identifiers=True, normalize_literals=True, min_match_tokens=3, min_fragment_tokens=6
+        )
+
+        result = self.service.compare_similarity(tokens1, tokens2, options)
+
+        self.assertEqual(len(result['fragments']), 1)
+        fragment = result['fragments'][0]
+        # Positions of the original tokens, not of their placeholders
+        self.assertEqual(fragment['left'], {
+            'file': None, 'start_line': 0, 'start_column': 0, 'end_line': 1, 'end_column': 10
+        })
+        self.assertEqual(fragment['right'], fragment['left'])
+        self.assertEqual((fragment['tokens'], fragment['similarity']), (6, 1.0))
+        options.min_fragment_tokens = 7
+        self.assertEqual(self.service.compare_similarity(tokens1, tokens2, options)['fragments'], [])
+
+    def test_compare_similarity_file_breakdown(self):
+        """Test that the matches are broken down by file, the aggregated score replacing the metric one on demand."""
+        def assignments(file, names):
+            return [
+                {'type': 'assignment', 'text': f'{name} = {name} + 1', 'start': row, 'end': row, 'file': file}
+                for row, name in enumerate(names)
+            ]
+
+        tokens1 = assignments('a.py', ['total', 'amount', 'price', 'rate']) + assignments('b.py', ['left', 'right'])
+        tokens2 = assignments('c.py', ['total', 'amount', 'price', 'rate'])
+        options = DetectionOptionsDto(min_match_tokens=2)
+
+        result = self.service.compare_similarity(tokens1, tokens2, options)
+
+        breakdown = result['file_similarities']
+        self.assertEqual(breakdown['pairs'], [
+            {'file1': 'a.py', 'file2': 'c.py', 'matched_tokens': 4, 'similarity': 1.0}
+        ])
+        self.assertEqual(breakdown['files1'][1]['file'], 'b.py')
+        self.assertIsNone(breakdown['files1'][1]['best_match'])
+        self.assertNotIn('metric_similarity', result)
+
+        options.aggregate_file_scores = True
+        aggregated = self.service.compare_similarity(tokens1, tokens2, options)
+
+        # (4 * 1.0 + 2 * 0 + 4 * 1.0) / 10
+        self.assertEqual(aggregated['overall_similarity'], 0.8)
+        self.assertEqual(aggregated['metric_similarity'], result['overall_similarity'])
+
+    def test_compare_similarity_greedy_string_tiling(self):
+        """Test the greedy string tiling metric: coverage of both files by the tiles, located in both files."""
+        def statement(name, row):
+            return [
+                {'type': 'expression_statement', 'text': f'{name}()', 'start': row, 'end': row},
+                {'type': 'call', 'text': f'{name}()', 'start': row, 'end': row},
+                {'type': 'identifier', 'text': name, 'start': row, 'end': row}
+            ]
+
+        tokens1 = statement('load', 0) + statement('parse', 1) + statement('render', 2)
+        # The first two statements moved after the third one, plus one of its own
+        tokens2 = statement('render', 0) + statement('save', 1) + statement('load', 2) + statement('parse', 3)
+        options = DetectionOptionsDto(metric=SimilarityMetric.GREEDY_STRING_TILING, min_tile_length=3)
+
+        result = self.service.compare_similarity(tokens1, tokens2, options)
+
+        self.assertEqual(result['metric'], 'greedy_string_tiling')
+        self.assertEqual(result['coverage'], {'file1': 1.0, 'file2': 0.75})
+        self.assertEqual(result['overall_similarity'], round(18 / 21, 4))
+        self.assertEqual([(t['file1']['start_line'], t['file2']['start_line'], t['tokens']) for t in result['tiles']],
+                         [(0, 2, 6), (2, 0, 3)])
+        self.assertEqual(
+            self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(
+                metric=SimilarityMetric.GREEDY_STRING_TILING, min_tile_length=7
+            ))['overall_similarity'],
+            0.0
+        )
+
 
 class TestSimilarityDetectionServiceIntegration(unittest.TestCase):
     """Integration tests for SimilarityDetectionService with realistic scenarios."""
@@ -469,5 +1006,227 @@ class TestSimilarityDetectionServiceIntegration(unittest.TestCase):
         self.assertEqual(shared_blocks['total_shared_blocks'], 0)
         self.assertEqual(shared_blocks['average_similarity'], 0.0)
 
+    def test_php_html_excluded_from_similarity(self):
+        """Test that the HTML around PHP regions does not contribute to the similarity."""
+        tokenization_service = TokenizationService()
+        code = "<?php\n$total = 0;\nforeach ($items as $item) {\n    $total += $item;\n}\necho $total;\n?>\n"
+        page1 = "<html><body>\n<h1>Invoice</h1>\n" + code + "<footer>Shop</footer></body></html>\n"
+        page2 = "<!DOCTYPE html>\n<html>\n<head><title>Totals</title></head>\n<body>\n" + code + "<p>Done</p>\n</body>\n</html>\n"
+
+        tokens1 = tokenization_service.tokenize(page1, Path("invoice.php"))
+        tokens2 = tokenization_service.tokenize(page2, Path("totals.php"))
+
+        self.assertGreater(len(tokens1), 0)
+        self.assertEqual([t['text'] for t in tokens1], [t['text'] for t in tokens2])
+
+        similarity = self.service.compare_similarity(tokens1, tokens2)
+        self.assertEqual(similarity['jaccard_similarity'], 1.0)
+        self.assertEqual(similarity['overall_similarity'], 1.0)
+
+    def test_ignore_comments_python_sources(self):
+        """Test that two Python files differing only in comments and docstrings reach 100% similarity."""
+        tokenization_service = TokenizationService()
+        commented = (
+            '#!/usr/bin/env python3\n'
+            '"""Geometry helpers."""\n'
+            'import math\n\n\n'
+            'def area(radius):\n'
+            '    """Area of a circle."""\n'
+            '    # pi times the squared radius\n'
+            '    return math.pi * radius ** 2  # formula\n\n\n'
+            'class Shape:\n'
+            '    """A named shape."""\n\n'
+            '    def __init__(self, name):\n'
+            '        self.name = name  # kept for the report\n'
+        )
+        plain = (
+            'import math\n\n\n'
+            'def area(radius):\n'
+            '    return math.pi * radius ** 2\n\n\n'
+            'class Shape:\n\n'
+            '    def __init__(self, name):\n'
+            '        self.name = name\n'
+        )
+
+        tokens1 = tokenization_service.tokenize(commented, Path("shapes.py"))
+        tokens2 = tokenization_service.tokenize(plain, Path("shapes_copy.py"))
+
+        options = DetectionOptionsDto(ignore_comments=True, docstrings_as_comments=True)
+        similarity = self.service.compare_similarity(tokens1, tokens2, options)
+        self.assertEqual(similarity['jaccard_similarity'], 1.0)
+        self.assertEqual(similarity['overall_similarity'], 1.0)
+
+        # Without the docstring sub-option the docstrings still take part in the comparison
+        comments_only = self.service.compare_similarity(tokens1, tokens2, DetectionOptionsDto(ignore_comments=True))
+        self.assertLess(comments_only['overall_similarity'], 1.0)
+
+    def test_ignore_imports_per_language(self):
+        """Test that the import statements of each language are removed from the token stream."""
+        tokenization_service = TokenizationService()
+        # Source and first line of code after the imports, per file
+        sources = {
+            "main.go": (
+                'package main\n\nimport (\n\t"fmt"\n\t"os"\n)\n\nfunc main() {\n\tfmt.Println(os.Args)\n}\n', 7
+            ),
+            "main.py": ("import os\nfrom sys import argv as args\n\nprint(os.getcwd(), args)\n", 3),
+            "Main.java": ("import java.util.List;\n\nclass Main {\n    List<String> names;\n}\n", 2),
+            "main.c": ("#include <stdio.h>\n#include \"util.h\"\n\nint main(void) {\n    return 0;\n}\n", 3),
+            "main.js": ("import path from 'path';\nconst fs = require('fs');\n\nconsole.log(path.sep);\n", 3),
+        }
+
+        for file_name, (source, code_line) in sources.items():
+            with self.subTest(file=file_name):
+                tokens = tokenization_service.tokenize(source, Path(file_name))
+                kept = self.service.remove_imports(tokens)
+
+                self.assertLess(len(kept), len(tokens))
+                kept_types = {t['type'] for t in kept}
+                self.assertFalse(kept_types & {'import_declaration', 'import_statement', 'import_from_statement',
+                                               'preproc_include', 'import_spec', 'system_lib_string'})
+                self.assertNotIn('require', [t['text'] for t in kept])
+                # The code after the imports is kept
+                self.assertTrue(any(t['start'] == code_line for t in kept))
+
+    def test_exclude_unreachable_padding_python(self):
+        """Test that copied code padded with functions never called is detected once they are excluded."""
+        tokenization_service = TokenizationService()
+        original = (
+            'def area(radius):\n'
+            '    return 3.14 * radius * radius\n\n\n'
+            'def main():\n'
+            '    print(area(2))\n\n\n'
+            'if __name__ == "__main__":\n'
+            '    main()\n'
+        )
+        padded = original + (
+            '\n\ndef bubble_sort(items):\n'
+            '    for i in range(len(items)):\n'
+            '        for j in range(len(items) - i - 1):\n'
+            '            if items[j] > items[j + 1]:\n'
+            '                items[j], items[j + 1] = items[j + 1], items[j]\n'
+            '    return items\n'
+        )
+
+        tokens1 = tokenization_service.tokenize(original, Path("circle.py"))
+        tokens2 = tokenization_service.tokenize(padded, Path("circle_copy.py"))
+
+        plain = self.service.compare_similarity(tokens1, tokens2, language="python")
+        options = DetectionOptionsDto(exclude_unreachable_functions=True)
+        excluded = self.service.compare_similarity(tokens1, tokens2, options, language="python")
+
+        self.assertEqual(excluded['unreachable_functions']['file1'], [])
+        self.assertEqual([f['name'] for f in excluded['unreachable_functions']['file2']], ['bubble_sort'])
+        self.assertGreater(excluded['overall_similarity'], plain['overall_similarity'])
+
+    def test_normalize_identifiers_renamed_go_sample(self):
+        """Test that a copy of sample.go with every identifier renamed is detected in normalized mode."""
+        tokenization_service = TokenizationService()
+        samples_dir = Path(__file__).parent.parent.parent.parent / "resources" / "test" / "language_samples"
+        original_path = samples_dir / "sample.go"
+        renamed_path = samples_dir / "sample_renamed.go"
+
+        tokens1 = tokenization_service.tokenize(original_path.read_text(encoding="utf-8"), original_path)
+        tokens2 = tokenization_service.tokenize(renamed_path.read_text(encoding="utf-8"), renamed_path)
+
+        plain = self.service.compare_similarity(tokens1, tokens2, language="go")
+        normalized = self.service.compare_similarity(
+            tokens1, tokens2, DetectionOptionsDto(normalize_identifiers=True), language="go"
+        )
+
+        self.assertGreaterEqual(normalized['jaccard_similarity'], 0.95)
+        self.assertGreaterEqual(normalized['overall_similarity'], 0.95)
+        self.assertLess(plain['jaccard_similarity'], 0.75)
+        self.assertLess(plain['overall_similarity'], normalized['overall_similarity'])
+
+    def test_winnowed_fingerprints_language_samples(self):
+        """Test that winnowing the samples keeps the exhaustive fingerprint similarity with far fewer fingerprints."""
+        tokenization_service = TokenizationService()
+        samples_dir = Path(__file__).parent.parent.parent.parent / "resources" / "test" / "language_samples"
+        exhaustive = DetectionOptionsDto(fingerprint_window_size=1)
+
+        for file_name in ["sample.py", "sample.go", "sample.java", "sample.js", "sample.rs", "sample.cpp"]:
+            with self.subTest(file=file_name):
+                path = samples_dir / file_name
+                source = path.read_text(encoding="utf-8")
+                # The copy keeps the first two thirds of the sample
+                lines = source.splitlines(keepends=True)
+                tokens1 = tokenization_service.tokenize(source, path)
+                tokens2 = tokenization_service.tokenize("".join(lines[: len(lines) * 2 // 3]), path)
+
+                all_kgrams = self.service.compare_similarity(tokens1, tokens2, exhaustive)
+                winnowed = self.service.compare_similarity(tokens1, tokens2)
+
+                self.assertAlmostEqual(
+                    winnowed['fingerprint_similarity'], all_kgrams['fingerprint_similarity'], delta=0.1
+                )
+                self.assertGreater(winnowed['fingerprint_similarity'], 0.3)
+                for side in ('file1', 'file2'):
+                    self.assertLess(
+                        len(winnowed['fingerprints'][side]), len(all_kgrams['fingerprints'][side]) * 0.6
+                    )
+
+    def test_structural_go_refactored_sample(self):
+        """Test that sample.go with reordered functions, reordered struct fields and a split worker scores high."""
+        tokenization_service = TokenizationService()
+        samples_dir = Path(__file__).parent.parent.parent.parent / "resources" / "test" / "language_samples"
+        original_path = samples_dir / "sample.go"
+        refactored_path = samples_dir / "sample_refactored.go"
+
+        result = self.service.compare_go_structure(
+            original_path.read_text(encoding="utf-8"),
+            refactored_path.read_text(encoding="utf-8"),
+            tokenization_service,
+            DetectionOptionsDto(metric=SimilarityMetric.STRUCTURAL),
+            original_path,
+            refactored_path,
+        )
+
+        self.assertEqual(result['metric'], 'structural')
+        self.assertEqual(result['warnings'], [])
+        self.assertGreaterEqual(result['overall_similarity'], 0.85)
+        matches = {m['unit1']: (m['unit2'], m['similarity']) for m in result['function_matches']}
+        self.assertEqual(matches['main'], ('main', 1.0))
+        self.assertEqual(matches['Person'], ('Person', 1.0))
+        self.assertEqual(matches['Map'], ('Map', 1.0))
+        self.assertEqual(matches['worker'][0], 'worker')
+        self.assertLess(matches['worker'][1], 1.0)
+
+    def test_structural_go_parse_error_fallback(self):
+        """Test that a file that does not parse is compared in token mode with a warning instead of failing."""
+        tokenization_service = TokenizationService()
+        valid = 'package main\n\nfunc main() {\n\tprintln("ok")\n}\n'
+        broken = 'package main\n\nfunc main( {\n\tprintln("ok"\n'
+
+        result = self.service.compare_go_structure(valid, broken, tokenization_service)
+
+        self.assertEqual(result['metric'], 'weighted')
+        self.assertEqual(len(result['warnings']), 1)
+        self.assertIn('file2', result['warnings'][0])
+        self.assertIn('overall_similarity', result)
+
+    def test_cross_language_worker_pool_samples(self):
+        """Test that the Python transliteration of the Go worker pool sample is found across languages."""
+        tokenization_service = TokenizationService()
+        samples_dir = Path(__file__).parent.parent.parent.parent / "resources" / "test" / "language_samples"
+        tokens = {
+            name: tokenization_service.tokenize((samples_dir / name).read_text(encoding="utf-8"), samples_dir / name)
+            for name in ("sample_worker_pool.go", "sample_worker_pool.py", "sample.py")
+        }
+        options = DetectionOptionsDto(cross_language=True)
+
+        transliterated = self.service.compare_cross_language(
+            tokens["sample_worker_pool.go"], tokens["sample_worker_pool.py"], "go", "python", options
+        )
+        unrelated = self.service.compare_cross_language(
+            tokens["sample_worker_pool.go"], tokens["sample.py"], "go", "python", options
+        )
+        per_language = self.service.compare_similarity(tokens["sample_worker_pool.go"], tokens["sample_worker_pool.py"])
+
+        self.assertEqual(transliterated['cross_language']['languages'], {'file1': 'go', 'file2': 'python'})
+        self.assertEqual(transliterated['cross_language']['confidence'], 'lower')
+        self.assertGreater(transliterated['fingerprint_similarity'], unrelated['fingerprint_similarity'])
+        self.assertGreater(transliterated['overall_similarity'], unrelated['overall_similarity'])
+        self.assertGreater(transliterated['fingerprint_similarity'], per_language['fingerprint_similarity'])
+
 if __name__ == '__main__':
     unittest.main()
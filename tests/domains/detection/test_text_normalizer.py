@@ -0,0 +1,52 @@
+"""
+Tests for TextNormalizer
+"""
+
+import unittest
+
+from app.domains.detection.text_normalizer import TextNormalizer
+
+
+class TestTextNormalizer(unittest.TestCase):
+    """Unit tests for the normalization of files compared without tokenizer."""
+
+    def test_whitespace_and_line_endings(self):
+        """Test that line endings, indentation, trailing whitespace and runs of spaces are normalized."""
+        normalized = TextNormalizer().normalize("SELECT  *\r\n\t\tFROM users   \rWHERE id =\t1\n")
+
+        self.assertEqual(normalized.text, "SELECT *\nFROM users\nWHERE id = 1\n")
+
+    def test_reformatted_texts_identical(self):
+        """Test that re-indented and reformatted copies normalize to the same text."""
+        normalizer = TextNormalizer()
+        original = normalizer.normalize("procedure Main is\n    Count : Integer := 0;\nbegin\n    null;\nend Main;\n")
+        reformatted = normalizer.normalize(
+            "procedure Main is  \r\n  Count :   Integer := 0;\r\nbegin\r\n\tnull;\r\nend Main;\r\n"
+        )
+
+        self.assertEqual(original.text, reformatted.text)
+
+    def test_lowercase(self):
+        """Test that the text is only lowercased when requested."""
+        self.assertEqual(TextNormalizer().normalize("BEGIN End").text, "BEGIN End")
+        self.assertEqual(TextNormalizer(lowercase=True).normalize("BEGIN End").text, "begin end")
+
+    def test_original_offsets(self):
+        """Test that normalized positions map back to the byte offsets and lines of the original text."""
+        content = "first line\r\n    café   au lait  \nlast"
+        normalized = TextNormalizer().normalize(content)
+        source = content.encode("utf8")
+
+        start = normalized.text.index("café au")
+        start_byte, end_byte = normalized.original_span(start, start + len("café au"))
+        self.assertEqual(source[start_byte:end_byte].decode("utf8"), "café   au")
+        self.assertEqual(normalized.line_of(start_byte), 1)
+
+        last = normalized.text.index("last")
+        start_byte, _ = normalized.original_span(last, last + 4)
+        self.assertEqual(normalized.line_of(start_byte), 2)
+        self.assertEqual(source[start_byte:].decode("utf8"), "last")
+
+
+if __name__ == '__main__':
+    unittest.main()
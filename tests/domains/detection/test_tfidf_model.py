@@ -0,0 +1,54 @@
+"""
+Tests for TfidfModel
+"""
+
+import unittest
+
+from app.domains.detection.tfidf_model import TfidfModel
+
+
+class TestTfidfModel(unittest.TestCase):
+    """Unit tests for the IDF model of the n-grams of a detection run."""
+
+    def _parts(self, words):
+        return [f'identifier:{word}' for word in words.split()]
+
+    def test_shared_ngrams_down_weighted(self):
+        """Test that the n-grams of every document weigh less than those of a few documents."""
+        documents = [self._parts('err nil check a'), self._parts('err nil check b'), self._parts('x y z')]
+        model = TfidfModel.fit(documents, ngram_size=2)
+
+        vector = model.vector(self._parts('err nil z'))
+        common = model._key(self._parts('err nil'))
+
+        self.assertEqual(model.document_count, 3)
+        self.assertLess(model.idf[common], model.idf[model._key(self._parts('check a'))])
+        self.assertLess(vector[common], vector[model._key(self._parts('nil z'))])
+
+    def test_cosine(self):
+        """Test the cosine of identical, disjoint and empty vectors."""
+        model = TfidfModel.fit([self._parts('a b c'), self._parts('d e f')], ngram_size=2)
+
+        abc = model.vector(self._parts('a b c'))
+
+        self.assertAlmostEqual(TfidfModel.cosine(abc, abc), 1.0)
+        self.assertEqual(TfidfModel.cosine(abc, model.vector(self._parts('d e f'))), 0.0)
+        # Too short for an n-gram
+        self.assertEqual(TfidfModel.cosine(model.vector(self._parts('a')), model.vector(self._parts('a'))), 0.0)
+
+    def test_serialized_model_reproduces_scores(self):
+        """Test that the model serialized in the run metadata gives back the same scores."""
+        documents = [self._parts('a b c d'), self._parts('a b e f'), self._parts('a b c g')]
+        model = TfidfModel.fit(documents, ngram_size=2)
+        restored = TfidfModel.from_dict(model.to_dict())
+
+        self.assertEqual(restored.to_dict(), model.to_dict())
+        self.assertAlmostEqual(
+            TfidfModel.cosine(model.vector(documents[0]), model.vector(documents[2])),
+            TfidfModel.cosine(restored.vector(documents[0]), restored.vector(documents[2])),
+            places=4
+        )
+
+
+if __name__ == '__main__':
+    unittest.main()
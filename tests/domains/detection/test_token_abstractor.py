@@ -0,0 +1,79 @@
+"""
+Tests for TokenAbstractor
+"""
+
+import unittest
+
+from app.domains.detection.token_abstractor import TokenAbstractor
+
+
+class TestTokenAbstractor(unittest.TestCase):
+    """Unit tests for the abstract token categories shared by every language."""
+
+    def setUp(self):
+        self.abstractor = TokenAbstractor()
+
+    def _token(self, token_type, start, start_column, end, end_column, file=None):
+        token = {'type': token_type, 'text': '', 'start': start, 'end': end,
+                 'start_column': start_column, 'end_column': end_column}
+        if file:
+            token['file'] = file
+        return token
+
+    def _go_condition(self, row=0, file=None):
+        """if x > limit {\\n\\treturn x\\n}"""
+        return [
+            self._token('if_statement', row, 0, row + 2, 1, file),
+            self._token('binary_expression', row, 3, row, 12, file),
+            self._token('identifier', row, 3, row, 4, file),
+            self._token('identifier', row, 7, row, 12, file),
+            self._token('block', row, 13, row + 2, 1, file),
+            self._token('return_statement', row + 1, 1, row + 1, 9, file),
+            self._token('expression_list', row + 1, 8, row + 1, 9, file),
+            self._token('identifier', row + 1, 8, row + 1, 9, file),
+        ]
+
+    def test_transliterated_condition(self):
+        """Test that the same condition in Go and in Python gives the same abstract stream."""
+        # if x > limit:\n    return x
+        python_tokens = [
+            self._token('if_statement', 0, 0, 1, 12),
+            self._token('comparison_operator', 0, 3, 0, 12),
+            self._token('identifier', 0, 3, 0, 4),
+            self._token('identifier', 0, 7, 0, 12),
+            self._token('block', 1, 4, 1, 12),
+            self._token('return_statement', 1, 4, 1, 12),
+            self._token('identifier', 1, 11, 1, 12),
+        ]
+
+        go_stream = [t['type'] for t in self.abstractor.abstract(self._go_condition())]
+        python_stream = [t['type'] for t in self.abstractor.abstract(python_tokens)]
+
+        self.assertEqual(go_stream, ['KEYWORD_COND', 'OP_BINARY', 'IDENT', 'IDENT', 'BLOCK_OPEN', 'KEYWORD_RETURN',
+                                     'IDENT', 'BLOCK_CLOSE'])
+        self.assertEqual(python_stream, go_stream)
+
+    def test_blocks_closed_before_following_tokens(self):
+        """Test that a block is closed before the tokens following it, at the end of the block."""
+        tokens = self._go_condition() + [self._token('call_expression', 3, 0, 3, 6)]
+
+        abstract_tokens = self.abstractor.abstract(tokens)
+
+        self.assertEqual([t['type'] for t in abstract_tokens[-2:]], ['BLOCK_CLOSE', 'CALL'])
+        self.assertEqual((abstract_tokens[-2]['start'], abstract_tokens[-2]['start_column']), (2, 1))
+        self.assertEqual(abstract_tokens[0]['start'], 0)
+
+    def test_blocks_closed_at_file_change(self):
+        """Test that the blocks of a file are closed before the tokens of the next file of a submission."""
+        # The second file starts on a line the block of the first one spans
+        tokens = self._go_condition(file='a.go')[:5] + self._go_condition(row=1, file='b.go')
+
+        abstract_tokens = self.abstractor.abstract(tokens)
+
+        self.assertEqual([t['type'] for t in abstract_tokens[4:6]], ['BLOCK_OPEN', 'BLOCK_CLOSE'])
+        self.assertEqual(abstract_tokens[5]['file'], 'a.go')
+        self.assertEqual(abstract_tokens[6]['file'], 'b.go')
+
+
+if __name__ == '__main__':
+    unittest.main()
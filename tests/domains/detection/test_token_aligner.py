@@ -0,0 +1,90 @@
+"""
+Tests for TokenAligner
+"""
+
+import unittest
+
+from app.domains.detection.token_aligner import TokenAligner
+
+
+class TestTokenAligner(unittest.TestCase):
+    """Unit tests for the token-level alignment of two files and its pages."""
+
+    def setUp(self):
+        self.aligner = TokenAligner()
+
+    def _tokens(self, parts):
+        """One token per line, three columns wide"""
+        return [
+            {'type': 'identifier', 'text': part, 'start': row, 'end': row, 'start_column': 0, 'end_column': 3}
+            for row, part in enumerate(parts, start=1)
+        ]
+
+    def _align(self, parts1, parts2):
+        return self.aligner.align(parts1, parts2, self._tokens(parts1), self._tokens(parts2))
+
+    def test_identical_files(self):
+        """Test that identical streams are a single match over both files."""
+        operations = self._align(['a', 'b', 'c'], ['a', 'b', 'c'])
+
+        self.assertEqual([operation['operation'] for operation in operations], ['match'])
+        self.assertEqual(operations[0]['token_count'], 3)
+        self.assertEqual(
+            operations[0]['file1'],
+            {'start_index': 0, 'end_index': 3, 'start_line': 1, 'start_column': 0, 'end_line': 3, 'end_column': 3},
+        )
+
+    def test_replacement_split(self):
+        """Test that a replaced run is a deletion from the first file, then an insertion into the second."""
+        operations = self._align(['a', 'b', 'c'], ['a', 'x', 'y', 'c'])
+
+        self.assertEqual(
+            [(operation['operation'], operation['tokens']) for operation in operations],
+            [('match', ['a']), ('deletion', ['b']), ('insertion', ['x', 'y']), ('match', ['c'])],
+        )
+        deletion, insertion = operations[1], operations[2]
+        self.assertEqual(deletion['file2'], {'start_index': 1, 'end_index': 1})
+        self.assertEqual(insertion['file1'], {'start_index': 2, 'end_index': 2})
+        self.assertEqual(insertion['file2']['start_line'], 2)
+        self.assertEqual(insertion['file2']['end_line'], 3)
+
+    def test_summary(self):
+        """Test that the summary counts the tokens of each operation and the share matched."""
+        operations = self._align(['a', 'b', 'c'], ['a', 'x', 'y', 'c'])
+
+        summary = TokenAligner.summary(operations, 3, 4)
+
+        self.assertEqual(summary['matched_token_count'], 2)
+        self.assertEqual(summary['deleted_token_count'], 1)
+        self.assertEqual(summary['inserted_token_count'], 2)
+        self.assertEqual(summary['matched_share'], round(4 / 7, 4))
+
+    def test_pages(self):
+        """Test that the cursor of a page serves the next operations until the last one."""
+        parts1, parts2 = ['a', 'b', 'c', 'd', 'e'], ['a', 'x', 'c', 'y', 'e']
+        operations = self._align(parts1, parts2)
+        digest = TokenAligner.digest(parts1, parts2)
+
+        first = self.aligner.page(operations, digest, limit=4)
+        second = self.aligner.page(operations, digest, first['next_cursor'], limit=4)
+
+        self.assertTrue(first['truncated'])
+        self.assertEqual(first['operations'] + second['operations'], operations)
+        self.assertEqual(second['operation_offset'], 4)
+        self.assertFalse(second['truncated'])
+        self.assertIsNone(second['next_cursor'])
+
+    def test_cursor_of_other_files_refused(self):
+        """Test that a cursor is refused once the compared streams differ, and a malformed one too."""
+        operations = self._align(['a', 'b', 'c'], ['a', 'x', 'c'])
+        digest = TokenAligner.digest(['a', 'b', 'c'], ['a', 'x', 'c'])
+        cursor = self.aligner.page(operations, digest, limit=1)['next_cursor']
+
+        with self.assertRaises(ValueError):
+            self.aligner.page(operations, TokenAligner.digest(['a', 'b', 'c'], ['a', 'z', 'c']), cursor)
+        with self.assertRaises(ValueError):
+            self.aligner.page(operations, TokenAligner.digest(['a'], ['a']), 'not-a-cursor')
+
+
+if __name__ == '__main__':
+    unittest.main()
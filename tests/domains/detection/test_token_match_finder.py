@@ -0,0 +1,102 @@
+"""
+Tests for TokenMatchFinder
+"""
+
+import unittest
+
+from app.domains.detection.token_match_finder import TokenMatchFinder
+
+
+class TestTokenMatchFinder(unittest.TestCase):
+    """Unit tests for the maximal matching token runs and their positions."""
+
+    def setUp(self):
+        self.finder = TokenMatchFinder(min_length=3)
+
+    def _tokens(self, words, file=None):
+        """One token per word, each on its own line, its columns giving the length of the word"""
+        tokens = []
+        for row, word in enumerate(words.split()):
+            token = {'type': 'identifier', 'text': word, 'start': row, 'end': row,
+                     'start_column': 4, 'end_column': 4 + len(word)}
+            if file:
+                token['file'] = file
+            tokens.append(token)
+        return tokens
+
+    def test_reordered_blocks_found(self):
+        """Test that blocks moved elsewhere in the other file are found, longest first."""
+        parts1 = 'a b c d e x y z w q'.split()
+        parts2 = 'x y z w k a b c d e'.split()
+
+        self.assertEqual(self.finder.find(parts1, parts2), [(0, 5, 5), (5, 0, 4)])
+        # A run shorter than the minimum length is not reported
+        self.assertEqual(TokenMatchFinder(min_length=6).find(parts1, parts2), [])
+        self.assertEqual(self.finder.find(['a', 'b'], ['a', 'b']), [])
+
+    def test_tokens_matched_once(self):
+        """Test that the tokens of a run are not matched again by a shorter run."""
+        tiles = self.finder.find('a b c d a b c'.split(), 'a b c d'.split())
+
+        self.assertEqual(tiles, [(0, 0, 4)])
+
+    def test_positions(self):
+        """Test that a match spans from the start of its first token to the end of its last one."""
+        tokens1 = self._tokens('p a b c d', 'main.go')
+        tokens2 = self._tokens('a b c d', 'worker.go')
+
+        matches = self.finder.locate([(1, 0, 4)], tokens1, tokens2)
+
+        self.assertEqual(matches, [{
+            'file1': {'file': 'main.go', 'start_line': 1, 'start_column': 4, 'end_line': 4, 'end_column': 5},
+            'file2': {'file': 'worker.go', 'start_line': 0, 'start_column': 4, 'end_line': 3, 'end_column': 5},
+            'tokens': 4,
+        }])
+
+    def test_overlapping_matches_merged(self):
+        """Test that matches overlapping in both files are merged, the others being kept apart."""
+        tokens = self._tokens('a b c d e f g h')
+        # A parent token spanning the lines of the tokens that follow it
+        tokens[0]['end'] = 3
+
+        matches = self.finder.locate([(0, 0, 2), (2, 2, 3), (5, 5, 3)], tokens, tokens)
+
+        self.assertEqual(len(matches), 2)
+        self.assertEqual(matches[0]['tokens'], 5)
+        self.assertEqual((matches[0]['file1']['start_line'], matches[0]['file1']['end_line']), (0, 4))
+        self.assertEqual((matches[1]['file1']['start_line'], matches[1]['file1']['end_line']), (5, 7))
+
+    def test_match_split_across_files(self):
+        """Test that a run going from a file of a submission to the next one is reported in each file."""
+        tokens1 = self._tokens('a b c', 'a.go') + self._tokens('d e f', 'b.go')
+        tokens2 = self._tokens('a b c d e f', 'all.go')
+
+        matches = self.finder.locate(self.finder.find('a b c d e f'.split(), 'a b c d e f'.split()), tokens1, tokens2)
+
+        self.assertEqual([(m['file1']['file'], m['file2']['file'], m['tokens']) for m in matches],
+                         [('a.go', 'all.go', 3), ('b.go', 'all.go', 3)])
+
+    def test_fragments(self):
+        """Test that fragments under the minimum are dropped, merged runs scoring by their share of the region."""
+        tokens = self._tokens('a b c d e f g h')
+        tokens[0]['end'] = 3
+        matches = self.finder.locate([(0, 0, 2), (2, 2, 3), (5, 5, 3)], tokens, tokens)
+        # Merged runs spanning a token matched in neither of them in the second file
+        gapped = {
+            'file1': {'file': None, 'start_line': 0, 'start_column': 4, 'end_line': 2, 'end_column': 5},
+            'file2': {'file': None, 'start_line': 0, 'start_column': 4, 'end_line': 3, 'end_column': 5},
+            'tokens': 3,
+        }
+
+        fragments = self.finder.fragments(matches, tokens, tokens, min_tokens=4)
+        gapped_fragments = self.finder.fragments([gapped], self._tokens('a b c'), self._tokens('a x b c'), 3)
+
+        self.assertEqual(len(fragments), 1)
+        self.assertEqual(fragments[0]['left'], matches[0]['file1'])
+        self.assertEqual(fragments[0]['right'], matches[0]['file2'])
+        self.assertEqual((fragments[0]['tokens'], fragments[0]['similarity']), (5, 1.0))
+        self.assertEqual(gapped_fragments[0]['similarity'], round(6 / 7, 3))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,77 @@
+"""
+Tests for Winnower
+"""
+
+import random
+import unittest
+
+from app.domains.detection.winnowing import Winnower
+
+
+class TestWinnower(unittest.TestCase):
+    """Unit tests for the winnowing selection of document fingerprints."""
+
+    def _parts(self, words):
+        return [f'identifier:{word}' for word in words.split()]
+
+    def test_select_paper_example(self):
+        """Test the selection of the example of Schleimer et al., ties broken by the rightmost position."""
+        hashes = [77, 74, 42, 17, 98, 50, 17, 98, 8, 88, 67, 39, 77, 74, 42, 17, 98]
+
+        self.assertEqual(Winnower(window_size=4).select(hashes), [3, 6, 8, 11, 15])
+        self.assertEqual(Winnower(window_size=2).select([5, 5, 5]), [1, 2])
+        self.assertEqual(Winnower(window_size=4).select([9, 3]), [1])
+        self.assertEqual(Winnower(window_size=4).select([]), [])
+
+    def test_window_of_one_is_exhaustive(self):
+        """Test that a window of size 1 keeps every k-gram hash."""
+        winnower = Winnower(kgram_size=3, window_size=1)
+        parts = self._parts('a b c d e f g')
+
+        fingerprints = winnower.fingerprint(parts)
+
+        self.assertEqual([f.position for f in fingerprints], list(range(5)))
+        self.assertEqual([f.hash for f in fingerprints], winnower.kgram_hashes(parts))
+
+    def test_shared_run_guaranteed(self):
+        """Test that a run of at least k + w - 1 shared tokens always shares a fingerprint."""
+        winnower = Winnower(kgram_size=3, window_size=4)
+        shared = ' '.join(f's{index}' for index in range(6))
+        for prefix in range(8):
+            with self.subTest(prefix=prefix):
+                parts1 = self._parts(' '.join(['x'] * prefix) + ' ' + shared + ' y z')
+                parts2 = self._parts('u v w ' + shared)
+
+                self.assertGreater(winnower.similarity(winnower.fingerprint(parts1), winnower.fingerprint(parts2)), 0)
+
+    def test_fingerprint_positions(self):
+        """Test that the fingerprints keep the position and lines of their k-gram."""
+        winnower = Winnower(kgram_size=2, window_size=2)
+        tokens = [{'type': 'identifier', 'text': word, 'start': row, 'end': row} for row, word in enumerate('abcde')]
+        parts = self._parts('a b c d e')
+
+        fingerprints = winnower.fingerprint(parts, tokens)
+
+        self.assertTrue(fingerprints)
+        for fingerprint in fingerprints:
+            self.assertEqual(fingerprint.start, fingerprint.position)
+            self.assertEqual(fingerprint.end, fingerprint.position + 1)
+        self.assertEqual(set(fingerprint.to_dict()), {'hash', 'position', 'start', 'end'})
+        self.assertEqual(winnower.similarity([], []), 1.0)
+
+    def test_fingerprint_stream(self):
+        """Test that a stream gets the fingerprints of the whole stream, short streams included."""
+        generator = random.Random(0)
+        for kgram_size, window_size, length in [(5, 4, 200), (3, 1, 50), (5, 4, 3), (2, 4, 4), (1, 1, 1), (5, 4, 0)]:
+            with self.subTest(kgram_size=kgram_size, window_size=window_size, length=length):
+                winnower = Winnower(kgram_size, window_size)
+                parts = self._parts(' '.join(generator.choice('abcd') for _ in range(length)))
+                tokens = [{'start': index // 3, 'end': index // 3 + index % 2} for index in range(length)]
+
+                streamed = list(winnower.fingerprint_stream(zip(parts, tokens)))
+
+                self.assertEqual(streamed, winnower.fingerprint(parts, tokens))
+
+
+if __name__ == '__main__':
+    unittest.main()
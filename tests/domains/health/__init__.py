@@ -0,0 +1 @@
+# Health tests module
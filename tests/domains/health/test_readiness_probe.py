@@ -0,0 +1,79 @@
+"""
+Tests for the readiness probe
+"""
+
+import unittest
+
+from app.domains.health.probes import ReadinessProbe
+
+
+class FakeClock:
+    """Clock advanced by hand"""
+
+    def __init__(self):
+        self.now = 100.0
+
+    def __call__(self):
+        return self.now
+
+
+class TestReadinessProbe(unittest.TestCase):
+    """Unit tests for the checks of the dependencies, their cache and the shutdown."""
+
+    def setUp(self):
+        self.clock = FakeClock()
+        self.calls = 0
+        self.database_down = False
+
+        def check_database():
+            self.calls += 1
+            if self.database_down:
+                raise ConnectionError('connection refused')
+
+        self.probe = ReadinessProbe(
+            {'database': check_database, 'storage': lambda: None}, cache_seconds=2.0, clock=self.clock
+        )
+
+    def test_ready_when_every_dependency_is_up(self):
+        """Test that the service is ready with each dependency up and its latency."""
+        result = self.probe.check()
+
+        self.assertEqual(result['status'], 'ready')
+        self.assertEqual(result['dependencies']['database'], {'status': 'up', 'latency_ms': 0.0})
+
+    def test_not_ready_when_a_dependency_is_down(self):
+        """Test that a failing check marks its dependency down with its error."""
+        self.database_down = True
+
+        result = self.probe.check()
+
+        self.assertEqual(result['status'], 'not_ready')
+        self.assertEqual(result['dependencies']['database']['error'], 'connection refused')
+        self.assertEqual(result['dependencies']['storage']['status'], 'up')
+
+    def test_checks_cached_for_a_short_window(self):
+        """Test that the dependencies are checked again only once the cache expires."""
+        self.probe.check()
+        self.clock.now += 1.5
+        self.database_down = True
+
+        self.assertEqual(self.probe.check()['status'], 'ready')
+        self.assertEqual(self.calls, 1)
+
+        self.clock.now += 1.0
+        self.assertEqual(self.probe.check()['status'], 'not_ready')
+        self.assertEqual(self.calls, 2)
+
+    def test_not_ready_once_shutting_down(self):
+        """Test that the service is not ready at once when the shutdown begins, whatever the cache."""
+        self.probe.check()
+
+        self.probe.mark_shutting_down()
+        result = self.probe.check()
+
+        self.assertEqual(result['status'], 'not_ready')
+        self.assertTrue(result['shutting_down'])
+
+
+if __name__ == '__main__':
+    unittest.main()
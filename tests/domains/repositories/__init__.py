@@ -0,0 +1 @@
+# Repositories tests module
@@ -0,0 +1,302 @@
+"""
+Tests for ArchiveExtractor
+"""
+
+import io
+import tarfile
+import tempfile
+import unittest
+import zipfile
+from pathlib import Path
+
+from app.domains.repositories.archive_extractor import (
+    DUPLICATE_REASON,
+    ArchiveExtractionResult,
+    ArchiveExtractor,
+    ArchiveFile,
+    ArchiveFormat,
+    ArchiveLimitExceeded,
+    case_colliding_paths,
+)
+
+RESOURCES_DIR = Path(__file__).parent.parent.parent.parent / 'resources' / 'test'
+SAMPLE_NAMES = ['go.mod', 'sample.c', 'sample.go', 'sample.java', 'sample.py']
+
+
+def _zip(entries, compression=zipfile.ZIP_STORED):
+    """ZIP archive of (name, content) entries, a name ending with / being a directory."""
+    buffer = io.BytesIO()
+    with zipfile.ZipFile(buffer, 'w', compression=compression) as archive:
+        for name, content in entries:
+            archive.writestr(name, content)
+    return buffer.getvalue()
+
+
+def _tar(entries, mode='w'):
+    """Tar archive of (TarInfo, content) entries, content being None for the entries without data."""
+    buffer = io.BytesIO()
+    with tarfile.open(fileobj=buffer, mode=mode) as archive:
+        for info, content in entries:
+            info.size = len(content) if content is not None else 0
+            archive.addfile(info, io.BytesIO(content) if content is not None else None)
+    return buffer.getvalue()
+
+
+def _tar_info(name, entry_type=tarfile.REGTYPE, linkname=''):
+    info = tarfile.TarInfo(name)
+    info.type = entry_type
+    info.linkname = linkname
+    return info
+
+
+class TestArchiveExtractor(unittest.TestCase):
+    """Unit tests for the extraction of the uploaded ZIP archives into multi-file submissions."""
+
+    def test_signature(self):
+        """Test that archives are recognized by their signature, whatever their name."""
+        self.assertTrue(ArchiveExtractor.is_zip(_zip([('main.go', 'package main')])))
+        self.assertFalse(ArchiveExtractor.is_zip(b'package main'))
+        self.assertFalse(ArchiveExtractor.is_zip(b''))
+        tar = _tar([(_tar_info('main.go'), b'package main')])
+        self.assertEqual(ArchiveExtractor.detect_format(tar), ArchiveFormat.TAR)
+        self.assertEqual(ArchiveExtractor.detect_format(_tar([], mode='w:gz')), ArchiveFormat.TAR_GZ)
+        self.assertIsNone(ArchiveExtractor.detect_format(b'package main'))
+
+    def test_paths_and_metadata(self):
+        """Test that the relative paths are preserved, directories and metadata of the systems being skipped."""
+        content = _zip([
+            ('project/', ''),
+            ('project/cmd/main.go', 'package main'),
+            ('project/.DS_Store', 'x'),
+            ('__MACOSX/project/._main.go', 'x'),
+            ('project/docs/Thumbs.db', 'x'),
+        ])
+
+        result = ArchiveExtractor().extract(content)
+
+        self.assertEqual([file.path for file in result.files], ['project/cmd/main.go'])
+        self.assertEqual(result.files[0].content, b'package main')
+        self.assertEqual({entry['reason'] for entry in result.skipped_entries}, {'operating system metadata'})
+        self.assertEqual(len(result.skipped_entries), 3)
+
+    def test_nested_archives(self):
+        """Test that nested archives are extracted one level deep, deeper archives being reported as skipped."""
+        deepest = _zip([('deep.py', 'print(1)')])
+        nested = _zip([('lib/util.py', 'x = 1'), ('vendor.zip', deepest)])
+        content = _zip([('main.py', 'import lib'), ('libs.zip', nested)])
+
+        result = ArchiveExtractor().extract(content)
+
+        files = {file.path: file.archive for file in result.files}
+        self.assertEqual(files, {'main.py': None, 'libs/lib/util.py': 'libs.zip'})
+        self.assertEqual(
+            result.skipped_entries, [{'path': 'libs/vendor.zip', 'reason': 'archive nested more than one level'}]
+        )
+
+    def test_unsafe_entries(self):
+        """Test that the entries escaping the archive and those over the size cap are skipped."""
+        content = _zip([('../evil.sh', 'rm -rf /'), ('/etc/passwd', 'x'), ('big.txt', 'x' * 100), ('ok.txt', 'ok')])
+
+        result = ArchiveExtractor(max_extracted_bytes=50).extract(content)
+
+        self.assertEqual([file.path for file in result.files], ['ok.txt'])
+        reasons = {entry['path']: entry['reason'] for entry in result.skipped_entries}
+        self.assertEqual(reasons['../evil.sh'], 'path outside of the archive')
+        self.assertIn('over 50 bytes', reasons['big.txt'])
+
+    def test_enforced_limits(self):
+        """Test that an enforced limit stops the extraction with its code and the offending entry."""
+        content = _zip([('a.txt', 'x' * 10), ('b.txt', 'x' * 30), ('c.txt', 'x' * 10)])
+        cases = [
+            (ArchiveExtractor(max_extracted_bytes=35, enforce_limits=True), 'extracted_size_exceeded', 'b.txt'),
+            (ArchiveExtractor(max_file_count=2, enforce_limits=True), 'too_many_files', 'c.txt'),
+            (ArchiveExtractor(max_file_bytes=20, enforce_limits=True), 'file_too_large', 'b.txt'),
+        ]
+
+        for extractor, code, entry in cases:
+            with self.assertRaises(ArchiveLimitExceeded) as context:
+                extractor.extract(content)
+            self.assertEqual((context.exception.code, context.exception.entry), (code, entry))
+            self.assertEqual(context.exception.to_dict()['error_type'], code)
+        self.assertEqual(len(ArchiveExtractor(max_file_count=2).extract(content).files), 3)
+
+    def test_ignored_entries(self):
+        """Test that the ignored entries are recorded, never extracted nor counted in the limits."""
+        content = _zip([('main.c', 'x' * 10), ('node_modules/a.js', 'x' * 30), ('node_modules/b.js', 'x' * 30)])
+        extractor = ArchiveExtractor(
+            max_file_count=1,
+            max_extracted_bytes=20,
+            enforce_limits=True,
+            ignored=lambda path, is_directory: path.startswith('node_modules/'),
+        )
+
+        result = extractor.extract(content)
+
+        self.assertEqual([file.path for file in result.files], ['main.c'])
+        self.assertEqual(result.ignored_paths, ['node_modules/a.js', 'node_modules/b.js'])
+
+    def test_language_samples_tarball(self):
+        """Test that a tarball made with tar czf yields the files of the language samples it was built from."""
+        content = (RESOURCES_DIR / 'archives' / 'language_samples.tar.gz').read_bytes()
+
+        result = ArchiveExtractor().extract(content)
+
+        self.assertEqual(sorted(file.path for file in result.files), [f'language_samples/{n}' for n in SAMPLE_NAMES])
+        for file in result.files:
+            self.assertEqual(file.content, (RESOURCES_DIR / file.path).read_bytes())
+        self.assertEqual(result.skipped_entries, [])
+
+    def test_same_files_whatever_the_format(self):
+        """Test that ZIP, tar and tar.gz archives of the same files produce identical files."""
+        samples_dir = RESOURCES_DIR / 'language_samples'
+        samples = [(f'samples/{name}', (samples_dir / name).read_bytes()) for name in SAMPLE_NAMES]
+        nested = _zip([('lib/util.py', 'x = 1')])
+        entries = samples + [('samples/.DS_Store', b'x'), ('vendor/lib.zip', nested)]
+
+        results = [
+            ArchiveExtractor().extract(_zip(entries)),
+            ArchiveExtractor().extract(_tar([(_tar_info(name), data) for name, data in entries])),
+            ArchiveExtractor().extract(_tar([(_tar_info(name), data) for name, data in entries], mode='w:gz')),
+        ]
+
+        expected = [(file.path, file.content, file.archive) for file in results[0].files]
+        self.assertEqual(len(expected), len(SAMPLE_NAMES) + 1)
+        for result in results[1:]:
+            self.assertEqual([(file.path, file.content, file.archive) for file in result.files], expected)
+            self.assertEqual(result.skipped_entries, results[0].skipped_entries)
+
+    def test_tar_unsafe_entries(self):
+        """Test that hard links, symbolic links, device nodes and absolute paths of tar archives are skipped."""
+        content = _tar(
+            [
+                (_tar_info('src/main.c'), b'int main() {}'),
+                (_tar_info('src/copy.c', tarfile.LNKTYPE, 'src/main.c'), None),
+                (_tar_info('src/alias.c', tarfile.SYMTYPE, '/etc/passwd'), None),
+                (_tar_info('dev/null', tarfile.CHRTYPE), None),
+                (_tar_info('dev/pipe', tarfile.FIFOTYPE), None),
+                (_tar_info('/etc/cron.d/job'), b'* * * * * evil'),
+            ],
+            mode='w:gz',
+        )
+
+        result = ArchiveExtractor().extract(content)
+
+        self.assertEqual([file.path for file in result.files], ['src/main.c'])
+        self.assertEqual(
+            {entry['path']: entry['reason'] for entry in result.skipped_entries},
+            {
+                'src/copy.c': 'hard link',
+                'src/alias.c': 'symbolic link',
+                'dev/null': 'device node',
+                'dev/pipe': 'device node',
+                '/etc/cron.d/job': 'absolute path',
+            },
+        )
+
+    def test_traversal_archive(self):
+        """Test that an upload with an entry escaping the archive is rejected, nothing landing outside the root."""
+        content = _zip([('src/main.py', 'print(1)'), ('../../etc/cron.d/x', '* * * * * evil'), ('/etc/passwd', 'x')])
+
+        with self.assertRaises(ArchiveLimitExceeded) as context:
+            ArchiveExtractor(enforce_limits=True).extract(content)
+        self.assertEqual((context.exception.code, context.exception.entry), ('unsafe_entry_path', '../../etc/cron.d/x'))
+
+        with tempfile.TemporaryDirectory() as directory:
+            root = Path(directory) / 'root'
+            ArchiveExtractor().extract(content).write(root)
+            self.assertEqual([path.name for path in Path(directory).rglob('*') if path.is_file()], ['main.py'])
+
+            escaping = ArchiveExtractionResult(
+                files=[ArchiveFile('README.md', b'ok'), ArchiveFile('../escaped.txt', b'x')]
+            )
+            with self.assertRaises(ArchiveLimitExceeded):
+                escaping.write(root / 'other')
+            self.assertFalse((root / 'other').exists())
+            self.assertFalse((root / 'escaped.txt').exists())
+
+    def test_compression_bomb(self):
+        """Test that a high-ratio entry stops the extraction early, whatever its format and the limits."""
+        bomb = b'\0' * 20_000_000
+        zip_bomb = _zip([('readme.txt', 'hello'), ('bomb.bin', bomb)], compression=zipfile.ZIP_DEFLATED)
+        tar_bomb = _tar([(_tar_info('bomb.bin'), bomb)], mode='w:gz')
+        self.assertLess(len(zip_bomb), 50_000)
+
+        for content in (zip_bomb, tar_bomb):
+            for extractor in (ArchiveExtractor(), ArchiveExtractor(enforce_limits=True)):
+                with self.assertRaises(ArchiveLimitExceeded) as context:
+                    extractor.extract(content)
+                self.assertEqual(context.exception.code, 'compression_ratio_exceeded')
+                self.assertEqual(context.exception.entry, 'bomb.bin')
+
+    def test_too_many_entries(self):
+        """Test that an archive of 100k tiny entries is rejected as soon as its entries are counted."""
+        content = _zip([(f'f{index}.txt', '') for index in range(100_000)])
+        tar = _tar([(_tar_info(f'f{index}.txt'), b'') for index in range(30)])
+
+        with self.assertRaises(ArchiveLimitExceeded) as context:
+            ArchiveExtractor().extract(content)
+        self.assertEqual((context.exception.code, context.exception.entry), ('too_many_entries', 'f20000.txt'))
+        with self.assertRaises(ArchiveLimitExceeded) as context:
+            ArchiveExtractor(max_entries=20).extract(tar)
+        self.assertEqual(context.exception.entry, 'f20.txt')
+
+    def test_zip_symbolic_links(self):
+        """Test that a symbolic link of a ZIP archive is skipped rather than extracted as a file holding its target."""
+        buffer = io.BytesIO()
+        with zipfile.ZipFile(buffer, 'w') as archive:
+            archive.writestr('src/main.py', 'print(1)')
+            link = zipfile.ZipInfo('src/secrets.py')
+            link.external_attr = 0o120777 << 16
+            archive.writestr(link, '/etc/passwd')
+
+        result = ArchiveExtractor().extract(buffer.getvalue())
+
+        self.assertEqual([file.path for file in result.files], ['src/main.py'])
+        self.assertEqual(result.skipped_entries, [{'path': 'src/secrets.py', 'reason': 'symbolic link'}])
+
+    def test_duplicate_paths(self):
+        """Test that the last entry at a path wins with the others skipped, or that strict mode rejects the archive."""
+        content = _tar(
+            [
+                (_tar_info('main.go'), b'package old'),
+                (_tar_info('util.go'), b''),
+                (_tar_info('./main.go'), b'package new'),
+            ]
+        )
+
+        result = ArchiveExtractor().extract(content)
+
+        self.assertEqual(
+            [(file.path, file.content) for file in result.files], [('util.go', b''), ('main.go', b'package new')]
+        )
+        self.assertEqual(result.skipped_entries, [{'path': 'main.go', 'reason': DUPLICATE_REASON}])
+        with self.assertRaises(ArchiveLimitExceeded) as context:
+            ArchiveExtractor(strict_paths=True).extract(content)
+        self.assertEqual((context.exception.code, context.exception.entry), ('duplicate_entry_path', 'main.go'))
+
+    def test_case_colliding_paths(self):
+        """Test that the paths differing only by case are kept and reported, or rejected in strict mode."""
+        content = _zip([('src/Main.go', 'package a'), ('src/main.go', 'package b'), ('README.md', '')])
+
+        result = ArchiveExtractor().extract(content)
+
+        self.assertEqual(len(result.files), 3)
+        self.assertEqual(case_colliding_paths(file.path for file in result.files), [['src/Main.go', 'src/main.go']])
+        for check in (
+            lambda: ArchiveExtractor(strict_paths=True).extract(content),
+            lambda: ArchiveExtractor(strict_paths=True).check_files(result.files),
+        ):
+            with self.assertRaises(ArchiveLimitExceeded) as context:
+                check()
+            self.assertEqual((context.exception.code, context.exception.entry), ('case_colliding_paths', 'src/main.go'))
+
+    def test_invalid_archive(self):
+        """Test that an archive with a ZIP signature but no valid structure is rejected."""
+        with self.assertRaises(ValueError):
+            ArchiveExtractor().extract(b'PK\x03\x04 truncated')
+        with self.assertRaises(ValueError):
+            ArchiveExtractor().extract(b'\x1f\x8b not a gzip stream')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,114 @@
+"""
+Tests for IgnoreRules
+"""
+
+import unittest
+
+from app.domains.repositories.archive_extractor import ArchiveFile
+from app.domains.repositories.ignore_rules import DEFAULT_IGNORE_RULES, IgnoreRule, IgnoreRules
+
+
+class TestIgnoreRules(unittest.TestCase):
+    """Unit tests for the gitignore-style rules leaving paths out of the submissions."""
+
+    def test_default_rules(self):
+        """Test that the built-in rules ignore the dependencies, build outputs and IDE folders at any depth."""
+        rules = IgnoreRules()
+
+        for path in ['node_modules/left-pad/index.js', 'backend/vendor/lib.go', 'target/App.class', 'src/main.o']:
+            self.assertTrue(rules.ignores(path), path)
+        for path in ['src/main.c', 'README.md', 'vendors.txt', 'src/dist.py']:
+            self.assertFalse(rules.ignores(path), path)
+        self.assertEqual(rules.lines, DEFAULT_IGNORE_RULES)
+
+    def test_directory_only(self):
+        """Test that a pattern ending with a slash only ignores directories, not a file of that name."""
+        rules = IgnoreRules(['build/'])
+
+        self.assertTrue(rules.ignores('build/out.txt'))
+        self.assertTrue(rules.ignores('build', is_directory=True))
+        self.assertFalse(rules.ignores('build'))
+
+    def test_anchored(self):
+        """Test that a pattern with a slash is matched from the root, the others at any depth."""
+        rules = IgnoreRules(['/docs', 'src/*.tmp'])
+
+        self.assertTrue(rules.ignores('docs/index.md'))
+        self.assertFalse(rules.ignores('backend/docs/index.md'))
+        self.assertTrue(rules.ignores('src/a.tmp'))
+        self.assertFalse(rules.ignores('lib/src/a.tmp'))
+        self.assertFalse(rules.ignores('src/nested/a.tmp'))
+
+    def test_double_star(self):
+        """Test that ** matches any number of directories."""
+        rules = IgnoreRules(['**/generated/**', 'logs/**/*.log'])
+
+        self.assertTrue(rules.ignores('generated/a.py'))
+        self.assertTrue(rules.ignores('api/v1/generated/b/c.py'))
+        self.assertTrue(rules.ignores('logs/a.log'))
+        self.assertTrue(rules.ignores('logs/2024/09/a.log'))
+        self.assertFalse(rules.ignores('logs/a.txt'))
+
+    def test_negation(self):
+        """Test that a negation includes a path again, the last matching rule deciding."""
+        rules = IgnoreRules(['*.txt', '!keep.txt'])
+
+        self.assertTrue(rules.ignores('notes.txt'))
+        self.assertFalse(rules.ignores('keep.txt'))
+        self.assertTrue(IgnoreRules(['!keep.txt', '*.txt']).ignores('keep.txt'))
+
+    def test_negation_inside_ignored_directory(self):
+        """Test that a file in an ignored directory stays ignored whatever the negations, as with git."""
+        rules = IgnoreRules(['build/', '!build/README.md'])
+        self.assertTrue(rules.ignores('build/README.md'))
+
+        rules = IgnoreRules(['build/*', '!build/README.md'])
+        self.assertFalse(rules.ignores('build/README.md'))
+        self.assertTrue(rules.ignores('build/out.txt'))
+
+    def test_comments_and_escapes(self):
+        """Test that blank lines and comments are no rules, and that escaped characters are literal."""
+        self.assertIsNone(IgnoreRule.parse('# comment'))
+        self.assertIsNone(IgnoreRule.parse('   '))
+        rules = IgnoreRules(['\\#notes', '\\!important', 'file[0-9].c'])
+
+        self.assertTrue(rules.ignores('#notes'))
+        self.assertTrue(rules.ignores('!important'))
+        self.assertTrue(rules.ignores('file7.c'))
+        self.assertFalse(rules.ignores('filex.c'))
+
+    def test_no_rules(self):
+        """Test that an empty list of rules ignores nothing."""
+        rules = IgnoreRules([])
+
+        self.assertFalse(rules.enabled)
+        self.assertFalse(rules.ignores('node_modules/index.js'))
+
+    def test_filter(self):
+        """Test that the files are split into the kept ones and the paths of the ignored ones."""
+        files = [ArchiveFile('main.c', b'int main() {}'), ArchiveFile('main.o', b'\x7fELF')]
+
+        kept, ignored = IgnoreRules().filter(files)
+
+        self.assertEqual([file.path for file in kept], ['main.c'])
+        self.assertEqual(ignored, ['main.o'])
+
+    def test_honored_gitignores(self):
+        """Test that the .gitignore files apply under their directory, without including what the step ignores."""
+        files = [
+            ArchiveFile('.gitignore', b'*.log\n!keep.log\n!*.o\n'),
+            ArchiveFile('backend/.gitignore', b'# local\nsecrets.env\n!debug.log\n'),
+        ]
+        rules = IgnoreRules().with_gitignores(files)
+
+        self.assertTrue(rules.ignores('run.log'))
+        self.assertFalse(rules.ignores('keep.log'))
+        self.assertTrue(rules.ignores('backend/secrets.env'))
+        self.assertFalse(rules.ignores('secrets.env'))
+        self.assertFalse(rules.ignores('backend/debug.log'))
+        self.assertTrue(rules.ignores('src/main.o'))
+        self.assertFalse(IgnoreRules().ignores('run.log'))
+
+
+if __name__ == '__main__':
+    unittest.main()
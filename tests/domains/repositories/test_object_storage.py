@@ -0,0 +1,234 @@
+"""
+Tests for ObjectStorage
+"""
+
+import io
+import os
+import tempfile
+import unittest
+import uuid
+from pathlib import Path
+
+from app.domains.repositories.exceptions import (
+    ObjectCorruptedException,
+    ObjectNotFoundException,
+    ObjectStorageException,
+)
+from app.domains.repositories.object_storage import (
+    FilesystemObjectStorage,
+    LocalObjectStorage,
+    S3ObjectStorage,
+    boto3,
+    submission_file_key,
+)
+from app.domains.repositories.storage_migration import StorageMigration
+
+# MinIO the S3 storage is tested against, e.g. started by docker compose up minio
+MINIO_ENDPOINT_URL = os.environ.get('MINIO_ENDPOINT_URL')
+
+
+class ObjectStorageContract:
+    """Behavior shared by the backends of the object storage, run against each of them."""
+
+    def test_put_get(self):
+        """Test that an object is written and read back as a stream, replacing the previous one of its key."""
+        content = os.urandom(3 * 1024 * 1024 + 17)
+        self.storage.put('uploads/a/archive.zip', io.BytesIO(b'previous'))
+        self.storage.put('uploads/a/archive.zip', io.BytesIO(content))
+
+        self.assertTrue(self.storage.exists('uploads/a/archive.zip'))
+        self.assertEqual(self.storage.read_bytes('uploads/a/archive.zip'), content)
+        self.assertEqual(b''.join(self.storage.iter_chunks('uploads/a/archive.zip', 1024 * 1024)), content)
+
+    def test_list_delete(self):
+        """Test that the objects are listed by prefix, and deleted one at a time or by prefix."""
+        for key in ['chunks/1/0', 'chunks/1/1', 'chunks/10/0', 'uploads/file']:
+            self.storage.put(key, io.BytesIO(key.encode('utf8')))
+
+        self.assertEqual(self.storage.list('chunks/1/'), ['chunks/1/0', 'chunks/1/1'])
+        self.assertEqual(self.storage.list('chunks/1'), ['chunks/1/0', 'chunks/1/1', 'chunks/10/0'])
+        self.assertEqual(len(self.storage.list()), 4)
+
+        self.storage.delete('uploads/file')
+        self.storage.delete('uploads/file')
+        self.assertFalse(self.storage.exists('uploads/file'))
+        self.assertEqual(self.storage.delete_prefix('chunks/1/'), 2)
+        self.assertEqual(self.storage.list(), ['chunks/10/0'])
+
+    def test_missing_and_invalid_keys(self):
+        """Test that a missing object is reported as such, and the keys escaping the storage rejected."""
+        with self.assertRaises(ObjectNotFoundException):
+            self.storage.get('missing')
+        for key in ['', '/absolute', 'a/../b', 'a//b']:
+            with self.assertRaises(ObjectStorageException):
+                self.storage.put(key, io.BytesIO(b''))
+
+    def test_reachable(self):
+        """Test that a reachable backend passes the check of the readiness probe."""
+        self.storage.check_reachable()
+
+
+class TestLocalObjectStorage(ObjectStorageContract, unittest.TestCase):
+    """Unit tests for the objects kept under a directory."""
+
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+        self.storage = LocalObjectStorage(Path(self.directory.name))
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    def test_no_links(self):
+        """Test that the local objects have no S3 links, nor their directories left once deleted."""
+        self.storage.put('uploads/a/file', io.BytesIO(b'content'))
+        self.storage.delete('uploads/a/file')
+
+        self.assertIsNone(self.storage.link('uploads/a/file'))
+        self.assertEqual(list(Path(self.directory.name).iterdir()), [])
+
+
+class TestFilesystemObjectStorage(ObjectStorageContract, unittest.TestCase):
+    """Unit tests for the objects kept on a volume with their checksums."""
+
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+        self.root = Path(self.directory.name)
+        self.storage = FilesystemObjectStorage(self.root)
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    def test_layout_and_links(self):
+        """Test that the files are laid out by assignment, submission and version, with links mapped to their key."""
+        project, step, group = uuid.uuid4(), uuid.uuid4(), uuid.uuid4()
+        key = submission_file_key(project, step, group, 2, 'src\\main.py')
+        self.storage.put(key, io.BytesIO(b'print(1)'))
+
+        self.assertEqual(key, f'{project}/{step}/{group}/v2/src/main.py')
+        self.assertTrue((self.root / str(project) / str(step) / str(group) / 'v2' / 'src' / 'main.py').is_file())
+        self.assertEqual(self.storage.key_of(self.storage.link(key)), key)
+        self.assertEqual(self.storage.list(), [key])
+        self.assertEqual([path.name for path in self.root.rglob('*.part')], [])
+
+    def test_tenant_layout(self):
+        """Test that the files of a tenant are laid out under its directory, those of the default tenant as before."""
+        project, step, group = uuid.uuid4(), uuid.uuid4(), uuid.uuid4()
+
+        self.assertEqual(
+            submission_file_key(project, step, group, 1, 'main.py', 'acme'),
+            f'tenants/acme/{project}/{step}/{group}/v1/main.py',
+        )
+        self.assertEqual(
+            submission_file_key(project, step, group, 1, 'main.py', 'default'), f'{project}/{step}/{group}/v1/main.py'
+        )
+
+    def test_unsafe_paths(self):
+        """Test that the paths and keys leading out of the storage are rejected, through a symbolic link too."""
+        for path in ['../secret', '/etc/passwd', 'C:/Windows/file', 'a/../../b', '']:
+            with self.assertRaises(ObjectStorageException):
+                submission_file_key(uuid.uuid4(), uuid.uuid4(), uuid.uuid4(), 1, path)
+
+        outside = tempfile.TemporaryDirectory()
+        self.addCleanup(outside.cleanup)
+        os.symlink(outside.name, self.root / 'escape')
+        for key in ['escape/file', '.checksums/file']:
+            with self.assertRaises(ObjectStorageException):
+                self.storage.put(key, io.BytesIO(b'content'))
+        self.assertEqual(os.listdir(outside.name), [])
+
+    def test_corruption(self):
+        """Test that an altered file fails to be read, and is reported by the scan with the missing ones."""
+        for key in ['a/file', 'b/file', 'c/file']:
+            self.storage.put(key, io.BytesIO(b'content of ' + key.encode('utf8')))
+        (self.root / 'a' / 'file').write_bytes(b'altered')
+        (self.root / 'b' / 'file').unlink()
+        (self.root / 'd').mkdir()
+        (self.root / 'd' / 'file').write_bytes(b'copied without checksum')
+
+        with self.assertRaises(ObjectCorruptedException):
+            self.storage.read_bytes('a/file')
+        self.assertEqual(self.storage.read_bytes('c/file'), b'content of c/file')
+        self.assertEqual(self.storage.read_bytes('d/file'), b'copied without checksum')
+
+        report = self.storage.scan()
+        self.assertEqual(report.checked_count, 3)
+        self.assertEqual((report.missing, report.corrupted, report.unverified), (['b/file'], ['a/file'], ['d/file']))
+
+        self.storage.delete('c/file')
+        self.assertFalse((self.root / '.checksums' / 'c').exists())
+
+
+@unittest.skipUnless(MINIO_ENDPOINT_URL and boto3, 'MINIO_ENDPOINT_URL is not set or boto3 is missing')
+class TestS3ObjectStorage(ObjectStorageContract, unittest.TestCase):
+    """Integration tests for the objects kept in a bucket of MinIO."""
+
+    def setUp(self):
+        self.bucket = f'test-{uuid.uuid4()}'
+        self.client = boto3.client(
+            's3',
+            endpoint_url=MINIO_ENDPOINT_URL,
+            aws_access_key_id=os.environ.get('MINIO_ACCESS_KEY', 'minioadmin'),
+            aws_secret_access_key=os.environ.get('MINIO_SECRET_KEY', 'minioadmin'),
+            region_name='us-east-1',
+        )
+        self.client.create_bucket(Bucket=self.bucket)
+        self.storage = S3ObjectStorage(self.bucket, key_prefix='service/', client=self.client)
+
+    def tearDown(self):
+        for key in self.client.list_objects_v2(Bucket=self.bucket).get('Contents', []):
+            self.client.delete_object(Bucket=self.bucket, Key=key['Key'])
+        self.client.delete_bucket(Bucket=self.bucket)
+
+    def test_links(self):
+        """Test that the objects have S3 links under the key prefix, mapped back to their key."""
+        link = self.storage.link('uploads/a/file.zip')
+
+        self.assertEqual(link, f's3://{self.bucket}/service/uploads/a/file.zip')
+        self.assertEqual(self.storage.key_of(link), 'uploads/a/file.zip')
+        self.assertIsNone(self.storage.key_of(f's3://{self.bucket}/other/file.zip'))
+
+
+class CorruptingStorage(LocalObjectStorage):
+    """Local storage losing the last byte of the objects it writes."""
+
+    def put(self, key, stream):
+        super().put(key, io.BytesIO(stream.read()[:-1]))
+
+
+class TestStorageMigration(unittest.TestCase):
+    """Unit tests for the copy of the local objects into another storage."""
+
+    def setUp(self):
+        self.directories = [tempfile.TemporaryDirectory() for _ in range(2)]
+        self.source = LocalObjectStorage(Path(self.directories[0].name))
+        for key in ['chunked-uploads/1/0', 'uploads/a/archive.zip', 'uploads/b/file.py']:
+            self.source.put(key, io.BytesIO(os.urandom(1000)))
+
+    def tearDown(self):
+        for directory in self.directories:
+            directory.cleanup()
+
+    def test_copy_then_skip(self):
+        """Test that the objects are copied once, those already copied being skipped when run again."""
+        target = LocalObjectStorage(Path(self.directories[1].name))
+
+        report = StorageMigration(self.source, target).run()
+        self.assertEqual((report.copied, report.skipped, report.failed), (3, 0, []))
+        for key in self.source.list():
+            self.assertEqual(target.read_bytes(key), self.source.read_bytes(key))
+
+        self.source.put('uploads/b/file.py', io.BytesIO(b'changed'))
+        report = StorageMigration(self.source, target).run('uploads/')
+        self.assertEqual((report.copied, report.skipped), (1, 1))
+        self.assertEqual(target.read_bytes('uploads/b/file.py'), b'changed')
+
+    def test_checksum_mismatch(self):
+        """Test that an object not matching its checksum once copied is reported as failed."""
+        report = StorageMigration(self.source, CorruptingStorage(Path(self.directories[1].name))).run()
+
+        self.assertEqual(report.copied, 0)
+        self.assertEqual(len(report.failed), 3)
+
+
+if __name__ == '__main__':
+    unittest.main()
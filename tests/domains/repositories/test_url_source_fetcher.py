@@ -0,0 +1,87 @@
+"""
+Tests for UrlSourceFetcher
+"""
+
+import io
+import unittest
+from email.message import Message
+from unittest import mock
+
+from app.domains.repositories.exceptions import ExternalSourceException
+from app.domains.repositories.fetchers.url_source_fetcher import UrlSourceFetcher, _PolicyRedirectHandler
+
+FETCHER_MODULE = 'app.domains.repositories.fetchers.url_source_fetcher'
+
+
+class _Response(io.BytesIO):
+    """Response of a stubbed opener, with the headers of an HTTP response."""
+
+    def __init__(self, content, charset='utf-8'):
+        super().__init__(content)
+        self.headers = Message()
+        self.headers['Content-Type'] = f'text/plain; charset={charset}'
+
+
+class TestUrlSourceFetcher(unittest.TestCase):
+    """Unit tests for the fetching of external sources, capped in size and checked by a host policy."""
+
+    def _fetch(self, fetcher, url, response):
+        with mock.patch(f'{FETCHER_MODULE}.build_opener') as build_opener:
+            build_opener.return_value.open.return_value = response
+            return fetcher.fetch(url)
+
+    def test_fetch_within_limits(self):
+        """Test that a source is decoded with its charset and named after the URL path."""
+        fetcher = UrlSourceFetcher(max_bytes=100, timeout_seconds=1, host_policy=lambda host: True)
+
+        content, file_name = self._fetch(
+            fetcher, 'https://gist.example.com/raw/worker.py', _Response('print("é")'.encode('latin-1'), 'latin-1')
+        )
+
+        self.assertEqual(content, 'print("é")')
+        self.assertEqual(file_name, 'worker.py')
+        self.assertEqual(self._fetch(fetcher, 'https://gist.example.com', _Response(b'x'))[1], 'external')
+
+    def test_rejected_sources(self):
+        """Test that other schemes, denied hosts and responses over the size cap are rejected."""
+        fetcher = UrlSourceFetcher(max_bytes=4, timeout_seconds=1, host_policy=lambda host: host != 'denied.example')
+
+        errors = {}
+        for url, response in (
+            ('file:///etc/passwd', _Response(b'')),
+            ('https://denied.example/code.py', _Response(b'')),
+            ('https://allowed.example/code.py', _Response(b'12345')),
+        ):
+            with self.assertRaises(ExternalSourceException) as context:
+                self._fetch(fetcher, url, response)
+            errors[url] = context.exception.details['error_type']
+
+        self.assertEqual(
+            list(errors.values()),
+            ['external_source_invalid_url', 'external_source_denied', 'external_source_too_large'],
+        )
+        self.assertEqual(self._fetch(fetcher, 'https://allowed.example/code.py', _Response(b'1234'))[0], '1234')
+
+    def test_redirections_and_default_policy(self):
+        """Test that redirections are checked, and that the default policy only allows public addresses."""
+        fetcher = UrlSourceFetcher(max_bytes=4, timeout_seconds=1, host_policy=lambda host: host != 'denied.example')
+        handler = _PolicyRedirectHandler(fetcher)
+        with self.assertRaises(ExternalSourceException):
+            handler.redirect_request(None, None, 302, 'Found', {}, 'http://denied.example/code.py')
+
+        default = UrlSourceFetcher(max_bytes=4, timeout_seconds=1)
+        default.denied_hosts = {'blocked.example'}
+        addresses = {'public.example': '93.184.216.34', 'internal.example': '10.0.0.5', 'blocked.example': '1.1.1.1'}
+        with mock.patch(
+            f'{FETCHER_MODULE}.socket.getaddrinfo', side_effect=lambda host, port: [(2, 1, 6, '', (addresses[host], 0))]
+        ):
+            self.assertTrue(default.default_host_policy('public.example'))
+            self.assertFalse(default.default_host_policy('internal.example'))
+            self.assertFalse(default.default_host_policy('blocked.example'))
+
+        default.allowed_hosts = {'other.example'}
+        self.assertFalse(default.default_host_policy('public.example'))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1 @@
+# Submissions tests module
@@ -0,0 +1,38 @@
+"""
+Factories of the fake records shared by the tests of the submissions domain
+"""
+
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+
+def similarity(
+    submission_id,
+    compared_submission_id,
+    overall_similarity=0.0,
+    status=SimilarityStatus.COMPLETED,
+    tokens=(300, 300),
+    fragments=None,
+    **fields,
+):
+    """
+    Comparison record of a pair of submissions, its details giving the processed tokens of each side and its
+    fragments if given, any other field of the record being set by keyword
+    """
+    details = {'processed_tokens_count': {'submission1': tokens[0], 'submission2': tokens[1]}}
+    if fragments is not None:
+        details['fragments'] = list(fragments)
+    record = {
+        'id': uuid4(),
+        'submission_id': submission_id,
+        'compared_submission_id': compared_submission_id,
+        'overall_similarity': overall_similarity,
+        'max_function_similarity': None,
+        'status': status,
+        'similarity_details': details,
+        'shared_blocks': None,
+        'visualization_data': None,
+    }
+    return SimpleNamespace(**{**record, **fields})
@@ -0,0 +1,133 @@
+"""
+Tests for AccessPolicy
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.access_policy import AccessDenied, AccessPolicy, OutOfTenant
+from app.shared.authorization import Principal, Role
+
+
+class TestAccessPolicy(unittest.TestCase):
+    """Unit tests for the scope of the callers over the submissions and their results."""
+
+    def setUp(self):
+        """Set up test fixtures."""
+        self.step, self.other_step = uuid4(), uuid4()
+        self.student_id, self.group = uuid4(), uuid4()
+        self.own = self.submission(group_uuid=self.group)
+        self.other = self.submission(group_uuid=uuid4())
+        self.denials = []
+
+    def submission(self, group_uuid, project_step_uuid=None, submitted_by_uuid=None, tenant_id='default'):
+        return SimpleNamespace(
+            id=uuid4(),
+            group_uuid=group_uuid,
+            project_step_uuid=project_step_uuid or self.step,
+            submitted_by_uuid=submitted_by_uuid,
+            tenant_id=tenant_id,
+        )
+
+    def policy(self, role, **claims):
+        return AccessPolicy(Principal(subject=f'{role.value}-1', role=role, **claims), self.denials.append)
+
+    def student(self):
+        return self.policy(Role.STUDENT, student_id=self.student_id, group_ids=frozenset({self.group}))
+
+    def test_student_reads_only_their_own_submissions(self):
+        """Test that a student reads the submissions of their groups or submitted by them, not the others."""
+        policy = self.student()
+        submitted = self.submission(group_uuid=uuid4(), submitted_by_uuid=self.student_id)
+
+        policy.check_submission(self.own, 'get_submission')
+        policy.check_submission(submitted, 'get_submission')
+        with self.assertRaises(AccessDenied):
+            policy.check_submission(self.other, 'get_submission')
+        self.assertEqual(policy.readable([self.own, self.other, submitted]), [self.own, submitted])
+
+    def test_student_never_reads_pairwise_results(self):
+        """Test that a student is denied the results of a step, even of their own submission."""
+        with self.assertRaises(AccessDenied) as denied:
+            self.student().check_results(self.step, 'get_submission_similarities', 'submission', self.own.id)
+
+        self.assertEqual(denied.exception.to_dict()['resource_id'], str(self.own.id))
+
+    def test_student_cannot_manage_nor_submit_for_others(self):
+        """Test that a student cannot change their submission, nor submit for another group or submitter."""
+        policy = self.student()
+
+        policy.check_submission_creation(self.step, self.group, self.student_id, 'upload_submission')
+        for action in (
+            lambda: policy.check_submission(self.own, 'delete_submission', manage=True),
+            lambda: policy.check_submission_creation(self.step, uuid4(), None, 'upload_submission'),
+            lambda: policy.check_submission_creation(self.step, self.group, uuid4(), 'upload_submission'),
+        ):
+            with self.assertRaises(AccessDenied):
+                action()
+
+    def test_grader_is_restricted_to_their_assignments(self):
+        """Test that a grader reads and manages the submissions and results of their assignments only."""
+        policy = self.policy(Role.GRADER, assignment_ids=frozenset({self.step}))
+        elsewhere = self.submission(group_uuid=self.group, project_step_uuid=self.other_step)
+
+        policy.check_submission(self.own, 'delete_submission', manage=True)
+        policy.check_results(self.step, 'get_detection_run', 'detection_run')
+        for action in (
+            lambda: policy.check_submission(elsewhere, 'get_submission'),
+            lambda: policy.check_results(self.other_step, 'get_detection_run', 'detection_run'),
+            lambda: policy.check_global('create_webhook', 'webhook'),
+        ):
+            with self.assertRaises(AccessDenied):
+                action()
+        self.assertEqual(policy.submission_filters(), {'project_step_uuids': [self.step]})
+
+    def test_admin_and_internal_callers_see_everything(self):
+        """Test that an admin, or no caller at all, is never denied nor filtered."""
+        for policy in (self.policy(Role.ADMIN), AccessPolicy(None)):
+            with self.subTest(principal=policy.principal):
+                policy.check_submission(self.other, 'purge_submission', manage=True)
+                policy.check_results(self.other_step, 'get_similarity_matrix', 'detection_run')
+                policy.check_admin('purge_token_cache', 'token_cache')
+                self.assertEqual(policy.submission_filters(), {})
+        with self.assertRaises(AccessDenied):
+            self.policy(Role.SERVICE).check_admin('purge_submission', 'submission')
+
+    def test_other_tenants_are_not_found(self):
+        """Test that the resources of another tenant are reported as not found, to admins too, and unlisted."""
+        tenants = {self.step: 'default', self.other_step: 'acme'}
+        policy = AccessPolicy(
+            Principal(subject='admin-1', role=Role.ADMIN, tenant_id='acme'), self.denials.append, tenants.get
+        )
+        acme = self.submission(group_uuid=self.group, project_step_uuid=self.other_step, tenant_id='acme')
+
+        policy.check_submission(acme, 'get_submission')
+        policy.check_results(self.other_step, 'get_detection_run', 'detection_run')
+        policy.check_step(uuid4(), 'get_step_schedule')
+        for action in (
+            lambda: policy.check_submission(self.own, 'get_submission'),
+            lambda: policy.check_results(self.step, 'get_detection_run', 'detection_run', 'run-1'),
+            lambda: policy.check_submission_creation(self.step, self.group, None, 'create_submission'),
+        ):
+            with self.assertRaises(OutOfTenant):
+                action()
+        self.assertEqual(str(self.denials[0]), f'Submission not found with identifier: {self.own.id}')
+        self.assertEqual(policy.readable([self.own, acme]), [acme])
+        self.assertEqual(policy.tenant_filters(), {'tenant_id': 'acme'})
+        self.assertEqual(AccessPolicy(None).tenant_filters(), {})
+
+    def test_denials_are_reported_with_the_caller_and_target(self):
+        """Test that each denial reaches the audit callback, naming the caller, the operation and its target."""
+        with self.assertRaises(AccessDenied):
+            self.student().check_submission(self.other, 'download_submission')
+
+        self.assertEqual(len(self.denials), 1)
+        denial = self.denials[0]
+        self.assertEqual(denial.principal.subject, 'student-1')
+        self.assertEqual((denial.action, denial.resource_type), ('download_submission', 'submission'))
+        self.assertEqual(denial.resource_id, str(self.other.id))
+
+
+if __name__ == '__main__':
+    unittest.main()
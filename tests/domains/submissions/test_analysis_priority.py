@@ -0,0 +1,51 @@
+"""
+Tests for AnalysisPriorityPolicy
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+
+from app.domains.submissions.analysis_priority import AnalysisPriorityPolicy
+from app.domains.submissions.submissions_models import AnalysisPriority
+
+
+class TestAnalysisPriorityPolicy(unittest.TestCase):
+    """Unit tests for the priority of the analyses from the deadlines of the project steps."""
+
+    def setUp(self):
+        self.now = datetime(2024, 1, 15, 10, 30, tzinfo=timezone.utc)
+
+    def test_for_deadline(self):
+        """Test that the closer the deadline, the higher the priority, and normal without deadline."""
+        cases = [
+            (None, AnalysisPriority.NORMAL),
+            (self.now - timedelta(hours=2), AnalysisPriority.URGENT),
+            (self.now + timedelta(hours=20), AnalysisPriority.URGENT),
+            (self.now + timedelta(days=2), AnalysisPriority.HIGH),
+            (self.now + timedelta(days=10), AnalysisPriority.NORMAL),
+            (self.now + timedelta(days=30), AnalysisPriority.LOW),
+        ]
+        for deadline, expected in cases:
+            with self.subTest(deadline=deadline):
+                self.assertEqual(AnalysisPriorityPolicy.for_deadline(deadline, self.now), expected)
+
+    def test_naive_deadline(self):
+        """Test that a deadline stored without timezone is compared with the local time."""
+        deadline = datetime(2024, 1, 16, 9, 0)
+
+        self.assertEqual(AnalysisPriorityPolicy.for_deadline(deadline, self.now), AnalysisPriority.URGENT)
+
+    def test_resolve(self):
+        """Test that the explicit priority wins over the step's, which wins over the deadline's."""
+        tomorrow = self.now + timedelta(hours=12)
+
+        self.assertEqual(
+            AnalysisPriorityPolicy.resolve('low', AnalysisPriority.HIGH, tomorrow, self.now), AnalysisPriority.LOW
+        )
+        self.assertEqual(AnalysisPriorityPolicy.resolve(None, 'high', tomorrow, self.now), AnalysisPriority.HIGH)
+        self.assertEqual(AnalysisPriorityPolicy.resolve(None, None, tomorrow, self.now), AnalysisPriority.URGENT)
+        self.assertGreater(AnalysisPriorityPolicy.level('urgent'), AnalysisPriorityPolicy.level(AnalysisPriority.LOW))
+
+
+if __name__ == '__main__':
+    unittest.main()
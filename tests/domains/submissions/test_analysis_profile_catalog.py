@@ -0,0 +1,133 @@
+"""
+Tests for AnalysisProfileCatalog
+"""
+
+import unittest
+from types import SimpleNamespace
+
+from app.domains.submissions.analysis_profile_catalog import AnalysisProfileCatalog
+from app.domains.submissions.analysis_profile_resolver import DEFAULT_PROFILE_NAME, AnalysisProfileResolver
+from app.shared.exceptions import ConflictException, NotFoundException, ValidationException
+
+
+class FakeProfile(SimpleNamespace):
+    """Stored analysis profile, dumped as its fields"""
+
+    def model_dump(self):
+        return dict(vars(self))
+
+
+class FakeProfileRepository:
+    """Analysis profiles of all the tenants kept in memory"""
+
+    def __init__(self):
+        self.profiles = {}
+
+    def get_all(self, tenant_id):
+        return [profile for (owner, _), profile in self.profiles.items() if owner == tenant_id]
+
+    def get_by_name(self, name, tenant_id):
+        return self.profiles.get((tenant_id, name))
+
+    def create(self, profile_data):
+        profile = FakeProfile(**profile_data)
+        self.profiles[(profile.tenant_id, profile.name)] = profile
+        return profile
+
+    def update(self, name, profile_data, tenant_id):
+        profile = self.profiles[(tenant_id, name)]
+        for field, value in profile_data.items():
+            setattr(profile, field, value)
+        return profile
+
+    def delete(self, name, tenant_id):
+        return self.profiles.pop((tenant_id, name), None) is not None
+
+
+def validate_options(options):
+    """Refuse a k-gram size below 2, as the detection options do"""
+    if options['fingerprint_kgram_size'] < 2:
+        raise ValueError('fingerprint_kgram_size must be at least 2')
+    return options
+
+
+class TestAnalysisProfileCatalog(unittest.TestCase):
+    """Unit tests for the analysis profiles of a tenant, after the built-in default one."""
+
+    def setUp(self):
+        self.repository = FakeProfileRepository()
+        resolver = AnalysisProfileResolver({'normalize_identifiers': False, 'fingerprint_kgram_size': 5})
+        self.catalog = AnalysisProfileCatalog(self.repository, resolver, validate_options, 'tenant-a')
+        self.other_tenant = AnalysisProfileCatalog(self.repository, resolver, validate_options, 'tenant-b')
+
+    def _create(self, catalog=None, **profile_data):
+        profile_data = {
+            'name': 'renames',
+            'description': None,
+            'detection_options': {'normalize_identifiers': True},
+            'flag_threshold': 0.6,
+            'min_token_count': None,
+            **profile_data,
+        }
+        return (catalog or self.catalog).create(profile_data)
+
+    def test_create(self):
+        """Test that a profile gets the default options it lacks, after the built-in one, in its tenant only."""
+        profile = self._create()
+
+        self.assertEqual(profile['detection_options'], {'normalize_identifiers': True, 'fingerprint_kgram_size': 5})
+        self.assertFalse(profile['built_in'])
+        self.assertEqual([profile['name'] for profile in self.catalog.get_all()], [DEFAULT_PROFILE_NAME, 'renames'])
+        self.assertEqual([profile['name'] for profile in self.other_tenant.get_all()], [DEFAULT_PROFILE_NAME])
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.get('renames')
+
+    def test_conflicts(self):
+        """Test that neither an existing profile nor the built-in one is created again, nor the latter changed."""
+        self._create()
+        for name in ('renames', DEFAULT_PROFILE_NAME):
+            with self.subTest(name=name):
+                with self.assertRaises(ConflictException) as context:
+                    self._create(name=name)
+                self.assertEqual(context.exception.detail['error_type'], 'profile_exists')
+
+        with self.assertRaises(ConflictException) as context:
+            self.catalog.delete(DEFAULT_PROFILE_NAME)
+        self.assertEqual(context.exception.detail['error_type'], 'built_in_profile')
+        self.assertTrue(self.catalog.delete('renames'))
+
+    def test_invalid_options(self):
+        """Test that a profile with an unknown or invalid option is neither created nor updated."""
+        for options in ({'kgram': 9}, {'fingerprint_kgram_size': 1}):
+            with self.subTest(options=options):
+                with self.assertRaises(ValidationException) as context:
+                    self._create(detection_options=options)
+                self.assertEqual(context.exception.detail['error_type'], 'invalid_detection_options')
+
+        self._create()
+        with self.assertRaises(ValidationException):
+            self.catalog.update('renames', {'detection_options': {'fingerprint_kgram_size': 1}})
+        self.assertEqual(self.catalog.get('renames')['detection_options']['fingerprint_kgram_size'], 5)
+
+    def test_resolve(self):
+        """Test that a run resolves the options of its profile and overrides, the built-in one if none is named."""
+        self._create()
+
+        self.assertEqual(self.catalog.resolve()['detection_options']['normalize_identifiers'], False)
+        effective = self.catalog.resolve('renames', {'fingerprint_kgram_size': 9})
+        self.assertEqual(effective['detection_options'], {'normalize_identifiers': True, 'fingerprint_kgram_size': 9})
+        self.assertEqual(self.other_tenant.resolve('renames', tenant_id='tenant-a')['flag_threshold'], 0.6)
+
+        for name, overrides, error_type in (
+            ('missing', None, 'unknown_analysis_profile'),
+            ('renames', {'fingerprint_kgram_size': 1}, 'invalid_detection_options'),
+            ('renames', {'flag_threshold': 1.5}, 'invalid_detection_options'),
+        ):
+            with self.subTest(name=name, overrides=overrides):
+                with self.assertRaises(ValidationException) as context:
+                    self.catalog.resolve(name, overrides)
+                self.assertEqual(context.exception.detail['error_type'], error_type)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,55 @@
+"""
+Tests for AnalysisProfileResolver
+"""
+
+import unittest
+
+from app.domains.submissions.analysis_profile_resolver import DEFAULT_PROFILE_NAME, AnalysisProfileResolver
+
+
+class TestAnalysisProfileResolver(unittest.TestCase):
+    """Unit tests for the resolution of the effective options of a run from its profile and overrides."""
+
+    def setUp(self):
+        self.resolver = AnalysisProfileResolver({'normalize_identifiers': False, 'fingerprint_kgram_size': 5})
+        self.profile = {
+            'name': 'renames',
+            'detection_options': {'normalize_identifiers': True},
+            'flag_threshold': 0.6,
+            'min_token_count': None,
+        }
+
+    def test_built_in(self):
+        """Test that the built-in profile compares with the default options, leaving the flagging to the step."""
+        effective = self.resolver.resolve(self.resolver.built_in())
+
+        self.assertEqual(self.resolver.built_in()['name'], DEFAULT_PROFILE_NAME)
+        self.assertEqual(effective['detection_options'], {'normalize_identifiers': False, 'fingerprint_kgram_size': 5})
+        self.assertEqual((effective['flag_threshold'], effective['min_token_count']), (None, None))
+
+    def test_overrides(self):
+        """Test that the overrides replace the options of the profile, those it lacks being the default ones."""
+        effective = self.resolver.resolve(self.profile, {'fingerprint_kgram_size': 9, 'min_token_count': 50})
+
+        self.assertEqual(effective['detection_options'], {'normalize_identifiers': True, 'fingerprint_kgram_size': 9})
+        self.assertEqual((effective['flag_threshold'], effective['min_token_count']), (0.6, 50))
+        self.assertEqual(effective['overrides'], {'fingerprint_kgram_size': 9, 'min_token_count': 50})
+
+    def test_invalid_overrides(self):
+        """Test that unknown options and out of range flagging are refused."""
+        with self.assertRaises(ValueError):
+            self.resolver.resolve(self.profile, {'kgram': 9})
+        with self.assertRaises(ValueError):
+            self.resolver.resolve(self.profile, {'flag_threshold': 1.5})
+        with self.assertRaises(ValueError):
+            self.resolver.resolve(self.profile, {'min_token_count': True})
+
+    def test_profile_unchanged(self):
+        """Test that resolving a run leaves its profile as it was."""
+        self.resolver.resolve(self.profile, {'normalize_identifiers': False})
+
+        self.assertEqual(self.profile['detection_options'], {'normalize_identifiers': True})
+
+
+if __name__ == '__main__':
+    unittest.main()
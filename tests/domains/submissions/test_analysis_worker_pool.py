@@ -0,0 +1,206 @@
+"""
+Tests for AnalysisWorkerPool
+"""
+
+import threading
+import time
+import unittest
+from datetime import datetime
+
+from app.domains.submissions.analysis_worker_pool import (
+    AnalysisCancelled,
+    AnalysisPoolDraining,
+    AnalysisQueueFull,
+    AnalysisWorkerPool,
+    BackpressurePolicy,
+)
+from app.domains.submissions.processing_lifecycle import ProcessingLifecycle
+from app.domains.submissions.submissions_models import ProcessingStatus
+
+# Stages a submission may be left in once the analysis is drained
+RESTING_STATUSES = {ProcessingStatus.ANALYZED, ProcessingStatus.FAILED, ProcessingStatus.PENDING_RETRY}
+
+
+class TestAnalysisWorkerPool(unittest.TestCase):
+    """Unit tests for the bounded queue of the analysis workers."""
+
+    def setUp(self):
+        self.release = threading.Event()
+        self.started = threading.Event()
+
+    def _busy_pool(self, policy, block_seconds=0.05):
+        """Pool of one worker kept busy by a job, with a queue of one slot"""
+        pool = AnalysisWorkerPool(1, 1, policy, block_seconds=block_seconds, retry_after_seconds=7)
+        self.addCleanup(pool.shutdown)
+        self.addCleanup(self.release.set)
+        pool.submit(lambda: (self.started.set(), self.release.wait(5)))
+        self.assertTrue(self.started.wait(5))
+        return pool
+
+    def test_reject(self):
+        """Test that a job is refused at once when the queue is full, with when to retry."""
+        pool = self._busy_pool(BackpressurePolicy.REJECT)
+        pool.submit(lambda: None)
+
+        with self.assertRaises(AnalysisQueueFull) as context:
+            pool.submit(lambda: None)
+        self.assertEqual(context.exception.retry_after_seconds, 7)
+        self.assertEqual(
+            pool.stats(),
+            {
+                'worker_count': 1,
+                'busy_workers': 1,
+                'queue_depth': 1,
+                'queue_capacity': 1,
+                'backpressure': 'reject',
+                'rejected_count': 1,
+                'draining': False,
+            },
+        )
+
+    def test_block(self):
+        """Test that a job waits for a free slot, and is refused only once the wait is over."""
+        pool = self._busy_pool(BackpressurePolicy.BLOCK, block_seconds=2)
+        done = threading.Event()
+        pool.submit(lambda: None)
+
+        threading.Timer(0.05, self.release.set).start()
+        pool.submit(done.set)
+        self.assertTrue(done.wait(5))
+
+        self.release.clear()
+        self.started.clear()
+        pool.submit(lambda: (self.started.set(), self.release.wait(5)))
+        self.assertTrue(self.started.wait(5))
+        pool.submit(lambda: None)
+        pool.block_seconds = 0.05
+        start = time.monotonic()
+        with self.assertRaises(AnalysisQueueFull):
+            pool.ensure_capacity()
+        self.assertGreaterEqual(time.monotonic() - start, 0.04)
+
+    def test_shutdown(self):
+        """Test that a stopped pool drops its queued jobs and its workers return after their current job."""
+        pool = self._busy_pool(BackpressurePolicy.REJECT)
+        ran = threading.Event()
+        pool.submit(ran.set)
+
+        pool.shutdown()
+        self.release.set()
+        pool.shutdown(wait=True, timeout=5)
+
+        self.assertTrue(pool.cancelled)
+        self.assertFalse(ran.is_set())
+        self.assertFalse(pool.wait_for_capacity(0))
+        with self.assertRaises(RuntimeError):
+            pool.submit(lambda: None)
+
+
+    def test_priority_and_aging(self):
+        """Test that the jobs of higher priority are served first, unless a lower one waited long enough."""
+        now = [0.0]
+        pool = AnalysisWorkerPool(1, 10, BackpressurePolicy.REJECT, aging_seconds=100, clock=lambda: now[0])
+        self.addCleanup(pool.shutdown)
+        self.addCleanup(self.release.set)
+        served = []
+        done = threading.Event()
+        pool.submit(lambda: (self.started.set(), self.release.wait(5)))
+        self.assertTrue(self.started.wait(5))
+
+        pool.submit(served.append, 'old low', priority=0, job_id='old low')
+        now[0] = 250.0
+        pool.submit(served.append, 'normal', priority=1)
+        pool.submit(served.append, 'urgent', priority=3, job_id='urgent')
+        pool.submit(served.append, 'high', priority=2)
+        pool.submit(done.set)
+
+        self.assertEqual(pool.queue_position('urgent'), {'position': 1, 'effective_priority': 3.0})
+        self.assertEqual(pool.queue_position('old low'), {'position': 2, 'effective_priority': 2.5})
+        self.assertIsNone(pool.queue_position('missing'))
+        self.release.set()
+        self.assertTrue(done.wait(5))
+        self.assertEqual(served, ['urgent', 'old low', 'high', 'normal'])
+
+    def test_reprioritize(self):
+        """Test that a priority change of a group re-orders its queued jobs, and only them."""
+        pool = AnalysisWorkerPool(1, 10, BackpressurePolicy.REJECT, aging_seconds=0)
+        self.addCleanup(pool.shutdown)
+        self.addCleanup(self.release.set)
+        served = []
+        done = threading.Event()
+        pool.submit(lambda: (self.started.set(), self.release.wait(5)))
+        self.assertTrue(self.started.wait(5))
+
+        pool.submit(served.append, 'next month', priority=0, group='next month')
+        pool.submit(served.append, 'explicit', priority=1)
+        pool.submit(served.append, 'tomorrow', priority=1, group='tomorrow')
+        pool.submit(done.set, priority=-1)
+
+        self.assertEqual(pool.reprioritize('tomorrow', 3), 1)
+        self.assertEqual(pool.reprioritize('unknown', 3), 0)
+        self.release.set()
+        self.assertTrue(done.wait(5))
+        self.assertEqual(served, ['tomorrow', 'explicit', 'next month'])
+
+    def _processing(self, pool, statuses, file_count):
+        """Job processing a submission file by file like the analysis, left pending retry once cancelled"""
+        lock = threading.Lock()
+
+        def move(name, status):
+            with lock:
+                changes = ProcessingLifecycle.transition(statuses[name], [], status, datetime.now())
+                statuses[name] = changes['processing_status']
+
+        def process(name):
+            try:
+                move(name, ProcessingStatus.EXTRACTING)
+                move(name, ProcessingStatus.TOKENIZING)
+                self.started.set()
+                for _ in range(file_count):
+                    time.sleep(0.01)
+                    pool.check_cancelled()
+                move(name, ProcessingStatus.ANALYZED)
+            except AnalysisCancelled:
+                move(name, ProcessingStatus.PENDING_RETRY)
+
+        return process, lambda name: move(name, ProcessingStatus.PENDING_RETRY)
+
+    def test_drain_cancels(self):
+        """Test that a drain cancelled mid-run leaves no submission in progress, and refuses the new jobs."""
+        pool = AnalysisWorkerPool(1, 2, BackpressurePolicy.REJECT, retry_after_seconds=7)
+        self.addCleanup(pool.shutdown)
+        statuses = {'running': ProcessingStatus.RECEIVED, 'queued': ProcessingStatus.RECEIVED}
+        process, interrupt = self._processing(pool, statuses, file_count=1_000)
+        pool.submit(process, 'running', on_drop=lambda: interrupt('running'))
+        self.assertTrue(self.started.wait(5))
+        pool.submit(process, 'queued', on_drop=lambda: interrupt('queued'))
+
+        self.assertFalse(pool.drain(0.1, cancel_grace_seconds=5))
+
+        self.assertEqual(
+            statuses, {'running': ProcessingStatus.PENDING_RETRY, 'queued': ProcessingStatus.PENDING_RETRY}
+        )
+        self.assertTrue(set(statuses.values()) <= RESTING_STATUSES)
+        self.assertTrue(pool.stats()['draining'])
+        with self.assertRaises(AnalysisPoolDraining) as context:
+            pool.ensure_capacity()
+        self.assertEqual(context.exception.retry_after_seconds, 7)
+        self.assertFalse(pool.wait_for_capacity(0))
+
+    def test_drain_waits(self):
+        """Test that a drain lets the running jobs finish within its timeout."""
+        pool = AnalysisWorkerPool(1, 1, BackpressurePolicy.REJECT)
+        self.addCleanup(pool.shutdown)
+        statuses = {'running': ProcessingStatus.RECEIVED}
+        process, interrupt = self._processing(pool, statuses, file_count=5)
+        pool.submit(process, 'running', on_drop=lambda: interrupt('running'))
+        self.assertTrue(self.started.wait(5))
+
+        self.assertTrue(pool.drain(5))
+
+        self.assertEqual(statuses, {'running': ProcessingStatus.ANALYZED})
+        self.assertTrue(pool.cancelled)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,56 @@
+"""
+Tests for AutoRunPolicy
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+
+from app.domains.submissions.auto_run_policy import AutoRunPolicy
+from app.domains.submissions.submissions_models import AutoRunStatus
+
+
+class TestAutoRunPolicy(unittest.TestCase):
+    """Unit tests for the rescheduling of the detection runs scheduled for the project steps."""
+
+    def setUp(self):
+        self.auto_run_at = datetime(2024, 1, 16, 20, 0, tzinfo=timezone.utc)
+
+    def _schedule(self, status=AutoRunStatus.SCHEDULED, auto_run_at=None, profile='default'):
+        return SimpleNamespace(
+            auto_run_status=status, auto_run_at=auto_run_at or self.auto_run_at, auto_run_profile=profile
+        )
+
+    def test_first_schedule(self):
+        """Test that the run of a step without schedule, or without scheduled run, is scheduled."""
+        self.assertTrue(AutoRunPolicy.is_rescheduled(None, self.auto_run_at, 'default'))
+        self.assertTrue(AutoRunPolicy.is_rescheduled(self._schedule(status=None), self.auto_run_at, 'default'))
+
+    def test_started_run_saved_unchanged(self):
+        """Test that a started run saved with its time and profile, e.g. along a new deadline, is not started again."""
+        started = self._schedule(AutoRunStatus.STARTED)
+
+        self.assertFalse(AutoRunPolicy.is_rescheduled(started, self.auto_run_at, 'default'))
+
+    def test_changed_time_or_profile(self):
+        """Test that a run saved with another time or profile is scheduled again, even once started."""
+        started = self._schedule(AutoRunStatus.STARTED)
+
+        self.assertTrue(AutoRunPolicy.is_rescheduled(started, self.auto_run_at + timedelta(hours=1), 'default'))
+        self.assertTrue(AutoRunPolicy.is_rescheduled(started, self.auto_run_at, 'strict'))
+
+    def test_failed_run_retried(self):
+        """Test that a run whose start failed is scheduled again when saved unchanged."""
+        failed = self._schedule(AutoRunStatus.FAILED)
+
+        self.assertTrue(AutoRunPolicy.is_rescheduled(failed, self.auto_run_at, 'default'))
+
+    def test_naive_time_read_back(self):
+        """Test that a time read back without time zone is compared with the aware time given."""
+        stored = self._schedule(AutoRunStatus.STARTED, auto_run_at=self.auto_run_at.replace(tzinfo=None))
+
+        self.assertFalse(AutoRunPolicy.is_rescheduled(stored, self.auto_run_at, 'default'))
+
+
+if __name__ == '__main__':
+    unittest.main()
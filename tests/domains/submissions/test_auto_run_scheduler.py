@@ -0,0 +1,132 @@
+"""
+Tests for AutoRunScheduler
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.auto_run_scheduler import AUTO_RUN_FIELDS, AutoRunScheduler
+from app.domains.submissions.submissions_models import AutoRunStatus, ProcessingStatus
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class FakeScheduleRepository:
+    """Schedules of the project steps, the claimed ones given"""
+
+    def __init__(self, schedules, claimed=()):
+        self.schedules = {schedule.id: schedule for schedule in schedules}
+        self.claimed = set(claimed)
+        self.updates = []
+
+    def get_by_project_step(self, project_uuid, project_step_uuid):
+        return next((s for s in self.schedules.values() if s.project_step_uuid == project_step_uuid), None)
+
+    def get_due(self, now):
+        return [s for s in self.schedules.values() if s.auto_run_at is not None and s.auto_run_at <= now]
+
+    def claim_auto_run(self, schedule_id, auto_run_at):
+        return schedule_id in self.claimed
+
+    def update(self, schedule_id, changes):
+        self.updates.append((schedule_id, changes))
+
+
+class TestAutoRunScheduler(unittest.TestCase):
+    """Unit tests for the scheduling, cancellation and start of the detection runs of the project steps."""
+
+    def setUp(self):
+        self.now = datetime(2024, 1, 16, 20, 0, tzinfo=timezone.utc)
+        self.project_uuid = uuid4()
+        self.started = []
+        self.submissions = [
+            SimpleNamespace(id=uuid4(), processing_status=ProcessingStatus.ANALYZED),
+            SimpleNamespace(id=uuid4(), processing_status=ProcessingStatus.FAILED),
+        ]
+        self.submission_repository = SimpleNamespace(get_by_project_step=lambda *args, **kwargs: self.submissions)
+
+    def _schedule(self, auto_run_at=None, **fields):
+        return SimpleNamespace(
+            id=uuid4(),
+            project_uuid=self.project_uuid,
+            project_step_uuid=uuid4(),
+            auto_run_at=auto_run_at,
+            auto_run_profile='default',
+            auto_run_status=AutoRunStatus.SCHEDULED if auto_run_at else None,
+            **fields,
+        )
+
+    def _start_run(self, project_uuid, project_step_uuid, submission_ids, profile):
+        self.started.append((project_step_uuid, submission_ids, profile))
+        return SimpleNamespace(id=uuid4())
+
+    def _scheduler(self, schedule_repository, start_run=None):
+        return AutoRunScheduler(schedule_repository, self.submission_repository, start_run or self._start_run)
+
+    def test_schedule_fields(self):
+        """Test that a new run is reset as scheduled, an unchanged one kept, and one without time cleared."""
+        started = self._schedule(self.now, auto_run_id=uuid4())
+        started.auto_run_status = AutoRunStatus.STARTED
+        data = {'deadline': self.now, 'auto_run_at': self.now, 'auto_run_profile': 'default'}
+
+        fields, rescheduled = AutoRunScheduler.schedule_fields(None, data)
+        self.assertTrue(rescheduled)
+        self.assertEqual(fields['auto_run_status'], AutoRunStatus.SCHEDULED)
+        self.assertIsNone(fields['auto_run_id'])
+        self.assertEqual(fields['deadline'], self.now)
+
+        self.assertEqual(AutoRunScheduler.schedule_fields(started, data), (data, False))
+
+        fields, rescheduled = AutoRunScheduler.schedule_fields(started, {'deadline': self.now, 'auto_run_at': None})
+        self.assertFalse(rescheduled)
+        self.assertEqual({field: fields[field] for field in AUTO_RUN_FIELDS}, dict.fromkeys(AUTO_RUN_FIELDS))
+
+    def test_cancel(self):
+        """Test that a scheduled run is cleared, and a step without scheduled run is not found."""
+        scheduled, unscheduled = self._schedule(self.now), self._schedule()
+        repository = FakeScheduleRepository([scheduled, unscheduled])
+        scheduler = self._scheduler(repository)
+
+        scheduler.cancel(self.project_uuid, scheduled.project_step_uuid)
+        with self.assertRaises(NotFoundException):
+            scheduler.cancel(self.project_uuid, unscheduled.project_step_uuid)
+        with self.assertRaises(NotFoundException):
+            scheduler.cancel(self.project_uuid, uuid4())
+
+        self.assertEqual(repository.updates, [(scheduled.id, dict.fromkeys(AUTO_RUN_FIELDS))])
+
+    def test_start_due(self):
+        """Test that only the due runs claimed are started, over the analyzed submissions, their run recorded."""
+        due, unclaimed = self._schedule(self.now - timedelta(hours=1)), self._schedule(self.now)
+        later = self._schedule(self.now + timedelta(hours=1))
+        repository = FakeScheduleRepository([due, unclaimed, later], claimed=[due.id, later.id])
+
+        [outcome] = self._scheduler(repository).start_due(self.now)
+
+        self.assertEqual(self.started, [(due.project_step_uuid, [self.submissions[0].id], 'default')])
+        self.assertEqual(outcome['status'], AutoRunStatus.STARTED.value)
+        self.assertEqual(repository.updates, [(due.id, {'auto_run_id': outcome['run_id']})])
+
+    def test_failed_start_recorded(self):
+        """Test that a run failing to start is recorded as failed with its error, the other runs still started."""
+        failing, due = self._schedule(self.now), self._schedule(self.now)
+        repository = FakeScheduleRepository([failing, due], claimed=[failing.id, due.id])
+
+        def start_run(project_uuid, project_step_uuid, submission_ids, profile):
+            if project_step_uuid == failing.project_step_uuid:
+                raise ValidationException("Analysis profile 'default' not found")
+            return self._start_run(project_uuid, project_step_uuid, submission_ids, profile)
+
+        outcomes = self._scheduler(repository, start_run).start_due(self.now)
+
+        self.assertEqual([outcome['status'] for outcome in outcomes], ['failed', 'started'])
+        self.assertEqual(outcomes[0]['error'], "Analysis profile 'default' not found")
+        self.assertEqual(
+            repository.updates[0],
+            (failing.id, {'auto_run_status': AutoRunStatus.FAILED, 'auto_run_error': outcomes[0]['error']}),
+        )
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,72 @@
+"""
+Tests for BinaryFileDetector
+"""
+
+import tempfile
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.binary_file_detector import SNIFF_BYTES, BinaryFileDetector
+
+
+class TestBinaryFileDetector(unittest.TestCase):
+    """Unit tests for the magic bytes and heuristics classifying the binary files."""
+
+    def setUp(self):
+        """Set up test fixtures."""
+        self.detector = BinaryFileDetector()
+
+    def test_magic_bytes(self):
+        """Test that the common binary formats are recognized by their signature."""
+        samples = {
+            b'\x7fELF\x02\x01\x01' + bytes(16): 'elf',
+            b'\xca\xfe\xba\xbe\x00\x00\x00\x34': 'java-class',
+            b'\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR': 'png',
+            b'\xff\xd8\xff\xe0\x00\x10JFIF': 'jpeg',
+            b'PK\x03\x04\x14\x00\x08\x00': 'zip',
+            b'RIFF\x24\x00\x00\x00WEBPVP8 ': 'webp',
+            b'MZ\x90\x00\x03\x00\x00\x00': 'pe',
+            b'\x00asm\x01\x00\x00\x00': 'wasm',
+        }
+        for content, binary_format in samples.items():
+            with self.subTest(binary_format=binary_format):
+                self.assertEqual(self.detector.detect(content), binary_format)
+
+    def test_pdf_is_binary(self):
+        """Test that a PDF is binary, even when its start reads as text."""
+        content = b'%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n'
+
+        self.assertEqual(self.detector.detect(content), 'pdf')
+
+    def test_null_bytes_and_control_characters(self):
+        """Test that an unknown content with NUL bytes or mostly control characters is binary."""
+        self.assertEqual(self.detector.detect(b'\x13\x37' + b'\x00' * 64), 'binary')
+        self.assertEqual(self.detector.detect(bytes(range(1, 8)) * 100), 'binary')
+
+    def test_texts_are_not_binary(self):
+        """Test that source code, UTF-16 texts and texts beginning like a weak signature are not binary."""
+        for content in (
+            b'int main(void) {\n\treturn 0;\n}\n',
+            'def café():\n    return "été"\n'.encode(),
+            '// UTF-16 source\nclass A {}\n'.encode('utf-16'),
+            b'BMI = weight / height ** 2\n',
+            b'MZ_MAX = 10\n',
+            b'',
+        ):
+            with self.subTest(content=content[:20]):
+                self.assertIsNone(self.detector.detect(content))
+
+    def test_detect_file_reads_only_the_start(self):
+        """Test that a file is classified by its start, a missing file being no binary."""
+        with tempfile.TemporaryDirectory() as directory:
+            path = Path(directory) / 'main.c'
+            path.write_bytes(b'x = 1\n' * SNIFF_BYTES + b'\x00')
+
+            self.assertIsNone(self.detector.detect_file(path))
+            path.write_bytes(b'\x7fELF' + bytes(64))
+            self.assertEqual(self.detector.detect_file(path), 'elf')
+            self.assertIsNone(self.detector.detect_file(Path(directory) / 'missing.c'))
+
+
+if __name__ == '__main__':
+    unittest.main()
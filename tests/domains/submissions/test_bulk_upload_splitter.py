@@ -0,0 +1,88 @@
+"""
+Tests for BulkUploadSplitter
+"""
+
+import unittest
+from uuid import UUID, uuid4
+
+from app.domains.repositories.archive_extractor import ArchiveExtractionResult, ArchiveFile
+from app.domains.submissions.bulk_upload_splitter import BulkUploadSplitter
+
+
+class TestBulkUploadSplitter(unittest.TestCase):
+    """Unit tests for the split of a bulk upload into the submissions of the students."""
+
+    def setUp(self):
+        self.splitter = BulkUploadSplitter('{lastname}_{firstname}_{id}')
+
+    def test_match(self):
+        """Test that the placeholders are read from the directory names, the identifier being the last part."""
+        self.assertEqual(
+            self.splitter.match('van_der_berg_john_1234'),
+            {'lastname': 'van_der_berg', 'firstname': 'john', 'id': '1234'},
+        )
+        self.assertIsNone(self.splitter.match('john-1234'))
+        self.assertEqual(BulkUploadSplitter().match('1234'), {'id': '1234'})
+
+    def test_invalid_pattern(self):
+        """Test that a pattern without the student identifier, or repeating a placeholder, is rejected."""
+        with self.assertRaises(ValueError):
+            BulkUploadSplitter('{lastname}_{firstname}')
+        with self.assertRaises(ValueError):
+            BulkUploadSplitter('{id}_{id}')
+
+    def test_split(self):
+        """Test that one entry is made per directory, the bad ones with their error rather than failing."""
+        extraction = ArchiveExtractionResult(
+            files=[
+                ArchiveFile(path='doe_jane_1/main.py', content=b'print(1)\n'),
+                ArchiveFile(path='doe_jane_1/work/util.py', content=b'x = 1\n', archive='doe_jane_1/work.zip'),
+                ArchiveFile(path='smith_john_2/main.py', content=b'print(2)\n'),
+                ArchiveFile(path='not-a-student/main.py', content=b'print(3)\n'),
+                ArchiveFile(path='index.html', content=b'<html></html>'),
+            ],
+            skipped_entries=[{'path': 'empty_dir_3/link', 'reason': 'symbolic link'}],
+        )
+
+        entries, root_files = self.splitter.split(extraction)
+
+        self.assertEqual(
+            [entry.directory for entry in entries], ['doe_jane_1', 'empty_dir_3', 'not-a-student', 'smith_john_2']
+        )
+        self.assertEqual(root_files, ['index.html'])
+        jane = entries[0]
+        self.assertEqual((jane.student_id, jane.fields['firstname'], jane.error), ('1', 'jane', None))
+        self.assertEqual([file.path for file in jane.files], ['main.py', 'work/util.py'])
+        self.assertEqual(jane.files[1].archive, 'work.zip')
+        self.assertEqual(entries[1].error, 'The directory holds no file to submit')
+        self.assertEqual(entries[1].skipped_entries, [{'path': 'link', 'reason': 'symbolic link'}])
+        self.assertIn('does not match the pattern', entries[2].error)
+        self.assertIsNone(entries[2].student_id)
+
+    def test_wrapping_directory(self):
+        """Test that a single directory wrapping the directories of the students is looked into."""
+        extraction = ArchiveExtractionResult(
+            files=[
+                ArchiveFile(path='assignment-1/doe_jane_1/main.py', content=b'print(1)\n'),
+                ArchiveFile(path='assignment-1/smith_john_2/main.py', content=b'print(2)\n'),
+            ]
+        )
+
+        entries, _ = self.splitter.split(extraction)
+
+        self.assertEqual([entry.student_id for entry in entries], ['1', '2'])
+        self.assertEqual(entries[0].files[0].path, 'main.py')
+
+    def test_student_uuid(self):
+        """Test that a student identifier maps to the same UUID within a namespace, a UUID to itself."""
+        namespace, student = uuid4(), uuid4()
+        student_uuid = BulkUploadSplitter.student_uuid('1234', namespace)
+
+        self.assertIsInstance(student_uuid, UUID)
+        self.assertEqual(BulkUploadSplitter.student_uuid('1234', namespace), student_uuid)
+        self.assertNotEqual(BulkUploadSplitter.student_uuid('1234', uuid4()), student_uuid)
+        self.assertEqual(BulkUploadSplitter.student_uuid(str(student), namespace), student)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,62 @@
+"""
+Tests for ChunkedUploadStore
+"""
+
+import hashlib
+import tempfile
+import unittest
+from pathlib import Path
+from uuid import uuid4
+
+from app.domains.repositories.object_storage import LocalObjectStorage
+from app.domains.submissions.chunked_upload_store import ChunkConflictError, ChunkedUploadStore
+
+
+class TestChunkedUploadStore(unittest.TestCase):
+    """Unit tests for the chunks of the resumable uploads."""
+
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+        self.store = ChunkedUploadStore(LocalObjectStorage(Path(self.directory.name)))
+        self.upload_id = uuid4()
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    def test_resumed_upload(self):
+        """Test that the missing chunks are reported, and the chunks assembled in order whatever their arrival."""
+        self.assertTrue(self.store.write_chunk(self.upload_id, 2, b'!'))
+        self.assertTrue(self.store.write_chunk(self.upload_id, 0, b'hello '))
+
+        self.assertEqual(self.store.missing_chunks(self.upload_id, 3), [1])
+        self.store.write_chunk(self.upload_id, 1, b'world')
+        content, checksum = self.store.assemble(self.upload_id, 3)
+
+        self.assertEqual(self.store.received_chunks(self.upload_id), [0, 1, 2])
+        self.assertEqual(content, b'hello world!')
+        self.assertEqual(checksum, hashlib.sha256(b'hello world!').hexdigest())
+
+    def test_idempotent_chunk(self):
+        """Test that a chunk received again with the same content is a no-op, with another content a conflict."""
+        self.store.write_chunk(self.upload_id, 0, b'content')
+
+        self.assertFalse(self.store.write_chunk(self.upload_id, 0, b'content'))
+        with self.assertRaises(ChunkConflictError):
+            self.store.write_chunk(self.upload_id, 0, b'other content')
+        self.assertEqual(self.store.assemble(self.upload_id, 1)[0], b'content')
+
+    def test_orphaned_uploads(self):
+        """Test that only the chunks of the uploads without a session are deleted."""
+        orphan = uuid4()
+        self.store.write_chunk(self.upload_id, 0, b'kept')
+        self.store.write_chunk(orphan, 0, b'orphaned')
+
+        self.assertEqual(self.store.orphaned_uploads([self.upload_id]), [str(orphan)])
+        self.assertEqual(self.store.delete_orphaned([self.upload_id]), 1)
+        self.assertEqual(self.store.received_chunks(orphan), [])
+        self.store.delete(self.upload_id)
+        self.assertEqual(self.store.missing_chunks(self.upload_id, 1), [0])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,146 @@
+"""
+Tests for CodeMetricsAnalyzer
+"""
+
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.code_metrics_analyzer import CodeMetricsAnalyzer
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.tokenization_service import TokenizationService
+
+
+class TestCodeMetricsAnalyzer(unittest.TestCase):
+    """Unit tests for the lines, functions and complexity metrics of the files of a submission."""
+
+    def setUp(self):
+        self.analyzer = CodeMetricsAnalyzer()
+        self.samples_dir = Path(__file__).parent.parent.parent.parent / "resources" / "test" / "language_samples"
+
+    def _token(self, token_type, start, end, start_column=0, end_column=1, text='x'):
+        return {
+            'type': token_type,
+            'text': text,
+            'start': start,
+            'end': end,
+            'start_column': start_column,
+            'end_column': end_column,
+        }
+
+    def _function_tokens(self, start, text):
+        """Tokens of a two lines function returning its parameter"""
+        return [
+            self._token('function_definition', start, start + 1, 0, 12, text=text),
+            self._token('identifier', start, start, 4, 5),
+            self._token('return_statement', start + 1, start + 1, 4, 12),
+        ]
+
+    def test_lines(self):
+        """Test that the lines holding nothing but comments are told apart from the code and blank lines."""
+        content = '# header\n\nx = 1  # trailing\n"""\ndoc\n"""\n'
+        tokens = [
+            self._token('comment', 0, 0, 0, 8),
+            self._token('expression_statement', 2, 2, 0, 5),
+            self._token('comment', 2, 2, 7, 17),
+            self._token('expression_statement', 3, 5, 0, 3),
+        ]
+
+        metrics = self.analyzer.analyze_file('main.py', content, tokens, 'python')
+
+        self.assertEqual(metrics['physical_lines'], 6)
+        self.assertEqual(metrics['blank_lines'], 1)
+        self.assertEqual(metrics['comment_lines'], 1)
+        self.assertEqual(metrics['code_lines'], 4)
+        self.assertEqual(metrics['comment_ratio'], 0.2)
+
+    def test_functions(self):
+        """Test that a function counts its lexical tokens and 1 plus its decision points as complexity."""
+        content = 'def f(x):\n    if x:\n        return 1\n    return 2\n'
+        tokens = [
+            self._token('function_definition', 0, 3, 0, 12, text=content.rstrip()),
+            self._token('identifier', 0, 0, 4, 5),
+            self._token('if_statement', 1, 2, 4, 16),
+            self._token('identifier', 1, 1, 7, 8),
+            self._token('return_statement', 2, 2, 8, 16),
+            self._token('integer', 2, 2, 15, 16),
+            self._token('return_statement', 3, 3, 4, 12),
+            self._token('integer', 3, 3, 11, 12),
+            self._token('identifier', 5, 5, 0, 1),
+        ]
+
+        metrics = self.analyzer.analyze_file('main.py', content, tokens, 'python')
+
+        self.assertEqual(metrics['functions'], [
+            {'name': 'f', 'start_line': 0, 'end_line': 3, 'tokens': 4, 'complexity': 2}
+        ])
+        self.assertEqual(metrics['average_function_tokens'], 4.0)
+        self.assertEqual((metrics['cyclomatic_complexity'], metrics['max_complexity']), (2, 2))
+
+    def test_unknown_language(self):
+        """Test that the languages without known functions and decision points only get line metrics."""
+        metrics = self.analyzer.analyze_file('style.css', 'a {\n  color: red;\n}\n', [], 'css')
+
+        self.assertEqual(metrics['code_lines'], 3)
+        self.assertEqual(metrics['function_count'], 0)
+        self.assertIsNone(metrics['average_function_tokens'])
+        self.assertIsNone(metrics['cyclomatic_complexity'])
+
+    def test_aggregate(self):
+        """Test that the metrics of the files are summed into those of the submission."""
+        first = self.analyzer.analyze_file('a.css', 'a {}\n/* b */\n', [self._token('comment', 1, 1, 0, 7)], 'css')
+        second = self.analyzer.analyze_file('b.css', 'b {}\n\n', [], 'css')
+
+        metrics = self.analyzer.aggregate([first, second])
+
+        self.assertEqual(metrics['file_count'], 2)
+        self.assertEqual(metrics['languages'], {'css': 2})
+        self.assertEqual((metrics['code_lines'], metrics['comment_lines'], metrics['blank_lines']), (2, 1, 1))
+        self.assertEqual(metrics['comment_ratio'], 0.333)
+        self.assertIsNone(metrics['cyclomatic_complexity'])
+
+    def test_merge_chunks(self):
+        """Test that the metrics of a file analyzed in chunks are those of the whole file."""
+        content = 'def f(x):\n    return x\n\n# g\ndef g(y):\n    return y\n'
+        tokens = self._function_tokens(0, 'def f(x):') + [self._token('comment', 3, 3, 0, 3)]
+        whole = self.analyzer.analyze_file('main.py', content, tokens + self._function_tokens(4, 'def g(y):'), 'python')
+        first = self.analyzer.analyze_file(
+            'main.py', 'def f(x):\n    return x\n\n', self._function_tokens(0, 'def f(x):'), 'python'
+        )
+        second = self.analyzer.analyze_file(
+            'main.py',
+            '# g\ndef g(y):\n    return y\n',
+            [self._token('comment', 0, 0, 0, 3)] + self._function_tokens(1, 'def g(y):'),
+            'python',
+        )
+
+        self.assertEqual(self.analyzer.merge_chunks('main.py', 'python', [(0, first), (3, second)]), whole)
+
+    def test_go_sample(self):
+        """Test that the Go sample gets deterministic metrics."""
+        path = self.samples_dir / 'sample.go'
+        content = path.read_text(encoding='utf-8')
+        result = TokenizationService().tokenize_with_details(content, path, TokenizationOptionsDto(strip_headers=False))
+
+        metrics = self.analyzer.analyze_file('sample.go', content, result.tokens, result.language)
+
+        self.assertEqual(metrics['language'], 'go')
+        self.assertEqual(metrics['physical_lines'], 115)
+        self.assertEqual(metrics['blank_lines'], 18)
+        self.assertEqual(metrics['comment_lines'], 13)
+        self.assertEqual(metrics['code_lines'], 84)
+        self.assertEqual(metrics['comment_ratio'], 0.134)
+        functions = {function['name']: function for function in metrics['functions']}
+        self.assertEqual(list(functions), ['Greet', 'Map', 'worker', 'main'])
+        self.assertEqual({name: function['complexity'] for name, function in functions.items()}, {
+            'Greet': 1, 'Map': 2, 'worker': 5, 'main': 6
+        })
+        self.assertEqual((metrics['cyclomatic_complexity'], metrics['max_complexity']), (14, 6))
+        self.assertEqual((functions['main']['start_line'], functions['main']['end_line']), (55, 114))
+        self.assertEqual(
+            metrics['average_function_tokens'], round(sum(f['tokens'] for f in metrics['functions']) / 4, 1)
+        )
+        self.assertEqual(metrics, self.analyzer.analyze_file('sample.go', content, result.tokens, result.language))
+
+
+if __name__ == '__main__':
+    unittest.main()
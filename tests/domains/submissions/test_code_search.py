@@ -0,0 +1,77 @@
+"""
+Tests for CodeSearch
+"""
+
+import unittest
+from types import SimpleNamespace
+
+from app.domains.detection.winnowing import Fingerprint, Winnower
+from app.domains.submissions.code_search import CodeSearch
+
+
+class TestCodeSearch(unittest.TestCase):
+    """Unit tests for the search of the submissions for a code snippet."""
+
+    def setUp(self):
+        self.winnower = Winnower()
+        self.search = CodeSearch(min_tokens=12)
+
+    def _index(self, submission_id, file_path, parts):
+        """Index entries of a file whose token of each part is on its own line"""
+        tokens = [{'start': line, 'end': line} for line in range(len(parts))]
+        entries = CodeSearch.index_entries(file_path, self.winnower.fingerprint(parts, tokens))
+        return [SimpleNamespace(submission_id=submission_id, **entry) for entry in entries]
+
+    def test_find(self):
+        """Test that a snippet copied into a file is found with its lines, a shorter shared run being ignored."""
+        snippet = [f'snippet{index}' for index in range(20)]
+        copied = [f'own{index}' for index in range(10)] + snippet + ['own_end']
+        partly = snippet[:6] + [f'other{index}' for index in range(20)]
+        query = self.winnower.fingerprint(snippet, [{'start': line, 'end': line} for line in range(len(snippet))])
+        entries = self._index('copied', 'main.py', copied) + self._index('partly', 'util.py', partly)
+
+        fragments = self.search.find(query, entries)
+
+        self.assertEqual([fragment['submission_id'] for fragment in fragments], ['copied'])
+        fragment = fragments[0]
+        self.assertEqual(fragment['file_path'], 'main.py')
+        self.assertTrue(10 <= fragment['start_line'] and fragment['end_line'] < 30)
+        self.assertEqual(fragment['start_line'] - fragment['snippet_start_line'], 10)
+        self.assertGreaterEqual(fragment['tokens'], 12)
+
+    def test_index_entries(self):
+        """Test that the entries hold the hexadecimal hashes looked up by the queries."""
+        fingerprint = Fingerprint(hash=255, position=3, start=1, end=2)
+
+        self.assertEqual(
+            CodeSearch.index_entries('a.py', [fingerprint]),
+            [{'file_path': 'a.py', 'hash': '00000000000000ff', 'position': 3, 'start_line': 1, 'end_line': 2}],
+        )
+        self.assertEqual(CodeSearch.hashes([fingerprint, fingerprint]), ['00000000000000ff'])
+
+    def test_find_substring(self):
+        """Test that each occurrence of a snippet is located by its lines, a blank snippet never matching."""
+        content = 'import os\n\ndef helper():\n    return 1\n\ndef helper():\n    return 1\n'
+
+        fragments = CodeSearch.find_substring('\ndef helper():\n    return 1\n', 'id', 'a.py', content)
+
+        self.assertEqual([(fragment['start_line'], fragment['end_line']) for fragment in fragments], [(2, 3), (5, 6)])
+        self.assertEqual(CodeSearch.find_substring('  \n', 'id', 'a.py', content), [])
+
+    def test_by_submission(self):
+        """Test that the fragments are grouped per submission, the most matched first."""
+        fragments = [
+            {'submission_id': 'a', 'file_path': 'x.py', 'tokens': 12},
+            {'submission_id': 'b', 'file_path': 'y.py', 'tokens': 30},
+            {'submission_id': 'a', 'file_path': 'z.py', 'tokens': 14},
+        ]
+
+        matches = CodeSearch.by_submission(fragments)
+
+        summary = [(match['submission_id'], match['matched_tokens']) for match in matches]
+        self.assertEqual(summary, [('b', 30), ('a', 26)])
+        self.assertEqual(matches[1]['fragments'][1], {'file_path': 'z.py', 'tokens': 14})
+
+
+if __name__ == '__main__':
+    unittest.main()
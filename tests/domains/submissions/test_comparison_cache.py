@@ -0,0 +1,93 @@
+"""
+Tests for ComparisonCache
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.comparison_cache import ComparisonCache
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+
+class TestComparisonCache(unittest.TestCase):
+    """Unit tests for the keys of the comparisons reused across the detection runs."""
+
+    def setUp(self):
+        self.options = {'tokenizer_version': 3, 'detection_options': {'normalize_identifiers': False}}
+        self.cache = ComparisonCache(self.options, [{'baseline': ['a1', 'b2']}])
+        self.first = self._submission({'main.py': 'h1'})
+        self.second = self._submission({'main.py': 'h2'})
+
+    def _submission(self, files):
+        return SimpleNamespace(id=uuid4(), analyzed_files={'tokenizer_version': 3, 'files': files})
+
+    def test_same_contents(self):
+        """Test that submissions of the same files have the same keys, in the order of the comparison."""
+        copy = self._submission({'main.py': 'h2'})
+
+        self.assertEqual(self.cache.key(self.first, self.second), self.cache.key(self.first, copy))
+        self.assertNotEqual(self.cache.key(self.first, self.second), self.cache.key(self.second, self.first))
+
+    def test_invalidation(self):
+        """Test that other options, references or contents change the key, but not the order of the references."""
+        key = self.cache.key(self.first, self.second)
+        options = {**self.options, 'detection_options': {'normalize_identifiers': True}}
+
+        self.assertNotEqual(ComparisonCache(options, [{'baseline': ['a1', 'b2']}]).key(self.first, self.second), key)
+        self.assertNotEqual(ComparisonCache(self.options, []).key(self.first, self.second), key)
+        self.assertNotEqual(self.cache.key(self.first, self._submission({'main.py': 'h3'})), key)
+        self.assertEqual(
+            ComparisonCache(self.options, [{'b': 2}, {'a': 1}]).options_hash,
+            ComparisonCache(self.options, [{'a': 1}, {'b': 2}]).options_hash,
+        )
+
+    def test_exclusions(self):
+        """Test that the paths excluded by a run change the key, each exclusion set having its own."""
+        keys = set()
+        for exclude_paths in ([], ['tests/'], ['tests/', 'data/**']):
+            detection_options = {'normalize_identifiers': False, 'exclude_paths': exclude_paths}
+            options = {**self.options, 'detection_options': detection_options}
+            keys.add(ComparisonCache(options, [{'baseline': ['a1', 'b2']}]).key(self.first, self.second))
+
+        self.assertEqual(len(keys), 3)
+        self.assertNotIn(self.cache.key(self.first, self.second), keys)
+
+    def test_tenants(self):
+        """Test that the comparisons of a tenant are keyed apart, those of the default tenant keeping their keys."""
+        references = [{'baseline': ['a1', 'b2']}]
+        key = self.cache.key(self.first, self.second)
+
+        self.assertEqual(ComparisonCache(self.options, references, 'default').key(self.first, self.second), key)
+        self.assertNotEqual(ComparisonCache(self.options, references, 'school-a').key(self.first, self.second), key)
+        self.assertNotEqual(
+            ComparisonCache(self.options, references, 'school-a').options_hash,
+            ComparisonCache(self.options, references, 'school-b').options_hash,
+        )
+
+    def test_not_analyzed(self):
+        """Test that a submission without analyzed files has no key."""
+        legacy = SimpleNamespace(id=uuid4(), analyzed_files=None)
+
+        self.assertIsNone(self.cache.key(self.first, legacy))
+        self.assertEqual(len(self.cache.keys([(self.first, self.second), (self.first, legacy)])), 2)
+
+    def test_is_current(self):
+        """Test that only the completed records of the current key of their pair are current."""
+        keys = self.cache.keys([(self.first, self.second)])
+        similarity = SimpleNamespace(
+            submission_id=self.second.id,
+            compared_submission_id=self.first.id,
+            status=SimilarityStatus.COMPLETED,
+            cache_key=self.cache.key(self.second, self.first),
+        )
+
+        self.assertTrue(ComparisonCache.is_current(similarity, keys))
+        similarity.status = SimilarityStatus.FAILED
+        self.assertFalse(ComparisonCache.is_current(similarity, keys))
+        similarity.status, similarity.cache_key = SimilarityStatus.COMPLETED, None
+        self.assertFalse(ComparisonCache.is_current(similarity, keys))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,100 @@
+"""
+Tests for ComparisonReportRenderer
+"""
+
+import unittest
+
+from app.domains.submissions.comparison_report import ComparisonReportRenderer
+
+
+class TestComparisonReportRenderer(unittest.TestCase):
+    """Unit tests for the HTML reports of the comparisons, rendered from their stored fragments."""
+
+    def _pair(self, left_source, right_source):
+        return {
+            'similarity_id': 'similarity-1',
+            'submission1': {'id': 'submission-1', 'link': 'https://github.com/user/repo1'},
+            'submission2': {'id': 'submission-2', 'link': 'https://github.com/user/repo2'},
+            'overall_similarity': 0.82,
+            'status': 'completed',
+            'suspicious': True,
+            'configuration': {'flag_threshold': 0.7, 'detection_options': {'normalize_identifiers': False}},
+            'fragments': [{
+                'left': {'file': 'main.go', 'start_line': 2, 'start_column': 0, 'end_line': 3, 'end_column': 1},
+                'right': {'file': 'worker.go', 'start_line': 0, 'start_column': 0, 'end_line': 1, 'end_column': 1},
+                'tokens': 40,
+                'similarity': 0.95,
+            }],
+            'sources': {'submission1': {'main.go': left_source}, 'submission2': {'worker.go': right_source}},
+        }
+
+    def test_pair_report(self):
+        """Test that both files are rendered with the fragment highlighted and anchored in both of them."""
+        report = ComparisonReportRenderer().render_pair(
+            self._pair('package main\n\nfunc a() {\n}\n', 'func a() {\n}\n<script>')
+        )
+
+        self.assertTrue(report.startswith('<!DOCTYPE html>'))
+        self.assertIn("id='pair-left-fragment-1'", report)
+        self.assertIn("id='pair-right-fragment-1'", report)
+        self.assertIn("href='#pair-left-fragment-1'>main.go:3-4</a>", report)
+        self.assertEqual(report.count("title='Fragment 1'"), 4)
+        self.assertIn('82.0%', report)
+        self.assertIn('normalize_identifiers', report)
+        self.assertIn('&lt;script&gt;', report)
+        self.assertNotIn('<details', report)
+
+    def test_large_files_collapsed(self):
+        """Test that the unmatched regions of large files are collapsed, the context of the fragments kept."""
+        source = '\n'.join(f'line {number}' for number in range(50))
+        pair = self._pair(source, source)
+        pair['sources']['submission2'] = {}
+
+        report = ComparisonReportRenderer(large_file_lines=20, context_lines=1).render_pair(pair)
+
+        self.assertIn('<summary>Lines 6 to 50 not matched</summary>', report)
+        self.assertIn('Source not stored with the comparison', report)
+        self.assertIn('Detection run run-1', ComparisonReportRenderer().render_run(
+            {'run_id': 'run-1', 'configuration': {'flag_threshold': 0.7}}, [pair]
+        ))
+
+    def test_file_breakdown(self):
+        """Test that the best match of each file is surfaced, along with the excluded files and the formula."""
+        pair = self._pair('func a() {\n}\n', 'func a() {\n}\n')
+        pair['file_similarities'] = {
+            'files1': [
+                {'file': 'main.go', 'tokens': 40, 'matched_tokens': 40, 'best_match': 'worker.go', 'similarity': 0.92}
+            ],
+            'files2': [{'file': 'util.go', 'tokens': 10, 'matched_tokens': 0, 'best_match': None, 'similarity': 0.0}],
+            'pairs': [],
+            'excluded_files': {'file1': [{'file': 'api.pb.go', 'reason': 'generated code: protobuf'}], 'file2': []},
+            'aggregation': {
+                'method': 'max', 'formula': 'max(similarity(f1, f2))', 'pair_formula': '', 'similarity': 0.92
+            },
+        }
+
+        report = ComparisonReportRenderer().render_pair(pair)
+
+        self.assertIn('main.go</td><td>matches worker.go in the compared submission at 92.0%', report)
+        self.assertIn('util.go</td><td>matches no file', report)
+        self.assertIn('api.pb.go (generated code: protobuf)', report)
+        self.assertIn('max(similarity(f1, f2))', report)
+
+    def test_printable_report(self):
+        """Test that the printable report has no collapsible region and lays both submissions out for pages."""
+        source = '\n'.join(f'line {number}' for number in range(50))
+
+        report = ComparisonReportRenderer(large_file_lines=20, context_lines=1, printable=True).render_pair(
+            self._pair(source, source)
+        )
+
+        self.assertNotIn('<details', report)
+        self.assertIn("<div class='unmatched'>Lines 6 to 50 not matched</div>", report)
+        self.assertNotIn('line 10<', report)
+        self.assertIn("<table class='sides'><tr><td><h3>Submission</h3>", report)
+        self.assertIn('@page', report)
+        self.assertIn("href='#pair-left-fragment-1'>main.go:3-4</a>", report)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,153 @@
+"""
+Tests for Corpora
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.corpora import Corpora
+from app.domains.submissions.submissions_models import CorpusKind, SimilarityStatus
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class FakeCorpusRepository:
+    """Corpora of all the tenants and their items kept in memory, with the corpora referenced by each step"""
+
+    def __init__(self):
+        self.corpora = {}
+        self.items = []
+        self.step_corpora = {}
+
+    def create(self, corpus_data):
+        corpus = SimpleNamespace(id=uuid4(), **{'kind': CorpusKind.ARCHIVE, **corpus_data})
+        self.corpora[corpus.id] = corpus
+        return corpus
+
+    def get_by_id(self, corpus_id, tenant_id):
+        corpus = self.corpora.get(corpus_id)
+        return corpus if corpus and corpus.tenant_id == tenant_id else None
+
+    def get_by_kind(self, kind, tenant_id):
+        return [corpus for corpus in self.corpora.values() if corpus.kind == kind and corpus.tenant_id == tenant_id]
+
+    def get_items(self, corpus_ids):
+        return [item for item in self.items if item.corpus_id in corpus_ids]
+
+    def create_item(self, item_data):
+        item = SimpleNamespace(id=uuid4(), **item_data)
+        self.items.append(item)
+        return item
+
+    def get_by_project_step(self, project_uuid, project_step_uuid):
+        return [self.corpora[corpus_id] for corpus_id in self.step_corpora.get(project_step_uuid, [])]
+
+    def save_project_step(self, project_uuid, project_step_uuid, corpus_ids):
+        self.step_corpora[project_step_uuid] = list(corpus_ids)
+
+
+class FakeMatchRepository:
+    """Corpus matches kept in memory"""
+
+    def __init__(self):
+        self.matches = []
+
+    def get_by_submission_ids(self, submission_ids):
+        return [match for match in self.matches if match.submission_id in submission_ids]
+
+    def create_many(self, matches_data):
+        self.matches += [SimpleNamespace(**match_data) for match_data in matches_data]
+
+
+class TestCorpora(unittest.TestCase):
+    """Unit tests for the reference corpora of a tenant and the matching of the submissions with them."""
+
+    def setUp(self):
+        self.repository = FakeCorpusRepository()
+        self.match_repository = FakeMatchRepository()
+        self.submission = SimpleNamespace(
+            id=uuid4(), project_uuid=uuid4(), project_step_uuid=uuid4(), tenant_id='tenant-a'
+        )
+        self.fingerprints = {'a', 'b', 'c', 'd'}
+        self.fingerprinted = []
+        self.corpora = self._corpora('tenant-a')
+        self.other_tenant = self._corpora('tenant-b')
+
+    def _corpora(self, tenant_id):
+        return Corpora(
+            self.repository,
+            self.match_repository,
+            SimpleNamespace(get_by_id={self.submission.id: self.submission}.get),
+            tenant_id,
+            self._fingerprint_link,
+            self._fingerprint_submission,
+        )
+
+    @staticmethod
+    def _fingerprint_link(corpus_id, link, link_type):
+        return {'link_type': link_type or 'github', 'file_count': 2, 'token_count': 120, 'fingerprints': ['a', 'b']}
+
+    def _fingerprint_submission(self, submission):
+        self.fingerprinted.append(submission.id)
+        if self.fingerprints is None:
+            raise RuntimeError('fetch failed')
+        return self.fingerprints
+
+    def _archive(self):
+        corpus = self.corpora.create({'label': '2023', 'description': None})
+        self.corpora.create_item(corpus.id, {'label': '2023/alice', 'link': 'https://github.com/alice/repo'})
+        self.corpora.save_step_corpora(self.submission.project_uuid, self.submission.project_step_uuid, [corpus.id])
+        return corpus
+
+    def test_archive(self):
+        """Test that an archived submission is stored fingerprinted, in a corpus of the tenant only."""
+        corpus = self._archive()
+
+        [item] = self.corpora.get_items(corpus.id)
+        self.assertEqual((item.link_type, item.file_count, item.fingerprints), ('github', 2, ['a', 'b']))
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.get_items(corpus.id)
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.create_item(corpus.id, {'label': 'bob', 'link': 'https://github.com/bob/repo'})
+
+    def test_step_corpora(self):
+        """Test that a step references only existing corpora of the tenant, and no reference set."""
+        corpus = self._archive()
+        reference_set = self.corpora.create({'label': 'textbook', 'kind': CorpusKind.REFERENCE})
+
+        for corpus_ids in ([uuid4()], [reference_set.id]):
+            with self.subTest(corpus_ids=corpus_ids):
+                with self.assertRaises(ValidationException):
+                    self.corpora.save_step_corpora(uuid4(), self.submission.project_step_uuid, corpus_ids)
+        with self.assertRaises(ValidationException):
+            self.other_tenant.save_step_corpora(uuid4(), uuid4(), [corpus.id])
+        with self.assertRaises(ValidationException):
+            self.corpora.create_item(reference_set.id, {'label': 'x', 'link': 'https://github.com/x/repo'})
+
+        steps = self.corpora.get_step_corpora(self.submission.project_uuid, self.submission.project_step_uuid)
+        self.assertEqual(steps, [corpus])
+
+    def test_match(self):
+        """Test that a submission is matched with the items of its step once, those matched being skipped."""
+        self._archive()
+
+        self.corpora.match(self.submission.id)
+        self.corpora.match(self.submission.id)
+
+        [match] = self.match_repository.matches
+        self.assertEqual(match.status, SimilarityStatus.COMPLETED)
+        self.assertEqual(self.fingerprinted, [self.submission.id])
+
+    def test_failed_match(self):
+        """Test that a matching failing to fingerprint the submission fails each pending item."""
+        self._archive()
+        self.fingerprints = None
+
+        self.corpora.match(self.submission.id)
+
+        [match] = self.match_repository.matches
+        self.assertEqual((match.status, match.error_message), (SimilarityStatus.FAILED, 'fetch failed'))
+
+
+if __name__ == '__main__':
+    unittest.main()
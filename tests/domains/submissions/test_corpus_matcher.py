@@ -0,0 +1,46 @@
+"""
+Tests for CorpusMatcher
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.corpus_matcher import CorpusMatcher
+
+
+class TestCorpusMatcher(unittest.TestCase):
+    """Unit tests for the matching of a submission with the archived submissions of a corpus."""
+
+    def test_containment_and_jaccard(self):
+        """Test that matches are scored by the share of the submission found in each item, most similar first."""
+        reused = SimpleNamespace(id=uuid4(), fingerprints=['a', 'b', 'c', 'd', 'x', 'y', 'z', 'w'])
+        unrelated = SimpleNamespace(id=uuid4(), fingerprints=['q'])
+        empty = SimpleNamespace(id=uuid4(), fingerprints=None)
+
+        matches = CorpusMatcher().match(['a', 'b', 'c', 'e'], [unrelated, empty, reused])
+
+        self.assertEqual(matches[0]['corpus_item_id'], reused.id)
+        self.assertEqual(matches[0]['overall_similarity'], 0.75)
+        self.assertEqual(matches[0]['jaccard_similarity'], round(3 / 9, 3))
+        self.assertEqual(matches[0]['shared_fingerprint_count'], 3)
+        self.assertEqual([match['overall_similarity'] for match in matches[1:]], [0.0, 0.0])
+        self.assertEqual(CorpusMatcher().match([], [reused])[0]['overall_similarity'], 0.0)
+
+    def test_reference_containment(self):
+        """Test that a reference file is scored by the share of its fingerprints found in the submission."""
+        small = SimpleNamespace(id=uuid4(), fingerprints=['a', 'b'])
+        partial = SimpleNamespace(id=uuid4(), fingerprints=['a', 'x', 'y', 'z'])
+        empty = SimpleNamespace(id=uuid4(), fingerprints=[])
+
+        matches = CorpusMatcher().match_references(['a', 'b', 'c', 'd', 'e', 'f', 'g', 'h'], [partial, empty, small])
+
+        self.assertEqual(matches[0]['corpus_item_id'], small.id)
+        self.assertEqual(matches[0]['overall_similarity'], 1.0)
+        self.assertEqual(matches[0]['jaccard_similarity'], 0.25)
+        self.assertEqual((matches[1]['overall_similarity'], matches[1]['shared_fingerprint_count']), (0.25, 1))
+        self.assertEqual(matches[2]['overall_similarity'], 0.0)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,172 @@
+"""
+Tests for DataRetention
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.data_retention import DataRetention
+from app.domains.submissions.submissions_models import RetentionAnchor
+from app.shared.exceptions import NotFoundException
+from tests.domains.submissions.factories import similarity
+
+
+class FakePolicyRepository:
+    """Retention policies of the project steps kept in memory"""
+
+    def __init__(self):
+        self.policies = {}
+
+    def save(self, project_uuid, project_step_uuid, policy_data):
+        policy = SimpleNamespace(project_uuid=project_uuid, project_step_uuid=project_step_uuid, **policy_data)
+        self.policies[project_step_uuid] = policy
+        return policy
+
+    def get_by_project_step(self, project_uuid, project_step_uuid):
+        return self.policies.get(project_step_uuid)
+
+    def get_all(self):
+        return list(self.policies.values())
+
+    def delete(self, project_uuid, project_step_uuid):
+        return self.policies.pop(project_step_uuid, None) is not None
+
+
+class FakePurgeRepository:
+    """Log of the purges kept in memory"""
+
+    def __init__(self):
+        self.purges = []
+
+    def create(self, purge_data):
+        self.purges.append(SimpleNamespace(**purge_data))
+
+    def list_recent(self, project_uuid, limit):
+        return self.purges[::-1][:limit]
+
+
+class FakeStepRepository:
+    """Records of a project step kept in memory, with the changes made to them"""
+
+    def __init__(self, records=()):
+        self.records = list(records)
+        self.changes = []
+
+    def get_by_project_step(self, project_uuid, project_step_uuid, **filters):
+        return self.records
+
+    def patch(self, record_id, changes):
+        self.changes.append(('patch', record_id, changes))
+
+    def purge_details(self, record_ids):
+        self.changes.append(('purge_details', record_ids))
+
+    def delete_by_ids(self, record_ids):
+        self.changes.append(('delete', record_ids))
+
+    def delete_by_submission_id(self, submission_id):
+        self.changes.append(('delete', [submission_id]))
+
+    def delete_by_similarity_ids(self, similarity_ids):
+        self.changes.append(('delete', similarity_ids))
+
+
+class TestDataRetention(unittest.TestCase):
+    """Unit tests for the retention policies of the project steps and the purges of their expired data."""
+
+    def setUp(self):
+        self.now = datetime(2024, 9, 1, 12, 0, tzinfo=timezone.utc)
+        self.project_uuid, self.project_step_uuid = uuid4(), uuid4()
+        self.submissions = [
+            SimpleNamespace(
+                id=uuid4(),
+                upload_date_time=self.now - timedelta(days=120),
+                integrity_case_open=False,
+                files_purged_at=None,
+            )
+            for _ in range(2)
+        ]
+        self.similarity = similarity(*[s.id for s in self.submissions], created_at=self.now - timedelta(days=200))
+        self.policy_repository = FakePolicyRepository()
+        self.purge_repository = FakePurgeRepository()
+        self.submission_repository = FakeStepRepository(self.submissions)
+        self.similarity_repository = FakeStepRepository([self.similarity])
+        self.fingerprint_repository = FakeStepRepository()
+        self.purged_submissions, self.deleted_uploads = [], []
+        self.retention = DataRetention(
+            self.policy_repository,
+            self.purge_repository,
+            SimpleNamespace(get_by_project_step=lambda *args: None),
+            self.submission_repository,
+            self.similarity_repository,
+            FakeStepRepository(),
+            FakeStepRepository(),
+            self.fingerprint_repository,
+            self.purged_submissions.append,
+            self.deleted_uploads.append,
+        )
+
+    def _save_policy(self, full_deletion=False):
+        return self.retention.save_policy(
+            self.project_uuid,
+            self.project_step_uuid,
+            {
+                'anchor': RetentionAnchor.UPLOAD,
+                'anchor_date': None,
+                'file_retention_days': 90,
+                'result_retention_days': 180,
+                'full_deletion': full_deletion,
+            },
+        )
+
+    def test_policies(self):
+        """Test that a step has a retention policy once saved, and none once deleted."""
+        with self.assertRaises(NotFoundException):
+            self.retention.get_policy(self.project_uuid, self.project_step_uuid)
+        policy = self._save_policy()
+
+        self.assertIs(self.retention.get_policy(self.project_uuid, self.project_step_uuid), policy)
+        self.assertTrue(self.retention.delete_policy(self.project_uuid, self.project_step_uuid))
+        self.assertEqual(self.retention.purge_expired(self.now), [])
+
+    def test_dry_run(self):
+        """Test that a dry run reports what would be purged, nothing being deleted nor logged."""
+        self._save_policy()
+
+        [report] = self.retention.purge_expired(self.now, dry_run=True)
+
+        self.assertEqual((report['purged_file_count'], report['purged_result_count']), (2, 1))
+        self.assertEqual(self.submission_repository.changes + self.similarity_repository.changes, [])
+        self.assertEqual((self.deleted_uploads, self.retention.get_purges()), ([], []))
+
+    def test_purge(self):
+        """Test that the expired submissions lose their files and the comparisons their details, the purge logged."""
+        self._save_policy()
+
+        self.retention.purge_expired(self.now)
+
+        self.assertEqual(self.deleted_uploads, self.submissions)
+        self.assertEqual(self.fingerprint_repository.changes, [('delete', [s.id]) for s in self.submissions])
+        patches = [('patch', s.id, {'files_purged_at': self.now}) for s in self.submissions]
+        self.assertEqual(self.submission_repository.changes, patches)
+        self.assertEqual(self.similarity_repository.changes, [('purge_details', [self.similarity.id])])
+        [purge] = self.retention.get_purges()
+        self.assertEqual((purge.purged_file_count, purge.deleted_submission_count), (2, 0))
+
+    def test_full_deletion(self):
+        """Test that with full deletion the expired submissions and comparisons are deleted for good."""
+        self._save_policy(full_deletion=True)
+
+        self.retention.purge_expired(self.now)
+
+        self.assertEqual(self.purged_submissions, [s.id for s in self.submissions])
+        self.assertEqual(self.deleted_uploads, [])
+        self.assertEqual(self.similarity_repository.changes, [('delete', [])])
+        [purge] = self.retention.get_purges()
+        self.assertEqual((purge.purged_file_count, purge.deleted_submission_count), (0, 2))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,95 @@
+"""
+Tests for DetectionIntegrationService
+"""
+
+import tempfile
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.code_metrics_analyzer import CodeMetricsAnalyzer
+from app.domains.submissions.detection_integration_service import DetectionIntegrationService
+from app.domains.submissions.encoding_detector import EncodingDetector
+from app.domains.submissions.processing_timeline import ProcessingTimeline
+from app.domains.submissions.submissions_models import ProcessingOutcome, ProcessingStage
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.tokenization_service import TokenizationService
+
+SOURCE = '''import sys
+
+
+def greet(name):
+    if not name:
+        return "Hello"
+    return f"Hello {name}"
+
+
+def main():
+    for arg in sys.argv[1:]:
+        print(greet(arg))
+
+
+if __name__ == "__main__":
+    main()
+'''
+
+
+class TestDetectionIntegrationService(unittest.TestCase):
+    """Unit tests for the code metrics of the files of a submission, read whole or as a stream."""
+
+    @classmethod
+    def setUpClass(cls):
+        cls.tokenization_service = TokenizationService()
+
+    def setUp(self):
+        directory = tempfile.TemporaryDirectory()
+        self.addCleanup(directory.cleanup)
+        self.file_path = Path(directory.name) / 'main.py'
+        self.file_path.write_text(SOURCE, encoding='utf-8')
+        self.service = DetectionIntegrationService.__new__(DetectionIntegrationService)
+        self.service.tokenization_service = self.tokenization_service
+        self.service.encoding_detector = EncodingDetector()
+        self.service.stream_buffer_size = 1_000_000
+        self.files = []
+
+    def _compute(self):
+        timeline = ProcessingTimeline()
+        metrics = self.service._compute_file_metrics(
+            CodeMetricsAnalyzer(),
+            self.file_path,
+            'main.py',
+            TokenizationOptionsDto(strip_headers=False),
+            lambda relative_path, tokens, language: self.files.append((relative_path, list(tokens), language)),
+            timeline,
+        )
+        return metrics, timeline
+
+    def _assert_metrics(self, metrics, timeline):
+        self.assertEqual(metrics['language'], 'python')
+        self.assertEqual([function['name'] for function in metrics['functions']], ['greet', 'main'])
+        [(relative_path, tokens, language)] = self.files
+        self.assertEqual((relative_path, language), ('main.py', 'python'))
+        self.assertTrue(tokens)
+        stages = {event['stage']: event for event in timeline.events}
+        self.assertEqual(stages[ProcessingStage.LANGUAGE_DETECTION]['details'], {'language': 'python'})
+        self.assertEqual(stages[ProcessingStage.TOKENIZATION]['outcome'], ProcessingOutcome.OK)
+
+    def test_file_metrics(self):
+        """Test that a file read whole gets its metrics, its language detection being recorded in the timeline."""
+        metrics, timeline = self._compute()
+
+        self._assert_metrics(metrics, timeline)
+        self.assertNotIn('chunk_count', timeline.events[0]['details'])
+
+    def test_streamed_file_metrics(self):
+        """Test that a file larger than the stream buffer gets the same functions, tokenized chunk by chunk."""
+        self.service.stream_buffer_size = 64
+
+        metrics, timeline = self._compute()
+
+        self._assert_metrics(metrics, timeline)
+        tokenization = next(event for event in timeline.events if event['stage'] == ProcessingStage.TOKENIZATION)
+        self.assertGreater(tokenization['details']['chunk_count'], 1)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,63 @@
+"""
+Tests for DetectionRunLock
+"""
+
+import os
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.detection_run_lock import INSTANCE_ID, DetectionRunLock
+from app.domains.submissions.submissions_models import DetectionRunStatus
+
+
+class TestDetectionRunLock(unittest.TestCase):
+    """Unit tests for the lock of the detection runs across the instances of the service."""
+
+    def setUp(self):
+        self.now = datetime(2024, 9, 1, 12, 0, tzinfo=timezone.utc)
+        self.lock = DetectionRunLock(lease_seconds=120, now=self.now)
+
+    def _run(self, heartbeat_at, status=DetectionRunStatus.RUNNING):
+        return SimpleNamespace(status=status, heartbeat_at=heartbeat_at)
+
+    def test_key(self):
+        """Test that the lock key is that of the project step and profile, whatever the run."""
+        project, step = uuid4(), uuid4()
+        key = DetectionRunLock.key(project, step, 'default')
+
+        self.assertEqual(DetectionRunLock.key(project, step, 'default'), key)
+        self.assertNotEqual(DetectionRunLock.key(project, step, 'strict'), key)
+        self.assertNotEqual(DetectionRunLock.key(project, uuid4(), 'default'), key)
+
+    def test_stale_after_lease(self):
+        """Test that a running run is stale once its heartbeat is older than the lease."""
+        self.assertFalse(self.lock.is_stale(self._run(self.now - timedelta(seconds=119))))
+        self.assertTrue(self.lock.is_stale(self._run(self.now - timedelta(seconds=121))))
+
+    def test_stale_without_heartbeat(self):
+        """Test that a running run never renewed, started before the locks, is stale."""
+        self.assertTrue(self.lock.is_stale(self._run(None)))
+
+    def test_finished_run_never_stale(self):
+        """Test that a run no longer running is never stale, whatever its heartbeat."""
+        heartbeat_at = self.now - timedelta(hours=1)
+
+        for status in (DetectionRunStatus.COMPLETED, DetectionRunStatus.CANCELLED, DetectionRunStatus.FAILED):
+            self.assertFalse(self.lock.is_stale(self._run(heartbeat_at, status)))
+
+    def test_naive_heartbeat(self):
+        """Test that a heartbeat read back without time zone is compared with the aware time."""
+        heartbeat_at = (self.now - timedelta(minutes=5)).replace(tzinfo=None)
+
+        self.assertTrue(self.lock.is_stale(self._run(heartbeat_at)))
+
+    def test_instance_id(self):
+        """Test that the instance is identified by its host and process."""
+        self.assertEqual(len(INSTANCE_ID.split('-')[-1]), 8)
+        self.assertIn(f'-{os.getpid()}-', INSTANCE_ID)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,106 @@
+"""
+Tests for DetectionRunLocks
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.detection_run_locks import DetectionRunLocks
+from app.domains.submissions.submissions_models import DetectionRunStatus
+from app.shared.exceptions import ConflictException
+
+
+class FakeRunRepository:
+    """Detection runs kept in memory, holding the lock keys of their steps while running"""
+
+    def __init__(self, runs):
+        self.runs = {run.id: run for run in runs}
+
+    def get_by_id(self, run_id):
+        return self.runs.get(run_id)
+
+    def get_by_lock_key(self, lock_key):
+        return next((run for run in self.runs.values() if run.lock_key == lock_key), None)
+
+    def renew_heartbeats(self, instance_id):
+        return sum(1 for run in self.runs.values() if run.claimed_by == instance_id)
+
+    def get_stale(self, stale_before):
+        return [run for run in self.runs.values() if run.lock_key and run.heartbeat_at < stale_before]
+
+    def take_over(self, run_id, heartbeat_at, instance_id):
+        run = self.runs[run_id]
+        if run.heartbeat_at != heartbeat_at:
+            return False
+        run.claimed_by = instance_id
+        return True
+
+    def fail(self, run_id, error):
+        self.runs[run_id].status, self.runs[run_id].lock_key = DetectionRunStatus.FAILED, None
+
+
+class TestDetectionRunLocks(unittest.TestCase):
+    """Unit tests for the locks of the running detection runs and the recovery of the stale ones."""
+
+    def setUp(self):
+        self.now = datetime(2024, 1, 20, 12, 0, tzinfo=timezone.utc)
+        self.live = self._run('a/b/default', 'this-instance', self.now - timedelta(seconds=10))
+        self.stale = self._run('a/c/default', 'dead-instance', self.now - timedelta(seconds=300))
+        self.repository = FakeRunRepository([self.live, self.stale])
+        self.resumed = []
+
+    def _run(self, lock_key, claimed_by, heartbeat_at):
+        return SimpleNamespace(
+            id=uuid4(),
+            project_step_uuid=uuid4(),
+            status=DetectionRunStatus.RUNNING,
+            lock_key=lock_key,
+            claimed_by=claimed_by,
+            heartbeat_at=heartbeat_at,
+            rescored_at=None,
+        )
+
+    def _locks(self, orphaned_run_action='resume'):
+        return DetectionRunLocks(self.repository, 120, orphaned_run_action, self.resumed.append, 'this-instance')
+
+    def test_maintain(self):
+        """Test that the runs of this instance are renewed and the stale runs of the others taken over, resumed."""
+        self.assertEqual(self._locks().maintain(self.now), {'renewed': 1, 'recovered': 1})
+
+        self.assertEqual(self.resumed, [self.stale])
+        self.assertEqual(self.stale.claimed_by, 'this-instance')
+
+    def test_fail_orphaned_run(self):
+        """Test that a stale run is failed when the orphaned runs are not resumed, or when it was rescored."""
+        self.assertIsNone(self._locks('fail').get_running('a/c/default', self.now))
+        self.assertEqual(self.stale.status, DetectionRunStatus.FAILED)
+
+        rescored = self._run('a/d/default', 'dead-instance', self.now - timedelta(seconds=300))
+        rescored.rescored_at = self.now - timedelta(days=1)
+        self.repository.runs[rescored.id] = rescored
+        self._locks().maintain(self.now)
+        self.assertEqual((rescored.status, self.resumed), (DetectionRunStatus.FAILED, []))
+
+    def test_taken_over_meanwhile(self):
+        """Test that a stale run taken over by another instance first is left to it."""
+        run = SimpleNamespace(**{**vars(self.stale), 'heartbeat_at': self.now - timedelta(seconds=400)})
+
+        self.assertFalse(self._locks().recover(run))
+        self.assertEqual((self.stale.claimed_by, self.resumed), ('dead-instance', []))
+
+    def test_attach(self):
+        """Test that a new run attaches to the running one if asked, and is refused else."""
+        running = self._locks().get_running('a/b/default', self.now)
+
+        self.assertIs(DetectionRunLocks.attach(running, attach=True), self.live)
+        for running in (self.live, None):
+            with self.subTest(running=running):
+                with self.assertRaises(ConflictException) as context:
+                    DetectionRunLocks.attach(running, attach=False)
+                self.assertEqual(context.exception.detail['error_type'], 'detection_run_in_progress')
+
+
+if __name__ == '__main__':
+    unittest.main()
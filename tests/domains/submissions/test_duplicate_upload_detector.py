@@ -0,0 +1,67 @@
+"""
+Tests for DuplicateUploadDetector
+"""
+
+import unittest
+from datetime import datetime, timedelta
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.duplicate_upload_detector import DuplicateUploadDetector
+
+
+class TestDuplicateUploadDetector(unittest.TestCase):
+    """Unit tests for the exact duplicate detection of the uploaded submissions."""
+
+    def setUp(self):
+        self.project_uuid = uuid4()
+        self.project_step_uuid = uuid4()
+        self.now = datetime(2024, 1, 15, 10, 30)
+
+    def _submission(self, group_uuid=None, content_hash='abc', minutes=0, project_step_uuid=None):
+        return SimpleNamespace(
+            id=uuid4(),
+            group_uuid=group_uuid or uuid4(),
+            project_uuid=self.project_uuid,
+            project_step_uuid=project_step_uuid or self.project_step_uuid,
+            content_hash=content_hash,
+            upload_date_time=self.now + timedelta(minutes=minutes),
+        )
+
+    def test_aggregate_hash(self):
+        """Test that the aggregate hash ignores the order of the files, and that no files give no hash."""
+        self.assertEqual(
+            DuplicateUploadDetector.aggregate_hash(['a1', 'b2', 'c3']),
+            DuplicateUploadDetector.aggregate_hash(['c3', 'a1', 'b2']),
+        )
+        self.assertNotEqual(
+            DuplicateUploadDetector.aggregate_hash(['a1', 'b2']),
+            DuplicateUploadDetector.aggregate_hash(['a1', 'b2', 'b2']),
+        )
+        self.assertIsNone(DuplicateUploadDetector.aggregate_hash([]))
+        self.assertIsNone(DuplicateUploadDetector.aggregate_hash([None]))
+
+    def test_originals(self):
+        """Test that the submissions of the other groups of the step are the originals, earliest first."""
+        submission = self._submission(minutes=10)
+        later = self._submission(minutes=5)
+        earlier = self._submission(minutes=1)
+
+        originals = DuplicateUploadDetector.originals(submission, [submission, later, earlier])
+
+        self.assertEqual(originals, [earlier, later])
+
+    def test_versions_not_duplicates(self):
+        """Test that the versions of the same group, other steps and other contents are not duplicates."""
+        submission = self._submission(minutes=10)
+        candidates = [
+            self._submission(group_uuid=submission.group_uuid),
+            self._submission(project_step_uuid=uuid4()),
+            self._submission(content_hash='def'),
+        ]
+
+        self.assertEqual(DuplicateUploadDetector.originals(submission, candidates), [])
+
+
+if __name__ == '__main__':
+    unittest.main()
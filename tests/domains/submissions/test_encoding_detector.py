@@ -0,0 +1,86 @@
+"""
+Tests for EncodingDetector
+"""
+
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.binary_file_detector import BinaryFileDetector
+from app.domains.submissions.encoding_detector import EncodingDetector
+
+
+class TestEncodingDetector(unittest.TestCase):
+    """Unit tests for the detection of the encodings and the decoding of the files of the submissions."""
+
+    def setUp(self):
+        """Set up test fixtures."""
+        self.detector = EncodingDetector()
+        self.fixtures_dir = Path('resources/test/encodings')
+        self.text = (self.fixtures_dir / 'moyenne_utf8.py').read_bytes().decode('utf-8')
+
+    def fixture(self, name):
+        return (self.fixtures_dir / name).read_bytes()
+
+    def test_fixtures_decode_to_the_same_text(self):
+        """Test that a file saved as UTF-16LE with or without BOM or as ISO-8859-1 gives the text of its UTF-8 copy."""
+        fixtures = {
+            'moyenne_utf8.py': ('utf-8', False),
+            'moyenne_utf16le_bom.py': ('utf-16-le', True),
+            'moyenne_utf16le.py': ('utf-16-le', False),
+            'moyenne_iso8859_1.py': ('iso-8859-1', False),
+        }
+        for name, (encoding, bom) in fixtures.items():
+            with self.subTest(name=name):
+                decoded = self.detector.decode(self.fixture(name))
+
+                self.assertEqual(decoded.text, self.text)
+                self.assertEqual((decoded.encoding, decoded.bom), (encoding, bom))
+                self.assertEqual(decoded.replaced_count, 0)
+
+    def test_utf16_fixtures_are_not_binary(self):
+        """Test that the NUL bytes of a UTF-16 text, with or without BOM, do not make it binary."""
+        binary_detector = BinaryFileDetector()
+
+        self.assertIsNone(binary_detector.detect(self.fixture('moyenne_utf16le_bom.py')))
+        self.assertIsNone(binary_detector.detect(self.fixture('moyenne_utf16le.py')))
+
+    def test_byte_order_marks(self):
+        """Test that the byte order marks select their encoding and are left out of the text."""
+        for encoding in ('utf-8-sig', 'utf-16-be', 'utf-32'):
+            with self.subTest(encoding=encoding):
+                content = 'x = "é"\n'.encode(encoding)
+                if encoding == 'utf-16-be':
+                    content = b'\xfe\xff' + content
+
+                decoded = self.detector.decode(content)
+
+                self.assertTrue(decoded.bom)
+                self.assertEqual(decoded.text, 'x = "é"\n')
+
+    def test_windows_1252(self):
+        """Test that a single-byte text with curly quotes is Windows-1252 rather than ISO-8859-1."""
+        decoded = self.detector.decode('print(“coût: 5€”)\n'.encode('cp1252'))
+
+        self.assertEqual(decoded.encoding, 'cp1252')
+        self.assertEqual(decoded.text, 'print(“coût: 5€”)\n')
+
+    def test_invalid_sequences_are_replaced_and_counted(self):
+        """Test that the invalid sequences of a UTF-8 text are replaced the same way each time, and counted."""
+        content = 'résumé = "déjà vu"\n'.encode('utf-8') + b'\xff\xfe# end\n'
+
+        decoded = self.detector.decode(content)
+
+        self.assertEqual(decoded.encoding, 'utf-8')
+        self.assertEqual(decoded.replaced_count, 2)
+        self.assertIn('résumé', decoded.text)
+        self.assertEqual(self.detector.decode(content), decoded)
+
+    def test_stream_codec_skips_the_byte_order_mark(self):
+        """Test that the files streamed in chunks are read with a codec skipping their byte order mark."""
+        self.assertEqual(self.detector.stream_codec(self.fixture('moyenne_utf16le_bom.py')), 'utf-16')
+        self.assertEqual(self.detector.stream_codec(self.fixture('moyenne_utf16le.py')), 'utf-16-le')
+        self.assertEqual(self.detector.stream_codec(self.fixture('moyenne_iso8859_1.py')), 'iso-8859-1')
+
+
+if __name__ == '__main__':
+    unittest.main()
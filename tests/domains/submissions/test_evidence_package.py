@@ -0,0 +1,76 @@
+"""
+Tests for EvidencePackageBuilder
+"""
+
+import hashlib
+import io
+import json
+import unittest
+import zipfile
+from datetime import datetime
+from uuid import uuid4
+
+from app.domains.submissions.evidence_package import MANIFEST_NAME, EvidencePackageBuilder
+
+
+class TestEvidencePackageBuilder(unittest.TestCase):
+    """Unit tests for the reproducible case file of a pair of submissions."""
+
+    def setUp(self):
+        self.generated_at = datetime(2024, 9, 1, 12, 30, 15)
+        self.similarity_id = uuid4()
+
+    def _build(self, files):
+        builder = EvidencePackageBuilder(self.generated_at)
+        for name, content in files:
+            builder.add(name, content)
+        builder.add_json('comparison.json', {'similarity_id': self.similarity_id, 'scores': {'overall': 0.92}})
+        return builder.build({'similarity_id': self.similarity_id})
+
+    def test_reproducible(self):
+        """Test that two packages of the same files are identical, whatever the order they were added in."""
+        files = [('submissions/a/main.py', b'print(1)\n'), ('report.html', b'<html></html>')]
+
+        first = self._build(files)
+        second = self._build(list(reversed(files)))
+
+        self.assertEqual(first, second)
+        self.assertEqual(EvidencePackageBuilder.checksum(first), EvidencePackageBuilder.checksum(second))
+
+    def test_stable_entries(self):
+        """Test that the entries are sorted by path and share the time of generation and their permissions."""
+        content = self._build([('submissions/b/main.go', b'package main\n'), ('report.html', b'<html></html>')])
+
+        with zipfile.ZipFile(io.BytesIO(content)) as archive:
+            infos = archive.infolist()
+        self.assertEqual(
+            [info.filename for info in infos],
+            ['comparison.json', MANIFEST_NAME, 'report.html', 'submissions/b/main.go'],
+        )
+        self.assertEqual({info.date_time for info in infos}, {(2024, 9, 1, 12, 30, 14)})
+        self.assertEqual({info.external_attr >> 16 & 0o777 for info in infos}, {0o644})
+
+    def test_manifest(self):
+        """Test that the manifest lists the size and checksum of every other file, with the generation time."""
+        content = self._build([('submissions/a/main.py', b'print(1)\n')])
+
+        with zipfile.ZipFile(io.BytesIO(content)) as archive:
+            manifest = json.loads(archive.read(MANIFEST_NAME))
+            files = {name: archive.read(name) for name in archive.namelist()}
+        self.assertEqual(manifest['similarity_id'], str(self.similarity_id))
+        self.assertEqual(manifest['generated_at'], '2024-09-01T12:30:15')
+        self.assertEqual([file['path'] for file in manifest['files']], ['comparison.json', 'submissions/a/main.py'])
+        for file in manifest['files']:
+            self.assertEqual(file['sha256'], hashlib.sha256(files[file['path']]).hexdigest())
+            self.assertEqual(file['size_bytes'], len(files[file['path']]))
+
+    def test_changed_content(self):
+        """Test that a package of other files has another checksum."""
+        first = self._build([('submissions/a/main.py', b'print(1)\n')])
+        second = self._build([('submissions/a/main.py', b'print(2)\n')])
+
+        self.assertNotEqual(EvidencePackageBuilder.checksum(first), EvidencePackageBuilder.checksum(second))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,162 @@
+"""
+Tests for EvidencePackages
+"""
+
+import io
+import json
+import unittest
+import zipfile
+from datetime import datetime
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.evidence_packages import EVIDENCE_SCORE_FIELDS, EvidencePackages
+from app.domains.submissions.submissions_models import SimilarityStatus
+from app.shared.exceptions import ConflictException, NotFoundException, ValidationException
+from tests.domains.submissions.factories import similarity
+
+
+class FakeRepository:
+    """Records kept in memory by ID"""
+
+    def __init__(self, records=()):
+        self.records = {record.id: record for record in records}
+
+    def get_by_id(self, record_id):
+        return self.records.get(record_id)
+
+    def create(self, record_data):
+        record = SimpleNamespace(id=uuid4(), status=SimilarityStatus.PENDING, **record_data)
+        self.records[record.id] = record
+        return record
+
+    def update_status(self, record_id, status, **fields):
+        self.records[record_id].status = status
+        vars(self.records[record_id]).update(fields)
+
+
+class TestEvidencePackages(unittest.TestCase):
+    """Unit tests for the evidence packages of the pairs of the detection runs."""
+
+    def setUp(self):
+        self.submissions = [SimpleNamespace(id=uuid4(), quarantined=False) for _ in range(3)]
+        a, b, c = self.submissions
+        self.similarity = similarity(
+            a.id,
+            b.id,
+            created_at=datetime(2024, 1, 20, 12, 0),
+            updated_at=None,
+            detection_version='2.0',
+            processing_time_seconds=1.5,
+            **dict.fromkeys(EVIDENCE_SCORE_FIELDS, 0.5),
+        )
+        self.outside = similarity(a.id, c.id)
+        self.run = SimpleNamespace(
+            id=uuid4(),
+            project_uuid=uuid4(),
+            project_step_uuid=uuid4(),
+            created_at=datetime(2024, 1, 20, 11, 0),
+            analysis_profile='default',
+            effective_options={},
+            include_same_team=False,
+            submission_ids=[str(a.id), str(b.id)],
+        )
+        self.report_jobs = FakeRepository()
+        self.files = {a.id: {'main.py': b'print(1)\n'}, b.id: {'main.py': b'print(2)\n'}}
+        self.submitted = []
+        self.pdf_error = None
+        self.packages = EvidencePackages(
+            FakeRepository([self.run]),
+            FakeRepository([self.similarity, self.outside]),
+            FakeRepository(self.submissions),
+            self.report_jobs,
+            lambda run, similarity: {
+                'similarity_id': similarity.id,
+                'suspicious': True,
+                'configuration': {},
+                'fragments': [],
+                'file_similarities': [],
+            },
+            self._check_not_quarantined,
+            lambda submission: self.files[submission.id],
+            lambda pair: '<html></html>',
+            self._render_pdf,
+            lambda *arguments: self.submitted.append(arguments),
+        )
+
+    @staticmethod
+    def _check_not_quarantined(submission):
+        if submission.quarantined:
+            raise ConflictException(f'Submission {submission.id} is quarantined')
+
+    def _render_pdf(self, pair):
+        if self.pdf_error:
+            raise RuntimeError(self.pdf_error)
+        return b'%PDF-1.7'
+
+    def _build(self):
+        job = self.packages.request(self.run.id, self.similarity.id)
+        self.packages.build(*self.submitted[-1])
+        return job
+
+    def test_request_checks(self):
+        """Test that only a completed pair of the run, whose files are available, is packaged."""
+        with self.assertRaises(NotFoundException):
+            self.packages.request(uuid4(), self.similarity.id)
+        with self.assertRaises(NotFoundException):
+            self.packages.request(self.run.id, self.outside.id)
+        self.submissions[1].quarantined = True
+        with self.assertRaises(ConflictException):
+            self.packages.request(self.run.id, self.similarity.id)
+        self.similarity.status = SimilarityStatus.PROCESSING
+        with self.assertRaises(ValidationException):
+            self.packages.request(self.run.id, self.similarity.id)
+
+        self.assertEqual((self.report_jobs.records, self.submitted), ({}, []))
+
+    def test_build(self):
+        """Test that the package holds the files of both submissions, the reports and the record of the comparison."""
+        job = self._build()
+
+        self.assertEqual(job.status, SimilarityStatus.COMPLETED)
+        archive = zipfile.ZipFile(io.BytesIO(job.content))
+        a, b = self.submissions[:2]
+        self.assertEqual(
+            sorted(archive.namelist()),
+            sorted(
+                [
+                    'comparison.json',
+                    'manifest.json',
+                    'report.html',
+                    'report.pdf',
+                    f'submissions/{a.id}/main.py',
+                    f'submissions/{b.id}/main.py',
+                ]
+            ),
+        )
+        document = json.loads(archive.read('comparison.json'))
+        self.assertEqual(document['run']['id'], str(self.run.id))
+        self.assertEqual(document['scores']['overall_similarity'], 0.5)
+
+    def test_pdf_report_left_out(self):
+        """Test that a PDF report failing to render is left out, the manifest telling why."""
+        self.pdf_error = 'no fonts'
+
+        job = self._build()
+
+        archive = zipfile.ZipFile(io.BytesIO(job.content))
+        self.assertNotIn('report.pdf', archive.namelist())
+        self.assertEqual(json.loads(archive.read('manifest.json'))['pdf_report_error'], 'no fonts')
+
+    def test_build_failure(self):
+        """Test that a package whose files cannot be read fails its job."""
+        self.files.clear()
+
+        job = self._build()
+
+        self.assertEqual(job.status, SimilarityStatus.FAILED)
+        self.assertIn('error_message', vars(job))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,59 @@
+"""
+Tests for FileFilter
+"""
+
+import unittest
+
+from app.domains.repositories.archive_extractor import ArchiveFile
+from app.domains.submissions.file_filter import FileFilter, FileFilterMode
+
+
+class TestFileFilter(unittest.TestCase):
+    """Unit tests for the allowlist and denylist of the files of a project step."""
+
+    def test_allow_everything_by_default(self):
+        """Test that every file is allowed without any list."""
+        file_filter = FileFilter()
+
+        self.assertFalse(file_filter.enabled)
+        self.assertIsNone(file_filter.reason('report.pdf'))
+        self.assertIsNone(file_filter.reason('node_modules/lib/index.js'))
+
+    def test_allowlist(self):
+        """Test that only the allowed extensions and patterns are kept, case insensitively."""
+        file_filter = FileFilter(allowed_extensions=['c', '.H'], allowed_patterns=['Makefile'])
+
+        self.assertIsNone(file_filter.reason('src/main.c'))
+        self.assertIsNone(file_filter.reason('include/list.h'))
+        self.assertIsNone(file_filter.reason('makefile'))
+        self.assertEqual(file_filter.reason('report.pdf'), 'not in the allowed extensions nor patterns')
+        self.assertIsNotNone(file_filter.reason('main.cpp'))
+
+    def test_denylist(self):
+        """Test that a denied file is never allowed, a directory name denying the whole directory."""
+        file_filter = FileFilter(
+            allowed_extensions=['.js'], denied_extensions=['.min.js'], denied_patterns=['node_modules', 'docs/*.js']
+        )
+
+        self.assertIsNone(file_filter.reason('src/app.js'))
+        self.assertEqual(file_filter.reason('dist/app.min.js'), 'denied extension .min.js')
+        self.assertEqual(file_filter.reason('web/node_modules/lib/index.js'), 'denied pattern node_modules')
+        self.assertEqual(file_filter.reason('docs/example.js'), 'denied pattern docs/*.js')
+        self.assertIsNone(file_filter.reason('src/docs/example.js'))
+
+    def test_filter(self):
+        """Test that the disallowed files are returned as skipped entries with their reason."""
+        file_filter = FileFilter(FileFilterMode.STRICT, allowed_extensions=['.c'])
+        files = [ArchiveFile(path='main.c', content=b'int main;'), ArchiveFile(path='shot.png', content=b'\x89PNG')]
+
+        allowed, disallowed = file_filter.filter(files)
+
+        self.assertEqual(file_filter.mode, FileFilterMode.STRICT)
+        self.assertEqual([file.path for file in allowed], ['main.c'])
+        self.assertEqual(
+            disallowed, [{'path': 'shot.png', 'reason': 'disallowed file: not in the allowed extensions nor patterns'}]
+        )
+
+
+if __name__ == '__main__':
+    unittest.main()
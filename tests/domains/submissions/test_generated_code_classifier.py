@@ -0,0 +1,70 @@
+"""
+Tests for GeneratedCodeClassifier
+"""
+
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.generated_code_classifier import GeneratedCodeClassifier
+
+
+class TestGeneratedCodeClassifier(unittest.TestCase):
+    """Unit tests for the generated and minified code heuristics."""
+
+    def setUp(self):
+        """Set up test fixtures."""
+        self.classifier = GeneratedCodeClassifier()
+        self.samples_dir = Path("resources/test/language_samples")
+
+    def test_generator_markers(self):
+        """Test that files with a generator header are classified as generated."""
+        content = (
+            "// Code generated by protoc-gen-go. DO NOT EDIT.\n"
+            "// source: user.proto\n\n"
+            "package userpb\n\n"
+            "type User struct {\n\tName string\n}\n"
+        )
+        classification = self.classifier.classify(Path("user.go"), content, "api/user.go")
+
+        self.assertTrue(classification.is_generated)
+        self.assertEqual(classification.kind, "generated")
+        self.assertEqual(classification.file, "api/user.go")
+        self.assertTrue(any("DO NOT EDIT" in reason for reason in classification.reasons))
+
+        classification = self.classifier.classify(Path("Parser.java"), "/* @generated */\nclass Parser {}\n")
+        self.assertEqual(classification.kind, "generated")
+
+    def test_generated_file_names(self):
+        """Test that minified bundles and generated sources are recognized by their name."""
+        for name in ["bundle.min.js", "theme.min.css", "user.pb.go", "user_pb2.py", "main.bundle.js"]:
+            with self.subTest(name=name):
+                self.assertTrue(self.classifier.classify(Path(name), "x = 1\n").is_generated)
+        self.assertFalse(self.classifier.classify(Path("minimal.js"), "const value = 1;\n").is_generated)
+
+    def test_minified_code(self):
+        """Test that long lines and single character identifiers without comments are classified as minified."""
+        statement = "var a=function(b,c){return b+c},d=a(1,2),e=[d,a(d,3)];"
+        classification = self.classifier.classify(Path("app.js"), statement * 20)
+
+        self.assertEqual(classification.kind, "minified")
+        self.assertGreater(classification.metrics["average_line_length"], 200)
+        self.assertFalse(classification.metrics["has_comments"])
+
+        # Short lines, but mangled identifiers and no comments in a large file
+        mangled = "\n".join(f"function f{index}(a,b){{var c=a*b;return c+a-b}}" for index in range(80))
+        classification = self.classifier.classify(Path("lib.js"), mangled)
+        self.assertEqual(classification.kind, "minified")
+        self.assertGreater(classification.metrics["single_char_identifier_ratio"], 0.4)
+
+    def test_handwritten_samples_are_not_generated(self):
+        """Test that the language samples, written by hand, are not classified as generated."""
+        for name in ["sample.js", "sample.py", "sample.go", "sample.css", "sample_templates.cpp"]:
+            with self.subTest(name=name):
+                content = (self.samples_dir / name).read_text(encoding="utf-8")
+                classification = self.classifier.classify(self.samples_dir / name, content)
+                self.assertFalse(classification.is_generated, classification.reasons)
+                self.assertEqual(classification.to_dict()["kind"], "")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,88 @@
+"""
+Tests for GoPackagePreprocessor
+"""
+
+import tempfile
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessor
+
+
+class TestGoPackagePreprocessor(unittest.TestCase):
+    """Unit tests for the grouping of Go files by package and the build constraints."""
+
+    def setUp(self):
+        """Create a submission with a package split across files and platform specific files."""
+        self.temp_dir = tempfile.TemporaryDirectory()
+        self.repo_path = Path(self.temp_dir.name)
+        files = {
+            'main.go': 'package main\n\nfunc main() {}\n',
+            'store/types.go': 'package store\n\ntype Store struct{ items []string }\n',
+            'store/methods.go': 'package store\n\nfunc (s *Store) Add(item string) { s.items = append(s.items, item) }\n',
+            'store/store_test.go': 'package store\n\nfunc TestAdd() {}\n',
+            'store/stub_windows.go': '//go:build windows\n\npackage store\n\nfunc stub() {}\n',
+            'store/path_darwin.go': 'package store\n\nfunc path() string { return "" }\n',
+            'store/legacy.go': '// +build darwin,amd64 windows\n\npackage store\n\nfunc legacy() {}\n',
+            'store/unix.go': '//go:build unix && !windows\n\npackage store\n\nfunc unix() {}\n',
+            'README.md': '# Submission\n',
+        }
+        for name, content in files.items():
+            file_path = self.repo_path / name
+            file_path.parent.mkdir(parents=True, exist_ok=True)
+            file_path.write_text(content)
+        self.files = [self.repo_path / name for name in files]
+        self.preprocessor = GoPackagePreprocessor(goos='linux', goarch='amd64')
+
+    def tearDown(self):
+        self.temp_dir.cleanup()
+
+    def test_package_files_are_contiguous(self):
+        """Test that files of a same package are grouped and sorted, other files coming last."""
+        result = self.preprocessor.prepare(self.files, self.repo_path)
+
+        relative_files = [str(file_path.relative_to(self.repo_path)) for file_path in result.files]
+        self.assertEqual(
+            relative_files, ['main.go', 'store/methods.go', 'store/types.go', 'store/unix.go', 'README.md']
+        )
+        self.assertEqual(result.platform, 'linux/amd64')
+        self.assertEqual(
+            [(package['directory'], package['package']) for package in result.packages],
+            [('.', 'main'), ('store', 'store')],
+        )
+
+    def test_excluded_files_are_reported(self):
+        """Test that test files and files excluded by build constraints are skipped with a reason."""
+        result = self.preprocessor.prepare(self.files, self.repo_path)
+
+        reasons = {skipped['file']: skipped['reason'] for skipped in result.skipped_files}
+        self.assertEqual(
+            set(reasons), {'store/store_test.go', 'store/stub_windows.go', 'store/path_darwin.go', 'store/legacy.go'}
+        )
+        self.assertEqual(reasons['store/store_test.go'], 'test file')
+        self.assertIn('file name constraint', reasons['store/path_darwin.go'])
+        self.assertIn('build constraint', reasons['store/legacy.go'])
+        self.assertEqual(result.to_dict()['skipped_files'], result.skipped_files)
+
+    def test_platform_changes_selection(self):
+        """Test that the configured platform and test file option change the selected files."""
+        preprocessor = GoPackagePreprocessor(goos='darwin', goarch='amd64', skip_test_files=False)
+        result = preprocessor.prepare(self.files, self.repo_path)
+
+        relative_files = {str(file_path.relative_to(self.repo_path)) for file_path in result.files}
+        self.assertIn('store/path_darwin.go', relative_files)
+        self.assertIn('store/legacy.go', relative_files)
+        self.assertIn('store/store_test.go', relative_files)
+        self.assertNotIn('store/stub_windows.go', relative_files)
+
+    def test_matches_constraint(self):
+        """Test the evaluation of build constraint expressions."""
+        self.assertTrue(self.preprocessor.matches_constraint('linux && (amd64 || arm64)'))
+        self.assertTrue(self.preprocessor.matches_constraint('!windows'))
+        self.assertTrue(self.preprocessor.matches_constraint('unix && go1.21'))
+        self.assertFalse(self.preprocessor.matches_constraint('linux && !amd64'))
+        self.assertFalse(self.preprocessor.matches_constraint('ignore'))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,73 @@
+"""
+Tests for the grading callback
+"""
+
+import unittest
+from datetime import datetime
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.grading_callback import GRADING_SCHEMA_VERSION, attempt_changes, grading_payload
+from app.domains.submissions.submissions_models import GradingCallbackStatus
+from app.domains.submissions.webhook_delivery import WebhookAttempt
+
+
+class TestGradingCallback(unittest.TestCase):
+    """Unit tests for the payload of the results posted to the grading service and the record of its attempts."""
+
+    def setUp(self):
+        self.first, self.second, self.third = uuid4(), uuid4(), uuid4()
+        self.run = SimpleNamespace(
+            id=uuid4(),
+            project_uuid=uuid4(),
+            project_step_uuid=uuid4(),
+            completed_at=datetime(2024, 1, 20, 12, 0),
+            grading_callback_attempts=[],
+        )
+
+    def test_payload_of_flagged_pairs_and_max_scores(self):
+        """Test that the payload lists the flagged pairs and the highest similarity of each submission."""
+        matrix = {
+            'flag_threshold': 0.7,
+            'flagged_pairs': [
+                {
+                    'submission_id': self.first,
+                    'compared_submission_id': self.second,
+                    'overall_similarity': 0.91,
+                    'same_team': False,
+                }
+            ],
+            'max_similarities': [
+                {'submission_id': self.first, 'max_similarity': 0.91, 'most_similar_submission_id': self.second},
+                {'submission_id': self.second, 'max_similarity': 0.91, 'most_similar_submission_id': self.first},
+                {'submission_id': self.third, 'max_similarity': None, 'most_similar_submission_id': None},
+            ],
+        }
+
+        payload = grading_payload(self.run, matrix)
+
+        self.assertEqual(payload['schema_version'], GRADING_SCHEMA_VERSION)
+        self.assertEqual(payload['run_id'], str(self.run.id))
+        self.assertEqual(payload['flagged_pairs'][0]['compared_submission_id'], str(self.second))
+        self.assertEqual(
+            payload['submissions'][2],
+            {'submission_id': str(self.third), 'max_similarity': None, 'most_similar_submission_id': None},
+        )
+
+    def test_delivered_only_after_2xx(self):
+        """Test that a run is delivered once answered with a 2xx, failed otherwise, each attempt being recorded."""
+        at = datetime(2024, 1, 20, 12, 5)
+
+        failed = attempt_changes(self.run, WebhookAttempt(503, 'HTTP 503', 12.0), at)
+        self.run.grading_callback_attempts = failed['grading_callback_attempts']
+        delivered = attempt_changes(self.run, WebhookAttempt(204, None, 8.0), at)
+
+        self.assertEqual(failed['grading_callback_status'], GradingCallbackStatus.FAILED)
+        self.assertIsNone(failed['grading_callback_delivered_at'])
+        self.assertEqual(delivered['grading_callback_status'], GradingCallbackStatus.DELIVERED)
+        self.assertEqual(delivered['grading_callback_delivered_at'], at)
+        self.assertEqual([attempt['attempt'] for attempt in delivered['grading_callback_attempts']], [1, 2])
+
+
+if __name__ == '__main__':
+    unittest.main()
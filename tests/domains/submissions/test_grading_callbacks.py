@@ -0,0 +1,153 @@
+"""
+Tests for GradingCallbacks
+"""
+
+import json
+import unittest
+from datetime import datetime, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.grading_callback import SCHEMA_VERSION_HEADER
+from app.domains.submissions.grading_callbacks import GradingCallbacks
+from app.domains.submissions.submissions_models import DetectionRunStatus, GradingCallbackStatus
+from app.domains.submissions.webhook_delivery import WebhookAttempt, WebhookSender
+from app.shared.exceptions import ConflictException, NotFoundException, ValidationException
+
+
+class FakeConfigRepository:
+    """Grading callbacks of the project steps kept in memory"""
+
+    def __init__(self):
+        self.configs = {}
+
+    def save(self, project_uuid, project_step_uuid, config_data):
+        self.configs[project_step_uuid] = SimpleNamespace(project_step_uuid=project_step_uuid, **config_data)
+        return self.configs[project_step_uuid]
+
+    def get_by_project_step(self, project_uuid, project_step_uuid):
+        return self.configs.get(project_step_uuid)
+
+    def delete(self, project_uuid, project_step_uuid):
+        return self.configs.pop(project_step_uuid, None) is not None
+
+
+class FakeRunRepository:
+    """Detection runs kept in memory"""
+
+    def __init__(self, runs):
+        self.runs = {run.id: run for run in runs}
+
+    def get_by_id(self, run_id):
+        return self.runs.get(run_id)
+
+    def update(self, run_id, changes):
+        for field, value in changes.items():
+            setattr(self.runs[run_id], field, value)
+        return self.runs[run_id]
+
+
+class RecordingSender(WebhookSender):
+    """Sender recording its deliveries, answering with the given status code"""
+
+    def __init__(self, status_code=204):
+        super().__init__(host_policy=lambda host: host != '169.254.169.254')
+        self.status_code = status_code
+        self.deliveries = []
+
+    def send(self, url, secret, event, delivery_id, body, headers=None):
+        self.deliveries.append((url, secret, delivery_id, json.loads(body), headers))
+        return WebhookAttempt(self.status_code, None, 12.0)
+
+
+class TestGradingCallbacks(unittest.TestCase):
+    """Unit tests for the grading callbacks of the project steps and the delivery of the results of their runs."""
+
+    def setUp(self):
+        self.project_uuid, self.project_step_uuid = uuid4(), uuid4()
+        self.run = SimpleNamespace(
+            id=uuid4(),
+            project_uuid=self.project_uuid,
+            project_step_uuid=self.project_step_uuid,
+            status=DetectionRunStatus.COMPLETED,
+            completed_at=datetime(2024, 1, 20, 12, 0),
+            grading_callback_status=None,
+            grading_callback_attempts=[],
+        )
+        self.config_repository = FakeConfigRepository()
+        self.sender = RecordingSender()
+        self.dispatched = []
+        self.callbacks = GradingCallbacks(
+            self.config_repository,
+            FakeRunRepository([self.run]),
+            self.sender,
+            lambda run_id: {'flag_threshold': 0.8, 'flagged_pairs': [], 'max_similarities': []},
+            self.dispatched.append,
+        )
+        self.now = datetime(2024, 1, 20, 12, 5, tzinfo=timezone.utc)
+
+    def _save(self, url='https://grading.example.com/assignments/42', secret=None):
+        return self.callbacks.save(self.project_uuid, self.project_step_uuid, {'url': url, 'secret': secret})
+
+    def test_save(self):
+        """Test that a callback keeps the given secret or gets a generated one, its host being allowed."""
+        self.assertGreaterEqual(len(self._save().secret), 32)
+        self.assertEqual(self._save(secret='a-secret-of-16-characters').secret, 'a-secret-of-16-characters')
+
+        with self.assertRaises(ValidationException) as context:
+            self._save('http://169.254.169.254/latest/meta-data')
+        self.assertIn('host 169.254.169.254 is not allowed', context.exception.detail)
+
+        self.assertTrue(self.callbacks.delete(self.project_uuid, self.project_step_uuid))
+        with self.assertRaises(NotFoundException):
+            self.callbacks.get(self.project_uuid, self.project_step_uuid)
+
+    def test_send_and_deliver(self):
+        """Test that a completed run of a step with a callback is dispatched, then delivered signed."""
+        self.callbacks.send(self.run)
+        self.assertEqual(self.dispatched, [])
+
+        config = self._save()
+        self.callbacks.send(self.run)
+        self.callbacks.deliver(self.run.id, self.now)
+
+        self.assertEqual(self.dispatched, [self.run.id])
+        [(url, secret, delivery_id, payload, headers)] = self.sender.deliveries
+        self.assertEqual((url, secret, delivery_id), (config.url, config.secret, str(self.run.id)))
+        self.assertEqual(payload['run_id'], str(self.run.id))
+        self.assertEqual(headers, {SCHEMA_VERSION_HEADER: '1'})
+        self.assertEqual(self.run.grading_callback_status, GradingCallbackStatus.DELIVERED)
+        self.assertEqual(self.run.grading_callback_delivered_at, self.now)
+
+    def test_failed_delivery(self):
+        """Test that a delivery not answered with a 2xx fails the run, and one no longer pending is not sent."""
+        self.sender.status_code = 503
+        self._save()
+        self.callbacks.send(self.run)
+
+        self.callbacks.deliver(self.run.id, self.now)
+        self.callbacks.deliver(self.run.id, self.now)
+
+        self.assertEqual(len(self.sender.deliveries), 1)
+        self.assertEqual(self.run.grading_callback_status, GradingCallbackStatus.FAILED)
+        self.assertEqual(len(self.run.grading_callback_attempts), 1)
+
+    def test_redeliver(self):
+        """Test that only a completed run of a step with a callback is delivered again."""
+        with self.assertRaises(NotFoundException):
+            self.callbacks.redeliver(self.run.id)
+        self._save()
+        self.run.status = DetectionRunStatus.RUNNING
+        with self.assertRaises(ConflictException) as context:
+            self.callbacks.redeliver(self.run.id)
+        self.assertEqual(context.exception.detail['error_type'], 'run_in_progress')
+
+        self.run.status = DetectionRunStatus.COMPLETED
+        run = self.callbacks.redeliver(self.run.id)
+
+        self.assertEqual(run.grading_callback_status, GradingCallbackStatus.PENDING)
+        self.assertEqual(self.dispatched, [self.run.id])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,41 @@
+"""
+Tests for Idempotency
+"""
+
+import unittest
+
+from app.domains.submissions.idempotency import ANONYMOUS_CLIENT_SCOPE, Idempotency, IdempotencyDecision
+
+
+class TestIdempotency(unittest.TestCase):
+    """Unit tests for the idempotency keys of the creation requests."""
+
+    def test_client_scope(self):
+        """Test that a client is scoped after its credential, never kept, the clients without one sharing a scope."""
+        scope = Idempotency.client_scope('Bearer secret')
+
+        self.assertEqual(Idempotency.client_scope('Bearer secret'), scope)
+        self.assertNotEqual(Idempotency.client_scope('Bearer other'), scope)
+        self.assertNotIn('secret', scope)
+        self.assertEqual(Idempotency.client_scope(None), ANONYMOUS_CLIENT_SCOPE)
+
+    def test_request_hash(self):
+        """Test that the hash of a payload ignores the order of its fields, but not the endpoint."""
+        payload = {'project_uuid': 'a', 'description': 'first', 'rules': [1, 2]}
+        request_hash = Idempotency.request_hash('create_submission', payload)
+        reordered = dict(reversed(list(payload.items())))
+
+        self.assertEqual(Idempotency.request_hash('create_submission', reordered), request_hash)
+        self.assertNotEqual(Idempotency.request_hash('create_submission', {**payload, 'rules': [2, 1]}), request_hash)
+        self.assertNotEqual(Idempotency.request_hash('create_git_submission', payload), request_hash)
+
+    def test_decide(self):
+        """Test that a new key is executed, a retry replayed once completed, another payload a conflict."""
+        self.assertEqual(Idempotency.decide(None, False, 'hash'), IdempotencyDecision.EXECUTE)
+        self.assertEqual(Idempotency.decide('hash', True, 'hash'), IdempotencyDecision.REPLAY)
+        self.assertEqual(Idempotency.decide('hash', False, 'hash'), IdempotencyDecision.IN_PROGRESS)
+        self.assertEqual(Idempotency.decide('hash', True, 'other'), IdempotencyDecision.CONFLICT)
+
+
+if __name__ == '__main__':
+    unittest.main()
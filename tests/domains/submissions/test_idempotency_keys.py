@@ -0,0 +1,89 @@
+"""
+Tests for IdempotencyKeys
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.idempotency_keys import IdempotencyKeys
+from app.shared.exceptions import ConflictException
+
+
+class FakeIdempotencyKeyRepository:
+    """Idempotency keys kept in memory, a key being reserved once"""
+
+    def __init__(self):
+        self.records = {}
+
+    def get_active(self, client_scope, key, now):
+        record = self.records.get((client_scope, key))
+        return record if record and record.expires_at > now else None
+
+    def reserve(self, key_data):
+        pair = (key_data['client_scope'], key_data['key'])
+        if pair in self.records:
+            return None
+        self.records[pair] = SimpleNamespace(id=uuid4(), status_code=None, response_body=None, **key_data)
+        return self.records[pair]
+
+    def complete(self, record_id, status_code, response_body):
+        record = next(record for record in self.records.values() if record.id == record_id)
+        record.status_code, record.response_body = status_code, response_body
+
+    def delete(self, record_id):
+        self.records = {pair: record for pair, record in self.records.items() if record.id != record_id}
+
+    def delete_expired(self, now):
+        self.records = {pair: record for pair, record in self.records.items() if record.expires_at > now}
+
+
+class TestIdempotencyKeys(unittest.TestCase):
+    """Unit tests for the reservation, replay and release of the stored idempotency keys."""
+
+    def setUp(self):
+        self.repository = FakeIdempotencyKeyRepository()
+        self.keys = IdempotencyKeys(self.repository, ttl_seconds=3600)
+        self.now = datetime(2024, 1, 16, 20, 0, tzinfo=timezone.utc)
+
+    def _begin(self, request_hash='hash', now=None):
+        return self.keys.begin('client', 'key-1', 'create_submission', request_hash, now or self.now)
+
+    def _error_type(self, operation):
+        with self.assertRaises(ConflictException) as context:
+            operation()
+        return context.exception.detail['error_type']
+
+    def test_replay(self):
+        """Test that the retry of a completed request gets its stored response, the key expiring after its time."""
+        record = self._begin()
+        self.keys.complete(record.id, 201, {'submission_id': 'abc'})
+
+        replayed = self._begin()
+
+        self.assertEqual(replayed.id, record.id)
+        self.assertEqual((replayed.status_code, replayed.response_body), (201, {'submission_id': 'abc'}))
+        self.assertEqual(record.expires_at, self.now + timedelta(hours=1))
+        self.assertIsNone(self._begin(now=self.now + timedelta(hours=2)).status_code)
+
+    def test_conflicts(self):
+        """Test that a key reused with another payload, or whose request is still executed, is a conflict."""
+        self._begin()
+
+        self.assertEqual(self._error_type(lambda: self._begin()), 'idempotency_request_in_progress')
+        self.assertEqual(self._error_type(lambda: self._begin('other-hash')), 'idempotency_key_reused')
+
+    def test_release(self):
+        """Test that the released key of a failed request is reserved again by its retry."""
+        record = self._begin()
+        self.keys.release(record.id)
+
+        retried = self._begin()
+
+        self.assertNotEqual(retried.id, record.id)
+        self.assertIsNone(retried.status_code)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,73 @@
+"""
+Tests for IncrementalReanalysis
+"""
+
+import hashlib
+import unittest
+
+from app.domains.submissions.incremental_reanalysis import IncrementalReanalysis
+
+
+def content_hashes(files):
+    return {path: hashlib.sha256(content.encode('utf8')).hexdigest() for path, content in files.items()}
+
+
+class TestIncrementalReanalysis(unittest.TestCase):
+    """Unit tests for the re-analysis of the files of a new version of a submission."""
+
+    def setUp(self):
+        self.other_files = {'x.py': 'def f(): return 1', 'y.py': 'def g(): return 2', 'z.py': 'print(3)'}
+        self.version1 = {'a.py': 'def f(): return 1', 'b.py': 'def h(): pass', 'c.py': 'print(3)'}
+        self.version2 = {'a.py': 'def f(): return 1', 'b.py': 'def g(): return 2', 'd.py': 'print(3)'}
+        self.compared = []
+
+    def compare(self, files1, files2):
+        """Comparison of a pair of files, None if they share no line"""
+
+        def compare_pair(pair):
+            self.compared.append(pair)
+            shared = set(files1[pair[0]].split()) & set(files2[pair[1]].split())
+            return {'pair': pair, 'shared': sorted(shared)} if shared else None
+
+        return compare_pair
+
+    def full_recompute(self, files1, files2):
+        pairs = [(path1, path2) for path1 in files1 for path2 in files2]
+        return IncrementalReanalysis.merge(pairs, {}, self.compare(files1, files2), lambda pair: False)
+
+    def test_changes(self):
+        """Test that the files are unchanged if previously analyzed with the same content only."""
+        reanalysis = IncrementalReanalysis(content_hashes(self.version1), content_hashes(self.version2))
+
+        self.assertEqual(reanalysis.reused, ['a.py'])
+        self.assertEqual(reanalysis.reprocessed, ['b.py', 'd.py'])
+        self.assertEqual(reanalysis.removed, ['c.py'])
+        self.assertEqual(IncrementalReanalysis(None, content_hashes(self.version2)).reused, [])
+
+        entries = reanalysis.log_entries(1)
+        self.assertEqual(len(entries), 2)
+        self.assertIn('1 files unchanged since version 1: a.py', entries[0]['message'])
+        self.assertIn('2 files changed or added since version 1: b.py, d.py', entries[1]['message'])
+
+    def test_merge_matches_full_recompute(self):
+        """Test that a re-analysis reusing the unchanged pairs is identical to a full recompute."""
+        previous = {result['pair']: result for result in self.full_recompute(self.version1, self.other_files)}
+        reanalysis = IncrementalReanalysis(content_hashes(self.version1), content_hashes(self.version2))
+        others = IncrementalReanalysis(content_hashes(self.other_files), content_hashes(self.other_files))
+
+        self.compared = []
+        incremental = IncrementalReanalysis.merge(
+            [(path1, path2) for path1 in self.version2 for path2 in self.other_files],
+            previous,
+            self.compare(self.version2, self.other_files),
+            lambda pair: reanalysis.is_unchanged(pair[0]) and others.is_unchanged(pair[1]),
+        )
+        # Only the pairs of the changed and added files are compared again
+        self.assertEqual({pair[0] for pair in self.compared}, {'b.py', 'd.py'})
+        self.assertEqual(len(self.compared), 6)
+
+        self.assertEqual(incremental, self.full_recompute(self.version2, self.other_files))
+
+
+if __name__ == '__main__':
+    unittest.main()
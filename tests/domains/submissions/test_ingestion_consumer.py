@@ -0,0 +1,136 @@
+"""
+Tests for the ingestion consumer
+"""
+
+import hashlib
+import json
+import unittest
+from types import SimpleNamespace
+from unittest.mock import patch
+from uuid import uuid4
+
+from app.domains.submissions import ingestion_consumer
+from app.domains.submissions.analysis_worker_pool import AnalysisQueueFull
+from app.domains.submissions.ingestion_consumer import (
+    ATTEMPTS_HEADER,
+    DEAD_LETTERED,
+    DUPLICATE,
+    INGESTED,
+    RETRIED,
+    IngestionConsumer,
+    IngestionMessage,
+    InvalidIngestionMessage,
+)
+
+
+class FakeChannel:
+    """Channel recording the acks, nacks and publications"""
+
+    def __init__(self):
+        self.acked, self.nacked, self.published = [], [], []
+
+    def basic_ack(self, delivery_tag):
+        self.acked.append(delivery_tag)
+
+    def basic_nack(self, delivery_tag, requeue):
+        self.nacked.append((delivery_tag, requeue))
+
+    def basic_publish(self, exchange, routing_key, body, properties):
+        self.published.append((routing_key, properties.headers))
+
+
+class TestIngestionConsumer(unittest.TestCase):
+    """Unit tests for the parsing of the ingestion messages and their acks, retries and dead-lettering."""
+
+    def setUp(self):
+        self.content = b'print("hello")'
+        self.fields = {
+            'project_id': str(uuid4()),
+            'assignment_id': str(uuid4()),
+            'student_id': str(uuid4()),
+            'file_url': 'https://lms.example.com/files/main.py',
+            'checksum': 'sha256:' + hashlib.sha256(self.content).hexdigest(),
+        }
+        self.body = json.dumps(self.fields).encode()
+        self.channel = FakeChannel()
+        self.method = SimpleNamespace(delivery_tag=7)
+        pika = SimpleNamespace(BasicProperties=lambda **kwargs: SimpleNamespace(**kwargs))
+        patcher = patch.object(ingestion_consumer, 'pika', pika)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def consumer(self, ingest):
+        return IngestionConsumer('amqp://localhost', 'uploads', max_attempts=3, ingest=ingest)
+
+    def properties(self, attempts=None):
+        headers = {ATTEMPTS_HEADER: attempts} if attempts else None
+        return SimpleNamespace(content_type='application/json', headers=headers)
+
+    def test_parse_defaults_and_idempotency_key(self):
+        """Test that the group defaults to the student and that the key ignores the URL of the file."""
+        message = IngestionMessage.parse(self.body)
+        republished = IngestionMessage.parse(json.dumps({**self.fields, 'file_url': 'https://lms.example.com/b'}))
+
+        self.assertEqual(message.group_uuid, message.student_id)
+        self.assertEqual(message.idempotency_key, republished.idempotency_key)
+        self.assertEqual(message.idempotency_payload(), republished.idempotency_payload())
+        message.verify(self.content)
+
+    def test_parse_rejects_invalid_messages(self):
+        """Test that a message missing fields, with a bad URL or checksum does not parse."""
+        for body in (
+            b'not json',
+            json.dumps({**self.fields, 'checksum': ''}),
+            json.dumps({**self.fields, 'file_url': 'file:///etc/passwd'}),
+            json.dumps({**self.fields, 'checksum': 'md5:abc'}),
+            json.dumps({**self.fields, 'student_id': 'student-1'}),
+        ):
+            with self.assertRaises(InvalidIngestionMessage):
+                IngestionMessage.parse(body)
+
+    def test_acks_ingested_and_duplicate_messages(self):
+        """Test that a message is acked once ingested or recognized as a duplicate."""
+        for outcome in (INGESTED, DUPLICATE):
+            channel = FakeChannel()
+            result = self.consumer(lambda message: outcome).handle(channel, self.method, self.properties(), self.body)
+
+            self.assertEqual(result, outcome)
+            self.assertEqual(channel.acked, [7])
+            self.assertEqual(channel.published, [])
+
+    def test_retries_then_dead_letters_failed_messages(self):
+        """Test that a failed message is retried with its attempts counted, then dead-lettered at the last one."""
+
+        def fail(message):
+            raise ConnectionError('LMS unreachable')
+
+        consumer = self.consumer(fail)
+
+        self.assertEqual(consumer.handle(self.channel, self.method, self.properties(1), self.body), RETRIED)
+        self.assertEqual(self.channel.published, [('uploads.retry', {ATTEMPTS_HEADER: 2})])
+        self.assertEqual(self.channel.acked, [7])
+
+        self.assertEqual(consumer.handle(self.channel, self.method, self.properties(2), self.body), DEAD_LETTERED)
+        self.assertEqual(self.channel.nacked, [(7, False)])
+
+    def test_saturation_is_not_a_failed_attempt(self):
+        """Test that a message retried while the analysis is saturated keeps its attempts."""
+
+        def saturated(message):
+            raise AnalysisQueueFull('The analysis queue is full', 30)
+
+        consumer = self.consumer(saturated)
+
+        self.assertEqual(consumer.handle(self.channel, self.method, self.properties(2), self.body), RETRIED)
+        self.assertEqual(self.channel.published, [('uploads.retry', {ATTEMPTS_HEADER: 2})])
+
+    def test_dead_letters_invalid_messages_at_once(self):
+        """Test that a message not matching the schema is dead-lettered without being ingested."""
+        consumer = self.consumer(lambda message: self.fail('ingested'))
+
+        self.assertEqual(consumer.handle(self.channel, self.method, self.properties(), b'{}'), DEAD_LETTERED)
+        self.assertEqual(self.channel.nacked, [(7, False)])
+
+
+if __name__ == '__main__':
+    unittest.main()
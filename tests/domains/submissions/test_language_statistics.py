@@ -0,0 +1,101 @@
+"""
+Tests for LanguageStatistics
+"""
+
+import unittest
+from types import SimpleNamespace
+
+from app.domains.submissions.language_statistics import LanguageStatistics
+
+
+def _file(language, code_lines):
+    return {'file': f'main.{language or "txt"}', 'language': language, 'code_lines': code_lines}
+
+
+def _submission(files, flagged_files=(), analyzed=True):
+    return SimpleNamespace(
+        code_metrics={'files': files} if analyzed else None,
+        language_detection={'flagged_files': [{'file': path} for path in flagged_files]},
+    )
+
+
+class TestLanguageStatistics(unittest.TestCase):
+    """Unit tests for the languages of the submissions of a project step, from their stored analysis."""
+
+    def setUp(self):
+        self.statistics = LanguageStatistics()
+
+    def test_primary_language_by_code_lines(self):
+        """Test that the primary language is that of most lines of code, not of most files."""
+        files = [_file('python', 10), _file('python', 10), _file('rust', 50), _file(None, 500)]
+
+        self.assertEqual(LanguageStatistics.primary_language(files), 'rust')
+
+    def test_primary_language_tie(self):
+        """Test that a tie goes to the first language by name, whatever the order of the files."""
+        files = [_file('rust', 40), _file('go', 40)]
+
+        self.assertEqual(LanguageStatistics.primary_language(files), 'go')
+        self.assertEqual(LanguageStatistics.primary_language(list(reversed(files))), 'go')
+        self.assertIsNone(LanguageStatistics.primary_language([_file(None, 10)]))
+
+    def test_aggregate(self):
+        """Test that each language counts its primary submissions, files and lines of code."""
+        submissions = [
+            _submission([_file('python', 100), _file('python', 20)]),
+            _submission([_file('rust', 300), _file('python', 10)]),
+            _submission([_file('rust', 80)], flagged_files=['notes.md', 'build.sh']),
+        ]
+
+        statistics = self.statistics.aggregate(submissions)
+
+        self.assertEqual(statistics['submission_count'], 3)
+        self.assertEqual(statistics['analyzed_submission_count'], 3)
+        self.assertEqual(
+            statistics['languages'],
+            [
+                {
+                    'language': 'rust',
+                    'submission_count': 2,
+                    'file_count': 2,
+                    'code_lines': 380,
+                    'code_line_share': 0.7451,
+                },
+                {
+                    'language': 'python',
+                    'submission_count': 1,
+                    'file_count': 3,
+                    'code_lines': 130,
+                    'code_line_share': 0.2549,
+                },
+            ],
+        )
+        self.assertEqual(statistics['low_confidence_file_count'], 2)
+        self.assertEqual(statistics['low_confidence_submission_count'], 1)
+
+    def test_plain_text_and_unanalyzed(self):
+        """Test that the plain text files are counted apart, and the submissions not analyzed yet only counted."""
+        submissions = [
+            _submission([_file(None, 40), _file(None, 5)]),
+            _submission([_file('java', 60), _file(None, 2)]),
+            _submission([], analyzed=False),
+        ]
+
+        statistics = self.statistics.aggregate(submissions)
+
+        self.assertEqual(statistics['submission_count'], 3)
+        self.assertEqual(statistics['analyzed_submission_count'], 2)
+        self.assertEqual(statistics['plain_text_file_count'], 3)
+        self.assertEqual(statistics['plain_text_submission_count'], 1)
+        self.assertEqual([usage['language'] for usage in statistics['languages']], ['java'])
+
+    def test_no_submissions(self):
+        """Test that a step without submissions has no languages."""
+        statistics = self.statistics.aggregate([])
+
+        self.assertEqual(statistics['languages'], [])
+        self.assertEqual(statistics['analyzed_submission_count'], 0)
+
+
+if __name__ == '__main__':
+    unittest.main()
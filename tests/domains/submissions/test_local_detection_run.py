@@ -0,0 +1,91 @@
+"""
+Tests for LocalDetectionRun
+"""
+
+import csv
+import json
+import tempfile
+import unittest
+from pathlib import Path
+
+from app.domains.detection.dto.detection_options_dto import DetectionOptionsDto
+from app.domains.detection.similarity_detection_service import SimilarityDetectionService
+from app.domains.submissions.local_detection_run import LocalDetectionRun, LocalRunWriter
+from app.domains.tokenization.tokenization_service import TokenizationService
+
+SOLUTION = '''
+def fibonacci(n):
+    if n < 2:
+        return n
+    return fibonacci(n - 1) + fibonacci(n - 2)
+
+
+def main():
+    for i in range(10):
+        print(fibonacci(i))
+'''
+
+OTHER_SOLUTION = '''
+class Stack:
+    def __init__(self):
+        self.items = []
+
+    def push(self, item):
+        self.items.append(item)
+
+    def pop(self):
+        return self.items.pop() if self.items else None
+'''
+
+
+class TestLocalDetectionRun(unittest.TestCase):
+    """Unit tests for the detection runs over a directory of submissions, without the database."""
+
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+        self.root = Path(self.directory.name) / 'submissions'
+        for name, source in (('alice', SOLUTION), ('bob', SOLUTION), ('carol', OTHER_SOLUTION)):
+            (self.root / name).mkdir(parents=True)
+            (self.root / name / 'main.py').write_text(source, encoding='utf-8')
+            (self.root / name / 'helper.c').write_bytes(b'\x7fELF\x02\x01\x01' + bytes(32))
+        (self.root / '.git').mkdir()
+        self.run = LocalDetectionRun(
+            TokenizationService(), SimilarityDetectionService(), DetectionOptionsDto(), threshold=0.9
+        )
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    def test_pairs_flagged_over_the_threshold(self):
+        """Test that every pair of submissions is compared once, the copied one being flagged first."""
+        result = self.run.run(self.root)
+        self.assertEqual(result['submissions'], {'alice': 1, 'bob': 1, 'carol': 1})
+        self.assertEqual(len(result['pairs']), 3)
+        first = result['pairs'][0]
+        self.assertEqual((first['submission'], first['compared_submission']), ('alice', 'bob'))
+        self.assertTrue(first['flagged'])
+        self.assertEqual([pair['flagged'] for pair in result['pairs'][1:]], [False, False])
+
+    def test_too_few_submissions(self):
+        """Test that a directory of a single submission is refused."""
+        with self.assertRaises(ValueError):
+            self.run.run(self.root / 'alice')
+
+    def test_written_results(self):
+        """Test that the results are written as CSV and JSON, with an HTML report of each flagged pair."""
+        result = self.run.run(self.root)
+        output = Path(self.directory.name) / 'results'
+        written = LocalRunWriter(output).write(result, ['csv', 'json'], html=True)
+
+        self.assertIn(output / 'reports' / 'alice__bob.html', written)
+        with open(output / 'results.csv', newline='', encoding='utf-8') as file:
+            rows = list(csv.DictReader(file))
+        self.assertEqual(len(rows), 3)
+        self.assertEqual(rows[0]['flagged'], 'True')
+        document = json.loads((output / 'results.json').read_text(encoding='utf-8'))
+        self.assertEqual(document['configuration']['threshold'], 0.9)
+        self.assertNotIn('sources', document['pairs'][0])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,95 @@
+"""
+Tests for ClamAVScanner
+"""
+
+import socketserver
+import struct
+import threading
+import unittest
+
+from app.domains.submissions.malware_scanner import ClamAVScanner, NoOpMalwareScanner, ScannerUnavailable
+
+EICAR = b'X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*'
+
+
+class ClamdHandler(socketserver.BaseRequestHandler):
+    """Fake clamd reading an INSTREAM scan, finding the EICAR test file or replying with the error of the server."""
+
+    def handle(self):
+        command = self._read(len(b'zINSTREAM\0'))
+        content = b''
+        while True:
+            (length,) = struct.unpack('!L', self._read(4))
+            if not length:
+                break
+            self.server.chunk_sizes.append(length)
+            content += self._read(length)
+        self.server.scans.append((command, content))
+        if self.server.error:
+            reply = f'{self.server.error} ERROR'
+        elif EICAR in content:
+            reply = 'stream: Win.Test.EICAR_HDB-1 FOUND'
+        else:
+            reply = 'stream: OK'
+        self.request.sendall(reply.encode() + b'\0')
+
+    def _read(self, size):
+        data = b''
+        while len(data) < size:
+            data += self.request.recv(size - len(data))
+        return data
+
+
+class TestClamAVScanner(unittest.TestCase):
+    """Unit tests for the scans of the files by clamd."""
+
+    def setUp(self):
+        self.server = socketserver.ThreadingTCPServer(('127.0.0.1', 0), ClamdHandler)
+        self.server.daemon_threads = True
+        self.server.scans = []
+        self.server.chunk_sizes = []
+        self.server.error = None
+        threading.Thread(target=self.server.serve_forever, daemon=True).start()
+        self.scanner = ClamAVScanner('127.0.0.1', self.server.server_address[1], timeout_seconds=5, chunk_bytes=16)
+
+    def tearDown(self):
+        self.server.shutdown()
+        self.server.server_close()
+
+    def test_clean_and_infected_files(self):
+        """Test that the infected files are reported with their signature, the content being streamed in chunks."""
+        verdict = self.scanner.scan_files([('main.py', b'print("hello")\n' * 4), ('eicar.com', EICAR)])
+        self.assertTrue(verdict.infected)
+        self.assertEqual(verdict.infected_files, [{'path': 'eicar.com', 'signature': 'Win.Test.EICAR_HDB-1'}])
+        self.assertEqual(verdict.to_dict()['verdict'], 'infected')
+        self.assertEqual(self.server.scans[0], (b'zINSTREAM\0', b'print("hello")\n' * 4))
+        self.assertTrue(all(size <= 16 for size in self.server.chunk_sizes))
+
+    def test_server_error(self):
+        """Test that an error of clamd (file over its size limit) makes the scanner unavailable."""
+        self.server.error = 'INSTREAM size limit exceeded.'
+        with self.assertRaises(ScannerUnavailable):
+            self.scanner.scan(b'content')
+
+    def test_unreachable_daemon(self):
+        """Test that a daemon that cannot be reached makes the scanner unavailable."""
+        self.server.shutdown()
+        self.server.server_close()
+        with self.assertRaises(ScannerUnavailable):
+            self.scanner.scan(b'content')
+
+    def test_parse_reply(self):
+        """Test the replies of clamd to a scan."""
+        self.assertIsNone(ClamAVScanner.parse_reply('stream: OK'))
+        self.assertEqual(ClamAVScanner.parse_reply('stream: Eicar-Signature FOUND\n'), 'Eicar-Signature')
+        with self.assertRaises(ScannerUnavailable):
+            ClamAVScanner.parse_reply('')
+
+    def test_no_op_scanner(self):
+        """Test that every file is clean without a scanner."""
+        verdict = NoOpMalwareScanner().scan_files([('eicar.com', EICAR)])
+        self.assertEqual(verdict.to_dict(), {'scanner': 'none', 'verdict': 'clean', 'infected_files': []})
+
+
+if __name__ == '__main__':
+    unittest.main()
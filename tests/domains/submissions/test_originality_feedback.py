@@ -0,0 +1,87 @@
+"""
+Tests for OriginalityFeedback
+"""
+
+import unittest
+from uuid import uuid4
+
+from app.domains.submissions.originality_feedback import OriginalityFeedback
+from app.domains.submissions.submissions_models import SimilarityStatus
+from tests.domains.submissions.factories import similarity
+
+
+class TestOriginalityFeedback(unittest.TestCase):
+    """Unit tests for the anonymized originality feedback of a submission."""
+
+    def setUp(self):
+        self.ids = [uuid4() for _ in range(4)]
+
+    def _position(self, file, start_line):
+        return {'file': file, 'start_line': start_line, 'start_column': 0, 'end_line': start_line + 9, 'end_column': 1}
+
+    def _fragment(self, left, right, similarity):
+        return {'left': left, 'right': right, 'tokens': 60, 'similarity': similarity}
+
+    def test_feedback(self):
+        """Test the highest similarity and percentile of a submission, its regions on either side of its pairs."""
+        a, b, c, d = self.ids
+        own_left = self._fragment(self._position('main.py', 3), self._position('b.py', 40), 0.9)
+        own_right = self._fragment(self._position('c.py', 1), self._position('util.py', 8), 1.0)
+        similarities = [
+            similarity(a, b, 0.6, fragments=[own_left]),
+            similarity(c, a, 0.3, fragments=[own_right]),
+            similarity(c, d, 0.8),
+            similarity(b, d, 0.95, status=SimilarityStatus.FAILED),
+        ]
+
+        feedback = OriginalityFeedback().build(a, similarities)
+
+        self.assertEqual(feedback['max_similarity'], 0.6)
+        # Highest similarities: a 0.6, b 0.6, c 0.8, d 0.8
+        self.assertEqual((feedback['percentile'], feedback['compared_submissions']), (50.0, 4))
+        self.assertEqual([region['file'] for region in feedback['matched_regions']], ['main.py', 'util.py'])
+        self.assertEqual(feedback['matched_regions'][1]['start_line'], 8)
+
+    def test_counterparts_withheld(self):
+        """Test that nothing of the matched submissions is part of the feedback."""
+        a, b = self.ids[:2]
+        fragments = [self._fragment(self._position('main.py', 3), self._position('secret/b.py', 40), 0.9)]
+        record = similarity(
+            a, b, 0.6, similarity_details={'fragments': fragments, 'fragment_sources': {'submission2': {}}}
+        )
+
+        feedback = OriginalityFeedback().build(a, [record])
+
+        serialized = repr(feedback)
+        for withheld in (str(b), str(record.id), 'secret/b.py', 'right', 'fragment_sources'):
+            self.assertNotIn(withheld, serialized)
+        self.assertEqual(
+            set(feedback['matched_regions'][0]),
+            {'file', 'start_line', 'start_column', 'end_line', 'end_column', 'tokens', 'similarity'},
+        )
+
+    def test_region_listed_once(self):
+        """Test that a region matched in several pairs is listed once, with its highest similarity."""
+        a, b, c = self.ids[:3]
+        region = self._position('main.py', 3)
+        similarities = [
+            similarity(a, b, 0.6, fragments=[self._fragment(region, self._position('b.py', 40), 0.7)]),
+            similarity(a, c, 0.5, fragments=[self._fragment(region, self._position('c.py', 2), 0.9)]),
+        ]
+
+        regions = OriginalityFeedback().build(a, similarities)['matched_regions']
+
+        self.assertEqual([(region['file'], region['similarity']) for region in regions], [('main.py', 0.9)])
+
+    def test_without_comparison(self):
+        """Test that a submission not compared yet has neither a highest similarity nor a percentile."""
+        a, b, c = self.ids[:3]
+
+        feedback = OriginalityFeedback().build(a, [similarity(b, c, 0.4)])
+
+        self.assertEqual((feedback['max_similarity'], feedback['percentile']), (None, None))
+        self.assertEqual(feedback['matched_regions'], [])
+
+
+if __name__ == '__main__':
+    unittest.main()
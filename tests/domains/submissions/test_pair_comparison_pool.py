@@ -0,0 +1,118 @@
+"""
+Tests for PairComparisonPool
+"""
+
+import itertools
+import random
+import threading
+import time
+import unittest
+from concurrent.futures import ThreadPoolExecutor
+
+import pytest
+
+from app.domains.detection.greedy_string_tiling import GreedyStringTiler
+from app.domains.submissions.pair_comparison_pool import PairComparisonPool, available_cpu_count
+
+
+def tile_pair(pair):
+    """Greedy string tiling coverage of two token streams, comparison of the benchmark"""
+    tokens1, tokens2 = pair
+    tiler = GreedyStringTiler()
+    return GreedyStringTiler.coverage(tiler.tile(tokens1, tokens2), len(tokens1), len(tokens2))
+
+
+def synthetic_corpus(submission_count, seed=42):
+    """Token streams of very different lengths, sharing blocks of a common pool"""
+    generator = random.Random(seed)
+    blocks = [[generator.randrange(40) for _ in range(30)] for _ in range(20)]
+    corpus = []
+    for _ in range(submission_count):
+        tokens = []
+        for _ in range(generator.choice([2, 4, 8, 40])):
+            tokens.extend(generator.choice(blocks) if generator.random() < 0.5 else generator.choices(range(40), k=30))
+        corpus.append(tokens)
+    return corpus
+
+
+class TestPairComparisonPool(unittest.TestCase):
+    """Unit tests for the parallel comparison of the pairs of the detection runs."""
+
+    def setUp(self):
+        self.batches = []
+        self.pool = PairComparisonPool(process_count=3, write_batch_size=4, executor_factory=ThreadPoolExecutor)
+
+    def tearDown(self):
+        self.pool.shutdown()
+
+    @staticmethod
+    def uneven_compare(pair):
+        # The first pairs cost the most, completing after the next ones
+        time.sleep(0.02 if pair < 2 else 0.001)
+        if pair == 7:
+            raise ValueError('unreadable file')
+        return pair * 10
+
+    def test_batches_in_pair_order(self):
+        """Test that the outcomes are written by batches, each in the order of the pairs, whatever the completion."""
+        pairs = list(range(20))
+
+        compared = self.pool.run(pairs, self.uneven_compare, self.batches.append)
+
+        self.assertEqual(compared, 20)
+        for batch in self.batches:
+            self.assertEqual([outcome.index for outcome in batch], sorted(outcome.index for outcome in batch))
+        outcomes = sorted(itertools.chain(*self.batches), key=lambda outcome: outcome.index)
+        self.assertEqual([outcome.pair for outcome in outcomes], pairs)
+        self.assertEqual(outcomes[3].result, 30)
+        self.assertEqual((outcomes[7].result, outcomes[7].error), (None, 'unreadable file'))
+
+        # The expensive first pairs did not hold the next ones back
+        self.assertNotIn(0, [outcome.index for outcome in self.batches[0]])
+
+    def test_stopped(self):
+        """Test that no more pairs are queued once stopped, the comparisons in progress being written."""
+        stop = threading.Event()
+
+        def write(batch):
+            self.batches.append(batch)
+            stop.set()
+
+        compared = self.pool.run(list(range(100)), self.uneven_compare, write, stopped=stop.is_set)
+
+        self.assertLess(compared, 100)
+        self.assertEqual(compared, sum(len(batch) for batch in self.batches))
+
+    @pytest.mark.slow
+    def test_benchmark_corpus(self):
+        """
+        Benchmark the comparison of the 4,950 pairs of a synthetic corpus of 100 submissions, one after the other
+        then in processes on all the cores: the outcomes are those of the sequential comparison. On one core, both
+        take about the same time (about 20 seconds, and the start of the process); the processes should take less
+        than 60% of the sequential time from 4 cores.
+        """
+        corpus = synthetic_corpus(100)
+        pairs = list(itertools.combinations(corpus, 2))
+
+        start = time.perf_counter()
+        sequential = [tile_pair(pair) for pair in pairs]
+        sequential_seconds = time.perf_counter() - start
+
+        pool = PairComparisonPool(write_batch_size=50)
+        start = time.perf_counter()
+        pool.run(pairs, tile_pair, self.batches.append)
+        parallel_seconds = time.perf_counter() - start
+        pool.shutdown()
+
+        outcomes = sorted(itertools.chain(*self.batches), key=lambda outcome: outcome.index)
+        self.assertEqual([outcome.result for outcome in outcomes], sequential)
+        print(
+            f'\n{len(pairs)} pairs: {sequential_seconds:.1f} s sequential, {parallel_seconds:.1f} s on '
+            f'{pool.process_count} processes'
+        )
+        if available_cpu_count() >= 4:
+            self.assertLess(parallel_seconds, sequential_seconds * 0.6)
+
+
+if __name__ == '__main__':
+    unittest.main()
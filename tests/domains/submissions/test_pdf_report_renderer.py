@@ -0,0 +1,53 @@
+"""
+Tests for PdfReportRenderer
+"""
+
+import sys
+import unittest
+from unittest import mock
+
+from app.domains.submissions.pdf_report_renderer import PdfRendererUnavailable, PdfReportRenderer
+
+
+class TestPdfReportRenderer(unittest.TestCase):
+    """Unit tests for the PDF reports of the comparisons."""
+
+    def test_long_files_paginated(self):
+        """Test that a comparison of long files is rendered as a PDF document of several pages."""
+        from weasyprint import HTML
+
+        source = '\n'.join(f'    total += values[{number}]' for number in range(300))
+        pair = {
+            'similarity_id': 'similarity-1',
+            'submission1': {'id': 'submission-1', 'link': 'https://github.com/user/repo1'},
+            'submission2': {'id': 'submission-2', 'link': 'https://github.com/user/repo2'},
+            'overall_similarity': 0.82,
+            'status': 'completed',
+            'suspicious': True,
+            'configuration': {'flag_threshold': 0.7},
+            'fragments': [{
+                'left': {'file': 'main.go', 'start_line': 10, 'end_line': 250},
+                'right': {'file': 'main.go', 'start_line': 20, 'end_line': 260},
+                'tokens': 900,
+                'similarity': 0.97,
+            }],
+            'sources': {'submission1': {'main.go': source}, 'submission2': {'main.go': source}},
+        }
+
+        renderer = PdfReportRenderer()
+        document = renderer.render_pair(pair)
+
+        self.assertTrue(document.startswith(b'%PDF'))
+        self.assertGreater(len(HTML(string=renderer.renderer.render_pair(pair)).render().pages), 1)
+
+    def test_unavailable_renderer(self):
+        """Test that a missing WeasyPrint fails the rendering alone, the renderer being created all the same."""
+        renderer = PdfReportRenderer()
+
+        with mock.patch.dict(sys.modules, {'weasyprint': None}):
+            with self.assertRaises(PdfRendererUnavailable):
+                renderer.render_pair({'similarity_id': 'similarity-1'})
+
+
+if __name__ == '__main__':
+    unittest.main()
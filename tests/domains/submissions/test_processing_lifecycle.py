@@ -0,0 +1,58 @@
+"""
+Tests for ProcessingLifecycle
+"""
+
+import unittest
+from datetime import datetime
+
+from app.domains.submissions.processing_lifecycle import InvalidProcessingTransition, ProcessingLifecycle
+from app.domains.submissions.submissions_models import ProcessingStatus
+
+
+class TestProcessingLifecycle(unittest.TestCase):
+    """Unit tests for the stages a submission is processed through."""
+
+    def test_transitions(self):
+        """Test that each transition is recorded with its time, and the other fields set along."""
+        at = datetime(2024, 1, 15, 10, 30)
+
+        changes = ProcessingLifecycle.transition(ProcessingStatus.RECEIVED, None, ProcessingStatus.EXTRACTING, at)
+        tokenizing = ProcessingLifecycle.transition(
+            ProcessingStatus.EXTRACTING, changes['processing_transitions'], 'tokenizing', at, total_file_count=12
+        )
+
+        self.assertEqual(changes['processing_status'], ProcessingStatus.EXTRACTING)
+        self.assertEqual((changes['processed_file_count'], changes['processing_error']), (0, None))
+        self.assertEqual(tokenizing['total_file_count'], 12)
+        self.assertEqual(
+            [transition['status'] for transition in tokenizing['processing_transitions']], ['extracting', 'tokenizing']
+        )
+        self.assertEqual(tokenizing['processing_transitions'][1]['at'], '2024-01-15T10:30:00')
+        self.assertNotIn('processing_error', tokenizing)
+
+    def test_invalid_transitions(self):
+        """Test that a stage is never skipped, and that a failed submission is only processed again from extraction."""
+        at = datetime.now()
+        with self.assertRaises(InvalidProcessingTransition):
+            ProcessingLifecycle.transition(ProcessingStatus.RECEIVED, [], ProcessingStatus.ANALYZED, at)
+        with self.assertRaises(InvalidProcessingTransition):
+            ProcessingLifecycle.transition(ProcessingStatus.FAILED, [], ProcessingStatus.TOKENIZING, at)
+
+        changes = ProcessingLifecycle.transition(
+            ProcessingStatus.FAILED, [], ProcessingStatus.EXTRACTING, at, processing_error='kept'
+        )
+        self.assertEqual(changes['processing_error'], 'kept')
+        self.assertIsNone(changes['processing_failed_file'])
+
+    def test_timeline(self):
+        """Test that the timeline starts with the reception of the submission."""
+        received_at = datetime(2024, 1, 15, 10, 30)
+        transitions = [{'status': 'extracting', 'at': '2024-01-15T10:30:01'}]
+
+        timeline = ProcessingLifecycle.timeline(received_at, transitions)
+
+        self.assertEqual(timeline, [{'status': 'received', 'at': '2024-01-15T10:30:00'}] + transitions)
+
+
+if __name__ == '__main__':
+    unittest.main()
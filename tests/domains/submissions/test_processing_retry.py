@@ -0,0 +1,92 @@
+"""
+Tests for ProcessingRetryPolicy
+"""
+
+import random
+import unittest
+from datetime import datetime
+
+from app.domains.submissions.processing_retry import ProcessingRetryPolicy
+
+
+class FetchError(Exception):
+    """Structured fetch error, like the repository exceptions"""
+
+    def __init__(self, error_type):
+        super().__init__(error_type)
+        self.detail = {'error_type': error_type}
+
+
+class OperationalError(Exception):
+    """Database unavailable, like the SQLAlchemy error of the same name"""
+
+
+class TestProcessingRetryPolicy(unittest.TestCase):
+    """Unit tests for the retry of the transient processing failures."""
+
+    def test_classification(self):
+        """Test that the timeouts and the unavailable backends are transient, the other failures permanent."""
+        try:
+            try:
+                raise OperationalError('could not connect to server')
+            except OperationalError:
+                raise RuntimeError('Failed to update submission')
+        except RuntimeError as e:
+            wrapped = e
+        s3_error = Exception('SlowDown')
+        s3_error.response = {'Error': {'Code': 'SlowDown'}}
+
+        self.assertTrue(ProcessingRetryPolicy.is_transient(TimeoutError('read timed out')))
+        self.assertTrue(ProcessingRetryPolicy.is_transient(wrapped))
+        self.assertTrue(ProcessingRetryPolicy.is_transient(s3_error))
+        self.assertTrue(ProcessingRetryPolicy.is_transient(FetchError('git_network_error')))
+        self.assertFalse(ProcessingRetryPolicy.is_transient(FetchError('archive_corrupt')))
+        self.assertFalse(ProcessingRetryPolicy.is_transient(ValueError('unsupported language')))
+
+    def test_structured_error_decides(self):
+        """Test that a permanent structured error is not retried, even raised while handling a transient one."""
+        try:
+            try:
+                raise TimeoutError()
+            except TimeoutError:
+                raise FetchError('unsupported_repository')
+        except FetchError as e:
+            self.assertFalse(ProcessingRetryPolicy.is_transient(e))
+
+    def test_attempts_and_backoff(self):
+        """Test that only the transient failures are retried, up to the attempts, after a doubling jittered delay."""
+        policy = ProcessingRetryPolicy(
+            max_attempts=3, base_delay_seconds=4, max_delay_seconds=10, random_source=random.Random(7)
+        )
+
+        self.assertTrue(policy.should_retry(TimeoutError(), 1))
+        self.assertTrue(policy.should_retry(TimeoutError(), 2))
+        self.assertFalse(policy.should_retry(TimeoutError(), 3))
+        self.assertFalse(policy.should_retry(ValueError(), 1))
+        for attempt, (low, high) in enumerate([(2, 4), (4, 8), (5, 10), (5, 10)], start=1):
+            delay = policy.delay(attempt)
+            self.assertGreaterEqual(delay, low)
+            self.assertLessEqual(delay, high)
+
+    def test_attempt_record(self):
+        """Test that a failed attempt is recorded with its time, its error and when it is retried."""
+        at = datetime(2024, 1, 15, 10, 30)
+
+        record = ProcessingRetryPolicy.attempt_record(2, at, 'Read timeout', None, True, retry_in_seconds=8)
+
+        self.assertEqual(
+            record,
+            {
+                'attempt': 2,
+                'at': '2024-01-15T10:30:00',
+                'error': 'Read timeout',
+                'failed_file': None,
+                'transient': True,
+                'retry_at': '2024-01-15T10:30:08',
+            },
+        )
+        self.assertIsNone(ProcessingRetryPolicy.attempt_record(1, at, 'Corrupt', 'a.zip', False)['retry_at'])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,73 @@
+"""
+Tests for ProcessingTimeline
+"""
+
+import unittest
+from datetime import datetime, timedelta
+
+from app.domains.submissions.processing_timeline import ProcessingTimeline
+from app.domains.submissions.submissions_models import ProcessingOutcome, ProcessingStage, ProcessingWarningCode
+
+
+class TestProcessingTimeline(unittest.TestCase):
+    """Unit tests for the recording of the stages of the processing log of a submission."""
+
+    def setUp(self):
+        self.now = datetime(2024, 1, 15, 10, 30)
+        self.ticks = 0
+        self.timeline = ProcessingTimeline(clock=self._clock)
+
+    def _clock(self):
+        self.ticks += 1
+        return self.now + timedelta(milliseconds=250 * self.ticks)
+
+    def test_stage(self):
+        """Test that a stage is recorded with its start, end, duration and details."""
+        with self.timeline.stage(ProcessingStage.TOKENIZATION, 'src/main.py', file_count=3) as event:
+            event['details']['token_count'] = 120
+
+        self.assertEqual(len(self.timeline.events), 1)
+        self.assertEqual(event['outcome'], ProcessingOutcome.OK)
+        self.assertEqual(event['duration_ms'], 250)
+        self.assertEqual(event['file_path'], 'src/main.py')
+        self.assertEqual(event['details'], {'file_count': 3, 'token_count': 120})
+
+    def test_failed_stage(self):
+        """Test that a stage raising is recorded as failed with the error, which is raised again."""
+        with self.assertRaises(ValueError):
+            with self.timeline.stage(ProcessingStage.EXTRACTION):
+                raise ValueError('Archive not found')
+
+        event = self.timeline.events[0]
+        self.assertEqual(event['outcome'], ProcessingOutcome.FAILED)
+        self.assertEqual(event['error_message'], 'Archive not found')
+        self.assertIn('ended_at', event)
+
+    def test_warnings(self):
+        """Test that the warnings set the outcome, a skipped file staying skipped and a failure failed."""
+        event = self.timeline.record(
+            ProcessingStage.UPLOAD, 'a.exe', ProcessingWarningCode.BINARY_FILE_SKIPPED, 'Binary file', skipped=True
+        )
+        ProcessingTimeline.warn(event, ProcessingWarningCode.ENCODING_REPLACED, 'Replaced')
+
+        self.assertEqual(event['outcome'], ProcessingOutcome.SKIPPED)
+        codes = [warning['code'] for warning in event['warnings']]
+        self.assertEqual(codes, ['binary_file_skipped', 'encoding_replaced'])
+        self.assertEqual(self.timeline.record(ProcessingStage.LANGUAGE_DETECTION, 'b.py')['outcome'], 'ok')
+
+        with self.assertRaises(RuntimeError):
+            with self.timeline.stage(ProcessingStage.TOKENIZATION, 'c.py') as failed:
+                raise RuntimeError('Parser crashed')
+        ProcessingTimeline.warn(failed, ProcessingWarningCode.TOKENIZATION_TIMEOUT, 'Timed out', skipped=True)
+        self.assertEqual(failed['outcome'], ProcessingOutcome.FAILED)
+
+    def test_started_earlier(self):
+        """Test that a stage given its start lasts from it."""
+        event = self.timeline.record(ProcessingStage.UPLOAD, started_at=self.now)
+
+        self.assertEqual(event['started_at'], self.now)
+        self.assertEqual(event['duration_ms'], 250)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,107 @@
+"""
+Tests for ReferenceSets
+"""
+
+import unittest
+from datetime import datetime
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.reference_sets import ReferenceSets
+from app.domains.submissions.submissions_models import CorpusKind
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class FakeCorpusRepository:
+    """Corpora of all the tenants and their items kept in memory"""
+
+    def __init__(self):
+        self.corpora = {}
+        self.items = {}
+
+    def create(self, corpus_data):
+        corpus = SimpleNamespace(updated_at=None, **corpus_data)
+        self.corpora[corpus.id] = corpus
+        return corpus
+
+    def get_by_id(self, corpus_id, tenant_id):
+        corpus = self.corpora.get(corpus_id)
+        return corpus if corpus and corpus.tenant_id == tenant_id else None
+
+    def get_by_kind(self, kind, tenant_id):
+        return [corpus for corpus in self.corpora.values() if corpus.kind == kind and corpus.tenant_id == tenant_id]
+
+    def get_items(self, corpus_ids):
+        return [item for corpus_id in corpus_ids for item in self.items.get(corpus_id, [])]
+
+    def replace_items(self, corpus_id, items_data):
+        self.items[corpus_id] = [SimpleNamespace(id=uuid4(), corpus_id=corpus_id, **data) for data in items_data]
+
+    def update(self, corpus_id, update_data):
+        vars(self.corpora[corpus_id]).update(update_data)
+        return self.corpora[corpus_id]
+
+
+class FakeMatchRepository:
+    """Corpus matches deleted, kept in memory"""
+
+    def __init__(self):
+        self.deleted = []
+
+    def delete_by_corpus_item_ids(self, corpus_item_ids):
+        self.deleted.extend(corpus_item_ids)
+
+
+def fingerprint_archive(corpus_id, content, filename):
+    """One item per line of the archive, none being an invalid archive"""
+    if not content:
+        raise ValidationException('The uploaded file is empty')
+    return [{'label': path, 'path': path, 'link': filename} for path in content.decode().split()]
+
+
+class TestReferenceSets(unittest.TestCase):
+    """Unit tests for the external reference sets of a tenant."""
+
+    def setUp(self):
+        self.repository = FakeCorpusRepository()
+        self.match_repository = FakeMatchRepository()
+        self.reference_sets = ReferenceSets(self.repository, self.match_repository, 'tenant-a', fingerprint_archive)
+        self.other_tenant = ReferenceSets(self.repository, self.match_repository, 'tenant-b', fingerprint_archive)
+        self.now = datetime(2024, 3, 1, 9, 0)
+
+    def test_create(self):
+        """Test that a reference set holds a file per fingerprinted file of its archive, in its tenant only."""
+        reference_set = self.reference_sets.create(b'sort.py list.py', 'textbook.zip', 'Textbook', 'CC-BY')
+
+        self.assertEqual((reference_set.kind, reference_set.license_note), (CorpusKind.REFERENCE, 'CC-BY'))
+        self.assertEqual([item.path for item in self.repository.get_items([reference_set.id])], ['sort.py', 'list.py'])
+        self.assertEqual(self.reference_sets.get_all(), [reference_set])
+        self.assertEqual(self.other_tenant.get_all(), [])
+        with self.assertRaises(ValidationException):
+            self.reference_sets.create(b'', 'empty.zip', 'Empty')
+        self.assertEqual(len(self.repository.corpora), 1)
+
+    def test_refresh(self):
+        """Test that a refresh replaces the files, the matches with the former ones being deleted."""
+        reference_set = self.reference_sets.create(b'sort.py', 'textbook.zip', 'Textbook', 'CC-BY')
+        [former] = self.repository.get_items([reference_set.id])
+
+        refreshed = self.reference_sets.refresh(reference_set.id, b'sort.py tree.py', 'textbook-2.zip', self.now)
+
+        self.assertEqual(self.match_repository.deleted, [former.id])
+        self.assertEqual(len(self.repository.get_items([reference_set.id])), 2)
+        self.assertEqual((refreshed.updated_at, refreshed.license_note), (self.now, 'CC-BY'))
+
+    def test_refresh_unknown(self):
+        """Test that neither a reference set of another tenant nor a corpus of archived submissions is refreshed."""
+        reference_set = self.reference_sets.create(b'sort.py', 'textbook.zip', 'Textbook')
+        archive = self.repository.create({'id': uuid4(), 'tenant_id': 'tenant-a', 'kind': CorpusKind.ARCHIVE})
+
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.refresh(reference_set.id, b'sort.py', 'textbook.zip', self.now)
+        with self.assertRaises(NotFoundException):
+            self.reference_sets.refresh(archive.id, b'sort.py', 'textbook.zip', self.now)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,134 @@
+"""
+Tests for ResumableUploads
+"""
+
+import hashlib
+import tempfile
+import unittest
+from datetime import datetime, timedelta, timezone
+from pathlib import Path
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.repositories.object_storage import LocalObjectStorage
+from app.domains.submissions.chunked_upload_store import ChunkedUploadStore
+from app.domains.submissions.resumable_uploads import ResumableUploads
+from app.domains.submissions.submissions_models import UploadSessionStatus
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class FakeUploadSessionRepository:
+    """Upload sessions kept in memory"""
+
+    def __init__(self):
+        self.uploads = {}
+
+    def create(self, upload_data):
+        upload = SimpleNamespace(id=uuid4(), status=UploadSessionStatus.PENDING, submission_id=None, **upload_data)
+        self.uploads[upload.id] = upload
+        return upload
+
+    def get_by_id(self, upload_id):
+        return self.uploads.get(upload_id)
+
+    def get_by_status(self, statuses):
+        return [upload for upload in self.uploads.values() if upload.status in statuses]
+
+    def get_expired(self, now):
+        return [u for u in self.uploads.values() if u.status == UploadSessionStatus.PENDING and u.expires_at < now]
+
+    def update(self, upload_id, upload_data):
+        upload = self.uploads[upload_id]
+        for field, value in upload_data.items():
+            setattr(upload, field, value)
+        return upload
+
+
+class TestResumableUploads(unittest.TestCase):
+    """Unit tests for the chunks, assembly and expiry of the resumable uploads."""
+
+    def setUp(self):
+        directory = tempfile.TemporaryDirectory()
+        self.addCleanup(directory.cleanup)
+        self.repository = FakeUploadSessionRepository()
+        self.store = ChunkedUploadStore(LocalObjectStorage(Path(directory.name)))
+        self.uploads = ResumableUploads(self.repository, self.store, chunk_bytes=4, ttl_seconds=3600)
+        self.now = datetime(2024, 1, 16, 20, 0, tzinfo=timezone.utc)
+        self.content = b'hello world!'
+
+    def _create(self, content=None):
+        content = content or self.content
+        checksum = hashlib.sha256(content).hexdigest().upper()
+        upload_data = {'filename': 'main.zip', 'total_bytes': len(content), 'checksum': checksum}
+        return self.uploads.create(upload_data, self.now)
+
+    def _send(self, upload, indexes):
+        for index in indexes:
+            self.uploads.store_chunk(upload.id, index, self.content[index * 4 : (index + 1) * 4])
+
+    def _error_type(self, operation):
+        with self.assertRaises(ValidationException) as context:
+            operation()
+        return context.exception.detail['error_type']
+
+    def test_create(self):
+        """Test that an upload is split into chunks of the configured size, expiring after its time."""
+        upload = self._create()
+
+        self.assertEqual((upload.chunk_bytes, upload.chunk_count), (4, 3))
+        self.assertEqual(upload.checksum, hashlib.sha256(self.content).hexdigest())
+        self.assertEqual(upload.expires_at, self.now + timedelta(hours=1))
+        with self.assertRaises(NotFoundException):
+            self.uploads.get(uuid4())
+
+    def test_resumed_upload(self):
+        """Test that the chunks are assembled whatever their order, a chunk sent again with its content ignored."""
+        upload = self._create()
+        self._send(upload, [2, 0])
+
+        self.assertEqual(self._error_type(lambda: self.uploads.assemble(upload)), 'missing_chunks')
+        self._send(upload, [1, 0])
+
+        self.assertEqual(self.uploads.received_chunks(upload), [0, 1, 2])
+        self.assertEqual(self.uploads.assemble(upload), self.content)
+
+    def test_invalid_chunks(self):
+        """Test that a chunk out of range, of the wrong size, checksum or content is refused."""
+        upload = self._create()
+        self._send(upload, [0])
+        bad_checksum = hashlib.sha256(b'else').hexdigest()
+
+        self.assertEqual(self._error_type(lambda: self.uploads.store_chunk(upload.id, 3, b'!')), 'invalid_chunk_index')
+        self.assertEqual(self._error_type(lambda: self.uploads.store_chunk(upload.id, 2, b'!!')), 'invalid_chunk_size')
+        self.assertEqual(
+            self._error_type(lambda: self.uploads.store_chunk(upload.id, 1, b' wor', bad_checksum)),
+            'chunk_checksum_mismatch',
+        )
+        self.assertEqual(self._error_type(lambda: self.uploads.store_chunk(upload.id, 0, b'HELL')), 'chunk_conflict')
+
+    def test_checksum_mismatch(self):
+        """Test that an assembled file not matching its checksum fails the upload, its chunks being deleted."""
+        upload = self._create(b'hello there!')
+        self._send(upload, [0, 1, 2])
+
+        self.assertEqual(self._error_type(lambda: self.uploads.assemble(upload)), 'checksum_mismatch')
+        self.assertEqual(upload.status, UploadSessionStatus.FAILED)
+        self.assertEqual(self.uploads.received_chunks(upload), [])
+        self.assertEqual(self._error_type(lambda: self.uploads.get_pending(upload.id)), 'upload_not_pending')
+
+    def test_clean_expired(self):
+        """Test that the uploads not finalized in time expire, their chunks deleted, the pending ones kept."""
+        expired, pending = self._create(), self._create()
+        expired.expires_at = self.now - timedelta(seconds=1)
+        self._send(expired, [0])
+        self._send(pending, [0])
+
+        self.assertEqual(self.uploads.clean_expired(self.now), 1)
+
+        self.assertEqual(expired.status, UploadSessionStatus.EXPIRED)
+        self.assertEqual(self.uploads.received_chunks(expired), [])
+        self.assertEqual(self.uploads.received_chunks(pending), [0])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,117 @@
+"""
+Tests for RetentionPlanner
+"""
+
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.retention_planner import RetentionPlanner
+from app.domains.submissions.submissions_models import DetectionRunStatus, RetentionAnchor
+from tests.domains.submissions.factories import similarity
+
+
+class TestRetentionPlanner(unittest.TestCase):
+    """Unit tests for what the retention policy of a project step purges."""
+
+    def setUp(self):
+        self.now = datetime(2024, 9, 1, 12, 0, tzinfo=timezone.utc)
+        self.deadline = self.now - timedelta(days=100)
+        self.results_date = self.now - timedelta(days=110)
+
+    def _policy(self, anchor=RetentionAnchor.DEADLINE, file_days=90, result_days=180, full_deletion=False, date=None):
+        return SimpleNamespace(
+            anchor=anchor,
+            anchor_date=date,
+            file_retention_days=file_days,
+            result_retention_days=result_days,
+            full_deletion=full_deletion,
+        )
+
+    def _submission(self, days_ago=120, integrity_case_open=False, files_purged_at=None):
+        return SimpleNamespace(
+            id=uuid4(),
+            upload_date_time=self.now - timedelta(days=days_ago),
+            integrity_case_open=integrity_case_open,
+            files_purged_at=files_purged_at,
+        )
+
+    def _run(self, *submissions, days_ago=110, status=DetectionRunStatus.COMPLETED):
+        return SimpleNamespace(
+            id=uuid4(),
+            submission_ids=[str(submission.id) for submission in submissions],
+            created_at=self.now - timedelta(days=days_ago),
+            status=status,
+        )
+
+    def test_files_expire_after_the_deadline(self):
+        """Test that the files expire a number of days after the deadline, whenever the submission was uploaded."""
+        a, b = self._submission(days_ago=150), self._submission(days_ago=101)
+
+        plan = RetentionPlanner(self._policy(file_days=90), self.deadline, self.now).plan([a, b], [], [])
+        self.assertEqual(plan['expired_file_submission_ids'], [a.id, b.id])
+
+        plan = RetentionPlanner(self._policy(file_days=120), self.deadline, self.now).plan([a, b], [], [])
+        self.assertEqual(plan['expired_file_submission_ids'], [])
+
+    def test_nothing_expires_without_anchor(self):
+        """Test that nothing expires while the step has no deadline, nor without a retention period."""
+        a, b = self._submission(days_ago=400), self._submission(days_ago=400)
+        similarities = [similarity(a.id, b.id, created_at=self.now - timedelta(days=400))]
+
+        plan = RetentionPlanner(self._policy(file_days=0, result_days=0), None, self.now).plan([a, b], similarities, [])
+        self.assertEqual((plan['expired_file_submission_ids'], plan['expired_similarity_ids']), ([], []))
+
+        plan = RetentionPlanner(self._policy(file_days=None), self.deadline, self.now).plan([a, b], [], [])
+        self.assertEqual(plan['expired_file_submission_ids'], [])
+
+    def test_anchors(self):
+        """Test the fixed date and the upload of each submission as anchors, a naive date being compared as local."""
+        a, b = self._submission(days_ago=40), self._submission(days_ago=20)
+
+        upload = self._policy(RetentionAnchor.UPLOAD, file_days=30)
+        plan = RetentionPlanner(upload, None, self.now).plan([a, b], [], [])
+        self.assertEqual(plan['expired_file_submission_ids'], [a.id])
+
+        course_end = self._policy(RetentionAnchor.DATE, file_days=30, date=datetime(2024, 7, 1))
+        plan = RetentionPlanner(course_end, None, self.now).plan([a, b], [], [])
+        self.assertEqual(plan['expired_file_submission_ids'], [a.id, b.id])
+
+    def test_integrity_case_exempt(self):
+        """Test that a submission with an open integrity case is kept, with its comparisons and runs."""
+        a, b, c = self._submission(), self._submission(integrity_case_open=True), self._submission()
+        similarities = [
+            similarity(a.id, b.id, created_at=self.results_date),
+            similarity(a.id, c.id, created_at=self.results_date),
+        ]
+        runs = [self._run(a, b), self._run(a, c)]
+
+        plan = RetentionPlanner(self._policy(result_days=0, full_deletion=True), self.deadline, self.now).plan(
+            [a, b, c], similarities, runs
+        )
+
+        self.assertEqual(plan['exempt_submission_ids'], [b.id])
+        self.assertEqual(plan['expired_file_submission_ids'], [a.id, c.id])
+        self.assertEqual(plan['expired_run_ids'], [runs[1].id])
+        # The comparisons of the deleted submissions are deleted with them
+        self.assertEqual(plan['expired_similarity_ids'], [])
+
+    def test_metadata_kept(self):
+        """Test that without full deletion the purged results are not purged again, and the runs are kept."""
+        a, b = self._submission(files_purged_at=self.now - timedelta(days=1)), self._submission()
+        detailed = similarity(a.id, b.id, created_at=self.results_date)
+        purged = similarity(b.id, a.id, created_at=self.results_date, similarity_details=None)
+        running = self._run(a, b, status=DetectionRunStatus.RUNNING)
+
+        plan = RetentionPlanner(self._policy(result_days=0), self.deadline, self.now).plan(
+            [a, b], [detailed, purged], [self._run(a, b), running]
+        )
+
+        self.assertEqual(plan['expired_file_submission_ids'], [b.id])
+        self.assertEqual(plan['expired_similarity_ids'], [detailed.id])
+        self.assertEqual(plan['expired_run_ids'], [])
+
+
+if __name__ == '__main__':
+    unittest.main()
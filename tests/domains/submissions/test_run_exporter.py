@@ -0,0 +1,116 @@
+"""
+Tests for DetectionRunExporter
+"""
+
+import csv
+import io
+import json
+import unittest
+from uuid import uuid4
+
+from app.domains.submissions.run_exporter import CSV_COLUMNS, REFERENCE_CSV_COLUMNS, DetectionRunExporter
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from app.domains.submissions.similarity_matrix import SimilarityMatrix
+from app.domains.submissions.submissions_models import SimilarityStatus
+from tests.domains.submissions.factories import similarity
+
+
+class TestDetectionRunExporter(unittest.TestCase):
+    """Unit tests for the streamed CSV and JSON exports of a detection run."""
+
+    def setUp(self):
+        self.ids = [uuid4() for _ in range(3)]
+        fragment = {'left': {'start_line': 0, 'end_line': 4}, 'right': {'start_line': 2, 'end_line': 6}, 'tokens': 20}
+        self.records = [
+            similarity(self.ids[0], self.ids[1], 0.9, fragments=[fragment, fragment]),
+            similarity(self.ids[1], self.ids[0], 0.4, fragments=[]),
+            similarity(self.ids[0], self.ids[2], 0.5, fragments=[fragment]),
+            similarity(self.ids[1], self.ids[2], 0.8, tokens=(300, 10), fragments=[]),
+        ]
+        self.records.sort(key=lambda similarity: -similarity.overall_similarity)
+        self.reads = 0
+        matrix = SimilarityMatrix(SimilarityFlagger(flag_threshold=0.7, min_token_count=50))
+        self.exporter = DetectionRunExporter(matrix, self.ids, self._records)
+
+    def _records(self):
+        self.reads += 1
+        return iter(self.records)
+
+    def test_csv(self):
+        """Test that each pair is exported once as a CSV row, flagged as in the matrix of the run."""
+        rows = list(csv.reader(io.StringIO(''.join(self.exporter.csv()))))
+
+        self.assertEqual(rows[0], list(CSV_COLUMNS))
+        self.assertEqual(len(rows), 4)
+        first, too_short, last = (dict(zip(rows[0], row)) for row in rows[1:])
+        self.assertEqual(first['overall_similarity'], '0.9')
+        self.assertEqual(first['suspicious'], 'True')
+        self.assertEqual(first['fragment_count'], '2')
+        self.assertEqual(first['compared_submission_token_count'], '300')
+        self.assertEqual(too_short['suspicious'], 'False')
+        self.assertEqual(too_short['too_short'], 'True')
+        self.assertEqual(too_short['compared_submission_token_count'], '10')
+        self.assertEqual(last['status'], 'completed')
+        self.assertEqual(self.reads, 2)
+
+    def test_json_filters(self):
+        """Test that the JSON export is a document holding the filtered pairs and their fragments."""
+        run = {'run_id': uuid4(), 'min_similarity': 0.6}
+
+        document = json.loads(''.join(self.exporter.json(run, min_similarity=0.6, flagged_only=True)))
+
+        self.assertEqual(document['run']['run_id'], str(run['run_id']))
+        self.assertEqual(len(document['pairs']), 1)
+        self.assertEqual(document['pairs'][0]['submission_id'], str(self.ids[0]))
+        self.assertEqual(len(document['pairs'][0]['fragments']), 2)
+
+    def test_metrics(self):
+        """Test that the code metrics of both submissions are exported with each pair when given."""
+        summary = {'code_lines': 120, 'comment_ratio': 0.1, 'function_count': 6, 'cyclomatic_complexity': 14}
+        metrics = {self.ids[0]: summary}
+        exporter = DetectionRunExporter(self.exporter.matrix, self.ids, self._records, metrics)
+
+        rows = list(csv.reader(io.StringIO(''.join(exporter.csv()))))
+        document = json.loads(''.join(exporter.json({})))
+
+        first = dict(zip(rows[0], rows[1]))
+        self.assertEqual(first['submission_code_lines'], '120')
+        self.assertEqual(first['compared_submission_cyclomatic_complexity'], '')
+        self.assertEqual(document['pairs'][0]['submission_metrics']['function_count'], 6)
+        self.assertIsNone(document['pairs'][0]['compared_submission_metrics'])
+        self.assertEqual(len(rows[0]), len(CSV_COLUMNS) + 8)
+
+    def test_json_empty(self):
+        """Test that a run without exported pairs is a valid document."""
+        document = json.loads(''.join(self.exporter.json({}, min_similarity=1.0)))
+
+        self.assertEqual(document, {'run': {}, 'pairs': []})
+
+    def test_reference_matches(self):
+        """Test that the reference matches follow the pairs in JSON, and are exported on their own as CSV."""
+        match = {
+            'submission_id': self.ids[0],
+            'corpus_item_id': uuid4(),
+            'reference_set_id': uuid4(),
+            'reference_label': 'left-pad 1.3.0',
+            'path': 'left-pad/index.js',
+            'license_note': 'WTFPL',
+            'overall_similarity': 0.92,
+            'shared_fingerprint_count': 38,
+            'status': SimilarityStatus.COMPLETED,
+            'suspicious': True,
+        }
+
+        document = json.loads(''.join(self.exporter.json({}, min_similarity=1.0, reference_matches=[match])))
+        rows = list(csv.reader(io.StringIO(''.join(self.exporter.references_csv([match])))))
+
+        self.assertEqual(document['pairs'], [])
+        self.assertEqual(document['reference_matches'][0]['path'], 'left-pad/index.js')
+        self.assertEqual(document['reference_matches'][0]['status'], 'completed')
+        self.assertEqual(rows[0], list(REFERENCE_CSV_COLUMNS))
+        self.assertEqual(dict(zip(rows[0], rows[1]))['reference_label'], 'left-pad 1.3.0')
+        self.assertEqual(len(rows), 2)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,93 @@
+"""
+Tests for RunFingerprint
+"""
+
+import unittest
+
+from app.domains.submissions.run_fingerprint import ALGORITHM_VERSIONS, RunFingerprint
+
+
+class TestRunFingerprint(unittest.TestCase):
+    """Unit tests for the fingerprint of a detection run and the verification of its results."""
+
+    def setUp(self):
+        self.options = {
+            'detection_options': {'normalize_identifiers': True, 'exclude_paths': []},
+            'flag_threshold': 0.7,
+        }
+        self.results = {
+            'overall_similarity': 0.82,
+            'shared_blocks_count': 3,
+            'similarity_details': {'tokens_count': {'submission1': 120, 'submission2': 118}, 'matches': [[0, 4, 9]]},
+            'visualization_data': [{'file1': 'main.go', 'file2': 'main.go'}],
+            'processing_time_seconds': 1.4,
+            'cache_key': 'abc',
+        }
+
+    def test_options_hash_ignores_key_order(self):
+        """Test that the options hash depends on the options, not on the order of their keys."""
+        reordered = {'flag_threshold': 0.7, 'detection_options': {'exclude_paths': [], 'normalize_identifiers': True}}
+
+        fingerprint = RunFingerprint.build({'go': '1/0.9.0/abi-14'}, self.options)
+
+        self.assertEqual(fingerprint['options_hash'], RunFingerprint.build({}, reordered)['options_hash'])
+        changed = RunFingerprint.build({}, {**self.options, 'flag_threshold': 0.5})
+        self.assertNotEqual(fingerprint['options_hash'], changed['options_hash'])
+        self.assertEqual(fingerprint['algorithms'], ALGORITHM_VERSIONS)
+
+    def test_environment_differences(self):
+        """Test that a changed tokenizer and a new language are listed by name, the unchanged entries left out."""
+        recorded = RunFingerprint.build({'go': '1/0.9.0/abi-14', 'python': '1/0.9.0/abi-14'}, self.options)
+        current = RunFingerprint.build(
+            {'go': '1/0.10.0/abi-15', 'python': '1/0.9.0/abi-14', 'rust': '1/0.10.0/abi-15'}, self.options
+        )
+
+        differences = RunFingerprint.differences(recorded, current)
+
+        self.assertEqual(
+            differences,
+            [
+                {'field': 'tokenizers.go', 'recorded': '1/0.9.0/abi-14', 'current': '1/0.10.0/abi-15'},
+                {'field': 'tokenizers.rust', 'recorded': None, 'current': '1/0.10.0/abi-15'},
+            ],
+        )
+        self.assertEqual(RunFingerprint.differences(recorded, recorded), [])
+
+    def test_identical_results_apart_from_timing(self):
+        """Test that results differing only by their timing and cache key are identical, with the same digest."""
+        executed = {**self.results, 'processing_time_seconds': 2.9, 'cache_key': None, 'status': 'completed'}
+
+        divergence = RunFingerprint.divergence(self.results, executed)
+
+        self.assertEqual(divergence['fields'], [])
+        self.assertIsNone(divergence['stage'])
+        self.assertEqual(divergence['recorded_digest'], divergence['current_digest'])
+
+    def test_divergence_stage(self):
+        """Test that a divergence is reported with its fields and the first stage of the pipeline among theirs."""
+        details = {**self.results['similarity_details'], 'tokens_count': {'submission1': 121, 'submission2': 118}}
+        executed = {**self.results, 'overall_similarity': 0.84, 'similarity_details': details}
+
+        divergence = RunFingerprint.divergence(self.results, executed)
+
+        self.assertEqual(divergence['fields'], ['overall_similarity', 'similarity_details.tokens_count'])
+        self.assertEqual(divergence['stage'], 'tokenization')
+        self.assertNotEqual(divergence['recorded_digest'], divergence['current_digest'])
+        self.assertEqual(
+            RunFingerprint.divergence(self.results, {**self.results, 'visualization_data': []})['stage'],
+            'visualization',
+        )
+
+    def test_sample_from_seed(self):
+        """Test that the same seed draws the same pairs whatever their order, all of them if few enough."""
+        pairs = [(f'a{index}', f'b{index}') for index in range(20)]
+
+        sample = RunFingerprint.sample(pairs, 42, 5)
+
+        self.assertEqual(len(sample), 5)
+        self.assertEqual(RunFingerprint.sample(list(reversed(pairs)), 42, 5), sample)
+        self.assertEqual(RunFingerprint.sample(pairs[:3], 42, 5), sorted(pairs[:3]))
+
+
+if __name__ == '__main__':
+    unittest.main()
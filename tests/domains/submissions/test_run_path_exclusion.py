@@ -0,0 +1,52 @@
+"""
+Tests for RunPathExclusion
+"""
+
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.go_package_preprocessor import GoPackagePreprocessingResult
+from app.domains.submissions.run_path_exclusion import RunPathExclusion
+
+
+class TestRunPathExclusion(unittest.TestCase):
+    """Unit tests for the paths excluded by a detection run from its comparisons."""
+
+    def setUp(self):
+        self.repo_path = Path('/tmp/submission')
+        self.files = [
+            self.repo_path / path for path in ('main.go', 'tests/main_test.go', 'data/input.txt', 'src/data/parse.go')
+        ]
+
+    def _selection(self):
+        return GoPackagePreprocessingResult(files=list(self.files))
+
+    def test_nothing_excluded_by_default(self):
+        """Test that without patterns every file is kept."""
+        selection = self._selection()
+
+        self.assertFalse(RunPathExclusion().enabled)
+        self.assertEqual(RunPathExclusion().apply(selection, self.repo_path), [])
+        self.assertEqual(selection.files, self.files)
+
+    def test_excluded_paths(self):
+        """Test that the matching files are removed from the selection and listed by their relative path."""
+        selection = self._selection()
+
+        excluded = RunPathExclusion(['tests/', '/data/']).apply(selection, self.repo_path)
+
+        self.assertEqual(excluded, ['tests/main_test.go', 'data/input.txt'])
+        self.assertEqual(selection.files, [self.files[0], self.files[3]])
+
+    def test_negated_pattern(self):
+        """Test that a negated pattern keeps a file otherwise excluded."""
+        selection = self._selection()
+
+        excluded = RunPathExclusion(['*.go', '!main.go']).apply(selection, self.repo_path)
+
+        self.assertEqual(excluded, ['tests/main_test.go', 'src/data/parse.go'])
+        self.assertEqual(selection.files, [self.files[0], self.files[2]])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,56 @@
+"""
+Tests for RunProgressTracker
+"""
+
+import unittest
+
+from app.domains.submissions.run_progress import RunProgressTracker
+
+
+class FakeClock:
+    """Clock advanced by hand"""
+
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class TestRunProgressTracker(unittest.TestCase):
+    """Unit tests for the progress and ETA of the detection runs."""
+
+    def setUp(self):
+        self.clock = FakeClock()
+        self.tracker = RunProgressTracker(window_seconds=60.0, clock=self.clock)
+
+    def test_throughput(self):
+        """Test that the throughput counts the completions since the start, then over the window only."""
+        self.assertIsNone(self.tracker.throughput('run'))
+
+        self.tracker.start('run')
+        self.clock.now += 10
+        self.tracker.record('run', 20)
+        self.assertAlmostEqual(self.tracker.throughput('run'), 2.0)
+
+        # The completions older than the window no longer count
+        self.clock.now += 60
+        self.tracker.record('run', 30)
+        self.assertAlmostEqual(self.tracker.throughput('run'), 0.5)
+
+        self.tracker.forget('run')
+        self.assertIsNone(self.tracker.throughput('run'))
+
+    def test_estimate(self):
+        """Test the percentage and the time left of a run, unknown without throughput."""
+        self.assertEqual(
+            RunProgressTracker.estimate(200, 50, 5.0),
+            {'progress_percentage': 25.0, 'eta_seconds': 30.0, 'pairs_per_second': 5.0},
+        )
+        self.assertIsNone(RunProgressTracker.estimate(200, 50, None)['eta_seconds'])
+        self.assertEqual(RunProgressTracker.estimate(200, 200, None)['eta_seconds'], 0.0)
+        self.assertEqual(RunProgressTracker.estimate(0, 0, None)['progress_percentage'], 100.0)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,59 @@
+"""
+Tests for RunResultsFeed
+"""
+
+import threading
+import time
+import unittest
+
+from app.domains.submissions.run_results_feed import RunResultsFeed
+
+
+class TestRunResultsFeed(unittest.TestCase):
+    """Unit tests for the notifications waking the streams of the run results."""
+
+    def setUp(self):
+        self.feed = RunResultsFeed()
+
+    def test_wakes_follower(self):
+        """Test that a follower waiting for a run is woken by its next notification, not by another run."""
+        version = self.feed.version('run')
+        woken = []
+        follower = threading.Thread(target=lambda: woken.append(self.feed.wait('run', version, 2)))
+        follower.start()
+
+        self.feed.publish('other-run')
+        time.sleep(0.1)
+        self.assertEqual(woken, [])
+        self.feed.publish('run')
+        follower.join(1)
+
+        self.assertEqual(woken, [True])
+        self.assertEqual(self.feed.version('run'), version + 1)
+
+    def test_write_before_wait_not_missed(self):
+        """Test that a notification between reading the version and waiting does not wait for the timeout."""
+        version = self.feed.version('run')
+        self.feed.publish('run')
+
+        started = time.monotonic()
+        self.assertTrue(self.feed.wait('run', version, 2))
+        self.assertLess(time.monotonic() - started, 0.1)
+
+    def test_timeout(self):
+        """Test that a follower without notification gives up at the timeout, to read the database again."""
+        self.assertFalse(self.feed.wait('run', self.feed.version('run'), 0.05))
+
+    def test_forget_wakes_follower(self):
+        """Test that forgetting a finished run wakes its followers one last time."""
+        self.feed.publish('run')
+        version = self.feed.version('run')
+        timer = threading.Timer(0.05, self.feed.forget, args=('run',))
+        timer.start()
+
+        self.assertTrue(self.feed.wait('run', version, 2))
+        self.assertEqual(self.feed.version('run'), 0)
+
+
+if __name__ == '__main__':
+    unittest.main()
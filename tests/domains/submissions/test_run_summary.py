@@ -0,0 +1,80 @@
+"""
+Tests for DetectionRunSummarizer
+"""
+
+import unittest
+from uuid import uuid4
+
+from app.domains.submissions.run_summary import DetectionRunSummarizer
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from app.domains.submissions.similarity_matrix import SimilarityMatrix
+from app.domains.submissions.submissions_models import SimilarityStatus
+from tests.domains.submissions.factories import similarity
+
+
+class TestDetectionRunSummarizer(unittest.TestCase):
+    """Unit tests for the score distribution of a detection run."""
+
+    def setUp(self):
+        self.ids = [uuid4() for _ in range(5)]
+        self.matrix = SimilarityMatrix(SimilarityFlagger(flag_threshold=0.7, min_token_count=0))
+        self.summarizer = DetectionRunSummarizer()
+
+    def _summary(self, similarities, pair_count):
+        built = self.matrix.build(self.ids, similarities, limit=len(similarities))
+        return self.summarizer.summarize(built, pair_count)
+
+    def test_distribution(self):
+        """Test the histogram, statistics and central submissions of the scores of a run."""
+        a, b, c, d, e = self.ids
+        similarities = [
+            similarity(a, b, 0.9),
+            similarity(a, c, 0.8),
+            similarity(b, c, 0.75),
+            similarity(a, d, 0.29),
+            similarity(d, e, 0.1),
+            similarity(c, e, 0.0, SimilarityStatus.FAILED),
+        ]
+
+        summary = self._summary(similarities, pair_count=6)
+        view = self.summarizer.view(summary, buckets=4, top=2)
+
+        self.assertTrue(summary['complete'])
+        self.assertEqual((summary['completed_pairs'], summary['failed_pairs']), (5, 1))
+        self.assertEqual(summary['score_counts'][29], 1)
+        self.assertEqual([bucket['count'] for bucket in view['histogram']], [1, 1, 0, 3])
+        self.assertEqual((view['histogram'][1]['min'], view['histogram'][1]['max']), (0.25, 0.5))
+        self.assertEqual((summary['mean'], summary['median'], summary['p95']), (0.568, 0.75, 0.9))
+        self.assertEqual((summary['flagged_pairs'], summary['cluster_count']), (3, 1))
+        self.assertEqual(
+            [(s['submission_id'], s['flagged_pair_count']) for s in view['central_submissions']],
+            sorted([(str(a), 2), (str(b), 2)]),
+        )
+        self.assertNotIn('score_counts', view)
+
+    def test_partial(self):
+        """Test that a run some of whose pairs are not compared yet has an incomplete summary."""
+        a, b, c = self.ids[:3]
+        similarities = [similarity(a, b, 0.4), similarity(a, c, 0.0, SimilarityStatus.PROCESSING)]
+
+        summary = self._summary(similarities, pair_count=3)
+
+        self.assertFalse(summary['complete'])
+        self.assertEqual((summary['completed_pairs'], summary['pending_pairs']), (1, 2))
+        self.assertEqual(summary['median'], 0.4)
+        self.assertEqual(self._summary([], pair_count=3)['mean'], None)
+
+    def test_timed_out(self):
+        """Test that the pairs timed out complete the run without being scored."""
+        a, b, c = self.ids[:3]
+        similarities = [similarity(a, b, 0.4), similarity(a, c, 0.0, SimilarityStatus.TIMED_OUT)]
+
+        summary = self._summary(similarities, pair_count=2)
+
+        self.assertTrue(summary['complete'])
+        self.assertEqual((summary['completed_pairs'], summary['timed_out_pairs'], summary['pending_pairs']), (1, 1, 0))
+        self.assertEqual(summary['mean'], 0.4)
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,130 @@
+"""
+Tests for DetectionRunVerifier
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.run_fingerprint import RunFingerprint
+from app.domains.submissions.run_verifier import DetectionRunVerifier
+from app.domains.submissions.submissions_models import SimilarityStatus
+from app.shared.deadlines import StageDeadline, StageTimeout
+from tests.domains.submissions.factories import similarity
+
+
+class TestDetectionRunVerifier(unittest.TestCase):
+    """Unit tests for the verification of the reproducibility of a detection run."""
+
+    def setUp(self):
+        self.language_versions = {'go': '1/0.9.0/abi-14'}
+        self.effective_options = {'detection_options': {'normalize_identifiers': True}}
+        submission_ids = sorted(uuid4() for _ in range(3))
+        self.submissions = {submission_id: SimpleNamespace(id=submission_id) for submission_id in submission_ids}
+        first, second, third = self.submissions
+        self.similarities = [
+            self._similarity(first, second, 0.8),
+            self._similarity(first, third, 0.4),
+            self._similarity(second, third, 0.1),
+        ]
+        self.current = {}
+
+    def _similarity(self, submission_id, compared_submission_id, overall_similarity):
+        return similarity(submission_id, compared_submission_id, overall_similarity, cache_key=None)
+
+    def _run(self, fingerprint=None):
+        return SimpleNamespace(
+            id=uuid4(),
+            effective_options=self.effective_options,
+            fingerprint=fingerprint or RunFingerprint.build(self.language_versions, self.effective_options),
+        )
+
+    @staticmethod
+    def _results(record):
+        return {'overall_similarity': record.overall_similarity, 'similarity_details': record.similarity_details}
+
+    def _compare(self, submission, compared_submission):
+        pair = (submission.id, compared_submission.id)
+        result = self.current.get(pair)
+        if isinstance(result, BaseException):
+            raise result
+        if result is None:
+            record = next(s for s in self.similarities if (s.submission_id, s.compared_submission_id) == pair)
+            result = self._results(record)
+        return result
+
+    def _verify(self, run=None, similarities=None, cache_keys=None, sample_size=10, language_versions=None):
+        verifier = DetectionRunVerifier(self._compare, self._results, None, language_versions or self.language_versions)
+        return verifier.verify(
+            run or self._run(), self.submissions, similarities or self.similarities, cache_keys or {}, sample_size, 7
+        )
+
+    def test_reproducible(self):
+        """Test that identical results make a reproducible run, every completed pair being verified."""
+        report = self._verify()
+
+        self.assertTrue(report['reproducible'])
+        self.assertIsNone(report['stage'])
+        self.assertEqual((report['verified_pair_count'], report['identical_pair_count']), (3, 3))
+        self.assertEqual(report['environment_differences'], [])
+
+    def test_divergence(self):
+        """Test that a pair whose results differ is reported with its fields and the stage they come from."""
+        record = self.similarities[0]
+        self.current[(record.submission_id, record.compared_submission_id)] = {
+            **self._results(record),
+            'similarity_details': {**record.similarity_details, 'fragments': [[0, 4]]},
+        }
+
+        report = self._verify()
+
+        self.assertFalse(report['reproducible'])
+        self.assertEqual(report['stage'], 'fingerprinting')
+        [diverged] = [pair for pair in report['pairs'] if pair['status'] == 'diverged']
+        self.assertEqual(diverged['similarity_id'], record.id)
+        self.assertEqual(diverged['fields'], ['similarity_details.fragments'])
+
+    def test_failed_comparisons(self):
+        """Test that a comparison failing or timing out is reported failed, the environment change blamed."""
+        first, second = self.similarities[0], self.similarities[1]
+        deadline = StageDeadline('comparison', 1.0, iter([0.0, 2.5, 2.5]).__next__)
+        self.current[(first.submission_id, first.compared_submission_id)] = StageTimeout(deadline)
+        self.current[(second.submission_id, second.compared_submission_id)] = ValueError('Repository not found')
+
+        report = self._verify(language_versions={'go': '2/0.9.0/abi-14'})
+
+        self.assertEqual(report['failed_pair_count'], 2)
+        self.assertEqual(report['stage'], 'environment')
+        errors = sorted(pair['error'] for pair in report['pairs'] if pair['status'] == 'failed')
+        self.assertEqual(errors, ['Comparison timed out after 2.5 seconds', 'Repository not found'])
+        self.assertEqual(report['environment_differences'][0]['field'], 'tokenizers.go')
+
+    def test_superseded_and_incomplete_pairs(self):
+        """Test that the pairs compared again since the run are only counted, the incomplete ones left out."""
+        superseded, current, failed = self.similarities
+        superseded.cache_key, current.cache_key = 'old', 'current'
+        failed.status = SimilarityStatus.FAILED
+        cache_keys = {
+            (superseded.submission_id, superseded.compared_submission_id): 'new',
+            (current.submission_id, current.compared_submission_id): 'current',
+        }
+
+        report = self._verify(cache_keys=cache_keys)
+
+        self.assertEqual(report['completed_pair_count'], 2)
+        self.assertEqual(report['superseded_pair_count'], 1)
+        self.assertEqual([pair['similarity_id'] for pair in report['pairs']], [current.id])
+
+    def test_sample_drawn_from_seed(self):
+        """Test that the sample of the pairs verified is the same for the same seed."""
+        first = self._verify(sample_size=2)
+        second = self._verify(sample_size=2, similarities=list(reversed(self.similarities)))
+
+        self.assertEqual(first['verified_pair_count'], 2)
+        self.assertEqual(
+            [pair['similarity_id'] for pair in first['pairs']], [pair['similarity_id'] for pair in second['pairs']]
+        )
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,70 @@
+"""
+Tests for SimilarityClusterer
+"""
+
+import unittest
+from uuid import uuid4
+
+from app.domains.submissions.similarity_clusterer import SimilarityClusterer
+from app.domains.submissions.submissions_models import SimilarityStatus
+
+
+class TestSimilarityClusterer(unittest.TestCase):
+    """Unit tests for the single linkage clustering of the flagged pairs of a detection run."""
+
+    def setUp(self):
+        self.clusterer = SimilarityClusterer(merge_threshold=0.85)
+        self.ids = [uuid4() for _ in range(7)]
+
+    def _entry(self, first, second, overall_similarity, flag_threshold=0.7, status=SimilarityStatus.COMPLETED):
+        return {
+            'submission_id': self.ids[first],
+            'compared_submission_id': self.ids[second],
+            'overall_similarity': overall_similarity,
+            'status': status,
+            'suspicious': overall_similarity >= flag_threshold,
+        }
+
+    def test_shared_code_group(self):
+        """Test that five students sharing code form one cluster instead of ten pairs, with its statistics."""
+        entries = [
+            self._entry(first, second, 0.9 if 1 in (first, second) else 0.75)
+            for first in range(5)
+            for second in range(first + 1, 5)
+        ]
+        entries += [self._entry(0, 5, 0.3), self._entry(5, 6, 0.0, status=SimilarityStatus.FAILED)]
+
+        clusters = self.clusterer.cluster(entries)
+
+        self.assertEqual(len(clusters), 1)
+        self.assertEqual(set(clusters[0]['submission_ids']), set(self.ids[:5]))
+        self.assertEqual(clusters[0]['size'], 5)
+        self.assertEqual((clusters[0]['min_similarity'], clusters[0]['max_similarity']), (0.75, 0.9))
+        self.assertEqual(clusters[0]['avg_similarity'], 0.81)
+        self.assertEqual(clusters[0]['medoid_submission_id'], self.ids[1])
+
+    def test_bridge_merges_above_merge_threshold(self):
+        """Test that a pair bridging two clusters merges them only at or above the merge threshold."""
+        entries = [self._entry(0, 1, 0.95), self._entry(2, 3, 0.9), self._entry(1, 2, 0.8), self._entry(3, 4, 0.72)]
+
+        clusters = self.clusterer.cluster(entries)
+        merged = SimilarityClusterer(merge_threshold=0.8).cluster(entries)
+
+        self.assertEqual([set(cluster['submission_ids']) for cluster in clusters],
+                         [set(self.ids[2:5]), set(self.ids[:2])])
+        self.assertEqual(len(merged), 1)
+        self.assertEqual(merged[0]['size'], 5)
+        self.assertEqual(merged[0]['min_similarity'], 0.72)
+
+    def test_ties_in_any_order(self):
+        """Test that the pairs of the same similarity yield the same clusters whatever the order of the entries."""
+        entries = [self._entry(0, 1, 0.9), self._entry(2, 3, 0.9), self._entry(1, 4, 0.8), self._entry(3, 4, 0.8)]
+
+        clusters = self.clusterer.cluster(entries)
+
+        self.assertEqual(self.clusterer.cluster(list(reversed(entries))), clusters)
+        self.assertEqual(sorted(cluster['size'] for cluster in clusters), [2, 3])
+
+
+if __name__ == '__main__':
+    unittest.main()
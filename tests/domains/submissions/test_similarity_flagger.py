@@ -0,0 +1,56 @@
+"""
+Tests for SimilarityFlagger
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from tests.domains.submissions.factories import similarity
+
+
+class TestSimilarityFlagger(unittest.TestCase):
+    """Unit tests for the suspicion flags and summary of a detection run."""
+
+    def setUp(self):
+        self.long1, self.long2, self.short = uuid4(), uuid4(), uuid4()
+        self.similarities = [
+            similarity(self.long1, self.long2, 0.55, tokens=(400, 350)),
+            similarity(self.long1, self.short, 0.9, tokens=(400, 30)),
+            similarity(self.short, self.long2, 0.3, tokens=(30, 350)),
+            # Pair whose comparison failed, without token counts
+            SimpleNamespace(
+                submission_id=self.long2,
+                compared_submission_id=uuid4(),
+                overall_similarity=0.0,
+                similarity_details=None,
+            ),
+        ]
+
+    def test_flags_with_threshold(self):
+        """Test that pairs at or above the threshold are suspicious, unless a submission is too short."""
+        flagger = SimilarityFlagger(flag_threshold=0.55, min_token_count=50)
+        too_short = flagger.too_short_submissions(self.similarities)
+
+        flags = [flagger.flag(similarity, too_short) for similarity in self.similarities]
+
+        self.assertEqual(too_short, {self.short})
+        self.assertEqual(flags[0], {'suspicious': True, 'too_short': False})
+        self.assertEqual(flags[1], {'suspicious': False, 'too_short': True})
+        self.assertEqual(flags[3], {'suspicious': False, 'too_short': False})
+        self.assertEqual(SimilarityFlagger(0.6, 50).flag(self.similarities[0], too_short)['suspicious'], False)
+
+    def test_summary_excludes_too_short_submissions(self):
+        """Test that the pairs of too short submissions are not counted in the compared pairs of the run."""
+        summary = SimilarityFlagger(flag_threshold=0.5, min_token_count=50).summarize(self.similarities)
+
+        self.assertEqual(summary['compared_pairs'], 2)
+        self.assertEqual(summary['flagged_pairs'], 1)
+        self.assertEqual(summary['too_short_submissions'], [self.short])
+        self.assertEqual(SimilarityFlagger(0.5, 0).summarize(self.similarities)['compared_pairs'], 4)
+        self.assertEqual(SimilarityFlagger(0.5, 0).summarize(self.similarities)['flagged_pairs'], 2)
+
+
+if __name__ == '__main__':
+    unittest.main()
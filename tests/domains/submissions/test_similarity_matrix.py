@@ -0,0 +1,161 @@
+"""
+Tests for SimilarityMatrix
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.similarity_flagger import SimilarityFlagger
+from app.domains.submissions.similarity_matrix import PairSortField, SimilarityMatrix
+from app.domains.submissions.submissions_models import SimilarityStatus
+from tests.domains.submissions.factories import similarity
+
+
+class TestSimilarityMatrix(unittest.TestCase):
+    """Unit tests for the pairs and the sparse matrix of a detection run."""
+
+    def setUp(self):
+        self.matrix = SimilarityMatrix(SimilarityFlagger(flag_threshold=0.7, min_token_count=0))
+        self.ids = [uuid4() for _ in range(4)]
+
+    def test_distinct_pairs(self):
+        """Test that each unordered pair appears once, without self pairs nor pairs of the same group."""
+        group = uuid4()
+        submissions = [SimpleNamespace(id=submission_id, group_uuid=uuid4()) for submission_id in self.ids[:3]]
+        submissions.append(SimpleNamespace(id=self.ids[3], group_uuid=group))
+        submissions[0].group_uuid = group
+
+        pairs = SimilarityMatrix.pairs(submissions + [submissions[1]])
+
+        keys = [SimilarityMatrix.pair_key(first.id, second.id) for first, second in pairs]
+        self.assertEqual(len(pairs), 5)
+        self.assertEqual(len(set(keys)), 5)
+        self.assertNotIn(SimilarityMatrix.pair_key(self.ids[0], self.ids[3]), keys)
+
+    def test_sparse_matrix(self):
+        """Test the floor and pagination of the listed pairs, the flagged pairs and the maximum similarities."""
+        a, b, c, d = self.ids
+        similarities = [
+            similarity(a, b, 0.9),
+            similarity(b, a, 0.1),  # Same pair in the other order, ignored
+            similarity(c, a, 0.4),
+            similarity(b, c, 0.75),
+            similarity(c, d, 0.2),
+            similarity(a, d, 0.0, SimilarityStatus.PENDING),
+            similarity(a, uuid4(), 0.95),  # Submission outside of the run
+        ]
+
+        matrix = self.matrix.build(self.ids, similarities, min_similarity=0.3, skip=1, limit=1)
+        maximums = {entry['submission_id']: entry for entry in matrix['max_similarities']}
+
+        self.assertEqual(matrix['compared_pairs'], 5)
+        self.assertEqual(matrix['status_breakdown'], {SimilarityStatus.COMPLETED: 4, SimilarityStatus.PENDING: 1})
+        self.assertEqual(matrix['total_pairs'], 3)
+        self.assertEqual([pair['overall_similarity'] for pair in matrix['pairs']], [0.75])
+        self.assertEqual([pair['overall_similarity'] for pair in matrix['flagged_pairs']], [0.9, 0.75])
+        self.assertEqual((maximums[a]['max_similarity'], maximums[a]['most_similar_submission_id']), (0.9, b))
+        self.assertEqual((maximums[c]['max_similarity'], maximums[c]['most_similar_submission_id']), (0.75, b))
+        self.assertEqual((maximums[d]['max_similarity'], maximums[d]['most_similar_submission_id']), (0.2, c))
+
+    def test_same_team_pairs(self):
+        """Test that the pairs of teammates are marked and, unless included, neither flagged nor clustered."""
+        a, b, c, d = self.ids
+        similarities = [
+            similarity(a, b, 0.95),
+            similarity(a, c, 0.8),
+            similarity(c, d, 0.9),
+            similarity(b, d, 0.3),
+        ]
+        teams = {a: 'team-1', b: 'team-1', c: 'team-2'}
+
+        matrix = SimilarityMatrix(self.matrix.flagger, teams=teams).build(self.ids, similarities)
+        included = SimilarityMatrix(self.matrix.flagger, teams=teams, include_same_team=True).build(
+            self.ids, similarities
+        )
+
+        self.assertEqual([pair['same_team'] for pair in matrix['pairs']], [True, False, False, False])
+        self.assertEqual((matrix['same_team_pairs'], matrix['suppressed_pairs']), (1, 1))
+        self.assertEqual([pair['overall_similarity'] for pair in matrix['flagged_pairs']], [0.9, 0.8])
+        self.assertEqual(matrix['clusters'][0]['medoid_submission_id'], c)
+        self.assertEqual(matrix['clusters'][0]['min_similarity'], 0.8)
+        self.assertEqual(included['suppressed_pairs'], 0)
+        self.assertEqual(len(included['flagged_pairs']), 3)
+
+    def test_deleted_submission_pairs(self):
+        """Test that the pairs of a submission deleted since the run keep their results, marked as such."""
+        a, b, c, d = self.ids
+        similarities = [similarity(a, b, 0.9), similarity(c, d, 0.8), similarity(b, c, 0.2)]
+
+        matrix = SimilarityMatrix(self.matrix.flagger, deleted_submission_ids={b, uuid4()}).build(
+            self.ids, similarities
+        )
+
+        self.assertEqual([pair['deleted_submission'] for pair in matrix['pairs']], [True, False, True])
+        self.assertEqual([pair['overall_similarity'] for pair in matrix['flagged_pairs']], [0.9, 0.8])
+        self.assertEqual(matrix['deleted_submission_ids'], [b])
+
+    def test_sort_by_function_similarity(self):
+        """Test that the pairs are listed by their most similar functions if requested, those without last."""
+        a, b, c, d = self.ids
+        similarities = [
+            similarity(a, b, 0.6, max_function_similarity=0.4),
+            similarity(c, d, 0.3, max_function_similarity=0.95),
+            similarity(b, c, 0.5),
+        ]
+
+        matrix = self.matrix.build(self.ids, similarities, sort_by=PairSortField.MAX_FUNCTION_SIMILARITY)
+
+        self.assertEqual([pair['max_function_similarity'] for pair in matrix['pairs']], [0.95, 0.4, None])
+        self.assertEqual(matrix['sort_by'], PairSortField.MAX_FUNCTION_SIMILARITY)
+        maximums = {entry['submission_id']: entry['max_similarity'] for entry in matrix['max_similarities']}
+        self.assertEqual(maximums[b], 0.6)
+
+    def test_corpus_matches(self):
+        """Test that each submission is reported once per archived submission, with the label of the item."""
+        item, other_item = uuid4(), uuid4()
+        a, b = self.ids[:2]
+        matches = [
+            SimpleNamespace(submission_id=a, corpus_item_id=item, overall_similarity=0.0, jaccard_similarity=0.0,
+                            shared_fingerprint_count=0, status=SimilarityStatus.FAILED),
+            SimpleNamespace(submission_id=a, corpus_item_id=item, overall_similarity=0.8, jaccard_similarity=0.6,
+                            shared_fingerprint_count=40, status=SimilarityStatus.COMPLETED),
+            SimpleNamespace(submission_id=b, corpus_item_id=other_item, overall_similarity=0.1,
+                            jaccard_similarity=0.05, shared_fingerprint_count=5, status=SimilarityStatus.COMPLETED),
+        ]
+
+        corpus_matches = self.matrix.build_corpus_matches(matches, {item: '2023/alice'}, min_similarity=0.05)
+
+        self.assertEqual(len(corpus_matches), 2)
+        self.assertEqual((corpus_matches[0]['corpus_label'], corpus_matches[0]['suspicious']), ('2023/alice', True))
+        self.assertEqual(corpus_matches[0]['status'], SimilarityStatus.COMPLETED)
+        self.assertEqual((corpus_matches[1]['corpus_label'], corpus_matches[1]['suspicious']), (str(other_item), False))
+        self.assertEqual(self.matrix.build_corpus_matches(matches, {}, min_similarity=0.5)[0]['submission_id'], a)
+
+    def test_reference_matches(self):
+        """Test that the reference matches name the set and file, those sharing no fingerprint not being listed."""
+        reference_set = SimpleNamespace(id=uuid4(), label='left-pad 1.3.0', license_note='WTFPL')
+        index = SimpleNamespace(id=uuid4(), corpus_id=reference_set.id, path='left-pad/index.js', label='index.js')
+        readme = SimpleNamespace(id=uuid4(), corpus_id=reference_set.id, path='left-pad/README.js', label='README.js')
+        a, b = self.ids[:2]
+        matches = [
+            SimpleNamespace(submission_id=a, corpus_item_id=index.id, overall_similarity=0.9, jaccard_similarity=0.1,
+                            shared_fingerprint_count=36, status=SimilarityStatus.COMPLETED),
+            SimpleNamespace(submission_id=b, corpus_item_id=readme.id, overall_similarity=0.0,
+                            jaccard_similarity=0.0, shared_fingerprint_count=0, status=SimilarityStatus.COMPLETED),
+            SimpleNamespace(submission_id=b, corpus_item_id=uuid4(), overall_similarity=0.5,
+                            jaccard_similarity=0.1, shared_fingerprint_count=9, status=SimilarityStatus.COMPLETED),
+        ]
+        items = {index.id: index, readme.id: readme}
+
+        reference_matches = self.matrix.build_reference_matches(matches, items, {reference_set.id: reference_set})
+
+        self.assertEqual(len(reference_matches), 1)
+        self.assertEqual(reference_matches[0]['reference_label'], 'left-pad 1.3.0')
+        self.assertEqual(reference_matches[0]['path'], 'left-pad/index.js')
+        self.assertEqual(reference_matches[0]['license_note'], 'WTFPL')
+        self.assertTrue(reference_matches[0]['suspicious'])
+
+
+if __name__ == '__main__':
+    unittest.main()
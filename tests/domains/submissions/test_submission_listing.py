@@ -0,0 +1,178 @@
+"""
+Tests for the listing of the submissions a page at a time
+"""
+
+import base64
+import json
+import unittest
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+from uuid import uuid4
+
+from sqlmodel import Session, SQLModel, create_engine
+from sqlmodel.pool import StaticPool
+
+from app.domains.submissions.dto.submission_page_dto import SortOrder, SubmissionSortField
+from app.domains.submissions.submissions_models import Submission, SubmissionStatus
+from app.domains.submissions.submissions_repository import SubmissionRepository
+from app.domains.submissions.submissions_service import SubmissionService
+from app.shared.exceptions import BadRequestException
+
+
+class TestSubmissionListing(unittest.TestCase):
+    """Unit tests for the keyset pagination of the submissions, its cursors and its filters."""
+
+    def setUp(self):
+        engine = create_engine('sqlite://', connect_args={'check_same_thread': False}, poolclass=StaticPool)
+        SQLModel.metadata.create_all(engine, tables=[Submission.__table__])
+        self.session = Session(engine)
+        self.addCleanup(self.session.close)
+        self.service = SubmissionService.__new__(SubmissionService)
+        self.service.repository = SubmissionRepository(self.session)
+        self.service.access = SimpleNamespace(submission_filters=lambda: {}, tenant_filters=lambda: {})
+        self.project_uuid = uuid4()
+        self.start = datetime(2024, 1, 15, 9, 0)
+
+    def _submission(self, created_at, **fields):
+        submission = Submission(
+            link='https://github.com/student/project',
+            project_uuid=self.project_uuid,
+            group_uuid=uuid4(),
+            project_step_uuid=uuid4(),
+            created_at=created_at,
+            **fields,
+        )
+        self.session.add(submission)
+        self.session.commit()
+        self.session.refresh(submission)
+        return submission
+
+    def _list_all(self, limit, **kwargs):
+        """IDs of every page of a listing, following the cursors"""
+        ids, cursor = [], None
+        while True:
+            page = self.service.list_submissions(limit=limit, cursor=cursor, **kwargs)
+            ids.extend(item.id for item in page.items)
+            if not page.has_more:
+                self.assertIsNone(page.next_cursor)
+                return ids
+            cursor = page.next_cursor
+
+    @staticmethod
+    def _cursor(payload):
+        return base64.urlsafe_b64encode(json.dumps(payload).encode('utf-8')).decode('ascii').rstrip('=')
+
+    def test_default_page(self):
+        """Test that without parameters the first page holds the latest submissions, with the total."""
+        submissions = [self._submission(self.start + timedelta(minutes=index)) for index in range(3)]
+
+        page = self.service.list_submissions()
+
+        self.assertEqual([item.id for item in page.items], [sub.id for sub in reversed(submissions)])
+        self.assertEqual(page.total, 3)
+        self.assertFalse(page.has_more)
+        self.assertEqual(page.headers(), {'X-Total-Count': '3', 'X-Has-More': 'false'})
+
+    def test_ties_across_page_boundary(self):
+        """Test that submissions with the same sort value are each listed once, ordered by ID, across pages."""
+        submissions = [self._submission(self.start) for _ in range(5)]
+
+        ids = self._list_all(2)
+
+        self.assertEqual(ids, sorted((sub.id for sub in submissions), reverse=True))
+
+    def test_ascending_and_descending(self):
+        """Test that both orders list every submission once, the one the reverse of the other."""
+        for index, status in enumerate([SubmissionStatus.COMPLETED, SubmissionStatus.PENDING] * 3):
+            self._submission(self.start + timedelta(minutes=index), status=status)
+
+        for sort_by in SubmissionSortField:
+            descending = self._list_all(2, sort_by=sort_by, order=SortOrder.DESC)
+            ascending = self._list_all(2, sort_by=sort_by, order=SortOrder.ASC)
+
+            self.assertEqual(len(set(descending)), 6)
+            self.assertEqual(ascending, list(reversed(descending)))
+
+    def test_inserts_between_pages(self):
+        """Test that submissions inserted between two pages neither shift nor repeat the following page."""
+        submissions = [self._submission(self.start + timedelta(minutes=index)) for index in range(4)]
+        first = self.service.list_submissions(limit=2)
+
+        self._submission(self.start + timedelta(hours=1))
+        self._submission(self.start + timedelta(minutes=3))
+        second = self.service.list_submissions(limit=2, cursor=first.next_cursor)
+
+        self.assertEqual([item.id for item in first.items], [submissions[3].id, submissions[2].id])
+        self.assertEqual([item.id for item in second.items], [submissions[1].id, submissions[0].id])
+        self.assertFalse(second.has_more)
+
+    def test_cursor_of_another_sort(self):
+        """Test that a cursor is refused with another sort field or order."""
+        for index in range(3):
+            self._submission(self.start + timedelta(minutes=index))
+        cursor = self.service.list_submissions(limit=1).next_cursor
+
+        with self.assertRaises(BadRequestException):
+            self.service.list_submissions(limit=1, cursor=cursor, order=SortOrder.ASC)
+        with self.assertRaises(BadRequestException):
+            self.service.list_submissions(limit=1, cursor=cursor, sort_by=SubmissionSortField.STATUS)
+
+    def test_malformed_cursor(self):
+        """Test that a cursor that is not base64 JSON, or whose fields were tampered with, is a bad request."""
+        valid = {'sort': 'created_at', 'order': 'desc', 'value': self.start.isoformat(), 'id': str(uuid4())}
+        cursors = [
+            'not a cursor!',
+            base64.urlsafe_b64encode(b'\xff\xfe').decode('ascii'),
+            self._cursor(['created_at', 'desc']),
+            self._cursor({'sort': 'created_at', 'order': 'desc'}),
+            self._cursor({**valid, 'value': 12}),
+            self._cursor({**valid, 'value': 'yesterday'}),
+            self._cursor({**valid, 'id': 'not-a-uuid'}),
+        ]
+
+        self.assertEqual(
+            SubmissionService._decode_cursor(self._cursor(valid), SubmissionSortField.CREATED_AT, SortOrder.DESC)[0],
+            self.start,
+        )
+        for cursor in cursors:
+            with self.subTest(cursor=cursor), self.assertRaises(BadRequestException):
+                self.service.list_submissions(cursor=cursor)
+        unknown_status = self._cursor({**valid, 'sort': 'status', 'value': 'unknown'})
+        with self.assertRaises(BadRequestException):
+            SubmissionService._decode_cursor(unknown_status, SubmissionSortField.STATUS, SortOrder.DESC)
+
+    def test_cursor_round_trip(self):
+        """Test that the cursor of a submission decodes to its sort value and ID, for each sort field."""
+        submission = self._submission(self.start, status=SubmissionStatus.COMPLETED, language='go')
+        expected = {
+            SubmissionSortField.CREATED_AT: self.start,
+            SubmissionSortField.GROUP: submission.group_uuid,
+            SubmissionSortField.STATUS: SubmissionStatus.COMPLETED,
+            SubmissionSortField.LANGUAGE: 'go',
+        }
+
+        for sort_by, value in expected.items():
+            cursor = SubmissionService._encode_cursor(submission, sort_by, SortOrder.ASC)
+            self.assertEqual(SubmissionService._decode_cursor(cursor, sort_by, SortOrder.ASC), (value, submission.id))
+
+    def test_creation_dates(self):
+        """Test that the creation dates filter the submissions, inverted or mixed timezones being a bad request."""
+        submissions = [self._submission(self.start + timedelta(days=index)) for index in range(3)]
+
+        page = self.service.list_submissions(
+            filters={'created_after': self.start + timedelta(days=1), 'created_before': self.start + timedelta(days=2)}
+        )
+
+        self.assertEqual([item.id for item in page.items], [submissions[1].id])
+        for created_after, created_before in [
+            (self.start, self.start),
+            (self.start + timedelta(days=1), self.start),
+            (self.start.replace(tzinfo=timezone.utc), self.start + timedelta(days=1)),
+        ]:
+            filters = {'created_after': created_after, 'created_before': created_before}
+            with self.subTest(filters=filters), self.assertRaises(BadRequestException):
+                self.service.list_submissions(filters=filters)
+
+
+if __name__ == '__main__':
+    unittest.main()
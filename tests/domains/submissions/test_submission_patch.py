@@ -0,0 +1,173 @@
+"""
+Tests for the partial update of the metadata of a submission
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from fastapi.encoders import jsonable_encoder
+from sqlmodel import Session, SQLModel, create_engine, select
+from sqlmodel.pool import StaticPool
+
+from app.domains.submissions.dto.patch_submission_dto import PatchSubmissionDto
+from app.domains.submissions.submissions_models import Submission, SubmissionAuditEntry
+from app.domains.submissions.submissions_repository import SubmissionRepository
+from app.domains.submissions.submissions_service import SubmissionService
+from app.shared.exceptions import BadRequestException, DatabaseException
+
+
+class TestSubmissionPatch(unittest.TestCase):
+    """Unit tests for the validation of a patch, its version recompute, its invalidations and its audit entry."""
+
+    def setUp(self):
+        engine = create_engine('sqlite://', connect_args={'check_same_thread': False}, poolclass=StaticPool)
+        SQLModel.metadata.create_all(engine, tables=[Submission.__table__, SubmissionAuditEntry.__table__])
+        self.session = Session(engine)
+        self.addCleanup(self.session.close)
+        self.invalidations = []
+        self.service = SubmissionService.__new__(SubmissionService)
+        self.service.repository = SubmissionRepository(self.session)
+        self.service.access = SimpleNamespace(
+            check_submission=lambda submission, action, manage=False: None,
+            check_step=lambda project_step_uuid, action: None,
+        )
+        self.service.detection_service = SimpleNamespace(
+            build_submission_audit_entry=self._audit_entry,
+            invalidate_detection_results=lambda *args: self.invalidations.append(args),
+        )
+        self.project_uuid = uuid4()
+        self.project_step_uuid = uuid4()
+        self.submission = self._submission(group_uuid=uuid4(), display_name='Team Rocket')
+
+    def _submission(self, **fields):
+        submission = Submission(
+            link='https://github.com/student/project',
+            project_uuid=self.project_uuid,
+            project_step_uuid=fields.pop('project_step_uuid', self.project_step_uuid),
+            **fields,
+        )
+        self.session.add(submission)
+        self.session.commit()
+        self.session.refresh(submission)
+        return submission
+
+    @staticmethod
+    def _audit_entry(submission_id, changes, actor, ip_address):
+        return SubmissionAuditEntry(
+            submission_id=submission_id, changes=jsonable_encoder(changes), actor=actor, ip_address=ip_address
+        )
+
+    def _audit_trail(self):
+        statement = select(SubmissionAuditEntry).where(SubmissionAuditEntry.submission_id == self.submission.id)
+        return list(self.session.exec(statement).all())
+
+    def test_extra_fields_kept(self):
+        """Test that the fields a patch does not declare are kept aside, only the given ones being set."""
+        patch = PatchSubmissionDto(display_name='  Final  ', files=[], colour='red')
+
+        self.assertEqual(patch.model_fields_set, {'display_name', 'files', 'colour'})
+        self.assertEqual(patch.model_extra, {'files': [], 'colour': 'red'})
+        self.assertEqual(patch.display_name, 'Final')
+
+    def test_immutable_and_unknown_fields(self):
+        """Test that immutable and unknown fields are a bad request listing them, nothing being changed."""
+        cases = [
+            ({'files': [], 'content_hash': 'abc'}, ['content_hash', 'files'], []),
+            ({'colour': 'red'}, [], ['colour']),
+            ({'upload_date_time': None, 'colour': 'red', 'display_name': 'x'}, ['upload_date_time'], ['colour']),
+        ]
+
+        for fields, immutable_fields, unknown_fields in cases:
+            with self.subTest(fields=fields):
+                with self.assertRaises(BadRequestException) as context:
+                    self.service.patch_submission(self.submission.id, PatchSubmissionDto(**fields))
+
+                self.assertEqual(context.exception.status_code, 400)
+                self.assertEqual(context.exception.detail['immutable_fields'], immutable_fields)
+                self.assertEqual(context.exception.detail['unknown_fields'], unknown_fields)
+        self.assertEqual(self.service.repository.get_by_id(self.submission.id).display_name, 'Team Rocket')
+        self.assertEqual(self._audit_trail(), [])
+
+    def test_required_field_removed(self):
+        """Test that setting a required field to None is a bad request."""
+        for field in ('project_uuid', 'group_uuid', 'project_step_uuid'):
+            with self.subTest(field=field), self.assertRaises(BadRequestException) as context:
+                self.service.patch_submission(self.submission.id, PatchSubmissionDto(**{field: None}))
+
+            self.assertEqual(context.exception.status_code, 400)
+            self.assertIn(field, context.exception.detail)
+
+    def test_audit_entry(self):
+        """Test that the old and new values of the changed fields are recorded, the unchanged ones left out."""
+        result = self.service.patch_submission(
+            self.submission.id,
+            PatchSubmissionDto(display_name='Team Rocket - final', tags=['late'], description=None),
+            actor='grader@school.fr',
+            ip_address='10.0.0.1',
+        )
+
+        self.assertEqual(result.data.display_name, 'Team Rocket - final')
+        [entry] = self._audit_trail()
+        self.assertEqual(
+            entry.changes,
+            {
+                'display_name': {'old': 'Team Rocket', 'new': 'Team Rocket - final'},
+                'tags': {'old': None, 'new': ['late']},
+            },
+        )
+        self.assertEqual((entry.actor, entry.ip_address), ('grader@school.fr', '10.0.0.1'))
+        self.assertEqual(self.invalidations, [])
+
+    def test_unchanged(self):
+        """Test that a patch setting the current values changes nothing and records nothing."""
+        result = self.service.patch_submission(self.submission.id, PatchSubmissionDto(display_name='Team Rocket'))
+
+        self.assertEqual(result.message, 'Submission unchanged')
+        self.assertEqual(self._audit_trail(), [])
+
+    def test_group_reassigned(self):
+        """Test that a submission moved to another group becomes its latest version there, its results dropped."""
+        group_uuid = uuid4()
+        for version in (1, 2):
+            self._submission(group_uuid=group_uuid, version=version)
+
+        result = self.service.patch_submission(self.submission.id, PatchSubmissionDto(group_uuid=group_uuid))
+
+        self.assertEqual(result.data.version, 3)
+        [entry] = self._audit_trail()
+        self.assertEqual(entry.changes['version'], {'old': 1, 'new': 3})
+        self.assertEqual(entry.changes['group_uuid']['new'], str(group_uuid))
+        [(submission, project_uuid, project_step_uuid)] = self.invalidations
+        self.assertEqual(submission.id, self.submission.id)
+        self.assertEqual((project_uuid, project_step_uuid), (self.project_uuid, self.project_step_uuid))
+
+    def test_step_reassigned(self):
+        """Test that a submission moved to another step keeps its version if first there, its results dropped."""
+        project_step_uuid = uuid4()
+
+        result = self.service.patch_submission(
+            self.submission.id, PatchSubmissionDto(project_step_uuid=project_step_uuid)
+        )
+
+        self.assertEqual(result.data.version, 1)
+        self.assertNotIn('version', self._audit_trail()[0].changes)
+        [(submission, project_uuid, old_project_step_uuid)] = self.invalidations
+        self.assertEqual(submission.project_step_uuid, project_step_uuid)
+        self.assertEqual(old_project_step_uuid, self.project_step_uuid)
+
+    def test_one_transaction(self):
+        """Test that a patch whose audit entry cannot be saved is not saved either."""
+        self.service.detection_service.build_submission_audit_entry = lambda *args: SubmissionAuditEntry(
+            submission_id=None, changes={}
+        )
+
+        with self.assertRaises(DatabaseException):
+            self.service.patch_submission(self.submission.id, PatchSubmissionDto(display_name='Team Rocket - final'))
+
+        self.assertEqual(self.service.repository.get_by_id(self.submission.id).display_name, 'Team Rocket')
+        self.assertEqual(self._audit_trail(), [])
+
+
+if __name__ == '__main__':
+    unittest.main()
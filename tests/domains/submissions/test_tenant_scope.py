@@ -0,0 +1,42 @@
+"""
+Tests for TenantScope
+"""
+
+import unittest
+from uuid import uuid4
+
+from app.domains.submissions.tenant_scope import TenantScope
+
+
+class FakeAssignmentRepository:
+    """Tenants of the project steps, a step belonging to the first tenant claiming it"""
+
+    def __init__(self):
+        self.tenants = {}
+
+    def claim(self, project_step_uuid, tenant_id):
+        return self.tenants.setdefault(project_step_uuid, tenant_id)
+
+
+class TestTenantScope(unittest.TestCase):
+    """Unit tests for the tenant of the caller and the tenants of the project steps it acts on."""
+
+    def setUp(self):
+        self.repository = FakeAssignmentRepository()
+
+    def test_caller_tenant(self):
+        """Test that the records of a caller belong to its tenant, those of the background jobs to the default one."""
+        self.assertEqual(TenantScope(self.repository, 'school-a').tenant_id, 'school-a')
+        self.assertEqual(TenantScope(self.repository).tenant_id, 'default')
+
+    def test_step_tenant(self):
+        """Test that a new step is registered to the tenant of the caller, and kept by it when another acts on it."""
+        project_step_uuid = uuid4()
+
+        self.assertEqual(TenantScope(self.repository, 'school-a').step_tenant(project_step_uuid), 'school-a')
+        self.assertEqual(TenantScope(self.repository, 'school-b').step_tenant(project_step_uuid), 'school-a')
+        self.assertEqual(TenantScope(self.repository).step_tenant(uuid4()), 'default')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,89 @@
+"""
+Tests for TokenStreamCache
+"""
+
+import unittest
+from pathlib import Path
+from types import SimpleNamespace
+
+from app.domains.submissions.token_stream_cache import TokenStreamCache
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.dto.tokenization_result_dto import TokenizationResultDto
+from app.shared.exceptions import DatabaseException
+
+
+class FakeTokenCacheRepository:
+    """Token cache repository kept in memory"""
+
+    def __init__(self):
+        self.entries = {}
+        self.failing = False
+
+    def get(self, content_hash, tokenizer_version, tokenization_key, language):
+        if self.failing:
+            raise DatabaseException('Database unavailable')
+        return self.entries.get((content_hash, tokenizer_version, tokenization_key, language))
+
+    def create(self, entry_data):
+        fields = ('content_hash', 'tokenizer_version', 'tokenization_key', 'language')
+        key = tuple(entry_data[field] for field in fields)
+        self.entries.setdefault(key, SimpleNamespace(**entry_data))
+        return self.entries[key]
+
+
+class TestTokenStreamCache(unittest.TestCase):
+    """Unit tests for the cache of the token streams of the file contents."""
+
+    def setUp(self):
+        self.repository = FakeTokenCacheRepository()
+        self.options = TokenizationOptionsDto()
+        self.path = Path('main.py')
+        self.result = TokenizationResultDto(tokens=[{'type': 'identifier', 'text': 'x', 'start': 0, 'end': 0}])
+        self.content_hash = TokenStreamCache.content_hash(b'x\n')
+
+    def test_hit_and_miss(self):
+        """Test that a cached content is a hit for the same version, options and extension only."""
+        cache = TokenStreamCache(self.repository, tokenizer_version=1)
+
+        self.assertIsNone(cache.get(self.content_hash, self.options, self.path, 'python'))
+        cache.put(self.content_hash, self.options, self.path, 'python', self.result)
+
+        self.assertEqual(cache.get(self.content_hash, self.options, self.path, 'python'), self.result)
+        # The timeout does not change the tokens, the header options and the extension do
+        timeout_options = TokenizationOptionsDto(timeout_seconds=5)
+        self.assertIsNotNone(cache.get(self.content_hash, timeout_options, self.path, 'python'))
+        header_options = TokenizationOptionsDto(header_patterns=['^# Author:'])
+        self.assertIsNone(cache.get(self.content_hash, header_options, self.path, 'python'))
+        self.assertIsNone(cache.get(self.content_hash, self.options, Path('main.pyi'), 'python'))
+        next_version = TokenStreamCache(self.repository, tokenizer_version=2)
+        self.assertIsNone(next_version.get(self.content_hash, self.options, self.path, 'python'))
+        self.assertEqual((cache.hits, cache.misses), (2, 3))
+
+    def test_not_cached(self):
+        """Test that the timed out and empty results are not cached, and that a failing lookup is a miss."""
+        cache = TokenStreamCache(self.repository)
+
+        cache.put(self.content_hash, self.options, self.path, 'python', TokenizationResultDto(timed_out=True))
+        cache.put(self.content_hash, self.options, self.path, 'python', TokenizationResultDto(language='python'))
+        self.assertEqual(self.repository.entries, {})
+
+        cache.put(self.content_hash, self.options, self.path, 'python', self.result)
+        self.repository.failing = True
+        self.assertIsNone(cache.get(self.content_hash, self.options, self.path, 'python'))
+        self.assertEqual((cache.hits, cache.misses), (0, 1))
+
+    def test_tenants_apart(self):
+        """Test that the results of a tenant are keyed under its prefix, never hits for another tenant."""
+        cache = TokenStreamCache(self.repository, tenant_id='acme')
+        cache.put(self.content_hash, self.options, self.path, 'python', self.result)
+
+        self.assertEqual(cache.get(self.content_hash, self.options, self.path, 'python'), self.result)
+        self.assertIsNone(TokenStreamCache(self.repository).get(self.content_hash, self.options, self.path, 'python'))
+        other = TokenStreamCache(self.repository, tenant_id='globex')
+        self.assertIsNone(other.get(self.content_hash, self.options, self.path, 'python'))
+        (key,) = self.repository.entries
+        self.assertTrue(key[2].startswith('tenants/acme/'))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,66 @@
+"""
+Tests for TokenStreamExporter
+"""
+
+import json
+import unittest
+
+from app.domains.submissions.token_stream_exporter import TokenStreamExporter
+
+
+class TestTokenStreamExporter(unittest.TestCase):
+    """Unit tests for the streamed JSON and newline-delimited exports of the token streams of a submission."""
+
+    def setUp(self):
+        self.header = {'submission_id': 'abc', 'tokenizer_version': 1, 'language': 'python'}
+        self.files = [
+            {
+                'file': 'main.py',
+                'language': 'python',
+                'tokens': [
+                    {'type': 'identifier', 'text': 'ID1', 'start': 0, 'end': 0, 'start_column': 0, 'end_column': 5},
+                    {'type': 'integer', 'text': 'NUM', 'start': 0, 'end': 0, 'start_column': 8, 'end_column': 9},
+                ],
+            },
+            {'file': 'empty.py', 'language': 'python', 'tokens': []},
+        ]
+        self.reads = 0
+
+    def _files(self):
+        for file in self.files:
+            self.reads += 1
+            yield file
+
+    def test_json(self):
+        """Test that the document holds the header, then each file with its exported tokens."""
+        document = json.loads(''.join(TokenStreamExporter(self.header, self.files).json()))
+
+        self.assertEqual(document['submission'], self.header)
+        self.assertEqual([file['file'] for file in document['files']], ['main.py', 'empty.py'])
+        self.assertEqual(
+            document['files'][0]['tokens'][1],
+            {'kind': 'integer', 'value': 'NUM', 'line': 0, 'column': 8, 'end_line': 0, 'end_column': 9},
+        )
+
+    def test_ndjson(self):
+        """Test that the header comes first, then one compact line per token with its file."""
+        lines = list(TokenStreamExporter(self.header, self.files).ndjson())
+
+        self.assertEqual(len(lines), 3)
+        self.assertTrue(all(line.endswith('\n') and ', ' not in line for line in lines))
+        self.assertEqual(json.loads(lines[0])['tokenizer_version'], 1)
+        token = json.loads(lines[1])
+        self.assertEqual((token['file'], token['kind'], token['value']), ('main.py', 'identifier', 'ID1'))
+        self.assertEqual((token['line'], token['column'], token['end_line'], token['end_column']), (0, 0, 0, 5))
+
+    def test_streamed(self):
+        """Test that the files are read as they are exported, not beforehand."""
+        stream = TokenStreamExporter(self.header, self._files()).json()
+        next(stream)
+        self.assertEqual(self.reads, 0)
+        next(stream)
+        self.assertEqual(self.reads, 1)
+
+
+if __name__ == '__main__':
+    unittest.main()
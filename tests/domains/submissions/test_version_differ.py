@@ -0,0 +1,72 @@
+"""
+Tests for SubmissionVersionDiffer
+"""
+
+import tempfile
+import unittest
+from pathlib import Path
+
+from app.domains.submissions.version_differ import SubmissionVersionDiffer
+
+
+class TestSubmissionVersionDiffer(unittest.TestCase):
+    """Unit tests for the per-file changes between two versions of a submission."""
+
+    def setUp(self):
+        self.differ = SubmissionVersionDiffer()
+
+    def test_added_removed_modified(self):
+        """Test that the files are told apart as added, removed, modified or unchanged."""
+        old = {'main.py': b'print("v1")\nprint("end")\n', 'README.md': b'# Project\n', 'old.py': b'x = 1\n'}
+        new = {'main.py': b'print("v2")\nprint("end")\n', 'README.md': b'# Project\n', 'new.py': b'y = 2\nz = 3\n'}
+
+        diff = self.differ.diff(old, new)
+
+        self.assertEqual(
+            {file['path']: file['status'] for file in diff['files']},
+            {'main.py': 'modified', 'new.py': 'added', 'old.py': 'removed'},
+        )
+        self.assertEqual((diff['files_added'], diff['files_removed'], diff['files_modified']), (1, 1, 1))
+        self.assertEqual(diff['files_unchanged'], 1)
+        self.assertEqual((diff['lines_added'], diff['lines_removed']), (3, 2))
+        main = next(file for file in diff['files'] if file['path'] == 'main.py')
+        self.assertEqual(main['diff'][:2], ['--- a/main.py', '+++ b/main.py'])
+        self.assertIn('-print("v1")', main['diff'])
+        self.assertIn('+print("v2")', main['diff'])
+        added = next(file for file in diff['files'] if file['path'] == 'new.py')
+        self.assertEqual(added['diff'][0], '--- /dev/null')
+
+    def test_binary_files(self):
+        """Test that binary files are reported as changed without a line diff."""
+        diff = self.differ.diff({'logo.png': b'\x89PNG\x00\x01'}, {'logo.png': b'\x89PNG\x00\x02', 'data.bin': b'\xff'})
+
+        for file in diff['files']:
+            self.assertTrue(file['binary'])
+            self.assertIsNone(file['diff'])
+        self.assertEqual((diff['lines_added'], diff['lines_removed']), (0, 0))
+
+    def test_truncated_diff(self):
+        """Test that long diffs are cut, their changed lines being counted anyway."""
+        old = {'data.txt': ''.join(f'{i}\n' for i in range(100)).encode()}
+        new = {'data.txt': ''.join(f'{i * 2}\n' for i in range(100)).encode()}
+
+        file = SubmissionVersionDiffer(max_diff_lines=10).diff(old, new)['files'][0]
+
+        self.assertTrue(file['truncated'])
+        self.assertEqual(len(file['diff']), 10)
+        self.assertEqual(file['lines_added'], 50)
+
+    def test_read_files(self):
+        """Test that the files of a fetched version are read by relative path, without the git internals."""
+        with tempfile.TemporaryDirectory() as temp_dir:
+            root = Path(temp_dir)
+            (root / '.git').mkdir()
+            (root / '.git' / 'HEAD').write_text('ref: refs/heads/main\n')
+            (root / 'src').mkdir()
+            (root / 'src' / 'main.go').write_text('package main\n')
+
+            self.assertEqual(SubmissionVersionDiffer.read_files(root), {'src/main.go': b'package main\n'})
+
+
+if __name__ == '__main__':
+    unittest.main()
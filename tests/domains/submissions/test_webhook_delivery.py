@@ -0,0 +1,134 @@
+"""
+Tests for WebhookSender
+"""
+
+import hashlib
+import hmac
+import json
+import threading
+import time
+import unittest
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+from app.domains.submissions.webhook_delivery import (
+    SIGNATURE_HEADER,
+    TIMESTAMP_HEADER,
+    WebhookDispatcher,
+    WebhookRetryPolicy,
+    WebhookSender,
+    encode_payload,
+    sign,
+)
+
+
+class ReceiverHandler(BaseHTTPRequestHandler):
+    """Receiver answering with the next status of the server, recording the requests."""
+
+    def do_POST(self):
+        body = self.rfile.read(int(self.headers['Content-Length']))
+        self.server.requests.append((dict(self.headers), body))
+        status = self.server.statuses.pop(0)
+        self.send_response(status)
+        if status == 302:
+            self.send_header('Location', '/elsewhere')
+        self.end_headers()
+        self.wfile.write(b'{"received": true}')
+
+    def log_message(self, *args):
+        pass
+
+
+class TestWebhookSender(unittest.TestCase):
+    """Unit tests for the signed deliveries of the webhooks."""
+
+    def setUp(self):
+        self.server = HTTPServer(('127.0.0.1', 0), ReceiverHandler)
+        self.server.requests = []
+        self.server.statuses = []
+        threading.Thread(target=self.server.serve_forever, daemon=True).start()
+        self.url = f'http://127.0.0.1:{self.server.server_port}/hook'
+        self.sender = WebhookSender(timeout_seconds=5)
+
+    def tearDown(self):
+        self.server.shutdown()
+        self.server.server_close()
+
+    def test_signed_delivery(self):
+        """Test that a delivery is signed over its timestamp and body, a 2xx being a success."""
+        self.server.statuses = [204]
+        body = encode_payload({'event': 'submission.analyzed', 'data': {'submission_id': 'abc'}})
+
+        attempt = self.sender.send(self.url, 'a-secret-of-16-characters', 'submission.analyzed', 'delivery-1', body)
+
+        self.assertTrue(attempt.succeeded)
+        headers, received = self.server.requests[0]
+        self.assertEqual(received, body)
+        self.assertEqual(json.loads(received)['data'], {'submission_id': 'abc'})
+        expected = hmac.new(
+            b'a-secret-of-16-characters', f'{headers[TIMESTAMP_HEADER]}.'.encode() + body, hashlib.sha256
+        ).hexdigest()
+        self.assertEqual(headers[SIGNATURE_HEADER], f'sha256={expected}')
+        self.assertEqual(headers['X-Webhook-Delivery'], 'delivery-1')
+        self.assertNotEqual(sign('another-secret', int(headers[TIMESTAMP_HEADER]), body), headers[SIGNATURE_HEADER])
+
+    def test_failed_attempts(self):
+        """Test that an error status, a redirection and an unreachable receiver are failed attempts."""
+        self.server.statuses = [503, 302]
+
+        unavailable = self.sender.send(self.url, 'secret', 'submission.failed', 'delivery-2', b'{}')
+        redirected = self.sender.send(self.url, 'secret', 'submission.failed', 'delivery-2', b'{}')
+        unreachable = self.sender.send('http://127.0.0.1:1/hook', 'secret', 'submission.failed', 'delivery-2', b'{}')
+
+        self.assertEqual((unavailable.succeeded, unavailable.status_code), (False, 503))
+        self.assertEqual(unavailable.response_excerpt, '{"received": true}')
+        self.assertEqual((redirected.succeeded, redirected.status_code), (False, 302))
+        self.assertEqual(len(self.server.requests), 2)
+        self.assertIsNone(unreachable.status_code)
+        self.assertTrue(unreachable.error)
+
+    def test_denied_hosts(self):
+        """Test that a host the policy does not allow, or a URL other than http or https, is not posted to."""
+        sender = WebhookSender(timeout_seconds=5, host_policy=lambda host: host != '127.0.0.1')
+
+        denied = sender.send(self.url, 'secret', 'submission.failed', 'delivery-3', b'{}')
+
+        self.assertEqual((denied.succeeded, denied.status_code), (False, None))
+        self.assertEqual(denied.error, 'host 127.0.0.1 is not allowed')
+        self.assertEqual(self.server.requests, [])
+        self.assertEqual(sender.denied_reason('ftp://example.com/hook'), 'only http and https URLs are posted to')
+        self.assertIsNone(sender.denied_reason('https://example.com/hook'))
+        self.assertIsNone(self.sender.denied_reason(self.url))
+
+
+class TestWebhookRetryPolicy(unittest.TestCase):
+    """Unit tests for the retries of the failed deliveries."""
+
+    def test_backoff_up_to_cap(self):
+        """Test that the delay doubles up to its maximum, no retry being made past the last attempt."""
+        policy = WebhookRetryPolicy(max_attempts=5, base_delay_seconds=10, max_delay_seconds=50)
+
+        self.assertEqual([policy.delay(attempt) for attempt in range(1, 6)], [10, 20, 40, 50, None])
+
+
+class TestWebhookDispatcher(unittest.TestCase):
+    """Unit tests for the background threads the webhooks are delivered from."""
+
+    def test_does_not_block(self):
+        """Test that a slow delivery does not hold the notifying thread, and a shutdown cancels the retries."""
+        dispatcher = WebhookDispatcher(worker_count=1)
+        delivered = threading.Event()
+        retried = []
+
+        started = time.monotonic()
+        dispatcher.submit(lambda: (time.sleep(0.2), delivered.set()))
+        self.assertLess(time.monotonic() - started, 0.1)
+        self.assertTrue(delivered.wait(2))
+
+        dispatcher.schedule(0.2, retried.append, 'retry')
+        dispatcher.shutdown()
+        time.sleep(0.3)
+        self.assertEqual(retried, [])
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,93 @@
+"""
+Tests for WebhookRegistry
+"""
+
+import unittest
+from types import SimpleNamespace
+from uuid import uuid4
+
+from app.domains.submissions.webhook_delivery import WebhookSender
+from app.domains.submissions.webhook_registry import WebhookRegistry
+from app.shared.exceptions import NotFoundException, ValidationException
+
+
+class FakeWebhookRepository:
+    """Webhooks of all the tenants kept in memory, with the log of their deliveries"""
+
+    def __init__(self):
+        self.webhooks = {}
+        self.deliveries = []
+
+    def create(self, webhook_data):
+        webhook = SimpleNamespace(id=uuid4(), project_uuid=None, **webhook_data)
+        self.webhooks[webhook.id] = webhook
+        return webhook
+
+    def get_all(self, project_uuid, tenant_id):
+        return [
+            webhook
+            for webhook in self.webhooks.values()
+            if webhook.tenant_id == tenant_id and project_uuid in (None, webhook.project_uuid)
+        ]
+
+    def get_by_id(self, webhook_id, tenant_id):
+        webhook = self.webhooks.get(webhook_id)
+        return webhook if webhook and webhook.tenant_id == tenant_id else None
+
+    def delete(self, webhook_id):
+        return self.webhooks.pop(webhook_id, None) is not None
+
+    def get_deliveries(self, webhook_id, status, limit):
+        return [delivery for delivery in self.deliveries if delivery.webhook_id == webhook_id][:limit]
+
+
+class TestWebhookRegistry(unittest.TestCase):
+    """Unit tests for the webhooks registered by a tenant, to the allowed hosts only."""
+
+    def setUp(self):
+        self.repository = FakeWebhookRepository()
+        self.sender = WebhookSender(host_policy=lambda host: host != '169.254.169.254')
+        self.registry = WebhookRegistry(self.repository, self.sender, 'tenant-a')
+        self.other_tenant = WebhookRegistry(self.repository, self.sender, 'tenant-b')
+
+    def _create(self, registry=None, **webhook_data):
+        webhook_data = {'url': 'https://lms.example.com/hooks', 'events': ['submission.analyzed'], **webhook_data}
+        return (registry or self.registry).create(webhook_data)
+
+    def test_create(self):
+        """Test that a webhook belongs to the tenant, its secret being generated unless given."""
+        generated = self._create()
+        given = self._create(secret='a-secret-of-16-characters')
+
+        self.assertEqual(generated.tenant_id, 'tenant-a')
+        self.assertGreaterEqual(len(generated.secret), 32)
+        self.assertEqual(given.secret, 'a-secret-of-16-characters')
+
+    def test_denied_host(self):
+        """Test that a webhook whose host is not allowed, or which is not posted over http, is not registered."""
+        for url in ('http://169.254.169.254/latest/meta-data', 'file:///etc/passwd'):
+            with self.subTest(url=url):
+                with self.assertRaises(ValidationException):
+                    self._create(url=url)
+
+        self.assertEqual(self.repository.webhooks, {})
+
+    def test_tenant_isolation(self):
+        """Test that the webhooks of another tenant are neither listed, read, deleted nor their deliveries read."""
+        webhook = self._create()
+        self.repository.deliveries.append(SimpleNamespace(webhook_id=webhook.id))
+
+        self.assertEqual(self.other_tenant.get_all(), [])
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.get(webhook.id)
+        with self.assertRaises(NotFoundException):
+            self.other_tenant.get_deliveries(webhook.id)
+        self.assertFalse(self.other_tenant.delete(webhook.id))
+
+        self.assertEqual(len(self.registry.get_deliveries(webhook.id)), 1)
+        self.assertTrue(self.registry.delete(webhook.id))
+        self.assertEqual(self.registry.get_all(), [])
+
+
+if __name__ == '__main__':
+    unittest.main()
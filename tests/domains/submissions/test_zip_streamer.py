@@ -0,0 +1,59 @@
+"""
+Tests for ZipStreamer
+"""
+
+import io
+import os
+import tempfile
+import unittest
+import zipfile
+from datetime import datetime
+from pathlib import Path
+
+from app.domains.submissions.zip_streamer import ZipStreamer
+
+
+class TestZipStreamer(unittest.TestCase):
+    """Unit tests for the ZIP archives streamed from files on disk."""
+
+    def setUp(self):
+        self.directory = tempfile.TemporaryDirectory()
+        self.root = Path(self.directory.name)
+
+    def tearDown(self):
+        self.directory.cleanup()
+
+    def _write(self, name, content, mode=0o644):
+        path = self.root / name
+        path.parent.mkdir(parents=True, exist_ok=True)
+        path.write_bytes(content)
+        os.chmod(path, mode)
+        return name, path
+
+    def test_stream(self):
+        """Test that the archive is yielded in pieces, keeping the relative paths, contents and permissions."""
+        files = [
+            self._write('src/main.py', os.urandom(5000)),
+            self._write('run.sh', b'#!/bin/sh\necho hello\n', 0o755),
+        ]
+
+        chunks = list(ZipStreamer(chunk_bytes=1024).stream(files, datetime(2024, 1, 16, 9, 30, 12)))
+
+        self.assertGreater(len(chunks), 2)
+        with zipfile.ZipFile(io.BytesIO(b''.join(chunks))) as archive:
+            self.assertEqual(archive.namelist(), ['src/main.py', 'run.sh'])
+            self.assertEqual(archive.read('src/main.py'), files[0][1].read_bytes())
+            info = archive.getinfo('run.sh')
+            self.assertEqual(info.date_time, (2024, 1, 16, 9, 30, 12))
+            self.assertEqual((info.external_attr >> 16) & 0o777, 0o755)
+            self.assertIsNone(archive.testzip())
+
+    def test_empty_and_old_dates(self):
+        """Test that an archive without files is still valid, and that a date before 1980 is moved to 1980."""
+        with zipfile.ZipFile(io.BytesIO(b''.join(ZipStreamer().stream([], datetime.now())))) as archive:
+            self.assertEqual(archive.namelist(), [])
+        self.assertEqual(ZipStreamer.zip_date_time(datetime(1970, 1, 1)), (1980, 1, 1, 0, 0, 0))
+
+
+if __name__ == '__main__':
+    unittest.main()
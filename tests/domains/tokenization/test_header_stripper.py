@@ -0,0 +1,79 @@
+"""
+Tests for HeaderStripper
+"""
+
+import unittest
+
+from app.domains.tokenization.header_stripper import HeaderStripper
+
+MIT_HEADER = (
+    "/*\n"
+    " * Copyright (c) 2024 Jane Doe\n"
+    " *\n"
+    " * Permission is hereby granted, free of charge, to any person obtaining a copy\n"
+    " */\n"
+)
+
+
+class TestHeaderStripper(unittest.TestCase):
+    """Unit tests for the removal of license and file headers."""
+
+    def setUp(self):
+        self.stripper = HeaderStripper()
+
+    def test_license_header_stripped(self):
+        """Test that a known license header is replaced with its line breaks, keeping the lines of the code."""
+        text = MIT_HEADER + "\nint main(void) {\n    return 0;\n}\n"
+
+        stripped, header = self.stripper.strip(text, "c")
+
+        self.assertEqual(stripped, "\n" * 5 + "\nint main(void) {\n    return 0;\n}\n")
+        self.assertEqual((header.start_line, header.end_line), (0, 4))
+        self.assertEqual((header.start_byte, header.end_byte), (0, len(MIT_HEADER) - 1))
+        self.assertEqual(header.matched, "Permission is hereby granted, free of charge")
+
+    def test_unmatched_comment_kept(self):
+        """Test that an initial comment matching no pattern is kept."""
+        text = "// Computes the totals of the orders\nfunc total() int { return 0 }\n"
+
+        self.assertEqual(self.stripper.strip(text, "go"), (text, None))
+        self.assertEqual(self.stripper.strip("package main\n", "go"), ("package main\n", None))
+
+    def test_school_template_and_shebang(self):
+        """Test that a header template of the assignment matches, the shebang being kept."""
+        text = (
+            "#!/usr/bin/env python3\n"
+            "# Author: Jane Doe\n"
+            "# Date:   2024-10-01\n"
+            "#\n"
+            "# Course: Algorithms 101\n"
+            "import sys\n"
+        )
+        template = "Author: {name}\nDate: {date}\nCourse: Algorithms 101"
+
+        stripped, header = self.stripper.strip(text, "python", patterns=[], templates=[template])
+
+        self.assertEqual(stripped, "#!/usr/bin/env python3\n\n\n\n\nimport sys\n")
+        self.assertEqual((header.start_line, header.end_line), (1, 4))
+        self.assertEqual(header.matched, template)
+        # Other courses do not match the template
+        self.assertIsNone(self.stripper.strip(text.replace("101", "202"), "python", [], [template])[1])
+
+    def test_custom_patterns_and_docstring(self):
+        """Test that configured patterns replace the default ones and that Python docstring headers are found."""
+        text = '"""\nProject: Library management\nSPDX-License-Identifier: MIT\n"""\nprint("hi")\n'
+
+        self.assertIsNotNone(self.stripper.strip(text, "python")[1])
+        self.assertIsNone(self.stripper.strip(text, "python", patterns=[r"^Group \d+"])[1])
+        self.assertEqual(self.stripper.strip(text, "python", patterns=[r"^Project:"])[1].matched, "^Project:")
+
+    def test_header_only_file(self):
+        """Test that a file holding only a header is left with line breaks only."""
+        stripped, header = self.stripper.strip("# SPDX-License-Identifier: GPL-3.0\n# Author: me\n", "bash")
+
+        self.assertEqual(stripped.strip(), "")
+        self.assertEqual((header.start_line, header.end_line), (0, 1))
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -2,6 +2,8 @@
 Tests for TokenizationService
 """
 
+import io
+import json
 import subprocess
 import tempfile
 import unittest
@@ -10,6 +12,13 @@ from unittest.mock import patch, MagicMock
 
 from app.domains.repositories.exceptions import UnsupportedRepositoryException
 from app.domains.repositories.fetchers.github_fetcher import _extract_repo_name, _normalize_github_url
+from app.domains.tokenization.dto.custom_language_definition_dto import CustomLanguageDefinitionDto
+from app.domains.tokenization.dto.tokenization_options_dto import TokenizationOptionsDto
+from app.domains.tokenization.exceptions import (
+    InvalidNotebookException,
+    LanguageRegistrationException,
+    UnsupportedNotebookLanguageException,
+)
 from app.domains.tokenization.tokenization_service import TokenizationService
 from app.shared.exceptions import ValidationException
 
@@ -647,6 +656,52 @@ func main() {
         self.assertIsInstance(tokens, list)
         self.assertGreater(len(tokens), 0)
 
+    def test_tokenize_go_generics(self):
+        """Test that Go type parameters and instantiations produce stable token kinds."""
+        code = '''
+package main
+
+type Stack[T any] struct {
+    items []T
+}
+
+func (s *Stack[T]) Push(v T) {
+    s.items = append(s.items, v)
+}
+
+func Sum[K comparable, V int | float64](m map[K][]V) V {
+    var total V
+    for _, values := range m {
+        for _, v := range values {
+            total += v
+        }
+    }
+    return total
+}
+
+func main() {
+    labels := Map[int, string]([]int{1, 2}, func(i int) string { return "x" })
+    _ = labels
+}
+'''
+        tokens = self.service.tokenize(code, Path("main.go"))
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertEqual(texts('type_parameter'), ['T', 'T', 'K', 'V'])
+        self.assertEqual(texts('type_constraint'), ['any', 'comparable', 'int | float64'])
+        self.assertEqual(texts('generic_receiver_type'), ['Stack[T]'])
+        self.assertEqual(len(texts('generic_instantiation')), 1)
+        self.assertTrue(texts('generic_instantiation')[0].startswith('Map[int, string]('))
+        self.assertEqual(texts('type_arguments'), ['[T]', '[int, string]'])
+
+        # Nested brackets in composite types are not type parameters or arguments
+        self.assertIn('map[K][]V', texts('map_type'))
+        self.assertIn('[]V', texts('slice_type'))
+        self.assertNotIn('[]V', texts('type_argument'))
+        self.assertNotIn('K', texts('type_argument'))
+
     def test_tokenize_typescript(self):
         """Test tokenization of TypeScript code."""
         code = '''
@@ -1642,6 +1697,31 @@ if __name__ == "__main__":
         self.assertIn("Hello, World!", reconstructed)
         self.assertIn("print", reconstructed)
 
+    def test_tokenize_stream(self):
+        """Test that a file tokenized as a stream of chunks gets the statements of the whole file, on its lines."""
+        function = 'def function_{}(value):\n    """Doc\n\n    """\n    return value\n\n'
+        source = ''.join(function.format(index) for index in range(20))
+        path = Path('large.py')
+
+        chunks = list(self.service.tokenize_stream(io.StringIO(source), path, buffer_size=100))
+        whole = self.service.tokenize_with_details(source, path)
+
+        self.assertGreater(len(chunks), 1)
+        self.assertEqual(''.join(chunk.text for chunk, _ in chunks), source)
+        self.assertEqual({result.language for _, result in chunks}, {'python'})
+        streamed_functions = [
+            (token['text'], token['start'] + chunk.line_offset, token['end'] + chunk.line_offset)
+            for chunk, result in chunks
+            for token in result.tokens
+            if token['type'] == 'function_definition'
+        ]
+        functions = [
+            (token['text'], token['start'], token['end'])
+            for token in whole.tokens
+            if token['type'] == 'function_definition'
+        ]
+        self.assertEqual(streamed_functions, functions)
+
 
 class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
     """Tests for TokenizationService using comprehensive sample files."""
@@ -1700,6 +1780,247 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Bash sample file."""
         self._test_sample_file("sample.sh", "bash", 40)
 
+    def test_bash_deploy_sample(self):
+        """Test expansion, quoting, pipeline and heredoc tokens of the deploy script sample."""
+        self._test_sample_file("sample_deploy.sh", "bash", 60)
+
+        file_path = self.sample_files_dir / "sample_deploy.sh"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        def at_row(kind, row):
+            return [t['text'] for t in tokens if t['type'] == kind and t['start'] == row]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(texts('command_substitution'), ['$(date +%Y%m%d%H%M%S)', '$(whoami)'])
+        self.assertEqual(types.count('pipeline'), 1)
+
+        # Single quotes are literal, double quotes keep their expansions
+        self.assertEqual(texts('single_quoted_string'), ["'Literal: $APP_NAME is not expanded'", "'^tests/'"])
+        self.assertEqual(at_row('variable_expansion', 9), [])
+        self.assertEqual(at_row('variable_expansion', 8), ['$APP_NAME', '$TARGET_DIR'])
+        self.assertEqual(at_row('parameter_expansion', 6), ['${APP_NAME}', '${RELEASE}'])
+
+        # Heredoc with an unquoted delimiter: its $ expansions are tokenized
+        self.assertEqual(types.count('expanding_heredoc_body'), 1)
+        self.assertEqual(at_row('variable_expansion', 15), ['$APP_NAME'])
+        self.assertEqual(at_row('parameter_expansion', 16), ['${RELEASE}'])
+        self.assertEqual(at_row('command_substitution', 17), ['$(whoami)'])
+
+        # Heredoc with a quoted delimiter: a single literal token
+        literal = texts('literal_heredoc_body')
+        self.assertEqual(len(literal), 1)
+        self.assertIn('$APP_NAME and $(whoami) are kept as is', literal[0])
+        self.assertEqual([t for t in tokens if t['start'] == 21 and t['type'] != 'literal_heredoc_body'], [])
+
+    def test_shebang_detection(self):
+        """Test that scripts without extension are detected and collected by their shebang."""
+        self.assertEqual(self.service.detect_file_language(Path("deploy"), "#!/bin/sh\necho hi\n"), "bash")
+        self.assertEqual(self.service.detect_file_language(Path("run"), "#!/usr/bin/env -S python3 -u\n"), "python")
+        self.assertEqual(self.service.detect_file_language(Path("serve"), "#!/usr/bin/env node\n"), "javascript")
+        self.assertEqual(self.service.detect_file_language(Path("solve"), "#!/usr/bin/python3\n"), "python")
+        self.assertEqual(self.service.detect_file_language(Path("report"), "#!/usr/bin/env ruby\n"), "ruby")
+        self.assertEqual(self.service.detect_file_language(Path("parse"), "#!/usr/bin/perl -w\n"), "perl")
+        # The extension wins over the shebang
+        self.assertEqual(self.service.detect_file_language(Path("tool.py"), "#!/bin/bash\n"), "python")
+
+        with tempfile.TemporaryDirectory() as temp_dir:
+            project = Path(temp_dir)
+            (project / "deploy").write_text("#!/usr/bin/env bash\necho deploy\n", encoding='utf-8')
+            (project / "Makefile").write_text("all:\n\techo all\n", encoding='utf-8')
+            (project / "LICENSE").write_text("MIT License\n", encoding='utf-8')
+
+            files = sorted(f.name for f in self.service.extract_supported_files_from_directory(project))
+            self.assertEqual(files, ["Makefile", "deploy"])
+
+    def test_language_detection_confidence(self):
+        """Test the confidence and alternatives returned with the detected language."""
+        detection = self.service.detect_language_with_confidence(Path("main.py"), "print('hi')\n")
+        self.assertEqual(detection.language, "python")
+        self.assertEqual(detection.method, "extension")
+        self.assertGreaterEqual(detection.confidence, 0.9)
+        self.assertEqual(detection.alternatives, [])
+        self.assertFalse(detection.low_confidence)
+
+        # Ambiguous header: the other language is an alternative with a lower score
+        detection = self.service.detect_language_with_confidence(Path("utils.h"), "int add(int a, int b);\n")
+        self.assertEqual(detection.language, "c")
+        self.assertEqual([alternative.language for alternative in detection.alternatives], ["cpp"])
+        self.assertLess(detection.alternatives[0].score, detection.confidence)
+
+        # Extension and shebang disagree: the extension wins, the shebang language is an alternative
+        detection = self.service.detect_language_with_confidence(Path("tool.py"), "#!/bin/bash\n")
+        self.assertEqual(detection.language, "python")
+        self.assertEqual(detection.alternatives[0].language, "bash")
+
+        # Nothing to rely on: the default language is flagged
+        detection = self.service.detect_language_with_confidence(Path("notes"), "Some notes\n")
+        self.assertEqual(detection.method, "default")
+        self.assertTrue(detection.low_confidence)
+        self.assertEqual(self.service.detect_file_language(Path("notes"), "Some notes\n"), detection.language)
+
+        # The threshold decides what is flagged
+        detection = self.service.detect_language_with_confidence(Path("utils.h"), "int add(int a, int b);\n", 0.75)
+        self.assertTrue(detection.low_confidence)
+
+    def _read_sample(self, filename):
+        """Read the content of a language sample."""
+        with open(self.sample_files_dir / filename, 'r', encoding='utf-8') as f:
+            return f.read()
+
+    def test_content_fallback_when_extension_lies(self):
+        """Test that mis-extensioned samples are tokenized with the language detected from their content."""
+        cases = [
+            ("sample.java", "Sample.txt", "java"),
+            ("sample.c", "sample_c.py", "c"),
+            ("sample.go", "main.js", "go"),
+        ]
+        for sample, disguised_name, language in cases:
+            with self.subTest(sample=sample, disguised_name=disguised_name):
+                content = self._read_sample(sample)
+                result = self.service.tokenize_with_details(content, Path(disguised_name))
+
+                self.assertEqual(result.language, language)
+                self.assertIsNotNone(result.language_fallback)
+                self.assertEqual(result.language_fallback.language, language)
+                self.assertNotEqual(result.language_fallback.detected_language, language)
+                self.assertLess(
+                    result.language_fallback.fallback_unknown_token_percentage,
+                    result.language_fallback.unknown_token_percentage,
+                )
+                # Same tokens as the correctly named sample
+                self.assertEqual(result.tokens, self.service.tokenize(content, self.sample_files_dir / sample))
+
+    def test_content_fallback_threshold(self):
+        """Test that the unknown tokens percentage triggering the fallback is configurable."""
+        content = self._read_sample("sample.java")
+
+        result = self.service.tokenize_with_details(
+            content, Path("Sample.txt"), TokenizationOptionsDto(max_unknown_token_percentage=100.0)
+        )
+        self.assertEqual(result.language, "python")
+        self.assertIsNone(result.language_fallback)
+        self.assertGreater(result.unknown_token_percentage, 0.0)
+
+        # Correctly named samples parse without unknown tokens and never fall back
+        result = self.service.tokenize_with_details(
+            content, self.sample_files_dir / "sample.java", TokenizationOptionsDto(max_unknown_token_percentage=0.0)
+        )
+        self.assertEqual(result.language, "java")
+        self.assertIsNone(result.language_fallback)
+
+    def test_content_language_ranking(self):
+        """Test the content heuristics on distinctive constructs."""
+        detector = self.service.content_language_detector
+        self.assertEqual(detector.rank("package main\n\nfunc main() {\n\tx := 1\n}\n")[0][0], "go")
+        self.assertEqual(detector.rank("class A:\n    def __init__(self):\n        self.x = 1\n")[0][0], "python")
+        self.assertEqual(detector.rank("#include <stdio.h>\nint main(void) { printf(\"hi\"); }\n")[0][0], "c")
+        self.assertEqual(detector.rank("Just some notes\n"), [])
+
+        scores = [score for _, score in detector.rank(self._read_sample("sample.java"))]
+        self.assertAlmostEqual(sum(scores), 1.0)
+
+    def _algo_definition(self, **overrides):
+        """Definition of the teaching language of the custom language sample."""
+        definition = {
+            "name": "algo",
+            "extensions": [".algo"],
+            "keywords": (
+                "algorithme var tableau de entier debut fin pour jusqua faire finpour si alors finsi ecrire"
+            ).split(),
+            "line_comments": ["//"],
+            "block_comments": [["/*", "*/"]],
+            "string_delimiters": ['"'],
+            "operators": ["<-", "..", ">", "<", "=", "+", "-"],
+            "case_sensitive": False,
+        }
+        definition.update(overrides)
+        return CustomLanguageDefinitionDto(**definition)
+
+    def test_custom_language_registration(self):
+        """Test that a registered custom language is detected, collected and tokenized like built-in ones."""
+        self.service.register_custom_language(self._algo_definition())
+
+        self.assertIn("algo", self.service.get_supported_languages())
+        self.assertIn(".algo", self.service.get_supported_extensions())
+        self.assertEqual([d.name for d in self.service.get_custom_languages()], ["algo"])
+
+        file_path = self.sample_files_dir / "sample_custom.algo"
+        content = self._read_sample("sample_custom.algo")
+        self.assertEqual(self.service.detect_file_language(file_path, content), "algo")
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+        self.assertEqual(tokens[0]['type'], 'comment')
+        self.assertEqual(tokens[0]['text'], "// Recherche du maximum d'un tableau")
+        # Keywords are matched case insensitively and have their own type
+        self.assertEqual(types[1:3], ['algorithme', 'identifier'])
+        self.assertEqual(types.count('pour'), 1)
+        self.assertIn({'type': 'operator', 'text': '<-', 'start': 5, 'end': 5}, tokens)
+        block_comment = next(t for t in tokens if t['text'].startswith('/*'))
+        self.assertEqual((block_comment['type'], block_comment['start'], block_comment['end']), ('comment', 11, 12))
+        self.assertIn({'type': 'string', 'text': '"Maximum : "', 'start': 13, 'end': 13}, tokens)
+
+        with tempfile.TemporaryDirectory() as temp_dir:
+            project = Path(temp_dir)
+            (project / "max.algo").write_text(content, encoding='utf-8')
+            (project / "notes.unknown").write_text("notes\n", encoding='utf-8')
+            files = [f.name for f in self.service.extract_supported_files_from_directory(project)]
+            self.assertEqual(files, ["max.algo"])
+
+    def test_custom_language_conflicts(self):
+        """Test that custom languages reusing a name or an extension are rejected with the conflicts."""
+        with self.assertRaises(LanguageRegistrationException) as context:
+            self.service.register_custom_language(self._algo_definition(extensions=[".algo", ".py"]))
+        self.assertEqual(context.exception.conflicts, {"extensions": {".py": "python"}})
+        self.assertIn(".py (python)", context.exception.message)
+        # Nothing is registered when the definition is rejected
+        self.assertNotIn(".algo", self.service.get_supported_extensions())
+
+        with self.assertRaises(LanguageRegistrationException) as context:
+            self.service.register_custom_language(self._algo_definition(name="python", extensions=[".pyx2"]))
+        self.assertEqual(context.exception.conflicts, {"name": "python"})
+
+        self.service.register_custom_language(self._algo_definition())
+        with self.assertRaises(LanguageRegistrationException):
+            self.service.register_custom_language(self._algo_definition(name="algo2"))
+
+    def test_custom_languages_file(self):
+        """Test the registration of the custom languages of a configuration file."""
+        with tempfile.TemporaryDirectory() as temp_dir:
+            definitions_path = Path(temp_dir) / "languages.json"
+            definitions_path.write_text(json.dumps([self._algo_definition().model_dump()]), encoding='utf-8')
+
+            languages = self.service.load_custom_languages(definitions_path)
+            self.assertEqual([language.name for language in languages], ["algo"])
+            self.assertEqual(self.service.detect_file_language(Path("max.algo"), "DEBUT\nFIN\n"), "algo")
+
+            definitions_path.write_text('{"name": "algo"}', encoding='utf-8')
+            with self.assertRaises(ValidationException):
+                self.service.load_custom_languages(definitions_path)
+
+    def test_shebang_with_unknown_extension(self):
+        """Test that the shebang wins over an extension unknown to the language mapping, such as .txt."""
+        self.assertEqual(self.service.detect_file_language(Path("solve.txt"), "#!/usr/bin/env python3\n"), "python")
+        self.assertEqual(self.service.detect_file_language(Path("run.out"), "#!/bin/sh\n"), "bash")
+        # Without interpreter line the content heuristics still apply
+        self.assertEqual(self.service.detect_file_language(Path("notes.txt"), "Some notes\n"), "python")
+
+        with tempfile.TemporaryDirectory() as temp_dir:
+            project = Path(temp_dir)
+            (project / "solve.txt").write_text("#!/usr/bin/env python3\nprint('solved')\n", encoding='utf-8')
+            (project / "notes.txt").write_text("Nothing to run here\n", encoding='utf-8')
+            (project / "data.bin").write_bytes(bytes(range(256)) * 64)
+
+            files = sorted(f.name for f in self.service.extract_supported_files_from_directory(project))
+            self.assertEqual(files, ["solve.txt"])
+
     def test_c_sample(self):
         """Test tokenization of C sample file."""
         self._test_sample_file("sample.c", "c", 60)
@@ -1708,10 +2029,111 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of C# sample file."""
         self._test_sample_file("sample.cs", "csharp", 80)
 
+    def test_csharp_async_worker_sample(self):
+        """Test property, LINQ, async, interpolated string, verbatim string and attribute tokens of the C# worker."""
+        self._test_sample_file("sample_worker.cs", "csharp", 100)
+
+        file_path = self.sample_files_dir / "sample_worker.cs"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+
+        # Property accessors
+        self.assertEqual(types.count('get_accessor'), 2)
+        self.assertEqual(types.count('set_accessor'), 1)
+        self.assertEqual(types.count('init_accessor'), 1)
+
+        # LINQ query syntax
+        self.assertEqual(types.count('query_expression'), 1)
+        for clause in ['from_clause', 'where_clause', 'select_clause']:
+            self.assertEqual(types.count(clause), 1, clause)
+
+        # async / await
+        async_methods = texts('async_method_declaration')
+        self.assertEqual(len(async_methods), 2)
+        self.assertIn('Worker(int id', async_methods[0])
+        self.assertEqual(types.count('await_expression'), 4)
+
+        # Strings: interpolations are tokenized, verbatim strings are a single token
+        self.assertEqual(types.count('interpolated_string'), 4)
+        self.assertGreaterEqual(types.count('interpolation'), 6)
+        self.assertEqual(texts('verbatim_string_literal'), [r'@"C:\logs\worker.log"'])
+
+        self.assertEqual(texts('attribute_name'), ['HttpGet', 'Obsolete'])
+
     def test_cpp_sample(self):
         """Test tokenization of C++ sample file."""
         self._test_sample_file("sample.cpp", "cpp", 70)
 
+    def test_cpp_templates_sample(self):
+        """Test template, namespace, preprocessor and lambda tokens of the C++ templates sample."""
+        self._test_sample_file("sample_templates.cpp", "cpp", 100)
+
+        file_path = self.sample_files_dir / "sample_templates.cpp"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(texts('type_parameter_list'), ['<typename T, typename Score>'])
+
+        # Nested template arguments closed by >> are template arguments, not shift expressions
+        type_arguments = texts('type_arguments')
+        self.assertIn('<std::string, std::vector<std::pair<int, int>>>', type_arguments)
+        self.assertIn('<std::pair<int, int>>', type_arguments)
+        self.assertIn('<int, int>', type_arguments)
+        self.assertFalse(any(text.rstrip().endswith('>>') for text in texts('binary_expression')))
+
+        self.assertEqual(types.count('namespace_definition'), 1)
+        self.assertEqual(types.count('preproc_include'), 5)
+        self.assertEqual([text.strip() for text in texts('preproc_def')], ['#define MAX_RESULTS 3'])
+        self.assertEqual(types.count('lambda_expression'), 2)
+        self.assertIn('// Highest score first', texts('comment'))
+
+        raw_strings = [t for t in tokens if t['type'] == 'raw_string_literal']
+        self.assertEqual(len(raw_strings), 1)
+        self.assertEqual(raw_strings[0]['end'] - raw_strings[0]['start'], 1)
+
+    def test_cpp_header_and_source_pair(self):
+        """Test that headers and sources of a C++ submission are all tokenized as C++."""
+        header = self.sample_files_dir / "sample_templates.hpp"
+        self._test_sample_file("sample_templates.hpp", "cpp", 30)
+
+        with tempfile.TemporaryDirectory() as temp_dir:
+            project = Path(temp_dir)
+            for name in ("sample_templates.hpp", "sample_templates.cpp"):
+                (project / name).write_text((self.sample_files_dir / name).read_text(encoding='utf-8'))
+            (project / "ranking.h").write_text(header.read_text(encoding='utf-8'))
+
+            files = sorted(f.name for f in self.service.extract_supported_files_from_directory(project))
+            self.assertEqual(files, ["ranking.h", "sample_templates.cpp", "sample_templates.hpp"])
+
+            for file_path in project.iterdir():
+                content = file_path.read_text(encoding='utf-8')
+                self.assertEqual(self.service.detect_file_language(file_path, content), "cpp")
+                self.assertGreater(len(self.service.tokenize(content, file_path)), 20, file_path.name)
+
+    def test_c_header_detection(self):
+        """Test that plain C headers keep the C language."""
+        c_header = "#ifndef UTIL_H\n#define UTIL_H\n\nint add(int a, int b);\nstruct point { int x; int y; };\n\n#endif\n"
+        cpp_header = "#pragma once\n\nclass Point {\npublic:\n    int x;\n};\n"
+
+        self.assertEqual(self.service.detect_file_language(Path("util.h"), c_header), "c")
+        self.assertEqual(self.service.detect_file_language(Path("point.h"), cpp_header), "cpp")
+        self.assertEqual(self.service._detect_language(Path("point.h")), "c")
+
     def test_cmake_sample(self):
         """Test tokenization of CMake sample file."""
         self._test_sample_file("CMakeLists.txt", "cmake", 20)
@@ -1736,6 +2158,181 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Go sample file."""
         self._test_sample_file("sample.go", "go", 50)
 
+    def test_go_sample_generic_function_tokens(self):
+        """Test the token sequence of the generic Map function in the Go sample file."""
+        file_path = self.sample_files_dir / "sample.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        map_function = next(
+            t for t in tokens if t['type'] == 'function_declaration' and t['text'].startswith('func Map[')
+        )
+
+        generic_kinds = {'type_parameter_list', 'type_parameter_declaration', 'type_parameter', 'type_constraint'}
+        generic_tokens = [
+            (t['type'], t['text'])
+            for t in tokens
+            if t['type'] in generic_kinds and map_function['start'] <= t['start'] <= map_function['end']
+        ]
+
+        self.assertEqual(generic_tokens, [
+            ('type_parameter_list', '[T, U any]'),
+            ('type_parameter_declaration', 'T, U any'),
+            ('type_parameter', 'T'),
+            ('type_parameter', 'U'),
+            ('type_constraint', 'any'),
+        ])
+
+        # Tokenizing twice must give the same kinds
+        self.assertEqual([t['type'] for t in tokens], [t['type'] for t in self.service.tokenize(content, file_path)])
+
+    def test_go_sample_struct_tags(self):
+        """Test that struct tags of the Go sample file are emitted as struct_tag tokens."""
+        file_path = self.sample_files_dir / "sample.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        tags = [t['text'] for t in tokens if t['type'] == 'struct_tag']
+        self.assertEqual(tags, ['`json:"name"`', '`json:"age"`'])
+
+        # The tag content is not tokenized further as a string literal
+        self.assertFalse(any(t['text'] == '`json:"name"`' and t['type'] != 'struct_tag' for t in tokens))
+
+        normalized = self.service.tokenize(content, file_path, options=TokenizationOptionsDto(normalize_struct_tags=True))
+        tags = [t['text'] for t in normalized if t['type'] == 'struct_tag']
+        self.assertEqual(tags, ['json:"<TAG>"', 'json:"<TAG>"'])
+
+    def test_go_multi_key_struct_tags_sample(self):
+        """Test struct tags with several keys."""
+        self._test_sample_file("sample_struct_tags.go", "go", 30)
+
+        file_path = self.sample_files_dir / "sample_struct_tags.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        tags = [t['text'] for t in tokens if t['type'] == 'struct_tag']
+        self.assertEqual(len(tags), 7)
+        self.assertIn('`json:"age" xml:"age"`', tags)
+        self.assertIn('"json:\\"course\\""', tags)
+
+        normalized = self.service.tokenize(content, file_path, options=TokenizationOptionsDto(normalize_struct_tags=True))
+        tags = [t['text'] for t in normalized if t['type'] == 'struct_tag']
+        self.assertEqual(tags, [
+            'json:"<TAG>" xml:"<TAG>" db:"<TAG>"',
+            'json:"<TAG>" xml:"<TAG>"',
+            'json:"<TAG>" xml:"<TAG>"',
+            'json:"<TAG>" xml:"<TAG>" validate:"<TAG>"',
+            'json:"<TAG>" xml:"<TAG>"',
+            'json:"<TAG>"',
+            'json:"<TAG>" xml:"<TAG>"',
+        ])
+
+    def test_go_sample_worker_concurrency_tokens(self):
+        """Test the channel and select token sequence of the worker function in the Go sample file."""
+        file_path = self.sample_files_dir / "sample.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        worker = next(t for t in tokens if t['type'] == 'function_declaration' and t['text'].startswith('func worker('))
+
+        concurrency_kinds = {
+            'channel_type', 'receive_channel_type', 'send_channel_type', 'channel_send', 'channel_receive',
+            'channel_receive_statement', 'select_statement', 'select_case', 'select_default',
+        }
+        sequence = [
+            (t['type'], t['text'].split('\n')[0])
+            for t in tokens
+            if t['type'] in concurrency_kinds and worker['start'] <= t['start'] <= worker['end']
+        ]
+
+        self.assertEqual(sequence, [
+            ('receive_channel_type', '<-chan int'),
+            ('send_channel_type', 'chan<- int'),
+            ('select_statement', 'select {'),
+            ('select_case', 'case job, ok := <-jobs:'),
+            ('channel_receive_statement', 'job, ok := <-jobs'),
+            ('channel_receive', '<-jobs'),
+            ('channel_send', 'results <- job * 2'),
+            ('select_case', 'case <-ctx.Done():'),
+            ('channel_receive_statement', '<-ctx.Done()'),
+            ('channel_receive', '<-ctx.Done()'),
+        ])
+
+        # Channels created with make() in main are bidirectional
+        self.assertIn('chan int', [t['text'] for t in tokens if t['type'] == 'channel_type'])
+
+    def test_go_raw_strings_sample(self):
+        """Test that multi-line raw strings are single tokens and do not shift the following lines."""
+        self._test_sample_file("sample_raw_strings.go", "go", 20)
+
+        file_path = self.sample_files_dir / "sample_raw_strings.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        raw_strings = [t for t in tokens if t['type'] == 'raw_string_literal']
+        self.assertEqual(len(raw_strings), 4)
+
+        usage, pattern, template, argument = raw_strings
+        self.assertEqual((usage['start'], usage['end']), (8, 13))
+        self.assertTrue(usage['text'].startswith('`Usage: grader') and usage['text'].endswith('`'))
+        self.assertEqual((pattern['start'], pattern['end']), (16, 16))
+        self.assertEqual(pattern['text'], r'`^C:\\Users\\[a-z]+\\go\\src\\.*\.go$`')
+        self.assertEqual((template['start'], template['end']), (19, 28))
+        self.assertEqual((argument['start'], argument['end']), (32, 32))
+
+        # Nothing inside the raw strings is tokenized
+        for raw_string in (usage, template):
+            inner = [t for t in tokens if raw_string['start'] < t['start'] <= raw_string['end']]
+            self.assertEqual(inner, [], f"Tokens found inside raw string on line {raw_string['start']}")
+        self.assertNotIn('raw_string_literal_content', [t['type'] for t in tokens])
+        self.assertNotIn('Generated', [t['text'] for t in tokens])
+
+        # Lines after the literals are still mapped correctly
+        main = next(t for t in tokens if t['type'] == 'function_declaration' and t['text'].startswith('func main()'))
+        self.assertEqual((main['start'], main['end']), (30, 34))
+
+    def test_go_embedding_sample(self):
+        """Test that embedded fields and interfaces, and inline structs and interfaces, get their own kinds."""
+        self._test_sample_file("sample_embedding.go", "go", 50)
+
+        file_path = self.sample_files_dir / "sample_embedding.go"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        composition_kinds = {
+            'embedded_field', 'embedded_pointer_field', 'embedded_type', 'embedded_interface',
+            'anonymous_struct_type', 'anonymous_interface_type',
+        }
+        sequence = [(t['type'], t['text'].split('\n')[0]) for t in tokens if t['type'] in composition_kinds]
+
+        self.assertEqual(sequence, [
+            ('embedded_field', 'http.Handler'),
+            ('embedded_type', 'http.Handler'),
+            ('embedded_pointer_field', '*log.Logger'),
+            ('embedded_type', 'log.Logger'),
+            ('anonymous_struct_type', 'struct {'),
+            ('anonymous_struct_type', 'struct{ count int }'),
+            ('embedded_interface', 'io.Reader'),
+            ('embedded_interface', 'io.Closer'),
+            ('anonymous_interface_type', 'interface{ WriteString(s string) (int, error) }'),
+            ('anonymous_struct_type', 'struct {'),
+        ])
+
+        # Named fields keep their kind, nested ones included
+        fields = [t['text'].split()[0] for t in tokens if t['type'] == 'field_declaration']
+        self.assertEqual(fields, ['mu', 'stats', 'hits', 'errors', 'count', 'Addr', 'Port'])
+
+        # The declared types keep their kind, and the inline ones span up to their closing brace
+        self.assertEqual([t['start'] for t in tokens if t['type'] in ('struct_type', 'interface_type')], [10, 21])
+        anonymous_structs = [(t['start'], t['end']) for t in tokens if t['type'] == 'anonymous_struct_type']
+        self.assertEqual(anonymous_structs, [(14, 17), (16, 16), (36, 39)])
+
     def test_go_mod_sample(self):
         """Test tokenization of Go module file."""
         self._test_sample_file("go.mod", "gomod", 5)
@@ -1760,10 +2357,73 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Java sample file."""
         self._test_sample_file("sample.java", "java", 80)
 
+    def test_java_modern_features_sample(self):
+        """Test records, switch expressions and text blocks of the modern Java sample."""
+        self._test_sample_file("sample_modern.java", "java", 60)
+
+        file_path = self.sample_files_dir / "sample_modern.java"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(types.count('record_declaration'), 1)
+        self.assertEqual(types.count('compact_constructor_declaration'), 1)
+        self.assertEqual(types.count('switch_expression'), 1)
+        self.assertEqual(types.count('switch_rule'), 3)
+        self.assertEqual([t['text'] for t in tokens if t['type'] == 'yield_statement'], ['yield none;'])
+
+        # The text block is a single token with correct line tracking
+        text_blocks = [t for t in tokens if t['type'] == 'text_block']
+        self.assertEqual(len(text_blocks), 1)
+        self.assertEqual((text_blocks[0]['start'], text_blocks[0]['end']), (31, 35))
+        self.assertIn('{ braces }', text_blocks[0]['text'])
+        self.assertEqual([t for t in tokens if 31 < t['start'] <= 35], [])
+
+        main = next(t for t in tokens if t['type'] == 'method_declaration' and 'void main' in t['text'])
+        self.assertEqual((main['start'], main['end']), (38, 45))
+
     def test_javascript_sample(self):
         """Test tokenization of JavaScript sample file."""
         self._test_sample_file("sample.js", "javascript", 70)
 
+    def test_javascript_template_literals_sample(self):
+        """Test template literal, tagged template and arrow token kinds of the JavaScript sample."""
+        self._test_sample_file("sample_template_literals.js", "javascript", 50)
+
+        file_path = self.sample_files_dir / "sample_template_literals.js"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+
+        # Nested interpolation three levels deep
+        literals = texts('template_literal')
+        self.assertEqual(len(literals), 7)
+        self.assertIn('`<${r}>`', literals)
+        self.assertIn('`roles: ${user.roles.map((r) => `<${r}>`).join(", ")}`', literals)
+        describe = next(t for t in literals if t.startswith('`${user.name} has'))
+        self.assertTrue(describe.endswith(': "no roles"}`'))
+
+        # Tagged template: the tag is kept apart from the literal
+        tagged = [t for t in tokens if t['type'] == 'tagged_template']
+        self.assertEqual(len(tagged), 1)
+        self.assertEqual(tagged[0]['start'], 11)
+        self.assertEqual(texts('template_tag'), ['sql'])
+        self.assertIn('`select * from users where id = ${id} and active = ${true}`', literals)
+
+        # Arrow functions emit a single arrow token
+        self.assertEqual(types.count('arrow_function'), 8)
+        self.assertEqual(texts('arrow'), ['=>'] * 8)
+
     def test_json_sample(self):
         """Test tokenization of JSON sample file."""
         self._test_sample_file("sample.json", "json", 20)
@@ -1772,10 +2432,130 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Julia sample file."""
         self._test_sample_file("sample.jl", "julia", 50)
 
+    def test_jupyter_notebook_sample(self):
+        """Test that only the code cells of a notebook are tokenized, with a cell to line mapping."""
+        file_path = self.sample_files_dir / "sample_analysis.ipynb"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        self.assertEqual(self.service._detect_language(file_path), "notebook")
+        self.assertEqual(self.service.detect_file_language(file_path, content), "python")
+
+        notebook = self.service.extract_notebook(content)
+        self.assertEqual(notebook.language, "python")
+        lines = notebook.source.splitlines()
+        self.assertEqual(lines[0], '# %matplotlib inline')
+        self.assertEqual(lines[3], 'def average(grades):')
+        self.assertNotIn('Grades analysis', notebook.source)
+        self.assertNotIn('Alice 15.0', notebook.source)
+
+        # Cell index (markdown cells included) to extracted line mapping
+        self.assertEqual(
+            [(cell.cell_index, cell.start_line, cell.end_line) for cell in notebook.cells],
+            [(1, 0, 1), (2, 3, 7), (4, 9, 9)],
+        )
+        self.assertEqual(notebook.cell_for_line(4).cell_index, 2)
+        self.assertIsNone(notebook.cell_for_line(8))
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(types.count('function_definition'), 1)
+        self.assertEqual(types.count('list_comprehension'), 1)
+        self.assertEqual([t for t in tokens if 'nbformat' in t['text'] or 'kernelspec' in t['text']], [])
+
+        functions = self.service.extract_functions_with_positions(content, file_path)
+        self.assertEqual([(f['start_line'], f['end_line']) for f in functions.values()], [(3, 4)])
+
+    def test_jupyter_notebook_metadata_does_not_change_tokens(self):
+        """Test that notebooks with the same code but different metadata and outputs tokenize identically."""
+        def notebook(code, kernel_name, outputs):
+            return json.dumps({
+                'cells': [
+                    {'cell_type': 'markdown', 'metadata': {}, 'source': ['# Title']},
+                    {'cell_type': 'code', 'metadata': {'tags': ['x']}, 'outputs': outputs, 'source': code},
+                ],
+                'metadata': {'kernelspec': {'name': kernel_name, 'language': 'python'}},
+                'nbformat': 4,
+                'nbformat_minor': 5,
+            })
+
+        code = 'total = sum(values)\nprint(total)'
+        tokens1 = self.service.tokenize(notebook(code, 'python3', []), Path("a.ipynb"))
+        tokens2 = self.service.tokenize(
+            notebook(code, 'conda-env-ds-py', [{'output_type': 'stream', 'text': ['42']}]), Path("b.ipynb")
+        )
+        self.assertGreater(len(tokens1), 0)
+        self.assertEqual(tokens1, tokens2)
+
+    def test_jupyter_notebook_kernel_routing(self):
+        """Test that non Python notebooks use the language of their kernel and unknown kernels are rejected."""
+        r_notebook = json.dumps({
+            'cells': [{'cell_type': 'code', 'metadata': {}, 'outputs': [], 'source': 'x <- c(1, 2, 3)\nmean(x)'}],
+            'metadata': {'kernelspec': {'name': 'ir', 'language': 'R'}, 'language_info': {'name': 'R'}},
+            'nbformat': 4,
+        })
+        self.assertEqual(self.service.detect_file_language(Path("stats.ipynb"), r_notebook), "r")
+        self.assertEqual(self.service.extract_notebook(r_notebook).language, "r")
+        self.assertEqual(
+            self.service.get_notebook_analysis_path(Path("/repo/stats.ipynb"), "r"), Path("/repo/stats.ipynb.r")
+        )
+        self.assertGreater(len(self.service.tokenize(r_notebook, Path("stats.ipynb"))), 0)
+
+        unknown = json.dumps({
+            'cells': [{'cell_type': 'code', 'metadata': {}, 'outputs': [], 'source': 'Print[1]'}],
+            'metadata': {'kernelspec': {'name': 'wolframlanguage', 'language': 'Wolfram Language'}},
+            'nbformat': 4,
+        })
+        with self.assertRaises(UnsupportedNotebookLanguageException) as context:
+            self.service.tokenize(unknown, Path("math.ipynb"))
+        self.assertEqual(context.exception.detail['error_type'], 'unsupported_notebook_language')
+        self.assertEqual(context.exception.kernel_language, 'wolfram language')
+
+        with self.assertRaises(InvalidNotebookException):
+            self.service.extract_notebook('{"cells": ')
+
     def test_kotlin_sample(self):
         """Test tokenization of Kotlin sample file."""
         self._test_sample_file("sample.kt", "kotlin", 60)
 
+    def test_kotlin_data_class_sample(self):
+        """Test data class, string template, nullable, extension function and trailing lambda tokens."""
+        self._test_sample_file("sample_data_class.kt", "kotlin", 60)
+
+        file_path = self.sample_files_dir / "sample_data_class.kt"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        # Kotlin is never routed to the Java grammar
+        self.assertEqual(self.service.detect_file_language(file_path, content), "kotlin")
+        self.assertEqual(self.service.detect_file_language(Path("build.gradle.kts"), "plugins { java }"), "kotlin")
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        self.assertNotIn('UNKNOWN', types)
+
+        self.assertEqual(types.count('data_class_declaration'), 1)
+        self.assertIn('data class Person', texts('data_class_declaration')[0])
+        self.assertEqual(types.count('class_declaration'), 1)
+
+        self.assertEqual(texts('string_template'), ['"$name is ${age} years old"', '"Found: ${found?.name.orUnknown()}"'])
+        self.assertEqual(texts('template_identifier'), ['name'])
+        self.assertEqual(types.count('template_substitution'), 2)
+
+        extensions = texts('extension_function_declaration')
+        self.assertEqual(len(extensions), 2)
+        self.assertTrue(extensions[0].startswith('fun Person.describe()'))
+        self.assertTrue(extensions[1].startswith('fun String?.orUnknown()'))
+
+        self.assertGreaterEqual(types.count('nullable_type'), 4)
+        self.assertEqual(types.count('trailing_lambda'), 3)
+
     def test_lua_sample(self):
         """Test tokenization of Lua sample file."""
         self._test_sample_file("sample.lua", "lua", 40)
@@ -1784,6 +2564,32 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Makefile sample file."""
         self._test_sample_file("Makefile", "make", 15)
 
+    def test_makefile_deploy_sample(self):
+        """Test target, prerequisite and recipe line tokens of the deploy Makefile sample."""
+        self._test_sample_file("sample_deploy.mk", "make", 30)
+
+        file_path = self.sample_files_dir / "sample_deploy.mk"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        for target in ['.PHONY', 'all', 'test', 'deploy', 'clean']:
+            self.assertIn(target, texts('target'))
+        for prerequisite in ['main.go', 'config.go', 'all']:
+            self.assertIn(prerequisite, texts('prerequisite'))
+        self.assertNotIn('main.go', texts('target'))
+
+        recipe_lines = texts('recipe_line')
+        self.assertEqual(len(recipe_lines), 5)
+        self.assertEqual(recipe_lines[1], 'go build -o $@ .')
+        self.assertEqual(types.count('rule'), 6)
+
     def test_markdown_sample(self):
         """Test tokenization of Markdown sample file."""
         self._test_sample_file("sample.md", "markdown", 30)
@@ -1808,10 +2614,163 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of PHP sample file."""
         self._test_sample_file("sample.php", "php", 60)
 
+    def test_html_embedded_regions_sample(self):
+        """Test that scripts, styles and inline event handlers of an HTML file are tokenized in their language."""
+        file_path = self.sample_files_dir / "sample_game.html"
+        content = self._read_sample("sample_game.html")
+
+        regions = self.service.html_region_extractor.extract(content)
+        # The external script and the JSON settings are skipped
+        self.assertEqual([region.kind for region in regions], ['style', 'event_handler', 'script'])
+        self.assertEqual([region.language for region in regions], ['css', 'javascript', 'javascript'])
+        self.assertEqual([region.start_line for region in regions], [7, 15, 18])
+        self.assertNotIn('confetti', ''.join(region.source for region in regions))
+        self.assertNotIn('maxAttempts', ''.join(region.source for region in regions))
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+        self.assertNotIn('ERROR', types)
+
+        # Markup and JavaScript tokens in one stream, in line order, with the lines of the HTML file
+        self.assertIn('start_tag', types)
+        self.assertIn('template_literal', types)
+        self.assertEqual([t['start'] for t in tokens], sorted(t['start'] for t in tokens))
+        declarations = [t for t in tokens if t['type'] == 'function_declaration']
+        self.assertEqual([t['start'] for t in declarations], [22, 32])
+        handler_calls = [t for t in tokens if t['type'] == 'call_expression' and t['text'].startswith('checkGuess(')]
+        self.assertEqual([t['start'] for t in handler_calls], [15])
+
+        # The script code is no longer part of the markup tokens
+        self.assertEqual([t for t in tokens if t['type'] == 'raw_text'], [])
+
+        # Functions of the scripts, with the lines of the HTML file
+        functions = self.service.extract_functions_with_positions(content, file_path)
+        self.assertEqual(
+            sorted((f['function_name'], f['start_line']) for f in functions.values()),
+            [('checkGuess', 22), ('showHint', 32)],
+        )
+        self.assertTrue(all(f['language'] == 'javascript' for f in functions.values()))
+
+    def test_php_interleaved_html_sample(self):
+        """Test that only PHP regions of a page mixing HTML and PHP are tokenized, with correct lines."""
+        self._test_sample_file("sample_page.php", "php", 30)
+
+        file_path = self.sample_files_dir / "sample_page.php"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+
+        # HTML, PHP tags and the page wide root token are excluded
+        for excluded in ['text', 'text_interpolation', 'php_tag', 'program']:
+            self.assertNotIn(excluded, types)
+        for html in ['<!DOCTYPE', '<title>', '<h1>', '<li>', '</body>']:
+            self.assertEqual([t for t in tokens if html in t['text']], [], html)
+
+        # One continuous stream keeping the lines of the original file
+        students = [t for t in tokens if t['type'] == 'variable_name' and t['text'] == '$students']
+        self.assertEqual(students[0]['start'], 8)
+        count = next(t for t in tokens if t['type'] == 'variable_name' and t['text'] == '$count')
+        self.assertEqual(count['start'], 23)
+        self.assertEqual(types.count('foreach_statement'), 1)
+
+        # Variables are single tokens
+        self.assertTrue(all(text.startswith('$') for text in texts('variable_name')))
+        self.assertIn('$threshold', texts('variable_name'))
+
+        # The three comment styles
+        self.assertEqual(
+            texts('comment'), ['// Load the students', '# Passing grade', '/* Summary rendered as a heredoc */']
+        )
+
+        # Heredocs keep their interpolations, nowdocs are a single token
+        self.assertEqual(types.count('heredoc'), 1)
+        heredoc = next(t for t in tokens if t['type'] == 'heredoc')
+        self.assertEqual((heredoc['start'], heredoc['end']), (24, 26))
+        self.assertIn('$threshold', [t['text'] for t in tokens if t['type'] == 'variable_name' and t['start'] == 25])
+        nowdocs = texts('nowdoc')
+        self.assertEqual(len(nowdocs), 1)
+        self.assertIn('SELECT name, grade FROM students', nowdocs[0])
+        self.assertEqual([t for t in tokens if t['start'] == 28], [])
+
     def test_python_sample(self):
         """Test tokenization of Python sample file."""
         self._test_sample_file("sample.py", "python", 60)
 
+    def test_python_decorators_and_f_strings_sample(self):
+        """Test decorator and f-string token kinds of the Python decorators sample file."""
+        self._test_sample_file("sample_decorators.py", "python", 50)
+
+        file_path = self.sample_files_dir / "sample_decorators.py"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertEqual(texts('ERROR'), [])
+
+        # Decorators: one decorator token plus the dotted name
+        self.assertEqual(len(texts('decorator')), 6)
+        self.assertEqual(
+            texts('decorator_name'),
+            ['functools.wraps', 'dataclass', 'property', 'staticmethod', 'log_calls', 'pytest.fixture'],
+        )
+        self.assertIn('(scope="module")', texts('argument_list'))
+
+        # F-strings, including the triple quoted one and the nested one
+        f_strings = [t for t in tokens if t['type'] == 'f_string']
+        self.assertEqual(len(f_strings), 5)
+        self.assertEqual(texts('type_conversion'), ['!r', '!r'])
+        self.assertIn(':<12', texts('format_specifier'))
+        self.assertIn(':>6.2f', texts('format_specifier'))
+        self.assertIn("'pass'", texts('string'))
+
+        triple = next(t for t in f_strings if t['text'].startswith('f"""'))
+        self.assertEqual(triple['end'] - triple['start'], 4)
+        inner_calls = [t['text'] for t in tokens if t['type'] == 'call' and triple['start'] <= t['start'] <= triple['end']]
+        self.assertIn('len(grades)', inner_calls)
+        self.assertTrue(any(t['type'] == 'lambda' and triple['start'] <= t['start'] <= triple['end'] for t in tokens))
+        self.assertGreaterEqual(len(texts('f_string_interpolation')), 10)
+
+    def test_python_async_sample(self):
+        """Test async/await and walrus token kinds of the Python async worker pool sample."""
+        self._test_sample_file("sample_async.py", "python", 50)
+
+        file_path = self.sample_files_dir / "sample_async.py"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(
+            [t['text'].split('(')[0] for t in tokens if t['type'] == 'async_function_definition'],
+            ['async def worker', 'async def main', 'async def numbers'],
+        )
+        self.assertEqual(
+            [t['text'].split('(')[0] for t in tokens if t['type'] == 'function_definition'], ['def collect']
+        )
+        self.assertEqual(types.count('await'), 7)
+        self.assertEqual(types.count('async_with_statement'), 1)
+        self.assertEqual(types.count('async_for_statement'), 1)
+        self.assertEqual(types.count('for_statement'), 3)
+
+        # The walrus operator is a single token inside its named expression
+        self.assertEqual([t['text'] for t in tokens if t['type'] == 'walrus_operator'], [':=', ':='])
+        self.assertEqual(types.count('named_expression'), 2)
+        walrus_index = types.index('walrus_operator')
+        self.assertEqual(tokens[walrus_index - 2]['type'], 'named_expression')
+
     def test_r_sample(self):
         """Test tokenization of R sample file."""
         self._test_sample_file("sample.r", "r", 40)
@@ -1820,10 +2779,85 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Ruby sample file."""
         self._test_sample_file("sample.rb", "ruby", 50)
 
+    def test_ruby_report_sample(self):
+        """Test symbol, interpolation, block, heredoc and %w[] tokens of the Ruby report sample."""
+        self._test_sample_file("sample_report.rb", "ruby", 80)
+
+        file_path = self.sample_files_dir / "sample_report.rb"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+
+        self.assertEqual(texts('symbol'), [':grade', ':name', ':grade', ':grade', ':passed', ':failed'])
+        self.assertEqual(types.count('hash_key_symbol'), 2)
+        self.assertEqual(texts('interpolated_string'), ['"#{student[:name]} passed with #{student[:grade]}"'])
+        self.assertEqual(types.count('interpolation'), 3)
+        self.assertEqual(types.count('do_block'), 1)
+        self.assertEqual(types.count('block'), 2)
+        self.assertEqual(types.count('block_parameters'), 3)
+        self.assertEqual(texts('string_array'), ['%w[name grade passed]'])
+
+        # The squiggly heredoc body is a heredoc token, its SQL is only heredoc content
+        self.assertEqual(texts('heredoc_beginning'), ['<<~SQL'])
+        heredocs = [t for t in tokens if t['type'] == 'heredoc']
+        self.assertEqual(len(heredocs), 1)
+        self.assertEqual(heredocs[0]['end'], 17)
+        self.assertIn('ORDER BY grade DESC', heredocs[0]['text'])
+        sql_tokens = [t for t in tokens if t['start'] >= 13 and t['end'] <= 17 and 'ORDER BY' in t['text']]
+        self.assertTrue(all(t['type'] in {'heredoc', 'heredoc_content'} for t in sql_tokens))
+
+        prepared = self.service.similarity_service.prepare_for_similarity(tokens)
+        self.assertEqual({t['text'] for t in prepared if t['type'] == 'heredoc_content'}, {'<STRING>'})
+
+    def test_ruby_filenames_detection(self):
+        """Test that Gemfile and Rakefile are detected as Ruby."""
+        self.assertEqual(self.service._detect_language(Path("Gemfile")), "ruby")
+        self.assertEqual(self.service._detect_language(Path("project/Rakefile")), "ruby")
+        self.assertEqual(self.service._detect_language(Path("tasks/deploy.rake")), "ruby")
+
     def test_rust_sample(self):
         """Test tokenization of Rust sample file."""
         self._test_sample_file("sample.rs", "rust", 70)
 
+    def test_rust_concurrency_sample(self):
+        """Test macro, lifetime, attribute, match and raw string tokens of the Rust concurrency sample."""
+        self._test_sample_file("sample_concurrency.rs", "rust", 100)
+
+        file_path = self.sample_files_dir / "sample_concurrency.rs"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+
+        # Macro invocations are a distinct kind carrying the macro name
+        self.assertEqual(types.count('macro_invocation'), 7)
+        self.assertEqual(texts('macro_name'), ['format', 'println', 'vec', 'println', 'println', 'vec', 'assert_eq'])
+
+        self.assertGreaterEqual(types.count('lifetime'), 5)
+        self.assertEqual(set(texts('lifetime')), {"'a", "'static"})
+        self.assertEqual(texts('attribute_item'), ['#[derive(Debug, Clone, PartialEq)]'])
+        self.assertEqual(types.count('match_arm'), 3)
+        self.assertEqual(types.count('closure_expression'), 2)
+
+        # Raw strings are a single token, their content is not tokenized
+        self.assertEqual(
+            texts('raw_string_literal'), ['r#"{"name": "Alice", "note": "uses \\"quotes\\" and # signs"}"#']
+        )
+        self.assertNotIn('"Alice", "note"', ''.join(texts('string_literal')))
+
     def test_scala_sample(self):
         """Test tokenization of Scala sample file."""
         self._test_sample_file("sample.scala", "scala", 80)
@@ -1836,6 +2870,55 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of SQL sample file."""
         self._test_sample_file("sample.sql", "sql", 150)
 
+    def test_sql_schema_sample(self):
+        """Test quoted identifier, string literal, comment and dollar-quoted tokens of the SQL schema sample."""
+        self._test_sample_file("sample_schema.sql", "sql", 50)
+
+        file_path = self.sample_files_dir / "sample_schema.sql"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(texts('quoted_identifier'), ['"Books"', '"Books"'])
+        self.assertEqual(texts('string_literal'), ["'Anonymous'"])
+        self.assertEqual(
+            texts('comment'), ['-- Library schema and queries (PostgreSQL)', '/* Books published after 2000, with their author */']
+        )
+
+        # The function body is a single literal spanning its lines
+        bodies = [t for t in tokens if t['type'] == 'dollar_quoted_string']
+        self.assertEqual(len(bodies), 1)
+        self.assertEqual((bodies[0]['start'], bodies[0]['end']), (21, 25))
+        self.assertIn('WHERE author_id = author;', bodies[0]['text'])
+        self.assertEqual([t for t in tokens if 22 <= t['start'] <= 24], [])
+
+    def test_sql_formatting_insensitive_tokens(self):
+        """Test that differently formatted but identical queries produce identical token streams."""
+        query1 = 'SELECT b.title, a.name FROM "Books" b JOIN authors a ON a.id = b.author_id WHERE b.published > 2000;'
+        query2 = '''select b.title,
+       a.name
+  from "Books" b
+       join authors a
+         on a.id = b.author_id
+ where b.published > 2000;'''
+
+        tokens1 = self.service.tokenize(query1, Path("query1.sql"))
+        tokens2 = self.service.tokenize(query2, Path("query2.sql"))
+
+        self.assertGreater(len(tokens1), 10)
+        self.assertEqual([(t['type'], t['text']) for t in tokens1], [(t['type'], t['text']) for t in tokens2])
+
+        # Keywords are upper-cased, whitespace between clauses is normalized
+        statement = next(t for t in tokens2 if t['type'] == 'statement')
+        self.assertTrue(statement['text'].startswith('SELECT b . title , a . name FROM "Books" b JOIN'))
+        self.assertNotIn('\n', statement['text'])
+
     def test_svelte_sample(self):
         """Test tokenization of Svelte sample file."""
         self._test_sample_file("sample.svelte", "svelte", 200)
@@ -1844,6 +2927,54 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of Swift sample file."""
         self._test_sample_file("sample.swift", "swift", 180)
 
+    def test_swift_protocol_sample(self):
+        """Test optional, closure, interpolation, guard/defer and multiline string tokens of the Swift sample."""
+        self._test_sample_file("sample_protocol.swift", "swift", 80)
+
+        file_path = self.sample_files_dir / "sample_protocol.swift"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(types.count('protocol_declaration'), 1)
+
+        # Optionals
+        self.assertGreaterEqual(types.count('optional_type'), 2)
+        self.assertEqual(texts('force_unwrap'), ['!'])
+
+        # Closures
+        self.assertEqual(texts('trailing_closure'), ['{ $0.age >= 18 }'])
+        self.assertIn('{ person in person.name }', texts('closure'))
+
+        # Interpolated single line strings and their interpolations
+        self.assertEqual(
+            texts('interpolated_string'), ['"Hello, \\(name)! You are \\(age) years old."', '"\\(person.name) <\\(email)>"']
+        )
+        self.assertEqual(types.count('interpolation'), 6)
+
+        # The multiline string is a single token spanning its lines, interpolations included
+        multiline = [t for t in tokens if t['type'] == 'multi_line_string_literal']
+        self.assertEqual(len(multiline), 1)
+        self.assertEqual((multiline[0]['start'], multiline[0]['end']), (38, 41))
+        self.assertIn('First: \\(first.greet())', multiline[0]['text'])
+        self.assertEqual(len([t for t in tokens if t['type'] == 'interpolation' and 38 <= t['start'] <= 41]), 2)
+
+        # guard / defer
+        guard = next(t for t in tokens if t['type'] == 'guard_statement')
+        self.assertEqual((guard['start'], guard['end']), (20, 22))
+        self.assertEqual(types.count('defer_statement'), 1)
+
+    def test_package_swift_detection(self):
+        """Test that Package.swift manifests are detected as Swift by filename."""
+        self.assertEqual(self.service._detect_language(Path("Package.swift")), "swift")
+        self.assertEqual(self.service.language_mapping["Package.swift"], "swift")
+
     def test_toml_sample(self):
         """Test tokenization of TOML sample file."""
         self._test_sample_file("sample.toml", "toml", 120)
@@ -1852,6 +2983,46 @@ class TestTokenizationServiceWithSampleFiles(unittest.TestCase):
         """Test tokenization of TypeScript sample file."""
         self._test_sample_file("sample.ts", "typescript", 200)
 
+    def test_typescript_generics_sample(self):
+        """Test TypeScript specific token kinds of the TypeScript generics sample."""
+        self._test_sample_file("sample_generics.ts", "typescript", 80)
+
+        file_path = self.sample_files_dir / "sample_generics.ts"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        def texts(kind):
+            return [t['text'] for t in tokens if t['type'] == kind]
+
+        self.assertNotIn('ERROR', types)
+        self.assertEqual(types.count('interface_declaration'), 1)
+        self.assertEqual(types.count('enum_declaration'), 1)
+        self.assertEqual(types.count('type_alias_declaration'), 1)
+        self.assertEqual(texts('type_parameter_list'), ['<K, V>', '<T, U>', '<K extends string, V>'])
+        self.assertIn('<number, number>', texts('type_arguments'))
+        self.assertEqual(texts('type_assertion'), ['raw as { name: string; age: number }'])
+        self.assertEqual(texts('decorator_name'), ['logged'])
+        self.assertGreaterEqual(types.count('type_annotation'), 10)
+        self.assertIn('arrow', types)
+
+    def test_tsx_sample(self):
+        """Test that TSX files are parsed with the TSX grammar and reported as TypeScript."""
+        self._test_sample_file("sample_component.tsx", "typescript", 30)
+
+        file_path = self.sample_files_dir / "sample_component.tsx"
+        with open(file_path, 'r', encoding='utf-8') as f:
+            content = f.read()
+
+        tokens = self.service.tokenize(content, file_path)
+        types = [t['type'] for t in tokens]
+
+        self.assertNotIn('ERROR', types)
+        self.assertIn('jsx_element', types)
+        self.assertEqual([t['text'] for t in tokens if t['type'] == 'type_parameter_list'], ['<T extends CounterProps>'])
+
     def test_vue_sample(self):
         """Test tokenization of Vue sample file."""
         self._test_sample_file("sample.vue", "vue", 250)
@@ -0,0 +1,83 @@
+"""
+Tests for TokenVerifier
+"""
+
+import unittest
+from uuid import uuid4
+
+from app.shared.authorization import InvalidToken, Principal, Role, TokenVerifier, bearer_token
+
+
+class TestTokenVerifier(unittest.TestCase):
+    """Unit tests for the verification of the bearer tokens and their scoping claims."""
+
+    def setUp(self):
+        """Set up test fixtures."""
+        self.verifier = TokenVerifier('a-secret-of-the-identity-provider', issuer='pamp-auth', audience='submissions')
+        self.now = 1_700_000_000
+        self.grader = Principal(subject='grader-1', role=Role.GRADER, assignment_ids=frozenset({uuid4(), uuid4()}))
+
+    def test_issued_token_round_trips(self):
+        """Test that the claims of a token give back its caller."""
+        student = Principal(subject='student-1', role=Role.STUDENT, student_id=uuid4(), group_ids=frozenset({uuid4()}))
+
+        for principal in (self.grader, student):
+            with self.subTest(role=principal.role):
+                token = self.verifier.issue(principal, now=self.now)
+
+                self.assertEqual(self.verifier.verify(token, now=self.now + 60), principal)
+
+    def test_tampered_token_is_rejected(self):
+        """Test that a token signed with another secret, or whose claims were changed, is rejected."""
+        other = TokenVerifier('another-secret', issuer='pamp-auth', audience='submissions')
+        header, payload, signature = self.verifier.issue(self.grader, now=self.now).split('.')
+        admin_payload = other.issue(Principal(subject='grader-1', role=Role.ADMIN), now=self.now).split('.')[1]
+
+        for token in (other.issue(self.grader, now=self.now), f'{header}.{admin_payload}.{signature}', 'not-a-jwt'):
+            with self.subTest(token=token[:20]):
+                with self.assertRaises(InvalidToken):
+                    self.verifier.verify(token, now=self.now)
+
+    def test_expiry_issuer_and_audience(self):
+        """Test that an expired token, or one of another issuer or audience, is rejected."""
+        token = self.verifier.issue(self.grader, ttl_seconds=60, now=self.now)
+
+        self.verifier.verify(token, now=self.now + 60 + 29)
+        with self.assertRaises(InvalidToken):
+            self.verifier.verify(token, now=self.now + 60 + 31)
+        for verifier in (
+            TokenVerifier('a-secret-of-the-identity-provider', issuer='other', audience='submissions'),
+            TokenVerifier('a-secret-of-the-identity-provider', issuer='pamp-auth', audience='other'),
+        ):
+            with self.subTest(issuer=verifier.issuer, audience=verifier.audience):
+                with self.assertRaises(InvalidToken):
+                    verifier.verify(token, now=self.now)
+
+    def test_student_token_needs_a_student_id(self):
+        """Test that the token of a student without student_id is rejected."""
+        token = self.verifier.issue(Principal(subject='student-1', role=Role.STUDENT), now=self.now)
+
+        with self.assertRaises(InvalidToken):
+            self.verifier.verify(token, now=self.now)
+
+    def test_tenant_claim(self):
+        """Test that the tenant of a token comes back with its caller, the default one without claim."""
+        acme = Principal(subject='admin-1', role=Role.ADMIN, tenant_id='acme')
+
+        self.assertEqual(self.verifier.verify(self.verifier.issue(acme, now=self.now), now=self.now), acme)
+        grader = self.verifier.verify(self.verifier.issue(self.grader, now=self.now), now=self.now)
+        self.assertEqual(grader.tenant_id, 'default')
+        token = self.verifier.issue(Principal(subject='admin-1', role=Role.ADMIN, tenant_id='../acme'), now=self.now)
+        with self.assertRaises(InvalidToken):
+            self.verifier.verify(token, now=self.now)
+
+    def test_bearer_token(self):
+        """Test that only the Bearer scheme of the Authorization header is accepted."""
+        self.assertEqual(bearer_token('Bearer abc.def.ghi'), 'abc.def.ghi')
+        self.assertIsNone(bearer_token(None))
+        with self.assertRaises(InvalidToken):
+            bearer_token('Basic dXNlcjpwYXNz')
+
+
+if __name__ == '__main__':
+    unittest.main()
@@ -0,0 +1,55 @@
+"""
+Tests for the error envelope
+"""
+
+import unittest
+
+from app.shared.error_envelope import as_code, error_code, error_envelope, validation_error_details
+
+
+class TestErrorEnvelope(unittest.TestCase):
+    """Unit tests for the codes, messages and details of the error responses."""
+
+    def test_code_from_error_type(self):
+        """Test that the error_type of a structured detail is the code, before the internal type and the status."""
+        detail = {'error_type': 'upload_too_large', 'message': 'Too large', 'limit': 10}
+
+        self.assertEqual(error_code(422, detail, 'database_error'), 'UPLOAD_TOO_LARGE')
+        self.assertEqual(error_code(500, 'Failed', 'database_error'), 'DATABASE_ERROR')
+        self.assertEqual(error_code(404, 'Submission not found'), 'NOT_FOUND')
+        self.assertEqual(error_code(502), 'INTERNAL_ERROR')
+        self.assertEqual(as_code('ValueError'), 'VALUE_ERROR')
+
+    def test_envelope_keeps_former_detail(self):
+        """Test that the envelope splits a structured detail and keeps it whole for the former clients."""
+        detail = {'error_type': 'upload_too_large', 'message': 'Too large', 'limit': 10}
+
+        envelope = error_envelope(422, detail, 'abc')
+
+        self.assertEqual(
+            envelope,
+            {
+                'code': 'UPLOAD_TOO_LARGE',
+                'message': 'Too large',
+                'details': {'limit': 10},
+                'request_id': 'abc',
+                'detail': detail,
+            },
+        )
+        self.assertEqual(error_envelope(404, None, 'abc')['message'], 'Not Found')
+
+    def test_every_invalid_field_listed(self):
+        """Test that the details of a validation failure list every invalid field."""
+        errors = [
+            {'loc': ('body', 'project_uuid'), 'msg': 'field required', 'type': 'value_error.missing'},
+            {'loc': ('query', 'limit'), 'msg': 'not a valid integer', 'type': 'type_error.integer'},
+        ]
+
+        details = validation_error_details(errors)
+
+        self.assertEqual([field['field'] for field in details['fields']], ['body.project_uuid', 'query.limit'])
+        self.assertEqual(details['fields'][1]['message'], 'not a valid integer')
+
+
+if __name__ == '__main__':
+    unittest.main()
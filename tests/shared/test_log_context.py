@@ -0,0 +1,95 @@
+"""
+Tests for the log context
+"""
+
+import json
+import logging
+import threading
+import unittest
+
+from app.shared.log_context import (
+    JsonLogFormatter,
+    LogContextFilter,
+    TextLogFormatter,
+    current_log_fields,
+    log_context,
+    log_stage,
+)
+
+
+class RecordingHandler(logging.Handler):
+    """Handler keeping the records it is given"""
+
+    def __init__(self):
+        super().__init__()
+        self.records = []
+        self.addFilter(LogContextFilter())
+
+    def emit(self, record):
+        self.records.append(record)
+
+
+class TestLogContext(unittest.TestCase):
+    """Unit tests for the fields of the logs of a context and their formats."""
+
+    def setUp(self):
+        self.handler = RecordingHandler()
+        self.logger = logging.getLogger('tests.log_context')
+        self.logger.setLevel(logging.INFO)
+        self.logger.addHandler(self.handler)
+        self.addCleanup(self.logger.removeHandler, self.handler)
+
+    def test_fields_nested_then_restored(self):
+        """Test that the fields of nested contexts add up, the None ones left out, and are reset after the block."""
+        with log_context(correlation_id='req-1'):
+            with log_context(submission_id='sub-1', job_id=None):
+                self.assertEqual(current_log_fields(), {'correlation_id': 'req-1', 'submission_id': 'sub-1'})
+            self.assertEqual(current_log_fields(), {'correlation_id': 'req-1'})
+        self.assertEqual(current_log_fields(), {})
+
+    def test_fields_not_shared_between_threads(self):
+        """Test that a thread started outside a context logs without its fields."""
+        seen = []
+        with log_context(correlation_id='req-1'):
+            thread = threading.Thread(target=lambda: seen.append(current_log_fields()))
+            thread.start()
+            thread.join()
+
+        self.assertEqual(seen, [{}])
+
+    def test_stage_logged_with_duration(self):
+        """Test that a stage logs its start and its end with its duration, within its context."""
+        with log_context(submission_id='sub-1'):
+            with log_stage(self.logger, 'extracting'):
+                pass
+
+        started, finished = self.handler.records
+        self.assertEqual(started.log_fields, {'submission_id': 'sub-1', 'stage': 'extracting'})
+        self.assertIn('finished', finished.getMessage())
+        self.assertGreaterEqual(finished.duration_ms, 0)
+
+    def test_stage_failure_logged_and_raised(self):
+        """Test that a failing stage logs its failure and raises its error."""
+        with self.assertRaises(ValueError):
+            with log_stage(self.logger, 'tokenizing'):
+                raise ValueError('unsupported language')
+
+        self.assertEqual(self.handler.records[-1].levelno, logging.WARNING)
+
+    def test_json_and_text_formats(self):
+        """Test that a record is formatted as a JSON object with its fields and extras, or as text with its fields."""
+        with log_context(correlation_id='req-1'):
+            self.logger.info('Fetched %d files', 3, extra={'duration_ms': 12.5})
+        record = self.handler.records[-1]
+
+        entry = json.loads(JsonLogFormatter().format(record))
+        text = TextLogFormatter('%(levelname)s %(message)s').format(record)
+
+        self.assertEqual(entry['message'], 'Fetched 3 files')
+        self.assertEqual(entry['correlation_id'], 'req-1')
+        self.assertEqual(entry['duration_ms'], 12.5)
+        self.assertEqual(text, 'INFO Fetched 3 files [correlation_id=req-1]')
+
+
+if __name__ == '__main__':
+    unittest.main()
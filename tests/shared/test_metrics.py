@@ -0,0 +1,72 @@
+"""
+Tests for PipelineMetrics
+"""
+
+import unittest
+
+from app.shared.deadlines import StageDeadline, StageTimeout
+from app.shared.metrics import PipelineMetrics, prometheus_client
+
+
+class FakePool:
+    """Analysis worker pool of a fixed load"""
+
+    def stats(self):
+        return {'queue_depth': 7, 'busy_workers': 2}
+
+
+class TestPipelineMetrics(unittest.TestCase):
+    """Unit tests for the metrics of the pipeline and their collection in the comparison processes."""
+
+    def setUp(self):
+        self.metrics = PipelineMetrics()
+
+    def test_collected_observations_replayed(self):
+        """Test that what is recorded within collect is kept apart, then counted once replayed."""
+        with self.metrics.collect() as observations:
+            self.metrics.record_token_cache_lookup(True)
+            self.metrics.record_token_cache_lookup(False)
+            self.metrics.observe_tokenization(None, 0.2)
+        self.assertEqual(self.metrics.token_cache_hit_ratio(), 0.0)
+        self.assertEqual(observations[2], ('tokenization', ('unknown',), 0.2))
+
+        self.metrics.replay(observations)
+        self.metrics.record_token_cache_lookup(True)
+
+        self.assertEqual(self.metrics.token_cache_hit_ratio(), 2 / 3)
+
+    def test_comparison_outcome(self):
+        """Test that a comparison raising is timed as failed, or timed out on a stage timeout."""
+        with self.metrics.collect() as observations:
+            with self.metrics.time_comparison():
+                pass
+            with self.assertRaises(ValueError):
+                with self.metrics.time_comparison():
+                    raise ValueError('unreadable submission')
+            with self.assertRaises(StageTimeout):
+                with self.metrics.time_comparison():
+                    raise StageTimeout(StageDeadline('comparison', 1.0))
+
+        self.assertEqual([labels for _, labels, _ in observations], [('completed',), ('failed',), ('timed_out',)])
+
+    @unittest.skipUnless(prometheus_client, 'prometheus_client is not installed')
+    def test_render(self):
+        """Test that a scrape exposes the metrics with their bounded labels and the load of the analysis queue."""
+        self.metrics.track_analysis_pool(FakePool())
+        self.metrics.observe_http_request('GET', '/submissions/{submission_id}', 404, 0.01)
+        self.metrics.observe_storage_operation('s3', 'get', 'not_found', 0.05)
+
+        content, content_type = self.metrics.render()
+        text = content.decode()
+
+        self.assertTrue(content_type.startswith('text/plain'))
+        self.assertIn(
+            'pamp_http_requests_total{method="GET",route="/submissions/{submission_id}",status="404"} 1.0', text
+        )
+        self.assertIn('operation="get"', text)
+        self.assertIn('pamp_analysis_queue_depth 7.0', text)
+        self.assertIn('pamp_analysis_jobs_in_flight 2.0', text)
+
+
+if __name__ == '__main__':
+    unittest.main()
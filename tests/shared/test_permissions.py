@@ -0,0 +1,57 @@
+"""
+Tests for authenticate
+"""
+
+import unittest
+from types import SimpleNamespace
+from unittest import mock
+
+from app.shared import permissions
+from app.shared.authorization import InvalidToken, Principal, Role, TokenVerifier
+
+
+class TestAuthenticate(unittest.TestCase):
+    """Unit tests for the authentication of the callers, refused unless enabled or explicitly disabled."""
+
+    def _settings(self, auth_enabled=False, auth_disabled=False):
+        settings = SimpleNamespace(
+            auth_enabled=auth_enabled,
+            auth_disabled=auth_disabled,
+            auth_jwt_secret=SimpleNamespace(get_secret_value=lambda: 'a-secret-of-the-identity-provider'),
+            auth_jwt_issuer=None,
+            auth_jwt_audience=None,
+            auth_clock_skew_seconds=30.0,
+        )
+        patcher = mock.patch.object(permissions, 'get_settings', return_value=settings)
+        patcher.start()
+        self.addCleanup(patcher.stop)
+
+    def test_refused_while_not_configured(self):
+        """Test that every request is refused while authentication is neither enabled nor disabled."""
+        self._settings()
+
+        with self.assertRaises(InvalidToken):
+            permissions.authenticate(None)
+        self.assertIn('every request is refused', permissions.authentication_warning())
+
+    def test_explicitly_disabled(self):
+        """Test that an explicit opt-out lets every caller through, with a warning at startup."""
+        self._settings(auth_disabled=True)
+
+        self.assertIsNone(permissions.authenticate(None))
+        self.assertIn('every caller is allowed everything', permissions.authentication_warning())
+
+    def test_enabled(self):
+        """Test that with authentication enabled the caller of a valid token is returned, without a warning."""
+        self._settings(auth_enabled=True, auth_disabled=True)
+        grader = Principal(subject='grader-1', role=Role.GRADER)
+        token = TokenVerifier('a-secret-of-the-identity-provider').issue(grader)
+
+        self.assertEqual(permissions.authenticate(f'Bearer {token}'), grader)
+        self.assertIsNone(permissions.authentication_warning())
+        with self.assertRaises(InvalidToken):
+            permissions.authenticate(None)
+
+
+if __name__ == '__main__':
+    unittest.main()
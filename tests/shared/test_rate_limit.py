@@ -0,0 +1,56 @@
+"""
+Tests for RateLimiter
+"""
+
+import unittest
+
+from app.shared.rate_limit import EXPENSIVE, STANDARD, InMemoryRateLimitStore, RateLimit, RateLimiter
+
+
+class TestRateLimiter(unittest.TestCase):
+    """Unit tests for the token buckets of the rate limits of the API clients."""
+
+    def setUp(self):
+        self.now = 1000.0
+        self.limits = {STANDARD: RateLimit(per_minute=60, burst=10), EXPENSIVE: RateLimit(per_minute=6, burst=2)}
+        self.limiter = RateLimiter(
+            InMemoryRateLimitStore(),
+            self.limits,
+            client_limits={'trusted-key': {EXPENSIVE: RateLimit(per_minute=60, burst=20)}},
+            clock=lambda: self.now,
+        )
+
+    def test_burst_then_refill(self):
+        """Test that a burst is let through, the next request being refused until the bucket refills."""
+        decisions = [self.limiter.check(EXPENSIVE, None, '10.0.0.1') for _ in range(3)]
+
+        self.assertEqual([decision.allowed for decision in decisions], [True, True, False])
+        self.assertEqual(decisions[1].headers()['X-RateLimit-Remaining'], '0')
+        refused = decisions[2].headers()
+        self.assertEqual((refused['X-RateLimit-Limit'], refused['Retry-After']), ('2', '10'))
+        self.assertEqual(refused['X-RateLimit-Reset'], '20')
+
+        # The expensive requests do not take from the bucket of the reads
+        self.assertTrue(self.limiter.check(STANDARD, None, '10.0.0.1').allowed)
+
+        self.now += 10
+        self.assertTrue(self.limiter.check(EXPENSIVE, None, '10.0.0.1').allowed)
+        self.assertFalse(self.limiter.check(EXPENSIVE, None, '10.0.0.1').allowed)
+
+    def test_client_credentials(self):
+        """Test that a configured credential has its own limits, the others being limited by IP address."""
+        for _ in range(2):
+            self.limiter.check(EXPENSIVE, None, '10.0.0.1')
+
+        trusted = [self.limiter.check(EXPENSIVE, 'trusted-key', '10.0.0.1') for _ in range(20)]
+        self.assertTrue(all(decision.allowed for decision in trusted))
+        self.assertEqual(trusted[0].limit, 20)
+        self.assertFalse(self.limiter.check(EXPENSIVE, 'trusted-key', '10.0.0.1').allowed)
+
+        # A credential not configured gets no bucket of its own
+        self.assertFalse(self.limiter.check(EXPENSIVE, 'made-up-key', '10.0.0.1').allowed)
+        self.assertTrue(self.limiter.check(EXPENSIVE, 'made-up-key', '10.0.0.2').allowed)
+
+
+if __name__ == '__main__':
+    unittest.main()